@@ -0,0 +1,315 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package admin exposes a runtime API on a dedicated listener, so the operators can inspect a
+running gateway and change its log level without restarting it.
+
+The listener is declared in the service extra config:
+
+	"extra_config": {
+		"github.com/luraproject/lura/admin": {
+			"listen_address": "127.0.0.1:9092",
+			"token": "s3cr3t"
+		}
+	}
+
+When a token is declared, the requests must carry it as a bearer token. The API contains the
+following endpoints:
+
+	GET /endpoints        the endpoints of the loaded config and their backends
+	GET /backends         the hosts returned by the subscribers of the balancers
+	GET /backends/health  the health of the hosts probed by the sd/healthcheck package
+	GET /breakers         the state of the circuit breakers and the rest of components
+	                      registered with telemetry.RegisterState
+	GET /caches           the stats of the response caches
+	GET /log/level        the current log level
+	PUT /log/level        changes the log level, i.e. {"level": "DEBUG"}
+
+The log level can only be changed when the logger of the service implements the
+logging.LevelSetter interface, like the ones created with logging.NewLevelSwitch.
+*/
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/cache"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+	"github.com/luraproject/lura/v2/sd/healthcheck"
+	"github.com/luraproject/lura/v2/telemetry"
+)
+
+// Namespace is the key to use to store and access the admin config
+const Namespace = "github.com/luraproject/lura/admin"
+
+// DefaultListenAddress is the address of the admin listener when the config does not declare one.
+// It only accepts local connections
+const DefaultListenAddress = "127.0.0.1:9092"
+
+const logPrefix = "[SERVICE: Admin]"
+
+// Config is the admin config of the service
+type Config struct {
+	ListenAddress string `json:"listen_address"`
+	Token         string `json:"token"`
+}
+
+// ConfigGetter parses the admin config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{ListenAddress: DefaultListenAddress}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("admin: parsing the config: %w", err)
+	}
+	if cfg.ListenAddress == "" {
+		cfg.ListenAddress = DefaultListenAddress
+	}
+	return cfg, true, nil
+}
+
+// BackendInfo describes a backend of a loaded endpoint
+type BackendInfo struct {
+	URLPattern string   `json:"url_pattern"`
+	Method     string   `json:"method"`
+	Host       []string `json:"host"`
+	SD         string   `json:"sd"`
+	Encoding   string   `json:"encoding"`
+}
+
+// EndpointInfo describes a loaded endpoint
+type EndpointInfo struct {
+	Endpoint       string        `json:"endpoint"`
+	Method         string        `json:"method"`
+	Timeout        string        `json:"timeout"`
+	CacheTTL       string        `json:"cache_ttl"`
+	OutputEncoding string        `json:"output_encoding"`
+	Backends       []BackendInfo `json:"backends"`
+}
+
+// Endpoints returns the description of the endpoints of the service config
+func Endpoints(cfg config.ServiceConfig) []EndpointInfo {
+	res := make([]EndpointInfo, 0, len(cfg.Endpoints))
+	for _, e := range cfg.Endpoints {
+		info := EndpointInfo{
+			Endpoint:       e.Endpoint,
+			Method:         e.Method,
+			Timeout:        e.Timeout.String(),
+			CacheTTL:       e.CacheTTL.String(),
+			OutputEncoding: e.OutputEncoding,
+			Backends:       make([]BackendInfo, 0, len(e.Backend)),
+		}
+		for _, b := range e.Backend {
+			info.Backends = append(info.Backends, BackendInfo{
+				URLPattern: b.URLPattern,
+				Method:     b.Method,
+				Host:       b.Host,
+				SD:         b.SD,
+				Encoding:   b.Encoding,
+			})
+		}
+		res = append(res, info)
+	}
+	return res
+}
+
+// Handler returns a http handler serving the admin API for the service config. The log level
+// endpoints use the logger if it implements the logging.LevelSetter interface
+func Handler(cfg config.ServiceConfig, logger logging.Logger, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/endpoints", get(func() interface{} { return Endpoints(cfg) }))
+	mux.HandleFunc("/backends", get(func() interface{} { return sd.GetTracker().Backends() }))
+	mux.HandleFunc("/backends/health", get(func() interface{} { return healthcheck.Statuses() }))
+	mux.HandleFunc("/breakers", get(func() interface{} { return telemetry.States() }))
+	mux.HandleFunc("/caches", get(func() interface{} { return cache.GetRegister().Stats() }))
+	mux.HandleFunc("/log/level", logLevelHandler(logger))
+
+	if token == "" {
+		return mux
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			writeJSON(w, http.StatusUnauthorized, errorBody{"missing or invalid token"})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+type levelBody struct {
+	Level string `json:"level"`
+}
+
+func get(f func() interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, errorBody{"method not allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, f())
+	}
+}
+
+func logLevelHandler(logger logging.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ls, ok := logger.(logging.LevelSetter)
+		if !ok {
+			writeJSON(w, http.StatusNotImplemented, errorBody{"the logger does not support changing the level"})
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var body levelBody
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeJSON(w, http.StatusBadRequest, errorBody{err.Error()})
+				return
+			}
+			if err := ls.SetLevel(body.Level); err != nil {
+				writeJSON(w, http.StatusBadRequest, errorBody{err.Error()})
+				return
+			}
+			logger.Info(logPrefix, "Log level set to", strings.ToUpper(body.Level))
+		default:
+			writeJSON(w, http.StatusMethodNotAllowed, errorBody{"method not allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, levelBody{ls.Level()})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+var (
+	listener   *adminListener
+	listenerMu sync.Mutex
+)
+
+// Register starts the admin listener declared in the service extra config, replacing the previous
+// one if its address changed. The listener serves the API for the last registered config and is
+// stopped when the context is done. It returns false if the service does not declare the admin API
+func Register(ctx context.Context, cfg config.ServiceConfig, logger logging.Logger) (Config, bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok || err != nil {
+		// the listener of a previous config is stopped with its context, so the routers without
+		// the admin API do not stop the listener of another router
+		return c, ok, err
+	}
+	h := Handler(cfg, logger, c.Token)
+
+	listenerMu.Lock()
+	defer listenerMu.Unlock()
+	if listener != nil && listener.addr == c.ListenAddress {
+		// the listener survives the reloads, so it is bound to the context of the last one
+		listener.handler.set(h)
+		listener.watch(ctx)
+		return c, true, nil
+	}
+	if listener != nil {
+		listener.stop()
+		listener = nil
+	}
+	l, err := startListener(ctx, c.ListenAddress, h, logger)
+	if err != nil {
+		return c, true, err
+	}
+	listener = l
+	return c, true, nil
+}
+
+type swappableHandler struct {
+	mu sync.RWMutex
+	h  http.Handler
+}
+
+func (s *swappableHandler) set(h http.Handler) {
+	s.mu.Lock()
+	s.h = h
+	s.mu.Unlock()
+}
+
+func (s *swappableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	h := s.h
+	s.mu.RUnlock()
+	h.ServeHTTP(w, r)
+}
+
+type adminListener struct {
+	addr    string
+	handler *swappableHandler
+	server  *http.Server
+	owner   context.Context
+}
+
+func startListener(ctx context.Context, addr string, h http.Handler, logger logging.Logger) (*adminListener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("admin: starting the listener: %w", err)
+	}
+	sh := &swappableHandler{h: h}
+	l := &adminListener{
+		addr:    addr,
+		handler: sh,
+		server:  &http.Server{Handler: sh, ReadHeaderTimeout: 5 * time.Second},
+	}
+
+	logger.Info(logPrefix, "Exposing the admin API at", ln.Addr().String())
+	go func() {
+		if err := l.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Error(logPrefix, err.Error())
+		}
+	}()
+	l.watch(ctx)
+	return l, nil
+}
+
+// watch stops the listener when the context is done, unless it was bound to another context
+// before. It must be called with the listenerMu locked
+func (l *adminListener) watch(ctx context.Context) {
+	l.owner = ctx
+	go func() {
+		<-ctx.Done()
+		listenerMu.Lock()
+		defer listenerMu.Unlock()
+		if l.owner != ctx {
+			return
+		}
+		if listener == l {
+			listener = nil
+		}
+		l.stop()
+	}()
+}
+
+func (l *adminListener) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	l.server.Shutdown(ctx)
+}
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/telemetry"
+)
+
+func TestHandler(t *testing.T) {
+	cfg := config.ServiceConfig{Endpoints: []*config.EndpointConfig{
+		{
+			Endpoint: "/users/{id}",
+			Method:   "GET",
+			Timeout:  time.Second,
+			Backend:  []*config.Backend{{URLPattern: "/u/{id}", Method: "GET", Host: []string{"http://a"}}},
+		},
+	}}
+	telemetry.RegisterState("breaker /users", func() interface{} { return "open" })
+
+	buff := new(bytes.Buffer)
+	l, _ := logging.NewLogger("DEBUG", buff, "")
+	ls, err := logging.NewLevelSwitch(l, "ERROR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Handler(cfg, ls, "s3cr3t")
+
+	call := func(method, path, body, token string) (int, string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		b, _ := io.ReadAll(w.Result().Body)
+		return w.Code, string(b)
+	}
+
+	if status, _ := call("GET", "/endpoints", "", "wrong"); status != http.StatusUnauthorized {
+		t.Errorf("unexpected status code %d", status)
+	}
+
+	status, body := call("GET", "/endpoints", "", "s3cr3t")
+	var endpoints []EndpointInfo
+	if err := json.Unmarshal([]byte(body), &endpoints); err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK || len(endpoints) != 1 || endpoints[0].Timeout != "1s" ||
+		len(endpoints[0].Backends) != 1 || endpoints[0].Backends[0].URLPattern != "/u/{id}" {
+		t.Errorf("unexpected response %d %s", status, body)
+	}
+
+	if status, body := call("GET", "/breakers", "", "s3cr3t"); status != http.StatusOK || !strings.Contains(body, `"breaker /users":"open"`) {
+		t.Errorf("unexpected response %d %s", status, body)
+	}
+	if status, _ := call("POST", "/caches", "", "s3cr3t"); status != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status code %d", status)
+	}
+
+	if status, body := call("GET", "/log/level", "", "s3cr3t"); status != http.StatusOK || !strings.Contains(body, `"ERROR"`) {
+		t.Errorf("unexpected response %d %s", status, body)
+	}
+	if status, _ := call("PUT", "/log/level", `{"level":"verbose"}`, "s3cr3t"); status != http.StatusBadRequest {
+		t.Errorf("unexpected status code %d", status)
+	}
+	if status, body := call("PUT", "/log/level", `{"level":"debug"}`, "s3cr3t"); status != http.StatusOK || !strings.Contains(body, `"DEBUG"`) {
+		t.Errorf("unexpected response %d %s", status, body)
+	}
+	buff.Reset()
+	ls.Debug("hello")
+	if !strings.Contains(buff.String(), "hello") {
+		t.Error("the new level was not applied")
+	}
+
+	w := httptest.NewRecorder()
+	Handler(cfg, logging.NoOp, "").ServeHTTP(w, httptest.NewRequest("GET", "/log/level", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("unexpected status code %d", w.Code)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"listen_address": "127.0.0.1:0",
+	}}}
+	c, ok, err := Register(ctx, cfg, logging.NoOp)
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if c.Token != "" {
+		t.Errorf("unexpected token: %s", c.Token)
+	}
+	first := listener
+
+	// a reload keeps the listener, but binds it to the new context
+	reloadCtx, reloadCancel := context.WithCancel(context.Background())
+	defer reloadCancel()
+	if _, _, err := Register(reloadCtx, cfg, logging.NoOp); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	listenerMu.Lock()
+	if listener != first {
+		t.Error("the listener was replaced or stopped by the previous context")
+	}
+	listenerMu.Unlock()
+
+	reloadCancel()
+	time.Sleep(10 * time.Millisecond)
+	listenerMu.Lock()
+	if listener != nil {
+		t.Error("the listener was not stopped")
+	}
+	listenerMu.Unlock()
+
+	if _, ok, _ := Register(context.Background(), config.ServiceConfig{}, logging.NoOp); ok {
+		t.Error("the service does not declare the admin api")
+	}
+}
@@ -0,0 +1,313 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package async
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/backoff"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/transport/queue"
+)
+
+// Namespace is the key to use to store and access the queue config of the async agents
+const Namespace = "github.com/luraproject/lura/async"
+
+// Config is the queue config of an async agent
+type Config struct {
+	// Driver is the name of the queue consumer driver
+	Driver string `json:"driver"`
+	// URL is the address of the broker
+	URL string `json:"url"`
+	// Group is the queue group of the NATS subscriptions and the consumer group of the Kafka
+	// consumers, the name of the agent by default
+	Group string `json:"group,omitempty"`
+	// Prefetch is the max number of messages not settled yet. It defaults to the number of workers
+	Prefetch int `json:"prefetch,omitempty"`
+	// Retries is the number of times a failing message is processed again
+	Retries int `json:"retries,omitempty"`
+	// RetryBackoff is the name of the backoff strategy between the retries of a message
+	RetryBackoff string `json:"retry_backoff,omitempty"`
+	// InitialOffset is the offset the Kafka consumers start from when their group has no
+	// committed offsets
+	InitialOffset string `json:"initial_offset,omitempty"`
+	// CommitInterval is the period of the commits of the offsets of the Kafka consumers
+	CommitInterval time.Duration `json:"-"`
+	// DeadLetter is the queue config of the backend publishing the messages failing all their
+	// retries
+	DeadLetter map[string]interface{} `json:"dead_letter,omitempty"`
+}
+
+// ConfigGetter parses the queue config from the extra config of an async agent
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("async: parsing the config: %w", err)
+	}
+	if v, ok := tmp["commit_interval"].(string); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, true, fmt.Errorf("async: invalid commit_interval %s", v)
+		}
+		cfg.CommitInterval = d
+	}
+	if _, ok := queue.GetConsumerDriver(cfg.Driver); !ok {
+		return cfg, true, fmt.Errorf("async: unknown driver %q", cfg.Driver)
+	}
+	if cfg.Retries < 0 || cfg.Prefetch < 0 {
+		return cfg, true, fmt.Errorf("async: the retries and the prefetch must be positive")
+	}
+	return cfg, true, nil
+}
+
+// NewQueueAgentFactory returns a Factory starting the agents declaring a queue config. The
+// agents consume the messages of the topic of their consumer with the declared number of
+// workers and rate, and pass every message to their pipe as a POST request with the message as
+// body. The messages failing are retried, and then published to the dead letter queue, if any,
+// or rejected. The connection to the broker is retried with the backoff strategy of the agent
+func NewQueueAgentFactory() Factory {
+	return func(ctx context.Context, opts Options) bool {
+		cfg, ok, err := ConfigGetter(opts.Agent.ExtraConfig)
+		if !ok {
+			return false
+		}
+		logPrefix := fmt.Sprintf("[SERVICE: AsyncAgent][%s]", opts.Agent.Name)
+		if err != nil {
+			opts.Logger.Error(logPrefix, err.Error())
+			return true
+		}
+		a := &queueAgent{
+			Options:   opts,
+			cfg:       cfg,
+			logPrefix: logPrefix,
+			retryWait: backoff.GetByName(cfg.RetryBackoff),
+		}
+		if cfg.DeadLetter != nil {
+			if a.deadLetter, a.deadLetterRenderer, err = newDeadLetterPublisher(cfg.DeadLetter); err != nil {
+				opts.Logger.Error(logPrefix, "Unable to create the dead letter publisher:", err.Error())
+				return true
+			}
+		}
+		opts.G.Go(func() error {
+			a.run(ctx)
+			return nil
+		})
+		return true
+	}
+}
+
+func newDeadLetterPublisher(raw map[string]interface{}) (queue.Publisher, *queue.Renderer, error) {
+	opt, err := queue.GetOptions(config.ExtraConfig{queue.Namespace: raw})
+	if err != nil {
+		return nil, nil, err
+	}
+	renderer, err := queue.New(*opt)
+	if err != nil {
+		return nil, nil, err
+	}
+	pf, _ := queue.GetDriver(opt.Driver)
+	publisher, err := pf(*opt)
+	return publisher, renderer, err
+}
+
+type queueAgent struct {
+	Options
+	cfg                Config
+	logPrefix          string
+	retryWait          backoff.TimeToWaitBeforeRetry
+	deadLetter         queue.Publisher
+	deadLetterRenderer *queue.Renderer
+}
+
+func (a *queueAgent) run(ctx context.Context) {
+	cf, _ := queue.GetConsumerDriver(a.cfg.Driver)
+	prefetch := a.cfg.Prefetch
+	if prefetch == 0 {
+		prefetch = a.Agent.Consumer.Workers
+	}
+	group := a.cfg.Group
+	if group == "" && a.cfg.Driver == queue.DriverKafka {
+		group = a.Agent.Name
+	}
+	consumer, err := cf(queue.ConsumerOptions{
+		Driver:         a.cfg.Driver,
+		URL:            a.cfg.URL,
+		Topic:          a.Agent.Consumer.Topic,
+		Group:          group,
+		Prefetch:       prefetch,
+		InitialOffset:  a.cfg.InitialOffset,
+		CommitInterval: a.cfg.CommitInterval,
+	})
+	if err != nil {
+		a.Logger.Error(a.logPrefix, "Unable to create the consumer:", err.Error())
+		return
+	}
+	defer consumer.Close()
+	if a.deadLetter != nil {
+		defer a.deadLetter.Close()
+	}
+
+	for i := 0; a.ShouldContinue(i); i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(a.BackoffF(i)):
+			}
+		}
+		a.Logger.Debug(a.logPrefix, "Consuming the messages of", a.Agent.Consumer.Topic)
+		received, err := a.consume(ctx, consumer)
+		if ctx.Err() != nil {
+			return
+		}
+		if received {
+			// the connection worked, so the retries start again
+			i = 0
+		}
+		if err != nil {
+			a.Logger.Warning(a.logPrefix, "Consumer failed:", err.Error())
+		}
+	}
+	a.Logger.Error(a.logPrefix, "Giving up after", a.Agent.Connection.MaxRetries, "retries")
+}
+
+// consume dispatches the messages to the workers until the consumer fails or the context is
+// cancelled. It returns true if some message was received
+func (a *queueAgent) consume(ctx context.Context, consumer queue.Consumer) (bool, error) {
+	localCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	deliveries := make(chan queue.Delivery)
+	var wg sync.WaitGroup
+	for w := 0; w < a.Agent.Consumer.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range deliveries {
+				a.process(ctx, d)
+			}
+		}()
+	}
+	go a.ping(localCtx)
+
+	var throttle <-chan time.Time
+	if a.Agent.Consumer.MaxRate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / a.Agent.Consumer.MaxRate))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	received := false
+	err := consumer.Consume(localCtx, func(d queue.Delivery) {
+		received = true
+		if throttle != nil {
+			select {
+			case <-throttle:
+			case <-localCtx.Done():
+				d.Nack(true)
+				return
+			}
+		}
+		select {
+		case deliveries <- d:
+		case <-localCtx.Done():
+			d.Nack(true)
+		}
+	})
+	close(deliveries)
+	wg.Wait()
+	return received, err
+}
+
+// ping reports the agent is alive every health interval
+func (a *queueAgent) ping(ctx context.Context) {
+	if a.AgentPing == nil {
+		return
+	}
+	ticker := time.NewTicker(a.Agent.Connection.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			select {
+			case a.AgentPing <- a.Agent.Name:
+			default:
+			}
+		}
+	}
+}
+
+// process passes the message to the pipe, retrying it when it fails. The messages failing all
+// the retries are published to the dead letter queue, if any, or rejected
+func (a *queueAgent) process(ctx context.Context, d queue.Delivery) {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = a.handle(ctx, d); err == nil {
+			d.Ack()
+			return
+		}
+		if attempt >= a.cfg.Retries || ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(a.retryWait(attempt + 1)):
+		}
+	}
+	a.Logger.Warning(a.logPrefix, "Processing the message:", err.Error())
+
+	if a.deadLetter == nil {
+		d.Nack(false)
+		return
+	}
+	m, err := a.deadLetterRenderer.Render(queue.TemplateData{Params: map[string]string{"Topic": d.Topic}}, d.Body)
+	if err == nil {
+		err = a.deadLetter.Publish(ctx, m)
+	}
+	if err != nil {
+		a.Logger.Error(a.logPrefix, "Publishing the message to the dead letter queue:", err.Error())
+		d.Nack(true)
+		return
+	}
+	d.Ack()
+}
+
+func (a *queueAgent) handle(ctx context.Context, d queue.Delivery) error {
+	if a.Agent.Consumer.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.Agent.Consumer.Timeout)
+		defer cancel()
+	}
+	headers := map[string][]string{}
+	if d.ContentType != "" {
+		headers["Content-Type"] = []string{d.ContentType}
+	}
+	_, err := a.Proxy(ctx, &proxy.Request{
+		Method:  http.MethodPost,
+		Path:    "/",
+		Query:   url.Values{},
+		Params:  map[string]string{"Topic": d.Topic},
+		Headers: headers,
+		Body:    io.NopCloser(bytes.NewReader(d.Body)),
+	})
+	return err
+}
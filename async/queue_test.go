@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/transport/queue"
+
+	"golang.org/x/sync/errgroup"
+)
+
+type fakeConsumer struct {
+	deliveries []queue.Delivery
+}
+
+func (c *fakeConsumer) Consume(ctx context.Context, handler func(queue.Delivery)) error {
+	for _, d := range c.deliveries {
+		handler(d)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (*fakeConsumer) Close() error { return nil }
+
+type fakePublisher struct {
+	published chan<- queue.Message
+}
+
+func (p fakePublisher) Publish(_ context.Context, m queue.Message) error {
+	p.published <- m
+	return nil
+}
+
+func (fakePublisher) Close() error { return nil }
+
+func TestNewQueueAgentFactory(t *testing.T) {
+	var mu sync.Mutex
+	settlements := []string{}
+	settled := make(chan struct{}, 10)
+	newDelivery := func(body string) queue.Delivery {
+		return queue.NewDelivery("orders", []byte(body), "application/json", func(ack, requeue bool) error {
+			mu.Lock()
+			settlements = append(settlements, fmt.Sprintf("%s %v %v", body, ack, requeue))
+			mu.Unlock()
+			settled <- struct{}{}
+			return nil
+		})
+	}
+	consumer := &fakeConsumer{deliveries: []queue.Delivery{newDelivery("ok"), newDelivery("ko")}}
+	queue.RegisterConsumerDriver("fake-async", func(opt queue.ConsumerOptions) (queue.Consumer, error) {
+		if opt.Topic != "orders" || opt.Prefetch != 2 {
+			t.Errorf("unexpected options: %+v", opt)
+		}
+		return consumer, nil
+	})
+	published := make(chan queue.Message, 1)
+	queue.RegisterDriver("fake-async-dlq", func(queue.Options) (queue.Publisher, error) {
+		return fakePublisher{published: published}, nil
+	})
+
+	var calls sync.Map
+	p := func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		n, _ := calls.LoadOrStore(string(b), new(int32))
+		mu.Lock()
+		*n.(*int32)++
+		mu.Unlock()
+		if r.Method != "POST" || r.Params["Topic"] != "orders" || r.Headers["Content-Type"][0] != "application/json" {
+			t.Errorf("unexpected request: %+v", r)
+		}
+		if string(b) == "ko" {
+			return nil, errors.New("processing failed")
+		}
+		return &proxy.Response{IsComplete: true}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g, ctx := errgroup.WithContext(ctx)
+	opts := Options{
+		Agent: &config.AsyncAgent{
+			Name:       "orders-agent",
+			Connection: config.Connection{HealthInterval: time.Second},
+			Consumer:   config.Consumer{Topic: "orders", Workers: 2},
+			ExtraConfig: config.ExtraConfig{
+				Namespace: map[string]interface{}{
+					"driver":        "fake-async",
+					"url":           "fake://broker",
+					"retries":       2,
+					"retry_backoff": "linear",
+					"dead_letter": map[string]interface{}{
+						"driver":  "fake-async-dlq",
+						"url":     "fake://broker",
+						"subject": "orders.dlq",
+					},
+				},
+			},
+		},
+		Proxy:          p,
+		G:              g,
+		ShouldContinue: func(i int) bool { return i < 1 },
+		BackoffF:       func(int) time.Duration { return time.Millisecond },
+		Logger:         logging.NoOp,
+	}
+	// the retries of the failing message wait 1s and 2s with the linear backoff
+	if !NewQueueAgentFactory()(ctx, opts) {
+		t.Fatal("the agent was not started")
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-settled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the messages were not settled")
+		}
+	}
+
+	select {
+	case m := <-published:
+		if string(m.Body) != "ko" {
+			t.Errorf("unexpected dead letter: %s", m.Body)
+		}
+	default:
+		t.Error("the failing message was not published to the dead letter queue")
+	}
+
+	mu.Lock()
+	sort.Strings(settlements)
+	if fmt.Sprintf("%v", settlements) != "[ko true false ok true false]" {
+		t.Errorf("unexpected settlements: %v", settlements)
+	}
+	mu.Unlock()
+
+	if n, _ := calls.Load("ko"); *n.(*int32) != 3 {
+		t.Errorf("unexpected number of attempts: %d", *n.(*int32))
+	}
+
+	cancel()
+	if err := g.Wait(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewQueueAgentFactory_noConfig(t *testing.T) {
+	if NewQueueAgentFactory()(context.Background(), Options{Agent: &config.AsyncAgent{}}) {
+		t.Error("the agent without queue config was accepted")
+	}
+}
+
+func TestConfigGetter(t *testing.T) {
+	for _, tc := range []map[string]interface{}{
+		{"driver": "unknown", "url": "fake://broker"},
+		{"driver": "nats", "url": "nats://localhost:4222", "retries": -1},
+		{"driver": "kafka", "url": "kafka://localhost:9092", "commit_interval": "soon"},
+	} {
+		if _, ok, err := ConfigGetter(config.ExtraConfig{Namespace: tc}); !ok || err == nil {
+			t.Errorf("expecting an error with %v", tc)
+		}
+	}
+}
+
+func TestConfigGetter_kafka(t *testing.T) {
+	// the kafka consumers are built on a client library and registered by the gateway
+	queue.RegisterConsumerDriver(queue.DriverKafka, func(_ queue.ConsumerOptions) (queue.Consumer, error) {
+		return nil, errors.New("not implemented")
+	})
+
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"driver":          "kafka",
+		"url":             "kafka://localhost:9092",
+		"initial_offset":  "oldest",
+		"commit_interval": "5s",
+	}})
+	if !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	if cfg.InitialOffset != queue.OffsetOldest || cfg.CommitInterval != 5*time.Second {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
@@ -0,0 +1,351 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package budget limits the cost of the requests each tenant can consume per time window.
+
+Every endpoint declares the cost of its requests, so the heavier aggregations consume more
+budget than the cheap lookups:
+
+	"extra_config": {
+		"github.com/luraproject/lura/budget": {
+			"cost": 10
+		}
+	}
+
+and the service declares the budget of the tenants for each window:
+
+	"extra_config": {
+		"github.com/luraproject/lura/budget": {
+			"window": "1m",
+			"budget": 1000,
+			"tenants": {
+				"mobile-app": 5000
+			},
+			"tenant_header": "X-Tenant"
+		}
+	}
+
+The tenant of a request is the client id of its API key identity. The requests without one are
+identified by the tenant header, if declared, and the rest are charged to the AnonymousTenant.
+The endpoints not declaring a cost are not charged.
+
+Every instance of the gateway tracks the spent budget in memory by default. The clusters of
+gateways enforcing the budgets across all their instances keep it in the shared store declared
+by the service (see the sharedstore package) with:
+
+	"store": "shared"
+
+When the shared store is not available, the budget is tracked in memory until it recovers, so the
+requests are not rejected because of the store.
+*/
+package budget
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/router/apikey"
+	"github.com/luraproject/lura/v2/sharedstore"
+)
+
+// Namespace is the key to use to store and access the budget config
+const Namespace = "github.com/luraproject/lura/budget"
+
+// DefaultWindow is the length of the windows when the config does not declare one
+const DefaultWindow = time.Minute
+
+// AnonymousTenant is the tenant charged with the requests not identifying one
+const AnonymousTenant = "anonymous"
+
+const (
+	// LocalStore is the store keeping the spent budget in the memory of every instance
+	LocalStore = "local"
+	// SharedStore is the store keeping the spent budget in the shared store of the service
+	SharedStore = "shared"
+)
+
+// ErrNoBudget is returned when an endpoint declares a cost but the service does not declare any
+// budget
+var ErrNoBudget = errors.New("budget: no budget registered")
+
+// ExhaustedError is returned when the cost of a request exceeds the budget left to its tenant
+type ExhaustedError struct {
+	Tenant string
+	Reset  time.Time
+}
+
+// Error implements the error interface
+func (e ExhaustedError) Error() string {
+	return fmt.Sprintf("budget: the tenant %s exhausted its budget until %s", e.Tenant, e.Reset.Format(time.RFC3339))
+}
+
+// StatusCode returns the 429 Too Many Requests status code
+func (ExhaustedError) StatusCode() int { return http.StatusTooManyRequests }
+
+// Config is the budget config of the service
+type Config struct {
+	Window       string           `json:"window"`
+	Budget       int64            `json:"budget"`
+	Tenants      map[string]int64 `json:"tenants"`
+	TenantHeader string           `json:"tenant_header"`
+	Store        string           `json:"store"`
+}
+
+// ConfigGetter parses the budget config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("budget: parsing the config: %w", err)
+	}
+	return cfg, true, nil
+}
+
+// CostGetter returns the cost declared in the extra config of an endpoint
+func CostGetter(e config.ExtraConfig) (int64, bool, error) {
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return 0, false, nil
+	}
+	cost, ok := getNumber(tmp["cost"])
+	if !ok || cost <= 0 {
+		return 0, true, fmt.Errorf("budget: the cost must be a positive number, got %v", tmp["cost"])
+	}
+	return cost, true, nil
+}
+
+// Store keeps the cost spent by the tenants in every window
+type Store interface {
+	// Spend charges the cost to the tenant in the window starting at start, unless the spent
+	// cost would exceed the limit. It returns the cost spent after the operation and whether the
+	// cost was charged. The ttl is the length of the window
+	Spend(ctx context.Context, tenant string, start time.Time, ttl time.Duration, cost, limit int64) (int64, bool, error)
+}
+
+// NewLocalStore returns a Store keeping the spent cost in memory
+func NewLocalStore() Store {
+	return &localStore{spent: map[string]int64{}}
+}
+
+// localStore drops the usage of the previous window at once, since all the tenants share the
+// window boundaries
+type localStore struct {
+	mu    sync.Mutex
+	start time.Time
+	spent map[string]int64
+}
+
+func (s *localStore) Spend(_ context.Context, tenant string, start time.Time, _ time.Duration, cost, limit int64) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !start.Equal(s.start) {
+		s.start = start
+		s.spent = map[string]int64{}
+	}
+	spent := s.spent[tenant]
+	if spent+cost > limit {
+		return spent, false, nil
+	}
+	s.spent[tenant] = spent + cost
+	return spent + cost, true, nil
+}
+
+// NewSharedStore returns a Store keeping the spent cost in the shared store, so all the instances
+// of the gateway enforce the same budgets. The cost is tracked by the fallback store while the
+// shared one fails
+func NewSharedStore(c sharedstore.Client, fallback Store) Store {
+	return sharedStore{client: c, fallback: fallback}
+}
+
+type sharedStore struct {
+	client   sharedstore.Client
+	fallback Store
+}
+
+func (s sharedStore) Spend(ctx context.Context, tenant string, start time.Time, ttl time.Duration, cost, limit int64) (int64, bool, error) {
+	key := fmt.Sprintf("lura:budget:%s:%d", tenant, start.Unix())
+	spent, err := s.client.Incr(ctx, key, cost, ttl)
+	if err != nil {
+		return s.fallback.Spend(ctx, tenant, start, ttl, cost, limit)
+	}
+	if spent > limit {
+		// the cost is given back, so the rejected requests do not consume the budget
+		s.client.Incr(ctx, key, -cost, ttl)
+		return spent - cost, false, nil
+	}
+	return spent, true, nil
+}
+
+// Budget tracks the cost consumed by the tenants in fixed windows
+type Budget struct {
+	window       time.Duration
+	budget       int64
+	tenants      map[string]int64
+	tenantHeader string
+	now          func() time.Time
+	store        Store
+}
+
+// New returns the Budget of the config, tracking the spent cost in memory
+func New(cfg Config) (*Budget, error) {
+	return NewWithStore(cfg, NewLocalStore())
+}
+
+// NewWithStore returns the Budget of the config, tracking the spent cost in the store
+func NewWithStore(cfg Config, s Store) (*Budget, error) {
+	b := &Budget{
+		window:       DefaultWindow,
+		budget:       cfg.Budget,
+		tenants:      cfg.Tenants,
+		tenantHeader: http.CanonicalHeaderKey(cfg.TenantHeader),
+		now:          time.Now,
+		store:        s,
+	}
+	switch cfg.Store {
+	case "", LocalStore, SharedStore:
+	default:
+		return nil, fmt.Errorf("budget: unknown store %s", cfg.Store)
+	}
+	if cfg.Window != "" {
+		d, err := time.ParseDuration(cfg.Window)
+		if err != nil {
+			return nil, fmt.Errorf("budget: invalid window: %w", err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("budget: the window must be at least 1s, got %s", d)
+		}
+		b.window = d
+	}
+	if b.budget <= 0 {
+		return nil, fmt.Errorf("budget: the budget must be a positive number, got %d", b.budget)
+	}
+	for t, v := range b.tenants {
+		if v <= 0 {
+			return nil, fmt.Errorf("budget: the budget of the tenant %s must be a positive number, got %d", t, v)
+		}
+	}
+	return b, nil
+}
+
+// Tenant returns the tenant of a request with the received context and headers
+func (b *Budget) Tenant(ctx context.Context, headers map[string][]string) string {
+	if i, ok := apikey.FromContext(ctx); ok && i.ClientID != "" {
+		return i.ClientID
+	}
+	if b.tenantHeader != "" {
+		if vs := headers[b.tenantHeader]; len(vs) > 0 && vs[0] != "" {
+			return vs[0]
+		}
+	}
+	return AnonymousTenant
+}
+
+// Limit returns the budget of the tenant for every window
+func (b *Budget) Limit(tenant string) int64 {
+	if v, ok := b.tenants[tenant]; ok {
+		return v
+	}
+	return b.budget
+}
+
+// Spend charges the cost to the tenant and returns the budget left in the current window. It
+// returns an ExhaustedError, without charging anything, if the budget left is not enough
+func (b *Budget) Spend(tenant string, cost int64) (int64, error) {
+	return b.SpendContext(context.Background(), tenant, cost)
+}
+
+// SpendContext is like Spend, but the operations of the store are bound to the context
+func (b *Budget) SpendContext(ctx context.Context, tenant string, cost int64) (int64, error) {
+	limit := b.Limit(tenant)
+	start := b.now().Truncate(b.window)
+
+	spent, ok, err := b.store.Spend(ctx, tenant, start, b.window, cost, limit)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return limit - spent, ExhaustedError{Tenant: tenant, Reset: start.Add(b.window)}
+	}
+	return limit - spent, nil
+}
+
+var (
+	global   *Budget
+	globalMu sync.RWMutex
+)
+
+// Register creates the budget declared in the service extra config and validates the costs of
+// its endpoints. It returns false if the service does not declare a budget
+func Register(cfg config.ServiceConfig) (bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok {
+		// drop the budget of a previous config
+		SetGlobal(nil)
+		return false, nil
+	}
+	var b *Budget
+	if err == nil {
+		b, err = New(c)
+	}
+	var storeErr error
+	if err == nil && c.Store == SharedStore {
+		client, cErr := sharedstore.GetClient(context.Background(), cfg.ExtraConfig)
+		if cErr != nil {
+			// the budget is still enforced by every instance on its own
+			storeErr = fmt.Errorf("budget: tracking the budget in memory: %w", cErr)
+		} else {
+			b.store = NewSharedStore(client, b.store)
+		}
+	}
+	SetGlobal(b)
+	if err != nil {
+		return true, err
+	}
+
+	for _, e := range cfg.Endpoints {
+		if _, _, err := CostGetter(e.ExtraConfig); err != nil {
+			return true, fmt.Errorf("%w in the endpoint %s %s", err, e.Method, e.Endpoint)
+		}
+	}
+	return true, storeErr
+}
+
+// SetGlobal sets the budget charged by the endpoints
+func SetGlobal(b *Budget) {
+	globalMu.Lock()
+	global = b
+	globalMu.Unlock()
+}
+
+// GetGlobal returns the budget charged by the endpoints, if any
+func GetGlobal() (*Budget, bool) {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return global, global != nil
+}
+
+func getNumber(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int64(n), true
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package budget
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/router/apikey"
+)
+
+func TestBudget_Spend(t *testing.T) {
+	b, err := New(Config{Window: "1m", Budget: 10, Tenants: map[string]int64{"premium": 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2023, 1, 4, 10, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+
+	for i, tc := range []struct {
+		tenant string
+		cost   int64
+		left   int64
+		err    bool
+	}{
+		{tenant: "basic", cost: 4, left: 6},
+		{tenant: "basic", cost: 4, left: 2},
+		{tenant: "basic", cost: 4, left: 2, err: true},
+		{tenant: "basic", cost: 1, left: 1},
+		{tenant: "premium", cost: 50, left: 50},
+	} {
+		left, err := b.Spend(tc.tenant, tc.cost)
+		if left != tc.left {
+			t.Errorf("#%d: unexpected budget left: %d", i, left)
+		}
+		if (err != nil) != tc.err {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+	}
+
+	_, err = b.Spend("basic", 2)
+	var exhausted ExhaustedError
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exhausted.StatusCode() != http.StatusTooManyRequests {
+		t.Errorf("unexpected status code: %d", exhausted.StatusCode())
+	}
+	if !exhausted.Reset.Equal(now.Add(time.Minute)) {
+		t.Errorf("unexpected reset: %s", exhausted.Reset)
+	}
+
+	now = now.Add(time.Minute)
+	if left, err := b.Spend("basic", 2); err != nil || left != 8 {
+		t.Errorf("the budget was not renewed. left: %d, err: %v", left, err)
+	}
+}
+
+func TestBudget_Tenant(t *testing.T) {
+	b, _ := New(Config{Budget: 10, TenantHeader: "x-tenant"})
+	headers := map[string][]string{"X-Tenant": {"acme"}}
+
+	if tenant := b.Tenant(context.Background(), headers); tenant != "acme" {
+		t.Errorf("unexpected tenant: %s", tenant)
+	}
+	ctx := apikey.NewContext(context.Background(), apikey.Identity{ClientID: "mobile-app"})
+	if tenant := b.Tenant(ctx, headers); tenant != "mobile-app" {
+		t.Errorf("unexpected tenant: %s", tenant)
+	}
+	if tenant := b.Tenant(context.Background(), nil); tenant != AnonymousTenant {
+		t.Errorf("unexpected tenant: %s", tenant)
+	}
+}
+
+func TestNew_invalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{},
+		{Budget: 10, Window: "forever"},
+		{Budget: 10, Window: "10ms"},
+		{Budget: 10, Tenants: map[string]int64{"a": 0}},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("expecting an error for %+v", cfg)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer SetGlobal(nil)
+
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"budget": 100}},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/a", Method: "GET", ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"cost": 5}}},
+		},
+	}
+	if ok, err := Register(cfg); !ok || err != nil {
+		t.Errorf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if _, ok := GetGlobal(); !ok {
+		t.Error("the budget was not registered")
+	}
+
+	cfg.Endpoints[0].ExtraConfig[Namespace] = map[string]interface{}{"cost": "a lot"}
+	if _, err := Register(cfg); err == nil {
+		t.Error("expecting an error")
+	}
+
+	cfg.Endpoints[0].ExtraConfig[Namespace] = map[string]interface{}{"cost": 5}
+	cfg.ExtraConfig[Namespace] = map[string]interface{}{"budget": 100, "store": SharedStore}
+	if ok, err := Register(cfg); !ok || err == nil {
+		t.Errorf("expecting an error without a shared store. ok: %v, err: %v", ok, err)
+	}
+	if _, ok := GetGlobal(); !ok {
+		t.Error("the budget should fall back to the local store")
+	}
+
+	if ok, _ := Register(config.ServiceConfig{}); ok {
+		t.Error("the service does not declare a budget")
+	}
+	if _, ok := GetGlobal(); ok {
+		t.Error("the budget of the previous config was not dropped")
+	}
+}
+
+type fakeSharedClient struct {
+	mu       sync.Mutex
+	counters map[string]int64
+	down     bool
+}
+
+func (c *fakeSharedClient) Incr(_ context.Context, key string, delta int64, _ time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.down {
+		return 0, errors.New("connection refused")
+	}
+	c.counters[key] += delta
+	return c.counters[key], nil
+}
+
+func (*fakeSharedClient) Get(_ context.Context, _ string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (*fakeSharedClient) Set(_ context.Context, _ string, _ []byte, _ time.Duration) error {
+	return nil
+}
+
+func (*fakeSharedClient) Del(_ context.Context, _ string) error { return nil }
+
+func (*fakeSharedClient) Close() error { return nil }
+
+func TestBudget_sharedStore(t *testing.T) {
+	client := &fakeSharedClient{counters: map[string]int64{}}
+	now := time.Date(2023, 1, 4, 10, 0, 0, 0, time.UTC)
+
+	// two instances of the gateway sharing the store
+	instances := make([]*Budget, 2)
+	for i := range instances {
+		b, err := NewWithStore(Config{Budget: 10, Store: SharedStore}, NewSharedStore(client, NewLocalStore()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.now = func() time.Time { return now }
+		instances[i] = b
+	}
+
+	if left, err := instances[0].Spend("basic", 6); err != nil || left != 4 {
+		t.Errorf("unexpected result. left: %d, err: %v", left, err)
+	}
+	if left, err := instances[1].Spend("basic", 6); err == nil || left != 4 {
+		t.Errorf("the budget spent by the other instance was ignored. left: %d, err: %v", left, err)
+	}
+	if left, err := instances[1].Spend("basic", 4); err != nil || left != 0 {
+		t.Errorf("the rejected cost was not given back. left: %d, err: %v", left, err)
+	}
+
+	client.down = true
+	if left, err := instances[0].Spend("basic", 6); err != nil || left != 4 {
+		t.Errorf("the local store was not used. left: %d, err: %v", left, err)
+	}
+}
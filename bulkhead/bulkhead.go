@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package bulkhead isolates the backends, bounding the number of requests in flight to each of
+them, so a slow upstream can not consume all the goroutines and the sockets of the gateway.
+
+The bulkhead is declared in the extra config of the backends:
+
+	"github.com/luraproject/lura/bulkhead": {
+		"max_concurrent": 50,
+		"max_queue": 100,
+		"queue_timeout": "200ms"
+	}
+
+The requests arriving when all the slots are taken wait in a bounded queue until a slot is
+released, the queue timeout expires or their context is done. The requests arriving when the
+queue is full are rejected right away.
+*/
+package bulkhead
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the bulkhead config
+const Namespace = "github.com/luraproject/lura/bulkhead"
+
+// ErrFull is returned when the bulkhead can not accept more requests
+var ErrFull = FullError{}
+
+// FullError is the error returned when the bulkhead can not accept more requests
+type FullError struct{}
+
+// Error implements the error interface
+func (FullError) Error() string { return "bulkhead: too many requests in flight" }
+
+// StatusCode returns the 503 Service Unavailable status code
+func (FullError) StatusCode() int { return http.StatusServiceUnavailable }
+
+// Config is the bulkhead config
+type Config struct {
+	MaxConcurrent int    `json:"max_concurrent"`
+	MaxQueue      int    `json:"max_queue"`
+	QueueTimeout  string `json:"queue_timeout"`
+}
+
+// ConfigGetter parses the bulkhead config from the extra config of a backend
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("bulkhead: parsing the config: %w", err)
+	}
+	return cfg, true, nil
+}
+
+// Bulkhead bounds the number of concurrent requests
+type Bulkhead struct {
+	slots   chan struct{}
+	queue   chan struct{}
+	timeout time.Duration
+}
+
+// New returns the Bulkhead of the config
+func New(cfg Config) (*Bulkhead, error) {
+	if cfg.MaxConcurrent < 1 {
+		return nil, errors.New("bulkhead: the max_concurrent must be greater than zero")
+	}
+	if cfg.MaxQueue < 0 {
+		return nil, errors.New("bulkhead: the max_queue can not be negative")
+	}
+	b := &Bulkhead{slots: make(chan struct{}, cfg.MaxConcurrent)}
+	if cfg.MaxQueue > 0 {
+		b.queue = make(chan struct{}, cfg.MaxQueue)
+	}
+	if cfg.QueueTimeout != "" {
+		d, err := time.ParseDuration(cfg.QueueTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("bulkhead: parsing the queue_timeout: %w", err)
+		}
+		b.timeout = d
+	}
+	return b, nil
+}
+
+// Acquire takes a slot of the bulkhead, waiting in the queue if all of them are taken. The
+// returned function releases the slot and must be called once the request is done
+func (b *Bulkhead) Acquire(ctx context.Context) (func(), error) {
+	select {
+	case b.slots <- struct{}{}:
+		return b.release, nil
+	default:
+	}
+
+	select {
+	case b.queue <- struct{}{}:
+	default:
+		// no queue or full queue
+		return nil, ErrFull
+	}
+	defer func() { <-b.queue }()
+
+	var expired <-chan time.Time
+	if b.timeout > 0 {
+		t := time.NewTimer(b.timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return b.release, nil
+	case <-expired:
+		return nil, ErrFull
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *Bulkhead) release() { <-b.slots }
+
+// InFlight returns the number of requests holding a slot
+func (b *Bulkhead) InFlight() int { return len(b.slots) }
+
+// Queued returns the number of requests waiting for a slot
+func (b *Bulkhead) Queued() int {
+	if b.queue == nil {
+		return 0
+	}
+	return len(b.queue)
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bulkhead
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok, _ := ConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("unexpected config")
+	}
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"max_concurrent": 2,
+		"max_queue":      1,
+		"queue_timeout":  "10ms",
+	}})
+	if !ok || err != nil {
+		t.Errorf("unexpected result: %v, %v", ok, err)
+	}
+	if cfg.MaxConcurrent != 2 || cfg.MaxQueue != 1 || cfg.QueueTimeout != "10ms" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if _, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"max_queue": "x"}}); !ok || err == nil {
+		t.Errorf("unexpected result: %v, %v", ok, err)
+	}
+}
+
+func TestNew_ko(t *testing.T) {
+	for i, cfg := range []Config{
+		{},
+		{MaxConcurrent: 1, MaxQueue: -1},
+		{MaxConcurrent: 1, QueueTimeout: "x"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("#%d: expecting an error", i)
+		}
+	}
+}
+
+func TestBulkhead_noQueue(t *testing.T) {
+	b, err := New(Config{MaxConcurrent: 1})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	release, err := b.Acquire(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := b.Acquire(context.Background()); err != ErrFull {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if b.InFlight() != 1 {
+		t.Errorf("unexpected requests in flight: %d", b.InFlight())
+	}
+	release()
+	release, err = b.Acquire(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	release()
+	if ErrFull.StatusCode() != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status code: %d", ErrFull.StatusCode())
+	}
+}
+
+func TestBulkhead_queue(t *testing.T) {
+	b, err := New(Config{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: "1s"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	release, _ := b.Acquire(context.Background())
+
+	acquired := make(chan error, 1)
+	go func() {
+		r, err := b.Acquire(context.Background())
+		if err == nil {
+			r()
+		}
+		acquired <- err
+	}()
+
+	for b.Queued() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := b.Acquire(context.Background()); err != ErrFull {
+		t.Errorf("the queue should be full: %v", err)
+	}
+	release()
+	if err := <-acquired; err != nil {
+		t.Errorf("the queued request should get the slot: %v", err)
+	}
+}
+
+func TestBulkhead_queueTimeout(t *testing.T) {
+	b, err := New(Config{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: "10ms"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	release, _ := b.Acquire(context.Background())
+	defer release()
+	if _, err := b.Acquire(context.Background()); err != ErrFull {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.Acquire(ctx); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if b.Queued() != 0 {
+		t.Errorf("unexpected queued requests: %d", b.Queued())
+	}
+}
@@ -0,0 +1,265 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package bbolt provides a disk tier for the response caches persisting the entries in a bbolt
+database.
+
+The package registers its engine in the cache package when imported, so the cached endpoints
+can select it in the disk tier of their cache config:
+
+	import _ "github.com/luraproject/lura/v2/cache/bbolt"
+
+	"cache": {
+		"max_size": 1048576,
+		"disk": {
+			"path": "/var/cache/lura",
+			"engine": "bbolt",
+			"max_size": 1073741824
+		}
+	}
+
+The package is a module of its own, so the services keeping the entries as plain files do not
+depend on bbolt.
+*/
+package bbolt
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/luraproject/lura/v2/cache"
+)
+
+// Engine is the name of the disk engine registered by the package
+const Engine = "bbolt"
+
+// FileName is the name of the database file created in the folder of the disk tier
+const FileName = "cache.db"
+
+var bucket = []byte("entries")
+
+func init() {
+	cache.RegisterDiskEngine(Engine, func(opts cache.DiskOptions) (cache.DiskTier, error) {
+		return New(opts)
+	})
+}
+
+// New returns a Store persisting the entries in a bbolt database created in the configured
+// folder. The entries already present in the database are indexed, so they survive restarts
+func New(opts cache.DiskOptions) (*Store, error) {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = cache.DefaultDiskMaxBytes
+	}
+	if err := os.MkdirAll(opts.Path, 0o700); err != nil {
+		return nil, err
+	}
+	db, err := open(filepath.Join(opts.Path, FileName))
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{
+		opts:  opts,
+		db:    db,
+		index: map[string]entry{},
+		mu:    new(sync.Mutex),
+		now:   time.Now,
+	}
+	now := s.now()
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucket)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			s.index[string(k)] = entry{size: int64(len(v)), lastAccess: now}
+			s.size += int64(len(v))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.evict()
+	s.mu.Unlock()
+	return s, nil
+}
+
+// Store is a size-aware cache.DiskTier backed by a bbolt database
+type Store struct {
+	opts      cache.DiskOptions
+	db        *bolt.DB
+	index     map[string]entry
+	size      int64
+	mu        *sync.Mutex
+	now       func() time.Time
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type entry struct {
+	size       int64
+	lastAccess time.Time
+}
+
+// Get returns the payload stored under the key, if it has not expired
+func (s *Store) Get(key string) ([]byte, bool) {
+	b, _, ok := s.GetWithTTL(key)
+	return b, ok
+}
+
+// GetWithTTL returns the payload stored under the key and its remaining ttl, if it has not expired
+func (s *Store) GetWithTTL(key string) ([]byte, time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.index[key]
+	if !ok {
+		atomic.AddUint64(&s.misses, 1)
+		return nil, 0, false
+	}
+	b, err := s.read(key)
+	if err != nil || len(b) < 8 {
+		s.remove(key)
+		atomic.AddUint64(&s.misses, 1)
+		return nil, 0, false
+	}
+	now := s.now()
+	expiration := time.Unix(0, int64(binary.BigEndian.Uint64(b[:8])))
+	if !expiration.After(now) {
+		s.remove(key)
+		atomic.AddUint64(&s.misses, 1)
+		return nil, 0, false
+	}
+	e.lastAccess = now
+	s.index[key] = e
+	atomic.AddUint64(&s.hits, 1)
+	return b[8:], expiration.Sub(now), true
+}
+
+// Set persists the payload under the key for the given ttl. Payloads bigger than
+// the disk budget are ignored
+func (s *Store) Set(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 || int64(len(value)+8) > s.opts.MaxBytes {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	b := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(b[:8], uint64(now.Add(ttl).UnixNano()))
+	copy(b[8:], value)
+
+	if s.opts.Cipher != nil {
+		var err error
+		if b, err = s.opts.Cipher.Encrypt(b, []byte(key)); err != nil {
+			return
+		}
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(key), b)
+	})
+	if err != nil {
+		return
+	}
+	if e, ok := s.index[key]; ok {
+		s.size -= e.size
+	}
+	s.index[key] = entry{size: int64(len(b)), lastAccess: now}
+	s.size += int64(len(b))
+	s.evict()
+}
+
+// Stats returns a snapshot of the usage counters of the store
+func (s *Store) Stats() cache.Stats {
+	s.mu.Lock()
+	entries, size := len(s.index), s.size
+	s.mu.Unlock()
+
+	return cache.Stats{
+		Hits:      atomic.LoadUint64(&s.hits),
+		Misses:    atomic.LoadUint64(&s.misses),
+		Evictions: atomic.LoadUint64(&s.evictions),
+		Entries:   entries,
+		Bytes:     size,
+		MaxBytes:  s.opts.MaxBytes,
+	}
+}
+
+// Close releases the database, so it can be opened again. The database is shared by all the
+// stores of the folder, so they can not be used after closing any of them
+func (s *Store) Close() error {
+	dbsMu.Lock()
+	defer dbsMu.Unlock()
+	delete(dbs, s.db.Path())
+	return s.db.Close()
+}
+
+var (
+	dbs   = map[string]*bolt.DB{}
+	dbsMu = new(sync.Mutex)
+)
+
+// open returns the database of the file. The databases are locked while open, so the stores
+// created for the same folder, like the ones of a reloaded service, share the same one
+func open(path string) (*bolt.DB, error) {
+	dbsMu.Lock()
+	defer dbsMu.Unlock()
+	if db, ok := dbs[path]; ok {
+		return db, nil
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	dbs[path] = db
+	return db, nil
+}
+
+func (s *Store) read(key string) ([]byte, error) {
+	var b []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		// the values are only valid during the transaction
+		if v := tx.Bucket(bucket).Get([]byte(key)); v != nil {
+			b = append([]byte{}, v...)
+		}
+		return nil
+	})
+	if err != nil || b == nil || s.opts.Cipher == nil {
+		return b, err
+	}
+	return s.opts.Cipher.Decrypt(b, []byte(key))
+}
+
+func (s *Store) evict() {
+	for s.size > s.opts.MaxBytes && len(s.index) > 0 {
+		var oldest string
+		var lastAccess time.Time
+		first := true
+		for key, e := range s.index {
+			if first || e.lastAccess.Before(lastAccess) {
+				oldest, lastAccess, first = key, e.lastAccess, false
+			}
+		}
+		s.remove(oldest)
+		atomic.AddUint64(&s.evictions, 1)
+	}
+}
+
+func (s *Store) remove(key string) {
+	s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Delete([]byte(key))
+	})
+	s.size -= s.index[key].size
+	delete(s.index, key)
+}
@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bbolt
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/cache"
+	"github.com/luraproject/lura/v2/encryption"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(cache.DiskOptions{Path: dir, MaxBytes: 60})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	s.Set("a", []byte("0123456789"), time.Minute)
+	s.Set("b", []byte("0123456789"), time.Minute)
+	s.Set("c", []byte("0123456789"), time.Minute)
+	s.Set("d", []byte("0123456789"), time.Minute)
+
+	if _, ok := s.Get("a"); ok {
+		t.Error("the oldest entry should have been evicted")
+	}
+	b, ok := s.Get("d")
+	if !ok || !bytes.Equal(b, []byte("0123456789")) {
+		t.Errorf("unexpected payload: %s", string(b))
+	}
+	if stats := s.Stats(); stats.Bytes > 60 || stats.Evictions != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Error(err)
+		return
+	}
+	restarted, err := New(cache.DiskOptions{Path: dir, MaxBytes: 60})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer restarted.Close()
+	if _, ok := restarted.Get("d"); !ok {
+		t.Error("the entries should survive restarts")
+	}
+	if e := restarted.Stats().Entries; e != 3 {
+		t.Errorf("unexpected number of entries: %d", e)
+	}
+
+	reloaded, err := New(cache.DiskOptions{Path: dir, MaxBytes: 60})
+	if err != nil {
+		t.Errorf("the stores of the same folder should share the database: %s", err.Error())
+		return
+	}
+	if _, ok := reloaded.Get("c"); !ok {
+		t.Error("the entries should be available to the stores sharing the database")
+	}
+}
+
+func TestStore_expiration(t *testing.T) {
+	s, err := New(cache.DiskOptions{Path: t.TempDir()})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Close()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.Set("a", []byte("supu"), time.Second)
+	if _, ttl, ok := s.GetWithTTL("a"); !ok || ttl != time.Second {
+		t.Errorf("unexpected ttl: %v", ttl)
+	}
+	now = now.Add(2 * time.Second)
+	if _, ok := s.Get("a"); ok {
+		t.Error("the entry should have expired")
+	}
+	if e := s.Stats().Entries; e != 0 {
+		t.Errorf("unexpected number of entries: %d", e)
+	}
+}
+
+func TestStore_encrypted(t *testing.T) {
+	c, err := encryption.NewAESGCM(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	dir := t.TempDir()
+	s, err := New(cache.DiskOptions{Path: dir, Cipher: c})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	s.Set("a", []byte("sensitive payload"), time.Minute)
+	if b, ok := s.Get("a"); !ok || string(b) != "sensitive payload" {
+		t.Errorf("unexpected payload: %s", string(b))
+	}
+	s.Close()
+
+	raw, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if bytes.Contains(raw, []byte("sensitive payload")) {
+		t.Error("the entry has been persisted in clear text")
+	}
+}
+
+func TestNewDiskTier(t *testing.T) {
+	d, err := cache.NewDiskTier(cache.DiskOptions{Path: t.TempDir(), Engine: Engine})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	s, ok := d.(*Store)
+	if !ok {
+		t.Errorf("unexpected disk tier: %T", d)
+		return
+	}
+	defer s.Close()
+
+	tiered := cache.NewTieredStore(cache.Options{MaxBytes: 20}, d, 10)
+	tiered.Set("big", bytes.Repeat([]byte("x"), 30), time.Minute)
+	if b, ok := tiered.Get("big"); !ok || len(b) != 30 {
+		t.Errorf("unexpected payload: %s", string(b))
+	}
+	if e := tiered.DiskStats().Entries; e != 1 {
+		t.Errorf("the big payload should be stored in the database. entries: %d", e)
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/luraproject/lura/v2/cache/bbolt
+
+go 1.23
+
+require (
+	github.com/luraproject/lura/v2 v2.0.0-00010101000000-000000000000
+	go.etcd.io/bbolt v1.4.0
+)
+
+require golang.org/x/sys v0.29.0 // indirect
+
+replace github.com/luraproject/lura/v2 => ../..
@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package cache provides a size-aware in-memory store for the serialized responses
+of the cacheable endpoints
+*/
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store defines the interface of the response caches
+type Store interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+	Stats() Stats
+}
+
+// Stats is a snapshot of the usage counters of a Store
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	MaxBytes  int64  `json:"max_bytes"`
+}
+
+// HitRatio returns the ratio of lookups resolved by the store
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// DefaultMaxBytes is the memory budget of the stores created without an explicit one
+const DefaultMaxBytes = 64 * 1024 * 1024
+
+// Options contains the configuration of a MemoryStore
+type Options struct {
+	// MaxBytes is the hard limit for the size of the stored payloads
+	MaxBytes int64
+	// Compress flags if the payloads should be gzipped before storing them
+	Compress bool
+	// MinCompressSize is the smallest payload to be compressed
+	MinCompressSize int
+	// OnEvict, if defined, is called with the entries evicted because of the
+	// memory budget, along with their remaining ttl
+	OnEvict func(key string, value []byte, ttl time.Duration)
+}
+
+// NewMemoryStore returns a Store keeping the entries in memory and evicting the
+// least recently used ones whenever the memory budget is exhausted
+func NewMemoryStore(opts Options) *MemoryStore {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	return &MemoryStore{
+		opts:  opts,
+		items: map[string]*list.Element{},
+		lru:   list.New(),
+		mu:    new(sync.Mutex),
+		now:   time.Now,
+	}
+}
+
+// MemoryStore is a size-aware LRU Store
+type MemoryStore struct {
+	opts      Options
+	items     map[string]*list.Element
+	lru       *list.List
+	size      int64
+	mu        *sync.Mutex
+	now       func() time.Time
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type entry struct {
+	key        string
+	value      []byte
+	compressed bool
+	expiration time.Time
+}
+
+func (e *entry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+func (e *entry) payload() ([]byte, bool) {
+	if !e.compressed {
+		return e.value, true
+	}
+	r, err := gzip.NewReader(bytes.NewReader(e.value))
+	if err != nil {
+		return nil, false
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// Get returns the decompressed payload stored under the key, if it has not expired
+func (m *MemoryStore) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	el, ok := m.items[key]
+	if !ok {
+		m.mu.Unlock()
+		atomic.AddUint64(&m.misses, 1)
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !e.expiration.After(m.now()) {
+		m.remove(el)
+		m.mu.Unlock()
+		atomic.AddUint64(&m.misses, 1)
+		return nil, false
+	}
+	m.lru.MoveToFront(el)
+	m.mu.Unlock()
+
+	b, ok := e.payload()
+	if !ok {
+		atomic.AddUint64(&m.misses, 1)
+		return nil, false
+	}
+	atomic.AddUint64(&m.hits, 1)
+	return b, true
+}
+
+// Set stores the payload under the key for the given ttl. Payloads bigger than
+// the memory budget are ignored
+func (m *MemoryStore) Set(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	e := &entry{key: key, value: value}
+	if m.opts.Compress && len(value) >= m.opts.MinCompressSize {
+		if b, ok := compress(value); ok {
+			e.value = b
+			e.compressed = true
+		}
+	}
+	if e.size() > m.opts.MaxBytes {
+		return
+	}
+
+	var evicted []*entry
+
+	m.mu.Lock()
+	now := m.now()
+	e.expiration = now.Add(ttl)
+	if el, ok := m.items[key]; ok {
+		m.remove(el)
+	}
+	m.items[key] = m.lru.PushFront(e)
+	m.size += e.size()
+
+	for m.size > m.opts.MaxBytes {
+		el := m.lru.Back()
+		if el == nil {
+			break
+		}
+		evicted = append(evicted, m.remove(el))
+		atomic.AddUint64(&m.evictions, 1)
+	}
+	m.mu.Unlock()
+
+	if m.opts.OnEvict == nil {
+		return
+	}
+	for _, e := range evicted {
+		if !e.expiration.After(now) {
+			continue
+		}
+		if b, ok := e.payload(); ok {
+			m.opts.OnEvict(e.key, b, e.expiration.Sub(now))
+		}
+	}
+}
+
+// Stats returns a snapshot of the usage counters of the store
+func (m *MemoryStore) Stats() Stats {
+	m.mu.Lock()
+	entries, size := m.lru.Len(), m.size
+	m.mu.Unlock()
+
+	return Stats{
+		Hits:      atomic.LoadUint64(&m.hits),
+		Misses:    atomic.LoadUint64(&m.misses),
+		Evictions: atomic.LoadUint64(&m.evictions),
+		Entries:   entries,
+		Bytes:     size,
+		MaxBytes:  m.opts.MaxBytes,
+	}
+}
+
+func (m *MemoryStore) remove(el *list.Element) *entry {
+	e := m.lru.Remove(el).(*entry)
+	delete(m.items, e.key)
+	m.size -= e.size()
+	return e
+}
+
+func compress(value []byte) ([]byte, bool) {
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(value); err != nil {
+		return nil, false
+	}
+	if err := w.Close(); err != nil {
+		return nil, false
+	}
+	if buf.Len() >= len(value) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_budget(t *testing.T) {
+	s := NewMemoryStore(Options{MaxBytes: 30})
+
+	s.Set("a", []byte("0123456789"), time.Minute)
+	s.Set("b", []byte("0123456789"), time.Minute)
+	if _, ok := s.Get("a"); !ok {
+		t.Error("the entry a should be stored")
+	}
+	s.Set("c", []byte("0123456789"), time.Minute)
+
+	if _, ok := s.Get("b"); ok {
+		t.Error("the least recently used entry should have been evicted")
+	}
+	if _, ok := s.Get("c"); !ok {
+		t.Error("the entry c should be stored")
+	}
+
+	s.Set("d", bytes.Repeat([]byte("x"), 100), time.Minute)
+	if _, ok := s.Get("d"); ok {
+		t.Error("payloads bigger than the budget should be ignored")
+	}
+
+	stats := s.Stats()
+	if stats.Evictions != 1 {
+		t.Errorf("unexpected number of evictions: %d", stats.Evictions)
+	}
+	if stats.Bytes > 30 {
+		t.Errorf("the budget has been exceeded: %d", stats.Bytes)
+	}
+	if stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if r := stats.HitRatio(); r != 0.5 {
+		t.Errorf("unexpected hit ratio: %f", r)
+	}
+}
+
+func TestMemoryStore_compress(t *testing.T) {
+	s := NewMemoryStore(Options{MaxBytes: 1024, Compress: true})
+	payload := bytes.Repeat([]byte(`{"a":"some value"}`), 100)
+
+	s.Set("a", payload, time.Minute)
+
+	if b := s.Stats().Bytes; b >= int64(len(payload)) {
+		t.Errorf("the payload has not been compressed: %d", b)
+	}
+	res, ok := s.Get("a")
+	if !ok {
+		t.Error("the entry should be stored")
+		return
+	}
+	if !bytes.Equal(res, payload) {
+		t.Errorf("unexpected payload: %s", string(res))
+	}
+}
+
+func TestMemoryStore_expiration(t *testing.T) {
+	s := NewMemoryStore(Options{})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.Set("a", []byte("supu"), time.Second)
+	if _, ok := s.Get("a"); !ok {
+		t.Error("the entry should be stored")
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, ok := s.Get("a"); ok {
+		t.Error("the entry should have expired")
+	}
+	if e := s.Stats().Entries; e != 0 {
+		t.Errorf("unexpected number of entries: %d", e)
+	}
+}
@@ -0,0 +1,260 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/luraproject/lura/v2/encryption"
+	"github.com/luraproject/lura/v2/register"
+)
+
+// DiskOptions contains the configuration of a DiskStore
+type DiskOptions struct {
+	// Engine is the name of the DiskTierFactory persisting the entries. The DefaultDiskEngine
+	// is used when empty
+	Engine string
+	// Path is the folder where the entries are persisted
+	Path string
+	// MaxBytes is the hard limit for the size of the persisted payloads
+	MaxBytes int64
+	// Cipher, if defined, encrypts the persisted entries
+	Cipher encryption.Cipher
+}
+
+// DefaultDiskMaxBytes is the disk budget of the stores created without an explicit one
+const DefaultDiskMaxBytes = 1024 * 1024 * 1024
+
+// DefaultDiskEngine is the name of the engine persisting every entry in a file of its own
+const DefaultDiskEngine = "file"
+
+// DiskTier is a Store persisting the entries, usable as the second level of a TieredStore
+type DiskTier interface {
+	Store
+	GetWithTTL(key string) ([]byte, time.Duration, bool)
+}
+
+// DiskTierFactory creates a DiskTier with the received options
+type DiskTierFactory func(DiskOptions) (DiskTier, error)
+
+var diskEngines = register.NewUntyped()
+
+func init() {
+	RegisterDiskEngine(DefaultDiskEngine, func(opts DiskOptions) (DiskTier, error) {
+		return NewDiskStore(opts)
+	})
+}
+
+// RegisterDiskEngine adds a disk tier factory to the package register, so the disk tiers
+// can select it by name
+func RegisterDiskEngine(name string, f DiskTierFactory) {
+	diskEngines.Register(name, f)
+}
+
+// NewDiskTier returns a DiskTier created by the engine selected in the options
+func NewDiskTier(opts DiskOptions) (DiskTier, error) {
+	name := opts.Engine
+	if name == "" {
+		name = DefaultDiskEngine
+	}
+	v, ok := diskEngines.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("cache: unknown disk engine %q", name)
+	}
+	f, ok := v.(DiskTierFactory)
+	if !ok {
+		return nil, fmt.Errorf("cache: unknown disk engine %q", name)
+	}
+	return f(opts)
+}
+
+// NewDiskStore returns a Store persisting the entries as files in the configured folder.
+// The entries already present in the folder are indexed, so they survive restarts
+func NewDiskStore(opts DiskOptions) (*DiskStore, error) {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultDiskMaxBytes
+	}
+	if err := os.MkdirAll(opts.Path, 0o700); err != nil {
+		return nil, err
+	}
+	d := &DiskStore{
+		opts:  opts,
+		index: map[string]diskEntry{},
+		mu:    new(sync.Mutex),
+		now:   time.Now,
+	}
+	files, err := os.ReadDir(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(f.Name(), ".") {
+			os.Remove(filepath.Join(opts.Path, f.Name()))
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		d.index[f.Name()] = diskEntry{size: info.Size(), lastAccess: info.ModTime()}
+		d.size += info.Size()
+	}
+	d.mu.Lock()
+	d.evict()
+	d.mu.Unlock()
+	return d, nil
+}
+
+// DiskStore is a size-aware Store backed by the local filesystem
+type DiskStore struct {
+	opts      DiskOptions
+	index     map[string]diskEntry
+	size      int64
+	mu        *sync.Mutex
+	now       func() time.Time
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type diskEntry struct {
+	size       int64
+	lastAccess time.Time
+}
+
+// Get returns the payload stored under the key, if it has not expired
+func (d *DiskStore) Get(key string) ([]byte, bool) {
+	b, _, ok := d.GetWithTTL(key)
+	return b, ok
+}
+
+// GetWithTTL returns the payload stored under the key and its remaining ttl, if it has not expired
+func (d *DiskStore) GetWithTTL(key string) ([]byte, time.Duration, bool) {
+	name := diskFileName(key)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e, ok := d.index[name]
+	if !ok {
+		atomic.AddUint64(&d.misses, 1)
+		return nil, 0, false
+	}
+	b, err := d.read(name)
+	if err != nil || len(b) < 8 {
+		d.remove(name)
+		atomic.AddUint64(&d.misses, 1)
+		return nil, 0, false
+	}
+	now := d.now()
+	expiration := time.Unix(0, int64(binary.BigEndian.Uint64(b[:8])))
+	if !expiration.After(now) {
+		d.remove(name)
+		atomic.AddUint64(&d.misses, 1)
+		return nil, 0, false
+	}
+	e.lastAccess = now
+	d.index[name] = e
+	atomic.AddUint64(&d.hits, 1)
+	return b[8:], expiration.Sub(now), true
+}
+
+// Set persists the payload under the key for the given ttl. Payloads bigger than
+// the disk budget are ignored
+func (d *DiskStore) Set(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 || int64(len(value)+8) > d.opts.MaxBytes {
+		return
+	}
+	name := diskFileName(key)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	b := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(b[:8], uint64(now.Add(ttl).UnixNano()))
+	copy(b[8:], value)
+
+	if d.opts.Cipher != nil {
+		var err error
+		if b, err = d.opts.Cipher.Encrypt(b, []byte(name)); err != nil {
+			return
+		}
+	}
+
+	tmp := filepath.Join(d.opts.Path, "."+name)
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, filepath.Join(d.opts.Path, name)); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	if e, ok := d.index[name]; ok {
+		d.size -= e.size
+	}
+	d.index[name] = diskEntry{size: int64(len(b)), lastAccess: now}
+	d.size += int64(len(b))
+	d.evict()
+}
+
+// Stats returns a snapshot of the usage counters of the store
+func (d *DiskStore) Stats() Stats {
+	d.mu.Lock()
+	entries, size := len(d.index), d.size
+	d.mu.Unlock()
+
+	return Stats{
+		Hits:      atomic.LoadUint64(&d.hits),
+		Misses:    atomic.LoadUint64(&d.misses),
+		Evictions: atomic.LoadUint64(&d.evictions),
+		Entries:   entries,
+		Bytes:     size,
+		MaxBytes:  d.opts.MaxBytes,
+	}
+}
+
+func (d *DiskStore) read(name string) ([]byte, error) {
+	b, err := os.ReadFile(filepath.Join(d.opts.Path, name))
+	if err != nil || d.opts.Cipher == nil {
+		return b, err
+	}
+	return d.opts.Cipher.Decrypt(b, []byte(name))
+}
+
+func (d *DiskStore) evict() {
+	for d.size > d.opts.MaxBytes && len(d.index) > 0 {
+		var oldest string
+		var lastAccess time.Time
+		for name, e := range d.index {
+			if oldest == "" || e.lastAccess.Before(lastAccess) {
+				oldest, lastAccess = name, e.lastAccess
+			}
+		}
+		d.remove(oldest)
+		atomic.AddUint64(&d.evictions, 1)
+	}
+}
+
+func (d *DiskStore) remove(name string) {
+	os.Remove(filepath.Join(d.opts.Path, name))
+	d.size -= d.index[name].size
+	delete(d.index, name)
+}
+
+func diskFileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
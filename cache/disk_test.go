@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/encryption"
+)
+
+func TestDiskStore(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDiskStore(DiskOptions{Path: dir, MaxBytes: 60})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	d.Set("a", []byte("0123456789"), time.Minute)
+	d.Set("b", []byte("0123456789"), time.Minute)
+	d.Set("c", []byte("0123456789"), time.Minute)
+	d.Set("d", []byte("0123456789"), time.Minute)
+
+	if _, ok := d.Get("a"); ok {
+		t.Error("the oldest entry should have been evicted")
+	}
+	b, ok := d.Get("d")
+	if !ok || !bytes.Equal(b, []byte("0123456789")) {
+		t.Errorf("unexpected payload: %s", string(b))
+	}
+	if stats := d.Stats(); stats.Bytes > 60 || stats.Evictions != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	restarted, err := NewDiskStore(DiskOptions{Path: dir, MaxBytes: 60})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := restarted.Get("d"); !ok {
+		t.Error("the entries should survive restarts")
+	}
+	if e := restarted.Stats().Entries; e != 3 {
+		t.Errorf("unexpected number of entries: %d", e)
+	}
+}
+
+func TestDiskStore_expiration(t *testing.T) {
+	d, err := NewDiskStore(DiskOptions{Path: t.TempDir()})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	d.Set("a", []byte("supu"), time.Second)
+	if _, ttl, ok := d.GetWithTTL("a"); !ok || ttl != time.Second {
+		t.Errorf("unexpected ttl: %v", ttl)
+	}
+	now = now.Add(2 * time.Second)
+	if _, ok := d.Get("a"); ok {
+		t.Error("the entry should have expired")
+	}
+	if e := d.Stats().Entries; e != 0 {
+		t.Errorf("unexpected number of entries: %d", e)
+	}
+}
+
+func TestTieredStore(t *testing.T) {
+	d, err := NewDiskStore(DiskOptions{Path: t.TempDir()})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	evicted := make(chan string, 10)
+	s := NewTieredStore(Options{
+		MaxBytes: 20,
+		OnEvict:  func(key string, _ []byte, _ time.Duration) { evicted <- key },
+	}, d, 20)
+
+	s.Set("big", bytes.Repeat([]byte("x"), 30), time.Minute)
+	if s.MemoryStats().Entries != 0 || s.DiskStats().Entries != 1 {
+		t.Error("big payloads should be stored directly on disk")
+	}
+
+	s.Set("a", []byte("0123456789"), time.Minute)
+	s.Set("b", []byte("0123456789"), time.Minute)
+
+	select {
+	case k := <-evicted:
+		if k != "a" {
+			t.Errorf("unexpected evicted key: %s", k)
+		}
+	case <-time.After(time.Second):
+		t.Error("the entry a should have been evicted from memory")
+		return
+	}
+
+	for i := 0; i < 100; i++ {
+		if s.DiskStats().Entries == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if e := s.DiskStats().Entries; e != 2 {
+		t.Errorf("the evicted entry should have been demoted to disk. entries: %d", e)
+	}
+
+	if b, ok := s.Get("a"); !ok || string(b) != "0123456789" {
+		t.Errorf("unexpected payload: %s", string(b))
+	}
+	if _, ok := s.Get("big"); !ok {
+		t.Error("the big payload should be available")
+	}
+	if _, ok := s.Get("unknown"); ok {
+		t.Error("unexpected entry")
+	}
+	if stats := s.Stats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestDiskStore_encrypted(t *testing.T) {
+	c, err := encryption.NewAESGCM(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	dir := t.TempDir()
+	d, err := NewDiskStore(DiskOptions{Path: dir, Cipher: c})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	d.Set("a", []byte("sensitive payload"), time.Minute)
+
+	raw, err := os.ReadFile(filepath.Join(dir, diskFileName("a")))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if bytes.Contains(raw, []byte("sensitive payload")) {
+		t.Error("the entry has been persisted in clear text")
+	}
+
+	if b, ok := d.Get("a"); !ok || string(b) != "sensitive payload" {
+		t.Errorf("unexpected payload: %s", string(b))
+	}
+
+	other, _ := encryption.NewAESGCM(bytes.Repeat([]byte("x"), 32))
+	d, err = NewDiskStore(DiskOptions{Path: dir, Cipher: other})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := d.Get("a"); ok {
+		t.Error("the entries encrypted with another key should be discarded")
+	}
+}
+
+func TestNewDiskTier(t *testing.T) {
+	d, err := NewDiskTier(DiskOptions{Path: t.TempDir()})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := d.(*DiskStore); !ok {
+		t.Errorf("unexpected default engine: %T", d)
+	}
+
+	if _, err := NewDiskTier(DiskOptions{Path: t.TempDir(), Engine: "unknown"}); err == nil {
+		t.Error("error expected")
+	} else if err.Error() != `cache: unknown disk engine "unknown"` {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
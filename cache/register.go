@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"github.com/luraproject/lura/v2/register"
+)
+
+// GetRegister returns the package register
+func GetRegister() *Register {
+	return stores
+}
+
+// Register keeps track of the stores created by the pipes, so their stats
+// can be exposed by the metrics and admin components
+type Register struct {
+	data *register.Untyped
+}
+
+// Register adds the store to the register under the given name
+func (r *Register) Register(name string, s Store) {
+	r.data.Register(name, s)
+}
+
+// Get returns the store registered under the given name
+func (r *Register) Get(name string) (Store, bool) {
+	v, ok := r.data.Get(name)
+	if !ok {
+		return nil, ok
+	}
+	s, ok := v.(Store)
+	return s, ok
+}
+
+// Stats returns a snapshot of the stats of all the registered stores
+func (r *Register) Stats() map[string]Stats {
+	res := map[string]Stats{}
+	for k, v := range r.data.Clone() {
+		if s, ok := v.(Store); ok {
+			res[k] = s.Stats()
+		}
+	}
+	return res
+}
+
+var stores = &Register{register.NewUntyped()}
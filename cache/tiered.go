@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"time"
+)
+
+// NewTieredStore returns a Store keeping the hot entries in memory and the rest on disk.
+// The entries evicted from the memory tier are demoted to the disk tier and the ones found
+// in the disk tier are promoted back to memory. Both movements are executed asynchronously.
+// Payloads bigger than maxMemoryEntry bytes are sent directly to the disk tier, so they do
+// not blow the memory budget
+func NewTieredStore(opts Options, disk DiskTier, maxMemoryEntry int) *TieredStore {
+	t := &TieredStore{
+		disk:           disk,
+		maxMemoryEntry: maxMemoryEntry,
+		tasks:          make(chan func(), 1024),
+	}
+	onEvict := opts.OnEvict
+	opts.OnEvict = func(key string, value []byte, ttl time.Duration) {
+		t.async(func() { t.disk.Set(key, value, ttl) })
+		if onEvict != nil {
+			onEvict(key, value, ttl)
+		}
+	}
+	t.memory = NewMemoryStore(opts)
+	go t.run()
+	return t
+}
+
+// TieredStore is a two level Store: memory and disk
+type TieredStore struct {
+	memory         *MemoryStore
+	disk           DiskTier
+	maxMemoryEntry int
+	tasks          chan func()
+}
+
+// Get looks for the key in the memory tier and then in the disk one
+func (t *TieredStore) Get(key string) ([]byte, bool) {
+	if b, ok := t.memory.Get(key); ok {
+		return b, ok
+	}
+	b, ttl, ok := t.disk.GetWithTTL(key)
+	if !ok {
+		return nil, ok
+	}
+	if t.fitsInMemory(b) {
+		t.async(func() { t.memory.Set(key, b, ttl) })
+	}
+	return b, ok
+}
+
+// Set stores the payload in the memory tier or, if it is too big, in the disk one
+func (t *TieredStore) Set(key string, value []byte, ttl time.Duration) {
+	if !t.fitsInMemory(value) {
+		t.disk.Set(key, value, ttl)
+		return
+	}
+	t.memory.Set(key, value, ttl)
+}
+
+// Stats returns the aggregated stats of both tiers
+func (t *TieredStore) Stats() Stats {
+	m, d := t.memory.Stats(), t.disk.Stats()
+	return Stats{
+		Hits:      m.Hits + d.Hits,
+		Misses:    d.Misses,
+		Evictions: d.Evictions,
+		Entries:   m.Entries + d.Entries,
+		Bytes:     m.Bytes + d.Bytes,
+		MaxBytes:  m.MaxBytes + d.MaxBytes,
+	}
+}
+
+// MemoryStats returns the stats of the memory tier
+func (t *TieredStore) MemoryStats() Stats { return t.memory.Stats() }
+
+// DiskStats returns the stats of the disk tier
+func (t *TieredStore) DiskStats() Stats { return t.disk.Stats() }
+
+func (t *TieredStore) fitsInMemory(b []byte) bool {
+	return t.maxMemoryEntry <= 0 || len(b) <= t.maxMemoryEntry
+}
+
+// async enqueues the task without blocking. Tasks are dropped if the queue is full,
+// since moving entries between tiers is just an optimization
+func (t *TieredStore) async(task func()) {
+	select {
+	case t.tasks <- task:
+	default:
+	}
+}
+
+func (t *TieredStore) run() {
+	for task := range t.tasks {
+		task()
+	}
+}
@@ -0,0 +1,243 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Marshal returns the canonical JSON representation of the service config, using the format of
+// the configuration files. Every property is present, even the ones with zero values, the keys
+// are sorted and the durations use the time.Duration format, so two equivalent configs always
+// return the same bytes and the generated configs can be diffed reliably.
+//
+// The config is serialized as is: marshal the configs returned by the parsers or the ones already
+// initialized, so the defaults applied by Init are materialized.
+func Marshal(s ServiceConfig) ([]byte, error) {
+	b, err := json.Marshal(denormalizeServiceConfig(s))
+	if err != nil {
+		return nil, err
+	}
+	var v map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if p, ok := v["plugin"].(map[string]interface{}); ok {
+		// the Plugin struct has no json tags
+		v["plugin"] = lowerKeys(p)
+	}
+
+	buf := new(bytes.Buffer)
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	e.SetIndent("", "  ")
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func lowerKeys(m map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		res[strings.ToLower(k)] = v
+	}
+	return res
+}
+
+func denormalizeServiceConfig(s ServiceConfig) *parseableServiceConfig {
+	p := &parseableServiceConfig{
+		Name:                  s.Name,
+		Endpoints:             make([]*parseableEndpointConfig, 0, len(s.Endpoints)),
+		AsyncAgents:           make([]*parseableAsyncAgent, 0, len(s.AsyncAgents)),
+		Timeout:               s.Timeout.String(),
+		CacheTTL:              s.CacheTTL.String(),
+		Host:                  nonNilStrings(s.Host),
+		Port:                  s.Port,
+		Address:               s.Address,
+		Version:               s.Version,
+		ExtraConfig:           nonNilExtraConfig(s.ExtraConfig),
+		ReadTimeout:           s.ReadTimeout.String(),
+		WriteTimeout:          s.WriteTimeout.String(),
+		IdleTimeout:           s.IdleTimeout.String(),
+		ReadHeaderTimeout:     s.ReadHeaderTimeout.String(),
+		DisableKeepAlives:     s.DisableKeepAlives,
+		DisableCompression:    s.DisableCompression,
+		DisableStrictREST:     s.DisableStrictREST,
+		StrictNamespaces:      s.StrictNamespaces,
+		MaxIdleConns:          s.MaxIdleConns,
+		MaxIdleConnsPerHost:   s.MaxIdleConnsPerHost,
+		IdleConnTimeout:       s.IdleConnTimeout.String(),
+		ResponseHeaderTimeout: s.ResponseHeaderTimeout.String(),
+		ExpectContinueTimeout: s.ExpectContinueTimeout.String(),
+		OutputEncoding:        s.OutputEncoding,
+		DialerTimeout:         s.DialerTimeout.String(),
+		DialerFallbackDelay:   s.DialerFallbackDelay.String(),
+		DialerKeepAlive:       s.DialerKeepAlive.String(),
+		Debug:                 s.Debug,
+		Echo:                  s.Echo,
+		Plugin:                s.Plugin,
+		UseH2C:                s.UseH2C,
+		HeadersToPass:         nonNilStrings(s.HeadersToPass),
+		QueryString:           nonNilStrings(s.QueryString),
+		ConcurrentCalls:       s.ConcurrentCalls,
+	}
+	if s.TLS != nil {
+		p.TLS = &parseableTLS{
+			IsDisabled:               s.TLS.IsDisabled,
+			PublicKey:                s.TLS.PublicKey,
+			PrivateKey:               s.TLS.PrivateKey,
+			CaCerts:                  s.TLS.CaCerts,
+			MinVersion:               s.TLS.MinVersion,
+			MaxVersion:               s.TLS.MaxVersion,
+			CurvePreferences:         s.TLS.CurvePreferences,
+			PreferServerCipherSuites: s.TLS.PreferServerCipherSuites,
+			CipherSuites:             s.TLS.CipherSuites,
+			EnableMTLS:               s.TLS.EnableMTLS,
+			DisableSystemCaPool:      s.TLS.DisableSystemCaPool,
+			EnableHTTP3:              s.TLS.EnableHTTP3,
+			HTTP3Port:                s.TLS.HTTP3Port,
+			EnableHotReload:          s.TLS.EnableHotReload,
+			HotReloadInterval:        s.TLS.HotReloadInterval.String(),
+		}
+	}
+	p.ClientTLS = denormalizeClientTLS(s.ClientTLS)
+	for _, e := range s.Endpoints {
+		p.Endpoints = append(p.Endpoints, denormalizeEndpointConfig(e))
+	}
+	for _, a := range s.AsyncAgents {
+		p.AsyncAgents = append(p.AsyncAgents, denormalizeAsyncAgent(a))
+	}
+	for _, t := range s.Tenants {
+		p.Tenants = append(p.Tenants, denormalizeTenant(t))
+	}
+	return p
+}
+
+func denormalizeEndpointConfig(e *EndpointConfig) *parseableEndpointConfig {
+	p := &parseableEndpointConfig{
+		Endpoint:        e.Endpoint,
+		Method:          e.Method,
+		Backend:         denormalizeBackends(e.Backend),
+		ConcurrentCalls: e.ConcurrentCalls,
+		Timeout:         e.Timeout.String(),
+		CacheTTL:        e.CacheTTL.String(),
+		QueryString:     nonNilStrings(e.QueryString),
+		ExtraConfig:     nonNilExtraConfig(e.ExtraConfig),
+		HeadersToPass:   nonNilStrings(e.HeadersToPass),
+		OutputEncoding:  e.OutputEncoding,
+		Tenant:          e.Tenant,
+		Hosts:           e.Hosts,
+	}
+	for _, a := range e.Aliases {
+		p.Aliases = append(p.Aliases, parseableAlias{Path: a.Path, Deprecated: a.Deprecated, Sunset: a.Sunset})
+	}
+	return p
+}
+
+func denormalizeTenant(t *Tenant) *parseableTenant {
+	p := &parseableTenant{
+		Name:            t.Name,
+		Hosts:           nonNilStrings(t.Hosts),
+		PathPrefix:      t.PathPrefix,
+		Timeout:         t.Timeout.String(),
+		CacheTTL:        t.CacheTTL.String(),
+		HeadersToPass:   nonNilStrings(t.HeadersToPass),
+		QueryString:     nonNilStrings(t.QueryString),
+		ConcurrentCalls: t.ConcurrentCalls,
+		OutputEncoding:  t.OutputEncoding,
+		ExtraConfig:     nonNilExtraConfig(t.ExtraConfig),
+		Endpoints:       make([]*parseableEndpointConfig, 0, len(t.Endpoints)),
+	}
+	for _, e := range t.Endpoints {
+		p.Endpoints = append(p.Endpoints, denormalizeEndpointConfig(e))
+	}
+	return p
+}
+
+func denormalizeAsyncAgent(a *AsyncAgent) *parseableAsyncAgent {
+	p := &parseableAsyncAgent{
+		Name:        a.Name,
+		Encoding:    a.Encoding,
+		Backend:     denormalizeBackends(a.Backend),
+		ExtraConfig: *nonNilExtraConfig(a.ExtraConfig),
+	}
+	p.Connection.MaxRetries = a.Connection.MaxRetries
+	p.Connection.BackoffStrategy = a.Connection.BackoffStrategy
+	p.Connection.HealthInterval = a.Connection.HealthInterval.String()
+	p.Consumer.Timeout = a.Consumer.Timeout.String()
+	p.Consumer.Workers = a.Consumer.Workers
+	p.Consumer.Topic = a.Consumer.Topic
+	p.Consumer.MaxRate = a.Consumer.MaxRate
+	return p
+}
+
+func denormalizeBackends(bs []*Backend) []*parseableBackend {
+	res := make([]*parseableBackend, 0, len(bs))
+	for _, b := range bs {
+		mapping := b.Mapping
+		if mapping == nil {
+			mapping = map[string]string{}
+		}
+		res = append(res, &parseableBackend{
+			Group:                    b.Group,
+			Method:                   b.Method,
+			Host:                     nonNilStrings(b.Host),
+			HostSanitizationDisabled: b.HostSanitizationDisabled,
+			URLPattern:               b.URLPattern,
+			AllowList:                nonNilStrings(b.AllowList),
+			DenyList:                 nonNilStrings(b.DenyList),
+			Mapping:                  mapping,
+			Encoding:                 b.Encoding,
+			IsCollection:             b.IsCollection,
+			Target:                   b.Target,
+			ExtraConfig:              nonNilExtraConfig(b.ExtraConfig),
+			SD:                       b.SD,
+			HeadersToPass:            nonNilStrings(b.HeadersToPass),
+			SDScheme:                 b.SDScheme,
+			QueryStringsToPass:       nonNilStrings(b.QueryStringsToPass),
+			ClientTLS:                denormalizeClientTLS(b.ClientTLS),
+		})
+	}
+	return res
+}
+
+func denormalizeClientTLS(c *ClientTLS) *parseableClientTLS {
+	if c == nil {
+		return nil
+	}
+	p := &parseableClientTLS{
+		AllowInsecureConnections: c.AllowInsecureConnections,
+		CaCerts:                  c.CaCerts,
+		DisableSystemCaPool:      c.DisableSystemCaPool,
+		MinVersion:               c.MinVersion,
+		MaxVersion:               c.MaxVersion,
+		CurvePreferences:         c.CurvePreferences,
+		CipherSuites:             c.CipherSuites,
+		ClientCerts:              make([]parseableClientTLSCert, 0, len(c.ClientCerts)),
+		ServerName:               c.ServerName,
+	}
+	for _, cc := range c.ClientCerts {
+		p.ClientCerts = append(p.ClientCerts, parseableClientTLSCert(cc))
+	}
+	return p
+}
+
+// the nil slices and maps are rendered as empty ones, so the zero values do not change the output
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func nonNilExtraConfig(e ExtraConfig) *ExtraConfig {
+	if e == nil {
+		e = ExtraConfig{}
+	}
+	return &e
+}
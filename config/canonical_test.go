@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshal(t *testing.T) {
+	cfg := ServiceConfig{
+		Version:  ConfigVersion,
+		Name:     "gateway",
+		Timeout:  3 * time.Second,
+		CacheTTL: time.Hour,
+		Host:     []string{"http://b", "http://a"},
+		Plugin:   &Plugin{Folder: "./plugins", Pattern: ".so"},
+		ExtraConfig: ExtraConfig{
+			"zeta":  map[string]interface{}{"b": 1, "a": "<html>"},
+			"alpha": true,
+		},
+		Endpoints: []*EndpointConfig{
+			{
+				Endpoint: "/users/{id}",
+				Method:   "GET",
+				Timeout:  1500 * time.Millisecond,
+				Backend: []*Backend{
+					{URLPattern: "/u/{id}", Mapping: map[string]string{"b": "c"}},
+				},
+			},
+		},
+	}
+
+	b, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, again) {
+		t.Error("the output must be deterministic")
+	}
+
+	s := string(b)
+	for _, expected := range []string{
+		`"timeout": "3s"`,
+		`"cache_ttl": "1h0m0s"`,
+		`"timeout": "1.5s"`,
+		`"read_timeout": "0s"`,
+		`"input_headers": []`,
+		`"a": "<html>"`,
+		`"folder": "./plugins"`,
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("%s not found in %s", expected, s)
+		}
+	}
+	if strings.Index(s, `"alpha"`) > strings.Index(s, `"zeta"`) {
+		t.Error("the keys must be sorted")
+	}
+	if strings.Index(s, `"http://b"`) > strings.Index(s, `"http://a"`) {
+		t.Error("the order of the lists must be kept")
+	}
+
+	var p parseableServiceConfig
+	if err := json.Unmarshal(b, &p); err != nil {
+		t.Fatal(err)
+	}
+	res := p.normalize()
+	if res.Timeout != cfg.Timeout || res.CacheTTL != cfg.CacheTTL || res.Plugin.Folder != "./plugins" {
+		t.Errorf("unexpected config %+v", res)
+	}
+	if len(res.Endpoints) != 1 || res.Endpoints[0].Timeout != 1500*time.Millisecond || res.Endpoints[0].Backend[0].Mapping["b"] != "c" {
+		t.Errorf("unexpected endpoints %+v", res.Endpoints)
+	}
+}
@@ -13,7 +13,6 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"net/textproto"
 	"regexp"
 	"sort"
 	"strings"
@@ -49,6 +48,8 @@ type ServiceConfig struct {
 	Endpoints []*EndpointConfig `mapstructure:"endpoints"`
 	// set of async agent definitions
 	AsyncAgents []*AsyncAgent `mapstructure:"async_agent"`
+	// set of tenants grouping endpoints under their own hosts, path prefix and defaults
+	Tenants []*Tenant `mapstructure:"tenants"`
 	// defafult timeout
 	Timeout time.Duration `mapstructure:"timeout"`
 	// default TTL for GET
@@ -63,6 +64,14 @@ type ServiceConfig struct {
 	Version int `mapstructure:"version"`
 	// OutputEncoding defines the default encoding strategy to use for the endpoint responses
 	OutputEncoding string `mapstructure:"output_encoding"`
+	// HeadersToPass defines the default list of headers to pass to the backends,
+	// used by the endpoints without their own list
+	HeadersToPass []string `mapstructure:"input_headers"`
+	// QueryString defines the default list of query string params to pass to the backends,
+	// used by the endpoints without their own list
+	QueryString []string `mapstructure:"input_query_strings"`
+	// ConcurrentCalls defines the default number of concurrent calls to the backends
+	ConcurrentCalls int `mapstructure:"concurrent_calls"`
 	// Extra configuration for customized behaviour
 	ExtraConfig ExtraConfig `mapstructure:"extra_config"`
 
@@ -153,6 +162,12 @@ type ServiceConfig struct {
 	// DisableStrictREST flags if the REST enforcement is disabled
 	DisableStrictREST bool `mapstructure:"disable_rest"`
 
+	// StrictNamespaces rejects the deprecated extra config namespaces instead of moving them
+	// to their replacements
+	StrictNamespaces bool `mapstructure:"strict_namespaces"`
+	// namespaceWarnings are the uses of deprecated namespaces found by Init
+	namespaceWarnings []error
+
 	// Plugin defines the configuration for the plugin loader
 	Plugin *Plugin `mapstructure:"plugin"`
 
@@ -233,6 +248,23 @@ type EndpointConfig struct {
 	HeadersToPass []string `mapstructure:"input_headers"`
 	// OutputEncoding defines the encoding strategy to use for the endpoint responses
 	OutputEncoding string `mapstructure:"output_encoding"`
+	// Aliases is a set of extra paths (usually old URLs) to be registered against the same pipe
+	Aliases []EndpointAlias `mapstructure:"aliases"`
+	// Tenant is the name of the tenant declaring the endpoint, if any
+	Tenant string `mapstructure:"tenant"`
+	// Hosts restricts the endpoint to the requests sent to these hosts. If empty, the endpoint
+	// is served to all of them
+	Hosts []string `mapstructure:"hosts"`
+}
+
+// EndpointAlias defines an extra path exposing the same pipe of an endpoint
+type EndpointAlias struct {
+	// Path is the url pattern of the alias. It must declare the same params than the endpoint
+	Path string `mapstructure:"path"`
+	// Deprecated flags the alias, so the responses include the Deprecation header
+	Deprecated bool `mapstructure:"deprecated"`
+	// Sunset is the optional value of the Sunset header (an HTTP-date) for deprecated aliases
+	Sunset string `mapstructure:"sunset"`
 }
 
 // Backend defines how lura should connect to the backend service (the API resource to consume)
@@ -280,6 +312,9 @@ type Backend struct {
 	HeadersToPass []string `mapstructure:"input_headers"`
 	// QueryStringsToPass has the list of query string params to be sent to the backend
 	QueryStringsToPass []string `mapstructure:"input_query_strings"`
+	// ClientTLS defines the TLS params to use for the connections to this backend,
+	// overriding the ones of the http default transport
+	ClientTLS *ClientTLS `mapstructure:"client_tls"`
 
 	// ParentEndpoint is to be filled by the parent endpoint with its pattern enpoint
 	// so logs and other instrumentation can output better info (thus, it is not loaded
@@ -310,6 +345,14 @@ type TLS struct {
 	CipherSuites             []uint16 `mapstructure:"cipher_suites"`
 	EnableMTLS               bool     `mapstructure:"enable_mtls"`
 	DisableSystemCaPool      bool     `mapstructure:"disable_system_ca_pool"`
+	// EnableHTTP3 starts an HTTP/3 (QUIC) listener alongside the TCP one
+	EnableHTTP3 bool `mapstructure:"enable_http3"`
+	// HTTP3Port is the UDP port of the HTTP/3 listener. If zero, the service port is used
+	HTTP3Port int `mapstructure:"http3_port"`
+	// EnableHotReload reloads the certificate when its files change or on SIGHUP
+	EnableHotReload bool `mapstructure:"enable_hot_reload"`
+	// HotReloadInterval is the period between checks of the certificate files
+	HotReloadInterval time.Duration `mapstructure:"hot_reload_interval"`
 }
 
 // ClientTLS defines the configuration params for an HTTP Client
@@ -322,6 +365,8 @@ type ClientTLS struct {
 	CurvePreferences         []uint16        `mapstructure:"curve_preferences"`
 	CipherSuites             []uint16        `mapstructure:"cipher_suites"`
 	ClientCerts              []ClientTLSCert `mapstructure:"client_certs"`
+	// ServerName overrides the name used to verify the certificate of the backend
+	ServerName string `mapstructure:"server_name"`
 }
 
 // ClientTLSCert holds a certificate with its private key to be
@@ -358,10 +403,35 @@ func (e *ExtraConfig) Normalize() {
 // ExtraConfigAlias is the set of alias to accept as namespace
 var ExtraConfigAlias = map[string]string{}
 
+// BackendlessNamespaces is the set of namespaces declaring endpoints served by the router itself,
+// like the redirects, so they do not require any backend
+var BackendlessNamespaces = map[string]struct{}{}
+
+// InputParamsExtractors is the set of functions returning the names of the params computed by the
+// feature packages for an endpoint, keyed by the namespace of the package, so the backends can use
+// them in their url patterns like the params of the endpoint
+var InputParamsExtractors = map[string]func(ExtraConfig) []string{}
+
+// ReservedPaths is the set of functions returning the paths served by the feature packages
+// declared in the service extra config, keyed by the namespace of the package, so the endpoints
+// and their aliases can not shadow them
+var ReservedPaths = map[string]func(ExtraConfig) []string{}
+
+// IsBackendless returns true if the endpoint declares one of the BackendlessNamespaces
+func (e *EndpointConfig) IsBackendless() bool {
+	for namespace := range BackendlessNamespaces {
+		if _, ok := e.ExtraConfig[namespace]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 var (
-	simpleURLKeysPattern    = regexp.MustCompile(`\{([\w\-\.:/]+)\}`)
-	sequentialParamsPattern = regexp.MustCompile(`^(resp[\d]+_.+)?(JWT\.([\w\-\.:/]+))?$`)
-	invalidPattern          = `^[^/]|\*.|/__(debug|echo|health)(/.*)?$`
+	simpleURLKeysPattern    = regexp.MustCompile(`\{([\w\-\.:/]+?)(?:\?|\.\.\.)?\}`)
+	urlKeysMarkersPattern   = regexp.MustCompile(`\{([\w\-\.:/]+?)(?:\?|\.\.\.)\}`)
+	sequentialParamsPattern = regexp.MustCompile(`^(resp[\d]+_.+)?(resp[\d]+(Status|Header_[\w\-]+))?(JWT\.([\w\-\.:/]+))?$`)
+	invalidPattern          = `^[^/]|\*.|/__(debug|echo|health)(/.*)?$|[^/]\{[\w\-\.:/]+?(\?|\.\.\.)\}|\{[\w\-\.:/]+?(\?|\.\.\.)\}.`
 	errInvalidHost          = errors.New("invalid host")
 	errInvalidNoOpEncoding  = errors.New("can not use NoOp encoding with more than one backends connected to the same endpoint")
 	defaultPort             = 8080
@@ -403,7 +473,15 @@ func (s *ServiceConfig) Init() error {
 		return err
 	}
 
-	return s.initEndpoints()
+	if err := s.initTenants(); err != nil {
+		return err
+	}
+
+	if err := s.initEndpoints(); err != nil {
+		return err
+	}
+
+	return s.initNamespaces()
 }
 
 func (s *ServiceConfig) Normalize() {
@@ -439,6 +517,9 @@ func (s *ServiceConfig) initGlobalParams() error {
 	if s.Timeout == 0 {
 		s.Timeout = DefaultTimeout
 	}
+	for i := range s.HeadersToPass {
+		s.HeadersToPass[i] = canonicalHeaderParam(s.HeadersToPass[i])
+	}
 
 	var err error
 	s.Host, err = s.uriParser.SafeCleanHosts(s.Host)
@@ -484,10 +565,14 @@ func (s *ServiceConfig) initEndpoints() error {
 		if err := e.validate(); err != nil {
 			return err
 		}
+		if s.isReservedPath(e.Endpoint) {
+			return &EndpointPathError{Path: e.Endpoint, Method: e.Method}
+		}
 
 		for i := range e.HeadersToPass {
-			e.HeadersToPass[i] = textproto.CanonicalMIMEHeaderKey(e.HeadersToPass[i])
+			e.HeadersToPass[i] = canonicalHeaderParam(e.HeadersToPass[i])
 		}
+		e.Hosts = normalizeHosts(e.Hosts)
 
 		inputParams := s.extractPlaceHoldersFromURLTemplate(e.Endpoint, s.paramExtractionPattern())
 		inputSet := map[string]interface{}{}
@@ -497,6 +582,10 @@ func (s *ServiceConfig) initEndpoints() error {
 
 		e.Endpoint = s.uriParser.GetEndpointPath(e.Endpoint, inputParams)
 
+		if err := s.initEndpointAliases(e, inputSet); err != nil {
+			return err
+		}
+
 		s.initEndpointDefaults(i)
 
 		if e.OutputEncoding == encoding.NOOP && len(e.Backend) > 1 {
@@ -504,6 +593,7 @@ func (s *ServiceConfig) initEndpoints() error {
 		}
 
 		e.ExtraConfig.sanitize()
+		addExtractedParams(e.ExtraConfig, inputSet)
 
 		for j, b := range e.Backend {
 			// we "tell" the backend which is his parent endpoint
@@ -523,6 +613,26 @@ func (s *ServiceConfig) initEndpoints() error {
 	return nil
 }
 
+func (s *ServiceConfig) initEndpointAliases(e *EndpointConfig, inputSet map[string]interface{}) error {
+	for i, a := range e.Aliases {
+		path := s.uriParser.CleanPath(a.Path)
+		if matched, _ := regexp.MatchString(invalidPattern, path); matched || path == e.Endpoint || s.isReservedPath(path) {
+			return &EndpointPathError{Path: path, Method: e.Method}
+		}
+		params := s.extractPlaceHoldersFromURLTemplate(path, s.paramExtractionPattern())
+		if len(params) != len(inputSet) {
+			return &AliasParamsError{Alias: path, Path: e.Endpoint, Method: e.Method}
+		}
+		for _, p := range params {
+			if _, ok := inputSet[p]; !ok {
+				return &AliasParamsError{Alias: path, Path: e.Endpoint, Method: e.Method}
+			}
+		}
+		e.Aliases[i].Path = s.uriParser.GetEndpointPath(path, params)
+	}
+	return nil
+}
+
 func (s *ServiceConfig) paramExtractionPattern() *regexp.Regexp {
 	if s.DisableStrictREST {
 		return simpleURLKeysPattern
@@ -550,9 +660,18 @@ func (s *ServiceConfig) initEndpointDefaults(e int) {
 	if s.Timeout != 0 && endpoint.Timeout == 0 {
 		endpoint.Timeout = s.Timeout
 	}
+	if endpoint.ConcurrentCalls == 0 {
+		endpoint.ConcurrentCalls = s.ConcurrentCalls
+	}
 	if endpoint.ConcurrentCalls == 0 {
 		endpoint.ConcurrentCalls = 1
 	}
+	if endpoint.HeadersToPass == nil && s.HeadersToPass != nil {
+		endpoint.HeadersToPass = append([]string{}, s.HeadersToPass...)
+	}
+	if endpoint.QueryString == nil && s.QueryString != nil {
+		endpoint.QueryString = append([]string{}, s.QueryString...)
+	}
 	if endpoint.OutputEncoding == "" {
 		if s.OutputEncoding != "" {
 			endpoint.OutputEncoding = s.OutputEncoding
@@ -598,7 +717,7 @@ func (s *ServiceConfig) initBackendDefaults(e, b int) error {
 	backend.Decoder = encoding.GetRegister().Get(strings.ToLower(backend.Encoding))(backend.IsCollection)
 
 	for i := range backend.HeadersToPass {
-		backend.HeadersToPass[i] = textproto.CanonicalMIMEHeaderKey(backend.HeadersToPass[i])
+		backend.HeadersToPass[i] = canonicalHeaderParam(backend.HeadersToPass[i])
 	}
 	if backend.SDScheme == "" {
 		backend.SDScheme = "http"
@@ -610,6 +729,8 @@ func (s *ServiceConfig) initBackendURLMappings(e, b int, inputParams map[string]
 	backend := s.Endpoints[e].Backend[b]
 
 	backend.URLPattern = s.uriParser.CleanPath(backend.URLPattern)
+	// the markers of the optional segments and the catch-all wildcards do not apply to the backends
+	backend.URLPattern = urlKeysMarkersPattern.ReplaceAllString(backend.URLPattern, "{$1}")
 
 	outputParams, outputSetSize := uniqueOutput(s.extractPlaceHoldersFromURLTemplate(backend.URLPattern, simpleURLKeysPattern))
 
@@ -647,6 +768,31 @@ func (s *ServiceConfig) initBackendURLMappings(e, b int, inputParams map[string]
 	return nil
 }
 
+// addExtractedParams adds the params returned by the InputParamsExtractors to the input params
+// of an endpoint
+func addExtractedParams(e ExtraConfig, inputSet map[string]interface{}) {
+	for _, extract := range InputParamsExtractors {
+		for _, k := range extract(e) {
+			if k != "" {
+				inputSet[k] = nil
+			}
+		}
+	}
+}
+
+// isReservedPath returns true if one of the ReservedPaths functions returns the path for the
+// service extra config
+func (s *ServiceConfig) isReservedPath(path string) bool {
+	for _, reserved := range ReservedPaths {
+		for _, p := range reserved(s.ExtraConfig) {
+			if p == path {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func fromSetToSortedSlice(set map[string]interface{}) []string {
 	res := make([]string, 0, len(set))
 	for element := range set {
@@ -689,7 +835,7 @@ func (e *EndpointConfig) validate() error {
 		return &EndpointPathError{Path: e.Endpoint, Method: e.Method}
 	}
 
-	if len(e.Backend) == 0 {
+	if len(e.Backend) == 0 && !e.IsBackendless() {
 		return &NoBackendsError{Path: e.Endpoint, Method: e.Method}
 	}
 	return nil
@@ -744,6 +890,19 @@ func (e *EndpointPathError) Error() string {
 	return "ignoring the '" + e.Method + " " + e.Path + "' endpoint, since it is invalid!!!"
 }
 
+// AliasParamsError is the error returned by the configuration init process when an alias
+// does not declare the same set of params than its endpoint
+type AliasParamsError struct {
+	Alias  string
+	Path   string
+	Method string
+}
+
+// Error returns a string representation of the AliasParamsError
+func (a *AliasParamsError) Error() string {
+	return "the alias '" + a.Alias + "' of the '" + a.Method + " " + a.Path + "' endpoint must declare the same params"
+}
+
 // UndefinedOutputParamError is the error returned by the configuration init process when an output
 // param is not present in the input param set
 type UndefinedOutputParamError struct {
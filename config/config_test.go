@@ -24,6 +24,9 @@ func TestConfig_rejectInvalidEndpoints(t *testing.T) {
 		"/__debug/",
 		"/__debug/foo",
 		"/__debug/foo/bar",
+		"/foo/{id?}/bar",
+		"/foo/{path...}/bar",
+		"/foo/bar{id?}",
 	}
 
 	for _, e := range samples {
@@ -46,6 +49,9 @@ func TestConfig_initBackendURLMappings_ok(t *testing.T) {
 		"{resp0_x}/{tupu1}/{tupu_56}{supu-5t6}?a={tupu}&b={foo}",
 		"{resp0_x}/{tupu1}/{JWT.foo}",
 		"{resp0_x}/{tupu1}/{JWT.http://example.com/foo_bar}",
+		"{resp0Status}/{tupu1}?location={resp0Header_Location}",
+		"/supu/{tupu1}/{tupu...}",
+		"/supu/{tupu?}",
 	}
 
 	expected := []string{
@@ -58,6 +64,9 @@ func TestConfig_initBackendURLMappings_ok(t *testing.T) {
 		"/{{.Resp0_x}}/{{.Tupu1}}/{{.Tupu_56}}{{.Supu-5t6}}?a={{.Tupu}}&b={{.Foo}}",
 		"/{{.Resp0_x}}/{{.Tupu1}}/{{.JWT.foo}}",
 		"/{{.Resp0_x}}/{{.Tupu1}}/{{.JWT.http://example.com/foo_bar}}",
+		"/{{.Resp0Status}}/{{.Tupu1}}?location={{.Resp0Header_Location}}",
+		"/supu/{{.Tupu1}}/{{.Tupu}}",
+		"/supu/{{.Tupu}}",
 	}
 
 	backend := Backend{}
@@ -211,7 +220,7 @@ func TestConfig_init(t *testing.T) {
 		t.Error(err.Error())
 	}
 
-	if hash != "GdZTJtCn9ZHj3iBR1ZxmZL65HjbTCU8HhbDG8YWudAo=" {
+	if hash != "QUIDxTdrVi69MThlfOldXC0Nq/srB9NjAXKAuAcFg1I=" {
 		t.Errorf("unexpected hash: %s", hash)
 	}
 }
@@ -309,3 +318,118 @@ func TestConfig_initKOInvalidDebugPattern(t *testing.T) {
 
 	invalidPattern = dp
 }
+
+func TestConfig_initServiceDefaults(t *testing.T) {
+	inherited := EndpointConfig{
+		Endpoint: "/inherited",
+		Backend:  []*Backend{{URLPattern: "/"}},
+	}
+	overridden := EndpointConfig{
+		Endpoint:        "/overridden",
+		ConcurrentCalls: 1,
+		HeadersToPass:   []string{},
+		QueryString:     []string{"page"},
+		Backend:         []*Backend{{URLPattern: "/"}},
+	}
+	subject := ServiceConfig{
+		Version:         ConfigVersion,
+		Host:            []string{"http://127.0.0.1:8080"},
+		HeadersToPass:   []string{"x-tenant", "Authorization"},
+		QueryString:     []string{"limit"},
+		ConcurrentCalls: 3,
+		Endpoints:       []*EndpointConfig{&inherited, &overridden},
+	}
+
+	if err := subject.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(inherited.HeadersToPass) != 2 || inherited.HeadersToPass[0] != "X-Tenant" {
+		t.Errorf("unexpected headers to pass: %v", inherited.HeadersToPass)
+	}
+	if len(inherited.QueryString) != 1 || inherited.QueryString[0] != "limit" {
+		t.Errorf("unexpected query strings: %v", inherited.QueryString)
+	}
+	if inherited.ConcurrentCalls != 3 || inherited.Backend[0].ConcurrentCalls != 3 {
+		t.Errorf("unexpected concurrent calls: %d", inherited.ConcurrentCalls)
+	}
+
+	if len(overridden.HeadersToPass) != 0 {
+		t.Errorf("unexpected headers to pass: %v", overridden.HeadersToPass)
+	}
+	if len(overridden.QueryString) != 1 || overridden.QueryString[0] != "page" {
+		t.Errorf("unexpected query strings: %v", overridden.QueryString)
+	}
+	if overridden.ConcurrentCalls != 1 {
+		t.Errorf("unexpected concurrent calls: %d", overridden.ConcurrentCalls)
+	}
+}
+
+func TestConfig_initEndpointAliases(t *testing.T) {
+	e := EndpointConfig{
+		Endpoint: "/users/{id}",
+		Aliases: []EndpointAlias{
+			{Path: "/v1/users/{id}", Deprecated: true, Sunset: "Wed, 11 Nov 2026 23:59:59 GMT"},
+		},
+		Backend: []*Backend{{URLPattern: "/users/{id}"}},
+	}
+	subject := ServiceConfig{
+		Version:   ConfigVersion,
+		Host:      []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{&e},
+	}
+
+	if err := subject.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+	if p := e.Aliases[0].Path; p != "/v1/users/:id" {
+		t.Errorf("unexpected alias path: %s", p)
+	}
+
+	e = EndpointConfig{
+		Endpoint: "/users/{id}",
+		Aliases:  []EndpointAlias{{Path: "/v1/users/{user}"}},
+		Backend:  []*Backend{{URLPattern: "/users/{id}"}},
+	}
+	subject.Endpoints = []*EndpointConfig{&e}
+	err := subject.Init()
+	if _, ok := err.(*AliasParamsError); !ok {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestConfig_initExtractedParams(t *testing.T) {
+	InputParamsExtractors["computed"] = func(e ExtraConfig) []string {
+		v, _ := e["computed"].([]string)
+		return v
+	}
+	defer delete(InputParamsExtractors, "computed")
+
+	backend := &Backend{URLPattern: "/tenants/{tenant}/users/{id}"}
+	subject := ServiceConfig{
+		Version: ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{
+			{
+				Endpoint: "/users/{id}",
+				Method:   "GET",
+				Backend:  []*Backend{backend},
+				ExtraConfig: ExtraConfig{
+					"computed": []string{"tenant"},
+				},
+			},
+		},
+	}
+
+	if errs := Validate(subject); len(errs) != 0 {
+		t.Errorf("unexpected validation errors: %v", errs)
+	}
+	if err := subject.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if backend.URLPattern != "/tenants/{{.Tenant}}/users/{{.Id}}" {
+		t.Errorf("unexpected url pattern: %s", backend.URLPattern)
+	}
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// SecretResolver returns the value referenced by a `${scheme:ref}` expression
+type SecretResolver func(ref string) (string, bool, error)
+
+var (
+	secretResolvers = map[string]SecretResolver{
+		"env":  envResolver,
+		"file": fileResolver,
+	}
+	secretResolversMu = new(sync.RWMutex)
+)
+
+// RegisterSecretResolver adds a resolver for the `${scheme:ref}` expressions, so secret
+// managers can be plugged into the config parser
+func RegisterSecretResolver(scheme string, r SecretResolver) {
+	secretResolversMu.Lock()
+	secretResolvers[scheme] = r
+	secretResolversMu.Unlock()
+}
+
+// UndefinedVariableError is the error returned by the expansion process when a variable is
+// not defined and the expression has no default value
+type UndefinedVariableError struct {
+	Expression string
+}
+
+// Error returns a string representation of the UndefinedVariableError
+func (u *UndefinedVariableError) Error() string {
+	return "undefined variable in the expression '${" + u.Expression + "}'"
+}
+
+// ExpandVariables replaces the `${...}` expressions in the received config content:
+//
+//   - `${VAR}` and `${env:VAR}` are replaced with the value of the environment variable
+//   - `${file:/run/secrets/x}` is replaced with the content of the file, without the trailing newlines
+//   - `${VAR:-default}` uses the default value when the variable is not defined
+//   - `$${` is replaced with a literal `${`
+//
+// The values are escaped, so they can be safely placed inside JSON strings.
+func ExpandVariables(content []byte) ([]byte, error) {
+	if !bytes.Contains(content, []byte("${")) {
+		return content, nil
+	}
+	var out bytes.Buffer
+	out.Grow(len(content))
+	for {
+		i := bytes.Index(content, []byte("${"))
+		if i < 0 {
+			out.Write(content)
+			return out.Bytes(), nil
+		}
+		if i > 0 && content[i-1] == '$' {
+			out.Write(content[:i-1])
+			out.WriteString("${")
+			content = content[i+2:]
+			continue
+		}
+		end := bytes.IndexByte(content[i:], '}')
+		if end < 0 {
+			out.Write(content)
+			return out.Bytes(), nil
+		}
+		out.Write(content[:i])
+		expr := string(content[i+2 : i+end])
+		v, err := resolveExpression(expr)
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString(escapeJSONString(v))
+		content = content[i+end+1:]
+	}
+}
+
+func resolveExpression(expr string) (string, error) {
+	ref, def, hasDefault := expr, "", false
+	if i := strings.Index(expr, ":-"); i >= 0 {
+		ref, def, hasDefault = expr[:i], expr[i+2:], true
+	}
+
+	scheme := "env"
+	if i := strings.Index(ref, ":"); i >= 0 {
+		scheme, ref = ref[:i], ref[i+1:]
+	}
+
+	secretResolversMu.RLock()
+	r, ok := secretResolvers[scheme]
+	secretResolversMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown variable scheme '%s' in the expression '${%s}'", scheme, expr)
+	}
+
+	v, found, err := r(ref)
+	if err != nil {
+		return "", fmt.Errorf("resolving the expression '${%s}': %w", expr, err)
+	}
+	if found {
+		return v, nil
+	}
+	if hasDefault {
+		return def, nil
+	}
+	return "", &UndefinedVariableError{Expression: expr}
+}
+
+func envResolver(ref string) (string, bool, error) {
+	v, ok := os.LookupEnv(ref)
+	return v, ok, nil
+}
+
+func fileResolver(ref string) (string, bool, error) {
+	b, err := os.ReadFile(ref)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return strings.TrimRight(string(b), "\r\n"), true, nil
+}
+
+func escapeJSONString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandVariables(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("s3cr3t\"\n"), 0o600); err != nil {
+		t.Error(err)
+		return
+	}
+	t.Setenv("LURA_TEST_HOST", "http://10.0.0.1:8080")
+	RegisterSecretResolver("vault", func(ref string) (string, bool, error) {
+		if ref == "broken" {
+			return "", false, errors.New("vault unavailable")
+		}
+		return "from-" + ref, true, nil
+	})
+
+	for _, tc := range []struct {
+		in  string
+		out string
+	}{
+		{in: `{"host": "${LURA_TEST_HOST}"}`, out: `{"host": "http://10.0.0.1:8080"}`},
+		{in: `{"host": "${env:LURA_TEST_HOST}"}`, out: `{"host": "http://10.0.0.1:8080"}`},
+		{in: `{"port": ${LURA_TEST_UNDEFINED:-8080}}`, out: `{"port": 8080}`},
+		{in: `{"password": "${file:` + secret + `}"}`, out: `{"password": "s3cr3t\""}`},
+		{in: `{"password": "${file:/nowhere:-none}"}`, out: `{"password": "none"}`},
+		{in: `{"token": "${vault:api/token}"}`, out: `{"token": "from-api/token"}`},
+		{in: `{"template": "$${LURA_TEST_HOST}"}`, out: `{"template": "${LURA_TEST_HOST}"}`},
+		{in: `{"plain": "value"}`, out: `{"plain": "value"}`},
+	} {
+		out, err := ExpandVariables([]byte(tc.in))
+		if err != nil {
+			t.Errorf("%s: %s", tc.in, err.Error())
+			continue
+		}
+		if string(out) != tc.out {
+			t.Errorf("unexpected result. have: %s, want: %s", string(out), tc.out)
+		}
+	}
+
+	for _, in := range []string{
+		`{"host": "${LURA_TEST_UNDEFINED}"}`,
+		`{"host": "${unknown:ref}"}`,
+		`{"host": "${vault:broken}"}`,
+	} {
+		if _, err := ExpandVariables([]byte(in)); err == nil {
+			t.Errorf("%s: error expected", in)
+		}
+	}
+}
+
+func TestNewParser_expandVariables(t *testing.T) {
+	t.Setenv("LURA_TEST_PORT", "9090")
+	configPath := filepath.Join(t.TempDir(), "expand.json")
+	configContent := []byte(`{
+    "version": 3,
+    "port": ${LURA_TEST_PORT},
+    "name": "${LURA_TEST_NAME:-default name}",
+    "endpoints": []
+}`)
+	if err := os.WriteFile(configPath, configContent, 0o644); err != nil {
+		t.FailNow()
+	}
+
+	cfg, err := NewParser().Parse(configPath)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if cfg.Port != 9090 || cfg.Name != "default name" {
+		t.Errorf("unexpected config: %d %s", cfg.Port, cfg.Name)
+	}
+}
@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package importer generates draft endpoint and backend definitions from the API descriptions
+already available, so the services only documented with them can be exposed without writing
+their config from scratch:
+
+	b, _ := os.ReadFile("petstore.json")
+	draft, err := importer.Import(b)
+	out, _ := json.MarshalIndent(draft, "", "  ")
+
+The package registers the importers of Swagger 2.0 documents and Postman collections (v2.0 and
+v2.1). The format of a document is detected from its content, and other importers can be added
+with RegisterImporter. The drafts are meant to be reviewed: every operation becomes an endpoint
+exposing the same path and proxying to a single backend, with the query strings and the headers
+declared by the operation.
+*/
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/register"
+)
+
+const (
+	// Swagger2 is the name of the Swagger 2.0 importer
+	Swagger2 = "swagger2"
+	// Postman is the name of the Postman collections importer
+	Postman = "postman"
+)
+
+// ErrUnknownFormat is returned when the format of the document is not supported
+var ErrUnknownFormat = errors.New("importer: unknown document format")
+
+// Draft is the content of a config file with the generated endpoints
+type Draft struct {
+	Version   int        `json:"version"`
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// Endpoint is a draft endpoint definition
+type Endpoint struct {
+	Endpoint     string    `json:"endpoint"`
+	Method       string    `json:"method"`
+	QueryStrings []string  `json:"input_query_strings,omitempty"`
+	Headers      []string  `json:"input_headers,omitempty"`
+	Backend      []Backend `json:"backend"`
+}
+
+// Backend is a draft backend definition
+type Backend struct {
+	Host       []string `json:"host,omitempty"`
+	URLPattern string   `json:"url_pattern"`
+	Method     string   `json:"method"`
+	Encoding   string   `json:"encoding,omitempty"`
+}
+
+// Importer generates the draft endpoints of a document
+type Importer interface {
+	// Accepts returns true if the decoded document has the format of the importer
+	Accepts(doc map[string]interface{}) bool
+	// Import returns the endpoints described by the document
+	Import(b []byte) ([]Endpoint, error)
+}
+
+var importers = register.NewUntyped()
+
+func init() {
+	RegisterImporter(Swagger2, swaggerImporter{})
+	RegisterImporter(Postman, postmanImporter{})
+}
+
+// RegisterImporter adds an importer to the package register
+func RegisterImporter(name string, i Importer) {
+	importers.Register(name, i)
+}
+
+// GetImporter returns the importer registered with the name
+func GetImporter(name string) (Importer, bool) {
+	v, ok := importers.Get(name)
+	if !ok {
+		return nil, false
+	}
+	i, ok := v.(Importer)
+	return i, ok
+}
+
+// Detect returns the name of the importer accepting the document
+func Detect(b []byte) (string, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return "", fmt.Errorf("importer: decoding the document: %w", err)
+	}
+	names := importers.Clone()
+	keys := make([]string, 0, len(names))
+	for k := range names {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if i, ok := names[k].(Importer); ok && i.Accepts(doc) {
+			return k, nil
+		}
+	}
+	return "", ErrUnknownFormat
+}
+
+// Import detects the format of the document and returns the draft config of its endpoints
+func Import(b []byte) (Draft, error) {
+	name, err := Detect(b)
+	if err != nil {
+		return Draft{}, err
+	}
+	return ImportAs(name, b)
+}
+
+// ImportAs returns the draft config of the endpoints of a document, using the named importer
+func ImportAs(name string, b []byte) (Draft, error) {
+	i, ok := GetImporter(name)
+	if !ok {
+		return Draft{}, fmt.Errorf("%w: %s", ErrUnknownFormat, name)
+	}
+	es, err := i.Import(b)
+	if err != nil {
+		return Draft{}, err
+	}
+	return Draft{Version: config.ConfigVersion, Endpoints: normalize(es)}, nil
+}
+
+// normalize merges the endpoints with the same path and method and sorts them, so the drafts
+// are stable
+func normalize(es []Endpoint) []Endpoint {
+	index := map[string]int{}
+	res := make([]Endpoint, 0, len(es))
+	for _, e := range es {
+		e.Method = strings.ToUpper(e.Method)
+		key := e.Method + " " + e.Endpoint
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(res)
+			res = append(res, e)
+			continue
+		}
+		res[i].QueryStrings = append(res[i].QueryStrings, e.QueryStrings...)
+		res[i].Headers = append(res[i].Headers, e.Headers...)
+	}
+	for i := range res {
+		res[i].QueryStrings = uniqueSorted(res[i].QueryStrings)
+		res[i].Headers = uniqueSorted(res[i].Headers)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Endpoint != res[j].Endpoint {
+			return res[i].Endpoint < res[j].Endpoint
+		}
+		return res[i].Method < res[j].Method
+	})
+	return res
+}
+
+func uniqueSorted(vs []string) []string {
+	if len(vs) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(vs))
+	res := make([]string, 0, len(vs))
+	for _, v := range vs {
+		if _, ok := seen[v]; ok || v == "" {
+			continue
+		}
+		seen[v] = struct{}{}
+		res = append(res, v)
+	}
+	sort.Strings(res)
+	return res
+}
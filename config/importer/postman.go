@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type postmanCollection struct {
+	Info     postmanInfo       `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Variable []postmanVariable `json:"variable"`
+}
+
+type postmanInfo struct {
+	Schema string `json:"schema"`
+}
+
+type postmanItem struct {
+	Item    []postmanItem   `json:"item"`
+	Request *postmanRequest `json:"request"`
+}
+
+type postmanRequest struct {
+	Method string          `json:"method"`
+	URL    json.RawMessage `json:"url"`
+	Header []postmanHeader `json:"header"`
+}
+
+type postmanHeader struct {
+	Key      string `json:"key"`
+	Disabled bool   `json:"disabled"`
+}
+
+type postmanURL struct {
+	Raw      string            `json:"raw"`
+	Protocol string            `json:"protocol"`
+	Host     json.RawMessage   `json:"host"`
+	Path     json.RawMessage   `json:"path"`
+	Query    []postmanVariable `json:"query"`
+}
+
+type postmanVariable struct {
+	Key      string      `json:"key"`
+	Value    interface{} `json:"value"`
+	Disabled bool        `json:"disabled"`
+}
+
+var postmanVariablePattern = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+type postmanImporter struct{}
+
+// Accepts implements the Importer interface
+func (postmanImporter) Accepts(doc map[string]interface{}) bool {
+	info, _ := doc["info"].(map[string]interface{})
+	schema, _ := info["schema"].(string)
+	return strings.Contains(schema, "schema.getpostman.com") || strings.Contains(schema, "schema.postman.com")
+}
+
+// Import implements the Importer interface. The requests in the folders are flattened, the
+// collection variables are replaced in the hosts and the path variables (:id or {{id}}) become
+// endpoint params
+func (postmanImporter) Import(b []byte) ([]Endpoint, error) {
+	var c postmanCollection
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("importer: decoding the postman collection: %w", err)
+	}
+	vars := map[string]string{}
+	for _, v := range c.Variable {
+		if s, ok := v.Value.(string); ok && !v.Disabled {
+			vars[v.Key] = s
+		}
+	}
+
+	var res []Endpoint
+	var walk func(items []postmanItem) error
+	walk = func(items []postmanItem) error {
+		for _, it := range items {
+			if err := walk(it.Item); err != nil {
+				return err
+			}
+			if it.Request == nil {
+				continue
+			}
+			e, err := postmanEndpoint(it.Request, vars)
+			if err != nil {
+				return err
+			}
+			res = append(res, e)
+		}
+		return nil
+	}
+	if err := walk(c.Item); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func postmanEndpoint(r *postmanRequest, vars map[string]string) (Endpoint, error) {
+	u, err := parsePostmanURL(r.URL)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	method := strings.ToUpper(r.Method)
+	if method == "" {
+		method = "GET"
+	}
+
+	host := resolvePostmanVariables(strings.Join(jsonStrings(u.Host, "."), "."), vars)
+	var path string
+	if segments := jsonStrings(u.Path, "/"); len(segments) > 0 {
+		for i, s := range segments {
+			segments[i] = postmanParam(s)
+		}
+		path = "/" + strings.Join(segments, "/")
+	}
+	if host == "" && path == "" && u.Raw != "" {
+		host, path = splitRawPostmanURL(resolvePostmanVariables(u.Raw, vars))
+	}
+	if path == "" {
+		path = "/"
+	}
+	if host != "" && !strings.Contains(host, "://") {
+		protocol := u.Protocol
+		if protocol == "" {
+			protocol = "http"
+		}
+		host = protocol + "://" + host
+	}
+
+	e := Endpoint{
+		Endpoint: path,
+		Method:   method,
+		Backend:  []Backend{{URLPattern: path, Method: method}},
+	}
+	if host != "" {
+		e.Backend[0].Host = []string{host}
+	}
+	for _, q := range u.Query {
+		if !q.Disabled {
+			e.QueryStrings = append(e.QueryStrings, q.Key)
+		}
+	}
+	for _, h := range r.Header {
+		if !h.Disabled {
+			e.Headers = append(e.Headers, h.Key)
+		}
+	}
+	return e, nil
+}
+
+// parsePostmanURL decodes the url of a request, declared as a string or as an object
+func parsePostmanURL(raw json.RawMessage) (postmanURL, error) {
+	var u postmanURL
+	if len(raw) == 0 {
+		return u, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		u.Raw = s
+		if i := strings.IndexByte(s, '?'); i >= 0 {
+			if q, err := url.ParseQuery(s[i+1:]); err == nil {
+				for k := range q {
+					u.Query = append(u.Query, postmanVariable{Key: k})
+				}
+			}
+		}
+		return u, nil
+	}
+	if err := json.Unmarshal(raw, &u); err != nil {
+		return u, fmt.Errorf("importer: decoding the url of a request: %w", err)
+	}
+	return u, nil
+}
+
+// splitRawPostmanURL returns the host and the path of a raw url
+func splitRawPostmanURL(raw string) (string, string) {
+	if i := strings.IndexAny(raw, "?#"); i >= 0 {
+		raw = raw[:i]
+	}
+	rest := raw
+	scheme := ""
+	if i := strings.Index(rest, "://"); i >= 0 {
+		scheme, rest = rest[:i+3], rest[i+3:]
+	}
+	host, path := rest, ""
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		host, path = rest[:i], rest[i:]
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, s := range segments {
+		segments[i] = postmanParam(s)
+	}
+	path = "/" + strings.Join(segments, "/")
+	if host == "" {
+		return "", path
+	}
+	return scheme + host, path
+}
+
+// jsonStrings decodes a value declared as a string or as a list of strings
+func jsonStrings(raw json.RawMessage, sep string) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return list
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil && s != "" {
+		return strings.Split(strings.Trim(s, sep), sep)
+	}
+	return nil
+}
+
+// postmanParam translates the path variables of a segment to the endpoint params syntax
+func postmanParam(segment string) string {
+	if strings.HasPrefix(segment, ":") && len(segment) > 1 {
+		return "{" + segment[1:] + "}"
+	}
+	return postmanVariablePattern.ReplaceAllString(segment, "{$1}")
+}
+
+func resolvePostmanVariables(s string, vars map[string]string) string {
+	return postmanVariablePattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := postmanVariablePattern.FindStringSubmatch(m)[1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return m
+	})
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package importer
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const postmanCollectionDocument = `{
+	"info": {
+		"name": "Orders",
+		"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+	},
+	"variable": [{"key": "baseUrl", "value": "https://orders.example.com"}],
+	"item": [
+		{
+			"name": "orders",
+			"item": [
+				{
+					"name": "List orders",
+					"request": {
+						"method": "GET",
+						"header": [
+							{"key": "X-Tenant", "value": "acme"},
+							{"key": "X-Debug", "value": "1", "disabled": true}
+						],
+						"url": {
+							"raw": "{{baseUrl}}/orders?status=open",
+							"host": ["{{baseUrl}}"],
+							"path": ["orders"],
+							"query": [{"key": "status", "value": "open"}]
+						}
+					}
+				},
+				{
+					"name": "List orders paginated",
+					"request": {
+						"method": "GET",
+						"url": {
+							"raw": "{{baseUrl}}/orders?page=2",
+							"host": ["{{baseUrl}}"],
+							"path": ["orders"],
+							"query": [{"key": "page", "value": "2"}]
+						}
+					}
+				},
+				{
+					"name": "Get order",
+					"request": {
+						"method": "GET",
+						"url": {
+							"raw": "{{baseUrl}}/orders/:orderId",
+							"host": ["{{baseUrl}}"],
+							"path": ["orders", ":orderId"]
+						}
+					}
+				}
+			]
+		},
+		{
+			"name": "Cancel order",
+			"request": {
+				"method": "DELETE",
+				"url": "http://legacy.example.com:8080/orders/{{orderId}}"
+			}
+		}
+	]
+}`
+
+func TestImport_postman(t *testing.T) {
+	name, err := Detect([]byte(postmanCollectionDocument))
+	if err != nil || name != Postman {
+		t.Fatalf("unexpected format %s: %v", name, err)
+	}
+	draft, err := Import([]byte(postmanCollectionDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := json.Marshal(draft)
+	expected := `{"version":3,"endpoints":[` +
+		`{"endpoint":"/orders","method":"GET","input_query_strings":["page","status"],"input_headers":["X-Tenant"],"backend":[{"host":["https://orders.example.com"],"url_pattern":"/orders","method":"GET"}]},` +
+		`{"endpoint":"/orders/{orderId}","method":"DELETE","backend":[{"host":["http://legacy.example.com:8080"],"url_pattern":"/orders/{orderId}","method":"DELETE"}]},` +
+		`{"endpoint":"/orders/{orderId}","method":"GET","backend":[{"host":["https://orders.example.com"],"url_pattern":"/orders/{orderId}","method":"GET"}]}]}`
+	if string(b) != expected {
+		t.Errorf("unexpected draft:\n%s", string(b))
+	}
+}
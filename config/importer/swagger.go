@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+var swaggerMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+type swaggerDocument struct {
+	Swagger  string                                `json:"swagger"`
+	Host     string                                `json:"host"`
+	BasePath string                                `json:"basePath"`
+	Schemes  []string                              `json:"schemes"`
+	Produces []string                              `json:"produces"`
+	Paths    map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+type swaggerOperation struct {
+	Produces   []string           `json:"produces"`
+	Parameters []swaggerParameter `json:"parameters"`
+}
+
+type swaggerParameter struct {
+	Name string `json:"name"`
+	In   string `json:"in"`
+}
+
+type swaggerImporter struct{}
+
+// Accepts implements the Importer interface
+func (swaggerImporter) Accepts(doc map[string]interface{}) bool {
+	v, _ := doc["swagger"].(string)
+	return strings.HasPrefix(v, "2.")
+}
+
+// Import implements the Importer interface. The backends point to the host and the base path
+// of the document and the path params keep their names
+func (swaggerImporter) Import(b []byte) ([]Endpoint, error) {
+	var doc swaggerDocument
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("importer: decoding the swagger document: %w", err)
+	}
+
+	var hosts []string
+	if doc.Host != "" {
+		scheme := "http"
+		for _, s := range doc.Schemes {
+			if s == "https" {
+				scheme = s
+				break
+			}
+		}
+		hosts = []string{scheme + "://" + doc.Host}
+	}
+	basePath := strings.TrimSuffix(doc.BasePath, "/")
+
+	var res []Endpoint
+	for path, item := range doc.Paths {
+		var shared []swaggerParameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, fmt.Errorf("importer: decoding the parameters of %s: %w", path, err)
+			}
+		}
+		for _, method := range swaggerMethods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op swaggerOperation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("importer: decoding the operation %s %s: %w", method, path, err)
+			}
+			produces := op.Produces
+			if produces == nil {
+				produces = doc.Produces
+			}
+			e := Endpoint{
+				Endpoint: path,
+				Method:   method,
+				Backend: []Backend{{
+					Host:       hosts,
+					URLPattern: basePath + path,
+					Method:     strings.ToUpper(method),
+					Encoding:   encodingFor(produces),
+				}},
+			}
+			for _, p := range append(shared, op.Parameters...) {
+				switch p.In {
+				case "query":
+					e.QueryStrings = append(e.QueryStrings, p.Name)
+				case "header":
+					e.Headers = append(e.Headers, p.Name)
+				}
+			}
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+// encodingFor returns the backend encoding matching the media types produced by an operation.
+// The JSON operations use the default encoding
+func encodingFor(mediaTypes []string) string {
+	if len(mediaTypes) == 0 {
+		return ""
+	}
+	for _, m := range mediaTypes {
+		if strings.Contains(m, "json") {
+			return ""
+		}
+	}
+	for _, m := range mediaTypes {
+		if strings.Contains(m, "xml") {
+			return "xml"
+		}
+	}
+	return "no-op"
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package importer
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const swaggerPetstore = `{
+	"swagger": "2.0",
+	"host": "petstore.example.com",
+	"basePath": "/v1/",
+	"schemes": ["http", "https"],
+	"produces": ["application/json"],
+	"paths": {
+		"/pets": {
+			"get": {
+				"parameters": [
+					{"name": "limit", "in": "query", "type": "integer"},
+					{"name": "X-Request-Id", "in": "header", "type": "string"}
+				]
+			},
+			"post": {
+				"parameters": [{"name": "body", "in": "body", "schema": {}}]
+			}
+		},
+		"/pets/{petId}": {
+			"parameters": [{"name": "petId", "in": "path", "required": true, "type": "string"}],
+			"get": {
+				"produces": ["application/xml"],
+				"parameters": [{"name": "fields", "in": "query", "type": "string"}]
+			}
+		}
+	}
+}`
+
+func TestImport_swagger(t *testing.T) {
+	name, err := Detect([]byte(swaggerPetstore))
+	if err != nil || name != Swagger2 {
+		t.Fatalf("unexpected format %s: %v", name, err)
+	}
+	draft, err := Import([]byte(swaggerPetstore))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := json.Marshal(draft)
+	expected := `{"version":3,"endpoints":[` +
+		`{"endpoint":"/pets","method":"GET","input_query_strings":["limit"],"input_headers":["X-Request-Id"],"backend":[{"host":["https://petstore.example.com"],"url_pattern":"/v1/pets","method":"GET"}]},` +
+		`{"endpoint":"/pets","method":"POST","backend":[{"host":["https://petstore.example.com"],"url_pattern":"/v1/pets","method":"POST"}]},` +
+		`{"endpoint":"/pets/{petId}","method":"GET","input_query_strings":["fields"],"backend":[{"host":["https://petstore.example.com"],"url_pattern":"/v1/pets/{petId}","method":"GET","encoding":"xml"}]}]}`
+	if string(b) != expected {
+		t.Errorf("unexpected draft:\n%s", string(b))
+	}
+}
+
+func TestImport_unknown(t *testing.T) {
+	if _, err := Import([]byte(`{"openapi": "3.0.0"}`)); err != ErrUnknownFormat {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := Import([]byte(`not json`)); err == nil {
+		t.Error("expecting an error")
+	}
+	if _, err := ImportAs("raml", []byte(`{}`)); err == nil {
+		t.Error("expecting an error")
+	}
+}
@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IncludeKey is the key of the objects to be replaced by the content of other config files
+const IncludeKey = "$ref"
+
+// IncludeCycleError is the error returned by the include resolution when a file includes
+// itself, directly or through other files
+type IncludeCycleError struct {
+	Chain []string
+}
+
+// Error returns a string representation of the IncludeCycleError
+func (i *IncludeCycleError) Error() string {
+	return "include cycle detected: " + strings.Join(i.Chain, " -> ")
+}
+
+// IncludeNotFoundError is the error returned by the include resolution when a pattern does
+// not match any file
+type IncludeNotFoundError struct {
+	Pattern string
+	File    string
+}
+
+// Error returns a string representation of the IncludeNotFoundError
+func (i *IncludeNotFoundError) Error() string {
+	return "the include '" + i.Pattern + "' in '" + i.File + "' does not match any file"
+}
+
+// ResolveIncludes replaces the `{"$ref": "pattern"}` objects found in the content of the config file
+// with the content of the files matching the pattern. Relative patterns are resolved from the
+// directory of the file declaring them and the matches are processed in lexicographical order.
+//
+// The merging rules are:
+//
+//   - inside an array, the included arrays are spliced and the included objects are appended
+//   - elsewhere, the included objects are merged (later files override earlier ones) and the
+//     sibling keys of the `$ref` override the included content
+//
+// The included files get their variables expanded and can include other files as long as they
+// do not generate a cycle.
+func ResolveIncludes(content []byte, file string, reader FileReaderFunc) ([]byte, error) {
+	if !bytes.Contains(content, []byte(`"`+IncludeKey+`"`)) {
+		return content, nil
+	}
+	r := includeResolver{reader: reader}
+	v, err := r.resolveFile(content, file, nil)
+	if err != nil {
+		return content, err
+	}
+	return json.Marshal(v)
+}
+
+type includeResolver struct {
+	reader FileReaderFunc
+}
+
+func (r includeResolver) resolveFile(content []byte, file string, chain []string) (interface{}, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		abs = file
+	}
+	for _, f := range chain {
+		if f == abs {
+			return nil, &IncludeCycleError{Chain: append(chain, abs)}
+		}
+	}
+	chain = append(chain, abs)
+
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(content))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		if len(chain) > 1 {
+			return nil, fmt.Errorf("'%s': %w", file, err)
+		}
+		return nil, err
+	}
+	return r.resolve(v, file, chain)
+}
+
+func (r includeResolver) resolve(v interface{}, file string, chain []string) (interface{}, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		pattern, ok := t[IncludeKey].(string)
+		if !ok {
+			for k, e := range t {
+				res, err := r.resolve(e, file, chain)
+				if err != nil {
+					return nil, err
+				}
+				t[k] = res
+			}
+			return t, nil
+		}
+
+		included, err := r.include(pattern, file, chain)
+		if err != nil {
+			return nil, err
+		}
+		delete(t, IncludeKey)
+		if len(included) == 1 && len(t) == 0 {
+			return included[0], nil
+		}
+
+		res := map[string]interface{}{}
+		for _, i := range included {
+			m, ok := i.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("the include '%s' in '%s' can not be merged: it is not an object", pattern, file)
+			}
+			mergeIncluded(res, m)
+		}
+		for k, e := range t {
+			resolved, err := r.resolve(e, file, chain)
+			if err != nil {
+				return nil, err
+			}
+			t[k] = resolved
+		}
+		mergeIncluded(res, t)
+		return res, nil
+
+	case []interface{}:
+		res := make([]interface{}, 0, len(t))
+		for _, e := range t {
+			m, ok := e.(map[string]interface{})
+			if pattern, isRef := m[IncludeKey].(string); ok && isRef && len(m) == 1 {
+				included, err := r.include(pattern, file, chain)
+				if err != nil {
+					return nil, err
+				}
+				for _, i := range included {
+					if arr, ok := i.([]interface{}); ok {
+						res = append(res, arr...)
+						continue
+					}
+					res = append(res, i)
+				}
+				continue
+			}
+			resolved, err := r.resolve(e, file, chain)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, resolved)
+		}
+		return res, nil
+	}
+	return v, nil
+}
+
+func (r includeResolver) include(pattern, file string, chain []string) ([]interface{}, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(file), pattern)
+	}
+
+	files := []string{pattern}
+	if strings.ContainsAny(pattern, "*?[") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, &IncludeNotFoundError{Pattern: pattern, File: file}
+		}
+		sort.Strings(matches)
+		files = matches
+	}
+
+	res := make([]interface{}, 0, len(files))
+	for _, f := range files {
+		content, err := r.reader(f)
+		if err != nil {
+			return nil, err
+		}
+		if content, err = ExpandVariables(content); err != nil {
+			return nil, fmt.Errorf("'%s': %w", f, err)
+		}
+		v, err := r.resolveFile(content, f, chain)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+// mergeIncluded copies the src entries into dst, merging the nested objects recursively
+func mergeIncluded(dst, src map[string]interface{}) {
+	for k, v := range src {
+		sm, ok := v.(map[string]interface{})
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		dm, ok := dst[k].(map[string]interface{})
+		if !ok {
+			dst[k] = sm
+			continue
+		}
+		mergeIncluded(dm, sm)
+	}
+}
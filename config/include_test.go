@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewParser_includes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"krakend.json": `{
+			"version": 3,
+			"host": ["http://127.0.0.1:8080"],
+			"extra_config": {"$ref": "extra/*.json", "github_com/a": {"b": 2}},
+			"endpoints": [
+				{"endpoint": "/inline", "backend": [{"url_pattern": "/"}]},
+				{"$ref": "endpoints/*.json"}
+			]
+		}`,
+		"extra/a.json":         `{"github_com/a": {"a": 1, "b": 1}}`,
+		"endpoints/a.json":     `[{"endpoint": "/a", "backend": [{"$ref": "../backends/common.json"}]}]`,
+		"endpoints/b.json":     `{"endpoint": "/b", "backend": [{"url_pattern": "/"}]}`,
+		"backends/common.json": `{"url_pattern": "/common"}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	cfg, err := NewParser().Parse(filepath.Join(dir, "krakend.json"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(cfg.Endpoints) != 3 {
+		t.Errorf("unexpected number of endpoints: %d", len(cfg.Endpoints))
+		return
+	}
+	for i, path := range []string{"/inline", "/a", "/b"} {
+		if cfg.Endpoints[i].Endpoint != path {
+			t.Errorf("unexpected endpoint #%d: %s", i, cfg.Endpoints[i].Endpoint)
+		}
+	}
+	if p := cfg.Endpoints[1].Backend[0].URLPattern; p != "/common" {
+		t.Errorf("unexpected url pattern: %s", p)
+	}
+	extra, ok := cfg.ExtraConfig["github_com/a"].(map[string]interface{})
+	if !ok {
+		t.Errorf("unexpected extra config: %v", cfg.ExtraConfig)
+		return
+	}
+	if extra["a"] != 1.0 || extra["b"] != 2.0 {
+		t.Errorf("unexpected merged extra config: %v", extra)
+	}
+}
+
+func TestResolveIncludes_cycle(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"x": {"$ref": "b.json"}}`), 0o600)
+	os.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"y": {"$ref": "a.json"}}`), 0o600)
+
+	main := filepath.Join(dir, "a.json")
+	content, _ := os.ReadFile(main)
+	_, err := ResolveIncludes(content, main, os.ReadFile)
+	var cycle *IncludeCycleError
+	if !errors.As(err, &cycle) {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if len(cycle.Chain) != 3 {
+		t.Errorf("unexpected chain: %v", cycle.Chain)
+	}
+}
+
+func TestResolveIncludes_notFound(t *testing.T) {
+	_, err := ResolveIncludes([]byte(`[{"$ref": "unknown/*.json"}]`), filepath.Join(t.TempDir(), "main.json"), os.ReadFile)
+	if _, ok := err.(*IncludeNotFoundError); !ok {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestResolveIncludes_noRefs(t *testing.T) {
+	content := []byte(`{"version": 3}`)
+	res, err := ResolveIncludes(content, "main.json", nil)
+	if err != nil || string(res) != string(content) {
+		t.Errorf("unexpected result: %s %v", res, err)
+	}
+}
@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// NamespaceStage is the step of the deprecation process a renamed namespace is in
+type NamespaceStage int
+
+const (
+	// NamespaceAliased namespaces are silently moved to their replacement
+	NamespaceAliased NamespaceStage = iota
+	// NamespaceDeprecated namespaces are moved to their replacement with a warning, or rejected
+	// in strict mode
+	NamespaceDeprecated
+	// NamespaceRemoved namespaces are always rejected
+	NamespaceRemoved
+)
+
+// String returns the name of the stage
+func (n NamespaceStage) String() string {
+	switch n {
+	case NamespaceAliased:
+		return "aliased"
+	case NamespaceDeprecated:
+		return "deprecated"
+	case NamespaceRemoved:
+		return "removed"
+	}
+	return fmt.Sprintf("stage(%d)", int(n))
+}
+
+// NamespaceRename describes an extra config namespace replaced by a new one
+type NamespaceRename struct {
+	Namespace   string
+	Replacement string
+	Stage       NamespaceStage
+}
+
+var (
+	namespaceRenames   = map[string]NamespaceRename{}
+	namespaceRenamesMu sync.RWMutex
+)
+
+// RenameNamespace registers the replacement of an extra config namespace, so the components can
+// be renamed without breaking the existing configs at once. A rename usually goes through the
+// aliased, deprecated and removed stages in successive releases. Registering the same namespace
+// again moves it to the new stage
+func RenameNamespace(namespace, replacement string, stage NamespaceStage) {
+	namespaceRenamesMu.Lock()
+	namespaceRenames[namespace] = NamespaceRename{Namespace: namespace, Replacement: replacement, Stage: stage}
+	namespaceRenamesMu.Unlock()
+}
+
+// NamespaceRenames returns the registered namespace renames, sorted by namespace
+func NamespaceRenames() []NamespaceRename {
+	namespaceRenamesMu.RLock()
+	res := make([]NamespaceRename, 0, len(namespaceRenames))
+	for _, r := range namespaceRenames {
+		res = append(res, r)
+	}
+	namespaceRenamesMu.RUnlock()
+	sort.Slice(res, func(i, j int) bool { return res[i].Namespace < res[j].Namespace })
+	return res
+}
+
+func namespaceRename(namespace string) (NamespaceRename, bool) {
+	namespaceRenamesMu.RLock()
+	r, ok := namespaceRenames[namespace]
+	namespaceRenamesMu.RUnlock()
+	if ok {
+		return r, true
+	}
+	if alias, ok := ExtraConfigAlias[namespace]; ok {
+		return NamespaceRename{Namespace: namespace, Replacement: alias, Stage: NamespaceAliased}, true
+	}
+	return r, false
+}
+
+// DeprecatedNamespaceError reports the use of a renamed namespace. It is a warning for the
+// deprecated namespaces and an error for the removed ones and for all of them in strict mode
+type DeprecatedNamespaceError struct {
+	NamespaceRename
+	// Scope is the part of the config declaring the namespace
+	Scope string
+	// Conflict is true if the replacement is also declared, so the namespace has been ignored
+	Conflict bool
+}
+
+// Error returns a string representation of the DeprecatedNamespaceError
+func (d *DeprecatedNamespaceError) Error() string {
+	msg := fmt.Sprintf("the namespace %s used in the %s is %s, use %s instead", d.Namespace, d.Scope, d.Stage, d.Replacement)
+	if d.Conflict {
+		msg += " (ignored, the new namespace is also declared)"
+	}
+	return msg
+}
+
+// normalizeNamespaces moves the extra configs declared with renamed namespaces to their
+// replacements. It returns the warnings for the deprecated namespaces, and an error for the
+// removed ones or, in strict mode, the deprecated ones
+func (e ExtraConfig) normalizeNamespaces(scope string, strict bool) ([]error, error) {
+	var warnings []error
+	for _, namespace := range e.renamedNamespaces() {
+		r, _ := namespaceRename(namespace)
+		_, conflict := e[r.Replacement]
+		d := &DeprecatedNamespaceError{NamespaceRename: r, Scope: scope, Conflict: conflict}
+		if r.Stage == NamespaceRemoved || (strict && r.Stage == NamespaceDeprecated) {
+			return warnings, d
+		}
+		if !conflict {
+			e[r.Replacement] = e[namespace]
+		}
+		delete(e, namespace)
+		if r.Stage == NamespaceDeprecated || conflict {
+			warnings = append(warnings, d)
+		}
+	}
+	return warnings, nil
+}
+
+// renamedNamespaces returns the renamed namespaces declared, sorted so the results do not depend
+// on the iteration order of the map
+func (e ExtraConfig) renamedNamespaces() []string {
+	var res []string
+	for namespace := range e {
+		if _, ok := namespaceRename(namespace); ok {
+			res = append(res, namespace)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// NamespaceWarnings returns the uses of deprecated namespaces found by Init
+func (s *ServiceConfig) NamespaceWarnings() []error {
+	return s.namespaceWarnings
+}
+
+func (s *ServiceConfig) initNamespaces() error {
+	s.namespaceWarnings = nil
+	normalize := func(e ExtraConfig, scope string) error {
+		warnings, err := e.normalizeNamespaces(scope, s.StrictNamespaces)
+		s.namespaceWarnings = append(s.namespaceWarnings, warnings...)
+		return err
+	}
+	return s.walkExtraConfigs(normalize)
+}
+
+// walkExtraConfigs calls the function with every extra config of the service and the description
+// of its scope, stopping at the first error
+func (s *ServiceConfig) walkExtraConfigs(f func(ExtraConfig, string) error) error {
+	if err := f(s.ExtraConfig, "service config"); err != nil {
+		return err
+	}
+	for _, e := range s.Endpoints {
+		scope := "'" + e.Method + " " + e.Endpoint + "' endpoint"
+		if err := f(e.ExtraConfig, scope); err != nil {
+			return err
+		}
+		for _, b := range e.Backend {
+			if err := f(b.ExtraConfig, "'"+b.URLPattern+"' backend of the "+scope); err != nil {
+				return err
+			}
+		}
+	}
+	for _, a := range s.AsyncAgents {
+		scope := "'" + a.Name + "' async agent"
+		if err := f(a.ExtraConfig, scope); err != nil {
+			return err
+		}
+		for _, b := range a.Backend {
+			if err := f(b.ExtraConfig, "'"+b.URLPattern+"' backend of the "+scope); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateNamespaces returns the errors for the renamed namespaces rejected by Init, without
+// modifying the config
+func validateNamespaces(cfg ServiceConfig) []error {
+	var errs []error
+	cfg.walkExtraConfigs(func(e ExtraConfig, scope string) error {
+		for _, namespace := range e.renamedNamespaces() {
+			r, _ := namespaceRename(namespace)
+			if r.Stage == NamespaceRemoved || (cfg.StrictNamespaces && r.Stage == NamespaceDeprecated) {
+				_, conflict := e[r.Replacement]
+				errs = append(errs, &DeprecatedNamespaceError{NamespaceRename: r, Scope: scope, Conflict: conflict})
+			}
+		}
+		return nil
+	})
+	return errs
+}
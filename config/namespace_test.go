@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+)
+
+func registerTestRenames(t *testing.T) {
+	RenameNamespace("test/old-alias", "test/new-alias", NamespaceAliased)
+	RenameNamespace("test/old-deprecated", "test/new-deprecated", NamespaceDeprecated)
+	RenameNamespace("test/old-removed", "test/new-removed", NamespaceRemoved)
+	t.Cleanup(func() {
+		namespaceRenamesMu.Lock()
+		for _, ns := range []string{"test/old-alias", "test/old-deprecated", "test/old-removed"} {
+			delete(namespaceRenames, ns)
+		}
+		namespaceRenamesMu.Unlock()
+	})
+}
+
+func newNamespacesTestConfig(extra ExtraConfig) ServiceConfig {
+	return ServiceConfig{
+		Version:     ConfigVersion,
+		ExtraConfig: ExtraConfig{"test/old-alias": map[string]interface{}{"a": 1}},
+		Endpoints: []*EndpointConfig{
+			{
+				Endpoint:    "/foo",
+				Method:      "GET",
+				ExtraConfig: extra,
+				Backend:     []*Backend{{URLPattern: "/bar", Host: []string{"http://127.0.0.1:8080"}}},
+			},
+		},
+	}
+}
+
+func TestServiceConfig_initNamespaces(t *testing.T) {
+	registerTestRenames(t)
+
+	cfg := newNamespacesTestConfig(ExtraConfig{
+		"test/old-deprecated": map[string]interface{}{"b": 2},
+	})
+	cfg.Endpoints[0].Backend[0].ExtraConfig = ExtraConfig{
+		"test/old-alias": map[string]interface{}{"c": 3},
+		"test/new-alias": map[string]interface{}{"c": 4},
+	}
+	if err := cfg.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cfg.ExtraConfig["test/new-alias"]; !ok || len(cfg.ExtraConfig) != 1 {
+		t.Errorf("the aliased namespace has not been moved: %v", cfg.ExtraConfig)
+	}
+	if _, ok := cfg.Endpoints[0].ExtraConfig["test/new-deprecated"]; !ok || len(cfg.Endpoints[0].ExtraConfig) != 1 {
+		t.Errorf("the deprecated namespace has not been moved: %v", cfg.Endpoints[0].ExtraConfig)
+	}
+	if v := cfg.Endpoints[0].Backend[0].ExtraConfig["test/new-alias"]; v.(map[string]interface{})["c"] != 4 || len(cfg.Endpoints[0].Backend[0].ExtraConfig) != 1 {
+		t.Errorf("the new namespace has been overridden: %v", cfg.Endpoints[0].Backend[0].ExtraConfig)
+	}
+
+	warnings := cfg.NamespaceWarnings()
+	if len(warnings) != 2 {
+		t.Fatalf("unexpected warnings %v", warnings)
+	}
+	if msg := warnings[0].Error(); msg != "the namespace test/old-deprecated used in the 'GET /foo' endpoint is deprecated, use test/new-deprecated instead" {
+		t.Errorf("unexpected warning: %s", msg)
+	}
+	if d, ok := warnings[1].(*DeprecatedNamespaceError); !ok || !d.Conflict || d.Scope != "'/bar' backend of the 'GET /foo' endpoint" {
+		t.Errorf("unexpected warning: %v", warnings[1])
+	}
+}
+
+func TestServiceConfig_initNamespaces_rejected(t *testing.T) {
+	registerTestRenames(t)
+
+	for _, tc := range []struct {
+		namespace string
+		strict    bool
+		rejected  bool
+	}{
+		{"test/old-alias", true, false},
+		{"test/old-deprecated", false, false},
+		{"test/old-deprecated", true, true},
+		{"test/old-removed", false, true},
+	} {
+		cfg := newNamespacesTestConfig(ExtraConfig{tc.namespace: map[string]interface{}{}})
+		cfg.StrictNamespaces = tc.strict
+
+		errs := Validate(cfg)
+		err := cfg.Init()
+		if _, ok := err.(*DeprecatedNamespaceError); ok != tc.rejected {
+			t.Errorf("%s (strict: %v): unexpected error %v", tc.namespace, tc.strict, err)
+		}
+		if (len(errs) == 1) != tc.rejected {
+			t.Errorf("%s (strict: %v): unexpected validation errors %v", tc.namespace, tc.strict, errs)
+		}
+	}
+}
+
+func TestNamespaceRenames(t *testing.T) {
+	registerTestRenames(t)
+	ExtraConfigAlias["test/legacy"] = "test/new-legacy"
+	defer delete(ExtraConfigAlias, "test/legacy")
+
+	renames := NamespaceRenames()
+	if len(renames) != 3 || renames[0].Namespace != "test/old-alias" || renames[2].Stage != NamespaceRemoved {
+		t.Errorf("unexpected renames %v", renames)
+	}
+	if r, ok := namespaceRename("test/legacy"); !ok || r.Replacement != "test/new-legacy" || r.Stage != NamespaceAliased {
+		t.Errorf("the legacy aliases are not honored: %v", r)
+	}
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"net/textproto"
+	"strings"
+)
+
+const (
+	// WildcardParam is the entry of the input_headers and input_query_strings lists forwarding
+	// every header or query string param of the request
+	WildcardParam = "*"
+	// DenyParamPrefix marks the entries of the input_headers and input_query_strings lists
+	// excluding a param from the wildcard:
+	//
+	//	"input_headers": ["*", "!Authorization", "!Cookie"]
+	DenyParamPrefix = "!"
+)
+
+// RangeHeaders are the headers of the range requests, always forwarded to the backends of the
+// no-op endpoints, so they can answer with partial contents
+var RangeHeaders = []string{"Range", "If-Range"}
+
+// WithRangeHeaders returns the list of headers to pass adding the range headers missing from it.
+// The denied ones are kept denied
+func WithRangeHeaders(headers []string) []string {
+	res := headers
+	for _, h := range RangeHeaders {
+		found := false
+		for _, v := range headers {
+			if textproto.CanonicalMIMEHeaderKey(strings.TrimPrefix(v, DenyParamPrefix)) == h {
+				found = true
+				break
+			}
+		}
+		if !found {
+			if len(res) == len(headers) {
+				res = append(make([]string, 0, len(headers)+len(RangeHeaders)), headers...)
+			}
+			res = append(res, h)
+		}
+	}
+	return res
+}
+
+// ParamsFilter selects the headers or query string params forwarded to the backends
+type ParamsFilter struct {
+	// All is true when the list declares the wildcard
+	All bool
+	// Allowed are the params declared by the list, ignored if it declares the wildcard
+	Allowed []string
+	// Denied are the params excluded from the wildcard
+	Denied map[string]struct{}
+}
+
+// NewParamsFilter returns the ParamsFilter of a list of query string params
+func NewParamsFilter(params []string) ParamsFilter {
+	return newParamsFilter(params, func(s string) string { return s })
+}
+
+// NewHeadersFilter returns the ParamsFilter of a list of headers. The names are canonicalized
+func NewHeadersFilter(params []string) ParamsFilter {
+	return newParamsFilter(params, textproto.CanonicalMIMEHeaderKey)
+}
+
+func newParamsFilter(params []string, normalize func(string) string) ParamsFilter {
+	f := ParamsFilter{Denied: map[string]struct{}{}}
+	for _, p := range params {
+		switch {
+		case p == WildcardParam:
+			f.All = true
+		case strings.HasPrefix(p, DenyParamPrefix):
+			f.Denied[normalize(p[len(DenyParamPrefix):])] = struct{}{}
+		default:
+			f.Allowed = append(f.Allowed, normalize(p))
+		}
+	}
+	return f
+}
+
+// Allows returns true if the param must be forwarded
+func (f ParamsFilter) Allows(k string) bool {
+	if _, ok := f.Denied[k]; ok {
+		return false
+	}
+	if f.All {
+		return true
+	}
+	for _, a := range f.Allowed {
+		if a == k {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the params to forward. The received map is returned as is when the filter
+// forwards everything
+func (f ParamsFilter) Filter(values map[string][]string) map[string][]string {
+	if f.All && len(f.Denied) == 0 {
+		return values
+	}
+	res := make(map[string][]string, len(f.Allowed))
+	if f.All {
+		for k, v := range values {
+			if _, ok := f.Denied[k]; !ok {
+				res[k] = v
+			}
+		}
+		return res
+	}
+	for _, k := range f.Allowed {
+		if _, ok := f.Denied[k]; ok {
+			continue
+		}
+		if v, ok := values[k]; ok && len(v) > 0 {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// canonicalHeaderParam canonicalizes an entry of a list of headers, keeping its deny prefix
+func canonicalHeaderParam(p string) string {
+	if strings.HasPrefix(p, DenyParamPrefix) {
+		return DenyParamPrefix + textproto.CanonicalMIMEHeaderKey(p[len(DenyParamPrefix):])
+	}
+	return textproto.CanonicalMIMEHeaderKey(p)
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewHeadersFilter(t *testing.T) {
+	f := NewHeadersFilter([]string{"*", "!authorization", "!x-api-key"})
+	if !f.All || len(f.Allowed) != 0 || len(f.Denied) != 2 {
+		t.Errorf("unexpected filter: %+v", f)
+	}
+	if f.Allows("Authorization") || f.Allows("X-Api-Key") || !f.Allows("Content-Type") {
+		t.Error("unexpected allowed headers")
+	}
+	res := f.Filter(map[string][]string{
+		"Authorization": {"Bearer x"},
+		"Content-Type":  {"application/json"},
+		"X-Api-Key":     {"secret"},
+	})
+	if !reflect.DeepEqual(res, map[string][]string{"Content-Type": {"application/json"}}) {
+		t.Errorf("unexpected headers: %v", res)
+	}
+}
+
+func TestNewParamsFilter(t *testing.T) {
+	values := map[string][]string{"a": {"1"}, "b": {"2"}, "c": {}}
+
+	f := NewParamsFilter([]string{"a", "c", "z"})
+	if f.All || !f.Allows("a") || f.Allows("b") {
+		t.Errorf("unexpected filter: %+v", f)
+	}
+	if res := f.Filter(values); !reflect.DeepEqual(res, map[string][]string{"a": {"1"}}) {
+		t.Errorf("unexpected params: %v", res)
+	}
+
+	f = NewParamsFilter([]string{"*"})
+	if res := f.Filter(values); reflect.ValueOf(res).Pointer() != reflect.ValueOf(values).Pointer() {
+		t.Error("the params should be returned as is")
+	}
+
+	f = NewParamsFilter([]string{"*", "!b"})
+	if res := f.Filter(values); len(res) != 2 || res["b"] != nil {
+		t.Errorf("unexpected params: %v", res)
+	}
+}
+
+func TestWithRangeHeaders(t *testing.T) {
+	for i, tc := range []struct {
+		in  []string
+		out []string
+	}{
+		{in: nil, out: []string{"Range", "If-Range"}},
+		{in: []string{"Content-Type"}, out: []string{"Content-Type", "Range", "If-Range"}},
+		{in: []string{"range", "If-Range"}, out: []string{"range", "If-Range"}},
+		{in: []string{"*", "!Range"}, out: []string{"*", "!Range", "If-Range"}},
+	} {
+		in := append([]string{}, tc.in...)
+		if res := WithRangeHeaders(in); !reflect.DeepEqual(res, tc.out) {
+			t.Errorf("#%d: unexpected headers: %v", i, res)
+		}
+		if !reflect.DeepEqual(in, append([]string{}, tc.in...)) {
+			t.Errorf("#%d: the received list has been modified: %v", i, in)
+		}
+	}
+}
+
+func TestCanonicalHeaderParam(t *testing.T) {
+	for in, out := range map[string]string{
+		"x-api-key":  "X-Api-Key",
+		"!x-api-key": "!X-Api-Key",
+		"*":          "*",
+	} {
+		if res := canonicalHeaderParam(in); res != out {
+			t.Errorf("%s: have %s, want %s", in, res, out)
+		}
+	}
+}
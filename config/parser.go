@@ -43,11 +43,21 @@ func (p parser) Parse(configFile string) (ServiceConfig, error) {
 	if err != nil {
 		return result, CheckErr(err, configFile)
 	}
+	if data, err = ExpandVariables(data); err != nil {
+		return result, CheckErr(err, configFile)
+	}
+	if data, err = ResolveIncludes(data, configFile, p.fileReader); err != nil {
+		return result, CheckErr(err, configFile)
+	}
 	if err = json.Unmarshal(data, &cfg); err != nil {
 		return result, CheckErr(err, configFile)
 	}
 	result = cfg.normalize()
 
+	if errs := Validate(result); len(errs) > 0 {
+		return result, CheckErr(&ValidationError{Errors: errs}, configFile)
+	}
+
 	if err = result.Init(); err != nil {
 		return result, CheckErr(err, configFile)
 	}
@@ -135,6 +145,7 @@ type parseableServiceConfig struct {
 	Name                  string                     `json:"name"`
 	Endpoints             []*parseableEndpointConfig `json:"endpoints"`
 	AsyncAgents           []*parseableAsyncAgent     `json:"async_agent"`
+	Tenants               []*parseableTenant         `json:"tenants,omitempty"`
 	Timeout               string                     `json:"timeout"`
 	CacheTTL              string                     `json:"cache_ttl"`
 	Host                  []string                   `json:"host"`
@@ -149,6 +160,7 @@ type parseableServiceConfig struct {
 	DisableKeepAlives     bool                       `json:"disable_keep_alives"`
 	DisableCompression    bool                       `json:"disable_compression"`
 	DisableStrictREST     bool                       `json:"disable_rest"`
+	StrictNamespaces      bool                       `json:"strict_namespaces"`
 	MaxIdleConns          int                        `json:"max_idle_connections"`
 	MaxIdleConnsPerHost   int                        `json:"max_idle_connections_per_host"`
 	IdleConnTimeout       string                     `json:"idle_connection_timeout"`
@@ -164,11 +176,17 @@ type parseableServiceConfig struct {
 	TLS                   *parseableTLS              `json:"tls,omitempty"`
 	ClientTLS             *parseableClientTLS        `json:"client_tls,omitempty"`
 	UseH2C                bool                       `json:"use_h2c,omitempty"`
+	HeadersToPass         []string                   `json:"input_headers"`
+	QueryString           []string                   `json:"input_query_strings"`
+	ConcurrentCalls       int                        `json:"concurrent_calls"`
 }
 
 func (p *parseableServiceConfig) normalize() ServiceConfig {
 	cfg := ServiceConfig{
 		Name:                  p.Name,
+		HeadersToPass:         p.HeadersToPass,
+		QueryString:           p.QueryString,
+		ConcurrentCalls:       p.ConcurrentCalls,
 		Timeout:               parseDuration(p.Timeout),
 		CacheTTL:              parseDuration(p.CacheTTL),
 		Host:                  p.Host,
@@ -184,6 +202,7 @@ func (p *parseableServiceConfig) normalize() ServiceConfig {
 		DisableKeepAlives:     p.DisableKeepAlives,
 		DisableCompression:    p.DisableCompression,
 		DisableStrictREST:     p.DisableStrictREST,
+		StrictNamespaces:      p.StrictNamespaces,
 		MaxIdleConns:          p.MaxIdleConns,
 		MaxIdleConnsPerHost:   p.MaxIdleConnsPerHost,
 		IdleConnTimeout:       parseDuration(p.IdleConnTimeout),
@@ -209,22 +228,14 @@ func (p *parseableServiceConfig) normalize() ServiceConfig {
 			CipherSuites:             p.TLS.CipherSuites,
 			EnableMTLS:               p.TLS.EnableMTLS,
 			DisableSystemCaPool:      p.TLS.DisableSystemCaPool,
+			EnableHTTP3:              p.TLS.EnableHTTP3,
+			HTTP3Port:                p.TLS.HTTP3Port,
+			EnableHotReload:          p.TLS.EnableHotReload,
+			HotReloadInterval:        parseDuration(p.TLS.HotReloadInterval),
 		}
 	}
 	if p.ClientTLS != nil {
-		cfg.ClientTLS = &ClientTLS{
-			AllowInsecureConnections: p.ClientTLS.AllowInsecureConnections,
-			CaCerts:                  p.ClientTLS.CaCerts,
-			DisableSystemCaPool:      p.ClientTLS.DisableSystemCaPool,
-			MinVersion:               p.ClientTLS.MinVersion,
-			MaxVersion:               p.ClientTLS.MaxVersion,
-			CurvePreferences:         p.ClientTLS.CurvePreferences,
-			CipherSuites:             p.ClientTLS.CipherSuites,
-			ClientCerts:              make([]ClientTLSCert, 0, len(p.ClientTLS.ClientCerts)),
-		}
-		for _, cc := range p.ClientTLS.ClientCerts {
-			cfg.ClientTLS.ClientCerts = append(cfg.ClientTLS.ClientCerts, ClientTLSCert(cc))
-		}
+		cfg.ClientTLS = p.ClientTLS.normalize()
 	}
 	if p.ExtraConfig != nil {
 		cfg.ExtraConfig = *p.ExtraConfig
@@ -239,6 +250,9 @@ func (p *parseableServiceConfig) normalize() ServiceConfig {
 		agents = append(agents, a.normalize())
 	}
 	cfg.AsyncAgents = agents
+	for _, t := range p.Tenants {
+		cfg.Tenants = append(cfg.Tenants, t.normalize())
+	}
 	return cfg
 }
 
@@ -254,6 +268,10 @@ type parseableTLS struct {
 	CipherSuites             []uint16 `json:"cipher_suites"`
 	EnableMTLS               bool     `json:"enable_mtls"`
 	DisableSystemCaPool      bool     `json:"disable_system_ca_pool"`
+	EnableHTTP3              bool     `json:"enable_http3"`
+	HTTP3Port                int      `json:"http3_port"`
+	EnableHotReload          bool     `json:"enable_hot_reload"`
+	HotReloadInterval        string   `json:"hot_reload_interval"`
 }
 
 type parseableClientTLS struct {
@@ -265,6 +283,25 @@ type parseableClientTLS struct {
 	CurvePreferences         []uint16                 `json:"curve_preferences"`
 	CipherSuites             []uint16                 `json:"cipher_suites"`
 	ClientCerts              []parseableClientTLSCert `json:"client_certs"`
+	ServerName               string                   `json:"server_name"`
+}
+
+func (p *parseableClientTLS) normalize() *ClientTLS {
+	cfg := &ClientTLS{
+		AllowInsecureConnections: p.AllowInsecureConnections,
+		CaCerts:                  p.CaCerts,
+		DisableSystemCaPool:      p.DisableSystemCaPool,
+		MinVersion:               p.MinVersion,
+		MaxVersion:               p.MaxVersion,
+		CurvePreferences:         p.CurvePreferences,
+		CipherSuites:             p.CipherSuites,
+		ClientCerts:              make([]ClientTLSCert, 0, len(p.ClientCerts)),
+		ServerName:               p.ServerName,
+	}
+	for _, cc := range p.ClientCerts {
+		cfg.ClientCerts = append(cfg.ClientCerts, ClientTLSCert(cc))
+	}
+	return cfg
 }
 
 type parseableClientTLSCert struct {
@@ -283,6 +320,15 @@ type parseableEndpointConfig struct {
 	ExtraConfig     *ExtraConfig        `json:"extra_config,omitempty"`
 	HeadersToPass   []string            `json:"input_headers"`
 	OutputEncoding  string              `json:"output_encoding"`
+	Aliases         []parseableAlias    `json:"aliases,omitempty"`
+	Tenant          string              `json:"tenant,omitempty"`
+	Hosts           []string            `json:"hosts,omitempty"`
+}
+
+type parseableAlias struct {
+	Path       string `json:"path"`
+	Deprecated bool   `json:"deprecated"`
+	Sunset     string `json:"sunset"`
 }
 
 func (p *parseableEndpointConfig) normalize() *EndpointConfig {
@@ -295,6 +341,8 @@ func (p *parseableEndpointConfig) normalize() *EndpointConfig {
 		QueryString:     p.QueryString,
 		HeadersToPass:   p.HeadersToPass,
 		OutputEncoding:  p.OutputEncoding,
+		Tenant:          p.Tenant,
+		Hosts:           p.Hosts,
 	}
 	if p.ExtraConfig != nil {
 		e.ExtraConfig = *p.ExtraConfig
@@ -304,9 +352,47 @@ func (p *parseableEndpointConfig) normalize() *EndpointConfig {
 		backends = append(backends, b.normalize())
 	}
 	e.Backend = backends
+	for _, a := range p.Aliases {
+		e.Aliases = append(e.Aliases, EndpointAlias{Path: a.Path, Deprecated: a.Deprecated, Sunset: a.Sunset})
+	}
 	return &e
 }
 
+type parseableTenant struct {
+	Name            string                     `json:"name"`
+	Hosts           []string                   `json:"hosts"`
+	PathPrefix      string                     `json:"path_prefix"`
+	Timeout         string                     `json:"timeout"`
+	CacheTTL        string                     `json:"cache_ttl"`
+	HeadersToPass   []string                   `json:"input_headers"`
+	QueryString     []string                   `json:"input_query_strings"`
+	ConcurrentCalls int                        `json:"concurrent_calls"`
+	OutputEncoding  string                     `json:"output_encoding"`
+	ExtraConfig     *ExtraConfig               `json:"extra_config,omitempty"`
+	Endpoints       []*parseableEndpointConfig `json:"endpoints"`
+}
+
+func (p *parseableTenant) normalize() *Tenant {
+	t := Tenant{
+		Name:            p.Name,
+		Hosts:           p.Hosts,
+		PathPrefix:      p.PathPrefix,
+		Timeout:         parseDuration(p.Timeout),
+		CacheTTL:        parseDuration(p.CacheTTL),
+		HeadersToPass:   p.HeadersToPass,
+		QueryString:     p.QueryString,
+		ConcurrentCalls: p.ConcurrentCalls,
+		OutputEncoding:  p.OutputEncoding,
+	}
+	if p.ExtraConfig != nil {
+		t.ExtraConfig = *p.ExtraConfig
+	}
+	for _, e := range p.Endpoints {
+		t.Endpoints = append(t.Endpoints, e.normalize())
+	}
+	return &t
+}
+
 type parseableAsyncAgent struct {
 	Name       string `json:"name"`
 	Connection struct {
@@ -353,22 +439,23 @@ func (p *parseableAsyncAgent) normalize() *AsyncAgent {
 }
 
 type parseableBackend struct {
-	Group                    string            `json:"group"`
-	Method                   string            `json:"method"`
-	Host                     []string          `json:"host"`
-	HostSanitizationDisabled bool              `json:"disable_host_sanitize"`
-	URLPattern               string            `json:"url_pattern"`
-	AllowList                []string          `json:"allow"`
-	DenyList                 []string          `json:"deny"`
-	Mapping                  map[string]string `json:"mapping"`
-	Encoding                 string            `json:"encoding"`
-	IsCollection             bool              `json:"is_collection"`
-	Target                   string            `json:"target"`
-	ExtraConfig              *ExtraConfig      `json:"extra_config,omitempty"`
-	SD                       string            `json:"sd"`
-	HeadersToPass            []string          `json:"input_headers"`
-	SDScheme                 string            `json:"sd_scheme"`
-	QueryStringsToPass       []string          `json:"input_query_strings"`
+	Group                    string              `json:"group"`
+	Method                   string              `json:"method"`
+	Host                     []string            `json:"host"`
+	HostSanitizationDisabled bool                `json:"disable_host_sanitize"`
+	URLPattern               string              `json:"url_pattern"`
+	AllowList                []string            `json:"allow"`
+	DenyList                 []string            `json:"deny"`
+	Mapping                  map[string]string   `json:"mapping"`
+	Encoding                 string              `json:"encoding"`
+	IsCollection             bool                `json:"is_collection"`
+	Target                   string              `json:"target"`
+	ExtraConfig              *ExtraConfig        `json:"extra_config,omitempty"`
+	SD                       string              `json:"sd"`
+	HeadersToPass            []string            `json:"input_headers"`
+	SDScheme                 string              `json:"sd_scheme"`
+	QueryStringsToPass       []string            `json:"input_query_strings"`
+	ClientTLS                *parseableClientTLS `json:"client_tls,omitempty"`
 }
 
 func (p *parseableBackend) normalize() *Backend {
@@ -392,6 +479,9 @@ func (p *parseableBackend) normalize() *Backend {
 	if b.SDScheme == "" {
 		b.SDScheme = "http"
 	}
+	if p.ClientTLS != nil {
+		b.ClientTLS = p.ClientTLS.normalize()
+	}
 	if p.ExtraConfig != nil {
 		b.ExtraConfig = *p.ExtraConfig
 	}
@@ -405,3 +495,17 @@ func parseDuration(v string) time.Duration {
 	}
 	return d
 }
+
+// ParseClientTLS decodes a client TLS definition embedded in an extra config section,
+// using the same format as the client_tls entries of the configuration file
+func ParseClientTLS(v interface{}) (*ClientTLS, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var p parseableClientTLS
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, err
+	}
+	return p.normalize(), nil
+}
@@ -67,7 +67,12 @@ func TestNewParser_ok(t *testing.T) {
                     "host": [
                         "http://127.0.0.1:8080"
                     ],
-                    "url_pattern": "/__debug/supu"
+                    "url_pattern": "/__debug/supu",
+                    "client_tls": {
+                        "ca_certs": ["ca.pem"],
+                        "server_name": "supu.internal",
+                        "client_certs": [{"certificate": "cert.pem", "private_key": "key.pem"}]
+                    }
                 }
             ]
         },
@@ -143,6 +148,23 @@ func TestNewParser_ok(t *testing.T) {
 		t.Error("Extra config is not present in BackendConfig")
 	}
 
+	if backend.ClientTLS != nil {
+		t.Error("unexpected client TLS config in the first backend")
+	}
+	if tlsCfg := serviceConfig.Endpoints[1].Backend[0].ClientTLS; tlsCfg == nil {
+		t.Error("client TLS config not present in the backend")
+	} else {
+		if tlsCfg.ServerName != "supu.internal" {
+			t.Errorf("unexpected server name: %s", tlsCfg.ServerName)
+		}
+		if len(tlsCfg.CaCerts) != 1 || tlsCfg.CaCerts[0] != "ca.pem" {
+			t.Errorf("unexpected CA certs: %v", tlsCfg.CaCerts)
+		}
+		if len(tlsCfg.ClientCerts) != 1 || tlsCfg.ClientCerts[0].PrivateKey != "key.pem" {
+			t.Errorf("unexpected client certs: %v", tlsCfg.ClientCerts)
+		}
+	}
+
 	if err := os.Remove(configPath); err != nil {
 		t.FailNow()
 	}
@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package remote provides config.FileReaderFunc implementations fetching the configuration from
+remote sources, so centrally managed fleets can share the same configuration:
+
+	parser := config.NewParserWithFileReader(remote.NewFileReader(remote.Options{}))
+	cfg, err := parser.Parse("https://config.example.com/krakend.json")
+
+The supported locations are http(s) URLs, S3 objects (s3://bucket/key) and Consul KV keys
+(consul://host:port/key). Any other location is read from the local filesystem.
+*/
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/luraproject/lura/v2/backoff"
+	"github.com/luraproject/lura/v2/config"
+)
+
+// DefaultTimeout is the timeout of every attempt when the options do not define one
+const DefaultTimeout = 10 * time.Second
+
+// ErrChecksumMismatch is the error returned when the content does not match the expected checksum
+var ErrChecksumMismatch = errors.New("remote config: checksum mismatch")
+
+// Options contains the configuration of the remote readers
+type Options struct {
+	// Client is the http client to use. If nil, a client with DefaultTimeout is used
+	Client *http.Client
+	// Retries is the number of extra attempts after a failed fetch
+	Retries int
+	// Backoff returns the time to wait before every retry. If nil, an exponential
+	// backoff with jitter is used
+	Backoff backoff.TimeToWaitBeforeRetry
+	// Checksum is the expected sha256 of the content, hex encoded. If empty, the checksum
+	// is not verified
+	Checksum string
+	// Headers are added to every http request (i.e: authorization)
+	Headers map[string]string
+	// ConsulToken is the ACL token for the Consul KV requests
+	ConsulToken string
+	// ConsulScheme is the scheme of the Consul API. Defaults to http
+	ConsulScheme string
+	// S3 contains the credentials and region for the S3 requests
+	S3 S3Options
+}
+
+// Fetcher returns the content stored at the location
+type Fetcher func(ctx context.Context, location string) ([]byte, error)
+
+// NewFileReader returns a config.FileReaderFunc selecting the source by the scheme of the
+// received location, with retries and checksum verification
+func NewFileReader(opts Options) config.FileReaderFunc {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: DefaultTimeout}
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = backoff.ExponentialJitterBackoff
+	}
+	fetchers := map[string]Fetcher{
+		"http://":   httpFetcher(opts),
+		"https://":  httpFetcher(opts),
+		"s3://":     s3Fetcher(opts),
+		"consul://": consulFetcher(opts),
+	}
+
+	return func(location string) ([]byte, error) {
+		fetch := func(_ context.Context, path string) ([]byte, error) { return os.ReadFile(path) }
+		for prefix, f := range fetchers {
+			if strings.HasPrefix(location, prefix) {
+				fetch = f
+				break
+			}
+		}
+
+		var b []byte
+		var err error
+		for i := 0; i <= opts.Retries; i++ {
+			if i > 0 {
+				time.Sleep(opts.Backoff(i))
+			}
+			b, err = fetch(context.Background(), location)
+			if err == nil {
+				break
+			}
+			var pathErr *os.PathError
+			if errors.As(err, &pathErr) {
+				return nil, err
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := verifyChecksum(b, opts.Checksum); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+}
+
+func verifyChecksum(b []byte, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	sum := sha256.Sum256(b)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), strings.TrimPrefix(expected, "sha256:")) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+func httpFetcher(opts Options) Fetcher {
+	return func(ctx context.Context, location string) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, http.NoBody)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range opts.Headers {
+			req.Header.Set(k, v)
+		}
+		return do(opts.Client, req)
+	}
+}
+
+func consulFetcher(opts Options) Fetcher {
+	scheme := opts.ConsulScheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	return func(ctx context.Context, location string) ([]byte, error) {
+		rest := strings.TrimPrefix(location, "consul://")
+		i := strings.Index(rest, "/")
+		if i <= 0 || i == len(rest)-1 {
+			return nil, fmt.Errorf("remote config: invalid consul location %s", location)
+		}
+		url := fmt.Sprintf("%s://%s/v1/kv/%s?raw", scheme, rest[:i], rest[i+1:])
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+		if err != nil {
+			return nil, err
+		}
+		if opts.ConsulToken != "" {
+			req.Header.Set("X-Consul-Token", opts.ConsulToken)
+		}
+		return do(opts.Client, req)
+	}
+}
+
+func do(c *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("remote config: unexpected status code %d fetching %s", resp.StatusCode, req.URL.Redacted())
+	}
+	return io.ReadAll(resp.Body)
+}
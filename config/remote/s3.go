@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package remote
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// S3Options contains the credentials and location of the S3 objects. The empty fields are
+// taken from the standard AWS environment variables
+type S3Options struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Endpoint overrides the AWS endpoint (i.e: minio or localstack). Path style requests
+	// are used when it is defined
+	Endpoint string
+}
+
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func (o S3Options) withDefaults() S3Options {
+	if o.Region == "" {
+		o.Region = os.Getenv("AWS_REGION")
+	}
+	if o.Region == "" {
+		o.Region = "us-east-1"
+	}
+	if o.AccessKeyID == "" {
+		o.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if o.SecretAccessKey == "" {
+		o.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if o.SessionToken == "" {
+		o.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	return o
+}
+
+func s3Fetcher(opts Options) Fetcher {
+	return func(ctx context.Context, location string) ([]byte, error) {
+		s3 := opts.S3.withDefaults()
+		rest := strings.TrimPrefix(location, "s3://")
+		i := strings.Index(rest, "/")
+		if i <= 0 || i == len(rest)-1 {
+			return nil, fmt.Errorf("remote config: invalid s3 location %s", location)
+		}
+		bucket, key := rest[:i], rest[i+1:]
+
+		var u string
+		if s3.Endpoint != "" {
+			u = strings.TrimSuffix(s3.Endpoint, "/") + "/" + bucket + "/" + s3Escape(key)
+		} else {
+			u = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, s3.Region, s3Escape(key))
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+		if err != nil {
+			return nil, err
+		}
+		if s3.AccessKeyID != "" {
+			signS3Request(req, s3, time.Now().UTC())
+		}
+		return do(opts.Client, req)
+	}
+}
+
+// signS3Request adds the AWS signature version 4 headers to the request
+func signS3Request(req *http.Request, o S3Options, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + o.Region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	values := []string{req.URL.Host, emptyPayloadHash, amzDate}
+	if o.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", o.SessionToken)
+		headers = append(headers, "x-amz-security-token")
+		values = append(values, o.SessionToken)
+	}
+
+	var canonicalHeaders strings.Builder
+	for i, h := range headers {
+		canonicalHeaders.WriteString(h + ":" + values[i] + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+o.SecretAccessKey), date)
+	key = hmacSHA256(key, o.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		o.AccessKeyID,
+		scope,
+		signedHeaders,
+		signature,
+	))
+}
+
+func canonicalQuery(v url.Values) string {
+	return strings.ReplaceAll(v.Encode(), "+", "%20")
+}
+
+// s3Escape encodes every byte of the key but the unreserved chars and the path separators
+func s3Escape(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// DurationPattern is the pattern of the durations in the configuration files, as accepted by
+// time.ParseDuration
+const DurationPattern = `^-?([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+// Schema returns the JSON schema (draft-07) of the configuration files, generated from the
+// structs used by the parser, so it always matches the properties accepted by the current
+// version. The extra config sections are free-form objects. The output is stable: two calls
+// with the same version of the package return the same bytes
+func Schema() ([]byte, error) {
+	s := schemaOf(reflect.TypeOf(parseableServiceConfig{}), "")
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "Lura service config"
+	s["required"] = []string{"version"}
+	props := s["properties"].(map[string]interface{})
+	props["version"] = map[string]interface{}{"type": "integer", "const": ConfigVersion}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+var extraConfigType = reflect.TypeOf(ExtraConfig{})
+
+func schemaOf(t reflect.Type, name string) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == extraConfigType {
+		return map[string]interface{}{"type": "object", "additionalProperties": true}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		if isDurationProperty(name) {
+			return map[string]interface{}{"type": "string", "pattern": DurationPattern}
+		}
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), "")}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), "")}
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			key := strings.Split(f.Tag.Get("json"), ",")[0]
+			if key == "-" {
+				continue
+			}
+			if key == "" {
+				// the json decoder matches the untagged fields ignoring the case
+				key = strings.ToLower(f.Name)
+			}
+			props[key] = schemaOf(f.Type, key)
+		}
+		return map[string]interface{}{"type": "object", "properties": props}
+	}
+	return map[string]interface{}{}
+}
+
+// isDurationProperty returns true for the properties parsed as time.Duration
+func isDurationProperty(name string) bool {
+	for _, suffix := range []string{"timeout", "_ttl", "_interval", "_delay", "_keep_alive"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
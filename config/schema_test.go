@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	b, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := Schema()
+	if !bytes.Equal(b, again) {
+		t.Error("the schema must be stable")
+	}
+
+	var s struct {
+		Required   []string `json:"required"`
+		Properties map[string]struct {
+			Type       string                     `json:"type"`
+			Pattern    string                     `json:"pattern"`
+			Const      int                        `json:"const"`
+			Items      map[string]json.RawMessage `json:"items"`
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Required) != 1 || s.Required[0] != "version" || s.Properties["version"].Const != ConfigVersion {
+		t.Errorf("unexpected version %+v", s.Properties["version"])
+	}
+	if p := s.Properties["timeout"]; p.Type != "string" || p.Pattern != DurationPattern {
+		t.Errorf("unexpected timeout %+v", p)
+	}
+	if p := s.Properties["port"]; p.Type != "integer" {
+		t.Errorf("unexpected port %+v", p)
+	}
+	if p := s.Properties["extra_config"]; p.Type != "object" {
+		t.Errorf("unexpected extra_config %+v", p)
+	}
+	if p := s.Properties["plugin"]; p.Properties["folder"] == nil {
+		t.Errorf("unexpected plugin %+v", p)
+	}
+	if p := s.Properties["endpoints"]; p.Type != "array" || p.Items["properties"] == nil {
+		t.Errorf("unexpected endpoints %+v", p)
+	}
+
+	re := regexp.MustCompile(DurationPattern)
+	for _, d := range []string{"1s", "1h30m", "1.5s", "300ms"} {
+		if !re.MatchString(d) {
+			t.Errorf("%s should match", d)
+		}
+	}
+	for _, d := range []string{"", "1", "s", "1 s"} {
+		if re.MatchString(d) {
+			t.Errorf("%s should not match", d)
+		}
+	}
+}
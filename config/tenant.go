@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Tenant groups the endpoints of one of the APIs served by the gateway. The endpoints of the
+// tenant are exposed under its path prefix and, when it declares a set of hosts, only to the
+// requests sent to them, so several tenants can declare the same endpoints.
+//
+// The settings of the tenant are the defaults of its endpoints: they are used by the endpoints
+// without their own values, before the defaults of the service. The namespaces of the tenant
+// extra config (rate limits, auth settings...) are added to the endpoints not declaring them.
+// Init moves the endpoints of the tenants, already scoped, to the endpoints of the service
+type Tenant struct {
+	// Name identifies the tenant
+	Name string `mapstructure:"name"`
+	// Hosts served by the tenant. A leading "*." matches all the subdomains of the host. If
+	// empty, the endpoints of the tenant are served to all the hosts
+	Hosts []string `mapstructure:"hosts"`
+	// PathPrefix is prepended to the paths of the endpoints of the tenant and their aliases
+	PathPrefix string `mapstructure:"path_prefix"`
+	// default timeout of the endpoints of the tenant
+	Timeout time.Duration `mapstructure:"timeout"`
+	// default duration of the cache header of the endpoints of the tenant
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	// default list of headers to pass to the backends
+	HeadersToPass []string `mapstructure:"input_headers"`
+	// default list of query string params to pass to the backends
+	QueryString []string `mapstructure:"input_query_strings"`
+	// default number of concurrent calls to the backends
+	ConcurrentCalls int `mapstructure:"concurrent_calls"`
+	// default encoding of the endpoint responses
+	OutputEncoding string `mapstructure:"output_encoding"`
+	// ExtraConfig holds the namespaces shared by the endpoints of the tenant
+	ExtraConfig ExtraConfig `mapstructure:"extra_config"`
+	// set of endpoint definitions of the tenant
+	Endpoints []*EndpointConfig `mapstructure:"endpoints"`
+}
+
+// scope returns a copy of the endpoint with the path prefix, the hosts and the defaults of the
+// tenant. The endpoint is not modified
+func (t *Tenant) scope(e *EndpointConfig) *EndpointConfig {
+	scoped := *e
+	scoped.Tenant = t.Name
+	scoped.Hosts = normalizeHosts(t.Hosts)
+	scoped.Endpoint = t.prefix(e.Endpoint)
+	if len(e.Aliases) > 0 {
+		scoped.Aliases = make([]EndpointAlias, len(e.Aliases))
+		for i, a := range e.Aliases {
+			a.Path = t.prefix(a.Path)
+			scoped.Aliases[i] = a
+		}
+	}
+
+	if scoped.Timeout == 0 {
+		scoped.Timeout = t.Timeout
+	}
+	if scoped.CacheTTL == 0 {
+		scoped.CacheTTL = t.CacheTTL
+	}
+	if scoped.ConcurrentCalls == 0 {
+		scoped.ConcurrentCalls = t.ConcurrentCalls
+	}
+	if scoped.OutputEncoding == "" {
+		scoped.OutputEncoding = t.OutputEncoding
+	}
+	if scoped.HeadersToPass == nil && len(t.HeadersToPass) > 0 {
+		scoped.HeadersToPass = append([]string{}, t.HeadersToPass...)
+	}
+	if scoped.QueryString == nil && len(t.QueryString) > 0 {
+		scoped.QueryString = append([]string{}, t.QueryString...)
+	}
+
+	if len(t.ExtraConfig) > 0 {
+		extra := make(ExtraConfig, len(t.ExtraConfig)+len(e.ExtraConfig))
+		for k, v := range t.ExtraConfig {
+			extra[k] = v
+		}
+		for k, v := range e.ExtraConfig {
+			extra[k] = v
+		}
+		scoped.ExtraConfig = extra
+	}
+	return &scoped
+}
+
+func (t *Tenant) prefix(path string) string {
+	prefix := strings.TrimRight(t.PathPrefix, "/")
+	if prefix == "" {
+		return path
+	}
+	if path == "" || path == "/" {
+		return prefix
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return prefix + path
+}
+
+// validate returns the reason of the tenant being invalid, if any
+func (t *Tenant) validate() string {
+	if t.Name == "" {
+		return "the tenant has no name"
+	}
+	if t.PathPrefix != "" && !strings.HasPrefix(t.PathPrefix, "/") {
+		return "the path prefix must start with a slash"
+	}
+	for _, h := range t.Hosts {
+		h = strings.TrimPrefix(h, "*.")
+		if h == "" || strings.ContainsAny(h, "/*?# ") {
+			return "invalid host '" + h + "'"
+		}
+	}
+	return ""
+}
+
+func (s *ServiceConfig) initTenants() error {
+	names := map[string]struct{}{}
+	for _, t := range s.Tenants {
+		if reason := t.validate(); reason != "" {
+			return &InvalidTenantError{Name: t.Name, Reason: reason}
+		}
+		if _, ok := names[t.Name]; ok {
+			return &InvalidTenantError{Name: t.Name, Reason: "the name is used by another tenant"}
+		}
+		names[t.Name] = struct{}{}
+
+		for _, e := range t.Endpoints {
+			s.Endpoints = append(s.Endpoints, t.scope(e))
+		}
+		t.Endpoints = nil
+	}
+	return nil
+}
+
+// tenantEndpoints returns the endpoints of the service followed by the scoped endpoints of the
+// tenants, without modifying the config
+func (s *ServiceConfig) tenantEndpoints() []*EndpointConfig {
+	endpoints := s.Endpoints[:len(s.Endpoints):len(s.Endpoints)]
+	for _, t := range s.Tenants {
+		for _, e := range t.Endpoints {
+			endpoints = append(endpoints, t.scope(e))
+		}
+	}
+	return endpoints
+}
+
+// validateTenants returns the errors of the tenants rejected by Init
+func validateTenants(tenants []*Tenant) []error {
+	var errs []error
+	names := map[string]struct{}{}
+	for _, t := range tenants {
+		if reason := t.validate(); reason != "" {
+			errs = append(errs, &InvalidTenantError{Name: t.Name, Reason: reason})
+			continue
+		}
+		if _, ok := names[t.Name]; ok {
+			errs = append(errs, &InvalidTenantError{Name: t.Name, Reason: "the name is used by another tenant"})
+		}
+		names[t.Name] = struct{}{}
+	}
+	return errs
+}
+
+// normalizeHosts returns the hosts in lower case, without the trailing dots
+func normalizeHosts(hosts []string) []string {
+	if len(hosts) == 0 {
+		return nil
+	}
+	res := make([]string, len(hosts))
+	for i, h := range hosts {
+		res[i] = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(h)), ".")
+	}
+	return res
+}
+
+// InvalidTenantError is the error returned when a tenant has no name, reuses the name of another
+// one or declares an invalid path prefix or host
+type InvalidTenantError struct {
+	Name   string
+	Reason string
+}
+
+// Error returns a string representation of the InvalidTenantError
+func (i *InvalidTenantError) Error() string {
+	return "invalid tenant '" + i.Name + "': " + i.Reason
+}
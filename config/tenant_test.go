@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const tenantsConfig = `{
+	"version": 3,
+	"host": ["http://127.0.0.1:8080"],
+	"timeout": "3s",
+	"endpoints": [
+		{"endpoint": "/users/{id}", "backend": [{"url_pattern": "/users/{id}"}]}
+	],
+	"tenants": [
+		{
+			"name": "acme",
+			"hosts": ["API.acme.com."],
+			"timeout": "1s",
+			"input_headers": ["x-tenant"],
+			"extra_config": {
+				"auth": {"roles": ["admin"]},
+				"ratelimit": {"max_rate": 10}
+			},
+			"endpoints": [
+				{"endpoint": "/users/{id}", "backend": [{"url_pattern": "/acme/users/{id}"}]},
+				{
+					"endpoint": "/orders",
+					"timeout": "5s",
+					"aliases": [{"path": "/purchases"}],
+					"extra_config": {"ratelimit": {"max_rate": 1}},
+					"backend": [{"url_pattern": "/acme/orders"}]
+				}
+			]
+		},
+		{
+			"name": "globex",
+			"path_prefix": "/globex/",
+			"cache_ttl": "1m",
+			"endpoints": [
+				{"endpoint": "/users/{id}", "backend": [{"url_pattern": "/users/{id}"}]}
+			]
+		}
+	]
+}`
+
+func TestServiceConfig_initTenants(t *testing.T) {
+	cfg, err := NewParserWithFileReader(func(string) ([]byte, error) {
+		return []byte(tenantsConfig), nil
+	}).Parse("tenants.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Endpoints) != 4 {
+		t.Fatalf("unexpected number of endpoints: %d", len(cfg.Endpoints))
+	}
+	for _, tenant := range cfg.Tenants {
+		if len(tenant.Endpoints) != 0 {
+			t.Errorf("the endpoints of the tenant %s were not moved to the service", tenant.Name)
+		}
+	}
+
+	if e := cfg.Endpoints[0]; e.Tenant != "" || len(e.Hosts) != 0 || e.Timeout != 3*time.Second {
+		t.Errorf("the endpoints of the service must not be scoped: %+v", e)
+	}
+
+	users := cfg.Endpoints[1]
+	if users.Tenant != "acme" || users.Endpoint != "/users/:id" {
+		t.Errorf("unexpected endpoint: %s %s", users.Tenant, users.Endpoint)
+	}
+	if len(users.Hosts) != 1 || users.Hosts[0] != "api.acme.com" {
+		t.Errorf("unexpected hosts: %v", users.Hosts)
+	}
+	if users.Timeout != time.Second {
+		t.Errorf("the timeout of the tenant was not applied: %s", users.Timeout)
+	}
+	if len(users.HeadersToPass) != 1 || users.HeadersToPass[0] != "X-Tenant" {
+		t.Errorf("the headers of the tenant were not applied: %v", users.HeadersToPass)
+	}
+	if _, ok := users.ExtraConfig["auth"]; !ok {
+		t.Errorf("the extra config of the tenant was not applied: %v", users.ExtraConfig)
+	}
+
+	orders := cfg.Endpoints[2]
+	if orders.Timeout != 5*time.Second {
+		t.Errorf("the timeout of the endpoint was overridden: %s", orders.Timeout)
+	}
+	if rl, _ := orders.ExtraConfig["ratelimit"].(map[string]interface{}); rl["max_rate"] != 1.0 {
+		t.Errorf("the namespaces of the endpoint were overridden: %v", orders.ExtraConfig)
+	}
+	if _, ok := orders.ExtraConfig["auth"]; !ok {
+		t.Errorf("the extra config of the tenant was not merged: %v", orders.ExtraConfig)
+	}
+
+	globex := cfg.Endpoints[3]
+	if globex.Endpoint != "/globex/users/:id" || len(globex.Hosts) != 0 {
+		t.Errorf("unexpected endpoint: %s %v", globex.Endpoint, globex.Hosts)
+	}
+	if globex.CacheTTL != time.Minute || globex.Timeout != 3*time.Second {
+		t.Errorf("unexpected defaults. cache ttl: %s, timeout: %s", globex.CacheTTL, globex.Timeout)
+	}
+	if globex.Backend[0].URLPattern != "/users/{{.Id}}" {
+		t.Errorf("unexpected url pattern: %s", globex.Backend[0].URLPattern)
+	}
+}
+
+func TestServiceConfig_initTenants_ko(t *testing.T) {
+	for _, tenants := range [][]*Tenant{
+		{{}},
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: "a", PathPrefix: "a"}},
+		{{Name: "a", Hosts: []string{"a.com/b"}}},
+	} {
+		cfg := ServiceConfig{Version: ConfigVersion, Tenants: tenants}
+		if _, ok := cfg.Init().(*InvalidTenantError); !ok {
+			t.Errorf("expecting an invalid tenant error for %+v", tenants)
+		}
+	}
+}
+
+func TestValidate_tenants(t *testing.T) {
+	users := func() *EndpointConfig {
+		return &EndpointConfig{Endpoint: "/users", Backend: []*Backend{{URLPattern: "/users"}}}
+	}
+	cfg := ServiceConfig{
+		Version:   ConfigVersion,
+		Host:      []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{users()},
+		Tenants: []*Tenant{
+			{Name: "a", Hosts: []string{"a.com", "b.com"}, Endpoints: []*EndpointConfig{users()}},
+			{Name: "b", Hosts: []string{"B.com"}, Endpoints: []*EndpointConfig{users()}},
+			{Name: "c", PathPrefix: "/c", Endpoints: []*EndpointConfig{users()}},
+			{Name: "d", PathPrefix: "/c", Endpoints: []*EndpointConfig{users()}},
+			{Name: "d"},
+		},
+	}
+
+	errs := Validate(cfg)
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	expected := []string{
+		"invalid tenant 'd': the name is used by another tenant",
+		"the 'GET b.com/users' endpoint is defined more than once",
+		"the 'GET /c/users' endpoint is defined more than once",
+	}
+	if strings.Join(msgs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected errors:\n%s", strings.Join(msgs, "\n"))
+	}
+	if len(cfg.Endpoints) != 1 {
+		t.Error("the config was modified")
+	}
+}
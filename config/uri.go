@@ -8,8 +8,18 @@ import (
 	"strings"
 )
 
+// The markers of the placeholders declaring an optional segment ({param?}) and a catch-all
+// wildcard ({param...}). Both of them are only allowed in the last segment of the endpoint
+const (
+	OptionalSegmentMarker = "?"
+	CatchAllMarker        = "..."
+)
+
+// UnixSocketScheme is the prefix of the backend hosts pointing to a unix domain socket
+const UnixSocketScheme = "unix://"
+
 var (
-	endpointURLKeysPattern = regexp.MustCompile(`/\{([a-zA-Z\-_0-9]+)\}`)
+	endpointURLKeysPattern = regexp.MustCompile(`/\{([a-zA-Z\-_0-9]+)(?:\?|\.\.\.)?\}`)
 	hostPattern            = regexp.MustCompile(`(https?://)?([a-zA-Z0-9\._\-]+)(:[0-9]{2,6})?/?`)
 )
 
@@ -66,15 +76,27 @@ func (u URI) CleanHosts(hosts []string) []string {
 	return ss
 }
 
-// SafeCleanHost sanitizes the received host
+// SafeCleanHost sanitizes the received host. Hosts using the unix scheme are
+// preserved, since they point to a socket in the local filesystem. The scheme is
+// resolved per host, so a backend can mix http and https hosts
 func (URI) SafeCleanHost(host string) (string, error) {
+	if strings.HasPrefix(host, UnixSocketScheme) {
+		if len(host) == len(UnixSocketScheme) || host[len(UnixSocketScheme)] != '/' {
+			return "", errInvalidHost
+		}
+		return host, nil
+	}
 	matches := hostPattern.FindAllStringSubmatch(host, -1)
 	if len(matches) != 1 {
 		return "", errInvalidHost
 	}
 	keys := matches[0][1:]
 	if keys[0] == "" {
+		// the hosts without scheme use http, unless they point to the default https port
 		keys[0] = "http://"
+		if keys[2] == ":443" {
+			keys[0] = "https://"
+		}
 	}
 	return strings.Join(keys, ""), nil
 }
@@ -94,15 +116,37 @@ func (URI) CleanPath(path string) string {
 	return "/" + strings.TrimPrefix(path, "/")
 }
 
-// GetEndpointPath applies the proper replacement in the received path to generate valid route patterns
+// GetEndpointPath applies the proper replacement in the received path to generate valid route patterns.
+// The colon routers get the optional segments as :param? and the catch-all wildcards as *param
 func (u URI) GetEndpointPath(path string, params []string) string {
-	result := path
-	if u == ColonRouterPatternBuilder {
-		for p := range params {
-			parts := strings.Split(result, "?")
-			parts[0] = strings.ReplaceAll(parts[0], "{"+params[p]+"}", ":"+params[p])
-			result = strings.Join(parts, "?")
+	if u != ColonRouterPatternBuilder {
+		return path
+	}
+	route, query := splitQuery(path)
+	for _, p := range params {
+		route = strings.NewReplacer(
+			"{"+p+"}", ":"+p,
+			"{"+p+OptionalSegmentMarker+"}", ":"+p+OptionalSegmentMarker,
+			"{"+p+CatchAllMarker+"}", "*"+p,
+		).Replace(route)
+	}
+	return route + query
+}
+
+// splitQuery splits the path at the first question mark not enclosed in a placeholder
+func splitQuery(path string) (string, string) {
+	depth := 0
+	for i, r := range path {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '?':
+			if depth == 0 {
+				return path[:i], path[i:]
+			}
 		}
 	}
-	return result
+	return path, ""
 }
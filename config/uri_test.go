@@ -12,6 +12,9 @@ func TestURIParser_cleanHosts(t *testing.T) {
 		"http://127.0.0.1",
 		"supu_42.local:8080/",
 		"http://127.0.0.1:8080",
+		"unix:///var/run/service.sock",
+		"supu.local:443",
+		"http://supu.local:443",
 	}
 
 	expected := []string{
@@ -21,6 +24,9 @@ func TestURIParser_cleanHosts(t *testing.T) {
 		"http://127.0.0.1",
 		"http://supu_42.local:8080",
 		"http://127.0.0.1:8080",
+		"unix:///var/run/service.sock",
+		"https://supu.local:443",
+		"http://supu.local:443",
 	}
 
 	result := NewURIParser().CleanHosts(samples)
@@ -31,6 +37,14 @@ func TestURIParser_cleanHosts(t *testing.T) {
 	}
 }
 
+func TestURIParser_cleanHosts_badUnixSocket(t *testing.T) {
+	for _, h := range []string{"unix://", "unix://relative.sock"} {
+		if _, err := NewSafeURIParser().SafeCleanHost(h); err != errInvalidHost {
+			t.Errorf("%s: unexpected error: %v", h, err)
+		}
+	}
+}
+
 func TestURIParser_cleanPath(t *testing.T) {
 	samples := []string{
 		"supu/{tupu}",
@@ -70,6 +84,8 @@ func TestURIParser_getEndpointPath(t *testing.T) {
 		"/supu/{tupu}",
 		"/supu.local/",
 		"supu/{tupu}/{supu}?a={s}&b=2",
+		"/supu/{tupu}/{supu?}",
+		"/supu/{tupu...}",
 	}
 
 	expected := []string{
@@ -78,6 +94,8 @@ func TestURIParser_getEndpointPath(t *testing.T) {
 		"/supu/:tupu",
 		"/supu.local/",
 		"supu/:tupu/:supu?a={s}&b=2",
+		"/supu/:tupu/:supu?",
+		"/supu/*tupu",
 	}
 
 	sc := ServiceConfig{}
@@ -97,6 +115,8 @@ func TestURIParser_getEndpointPath_notStrictREST(t *testing.T) {
 		"/supu/{tupu}",
 		"/supu.local/",
 		"supu/{tupu}/{supu}?a={s}&b=2",
+		"/supu/{tupu}/{supu?}",
+		"/supu/{tupu...}",
 	}
 
 	expected := []string{
@@ -105,6 +125,8 @@ func TestURIParser_getEndpointPath_notStrictREST(t *testing.T) {
 		"/supu/:tupu",
 		"/supu.local/",
 		"supu/:tupu/:supu?a={s}&b=2",
+		"/supu/:tupu/:supu?",
+		"/supu/*tupu",
 	}
 
 	sc := ServiceConfig{DisableStrictREST: true}
@@ -0,0 +1,289 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/luraproject/lura/v2/encoding"
+)
+
+// Validate checks the received configuration and returns all the problems found, instead of
+// stopping at the first one like the init process does. The configuration is not modified, so
+// it can be called before the Init method.
+func Validate(cfg ServiceConfig) []error {
+	errs := []error{}
+	if cfg.Version != ConfigVersion {
+		errs = append(errs, &UnsupportedVersionError{Have: cfg.Version, Want: ConfigVersion})
+	}
+	if cfg.Address != "" && !validateAddress(cfg.Address) {
+		errs = append(errs, fmt.Errorf("invalid ip address %s", cfg.Address))
+	}
+
+	for _, d := range []struct {
+		field string
+		value time.Duration
+	}{
+		{"timeout", cfg.Timeout},
+		{"cache_ttl", cfg.CacheTTL},
+		{"read_timeout", cfg.ReadTimeout},
+		{"write_timeout", cfg.WriteTimeout},
+		{"idle_timeout", cfg.IdleTimeout},
+		{"read_header_timeout", cfg.ReadHeaderTimeout},
+	} {
+		if d.value < 0 {
+			errs = append(errs, &InvalidDurationError{Field: d.field, Value: d.value})
+		}
+	}
+
+	uriParser := NewSafeURIParser()
+	if _, err := uriParser.SafeCleanHosts(cfg.Host); err != nil {
+		errs = append(errs, err)
+	}
+
+	pattern := endpointURLKeysPattern
+	if cfg.DisableStrictREST {
+		pattern = simpleURLKeysPattern
+	}
+
+	errs = append(errs, validateTenants(cfg.Tenants)...)
+	// the endpoints of the tenants are validated like the ones of the service
+	cfg.Endpoints = cfg.tenantEndpoints()
+
+	seen := map[string]*EndpointConfig{}
+	shapes := map[string]string{}
+	for _, e := range cfg.Endpoints {
+		path := uriParser.CleanPath(e.Endpoint)
+		method := strings.ToUpper(e.Method)
+		if method == "" {
+			method = http.MethodGet
+		}
+		errs = append(errs, validateEndpoint(e, path, method, pattern)...)
+
+		paths := []string{path}
+		for _, a := range e.Aliases {
+			paths = append(paths, uriParser.CleanPath(a.Path))
+		}
+		for _, p := range paths {
+			if cfg.isReservedPath(p) {
+				errs = append(errs, &EndpointPathError{Path: p, Method: method})
+			}
+		}
+		// the endpoints served to different hosts can share their paths
+		hosts := normalizeHosts(e.Hosts)
+		if len(hosts) == 0 {
+			hosts = []string{""}
+		}
+		for _, h := range hosts {
+			for _, p := range paths {
+				route := h + p
+				key := method + " " + route
+				if _, ok := seen[key]; ok {
+					errs = append(errs, &DuplicatedEndpointError{Path: route, Method: method})
+					continue
+				}
+				seen[key] = e
+
+				shape := method + " " + h + simpleURLKeysPattern.ReplaceAllString(p, "{}")
+				if other, ok := shapes[shape]; ok {
+					errs = append(errs, &ConflictingEndpointsError{
+						Method: method,
+						Path:   route,
+						Other:  other,
+					})
+					continue
+				}
+				shapes[shape] = route
+			}
+		}
+	}
+	return append(errs, validateNamespaces(cfg)...)
+}
+
+func validateEndpoint(e *EndpointConfig, path, method string, pattern *regexp.Regexp) []error {
+	errs := []error{}
+	if matched, _ := regexp.MatchString(invalidPattern, path); matched {
+		errs = append(errs, &EndpointPathError{Path: path, Method: method})
+	}
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		errs = append(errs, &UnsupportedMethodError{Path: path, Method: method})
+	}
+	if e.Timeout < 0 {
+		errs = append(errs, &InvalidDurationError{Field: "timeout", Value: e.Timeout, Path: path, Method: method})
+	}
+	if e.CacheTTL < 0 {
+		errs = append(errs, &InvalidDurationError{Field: "cache_ttl", Value: e.CacheTTL, Path: path, Method: method})
+	}
+	if len(e.Backend) == 0 && !e.IsBackendless() {
+		errs = append(errs, &NoBackendsError{Path: path, Method: method})
+	}
+	if e.OutputEncoding == encoding.NOOP && len(e.Backend) > 1 {
+		errs = append(errs, fmt.Errorf("'%s %s': %w", method, path, errInvalidNoOpEncoding))
+	}
+
+	inputSet := map[string]interface{}{}
+	for _, m := range pattern.FindAllStringSubmatch(path, -1) {
+		inputSet[m[1]] = nil
+	}
+	addExtractedParams(e.ExtraConfig, inputSet)
+	input := fromSetToSortedSlice(inputSet)
+
+	uriParser := NewSafeURIParser()
+	for i, b := range e.Backend {
+		if !b.HostSanitizationDisabled {
+			if _, err := uriParser.SafeCleanHosts(b.Host); err != nil {
+				errs = append(errs, fmt.Errorf("'%s %s' backend %d: %w", method, path, i, err))
+			}
+		}
+		if !isValidURLPattern(b.URLPattern) {
+			errs = append(errs, &InvalidURLPatternError{Endpoint: path, Method: method, Backend: i, URLPattern: b.URLPattern})
+			continue
+		}
+
+		matches := simpleURLKeysPattern.FindAllStringSubmatch(b.URLPattern, -1)
+		keys := make([]string, len(matches))
+		for k, m := range matches {
+			keys[k] = m[1]
+		}
+		output, outputSetSize := uniqueOutput(keys)
+		if outputSetSize > len(input) {
+			errs = append(errs, &WrongNumberOfParamsError{
+				Endpoint:     path,
+				Method:       method,
+				Backend:      i,
+				InputParams:  input,
+				OutputParams: output,
+			})
+			continue
+		}
+		for _, o := range output {
+			if sequentialParamsPattern.MatchString(o) {
+				continue
+			}
+			if _, ok := inputSet[o]; !ok {
+				errs = append(errs, &UndefinedOutputParamError{
+					Param:        o,
+					Endpoint:     path,
+					Method:       method,
+					Backend:      i,
+					InputParams:  input,
+					OutputParams: output,
+				})
+			}
+		}
+	}
+	return errs
+}
+
+func isValidURLPattern(p string) bool {
+	if strings.ContainsAny(p, " \t\r\n") {
+		return false
+	}
+	depth := 0
+	for _, r := range p {
+		switch r {
+		case '{':
+			depth++
+			if depth > 1 {
+				return false
+			}
+		case '}':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// ValidationError aggregates all the problems found by the Validate function
+type ValidationError struct {
+	Errors []error
+}
+
+// Error returns a string representation of the ValidationError
+func (v *ValidationError) Error() string {
+	if len(v.Errors) == 1 {
+		return v.Errors[0].Error()
+	}
+	msgs := make([]string, len(v.Errors))
+	for i, err := range v.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors found: %s", len(v.Errors), strings.Join(msgs, "; "))
+}
+
+// DuplicatedEndpointError is the error returned by the validation process when the same
+// endpoint is defined more than once
+type DuplicatedEndpointError struct {
+	Path   string
+	Method string
+}
+
+// Error returns a string representation of the DuplicatedEndpointError
+func (d *DuplicatedEndpointError) Error() string {
+	return "the '" + d.Method + " " + d.Path + "' endpoint is defined more than once"
+}
+
+// ConflictingEndpointsError is the error returned by the validation process when two endpoints
+// only differ in the names of their placeholders, so the router can not tell them apart
+type ConflictingEndpointsError struct {
+	Method string
+	Path   string
+	Other  string
+}
+
+// Error returns a string representation of the ConflictingEndpointsError
+func (c *ConflictingEndpointsError) Error() string {
+	return fmt.Sprintf("the '%s %s' endpoint conflicts with '%s %s'", c.Method, c.Path, c.Method, c.Other)
+}
+
+// UnsupportedMethodError is the error returned by the validation process when an endpoint
+// uses a method not supported by the routers
+type UnsupportedMethodError struct {
+	Path   string
+	Method string
+}
+
+// Error returns a string representation of the UnsupportedMethodError
+func (u *UnsupportedMethodError) Error() string {
+	return "unsupported method in the '" + u.Method + " " + u.Path + "' endpoint"
+}
+
+// InvalidDurationError is the error returned by the validation process when a timeout or ttl
+// has an invalid value. Path and Method are empty for the service level durations
+type InvalidDurationError struct {
+	Field  string
+	Value  time.Duration
+	Path   string
+	Method string
+}
+
+// Error returns a string representation of the InvalidDurationError
+func (i *InvalidDurationError) Error() string {
+	if i.Path == "" {
+		return fmt.Sprintf("invalid %s: %s", i.Field, i.Value)
+	}
+	return fmt.Sprintf("invalid %s in the '%s %s' endpoint: %s", i.Field, i.Method, i.Path, i.Value)
+}
+
+// InvalidURLPatternError is the error returned by the validation process when the url pattern
+// of a backend is malformed
+type InvalidURLPatternError struct {
+	Endpoint   string
+	Method     string
+	Backend    int
+	URLPattern string
+}
+
+// Error returns a string representation of the InvalidURLPatternError
+func (i *InvalidURLPatternError) Error() string {
+	return fmt.Sprintf("invalid url pattern '%s'. endpoint: %s %s, backend: %d", i.URLPattern, i.Method, i.Endpoint, i.Backend)
+}
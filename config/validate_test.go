@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate_ok(t *testing.T) {
+	cfg := ServiceConfig{
+		Version: ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{
+			{
+				Endpoint: "/users/{id}",
+				Backend:  []*Backend{{URLPattern: "/users/{id}"}},
+			},
+			{
+				Endpoint: "/users/{id}",
+				Method:   "POST",
+				Backend:  []*Backend{{URLPattern: "/users/{id}/{resp0_name}"}},
+			},
+		},
+	}
+	if errs := Validate(cfg); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+func TestValidate_ko(t *testing.T) {
+	cfg := ServiceConfig{
+		Version: 2,
+		Timeout: -time.Second,
+		Endpoints: []*EndpointConfig{
+			{
+				Endpoint: "/users/{id}",
+				Backend:  []*Backend{{URLPattern: "/users/{id}"}},
+			},
+			{
+				Endpoint: "users/{id}",
+				Method:   "get",
+				Backend:  []*Backend{{URLPattern: "/users/{id}"}},
+			},
+			{
+				Endpoint: "/users/{name}",
+				Backend:  []*Backend{{URLPattern: "/users/{name}"}},
+			},
+			{
+				Endpoint: "/items/{id}",
+				Method:   "OPTIONS",
+				Timeout:  -time.Second,
+				Backend: []*Backend{
+					{URLPattern: "/items/{id"},
+					{URLPattern: "/items/{item}"},
+					{URLPattern: "/items/{id}/{a}/{b}"},
+					{Host: []string{"unix://relative.sock"}, URLPattern: "/"},
+				},
+			},
+			{
+				Endpoint: "/__debug/",
+			},
+		},
+	}
+
+	errs := Validate(cfg)
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	expected := []string{
+		"unsupported version: 2 (want: 3)",
+		"invalid timeout: -1s",
+		"the 'GET /users/{id}' endpoint is defined more than once",
+		"the 'GET /users/{name}' endpoint conflicts with 'GET /users/{id}'",
+		"unsupported method in the 'OPTIONS /items/{id}' endpoint",
+		"invalid timeout in the 'OPTIONS /items/{id}' endpoint: -1s",
+		"invalid url pattern '/items/{id'. endpoint: OPTIONS /items/{id}, backend: 0",
+		"undefined output param 'item'! endpoint: OPTIONS /items/{id}, backend: 1. input: [id], output: [item]",
+		"input and output params do not match. endpoint: OPTIONS /items/{id}, backend: 2. input: [id], output: [a b id]",
+		"'OPTIONS /items/{id}' backend 3: host unix://relative.sock not valid: invalid host",
+		"ignoring the 'GET /__debug/' endpoint, since it is invalid!!!",
+		"ignoring the 'GET /__debug/' endpoint, since it has 0 backends defined!",
+	}
+	if len(msgs) != len(expected) {
+		t.Errorf("unexpected number of errors: %d\n%s", len(msgs), strings.Join(msgs, "\n"))
+		return
+	}
+	for i := range expected {
+		if msgs[i] != expected[i] {
+			t.Errorf("unexpected error #%d. have: %s, want: %s", i, msgs[i], expected[i])
+		}
+	}
+}
+
+func TestValidate_duplicatedAlias(t *testing.T) {
+	cfg := ServiceConfig{
+		Version: ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{
+			{
+				Endpoint: "/users",
+				Backend:  []*Backend{{URLPattern: "/users"}},
+			},
+			{
+				Endpoint: "/v2/users",
+				Aliases:  []EndpointAlias{{Path: "/users"}},
+				Backend:  []*Backend{{URLPattern: "/users"}},
+			},
+		},
+	}
+	errs := Validate(cfg)
+	if len(errs) != 1 {
+		t.Errorf("unexpected errors: %v", errs)
+		return
+	}
+	if _, ok := errs[0].(*DuplicatedEndpointError); !ok {
+		t.Errorf("unexpected error: %v", errs[0])
+	}
+}
+
+func TestNewParser_validationErrors(t *testing.T) {
+	configPath := "/tmp/validation.json"
+	configContent := []byte(`{
+    "version": 3,
+    "timeout": "-3s",
+    "endpoints": [
+        {"endpoint": "/a", "backend": [{"host": ["http://127.0.0.1:8080"], "url_pattern": "/"}]},
+        {"endpoint": "/a", "backend": [{"host": ["http://127.0.0.1:8080"], "url_pattern": "/"}]}
+    ]
+}`)
+	if err := os.WriteFile(configPath, configContent, 0644); err != nil {
+		t.FailNow()
+	}
+	defer os.Remove(configPath)
+
+	_, err := NewParser().Parse(configPath)
+	if err == nil {
+		t.Error("error expected")
+		return
+	}
+	expected := "'/tmp/validation.json': 2 errors found: invalid timeout: -3s; the 'GET /a' endpoint is defined more than once"
+	if err.Error() != expected {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package consistency routes the reads of the clients that just wrote to the primary hosts of the
+backends, so they never read stale data from a lagging replica.
+
+The backends served by replicas declare the hosts of their primary:
+
+	"extra_config": {
+		"github.com/luraproject/lura/consistency": {
+			"primary_hosts": ["http://orders-primary:8080"]
+		}
+	}
+
+Their mutating requests are always sent to the primary hosts and their successful responses carry
+a routing hint, a token valid for the consistency window. The requests presenting a valid hint,
+in the header or in the cookie, are sent to the primary hosts of every backend declaring them,
+and the rest are balanced over the regular hosts as usual. The service can declare the names of
+the header and the cookie, the window and a secret to sign the tokens, so the clients can not
+forge them:
+
+	"extra_config": {
+		"github.com/luraproject/lura/consistency": {
+			"header": "X-Consistency-Token",
+			"cookie": "consistency",
+			"window": "5s",
+			"secret": "s3cr3t"
+		}
+	}
+
+The hints are only added to the responses of the endpoints with a single backend, as the merged
+responses do not keep the headers of the backends.
+*/
+package consistency
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the consistency config
+const Namespace = "github.com/luraproject/lura/consistency"
+
+const (
+	// DefaultHeader is the name of the hint header when the config does not declare one
+	DefaultHeader = "X-Consistency-Token"
+	// DefaultWindow is the validity of the hints when the config does not declare it
+	DefaultWindow = 5 * time.Second
+)
+
+// ContextKey is the string key of the hint flag in the contexts that only support string keys,
+// like the gin ones
+const ContextKey = "github.com/luraproject/lura/consistency.primary"
+
+// Config is the consistency config of the service
+type Config struct {
+	Header string `json:"header"`
+	Cookie string `json:"cookie"`
+	Window string `json:"window"`
+	Secret string `json:"secret"`
+}
+
+// ConfigGetter parses the consistency config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("consistency: parsing the config: %w", err)
+	}
+	return cfg, true, nil
+}
+
+// BackendConfig is the consistency config of a backend
+type BackendConfig struct {
+	PrimaryHosts []string `json:"primary_hosts"`
+}
+
+// BackendConfigGetter parses the consistency config from the backend extra config. It returns
+// false if the backend does not declare primary hosts
+func BackendConfigGetter(e config.ExtraConfig) (BackendConfig, bool) {
+	var cfg BackendConfig
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false
+	}
+	hosts, _ := tmp["primary_hosts"].([]interface{})
+	for _, h := range hosts {
+		if s, ok := h.(string); ok && s != "" {
+			cfg.PrimaryHosts = append(cfg.PrimaryHosts, s)
+		}
+	}
+	return cfg, len(cfg.PrimaryHosts) > 0
+}
+
+// Hinter issues and validates the routing hints
+type Hinter struct {
+	header string
+	cookie string
+	window time.Duration
+	secret []byte
+	now    func() time.Time
+}
+
+// New returns a Hinter with the config
+func New(cfg Config) (*Hinter, error) {
+	h := &Hinter{
+		header: cfg.Header,
+		cookie: cfg.Cookie,
+		window: DefaultWindow,
+		now:    time.Now,
+	}
+	if h.header == "" {
+		h.header = DefaultHeader
+	}
+	if cfg.Window != "" {
+		d, err := time.ParseDuration(cfg.Window)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("consistency: invalid window %s", cfg.Window)
+		}
+		h.window = d
+	}
+	if cfg.Secret != "" {
+		h.secret = []byte(cfg.Secret)
+	}
+	return h, nil
+}
+
+// Header returns the name of the hint header
+func (h *Hinter) Header() string { return h.header }
+
+// Token returns a new hint, valid for the consistency window
+func (h *Hinter) Token() string {
+	expiry := strconv.FormatInt(h.now().Add(h.window).UnixNano()/int64(time.Millisecond), 10)
+	if h.secret == nil {
+		return expiry
+	}
+	return expiry + "." + h.sign(expiry)
+}
+
+// Valid returns true if the token is a hint issued by the hinter and not expired yet
+func (h *Hinter) Valid(token string) bool {
+	expiry, sig := token, ""
+	if i := strings.IndexByte(token, '.'); i >= 0 {
+		expiry, sig = token[:i], token[i+1:]
+	}
+	if h.secret != nil && !hmac.Equal([]byte(sig), []byte(h.sign(expiry))) {
+		return false
+	}
+	ms, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return false
+	}
+	return h.now().UnixNano()/int64(time.Millisecond) < ms
+}
+
+// Hinted returns true if the request carries a valid hint in its header or its cookie
+func (h *Hinter) Hinted(r *http.Request) bool {
+	if v := r.Header.Get(h.header); v != "" && h.Valid(v) {
+		return true
+	}
+	if h.cookie == "" {
+		return false
+	}
+	c, err := r.Cookie(h.cookie)
+	return err == nil && h.Valid(c.Value)
+}
+
+// SetHint adds a new hint to the headers of a response
+func (h *Hinter) SetHint(headers map[string][]string) {
+	token := h.Token()
+	headers[http.CanonicalHeaderKey(h.header)] = []string{token}
+	if h.cookie == "" {
+		return
+	}
+	c := &http.Cookie{
+		Name:     h.cookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int((h.window + time.Second - 1) / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	headers["Set-Cookie"] = append(headers["Set-Cookie"], c.String())
+}
+
+func (h *Hinter) sign(expiry string) string {
+	m := hmac.New(sha256.New, h.secret)
+	m.Write([]byte(expiry))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+type primaryKey struct{}
+
+// WithPrimary returns a copy of the context flagging its requests to be sent to the primary hosts
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// RequiresPrimary returns true if the requests of the context must be sent to the primary hosts
+func RequiresPrimary(ctx context.Context) bool {
+	if v, ok := ctx.Value(primaryKey{}).(bool); ok {
+		return v
+	}
+	v, _ := ctx.Value(ContextKey).(bool)
+	return v
+}
+
+// UsesPrimaries returns true if any backend of the endpoint declares primary hosts
+func UsesPrimaries(cfg *config.EndpointConfig) bool {
+	for _, b := range cfg.Backend {
+		if _, ok := BackendConfigGetter(b.ExtraConfig); ok {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	global   *Hinter
+	globalMu sync.RWMutex
+)
+
+// Register creates the hinter declared in the service extra config. The services not declaring
+// one get a hinter with the default config. It returns false if the service does not declare
+// the consistency config
+func Register(cfg config.ServiceConfig) (bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if err != nil {
+		SetGlobal(nil)
+		return ok, err
+	}
+	h, err := New(c)
+	if err != nil {
+		SetGlobal(nil)
+		return ok, err
+	}
+	SetGlobal(h)
+	return ok, nil
+}
+
+// SetGlobal sets the hinter used by the router and the proxies
+func SetGlobal(h *Hinter) {
+	globalMu.Lock()
+	global = h
+	globalMu.Unlock()
+}
+
+// GetGlobal returns the hinter used by the router and the proxies. It returns a hinter with the
+// default config when none has been registered
+func GetGlobal() *Hinter {
+	globalMu.RLock()
+	h := global
+	globalMu.RUnlock()
+	if h != nil {
+		return h
+	}
+	h, _ = New(Config{})
+	return h
+}
@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package consistency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestHinter(t *testing.T) {
+	h, err := New(Config{Cookie: "consistency", Window: "2s", Secret: "s3cr3t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+
+	token := h.Token()
+	if !h.Valid(token) {
+		t.Errorf("the token %s should be valid", token)
+	}
+	if h.Valid(strings.Split(token, ".")[0]) {
+		t.Error("the unsigned tokens should not be valid")
+	}
+	if h.Valid("99999999999999." + strings.Split(token, ".")[1]) {
+		t.Error("the tokens with a forged expiry should not be valid")
+	}
+
+	headers := map[string][]string{}
+	h.SetHint(headers)
+	if headers[DefaultHeader][0] != token {
+		t.Errorf("unexpected hint header: %v", headers)
+	}
+	if c := headers["Set-Cookie"]; len(c) != 1 || !strings.HasPrefix(c[0], "consistency="+token+"; Path=/; Max-Age=2; HttpOnly") {
+		t.Errorf("unexpected cookie: %v", c)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if h.Hinted(r) {
+		t.Error("the request carries no hint")
+	}
+	r.AddCookie(&http.Cookie{Name: "consistency", Value: token})
+	if !h.Hinted(r) {
+		t.Error("the request carries a hint in its cookie")
+	}
+
+	now = now.Add(2 * time.Second)
+	if h.Valid(token) || h.Hinted(r) {
+		t.Error("the hint should be expired")
+	}
+}
+
+func TestHinter_unsigned(t *testing.T) {
+	h, err := New(Config{Header: "X-Written"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Written", h.Token())
+	if !h.Hinted(r) {
+		t.Error("the request carries a hint in its header")
+	}
+	if _, err := New(Config{Window: "never"}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestBackendConfigGetter(t *testing.T) {
+	cfg, ok := BackendConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"primary_hosts": []interface{}{"http://primary:8080"},
+	}})
+	if !ok || len(cfg.PrimaryHosts) != 1 || cfg.PrimaryHosts[0] != "http://primary:8080" {
+		t.Errorf("unexpected config: %v %v", cfg, ok)
+	}
+	if _, ok := BackendConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{}}); ok {
+		t.Error("the backend does not declare primary hosts")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer SetGlobal(nil)
+	ok, err := Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"header": "X-Written",
+	}}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if h := GetGlobal().Header(); h != "X-Written" {
+		t.Errorf("unexpected header: %s", h)
+	}
+
+	if ok, err := Register(config.ServiceConfig{}); ok || err != nil {
+		t.Errorf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if h := GetGlobal().Header(); h != DefaultHeader {
+		t.Errorf("unexpected header: %s", h)
+	}
+}
+
+func TestRequiresPrimary(t *testing.T) {
+	if RequiresPrimary(context.Background()) {
+		t.Error("the context is not flagged")
+	}
+	if !RequiresPrimary(WithPrimary(context.Background())) {
+		t.Error("the context is flagged")
+	}
+	if !RequiresPrimary(context.WithValue(context.Background(), ContextKey, true)) {
+		t.Error("the context is flagged with the string key")
+	}
+}
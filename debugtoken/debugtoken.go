@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package debugtoken lets the operators change how a single request is served, so the production
+issues can be reproduced without touching the global config.
+
+The requests carrying a valid debug token can force the sampling of their trace, bypass the
+endpoint caches and send their backend requests to a specific host. The tokens are signed with
+the secret of the service and expire shortly, so they can be shared in a ticket without turning
+into a permanent backdoor:
+
+	"extra_config": {
+		"github.com/luraproject/lura/debugtoken": {
+			"secret": "s3cr3t",
+			"header": "X-Debug-Token",
+			"max_ttl": "15m"
+		}
+	}
+
+A token is the base64url encoded JSON of its claims and its HMAC-SHA256 signature, separated by
+a dot:
+
+	{"exp": 1700000000, "trace": true, "no_cache": true, "host": "http://users-2:8080", "endpoint": "/users/{id}"}
+
+The tokens expiring later than max_ttl from now are rejected, so the issuers can not mint long
+lived ones. The host must be one of the hosts declared by the backend, and the endpoint, if set,
+restricts the token to the endpoint with that pattern.
+*/
+package debugtoken
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the debug token config
+const Namespace = "github.com/luraproject/lura/debugtoken"
+
+const (
+	// DefaultHeader is the name of the token header when the config does not declare one
+	DefaultHeader = "X-Debug-Token"
+	// DefaultMaxTTL is the longest validity accepted when the config does not declare it
+	DefaultMaxTTL = 15 * time.Minute
+)
+
+// ContextKey is the string key of the overrides in the contexts that only support string keys,
+// like the gin ones
+const ContextKey = "github.com/luraproject/lura/debugtoken.overrides"
+
+var (
+	// ErrMissingSecret is returned when the config does not declare the secret signing the tokens
+	ErrMissingSecret = errors.New("debugtoken: the secret is required")
+	// ErrInvalidToken is returned when the token is malformed or its signature does not match
+	ErrInvalidToken = errors.New("debugtoken: invalid token")
+	// ErrExpiredToken is returned when the token is expired or expires too late
+	ErrExpiredToken = errors.New("debugtoken: expired token")
+)
+
+// Config is the debug token config of the service
+type Config struct {
+	Secret string `json:"secret"`
+	Header string `json:"header"`
+	MaxTTL string `json:"max_ttl"`
+}
+
+// ConfigGetter parses the debug token config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("debugtoken: parsing the config: %w", err)
+	}
+	return cfg, true, nil
+}
+
+// Claims are the overrides granted by a token
+type Claims struct {
+	Expiry   int64  `json:"exp"`
+	Trace    bool   `json:"trace,omitempty"`
+	NoCache  bool   `json:"no_cache,omitempty"`
+	Host     string `json:"host,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Verifier issues and validates the debug tokens
+type Verifier struct {
+	header string
+	maxTTL time.Duration
+	secret []byte
+	now    func() time.Time
+}
+
+// New returns a Verifier with the config
+func New(cfg Config) (*Verifier, error) {
+	if cfg.Secret == "" {
+		return nil, ErrMissingSecret
+	}
+	v := &Verifier{
+		header: cfg.Header,
+		maxTTL: DefaultMaxTTL,
+		secret: []byte(cfg.Secret),
+		now:    time.Now,
+	}
+	if v.header == "" {
+		v.header = DefaultHeader
+	}
+	if cfg.MaxTTL != "" {
+		d, err := time.ParseDuration(cfg.MaxTTL)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("debugtoken: invalid max ttl %s", cfg.MaxTTL)
+		}
+		v.maxTTL = d
+	}
+	return v, nil
+}
+
+// Header returns the name of the token header
+func (v *Verifier) Header() string { return v.header }
+
+// Issue returns a token granting the claims for the ttl. The ttl is capped to the max ttl
+func (v *Verifier) Issue(c Claims, ttl time.Duration) string {
+	if ttl <= 0 || ttl > v.maxTTL {
+		ttl = v.maxTTL
+	}
+	c.Expiry = v.now().Add(ttl).Unix()
+	b, _ := json.Marshal(c)
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + v.sign(payload)
+}
+
+// Verify returns the claims of the token if its signature is valid and it is not expired
+func (v *Verifier) Verify(token string) (Claims, error) {
+	var c Claims
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return c, ErrInvalidToken
+	}
+	payload, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(v.sign(payload))) {
+		return c, ErrInvalidToken
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(b, &c) != nil {
+		return c, ErrInvalidToken
+	}
+	now := v.now()
+	// a second of slack for the rounding of the expiration
+	if now.Unix() >= c.Expiry || time.Unix(c.Expiry, 0).Sub(now) > v.maxTTL+time.Second {
+		return c, ErrExpiredToken
+	}
+	return c, nil
+}
+
+// FromRequest returns the claims of the token carried by the request, if any. The tokens
+// restricted to other endpoints are ignored
+func (v *Verifier) FromRequest(r *http.Request, endpoint string) (Claims, bool, error) {
+	token := r.Header.Get(v.header)
+	if token == "" {
+		return Claims{}, false, nil
+	}
+	c, err := v.Verify(token)
+	if err != nil {
+		return c, false, err
+	}
+	if c.Endpoint != "" && c.Endpoint != endpoint {
+		return c, false, nil
+	}
+	return c, true, nil
+}
+
+func (v *Verifier) sign(payload string) string {
+	m := hmac.New(sha256.New, v.secret)
+	m.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+type overridesKey struct{}
+
+// NewContext returns a copy of the context carrying the claims of the request
+func NewContext(ctx context.Context, c Claims) context.Context {
+	return context.WithValue(ctx, overridesKey{}, c)
+}
+
+// FromContext returns the claims of the request, if any
+func FromContext(ctx context.Context) (Claims, bool) {
+	if c, ok := ctx.Value(overridesKey{}).(Claims); ok {
+		return c, true
+	}
+	c, ok := ctx.Value(ContextKey).(Claims)
+	return c, ok
+}
+
+// BypassCache returns true if the request must skip the caches
+func BypassCache(ctx context.Context) bool {
+	c, ok := FromContext(ctx)
+	return ok && c.NoCache
+}
+
+// TargetHost returns the backend host requested by the token of the request, if any
+func TargetHost(ctx context.Context) (string, bool) {
+	c, ok := FromContext(ctx)
+	return c.Host, ok && c.Host != ""
+}
+
+var (
+	global   *Verifier
+	globalMu sync.RWMutex
+)
+
+// Register creates the verifier declared in the service extra config. It returns false if the
+// service does not declare the debug token config. The debug tokens are ignored unless a
+// verifier is registered
+func Register(cfg config.ServiceConfig) (bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok || err != nil {
+		SetGlobal(nil)
+		return ok, err
+	}
+	v, err := New(c)
+	if err != nil {
+		SetGlobal(nil)
+		return ok, err
+	}
+	SetGlobal(v)
+	return ok, nil
+}
+
+// SetGlobal sets the verifier used by the router and the proxies
+func SetGlobal(v *Verifier) {
+	globalMu.Lock()
+	global = v
+	globalMu.Unlock()
+}
+
+// GetGlobal returns the verifier used by the router and the proxies, if any
+func GetGlobal() (*Verifier, bool) {
+	globalMu.RLock()
+	v := global
+	globalMu.RUnlock()
+	return v, v != nil
+}
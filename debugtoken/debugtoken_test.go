@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package debugtoken
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestVerifier(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	v, err := New(Config{Secret: "s3cr3t", MaxTTL: "10m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return now }
+
+	token := v.Issue(Claims{Trace: true, Host: "http://users-2"}, time.Hour)
+	c, err := v.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Trace || c.NoCache || c.Host != "http://users-2" || c.Expiry != now.Add(10*time.Minute).Unix() {
+		t.Errorf("unexpected claims %+v", c)
+	}
+
+	other, _ := New(Config{Secret: "other"})
+	if _, err := other.Verify(token); err != ErrInvalidToken {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := v.Verify(strings.Replace(token, ".", "x.", 1)); err != ErrInvalidToken {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := v.Verify("garbage"); err != ErrInvalidToken {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	v.now = func() time.Time { return now.Add(11 * time.Minute) }
+	if _, err := v.Verify(token); err != ErrExpiredToken {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// a verifier with a longer max ttl issues tokens rejected by the stricter one
+	lax, _ := New(Config{Secret: "s3cr3t", MaxTTL: "24h"})
+	lax.now = func() time.Time { return now }
+	v.now = lax.now
+	if _, err := v.Verify(lax.Issue(Claims{}, 2*time.Hour)); err != ErrExpiredToken {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestVerifier_FromRequest(t *testing.T) {
+	v, _ := New(Config{Secret: "s3cr3t", Header: "X-Debug"})
+	req, _ := http.NewRequest("GET", "/", nil)
+	if _, ok, err := v.FromRequest(req, "/users"); ok || err != nil {
+		t.Error("the requests without a token must not get overrides")
+	}
+
+	req.Header.Set("X-Debug", v.Issue(Claims{NoCache: true, Endpoint: "/users"}, time.Minute))
+	if c, ok, err := v.FromRequest(req, "/users"); !ok || err != nil || !c.NoCache {
+		t.Errorf("unexpected claims %+v: %v", c, err)
+	}
+	if _, ok, _ := v.FromRequest(req, "/orders"); ok {
+		t.Error("the token is restricted to another endpoint")
+	}
+
+	req.Header.Set("X-Debug", "invalid")
+	if _, ok, err := v.FromRequest(req, "/users"); ok || err != ErrInvalidToken {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	ctx := NewContext(context.Background(), Claims{NoCache: true, Host: "http://a"})
+	if !BypassCache(ctx) {
+		t.Error("the cache should be bypassed")
+	}
+	if h, ok := TargetHost(ctx); !ok || h != "http://a" {
+		t.Errorf("unexpected host %s", h)
+	}
+	ctx = context.WithValue(context.Background(), ContextKey, Claims{})
+	if _, ok := FromContext(ctx); !ok || BypassCache(ctx) {
+		t.Error("unexpected claims in the string keyed context")
+	}
+	if _, ok := TargetHost(context.Background()); ok {
+		t.Error("unexpected host")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer SetGlobal(nil)
+
+	if ok, err := Register(config.ServiceConfig{}); ok || err != nil {
+		t.Errorf("unexpected result %v %v", ok, err)
+	}
+	if _, ok := GetGlobal(); ok {
+		t.Error("the services not declaring the config must not accept debug tokens")
+	}
+
+	if ok, err := Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{"header": "X-Debug"},
+	}}); !ok || err != ErrMissingSecret {
+		t.Errorf("unexpected result %v %v", ok, err)
+	}
+
+	if ok, err := Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{"secret": "s3cr3t", "max_ttl": "5m"},
+	}}); !ok || err != nil {
+		t.Errorf("unexpected result %v %v", ok, err)
+	}
+	if v, ok := GetGlobal(); !ok || v.Header() != DefaultHeader || v.maxTTL != 5*time.Minute {
+		t.Error("unexpected verifier")
+	}
+}
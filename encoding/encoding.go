@@ -9,11 +9,14 @@ Decode decodes HTTP responses:
 	...
 	var data map[string]interface{}
 	err := JSONDecoder(resp.Body, &data)
+
+The json decoders and renders use the json engine selected in the service extra config, the one of
+the standard library by default. The engines of json-iterator, go-json and sonic are registered
+when building with the jsoniter, go_json and sonic tags, as gin does.
 */
 package encoding
 
 import (
-	"encoding/json"
 	"io"
 )
 
@@ -45,17 +48,13 @@ func NewJSONDecoder(isCollection bool) func(io.Reader, *map[string]interface{})
 
 // JSONDecoder decodes a json message into a map
 func JSONDecoder(r io.Reader, v *map[string]interface{}) error {
-	d := json.NewDecoder(r)
-	d.UseNumber()
-	return d.Decode(v)
+	return newJSONDecoder(r).Decode(v)
 }
 
 // JSONCollectionDecoder decodes a json collection and returns a map with the array at the 'collection' key
 func JSONCollectionDecoder(r io.Reader, v *map[string]interface{}) error {
 	var collection []interface{}
-	d := json.NewDecoder(r)
-	d.UseNumber()
-	if err := d.Decode(&collection); err != nil {
+	if err := newJSONDecoder(r).Decode(&collection); err != nil {
 		return err
 	}
 	*(v) = map[string]interface{}{"collection": collection}
@@ -72,10 +71,8 @@ func NewSafeJSONDecoder(_ bool) func(io.Reader, *map[string]interface{}) error {
 
 // SafeJSONDecoder decodes both json objects and collections
 func SafeJSONDecoder(r io.Reader, v *map[string]interface{}) error {
-	d := json.NewDecoder(r)
-	d.UseNumber()
 	var t interface{}
-	if err := d.Decode(&t); err != nil {
+	if err := newJSONDecoder(r).Decode(&t); err != nil {
 		return err
 	}
 	switch tt := t.(type) {
@@ -33,7 +33,7 @@ func TestRegister(t *testing.T) {
 
 	original := GetRegister()
 
-	if len(original.data.Clone()) != 4 {
+	if len(original.data.Clone()) != 5 {
 		t.Error("Unexpected number of registered factories:", len(original.data.Clone()))
 	}
 
@@ -49,7 +49,7 @@ func TestGet(t *testing.T) {
 	decoders = initDecoderRegister()
 	defer func() { decoders = initDecoderRegister() }()
 
-	if len(decoders.data.Clone()) != 4 {
+	if len(decoders.data.Clone()) != 5 {
 		t.Error("Unexpected number of registered factories:", len(decoders.data.Clone()))
 	}
 
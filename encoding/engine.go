@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// JSONEngineNamespace is the key of the json engine in the service extra config:
+//
+//	"github.com/luraproject/lura/encoding/json": {
+//		"engine": "jsoniter"
+//	}
+const JSONEngineNamespace = "github.com/luraproject/lura/encoding/json"
+
+// StdJSON is the name of the json engine of the standard library, used by default
+const StdJSON = "std"
+
+// JSONEngine encodes and decodes the json documents of the json decoders and renders
+type JSONEngine interface {
+	Marshal(v interface{}) ([]byte, error)
+	NewDecoder(r io.Reader) JSONStreamDecoder
+}
+
+// JSONAppender is implemented by the json engines able to append the encoding of a value to a
+// buffer, so the renders encode the responses into pooled buffers instead of a new slice per
+// response
+type JSONAppender interface {
+	AppendJSON(dst []byte, v interface{}) ([]byte, error)
+}
+
+// JSONStreamDecoder decodes the json documents of a reader
+type JSONStreamDecoder interface {
+	// UseNumber makes the decoder keep the numbers as json.Number values
+	UseNumber()
+	Decode(v interface{}) error
+}
+
+type stdJSON struct{}
+
+func (stdJSON) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (stdJSON) NewDecoder(r io.Reader) JSONStreamDecoder { return json.NewDecoder(r) }
+
+type namedJSONEngine struct {
+	name   string
+	engine JSONEngine
+}
+
+var (
+	jsonEngines   = map[string]JSONEngine{StdJSON: stdJSON{}}
+	jsonEnginesMu sync.RWMutex
+	jsonEngine    atomic.Value
+)
+
+func init() {
+	jsonEngine.Store(namedJSONEngine{name: StdJSON, engine: stdJSON{}})
+}
+
+// RegisterJSONEngine makes a json engine available with the received name, replacing the
+// previous one, if any. The engines built with the jsoniter, go_json and sonic tags register
+// themselves
+func RegisterJSONEngine(name string, e JSONEngine) {
+	jsonEnginesMu.Lock()
+	jsonEngines[name] = e
+	jsonEnginesMu.Unlock()
+}
+
+// SetJSONEngine selects the registered json engine used by the json decoders and renders. The
+// current engine is kept if there is no engine with the received name
+func SetJSONEngine(name string) error {
+	jsonEnginesMu.RLock()
+	e, ok := jsonEngines[name]
+	jsonEnginesMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("json engine: unknown engine %s", name)
+	}
+	jsonEngine.Store(namedJSONEngine{name: name, engine: e})
+	return nil
+}
+
+// GetJSONEngine returns the selected json engine and its name
+func GetJSONEngine() (string, JSONEngine) {
+	e := jsonEngine.Load().(namedJSONEngine)
+	return e.name, e.engine
+}
+
+// SetJSONEngineFromExtraConfig selects the json engine declared in the service extra config, or
+// the standard library one if the service does not declare it. It returns false if the engine is
+// not declared
+func SetJSONEngineFromExtraConfig(e map[string]interface{}) (bool, error) {
+	tmp, ok := e[JSONEngineNamespace].(map[string]interface{})
+	if !ok {
+		SetJSONEngine(StdJSON)
+		return false, nil
+	}
+	name, _ := tmp["engine"].(string)
+	if name == "" {
+		name = StdJSON
+	}
+	return true, SetJSONEngine(name)
+}
+
+func newJSONDecoder(r io.Reader) JSONStreamDecoder {
+	_, e := GetJSONEngine()
+	d := e.NewDecoder(r)
+	d.UseNumber()
+	return d
+}
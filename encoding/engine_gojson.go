@@ -0,0 +1,25 @@
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build go_json
+// +build go_json
+
+package encoding
+
+import (
+	"io"
+
+	gojson "github.com/goccy/go-json"
+)
+
+// GoJSON is the name of the go-json engine
+const GoJSON = "go-json"
+
+func init() {
+	RegisterJSONEngine(GoJSON, goJSONEngine{})
+}
+
+type goJSONEngine struct{}
+
+func (goJSONEngine) Marshal(v interface{}) ([]byte, error) { return gojson.Marshal(v) }
+
+func (goJSONEngine) NewDecoder(r io.Reader) JSONStreamDecoder { return gojson.NewDecoder(r) }
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build jsoniter
+// +build jsoniter
+
+package encoding
+
+import (
+	"io"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// JSONIter is the name of the json-iterator engine
+const JSONIter = "jsoniter"
+
+func init() {
+	RegisterJSONEngine(JSONIter, jsoniterEngine{api: jsoniter.ConfigCompatibleWithStandardLibrary})
+}
+
+type jsoniterEngine struct {
+	api jsoniter.API
+}
+
+func (j jsoniterEngine) Marshal(v interface{}) ([]byte, error) { return j.api.Marshal(v) }
+
+func (j jsoniterEngine) NewDecoder(r io.Reader) JSONStreamDecoder { return j.api.NewDecoder(r) }
+
+// AppendJSON implements the JSONAppender interface, encoding the value with a pooled stream
+func (j jsoniterEngine) AppendJSON(dst []byte, v interface{}) ([]byte, error) {
+	s := j.api.BorrowStream(nil)
+	defer j.api.ReturnStream(s)
+
+	s.SetBuffer(dst)
+	s.WriteVal(v)
+	b := s.Buffer()
+	// the pooled stream does not keep the buffer of the caller
+	s.SetBuffer(nil)
+	if s.Error != nil {
+		return dst, s.Error
+	}
+	return b, nil
+}
@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build sonic && avx && (linux || windows || darwin) && amd64
+// +build sonic
+// +build avx
+// +build linux windows darwin
+// +build amd64
+
+package encoding
+
+import (
+	"io"
+
+	"github.com/bytedance/sonic"
+)
+
+// Sonic is the name of the sonic engine
+const Sonic = "sonic"
+
+func init() {
+	RegisterJSONEngine(Sonic, sonicEngine{api: sonic.ConfigStd})
+}
+
+type sonicEngine struct {
+	api sonic.API
+}
+
+func (s sonicEngine) Marshal(v interface{}) ([]byte, error) { return s.api.Marshal(v) }
+
+func (s sonicEngine) NewDecoder(r io.Reader) JSONStreamDecoder { return s.api.NewDecoder(r) }
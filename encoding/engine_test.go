@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+type countingJSONEngine struct {
+	marshals int
+	decoders int
+}
+
+func (c *countingJSONEngine) Marshal(v interface{}) ([]byte, error) {
+	c.marshals++
+	return json.Marshal(v)
+}
+
+func (c *countingJSONEngine) NewDecoder(r io.Reader) JSONStreamDecoder {
+	c.decoders++
+	return json.NewDecoder(r)
+}
+
+func TestSetJSONEngineFromExtraConfig(t *testing.T) {
+	defer SetJSONEngine(StdJSON)
+
+	engine := &countingJSONEngine{}
+	RegisterJSONEngine("counting", engine)
+
+	ok, err := SetJSONEngineFromExtraConfig(map[string]interface{}{
+		JSONEngineNamespace: map[string]interface{}{"engine": "counting"},
+	})
+	if !ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+		return
+	}
+	if name, _ := GetJSONEngine(); name != "counting" {
+		t.Errorf("unexpected engine: %s", name)
+		return
+	}
+
+	var data map[string]interface{}
+	if err := JSONDecoder(strings.NewReader(`{"a":1}`), &data); err != nil {
+		t.Error(err)
+		return
+	}
+	if n, ok := data["a"].(json.Number); !ok || n != "1" {
+		t.Errorf("unexpected data: %v", data)
+	}
+	if err := NewJSONDecoder(true)(strings.NewReader(`[1,2]`), &data); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := NewSafeJSONDecoder(true)(strings.NewReader(`"a"`), &data); err != nil {
+		t.Error(err)
+		return
+	}
+	if engine.decoders != 3 {
+		t.Errorf("unexpected number of decoders: %d", engine.decoders)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteJSON(buf, data); err != nil {
+		t.Error(err)
+		return
+	}
+	if buf.String() != `{"content":"a"}` {
+		t.Errorf("unexpected output: %s", buf.String())
+	}
+	if b, err := AppendJSON([]byte("x"), "y"); err != nil || string(b) != `x"y"` {
+		t.Errorf("unexpected output: %s %v", b, err)
+	}
+	if engine.marshals != 2 {
+		t.Errorf("unexpected number of marshals: %d", engine.marshals)
+	}
+
+	ok, err = SetJSONEngineFromExtraConfig(map[string]interface{}{
+		JSONEngineNamespace: map[string]interface{}{"engine": "unknown"},
+	})
+	if !ok || err == nil {
+		t.Errorf("expecting an error, got: %v %v", ok, err)
+	}
+	if name, _ := GetJSONEngine(); name != "counting" {
+		t.Errorf("the engine should be kept, got: %s", name)
+	}
+
+	ok, err = SetJSONEngineFromExtraConfig(map[string]interface{}{})
+	if ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	if name, _ := GetJSONEngine(); name != StdJSON {
+		t.Errorf("unexpected engine: %s", name)
+	}
+}
+
+type appendingJSONEngine struct {
+	countingJSONEngine
+	appends int
+}
+
+func (a *appendingJSONEngine) AppendJSON(dst []byte, v interface{}) ([]byte, error) {
+	a.appends++
+	b, err := json.Marshal(v)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, b...), nil
+}
+
+func TestWriteJSON_appender(t *testing.T) {
+	defer SetJSONEngine(StdJSON)
+
+	engine := &appendingJSONEngine{}
+	RegisterJSONEngine("appending", engine)
+	if err := SetJSONEngine("appending"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteJSON(buf, map[string]interface{}{"a": 1}); err != nil {
+		t.Error(err)
+		return
+	}
+	if buf.String() != `{"a":1}` {
+		t.Errorf("unexpected output: %s", buf.String())
+	}
+	if engine.appends != 1 || engine.marshals != 0 {
+		t.Errorf("unexpected number of appends and marshals: %d %d", engine.appends, engine.marshals)
+	}
+}
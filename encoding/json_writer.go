@@ -0,0 +1,310 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxPooledBuffer is the capacity of the biggest buffer kept in the pool, so a single huge
+// response does not pin its memory
+const maxPooledBuffer = 1 << 20
+
+var jsonBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+// WriteJSON writes the json encoding of the value into the writer, marshaled by the selected
+// json engine, so the renders follow the engine declared in the service extra config. The output
+// buffers are pooled and filled by the engines implementing JSONAppender, so the standard
+// library engine encodes the decoded json trees (maps, slices, strings, numbers, booleans and
+// nulls) without allocations. Nothing is written if the encoding fails
+func WriteJSON(w io.Writer, v interface{}) error {
+	buf := jsonBufferPool.Get().(*[]byte)
+	defer releaseJSONBuffer(buf)
+
+	b, err := AppendJSON((*buf)[:0], v)
+	if err != nil {
+		return err
+	}
+	*buf = b
+	_, err = w.Write(b)
+	return err
+}
+
+// AppendJSON appends the json encoding of the value to dst, as WriteJSON does. The engines not
+// implementing JSONAppender marshal the value into a new slice, appended to dst
+func AppendJSON(dst []byte, v interface{}) ([]byte, error) {
+	_, engine := GetJSONEngine()
+	if a, ok := engine.(JSONAppender); ok {
+		return a.AppendJSON(dst, v)
+	}
+	b, err := engine.Marshal(v)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, b...), nil
+}
+
+func releaseJSONBuffer(buf *[]byte) {
+	if cap(*buf) > maxPooledBuffer {
+		return
+	}
+	*buf = (*buf)[:0]
+	jsonBufferPool.Put(buf)
+}
+
+var jsonWriterPool = sync.Pool{
+	New: func() interface{} { return &jsonWriter{} },
+}
+
+// jsonWriter holds the stack of the keys of the maps being encoded, reused across the encodings
+// of the standard library engine
+type jsonWriter struct {
+	keys []string
+}
+
+// AppendJSON implements the JSONAppender interface with the same output as json.Marshal
+func (stdJSON) AppendJSON(dst []byte, v interface{}) ([]byte, error) {
+	e := jsonWriterPool.Get().(*jsonWriter)
+	defer e.release()
+
+	b, err := e.append(dst, v)
+	if err != nil {
+		return dst, err
+	}
+	return b, nil
+}
+
+func (e *jsonWriter) release() {
+	for i := range e.keys {
+		e.keys[i] = ""
+	}
+	e.keys = e.keys[:0]
+	jsonWriterPool.Put(e)
+}
+
+func (e *jsonWriter) append(b []byte, v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case nil:
+		return append(b, "null"...), nil
+	case string:
+		return appendJSONString(b, t)
+	case bool:
+		return strconv.AppendBool(b, t), nil
+	case json.Number:
+		s := string(t)
+		if s == "" {
+			s = "0"
+		}
+		if !isValidNumber(s) {
+			return appendMarshaled(b, t)
+		}
+		return append(b, s...), nil
+	case float64:
+		return appendJSONFloat(b, t, 64)
+	case float32:
+		return appendJSONFloat(b, float64(t), 32)
+	case int:
+		return strconv.AppendInt(b, int64(t), 10), nil
+	case int64:
+		return strconv.AppendInt(b, t, 10), nil
+	case map[string]interface{}:
+		if t == nil {
+			return append(b, "null"...), nil
+		}
+		return e.appendMap(b, t)
+	case []interface{}:
+		if t == nil {
+			return append(b, "null"...), nil
+		}
+		b = append(b, '[')
+		for i, item := range t {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			var err error
+			if b, err = e.append(b, item); err != nil {
+				return b, err
+			}
+		}
+		return append(b, ']'), nil
+	}
+	return appendMarshaled(b, v)
+}
+
+func (e *jsonWriter) appendMap(b []byte, m map[string]interface{}) ([]byte, error) {
+	// the keys of the map are pushed to the stack, so the nested maps reuse it
+	start := len(e.keys)
+	for k := range m {
+		e.keys = append(e.keys, k)
+	}
+	end := len(e.keys)
+	sortKeys(e.keys[start:end])
+
+	b = append(b, '{')
+	for i := start; i < end; i++ {
+		if i > start {
+			b = append(b, ',')
+		}
+		k := e.keys[i]
+		var err error
+		if b, err = appendJSONString(b, k); err != nil {
+			return b, err
+		}
+		b = append(b, ':')
+		if b, err = e.append(b, m[k]); err != nil {
+			return b, err
+		}
+	}
+	e.keys = e.keys[:start]
+	return append(b, '}'), nil
+}
+
+// sortKeys sorts the small lists of keys with an insertion sort, so they are sorted in place
+// without allocations
+func sortKeys(keys []string) {
+	if len(keys) > 12 {
+		sort.Strings(keys)
+		return
+	}
+	for i := 1; i < len(keys); i++ {
+		for j := i; j > 0 && keys[j] < keys[j-1]; j-- {
+			keys[j], keys[j-1] = keys[j-1], keys[j]
+		}
+	}
+}
+
+func appendMarshaled(b []byte, v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return b, err
+	}
+	return append(b, raw...), nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends the quoted string escaping the html characters, as json.Marshal does.
+// The strings with invalid utf8 sequences or control characters without a short escape are left
+// to json.Marshal, since their encoding depends on the version of the standard library
+func appendJSONString(b []byte, s string) ([]byte, error) {
+	origin := len(b)
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			case '<', '>', '&':
+				b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				return appendMarshaled(b[:origin], s)
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			return appendMarshaled(b[:origin], s)
+		}
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hexDigits[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"'), nil
+}
+
+// appendJSONFloat appends the float with the format of json.Marshal
+func appendJSONFloat(b []byte, f float64, bits int) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return appendMarshaled(b, f)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b = strconv.AppendFloat(b, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b, nil
+}
+
+// isValidNumber reports whether s is a valid json number literal
+func isValidNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	if s[0] == '-' {
+		s = s[1:]
+		if s == "" {
+			return false
+		}
+	}
+	switch {
+	case s[0] == '0':
+		s = s[1:]
+	case '1' <= s[0] && s[0] <= '9':
+		s = s[1:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	default:
+		return false
+	}
+	if len(s) >= 2 && s[0] == '.' && '0' <= s[1] && s[1] <= '9' {
+		s = s[2:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+	if len(s) >= 2 && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if s[0] == '+' || s[0] == '-' {
+			s = s[1:]
+			if s == "" {
+				return false
+			}
+		}
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+	return s == ""
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	for i, v := range []interface{}{
+		nil,
+		"plain",
+		"<script>&\"quoted\"\\ \n\r\t",
+		"control \x01 \x08 \x0c chars",
+		"invalid \xff utf8",
+		"separators     and ñ 漢字",
+		true,
+		json.Number("42"),
+		json.Number("-1.5e-7"),
+		json.Number(""),
+		42,
+		int64(-42),
+		3.14,
+		1e21,
+		1e-7,
+		float32(0.1),
+		-0.0,
+		[]interface{}{},
+		[]interface{}(nil),
+		map[string]interface{}(nil),
+		map[string]interface{}{},
+		map[string]interface{}{
+			"z": []interface{}{1, "a", nil, map[string]interface{}{"b": false, "a": true}},
+			"a": map[string]interface{}{"<k>": json.Number("1"), "k": []string{"x"}},
+			"m": map[string]string{"b": "c"},
+		},
+		[]interface{}{map[string]interface{}{"a": 1}, map[string]interface{}{"b": map[string]interface{}{"c": 2}}},
+		struct {
+			A int `json:"a"`
+		}{A: 1},
+	} {
+		expected, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := new(bytes.Buffer)
+		if err := WriteJSON(buf, v); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if buf.String() != string(expected) {
+			t.Errorf("#%d: unexpected output.\nhave: %s\nwant: %s", i, buf.String(), expected)
+		}
+		if b, _ := AppendJSON([]byte("prefix"), v); string(b) != "prefix"+string(expected) {
+			t.Errorf("#%d: unexpected appended output: %s", i, b)
+		}
+	}
+}
+
+func TestWriteJSON_manyKeys(t *testing.T) {
+	v := map[string]interface{}{}
+	for _, k := range []string{"q", "w", "e", "r", "t", "y", "u", "i", "o", "p", "a", "s", "d", "f", "g"} {
+		v[k] = map[string]interface{}{k: k, "x": []interface{}{k}}
+	}
+	expected, _ := json.Marshal(v)
+	buf := new(bytes.Buffer)
+	if err := WriteJSON(buf, v); err != nil || buf.String() != string(expected) {
+		t.Errorf("unexpected output %s: %v", buf.String(), err)
+	}
+}
+
+func TestWriteJSON_error(t *testing.T) {
+	for i, v := range []interface{}{
+		map[string]interface{}{"a": math.NaN()},
+		[]interface{}{json.Number("1a")},
+		map[string]interface{}{"a": make(chan int)},
+	} {
+		buf := new(bytes.Buffer)
+		if err := WriteJSON(buf, v); err == nil {
+			t.Errorf("#%d: error expected", i)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("#%d: nothing should be written: %s", i, buf.String())
+		}
+	}
+}
+
+func TestWriteJSON_allocs(t *testing.T) {
+	data := map[string]interface{}{}
+	for i := 0; i < 50; i++ {
+		data[fmt.Sprintf("key%02d", i)] = []interface{}{"value", json.Number("1"), true, nil}
+	}
+	if err := WriteJSON(io.Discard, data); err != nil {
+		t.Fatal(err)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { WriteJSON(io.Discard, data) }); allocs > 0 {
+		t.Errorf("unexpected number of allocations: %v", allocs)
+	}
+}
+
+func TestIsValidNumber(t *testing.T) {
+	for _, s := range []string{"0", "-0", "1", "10", "1.5", "-1.5e10", "1E+2", "0.0e-1"} {
+		if !isValidNumber(s) {
+			t.Errorf("%s should be valid", s)
+		}
+	}
+	for _, s := range []string{"", "-", "01", "1.", ".1", "1e", "1e+", "+1", "1a", "0x1"} {
+		if isValidNumber(s) {
+			t.Errorf("%s should be invalid", s)
+		}
+	}
+}
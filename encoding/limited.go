@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// LimitsNamespace is the key of the decoding limits in the backend extra config
+const LimitsNamespace = "github.com/luraproject/lura/encoding/limits"
+
+var (
+	// ErrMaxBytes is returned when the response body is bigger than the limit
+	ErrMaxBytes = errors.New("decoding limits: the body exceeds the max bytes")
+	// ErrMaxDepth is returned when the response nests more containers than the limit
+	ErrMaxDepth = errors.New("decoding limits: the document exceeds the max depth")
+	// ErrMaxElements is returned when the response contains more elements than the limit
+	ErrMaxElements = errors.New("decoding limits: the document exceeds the max elements")
+)
+
+// Limits bounds the resources a backend response can consume while being decoded. Zero values
+// disable the limit
+type Limits struct {
+	// MaxBytes is the max size of the response body
+	MaxBytes int64
+	// MaxDepth is the max nesting of objects and arrays. The root container is at depth 1
+	MaxDepth int
+	// MaxElements is the max number of array items and object members in the whole document
+	MaxElements int
+}
+
+// LimitsFromExtraConfig parses the decoding limits of a backend:
+//
+//	"github.com/luraproject/lura/encoding/limits": {
+//		"max_bytes": 10485760,
+//		"max_depth": 32,
+//		"max_elements": 100000
+//	}
+func LimitsFromExtraConfig(e map[string]interface{}) (Limits, bool, error) {
+	tmp, ok := e[LimitsNamespace].(map[string]interface{})
+	if !ok {
+		return Limits{}, false, nil
+	}
+	l := Limits{
+		MaxBytes:    int64(getNumber(tmp["max_bytes"])),
+		MaxDepth:    int(getNumber(tmp["max_depth"])),
+		MaxElements: int(getNumber(tmp["max_elements"])),
+	}
+	if l.MaxBytes < 0 || l.MaxDepth < 0 || l.MaxElements < 0 {
+		return l, true, fmt.Errorf("decoding limits: the limits can not be negative: %+v", l)
+	}
+	return l, true, nil
+}
+
+// NewLimitedJSONDecoder returns a JSON decoder enforcing the limits. The document is parsed token
+// by token, so the limits are checked before the offending values are allocated. As the
+// NewJSONDecoder ones, the decoder expects an object or, for collections, an array
+func NewLimitedJSONDecoder(l Limits) DecoderFactory {
+	return func(isCollection bool) func(io.Reader, *map[string]interface{}) error {
+		return func(r io.Reader, v *map[string]interface{}) error {
+			t, err := decodeLimited(r, l)
+			if err != nil {
+				return err
+			}
+			if isCollection {
+				collection, ok := t.([]interface{})
+				if !ok {
+					return fmt.Errorf("json: cannot unmarshal %T into a collection", t)
+				}
+				*v = map[string]interface{}{"collection": collection}
+				return nil
+			}
+			m, ok := t.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("json: cannot unmarshal %T into an object", t)
+			}
+			*v = m
+			return nil
+		}
+	}
+}
+
+// NewLimitedSafeJSONDecoder returns a SafeJSONDecoder enforcing the limits
+func NewLimitedSafeJSONDecoder(l Limits) DecoderFactory {
+	return func(_ bool) func(io.Reader, *map[string]interface{}) error {
+		return func(r io.Reader, v *map[string]interface{}) error {
+			t, err := decodeLimited(r, l)
+			if err != nil {
+				return err
+			}
+			switch tt := t.(type) {
+			case map[string]interface{}:
+				*v = tt
+			case []interface{}:
+				*v = map[string]interface{}{"collection": tt}
+			default:
+				*v = map[string]interface{}{"content": tt}
+			}
+			return nil
+		}
+	}
+}
+
+// NewLimitedReaderDecoder decorates the decoder, so it fails with ErrMaxBytes when the body is
+// bigger than maxBytes instead of reading it completely
+func NewLimitedReaderDecoder(maxBytes int64, next Decoder) Decoder {
+	if maxBytes <= 0 {
+		return next
+	}
+	return func(r io.Reader, v *map[string]interface{}) error {
+		return next(&limitedReader{r: r, n: maxBytes}, v)
+	}
+}
+
+func decodeLimited(r io.Reader, l Limits) (interface{}, error) {
+	if l.MaxBytes > 0 {
+		r = &limitedReader{r: r, n: l.MaxBytes}
+	}
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	ld := &limitedDecoder{d: d, limits: l}
+	return ld.value(0)
+}
+
+type limitedDecoder struct {
+	d        *json.Decoder
+	limits   Limits
+	elements int
+}
+
+func (ld *limitedDecoder) value(depth int) (interface{}, error) {
+	t, err := ld.d.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := t.(json.Delim)
+	if !ok {
+		return t, nil
+	}
+	if ld.limits.MaxDepth > 0 && depth >= ld.limits.MaxDepth {
+		return nil, ErrMaxDepth
+	}
+
+	switch delim {
+	case '{':
+		m := map[string]interface{}{}
+		for ld.d.More() {
+			if err := ld.count(); err != nil {
+				return nil, err
+			}
+			k, err := ld.d.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("json: unexpected object key %v", k)
+			}
+			if m[key], err = ld.value(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		_, err = ld.d.Token()
+		return m, err
+	case '[':
+		a := []interface{}{}
+		for ld.d.More() {
+			if err := ld.count(); err != nil {
+				return nil, err
+			}
+			item, err := ld.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, item)
+		}
+		_, err = ld.d.Token()
+		return a, err
+	}
+	return nil, fmt.Errorf("json: unexpected delimiter %s", delim)
+}
+
+func (ld *limitedDecoder) count() error {
+	ld.elements++
+	if ld.limits.MaxElements > 0 && ld.elements > ld.limits.MaxElements {
+		return ErrMaxElements
+	}
+	return nil
+}
+
+// limitedReader is an io.LimitedReader failing when the underlying reader has more data
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		var b [1]byte
+		if n, _ := l.r.Read(b[:]); n > 0 {
+			return 0, ErrMaxBytes
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+func getNumber(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return 0
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLimitsFromExtraConfig(t *testing.T) {
+	if _, ok, _ := LimitsFromExtraConfig(map[string]interface{}{}); ok {
+		t.Error("the config does not declare limits")
+	}
+	l, ok, err := LimitsFromExtraConfig(map[string]interface{}{
+		LimitsNamespace: map[string]interface{}{"max_bytes": 1024.0, "max_depth": 4, "max_elements": 10.0},
+	})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if l != (Limits{MaxBytes: 1024, MaxDepth: 4, MaxElements: 10}) {
+		t.Errorf("unexpected limits: %+v", l)
+	}
+	if _, _, err := LimitsFromExtraConfig(map[string]interface{}{
+		LimitsNamespace: map[string]interface{}{"max_depth": -1},
+	}); err == nil {
+		t.Error("expecting an error for the negative limit")
+	}
+}
+
+func TestNewLimitedJSONDecoder(t *testing.T) {
+	for name, tc := range map[string]struct {
+		limits       Limits
+		isCollection bool
+		body         string
+		err          error
+	}{
+		"object":            {limits: Limits{MaxDepth: 2, MaxElements: 3}, body: `{"a":1,"b":{"c":true}}`},
+		"collection":        {limits: Limits{MaxDepth: 2, MaxElements: 4}, isCollection: true, body: `[1,2,[3]]`},
+		"unlimited":         {body: `{"a":[[[[[1]]]]]}`},
+		"too deep":          {limits: Limits{MaxDepth: 2}, body: `{"a":{"b":{"c":1}}}`, err: ErrMaxDepth},
+		"too many elements": {limits: Limits{MaxElements: 3}, isCollection: true, body: `[1,2,3,4,5]`, err: ErrMaxElements},
+		"nested elements":   {limits: Limits{MaxElements: 3}, body: `{"a":[1,2],"b":3}`, err: ErrMaxElements},
+		"too big":           {limits: Limits{MaxBytes: 8}, body: `{"a":"0123456789"}`, err: ErrMaxBytes},
+		"exactly max bytes": {limits: Limits{MaxBytes: 9}, body: `{"a":123}`},
+	} {
+		var v map[string]interface{}
+		err := NewLimitedJSONDecoder(tc.limits)(tc.isCollection)(strings.NewReader(tc.body), &v)
+		if err != tc.err {
+			t.Errorf("%s: unexpected error. have: %v, want: %v", name, err, tc.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var expected map[string]interface{}
+		if tc.isCollection {
+			NewJSONDecoder(true)(strings.NewReader(tc.body), &expected)
+		} else {
+			NewJSONDecoder(false)(strings.NewReader(tc.body), &expected)
+		}
+		have, _ := json.Marshal(v)
+		want, _ := json.Marshal(expected)
+		if string(have) != string(want) {
+			t.Errorf("%s: unexpected result. have: %s, want: %s", name, have, want)
+		}
+	}
+}
+
+func TestNewLimitedJSONDecoder_wrongType(t *testing.T) {
+	var v map[string]interface{}
+	if err := NewLimitedJSONDecoder(Limits{})(true)(strings.NewReader(`{"a":1}`), &v); err == nil {
+		t.Error("expecting an error decoding an object as a collection")
+	}
+	if err := NewLimitedJSONDecoder(Limits{})(false)(strings.NewReader(`[1]`), &v); err == nil {
+		t.Error("expecting an error decoding a collection as an object")
+	}
+	if err := NewLimitedJSONDecoder(Limits{})(false)(strings.NewReader(`{"a":`), &v); err == nil {
+		t.Error("expecting an error decoding a truncated document")
+	}
+}
+
+func TestNewLimitedSafeJSONDecoder(t *testing.T) {
+	for body, expected := range map[string]string{
+		`{"a":1}`: `{"a":1}`,
+		`[1,2]`:   `{"collection":[1,2]}`,
+		`"text"`:  `{"content":"text"}`,
+	} {
+		var v map[string]interface{}
+		if err := NewLimitedSafeJSONDecoder(Limits{MaxElements: 2})(false)(strings.NewReader(body), &v); err != nil {
+			t.Errorf("%s: %s", body, err.Error())
+			continue
+		}
+		if have, _ := json.Marshal(v); string(have) != expected {
+			t.Errorf("%s: unexpected result %s", body, have)
+		}
+	}
+}
+
+func TestNewLimitedReaderDecoder(t *testing.T) {
+	dec := NewLimitedReaderDecoder(4, StringDecoder)
+	var v map[string]interface{}
+	if err := dec(strings.NewReader("1234"), &v); err != nil || v["content"] != "1234" {
+		t.Errorf("unexpected result. err: %v, content: %v", err, v["content"])
+	}
+	if err := dec(strings.NewReader("12345"), &v); err != ErrMaxBytes {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -48,6 +48,7 @@ var (
 		JSON:      NewJSONDecoder,
 		SAFE_JSON: NewSafeJSONDecoder,
 		STRING:    NewStringDecoder,
+		XML:       NewXMLDecoder,
 		NOOP:      noOpDecoderFactory,
 	}
 )
@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// XML is the key for the xml encoding
+const XML = "xml"
+
+// maxXMLDepth bounds the nesting of the decoded XML documents
+const maxXMLDepth = 256
+
+// NewXMLDecoder returns the right XML decoder
+func NewXMLDecoder(isCollection bool) func(io.Reader, *map[string]interface{}) error {
+	if isCollection {
+		return XMLCollectionDecoder
+	}
+	return XMLDecoder
+}
+
+// XMLDecoder decodes a xml document into a map with the content of its root element. The
+// namespaces are stripped, the attributes are stored with the '@' prefix and the repeated
+// elements are grouped into arrays. The elements with just text are decoded as strings and the
+// text of the elements with attributes or children is stored at the '#text' key
+func XMLDecoder(r io.Reader, v *map[string]interface{}) error {
+	_, data, err := DecodeXMLDocument(r)
+	if err != nil {
+		return err
+	}
+	switch tt := data.(type) {
+	case map[string]interface{}:
+		*v = tt
+	default:
+		*v = map[string]interface{}{"content": tt}
+	}
+	return nil
+}
+
+// XMLCollectionDecoder decodes a xml document and returns a map with the children of its root
+// element at the 'collection' key
+func XMLCollectionDecoder(r io.Reader, v *map[string]interface{}) error {
+	_, data, err := DecodeXMLDocument(r)
+	if err != nil {
+		return err
+	}
+	*(v) = map[string]interface{}{"collection": XMLChildren(data)}
+	return nil
+}
+
+// XMLChildren returns the children of a decoded element, flattening the repeated ones. The
+// attributes and the text of the element are ignored
+func XMLChildren(v interface{}) []interface{} {
+	children := []interface{}{}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return children
+	}
+	for k, child := range m {
+		if strings.HasPrefix(k, "@") || k == "#text" {
+			continue
+		}
+		if items, ok := child.([]interface{}); ok {
+			children = append(children, items...)
+			continue
+		}
+		children = append(children, child)
+	}
+	return children
+}
+
+// DecodeXMLDocument decodes a xml document, returning the local name and the content of its root
+// element, decoded as in the XMLDecoder
+func DecodeXMLDocument(r io.Reader) (string, interface{}, error) {
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return "", nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			v, err := decodeXMLElement(d, start, 0)
+			return start.Name.Local, v, err
+		}
+	}
+}
+
+func decodeXMLElement(d *xml.Decoder, start xml.StartElement, depth int) (interface{}, error) {
+	if depth > maxXMLDepth {
+		return nil, errors.New("xml: max depth exceeded")
+	}
+	m := map[string]interface{}{}
+	for _, a := range start.Attr {
+		if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+			continue
+		}
+		m["@"+a.Name.Local] = a.Value
+	}
+	var text strings.Builder
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(d, t, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			addXMLChild(m, t.Name.Local, child)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(m) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				m["#text"] = s
+			}
+			return m, nil
+		}
+	}
+}
+
+func addXMLChild(m map[string]interface{}, name string, child interface{}) {
+	prev, ok := m[name]
+	if !ok {
+		m[name] = child
+		return
+	}
+	if items, ok := prev.([]interface{}); ok {
+		m[name] = append(items, child)
+		return
+	}
+	m[name] = []interface{}{prev, child}
+}
+
+// EncodeXML writes the value as a xml document with the received root element, following the
+// conventions of the XMLDecoder: the keys with the '@' prefix are written as attributes, the
+// '#text' key as the text of the element and the arrays as repeated elements. The keys are
+// sorted and the characters not allowed in the names of the elements are replaced by '_'
+func EncodeXML(w io.Writer, root string, v interface{}) error {
+	e := xml.NewEncoder(w)
+	if err := encodeXMLElement(e, xmlName(root), v); err != nil {
+		return err
+	}
+	return e.Flush()
+}
+
+func encodeXMLElement(e *xml.Encoder, name string, v interface{}) error {
+	if items, ok := v.([]interface{}); ok {
+		for _, item := range items {
+			if err := encodeXMLElement(e, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		if v != nil {
+			if err := e.EncodeToken(xml.CharData(fmt.Sprint(v))); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if strings.HasPrefix(k, "@") {
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: xmlName(k[1:])}, Value: fmt.Sprint(m[k])})
+		}
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if text, ok := m["#text"]; ok {
+		if err := e.EncodeToken(xml.CharData(fmt.Sprint(text))); err != nil {
+			return err
+		}
+	}
+	for _, k := range keys {
+		if strings.HasPrefix(k, "@") || k == "#text" {
+			continue
+		}
+		if err := encodeXMLElement(e, xmlName(k), m[k]); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func xmlName(s string) string {
+	if s == "" {
+		return "_"
+	}
+	b := []byte(s)
+	for i, c := range b {
+		valid := c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+		if i > 0 {
+			valid = valid || c == '-' || c == '.' || c >= '0' && c <= '9'
+		}
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
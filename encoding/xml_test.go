@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXMLDecoder(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<u:user xmlns:u="urn:users" id="42">
+	<u:name>John</u:name>
+	<u:tags><u:tag>a</u:tag><u:tag>b</u:tag></u:tags>
+	<u:note lang="en">hello</u:note>
+	<u:empty/>
+</u:user>`
+	var result map[string]interface{}
+	if err := NewXMLDecoder(false)(strings.NewReader(doc), &result); err != nil {
+		t.Error("Unexpected error:", err.Error())
+		return
+	}
+	expected := map[string]interface{}{
+		"@id":   "42",
+		"name":  "John",
+		"tags":  map[string]interface{}{"tag": []interface{}{"a", "b"}},
+		"note":  map[string]interface{}{"@lang": "en", "#text": "hello"},
+		"empty": "",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestXMLCollectionDecoder(t *testing.T) {
+	doc := `<users><user><id>1</id></user><user><id>2</id></user></users>`
+	var result map[string]interface{}
+	if err := NewXMLDecoder(true)(strings.NewReader(doc), &result); err != nil {
+		t.Error("Unexpected error:", err.Error())
+		return
+	}
+	expected := map[string]interface{}{
+		"collection": []interface{}{
+			map[string]interface{}{"id": "1"},
+			map[string]interface{}{"id": "2"},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestXMLDecoder_ko(t *testing.T) {
+	for _, doc := range []string{"", "<a><b></a>", "<a><b>"} {
+		var result map[string]interface{}
+		if err := XMLDecoder(strings.NewReader(doc), &result); err == nil {
+			t.Errorf("expecting an error decoding %q", doc)
+		}
+	}
+}
+
+func TestXMLDecoder_maxDepth(t *testing.T) {
+	doc := strings.Repeat("<a>", maxXMLDepth+2) + strings.Repeat("</a>", maxXMLDepth+2)
+	var result map[string]interface{}
+	if err := XMLDecoder(strings.NewReader(doc), &result); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestEncodeXML(t *testing.T) {
+	data := map[string]interface{}{
+		"@id":   json.Number("42"),
+		"name":  "John & Jane",
+		"tags":  map[string]interface{}{"tag": []interface{}{"a", "b"}},
+		"note":  map[string]interface{}{"@lang": "en", "#text": "hello"},
+		"empty": nil,
+		"2fa":   true,
+	}
+	var buf strings.Builder
+	if err := EncodeXML(&buf, "user", data); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<user id="42"><_fa>true</_fa><empty></empty><name>John &amp; Jane</name><note lang="en">hello</note><tags><tag>a</tag><tag>b</tag></tags></user>`
+	if buf.String() != expected {
+		t.Errorf("unexpected document:\nhave: %s\nwant: %s", buf.String(), expected)
+	}
+
+	var result map[string]interface{}
+	if err := XMLDecoder(strings.NewReader(buf.String()), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result["name"] != "John & Jane" || !reflect.DeepEqual(result["tags"], data["tags"]) {
+		t.Errorf("unexpected decoded document: %v", result)
+	}
+}
@@ -0,0 +1,234 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package encryption provides transparent AES-GCM encryption for the state persisted by the
+gateway (disk cache, recordings, compiled configs...), so it can be deployed in environments
+with strict data-at-rest requirements.
+
+The keys are obtained from KeyProviders. The package offers providers reading the key from
+a file or an environment variable and a register where other subsystems can plug their own.
+*/
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/luraproject/lura/v2/register"
+)
+
+const (
+	// Namespace is the key to use to store and access the encryption config
+	Namespace = "github.com/luraproject/lura/encryption"
+
+	formatVersion byte = 1
+	keyIDSize          = 4
+)
+
+var (
+	// ErrInvalidKeySize is the error returned when the key is not 16, 24 or 32 bytes long
+	ErrInvalidKeySize = errors.New("encryption: the key must be 16, 24 or 32 bytes long")
+	// ErrMalformedPayload is the error returned when the payload to decrypt is not valid
+	ErrMalformedPayload = errors.New("encryption: malformed payload")
+	// ErrUnknownKey is the error returned when the payload was encrypted with an unknown key
+	ErrUnknownKey = errors.New("encryption: payload encrypted with an unknown key")
+)
+
+// Cipher encrypts and decrypts payloads. The additional data is authenticated but not
+// encrypted, so it can be used to bind the payload to its location (a key, a file name...)
+type Cipher interface {
+	Encrypt(plaintext, additionalData []byte) ([]byte, error)
+	Decrypt(ciphertext, additionalData []byte) ([]byte, error)
+}
+
+// NewAESGCM returns a Cipher using AES-GCM with the received key. The previous keys are
+// only used for decrypting, so the payloads persisted before a key rotation remain readable
+func NewAESGCM(key []byte, previous ...[]byte) (Cipher, error) {
+	c := &aesGCM{keys: map[string]cipher.AEAD{}}
+	for i, k := range append([][]byte{key}, previous...) {
+		aead, err := newAEAD(k)
+		if err != nil {
+			return nil, err
+		}
+		id := keyID(k)
+		if i == 0 {
+			c.current, c.currentID = aead, id
+		}
+		c.keys[string(id)] = aead
+	}
+	return c, nil
+}
+
+type aesGCM struct {
+	current   cipher.AEAD
+	currentID []byte
+	keys      map[string]cipher.AEAD
+}
+
+// Encrypt seals the payload. The result contains the format version, the id of the
+// key, the nonce and the sealed data
+func (a *aesGCM) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	nonceSize := a.current.NonceSize()
+	headerSize := 1 + keyIDSize + nonceSize
+	out := make([]byte, headerSize, headerSize+len(plaintext)+a.current.Overhead())
+	out[0] = formatVersion
+	copy(out[1:], a.currentID)
+	if _, err := io.ReadFull(rand.Reader, out[1+keyIDSize:headerSize]); err != nil {
+		return nil, err
+	}
+	return a.current.Seal(out, out[1+keyIDSize:headerSize], plaintext, additionalData), nil
+}
+
+// Decrypt opens the payload with the key used for encrypting it
+func (a *aesGCM) Decrypt(ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < 1+keyIDSize || ciphertext[0] != formatVersion {
+		return nil, ErrMalformedPayload
+	}
+	aead, ok := a.keys[string(ciphertext[1:1+keyIDSize])]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	headerSize := 1 + keyIDSize + aead.NonceSize()
+	if len(ciphertext) < headerSize+aead.Overhead() {
+		return nil, ErrMalformedPayload
+	}
+	return aead.Open(nil, ciphertext[1+keyIDSize:headerSize], ciphertext[headerSize:], additionalData)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, ErrInvalidKeySize
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func keyID(key []byte) []byte {
+	sum := sha256.Sum256(key)
+	return sum[:keyIDSize]
+}
+
+// KeyProvider returns the encryption key identified by the received reference
+type KeyProvider func(ref string) ([]byte, error)
+
+// RegisterKeyProvider adds a KeyProvider to the package register, so it can be selected
+// by name from the configuration
+func RegisterKeyProvider(name string, kp KeyProvider) {
+	providers.Register(name, kp)
+}
+
+// FileKeyProvider reads the key from the file at the path ref
+func FileKeyProvider(ref string) ([]byte, error) {
+	b, err := os.ReadFile(ref)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeKey(strings.TrimSpace(string(b)))
+}
+
+// EnvKeyProvider reads the key from the environment variable named ref
+func EnvKeyProvider(ref string) ([]byte, error) {
+	v, ok := os.LookupEnv(ref)
+	if !ok {
+		return nil, fmt.Errorf("encryption: environment variable %s not defined", ref)
+	}
+	return DecodeKey(v)
+}
+
+// DecodeKey decodes a key encoded in hex or in base64. Raw keys with a valid length are
+// accepted as they are
+func DecodeKey(s string) ([]byte, error) {
+	if b, err := hex.DecodeString(s); err == nil && validKeySize(len(b)) {
+		return b, nil
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && validKeySize(len(b)) {
+		return b, nil
+	}
+	if validKeySize(len(s)) {
+		return []byte(s), nil
+	}
+	return nil, ErrInvalidKeySize
+}
+
+func validKeySize(l int) bool {
+	return l == 16 || l == 24 || l == 32
+}
+
+// Config defines where to get the keys from
+type Config struct {
+	// Provider is the name of the KeyProvider to use: "file", "env" or a registered one
+	Provider string
+	// Key is the reference of the current key
+	Key string
+	// PreviousKeys are the references of the keys only used for decrypting
+	PreviousKeys []string
+}
+
+// ConfigGetter parses the encryption config embedded in a component config.
+// It returns false if the component does not require encryption
+func ConfigGetter(e map[string]interface{}) (Config, bool) {
+	cfg := Config{}
+	tmp, ok := e["encryption"].(map[string]interface{})
+	if !ok {
+		return cfg, false
+	}
+	cfg.Provider, _ = tmp["provider"].(string)
+	if cfg.Provider == "" {
+		cfg.Provider = "file"
+	}
+	cfg.Key, _ = tmp["key"].(string)
+	if previous, ok := tmp["previous_keys"].([]interface{}); ok {
+		for _, p := range previous {
+			if s, ok := p.(string); ok {
+				cfg.PreviousKeys = append(cfg.PreviousKeys, s)
+			}
+		}
+	}
+	return cfg, true
+}
+
+// New returns a Cipher with the keys defined by the configuration
+func New(cfg Config) (Cipher, error) {
+	v, ok := providers.Get(cfg.Provider)
+	if !ok {
+		return nil, fmt.Errorf("encryption: unknown key provider '%s'", cfg.Provider)
+	}
+	kp, ok := v.(KeyProvider)
+	if !ok {
+		return nil, fmt.Errorf("encryption: invalid key provider '%s'", cfg.Provider)
+	}
+	key, err := kp(cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	previous := make([][]byte, 0, len(cfg.PreviousKeys))
+	for _, ref := range cfg.PreviousKeys {
+		k, err := kp(ref)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, k)
+	}
+	return NewAESGCM(key, previous...)
+}
+
+var providers = register.NewUntyped()
+
+func init() {
+	RegisterKeyProvider("file", FileKeyProvider)
+	RegisterKeyProvider("env", EnvKeyProvider)
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encryption
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewAESGCM(t *testing.T) {
+	key := bytes.Repeat([]byte("k"), 32)
+	c, err := NewAESGCM(key)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	plaintext := []byte("some persisted state")
+	ciphertext, err := c.Encrypt(plaintext, []byte("file-a"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if bytes.Contains(ciphertext, plaintext) {
+		t.Error("the payload has not been encrypted")
+	}
+
+	b, err := c.Decrypt(ciphertext, []byte("file-a"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(b, plaintext) {
+		t.Errorf("unexpected plaintext: %s", string(b))
+	}
+
+	if _, err := c.Decrypt(ciphertext, []byte("file-b")); err == nil {
+		t.Error("the additional data should be authenticated")
+	}
+	if _, err := c.Decrypt(ciphertext[:3], nil); err != ErrMalformedPayload {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := NewAESGCM([]byte("short")); err != ErrInvalidKeySize {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewAESGCM_rotation(t *testing.T) {
+	oldKey := bytes.Repeat([]byte("o"), 16)
+	newKey := bytes.Repeat([]byte("n"), 32)
+
+	old, _ := NewAESGCM(oldKey)
+	ciphertext, _ := old.Encrypt([]byte("supu"), nil)
+
+	rotated, err := NewAESGCM(newKey, oldKey)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if b, err := rotated.Decrypt(ciphertext, nil); err != nil || string(b) != "supu" {
+		t.Errorf("unexpected result: %s, %v", string(b), err)
+	}
+
+	fresh, _ := NewAESGCM(newKey)
+	if _, err := fresh.Decrypt(ciphertext, nil); err != ErrUnknownKey {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	key := bytes.Repeat([]byte{42}, 32)
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+		t.Error(err)
+		return
+	}
+	t.Setenv("LURA_TEST_ENCRYPTION_KEY", hex.EncodeToString(key))
+
+	cfg, ok := ConfigGetter(map[string]interface{}{
+		"encryption": map[string]interface{}{"key": path},
+	})
+	if !ok {
+		t.Error("the config should be parsed")
+		return
+	}
+	fromFile, err := New(cfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	fromEnv, err := New(Config{Provider: "env", Key: "LURA_TEST_ENCRYPTION_KEY"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ciphertext, _ := fromFile.Encrypt([]byte("tupu"), nil)
+	if b, err := fromEnv.Decrypt(ciphertext, nil); err != nil || string(b) != "tupu" {
+		t.Errorf("unexpected result: %s, %v", string(b), err)
+	}
+
+	if _, err := New(Config{Provider: "unknown"}); err == nil {
+		t.Error("error expected")
+	}
+	if _, ok := ConfigGetter(map[string]interface{}{}); ok {
+		t.Error("unexpected config")
+	}
+}
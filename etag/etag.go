@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package etag tags the responses of the endpoints, so the clients can revalidate their copies with
+conditional requests instead of downloading them again.
+
+The endpoints enable the tags in their extra config:
+
+	"extra_config": {
+		"github.com/luraproject/lura/etag": {
+			"weak": false
+		}
+	}
+
+The tags are strong by default and derived from the content of the responses, so the equal
+responses get equal tags. The requests with an If-None-Match header matching the tag of the
+response get a 304 Not Modified without a body. The cached responses keep their digest, so they
+are revalidated without encoding their content again.
+*/
+package etag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the etag config
+const Namespace = "github.com/luraproject/lura/etag"
+
+// Config is the etag config of an endpoint
+type Config struct {
+	// Weak marks the tags as weak, for the endpoints whose responses are transformed by the
+	// intermediaries, like the compressing proxies
+	Weak bool `json:"weak"`
+}
+
+// ConfigGetter parses the etag config from the extra config of an endpoint
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("etag: parsing the config: %w", err)
+	}
+	return cfg, true, nil
+}
+
+// Digest returns the digest of the content of a response. The keys of the maps are sorted by the
+// json encoding, so the equal contents get equal digests
+func Digest(data map[string]interface{}) (string, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Tag returns the tag of a response with the received digest. The variant identifies the
+// representations of the same content, like the negotiated encodings, so they get different tags
+func (c Config) Tag(digest, variant string) string {
+	if variant != "" {
+		sum := sha256.Sum256([]byte(digest + "\x00" + variant))
+		digest = hex.EncodeToString(sum[:])
+	}
+	tag := `"` + digest + `"`
+	if c.Weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// Match returns true if the If-None-Match header matches the tag, with the weak comparison
+// required by the conditional GET requests
+func Match(ifNoneMatch, tag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Recorder keeps the digest of the response of a request, computed by the inner layers of the
+// pipe, like the cache
+type Recorder struct {
+	mu     sync.Mutex
+	digest string
+}
+
+type recorderKey struct{}
+
+// NewContext returns a context carrying a new Recorder
+func NewContext(ctx context.Context) (context.Context, *Recorder) {
+	r := &Recorder{}
+	return context.WithValue(ctx, recorderKey{}, r), r
+}
+
+// FromContext returns the Recorder of the context, if any
+func FromContext(ctx context.Context) (*Recorder, bool) {
+	r, ok := ctx.Value(recorderKey{}).(*Recorder)
+	return r, ok
+}
+
+// SetDigest records the digest of the response
+func (r *Recorder) SetDigest(digest string) {
+	r.mu.Lock()
+	r.digest = digest
+	r.mu.Unlock()
+}
+
+// Digest returns the recorded digest, if any
+func (r *Recorder) Digest() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.digest
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package etag
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok, err := ConfigGetter(config.ExtraConfig{}); ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"weak": true}})
+	if !ok || err != nil || !cfg.Weak {
+		t.Errorf("unexpected result: %+v %v %v", cfg, ok, err)
+	}
+	if _, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"weak": "yes"}}); !ok || err == nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	a, err := Digest(map[string]interface{}{"a": 1, "b": []interface{}{"c", true}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, _ := Digest(map[string]interface{}{"b": []interface{}{"c", true}, "a": 1})
+	if a != b {
+		t.Errorf("equal contents with different digests: %s %s", a, b)
+	}
+	c, _ := Digest(map[string]interface{}{"a": 2, "b": []interface{}{"c", true}})
+	if a == c {
+		t.Error("different contents with the same digest")
+	}
+}
+
+func TestConfig_Tag(t *testing.T) {
+	digest, _ := Digest(map[string]interface{}{"a": 1})
+	strong := Config{}.Tag(digest, "")
+	if strong != `"`+digest+`"` {
+		t.Errorf("unexpected tag: %s", strong)
+	}
+	if weak := (Config{Weak: true}).Tag(digest, ""); weak != "W/"+strong {
+		t.Errorf("unexpected tag: %s", weak)
+	}
+	xml := Config{}.Tag(digest, "application/xml")
+	if xml == strong || !strings.HasPrefix(xml, `"`) {
+		t.Errorf("unexpected tag: %s", xml)
+	}
+	if xml != (Config{}).Tag(digest, "application/xml") {
+		t.Error("the tags of the same variant differ")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	for i, tc := range []struct {
+		header string
+		tag    string
+		match  bool
+	}{
+		{header: "", tag: `"a"`},
+		{header: `"b"`, tag: `"a"`},
+		{header: `"a"`, tag: `"a"`, match: true},
+		{header: `"b", "a"`, tag: `"a"`, match: true},
+		{header: `W/"a"`, tag: `"a"`, match: true},
+		{header: `"a"`, tag: `W/"a"`, match: true},
+		{header: `*`, tag: `"a"`, match: true},
+	} {
+		if m := Match(tc.header, tc.tag); m != tc.match {
+			t.Errorf("#%d: unexpected result %v", i, m)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("unexpected recorder")
+	}
+	ctx, r := NewContext(context.Background())
+	r.SetDigest("abc")
+	recorder, ok := FromContext(ctx)
+	if !ok || recorder.Digest() != "abc" {
+		t.Error("unexpected recorder")
+	}
+}
@@ -0,0 +1,353 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package expression evaluates small expressions over the data of a request, so the endpoints can
+compute new params and headers from the received ones without plugins nor scripts.
+
+The syntax is a subset of CEL:
+
+	headers["Host"].split(".")[0].lower()
+	params.user + "-" + query.page
+	has(headers["X-Tenant"]) ? headers["X-Tenant"] : "public"
+
+The expressions read the method and the path of the request and the params, headers and query
+maps. The missing keys are empty strings, the headers and the query return their first value and
+the params are looked up like in the url patterns, so params.user is the {user} param.
+
+The values are strings, integers, booleans and lists of strings. The operators are +
+(concatenation or addition), -, ==, !=, <, <=, >, >=, !, && and ||, the ternary ?: and the index
+operator of the maps and the lists. The functions can be called as methods of their first
+argument: lower, upper, trim, trimPrefix, trimSuffix, replace, split, join, contains,
+startsWith, endsWith, size, has, default, substr, string and int.
+*/
+package expression
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Data is the request data available to the expressions
+type Data struct {
+	Method  string
+	Path    string
+	Params  map[string]string
+	Headers map[string][]string
+	Query   url.Values
+}
+
+// Expression is a compiled expression. It is safe for concurrent use
+type Expression struct {
+	src  string
+	root node
+}
+
+// Compile parses the expression, checking the functions and the variables it uses
+func Compile(src string) (*Expression, error) {
+	p := &parser{}
+	if err := p.tokenize(src); err != nil {
+		return nil, fmt.Errorf("expression: %q: %w", src, err)
+	}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("expression: %q: empty expression", src)
+	}
+	root, err := p.parseTernary()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expression: %q: %w", src, err)
+	}
+	return &Expression{src: src, root: root}, nil
+}
+
+// String returns the source of the expression
+func (e *Expression) String() string { return e.src }
+
+// Eval returns the value of the expression: a string, an int64, a bool or a []string
+func (e *Expression) Eval(d Data) (interface{}, error) {
+	v, err := e.root.eval(&d)
+	if err != nil {
+		return nil, fmt.Errorf("expression: %q: %w", e.src, err)
+	}
+	return v, nil
+}
+
+// EvalString returns the value of the expression as a string. The lists are joined with commas
+func (e *Expression) EvalString(d Data) (string, error) {
+	v, err := e.Eval(d)
+	if err != nil {
+		return "", err
+	}
+	return toString(v), nil
+}
+
+// ErrType is returned when a value does not have the type required by an operator or a function
+var ErrType = errors.New("type mismatch")
+
+// mapValue is the view of the maps of the request data
+type mapValue func(key string) string
+
+type node interface {
+	eval(d *Data) (interface{}, error)
+}
+
+type literal struct{ v interface{} }
+
+func (l literal) eval(_ *Data) (interface{}, error) { return l.v, nil }
+
+type variable string
+
+func (v variable) eval(d *Data) (interface{}, error) {
+	switch v {
+	case "method":
+		return d.Method, nil
+	case "path":
+		return d.Path, nil
+	case "params":
+		return mapValue(func(k string) string {
+			if v, ok := d.Params[k]; ok || k == "" {
+				return v
+			}
+			return d.Params[strings.ToUpper(k[:1])+k[1:]]
+		}), nil
+	case "headers":
+		return mapValue(func(k string) string { return http.Header(d.Headers).Get(k) }), nil
+	case "query":
+		return mapValue(d.Query.Get), nil
+	}
+	return nil, fmt.Errorf("unknown variable %s", string(v))
+}
+
+type field struct {
+	x    node
+	name string
+}
+
+func (f field) eval(d *Data) (interface{}, error) {
+	x, err := f.x.eval(d)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := x.(mapValue)
+	if !ok {
+		return nil, fmt.Errorf("%w: the field %s of a %s", ErrType, f.name, typeName(x))
+	}
+	return m(f.name), nil
+}
+
+type index struct {
+	x, i node
+}
+
+func (n index) eval(d *Data) (interface{}, error) {
+	x, err := n.x.eval(d)
+	if err != nil {
+		return nil, err
+	}
+	i, err := n.i.eval(d)
+	if err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case mapValue:
+		k, ok := i.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: the maps are indexed with strings, got %s", ErrType, typeName(i))
+		}
+		return x(k), nil
+	case []string:
+		k, ok := i.(int64)
+		if !ok {
+			return nil, fmt.Errorf("%w: the lists are indexed with integers, got %s", ErrType, typeName(i))
+		}
+		if k < 0 {
+			k += int64(len(x))
+		}
+		if k < 0 || k >= int64(len(x)) {
+			return "", nil
+		}
+		return x[k], nil
+	}
+	return nil, fmt.Errorf("%w: can not index a %s", ErrType, typeName(x))
+}
+
+type not struct{ x node }
+
+func (n not) eval(d *Data) (interface{}, error) {
+	x, err := n.x.eval(d)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(x), nil
+}
+
+type binary struct {
+	op   string
+	l, r node
+}
+
+func (b binary) eval(d *Data) (interface{}, error) {
+	l, err := b.l.eval(d)
+	if err != nil {
+		return nil, err
+	}
+	// the logical operators short-circuit
+	switch b.op {
+	case "&&":
+		if !truthy(l) {
+			return false, nil
+		}
+		r, err := b.r.eval(d)
+		return err == nil && truthy(r), err
+	case "||":
+		if truthy(l) {
+			return true, nil
+		}
+		r, err := b.r.eval(d)
+		return err == nil && truthy(r), err
+	}
+
+	r, err := b.r.eval(d)
+	if err != nil {
+		return nil, err
+	}
+	switch b.op {
+	case "+":
+		li, lok := l.(int64)
+		ri, rok := r.(int64)
+		if lok && rok {
+			return li + ri, nil
+		}
+		return toString(l) + toString(r), nil
+	case "-":
+		li, lok := l.(int64)
+		ri, rok := r.(int64)
+		if !lok || !rok {
+			return nil, fmt.Errorf("%w: can not subtract a %s from a %s", ErrType, typeName(r), typeName(l))
+		}
+		return li - ri, nil
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	}
+
+	var c int
+	li, lok := l.(int64)
+	ri, rok := r.(int64)
+	if lok && rok {
+		switch {
+		case li < ri:
+			c = -1
+		case li > ri:
+			c = 1
+		}
+	} else {
+		c = strings.Compare(toString(l), toString(r))
+	}
+	switch b.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	}
+	return c >= 0, nil
+}
+
+type ternary struct {
+	cond, then, otherwise node
+}
+
+func (t ternary) eval(d *Data) (interface{}, error) {
+	c, err := t.cond.eval(d)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(c) {
+		return t.then.eval(d)
+	}
+	return t.otherwise.eval(d)
+}
+
+type call struct {
+	fn   function
+	args []node
+}
+
+func (c call) eval(d *Data) (interface{}, error) {
+	args := make([]interface{}, len(c.args))
+	for i, a := range c.args {
+		v, err := a.eval(d)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return c.fn.call(args)
+}
+
+// truthy returns false for the empty strings, the zero, false, "false", "0" and the empty lists
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case int64:
+		return v != 0
+	case string:
+		return v != "" && v != "false" && v != "0"
+	case []string:
+		return len(v) > 0
+	}
+	return v != nil
+}
+
+func equal(l, r interface{}) bool {
+	li, lok := l.(int64)
+	ri, rok := r.(int64)
+	if lok && rok {
+		return li == ri
+	}
+	lb, lok := l.(bool)
+	rb, rok := r.(bool)
+	if lok && rok {
+		return lb == rb
+	}
+	return toString(l) == toString(r)
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case []string:
+		return strings.Join(v, ",")
+	}
+	return ""
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case int64:
+		return "int"
+	case bool:
+		return "bool"
+	case []string:
+		return "list"
+	case mapValue:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package expression
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+var data = Data{
+	Method:  "GET",
+	Path:    "/users/42",
+	Params:  map[string]string{"User": "42", "Org": "Acme"},
+	Headers: map[string][]string{"Host": {"eu.acme.example.com"}, "X-User": {"Alice", "Bob"}},
+	Query:   url.Values{"page": {"3"}},
+}
+
+func TestExpression_Eval(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want interface{}
+	}{
+		{`method`, "GET"},
+		{`path`, "/users/42"},
+		{`params.user`, "42"},
+		{`params["Org"].lower()`, "acme"},
+		{`headers["x-user"]`, "Alice"},
+		{`lower(headers["X-User"])`, "alice"},
+		{`headers["Host"].split(".")[0]`, "eu"},
+		{`headers["Host"].split(".")[-1]`, "com"},
+		{`headers["Host"].split(".")[9]`, ""},
+		{`params.user + "-" + query.page`, "42-3"},
+		{`int(query.page) + 1`, int64(4)},
+		{`size(params.org) - int(query.page) - 1`, int64(0)},
+		{`query.missing`, ""},
+		{`has(headers["X-Tenant"]) ? headers["X-Tenant"] : "public"`, "public"},
+		{`headers["X-Tenant"].default(params.org).upper()`, "ACME"},
+		{`method == "GET" && !has(query.debug)`, true},
+		{`method != 'GET' || size(params.org) > 3`, true},
+		{`int(query.page) >= 10`, false},
+		{`"b" < "a"`, false},
+		{`(1 + 2 == 3) ? "yes" : "no"`, "yes"},
+		{`split("a,b,c", ",").join("|")`, "a|b|c"},
+		{`'it\'s'`, "it's"},
+		{`true ? false ? "a" : "b" : "c"`, "b"},
+	} {
+		e, err := Compile(tc.src)
+		if err != nil {
+			t.Errorf("%s: %v", tc.src, err)
+			continue
+		}
+		v, err := e.Eval(data)
+		if err != nil {
+			t.Errorf("%s: %v", tc.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, tc.want) {
+			t.Errorf("%s: have %#v, want %#v", tc.src, v, tc.want)
+		}
+	}
+}
+
+func TestExpression_EvalString(t *testing.T) {
+	e, _ := Compile(`split(headers["Host"], ".")`)
+	if s, err := e.EvalString(data); err != nil || s != "eu,acme,example,com" {
+		t.Errorf("unexpected result: %q, %v", s, err)
+	}
+	e, _ = Compile(`int(params.user) + 1`)
+	if s, err := e.EvalString(data); err != nil || s != "43" {
+		t.Errorf("unexpected result: %q, %v", s, err)
+	}
+}
+
+func TestCompile_invalid(t *testing.T) {
+	for _, src := range []string{
+		``,
+		`body.id`,
+		`unknown(method)`,
+		`lower()`,
+		`lower(method, path)`,
+		`method ==`,
+		`(method`,
+		`headers["Host"`,
+		`"unterminated`,
+		`method # path`,
+		`method ? "a"`,
+		`params.`,
+		`method path`,
+	} {
+		if _, err := Compile(src); err == nil {
+			t.Errorf("expecting an error for %q", src)
+		}
+	}
+}
+
+func TestExpression_Eval_typeErrors(t *testing.T) {
+	for _, src := range []string{
+		`method.name`,
+		`params[1]`,
+		`split(path, "/")["a"]`,
+		`method[0]`,
+		`int(path)`,
+		`lower(split(path, "/"))`,
+		`path - 1`,
+	} {
+		e, err := Compile(src)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		if _, err := e.Eval(data); !errors.Is(err, ErrType) {
+			t.Errorf("%s: unexpected error %v", src, err)
+		}
+	}
+}
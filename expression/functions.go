@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package expression
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type function struct {
+	min, max int
+	fn       func(args []interface{}) (interface{}, error)
+}
+
+func (f function) call(args []interface{}) (interface{}, error) {
+	return f.fn(args)
+}
+
+var functions = map[string]function{
+	"lower":      stringFunc(strings.ToLower),
+	"upper":      stringFunc(strings.ToUpper),
+	"trim":       stringFunc(strings.TrimSpace),
+	"trimPrefix": stringsFunc(2, func(s []string) interface{} { return strings.TrimPrefix(s[0], s[1]) }),
+	"trimSuffix": stringsFunc(2, func(s []string) interface{} { return strings.TrimSuffix(s[0], s[1]) }),
+	"replace":    stringsFunc(3, func(s []string) interface{} { return strings.ReplaceAll(s[0], s[1], s[2]) }),
+	"split":      stringsFunc(2, func(s []string) interface{} { return strings.Split(s[0], s[1]) }),
+	"contains":   stringsFunc(2, func(s []string) interface{} { return strings.Contains(s[0], s[1]) }),
+	"startsWith": stringsFunc(2, func(s []string) interface{} { return strings.HasPrefix(s[0], s[1]) }),
+	"endsWith":   stringsFunc(2, func(s []string) interface{} { return strings.HasSuffix(s[0], s[1]) }),
+	"string":     {min: 1, max: 1, fn: func(a []interface{}) (interface{}, error) { return toString(a[0]), nil }},
+	"has":        {min: 1, max: 1, fn: func(a []interface{}) (interface{}, error) { return toString(a[0]) != "", nil }},
+	"join":       {min: 2, max: 2, fn: join},
+	"size":       {min: 1, max: 1, fn: size},
+	"default":    {min: 2, max: 2, fn: defaultValue},
+	"substr":     {min: 2, max: 3, fn: substr},
+	"int":        {min: 1, max: 1, fn: toInt},
+}
+
+func newCall(name string, args []node) (node, error) {
+	f, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+	if len(args) < f.min || len(args) > f.max {
+		return nil, fmt.Errorf("wrong number of arguments for %s: %d", name, len(args))
+	}
+	return call{fn: f, args: args}, nil
+}
+
+func stringFunc(fn func(string) string) function {
+	return stringsFunc(1, func(s []string) interface{} { return fn(s[0]) })
+}
+
+// stringsFunc adapts the functions receiving n strings. The integers and the booleans are
+// converted to strings
+func stringsFunc(n int, fn func([]string) interface{}) function {
+	return function{min: n, max: n, fn: func(args []interface{}) (interface{}, error) {
+		s := make([]string, n)
+		for i, a := range args {
+			if _, ok := a.([]string); ok {
+				return nil, fmt.Errorf("%w: expecting a string, got a list", ErrType)
+			}
+			s[i] = toString(a)
+		}
+		return fn(s), nil
+	}}
+}
+
+func join(args []interface{}) (interface{}, error) {
+	switch l := args[0].(type) {
+	case []string:
+		return strings.Join(l, toString(args[1])), nil
+	case string:
+		return l, nil
+	}
+	return nil, fmt.Errorf("%w: join expects a list, got %s", ErrType, typeName(args[0]))
+}
+
+func size(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case []string:
+		return int64(len(v)), nil
+	case string:
+		return int64(utf8.RuneCountInString(v)), nil
+	}
+	return nil, fmt.Errorf("%w: size expects a string or a list, got %s", ErrType, typeName(args[0]))
+}
+
+func defaultValue(args []interface{}) (interface{}, error) {
+	if toString(args[0]) == "" {
+		return args[1], nil
+	}
+	return args[0], nil
+}
+
+// substr returns the runes of the string from the start index to the end one, or to the end of
+// the string. The indexes out of the string are clamped
+func substr(args []interface{}) (interface{}, error) {
+	r := []rune(toString(args[0]))
+	bound := func(v interface{}) (int, error) {
+		i, ok := v.(int64)
+		if !ok {
+			return 0, fmt.Errorf("%w: substr expects integer indexes, got %s", ErrType, typeName(v))
+		}
+		switch {
+		case i < 0:
+			return 0, nil
+		case i > int64(len(r)):
+			return len(r), nil
+		}
+		return int(i), nil
+	}
+	start, err := bound(args[1])
+	if err != nil {
+		return nil, err
+	}
+	end := len(r)
+	if len(args) == 3 {
+		if end, err = bound(args[2]); err != nil {
+			return nil, err
+		}
+	}
+	if end < start {
+		return "", nil
+	}
+	return string(r[start:end]), nil
+}
+
+func toInt(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case int64:
+		return v, nil
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not an integer", ErrType, v)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("%w: can not convert a %s to an integer", ErrType, typeName(args[0]))
+}
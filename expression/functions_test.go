@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package expression
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFunctions(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		args []interface{}
+		want interface{}
+	}{
+		{"upper", []interface{}{"abc"}, "ABC"},
+		{"trim", []interface{}{"  abc "}, "abc"},
+		{"trimPrefix", []interface{}{"tenant-acme", "tenant-"}, "acme"},
+		{"trimSuffix", []interface{}{"acme.example.com", ".example.com"}, "acme"},
+		{"replace", []interface{}{"a-b-c", "-", "_"}, "a_b_c"},
+		{"contains", []interface{}{"abc", "b"}, true},
+		{"startsWith", []interface{}{"abc", "b"}, false},
+		{"endsWith", []interface{}{"abc", "c"}, true},
+		{"string", []interface{}{int64(42)}, "42"},
+		{"size", []interface{}{[]string{"a", "b"}}, int64(2)},
+		{"size", []interface{}{"añb"}, int64(3)},
+		{"default", []interface{}{"", "x"}, "x"},
+		{"default", []interface{}{"y", "x"}, "y"},
+		{"substr", []interface{}{"gateway", int64(0), int64(4)}, "gate"},
+		{"substr", []interface{}{"gateway", int64(4)}, "way"},
+		{"substr", []interface{}{"gateway", int64(5), int64(99)}, "ay"},
+		{"substr", []interface{}{"gateway", int64(5), int64(2)}, ""},
+		{"int", []interface{}{true}, int64(1)},
+		{"join", []interface{}{"single", ","}, "single"},
+	} {
+		v, err := functions[tc.name].call(tc.args)
+		if err != nil {
+			t.Errorf("%s%v: %v", tc.name, tc.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, tc.want) {
+			t.Errorf("%s%v: have %#v, want %#v", tc.name, tc.args, v, tc.want)
+		}
+	}
+}
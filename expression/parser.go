@@ -0,0 +1,312 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package expression
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenString
+	tokenInt
+	tokenOp
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+var variables = map[string]bool{"method": true, "path": true, "params": true, "headers": true, "query": true}
+
+// operators are sorted by length, so the longest one matches first
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "(", ")", "[", "]", ".", ",", "?", ":", "+", "-", "!", "<", ">"}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) tokenize(src string) error {
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			s, n, err := unquote(src[i:])
+			if err != nil {
+				return err
+			}
+			p.tokens = append(p.tokens, token{kind: tokenString, value: s})
+			i += n
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+				j++
+			}
+			p.tokens = append(p.tokens, token{kind: tokenInt, value: src[i:j]})
+			i = j
+		case isIdentStart(c):
+			j := i
+			for j < len(src) && (isIdentStart(src[j]) || (src[j] >= '0' && src[j] <= '9')) {
+				j++
+			}
+			p.tokens = append(p.tokens, token{kind: tokenIdent, value: src[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+			p.tokens = append(p.tokens, token{kind: tokenOp, value: op})
+			i += len(op)
+		}
+	}
+	return nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// unquote returns the string literal at the start of src and its length in the source
+func unquote(src string) (string, int, error) {
+	quote := src[0]
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch c := src[i]; c {
+		case quote:
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 == len(src) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			i++
+			switch e := src[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func (p *parser) peek(op string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenOp && p.tokens[p.pos].value == op
+}
+
+func (p *parser) accept(ops ...string) (string, bool) {
+	for _, op := range ops {
+		if p.peek(op) {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); ok {
+		return nil
+	}
+	if p.pos < len(p.tokens) {
+		return fmt.Errorf("expecting %q, got %q", op, p.tokens[p.pos].value)
+	}
+	return fmt.Errorf("expecting %q at the end of the expression", op)
+}
+
+func (p *parser) parseTernary() (node, error) {
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return cond, nil
+	}
+	then, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	return ternary{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+// parseBinary parses the left-associative operators of a precedence level
+func (p *parser) parseBinary(next func() (node, error), ops ...string) (node, error) {
+	l, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return l, nil
+		}
+		r, err := next()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{op: op, l: l, r: r}
+	}
+}
+
+func (p *parser) parseOr() (node, error) { return p.parseBinary(p.parseAnd, "||") }
+
+func (p *parser) parseAnd() (node, error) { return p.parseBinary(p.parseCompare, "&&") }
+
+func (p *parser) parseCompare() (node, error) {
+	return p.parseBinary(p.parseAdd, "==", "!=", "<=", ">=", "<", ">")
+}
+
+func (p *parser) parseAdd() (node, error) { return p.parseBinary(p.parseUnary, "+", "-") }
+
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.accept("-"); ok {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return binary{op: "-", l: literal{v: int64(0)}, r: x}, nil
+	}
+	if _, ok := p.accept("!"); ok {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return not{x: x}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.peek("."):
+			p.pos++
+			if p.pos == len(p.tokens) || p.tokens[p.pos].kind != tokenIdent {
+				return nil, fmt.Errorf("expecting a name after the dot")
+			}
+			name := p.tokens[p.pos].value
+			p.pos++
+			if !p.peek("(") {
+				x = field{x: x, name: name}
+				continue
+			}
+			// the methods are the functions receiving the value as their first argument
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			if x, err = newCall(name, append([]node{x}, args...)); err != nil {
+				return nil, err
+			}
+		case p.peek("["):
+			p.pos++
+			i, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = index{x: x, i: i}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) parseArgs() ([]node, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []node
+	if _, ok := p.accept(")"); ok {
+		return args, nil
+	}
+	for {
+		a, err := p.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+		if _, ok := p.accept(")"); ok {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	if p.pos == len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of the expression")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	switch t.kind {
+	case tokenString:
+		return literal{v: t.value}, nil
+	case tokenInt:
+		n, err := strconv.ParseInt(t.value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return literal{v: n}, nil
+	case tokenIdent:
+		switch t.value {
+		case "true":
+			return literal{v: true}, nil
+		case "false":
+			return literal{v: false}, nil
+		}
+		if p.peek("(") {
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			return newCall(t.value, args)
+		}
+		if !variables[t.value] {
+			return nil, fmt.Errorf("unknown variable %s", t.value)
+		}
+		return variable(t.value), nil
+	}
+	if t.value == "(" {
+		x, err := p.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	}
+	return nil, fmt.Errorf("unexpected %q", t.value)
+}
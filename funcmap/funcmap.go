@@ -0,0 +1,232 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package funcmap holds the functions available to all the templates of the gateway: the url patterns
+of the backends, the bodies of the SOAP envelopes and the queue messages, the error templates and
+the access log template.
+
+It includes a set of helpers similar to the ones of sprig:
+
+	{{ lower .Params.Id }}
+	{{ .Query.page | default "1" }}
+	{{ json .Body }}
+
+and an API to register custom functions once, before the config is parsed, so they can be used in
+all the templates:
+
+	funcmap.Register("tenant", func(host string) string { return strings.Split(host, ".")[0] })
+
+The url patterns only run the templates when they call a function ({{ upper .Id }}), so the plain
+params ({{.Id}}) keep their usual replacement.
+*/
+package funcmap
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// ErrInvalidFunction is returned when registering a value that can not be used in a template
+var ErrInvalidFunction = errors.New("funcmap: invalid function")
+
+// ErrBuiltinFunction is returned when registering a function with the name of one of the builtin
+// functions of the templates, like and, index or printf
+var ErrBuiltinFunction = errors.New("funcmap: the name is reserved by the templates")
+
+var builtins = map[string]bool{
+	"and": true, "call": true, "html": true, "index": true, "slice": true, "js": true, "len": true,
+	"not": true, "or": true, "print": true, "printf": true, "println": true, "urlquery": true,
+	"eq": true, "ge": true, "gt": true, "le": true, "lt": true, "ne": true,
+}
+
+var (
+	mu    sync.RWMutex
+	funcs = defaultFuncs()
+)
+
+// Register adds a function to the templates, replacing the previous one with the same name. The
+// function must return one value, or a value and an error, as required by text/template. The
+// templates parsed before the registration do not see it
+func Register(name string, fn interface{}) error {
+	if builtins[name] {
+		return fmt.Errorf("%w: %s", ErrBuiltinFunction, name)
+	}
+	if name == "" || !isValidFunction(fn) {
+		return fmt.Errorf("%w: %s", ErrInvalidFunction, name)
+	}
+	mu.Lock()
+	funcs[name] = fn
+	mu.Unlock()
+	return nil
+}
+
+// FuncMap returns a copy of the registered functions
+func FuncMap() template.FuncMap {
+	mu.RLock()
+	defer mu.RUnlock()
+	res := make(template.FuncMap, len(funcs))
+	for k, v := range funcs {
+		res[k] = v
+	}
+	return res
+}
+
+// New returns a template with the registered functions
+func New(name string) *template.Template {
+	return template.New(name).Funcs(FuncMap())
+}
+
+// Reset removes the custom functions, restoring the default ones
+func Reset() {
+	mu.Lock()
+	funcs = defaultFuncs()
+	mu.Unlock()
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func isValidFunction(fn interface{}) bool {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func {
+		return false
+	}
+	switch t.NumOut() {
+	case 1:
+		return true
+	case 2:
+		return t.Out(1) == errorType
+	}
+	return false
+}
+
+func defaultFuncs() template.FuncMap {
+	return template.FuncMap{
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"title":      title,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+		"quote":      strconv.Quote,
+		"default":    defaultValue,
+		"toString":   func(v interface{}) string { return fmt.Sprint(v) },
+		"toInt":      toInt,
+		"add":        func(a, b interface{}) (int64, error) { return arith(a, b, func(x, y int64) int64 { return x + y }) },
+		"sub":        func(a, b interface{}) (int64, error) { return arith(a, b, func(x, y int64) int64 { return x - y }) },
+		"json":       toJSON,
+		"toJson":     toJSON,
+		"b64enc":     func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"b64dec":     b64dec,
+		"sha256sum":  func(s string) string { sum := sha256.Sum256([]byte(s)); return hex.EncodeToString(sum[:]) },
+		"now":        time.Now,
+		"date":       date,
+	}
+}
+
+// title uppercases the first letter of every word. The casers are stateful, so every call gets
+// its own
+func title(s string) string {
+	return cases.Title(language.Und, cases.NoLower).String(s)
+}
+
+// join accepts the slices of strings and the decoded JSON arrays
+func join(sep string, v interface{}) string {
+	switch v := v.(type) {
+	case []string:
+		return strings.Join(v, sep)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, p := range v {
+			parts[i] = fmt.Sprint(p)
+		}
+		return strings.Join(parts, sep)
+	}
+	return fmt.Sprint(v)
+}
+
+// defaultValue returns the default if the value is empty, so it can be piped:
+// {{ .Query.page | default "1" }}
+func defaultValue(def interface{}, v ...interface{}) interface{} {
+	if len(v) == 0 || isEmpty(v[0]) {
+		return def
+	}
+	return v[0]
+}
+
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
+
+func toInt(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	case json.Number:
+		return v.Int64()
+	}
+	return 0, fmt.Errorf("funcmap: can not convert %T to an integer", v)
+}
+
+func arith(a, b interface{}, op func(x, y int64) int64) (int64, error) {
+	x, err := toInt(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := toInt(b)
+	if err != nil {
+		return 0, err
+	}
+	return op(x, y), nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func b64dec(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	return string(b), err
+}
+
+// date formats the time with the layout of the time package
+func date(layout string, t time.Time) string {
+	return t.Format(layout)
+}
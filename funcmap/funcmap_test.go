@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package funcmap
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestNew_defaultFuncs(t *testing.T) {
+	data := map[string]interface{}{
+		"Id":    "Supu",
+		"Empty": "",
+		"Tags":  []interface{}{"a", "b"},
+		"Body":  map[string]interface{}{"n": 1},
+		"Num":   "41",
+	}
+	for tmpl, expected := range map[string]string{
+		`{{ lower .Id }}`:                       "supu",
+		`{{ upper .Id }}`:                       "SUPU",
+		`{{ title "supu tupu" }}`:               "Supu Tupu",
+		`{{ .Empty | default "none" }}`:         "none",
+		`{{ .Id | default "none" }}`:            "Supu",
+		`{{ join "," .Tags }}`:                  "a,b",
+		`{{ json .Body }}`:                      `{"n":1}`,
+		`{{ add .Num 1 }}`:                      "42",
+		`{{ .Id | replace "u" "o" }}`:           "Sopo",
+		`{{ b64enc .Id | b64dec }}`:             "Supu",
+		`{{ .Id | trimPrefix "Su" }}`:           "pu",
+		`{{ if hasPrefix "Su" .Id }}y{{ end }}`: "y",
+	} {
+		buf := new(bytes.Buffer)
+		if err := mustParse(t, tmpl).Execute(buf, data); err != nil {
+			t.Errorf("%s: %v", tmpl, err)
+			continue
+		}
+		if buf.String() != expected {
+			t.Errorf("%s: unexpected result %q", tmpl, buf.String())
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer Reset()
+
+	if err := Register("shout", func(s string) string { return strings.ToUpper(s) + "!" }); err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := mustParse(t, `{{ shout .Id }}`).Execute(buf, map[string]string{"Id": "supu"}); err != nil || buf.String() != "SUPU!" {
+		t.Errorf("unexpected result: %s %v", buf.String(), err)
+	}
+
+	if err := Register("printf", func() string { return "" }); !errors.Is(err, ErrBuiltinFunction) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, fn := range []interface{}{nil, "supu", func() {}, func() (string, string) { return "", "" }} {
+		if err := Register("invalid", fn); !errors.Is(err, ErrInvalidFunction) {
+			t.Errorf("unexpected error for %T: %v", fn, err)
+		}
+	}
+
+	Reset()
+	if _, ok := FuncMap()["shout"]; ok {
+		t.Error("the custom functions should be removed")
+	}
+}
+
+func mustParse(t *testing.T, s string) *template.Template {
+	tmpl, err := New("test").Parse(s)
+	if err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	return tmpl
+}
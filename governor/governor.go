@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package governor bounds the memory used by the gateway, tracking the requests in flight and the
+bytes of the bodies they keep in memory, so a burst of huge aggregations can not exhaust it.
+
+The ceilings are declared in the service extra config:
+
+	"extra_config": {
+		"github.com/luraproject/lura/governor": {
+			"max_in_flight": 5000,
+			"max_buffered_bytes": 536870912
+		}
+	}
+
+The new requests are rejected with a 503 Service Unavailable while any of the ceilings is
+reached. The bytes of the request bodies and of the backend responses decoded by the gateway are
+reserved as they are read, and the reads exceeding the max_buffered_bytes fail, so the requests
+already admitted can not exceed it either. The reservations of a request are released when it is
+done.
+*/
+package governor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the governor config
+const Namespace = "github.com/luraproject/lura/governor"
+
+// ErrOverloaded is returned when a request is rejected because a ceiling is reached
+var ErrOverloaded = OverloadedError{}
+
+// OverloadedError is the error returned when a request is rejected because a ceiling is reached
+type OverloadedError struct{}
+
+// Error implements the error interface
+func (OverloadedError) Error() string { return "governor: the gateway reached its ceilings" }
+
+// StatusCode returns the 503 Service Unavailable status code
+func (OverloadedError) StatusCode() int { return http.StatusServiceUnavailable }
+
+// ErrBufferFull is returned when reading a body would exceed the max buffered bytes
+var ErrBufferFull = BufferFullError{}
+
+// BufferFullError is the error returned when reading a body would exceed the max buffered bytes
+type BufferFullError struct{}
+
+// Error implements the error interface
+func (BufferFullError) Error() string { return "governor: too many bytes buffered" }
+
+// StatusCode returns the 503 Service Unavailable status code
+func (BufferFullError) StatusCode() int { return http.StatusServiceUnavailable }
+
+// Config is the governor config of the service
+type Config struct {
+	MaxInFlight      int   `json:"max_in_flight"`
+	MaxBufferedBytes int64 `json:"max_buffered_bytes"`
+}
+
+// ConfigGetter parses the governor config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("governor: parsing the config: %w", err)
+	}
+	return cfg, true, nil
+}
+
+// Governor tracks the requests in flight and their buffered bytes
+type Governor struct {
+	maxInFlight int64
+	maxBuffered int64
+
+	inFlight int64
+	buffered int64
+}
+
+// New returns the Governor of the config
+func New(cfg Config) (*Governor, error) {
+	if cfg.MaxInFlight < 0 || cfg.MaxBufferedBytes < 0 {
+		return nil, errors.New("governor: the ceilings can not be negative")
+	}
+	if cfg.MaxInFlight == 0 && cfg.MaxBufferedBytes == 0 {
+		return nil, errors.New("governor: no max_in_flight nor max_buffered_bytes declared")
+	}
+	return &Governor{maxInFlight: int64(cfg.MaxInFlight), maxBuffered: cfg.MaxBufferedBytes}, nil
+}
+
+// Admit admits a new request, unless any of the ceilings is reached. The Done method of the
+// returned ticket must be called once the request is done
+func (g *Governor) Admit() (*Ticket, error) {
+	if g.maxBuffered > 0 && atomic.LoadInt64(&g.buffered) >= g.maxBuffered {
+		return nil, ErrOverloaded
+	}
+	if n := atomic.AddInt64(&g.inFlight, 1); g.maxInFlight > 0 && n > g.maxInFlight {
+		atomic.AddInt64(&g.inFlight, -1)
+		return nil, ErrOverloaded
+	}
+	return &Ticket{g: g}, nil
+}
+
+// InFlight returns the number of requests in flight
+func (g *Governor) InFlight() int { return int(atomic.LoadInt64(&g.inFlight)) }
+
+// Buffered returns the number of bytes reserved by the requests in flight
+func (g *Governor) Buffered() int64 { return atomic.LoadInt64(&g.buffered) }
+
+func (g *Governor) reserve(n int64) bool {
+	for {
+		cur := atomic.LoadInt64(&g.buffered)
+		if g.maxBuffered > 0 && cur+n > g.maxBuffered {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&g.buffered, cur, cur+n) {
+			return true
+		}
+	}
+}
+
+// Ticket is the admission of a request. It is safe for concurrent use, so the backends of the
+// request can reserve bytes in parallel
+type Ticket struct {
+	g        *Governor
+	reserved int64
+	done     int32
+}
+
+// Reserve reserves n bytes for the request, or returns ErrBufferFull if it would exceed the max
+// buffered bytes
+func (t *Ticket) Reserve(n int64) error {
+	if n <= 0 {
+		return nil
+	}
+	if !t.g.reserve(n) {
+		return ErrBufferFull
+	}
+	atomic.AddInt64(&t.reserved, n)
+	return nil
+}
+
+// Done releases the request and its reserved bytes. The calls after the first one do nothing
+func (t *Ticket) Done() {
+	if !atomic.CompareAndSwapInt32(&t.done, 0, 1) {
+		return
+	}
+	atomic.AddInt64(&t.g.inFlight, -1)
+	atomic.AddInt64(&t.g.buffered, -atomic.SwapInt64(&t.reserved, 0))
+}
+
+type ticketKey struct{}
+
+// NewContext returns a copy of the context carrying the ticket
+func NewContext(ctx context.Context, t *Ticket) context.Context {
+	return context.WithValue(ctx, ticketKey{}, t)
+}
+
+// FromContext returns the ticket of the request, if any
+func FromContext(ctx context.Context) (*Ticket, bool) {
+	t, ok := ctx.Value(ticketKey{}).(*Ticket)
+	return t, ok
+}
+
+// NewReader returns a body reserving the bytes read from rc with the ticket of the context. It
+// returns rc if the context does not carry a ticket
+func NewReader(ctx context.Context, rc io.ReadCloser) io.ReadCloser {
+	t, ok := FromContext(ctx)
+	if !ok || rc == nil || rc == http.NoBody {
+		return rc
+	}
+	return &reader{ReadCloser: rc, t: t}
+}
+
+type reader struct {
+	io.ReadCloser
+	t *Ticket
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if rerr := r.t.Reserve(int64(n)); rerr != nil {
+			return 0, rerr
+		}
+	}
+	return n, err
+}
+
+var (
+	global   *Governor
+	globalMu sync.RWMutex
+)
+
+// Register creates the governor declared in the service extra config. It returns false if the
+// service does not declare the governor config
+func Register(cfg config.ServiceConfig) (bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	var g *Governor
+	if ok && err == nil {
+		g, err = New(c)
+	}
+	SetGlobal(g)
+	return ok, err
+}
+
+// SetGlobal sets the governor used by the router and the backends
+func SetGlobal(g *Governor) {
+	globalMu.Lock()
+	global = g
+	globalMu.Unlock()
+}
+
+// GetGlobal returns the governor used by the router and the backends, if any
+func GetGlobal() (*Governor, bool) {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return global, global != nil
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package governor
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestGovernor_inFlight(t *testing.T) {
+	g, err := New(Config{MaxInFlight: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t1, err := g.Admit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2, err := g.Admit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Admit(); err != ErrOverloaded {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if g.InFlight() != 2 {
+		t.Errorf("unexpected requests in flight: %d", g.InFlight())
+	}
+	t1.Done()
+	t1.Done()
+	if g.InFlight() != 1 {
+		t.Errorf("the ticket was released twice: %d", g.InFlight())
+	}
+	if _, err := g.Admit(); err != nil {
+		t.Error(err)
+	}
+	t2.Done()
+}
+
+func TestGovernor_buffered(t *testing.T) {
+	g, _ := New(Config{MaxBufferedBytes: 10})
+	t1, _ := g.Admit()
+	if err := t1.Reserve(8); err != nil {
+		t.Fatal(err)
+	}
+	if err := t1.Reserve(3); err != ErrBufferFull {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := t1.Reserve(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Admit(); err != ErrOverloaded {
+		t.Errorf("the full buffer must reject the new requests: %v", err)
+	}
+	t1.Done()
+	if g.Buffered() != 0 || g.InFlight() != 0 {
+		t.Errorf("the reservations were not released. buffered: %d, in flight: %d", g.Buffered(), g.InFlight())
+	}
+}
+
+func TestGovernor_concurrent(t *testing.T) {
+	g, _ := New(Config{MaxInFlight: 1000, MaxBufferedBytes: 1 << 20})
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tk, err := g.Admit()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for j := 0; j < 10; j++ {
+				tk.Reserve(100)
+			}
+			tk.Done()
+		}()
+	}
+	wg.Wait()
+	if g.Buffered() != 0 || g.InFlight() != 0 {
+		t.Errorf("the reservations were not released. buffered: %d, in flight: %d", g.Buffered(), g.InFlight())
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	g, _ := New(Config{MaxBufferedBytes: 10})
+	tk, _ := g.Admit()
+	defer tk.Done()
+
+	body := io.NopCloser(strings.NewReader("0123456789abcdef"))
+	if NewReader(context.Background(), body) != body {
+		t.Error("the bodies without a ticket must not be wrapped")
+	}
+
+	r := NewReader(NewContext(context.Background(), tk), body)
+	b, err := io.ReadAll(r)
+	if err != ErrBufferFull {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(b) > 10 {
+		t.Errorf("the reader exceeded the max buffered bytes: %s", b)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer SetGlobal(nil)
+	if ok, err := Register(config.ServiceConfig{}); ok || err != nil {
+		t.Errorf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if _, ok := GetGlobal(); ok {
+		t.Error("the service does not declare a governor")
+	}
+	if ok, err := Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{},
+	}}); !ok || err == nil {
+		t.Errorf("expecting an error for the missing ceilings. ok: %v, err: %v", ok, err)
+	}
+	if ok, err := Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{"max_in_flight": 10},
+	}}); !ok || err != nil {
+		t.Errorf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if _, ok := GetGlobal(); !ok {
+		t.Error("the governor was not registered")
+	}
+}
@@ -0,0 +1,717 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package jsonschema validates the responses against a JSON Schema, protecting the clients from the
+changes of the contracts of the upstream services.
+
+The endpoints and the backends declare the schema, inline or in a file, and what to do with the
+invalid responses:
+
+	"extra_config": {
+		"github.com/luraproject/lura/jsonschema": {
+			"schema": {
+				"type": "object",
+				"required": ["id"],
+				"properties": {
+					"id": {"type": "integer"},
+					"email": {"type": "string", "pattern": "@"}
+				}
+			},
+			"mode": "strip"
+		}
+	}
+
+The reject mode, the default one, fails the invalid responses. The strip mode removes the fields
+not declared by the schema and logs the remaining violations, and the log mode just logs them.
+
+The validator supports the type, enum, const, properties, required, additionalProperties,
+patternProperties, minProperties, maxProperties, items, minItems, maxItems, uniqueItems,
+minLength, maxLength, pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf,
+allOf, anyOf, oneOf, not and local $ref keywords of the draft-07 specification. The rest of the
+keywords, like format, are ignored.
+*/
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the response validation config
+const Namespace = "github.com/luraproject/lura/jsonschema"
+
+const (
+	// ModeReject fails the invalid responses
+	ModeReject = "reject"
+	// ModeStrip removes the fields not declared by the schema and logs the remaining violations
+	ModeStrip = "strip"
+	// ModeLog logs the violations without changing the responses
+	ModeLog = "log"
+)
+
+// Config is the response validation config of an endpoint or a backend
+type Config struct {
+	Schema *Schema
+	Mode   string
+}
+
+type rawConfig struct {
+	Schema     map[string]interface{} `json:"schema"`
+	SchemaPath string                 `json:"schema_path"`
+	Mode       string                 `json:"mode"`
+}
+
+// ConfigGetter parses the response validation config from the extra config, compiling its schema
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{Mode: ModeReject}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	var raw rawConfig
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return cfg, true, fmt.Errorf("jsonschema: parsing the config: %w", err)
+	}
+	switch raw.Mode {
+	case "":
+	case ModeReject, ModeStrip, ModeLog:
+		cfg.Mode = raw.Mode
+	default:
+		return cfg, true, fmt.Errorf("jsonschema: unknown mode %s", raw.Mode)
+	}
+
+	if raw.Schema == nil && raw.SchemaPath == "" {
+		return cfg, true, fmt.Errorf("jsonschema: no schema declared")
+	}
+	cfg.Schema, err = Load(raw.Schema, raw.SchemaPath)
+	return cfg, true, err
+}
+
+// Load compiles the inline schema or, if the path is not empty, the schema stored in the file
+func Load(doc map[string]interface{}, path string) (*Schema, error) {
+	if path != "" {
+		if doc != nil {
+			return nil, fmt.Errorf("jsonschema: both an inline schema and the file %s declared", path)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("jsonschema: reading the schema: %w", err)
+		}
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return nil, fmt.Errorf("jsonschema: parsing the schema %s: %w", path, err)
+		}
+	}
+	return Compile(doc)
+}
+
+// Violation is a constraint of the schema not satisfied by a value
+type Violation struct {
+	// Path is the JSON pointer of the value, empty for the root
+	Path    string
+	Message string
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return "/: " + v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// ValidationError is returned when a response does not satisfy the schema in the reject mode
+type ValidationError struct {
+	Violations []Violation
+}
+
+// Error implements the error interface
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return "jsonschema: invalid response: " + strings.Join(msgs, "; ")
+}
+
+// StatusCode returns the 502 Bad Gateway status code, since the upstream broke its contract
+func (ValidationError) StatusCode() int { return http.StatusBadGateway }
+
+// Schema is a compiled JSON Schema
+type Schema struct {
+	root *node
+}
+
+// Compile compiles a JSON Schema document
+func Compile(doc map[string]interface{}) (*Schema, error) {
+	c := &compiler{doc: doc, refs: map[string]*node{}}
+	root, err := c.compile(doc, "#")
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// Validate returns the violations of the schema by the value, nil if it is valid. The values are
+// the ones decoded by the encoding/json package, with or without json.Number
+func (s *Schema) Validate(v interface{}) []Violation {
+	var res []Violation
+	s.root.validate(v, "", &res)
+	return res
+}
+
+// Strip removes the properties of the objects not declared by the schema, following the
+// properties, the pattern properties and the items of the schema
+func (s *Schema) Strip(v interface{}) {
+	s.root.strip(v)
+}
+
+type node struct {
+	always *bool
+	ref    *node
+
+	types      []string
+	enum       []interface{}
+	constant   interface{}
+	hasConst   bool
+	properties map[string]*node
+	patterns   map[*regexp.Regexp]*node
+	required   []string
+	additional *node
+	minProps   int
+	maxProps   int
+	items      *node
+	tuple      []*node
+	minItems   int
+	maxItems   int
+	unique     bool
+	minLength  int
+	maxLength  int
+	pattern    *regexp.Regexp
+	minimum    *float64
+	maximum    *float64
+	exMinimum  *float64
+	exMaximum  *float64
+	multipleOf float64
+	allOf      []*node
+	anyOf      []*node
+	oneOf      []*node
+	not        *node
+}
+
+type compiler struct {
+	doc  map[string]interface{}
+	refs map[string]*node
+}
+
+func (c *compiler) compile(v interface{}, path string) (*node, error) {
+	if b, ok := v.(bool); ok {
+		return &node{always: &b}, nil
+	}
+	s, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("jsonschema: invalid schema at %s", path)
+	}
+	n := &node{maxProps: -1, maxItems: -1, maxLength: -1}
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := c.resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		n.ref = target
+		// the rest of the keywords are ignored by the draft-07 references
+		return n, nil
+	}
+
+	switch t := s["type"].(type) {
+	case string:
+		n.types = []string{t}
+	case []interface{}:
+		for _, v := range t {
+			if name, ok := v.(string); ok {
+				n.types = append(n.types, name)
+			}
+		}
+	}
+	if e, ok := s["enum"].([]interface{}); ok {
+		n.enum = e
+	}
+	n.constant, n.hasConst = s["const"]
+
+	if props, ok := s["properties"].(map[string]interface{}); ok {
+		n.properties = make(map[string]*node, len(props))
+		for k, v := range props {
+			p, err := c.compile(v, path+"/properties/"+k)
+			if err != nil {
+				return nil, err
+			}
+			n.properties[k] = p
+		}
+	}
+	if props, ok := s["patternProperties"].(map[string]interface{}); ok {
+		n.patterns = make(map[*regexp.Regexp]*node, len(props))
+		for k, v := range props {
+			re, err := regexp.Compile(k)
+			if err != nil {
+				return nil, fmt.Errorf("jsonschema: invalid pattern %s at %s: %w", k, path, err)
+			}
+			p, err := c.compile(v, path+"/patternProperties/"+k)
+			if err != nil {
+				return nil, err
+			}
+			n.patterns[re] = p
+		}
+	}
+	if req, ok := s["required"].([]interface{}); ok {
+		for _, r := range req {
+			if name, ok := r.(string); ok {
+				n.required = append(n.required, name)
+			}
+		}
+	}
+	if v, ok := s["additionalProperties"]; ok {
+		a, err := c.compile(v, path+"/additionalProperties")
+		if err != nil {
+			return nil, err
+		}
+		n.additional = a
+	}
+
+	switch items := s["items"].(type) {
+	case []interface{}:
+		for i, v := range items {
+			item, err := c.compile(v, path+"/items/"+strconv.Itoa(i))
+			if err != nil {
+				return nil, err
+			}
+			n.tuple = append(n.tuple, item)
+		}
+	case nil:
+	default:
+		item, err := c.compile(items, path+"/items")
+		if err != nil {
+			return nil, err
+		}
+		n.items = item
+	}
+	n.unique, _ = s["uniqueItems"].(bool)
+
+	for _, k := range []struct {
+		name string
+		dst  *int
+	}{
+		{"minProperties", &n.minProps},
+		{"maxProperties", &n.maxProps},
+		{"minItems", &n.minItems},
+		{"maxItems", &n.maxItems},
+		{"minLength", &n.minLength},
+		{"maxLength", &n.maxLength},
+	} {
+		if f, ok := number(s[k.name]); ok {
+			*k.dst = int(f)
+		}
+	}
+	for _, k := range []struct {
+		name string
+		dst  **float64
+	}{
+		{"minimum", &n.minimum},
+		{"maximum", &n.maximum},
+		{"exclusiveMinimum", &n.exMinimum},
+		{"exclusiveMaximum", &n.exMaximum},
+	} {
+		if f, ok := number(s[k.name]); ok {
+			*k.dst = &f
+		}
+	}
+	n.multipleOf, _ = number(s["multipleOf"])
+	if p, ok := s["pattern"].(string); ok {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("jsonschema: invalid pattern %s at %s: %w", p, path, err)
+		}
+		n.pattern = re
+	}
+
+	for _, k := range []struct {
+		name string
+		dst  *[]*node
+	}{
+		{"allOf", &n.allOf},
+		{"anyOf", &n.anyOf},
+		{"oneOf", &n.oneOf},
+	} {
+		list, ok := s[k.name].([]interface{})
+		if !ok {
+			continue
+		}
+		for i, v := range list {
+			sub, err := c.compile(v, path+"/"+k.name+"/"+strconv.Itoa(i))
+			if err != nil {
+				return nil, err
+			}
+			*k.dst = append(*k.dst, sub)
+		}
+	}
+	if v, ok := s["not"]; ok {
+		sub, err := c.compile(v, path+"/not")
+		if err != nil {
+			return nil, err
+		}
+		n.not = sub
+	}
+	return n, nil
+}
+
+// resolve returns the node of a local reference, compiling it once, so the recursive schemas are
+// supported
+func (c *compiler) resolve(ref string) (*node, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("jsonschema: unsupported reference %s", ref)
+	}
+	if n, ok := c.refs[ref]; ok {
+		return n, nil
+	}
+	var target interface{} = c.doc
+	for _, token := range strings.Split(strings.TrimPrefix(strings.TrimPrefix(ref, "#"), "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		m, ok := target.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("jsonschema: unresolvable reference %s", ref)
+		}
+		if target, ok = m[token]; !ok {
+			return nil, fmt.Errorf("jsonschema: unresolvable reference %s", ref)
+		}
+	}
+	// the placeholder is filled once the target is compiled
+	n := &node{}
+	c.refs[ref] = n
+	compiled, err := c.compile(target, ref)
+	if err != nil {
+		return nil, err
+	}
+	*n = *compiled
+	return n, nil
+}
+
+func (n *node) valid(v interface{}) bool {
+	var res []Violation
+	n.validate(v, "", &res)
+	return len(res) == 0
+}
+
+func (n *node) validate(v interface{}, path string, res *[]Violation) {
+	if n.always != nil {
+		if !*n.always {
+			*res = append(*res, Violation{path, "no value allowed"})
+		}
+		return
+	}
+	if n.ref != nil {
+		n.ref.validate(v, path, res)
+		return
+	}
+	add := func(format string, args ...interface{}) {
+		*res = append(*res, Violation{path, fmt.Sprintf(format, args...)})
+	}
+
+	if len(n.types) > 0 && !hasType(v, n.types) {
+		add("expected %s, got %s", strings.Join(n.types, " or "), typeOf(v))
+		return
+	}
+	if n.enum != nil {
+		found := false
+		for _, e := range n.enum {
+			if equal(v, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			add("value not in the enum")
+		}
+	}
+	if n.hasConst && !equal(v, n.constant) {
+		add("value does not match the const")
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		n.validateObject(t, path, res)
+	case []interface{}:
+		n.validateArray(t, path, res)
+	case string:
+		length := utf8.RuneCountInString(t)
+		if length < n.minLength {
+			add("shorter than %d characters", n.minLength)
+		}
+		if n.maxLength >= 0 && length > n.maxLength {
+			add("longer than %d characters", n.maxLength)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(t) {
+			add("does not match the pattern %s", n.pattern)
+		}
+	default:
+		if f, ok := number(v); ok {
+			n.validateNumber(f, add)
+		}
+	}
+
+	for _, sub := range n.allOf {
+		sub.validate(v, path, res)
+	}
+	if len(n.anyOf) > 0 {
+		matched := false
+		for _, sub := range n.anyOf {
+			if sub.valid(v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			add("does not match any of the anyOf schemas")
+		}
+	}
+	if len(n.oneOf) > 0 {
+		matches := 0
+		for _, sub := range n.oneOf {
+			if sub.valid(v) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			add("matches %d of the oneOf schemas", matches)
+		}
+	}
+	if n.not != nil && n.not.valid(v) {
+		add("matches the not schema")
+	}
+}
+
+func (n *node) validateObject(obj map[string]interface{}, path string, res *[]Violation) {
+	for _, r := range n.required {
+		if _, ok := obj[r]; !ok {
+			*res = append(*res, Violation{path, "missing required property " + r})
+		}
+	}
+	if len(obj) < n.minProps {
+		*res = append(*res, Violation{path, fmt.Sprintf("less than %d properties", n.minProps)})
+	}
+	if n.maxProps >= 0 && len(obj) > n.maxProps {
+		*res = append(*res, Violation{path, fmt.Sprintf("more than %d properties", n.maxProps)})
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		childPath := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+		matched := false
+		if p, ok := n.properties[k]; ok {
+			p.validate(obj[k], childPath, res)
+			matched = true
+		}
+		for re, p := range n.patterns {
+			if re.MatchString(k) {
+				p.validate(obj[k], childPath, res)
+				matched = true
+			}
+		}
+		if !matched && n.additional != nil {
+			if n.additional.always != nil && !*n.additional.always {
+				*res = append(*res, Violation{childPath, "additional property not allowed"})
+				continue
+			}
+			n.additional.validate(obj[k], childPath, res)
+		}
+	}
+}
+
+func (n *node) validateArray(arr []interface{}, path string, res *[]Violation) {
+	if len(arr) < n.minItems {
+		*res = append(*res, Violation{path, fmt.Sprintf("less than %d items", n.minItems)})
+	}
+	if n.maxItems >= 0 && len(arr) > n.maxItems {
+		*res = append(*res, Violation{path, fmt.Sprintf("more than %d items", n.maxItems)})
+	}
+	for i, item := range arr {
+		childPath := path + "/" + strconv.Itoa(i)
+		switch {
+		case n.items != nil:
+			n.items.validate(item, childPath, res)
+		case i < len(n.tuple):
+			n.tuple[i].validate(item, childPath, res)
+		}
+	}
+	if n.unique {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if equal(arr[i], arr[j]) {
+					*res = append(*res, Violation{path, fmt.Sprintf("the items %d and %d are equal", i, j)})
+					return
+				}
+			}
+		}
+	}
+}
+
+func (n *node) validateNumber(f float64, add func(string, ...interface{})) {
+	if n.minimum != nil && f < *n.minimum {
+		add("less than the minimum %v", *n.minimum)
+	}
+	if n.maximum != nil && f > *n.maximum {
+		add("greater than the maximum %v", *n.maximum)
+	}
+	if n.exMinimum != nil && f <= *n.exMinimum {
+		add("not greater than the exclusive minimum %v", *n.exMinimum)
+	}
+	if n.exMaximum != nil && f >= *n.exMaximum {
+		add("not less than the exclusive maximum %v", *n.exMaximum)
+	}
+	if n.multipleOf > 0 {
+		if q := f / n.multipleOf; math.Abs(q-math.Round(q)) > 1e-9 {
+			add("not a multiple of %v", n.multipleOf)
+		}
+	}
+}
+
+func (n *node) strip(v interface{}) {
+	if n.ref != nil {
+		n.ref.strip(v)
+		return
+	}
+	if n.always != nil {
+		return
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if n.properties == nil && n.patterns == nil {
+			// the objects without declared properties are free-form
+			return
+		}
+		for k, child := range t {
+			declared := false
+			if p, ok := n.properties[k]; ok {
+				p.strip(child)
+				declared = true
+			}
+			for re, p := range n.patterns {
+				if re.MatchString(k) {
+					p.strip(child)
+					declared = true
+				}
+			}
+			if !declared {
+				delete(t, k)
+			}
+		}
+	case []interface{}:
+		for i, item := range t {
+			switch {
+			case n.items != nil:
+				n.items.strip(item)
+			case i < len(n.tuple):
+				n.tuple[i].strip(item)
+			}
+		}
+	}
+}
+
+func hasType(v interface{}, types []string) bool {
+	actual := typeOf(v)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	if f, ok := number(v); ok {
+		if f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return "integer"
+		}
+		return "number"
+	}
+	return reflect.TypeOf(v).String()
+}
+
+func number(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case float32:
+		return float64(t), true
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case json.Number:
+		f, err := t.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// equal compares two JSON values, ignoring the representation of the numbers
+func equal(a, b interface{}) bool {
+	if fa, ok := number(a); ok {
+		fb, ok := number(b)
+		return ok && fa == fb
+	}
+	switch ta := a.(type) {
+	case map[string]interface{}:
+		tb, ok := b.(map[string]interface{})
+		if !ok || len(ta) != len(tb) {
+			return false
+		}
+		for k, v := range ta {
+			if w, ok := tb[k]; !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		tb, ok := b.([]interface{})
+		if !ok || len(ta) != len(tb) {
+			return false
+		}
+		for i := range ta {
+			if !equal(ta[i], tb[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
@@ -0,0 +1,204 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package jsonschema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func compile(t *testing.T, doc string) *Schema {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(doc), &m); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Compile(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func decode(t *testing.T, data string) interface{} {
+	t.Helper()
+	d := json.NewDecoder(strings.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestSchema_Validate(t *testing.T) {
+	s := compile(t, `{
+		"definitions": {
+			"tag": {"type": "string", "minLength": 2, "maxLength": 5}
+		},
+		"type": "object",
+		"required": ["id", "email"],
+		"additionalProperties": false,
+		"properties": {
+			"id": {"type": "integer", "minimum": 1},
+			"email": {"type": "string", "pattern": "@"},
+			"role": {"enum": ["admin", "user"]},
+			"score": {"type": "number", "exclusiveMaximum": 10, "multipleOf": 0.5},
+			"tags": {"type": "array", "items": {"$ref": "#/definitions/tag"}, "maxItems": 2, "uniqueItems": true},
+			"contact": {"oneOf": [{"type": "string"}, {"type": "null"}]},
+			"status": {"not": {"const": "deleted"}}
+		}
+	}`)
+
+	for _, tc := range []struct {
+		name       string
+		data       string
+		violations []string
+	}{
+		{
+			name: "valid",
+			data: `{"id": 42, "email": "jane@example.com", "role": "admin", "score": 9.5, "tags": ["go", "api"], "contact": null}`,
+		},
+		{
+			name:       "wrong type",
+			data:       `{"id": 4.2, "email": "jane@example.com"}`,
+			violations: []string{"/id: expected integer, got number"},
+		},
+		{
+			name:       "missing required",
+			data:       `{"id": 1}`,
+			violations: []string{"/: missing required property email"},
+		},
+		{
+			name:       "additional property",
+			data:       `{"id": 1, "email": "a@b", "password": "secret"}`,
+			violations: []string{"/password: additional property not allowed"},
+		},
+		{
+			name: "constraints",
+			data: `{"id": 0, "email": "nope", "role": "root", "score": 10, "tags": ["g", "api", "api"], "contact": 1, "status": "deleted"}`,
+			violations: []string{
+				"/contact: matches 0 of the oneOf schemas",
+				"/email: does not match the pattern @",
+				"/id: less than the minimum 1",
+				"/role: value not in the enum",
+				"/score: not less than the exclusive maximum 10",
+				"/status: matches the not schema",
+				"/tags: more than 2 items",
+				"/tags/0: shorter than 2 characters",
+				"/tags: the items 1 and 2 are equal",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, v := range s.Validate(decode(t, tc.data)) {
+				got = append(got, v.String())
+			}
+			if !reflect.DeepEqual(got, tc.violations) {
+				t.Errorf("unexpected violations:\nhave: %q\nwant: %q", got, tc.violations)
+			}
+		})
+	}
+}
+
+func TestSchema_Validate_recursive(t *testing.T) {
+	s := compile(t, `{
+		"$defs": {
+			"node": {
+				"type": "object",
+				"required": ["name"],
+				"properties": {"children": {"type": "array", "items": {"$ref": "#/$defs/node"}}}
+			}
+		},
+		"$ref": "#/$defs/node"
+	}`)
+	v := s.Validate(decode(t, `{"name": "root", "children": [{"name": "a"}, {"children": []}]}`))
+	if len(v) != 1 || v[0].String() != "/children/1: missing required property name" {
+		t.Errorf("unexpected violations: %v", v)
+	}
+}
+
+func TestSchema_Strip(t *testing.T) {
+	s := compile(t, `{
+		"type": "object",
+		"properties": {
+			"id": {"type": "integer"},
+			"items": {"type": "array", "items": {"type": "object", "properties": {"sku": {"type": "string"}}}},
+			"meta": {"type": "object"}
+		},
+		"patternProperties": {"^x-": {"type": "string"}}
+	}`)
+	data := decode(t, `{"id": 1, "secret": true, "x-trace": "abc", "items": [{"sku": "a", "cost": 3}], "meta": {"free": "form"}}`)
+	s.Strip(data)
+	expected := decode(t, `{"id": 1, "x-trace": "abc", "items": [{"sku": "a"}], "meta": {"free": "form"}}`)
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("unexpected data: %v", data)
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	err := ValidationError{Violations: []Violation{{Path: "/id", Message: "expected integer, got string"}}}
+	if err.Error() != "jsonschema: invalid response: /id: expected integer, got string" {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if err.StatusCode() != 502 {
+		t.Errorf("unexpected status code: %d", err.StatusCode())
+	}
+}
+
+func TestConfigGetter(t *testing.T) {
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{})
+	if ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+
+	cfg, ok, err = ConfigGetter(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"schema": map[string]interface{}{"type": "object"},
+		},
+	})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result: %v %v", ok, err)
+	}
+	if cfg.Mode != ModeReject {
+		t.Errorf("unexpected mode: %s", cfg.Mode)
+	}
+	if len(cfg.Schema.Validate([]interface{}{})) != 1 {
+		t.Error("the schema should reject the arrays")
+	}
+
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(`{"type": "array"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, ok, err = ConfigGetter(config.ExtraConfig{
+		Namespace: map[string]interface{}{"schema_path": path, "mode": "strip"},
+	})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result: %v %v", ok, err)
+	}
+	if cfg.Mode != ModeStrip || len(cfg.Schema.Validate([]interface{}{})) != 0 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestConfigGetter_ko(t *testing.T) {
+	for _, tc := range []map[string]interface{}{
+		{},
+		{"schema": map[string]interface{}{"type": "object"}, "mode": "drop"},
+		{"schema_path": "/unknown/schema.json"},
+		{"schema": map[string]interface{}{"$ref": "#/definitions/unknown"}},
+		{"schema": map[string]interface{}{"pattern": "("}},
+	} {
+		if _, ok, err := ConfigGetter(config.ExtraConfig{Namespace: tc}); !ok || err == nil {
+			t.Errorf("expecting an error for %v", tc)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package lifecycle provides an orchestrator for the startup and the shutdown of the subsystems
+of a gateway (stores, subscribers, listeners...), so the applications embedding lura can tie
+their own resources into the same lifecycle.
+
+The components are started following their dependencies and stopped in the reverse order.
+The hooks registered for each phase are executed in registration order.
+*/
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const logPrefix = "[SERVICE: Lifecycle]"
+
+// Phase identifies the moments of the lifecycle where the hooks are executed
+type Phase int
+
+const (
+	// PreStart hooks are executed before starting the components
+	PreStart Phase = iota
+	// PostStart hooks are executed once all the components are started
+	PostStart
+	// PreStop hooks are executed before stopping the components
+	PreStop
+	// PostStop hooks are executed once all the components are stopped
+	PostStop
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PreStart:
+		return "pre-start"
+	case PostStart:
+		return "post-start"
+	case PreStop:
+		return "pre-stop"
+	case PostStop:
+		return "post-stop"
+	}
+	return "unknown"
+}
+
+// DefaultStopTimeout is the max duration of the shutdown sequence started by Run
+const DefaultStopTimeout = 30 * time.Second
+
+var (
+	// ErrDuplicatedComponent is the error returned when adding two components with the same name
+	ErrDuplicatedComponent = errors.New("lifecycle: duplicated component")
+	// ErrAlreadyStarted is the error returned when modifying or starting a started orchestrator
+	ErrAlreadyStarted = errors.New("lifecycle: already started")
+)
+
+// Hook is a function executed at a given phase of the lifecycle
+type Hook func(context.Context) error
+
+// Component is a subsystem managed by the orchestrator
+type Component struct {
+	// Name identifies the component
+	Name string
+	// DependsOn contains the names of the components to start before this one
+	DependsOn []string
+	// Start initializes the component. It should not block
+	Start func(context.Context) error
+	// Stop releases the resources of the component
+	Stop func(context.Context) error
+}
+
+// New returns an empty Orchestrator
+func New(logger logging.Logger) *Orchestrator {
+	if logger == nil {
+		logger = logging.NoOp
+	}
+	return &Orchestrator{
+		logger:     logger,
+		components: map[string]Component{},
+		hooks:      map[Phase][]Hook{},
+		mu:         new(sync.Mutex),
+	}
+}
+
+// Orchestrator starts and stops the registered components, executing the hooks of each phase
+type Orchestrator struct {
+	logger     logging.Logger
+	components map[string]Component
+	order      []string
+	hooks      map[Phase][]Hook
+	started    []Component
+	running    bool
+	mu         *sync.Mutex
+}
+
+// Add registers a component
+func (o *Orchestrator) Add(c Component) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.running {
+		return ErrAlreadyStarted
+	}
+	if _, ok := o.components[c.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicatedComponent, c.Name)
+	}
+	o.components[c.Name] = c
+	o.order = append(o.order, c.Name)
+	return nil
+}
+
+// AddHook registers a hook to be executed at the given phase
+func (o *Orchestrator) AddHook(p Phase, h Hook) {
+	o.mu.Lock()
+	o.hooks[p] = append(o.hooks[p], h)
+	o.mu.Unlock()
+}
+
+// Start executes the pre-start hooks, starts the components following their dependencies
+// and executes the post-start hooks. If something fails, the components already started are
+// stopped before returning the error
+func (o *Orchestrator) Start(ctx context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.running {
+		return ErrAlreadyStarted
+	}
+
+	sorted, err := o.sort()
+	if err != nil {
+		return err
+	}
+
+	if err := o.runHooks(ctx, PreStart); err != nil {
+		return err
+	}
+
+	o.running = true
+	for _, c := range sorted {
+		if c.Start != nil {
+			o.logger.Debug(logPrefix, "Starting", c.Name)
+			if err := c.Start(ctx); err != nil {
+				err = fmt.Errorf("lifecycle: starting %s: %w", c.Name, err)
+				if stopErr := o.stop(ctx); stopErr != nil {
+					o.logger.Error(logPrefix, stopErr.Error())
+				}
+				return err
+			}
+		}
+		o.started = append(o.started, c)
+	}
+
+	if err := o.runHooks(ctx, PostStart); err != nil {
+		if stopErr := o.stop(ctx); stopErr != nil {
+			o.logger.Error(logPrefix, stopErr.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// Stop executes the pre-stop hooks, stops the started components in the reverse order and
+// executes the post-stop hooks. All the steps are executed even if some of them fail
+func (o *Orchestrator) Stop(ctx context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.running {
+		return nil
+	}
+	return o.stop(ctx)
+}
+
+// Run starts the orchestrator and blocks until the context is cancelled. Then, it stops the
+// orchestrator with a fresh context limited by the received timeout
+func (o *Orchestrator) Run(ctx context.Context, stopTimeout time.Duration) error {
+	if err := o.Start(ctx); err != nil {
+		return err
+	}
+	<-ctx.Done()
+
+	if stopTimeout <= 0 {
+		stopTimeout = DefaultStopTimeout
+	}
+	stopCtx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	return o.Stop(stopCtx)
+}
+
+func (o *Orchestrator) stop(ctx context.Context) error {
+	errs := []string{}
+	if err := o.runHooks(ctx, PreStop); err != nil {
+		errs = append(errs, err.Error())
+	}
+	for i := len(o.started) - 1; i >= 0; i-- {
+		c := o.started[i]
+		if c.Stop == nil {
+			continue
+		}
+		o.logger.Debug(logPrefix, "Stopping", c.Name)
+		if err := c.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("lifecycle: stopping %s: %s", c.Name, err.Error()))
+		}
+	}
+	o.started = nil
+	o.running = false
+	if err := o.runHooks(ctx, PostStop); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (o *Orchestrator) runHooks(ctx context.Context, p Phase) error {
+	for i, h := range o.hooks[p] {
+		if err := h(ctx); err != nil {
+			return fmt.Errorf("lifecycle: %s hook #%d: %w", p, i, err)
+		}
+	}
+	return nil
+}
+
+// sort returns the components in an order respecting their dependencies. Components without
+// dependencies between them keep their registration order
+func (o *Orchestrator) sort() ([]Component, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(o.components))
+	sorted := make([]Component, 0, len(o.components))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		c, ok := o.components[name]
+		if !ok {
+			return fmt.Errorf("lifecycle: unknown dependency %s of %s", name, path[len(path)-1])
+		}
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("lifecycle: dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+		for _, dep := range c.DependsOn {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		sorted = append(sorted, c)
+		return nil
+	}
+
+	for _, name := range o.order {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
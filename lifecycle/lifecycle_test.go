@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOrchestrator(t *testing.T) {
+	var events []string
+	track := func(e string) func(context.Context) error {
+		return func(_ context.Context) error {
+			events = append(events, e)
+			return nil
+		}
+	}
+
+	o := New(nil)
+	for _, c := range []Component{
+		{Name: "listener", DependsOn: []string{"store", "subscriber"}, Start: track("start listener"), Stop: track("stop listener")},
+		{Name: "subscriber", DependsOn: []string{"store"}, Start: track("start subscriber"), Stop: track("stop subscriber")},
+		{Name: "store", Start: track("start store"), Stop: track("stop store")},
+		{Name: "metrics", Start: track("start metrics")},
+	} {
+		if err := o.Add(c); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if err := o.Add(Component{Name: "store"}); !errors.Is(err, ErrDuplicatedComponent) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	o.AddHook(PreStart, track("pre-start"))
+	o.AddHook(PostStart, track("post-start"))
+	o.AddHook(PreStop, track("pre-stop"))
+	o.AddHook(PostStop, track("post-stop"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- o.Run(ctx, time.Second) }()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := []string{
+		"pre-start",
+		"start store",
+		"start subscriber",
+		"start listener",
+		"start metrics",
+		"post-start",
+		"pre-stop",
+		"stop listener",
+		"stop subscriber",
+		"stop store",
+		"post-stop",
+	}
+	if strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected sequence: %v", events)
+	}
+}
+
+func TestOrchestrator_startError(t *testing.T) {
+	var stopped []string
+	o := New(nil)
+	o.Add(Component{Name: "a", Stop: func(_ context.Context) error { stopped = append(stopped, "a"); return nil }})
+	o.Add(Component{Name: "b", DependsOn: []string{"a"}, Start: func(_ context.Context) error { return errors.New("boom") }})
+	o.Add(Component{Name: "c", DependsOn: []string{"b"}, Stop: func(_ context.Context) error { stopped = append(stopped, "c"); return nil }})
+
+	err := o.Start(context.Background())
+	if err == nil || err.Error() != "lifecycle: starting b: boom" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(stopped) != 1 || stopped[0] != "a" {
+		t.Errorf("only the started components should be stopped: %v", stopped)
+	}
+	if err := o.Stop(context.Background()); err != nil {
+		t.Errorf("stopping a stopped orchestrator should be a noop: %v", err)
+	}
+}
+
+func TestOrchestrator_invalidDependencies(t *testing.T) {
+	o := New(nil)
+	o.Add(Component{Name: "a", DependsOn: []string{"b"}})
+	o.Add(Component{Name: "b", DependsOn: []string{"a"}})
+	if err := o.Start(context.Background()); err == nil || err.Error() != "lifecycle: dependency cycle: a -> b -> a" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	o = New(nil)
+	o.Add(Component{Name: "a", DependsOn: []string{"unknown"}})
+	if err := o.Start(context.Background()); err == nil || err.Error() != "lifecycle: unknown dependency unknown of a" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestOrchestrator_hookError(t *testing.T) {
+	var stopped bool
+	o := New(nil)
+	o.Add(Component{Name: "a", Stop: func(_ context.Context) error { stopped = true; return nil }})
+	o.AddHook(PostStart, func(_ context.Context) error { return errors.New("boom") })
+	o.AddHook(PostStop, func(_ context.Context) error { return errors.New("bang") })
+
+	err := o.Start(context.Background())
+	if err == nil || err.Error() != "lifecycle: post-start hook #0: boom" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !stopped {
+		t.Error("the started components should be stopped")
+	}
+}
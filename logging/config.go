@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/register"
+)
+
+// Namespace is the key to use to store and access the logging config
+const Namespace = "github.com/luraproject/lura/logging"
+
+const (
+	// DefaultLevel is the level of the logger when the config does not declare one
+	DefaultLevel = "INFO"
+	// TextFormat is the format of the BasicLogger
+	TextFormat = "text"
+	// JSONFormat is the format of the loggers returned by NewJSONLogger
+	JSONFormat = "json"
+)
+
+// Config is the logging config of the service. The format is text, json or any other one
+// registered with RegisterSinkFactory:
+//
+//	"extra_config": {
+//		"github.com/luraproject/lura/logging": {
+//			"level": "INFO",
+//			"format": "json",
+//			"modules": {"proxy": "DEBUG", "router": "WARNING"},
+//			"rate_limit": {"burst": 10, "interval": "10s"}
+//		}
+//	}
+type Config struct {
+	Level     string            `json:"level"`
+	Format    string            `json:"format"`
+	Prefix    string            `json:"prefix"`
+	Modules   map[string]string `json:"modules"`
+	RateLimit *RateLimitConfig  `json:"rate_limit"`
+}
+
+// SinkFactory creates the Sink of the structured loggers of a format, writing to out
+type SinkFactory func(out io.Writer) (Sink, error)
+
+var sinkFactories = register.NewUntyped()
+
+// RegisterSinkFactory adds the sink factory of a format to the package register, so the configs
+// can select it, like the ones of the github.com/luraproject/lura/v2/logging/zap and
+// github.com/luraproject/lura/v2/logging/zerolog modules
+func RegisterSinkFactory(format string, sf SinkFactory) {
+	sinkFactories.Register(format, sf)
+}
+
+// GetSinkFactory returns the sink factory registered for the format
+func GetSinkFactory(format string) (SinkFactory, bool) {
+	v, ok := sinkFactories.Get(format)
+	if !ok {
+		return nil, false
+	}
+	sf, ok := v.(SinkFactory)
+	return sf, ok
+}
+
+// ConfigGetter parses the logging config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{Level: DefaultLevel, Format: TextFormat}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("logging: parsing the config: %w", err)
+	}
+	if cfg.Level == "" {
+		cfg.Level = DefaultLevel
+	}
+	if cfg.Format == "" {
+		cfg.Format = TextFormat
+	}
+	return cfg, true, nil
+}
+
+// NewLoggerFromConfig returns the logger declared in the service extra config, writing to out.
+// When the config declares per module levels, the returned logger is a ModuleLogger and the
+// modules receiving it get their own logger with Module. When it declares a rate limit, the
+// messages are filtered by the levels before being rate limited
+func NewLoggerFromConfig(cfg config.ServiceConfig, out io.Writer) (Logger, error) {
+	c, _, err := ConfigGetter(cfg.ExtraConfig)
+	if err != nil {
+		return nil, err
+	}
+	level, ok := logLevels[strings.ToUpper(c.Level)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidLogLevel, c.Level)
+	}
+	modules := make(map[string]int, len(c.Modules))
+	for name, l := range c.Modules {
+		v, ok := logLevels[strings.ToUpper(l)]
+		if !ok {
+			return nil, fmt.Errorf("%w for the module %s: %s", ErrInvalidLogLevel, name, l)
+		}
+		modules[name] = v
+	}
+
+	// the module logger filters the messages, so the base logger accepts all of them
+	baseLevel := c.Level
+	if len(modules) > 0 {
+		baseLevel = "DEBUG"
+	}
+	var l Logger
+	switch c.Format {
+	case TextFormat:
+		l, err = NewLogger(baseLevel, out, c.Prefix)
+	case JSONFormat:
+		var s StructuredLogger
+		s, err = NewJSONLogger(baseLevel, out)
+		if err == nil && c.Prefix != "" {
+			s = s.With("prefix", c.Prefix)
+		}
+		l = s
+	default:
+		sf, ok := GetSinkFactory(c.Format)
+		if !ok {
+			return nil, fmt.Errorf("logging: unknown format %s", c.Format)
+		}
+		var sink Sink
+		if sink, err = sf(out); err != nil {
+			return nil, err
+		}
+		var s StructuredLogger
+		s, err = NewStructuredLogger(baseLevel, sink)
+		if err == nil && c.Prefix != "" {
+			s = s.With("prefix", c.Prefix)
+		}
+		l = s
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(modules) > 0 {
+		l = NewModuleLogger(l, level, modules)
+	}
+	if c.RateLimit == nil {
+		return l, nil
+	}
+	return NewRateLimitedLogger(l, *c.RateLimit)
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestNewLoggerFromConfig(t *testing.T) {
+	buff := new(bytes.Buffer)
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"level":   "warning",
+		"format":  "json",
+		"prefix":  "gw",
+		"modules": map[string]interface{}{"proxy": "DEBUG"},
+	}}}
+	l, err := NewLoggerFromConfig(cfg, buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info(infoMsg)
+	Module(l, "router").Info(infoMsg)
+	Module(l, "proxy").Debug(debugMsg)
+
+	out := buff.String()
+	if strings.Contains(out, infoMsg) {
+		t.Errorf("unexpected output: %s", out)
+	}
+	if !strings.Contains(out, `"message":"Debug msg"`) || !strings.Contains(out, `"module":"proxy"`) || !strings.Contains(out, `"prefix":"gw"`) {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
+
+func TestNewLoggerFromConfig_default(t *testing.T) {
+	buff := new(bytes.Buffer)
+	l, err := NewLoggerFromConfig(config.ServiceConfig{}, buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := l.(BasicLogger); !ok {
+		t.Errorf("unexpected logger: %T", l)
+	}
+	l.Debug(debugMsg)
+	l.Info(infoMsg)
+	if out := buff.String(); strings.Contains(out, debugMsg) || !strings.Contains(out, "INFO: "+infoMsg) {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
+
+func TestNewLoggerFromConfig_errors(t *testing.T) {
+	for _, e := range []map[string]interface{}{
+		{"level": "verbose"},
+		{"modules": map[string]interface{}{"proxy": "verbose"}},
+	} {
+		_, err := NewLoggerFromConfig(config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: e}}, new(bytes.Buffer))
+		if !errors.Is(err, ErrInvalidLogLevel) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	_, err := NewLoggerFromConfig(config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"format": "xml"}}}, new(bytes.Buffer))
+	if err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestNewLoggerFromConfig_registeredFormat(t *testing.T) {
+	RegisterSinkFactory("test", func(out io.Writer) (Sink, error) {
+		return SinkFunc(func(level int, msg string, kv []interface{}) {
+			fmt.Fprintln(out, levelNames[level], msg, kv)
+		}), nil
+	})
+
+	buff := new(bytes.Buffer)
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"level":  "INFO",
+		"format": "test",
+		"prefix": "gw",
+	}}}
+	l, err := NewLoggerFromConfig(cfg, buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Debug(debugMsg)
+	l.Info(infoMsg)
+	if out := buff.String(); out != "info "+infoMsg+" [prefix gw]\n" {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
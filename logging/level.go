@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// LevelSetter is implemented by the loggers able to change their level at runtime
+type LevelSetter interface {
+	Level() string
+	SetLevel(level string) error
+}
+
+// NewLevelSwitch returns a Logger filtering the messages with a level that can be changed at
+// runtime. The messages passing the filter are sent to the received logger, so it should not
+// filter them again (i.e. a BasicLogger with the DEBUG level)
+func NewLevelSwitch(l Logger, level string) (*LevelSwitch, error) {
+	s := &LevelSwitch{logger: l, level: new(int32)}
+	return s, s.SetLevel(level)
+}
+
+// LevelSwitch is a Logger with a level that can be changed at runtime
+type LevelSwitch struct {
+	logger Logger
+	level  *int32
+}
+
+// Level implements the LevelSetter interface
+func (s *LevelSwitch) Level() string {
+	l := int(atomic.LoadInt32(s.level))
+	for name, v := range logLevels {
+		if v == l {
+			return name
+		}
+	}
+	return ""
+}
+
+// SetLevel implements the LevelSetter interface
+func (s *LevelSwitch) SetLevel(level string) error {
+	l, ok := logLevels[strings.ToUpper(level)]
+	if !ok {
+		return ErrInvalidLogLevel
+	}
+	atomic.StoreInt32(s.level, int32(l))
+	return nil
+}
+
+// Module implements the Moduler interface. The loggers of the modules share the level of the
+// switch
+func (s *LevelSwitch) Module(name string) Logger {
+	return &LevelSwitch{logger: Module(s.logger, name), level: s.level}
+}
+
+func (s *LevelSwitch) enabled(level int) bool {
+	return int(atomic.LoadInt32(s.level)) <= level
+}
+
+// Debug logs a message using DEBUG as log level.
+func (s *LevelSwitch) Debug(v ...interface{}) {
+	if s.enabled(LEVEL_DEBUG) {
+		s.logger.Debug(v...)
+	}
+}
+
+// Info logs a message using INFO as log level.
+func (s *LevelSwitch) Info(v ...interface{}) {
+	if s.enabled(LEVEL_INFO) {
+		s.logger.Info(v...)
+	}
+}
+
+// Warning logs a message using WARNING as log level.
+func (s *LevelSwitch) Warning(v ...interface{}) {
+	if s.enabled(LEVEL_WARNING) {
+		s.logger.Warning(v...)
+	}
+}
+
+// Error logs a message using ERROR as log level.
+func (s *LevelSwitch) Error(v ...interface{}) {
+	if s.enabled(LEVEL_ERROR) {
+		s.logger.Error(v...)
+	}
+}
+
+// Critical logs a message using CRITICAL as log level.
+func (s *LevelSwitch) Critical(v ...interface{}) { s.logger.Critical(v...) }
+
+// Fatal logs a message using FATAL as log level and exits.
+func (s *LevelSwitch) Fatal(v ...interface{}) { s.logger.Fatal(v...) }
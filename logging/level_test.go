@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLevelSwitch(t *testing.T) {
+	buff := new(bytes.Buffer)
+	l, err := NewLogger("DEBUG", buff, "pref")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := NewLevelSwitch(l, "verbose"); err != ErrInvalidLogLevel {
+		t.Errorf("unexpected error: %v", err)
+	}
+	s, err := NewLevelSwitch(l, "error")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	m := s.Module("router")
+
+	s.Info(infoMsg)
+	m.Error(errorMsg)
+	if out := buff.String(); strings.Contains(out, infoMsg) || !strings.Contains(out, errorMsg) {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	if err := s.SetLevel("DEBUG"); err != nil {
+		t.Error(err)
+	}
+	if lvl := m.(LevelSetter).Level(); lvl != "DEBUG" {
+		t.Errorf("the module does not share the level: %s", lvl)
+	}
+	buff.Reset()
+	m.Debug(debugMsg)
+	if out := buff.String(); !strings.Contains(out, debugMsg) {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
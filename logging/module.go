@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+// Moduler is implemented by the loggers with a different level for every module
+type Moduler interface {
+	Module(name string) Logger
+}
+
+// Module returns the logger of the named module if the logger supports per module levels, or
+// the logger itself otherwise
+func Module(l Logger, name string) Logger {
+	if m, ok := l.(Moduler); ok {
+		return m.Module(name)
+	}
+	return l
+}
+
+// NewModuleLogger returns a Logger filtering the messages with the level of the module sending
+// them. The modules without a level of their own use the default one. The messages passing the
+// filter are sent to the received logger, so it should not filter them again. When it is a
+// StructuredLogger, the messages carry the name of the module in the "module" key
+func NewModuleLogger(l Logger, level int, modules map[string]int) *ModuleLogger {
+	return &ModuleLogger{root: l, logger: l, level: level, defaultLevel: level, modules: modules}
+}
+
+// ModuleLogger is a Logger with per module levels
+type ModuleLogger struct {
+	root         Logger
+	logger       Logger
+	level        int
+	defaultLevel int
+	modules      map[string]int
+}
+
+// Module implements the Moduler interface
+func (m *ModuleLogger) Module(name string) Logger {
+	level, ok := m.modules[name]
+	if !ok {
+		level = m.defaultLevel
+	}
+	l := m.root
+	if s, ok := l.(StructuredLogger); ok {
+		l = s.With("module", name)
+	}
+	return &ModuleLogger{root: m.root, logger: l, level: level, defaultLevel: m.defaultLevel, modules: m.modules}
+}
+
+// Debug logs a message using DEBUG as log level.
+func (m *ModuleLogger) Debug(v ...interface{}) {
+	if m.level > LEVEL_DEBUG {
+		return
+	}
+	m.logger.Debug(v...)
+}
+
+// Info logs a message using INFO as log level.
+func (m *ModuleLogger) Info(v ...interface{}) {
+	if m.level > LEVEL_INFO {
+		return
+	}
+	m.logger.Info(v...)
+}
+
+// Warning logs a message using WARNING as log level.
+func (m *ModuleLogger) Warning(v ...interface{}) {
+	if m.level > LEVEL_WARNING {
+		return
+	}
+	m.logger.Warning(v...)
+}
+
+// Error logs a message using ERROR as log level.
+func (m *ModuleLogger) Error(v ...interface{}) {
+	if m.level > LEVEL_ERROR {
+		return
+	}
+	m.logger.Error(v...)
+}
+
+// Critical logs a message using CRITICAL as log level.
+func (m *ModuleLogger) Critical(v ...interface{}) { m.logger.Critical(v...) }
+
+// Fatal logs a message using FATAL as log level and exits.
+func (m *ModuleLogger) Fatal(v ...interface{}) { m.logger.Fatal(v...) }
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestModuleLogger(t *testing.T) {
+	buff := new(bytes.Buffer)
+	base, _ := NewLogger("DEBUG", buff, "")
+	l := NewModuleLogger(base, LEVEL_INFO, map[string]int{
+		"proxy":  LEVEL_DEBUG,
+		"router": LEVEL_WARNING,
+	})
+
+	proxy := Module(l, "proxy")
+	router := Module(l, "router")
+	other := Module(l, "sd")
+
+	proxy.Debug("proxy", debugMsg)
+	router.Info("router", infoMsg)
+	router.Warning("router", warningMsg)
+	other.Debug("sd", debugMsg)
+	other.Info("sd", infoMsg)
+	l.Debug("root", debugMsg)
+	Module(router, "proxy").Debug("rescoped", debugMsg)
+
+	out := buff.String()
+	for _, msg := range []string{"proxy " + debugMsg, "router " + warningMsg, "sd " + infoMsg, "rescoped " + debugMsg} {
+		if !strings.Contains(out, msg) {
+			t.Errorf("%s not found in the output: %s", msg, out)
+		}
+	}
+	for _, msg := range []string{"router " + infoMsg, "sd " + debugMsg, "root " + debugMsg} {
+		if strings.Contains(out, msg) {
+			t.Errorf("%s found in the output: %s", msg, out)
+		}
+	}
+
+	if Module(base, "proxy") != Logger(base) {
+		t.Error("the loggers without per module levels must be returned as they are")
+	}
+}
+
+func TestModuleLogger_structured(t *testing.T) {
+	buff := new(bytes.Buffer)
+	base, _ := NewJSONLogger("DEBUG", buff)
+	l := NewModuleLogger(base, LEVEL_INFO, map[string]int{"proxy": LEVEL_DEBUG})
+
+	Module(Module(l, "router"), "proxy").Debug(debugMsg)
+	if out := buff.String(); strings.Count(out, `"module"`) != 1 || !strings.Contains(out, `"module":"proxy"`) {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
@@ -0,0 +1,263 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRateLimitBurst is the number of similar messages logged per interval when the rate
+	// limit config does not declare it
+	DefaultRateLimitBurst = 10
+	// DefaultRateLimitInterval is the period of the rate limit when the config does not declare it
+	DefaultRateLimitInterval = 10 * time.Second
+
+	maxRateLimitKeys = 1000
+	maxRateLimitKey  = 200
+)
+
+// RateLimitConfig protects the log outputs during the incident storms: only the first Burst
+// messages of every group of similar ones are logged per interval, and the rest are replaced by
+// a "suppressed N similar messages" note once the interval is over. Two messages are similar
+// when they have the same level and only differ in their numbers (status codes, ports, ids...).
+//
+//	"extra_config": {
+//		"github.com/luraproject/lura/logging": {
+//			"level": "INFO",
+//			"rate_limit": {"burst": 10, "interval": "10s"}
+//		}
+//	}
+type RateLimitConfig struct {
+	Burst    int    `json:"burst"`
+	Interval string `json:"interval"`
+}
+
+// NewRateLimitedLogger returns a Logger sending the messages to the received one with the rate
+// limit of the config. The CRITICAL and FATAL messages are never suppressed. If the received
+// logger is a StructuredLogger, so is the returned one
+func NewRateLimitedLogger(l Logger, cfg RateLimitConfig) (Logger, error) {
+	rl := &rateLimiter{
+		burst:    cfg.Burst,
+		interval: DefaultRateLimitInterval,
+		now:      time.Now,
+		groups:   map[string]*messageGroup{},
+	}
+	if rl.burst <= 0 {
+		rl.burst = DefaultRateLimitBurst
+	}
+	if cfg.Interval != "" {
+		d, err := time.ParseDuration(cfg.Interval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("logging: invalid rate limit interval %s", cfg.Interval)
+		}
+		rl.interval = d
+	}
+	return newRateLimitedLogger(l, rl), nil
+}
+
+func newRateLimitedLogger(l Logger, rl *rateLimiter) Logger {
+	base := &rateLimitedLogger{logger: l, limiter: rl}
+	if s, ok := l.(StructuredLogger); ok {
+		return &rateLimitedStructuredLogger{rateLimitedLogger: base, structured: s}
+	}
+	return base
+}
+
+type messageGroup struct {
+	level      int
+	sample     string
+	start      time.Time
+	count      int
+	suppressed int
+	logger     Logger
+}
+
+type rateLimiter struct {
+	burst    int
+	interval time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	groups    map[string]*messageGroup
+	lastSweep time.Time
+}
+
+type suppressedNote struct {
+	logger Logger
+	level  int
+	msg    string
+}
+
+// allow returns true if the message must be logged, and the notes of the suppressed messages to
+// log before it
+func (rl *rateLimiter) allow(l Logger, level int, msg string) (bool, []suppressedNote) {
+	now := rl.now()
+	key := groupKey(level, msg)
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	var notes []suppressedNote
+	if now.Sub(rl.lastSweep) >= rl.interval {
+		notes = rl.sweep(now)
+		rl.lastSweep = now
+	}
+
+	g, ok := rl.groups[key]
+	if !ok {
+		if len(rl.groups) >= maxRateLimitKeys {
+			// too many different messages, so the new ones share the same budget
+			key = groupKey(level, "")
+			g, ok = rl.groups[key]
+		}
+		if !ok {
+			g = &messageGroup{level: level, sample: msg, start: now, logger: l}
+			rl.groups[key] = g
+		}
+	}
+	if now.Sub(g.start) >= rl.interval {
+		if n := g.note(); n != nil {
+			notes = append(notes, *n)
+		}
+		g.start, g.count, g.suppressed = now, 0, 0
+	}
+	g.count++
+	g.sample = msg
+	g.logger = l
+	if g.count > rl.burst {
+		g.suppressed++
+		return false, notes
+	}
+	return true, notes
+}
+
+// sweep returns the notes of the groups with an expired interval and forgets them. It must be
+// called with the mu locked
+func (rl *rateLimiter) sweep(now time.Time) []suppressedNote {
+	var notes []suppressedNote
+	for k, g := range rl.groups {
+		if now.Sub(g.start) < rl.interval {
+			continue
+		}
+		if n := g.note(); n != nil {
+			notes = append(notes, *n)
+		}
+		delete(rl.groups, k)
+	}
+	return notes
+}
+
+func (g *messageGroup) note() *suppressedNote {
+	if g.suppressed == 0 {
+		return nil
+	}
+	return &suppressedNote{
+		logger: g.logger,
+		level:  g.level,
+		msg:    fmt.Sprintf("suppressed %d similar messages: %s", g.suppressed, g.sample),
+	}
+}
+
+// groupKey normalizes the numbers of the message, so the messages only differing in them are
+// grouped together
+func groupKey(level int, msg string) string {
+	var b strings.Builder
+	b.WriteByte(byte('0' + level))
+	inNumber := false
+	for _, r := range msg {
+		if b.Len() >= maxRateLimitKey {
+			break
+		}
+		if r >= '0' && r <= '9' {
+			if !inNumber {
+				b.WriteByte('#')
+			}
+			inNumber = true
+			continue
+		}
+		inNumber = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+type rateLimitedLogger struct {
+	logger  Logger
+	limiter *rateLimiter
+}
+
+func (r *rateLimitedLogger) log(level int, write func(Logger, ...interface{}), v []interface{}) {
+	ok, notes := r.limiter.allow(r.logger, level, strings.TrimSpace(fmt.Sprintln(v...)))
+	for _, n := range notes {
+		writeAtLevel(n.logger, n.level, n.msg)
+	}
+	if ok {
+		write(r.logger, v...)
+	}
+}
+
+func writeAtLevel(l Logger, level int, msg string) {
+	switch level {
+	case LEVEL_DEBUG:
+		l.Debug(msg)
+	case LEVEL_INFO:
+		l.Info(msg)
+	case LEVEL_WARNING:
+		l.Warning(msg)
+	default:
+		l.Error(msg)
+	}
+}
+
+// Module implements the Moduler interface. The loggers of the modules share the rate limit
+func (r *rateLimitedLogger) Module(name string) Logger {
+	return newRateLimitedLogger(Module(r.logger, name), r.limiter)
+}
+
+// Debug logs a message using DEBUG as log level.
+func (r *rateLimitedLogger) Debug(v ...interface{}) { r.log(LEVEL_DEBUG, Logger.Debug, v) }
+
+// Info logs a message using INFO as log level.
+func (r *rateLimitedLogger) Info(v ...interface{}) { r.log(LEVEL_INFO, Logger.Info, v) }
+
+// Warning logs a message using WARNING as log level.
+func (r *rateLimitedLogger) Warning(v ...interface{}) { r.log(LEVEL_WARNING, Logger.Warning, v) }
+
+// Error logs a message using ERROR as log level.
+func (r *rateLimitedLogger) Error(v ...interface{}) { r.log(LEVEL_ERROR, Logger.Error, v) }
+
+// Critical logs a message using CRITICAL as log level.
+func (r *rateLimitedLogger) Critical(v ...interface{}) { r.logger.Critical(v...) }
+
+// Fatal logs a message using FATAL as log level and exits.
+func (r *rateLimitedLogger) Fatal(v ...interface{}) { r.logger.Fatal(v...) }
+
+type rateLimitedStructuredLogger struct {
+	*rateLimitedLogger
+	structured StructuredLogger
+}
+
+// Log implements the StructuredLogger interface. The messages are grouped by their text, without
+// the key/value pairs
+func (r *rateLimitedStructuredLogger) Log(level int, msg string, keysAndValues ...interface{}) {
+	if level >= LEVEL_CRITICAL {
+		r.structured.Log(level, msg, keysAndValues...)
+		return
+	}
+	ok, notes := r.limiter.allow(r.logger, level, msg)
+	for _, n := range notes {
+		writeAtLevel(n.logger, n.level, n.msg)
+	}
+	if ok {
+		r.structured.Log(level, msg, keysAndValues...)
+	}
+}
+
+// With implements the StructuredLogger interface
+func (r *rateLimitedStructuredLogger) With(keysAndValues ...interface{}) StructuredLogger {
+	return newRateLimitedLogger(r.structured.With(keysAndValues...), r.limiter).(StructuredLogger)
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewRateLimitedLogger(t *testing.T) {
+	buff := new(bytes.Buffer)
+	l, err := NewLogger("DEBUG", buff, "pref")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	rl, err := NewRateLimitedLogger(l, RateLimitConfig{Burst: 2, Interval: "1m"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	now := time.Now()
+	rl.(*rateLimitedLogger).limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		rl.Error("backend 127.0.0.1:8080 answered with status", 500+i)
+	}
+	rl.Info("a different message")
+	rl.Critical("never suppressed")
+	rl.Critical("never suppressed")
+
+	out := buff.String()
+	if n := strings.Count(out, "answered with status"); n != 2 {
+		t.Errorf("unexpected number of similar messages: %d. output: %s", n, out)
+	}
+	if strings.Count(out, "never suppressed") != 2 || !strings.Contains(out, "a different message") {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	buff.Reset()
+	now = now.Add(time.Minute)
+	rl.Warning("after the storm")
+	out = buff.String()
+	if !strings.Contains(out, "pref ERROR: suppressed 3 similar messages: backend 127.0.0.1:8080 answered with status 504") {
+		t.Errorf("the suppressed messages were not reported: %s", out)
+	}
+	if !strings.Contains(out, "after the storm") {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
+
+func TestNewRateLimitedLogger_structured(t *testing.T) {
+	buff := new(bytes.Buffer)
+	l, err := NewJSONLogger("DEBUG", buff)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	rl, err := NewRateLimitedLogger(l, RateLimitConfig{Burst: 1})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	s, ok := rl.(StructuredLogger)
+	if !ok {
+		t.Fatal("the rate limited logger must be structured")
+	}
+	s.With("request_id", "a").Log(LEVEL_ERROR, "timeout", "status", 504)
+	s.With("request_id", "b").Log(LEVEL_ERROR, "timeout", "status", 504)
+	// the modules share the rate limit
+	Module(rl, "proxy").Error("timeout")
+
+	if n := strings.Count(buff.String(), "timeout"); n != 1 {
+		t.Errorf("unexpected output: %s", buff.String())
+	}
+}
+
+func TestNewRateLimitedLogger_badInterval(t *testing.T) {
+	if _, err := NewRateLimitedLogger(NoOp, RateLimitConfig{Interval: "often"}); err == nil {
+		t.Error("error expected")
+	}
+}
@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StructuredLogger is a Logger also accepting messages with key/value pairs
+type StructuredLogger interface {
+	Logger
+	// Log writes the message with the key/value pairs if the level is enabled
+	Log(level int, msg string, keysAndValues ...interface{})
+	// With returns a logger adding the key/value pairs to all its messages
+	With(keysAndValues ...interface{}) StructuredLogger
+}
+
+// Sink writes the messages of a StructuredLogger. The key/value pairs alternate the keys, as
+// strings, and their values
+type Sink interface {
+	Write(level int, msg string, keysAndValues []interface{})
+}
+
+// SinkFunc type is an adapter to allow the use of ordinary functions as sinks. It is the way to
+// plug loggers with a builder API, like zerolog:
+//
+//	logging.SinkFunc(func(level int, msg string, kv []interface{}) {
+//		zl.WithLevel(zerologLevels[level]).Fields(kv).Msg(msg)
+//	})
+type SinkFunc func(level int, msg string, keysAndValues []interface{})
+
+// Write implements the Sink interface
+func (f SinkFunc) Write(level int, msg string, keysAndValues []interface{}) {
+	f(level, msg, keysAndValues)
+}
+
+// NewStructuredLogger returns a StructuredLogger sending the messages with the level or above
+// to the sink
+func NewStructuredLogger(level string, s Sink) (StructuredLogger, error) {
+	l, ok := logLevels[strings.ToUpper(level)]
+	if !ok {
+		return nil, ErrInvalidLogLevel
+	}
+	return structuredLogger{level: l, sink: s}, nil
+}
+
+// NewJSONLogger returns a StructuredLogger writing every message as a JSON object in its own
+// line, with the field names used by zerolog
+func NewJSONLogger(level string, out io.Writer) (StructuredLogger, error) {
+	return NewStructuredLogger(level, &jsonSink{out: out})
+}
+
+// SugaredLogger is the subset of the methods of the zap.SugaredLogger used by its sink
+type SugaredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+// NewZapSink returns a Sink sending the messages to a zap.SugaredLogger. The CRITICAL messages
+// are sent at the error level, as zap only panics or exits above it
+func NewZapSink(l SugaredLogger) Sink {
+	return SinkFunc(func(level int, msg string, keysAndValues []interface{}) {
+		switch level {
+		case LEVEL_DEBUG:
+			l.Debugw(msg, keysAndValues...)
+		case LEVEL_INFO:
+			l.Infow(msg, keysAndValues...)
+		case LEVEL_WARNING:
+			l.Warnw(msg, keysAndValues...)
+		default:
+			l.Errorw(msg, keysAndValues...)
+		}
+	})
+}
+
+type structuredLogger struct {
+	level  int
+	sink   Sink
+	fields []interface{}
+}
+
+// Log implements the StructuredLogger interface
+func (l structuredLogger) Log(level int, msg string, keysAndValues ...interface{}) {
+	if level < l.level {
+		return
+	}
+	kv := keysAndValues
+	if len(l.fields) > 0 {
+		kv = make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+		kv = append(kv, l.fields...)
+		kv = append(kv, keysAndValues...)
+	}
+	l.sink.Write(level, msg, kv)
+}
+
+// With implements the StructuredLogger interface
+func (l structuredLogger) With(keysAndValues ...interface{}) StructuredLogger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keysAndValues...)
+	return structuredLogger{level: l.level, sink: l.sink, fields: fields}
+}
+
+// Debug logs a message using DEBUG as log level.
+func (l structuredLogger) Debug(v ...interface{}) { l.Log(LEVEL_DEBUG, message(v)) }
+
+// Info logs a message using INFO as log level.
+func (l structuredLogger) Info(v ...interface{}) { l.Log(LEVEL_INFO, message(v)) }
+
+// Warning logs a message using WARNING as log level.
+func (l structuredLogger) Warning(v ...interface{}) { l.Log(LEVEL_WARNING, message(v)) }
+
+// Error logs a message using ERROR as log level.
+func (l structuredLogger) Error(v ...interface{}) { l.Log(LEVEL_ERROR, message(v)) }
+
+// Critical logs a message using CRITICAL as log level.
+func (l structuredLogger) Critical(v ...interface{}) { l.Log(LEVEL_CRITICAL, message(v)) }
+
+// Fatal is equivalent to l.Critical(fmt.Sprint()) followed by a call to os.Exit(1).
+func (l structuredLogger) Fatal(v ...interface{}) {
+	l.Log(LEVEL_CRITICAL, message(v))
+	os.Exit(1)
+}
+
+// message formats the arguments as the BasicLogger does
+func message(v []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(v...), "\n")
+}
+
+var levelNames = map[int]string{
+	LEVEL_DEBUG:    "debug",
+	LEVEL_INFO:     "info",
+	LEVEL_WARNING:  "warn",
+	LEVEL_ERROR:    "error",
+	LEVEL_CRITICAL: "fatal",
+}
+
+type jsonSink struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (s *jsonSink) Write(level int, msg string, keysAndValues []interface{}) {
+	entry := make(map[string]interface{}, 3+len(keysAndValues)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		k := fmt.Sprintf("%v", keysAndValues[i])
+		if i+1 == len(keysAndValues) {
+			entry[k] = nil
+			break
+		}
+		v := keysAndValues[i+1]
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		entry[k] = v
+	}
+	entry["level"] = levelNames[level]
+	entry["time"] = time.Now().Format(time.RFC3339)
+	entry["message"] = msg
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		b, _ = json.Marshal(map[string]interface{}{
+			"level":   levelNames[level],
+			"time":    entry["time"],
+			"message": msg,
+			"error":   err.Error(),
+		})
+	}
+	s.mu.Lock()
+	s.out.Write(append(b, '\n'))
+	s.mu.Unlock()
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNewJSONLogger(t *testing.T) {
+	buff := new(bytes.Buffer)
+	l, err := NewJSONLogger("INFO", buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Debug(debugMsg)
+	l.With("module", "proxy").Log(LEVEL_WARNING, warningMsg, "backend", "/foo", "error", errors.New("boom"))
+	l.Error("[SERVICE: Test]", errorMsg)
+
+	lines := strings.Split(strings.TrimSpace(buff.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected output: %s", buff.String())
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]interface{}{
+		"level":   "warn",
+		"message": warningMsg,
+		"module":  "proxy",
+		"backend": "/foo",
+		"error":   "boom",
+	} {
+		if entry[k] != v {
+			t.Errorf("unexpected value for %s: %v", k, entry[k])
+		}
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Error("the time is missing")
+	}
+
+	entry = map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "error" || entry["message"] != "[SERVICE: Test] "+errorMsg {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+	if _, ok := entry["module"]; ok {
+		t.Error("the fields of the derived logger leaked to its parent")
+	}
+
+	if _, err := NewJSONLogger("UNKNOWN", buff); err != ErrInvalidLogLevel {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+type sugaredLogger struct {
+	calls []string
+}
+
+func (s *sugaredLogger) Debugw(msg string, kv ...interface{}) { s.record("debug", msg, kv) }
+func (s *sugaredLogger) Infow(msg string, kv ...interface{})  { s.record("info", msg, kv) }
+func (s *sugaredLogger) Warnw(msg string, kv ...interface{})  { s.record("warn", msg, kv) }
+func (s *sugaredLogger) Errorw(msg string, kv ...interface{}) { s.record("error", msg, kv) }
+
+func (s *sugaredLogger) record(level, msg string, kv []interface{}) {
+	s.calls = append(s.calls, strings.TrimSpace(fmt.Sprintln(append([]interface{}{level, msg}, kv...)...)))
+}
+
+func TestNewZapSink(t *testing.T) {
+	z := &sugaredLogger{}
+	l, err := NewStructuredLogger("DEBUG", NewZapSink(z))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = l.With("module", "router")
+	l.Debug(debugMsg)
+	l.Info(infoMsg)
+	l.Warning(warningMsg)
+	l.Log(LEVEL_ERROR, errorMsg, "endpoint", "/foo")
+	l.Critical(criticalMsg)
+
+	expected := []string{
+		"debug Debug msg module router",
+		"info Info msg module router",
+		"warn Warning msg module router",
+		"error Error msg module router endpoint /foo",
+		"error Critical msg module router",
+	}
+	if strings.Join(z.calls, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected calls: %v", z.calls)
+	}
+}
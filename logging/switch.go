@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import "sync/atomic"
+
+// NewDebugSwitch returns a Logger sending the messages to the regular logger or, while the
+// debug mode is enabled, to the debug one. It allows changing the verbosity of a running
+// process without restarting it
+func NewDebugSwitch(regular, debug Logger) *DebugSwitch {
+	return &DebugSwitch{regular: regular, debug: debug}
+}
+
+// NewBasicDebugSwitch returns a DebugSwitch using a copy of the received BasicLogger with
+// the DEBUG level as the debug logger
+func NewBasicDebugSwitch(l BasicLogger) *DebugSwitch {
+	debug := l
+	debug.Level = LEVEL_DEBUG
+	return NewDebugSwitch(l, debug)
+}
+
+// DebugSwitch is a Logger able to toggle the debug mode at runtime
+type DebugSwitch struct {
+	regular Logger
+	debug   Logger
+	enabled int32
+}
+
+// Toggle switches the debug mode and returns true if it has been enabled
+func (d *DebugSwitch) Toggle() bool {
+	for {
+		old := atomic.LoadInt32(&d.enabled)
+		if atomic.CompareAndSwapInt32(&d.enabled, old, 1-old) {
+			return old == 0
+		}
+	}
+}
+
+// IsDebugEnabled returns true if the debug mode is enabled
+func (d *DebugSwitch) IsDebugEnabled() bool {
+	return atomic.LoadInt32(&d.enabled) == 1
+}
+
+func (d *DebugSwitch) current() Logger {
+	if d.IsDebugEnabled() {
+		return d.debug
+	}
+	return d.regular
+}
+
+// Debug logs a message using DEBUG as log level.
+func (d *DebugSwitch) Debug(v ...interface{}) { d.current().Debug(v...) }
+
+// Info logs a message using INFO as log level.
+func (d *DebugSwitch) Info(v ...interface{}) { d.current().Info(v...) }
+
+// Warning logs a message using WARNING as log level.
+func (d *DebugSwitch) Warning(v ...interface{}) { d.current().Warning(v...) }
+
+// Error logs a message using ERROR as log level.
+func (d *DebugSwitch) Error(v ...interface{}) { d.current().Error(v...) }
+
+// Critical logs a message using CRITICAL as log level.
+func (d *DebugSwitch) Critical(v ...interface{}) { d.current().Critical(v...) }
+
+// Fatal logs a message using FATAL as log level and exits.
+func (d *DebugSwitch) Fatal(v ...interface{}) { d.current().Fatal(v...) }
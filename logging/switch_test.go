@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDebugSwitch(t *testing.T) {
+	buff := new(bytes.Buffer)
+	l, err := NewLogger("ERROR", buff, "pref")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	s := NewBasicDebugSwitch(l)
+
+	s.Debug(debugMsg)
+	s.Error(errorMsg)
+	if out := buff.String(); strings.Contains(out, debugMsg) || !strings.Contains(out, errorMsg) {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	if !s.Toggle() || !s.IsDebugEnabled() {
+		t.Error("the debug mode should be enabled")
+	}
+	buff.Reset()
+	s.Debug(debugMsg)
+	s.Info(infoMsg)
+	if out := buff.String(); !strings.Contains(out, debugMsg) || !strings.Contains(out, infoMsg) {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	if s.Toggle() || s.IsDebugEnabled() {
+		t.Error("the debug mode should be disabled")
+	}
+	buff.Reset()
+	s.Info(infoMsg)
+	if out := buff.String(); out != "" {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package zap adapts zap loggers to the structured loggers of lura.
+
+The package registers the "zap" format in the logging package when imported, writing the
+messages as JSON with the production encoder of zap:
+
+	import _ "github.com/luraproject/lura/v2/logging/zap"
+
+	"github.com/luraproject/lura/logging": {
+		"level": "INFO",
+		"format": "zap",
+		"modules": {"proxy": "DEBUG"}
+	}
+
+The services with their own zap logger can wrap it with New. The levels are filtered by lura, so
+the zap logger should enable all of them. The package is a module of its own, so the services
+using the built-in formats do not depend on zap.
+*/
+package zap
+
+import (
+	"io"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/luraproject/lura/v2/logging"
+)
+
+// Format is the name of the logging format registered by the package
+const Format = "zap"
+
+func init() {
+	logging.RegisterSinkFactory(Format, func(out io.Writer) (logging.Sink, error) {
+		core := zapcore.NewCore(
+			zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+			zapcore.AddSync(out),
+			zapcore.DebugLevel,
+		)
+		return NewSink(zap.New(core)), nil
+	})
+}
+
+// New returns a StructuredLogger sending the messages with the level or above to the zap logger
+func New(level string, l *zap.Logger) (logging.StructuredLogger, error) {
+	return logging.NewStructuredLogger(level, NewSink(l))
+}
+
+// NewSink returns a Sink sending the messages to the zap logger. The CRITICAL messages are sent
+// at the error level, as zap only panics or exits above it
+func NewSink(l *zap.Logger) logging.Sink {
+	return logging.NewZapSink(l.Sugar())
+}
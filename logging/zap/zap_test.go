@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package zap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNew(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	l, err := New("INFO", zap.New(core))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Debug("debug msg")
+	l.With("module", "proxy").Log(logging.LEVEL_WARNING, "slow backend", "backend", "/users", "error", errors.New("timeout"))
+	l.Critical("critical msg")
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+	if e := entries[0]; e.Level != zap.WarnLevel || e.Message != "slow backend" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	fields := entries[0].ContextMap()
+	if fields["module"] != "proxy" || fields["backend"] != "/users" || fields["error"] != "timeout" {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+	if e := entries[1]; e.Level != zap.ErrorLevel || e.Message != "critical msg" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	buff := new(bytes.Buffer)
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{logging.Namespace: map[string]interface{}{
+		"level":   "WARNING",
+		"format":  Format,
+		"modules": map[string]interface{}{"proxy": "DEBUG"},
+	}}}
+	l, err := logging.NewLoggerFromConfig(cfg, buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info("info msg")
+	logging.Module(l, "proxy").Debug("debug msg")
+
+	lines := strings.Split(strings.TrimSpace(buff.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("unexpected output: %s", buff.String())
+	}
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "debug" || entry["msg"] != "debug msg" || entry["module"] != "proxy" {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+}
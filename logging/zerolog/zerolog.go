@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package zerolog adapts zerolog loggers to the structured loggers of lura.
+
+The package registers the "zerolog" format in the logging package when imported, writing the
+messages as JSON with a timestamp:
+
+	import _ "github.com/luraproject/lura/v2/logging/zerolog"
+
+	"github.com/luraproject/lura/logging": {
+		"level": "INFO",
+		"format": "zerolog",
+		"modules": {"proxy": "DEBUG"}
+	}
+
+The services with their own zerolog logger can wrap it with New. The levels are filtered by
+lura, so the zerolog logger should enable all of them. The package is a module of its own, so
+the services using the built-in formats do not depend on zerolog.
+*/
+package zerolog
+
+import (
+	"io"
+
+	"github.com/rs/zerolog"
+
+	"github.com/luraproject/lura/v2/logging"
+)
+
+// Format is the name of the logging format registered by the package
+const Format = "zerolog"
+
+var levels = map[int]zerolog.Level{
+	logging.LEVEL_DEBUG:    zerolog.DebugLevel,
+	logging.LEVEL_INFO:     zerolog.InfoLevel,
+	logging.LEVEL_WARNING:  zerolog.WarnLevel,
+	logging.LEVEL_ERROR:    zerolog.ErrorLevel,
+	logging.LEVEL_CRITICAL: zerolog.FatalLevel,
+}
+
+func init() {
+	logging.RegisterSinkFactory(Format, func(out io.Writer) (logging.Sink, error) {
+		return NewSink(zerolog.New(out).With().Timestamp().Logger()), nil
+	})
+}
+
+// New returns a StructuredLogger sending the messages with the level or above to the zerolog
+// logger
+func New(level string, l zerolog.Logger) (logging.StructuredLogger, error) {
+	return logging.NewStructuredLogger(level, NewSink(l))
+}
+
+// NewSink returns a Sink sending the messages to the zerolog logger. The CRITICAL messages are
+// sent at the fatal level, without exiting
+func NewSink(l zerolog.Logger) logging.Sink {
+	return logging.SinkFunc(func(level int, msg string, keysAndValues []interface{}) {
+		lvl, ok := levels[level]
+		if !ok {
+			lvl = zerolog.ErrorLevel
+		}
+		l.WithLevel(lvl).Fields(keysAndValues).Msg(msg)
+	})
+}
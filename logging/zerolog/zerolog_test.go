@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package zerolog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNew(t *testing.T) {
+	buff := new(bytes.Buffer)
+	l, err := New("INFO", zerolog.New(buff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Debug("debug msg")
+	l.With("module", "proxy").Log(logging.LEVEL_WARNING, "slow backend", "backend", "/users", "error", errors.New("timeout"))
+	l.Critical("critical msg")
+
+	entries := decode(t, buff)
+	if len(entries) != 2 {
+		t.Fatalf("unexpected output: %s", buff.String())
+	}
+	expected := map[string]interface{}{
+		"level":   "warn",
+		"message": "slow backend",
+		"module":  "proxy",
+		"backend": "/users",
+		"error":   "timeout",
+	}
+	for k, v := range expected {
+		if entries[0][k] != v {
+			t.Errorf("unexpected value for %s: %v", k, entries[0][k])
+		}
+	}
+	if entries[1]["level"] != "fatal" || entries[1]["message"] != "critical msg" {
+		t.Errorf("unexpected entry: %v", entries[1])
+	}
+}
+
+func TestFormat(t *testing.T) {
+	buff := new(bytes.Buffer)
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{logging.Namespace: map[string]interface{}{
+		"level":   "WARNING",
+		"format":  Format,
+		"modules": map[string]interface{}{"proxy": "DEBUG"},
+	}}}
+	l, err := logging.NewLoggerFromConfig(cfg, buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info("info msg")
+	logging.Module(l, "proxy").Debug("debug msg")
+
+	entries := decode(t, buff)
+	if len(entries) != 1 {
+		t.Fatalf("unexpected output: %s", buff.String())
+	}
+	if e := entries[0]; e["level"] != "debug" || e["message"] != "debug msg" || e["module"] != "proxy" || e["time"] == nil {
+		t.Errorf("unexpected entry: %v", e)
+	}
+}
+
+func decode(t *testing.T, buff *bytes.Buffer) []map[string]interface{} {
+	var res []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buff.String()), "\n") {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, entry)
+	}
+	return res
+}
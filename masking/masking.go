@@ -0,0 +1,290 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package masking redacts the sensitive fields of the responses.
+
+The service declares named profiles once, with the fields to mask and the strategy to apply:
+
+	"extra_config": {
+		"github.com/luraproject/lura/masking": {
+			"profiles": {
+				"pii": {
+					"strategy": "redact",
+					"fields": ["email", "address.street"],
+					"rules": [
+						{"field": "card.number", "strategy": "partial", "keep_last": 4}
+					]
+				}
+			}
+		}
+	}
+
+and the endpoints and backends reference them, optionally adding their own fields:
+
+	"extra_config": {
+		"github.com/luraproject/lura/masking": {
+			"profiles": ["pii"],
+			"fields": ["ssn"],
+			"strategy": "remove"
+		}
+	}
+
+The fields are dot separated paths. The arrays found along a path are traversed, so the rule
+"users.email" masks the email of every user.
+*/
+package masking
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the masking config
+const Namespace = "github.com/luraproject/lura/masking"
+
+const (
+	// Redact replaces the value with the replacement
+	Redact = "redact"
+	// Remove deletes the field
+	Remove = "remove"
+	// Hash replaces the value with its hex encoded SHA-256 hash
+	Hash = "hash"
+	// Partial masks all the characters of the value but the last ones
+	Partial = "partial"
+
+	// DefaultReplacement is the replacement of the redacted values when the rule does not declare one
+	DefaultReplacement = "****"
+	// DefaultKeepLast is the number of characters kept by the partial strategy when the rule does
+	// not declare it
+	DefaultKeepLast = 4
+)
+
+// Rule declares how to mask a field
+type Rule struct {
+	Field       string `json:"field"`
+	Strategy    string `json:"strategy"`
+	Replacement string `json:"replacement"`
+	KeepLast    int    `json:"keep_last"`
+}
+
+type ruleSet struct {
+	Fields      []string `json:"fields"`
+	Strategy    string   `json:"strategy"`
+	Replacement string   `json:"replacement"`
+	KeepLast    int      `json:"keep_last"`
+	Rules       []Rule   `json:"rules"`
+}
+
+func (r ruleSet) rules() ([]Rule, error) {
+	rules := make([]Rule, 0, len(r.Fields)+len(r.Rules))
+	for _, f := range r.Fields {
+		rules = append(rules, Rule{Field: f, Strategy: r.Strategy, Replacement: r.Replacement, KeepLast: r.KeepLast})
+	}
+	for _, rule := range r.Rules {
+		if rule.Strategy == "" {
+			rule.Strategy = r.Strategy
+		}
+		rules = append(rules, rule)
+	}
+	for i, rule := range rules {
+		if rule.Field == "" {
+			return nil, fmt.Errorf("masking: the rule #%d does not declare a field", i)
+		}
+		switch rule.Strategy {
+		case "":
+			rules[i].Strategy = Redact
+		case Redact, Remove, Hash, Partial:
+		default:
+			return nil, fmt.Errorf("masking: unknown strategy %s for the field %s", rule.Strategy, rule.Field)
+		}
+	}
+	return rules, nil
+}
+
+var (
+	profiles   = map[string][]Rule{}
+	profilesMu sync.RWMutex
+)
+
+// Register parses the profiles declared in the service extra config and makes them available to
+// the endpoints and backends. It also checks every reference to a profile, so the config errors
+// are reported at startup. It returns false if the service does not declare any profile
+func Register(cfg config.ServiceConfig) (bool, error) {
+	ps, ok, err := ProfilesFromExtraConfig(cfg.ExtraConfig)
+	if err != nil {
+		return true, err
+	}
+	if !ok {
+		// drop the profiles of a previous config
+		SetProfiles(map[string][]Rule{})
+		return false, nil
+	}
+	SetProfiles(ps)
+
+	for _, e := range cfg.Endpoints {
+		if _, _, err := ConfigGetter(e.ExtraConfig); err != nil {
+			return true, fmt.Errorf("%w in the endpoint %s %s", err, e.Method, e.Endpoint)
+		}
+		for _, b := range e.Backend {
+			if _, _, err := ConfigGetter(b.ExtraConfig); err != nil {
+				return true, fmt.Errorf("%w in the backend %s of the endpoint %s %s", err, b.URLPattern, e.Method, e.Endpoint)
+			}
+		}
+	}
+	return true, nil
+}
+
+// ProfilesFromExtraConfig parses the profiles declared in the service extra config
+func ProfilesFromExtraConfig(e config.ExtraConfig) (map[string][]Rule, bool, error) {
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+	b, err := json.Marshal(tmp["profiles"])
+	if err != nil {
+		return nil, true, err
+	}
+	var sets map[string]ruleSet
+	if err := json.Unmarshal(b, &sets); err != nil {
+		return nil, true, fmt.Errorf("masking: parsing the profiles: %w", err)
+	}
+	ps := make(map[string][]Rule, len(sets))
+	for name, s := range sets {
+		rules, err := s.rules()
+		if err != nil {
+			return nil, true, fmt.Errorf("%w in the profile %s", err, name)
+		}
+		ps[name] = rules
+	}
+	return ps, true, nil
+}
+
+// SetProfiles replaces the profiles available to the endpoints and backends
+func SetProfiles(ps map[string][]Rule) {
+	profilesMu.Lock()
+	profiles = ps
+	profilesMu.Unlock()
+}
+
+// GetProfile returns the rules of the profile
+func GetProfile(name string) ([]Rule, bool) {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	rules, ok := profiles[name]
+	return rules, ok
+}
+
+// ConfigGetter returns the masker declared in the extra config of an endpoint or a backend,
+// resolving the referenced profiles. It returns false if the extra config does not declare any
+func ConfigGetter(e config.ExtraConfig) (*Masker, bool, error) {
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return nil, true, err
+	}
+	cfg := struct {
+		ruleSet
+		Profiles []string `json:"profiles"`
+	}{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, true, fmt.Errorf("masking: parsing the config: %w", err)
+	}
+
+	var rules []Rule
+	for _, name := range cfg.Profiles {
+		rs, ok := GetProfile(name)
+		if !ok {
+			return nil, true, fmt.Errorf("masking: unknown profile %s", name)
+		}
+		rules = append(rules, rs...)
+	}
+	inline, err := cfg.rules()
+	if err != nil {
+		return nil, true, err
+	}
+	return New(append(rules, inline...)), true, nil
+}
+
+// Masker applies a list of rules to the response data
+type Masker struct {
+	rules []Rule
+	paths [][]string
+}
+
+// New returns a Masker applying the rules in order. The rules are not validated
+func New(rules []Rule) *Masker {
+	m := &Masker{rules: rules, paths: make([][]string, len(rules))}
+	for i, r := range rules {
+		m.paths[i] = strings.Split(r.Field, ".")
+	}
+	return m
+}
+
+// Rules returns the rules applied by the masker
+func (m *Masker) Rules() []Rule {
+	return m.rules
+}
+
+// Mask applies the rules to the data. The data is modified in place
+func (m *Masker) Mask(data map[string]interface{}) {
+	for i, r := range m.rules {
+		maskPath(data, m.paths[i], r)
+	}
+}
+
+func maskPath(v interface{}, path []string, r Rule) {
+	switch t := v.(type) {
+	case []interface{}:
+		for _, item := range t {
+			maskPath(item, path, r)
+		}
+	case map[string]interface{}:
+		value, ok := t[path[0]]
+		if !ok {
+			return
+		}
+		if len(path) > 1 {
+			maskPath(value, path[1:], r)
+			return
+		}
+		if r.Strategy == Remove {
+			delete(t, path[0])
+			return
+		}
+		t[path[0]] = maskValue(value, r)
+	}
+}
+
+func maskValue(v interface{}, r Rule) interface{} {
+	if v == nil {
+		return nil
+	}
+	switch r.Strategy {
+	case Hash:
+		sum := sha256.Sum256([]byte(fmt.Sprint(v)))
+		return hex.EncodeToString(sum[:])
+	case Partial:
+		keep := r.KeepLast
+		if keep <= 0 {
+			keep = DefaultKeepLast
+		}
+		s := []rune(fmt.Sprint(v))
+		if len(s) <= keep {
+			return strings.Repeat("*", len(s))
+		}
+		return strings.Repeat("*", len(s)-keep) + string(s[len(s)-keep:])
+	}
+	if r.Replacement != "" {
+		return r.Replacement
+	}
+	return DefaultReplacement
+}
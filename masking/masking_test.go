@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package masking
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestMasker_Mask(t *testing.T) {
+	m := New([]Rule{
+		{Field: "email", Strategy: Redact},
+		{Field: "password", Strategy: Remove},
+		{Field: "card.number", Strategy: Partial, KeepLast: 4},
+		{Field: "users.ssn", Strategy: Hash},
+		{Field: "users.token", Strategy: Redact, Replacement: "[hidden]"},
+		{Field: "missing.field", Strategy: Redact},
+	})
+	data := map[string]interface{}{
+		"email":    "jane@example.com",
+		"password": "secret",
+		"card":     map[string]interface{}{"number": "4111111111111111"},
+		"users": []interface{}{
+			map[string]interface{}{"ssn": "123", "token": "abc"},
+			map[string]interface{}{"name": "bob"},
+		},
+	}
+	m.Mask(data)
+
+	b, _ := json.Marshal(data)
+	expected := `{"card":{"number":"************1111"},"email":"****","users":[{"ssn":"a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3","token":"[hidden]"},{"name":"bob"}]}`
+	if string(b) != expected {
+		t.Errorf("unexpected result:\nhave: %s\nwant: %s", b, expected)
+	}
+}
+
+func TestMaskValue_partialShortValue(t *testing.T) {
+	if v := maskValue("123", Rule{Strategy: Partial}); v != "***" {
+		t.Errorf("unexpected value: %v", v)
+	}
+	if v := maskValue(nil, Rule{Strategy: Redact}); v != nil {
+		t.Errorf("unexpected value: %v", v)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer SetProfiles(map[string][]Rule{})
+
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"profiles": map[string]interface{}{
+					"pii": map[string]interface{}{
+						"strategy": "hash",
+						"fields":   []interface{}{"email"},
+						"rules": []interface{}{
+							map[string]interface{}{"field": "phone", "strategy": "partial", "keep_last": 2},
+							map[string]interface{}{"field": "name"},
+						},
+					},
+				},
+			},
+		},
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint: "/users",
+				Method:   "GET",
+				ExtraConfig: config.ExtraConfig{
+					Namespace: map[string]interface{}{"profiles": []interface{}{"pii"}, "fields": []interface{}{"ssn"}},
+				},
+				Backend: []*config.Backend{{URLPattern: "/users"}},
+			},
+		},
+	}
+	ok, err := Register(cfg)
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+
+	m, ok, err := ConfigGetter(cfg.Endpoints[0].ExtraConfig)
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	expected := []Rule{
+		{Field: "email", Strategy: Hash},
+		{Field: "phone", Strategy: Partial, KeepLast: 2},
+		{Field: "name", Strategy: Hash},
+		{Field: "ssn", Strategy: Redact},
+	}
+	rules := m.Rules()
+	if len(rules) != len(expected) {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+	for i, r := range rules {
+		if r != expected[i] {
+			t.Errorf("unexpected rule #%d: %+v", i, r)
+		}
+	}
+
+	cfg.Endpoints[0].Backend[0].ExtraConfig = config.ExtraConfig{
+		Namespace: map[string]interface{}{"profiles": []interface{}{"unknown"}},
+	}
+	if _, err := Register(cfg); err == nil {
+		t.Error("expecting an error for the unknown profile")
+	}
+
+	if ok, _ := Register(config.ServiceConfig{}); ok {
+		t.Error("the service does not declare profiles")
+	}
+	if _, ok := GetProfile("pii"); ok {
+		t.Error("the profiles of the previous config were not dropped")
+	}
+}
+
+func TestProfilesFromExtraConfig_unknownStrategy(t *testing.T) {
+	_, _, err := ProfilesFromExtraConfig(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"profiles": map[string]interface{}{
+				"pii": map[string]interface{}{"strategy": "shuffle", "fields": []interface{}{"email"}},
+			},
+		},
+	})
+	if err == nil {
+		t.Error("expecting an error for the unknown strategy")
+	}
+}
@@ -0,0 +1,289 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package metaheaders adds headers exposing the metadata of the pipe to the responses, so the client
+SDKs can inspect how their requests were served.
+
+Every header is disabled by default and can be enabled with its default name or with a custom
+one, so the security-conscious deployments only expose what they need:
+
+	"extra_config": {
+		"github.com/luraproject/lura/metaheaders": {
+			"version": true,
+			"endpoint": "X-Api-Operation",
+			"cache": true,
+			"backend": false,
+			"quota": true
+		}
+	}
+
+The headers available are:
+  - version: the version of the gateway
+  - endpoint: the method and the pattern of the endpoint
+  - cache: HIT, MISS or STALE, for the endpoints with a cache
+  - backend: the groups (or the url patterns) of the backends answering the request
+  - quota: the budget left to the tenant, for the endpoints declaring a cost
+
+The endpoints can override the headers of the service declaring the same namespace in their
+extra config. The headers are only added to the responses with content.
+*/
+package metaheaders
+
+import (
+	"context"
+	"fmt"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/core"
+)
+
+// Namespace is the key to use to store and access the meta headers config
+const Namespace = "github.com/luraproject/lura/metaheaders"
+
+// The names of the headers enabled without a custom name
+const (
+	DefaultVersionHeader  = "X-Gateway-Version"
+	DefaultEndpointHeader = "X-Gateway-Endpoint"
+	DefaultCacheHeader    = "X-Cache"
+	DefaultBackendHeader  = "X-Gateway-Backend"
+	DefaultQuotaHeader    = "X-Quota-Remaining"
+)
+
+// The values of the cache header
+const (
+	CacheHit   = "HIT"
+	CacheMiss  = "MISS"
+	CacheStale = "STALE"
+)
+
+var defaultNames = map[string]string{
+	"version":  DefaultVersionHeader,
+	"endpoint": DefaultEndpointHeader,
+	"cache":    DefaultCacheHeader,
+	"backend":  DefaultBackendHeader,
+	"quota":    DefaultQuotaHeader,
+}
+
+// Config contains the names of the enabled headers. The empty ones are disabled
+type Config struct {
+	Version  string
+	Endpoint string
+	Cache    string
+	Backend  string
+	Quota    string
+}
+
+// Enabled returns true if any header is enabled
+func (c Config) Enabled() bool {
+	return c != Config{}
+}
+
+func (c *Config) field(name string) *string {
+	switch name {
+	case "version":
+		return &c.Version
+	case "endpoint":
+		return &c.Endpoint
+	case "cache":
+		return &c.Cache
+	case "backend":
+		return &c.Backend
+	case "quota":
+		return &c.Quota
+	}
+	return nil
+}
+
+// ConfigGetter parses the meta headers config from the extra config, over the received one, so
+// the endpoints can override the config of the service
+func ConfigGetter(base Config, e config.ExtraConfig) (Config, bool, error) {
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return base, false, nil
+	}
+	cfg := base
+	for k, v := range tmp {
+		f := cfg.field(k)
+		if f == nil {
+			return base, true, fmt.Errorf("metaheaders: unknown header %s", k)
+		}
+		switch t := v.(type) {
+		case bool:
+			*f = ""
+			if t {
+				*f = defaultNames[k]
+			}
+		case string:
+			if !isToken(t) {
+				return base, true, fmt.Errorf("metaheaders: invalid name for the %s header: %q", k, t)
+			}
+			*f = textproto.CanonicalMIMEHeaderKey(t)
+		default:
+			return base, true, fmt.Errorf("metaheaders: the %s header must be a boolean or a name", k)
+		}
+	}
+	return cfg, true, nil
+}
+
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// EndpointConfig returns the headers enabled for the endpoint. It returns false if none is
+func EndpointConfig(cfg *config.EndpointConfig) (Config, bool) {
+	base, _ := GetGlobal()
+	c, _, err := ConfigGetter(base, cfg.ExtraConfig)
+	if err != nil {
+		c = base
+	}
+	return c, c.Enabled()
+}
+
+// Info collects the metadata of a request while it goes through the pipe
+type Info struct {
+	mu           sync.Mutex
+	cache        string
+	backends     []string
+	remaining    int64
+	hasRemaining bool
+}
+
+type infoKey struct{}
+
+// NewContext returns a context carrying a new Info
+func NewContext(ctx context.Context) (context.Context, *Info) {
+	i := &Info{}
+	return context.WithValue(ctx, infoKey{}, i), i
+}
+
+// FromContext returns the Info of the context, if any
+func FromContext(ctx context.Context) (*Info, bool) {
+	i, ok := ctx.Value(infoKey{}).(*Info)
+	return i, ok
+}
+
+// CacheHit records if the response was served by the cache
+func (i *Info) CacheHit(hit bool) {
+	i.mu.Lock()
+	i.cache = CacheMiss
+	if hit {
+		i.cache = CacheHit
+	}
+	i.mu.Unlock()
+}
+
+// CacheStale records that the response was an expired copy served by the cache while it is
+// being refreshed
+func (i *Info) CacheStale() {
+	i.mu.Lock()
+	i.cache = CacheStale
+	i.mu.Unlock()
+}
+
+// AddBackend records a backend answering the request
+func (i *Info) AddBackend(name string) {
+	i.mu.Lock()
+	i.backends = append(i.backends, name)
+	i.mu.Unlock()
+}
+
+// SetRemaining records the quota left to the client
+func (i *Info) SetRemaining(n int64) {
+	i.mu.Lock()
+	i.remaining, i.hasRemaining = n, true
+	i.mu.Unlock()
+}
+
+// Headers returns the enabled headers of the endpoint with the metadata collected by the Info
+func (c Config) Headers(endpoint *config.EndpointConfig, i *Info) map[string][]string {
+	h := map[string][]string{}
+	if c.Version != "" {
+		h[c.Version] = []string{core.KrakendVersion}
+	}
+	if c.Endpoint != "" {
+		h[c.Endpoint] = []string{strings.ToUpper(endpoint.Method) + " " + endpoint.Endpoint}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if c.Cache != "" && i.cache != "" {
+		h[c.Cache] = []string{i.cache}
+	}
+	if c.Backend != "" && len(i.backends) > 0 {
+		backends := append([]string{}, i.backends...)
+		sort.Strings(backends)
+		h[c.Backend] = []string{strings.Join(backends, ", ")}
+	}
+	if c.Quota != "" && i.hasRemaining {
+		h[c.Quota] = []string{strconv.FormatInt(i.remaining, 10)}
+	}
+	return h
+}
+
+var (
+	global         Config
+	recordBackends bool
+	globalMu       sync.RWMutex
+)
+
+// Register sets the headers declared in the service extra config. It returns false if neither
+// the service nor its endpoints declare them
+func Register(cfg config.ServiceConfig) (bool, error) {
+	c, ok, err := ConfigGetter(Config{}, cfg.ExtraConfig)
+	if err != nil {
+		SetGlobal(Config{})
+		return true, err
+	}
+	backends := c.Backend != ""
+	for _, e := range cfg.Endpoints {
+		ec, found, err := ConfigGetter(c, e.ExtraConfig)
+		if err != nil {
+			SetGlobal(Config{})
+			return true, fmt.Errorf("%w in the endpoint %s %s", err, e.Method, e.Endpoint)
+		}
+		ok = ok || found
+		backends = backends || ec.Backend != ""
+	}
+	SetGlobal(c)
+	globalMu.Lock()
+	recordBackends = backends
+	globalMu.Unlock()
+	return ok, nil
+}
+
+// SetGlobal sets the headers of the service
+func SetGlobal(c Config) {
+	globalMu.Lock()
+	global = c
+	recordBackends = c.Backend != ""
+	globalMu.Unlock()
+}
+
+// RecordsBackends returns true if the service or any of its endpoints expose the backend header,
+// so the backends have to record themselves in the Info of the requests
+func RecordsBackends() bool {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return recordBackends
+}
+
+// GetGlobal returns the headers of the service and if any is enabled
+func GetGlobal() (Config, bool) {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return global, global.Enabled()
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package metaheaders
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/core"
+)
+
+func TestConfigGetter(t *testing.T) {
+	base, ok, err := ConfigGetter(Config{}, config.ExtraConfig{Namespace: map[string]interface{}{
+		"version":  true,
+		"endpoint": "x-api-operation",
+		"cache":    true,
+		"backend":  false,
+	}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	expected := Config{Version: DefaultVersionHeader, Endpoint: "X-Api-Operation", Cache: DefaultCacheHeader}
+	if base != expected {
+		t.Errorf("unexpected config %+v", base)
+	}
+
+	endpoint, _, err := ConfigGetter(base, config.ExtraConfig{Namespace: map[string]interface{}{
+		"version": false,
+		"quota":   true,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = Config{Endpoint: "X-Api-Operation", Cache: DefaultCacheHeader, Quota: DefaultQuotaHeader}
+	if endpoint != expected {
+		t.Errorf("the endpoint config does not override the service one: %+v", endpoint)
+	}
+
+	for _, raw := range []map[string]interface{}{
+		{"unknown": true},
+		{"cache": "X Cache"},
+		{"cache": 1},
+	} {
+		if _, _, err := ConfigGetter(Config{}, config.ExtraConfig{Namespace: raw}); err == nil {
+			t.Errorf("error expected for %v", raw)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer SetGlobal(Config{})
+
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"version": true}},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/a", ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"backend": true}}},
+		},
+	}
+	if ok, err := Register(cfg); !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if c, ok := GetGlobal(); !ok || c.Version != DefaultVersionHeader || c.Backend != "" {
+		t.Errorf("unexpected global config %+v", c)
+	}
+	if !RecordsBackends() {
+		t.Error("the backends must be recorded for the endpoints exposing them")
+	}
+	if _, ok := EndpointConfig(&config.EndpointConfig{}); !ok {
+		t.Error("the endpoints must inherit the service config")
+	}
+
+	cfg.Endpoints[0].ExtraConfig[Namespace] = map[string]interface{}{"backend": "x y"}
+	if _, err := Register(cfg); err == nil {
+		t.Error("error expected")
+	}
+	if _, ok := GetGlobal(); ok {
+		t.Error("an invalid config must disable the headers")
+	}
+
+	if ok, err := Register(config.ServiceConfig{}); ok || err != nil {
+		t.Errorf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+}
+
+func TestConfig_Headers(t *testing.T) {
+	c := Config{
+		Version:  DefaultVersionHeader,
+		Endpoint: DefaultEndpointHeader,
+		Cache:    DefaultCacheHeader,
+		Backend:  DefaultBackendHeader,
+		Quota:    DefaultQuotaHeader,
+	}
+	ctx, info := NewContext(context.Background())
+	if i, ok := FromContext(ctx); !ok || i != info {
+		t.Fatal("the info is not in the context")
+	}
+	endpoint := &config.EndpointConfig{Method: "get", Endpoint: "/users/{id}"}
+
+	h := c.Headers(endpoint, info)
+	if len(h) != 2 || h[DefaultVersionHeader][0] != core.KrakendVersion || h[DefaultEndpointHeader][0] != "GET /users/{id}" {
+		t.Errorf("unexpected headers %v", h)
+	}
+
+	info.CacheHit(true)
+	info.AddBackend("users")
+	info.AddBackend("accounts")
+	info.SetRemaining(42)
+	h = c.Headers(endpoint, info)
+	if h[DefaultCacheHeader][0] != CacheHit || h[DefaultBackendHeader][0] != "accounts, users" || h[DefaultQuotaHeader][0] != "42" {
+		t.Errorf("unexpected headers %v", h)
+	}
+
+	if h := (Config{Cache: "X-Hit"}).Headers(endpoint, info); len(h) != 1 || h["X-Hit"][0] != CacheHit {
+		t.Errorf("unexpected headers %v", h)
+	}
+
+	info.CacheStale()
+	if h := (Config{Cache: "X-Hit"}).Headers(endpoint, info); len(h) != 1 || h["X-Hit"][0] != CacheStale {
+		t.Errorf("unexpected headers %v", h)
+	}
+}
@@ -0,0 +1,329 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package metrics instruments the request pipeline and exposes the metrics with the Prometheus text
+format on a dedicated listener, so the scrapers never share the port of the public endpoints.
+
+The listener is declared in the service extra config:
+
+	"extra_config": {
+		"github.com/luraproject/lura/metrics": {
+			"listen_address": ":9091",
+			"path": "/metrics",
+			"buckets": [0.01, 0.05, 0.1, 0.5, 1, 5]
+		}
+	}
+
+The router counts the requests of every endpoint by status code and records their latencies,
+and the proxy stack counts the requests and the errors of every backend and records their
+latencies, along with the failures by status code and retryability. The circuit breakers report their state with SetCircuitBreakerOpen and the caching
+resolver of the http client reports its hits and misses with ObserveDNSLookup. The metrics are
+reset every time the service config is registered.
+
+The metrics are written by the package, without depending on the Prometheus client. The
+github.com/luraproject/lura/v2/metrics/prometheus module exposes them as a collector of the
+Prometheus client and serves them, along with the runtime metrics, on the same listener.
+*/
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+// Namespace is the key to use to store and access the metrics config
+const Namespace = "github.com/luraproject/lura/metrics"
+
+const (
+	// DefaultListenAddress is the address of the metrics listener when the config does not declare one
+	DefaultListenAddress = ":9091"
+	// DefaultPath is the path of the metrics endpoint when the config does not declare one
+	DefaultPath = "/metrics"
+)
+
+const logPrefix = "[SERVICE: Metrics]"
+
+// Config is the metrics config of the service
+type Config struct {
+	ListenAddress string    `json:"listen_address"`
+	Path          string    `json:"path"`
+	Buckets       []float64 `json:"buckets"`
+}
+
+// ConfigGetter parses the metrics config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{ListenAddress: DefaultListenAddress, Path: DefaultPath}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("metrics: parsing the config: %w", err)
+	}
+	if cfg.ListenAddress == "" {
+		cfg.ListenAddress = DefaultListenAddress
+	}
+	if cfg.Path == "" {
+		cfg.Path = DefaultPath
+	}
+	if len(cfg.Buckets) == 0 {
+		cfg.Buckets = DefaultBuckets
+	}
+	if !sort.Float64sAreSorted(cfg.Buckets) {
+		return cfg, true, fmt.Errorf("metrics: the buckets must be in increasing order")
+	}
+	return cfg, true, nil
+}
+
+// Pipeline contains the metrics of the request pipeline
+type Pipeline struct {
+	registry        *Registry
+	requests        CounterVec
+	latency         HistogramVec
+	backendRequests CounterVec
+	backendErrors   CounterVec
+	backendLatency  HistogramVec
+	backendFailures CounterVec
+	circuitBreakers GaugeVec
+	divergences     CounterVec
+	dnsLookups      CounterVec
+}
+
+// NewPipeline registers the metrics of the request pipeline in the registry
+func NewPipeline(r *Registry, buckets []float64) *Pipeline {
+	return &Pipeline{
+		registry:        r,
+		requests:        r.Counter("lura_router_requests_total", "Requests received by the endpoints.", "endpoint", "method", "status"),
+		latency:         r.Histogram("lura_router_request_duration_seconds", "Latency of the endpoint requests.", buckets, "endpoint", "method"),
+		backendRequests: r.Counter("lura_backend_requests_total", "Requests sent to the backends.", "endpoint", "backend"),
+		backendErrors:   r.Counter("lura_backend_errors_total", "Requests to the backends ending with an error.", "endpoint", "backend"),
+		backendLatency:  r.Histogram("lura_backend_request_duration_seconds", "Latency of the backend requests.", buckets, "endpoint", "backend"),
+		backendFailures: r.Counter("lura_backend_failures_total", "Failed backend requests by status code and retryability.", "endpoint", "backend", "status", "retryable"),
+		circuitBreakers: r.Gauge("lura_circuit_breaker_open", "State of the circuit breakers, 1 when open.", "endpoint", "backend"),
+		divergences:     r.Counter("lura_dual_write_divergences_total", "Dual writes where the secondary status differs from the primary one.", "endpoint", "primary", "secondary"),
+		dnsLookups:      r.Counter("lura_dns_cache_lookups_total", "Lookups of the caching resolver by result.", "result"),
+	}
+}
+
+// Registry returns the registry containing the metrics
+func (p *Pipeline) Registry() *Registry {
+	return p.registry
+}
+
+// ObserveRequest records a request to an endpoint
+func (p *Pipeline) ObserveRequest(endpoint, method string, status int, d time.Duration) {
+	p.requests.Inc(endpoint, method, strconv.Itoa(status))
+	p.latency.Observe(d.Seconds(), endpoint, method)
+}
+
+// ObserveBackend records a request to a backend
+func (p *Pipeline) ObserveBackend(endpoint, backend string, d time.Duration, failed bool) {
+	p.backendRequests.Inc(endpoint, backend)
+	if failed {
+		p.backendErrors.Inc(endpoint, backend)
+	}
+	p.backendLatency.Observe(d.Seconds(), endpoint, backend)
+}
+
+// ObserveBackendFailure records a failed request to a backend with the status code returned by
+// the backend, zero if it did not respond, and whether the request could be retried
+func (p *Pipeline) ObserveBackendFailure(endpoint, backend string, status int, retryable bool) {
+	p.backendFailures.Inc(endpoint, backend, strconv.Itoa(status), strconv.FormatBool(retryable))
+}
+
+// SetCircuitBreakerOpen records the state of the circuit breaker of a backend
+func (p *Pipeline) SetCircuitBreakerOpen(endpoint, backend string, open bool) {
+	v := 0.0
+	if open {
+		v = 1
+	}
+	p.circuitBreakers.Set(v, endpoint, backend)
+}
+
+// ObserveDivergence records a dual write where the status of the secondary backend differs from
+// the primary one
+func (p *Pipeline) ObserveDivergence(endpoint string, primary, secondary int) {
+	p.divergences.Inc(endpoint, strconv.Itoa(primary), strconv.Itoa(secondary))
+}
+
+// ObserveDNSLookup records a lookup of the caching resolver with its result (hit, negative_hit
+// or miss)
+func (p *Pipeline) ObserveDNSLookup(result string) {
+	p.dnsLookups.Inc(result)
+}
+
+// ObserveDNSLookup records a lookup of the caching resolver in the registered pipeline, if any
+func ObserveDNSLookup(result string) {
+	if p, ok := GetGlobal(); ok {
+		p.ObserveDNSLookup(result)
+	}
+}
+
+// SetCircuitBreakerOpen records the state of the circuit breaker of a backend in the registered
+// pipeline, if any
+func SetCircuitBreakerOpen(endpoint, backend string, open bool) {
+	if p, ok := GetGlobal(); ok {
+		p.SetCircuitBreakerOpen(endpoint, backend, open)
+	}
+}
+
+var (
+	global   *Pipeline
+	globalMu sync.RWMutex
+
+	listener   *metricsListener
+	listenerMu sync.Mutex
+
+	handlerFactory   HandlerFactory
+	handlerFactoryMu sync.RWMutex
+)
+
+// HandlerFactory creates the http handler exposing the metrics of a registry
+type HandlerFactory func(*Registry) http.Handler
+
+// RegisterHandlerFactory sets the factory of the handlers exposing the metrics of the pipelines,
+// replacing the Registry handler. It must be called before registering the service config
+func RegisterHandlerFactory(f HandlerFactory) {
+	handlerFactoryMu.Lock()
+	handlerFactory = f
+	handlerFactoryMu.Unlock()
+}
+
+// SetGlobal sets the pipeline instrumented by the router and the proxies
+func SetGlobal(p *Pipeline) {
+	globalMu.Lock()
+	global = p
+	globalMu.Unlock()
+}
+
+// GetGlobal returns the pipeline instrumented by the router and the proxies, if any
+func GetGlobal() (*Pipeline, bool) {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return global, global != nil
+}
+
+// Register creates the pipeline metrics declared in the service extra config and starts the
+// listener exposing them, replacing the previous one if its address or path changed. The
+// listener is stopped when the context is done. It returns false if the service does not declare
+// the metrics
+func Register(ctx context.Context, cfg config.ServiceConfig, logger logging.Logger) (Config, bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok || err != nil {
+		// the listener of a previous config is stopped with its context, so the routers without
+		// metrics do not stop the listener of another router
+		SetGlobal(nil)
+		return c, ok, err
+	}
+	SetGlobal(NewPipeline(NewRegistry(), c.Buckets))
+
+	listenerMu.Lock()
+	defer listenerMu.Unlock()
+	if listener != nil && listener.addr == c.ListenAddress && listener.path == c.Path {
+		// the listener survives the reloads, so it is bound to the context of the last one
+		listener.handler.Store(Handler())
+		listener.watch(ctx)
+		return c, true, nil
+	}
+	if listener != nil {
+		listener.stop()
+		listener = nil
+	}
+	l, err := startListener(ctx, c, logger)
+	if err != nil {
+		return c, true, err
+	}
+	listener = l
+	return c, true, nil
+}
+
+// Handler returns a http handler exposing the metrics of the registered pipeline. The pipeline is
+// resolved when the handler is created, so it keeps exposing the same one after another router
+// registers its own
+func Handler() http.Handler {
+	p, ok := GetGlobal()
+	if !ok {
+		return http.NotFoundHandler()
+	}
+	handlerFactoryMu.RLock()
+	hf := handlerFactory
+	handlerFactoryMu.RUnlock()
+	if hf != nil {
+		return hf(p.Registry())
+	}
+	return p.Registry().Handler()
+}
+
+type metricsListener struct {
+	addr string
+	path string
+	// handler holds the http.Handler of the last registered pipeline
+	handler atomic.Value
+	server  *http.Server
+	owner   context.Context
+}
+
+func startListener(ctx context.Context, c Config, logger logging.Logger) (*metricsListener, error) {
+	ln, err := net.Listen("tcp", c.ListenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: starting the listener: %w", err)
+	}
+	l := &metricsListener{
+		addr: c.ListenAddress,
+		path: c.Path,
+	}
+	l.handler.Store(Handler())
+	mux := http.NewServeMux()
+	mux.Handle(c.Path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.handler.Load().(http.Handler).ServeHTTP(w, r)
+	}))
+	l.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	logger.Info(logPrefix, "Exposing the metrics at", ln.Addr().String()+c.Path)
+	go func() {
+		if err := l.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Error(logPrefix, err.Error())
+		}
+	}()
+	l.watch(ctx)
+	return l, nil
+}
+
+// watch stops the listener when the context is done, unless it was bound to another context
+// before. It must be called with the listenerMu locked
+func (l *metricsListener) watch(ctx context.Context) {
+	l.owner = ctx
+	go func() {
+		<-ctx.Done()
+		listenerMu.Lock()
+		defer listenerMu.Unlock()
+		if l.owner != ctx {
+			return
+		}
+		if listener == l {
+			listener = nil
+		}
+		l.stop()
+	}()
+}
+
+func (l *metricsListener) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	l.server.Shutdown(ctx)
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestRegister(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"listen_address": "127.0.0.1:0",
+		"buckets":        []interface{}{0.1, 1.0},
+	}}}
+	c, ok, err := Register(ctx, cfg, logging.NoOp)
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if c.Path != DefaultPath {
+		t.Errorf("unexpected path: %s", c.Path)
+	}
+	first := listener
+
+	// a reload keeps the listener, but binds it to the new context
+	reloadCtx, reloadCancel := context.WithCancel(context.Background())
+	defer reloadCancel()
+	if _, _, err := Register(reloadCtx, cfg, logging.NoOp); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	listenerMu.Lock()
+	if listener != first {
+		t.Error("the listener was replaced or stopped by the previous context")
+	}
+	listenerMu.Unlock()
+
+	p, _ := GetGlobal()
+	p.ObserveRequest("/a", "GET", 200, 50*time.Millisecond)
+	p.ObserveBackend("/a", "/backend", 20*time.Millisecond, true)
+	p.ObserveBackendFailure("/a", "/backend", 503, true)
+	SetCircuitBreakerOpen("/a", "/backend", true)
+	ObserveDNSLookup("hit")
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range []string{
+		`lura_router_requests_total{endpoint="/a",method="GET",status="200"} 1`,
+		`lura_router_request_duration_seconds_bucket{endpoint="/a",method="GET",le="0.1"} 1`,
+		`lura_backend_requests_total{endpoint="/a",backend="/backend"} 1`,
+		`lura_backend_errors_total{endpoint="/a",backend="/backend"} 1`,
+		`lura_backend_failures_total{endpoint="/a",backend="/backend",status="503",retryable="true"} 1`,
+		`lura_circuit_breaker_open{endpoint="/a",backend="/backend"} 1`,
+		`lura_dns_cache_lookups_total{result="hit"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), line) {
+			t.Errorf("%s not found in:\n%s", line, w.Body.String())
+		}
+	}
+
+	reloadCancel()
+	time.Sleep(10 * time.Millisecond)
+	listenerMu.Lock()
+	if listener != nil {
+		t.Error("the listener was not stopped")
+	}
+	listenerMu.Unlock()
+
+	if _, ok, _ := Register(context.Background(), config.ServiceConfig{}, logging.NoOp); ok {
+		t.Error("the service does not declare the metrics")
+	}
+	if _, ok := GetGlobal(); ok {
+		t.Error("the pipeline of the previous config was not dropped")
+	}
+}
+
+func TestConfigGetter_invalidBuckets(t *testing.T) {
+	_, _, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"buckets": []interface{}{1.0, 0.1},
+	}})
+	if err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestRegisterHandlerFactory(t *testing.T) {
+	defer RegisterHandlerFactory(nil)
+	defer SetGlobal(nil)
+
+	SetGlobal(NewPipeline(NewRegistry(), nil))
+	var registry *Registry
+	RegisterHandlerFactory(func(r *Registry) http.Handler {
+		registry = r
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})
+	})
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusTeapot {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if p, _ := GetGlobal(); registry != p.Registry() {
+		t.Error("the factory should receive the registry of the pipeline")
+	}
+}
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package prometheus exposes the metrics of the lura pipelines with the Prometheus client.
+
+The package registers its handler in the metrics package when imported, so the metrics listener
+declared in the service extra config serves the pipeline metrics along with the go runtime and
+the process metrics, with the content negotiated by the Prometheus client:
+
+	import _ "github.com/luraproject/lura/v2/metrics/prometheus"
+
+The services exposing their own Prometheus registry can add the pipeline metrics to it with
+NewCollector instead. The package is a module of its own, so the services using the built-in
+handler do not depend on the Prometheus client.
+*/
+package prometheus
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/luraproject/lura/v2/metrics"
+)
+
+func init() {
+	metrics.RegisterHandlerFactory(Handler)
+}
+
+// Handler returns a http handler exposing the metrics of the registry along with the go runtime
+// and the process metrics. It implements the metrics.HandlerFactory signature
+func Handler(r *metrics.Registry) http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		NewRegistryCollector(r),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
+
+// NewCollector returns a prometheus.Collector with the metrics of the registered pipeline. The
+// pipeline is resolved on every collection, so the collector follows the config reloads
+func NewCollector() prometheus.Collector {
+	return collector{registry: func() (*metrics.Registry, bool) {
+		p, ok := metrics.GetGlobal()
+		if !ok {
+			return nil, false
+		}
+		return p.Registry(), true
+	}}
+}
+
+// NewRegistryCollector returns a prometheus.Collector with the metrics of the registry
+func NewRegistryCollector(r *metrics.Registry) prometheus.Collector {
+	return collector{registry: func() (*metrics.Registry, bool) { return r, true }}
+}
+
+// collector is an unchecked collector, since the families of the registry are created with the
+// first observation
+type collector struct {
+	registry func() (*metrics.Registry, bool)
+}
+
+// Describe implements the prometheus.Collector interface
+func (collector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements the prometheus.Collector interface
+func (c collector) Collect(ch chan<- prometheus.Metric) {
+	r, ok := c.registry()
+	if !ok {
+		return
+	}
+	for _, f := range r.Gather() {
+		desc := prometheus.NewDesc(f.Name, f.Help, f.Labels, nil)
+		for _, s := range f.Series {
+			var m prometheus.Metric
+			var err error
+			switch f.Type {
+			case metrics.CounterType:
+				m, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, s.Value, s.LabelValues...)
+			case metrics.GaugeType:
+				m, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, s.Value, s.LabelValues...)
+			case metrics.HistogramType:
+				buckets := make(map[float64]uint64, len(f.Buckets))
+				for i, b := range f.Buckets {
+					buckets[b] = s.BucketCounts[i]
+				}
+				m, err = prometheus.NewConstHistogram(desc, s.Count, s.Value, buckets, s.LabelValues...)
+			default:
+				continue
+			}
+			if err != nil {
+				m = prometheus.NewInvalidMetric(desc, err)
+			}
+			ch <- m
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/metrics"
+)
+
+func TestNewRegistryCollector(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.Counter("test_requests_total", "Requests.", "endpoint", "status").Inc("/a", "200")
+	r.Gauge("test_open", "Open breakers.").Set(1)
+	h := r.Histogram("test_duration_seconds", "Latency.", []float64{0.1, 1}, "endpoint")
+	h.Observe(0.05, "/a")
+	h.Observe(0.5, "/a")
+	h.Observe(2, "/a")
+
+	expected := `# HELP test_duration_seconds Latency.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{endpoint="/a",le="0.1"} 1
+test_duration_seconds_bucket{endpoint="/a",le="1"} 2
+test_duration_seconds_bucket{endpoint="/a",le="+Inf"} 3
+test_duration_seconds_sum{endpoint="/a"} 2.55
+test_duration_seconds_count{endpoint="/a"} 3
+# HELP test_open Open breakers.
+# TYPE test_open gauge
+test_open 1
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{endpoint="/a",status="200"} 1
+`
+	if err := testutil.CollectAndCompare(NewRegistryCollector(r), strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewCollector(t *testing.T) {
+	defer metrics.SetGlobal(nil)
+
+	c := NewCollector()
+	if n := testutil.CollectAndCount(c); n != 0 {
+		t.Errorf("unexpected metrics without a pipeline: %d", n)
+	}
+
+	metrics.SetGlobal(metrics.NewPipeline(metrics.NewRegistry(), nil))
+	p, _ := metrics.GetGlobal()
+	p.ObserveRequest("/a", "GET", 200, 50*time.Millisecond)
+	if n := testutil.CollectAndCount(c, "lura_router_requests_total"); n != 1 {
+		t.Errorf("unexpected number of metrics: %d", n)
+	}
+}
+
+func TestHandler_registered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer metrics.SetGlobal(nil)
+
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{metrics.Namespace: map[string]interface{}{
+		"listen_address": "127.0.0.1:0",
+	}}}
+	if _, ok, err := metrics.Register(ctx, cfg, logging.NoOp); !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	p, _ := metrics.GetGlobal()
+	p.ObserveRequest("/a", "GET", 200, 50*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range []string{
+		`lura_router_requests_total{endpoint="/a",method="GET",status="200"} 1`,
+		`# TYPE go_goroutines gauge`,
+	} {
+		if !strings.Contains(w.Body.String(), line) {
+			t.Errorf("%s not found in:\n%s", line, w.Body.String())
+		}
+	}
+}
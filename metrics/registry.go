@@ -0,0 +1,300 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds, in seconds, of the latency histograms when the config does
+// not declare them
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// The types of the metric families
+const (
+	CounterType   = "counter"
+	GaugeType     = "gauge"
+	HistogramType = "histogram"
+)
+
+// Registry contains the metric families and writes them with the Prometheus text format
+type Registry struct {
+	mu       sync.RWMutex
+	families map[string]*family
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+type family struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labels []string
+	value  float64
+	counts []uint64
+	count  uint64
+}
+
+// CounterVec is a family of counters partitioned by their label values
+type CounterVec struct{ f *family }
+
+// Add increases the counter with the label values
+func (c CounterVec) Add(v float64, labelValues ...string) {
+	c.f.update(labelValues, func(s *series) { s.value += v })
+}
+
+// Inc increases by one the counter with the label values
+func (c CounterVec) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+// GaugeVec is a family of gauges partitioned by their label values
+type GaugeVec struct{ f *family }
+
+// Set sets the value of the gauge with the label values
+func (g GaugeVec) Set(v float64, labelValues ...string) {
+	g.f.update(labelValues, func(s *series) { s.value = v })
+}
+
+// HistogramVec is a family of histograms partitioned by their label values
+type HistogramVec struct{ f *family }
+
+// Observe adds an observation to the histogram with the label values
+func (h HistogramVec) Observe(v float64, labelValues ...string) {
+	h.f.update(labelValues, func(s *series) {
+		if s.counts == nil {
+			s.counts = make([]uint64, len(h.f.buckets))
+		}
+		for i, b := range h.f.buckets {
+			if v <= b {
+				s.counts[i]++
+			}
+		}
+		s.count++
+		s.value += v
+	})
+}
+
+// Counter returns the counter family with the name, registering it if required
+func (r *Registry) Counter(name, help string, labels ...string) CounterVec {
+	return CounterVec{r.family(name, help, CounterType, labels, nil)}
+}
+
+// Gauge returns the gauge family with the name, registering it if required
+func (r *Registry) Gauge(name, help string, labels ...string) GaugeVec {
+	return GaugeVec{r.family(name, help, GaugeType, labels, nil)}
+}
+
+// Histogram returns the histogram family with the name, registering it if required. The buckets
+// are the upper bounds of the histogram, in increasing order
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) HistogramVec {
+	return HistogramVec{r.family(name, help, HistogramType, labels, buckets)}
+}
+
+func (r *Registry) family(name, help, typ string, labels []string, buckets []float64) *family {
+	r.mu.RLock()
+	f, ok := r.families[name]
+	r.mu.RUnlock()
+	if ok {
+		return f
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok = r.families[name]; ok {
+		return f
+	}
+	f = &family{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		series:  map[string]*series{},
+	}
+	r.families[name] = f
+	return f
+}
+
+func (f *family) update(labelValues []string, fn func(*series)) {
+	if len(labelValues) != len(f.labels) {
+		return
+	}
+	key := strings.Join(labelValues, "\xff")
+	f.mu.Lock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: append([]string{}, labelValues...)}
+		f.series[key] = s
+	}
+	fn(s)
+	f.mu.Unlock()
+}
+
+// Family is a snapshot of a metric family
+type Family struct {
+	Name string
+	Help string
+	// Type is one of CounterType, GaugeType or HistogramType
+	Type   string
+	Labels []string
+	// Buckets are the upper bounds of the histograms
+	Buckets []float64
+	Series  []Series
+}
+
+// Series is a snapshot of the metric of a family with some label values
+type Series struct {
+	LabelValues []string
+	// Value is the value of the counters and the gauges and the sum of the histograms
+	Value float64
+	// Count is the number of observations of the histograms
+	Count uint64
+	// BucketCounts are the cumulative counts of the buckets of the histograms
+	BucketCounts []uint64
+}
+
+// Gather returns a snapshot of the metric families, sorted by name and labels
+func (r *Registry) Gather() []Family {
+	r.mu.RLock()
+	fs := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		fs = append(fs, f)
+	}
+	r.mu.RUnlock()
+	sort.Slice(fs, func(i, j int) bool { return fs[i].name < fs[j].name })
+
+	res := make([]Family, len(fs))
+	for i, f := range fs {
+		res[i] = f.snapshot()
+	}
+	return res
+}
+
+// WriteTo writes the metrics with the Prometheus text format, sorted by name and labels
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	for _, f := range r.Gather() {
+		writeFamily(cw, f)
+	}
+	if err := bw.Flush(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, cw.err
+}
+
+// Handler returns a http handler exposing the metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+func (f *family) snapshot() Family {
+	f.mu.Lock()
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ss := make([]Series, len(keys))
+	for i, k := range keys {
+		s := f.series[k]
+		ss[i] = Series{LabelValues: s.labels, Value: s.value, Count: s.count}
+		if f.typ == HistogramType {
+			ss[i].BucketCounts = make([]uint64, len(f.buckets))
+			copy(ss[i].BucketCounts, s.counts)
+		}
+	}
+	f.mu.Unlock()
+
+	return Family{Name: f.name, Help: f.help, Type: f.typ, Labels: f.labels, Buckets: f.buckets, Series: ss}
+}
+
+func writeFamily(w *countingWriter, f Family) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.Name, escapeHelp(f.Help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.Name, f.Type)
+	for _, s := range f.Series {
+		if f.Type != HistogramType {
+			fmt.Fprintf(w, "%s%s %s\n", f.Name, labelPairs(f.Labels, s.LabelValues, "", ""), formatFloat(s.Value))
+			continue
+		}
+		for i, b := range f.Buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.Name, labelPairs(f.Labels, s.LabelValues, "le", formatFloat(b)), s.BucketCounts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.Name, labelPairs(f.Labels, s.LabelValues, "le", "+Inf"), s.Count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.Name, labelPairs(f.Labels, s.LabelValues, "", ""), formatFloat(s.Value))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.Name, labelPairs(f.Labels, s.LabelValues, "", ""), s.Count)
+	}
+}
+
+func labelPairs(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, n := range names {
+		pairs = append(pairs, n+`="`+escapeLabel(values[i])+`"`)
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+extraValue+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelReplacer.Replace(s) }
+
+func escapeHelp(s string) string { return helpReplacer.Replace(s) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("test_requests_total", "Requests.", "endpoint", "status")
+	c.Inc("/a", "200")
+	c.Inc("/a", "200")
+	c.Add(3, `/b"\`, "500")
+	c.Inc("/a")
+
+	g := r.Gauge("test_open", "Open\nbreakers.")
+	g.Set(1)
+
+	h := r.Histogram("test_duration_seconds", "Latency.", []float64{0.1, 1}, "endpoint")
+	h.Observe(0.05, "/a")
+	h.Observe(0.5, "/a")
+	h.Observe(2, "/a")
+
+	buf := new(bytes.Buffer)
+	if _, err := r.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP test_duration_seconds Latency.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{endpoint="/a",le="0.1"} 1
+test_duration_seconds_bucket{endpoint="/a",le="1"} 2
+test_duration_seconds_bucket{endpoint="/a",le="+Inf"} 3
+test_duration_seconds_sum{endpoint="/a"} 2.55
+test_duration_seconds_count{endpoint="/a"} 3
+# HELP test_open Open\nbreakers.
+# TYPE test_open gauge
+test_open 1
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{endpoint="/a",status="200"} 2
+test_requests_total{endpoint="/b\"\\",status="500"} 3
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Header().Get("Content-Type") != ContentType || w.Body.String() != expected {
+		t.Errorf("unexpected response: %s", w.Body.String())
+	}
+}
+
+func TestRegistry_Gather(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("test_requests_total", "Requests.", "endpoint").Inc("/a")
+	h := r.Histogram("test_duration_seconds", "Latency.", []float64{0.1, 1}, "endpoint")
+	h.Observe(0.5, "/a")
+
+	expected := []Family{
+		{
+			Name:    "test_duration_seconds",
+			Help:    "Latency.",
+			Type:    HistogramType,
+			Labels:  []string{"endpoint"},
+			Buckets: []float64{0.1, 1},
+			Series:  []Series{{LabelValues: []string{"/a"}, Value: 0.5, Count: 1, BucketCounts: []uint64{0, 1}}},
+		},
+		{
+			Name:   "test_requests_total",
+			Help:   "Requests.",
+			Type:   CounterType,
+			Labels: []string{"endpoint"},
+			Series: []Series{{LabelValues: []string{"/a"}, Value: 1}},
+		},
+	}
+	if fs := r.Gather(); !reflect.DeepEqual(fs, expected) {
+		t.Errorf("unexpected families: %+v", fs)
+	}
+}
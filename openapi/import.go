@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// ErrUnknownSpec is returned when the document is neither an OpenAPI 3 nor a Swagger 2 one
+var ErrUnknownSpec = errors.New("openapi: unknown specification")
+
+// ImportOptions customizes the endpoints created from a document
+type ImportOptions struct {
+	// Host overrides the hosts declared by the document
+	Host []string
+	// Prefix is added to the path of the endpoints
+	Prefix string
+}
+
+type importDocument struct {
+	OpenAPI  string                                `json:"openapi"`
+	Swagger  string                                `json:"swagger"`
+	Servers  []struct{ URL string }                `json:"servers"`
+	Host     string                                `json:"host"`
+	BasePath string                                `json:"basePath"`
+	Schemes  []string                              `json:"schemes"`
+	Paths    map[string]map[string]json.RawMessage `json:"paths"`
+
+	// the reusable params of the OpenAPI 3 and the Swagger 2 documents
+	Components struct {
+		Parameters map[string]importParameter `json:"parameters"`
+	} `json:"components"`
+	Parameters map[string]importParameter `json:"parameters"`
+}
+
+type importOperation struct {
+	Parameters []importParameter `json:"parameters"`
+}
+
+type importParameter struct {
+	Ref  string `json:"$ref"`
+	Name string `json:"name"`
+	In   string `json:"in"`
+}
+
+var importMethods = []string{"get", "post", "put", "patch", "delete"}
+
+// Import converts an OpenAPI 3 or Swagger 2 JSON document into the endpoints proxying its
+// operations. The query string and header params of the operations are forwarded to the
+// backends. The operations with methods not supported by the routers are ignored
+func Import(data []byte, opts ImportOptions) ([]*config.EndpointConfig, error) {
+	var doc importDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("openapi: parsing the document: %w", err)
+	}
+
+	var hosts []string
+	var basePath string
+	switch {
+	case strings.HasPrefix(doc.OpenAPI, "3."):
+		if len(doc.Servers) > 0 {
+			u, err := url.Parse(doc.Servers[0].URL)
+			if err != nil {
+				return nil, fmt.Errorf("openapi: invalid server url %s: %w", doc.Servers[0].URL, err)
+			}
+			if u.Host != "" {
+				hosts = []string{u.Scheme + "://" + u.Host}
+			}
+			basePath = u.Path
+		}
+	case doc.Swagger == "2.0":
+		if doc.Host != "" {
+			scheme := "http"
+			for _, s := range doc.Schemes {
+				if s == "https" {
+					scheme = s
+				}
+			}
+			hosts = []string{scheme + "://" + doc.Host}
+		}
+		basePath = doc.BasePath
+	default:
+		return nil, ErrUnknownSpec
+	}
+	if len(opts.Host) > 0 {
+		hosts = opts.Host
+	}
+	basePath = strings.TrimSuffix(basePath, "/")
+	prefix := strings.TrimSuffix(opts.Prefix, "/")
+
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	endpoints := []*config.EndpointConfig{}
+	for _, p := range paths {
+		item := doc.Paths[p]
+		var shared []importParameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, fmt.Errorf("openapi: parsing the params of %s: %w", p, err)
+			}
+		}
+		for _, m := range importMethods {
+			raw, ok := item[m]
+			if !ok {
+				continue
+			}
+			var op importOperation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("openapi: parsing the operation %s %s: %w", m, p, err)
+			}
+			method := strings.ToUpper(m)
+			e := &config.EndpointConfig{
+				Endpoint: prefix + p,
+				Method:   method,
+				Backend: []*config.Backend{
+					{
+						Host:       hosts,
+						URLPattern: basePath + p,
+						Method:     method,
+					},
+				},
+			}
+			params := append(append([]importParameter{}, shared...), op.Parameters...)
+			for _, param := range params {
+				param = doc.resolve(param)
+				switch param.In {
+				case "query":
+					e.QueryString = appendUnique(e.QueryString, param.Name)
+				case "header":
+					e.HeadersToPass = appendUnique(e.HeadersToPass, http.CanonicalHeaderKey(param.Name))
+				}
+			}
+			endpoints = append(endpoints, e)
+		}
+	}
+	return endpoints, nil
+}
+
+// resolve returns the param referenced by a local reference, if any
+func (d importDocument) resolve(p importParameter) importParameter {
+	switch {
+	case strings.HasPrefix(p.Ref, "#/components/parameters/"):
+		return d.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+	case strings.HasPrefix(p.Ref, "#/parameters/"):
+		return d.Parameters[strings.TrimPrefix(p.Ref, "#/parameters/")]
+	}
+	return p
+}
+
+func appendUnique(values []string, v string) []string {
+	for _, s := range values {
+		if s == v {
+			return values
+		}
+	}
+	return append(values, v)
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package openapi
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestImport_openAPI(t *testing.T) {
+	doc := `{
+		"openapi": "3.0.3",
+		"servers": [{"url": "https://users.example.com/v1"}],
+		"components": {"parameters": {"page": {"name": "page", "in": "query"}}},
+		"paths": {
+			"/users": {
+				"get": {"parameters": [{"$ref": "#/components/parameters/page"}, {"name": "x-tenant", "in": "header"}]},
+				"post": {},
+				"head": {}
+			},
+			"/users/{id}": {
+				"parameters": [{"name": "id", "in": "path", "required": true}],
+				"delete": {}
+			}
+		}
+	}`
+	endpoints, err := Import([]byte(doc), ImportOptions{Prefix: "/api/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := []string{"https://users.example.com"}
+	expected := []*config.EndpointConfig{
+		{
+			Endpoint:      "/api/users",
+			Method:        "GET",
+			QueryString:   []string{"page"},
+			HeadersToPass: []string{"X-Tenant"},
+			Backend:       []*config.Backend{{Host: hosts, URLPattern: "/v1/users", Method: "GET"}},
+		},
+		{
+			Endpoint: "/api/users",
+			Method:   "POST",
+			Backend:  []*config.Backend{{Host: hosts, URLPattern: "/v1/users", Method: "POST"}},
+		},
+		{
+			Endpoint: "/api/users/{id}",
+			Method:   "DELETE",
+			Backend:  []*config.Backend{{Host: hosts, URLPattern: "/v1/users/{id}", Method: "DELETE"}},
+		},
+	}
+	if !reflect.DeepEqual(endpoints, expected) {
+		for _, e := range endpoints {
+			t.Logf("%+v %+v", e, e.Backend[0])
+		}
+		t.Error("unexpected endpoints")
+	}
+}
+
+func TestImport_swagger(t *testing.T) {
+	doc := `{
+		"swagger": "2.0",
+		"host": "api.example.com",
+		"basePath": "/v2/",
+		"schemes": ["http", "https"],
+		"paths": {"/pets/{petId}": {"get": {}}}
+	}`
+	endpoints, err := Import([]byte(doc), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 1 {
+		t.Fatalf("unexpected endpoints %v", endpoints)
+	}
+	b := endpoints[0].Backend[0]
+	if endpoints[0].Endpoint != "/pets/{petId}" || b.URLPattern != "/v2/pets/{petId}" || !reflect.DeepEqual(b.Host, []string{"https://api.example.com"}) {
+		t.Errorf("unexpected endpoint %+v %+v", endpoints[0], b)
+	}
+
+	endpoints, err = Import([]byte(doc), ImportOptions{Host: []string{"http://localhost:8000"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := endpoints[0].Backend[0].Host; !reflect.DeepEqual(h, []string{"http://localhost:8000"}) {
+		t.Errorf("unexpected hosts %v", h)
+	}
+}
+
+func TestImport_ko(t *testing.T) {
+	if _, err := Import([]byte(`{"paths": {}}`), ImportOptions{}); !errors.Is(err, ErrUnknownSpec) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := Import([]byte(`{`), ImportOptions{}); err == nil {
+		t.Error("expecting an error")
+	}
+}
@@ -0,0 +1,309 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package openapi generates an OpenAPI 3 document describing the endpoints of the gateway, so the
+consumers always get an up-to-date contract of the exposed API.
+
+The document is served when the service extra config declares it:
+
+	"extra_config": {
+		"github.com/luraproject/lura/openapi": {
+			"path": "/__openapi",
+			"title": "My API",
+			"description": "The public API",
+			"version": "1.2.0"
+		}
+	}
+
+The paths, methods, params, query strings, headers and output encodings of the document are
+taken from the config of the endpoints, so no annotations are required.
+
+The Import function works the other way around, creating the endpoints proxying the operations
+of an existing OpenAPI or Swagger document.
+*/
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/router"
+	"github.com/luraproject/lura/v2/router/pathparams"
+)
+
+// Namespace is the key to use to store and access the openapi config
+const Namespace = "github.com/luraproject/lura/openapi"
+
+// DefaultPath is the path of the document used when the config does not declare one
+const DefaultPath = "/__openapi"
+
+// Version is the version of the OpenAPI specification of the generated documents
+const Version = "3.0.3"
+
+// Config defines the generated document and the endpoint serving it
+type Config struct {
+	Path        string
+	Title       string
+	Description string
+	Version     string
+}
+
+// ConfigGetter parses the openapi config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool) {
+	cfg := Config{Path: DefaultPath}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false
+	}
+	if p, ok := tmp["path"].(string); ok && p != "" {
+		cfg.Path = p
+	}
+	cfg.Title, _ = tmp["title"].(string)
+	cfg.Description, _ = tmp["description"].(string)
+	cfg.Version, _ = tmp["version"].(string)
+	return cfg, true
+}
+
+// Register returns the config of the document if the service config declares it
+func Register(cfg config.ServiceConfig) (Config, bool) {
+	return ConfigGetter(cfg.ExtraConfig)
+}
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI string              `json:"openapi"`
+	Info    Info                `json:"info"`
+	Paths   map[string]PathItem `json:"paths"`
+}
+
+// Info contains the metadata of the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem contains the operations of a path, indexed by the lowercased method
+type PathItem map[string]*Operation
+
+// Operation describes an endpoint
+type Operation struct {
+	OperationID string              `json:"operationId,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+	Deprecated  bool                `json:"deprecated,omitempty"`
+}
+
+// Parameter describes a param of an operation
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema,omitempty"`
+}
+
+// RequestBody describes the body of the requests to an operation
+type RequestBody struct {
+	Content map[string]MediaType `json:"content"`
+}
+
+// Response describes a response of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType describes the content of a body
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Schema describes a value
+type Schema struct {
+	Type    string   `json:"type,omitempty"`
+	Format  string   `json:"format,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+	Enum    []string `json:"enum,omitempty"`
+	Items   *Schema  `json:"items,omitempty"`
+}
+
+var (
+	routeParamPattern  = regexp.MustCompile(`/(?::|\*)([a-zA-Z\-_0-9]+)\??`)
+	markerParamPattern = regexp.MustCompile(`\{([a-zA-Z\-_0-9]+)(?:\?|\.\.\.)\}`)
+	pathParamPattern   = regexp.MustCompile(`\{([a-zA-Z\-_0-9]+)\}`)
+)
+
+// Generate returns the document describing the endpoints of the service
+func Generate(cfg config.ServiceConfig, c Config) Document {
+	doc := Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       c.Title,
+			Description: c.Description,
+			Version:     c.Version,
+		},
+		Paths: map[string]PathItem{},
+	}
+	if doc.Info.Title == "" {
+		doc.Info.Title = cfg.Name
+	}
+	if doc.Info.Title == "" {
+		doc.Info.Title = "lura"
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "1.0.0"
+	}
+
+	for _, e := range cfg.Endpoints {
+		method := strings.ToLower(e.Method)
+		if method == "" {
+			method = "get"
+		}
+		addOperation(doc.Paths, e.Endpoint, method, e, false)
+		for _, a := range e.Aliases {
+			addOperation(doc.Paths, a.Path, method, e, a.Deprecated)
+		}
+	}
+	return doc
+}
+
+// Handler returns a http handler serving the document of the service
+func Handler(cfg config.ServiceConfig, c Config) http.Handler {
+	b, err := json.Marshal(Generate(cfg, c))
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
+
+func addOperation(paths map[string]PathItem, endpoint, method string, e *config.EndpointConfig, deprecated bool) {
+	for _, p := range router.EndpointPaths(endpoint) {
+		p = routeParamPattern.ReplaceAllString(p, "/{$1}")
+		p = markerParamPattern.ReplaceAllString(p, "{$1}")
+
+		item, ok := paths[p]
+		if !ok {
+			item = PathItem{}
+			paths[p] = item
+		}
+		item[method] = newOperation(p, method, e, deprecated)
+	}
+}
+
+func newOperation(path, method string, e *config.EndpointConfig, deprecated bool) *Operation {
+	op := &Operation{
+		OperationID: operationID(method, path),
+		Responses:   map[string]Response{"200": {Description: "OK", Content: responseContent(e.OutputEncoding)}},
+		Deprecated:  deprecated,
+	}
+
+	constraints, _ := e.ExtraConfig[pathparams.Namespace].(map[string]interface{})
+	for _, m := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     m[1],
+			In:       "path",
+			Required: true,
+			Schema:   paramSchema(constraints, m[1]),
+		})
+	}
+	for _, q := range named(e.QueryString) {
+		op.Parameters = append(op.Parameters, Parameter{Name: q, In: "query", Schema: &Schema{Type: "string"}})
+	}
+	for _, h := range named(e.HeadersToPass) {
+		op.Parameters = append(op.Parameters, Parameter{Name: h, In: "header", Schema: &Schema{Type: "string"}})
+	}
+
+	switch method {
+	case "post", "put", "patch":
+		op.RequestBody = &RequestBody{Content: map[string]MediaType{"*/*": {}}}
+	}
+	return op
+}
+
+// named returns the sorted entries of a list of forwarded params, without the wildcards and the
+// exclusions
+func named(params []string) []string {
+	res := make([]string, 0, len(params))
+	for _, p := range params {
+		if p == "" || p == "*" || strings.HasPrefix(p, "!") {
+			continue
+		}
+		res = append(res, p)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// paramSchema returns the schema of a path param, applying the constraints declared with the
+// pathparams package
+func paramSchema(constraints map[string]interface{}, name string) *Schema {
+	s := &Schema{Type: "string"}
+	var c map[string]interface{}
+	for k, v := range constraints {
+		if strings.EqualFold(k, name) {
+			c, _ = v.(map[string]interface{})
+		}
+	}
+	if c == nil {
+		return s
+	}
+	switch c["type"] {
+	case pathparams.TypeInt:
+		s.Type = "integer"
+	case pathparams.TypeUUID:
+		s.Format = "uuid"
+	}
+	s.Pattern, _ = c["pattern"].(string)
+	if values, ok := c["enum"].([]interface{}); ok {
+		for _, v := range values {
+			if v, ok := v.(string); ok {
+				s.Enum = append(s.Enum, v)
+			}
+		}
+	}
+	return s
+}
+
+func responseContent(outputEncoding string) map[string]MediaType {
+	switch outputEncoding {
+	case encoding.NOOP:
+		return nil
+	case encoding.STRING:
+		return map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}
+	case "negotiate":
+		return map[string]MediaType{
+			"application/json": {Schema: &Schema{Type: "object"}},
+			"application/xml":  {Schema: &Schema{Type: "object"}},
+			"text/plain":       {Schema: &Schema{Type: "string"}},
+		}
+	case "xml":
+		return map[string]MediaType{"application/xml": {Schema: &Schema{Type: "object"}}}
+	case "yaml":
+		return map[string]MediaType{"application/x-yaml": {Schema: &Schema{Type: "object"}}}
+	case "json-collection":
+		return map[string]MediaType{"application/json": {Schema: &Schema{Type: "array", Items: &Schema{Type: "object"}}}}
+	}
+	return map[string]MediaType{"application/json": {Schema: &Schema{Type: "object"}}}
+}
+
+func operationID(method, path string) string {
+	id := method
+	for _, s := range strings.Split(path, "/") {
+		s = strings.Trim(s, "{}")
+		if s == "" {
+			continue
+		}
+		id += "_" + strings.NewReplacer("-", "_", ".", "_").Replace(s)
+	}
+	return id
+}
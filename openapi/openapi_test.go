@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/router/pathparams"
+)
+
+func TestGenerate(t *testing.T) {
+	cfg := config.ServiceConfig{
+		Name: "users",
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint:      "/users/:id",
+				Method:        "GET",
+				QueryString:   []string{"fields", "*", "!debug"},
+				HeadersToPass: []string{"Authorization"},
+				Aliases:       []config.EndpointAlias{{Path: "/people/:id", Deprecated: true}},
+				ExtraConfig: config.ExtraConfig{
+					pathparams.Namespace: map[string]interface{}{"id": map[string]interface{}{"type": "int"}},
+				},
+			},
+			{Endpoint: "/users", Method: "POST", OutputEncoding: "string"},
+			{Endpoint: "/files/*path", Method: "GET", OutputEncoding: "no-op"},
+			{Endpoint: "/orders/{id?}", Method: "DELETE"},
+		},
+	}
+	doc := Generate(cfg, Config{Version: "2.0.0"})
+
+	if doc.OpenAPI != Version || doc.Info.Title != "users" || doc.Info.Version != "2.0.0" {
+		t.Errorf("unexpected info %+v", doc.Info)
+	}
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	for _, p := range []string{"/users/{id}", "/people/{id}", "/users", "/files/{path}", "/orders", "/orders/{id}"} {
+		if _, ok := doc.Paths[p]; !ok {
+			t.Errorf("missing path %s in %v", p, paths)
+		}
+	}
+
+	op := doc.Paths["/users/{id}"]["get"]
+	expected := []Parameter{
+		{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer"}},
+		{Name: "fields", In: "query", Schema: &Schema{Type: "string"}},
+		{Name: "Authorization", In: "header", Schema: &Schema{Type: "string"}},
+	}
+	if !reflect.DeepEqual(op.Parameters, expected) {
+		b, _ := json.Marshal(op.Parameters)
+		t.Errorf("unexpected params %s", b)
+	}
+	if op.OperationID != "get_users_id" || op.Deprecated {
+		t.Errorf("unexpected operation %+v", op)
+	}
+	if !doc.Paths["/people/{id}"]["get"].Deprecated {
+		t.Error("the deprecated alias should be flagged")
+	}
+
+	post := doc.Paths["/users"]["post"]
+	if post.RequestBody == nil {
+		t.Error("missing request body")
+	}
+	if _, ok := post.Responses["200"].Content["text/plain"]; !ok {
+		t.Errorf("unexpected responses %+v", post.Responses)
+	}
+	if c := doc.Paths["/files/{path}"]["get"].Responses["200"].Content; c != nil {
+		t.Errorf("unexpected content %+v", c)
+	}
+	if params := doc.Paths["/orders"]["delete"].Parameters; len(params) != 0 {
+		t.Errorf("unexpected params %+v", params)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"title": "API"}},
+		Endpoints:   []*config.EndpointConfig{{Endpoint: "/a", Method: "GET"}},
+	}
+	c, ok := Register(cfg)
+	if !ok || c.Path != DefaultPath {
+		t.Fatalf("unexpected config %+v", c)
+	}
+
+	w := httptest.NewRecorder()
+	Handler(cfg, c).ServeHTTP(w, httptest.NewRequest("GET", c.Path, http.NoBody))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected response %d %v", w.Code, w.Header())
+	}
+	var doc Document
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Info.Title != "API" || len(doc.Paths) != 1 {
+		t.Errorf("unexpected document %+v", doc)
+	}
+}
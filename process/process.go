@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package process provides a runner for the main function of a gateway binary that behaves
+properly as a container init process (PID 1) and as a Windows service.
+
+The main function receives a context cancelled when the process is asked to stop (SIGINT and
+SIGTERM, or a stop request from the Windows service control manager). A second termination
+signal aborts the graceful shutdown. The runner translates the result into an exit code.
+*/
+package process
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+)
+
+// MainFunc is the main function of the process. It must return when the context is cancelled
+type MainFunc func(context.Context) error
+
+// ExitError allows the main function to control the exit code of the process
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+// Error returns a string representation of the ExitError
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return "exit status " + strconv.Itoa(e.Code)
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// ExitCode translates the result of the main function into an exit code: 0 for nil or
+// context.Canceled, the code of the ExitError and 1 for any other error
+func ExitCode(err error) int {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return 0
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return 1
+}
+
+// IsPID1 returns true if the process is the init process of its namespace (i.e: a container
+// without an init system). The kernel does not apply the default actions of the signals to
+// the init process, so it must handle them explicitly, as Run does.
+func IsPID1() bool {
+	return os.Getpid() == 1
+}
+
+// Run executes the main function until it returns and returns the exit code of the process.
+// When running as a Windows service, the service name is used to register in the service
+// control manager. It is meant to be used as `os.Exit(process.Run("krakend", run))`
+func Run(name string, main MainFunc) int {
+	return run(name, main)
+}
+
+// RunInteractive executes the main function, cancelling its context on SIGINT or SIGTERM.
+// If a second signal is received before the main function returns, RunInteractive returns
+// immediately with the conventional 128+signal exit code
+func RunInteractive(main MainFunc) int {
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	return runWithSignals(main, sigs)
+}
+
+func runWithSignals(main MainFunc, sigs <-chan os.Signal) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- main(ctx) }()
+
+	select {
+	case err := <-done:
+		return ExitCode(err)
+	case <-sigs:
+		cancel()
+	}
+
+	select {
+	case err := <-done:
+		return ExitCode(err)
+	case sig := <-sigs:
+		return signalExitCode(sig)
+	}
+}
+
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExitCode(t *testing.T) {
+	for i, tc := range []struct {
+		err  error
+		code int
+	}{
+		{err: nil, code: 0},
+		{err: context.Canceled, code: 0},
+		{err: fmt.Errorf("wrapped: %w", context.Canceled), code: 0},
+		{err: errors.New("boom"), code: 1},
+		{err: &ExitError{Code: 3, Err: errors.New("bad config")}, code: 3},
+		{err: fmt.Errorf("wrapped: %w", &ExitError{Code: 4}), code: 4},
+	} {
+		if code := ExitCode(tc.err); code != tc.code {
+			t.Errorf("#%d: unexpected exit code. have: %d, want: %d", i, code, tc.code)
+		}
+	}
+}
+
+func TestRunWithSignals_gracefulShutdown(t *testing.T) {
+	sigs := make(chan os.Signal, 2)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		sigs <- syscall.SIGTERM
+	}()
+
+	code := runWithSignals(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, sigs)
+	if code != 0 {
+		t.Errorf("unexpected exit code: %d", code)
+	}
+}
+
+func TestRunWithSignals_forcedShutdown(t *testing.T) {
+	sigs := make(chan os.Signal, 2)
+	sigs <- syscall.SIGTERM
+	sigs <- syscall.SIGTERM
+
+	block := make(chan struct{})
+	defer close(block)
+	code := runWithSignals(func(_ context.Context) error {
+		<-block
+		return nil
+	}, sigs)
+	if code != 128+int(syscall.SIGTERM) {
+		t.Errorf("unexpected exit code: %d", code)
+	}
+}
+
+func TestRunWithSignals_mainError(t *testing.T) {
+	code := runWithSignals(func(_ context.Context) error {
+		return &ExitError{Code: 2}
+	}, make(chan os.Signal))
+	if code != 2 {
+		t.Errorf("unexpected exit code: %d", code)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+// SPDX-License-Identifier: Apache-2.0
+
+package process
+
+func run(_ string, main MainFunc) int {
+	return RunInteractive(main)
+}
@@ -0,0 +1,61 @@
+//go:build windows
+// +build windows
+
+// SPDX-License-Identifier: Apache-2.0
+
+package process
+
+import (
+	"context"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+func run(name string, main MainFunc) int {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return RunInteractive(main)
+	}
+	h := &serviceHandler{main: main}
+	if err := svc.Run(name, h); err != nil {
+		return 1
+	}
+	return h.exitCode
+}
+
+type serviceHandler struct {
+	main     MainFunc
+	exitCode int
+}
+
+// Execute implements the svc.Handler interface, translating the requests of the service
+// control manager into the cancellation of the main function context
+func (h *serviceHandler) Execute(_ []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+	s <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- h.main(ctx) }()
+
+	s <- svc.Status{State: svc.Running, Accepts: accepted}
+
+	for {
+		select {
+		case err := <-done:
+			h.exitCode = ExitCode(err)
+			s <- svc.Status{State: svc.StopPending}
+			return h.exitCode != 0, uint32(h.exitCode)
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				s <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				s <- svc.Status{State: svc.StopPending}
+				cancel()
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+// ExecutorFactory creates the executor sending the requests to a backend. It is the only piece a
+// custom transport has to provide: the proxies created with NewBackendFactory build the requests,
+// handle the status codes and decode the responses like the http backends do
+type ExecutorFactory func(remote *config.Backend) (client.HTTPRequestExecutor, error)
+
+// BackendOptions customizes the proxies created by NewBackendFactory. The zero values get the
+// defaults of the http backends
+type BackendOptions struct {
+	// Decoder decodes the response bodies. It defaults to the decoder of the backend encoding
+	Decoder encoding.Decoder
+	// StatusHandler validates the responses. It defaults to client.GetHTTPStatusHandler
+	StatusHandler client.HTTPStatusHandler
+	// ResponseParser translates the responses. It defaults to the parser decoding the body with
+	// the Decoder and formatting it with the entity formatter of the backend
+	ResponseParser HTTPResponseParser
+}
+
+// NewBackendFactory returns a BackendFactory creating the proxies with the executors of the
+// factory and the options. The executor factory errors are returned by every request to the
+// backend, so the misconfigurations are not hidden
+func NewBackendFactory(ef ExecutorFactory, opts BackendOptions) BackendFactory {
+	return func(remote *config.Backend) Proxy {
+		re, err := ef(remote)
+		if err != nil {
+			re = failingExecutor(err)
+		}
+		if remote.Encoding == encoding.NOOP {
+			return NewHTTPProxyDetailed(remote, re, client.NoOpHTTPStatusHandler, NoOpHTTPResponseParser)
+		}
+
+		ch := opts.StatusHandler
+		if ch == nil {
+			ch = client.GetHTTPStatusHandler(remote)
+		}
+		rp := opts.ResponseParser
+		if rp == nil {
+			dec := opts.Decoder
+			if dec == nil {
+				dec = remote.Decoder
+			}
+			rp = DefaultHTTPResponseParserFactory(HTTPResponseParserConfig{dec, NewEntityFormatter(remote)})
+		}
+		return NewHTTPProxyDetailed(remote, re, ch, rp)
+	}
+}
+
+// BackendFactoryMiddleware decorates a BackendFactory
+type BackendFactoryMiddleware func(BackendFactory) BackendFactory
+
+// ChainBackendFactory returns the BackendFactory decorated with the middlewares. The first
+// middleware is the outermost one
+func ChainBackendFactory(bf BackendFactory, mws ...BackendFactoryMiddleware) BackendFactory {
+	for i := len(mws) - 1; i >= 0; i-- {
+		bf = mws[i](bf)
+	}
+	return bf
+}
+
+// WithBackendMiddleware adapts a BackendMiddlewareFactory, so its middleware wraps every proxy
+// created by the decorated factory
+func WithBackendMiddleware(logger logging.Logger, mf BackendMiddlewareFactory) BackendFactoryMiddleware {
+	return func(bf BackendFactory) BackendFactory {
+		return func(remote *config.Backend) Proxy {
+			return mf(logger, remote)(bf(remote))
+		}
+	}
+}
+
+// WithExecutorMiddleware decorates the executors created by the factory, i.e. to add headers or
+// to sign the requests of a custom transport
+func WithExecutorMiddleware(ef ExecutorFactory, mw func(*config.Backend, client.HTTPRequestExecutor) client.HTTPRequestExecutor) ExecutorFactory {
+	return func(remote *config.Backend) (client.HTTPRequestExecutor, error) {
+		re, err := ef(remote)
+		if err != nil {
+			return nil, err
+		}
+		return mw(remote, re), nil
+	}
+}
@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package backend creates the http proxies of the backends with the options declared in their extra
+config: the client TLS, the dialer, the connection pool and the egress proxy settings, the gRPC
+transcoding, the SOAP and the limited decoders, and the features decorating the request executors,
+like the signatures, the request ids or the traces.
+
+The factory replaces the default http backend factory of the proxy package:
+
+	bf := backend.NewFactory(client.NewHTTPClient)
+	pf := proxy.NewDefaultFactory(bf, logger)
+*/
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/governor"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/requestid"
+	"github.com/luraproject/lura/v2/tracing"
+	"github.com/luraproject/lura/v2/transport/http/client"
+	"github.com/luraproject/lura/v2/transport/http/client/grpc"
+	"github.com/luraproject/lura/v2/transport/http/client/signing"
+	"github.com/luraproject/lura/v2/transport/http/client/soap"
+	"github.com/luraproject/lura/v2/transport/http/server"
+)
+
+// ExecutorDecorator wraps the request executor of a backend with one of the features declared in
+// its config. The TLS config is the one of the backend, if it declares any
+type ExecutorDecorator func(*config.Backend, *tls.Config, client.HTTPRequestExecutor) client.HTTPRequestExecutor
+
+// DefaultExecutorDecorators returns the decorators of the default factory. They are applied in
+// order, so the last ones see the requests first. A new slice is returned on every call, so the
+// callers can add their own decorators
+func DefaultExecutorDecorators() []ExecutorDecorator {
+	return []ExecutorDecorator{
+		withProtocolSelection,
+		withDecompression,
+		withMaxResponseSize,
+		withGovernor,
+		withSigning,
+		withHost,
+		withRequestID,
+		withTracing,
+	}
+}
+
+// NewFactory returns a BackendFactory creating http proxies with the received HTTPClientFactory,
+// the options declared by the backends and the DefaultExecutorDecorators
+func NewFactory(cf client.HTTPClientFactory) proxy.BackendFactory {
+	return NewFactoryWithDecorators(cf, DefaultExecutorDecorators()...)
+}
+
+// NewFactoryWithDecorators returns a BackendFactory creating http proxies with the received
+// HTTPClientFactory, the options declared by the backends and the decorators. The invalid configs
+// fail all the requests to the backend
+func NewFactoryWithDecorators(cf client.HTTPClientFactory, decorators ...ExecutorDecorator) proxy.BackendFactory {
+	return func(remote *config.Backend) proxy.Proxy {
+		decode, err := backendDecoder(remote, remote.Decoder)
+		if err != nil {
+			return proxy.NewHTTPProxyWithHTTPExecutor(remote, failingExecutor(err), decode)
+		}
+		re, err := backendExecutor(remote, cf, decorators)
+		if err != nil {
+			return proxy.NewHTTPProxyWithHTTPExecutor(remote, failingExecutor(err), decode)
+		}
+		return proxy.NewHTTPProxyWithHTTPExecutor(remote, re, decode)
+	}
+}
+
+// backendDecoder returns the SOAP decoder for the SOAP backends, bounded by the decoding limits of
+// the backend, if any
+func backendDecoder(remote *config.Backend, decode encoding.Decoder) (encoding.Decoder, error) {
+	_, err := soap.GetOptions(remote.ExtraConfig)
+	isSOAP := err != soap.ErrNoConfigFound
+	if isSOAP && err != nil {
+		return decode, err
+	}
+	if isSOAP {
+		decode = soap.NewDecoder(remote.IsCollection)
+	}
+	limits, ok, err := encoding.LimitsFromExtraConfig(remote.ExtraConfig)
+	if err != nil || !ok {
+		return decode, err
+	}
+	if isSOAP {
+		return encoding.NewLimitedReaderDecoder(limits.MaxBytes, decode), nil
+	}
+	return limitedDecoder(remote, limits, decode), nil
+}
+
+// backendExecutor returns the request executor of the backend: the one of its http client,
+// decorated with the decorators
+func backendExecutor(remote *config.Backend, cf client.HTTPClientFactory, decorators []ExecutorDecorator) (client.HTTPRequestExecutor, error) {
+	tlsConfig, cf := tlsClientFactory(remote, cf)
+	cf, err := transportClientFactory(remote, tlsConfig, cf)
+	if err != nil {
+		return nil, err
+	}
+	re, err := grpcExecutor(remote, tlsConfig, cf)
+	if err != nil {
+		return nil, err
+	}
+	for _, decorate := range decorators {
+		re = decorate(remote, tlsConfig, re)
+	}
+	return re, nil
+}
+
+// tlsClientFactory returns the TLS config of the backends declaring their own client TLS options
+// or server name, and a client factory using it
+func tlsClientFactory(remote *config.Backend, cf client.HTTPClientFactory) (*tls.Config, client.HTTPClientFactory) {
+	var tlsConfig *tls.Config
+	if remote.ClientTLS != nil {
+		tlsConfig = server.ParseClientTLSConfigWithLogger(remote.ClientTLS, nil)
+		cf = client.NewTLSHTTPClientFactory(tlsConfig)
+	}
+	if name, ok := client.ServerNameGetter(remote.ExtraConfig); ok {
+		tlsConfig = client.WithServerName(tlsConfig, name)
+		cf = client.NewTLSHTTPClientFactory(tlsConfig)
+	}
+	return tlsConfig, cf
+}
+
+// transportClientFactory returns a dedicated client factory for the backends declaring their own
+// dialer, connection pool settings or egress proxy, so they do not share the connections of the
+// rest
+func transportClientFactory(remote *config.Backend, tlsConfig *tls.Config, cf client.HTTPClientFactory) (client.HTTPClientFactory, error) {
+	dialerCfg, hasDialer, err := client.DialerConfigGetter(remote.ExtraConfig)
+	if err != nil {
+		return nil, err
+	}
+	transportCfg, hasTransport, err := client.TransportConfigGetter(remote.ExtraConfig)
+	if err != nil {
+		return nil, err
+	}
+	proxyURL, hasProxy, err := client.OutboundProxyConfigGetter(remote.ExtraConfig)
+	if err != nil {
+		return nil, err
+	}
+	if !hasTransport && !hasProxy {
+		if hasDialer {
+			return client.NewDialerHTTPClientFactory(dialerCfg, tlsConfig), nil
+		}
+		return cf, nil
+	}
+	var dialer *client.DialerConfig
+	if hasDialer {
+		dialer = &dialerCfg
+	}
+	if hasProxy {
+		return client.NewOutboundProxyHTTPClientFactory(proxyURL, transportCfg, dialer, tlsConfig), nil
+	}
+	return client.NewTransportHTTPClientFactory(transportCfg, dialer, tlsConfig), nil
+}
+
+// grpcExecutor returns the executor of the client factory or, for the backends declaring gRPC
+// descriptor sets, one transcoding the JSON requests and responses over HTTP/2 clients
+func grpcExecutor(remote *config.Backend, tlsConfig *tls.Config, cf client.HTTPClientFactory) (client.HTTPRequestExecutor, error) {
+	grpcCfg, err := grpc.ConfigGetter(remote.ExtraConfig)
+	if err == grpc.ErrNoConfigFound {
+		return client.DefaultHTTPRequestExecutor(cf), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	files, err := grpc.LoadDescriptors(grpcCfg.DescriptorSets)
+	if err != nil {
+		return nil, err
+	}
+	return grpc.NewExecutor(files, client.DefaultHTTPRequestExecutor(grpc.NewHTTPClientFactory(tlsConfig))), nil
+}
+
+// withProtocolSelection reaches the hosts with declared ALPN protocols through dedicated clients
+// negotiating them
+func withProtocolSelection(remote *config.Backend, tlsConfig *tls.Config, re client.HTTPRequestExecutor) client.HTTPRequestExecutor {
+	protocols, ok := client.ProtocolsConfigGetter(remote.ExtraConfig)
+	if !ok {
+		return re
+	}
+	pre, err := client.NewProtocolSelectionExecutor(protocols, tlsConfig, re)
+	if err != nil {
+		return failingExecutor(err)
+	}
+	return pre
+}
+
+// withDecompression decompresses the bodies to decode, even if the backend ignores the negotiation
+func withDecompression(remote *config.Backend, _ *tls.Config, re client.HTTPRequestExecutor) client.HTTPRequestExecutor {
+	if client.CompressedPassthroughGetter(remote.ExtraConfig) {
+		return re
+	}
+	return client.NewDecompressionExecutor(remote.Encoding != encoding.NOOP, re)
+}
+
+// withMaxResponseSize bounds the response bodies by the max response size of the backend
+func withMaxResponseSize(remote *config.Backend, _ *tls.Config, re client.HTTPRequestExecutor) client.HTTPRequestExecutor {
+	limit, ok, err := client.MaxResponseSizeGetter(remote.ExtraConfig)
+	if err != nil {
+		return failingExecutor(err)
+	}
+	if !ok {
+		return re
+	}
+	return client.NewMaxResponseSizeExecutor(limit, re)
+}
+
+// withGovernor counts the decoded bodies, kept in memory, against the buffered bytes
+func withGovernor(remote *config.Backend, _ *tls.Config, re client.HTTPRequestExecutor) client.HTTPRequestExecutor {
+	if _, ok := governor.GetGlobal(); !ok || remote.Encoding == encoding.NOOP {
+		return re
+	}
+	return client.NewGovernorExecutor(re)
+}
+
+// withSigning signs the requests right before sending them
+func withSigning(remote *config.Backend, _ *tls.Config, re client.HTTPRequestExecutor) client.HTTPRequestExecutor {
+	signer, ok, err := signing.SignerFromConfig(remote.ExtraConfig)
+	if err != nil {
+		return failingExecutor(err)
+	}
+	if !ok {
+		return re
+	}
+	return signing.NewSigningExecutor(signer, re)
+}
+
+// withHost sets the Host header declared by the backend, before signing the request
+func withHost(remote *config.Backend, _ *tls.Config, re client.HTTPRequestExecutor) client.HTTPRequestExecutor {
+	_, ok, err := client.HostConfigGetter(remote.ExtraConfig)
+	if err != nil {
+		return failingExecutor(err)
+	}
+	if !ok {
+		return re
+	}
+	return client.NewHostExecutor(re)
+}
+
+// withRequestID sets the request id, before signing the request
+func withRequestID(_ *config.Backend, _ *tls.Config, re client.HTTPRequestExecutor) client.HTTPRequestExecutor {
+	g, ok := requestid.GetGlobal()
+	if !ok {
+		return re
+	}
+	return client.NewRequestIDExecutor(g, re)
+}
+
+// withTracing opens a client span per request and propagates the trace to the backend
+func withTracing(_ *config.Backend, _ *tls.Config, re client.HTTPRequestExecutor) client.HTTPRequestExecutor {
+	t, ok := tracing.GetGlobal()
+	if !ok {
+		return re
+	}
+	return client.NewTracingExecutor(t, re)
+}
+
+// limitedDecoder replaces the json decoders with the token based ones enforcing the limits. The
+// rest of the decoders just get their input bounded to the max bytes
+func limitedDecoder(remote *config.Backend, l encoding.Limits, decode encoding.Decoder) encoding.Decoder {
+	switch strings.ToLower(remote.Encoding) {
+	case "", encoding.JSON:
+		return encoding.NewLimitedJSONDecoder(l)(remote.IsCollection)
+	case encoding.SAFE_JSON:
+		return encoding.NewLimitedSafeJSONDecoder(l)(remote.IsCollection)
+	}
+	return encoding.NewLimitedReaderDecoder(l.MaxBytes, decode)
+}
+
+// failingExecutor returns an executor failing with the error of an invalid backend config, so
+// the misconfiguration is not hidden behind the default client settings
+func failingExecutor(err error) client.HTTPRequestExecutor {
+	return func(_ context.Context, _ *http.Request) (*http.Response, error) { return nil, err }
+}
@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/transport/http/client"
+	"github.com/luraproject/lura/v2/transport/http/client/grpc"
+	"github.com/luraproject/lura/v2/transport/http/client/soap"
+)
+
+const soapTemplate = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>` +
+	`<GetUser xmlns="urn:users"><Id>{{.Params.Id}}</Id><Name>{{.Body.name}}</Name></GetUser>` +
+	`</soap:Body></soap:Envelope>`
+
+func TestNewFactory_decodingLimits(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"id":1},{"id":2},{"id":3}]`)
+	}))
+	defer backendServer.Close()
+
+	rpURL, _ := url.Parse(backendServer.URL)
+	for _, tc := range []struct {
+		maxElements int
+		err         error
+	}{
+		{maxElements: 6},
+		{maxElements: 5, err: encoding.ErrMaxElements},
+	} {
+		backend := config.Backend{
+			IsCollection: true,
+			Decoder:      encoding.NewJSONDecoder(true),
+			ExtraConfig: config.ExtraConfig{
+				encoding.LimitsNamespace: map[string]interface{}{"max_elements": tc.maxElements},
+			},
+		}
+		request := proxy.Request{Method: "GET", Path: "/", URL: rpURL, Body: http.NoBody}
+		response, err := NewFactory(client.NewHTTPClient)(&backend)(context.Background(), &request)
+		if err != tc.err {
+			t.Errorf("max elements %d: unexpected error: %v", tc.maxElements, err)
+			continue
+		}
+		if err == nil && len(response.Data["collection"].([]interface{})) != 3 {
+			t.Errorf("max elements %d: unexpected response: %v", tc.maxElements, response.Data)
+		}
+	}
+}
+
+func TestNewFactory_grpcMisconfigured(t *testing.T) {
+	rpURL, _ := url.Parse("http://127.0.0.1:50051/orders.Orders/GetOrder")
+	for _, cfg := range []map[string]interface{}{
+		{},
+		{"descriptor_sets": []interface{}{"./unknown.pb"}},
+	} {
+		backend := config.Backend{
+			Decoder:     encoding.JSONDecoder,
+			ExtraConfig: config.ExtraConfig{grpc.Namespace: cfg},
+		}
+		request := proxy.Request{Method: "POST", Path: "/", URL: rpURL, Body: http.NoBody}
+		if _, err := NewFactory(client.NewHTTPClient)(&backend)(context.Background(), &request); err == nil {
+			t.Errorf("%v: expecting an error", cfg)
+		}
+	}
+}
+
+func TestNewFactory_decompression(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("header") != "" {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		// the backend ignores the negotiation and always compresses its responses
+		zw := gzip.NewWriter(w)
+		fmt.Fprintf(zw, `{"supu":"tupu"}`)
+		zw.Close()
+	}))
+	defer backendServer.Close()
+
+	for _, query := range []string{"", "header=1"} {
+		rpURL, _ := url.Parse(backendServer.URL + "/?" + query)
+		backend := config.Backend{Decoder: encoding.JSONDecoder}
+		request := proxy.Request{Method: "GET", Path: "/", URL: rpURL, Body: http.NoBody, Headers: map[string][]string{"Accept-Encoding": {"br"}}}
+		response, err := NewFactory(client.NewHTTPClient)(&backend)(context.Background(), &request)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", query, err)
+			continue
+		}
+		if response.Data["supu"] != "tupu" {
+			t.Errorf("%s: unexpected response: %v", query, response.Data)
+		}
+	}
+
+	rpURL, _ := url.Parse(backendServer.URL + "/?header=1")
+	backend := config.Backend{
+		Encoding:    encoding.NOOP,
+		ExtraConfig: config.ExtraConfig{client.Namespace: map[string]interface{}{"compressed_passthrough": true}},
+	}
+	request := proxy.Request{Method: "GET", Path: "/", URL: rpURL, Body: http.NoBody, Headers: map[string][]string{"Accept-Encoding": {"gzip"}}}
+	response, err := NewFactory(client.NewHTTPClient)(&backend)(context.Background(), &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Metadata.Headers["Content-Encoding"][0] != "gzip" {
+		t.Errorf("unexpected headers: %v", response.Metadata.Headers)
+	}
+	b, _ := io.ReadAll(response.Io)
+	if len(b) < 2 || b[0] != 0x1f || b[1] != 0x8b {
+		t.Errorf("the body should be forwarded compressed: %q", b)
+	}
+}
+
+func TestNewFactory_soap(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Soapaction") == `"urn:users#Unknown"` {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:Fault>`+
+				`<faultcode>soap:Client</faultcode><faultstring>unknown action</faultstring></soap:Fault></soap:Body></soap:Envelope>`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`+
+			`<m:GetUserResponse xmlns:m="urn:users"><m:Id>42</m:Id><m:Name>John</m:Name></m:GetUserResponse>`+
+			`</soap:Body></soap:Envelope>`)
+	}))
+	defer backendServer.Close()
+
+	rpURL, _ := url.Parse(backendServer.URL)
+	backend := config.Backend{
+		Decoder: encoding.JSONDecoder,
+		ExtraConfig: config.ExtraConfig{
+			soap.Namespace: map[string]interface{}{
+				"template": soapTemplate,
+				"action":   "urn:users#GetUser",
+			},
+		},
+	}
+	prxy := proxy.NewSOAPMiddleware(logging.NoOp, &backend)(NewFactory(client.NewHTTPClient)(&backend))
+	request := proxy.Request{
+		Method:  http.MethodGet,
+		Path:    "/",
+		URL:     rpURL,
+		Params:  map[string]string{"Id": "42"},
+		Headers: map[string][]string{},
+	}
+	resp, err := prxy(context.Background(), &request)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{"Id": "42", "Name": "John"}
+	if !reflect.DeepEqual(resp.Data, expected) {
+		t.Errorf("unexpected data: %v", resp.Data)
+	}
+
+	backend.ExtraConfig[soap.Namespace].(map[string]interface{})["action"] = "urn:users#Unknown"
+	prxy = proxy.NewSOAPMiddleware(logging.NoOp, &backend)(NewFactory(client.NewHTTPClient)(&backend))
+	request = proxy.Request{Method: http.MethodGet, Path: "/", URL: rpURL, Headers: map[string][]string{}}
+	if _, err := prxy(context.Background(), &request); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestNewFactory_soapMisconfigured(t *testing.T) {
+	rpURL, _ := url.Parse("http://127.0.0.1:8080/users")
+	backend := config.Backend{
+		Decoder:     encoding.JSONDecoder,
+		ExtraConfig: config.ExtraConfig{soap.Namespace: map[string]interface{}{"version": "3"}},
+	}
+	request := proxy.Request{Method: "POST", Path: "/", URL: rpURL, Body: http.NoBody}
+	_, err := NewFactory(client.NewHTTPClient)(&backend)(context.Background(), &request)
+	if err == nil || errors.Is(err, soap.ErrNoConfigFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewFactoryWithDecorators(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"order":%q}`, r.Header.Get("X-Order"))
+	}))
+	defer backendServer.Close()
+
+	decorator := func(name string) ExecutorDecorator {
+		return func(_ *config.Backend, _ *tls.Config, next client.HTTPRequestExecutor) client.HTTPRequestExecutor {
+			return func(ctx context.Context, r *http.Request) (*http.Response, error) {
+				r.Header.Add("X-Order", name)
+				return next(ctx, r)
+			}
+		}
+	}
+	bf := NewFactoryWithDecorators(client.NewHTTPClient, append(DefaultExecutorDecorators(), decorator("inner"), decorator("outer"))...)
+
+	rpURL, _ := url.Parse(backendServer.URL)
+	backend := config.Backend{Decoder: encoding.JSONDecoder}
+	request := proxy.Request{Method: "GET", Path: "/", URL: rpURL, Body: http.NoBody}
+	response, err := bf(&backend)(context.Background(), &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Data["order"] != "outer" {
+		t.Errorf("unexpected response: %v", response.Data)
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+type backendMetadataKey struct{}
+
+// backendMetadata stores the status codes and the headers of the responses of every backend of
+// an endpoint, for the endpoint middlewares depending on them, like the response headers and the
+// success status ones
+type backendMetadata struct {
+	mu       sync.Mutex
+	backends []*config.Backend
+	metas    []Metadata
+}
+
+// withBackendMetadata returns a context collecting the metadata of the responses of the backends.
+// The collector already in the context is reused if it collects the same backends, so several
+// middlewares of the endpoint can share it
+func withBackendMetadata(ctx context.Context, backends []*config.Backend) (context.Context, *backendMetadata) {
+	if m, ok := ctx.Value(backendMetadataKey{}).(*backendMetadata); ok && sameBackends(m.backends, backends) {
+		return ctx, m
+	}
+	m := &backendMetadata{backends: backends, metas: make([]Metadata, len(backends))}
+	return context.WithValue(ctx, backendMetadataKey{}, m), m
+}
+
+func sameBackends(a, b []*config.Backend) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *backendMetadata) record(remote *config.Backend, meta Metadata) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, b := range m.backends {
+		if b == remote {
+			m.metas[i] = meta
+			return
+		}
+	}
+}
+
+// collected returns a copy of the metadata of the backends, in their declaration order
+func (m *backendMetadata) collected() []Metadata {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]Metadata, len(m.metas))
+	copy(res, m.metas)
+	return res
+}
+
+// NewBackendMetadataMiddleware creates a proxy middleware recording the status code and the
+// headers of the responses of the backend for the middlewares of its endpoint depending on them.
+// It does nothing if the endpoint does not collect them
+func NewBackendMetadataMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: NewBackendMetadataMiddleware only accepts 1 proxy, got %d", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			m, ok := ctx.Value(backendMetadataKey{}).(*backendMetadata)
+			if !ok {
+				return next[0](ctx, r)
+			}
+			capture := &metadataCapture{}
+			resp, err := next[0](newMetadataCaptureContext(ctx, capture), r)
+			meta := capture.metadata()
+			if meta.StatusCode == 0 && resp != nil {
+				meta = resp.Metadata
+			}
+			if meta.StatusCode != 0 || len(meta.Headers) > 0 {
+				m.record(remote, meta)
+			}
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+func TestNewBackendFactory(t *testing.T) {
+	var received *http.Request
+	ef := func(_ *config.Backend) (client.HTTPRequestExecutor, error) {
+		return func(_ context.Context, req *http.Request) (*http.Response, error) {
+			received = req
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(bytes.NewBufferString(`{"id": 42, "name": "foo"}`)),
+			}, nil
+		}, nil
+	}
+	remote := &config.Backend{Decoder: encoding.JSONDecoder, DenyList: []string{"name"}}
+	p := NewBackendFactory(ef, BackendOptions{})(remote)
+
+	u, _ := url.Parse("http://example.com/users/42")
+	resp, err := p(context.Background(), &Request{Method: "GET", URL: u, Headers: map[string][]string{"X-Foo": {"bar"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.URL.String() != "http://example.com/users/42" || received.Header.Get("X-Foo") != "bar" {
+		t.Errorf("unexpected request %+v", received)
+	}
+	if !resp.IsComplete || len(resp.Data) != 1 || fmt.Sprint(resp.Data["id"]) != "42" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestNewBackendFactory_options(t *testing.T) {
+	ef := func(_ *config.Backend) (client.HTTPRequestExecutor, error) {
+		return func(_ context.Context, _ *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusTeapot, Body: io.NopCloser(bytes.NewBufferString("{}"))}, nil
+		}, nil
+	}
+	var handled bool
+	opts := BackendOptions{
+		StatusHandler: func(_ context.Context, resp *http.Response) (*http.Response, error) {
+			handled = true
+			return resp, nil
+		},
+		ResponseParser: func(_ context.Context, resp *http.Response) (*Response, error) {
+			resp.Body.Close()
+			return &Response{Data: map[string]interface{}{"status": resp.StatusCode}, IsComplete: true}, nil
+		},
+	}
+	p := NewBackendFactory(ef, opts)(&config.Backend{})
+	u, _ := url.Parse("http://example.com")
+	resp, err := p(context.Background(), &Request{Method: "GET", URL: u})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !handled || resp.Data["status"] != http.StatusTeapot {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestNewBackendFactory_statusError(t *testing.T) {
+	ef := func(_ *config.Backend) (client.HTTPRequestExecutor, error) {
+		return func(_ context.Context, _ *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(bytes.NewBufferString("{}"))}, nil
+		}, nil
+	}
+	p := NewBackendFactory(ef, BackendOptions{})(&config.Backend{Decoder: encoding.JSONDecoder})
+	u, _ := url.Parse("http://example.com")
+	_, err := p(context.Background(), &Request{Method: "GET", URL: u})
+	if !errors.Is(err, client.ErrInvalidStatusCode) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if e, ok := client.AsBackendError(err); !ok || e.Status != http.StatusInternalServerError || e.URL != "http://example.com" {
+		t.Errorf("unexpected backend error %+v", e)
+	}
+}
+
+func TestNewBackendFactory_executorError(t *testing.T) {
+	expected := errors.New("invalid transport config")
+	ef := func(_ *config.Backend) (client.HTTPRequestExecutor, error) { return nil, expected }
+	p := NewBackendFactory(ef, BackendOptions{})(&config.Backend{})
+	u, _ := url.Parse("http://example.com")
+	if _, err := p(context.Background(), &Request{Method: "GET", URL: u}); !errors.Is(err, expected) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestChainBackendFactory(t *testing.T) {
+	var order []string
+	mw := func(name string) BackendFactoryMiddleware {
+		return func(bf BackendFactory) BackendFactory {
+			return func(remote *config.Backend) Proxy {
+				next := bf(remote)
+				return func(ctx context.Context, r *Request) (*Response, error) {
+					order = append(order, name)
+					return next(ctx, r)
+				}
+			}
+		}
+	}
+	bf := func(_ *config.Backend) Proxy {
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			order = append(order, "backend")
+			return &Response{}, nil
+		}
+	}
+	logged := WithBackendMiddleware(logging.NoOp, func(_ logging.Logger, _ *config.Backend) Middleware {
+		return func(next ...Proxy) Proxy {
+			return func(ctx context.Context, r *Request) (*Response, error) {
+				order = append(order, "middleware")
+				return next[0](ctx, r)
+			}
+		}
+	})
+
+	p := ChainBackendFactory(bf, mw("first"), logged, mw("last"))(&config.Backend{})
+	if _, err := p(context.Background(), &Request{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 4 || order[0] != "first" || order[1] != "middleware" || order[2] != "last" || order[3] != "backend" {
+		t.Errorf("unexpected order %v", order)
+	}
+}
+
+func TestWithExecutorMiddleware(t *testing.T) {
+	var header string
+	ef := func(_ *config.Backend) (client.HTTPRequestExecutor, error) {
+		return func(_ context.Context, req *http.Request) (*http.Response, error) {
+			header = req.Header.Get("X-Backend")
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString("{}"))}, nil
+		}, nil
+	}
+	ef = WithExecutorMiddleware(ef, func(remote *config.Backend, next client.HTTPRequestExecutor) client.HTTPRequestExecutor {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Backend", remote.URLPattern)
+			return next(ctx, req)
+		}
+	})
+	p := NewBackendFactory(ef, BackendOptions{})(&config.Backend{URLPattern: "/users", Decoder: encoding.JSONDecoder})
+	u, _ := url.Parse("http://example.com")
+	if _, err := p(context.Background(), &Request{Method: "GET", URL: u}); err != nil {
+		t.Fatal(err)
+	}
+	if header != "/users" {
+		t.Errorf("unexpected header %s", header)
+	}
+}
@@ -4,12 +4,14 @@ package proxy
 
 import (
 	"context"
+	"fmt"
 	"net/url"
 	"strings"
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/logging"
 	"github.com/luraproject/lura/v2/sd"
+	"github.com/luraproject/lura/v2/transport/http/client"
 )
 
 // NewLoadBalancedMiddleware creates proxy middleware adding the most perfomant balancer
@@ -84,6 +86,40 @@ func NewRandomLoadBalancedMiddlewareWithSubscriberAndLogger(l logging.Logger, su
 	return newLoadBalancedMiddleware(l, sd.NewRandomLB(subscriber))
 }
 
+// NewBackendLoadBalancedMiddleware creates proxy middleware adding the balancer selected by the
+// backend over the received subscriber. The backends select a balancer registered in the sd
+// balancer register by its name:
+//
+//	"github.com/devopsfaith/krakend/proxy": {
+//		"balancer": "round-robin"
+//	}
+//
+// The backends not selecting a balancer, or selecting an unknown one, get the most performant one
+func NewBackendLoadBalancedMiddleware(l logging.Logger, remote *config.Backend, subscriber sd.Subscriber) Middleware {
+	name, ok := getBalancerName(remote)
+	if !ok {
+		return NewLoadBalancedMiddlewareWithSubscriberAndLogger(l, subscriber)
+	}
+	bf, ok := sd.GetBalancerRegister().Get(name)
+	if !ok {
+		l.Error(fmt.Sprintf("[BACKEND: %s][Balancer] Unknown balancer %s", remote.URLPattern, name))
+		return NewLoadBalancedMiddlewareWithSubscriberAndLogger(l, subscriber)
+	}
+	l.Debug(fmt.Sprintf("[BACKEND: %s][Balancer] Using the %s balancer", remote.URLPattern, name))
+	return newLoadBalancedMiddleware(l, bf(subscriber))
+}
+
+const balancerKey = "balancer"
+
+func getBalancerName(remote *config.Backend) (string, bool) {
+	e, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	name, ok := e[balancerKey].(string)
+	return name, ok && name != ""
+}
+
 func newLoadBalancedMiddleware(l logging.Logger, lb sd.Balancer) Middleware {
 	return func(next ...Proxy) Proxy {
 		if len(next) > 1 {
@@ -95,6 +131,11 @@ func newLoadBalancedMiddleware(l logging.Logger, lb sd.Balancer) Middleware {
 			if err != nil {
 				return nil, err
 			}
+			if client.IsUnixSocketHost(host) {
+				var socket string
+				socket, host = client.UnixSocketHost(host)
+				ctx = client.WithUnixSocket(ctx, socket)
+			}
 			r := request.Clone()
 
 			var b strings.Builder
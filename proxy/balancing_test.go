@@ -11,7 +11,9 @@ import (
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
 	"github.com/luraproject/lura/v2/sd/dnssrv"
+	"github.com/luraproject/lura/v2/transport/http/client"
 )
 
 func TestNewLoadBalancedMiddleware_ok(t *testing.T) {
@@ -30,6 +32,25 @@ func TestNewLoadBalancedMiddleware_ok(t *testing.T) {
 	}
 }
 
+func TestNewLoadBalancedMiddleware_unixSocket(t *testing.T) {
+	lb := newLoadBalancedMiddleware(logging.NoOp, dummyBalancer("unix:///var/run/service.sock"))
+	assertion := func(ctx context.Context, request *Request) (*Response, error) {
+		socket, ok := client.UnixSocketFromContext(ctx)
+		if !ok || socket != "/var/run/service.sock" {
+			t.Errorf("unexpected socket: %s", socket)
+		}
+		if request.URL.Scheme != "http" || request.URL.Path != "/tupu" {
+			t.Errorf("unexpected url: %s", request.URL.String())
+		}
+		return nil, nil
+	}
+	if _, err := lb(assertion)(context.Background(), &Request{
+		Path: "/tupu",
+	}); err != nil {
+		t.Errorf("The middleware propagated an unexpected error: %s\n", err.Error())
+	}
+}
+
 func TestNewLoadBalancedMiddleware_explosiveBalancer(t *testing.T) {
 	expected := errors.New("supu")
 	lb := newLoadBalancedMiddleware(logging.NoOp, explosiveBalancer{expected})
@@ -38,6 +59,34 @@ func TestNewLoadBalancedMiddleware_explosiveBalancer(t *testing.T) {
 	}
 }
 
+func TestNewBackendLoadBalancedMiddleware(t *testing.T) {
+	sd.GetBalancerRegister().Register("constant", func(_ sd.Subscriber) sd.Balancer {
+		return dummyBalancer("http://constant")
+	})
+	subscriber := sd.FixedSubscriber{"http://supu"}
+
+	for name, want := range map[string]string{
+		"constant":        "http://constant/tupu",
+		sd.BalancerRandom: "http://supu/tupu",
+		"unknown":         "http://supu/tupu",
+		"":                "http://supu/tupu",
+	} {
+		backend := &config.Backend{ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{"balancer": name},
+		}}
+		lb := NewBackendLoadBalancedMiddleware(logging.NoOp, backend, subscriber)
+		assertion := func(_ context.Context, request *Request) (*Response, error) {
+			if request.URL.String() != want {
+				t.Errorf("%s: unexpected url %s", name, request.URL)
+			}
+			return nil, nil
+		}
+		if _, err := lb(assertion)(context.Background(), &Request{Path: "/tupu"}); err != nil {
+			t.Errorf("%s: %s", name, err.Error())
+		}
+	}
+}
+
 func TestNewRoundRobinLoadBalancedMiddleware(t *testing.T) {
 	testLoadBalancedMw(t, NewRoundRobinLoadBalancedMiddleware(&config.Backend{
 		Host: []string{"http://127.0.0.1:8080"},
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const maxBufferedBodySizeKey = "max_buffered_body_size"
+
+// ErrBodyTooLarge is the error returned when a body exceeds the max size of the buffered bodies
+var ErrBodyTooLarge = errors.New("body too large to be buffered")
+
+var maxBufferedBodySize int64
+
+// SetMaxBufferedBodySize sets the max size, in bytes, of the bodies buffered by CloneRequest and
+// BufferBody. Zero or a negative value removes the limit
+func SetMaxBufferedBodySize(size int64) {
+	if size < 0 {
+		size = 0
+	}
+	atomic.StoreInt64(&maxBufferedBodySize, size)
+}
+
+// MaxBufferedBodySize returns the max size, in bytes, of the buffered bodies, or zero if there is
+// no limit
+func MaxBufferedBodySize() int64 {
+	return atomic.LoadInt64(&maxBufferedBodySize)
+}
+
+// RegisterBodyBuffer sets the max size of the buffered bodies declared at the service level:
+//
+//	"extra_config": {
+//		"github.com/devopsfaith/krakend/proxy": {
+//			"max_buffered_body_size": 10485760
+//		}
+//	}
+//
+// It returns false if the service does not declare it. The limit is shared by all the routers of
+// the process, so it is the one of the last registered config
+func RegisterBodyBuffer(cfg config.ServiceConfig) (bool, error) {
+	e, _ := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	v, ok := e[maxBufferedBodySizeKey]
+	if !ok {
+		// drop the limit of a previous config
+		SetMaxBufferedBodySize(0)
+		return false, nil
+	}
+	size, ok := v.(float64)
+	if !ok || size < 0 || size != float64(int64(size)) {
+		return true, fmt.Errorf("invalid %s: %v", maxBufferedBodySizeKey, v)
+	}
+	SetMaxBufferedBodySize(int64(size))
+	return true, nil
+}
+
+// RewindableBody is a body buffered in memory, so it can be read by several middlewares and
+// backends. Every RewindableBody has its own read offset, and its clones share the buffer but not
+// the offset, so they can be consumed in parallel. Closing it is a no-op
+type RewindableBody struct {
+	data []byte
+	r    *bytes.Reader
+}
+
+// NewRewindableBody returns a RewindableBody with the received data. The data must not be modified
+// after the call
+func NewRewindableBody(data []byte) *RewindableBody {
+	return &RewindableBody{data: data, r: bytes.NewReader(data)}
+}
+
+// Read implements the io.Reader interface
+func (b *RewindableBody) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+// WriteTo implements the io.WriterTo interface
+func (b *RewindableBody) WriteTo(w io.Writer) (int64, error) {
+	return b.r.WriteTo(w)
+}
+
+// Close implements the io.Closer interface. The body can be read again after rewinding it
+func (b *RewindableBody) Close() error {
+	return nil
+}
+
+// Rewind moves the read offset back to the start of the body
+func (b *RewindableBody) Rewind() {
+	b.r.Reset(b.data)
+}
+
+// Bytes returns the whole body, regardless of the read offset. The returned slice is shared with
+// the clones of the body, so it must not be modified
+func (b *RewindableBody) Bytes() []byte {
+	return b.data
+}
+
+// Len returns the size of the whole body
+func (b *RewindableBody) Len() int {
+	return len(b.data)
+}
+
+// Clone returns a new RewindableBody sharing the buffer, with its read offset at the start of the
+// body
+func (b *RewindableBody) Clone() *RewindableBody {
+	return NewRewindableBody(b.data)
+}
+
+// BufferBody replaces the body of the request with a RewindableBody, reading and closing the
+// original one, and returns it. The bodies already buffered are returned as they are. If the body
+// exceeds the max size of the buffered bodies, it returns ErrBodyTooLarge and the request keeps a
+// body streaming the whole content, so it can still be consumed once
+func (r *Request) BufferBody() (*RewindableBody, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	if b, ok := r.Body.(*RewindableBody); ok {
+		return b, nil
+	}
+
+	max := MaxBufferedBodySize()
+	src := io.Reader(r.Body)
+	if max > 0 {
+		src = io.LimitReader(r.Body, max+1)
+	}
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(src); err != nil {
+		r.Body.Close()
+		r.Body = NewRewindableBody(buf.Bytes())
+		return nil, err
+	}
+	if max > 0 && int64(buf.Len()) > max {
+		r.Body = &partialBody{Reader: io.MultiReader(buf, r.Body), Closer: r.Body}
+		return nil, ErrBodyTooLarge
+	}
+	r.Body.Close()
+
+	b := NewRewindableBody(buf.Bytes())
+	r.Body = b
+	return b, nil
+}
+
+// partialBody streams the bytes already read from a body followed by the rest of it
+type partialBody struct {
+	io.Reader
+	io.Closer
+}
+
+// failedBody is the body of the clones of a request whose body could not be buffered
+type failedBody struct {
+	err error
+}
+
+func (f failedBody) Read(_ []byte) (int, error) { return 0, f.err }
+func (failedBody) Close() error                 { return nil }
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestRewindableBody(t *testing.T) {
+	b := NewRewindableBody([]byte("supu tupu"))
+	clone := b.Clone()
+
+	first, _ := io.ReadAll(b)
+	if string(first) != "supu tupu" {
+		t.Errorf("unexpected body: %s", first)
+	}
+	if rest, _ := io.ReadAll(b); len(rest) != 0 {
+		t.Errorf("the body was not consumed: %s", rest)
+	}
+	b.Close()
+	b.Rewind()
+	if again, _ := io.ReadAll(b); string(again) != "supu tupu" {
+		t.Errorf("unexpected body after rewinding it: %s", again)
+	}
+	if c, _ := io.ReadAll(clone); string(c) != "supu tupu" {
+		t.Errorf("the clone shares the read offset: %s", c)
+	}
+	if b.Len() != 9 || string(b.Bytes()) != "supu tupu" {
+		t.Errorf("unexpected content: %d %s", b.Len(), b.Bytes())
+	}
+}
+
+func TestCloneRequest_rewindableBody(t *testing.T) {
+	r := &Request{Body: io.NopCloser(strings.NewReader("supu"))}
+	clones := []*Request{CloneRequest(r), CloneRequest(r), CloneRequest(r)}
+	for i, c := range clones {
+		if b, _ := io.ReadAll(c.Body); string(b) != "supu" {
+			t.Errorf("#%d: unexpected body: %s", i, b)
+		}
+	}
+	if _, ok := r.Body.(*RewindableBody); !ok {
+		t.Errorf("the body was not buffered: %T", r.Body)
+	}
+	if b, _ := io.ReadAll(r.Body); string(b) != "supu" {
+		t.Errorf("unexpected body: %s", b)
+	}
+	if b, _ := io.ReadAll(CloneRequest(r).Body); string(b) != "supu" {
+		t.Errorf("the clones of a consumed body should read it from the start: %s", b)
+	}
+}
+
+func TestCloneRequest_bodyTooLarge(t *testing.T) {
+	SetMaxBufferedBodySize(4)
+	defer SetMaxBufferedBodySize(0)
+
+	r := &Request{Body: io.NopCloser(strings.NewReader("supu tupu"))}
+	clone := CloneRequest(r)
+	if _, err := io.ReadAll(clone.Body); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if b, _ := io.ReadAll(r.Body); string(b) != "supu tupu" {
+		t.Errorf("the original body was lost: %s", b)
+	}
+
+	r = &Request{Body: io.NopCloser(strings.NewReader("supu"))}
+	if b, _ := io.ReadAll(CloneRequest(r).Body); string(b) != "supu" {
+		t.Errorf("unexpected body: %s", b)
+	}
+}
+
+func TestRegisterBodyBuffer(t *testing.T) {
+	defer SetMaxBufferedBodySize(0)
+
+	if ok, err := RegisterBodyBuffer(config.ServiceConfig{}); ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{maxBufferedBodySizeKey: 1024.0}}}
+	if ok, err := RegisterBodyBuffer(cfg); !ok || err != nil || MaxBufferedBodySize() != 1024 {
+		t.Errorf("unexpected result: %v %v %d", ok, err, MaxBufferedBodySize())
+	}
+	cfg.ExtraConfig[Namespace] = map[string]interface{}{maxBufferedBodySizeKey: "big"}
+	if ok, err := RegisterBodyBuffer(cfg); !ok || err == nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+}
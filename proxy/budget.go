@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/luraproject/lura/v2/budget"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/metaheaders"
+)
+
+// NewBudgetMiddleware creates proxy middleware charging the cost declared in the extra config of
+// the endpoint to the budget of the tenant of every request, rejecting the requests exceeding it
+func NewBudgetMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	logPrefix := "[ENDPOINT: " + endpointConfig.Endpoint + "][Budget]"
+	cost, ok, err := budget.CostGetter(endpointConfig.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return emptyMiddlewareFallback(logger)
+	}
+	b, ok := budget.GetGlobal()
+	if !ok {
+		logger.Error(logPrefix, budget.ErrNoBudget.Error())
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(logPrefix, fmt.Sprintf("Charging %d per request", cost))
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewBudgetMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			tenant := b.Tenant(ctx, r.Headers)
+			left, err := b.SpendContext(ctx, tenant, cost)
+			if info, ok := metaheaders.FromContext(ctx); ok {
+				info.SetRemaining(left)
+			}
+			if err != nil {
+				logger.Debug(logPrefix, err.Error())
+				return nil, err
+			}
+			return next[0](ctx, r)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/luraproject/lura/v2/budget"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewBudgetMiddleware(t *testing.T) {
+	b, err := budget.New(budget.Config{Budget: 10, TenantHeader: "X-Tenant"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	budget.SetGlobal(b)
+	defer budget.SetGlobal(nil)
+
+	cfg := &config.EndpointConfig{
+		Endpoint:    "/heavy",
+		ExtraConfig: config.ExtraConfig{budget.Namespace: map[string]interface{}{"cost": 4}},
+	}
+	p := NewBudgetMiddleware(logging.NoOp, cfg)(func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{IsComplete: true}, nil
+	})
+
+	acme := &Request{Headers: map[string][]string{"X-Tenant": {"acme"}}}
+	for i := 0; i < 2; i++ {
+		if _, err := p(context.Background(), acme); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+	}
+	var exhausted budget.ExhaustedError
+	if _, err := p(context.Background(), acme); !errors.As(err, &exhausted) || exhausted.Tenant != "acme" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := p(context.Background(), &Request{}); err != nil {
+		t.Errorf("the budget of the other tenants was consumed: %v", err)
+	}
+}
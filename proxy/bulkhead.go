@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/luraproject/lura/v2/bulkhead"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+// NewBackendBulkheadMiddleware creates proxy middleware bounding the requests in flight to the
+// backend, as declared in its extra config. The requests exceeding the bulkhead fail with the
+// bulkhead.ErrFull error, answered with a 503 Service Unavailable
+func NewBackendBulkheadMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Bulkhead]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	cfg, ok, err := bulkhead.ConfigGetter(remote.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	var b *bulkhead.Bulkhead
+	if err == nil {
+		b, err = bulkhead.New(cfg)
+	}
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(logPrefix, fmt.Sprintf("Max concurrent: %d, max queue: %d", cfg.MaxConcurrent, cfg.MaxQueue))
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: NewBackendBulkheadMiddleware only accepts 1 proxy, got %d",
+				remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			release, err := b.Acquire(ctx)
+			if err != nil {
+				logger.Debug(logPrefix, err.Error())
+				return nil, err
+			}
+			defer release()
+			return next[0](ctx, r)
+		}
+	}
+}
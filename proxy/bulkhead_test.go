@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luraproject/lura/v2/bulkhead"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewBackendBulkheadMiddleware(t *testing.T) {
+	backend := &config.Backend{
+		URLPattern: "/slow",
+		ExtraConfig: config.ExtraConfig{
+			bulkhead.Namespace: map[string]interface{}{"max_concurrent": 1},
+		},
+	}
+	inside := make(chan struct{})
+	unblock := make(chan struct{})
+	p := NewBackendBulkheadMiddleware(logging.NoOp, backend)(func(_ context.Context, _ *Request) (*Response, error) {
+		inside <- struct{}{}
+		<-unblock
+		return &Response{IsComplete: true}, nil
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p(context.Background(), &Request{})
+		done <- err
+	}()
+	<-inside
+
+	if _, err := p(context.Background(), &Request{}); err != bulkhead.ErrFull {
+		t.Errorf("unexpected error: %v", err)
+	}
+	close(unblock)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	go func() { <-inside }()
+	if _, err := p(context.Background(), &Request{}); err != nil {
+		t.Errorf("the released slot should be available: %v", err)
+	}
+}
+
+func TestNewBackendBulkheadMiddleware_misconfigured(t *testing.T) {
+	backend := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			bulkhead.Namespace: map[string]interface{}{"max_concurrent": 0},
+		},
+	}
+	expected := &Response{}
+	p := NewBackendBulkheadMiddleware(logging.NoOp, backend)(func(_ context.Context, _ *Request) (*Response, error) {
+		return expected, nil
+	})
+	if resp, err := p(context.Background(), &Request{}); err != nil || resp != expected {
+		t.Errorf("unexpected result: %v, %v", resp, err)
+	}
+}
@@ -0,0 +1,323 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/cache"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/debugtoken"
+	"github.com/luraproject/lura/v2/encryption"
+	"github.com/luraproject/lura/v2/etag"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/metaheaders"
+)
+
+const cacheKey = "cache"
+
+// NewCacheMiddleware creates proxy middleware storing the complete responses of the safe
+// requests in a memory-bounded cache for the duration of the endpoint cache TTL.
+//
+// The endpoints declaring a stale_while_revalidate window keep the expired responses for that
+// long: they are served immediately while a single background request refreshes them, so the
+// clients do not wait for the slow backends. The refreshes are bounded by the endpoint timeout.
+//
+// The responses are cached per forwarded header, so the endpoints forwarding all of them with
+// the wildcard cache a response per combination. The Authorization and Cookie headers are always
+// part of the keys when they are forwarded, so a client never gets the response of another one.
+//
+// The endpoints tagging their responses with ETags keep the digest of the cached responses, so
+// the conditional requests are revalidated without encoding them again
+func NewCacheMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	cfg, ok := getCacheMiddlewareCfg(endpointConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	name := endpointConfig.Method + " " + endpointConfig.Endpoint
+	logPrefix := "[ENDPOINT: " + endpointConfig.Endpoint + "][Cache]"
+
+	var store cache.Store = cache.NewMemoryStore(cfg.Memory)
+	if cfg.Disk != nil {
+		sum := sha256.Sum256([]byte(name))
+		cfg.Disk.Path = filepath.Join(cfg.Disk.Path, hex.EncodeToString(sum[:8]))
+		var err error
+		if cfg.Encryption != nil {
+			cfg.Disk.Cipher, err = encryption.New(*cfg.Encryption)
+		}
+		var disk cache.DiskTier
+		if err == nil {
+			disk, err = cache.NewDiskTier(*cfg.Disk)
+		}
+		if err != nil {
+			logger.Error(logPrefix, "Unable to init the disk tier:", err.Error())
+		} else {
+			store = cache.NewTieredStore(cfg.Memory, disk, cfg.MaxMemoryEntry)
+		}
+	}
+	cache.GetRegister().Register(name, store)
+
+	logger.Debug(
+		fmt.Sprintf(
+			"%s Caching responses for %s (stale for %s). Budget: %d bytes, compression: %t, disk tier: %t, encrypted: %t",
+			logPrefix,
+			endpointConfig.CacheTTL.String(),
+			cfg.StaleWhileRevalidate.String(),
+			cfg.Memory.MaxBytes,
+			cfg.Memory.Compress,
+			cfg.Disk != nil,
+			cfg.Disk != nil && cfg.Disk.Cipher != nil,
+		),
+	)
+
+	classify := NewRequestClassifier(endpointConfig.ExtraConfig)
+	_, tagged, err := etag.ConfigGetter(endpointConfig.ExtraConfig)
+	tagged = tagged && err == nil
+	return newCacheMiddleware(logger, store, cacheTTL{
+		fresh:   endpointConfig.CacheTTL,
+		stale:   cfg.StaleWhileRevalidate,
+		refresh: endpointConfig.Timeout,
+	}, config.NewHeadersFilter(endpointConfig.HeadersToPass), classify, tagged)
+}
+
+// cacheTTL holds the durations of the cached responses
+type cacheTTL struct {
+	// fresh is the time the responses are served without contacting the backends
+	fresh time.Duration
+	// stale is the time the expired responses are served while they are refreshed
+	stale time.Duration
+	// refresh is the timeout of the background refreshes
+	refresh time.Duration
+}
+
+func newCacheMiddleware(logger logging.Logger, store cache.Store, ttl cacheTTL, headers config.ParamsFilter, classify RequestClassifier, tagged bool) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewCacheMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		save := func(key string, resp *Response) string {
+			var digest string
+			if tagged {
+				digest, _ = etag.Digest(resp.Data)
+			}
+			b, err := json.Marshal(cachedResponse{
+				Data:     resp.Data,
+				Metadata: resp.Metadata,
+				Expires:  time.Now().Add(ttl.fresh),
+				Digest:   digest,
+			})
+			if err == nil {
+				store.Set(key, b, ttl.fresh+ttl.stale)
+			}
+			return digest
+		}
+
+		var refreshing sync.Map
+		refresh := func(key string, request *Request) {
+			if _, loaded := refreshing.LoadOrStore(key, struct{}{}); loaded {
+				return
+			}
+			r := CloneRequest(request)
+			go func() {
+				defer refreshing.Delete(key)
+				// the refreshes outlive the requests triggering them, but not the backend timeout
+				timeout := ttl.refresh
+				if timeout <= 0 {
+					timeout = config.DefaultTimeout
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+				resp, err := next[0](ctx, r)
+				if err != nil || resp == nil || !resp.IsComplete || resp.Io != nil {
+					return
+				}
+				save(key, resp)
+			}()
+		}
+
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			// the debug tokens can bypass the cache, so the response is neither read nor stored
+			if !classify(request.Method).IsSafe() || debugtoken.BypassCache(ctx) {
+				return next[0](ctx, request)
+			}
+
+			info, hasInfo := metaheaders.FromContext(ctx)
+			recorder, hasRecorder := etag.FromContext(ctx)
+			key := cacheRequestKey(request, cacheKeyHeaders(request, headers))
+			if b, ok := store.Get(key); ok {
+				var resp cachedResponse
+				if err := decodeCachedResponse(b, &resp); err == nil {
+					if !resp.Expires.IsZero() && time.Now().After(resp.Expires) {
+						refresh(key, request)
+						if hasInfo {
+							info.CacheStale()
+						}
+					} else if hasInfo {
+						info.CacheHit(true)
+					}
+					if hasRecorder && resp.Digest != "" {
+						recorder.SetDigest(resp.Digest)
+					}
+					return &Response{
+						Data:       resp.Data,
+						IsComplete: true,
+						Metadata:   resp.Metadata,
+					}, nil
+				}
+			}
+
+			if hasInfo {
+				info.CacheHit(false)
+			}
+			resp, err := next[0](ctx, request)
+			if err != nil || resp == nil || !resp.IsComplete || resp.Io != nil {
+				return resp, err
+			}
+
+			if digest := save(key, resp); hasRecorder && digest != "" {
+				recorder.SetDigest(digest)
+			}
+			return resp, nil
+		}
+	}
+}
+
+type cachedResponse struct {
+	Data     map[string]interface{} `json:"data"`
+	Metadata Metadata               `json:"metadata"`
+	Expires  time.Time              `json:"expires"`
+	Digest   string                 `json:"digest,omitempty"`
+}
+
+// decodeCachedResponse keeps the numbers as json.Number, like the backend decoders, so the big
+// integers are not rounded
+func decodeCachedResponse(b []byte, resp *cachedResponse) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	return d.Decode(resp)
+}
+
+// credentialHeaders are always part of the cache keys when they reach the backends, so the
+// responses of a client are never served to another one
+var credentialHeaders = map[string]struct{}{"Authorization": {}, "Cookie": {}}
+
+// cacheKeyHeaders returns the sorted names of the headers of the request forwarded to the
+// backends, so the responses varying by them are cached apart
+func cacheKeyHeaders(r *Request, filter config.ParamsFilter) []string {
+	names := make([]string, 0, len(r.Headers))
+	for h := range r.Headers {
+		if _, ok := credentialHeaders[h]; ok || filter.Allows(h) {
+			names = append(names, h)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func cacheRequestKey(r *Request, headers []string) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteString(" ")
+	b.WriteString(r.Path)
+	if len(r.Query) > 0 {
+		b.WriteString("?")
+		b.WriteString(r.Query.Encode())
+	}
+
+	keys := make([]string, 0, len(r.Params))
+	for k := range r.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString("|")
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(r.Params[k])
+	}
+
+	for _, h := range headers {
+		if vs, ok := r.Headers[h]; ok {
+			b.WriteString("|")
+			b.WriteString(h)
+			b.WriteString(":")
+			b.WriteString(strings.Join(vs, ","))
+		}
+	}
+	return b.String()
+}
+
+type cacheConfig struct {
+	Memory         cache.Options
+	Disk           *cache.DiskOptions
+	MaxMemoryEntry int
+	Encryption     *encryption.Config
+	// StaleWhileRevalidate is the time the expired responses are served while they are refreshed
+	StaleWhileRevalidate time.Duration
+}
+
+func getCacheMiddlewareCfg(cfg *config.EndpointConfig) (cacheConfig, bool) {
+	res := cacheConfig{}
+	if cfg.CacheTTL <= 0 {
+		return res, false
+	}
+	v, ok := cfg.ExtraConfig[Namespace]
+	if !ok {
+		return res, false
+	}
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return res, false
+	}
+	tmp, ok := e[cacheKey].(map[string]interface{})
+	if !ok {
+		return res, false
+	}
+
+	res.Memory.MaxBytes = int64(getNumber(tmp["max_size"]))
+	res.Memory.Compress, _ = tmp["compress"].(bool)
+	res.Memory.MinCompressSize = int(getNumber(tmp["min_compress_size"]))
+	if v, ok := tmp["stale_while_revalidate"].(string); ok {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			res.StaleWhileRevalidate = d
+		}
+	}
+
+	if d, ok := tmp["disk"].(map[string]interface{}); ok {
+		if path, ok := d["path"].(string); ok && path != "" {
+			res.Disk = &cache.DiskOptions{
+				Path:     path,
+				MaxBytes: int64(getNumber(d["max_size"])),
+			}
+			res.Disk.Engine, _ = d["engine"].(string)
+			res.MaxMemoryEntry = int(getNumber(d["max_memory_entry_size"]))
+			if enc, ok := encryption.ConfigGetter(d); ok {
+				res.Encryption = &enc
+			}
+		}
+	}
+	return res, true
+}
+
+func getNumber(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return 0
+}
@@ -0,0 +1,299 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/cache"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/etag"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewCacheMiddleware(t *testing.T) {
+	endpoint := config.EndpointConfig{
+		Endpoint: "/cached",
+		Method:   "GET",
+		CacheTTL: time.Minute,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				cacheKey: map[string]interface{}{
+					"max_size": 1024.0,
+					"compress": true,
+				},
+			},
+		},
+	}
+
+	calls := 0
+	p := NewCacheMiddleware(logging.NoOp, &endpoint)(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		return &Response{Data: map[string]interface{}{"supu": json.Number("42")}, IsComplete: true}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		resp, err := p(context.Background(), &Request{Method: "GET", Path: "/cached", Query: url.Values{"a": {"1"}}})
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+		if resp == nil || !resp.IsComplete || resp.Data["supu"] != json.Number("42") {
+			t.Errorf("unexpected response: %v", resp)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+
+	if _, err := p(context.Background(), &Request{Method: "GET", Path: "/cached", Query: url.Values{"a": {"2"}}}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if calls != 2 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+
+	if _, err := p(context.Background(), &Request{Method: "POST", Path: "/cached"}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if _, err := p(context.Background(), &Request{Method: "POST", Path: "/cached"}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if calls != 4 {
+		t.Errorf("unsafe requests should not be cached. calls: %d", calls)
+	}
+
+	store, ok := cache.GetRegister().Get("GET /cached")
+	if !ok {
+		t.Error("the store has not been registered")
+		return
+	}
+	if stats := store.Stats(); stats.Hits != 2 || stats.Entries != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestNewCacheMiddleware_etag(t *testing.T) {
+	endpoint := config.EndpointConfig{
+		Endpoint: "/cached/etag",
+		Method:   "GET",
+		CacheTTL: time.Minute,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				cacheKey: map[string]interface{}{},
+			},
+			etag.Namespace: map[string]interface{}{},
+		},
+	}
+	calls := 0
+	p := NewCacheMiddleware(logging.NoOp, &endpoint)(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		return &Response{Data: map[string]interface{}{"supu": 42.0}, IsComplete: true}, nil
+	})
+
+	expected, _ := etag.Digest(map[string]interface{}{"supu": 42.0})
+	for i := 0; i < 2; i++ {
+		ctx, recorder := etag.NewContext(context.Background())
+		if _, err := p(ctx, &Request{Method: "GET", Path: "/cached/etag"}); err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+		if d := recorder.Digest(); d != expected {
+			t.Errorf("#%d: unexpected digest: %s", i, d)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+}
+
+func TestNewCacheMiddleware_noTTL(t *testing.T) {
+	endpoint := config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				cacheKey: map[string]interface{}{},
+			},
+		},
+	}
+	calls := 0
+	p := NewCacheMiddleware(logging.NoOp, &endpoint)(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		return &Response{Data: map[string]interface{}{}, IsComplete: true}, nil
+	})
+	p(context.Background(), &Request{Method: "GET"})
+	p(context.Background(), &Request{Method: "GET"})
+	if calls != 2 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+}
+
+func TestNewCacheMiddleware_diskTier(t *testing.T) {
+	endpoint := config.EndpointConfig{
+		Endpoint: "/cached/disk",
+		Method:   "GET",
+		CacheTTL: time.Minute,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				cacheKey: map[string]interface{}{
+					"max_size": 1024.0,
+					"disk": map[string]interface{}{
+						"path":                  t.TempDir(),
+						"max_size":              4096.0,
+						"max_memory_entry_size": 10.0,
+					},
+				},
+			},
+		},
+	}
+
+	calls := 0
+	p := NewCacheMiddleware(logging.NoOp, &endpoint)(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		return &Response{Data: map[string]interface{}{"supu": "a long enough value"}, IsComplete: true}, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := p(context.Background(), &Request{Method: "GET", Path: "/cached/disk"}); err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+		}
+	}
+	if calls != 1 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+
+	store, ok := cache.GetRegister().Get("GET /cached/disk")
+	if !ok {
+		t.Error("the store has not been registered")
+		return
+	}
+	tiered, ok := store.(*cache.TieredStore)
+	if !ok {
+		t.Errorf("unexpected store type: %T", store)
+		return
+	}
+	if e := tiered.DiskStats().Entries; e != 1 {
+		t.Errorf("the response should be stored on disk. entries: %d", e)
+	}
+}
+
+func TestNewCacheMiddleware_staleWhileRevalidate(t *testing.T) {
+	endpoint := config.EndpointConfig{
+		Endpoint: "/cached/stale",
+		Method:   "GET",
+		CacheTTL: 50 * time.Millisecond,
+		Timeout:  time.Second,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				cacheKey: map[string]interface{}{
+					"max_size":               1024.0,
+					"stale_while_revalidate": "200ms",
+				},
+			},
+		},
+	}
+
+	var calls int32
+	refreshed := make(chan struct{}, 10)
+	p := NewCacheMiddleware(logging.NoOp, &endpoint)(func(ctx context.Context, _ *Request) (*Response, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 2 {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("the refresh has no deadline")
+			}
+			defer func() { refreshed <- struct{}{} }()
+		}
+		return &Response{Data: map[string]interface{}{"version": json.Number(strconv.Itoa(int(n)))}, IsComplete: true}, nil
+	})
+
+	get := func() int64 {
+		resp, err := p(context.Background(), &Request{Method: "GET", Path: "/cached/stale"})
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return 0
+		}
+		n, _ := resp.Data["version"].(json.Number)
+		v, _ := n.Int64()
+		return v
+	}
+
+	if v := get(); v != 1 {
+		t.Errorf("unexpected version: %v", v)
+	}
+	time.Sleep(80 * time.Millisecond)
+
+	// the expired response is served while it is refreshed once
+	for i := 0; i < 3; i++ {
+		if v := get(); v != 1 {
+			t.Errorf("unexpected version: %v", v)
+		}
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Error("the response has not been refreshed")
+		return
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("unexpected number of calls to the backend: %d", n)
+	}
+	if v := get(); v != 2 {
+		t.Errorf("unexpected version: %v", v)
+	}
+
+	// the responses older than the stale window are not served
+	time.Sleep(300 * time.Millisecond)
+	if v := get(); v != 3 {
+		t.Errorf("unexpected version: %v", v)
+	}
+}
+
+func TestNewCacheMiddleware_bigNumbers(t *testing.T) {
+	endpoint := config.EndpointConfig{
+		Endpoint: "/cached/numbers",
+		Method:   "GET",
+		CacheTTL: time.Minute,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{cacheKey: map[string]interface{}{}},
+		},
+	}
+	p := NewCacheMiddleware(logging.NoOp, &endpoint)(func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{Data: map[string]interface{}{"id": json.Number("9007199254740993")}, IsComplete: true}, nil
+	})
+	for i := 0; i < 2; i++ {
+		resp, err := p(context.Background(), &Request{Method: "GET", Path: "/cached/numbers"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := resp.Data["id"]; v != json.Number("9007199254740993") {
+			t.Errorf("#%d: unexpected value: %v (%T)", i, v, v)
+		}
+	}
+}
+
+func TestCacheKeyHeaders(t *testing.T) {
+	alice := &Request{Method: "GET", Path: "/", Headers: map[string][]string{"X-User": {"alice"}, "Accept": {"*/*"}}}
+	bob := &Request{Method: "GET", Path: "/", Headers: map[string][]string{"X-User": {"bob"}, "Accept": {"*/*"}}}
+
+	wildcard := config.NewHeadersFilter([]string{"*"})
+	if cacheRequestKey(alice, cacheKeyHeaders(alice, wildcard)) == cacheRequestKey(bob, cacheKeyHeaders(bob, wildcard)) {
+		t.Error("the wildcard must add the forwarded headers to the key")
+	}
+	denied := config.NewHeadersFilter([]string{"*", "!X-User"})
+	if cacheRequestKey(alice, cacheKeyHeaders(alice, denied)) != cacheRequestKey(bob, cacheKeyHeaders(bob, denied)) {
+		t.Error("the denied headers must not be part of the key")
+	}
+
+	alice.Headers["Authorization"] = []string{"Bearer alice"}
+	bob.Headers["Authorization"] = []string{"Bearer bob"}
+	accept := config.NewHeadersFilter([]string{"Accept"})
+	if cacheRequestKey(alice, cacheKeyHeaders(alice, accept)) == cacheRequestKey(bob, cacheKeyHeaders(bob, accept)) {
+		t.Error("the credentials must always be part of the key")
+	}
+}
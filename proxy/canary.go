@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/textproto"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+const canaryKey = "canary"
+
+// canaryGroup is a set of hosts receiving a share of the traffic of a backend
+type canaryGroup struct {
+	name   string
+	weight int
+	hosts  []string
+}
+
+type canaryConfig struct {
+	groups       []canaryGroup
+	total        int
+	stickyHeader string
+}
+
+// getCanaryCfg parses the host groups of the backend:
+//
+//	"github.com/devopsfaith/krakend/proxy": {
+//		"canary": {
+//			"sticky_header": "X-User-Id",
+//			"groups": [
+//				{"name": "stable", "weight": 95, "host": ["http://users-v1:8080"]},
+//				{"name": "canary", "weight": 5, "host": ["http://users-v2:8080"]}
+//			]
+//		}
+//	}
+func getCanaryCfg(remote *config.Backend) (canaryConfig, bool, error) {
+	cfg := canaryConfig{}
+	e, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	tmp, ok := e[canaryKey].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	cfg.stickyHeader, _ = tmp["sticky_header"].(string)
+	cfg.stickyHeader = textproto.CanonicalMIMEHeaderKey(cfg.stickyHeader)
+	groups, _ := tmp["groups"].([]interface{})
+	uri := config.NewSafeURIParser()
+	for i, g := range groups {
+		v, ok := g.(map[string]interface{})
+		if !ok {
+			return cfg, true, fmt.Errorf("canary: invalid group #%d", i)
+		}
+		group := canaryGroup{}
+		group.name, _ = v["name"].(string)
+		if group.name == "" {
+			group.name = fmt.Sprintf("group-%d", i)
+		}
+		w, ok := v["weight"].(float64)
+		if !ok || w < 0 || w != float64(int(w)) {
+			return cfg, true, fmt.Errorf("canary: invalid weight of the group %s", group.name)
+		}
+		group.weight = int(w)
+		hosts, _ := v["host"].([]interface{})
+		for _, h := range hosts {
+			s, ok := h.(string)
+			if !ok {
+				continue
+			}
+			clean, err := uri.SafeCleanHost(s)
+			if err != nil {
+				return cfg, true, fmt.Errorf("canary: invalid host of the group %s: %w", group.name, err)
+			}
+			group.hosts = append(group.hosts, clean)
+		}
+		if len(group.hosts) == 0 {
+			return cfg, true, fmt.Errorf("canary: the group %s has no hosts", group.name)
+		}
+		cfg.groups = append(cfg.groups, group)
+		cfg.total += group.weight
+	}
+	if cfg.total == 0 {
+		return cfg, true, fmt.Errorf("canary: the groups must declare a positive weight")
+	}
+	return cfg, true, nil
+}
+
+// pick returns the index of the group receiving the request with the sticky key. The requests
+// without a key are randomly assigned
+func (c canaryConfig) pick(key string) int {
+	var n int
+	if key == "" {
+		n = rand.Intn(c.total)
+	} else {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		n = int(h.Sum32() % uint32(c.total))
+	}
+	for i, g := range c.groups {
+		if n < g.weight {
+			return i
+		}
+		n -= g.weight
+	}
+	return len(c.groups) - 1
+}
+
+// NewCanaryMiddleware creates proxy middleware splitting the traffic of the backend between
+// weighted groups of hosts, so the new versions of a service can get a small share of the
+// requests. The requests carrying the sticky header are always sent to the same group, as far
+// as the weights do not change, so the clients do not bounce between versions. Remember to
+// declare the sticky header in the input_headers of the endpoint. It returns the load balancer
+// received when the backend does not declare any group
+func NewCanaryMiddleware(logger logging.Logger, remote *config.Backend, lb Middleware) Middleware {
+	cfg, ok, err := getCanaryCfg(remote)
+	if !ok {
+		return lb
+	}
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Canary]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	if err != nil {
+		logger.Error(logPrefix, "Ignoring the host groups:", err.Error())
+		return lb
+	}
+	balancers := make([]Middleware, len(cfg.groups))
+	for i, g := range cfg.groups {
+		logger.Debug(fmt.Sprintf("%s Sending %d%% of the requests to the group %s: %v", logPrefix, 100*g.weight/cfg.total, g.name, g.hosts))
+		balancers[i] = NewLoadBalancedMiddlewareWithSubscriberAndLogger(logger, sd.FixedSubscriber(g.hosts))
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewCanaryMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		groups := make([]Proxy, len(balancers))
+		for i, b := range balancers {
+			groups[i] = b(next[0])
+		}
+
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			var key string
+			if cfg.stickyHeader != "" {
+				if vs := r.Headers[cfg.stickyHeader]; len(vs) > 0 {
+					key = vs[0]
+				}
+			}
+			return groups[cfg.pick(key)](ctx, r)
+		}
+	}
+}
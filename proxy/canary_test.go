@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func canaryBackend(groups ...interface{}) *config.Backend {
+	return &config.Backend{
+		URLPattern: "/users",
+		Host:       []string{"http://regular"},
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				canaryKey: map[string]interface{}{
+					"sticky_header": "x-user-id",
+					"groups":        groups,
+				},
+			},
+		},
+	}
+}
+
+func TestGetCanaryCfg(t *testing.T) {
+	if _, ok, _ := getCanaryCfg(&config.Backend{}); ok {
+		t.Error("the config should not be found")
+	}
+	cfg, ok, err := getCanaryCfg(canaryBackend(
+		map[string]interface{}{"name": "stable", "weight": 95.0, "host": []interface{}{"stable:8080"}},
+		map[string]interface{}{"weight": 5.0, "host": []interface{}{"http://canary:8080/"}},
+	))
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if cfg.total != 100 || cfg.stickyHeader != "X-User-Id" || len(cfg.groups) != 2 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if g := cfg.groups[1]; g.name != "group-1" || g.hosts[0] != "http://canary:8080" {
+		t.Errorf("unexpected group: %+v", g)
+	}
+
+	for _, groups := range [][]interface{}{
+		{map[string]interface{}{"weight": 0.0, "host": []interface{}{"a"}}},
+		{map[string]interface{}{"weight": -1.0, "host": []interface{}{"a"}}},
+		{map[string]interface{}{"weight": 1.0}},
+		{"stable"},
+		{},
+	} {
+		if _, _, err := getCanaryCfg(canaryBackend(groups...)); err == nil {
+			t.Errorf("%v: expecting an error", groups)
+		}
+	}
+}
+
+func TestNewCanaryMiddleware(t *testing.T) {
+	backend := canaryBackend(
+		map[string]interface{}{"name": "stable", "weight": 90.0, "host": []interface{}{"http://stable"}},
+		map[string]interface{}{"name": "canary", "weight": 10.0, "host": []interface{}{"http://canary"}},
+	)
+	lb := NewLoadBalancedMiddlewareWithSubscriberAndLogger(logging.NoOp, nil)
+	hosts := map[string]int{}
+	var last string
+	p := NewCanaryMiddleware(logging.NoOp, backend, lb)(func(_ context.Context, r *Request) (*Response, error) {
+		last = r.URL.Host
+		hosts[last]++
+		return &Response{IsComplete: true}, nil
+	})
+
+	for i := 0; i < 2000; i++ {
+		if _, err := p(context.Background(), &Request{Path: "/users", Headers: map[string][]string{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hosts["regular"] != 0 || hosts["canary"] < 100 || hosts["canary"] > 300 || hosts["stable"]+hosts["canary"] != 2000 {
+		t.Errorf("unexpected distribution: %v", hosts)
+	}
+
+	// the requests with the sticky header always reach the same group
+	for i := 0; i < 20; i++ {
+		user := "user-" + strconv.Itoa(i)
+		p(context.Background(), &Request{Path: "/users", Headers: map[string][]string{"X-User-Id": {user}}})
+		first := last
+		for j := 0; j < 5; j++ {
+			p(context.Background(), &Request{Path: "/users", Headers: map[string][]string{"X-User-Id": {user}}})
+			if last != first {
+				t.Errorf("%s: the group changed from %s to %s", user, first, last)
+			}
+		}
+	}
+}
+
+func TestNewCanaryMiddleware_fallback(t *testing.T) {
+	called := false
+	lb := func(next ...Proxy) Proxy {
+		called = true
+		return next[0]
+	}
+	backend := canaryBackend(map[string]interface{}{"weight": 0.0, "host": []interface{}{"http://stable"}})
+	NewCanaryMiddleware(logging.NoOp, backend, lb)(dummyProxy(&Response{}))
+	if !called {
+		t.Error("the invalid configs should keep the received load balancer")
+	}
+}
+
+func TestExplain_canary(t *testing.T) {
+	backend := canaryBackend(
+		map[string]interface{}{"name": "stable", "weight": 95.0, "host": []interface{}{"http://stable"}},
+		map[string]interface{}{"name": "canary", "weight": 5.0, "host": []interface{}{"http://canary"}},
+	)
+	plan := Explain(&config.EndpointConfig{Endpoint: "/users", Method: "GET", Backend: []*config.Backend{backend}})
+	if m := strings.Join(plan.Backends[0].Middlewares, ","); !strings.Contains(m, "canary(stable:95, canary:5),load-balancer") {
+		t.Errorf("unexpected middlewares: %s", m)
+	}
+}
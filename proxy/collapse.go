@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/consistency"
+	"github.com/luraproject/lura/v2/debugtoken"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const collapseKey = "collapse_requests"
+
+// NewBackendCollapseMiddleware creates proxy middleware merging the concurrent identical safe
+// requests to the backend into a single call, so the upstream gets one request per burst
+// instead of a thundering herd. Every caller gets its own copy of the shared response.
+//
+// The requests are identical if they share the method, the path, the query string, the params
+// and the headers, so the responses are never shared across the clients sending different
+// credentials. The middleware is enabled with the collapse_requests flag of the proxy namespace
+// of the backend, and it is ignored by the backends streaming their responses
+func NewBackendCollapseMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	if !isCollapsedBackend(remote) {
+		return emptyMiddlewareFallback(logger)
+	}
+	if remote.Encoding == encoding.NOOP {
+		logger.Warning(fmt.Sprintf("[BACKEND: %s %s -> %s][Collapse] Unable to collapse the requests of a no-op backend", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern))
+		return emptyMiddlewareFallback(logger)
+	}
+	logger.Debug(fmt.Sprintf("[BACKEND: %s %s -> %s][Collapse] Collapsing the concurrent identical requests", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern))
+
+	classify := NewRequestClassifier(remote.ExtraConfig)
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewBackendCollapseMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		g := &callGroup{calls: map[string]*call{}}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			if !classify(request.Method).IsSafe() || collapseBypassed(ctx) {
+				return next[0](ctx, request)
+			}
+			c, leader := g.join(collapseRequestKey(request))
+			if leader {
+				resp, err := next[0](ctx, request)
+				g.done(c, resp, err)
+				return resp, err
+			}
+
+			select {
+			case <-c.finished:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if c.shared == nil && c.err == nil {
+				// the response of the leader can not be shared
+				return next[0](ctx, request)
+			}
+			if c.err != nil {
+				// the client of the leader is gone, so the request is not failing by itself
+				if errors.Is(c.err, context.Canceled) || errors.Is(c.err, context.DeadlineExceeded) {
+					if ctx.Err() == nil {
+						return next[0](ctx, request)
+					}
+				}
+				return nil, c.err
+			}
+			return c.response()
+		}
+	}
+}
+
+func isCollapsedBackend(remote *config.Backend) bool {
+	e, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	v, _ := e[collapseKey].(bool)
+	return v
+}
+
+// collapseBypassed returns true if the request could be answered by a different host than the
+// rest, so it is never collapsed
+func collapseBypassed(ctx context.Context) bool {
+	if _, ok := debugtoken.TargetHost(ctx); ok {
+		return true
+	}
+	return consistency.RequiresPrimary(ctx)
+}
+
+// collapseRequestKey returns the key of the request with all its headers, so only the requests
+// sending the same headers share a response
+func collapseRequestKey(r *Request) string {
+	return cacheRequestKey(r, cacheKeyHeaders(r, config.ParamsFilter{All: true}))
+}
+
+// callGroup tracks the requests in flight by key
+type callGroup struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	key      string
+	finished chan struct{}
+	shared   *Response
+	err      error
+}
+
+// join returns the call in flight for the key, or a new one and true if the caller must execute it
+func (g *callGroup) join(key string) (*call, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.calls[key]; ok {
+		return c, false
+	}
+	c := &call{key: key, finished: make(chan struct{})}
+	g.calls[key] = c
+	return c, true
+}
+
+// done stores a snapshot of the result of the call, before the leader can modify the response,
+// and releases the followers
+func (g *callGroup) done(c *call, resp *Response, err error) {
+	g.mu.Lock()
+	delete(g.calls, c.key)
+	g.mu.Unlock()
+
+	c.err = err
+	if err == nil && resp != nil && resp.Io == nil {
+		c.shared = cloneResponse(resp)
+	}
+	close(c.finished)
+}
+
+// response returns a copy of the snapshot, so the followers can modify it
+func (c *call) response() (*Response, error) {
+	return cloneResponse(c.shared), nil
+}
+
+// cloneResponse returns a deep copy of the data and the metadata of the response
+func cloneResponse(r *Response) *Response {
+	return &Response{
+		Data:       cloneData(r.Data),
+		IsComplete: r.IsComplete,
+		Metadata: Metadata{
+			Headers:    CloneRequestHeaders(r.Metadata.Headers),
+			StatusCode: r.Metadata.StatusCode,
+		},
+	}
+}
+
+func cloneData(data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+	res := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		res[k] = cloneValue(v)
+	}
+	return res
+}
+
+// cloneValue copies the maps and the slices of the decoded responses. The rest of the values,
+// like the json.Number ones, are immutable
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return cloneData(v)
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			res[i] = cloneValue(e)
+		}
+		return res
+	case []map[string]interface{}:
+		res := make([]map[string]interface{}, len(v))
+		for i, e := range v {
+			res[i] = cloneData(e)
+		}
+		return res
+	case []string:
+		return append([]string(nil), v...)
+	}
+	return v
+}
@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/consistency"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func collapsedBackend() *config.Backend {
+	return &config.Backend{
+		Method:     "GET",
+		URLPattern: "/users",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{collapseKey: true},
+		},
+	}
+}
+
+// blockingProxy counts the calls and blocks them until the release channel is closed
+func blockingProxy(calls *int32, release chan struct{}, resp *Response, err error) Proxy {
+	return func(ctx context.Context, _ *Request) (*Response, error) {
+		atomic.AddInt32(calls, 1)
+		<-release
+		if err != nil {
+			return nil, err
+		}
+		r := *resp
+		r.Data = map[string]interface{}{}
+		for k, v := range resp.Data {
+			r.Data[k] = v
+		}
+		return &r, nil
+	}
+}
+
+func runCollapsed(p Proxy, total int, req func() *Request, release chan struct{}) ([]*Response, []error) {
+	resps := make([]*Response, total)
+	errs := make([]error, total)
+	var wg sync.WaitGroup
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resps[i], errs[i] = p(context.Background(), req())
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	return resps, errs
+}
+
+func TestNewBackendCollapseMiddleware_ok(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	expected := &Response{Data: map[string]interface{}{"id": 42.0}, IsComplete: true, Metadata: Metadata{StatusCode: 200, Headers: map[string][]string{"X-A": {"b"}}}}
+	p := NewBackendCollapseMiddleware(logging.NoOp, collapsedBackend())(blockingProxy(&calls, release, expected, nil))
+
+	resps, errs := runCollapsed(p, 10, func() *Request {
+		return &Request{Method: "GET", Path: "/users", Headers: map[string][]string{"Authorization": {"Bearer a"}}}
+	}, release)
+
+	if calls != 1 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+	for i, resp := range resps {
+		if errs[i] != nil {
+			t.Errorf("unexpected error: %s", errs[i])
+			continue
+		}
+		if !resp.IsComplete || resp.Data["id"] != 42.0 || resp.Metadata.StatusCode != 200 || resp.Metadata.Headers["X-A"][0] != "b" {
+			t.Errorf("unexpected response: %+v", resp)
+		}
+	}
+
+	// every caller gets its own copy of the response
+	resps[0].Data["id"] = 1
+	for _, resp := range resps[1:] {
+		if resp.Data["id"] != 42.0 {
+			t.Errorf("the responses are shared: %+v", resp.Data)
+		}
+	}
+}
+
+func TestNewBackendCollapseMiddleware_differentRequests(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	p := NewBackendCollapseMiddleware(logging.NoOp, collapsedBackend())(blockingProxy(&calls, release, &Response{IsComplete: true}, nil))
+
+	var i int32
+	runCollapsed(p, 4, func() *Request {
+		// the requests with different credentials are never collapsed
+		token := []string{"a", "b"}[atomic.AddInt32(&i, 1)%2]
+		return &Request{Method: "GET", Path: "/users", Headers: map[string][]string{"Authorization": {token}}}
+	}, release)
+
+	if calls != 2 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+}
+
+func TestNewBackendCollapseMiddleware_unsafe(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	p := NewBackendCollapseMiddleware(logging.NoOp, collapsedBackend())(blockingProxy(&calls, release, &Response{IsComplete: true}, nil))
+
+	runCollapsed(p, 3, func() *Request { return &Request{Method: "POST", Path: "/users"} }, release)
+
+	if calls != 3 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+}
+
+func TestNewBackendCollapseMiddleware_primary(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	p := NewBackendCollapseMiddleware(logging.NoOp, collapsedBackend())(blockingProxy(&calls, release, &Response{IsComplete: true}, nil))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p(consistency.WithPrimary(context.Background()), &Request{Method: "GET", Path: "/users"})
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 3 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+}
+
+func TestNewBackendCollapseMiddleware_error(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	expectedErr := errors.New("boom")
+	p := NewBackendCollapseMiddleware(logging.NoOp, collapsedBackend())(blockingProxy(&calls, release, nil, expectedErr))
+
+	resps, errs := runCollapsed(p, 5, func() *Request { return &Request{Method: "GET", Path: "/users"} }, release)
+
+	if calls != 1 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+	for i := range errs {
+		if errs[i] != expectedErr || resps[i] != nil {
+			t.Errorf("unexpected result: %v %v", resps[i], errs[i])
+		}
+	}
+}
+
+func TestNewBackendCollapseMiddleware_canceledLeader(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	next := func(ctx context.Context, _ *Request) (*Response, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return &Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+	p := NewBackendCollapseMiddleware(logging.NoOp, collapsedBackend())(next)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p(ctx, &Request{Method: "GET", Path: "/users"})
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	var resp *Response
+	var err error
+	go func() {
+		resp, err = p(context.Background(), &Request{Method: "GET", Path: "/users"})
+		close(release)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+	<-release
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if resp == nil || resp.Data["ok"] != true {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if calls != 2 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+}
+
+func TestNewBackendCollapseMiddleware_disabled(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	backend := collapsedBackend()
+	backend.Encoding = "no-op"
+	p := NewBackendCollapseMiddleware(logging.NoOp, backend)(blockingProxy(&calls, release, &Response{IsComplete: true, Metadata: Metadata{StatusCode: http.StatusOK}}, nil))
+
+	runCollapsed(p, 3, func() *Request { return &Request{Method: "GET", Path: "/users"} }, release)
+
+	if calls != 3 {
+		t.Errorf("unexpected number of calls to the backend: %d", calls)
+	}
+}
+
+func TestNewBackendCollapseMiddleware_copies(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	expected := &Response{
+		Data: map[string]interface{}{
+			"id":    json.Number("9007199254740993"),
+			"items": []interface{}{map[string]interface{}{"a": "b"}},
+		},
+		IsComplete: true,
+		Metadata:   Metadata{StatusCode: 200, Headers: map[string][]string{"X-A": {"b"}}},
+	}
+	p := NewBackendCollapseMiddleware(logging.NoOp, collapsedBackend())(blockingProxy(&calls, release, expected, nil))
+
+	resps, errs := runCollapsed(p, 5, func() *Request {
+		return &Request{Method: "GET", Path: "/users"}
+	}, release)
+	for i, resp := range resps {
+		if errs[i] != nil {
+			t.Fatalf("unexpected error: %s", errs[i])
+		}
+		if v := resp.Data["id"]; v != json.Number("9007199254740993") {
+			t.Errorf("#%d: unexpected id: %v (%T)", i, v, v)
+		}
+	}
+	for i, resp := range resps {
+		resp.Metadata.Headers["X-A"] = []string{fmt.Sprint(i)}
+		resp.Data["items"].([]interface{})[0].(map[string]interface{})["a"] = i
+	}
+	for i, resp := range resps {
+		if resp.Data["items"].([]interface{})[0].(map[string]interface{})["a"] != i {
+			t.Errorf("#%d: the responses share their data", i)
+		}
+		if resp.Metadata.Headers["X-A"][0] != fmt.Sprint(i) {
+			t.Errorf("#%d: the responses share their headers", i)
+		}
+	}
+}
@@ -5,13 +5,16 @@ package proxy
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/logging"
 )
 
-// NewConcurrentMiddlewareWithLogger creates a proxy middleware that enables sending several requests concurrently
+// NewConcurrentMiddlewareWithLogger creates a proxy middleware that enables sending several requests concurrently.
+// The requests that are not retry-safe are sent just once
 func NewConcurrentMiddlewareWithLogger(logger logging.Logger, remote *config.Backend) Middleware {
 	if remote.ConcurrentCalls == 1 {
 		logger.Fatal("too few concurrent calls for %s %s -> %s: NewConcurrentMiddleware expects more than 1 concurrent call, got %d",
@@ -19,6 +22,20 @@ func NewConcurrentMiddlewareWithLogger(logger logging.Logger, remote *config.Bac
 		return nil
 	}
 	serviceTimeout := time.Duration(75*remote.Timeout.Nanoseconds()/100) * time.Nanosecond
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Concurrent]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	classify := NewRequestClassifier(remote.ExtraConfig)
+	if !classify(remote.Method).IsRetrySafe() {
+		logger.Warning(fmt.Sprintf("%s The %s requests are not retry-safe, so they will be sent just once",
+			logPrefix, strings.ToUpper(remote.Method)))
+	}
+	strategy, err := getConcurrentStrategy(remote)
+	if err != nil {
+		logger.Error(logPrefix, err.Error(), "Using the first strategy")
+		strategy = &concurrentStrategy{name: concurrentFirst}
+	}
+	if strategy.name != concurrentFirst {
+		logger.Debug(logPrefix, "Selecting the responses with the", strategy.name, "strategy")
+	}
 
 	return func(next ...Proxy) Proxy {
 		if len(next) > 1 {
@@ -28,22 +45,25 @@ func NewConcurrentMiddlewareWithLogger(logger logging.Logger, remote *config.Bac
 		}
 
 		return func(ctx context.Context, request *Request) (*Response, error) {
+			if !classify(request.Method).IsRetrySafe() {
+				return next[0](ctx, request)
+			}
 			localCtx, cancel := context.WithTimeout(ctx, serviceTimeout)
 
-			results := make(chan *Response, remote.ConcurrentCalls)
+			results := make(chan concurrentResponse, remote.ConcurrentCalls)
 			failed := make(chan error, remote.ConcurrentCalls)
 
 			for i := 0; i < remote.ConcurrentCalls; i++ {
-				go processConcurrentCall(localCtx, next[0], request, results, failed)
+				go processConcurrentCall(localCtx, next[0], request, i, results, failed)
 			}
 
-			var response *Response
+			selection := strategy.newSelection()
 			var err error
 
 			for i := 0; i < remote.ConcurrentCalls; i++ {
 				select {
-				case response = <-results:
-					if response != nil && response.IsComplete {
+				case r := <-results:
+					if response, ok := selection.add(r.replica, r.response); ok {
 						cancel()
 						return response, nil
 					}
@@ -52,7 +72,7 @@ func NewConcurrentMiddlewareWithLogger(logger logging.Logger, remote *config.Bac
 				}
 			}
 			cancel()
-			return response, err
+			return selection.fallback(err)
 		}
 	}
 }
@@ -65,7 +85,13 @@ func NewConcurrentMiddleware(remote *config.Backend) Middleware {
 
 var errNullResult = errors.New("invalid response")
 
-func processConcurrentCall(ctx context.Context, next Proxy, request *Request, out chan<- *Response, failed chan<- error) {
+// concurrentResponse is the response of one of the concurrent calls
+type concurrentResponse struct {
+	replica  int
+	response *Response
+}
+
+func processConcurrentCall(ctx context.Context, next Proxy, request *Request, replica int, out chan<- concurrentResponse, failed chan<- error) {
 	localCtx, cancel := context.WithCancel(ctx)
 
 	result, err := next(localCtx, request)
@@ -80,7 +106,7 @@ func processConcurrentCall(ctx context.Context, next Proxy, request *Request, ou
 		return
 	}
 	select {
-	case out <- result:
+	case out <- concurrentResponse{replica: replica, response: result}:
 	case <-ctx.Done():
 		failed <- ctx.Err()
 	}
@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/jsonschema"
+)
+
+const (
+	concurrentStrategyKey = "concurrent_strategy"
+
+	concurrentFirst  = "first"
+	concurrentQuorum = "quorum"
+	concurrentSchema = "schema"
+)
+
+// ConcurrentReplicaHeader is the metadata header with the index of the concurrent call returning
+// the response, added when the concurrent strategy of the backend exposes it
+const ConcurrentReplicaHeader = "X-Concurrent-Replica"
+
+// ErrNoConcurrentQuorum is returned when the concurrent calls of a backend using the quorum
+// strategy do not return enough equal responses
+var ErrNoConcurrentQuorum = errors.New("no quorum among the concurrent responses")
+
+// concurrentStrategy decides which of the responses of the concurrent calls of a backend is
+// returned:
+//
+//	"extra_config": {
+//		"github.com/devopsfaith/krakend/proxy": {
+//			"concurrent_strategy": {
+//				"strategy": "quorum",
+//				"quorum": 2,
+//				"expose_replica": true
+//			}
+//		}
+//	}
+//
+// The "first" strategy (the default) returns the first complete response. The "quorum" one returns
+// the first response whose data is equal to the data of the responses of quorum calls (a majority
+// by default), and the "schema" one returns the first complete response valid against the declared
+// schema ("schema" or "schema_path"), or the first complete response if none is valid
+type concurrentStrategy struct {
+	name          string
+	quorum        int
+	schema        *jsonschema.Schema
+	exposeReplica bool
+}
+
+// getConcurrentStrategy returns the concurrent strategy of the backend, or the first strategy if it
+// does not declare one
+func getConcurrentStrategy(remote *config.Backend) (*concurrentStrategy, error) {
+	s := &concurrentStrategy{name: concurrentFirst}
+	e, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return s, nil
+	}
+	v, ok := e[concurrentStrategyKey].(map[string]interface{})
+	if !ok {
+		return s, nil
+	}
+	if name, ok := v["strategy"].(string); ok && name != "" {
+		s.name = name
+	}
+	s.exposeReplica, _ = v["expose_replica"].(bool)
+
+	switch s.name {
+	case concurrentFirst:
+	case concurrentQuorum:
+		s.quorum = remote.ConcurrentCalls/2 + 1
+		if q, ok := v["quorum"]; ok {
+			f, ok := q.(float64)
+			if !ok || f != float64(int(f)) || f < 1 || int(f) > remote.ConcurrentCalls {
+				return nil, fmt.Errorf("invalid quorum %v for %d concurrent calls", q, remote.ConcurrentCalls)
+			}
+			s.quorum = int(f)
+		}
+	case concurrentSchema:
+		doc, _ := v["schema"].(map[string]interface{})
+		path, _ := v["schema_path"].(string)
+		if doc == nil && path == "" {
+			return nil, errors.New("the schema strategy requires a schema")
+		}
+		schema, err := jsonschema.Load(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		s.schema = schema
+	default:
+		return nil, fmt.Errorf("unknown concurrent strategy %q", s.name)
+	}
+	return s, nil
+}
+
+// newSelection returns the state of the selection of the responses of a request
+func (s *concurrentStrategy) newSelection() *concurrentSelection {
+	return &concurrentSelection{strategy: s}
+}
+
+type concurrentGroup struct {
+	replica  int
+	response *Response
+	votes    int
+}
+
+// concurrentSelection receives the responses of the concurrent calls of a request, in the order
+// they are returned, until one of them is selected
+type concurrentSelection struct {
+	strategy *concurrentStrategy
+	last     *Response
+	groups   []*concurrentGroup
+}
+
+// add returns the response to return and true when the response of the replica is selected
+func (c *concurrentSelection) add(replica int, resp *Response) (*Response, bool) {
+	c.last = resp
+	if !resp.IsComplete {
+		return nil, false
+	}
+	switch c.strategy.name {
+	case concurrentQuorum:
+		for _, g := range c.groups {
+			if reflect.DeepEqual(g.response.Data, resp.Data) {
+				g.votes++
+				if g.votes >= c.strategy.quorum {
+					return c.expose(g.response, g.replica), true
+				}
+				return nil, false
+			}
+		}
+		c.groups = append(c.groups, &concurrentGroup{replica: replica, response: resp, votes: 1})
+		if c.strategy.quorum == 1 {
+			return c.expose(resp, replica), true
+		}
+		return nil, false
+	case concurrentSchema:
+		if len(c.strategy.schema.Validate(resp.Data)) == 0 {
+			return c.expose(resp, replica), true
+		}
+		if len(c.groups) == 0 {
+			c.groups = append(c.groups, &concurrentGroup{replica: replica, response: resp, votes: 1})
+		}
+		return nil, false
+	}
+	return c.expose(resp, replica), true
+}
+
+// fallback returns the response to return when none was selected and all the calls are done
+func (c *concurrentSelection) fallback(err error) (*Response, error) {
+	if len(c.groups) == 0 {
+		return c.last, err
+	}
+	best := c.groups[0]
+	for _, g := range c.groups[1:] {
+		if g.votes > best.votes {
+			best = g
+		}
+	}
+	if c.strategy.name == concurrentSchema {
+		return c.expose(best.response, best.replica), nil
+	}
+	resp := *c.expose(best.response, best.replica)
+	resp.IsComplete = false
+	return &resp, ErrNoConcurrentQuorum
+}
+
+// expose adds the index of the replica to the metadata of a copy of the response, if the strategy
+// exposes it
+func (c *concurrentSelection) expose(resp *Response, replica int) *Response {
+	if !c.strategy.exposeReplica {
+		return resp
+	}
+	// the responses could be shared, so the header is added to a copy
+	res := *resp
+	res.Metadata.Headers = CloneRequestHeaders(resp.Metadata.Headers)
+	http.Header(res.Metadata.Headers).Set(ConcurrentReplicaHeader, strconv.Itoa(replica))
+	return &res
+}
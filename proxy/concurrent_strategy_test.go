@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func concurrentBackend(strategy map[string]interface{}) *config.Backend {
+	return &config.Backend{
+		ConcurrentCalls: 3,
+		Timeout:         time.Second,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{concurrentStrategyKey: strategy},
+		},
+	}
+}
+
+// sequencedProxy returns the responses in order, delaying each call a bit more than the previous
+// one, so the order of the responses is the order of the calls
+func sequencedProxy(responses ...*Response) Proxy {
+	calls := int64(-1)
+	return func(ctx context.Context, _ *Request) (*Response, error) {
+		i := atomic.AddInt64(&calls, 1)
+		select {
+		case <-time.After(time.Duration(i*20) * time.Millisecond):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return responses[i], nil
+	}
+}
+
+func TestNewConcurrentMiddleware_quorum(t *testing.T) {
+	mw := NewConcurrentMiddleware(concurrentBackend(map[string]interface{}{
+		"strategy":       "quorum",
+		"expose_replica": true,
+	}))
+	p := mw(sequencedProxy(
+		&Response{Data: map[string]interface{}{"v": 1}, IsComplete: true},
+		&Response{Data: map[string]interface{}{"v": 2}, IsComplete: true},
+		&Response{Data: map[string]interface{}{"v": 1}, IsComplete: true},
+	))
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.IsComplete || resp.Data["v"] != 1 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	// the replicas are the indexes of the calls, launched concurrently
+	if h := resp.Metadata.Headers[ConcurrentReplicaHeader]; len(h) != 1 || h[0] < "0" || h[0] > "2" {
+		t.Errorf("unexpected replica header: %v", h)
+	}
+}
+
+func TestNewConcurrentMiddleware_noQuorum(t *testing.T) {
+	mw := NewConcurrentMiddleware(concurrentBackend(map[string]interface{}{
+		"strategy": "quorum",
+		"quorum":   3.0,
+	}))
+	p := mw(sequencedProxy(
+		&Response{Data: map[string]interface{}{"v": 1}, IsComplete: true},
+		&Response{Data: map[string]interface{}{"v": 2}, IsComplete: true},
+		&Response{Data: map[string]interface{}{"v": 2}, IsComplete: true},
+	))
+	resp, err := p(context.Background(), &Request{})
+	if err != ErrNoConcurrentQuorum {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if resp == nil || resp.IsComplete || resp.Data["v"] != 2 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if _, ok := resp.Metadata.Headers[ConcurrentReplicaHeader]; ok {
+		t.Error("the replica must not be exposed")
+	}
+}
+
+func TestNewConcurrentMiddleware_schema(t *testing.T) {
+	mw := NewConcurrentMiddleware(concurrentBackend(map[string]interface{}{
+		"strategy": "schema",
+		"schema": map[string]interface{}{
+			"type":     "object",
+			"required": []interface{}{"id"},
+		},
+		"expose_replica": true,
+	}))
+	p := mw(sequencedProxy(
+		&Response{Data: map[string]interface{}{"error": "stale"}, IsComplete: true},
+		&Response{Data: map[string]interface{}{"id": 42}, IsComplete: true},
+		&Response{Data: map[string]interface{}{"id": 43}, IsComplete: true},
+	))
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["id"] != 42 || len(resp.Metadata.Headers[ConcurrentReplicaHeader]) != 1 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	p = mw(sequencedProxy(
+		&Response{Data: map[string]interface{}{"a": 1}, IsComplete: true},
+		&Response{Data: map[string]interface{}{"b": 2}, IsComplete: true},
+		&Response{Data: map[string]interface{}{"c": 3}, IsComplete: true},
+	))
+	resp, err = p(context.Background(), &Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["a"] != 1 || !resp.IsComplete {
+		t.Errorf("the first complete response was expected as fallback: %+v", resp)
+	}
+}
+
+func TestGetConcurrentStrategy(t *testing.T) {
+	s, err := getConcurrentStrategy(&config.Backend{ConcurrentCalls: 3})
+	if err != nil || s.name != concurrentFirst {
+		t.Errorf("unexpected default strategy: %+v, %v", s, err)
+	}
+	s, err = getConcurrentStrategy(concurrentBackend(map[string]interface{}{"strategy": "quorum"}))
+	if err != nil || s.quorum != 2 {
+		t.Errorf("unexpected default quorum: %+v, %v", s, err)
+	}
+	for _, cfg := range []map[string]interface{}{
+		{"strategy": "fastest"},
+		{"strategy": "quorum", "quorum": 4.0},
+		{"strategy": "quorum", "quorum": 0.0},
+		{"strategy": "schema"},
+		{"strategy": "schema", "schema": map[string]interface{}{"pattern": "("}},
+	} {
+		if _, err := getConcurrentStrategy(concurrentBackend(cfg)); err == nil {
+			t.Errorf("expecting an error for %v", cfg)
+		}
+	}
+}
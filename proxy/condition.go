@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const (
+	whenKey                  = "when"
+	incompleteWhenSkippedKey = "incomplete_when_skipped"
+)
+
+// backendCondition decides if a backend of a multi-backend endpoint must be called. The backends
+// declare the condition as an expression over the same params accepted by their url pattern:
+//
+//	"extra_config": {
+//		"github.com/devopsfaith/krakend/proxy": {
+//			"when": "{resp0_id} && {resp0_active} != false",
+//			"incomplete_when_skipped": false
+//		}
+//	}
+//
+// The params can be compared with == and != and combined with !, && and || and parentheses. The
+// missing params are empty and a value is true unless it is empty, "false" or "0". The skipped
+// backends do not change the completeness of the merged response, unless they declare
+// incomplete_when_skipped
+type backendCondition struct {
+	expr       conditionExpr
+	keys       []string
+	incomplete bool
+}
+
+// getBackendCondition returns the condition declared by the backend, if any
+func getBackendCondition(remote *config.Backend) (*backendCondition, error) {
+	e, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	src, ok := e[whenKey].(string)
+	if !ok || strings.TrimSpace(src) == "" {
+		return nil, nil
+	}
+	p := &conditionParser{}
+	if err := p.tokenize(src); err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %w", src, err)
+	}
+	expr, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %w", src, err)
+	}
+	incomplete, _ := e[incompleteWhenSkippedKey].(bool)
+	return &backendCondition{expr: expr, keys: p.keys, incomplete: incomplete}, nil
+}
+
+// getBackendConditions returns the conditions of the backends of the endpoint. The backends with
+// an invalid condition are always called
+func getBackendConditions(cfg *config.EndpointConfig) ([]*backendCondition, []error) {
+	conds := make([]*backendCondition, len(cfg.Backend))
+	var errs []error
+	for i, b := range cfg.Backend {
+		c, err := getBackendCondition(b)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("backend %s: %w", b.URLPattern, err))
+			continue
+		}
+		conds[i] = c
+	}
+	return conds, errs
+}
+
+// templates returns the params referenced by the condition, in the format used by the url
+// patterns, so the sequential proxy can resolve them
+func (c *backendCondition) templates() string {
+	if c == nil {
+		return ""
+	}
+	parts := make([]string, len(c.keys))
+	for i, k := range c.keys {
+		parts[i] = "{{." + k + "}}"
+	}
+	return strings.Join(parts, " ")
+}
+
+// skip returns true if the backend must not be called with the received params
+func (c *backendCondition) skip(params map[string]string) bool {
+	return c != nil && !isTruthy(c.expr.eval(params))
+}
+
+// skipped returns the response standing for a skipped backend
+func (c *backendCondition) skipped() *Response {
+	return &Response{Data: map[string]interface{}{}, IsComplete: !c.incomplete}
+}
+
+func isTruthy(v string) bool {
+	return v != "" && v != "false" && v != "0"
+}
+
+type conditionExpr interface {
+	eval(params map[string]string) string
+}
+
+type literalExpr string
+
+func (l literalExpr) eval(_ map[string]string) string { return string(l) }
+
+type paramExpr string
+
+func (p paramExpr) eval(params map[string]string) string { return params[string(p)] }
+
+type notExpr struct{ e conditionExpr }
+
+func (n notExpr) eval(params map[string]string) string {
+	return boolString(!isTruthy(n.e.eval(params)))
+}
+
+type logicalExpr struct {
+	and         bool
+	left, right conditionExpr
+}
+
+func (l logicalExpr) eval(params map[string]string) string {
+	left := isTruthy(l.left.eval(params))
+	if l.and && !left || !l.and && left {
+		return boolString(left)
+	}
+	return boolString(isTruthy(l.right.eval(params)))
+}
+
+type compareExpr struct {
+	equal       bool
+	left, right conditionExpr
+}
+
+func (c compareExpr) eval(params map[string]string) string {
+	return boolString((c.left.eval(params) == c.right.eval(params)) == c.equal)
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+type conditionToken struct {
+	kind  byte // o: operator, p: param, l: literal
+	value string
+}
+
+type conditionParser struct {
+	tokens []conditionToken
+	pos    int
+	keys   []string
+}
+
+var errUnexpectedEnd = errors.New("unexpected end of the expression")
+
+func (p *conditionParser) tokenize(src string) error {
+	for i := 0; i < len(src); {
+		switch c := src[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.HasPrefix(src[i:], "&&"), strings.HasPrefix(src[i:], "||"),
+			strings.HasPrefix(src[i:], "=="), strings.HasPrefix(src[i:], "!="):
+			p.tokens = append(p.tokens, conditionToken{'o', src[i : i+2]})
+			i += 2
+		case c == '!' || c == '(' || c == ')':
+			p.tokens = append(p.tokens, conditionToken{'o', src[i : i+1]})
+			i++
+		case c == '{':
+			end := strings.IndexByte(src[i:], '}')
+			if end < 2 {
+				return fmt.Errorf("unterminated param at %d", i)
+			}
+			name := src[i+1 : i+end]
+			// same naming than the one applied by the config package to the url patterns
+			key := strings.ToUpper(name[:1]) + name[1:]
+			p.tokens = append(p.tokens, conditionToken{'p', key})
+			p.keys = append(p.keys, key)
+			i += end + 1
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(src[i+1:], c)
+			if end < 0 {
+				return fmt.Errorf("unterminated string at %d", i)
+			}
+			p.tokens = append(p.tokens, conditionToken{'l', src[i+1 : i+1+end]})
+			i += end + 2
+		default:
+			start := i
+			for i < len(src) && !strings.ContainsRune(" \t&|=!(){}'\"", rune(src[i])) {
+				i++
+			}
+			if start == i {
+				return fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			p.tokens = append(p.tokens, conditionToken{'l', src[start:i]})
+		}
+	}
+	return nil
+}
+
+func (p *conditionParser) next(op string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == 'o' && p.tokens[p.pos].value == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *conditionParser) parseOr() (conditionExpr, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.next("||") {
+		var right conditionExpr
+		right, err = p.parseAnd()
+		left = logicalExpr{left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *conditionParser) parseAnd() (conditionExpr, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.next("&&") {
+		var right conditionExpr
+		right, err = p.parseUnary()
+		left = logicalExpr{and: true, left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *conditionParser) parseUnary() (conditionExpr, error) {
+	if p.next("!") {
+		e, err := p.parseUnary()
+		return notExpr{e}, err
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.next("=="):
+		right, err := p.parseOperand()
+		return compareExpr{equal: true, left: left, right: right}, err
+	case p.next("!="):
+		right, err := p.parseOperand()
+		return compareExpr{left: left, right: right}, err
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseOperand() (conditionExpr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, errUnexpectedEnd
+	}
+	if p.next("(") {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.next(")") {
+			return nil, errors.New("missing )")
+		}
+		return e, nil
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	switch t.kind {
+	case 'p':
+		return paramExpr(t.value), nil
+	case 'l':
+		return literalExpr(t.value), nil
+	}
+	return nil, fmt.Errorf("unexpected %q", t.value)
+}
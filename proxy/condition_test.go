@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestGetBackendCondition(t *testing.T) {
+	params := map[string]string{
+		"Id":        "42",
+		"Resp0_id":  "7",
+		"Resp0_vip": "false",
+		"Name":      "foo bar",
+	}
+	for _, tc := range []struct {
+		when string
+		skip bool
+	}{
+		{"{id}", false},
+		{"{missing}", true},
+		{"!{missing}", false},
+		{"{resp0_vip}", true},
+		{"{resp0_vip} != false", true},
+		{"{resp0_id} == 7", false},
+		{"{resp0_id} == '8' || {id} == \"42\"", false},
+		{"{resp0_id} && !({resp0_vip} || {missing})", false},
+		{"{id} && {resp0_vip}", true},
+		{"{name} == 'foo bar'", false},
+		{"0", true},
+	} {
+		c, err := getBackendCondition(&config.Backend{ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{whenKey: tc.when},
+		}})
+		if err != nil {
+			t.Errorf("%s: %s", tc.when, err)
+			continue
+		}
+		if skip := c.skip(params); skip != tc.skip {
+			t.Errorf("%s: unexpected result %v", tc.when, skip)
+		}
+	}
+
+	for _, when := range []string{"{id} &&", "({id}", "{id} == 'x", "{}", "{id} )", "{id} &"} {
+		if _, err := getBackendCondition(&config.Backend{ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{whenKey: when},
+		}}); err == nil {
+			t.Errorf("%s: error expected", when)
+		}
+	}
+
+	if c, err := getBackendCondition(&config.Backend{}); c != nil || err != nil || c.skip(params) {
+		t.Error("the backends without conditions must be called")
+	}
+}
+
+func TestNewMergeDataMiddleware_conditionalParallel(t *testing.T) {
+	endpoint := config.EndpointConfig{
+		Backend: []*config.Backend{
+			{},
+			{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{whenKey: "{expand} == true"}}},
+			{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+				whenKey:                  "{expand} == all",
+				incompleteWhenSkippedKey: true,
+			}}},
+		},
+		Timeout: time.Second,
+	}
+	var calls int32
+	backend := func(key string) Proxy {
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			atomic.AddInt32(&calls, 1)
+			return &Response{Data: map[string]interface{}{key: true}, IsComplete: true}, nil
+		}
+	}
+	p := NewMergeDataMiddleware(logging.NoOp, &endpoint)(backend("a"), backend("b"), backend("c"))
+
+	for _, tc := range []struct {
+		expand   string
+		calls    int32
+		keys     int
+		complete bool
+	}{
+		{expand: "true", calls: 2, keys: 2, complete: false},
+		{expand: "all", calls: 2, keys: 2, complete: true},
+	} {
+		atomic.StoreInt32(&calls, 0)
+		resp, err := p(context.Background(), &Request{Params: map[string]string{"Expand": tc.expand}})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if c := atomic.LoadInt32(&calls); c != tc.calls {
+			t.Errorf("%s: unexpected number of calls %d", tc.expand, c)
+		}
+		if len(resp.Data) != tc.keys || resp.IsComplete != tc.complete {
+			t.Errorf("%s: unexpected response %+v", tc.expand, resp)
+		}
+	}
+}
+
+func TestNewMergeDataMiddleware_conditionalSequential(t *testing.T) {
+	endpoint := config.EndpointConfig{
+		Backend: []*config.Backend{
+			{URLPattern: "/users/{{.Id}}"},
+			{
+				URLPattern:  "/companies/{{.Resp0_company}}",
+				ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{whenKey: "{resp0_company}"}},
+			},
+			{URLPattern: "/tail"},
+		},
+		Timeout: time.Second,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{isSequentialKey: true},
+		},
+	}
+	var companyCalls int32
+	p := NewMergeDataMiddleware(logging.NoOp, &endpoint)(
+		func(_ context.Context, r *Request) (*Response, error) {
+			data := map[string]interface{}{"name": "john"}
+			if r.Params["Id"] == "1" {
+				data["company"] = "acme"
+			}
+			return &Response{Data: data, IsComplete: true}, nil
+		},
+		func(_ context.Context, _ *Request) (*Response, error) {
+			atomic.AddInt32(&companyCalls, 1)
+			return &Response{Data: map[string]interface{}{"company_name": "ACME"}, IsComplete: true}, nil
+		},
+		dummyProxy(&Response{Data: map[string]interface{}{"tail": true}, IsComplete: true}),
+	)
+
+	resp, err := p(context.Background(), &Request{Params: map[string]string{"Id": "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if companyCalls != 0 || !resp.IsComplete || resp.Data["tail"] != true || len(resp.Data) != 2 {
+		t.Errorf("unexpected response %+v. calls: %d", resp, companyCalls)
+	}
+
+	resp, err = p(context.Background(), &Request{Params: map[string]string{"Id": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if companyCalls != 1 || !resp.IsComplete || resp.Data["company_name"] != "ACME" {
+		t.Errorf("unexpected response %+v. calls: %d", resp, companyCalls)
+	}
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/consistency"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+// NewReadYourWritesMiddleware creates proxy middleware sending the mutating requests and the
+// reads carrying a valid routing hint to the primary hosts of the backend, and the rest of the
+// reads to the load balancer received. The successful writes add a new hint to their responses.
+// It returns the load balancer when the backend does not declare primary hosts
+func NewReadYourWritesMiddleware(logger logging.Logger, remote *config.Backend, lb Middleware) Middleware {
+	cfg, ok := consistency.BackendConfigGetter(remote.ExtraConfig)
+	if !ok {
+		return lb
+	}
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Consistency]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	logger.Debug(logPrefix, "Sending the writes and the hinted reads to", cfg.PrimaryHosts)
+	primaryLB := NewLoadBalancedMiddlewareWithSubscriberAndLogger(logger, sd.FixedSubscriber(cfg.PrimaryHosts))
+	h := consistency.GetGlobal()
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewReadYourWritesMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		regular := lb(next[0])
+		primary := primaryLB(next[0])
+
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			if !isMutatingMethod(r.Method) {
+				if consistency.RequiresPrimary(ctx) {
+					return primary(ctx, r)
+				}
+				return regular(ctx, r)
+			}
+			resp, err := primary(ctx, r)
+			if err != nil || resp == nil {
+				return resp, err
+			}
+			if resp.Metadata.Headers == nil {
+				resp.Metadata.Headers = map[string][]string{}
+			}
+			h.SetHint(resp.Metadata.Headers)
+			return resp, nil
+		}
+	}
+}
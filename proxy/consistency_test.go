@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/consistency"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+func TestNewReadYourWritesMiddleware(t *testing.T) {
+	remote := &config.Backend{
+		URLPattern: "/orders",
+		ExtraConfig: config.ExtraConfig{consistency.Namespace: map[string]interface{}{
+			"primary_hosts": []interface{}{"http://primary"},
+		}},
+	}
+	lb := NewLoadBalancedMiddlewareWithSubscriberAndLogger(logging.NoOp, sd.FixedSubscriber([]string{"http://replica"}))
+
+	var host string
+	p := NewReadYourWritesMiddleware(logging.NoOp, remote, lb)(func(_ context.Context, r *Request) (*Response, error) {
+		host = r.URL.Host
+		return &Response{IsComplete: true}, nil
+	})
+
+	if _, err := p(context.Background(), &Request{Method: "GET", Path: "/orders"}); err != nil || host != "replica" {
+		t.Errorf("the read should be sent to the replica. host: %s, err: %v", host, err)
+	}
+
+	resp, err := p(context.Background(), &Request{Method: "POST", Path: "/orders"})
+	if err != nil || host != "primary" {
+		t.Errorf("the write should be sent to the primary. host: %s, err: %v", host, err)
+	}
+	token := resp.Metadata.Headers[consistency.DefaultHeader]
+	if len(token) != 1 || !consistency.GetGlobal().Valid(token[0]) {
+		t.Errorf("unexpected hint: %v", resp.Metadata.Headers)
+	}
+
+	if _, err := p(consistency.WithPrimary(context.Background()), &Request{Method: "GET", Path: "/orders"}); err != nil || host != "primary" {
+		t.Errorf("the hinted read should be sent to the primary. host: %s, err: %v", host, err)
+	}
+}
+
+func TestNewReadYourWritesMiddleware_noPrimaries(t *testing.T) {
+	var called bool
+	lb := func(next ...Proxy) Proxy {
+		called = true
+		return next[0]
+	}
+	NewReadYourWritesMiddleware(logging.NoOp, &config.Backend{}, lb)(dummyProxy(&Response{}))
+	if !called {
+		t.Error("the load balancer should be used as it is")
+	}
+}
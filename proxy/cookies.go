@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	cookiesKey = "cookies"
+
+	setCookiePass  = "pass"
+	setCookieStrip = "strip"
+)
+
+// cookiePolicy declares the cookies forwarded to the backends and the handling of the cookies
+// set by them:
+//
+//	"github.com/devopsfaith/krakend/proxy": {
+//		"cookies": {
+//			"forward": ["*", "!tracking"],
+//			"set_cookie": "pass",
+//			"rename": {"sid": "orders_sid"}
+//		}
+//	}
+//
+// The forward list supports the wildcard and the denied entries of the input_headers. The
+// set_cookie policy is "pass" (the default) or "strip" and the rename map changes the names of
+// the cookies passed back to the clients
+type cookiePolicy struct {
+	forward   *config.ParamsFilter
+	strip     bool
+	rename    map[string]string
+	forwarded []string
+}
+
+func getCookiePolicy(extra config.ExtraConfig) (cookiePolicy, bool, error) {
+	policy := cookiePolicy{}
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return policy, false, nil
+	}
+	tmp, ok := e[cookiesKey].(map[string]interface{})
+	if !ok {
+		return policy, false, nil
+	}
+	if v, ok := tmp["forward"].([]interface{}); ok {
+		for _, c := range v {
+			if s, ok := c.(string); ok {
+				policy.forwarded = append(policy.forwarded, s)
+			}
+		}
+		f := config.NewParamsFilter(policy.forwarded)
+		policy.forward = &f
+	}
+	switch s, _ := tmp["set_cookie"].(string); s {
+	case "", setCookiePass:
+	case setCookieStrip:
+		policy.strip = true
+	default:
+		return policy, true, fmt.Errorf("cookies: unknown set_cookie policy %s", s)
+	}
+	policy.rename = getStringMap(tmp["rename"])
+	if policy.strip && len(policy.rename) > 0 {
+		return policy, true, fmt.Errorf("cookies: the stripped cookies can not be renamed")
+	}
+	return policy, true, nil
+}
+
+// filterRequest returns the request with the Cookie header reduced to the forwarded cookies
+func (c cookiePolicy) filterRequest(r *Request) *Request {
+	if c.forward == nil || len(r.Headers["Cookie"]) == 0 {
+		return r
+	}
+	cookies := (&http.Request{Header: http.Header{"Cookie": r.Headers["Cookie"]}}).Cookies()
+	kept := make([]string, 0, len(cookies))
+	for _, ck := range cookies {
+		if c.forward.Allows(ck.Name) {
+			kept = append(kept, ck.Name+"="+ck.Value)
+		}
+	}
+	if len(kept) == len(cookies) {
+		return r
+	}
+	res := r.Clone()
+	res.Headers = CloneRequestHeaders(r.Headers)
+	if len(kept) == 0 {
+		delete(res.Headers, "Cookie")
+	} else {
+		res.Headers["Cookie"] = []string{strings.Join(kept, "; ")}
+	}
+	return &res
+}
+
+// filterResponse returns the response with the Set-Cookie headers stripped or renamed
+func (c cookiePolicy) filterResponse(resp *Response) *Response {
+	if resp == nil || len(resp.Metadata.Headers["Set-Cookie"]) == 0 || (!c.strip && len(c.rename) == 0) {
+		return resp
+	}
+	res := *resp
+	res.Metadata.Headers = CloneRequestHeaders(resp.Metadata.Headers)
+	if c.strip {
+		delete(res.Metadata.Headers, "Set-Cookie")
+		return &res
+	}
+	values := res.Metadata.Headers["Set-Cookie"]
+	for i, v := range values {
+		eq := strings.Index(v, "=")
+		if eq < 0 {
+			continue
+		}
+		if name, ok := c.rename[strings.TrimSpace(v[:eq])]; ok {
+			values[i] = name + v[eq:]
+		}
+	}
+	return &res
+}
+
+// NewCookiePolicyMiddleware creates proxy middleware restricting the cookies forwarded to the
+// backends and stripping or renaming the cookies they set. The endpoint must declare the Cookie
+// header in its input_headers to forward any cookie. The variants of the endpoint are selected
+// after the policy is applied, so their cookies must be forwarded too
+func NewCookiePolicyMiddleware(logger logging.Logger, cfg *config.EndpointConfig) Middleware {
+	policy, ok, err := getCookiePolicy(cfg.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	logPrefix := fmt.Sprintf("[ENDPOINT: %s][Cookies]", cfg.Endpoint)
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return emptyMiddlewareFallback(logger)
+	}
+	if policy.forward != nil {
+		logger.Debug(logPrefix, "Forwarding the cookies", policy.forwarded)
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewCookiePolicyMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, policy.filterRequest(request))
+			return policy.filterResponse(resp), err
+		}
+	}
+}
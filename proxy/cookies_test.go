@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func cookiesEndpoint(policy map[string]interface{}) *config.EndpointConfig {
+	return &config.EndpointConfig{
+		Endpoint:    "/orders",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{cookiesKey: policy}},
+	}
+}
+
+func TestGetCookiePolicy(t *testing.T) {
+	if _, ok, _ := getCookiePolicy(config.ExtraConfig{}); ok {
+		t.Error("the policy should not be found")
+	}
+	for _, policy := range []map[string]interface{}{
+		{"set_cookie": "rename"},
+		{"set_cookie": "strip", "rename": map[string]interface{}{"sid": "orders_sid"}},
+	} {
+		if _, _, err := getCookiePolicy(cookiesEndpoint(policy).ExtraConfig); err == nil {
+			t.Errorf("%v: expecting an error", policy)
+		}
+	}
+}
+
+func TestNewCookiePolicyMiddleware_forward(t *testing.T) {
+	var received map[string][]string
+	p := NewCookiePolicyMiddleware(logging.NoOp, cookiesEndpoint(map[string]interface{}{
+		"forward": []interface{}{"sid", "ring"},
+	}))(func(_ context.Context, r *Request) (*Response, error) {
+		received = r.Headers
+		return &Response{IsComplete: true}, nil
+	})
+
+	for _, tc := range []struct {
+		cookie string
+		want   []string
+	}{
+		{cookie: "sid=1; tracking=abc; ring=beta", want: []string{"sid=1; ring=beta"}},
+		{cookie: "sid=1; ring=beta", want: []string{"sid=1; ring=beta"}},
+		{cookie: "tracking=abc"},
+	} {
+		headers := map[string][]string{"Cookie": {tc.cookie}, "X-Foo": {"bar"}}
+		if _, err := p(context.Background(), &Request{Headers: headers}); err != nil {
+			t.Fatal(err)
+		}
+		if len(received["X-Foo"]) != 1 {
+			t.Errorf("%s: the rest of headers should be forwarded: %v", tc.cookie, received)
+		}
+		if c := received["Cookie"]; len(c) != len(tc.want) || (len(c) > 0 && c[0] != tc.want[0]) {
+			t.Errorf("%s: unexpected cookies: %v", tc.cookie, c)
+		}
+		if headers["Cookie"][0] != tc.cookie {
+			t.Errorf("the headers of the request should not be modified: %v", headers)
+		}
+	}
+}
+
+func TestNewCookiePolicyMiddleware_setCookie(t *testing.T) {
+	setCookies := []string{"sid=1; Path=/; HttpOnly", "lang=en"}
+	backend := func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{
+			IsComplete: true,
+			Metadata: Metadata{Headers: map[string][]string{
+				"Set-Cookie":   setCookies,
+				"Content-Type": {"application/json"},
+			}},
+		}, nil
+	}
+
+	p := NewCookiePolicyMiddleware(logging.NoOp, cookiesEndpoint(map[string]interface{}{
+		"set_cookie": "strip",
+	}))(backend)
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resp.Metadata.Headers["Set-Cookie"]; ok || len(resp.Metadata.Headers) != 1 {
+		t.Errorf("unexpected headers: %v", resp.Metadata.Headers)
+	}
+
+	p = NewCookiePolicyMiddleware(logging.NoOp, cookiesEndpoint(map[string]interface{}{
+		"rename": map[string]interface{}{"sid": "orders_sid"},
+	}))(backend)
+	resp, err = p(context.Background(), &Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := resp.Metadata.Headers["Set-Cookie"]; len(c) != 2 || c[0] != "orders_sid=1; Path=/; HttpOnly" || c[1] != "lang=en" {
+		t.Errorf("unexpected cookies: %v", c)
+	}
+	if setCookies[0] != "sid=1; Path=/; HttpOnly" {
+		t.Error("the backend response should not be modified")
+	}
+}
@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/consistency"
+	"github.com/luraproject/lura/v2/debugtoken"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+// NewDebugHostMiddleware creates proxy middleware sending the requests carrying a debug token
+// that targets one of the hosts of the backend to that host, and the rest to the load balancer
+// received. Only the hosts declared in the config, including the primary and the canary ones, can
+// be targeted. It returns the load balancer when the service does not accept debug tokens
+func NewDebugHostMiddleware(logger logging.Logger, remote *config.Backend, lb Middleware) Middleware {
+	if _, ok := debugtoken.GetGlobal(); !ok {
+		return lb
+	}
+	hosts := append([]string{}, remote.Host...)
+	if cfg, ok := consistency.BackendConfigGetter(remote.ExtraConfig); ok {
+		hosts = append(hosts, cfg.PrimaryHosts...)
+	}
+	if cfg, ok, err := getCanaryCfg(remote); ok && err == nil {
+		for _, g := range cfg.groups {
+			hosts = append(hosts, g.hosts...)
+		}
+	}
+	if len(hosts) == 0 {
+		return lb
+	}
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][DebugToken]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	logger.Debug(logPrefix, "The debug tokens can target the hosts", hosts)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewDebugHostMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		regular := lb(next[0])
+		targets := make(map[string]Proxy, len(hosts))
+		for _, h := range hosts {
+			targets[h] = NewLoadBalancedMiddlewareWithSubscriberAndLogger(logger, sd.FixedSubscriber{h})(next[0])
+		}
+
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			if host, ok := debugtoken.TargetHost(ctx); ok {
+				if p, ok := targets[host]; ok {
+					return p(ctx, r)
+				}
+				logger.Warning(logPrefix, "Ignoring the unknown host of the debug token:", host)
+			}
+			return regular(ctx, r)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/debugtoken"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+func TestNewDebugHostMiddleware(t *testing.T) {
+	v, _ := debugtoken.New(debugtoken.Config{Secret: "s3cr3t"})
+	debugtoken.SetGlobal(v)
+	defer debugtoken.SetGlobal(nil)
+
+	remote := &config.Backend{URLPattern: "/users", Host: []string{"http://users-1", "http://users-2"}}
+	lb := NewLoadBalancedMiddlewareWithSubscriberAndLogger(logging.NoOp, sd.FixedSubscriber{"http://users-1"})
+	var host string
+	p := NewDebugHostMiddleware(logging.NoOp, remote, lb)(func(_ context.Context, r *Request) (*Response, error) {
+		host = r.URL.Host
+		return &Response{IsComplete: true}, nil
+	})
+
+	for _, tc := range []struct {
+		ctx      context.Context
+		expected string
+	}{
+		{context.Background(), "users-1"},
+		{debugtoken.NewContext(context.Background(), debugtoken.Claims{Host: "http://users-2"}), "users-2"},
+		{debugtoken.NewContext(context.Background(), debugtoken.Claims{Host: "http://evil"}), "users-1"},
+	} {
+		if _, err := p(tc.ctx, &Request{Path: "/users"}); err != nil {
+			t.Fatal(err)
+		}
+		if host != tc.expected {
+			t.Errorf("unexpected host %s, expected %s", host, tc.expected)
+		}
+	}
+}
+
+func TestNewCacheMiddleware_debugToken(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/users",
+		CacheTTL: time.Minute,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{cacheKey: map[string]interface{}{"max_size": 1024.0}},
+		},
+	}
+	calls := 0
+	p := NewCacheMiddleware(logging.NoOp, endpoint)(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		return &Response{Data: map[string]interface{}{"calls": calls}, IsComplete: true}, nil
+	})
+
+	bypass := debugtoken.NewContext(context.Background(), debugtoken.Claims{NoCache: true})
+	for i, ctx := range []context.Context{context.Background(), context.Background(), bypass, context.Background()} {
+		if _, err := p(ctx, &Request{Method: "GET", Path: "/users"}); err != nil {
+			t.Fatalf("#%d: %s", i, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("unexpected number of calls %d", calls)
+	}
+}
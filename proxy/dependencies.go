@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const (
+	maxParallelKey = "max_parallel"
+	dependsOnKey   = "depends_on"
+)
+
+// getMaxParallel returns the max number of backend calls of the endpoint running in parallel.
+// Zero means no limit
+func getMaxParallel(extra config.ExtraConfig) int {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	n, ok := e[maxParallelKey].(float64)
+	if !ok || n < 1 {
+		return 0
+	}
+	return int(n)
+}
+
+// getDependencies returns the indexes of the backends every backend depends on, as declared by
+// their depends_on lists:
+//
+//	"github.com/devopsfaith/krakend/proxy": {
+//		"depends_on": [0, 1]
+//	}
+//
+// It returns nil if none of the backends declares dependencies and an error if they reference
+// unknown backends or they are cyclic
+func getDependencies(backends []*config.Backend) ([][]int, error) {
+	var deps [][]int
+	for i, b := range backends {
+		e, ok := b.ExtraConfig[Namespace].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		vs, ok := e[dependsOnKey].([]interface{})
+		if !ok || len(vs) == 0 {
+			continue
+		}
+		if deps == nil {
+			deps = make([][]int, len(backends))
+		}
+		for _, v := range vs {
+			f, ok := v.(float64)
+			d := int(f)
+			if !ok || float64(d) != f || d < 0 || d >= len(backends) || d == i {
+				return nil, fmt.Errorf("the backend %d depends on the invalid backend %v", i, v)
+			}
+			deps[i] = append(deps[i], d)
+		}
+	}
+	if deps == nil {
+		return nil, nil
+	}
+
+	// the dependencies must be acyclic, so every backend is eventually called
+	state := make([]int, len(backends))
+	var visit func(int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case 1:
+			return fmt.Errorf("the dependencies of the backend %d are cyclic", i)
+		case 2:
+			return nil
+		}
+		state[i] = 1
+		for _, d := range deps[i] {
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		state[i] = 2
+		return nil
+	}
+	for i := range backends {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return deps, nil
+}
+
+// semaphore bounds the number of concurrent calls. The nil semaphore does not bound them
+type semaphore chan struct{}
+
+func newSemaphore(size int) semaphore {
+	if size < 1 {
+		return nil
+	}
+	return make(semaphore, size)
+}
+
+func (s semaphore) acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s semaphore) release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// requestBoundedPart requests the part once the semaphore lets it
+func requestBoundedPart(ctx context.Context, sem semaphore, i int, next Proxy, request *Request, out chan<- indexedPart) {
+	if err := sem.acquire(ctx); err != nil {
+		out <- indexedPart{index: i, err: err}
+		return
+	}
+	defer sem.release()
+	requestIndexedPart(ctx, i, next, request, out)
+}
+
+// dependencyMerge calls every backend as soon as the backends it depends on succeed, so the
+// independent ones run in parallel. The templates of the backends can reference the responses
+// of their dependencies, like in the sequential merge. The backends with a failed or incomplete
+// dependency are not called
+func dependencyMerge(reqCloner func(*Request) *Request, steps []sequentialStep, deps [][]int, maxParallel int, timeout time.Duration, newAcc func() mergeAccumulator, next ...Proxy) Proxy {
+	return func(ctx context.Context, request *Request) (*Response, error) {
+		localCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		total := len(next)
+		parts := make([]*Response, total)
+		started := make([]bool, total)
+		done := make([]bool, total)
+		failed := make([]bool, total)
+		results := make(chan indexedPart, total)
+		sem := newSemaphore(maxParallel)
+		pending := total
+		// the parts are merged at the end, because the combiners update the merged responses
+		// and the dependencies must be read as received
+		received := make([]indexedPart, 0, total)
+
+		// schedule starts the backends with all their dependencies done, until no more of them
+		// can be started or discarded
+		schedule := func() {
+			for changed := true; changed; {
+				changed = false
+			Backends:
+				for i := range next {
+					if started[i] {
+						continue
+					}
+					brokenDep := -1
+					for _, d := range deps[i] {
+						if !done[d] {
+							continue Backends
+						}
+						if failed[d] {
+							brokenDep = d
+						}
+					}
+					started[i], changed = true, true
+
+					if brokenDep >= 0 {
+						done[i], failed[i] = true, true
+						pending--
+						received = append(received, indexedPart{index: i, err: fmt.Errorf("the dependency %d of the backend %d failed", brokenDep, i)})
+						continue
+					}
+
+					r := reqCloner(request)
+					stepRequest := r.Clone()
+					stepRequest.Params = CloneRequestParams(r.Params)
+					available := make([]*Response, total)
+					metas := make([]Metadata, total)
+					for _, d := range deps[i] {
+						if parts[d] != nil {
+							available[d] = parts[d]
+							metas[d] = parts[d].Metadata
+						}
+					}
+					templates := steps[i].templates()
+					setMetadataParams(stepRequest.Params, templates, metas)
+					setResponseParams(stepRequest.Params, templates, available)
+
+					if steps[i].cond.skip(stepRequest.Params) {
+						done[i] = true
+						pending--
+						received = append(received, indexedPart{index: i, response: steps[i].cond.skipped()})
+						continue
+					}
+					if len(steps[i].headers) > 0 {
+						stepRequest = *withSequentialHeaders(&stepRequest, steps[i].headers)
+					}
+					go requestBoundedPart(localCtx, sem, i, next[i], &stepRequest, results)
+				}
+			}
+		}
+
+		for schedule(); pending > 0; schedule() {
+			p := <-results
+			pending--
+			done[p.index] = true
+			if p.err != nil || p.response == nil || !p.response.IsComplete {
+				failed[p.index] = true
+			} else {
+				parts[p.index] = p.response
+			}
+			received = append(received, p)
+		}
+
+		acc := newAcc()
+		for _, p := range received {
+			acc.MergeAt(p.index, p.response, p.err)
+		}
+		return acc.Result()
+	}
+}
@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func dependsOn(indexes ...int) config.ExtraConfig {
+	deps := make([]interface{}, len(indexes))
+	for i, d := range indexes {
+		deps[i] = float64(d)
+	}
+	return config.ExtraConfig{Namespace: map[string]interface{}{dependsOnKey: deps}}
+}
+
+func TestNewMergeDataMiddleware_dependencies(t *testing.T) {
+	endpoint := config.EndpointConfig{
+		Backend: []*config.Backend{
+			{URLPattern: "/a"},
+			{URLPattern: "/b"},
+			{URLPattern: "/c/{{.Resp0_id}}/{{.Resp1_id}}", ExtraConfig: dependsOn(0, 1)},
+		},
+		Timeout: time.Second,
+	}
+
+	var mu sync.Mutex
+	var order []string
+	called := func(name string) {
+		mu.Lock()
+		order = append(order, name)
+		mu.Unlock()
+	}
+	a := func(_ context.Context, _ *Request) (*Response, error) {
+		time.Sleep(20 * time.Millisecond)
+		called("a")
+		return &Response{IsComplete: true, Data: map[string]interface{}{"id": "x", "a": true}}, nil
+	}
+	b := func(_ context.Context, _ *Request) (*Response, error) {
+		called("b")
+		return &Response{IsComplete: true, Data: map[string]interface{}{"id": "y", "b": true}}, nil
+	}
+	c := func(_ context.Context, r *Request) (*Response, error) {
+		called("c")
+		if r.Params["Resp0_id"] != "x" || r.Params["Resp1_id"] != "y" {
+			t.Errorf("unexpected params: %v", r.Params)
+		}
+		return &Response{IsComplete: true, Data: map[string]interface{}{"c": true}}, nil
+	}
+
+	out, err := NewMergeDataMiddleware(logging.NoOp, &endpoint)(a, b, c)(context.Background(), &Request{Params: map[string]string{}})
+	if err != nil || !out.IsComplete {
+		t.Errorf("unexpected result. complete: %v, error: %v", out.IsComplete, err)
+		return
+	}
+	if len(order) != 3 || order[2] != "c" {
+		t.Errorf("unexpected order: %v", order)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		if _, ok := out.Data[k]; !ok {
+			t.Errorf("missing key %s: %v", k, out.Data)
+		}
+	}
+}
+
+func TestNewMergeDataMiddleware_failedDependency(t *testing.T) {
+	endpoint := config.EndpointConfig{
+		Backend: []*config.Backend{
+			{URLPattern: "/a"},
+			{URLPattern: "/b", ExtraConfig: dependsOn(0)},
+			{URLPattern: "/c", ExtraConfig: dependsOn(1)},
+			{URLPattern: "/d"},
+		},
+		Timeout: time.Second,
+	}
+	fail := func(_ context.Context, _ *Request) (*Response, error) { return nil, errors.New("boom") }
+	notCalled := func(_ context.Context, _ *Request) (*Response, error) {
+		t.Error("the backend should not be called")
+		return nil, nil
+	}
+	d := func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{IsComplete: true, Data: map[string]interface{}{"d": true}}, nil
+	}
+
+	out, err := NewMergeDataMiddleware(logging.NoOp, &endpoint)(fail, notCalled, notCalled, d)(context.Background(), &Request{Params: map[string]string{}})
+	if err == nil {
+		t.Error("expecting an error")
+	}
+	if errs := err.(mergeError).Errors(); len(errs) != 3 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	if out == nil || out.IsComplete {
+		t.Errorf("unexpected response: %v", out)
+		return
+	}
+	b, _ := json.Marshal(out.Data)
+	if string(b) != `{"d":true}` {
+		t.Errorf("unexpected data: %s", b)
+	}
+}
+
+func TestNewMergeDataMiddleware_maxParallel(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		backends []*config.Backend
+	}{
+		{
+			name:     "parallel",
+			backends: []*config.Backend{{}, {}, {}, {}},
+		},
+		{
+			name:     "dependencies",
+			backends: []*config.Backend{{}, {}, {}, {ExtraConfig: dependsOn(0)}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			endpoint := config.EndpointConfig{
+				Backend:     tc.backends,
+				Timeout:     time.Second,
+				ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{maxParallelKey: 2.0}},
+			}
+			var running, peak int32
+			backend := func(_ context.Context, _ *Request) (*Response, error) {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return &Response{IsComplete: true, Data: map[string]interface{}{}}, nil
+			}
+			next := []Proxy{backend, backend, backend, backend}
+			out, err := NewMergeDataMiddleware(logging.NoOp, &endpoint)(next...)(context.Background(), &Request{Params: map[string]string{}})
+			if err != nil || !out.IsComplete {
+				t.Errorf("unexpected result. complete: %v, error: %v", out.IsComplete, err)
+			}
+			if peak != 2 {
+				t.Errorf("unexpected peak of parallel calls: %d", peak)
+			}
+		})
+	}
+}
+
+func TestGetDependencies(t *testing.T) {
+	deps, err := getDependencies([]*config.Backend{{}, {}})
+	if deps != nil || err != nil {
+		t.Errorf("unexpected result: %v, %v", deps, err)
+	}
+
+	for i, backends := range [][]*config.Backend{
+		{{}, {ExtraConfig: dependsOn(2)}},
+		{{}, {ExtraConfig: dependsOn(1)}},
+		{{ExtraConfig: dependsOn(1)}, {ExtraConfig: dependsOn(2)}, {ExtraConfig: dependsOn(0)}},
+		{{}, {ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{dependsOnKey: []interface{}{0.5}}}}},
+	} {
+		if _, err := getDependencies(backends); err == nil {
+			t.Errorf("#%d: expecting an error", i)
+		}
+	}
+}
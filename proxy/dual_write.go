@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/metrics"
+)
+
+const (
+	dualWriteKey        = "dual_write"
+	dualWriteTimeoutKey = "dual_write_timeout"
+)
+
+type dualWriteFactory struct {
+	f      Factory
+	logger logging.Logger
+}
+
+// New checks the Backends for an ExtraConfig with the "dual_write" param to true and
+// implements the Factory interface. The flagged backends are the secondary ones, receiving a
+// copy of the mutating requests sent to the rest. Sets the "dual_write_timeout" defined in the
+// config; uses the backend timeout as fallback.
+func (d dualWriteFactory) New(cfg *config.EndpointConfig) (p Proxy, err error) {
+	if len(cfg.Backend) == 0 {
+		err = ErrNoBackends
+		return
+	}
+
+	backends := cfg.Backend
+	defer func() { cfg.Backend = backends }()
+
+	var secondary []*config.Backend
+	var primary []*config.Backend
+	var maxTimeout time.Duration
+	for _, b := range backends {
+		if t, ok := isDualWriteBackend(b); ok {
+			if maxTimeout < t {
+				maxTimeout = t
+			}
+			secondary = append(secondary, b)
+			continue
+		}
+		primary = append(primary, b)
+	}
+
+	cfg.Backend = primary
+	p, err = d.f.New(cfg)
+	if err != nil || len(secondary) == 0 {
+		return
+	}
+
+	cfg.Backend = secondary
+	pSecondary, err := d.f.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p = NewDualWriteProxy(d.logger, cfg.Endpoint, maxTimeout, p, pSecondary)
+	return
+}
+
+// NewDualWriteFactory creates a new dualWriteFactory using the provided Factory
+func NewDualWriteFactory(f Factory, logger logging.Logger) Factory {
+	return dualWriteFactory{f, logger}
+}
+
+// NewDualWriteProxy returns a Proxy that sends the mutating requests to the primary and the
+// secondary proxies and returns the response of the primary one. The secondary request runs
+// with its own timeout, so it is not cancelled when the primary one finishes. When both
+// responses have a different status code, the divergence is logged and counted in the pipeline
+// metrics, if registered. The rest of the requests are only sent to the primary proxy.
+func NewDualWriteProxy(logger logging.Logger, endpoint string, timeout time.Duration, primary, secondary Proxy) Proxy {
+	return newDualWriteProxy(logger, endpoint, timeout, primary, secondary, func() {})
+}
+
+// newDualWriteProxy returns a dual write proxy calling the compared func once the responses of
+// every mutating request are compared
+func newDualWriteProxy(logger logging.Logger, endpoint string, timeout time.Duration, primary, secondary Proxy, compared func()) Proxy {
+	if timeout <= 0 {
+		timeout = config.DefaultTimeout
+	}
+	logPrefix := "[ENDPOINT: " + endpoint + "][DualWrite]"
+	m, hasMetrics := metrics.GetGlobal()
+
+	return func(ctx context.Context, request *Request) (*Response, error) {
+		if !isMutatingMethod(request.Method) {
+			return primary(ctx, request)
+		}
+
+		secondaryCtx, cancel := newContextWrapperWithTimeout(ctx, timeout)
+		secondaryRequest := CloneRequest(request)
+		secondaryStatus := make(chan int, 1)
+		go func() {
+			resp, err := secondary(secondaryCtx, secondaryRequest)
+			cancel()
+			secondaryStatus <- responseStatus(resp, err)
+		}()
+
+		resp, err := primary(ctx, request)
+		primaryStatus := responseStatus(resp, err)
+		go func() {
+			defer compared()
+			s := <-secondaryStatus
+			if s == primaryStatus {
+				return
+			}
+			logger.Warning(logPrefix, fmt.Sprintf("Status mismatch: the primary returned %d and the secondary %d", primaryStatus, s))
+			if hasMetrics {
+				m.ObserveDivergence(endpoint, primaryStatus, s)
+			}
+		}()
+		return resp, err
+	}
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// responseStatus returns the status code the router would send for the result of a proxy
+func responseStatus(resp *Response, err error) int {
+	if err != nil {
+		if e, ok := err.(interface{ StatusCode() int }); ok {
+			return e.StatusCode()
+		}
+		return http.StatusInternalServerError
+	}
+	if resp == nil {
+		return http.StatusInternalServerError
+	}
+	if resp.Metadata.StatusCode == 0 {
+		return http.StatusOK
+	}
+	return resp.Metadata.StatusCode
+}
+
+func isDualWriteBackend(c *config.Backend) (time.Duration, bool) {
+	duration := c.Timeout
+	e, ok := c.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return duration, false
+	}
+
+	if v, ok := e[dualWriteKey].(bool); !ok || !v {
+		return duration, false
+	}
+
+	if t, ok := e[dualWriteTimeoutKey].(string); ok {
+		if d, err := time.ParseDuration(t); err == nil {
+			duration = d
+		}
+	}
+	return duration, true
+}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/metrics"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+func TestIsDualWriteBackend(t *testing.T) {
+	cfg := &config.Backend{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"dual_write":         true,
+			"dual_write_timeout": "3s",
+		},
+	}}
+	d, ok := isDualWriteBackend(cfg)
+	if !ok {
+		t.Error("the backend should be a dual write one")
+	}
+	if d != 3*time.Second {
+		t.Errorf("invalid duration %s", d)
+	}
+
+	if _, ok := isDualWriteBackend(&config.Backend{}); ok {
+		t.Error("the backend should not be a dual write one")
+	}
+	badCfg := &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"dual_write": "yes"}}}
+	if _, ok := isDualWriteBackend(badCfg); ok {
+		t.Error("the backend should not be a dual write one")
+	}
+}
+
+func TestNewDualWriteProxy(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.SetGlobal(metrics.NewPipeline(r, metrics.DefaultBuckets))
+	defer metrics.SetGlobal(nil)
+
+	buff := &lockedBuffer{}
+	logger, _ := logging.NewLogger("WARNING", buff, "")
+	var wg sync.WaitGroup
+
+	var secondaryCalls uint64
+	secondaryBodies := make(chan string, 2)
+	primary := func(ctx context.Context, req *Request) (*Response, error) {
+		return &Response{IsComplete: true, Metadata: Metadata{StatusCode: 201}}, nil
+	}
+	secondary := func(ctx context.Context, req *Request) (*Response, error) {
+		n := atomic.AddUint64(&secondaryCalls, 1)
+		b, _ := io.ReadAll(req.Body)
+		secondaryBodies <- string(b)
+		if n == 1 {
+			return &Response{IsComplete: true, Metadata: Metadata{StatusCode: 201}}, nil
+		}
+		return nil, client.HTTPResponseError{Code: 409, Msg: "conflict"}
+	}
+	p := newDualWriteProxy(logger, "/orders", time.Second, primary, secondary, wg.Done)
+
+	if _, err := p(context.Background(), &Request{Method: "GET"}); err != nil {
+		t.Error(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		req := &Request{Method: "POST", Body: io.NopCloser(strings.NewReader("payload"))}
+		resp, err := p(context.Background(), req)
+		if err != nil || resp.Metadata.StatusCode != 201 {
+			t.Errorf("unexpected response: %v %v", resp, err)
+		}
+		if b, _ := io.ReadAll(req.Body); string(b) != "payload" {
+			t.Errorf("the primary body was consumed: %s", string(b))
+		}
+		select {
+		case b := <-secondaryBodies:
+			if b != "payload" {
+				t.Errorf("unexpected secondary body: %s", b)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("the secondary proxy was not called")
+		}
+	}
+	wg.Wait()
+
+	if n := atomic.LoadUint64(&secondaryCalls); n != 2 {
+		t.Errorf("the secondary proxy should have been called 2 times, not %d", n)
+	}
+	if !strings.Contains(buff.String(), "[ENDPOINT: /orders][DualWrite] Status mismatch: the primary returned 201 and the secondary 409") {
+		t.Errorf("the divergence was not logged: %s", buff.String())
+	}
+	if strings.Count(buff.String(), "Status mismatch") != 1 {
+		t.Errorf("unexpected number of divergences logged: %s", buff.String())
+	}
+	out := new(bytes.Buffer)
+	r.WriteTo(out)
+	if !strings.Contains(out.String(), `lura_dual_write_divergences_total{endpoint="/orders",primary="201",secondary="409"} 1`) {
+		t.Errorf("the divergence was not counted: %s", out.String())
+	}
+}
+
+// lockedBuffer is a bytes.Buffer safe for concurrent use
+type lockedBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (l *lockedBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.Write(p)
+}
+
+func (l *lockedBuffer) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.String()
+}
+
+func TestDualWriteFactory(t *testing.T) {
+	var primaryCalls, secondaryCalls uint64
+	factory := FactoryFunc(func(cfg *config.EndpointConfig) (Proxy, error) {
+		if len(cfg.Backend) == 0 {
+			return nil, ErrNoBackends
+		}
+		counter := &primaryCalls
+		if cfg.Backend[0].URLPattern == "/v2" {
+			counter = &secondaryCalls
+		}
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			atomic.AddUint64(counter, 1)
+			return &Response{IsComplete: true}, nil
+		}, nil
+	})
+
+	cfg := &config.EndpointConfig{
+		Endpoint: "/orders",
+		Backend: []*config.Backend{
+			{URLPattern: "/v1"},
+			{URLPattern: "/v2", ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"dual_write": true}}},
+		},
+	}
+	p, err := NewDualWriteFactory(factory, logging.NoOp).New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Backend) != 2 {
+		t.Errorf("the factory changed the backends of the endpoint: %d", len(cfg.Backend))
+	}
+
+	p(context.Background(), &Request{Method: "PUT"})
+	p(context.Background(), &Request{Method: "GET"})
+	time.Sleep(50 * time.Millisecond)
+
+	if n := atomic.LoadUint64(&primaryCalls); n != 2 {
+		t.Errorf("the primary proxy should have been called 2 times, not %d", n)
+	}
+	if n := atomic.LoadUint64(&secondaryCalls); n != 1 {
+		t.Errorf("the secondary proxy should have been called once, not %d", n)
+	}
+
+	if _, err := NewDualWriteFactory(factory, logging.NoOp).New(&config.EndpointConfig{}); err != ErrNoBackends {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/expression"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const enrichKey = "enrich"
+
+func init() {
+	config.InputParamsExtractors[Namespace] = enrichedParams
+}
+
+// enrichedParams returns the names of the params computed by the enrich option of an endpoint, so
+// the config accepts them in the url patterns of its backends
+func enrichedParams(e config.ExtraConfig) []string {
+	v, _ := e[Namespace].(map[string]interface{})
+	enrich, _ := v[enrichKey].(map[string]interface{})
+	params, _ := enrich["params"].(map[string]interface{})
+	names := make([]string, 0, len(params))
+	for k := range params {
+		names = append(names, k)
+	}
+	return names
+}
+
+// enrichment computes new params and headers of the requests of an endpoint with the expressions
+// declared in its config:
+//
+//	"extra_config": {
+//		"github.com/devopsfaith/krakend/proxy": {
+//			"enrich": {
+//				"params": {"tenant": "headers['Host'].split('.')[0].lower()"},
+//				"headers": {"X-User": "lower(headers['X-User'])"}
+//			}
+//		}
+//	}
+//
+// All the expressions see the request as received, and the params are available to the url
+// patterns of the backends like the ones of the endpoint, so the tenant param is {tenant}
+type enrichment struct {
+	params  []enrichedValue
+	headers []enrichedValue
+}
+
+type enrichedValue struct {
+	name string
+	expr *expression.Expression
+}
+
+// getEnrichment returns the enrichment declared by the endpoint, if any
+func getEnrichment(cfg *config.EndpointConfig) (*enrichment, error) {
+	e, ok := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	v, ok := e[enrichKey].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	res := &enrichment{}
+	var err error
+	if res.params, err = compileEnrichedValues(v["params"], func(k string) string {
+		return strings.ToUpper(k[:1]) + k[1:]
+	}); err != nil {
+		return nil, err
+	}
+	if res.headers, err = compileEnrichedValues(v["headers"], http.CanonicalHeaderKey); err != nil {
+		return nil, err
+	}
+	if len(res.params) == 0 && len(res.headers) == 0 {
+		return nil, nil
+	}
+	return res, nil
+}
+
+func compileEnrichedValues(v interface{}, name func(string) string) ([]enrichedValue, error) {
+	m, _ := v.(map[string]interface{})
+	res := make([]enrichedValue, 0, len(m))
+	for k, raw := range m {
+		src, ok := raw.(string)
+		if k == "" || !ok {
+			return nil, fmt.Errorf("invalid enrichment %q: %v", k, raw)
+		}
+		expr, err := expression.Compile(src)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, enrichedValue{name: name(k), expr: expr})
+	}
+	// keep the order of the evaluations stable, so the logs are too
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res, nil
+}
+
+// NewEnrichmentMiddleware creates a proxy middleware setting the params and the headers declared
+// by the enrich option of the endpoint, evaluating their expressions once per request, before
+// sending it to the backends. The values failing to evaluate are not set, and the empty headers
+// are not sent
+func NewEnrichmentMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	enrich, err := getEnrichment(endpointConfig)
+	logPrefix := "[ENDPOINT: " + endpointConfig.Endpoint + "][Enrich]"
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return emptyMiddlewareFallback(logger)
+	}
+	if enrich == nil {
+		return emptyMiddlewareFallback(logger)
+	}
+	logger.Debug(logPrefix, fmt.Sprintf("Computing %d params and %d headers", len(enrich.params), len(enrich.headers)))
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewEnrichmentMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			data := expression.Data{
+				Method:  r.Method,
+				Path:    r.Path,
+				Params:  r.Params,
+				Headers: r.Headers,
+				Query:   r.Query,
+			}
+			params := CloneRequestParams(r.Params)
+			for _, v := range enrich.params {
+				s, err := v.expr.EvalString(data)
+				if err != nil {
+					logger.Warning(logPrefix, err.Error())
+					continue
+				}
+				params[v.name] = s
+			}
+			headers := CloneRequestHeaders(r.Headers)
+			for _, v := range enrich.headers {
+				s, err := v.expr.EvalString(data)
+				if err != nil {
+					logger.Warning(logPrefix, err.Error())
+					continue
+				}
+				if s == "" {
+					delete(headers, v.name)
+					continue
+				}
+				headers[v.name] = []string{s}
+			}
+
+			req := *r
+			req.Params = params
+			req.Headers = headers
+			return next[0](ctx, &req)
+		}
+	}
+}
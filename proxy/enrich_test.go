@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewEnrichmentMiddleware(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint: "/users/{id}",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				enrichKey: map[string]interface{}{
+					"params": map[string]interface{}{
+						"tenant": "headers['X-Forwarded-Host'].split('.')[0].lower()",
+						"key":    "params.id + '-' + query.page",
+						"broken": "int(params.id)",
+					},
+					"headers": map[string]interface{}{
+						"x-tenant":  "headers['X-Forwarded-Host'].split('.')[0]",
+						"X-Removed": "''",
+					},
+				},
+			},
+		},
+	}
+	original := &Request{
+		Method:  "GET",
+		Path:    "/users/abc",
+		Params:  map[string]string{"Id": "abc"},
+		Headers: map[string][]string{"X-Forwarded-Host": {"ACME.example.com"}, "X-Removed": {"x"}},
+		Query:   url.Values{"page": {"2"}},
+	}
+
+	var received *Request
+	p := NewEnrichmentMiddleware(logging.NoOp, cfg)(func(_ context.Context, r *Request) (*Response, error) {
+		received = r
+		return &Response{IsComplete: true}, nil
+	})
+	if _, err := p(context.Background(), original); err != nil {
+		t.Fatal(err)
+	}
+
+	if received.Params["Tenant"] != "acme" || received.Params["Key"] != "abc-2" {
+		t.Errorf("unexpected params: %v", received.Params)
+	}
+	if _, ok := received.Params["Broken"]; ok {
+		t.Errorf("the failed params must not be set: %v", received.Params)
+	}
+	if h := received.Headers["X-Tenant"]; len(h) != 1 || h[0] != "ACME" {
+		t.Errorf("unexpected headers: %v", received.Headers)
+	}
+	if _, ok := received.Headers["X-Removed"]; ok {
+		t.Errorf("the empty headers must be removed: %v", received.Headers)
+	}
+	if len(original.Params) != 1 || len(original.Headers) != 2 {
+		t.Errorf("the original request was modified: %+v", original)
+	}
+}
+
+func TestGetEnrichment(t *testing.T) {
+	if e, err := getEnrichment(&config.EndpointConfig{}); e != nil || err != nil {
+		t.Errorf("unexpected result: %v, %v", e, err)
+	}
+	for _, v := range []map[string]interface{}{
+		{"params": map[string]interface{}{"a": "unknown(path)"}},
+		{"headers": map[string]interface{}{"X-A": 42}},
+		{"params": map[string]interface{}{"": "path"}},
+	} {
+		cfg := &config.EndpointConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{enrichKey: v}}}
+		if _, err := getEnrichment(cfg); err == nil {
+			t.Errorf("expecting an error for %v", v)
+		}
+	}
+}
+
+func TestEnrichedParams_config(t *testing.T) {
+	backend := &config.Backend{URLPattern: "/tenants/{tenant}/users/{id}"}
+	subject := config.ServiceConfig{
+		Version: config.ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint: "/users/{id}",
+				Method:   "GET",
+				Backend:  []*config.Backend{backend},
+				ExtraConfig: config.ExtraConfig{
+					Namespace: map[string]interface{}{
+						"enrich": map[string]interface{}{
+							"params": map[string]interface{}{"tenant": "headers['Host'].split('.')[0]"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := subject.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if backend.URLPattern != "/tenants/{{.Tenant}}/users/{{.Id}}" {
+		t.Errorf("unexpected url pattern: %s", backend.URLPattern)
+	}
+}
@@ -0,0 +1,479 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+
+	"github.com/luraproject/lura/v2/budget"
+	"github.com/luraproject/lura/v2/bulkhead"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/consistency"
+	"github.com/luraproject/lura/v2/debugtoken"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/jsonschema"
+	"github.com/luraproject/lura/v2/masking"
+	"github.com/luraproject/lura/v2/metaheaders"
+	"github.com/luraproject/lura/v2/metrics"
+	"github.com/luraproject/lura/v2/proxy/plugin"
+	"github.com/luraproject/lura/v2/schedule"
+	"github.com/luraproject/lura/v2/script"
+	"github.com/luraproject/lura/v2/sd"
+	"github.com/luraproject/lura/v2/sd/outlier"
+	"github.com/luraproject/lura/v2/sd/slowstart"
+	"github.com/luraproject/lura/v2/sd/zone"
+	"github.com/luraproject/lura/v2/telemetry"
+	"github.com/luraproject/lura/v2/tracing"
+	"github.com/luraproject/lura/v2/transport/http/client"
+	"github.com/luraproject/lura/v2/transport/http/client/graphql"
+	"github.com/luraproject/lura/v2/transport/http/client/oauth2"
+	"github.com/luraproject/lura/v2/transport/http/client/soap"
+	"github.com/luraproject/lura/v2/transport/queue"
+	"github.com/luraproject/lura/v2/wasm"
+)
+
+// ExplainPath is the path of the admin endpoint serving the execution plans. The routers
+// register it when the debug mode is enabled
+const ExplainPath = "/__explain"
+
+// Plan describes the pipe the default factory builds for an endpoint. The middlewares are listed
+// from the outermost to the innermost one
+type Plan struct {
+	Endpoint        string        `json:"endpoint"`
+	Method          string        `json:"method"`
+	Timeout         string        `json:"timeout"`
+	CacheTTL        string        `json:"cache_ttl"`
+	OutputEncoding  string        `json:"output_encoding"`
+	ConcurrentCalls int           `json:"concurrent_calls"`
+	RequestClass    string        `json:"request_class"`
+	Middlewares     []string      `json:"middlewares"`
+	Merge           *MergePlan    `json:"merge,omitempty"`
+	Backends        []BackendPlan `json:"backends"`
+}
+
+// MergePlan describes how the responses of the backends are merged
+type MergePlan struct {
+	Sequential      bool   `json:"sequential"`
+	Combiner        string `json:"combiner"`
+	MaxParallel     int    `json:"max_parallel,omitempty"`
+	PartialResponse string `json:"partial_response,omitempty"`
+	Budget          string `json:"budget,omitempty"`
+}
+
+// BackendPlan describes the pipe of a backend
+type BackendPlan struct {
+	URLPattern       string   `json:"url_pattern"`
+	Method           string   `json:"method"`
+	Hosts            []string `json:"hosts"`
+	PrimaryHosts     []string `json:"primary_hosts,omitempty"`
+	SD               string   `json:"sd"`
+	Balancer         string   `json:"balancer"`
+	Encoding         string   `json:"encoding"`
+	Timeout          string   `json:"timeout"`
+	ConcurrentCalls  int      `json:"concurrent_calls"`
+	RequestClass     string   `json:"request_class"`
+	Variant          string   `json:"variant,omitempty"`
+	DependsOn        []int    `json:"depends_on,omitempty"`
+	Shadow           bool     `json:"shadow"`
+	ShadowSampleRate float64  `json:"shadow_sample_rate,omitempty"`
+	DualWrite        bool     `json:"dual_write"`
+	InternalEndpoint string   `json:"internal_endpoint,omitempty"`
+	Static           string   `json:"static,omitempty"`
+	Queue            string   `json:"queue,omitempty"`
+	StatusHandler    string   `json:"status_handler"`
+	ClientTLS        bool     `json:"client_tls"`
+	Middlewares      []string `json:"middlewares"`
+	Manipulations    []string `json:"manipulations"`
+}
+
+// Explain returns the execution plan of the endpoint, as composed by the default factory
+func Explain(cfg *config.EndpointConfig) Plan {
+	p := Plan{
+		Endpoint:        cfg.Endpoint,
+		Method:          strings.ToUpper(cfg.Method),
+		Timeout:         cfg.Timeout.String(),
+		CacheTTL:        cfg.CacheTTL.String(),
+		OutputEncoding:  cfg.OutputEncoding,
+		ConcurrentCalls: cfg.ConcurrentCalls,
+		RequestClass:    NewRequestClassifier(cfg.ExtraConfig)(cfg.Method).String(),
+		Middlewares:     []string{},
+		Backends:        make([]BackendPlan, 0, len(cfg.Backend)),
+	}
+
+	if _, ok := metaheaders.EndpointConfig(cfg); ok {
+		p.Middlewares = append(p.Middlewares, "meta-headers")
+	}
+	if telemetry.Enabled() {
+		p.Middlewares = append(p.Middlewares, "telemetry")
+	}
+	if _, ok, _ := budget.CostGetter(cfg.ExtraConfig); ok {
+		p.Middlewares = append(p.Middlewares, "budget")
+	}
+	if _, ok, _ := schedule.ConfigGetter(cfg.ExtraConfig); ok {
+		p.Middlewares = append(p.Middlewares, "schedule")
+	}
+	if _, ok := getCacheMiddlewareCfg(cfg); ok {
+		p.Middlewares = append(p.Middlewares, "cache")
+	}
+	if _, ok := getStaticMiddlewareCfg(cfg.ExtraConfig); ok {
+		p.Middlewares = append(p.Middlewares, "static")
+	}
+	if _, ok, _ := masking.ConfigGetter(cfg.ExtraConfig); ok {
+		p.Middlewares = append(p.Middlewares, "masking")
+	}
+	if _, ok := wasm.ConfigGetter(cfg.ExtraConfig); ok {
+		p.Middlewares = append(p.Middlewares, "wasm")
+	}
+	if _, ok := script.ConfigGetter(cfg.ExtraConfig); ok {
+		p.Middlewares = append(p.Middlewares, "script")
+	}
+	if names := pluginNames(cfg.ExtraConfig); len(names) > 0 {
+		p.Middlewares = append(p.Middlewares, "plugin("+strings.Join(names, ", ")+")")
+	}
+	if _, ok, _ := getCookiePolicy(cfg.ExtraConfig); ok {
+		p.Middlewares = append(p.Middlewares, "cookies")
+	}
+	if c, ok, err := jsonschema.ConfigGetter(cfg.ExtraConfig); ok && err == nil {
+		p.Middlewares = append(p.Middlewares, "response-validation("+c.Mode+")")
+	}
+	if variants, err := getVariants(cfg); err == nil && len(variants) > 0 {
+		names := make([]string, len(variants))
+		for i, v := range variants {
+			names[i] = v.name
+		}
+		p.Middlewares = append(p.Middlewares, "variants("+strings.Join(names, ", ")+")")
+	}
+
+	if len(cfg.Backend) > 1 {
+		p.Middlewares = append(p.Middlewares, "flatmap")
+		if path, ok := getLiftPath(cfg.ExtraConfig); ok {
+			p.Middlewares = append(p.Middlewares, "lift("+path+")")
+		}
+		if _, ok := tracing.GetGlobal(); ok {
+			p.Middlewares = append(p.Middlewares, "tracing")
+		}
+		p.Middlewares = append(p.Middlewares, "merge")
+		p.Merge = &MergePlan{
+			Sequential: shouldRunSequentialMerger(cfg),
+			Combiner:   getResponseCombinerName(cfg.ExtraConfig),
+		}
+		if policy, err := getPartialResponsePolicy(cfg); err == nil {
+			p.Merge.PartialResponse = policy.String()
+		}
+		p.Merge.MaxParallel = getMaxParallel(cfg.ExtraConfig)
+		if b, err := getSequentialBudget(cfg); err == nil && b != nil && p.Merge.Sequential {
+			p.Merge.Budget = b.String()
+		}
+	}
+
+	var deps [][]int
+	if len(cfg.Backend) > 1 && !shouldRunSequentialMerger(cfg) {
+		deps, _ = getDependencies(cfg.Backend)
+	}
+	for i, b := range cfg.Backend {
+		bp := explainBackend(b)
+		if deps != nil {
+			bp.DependsOn = deps[i]
+		}
+		p.Backends = append(p.Backends, bp)
+	}
+	return p
+}
+
+func explainBackend(b *config.Backend) BackendPlan {
+	bp := BackendPlan{
+		URLPattern:      b.URLPattern,
+		Method:          strings.ToUpper(b.Method),
+		Hosts:           b.Host,
+		SD:              b.SD,
+		Balancer:        "random",
+		Encoding:        b.Encoding,
+		Timeout:         b.Timeout.String(),
+		ConcurrentCalls: b.ConcurrentCalls,
+		RequestClass:    NewRequestClassifier(b.ExtraConfig)(b.Method).String(),
+		StatusHandler:   "default",
+		ClientTLS:       b.ClientTLS != nil,
+		Middlewares:     []string{"request-builder"},
+		Manipulations:   []string{},
+	}
+	if _, ok, _ := schedule.ConfigGetter(b.ExtraConfig); ok {
+		bp.Middlewares = append([]string{"schedule"}, bp.Middlewares...)
+	}
+	if isCollapsedBackend(b) && b.Encoding != encoding.NOOP {
+		bp.Middlewares = append([]string{"collapse"}, bp.Middlewares...)
+	}
+	if _, ok := metrics.GetGlobal(); ok {
+		bp.Middlewares = append([]string{"metrics"}, bp.Middlewares...)
+	}
+	if _, ok := tracing.GetGlobal(); ok {
+		bp.Middlewares = append([]string{"tracing"}, bp.Middlewares...)
+	}
+	if metaheaders.RecordsBackends() {
+		bp.Middlewares = append([]string{"meta-headers"}, bp.Middlewares...)
+	}
+	if bp.SD == "" {
+		bp.SD = "static"
+	}
+	if runtime.GOMAXPROCS(-1) == 1 {
+		bp.Balancer = "round-robin"
+	}
+	if name, ok := getBalancerName(b); ok {
+		if _, ok := sd.GetBalancerRegister().Get(name); ok {
+			bp.Balancer = name
+		}
+	}
+	bp.Variant = getBackendVariant(b)
+	_, bp.Shadow = isShadowBackend(b)
+	if bp.Shadow {
+		bp.ShadowSampleRate = shadowSampleRate(b)
+	}
+	_, bp.DualWrite = isDualWriteBackend(b)
+	bp.InternalEndpoint, _ = getInternalEndpoint(b)
+	if s, ok := getStaticBackendCfg(b); ok {
+		bp.Static = "response"
+		if s.fallback {
+			bp.Static = "fallback"
+		}
+	}
+	if opt, err := queue.GetOptions(b.ExtraConfig); err == nil {
+		bp.Queue = opt.Driver
+	}
+	if c, ok := consistency.BackendConfigGetter(b.ExtraConfig); ok {
+		bp.PrimaryHosts = c.PrimaryHosts
+	}
+
+	if e, ok := b.ExtraConfig[client.Namespace].(map[string]interface{}); ok {
+		if c, err := client.ParseStatusCodesConfig(b.ExtraConfig); err == nil && c != nil {
+			bp.StatusHandler = "status-codes(" + c.Mode + ")"
+		} else if v, ok := e["return_error_details"].(string); ok && v != "" {
+			bp.StatusHandler = "detailed(" + v + ")"
+		} else if v, ok := e["return_error_code"].(bool); ok && v {
+			bp.StatusHandler = "error-code"
+		}
+	}
+
+	if c, ok, err := getPaginationConfig(b.ExtraConfig); ok && err == nil {
+		bp.Middlewares = append(bp.Middlewares, "pagination("+c.mode+")")
+	}
+	if b.ConcurrentCalls > 1 {
+		bp.Middlewares = append(bp.Middlewares, fmt.Sprintf("concurrent(%d)", b.ConcurrentCalls))
+	}
+	if c, ok, err := zone.ConfigGetter(b.ExtraConfig); ok && err == nil {
+		bp.Middlewares = append(bp.Middlewares, "zone-aware("+c.Zone+")")
+	}
+	if c, ok, err := outlier.ConfigGetter(b.ExtraConfig); ok && err == nil {
+		bp.Middlewares = append(bp.Middlewares, fmt.Sprintf("outlier-detection(%d)", c.ConsecutiveErrors))
+	}
+	if c, ok, err := slowstart.ConfigGetter(b.ExtraConfig); ok && err == nil {
+		bp.Middlewares = append(bp.Middlewares, "slow-start("+c.Window.String()+")")
+	}
+	if c, ok, err := getHedgingCfg(b); ok && err == nil {
+		bp.Middlewares = append(bp.Middlewares, "hedging("+c.delay.String()+")")
+	}
+	if _, ok := debugtoken.GetGlobal(); ok && len(b.Host)+len(bp.PrimaryHosts) > 0 {
+		bp.Middlewares = append(bp.Middlewares, "debug-host")
+	}
+	if c, ok, err := getCanaryCfg(b); ok && err == nil {
+		groups := make([]string, len(c.groups))
+		for i, g := range c.groups {
+			groups[i] = fmt.Sprintf("%s:%d", g.name, g.weight)
+		}
+		bp.Middlewares = append(bp.Middlewares, "canary("+strings.Join(groups, ", ")+")")
+	}
+	bp.Middlewares = append(bp.Middlewares, "load-balancer")
+	if c, ok, err := client.HostConfigGetter(b.ExtraConfig); ok && err == nil {
+		bp.Middlewares = append(bp.Middlewares, "host("+c.Mode+")")
+	}
+	if len(b.QueryStringsToPass) > 0 {
+		bp.Middlewares = append(bp.Middlewares, "filter-query-strings")
+	}
+	if len(b.HeadersToPass) > 0 {
+		bp.Middlewares = append(bp.Middlewares, "filter-headers")
+	}
+	if _, err := soap.GetOptions(b.ExtraConfig); err == nil {
+		bp.Middlewares = append(bp.Middlewares, "soap")
+	}
+	if _, err := graphql.GetOptions(b.ExtraConfig); err == nil {
+		bp.Middlewares = append(bp.Middlewares, "graphql")
+	}
+	if _, ok, _ := masking.ConfigGetter(b.ExtraConfig); ok {
+		bp.Middlewares = append(bp.Middlewares, "masking")
+	}
+	if _, ok := wasm.ConfigGetter(b.ExtraConfig); ok {
+		bp.Middlewares = append(bp.Middlewares, "wasm")
+	}
+	if _, ok := script.ConfigGetter(b.ExtraConfig); ok {
+		bp.Middlewares = append(bp.Middlewares, "script")
+	}
+	if names := pluginNames(b.ExtraConfig); len(names) > 0 {
+		bp.Middlewares = append(bp.Middlewares, "plugin("+strings.Join(names, ", ")+")")
+	}
+	if _, ok := oauth2.ConfigGetter(b.ExtraConfig); ok {
+		bp.Middlewares = append(bp.Middlewares, "oauth2")
+	}
+	if c, ok, err := bulkhead.ConfigGetter(b.ExtraConfig); ok && err == nil {
+		bp.Middlewares = append(bp.Middlewares, fmt.Sprintf("bulkhead(%d)", c.MaxConcurrent))
+	}
+	if c, ok, err := jsonschema.ConfigGetter(b.ExtraConfig); ok && err == nil {
+		bp.Middlewares = append(bp.Middlewares, "response-validation("+c.Mode+")")
+	}
+
+	if b.Target != "" {
+		bp.Manipulations = append(bp.Manipulations, "target("+b.Target+")")
+	}
+	if b.IsCollection {
+		bp.Manipulations = append(bp.Manipulations, "collection")
+	}
+	if len(b.AllowList) > 0 {
+		bp.Manipulations = append(bp.Manipulations, "allow("+strings.Join(b.AllowList, ", ")+")")
+	}
+	if len(b.DenyList) > 0 {
+		bp.Manipulations = append(bp.Manipulations, "deny("+strings.Join(b.DenyList, ", ")+")")
+	}
+	if len(b.Mapping) > 0 {
+		bp.Manipulations = append(bp.Manipulations, fmt.Sprintf("mapping(%d)", len(b.Mapping)))
+	}
+	if b.Group != "" {
+		bp.Manipulations = append(bp.Manipulations, "group("+b.Group+")")
+	}
+	if e, ok := b.ExtraConfig[Namespace].(map[string]interface{}); ok {
+		if _, ok := e[flatmapKey]; ok {
+			bp.Manipulations = append(bp.Manipulations, "flatmap")
+		}
+		if prefix, ok := e[collisionPrefixKey].(string); ok && prefix != "" {
+			bp.Manipulations = append(bp.Manipulations, "collision-prefix("+prefix+")")
+		}
+	}
+	return bp
+}
+
+func pluginNames(e config.ExtraConfig) []string {
+	cfg, ok := e[plugin.Namespace].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	raw, _ := cfg["name"].([]interface{})
+	names := make([]string, 0, len(raw))
+	for _, n := range raw {
+		if s, ok := n.(string); ok {
+			names = append(names, s)
+		}
+	}
+	return names
+}
+
+// BackendRequest describes the request a backend receives
+type BackendRequest struct {
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Headers map[string][]string `json:"headers"`
+}
+
+// ExplainRequest returns the requests the backends of the endpoint would receive for the request
+// built by the router, without sending them. The first host of every backend stands for the ones
+// selected by its balancer, and the headers added by the sequential proxy are not included
+func ExplainRequest(cfg *config.EndpointConfig, r *Request) []BackendRequest {
+	reqs := make([]BackendRequest, 0, len(cfg.Backend))
+	for _, b := range cfg.Backend {
+		req := r.Clone()
+		if enc, ok, err := getPathEncoding(b.ExtraConfig); ok && err == nil {
+			req.GeneratePathWithEncoding(b.URLPattern, enc)
+		} else {
+			req.GeneratePath(b.URLPattern)
+		}
+		if len(b.QueryStringsToPass) > 0 {
+			req.Query = config.NewParamsFilter(b.QueryStringsToPass).Filter(req.Query)
+		}
+		if len(b.HeadersToPass) > 0 {
+			declared := b.HeadersToPass
+			if b.Encoding == encoding.NOOP {
+				declared = config.WithRangeHeaders(declared)
+			}
+			req.Headers = config.NewHeadersFilter(declared).Filter(req.Headers)
+		}
+
+		u := req.Path
+		if len(b.Host) > 0 {
+			u = b.Host[0] + u
+		}
+		if len(req.Query) > 0 {
+			sep := "?"
+			if strings.Contains(u, "?") {
+				sep = "&"
+			}
+			u += sep + req.Query.Encode()
+		}
+		reqs = append(reqs, BackendRequest{
+			Method:  strings.ToUpper(b.Method),
+			URL:     u,
+			Headers: req.Headers,
+		})
+	}
+	return reqs
+}
+
+// String returns a human readable representation of the plan
+func (p Plan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s (timeout: %s, cache_ttl: %s, encoding: %s)\n", p.Method, p.Endpoint, p.Timeout, p.CacheTTL, p.OutputEncoding)
+	fmt.Fprintf(&b, "  middlewares: %s\n", strings.Join(p.Middlewares, " -> "))
+	if p.Merge != nil {
+		fmt.Fprintf(&b, "  merge: sequential=%t combiner=%s\n", p.Merge.Sequential, p.Merge.Combiner)
+		if p.Merge.MaxParallel > 0 {
+			fmt.Fprintf(&b, "  max parallel calls: %d\n", p.Merge.MaxParallel)
+		}
+		if p.Merge.PartialResponse != "" {
+			fmt.Fprintf(&b, "  partial responses: %s\n", p.Merge.PartialResponse)
+		}
+		if p.Merge.Budget != "" {
+			fmt.Fprintf(&b, "  budget: %s\n", p.Merge.Budget)
+		}
+	}
+	for i, bp := range p.Backends {
+		fmt.Fprintf(&b, "  backend #%d: %s %s (hosts: %s, sd: %s, balancer: %s, encoding: %s, timeout: %s)\n",
+			i, bp.Method, bp.URLPattern, strings.Join(bp.Hosts, ", "), bp.SD, bp.Balancer, bp.Encoding, bp.Timeout)
+		fmt.Fprintf(&b, "    middlewares: %s\n", strings.Join(bp.Middlewares, " -> "))
+		if len(bp.DependsOn) > 0 {
+			fmt.Fprintf(&b, "    depends on: %v\n", bp.DependsOn)
+		}
+		if len(bp.Manipulations) > 0 {
+			fmt.Fprintf(&b, "    manipulations: %s\n", strings.Join(bp.Manipulations, ", "))
+		}
+	}
+	return b.String()
+}
+
+// ExplainHandler returns a http handler serving the plans of the endpoints of the service.
+// The endpoint and method query string params filter the returned plans. The plans are built
+// when the handler is created, so they describe the components registered by the router
+// building it
+func ExplainHandler(cfg config.ServiceConfig) http.Handler {
+	all := make([]Plan, len(cfg.Endpoints))
+	for i, e := range cfg.Endpoints {
+		all[i] = Explain(e)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := r.URL.Query().Get("endpoint")
+		method := strings.ToUpper(r.URL.Query().Get("method"))
+		plans := []Plan{}
+		for i, e := range cfg.Endpoints {
+			if endpoint != "" && e.Endpoint != endpoint {
+				continue
+			}
+			if method != "" && strings.ToUpper(e.Method) != method {
+				continue
+			}
+			plans = append(plans, all[i])
+		}
+		if endpoint != "" && len(plans) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(plans)
+	})
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestExplain(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint:       "/users/{id}",
+		Method:         "get",
+		Timeout:        2 * time.Second,
+		CacheTTL:       time.Minute,
+		OutputEncoding: "json",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				isSequentialKey: true,
+			},
+		},
+		Backend: []*config.Backend{
+			{
+				URLPattern:      "/users/{{.Id}}",
+				Method:          "GET",
+				Host:            []string{"http://a"},
+				Encoding:        "json",
+				Timeout:         time.Second,
+				HeadersToPass:   []string{"Authorization"},
+				AllowList:       []string{"id", "name"},
+				Group:           "user",
+				ConcurrentCalls: 3,
+			},
+			{
+				URLPattern: "/posts",
+				Method:     "GET",
+				Host:       []string{"http://b"},
+				Encoding:   "json",
+				Timeout:    time.Second,
+				Target:     "data",
+				ExtraConfig: config.ExtraConfig{
+					Namespace: map[string]interface{}{
+						flatmapKey: []interface{}{},
+					},
+				},
+			},
+		},
+	}
+
+	p := Explain(cfg)
+
+	if p.Method != "GET" || p.Timeout != "2s" || p.CacheTTL != "1m0s" {
+		t.Errorf("unexpected plan: %+v", p)
+	}
+	if strings.Join(p.Middlewares, ",") != "flatmap,merge" {
+		t.Errorf("unexpected middlewares: %v", p.Middlewares)
+	}
+	if p.Merge == nil || !p.Merge.Sequential {
+		t.Errorf("unexpected merge: %+v", p.Merge)
+	}
+	if len(p.Backends) != 2 {
+		t.Fatalf("unexpected number of backends: %d", len(p.Backends))
+	}
+
+	b0 := p.Backends[0]
+	if strings.Join(b0.Middlewares, ",") != "request-builder,concurrent(3),load-balancer,filter-headers" {
+		t.Errorf("unexpected backend middlewares: %v", b0.Middlewares)
+	}
+	if strings.Join(b0.Manipulations, ",") != "allow(id, name),group(user)" {
+		t.Errorf("unexpected backend manipulations: %v", b0.Manipulations)
+	}
+	if b0.SD != "static" || b0.StatusHandler != "default" {
+		t.Errorf("unexpected backend plan: %+v", b0)
+	}
+
+	b1 := p.Backends[1]
+	if strings.Join(b1.Manipulations, ",") != "target(data),flatmap" {
+		t.Errorf("unexpected backend manipulations: %v", b1.Manipulations)
+	}
+
+	if s := p.String(); !strings.Contains(s, "GET /users/{id}") || !strings.Contains(s, "backend #1: GET /posts") {
+		t.Errorf("unexpected string representation:\n%s", s)
+	}
+}
+
+func TestExplainHandler(t *testing.T) {
+	cfg := config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/a", Method: "GET", Backend: []*config.Backend{{URLPattern: "/a"}}},
+			{Endpoint: "/a", Method: "POST", Backend: []*config.Backend{{URLPattern: "/a"}}},
+			{Endpoint: "/b", Method: "GET", Backend: []*config.Backend{{URLPattern: "/b"}}},
+		},
+	}
+	h := ExplainHandler(cfg)
+
+	for _, tc := range []struct {
+		query  string
+		status int
+		plans  int
+	}{
+		{query: "", status: http.StatusOK, plans: 3},
+		{query: "?endpoint=/a", status: http.StatusOK, plans: 2},
+		{query: "?endpoint=/a&method=post", status: http.StatusOK, plans: 1},
+		{query: "?endpoint=/unknown", status: http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", ExplainPath+tc.query, http.NoBody))
+		if w.Code != tc.status {
+			t.Errorf("%s: unexpected status code: %d", tc.query, w.Code)
+			continue
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		var plans []Plan
+		if err := json.Unmarshal(w.Body.Bytes(), &plans); err != nil {
+			t.Errorf("%s: %s", tc.query, err.Error())
+			continue
+		}
+		if len(plans) != tc.plans {
+			t.Errorf("%s: unexpected number of plans: %d", tc.query, len(plans))
+		}
+	}
+}
+
+func TestExplainRequest(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint: "/users/{id}",
+		Method:   "GET",
+		Backend: []*config.Backend{
+			{
+				URLPattern:         "/users/{{.Id}}?full=true",
+				Method:             "get",
+				Host:               []string{"http://users.local"},
+				QueryStringsToPass: []string{"fields"},
+				HeadersToPass:      []string{"X-Tenant"},
+			},
+			{
+				URLPattern: "/orders?user={{.Id}}",
+				Method:     "GET",
+				Host:       []string{"http://orders.local"},
+			},
+		},
+	}
+	reqs := ExplainRequest(cfg, &Request{
+		Method:  "GET",
+		Path:    "/users/42",
+		Params:  map[string]string{"Id": "42"},
+		Query:   map[string][]string{"fields": {"name"}, "debug": {"1"}},
+		Headers: map[string][]string{"X-Tenant": {"acme"}, "User-Agent": {"curl"}},
+	})
+	if len(reqs) != 2 {
+		t.Fatalf("unexpected requests: %v", reqs)
+	}
+	if reqs[0].Method != "GET" || reqs[0].URL != "http://users.local/users/42?full=true&fields=name" {
+		t.Errorf("unexpected request: %+v", reqs[0])
+	}
+	if len(reqs[0].Headers) != 1 || reqs[0].Headers["X-Tenant"][0] != "acme" {
+		t.Errorf("unexpected headers: %v", reqs[0].Headers)
+	}
+	if reqs[1].URL != "http://orders.local/orders?user=42&debug=1&fields=name" {
+		t.Errorf("unexpected request: %+v", reqs[1])
+	}
+	if len(reqs[1].Headers) != 2 {
+		t.Errorf("unexpected headers: %v", reqs[1].Headers)
+	}
+}
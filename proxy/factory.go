@@ -3,9 +3,18 @@
 package proxy
 
 import (
+	"fmt"
+
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/script"
 	"github.com/luraproject/lura/v2/sd"
+	"github.com/luraproject/lura/v2/sd/healthcheck"
+	"github.com/luraproject/lura/v2/sd/outlier"
+	"github.com/luraproject/lura/v2/sd/slowstart"
+	"github.com/luraproject/lura/v2/sd/zone"
+	"github.com/luraproject/lura/v2/transport/queue"
+	"github.com/luraproject/lura/v2/wasm"
 )
 
 // Factory creates proxies based on the received endpoint configuration.
@@ -45,40 +54,80 @@ func NewDefaultFactory(backendFactory BackendFactory, logger logging.Logger) Fac
 // NewDefaultFactoryWithSubscriber returns a default proxy factory with the injected proxy builder,
 // logger and subscriber factory
 func NewDefaultFactoryWithSubscriber(backendFactory BackendFactory, logger logging.Logger, sF sd.SubscriberFactory) Factory {
-	return defaultFactory{backendFactory, logger, sF}
+	return NewDefaultFactoryWithRegistry(backendFactory, logger, sF, NewDefaultMiddlewareRegistry())
+}
+
+// NewDefaultFactoryWithRegistry returns a default proxy factory composing the middlewares of the
+// registry instead of the built-in ones. The registry should be created with
+// NewDefaultMiddlewareRegistry, so the custom middlewares are placed relative to the built-in ones
+func NewDefaultFactoryWithRegistry(backendFactory BackendFactory, logger logging.Logger, sF sd.SubscriberFactory, r *MiddlewareRegistry) Factory {
+	return defaultFactory{backendFactory, logging.Module(logger, "proxy"), sF, r}
 }
 
 type defaultFactory struct {
 	backendFactory    BackendFactory
 	logger            logging.Logger
 	subscriberFactory sd.SubscriberFactory
+	middlewares       *MiddlewareRegistry
 }
 
 // New implements the Factory interface
 func (pf defaultFactory) New(cfg *config.EndpointConfig) (p Proxy, err error) {
-	switch len(cfg.Backend) {
-	case 0:
-		err = ErrNoBackends
-	case 1:
-		p, err = pf.newSingle(cfg)
-	default:
-		p, err = pf.newMulti(cfg)
+	inheritStatusCodes(pf.logger, cfg)
+	inheritPathEncoding(pf.logger, cfg)
+	if err = validateExtensions(cfg); err != nil {
+		return
 	}
-	if err != nil {
+	if p, err = pf.newVariants(cfg); err != nil {
 		return
 	}
 
-	p = NewPluginMiddleware(pf.logger, cfg)(p)
-	p = NewStaticMiddleware(pf.logger, cfg)(p)
+	mw, err := pf.middlewares.EndpointMiddleware(pf.logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+	p = mw(p)
+	registerInternalEndpoint(cfg, p)
 	return
 }
 
+// validateExtensions returns an error if the endpoint or its backends declare scripts or wasm
+// modules that can not be executed by the registered engines and runtimes
+func validateExtensions(cfg *config.EndpointConfig) error {
+	extras := []config.ExtraConfig{cfg.ExtraConfig}
+	for _, b := range cfg.Backend {
+		extras = append(extras, b.ExtraConfig)
+	}
+	for _, e := range extras {
+		if err := script.Validate(e); err != nil {
+			return err
+		}
+		if err := wasm.Validate(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pf defaultFactory) newBackends(cfg *config.EndpointConfig) (Proxy, error) {
+	switch len(cfg.Backend) {
+	case 0:
+		return nil, ErrNoBackends
+	case 1:
+		return pf.newSingle(cfg)
+	default:
+		return pf.newMulti(cfg)
+	}
+}
+
 func (pf defaultFactory) newMulti(cfg *config.EndpointConfig) (p Proxy, err error) {
 	backendProxy := make([]Proxy, len(cfg.Backend))
 	for i, backend := range cfg.Backend {
 		backendProxy[i] = pf.newStack(backend)
 	}
 	p = NewMergeDataMiddleware(pf.logger, cfg)(backendProxy...)
+	p = NewMergeTracingMiddleware(pf.logger, cfg)(p)
+	p = NewLiftMiddleware(pf.logger, cfg)(p)
 	p = NewFlatmapMiddleware(pf.logger, cfg)(p)
 	return
 }
@@ -88,15 +137,85 @@ func (pf defaultFactory) newSingle(cfg *config.EndpointConfig) (Proxy, error) {
 }
 
 func (pf defaultFactory) newStack(backend *config.Backend) (p Proxy) {
+	if endpoint, ok := getInternalEndpoint(backend); ok {
+		return pf.newInternalStack(backend, endpoint)
+	}
+	if cfg, ok := getStaticBackendCfg(backend); ok && !cfg.fallback {
+		return pf.newStaticStack(backend)
+	}
+	if _, ok := backend.ExtraConfig[queue.Namespace]; ok {
+		return pf.newQueueStack(backend)
+	}
 	p = pf.backendFactory(backend)
+	p = NewBackendResponseValidationMiddleware(pf.logger, backend)(p)
+	p = NewBackendBulkheadMiddleware(pf.logger, backend)(p)
+	p = NewBackendOAuth2Middleware(pf.logger, backend)(p)
 	p = NewBackendPluginMiddleware(pf.logger, backend)(p)
+	p = NewBackendScriptMiddleware(pf.logger, backend)(p)
+	p = NewBackendWASMMiddleware(pf.logger, backend)(p)
+	p = NewBackendMaskingMiddleware(pf.logger, backend)(p)
 	p = NewGraphQLMiddleware(pf.logger, backend)(p)
+	p = NewSOAPMiddleware(pf.logger, backend)(p)
 	p = NewFilterHeadersMiddleware(pf.logger, backend)(p)
 	p = NewFilterQueryStringsMiddleware(pf.logger, backend)(p)
-	p = NewLoadBalancedMiddlewareWithSubscriberAndLogger(pf.logger, pf.subscriberFactory(backend))(p)
+	p = NewBackendHostMiddleware(pf.logger, backend)(p)
+	subscriber := healthcheck.NewSubscriber(pf.logger, backend, pf.subscriberFactory(backend))
+	detector, ok := outlier.New(pf.logger, backend, subscriber)
+	if ok {
+		subscriber = detector
+	}
+	subscriber = zone.NewSubscriber(pf.logger, backend, subscriber)
+	subscriber = slowstart.NewSubscriber(pf.logger, backend, subscriber)
+	lb := NewBackendLoadBalancedMiddleware(pf.logger, backend, subscriber)
+	lb = NewOutlierDetectionMiddleware(detector, lb)
+	lb = NewCanaryMiddleware(pf.logger, backend, lb)
+	lb = NewDebugHostMiddleware(pf.logger, backend, lb)
+	lb = NewHedgingMiddleware(pf.logger, backend, lb)
+	p = NewReadYourWritesMiddleware(pf.logger, backend, lb)(p)
 	if backend.ConcurrentCalls > 1 {
 		p = NewConcurrentMiddlewareWithLogger(pf.logger, backend)(p)
 	}
+	mw, err := pf.middlewares.BackendMiddleware(pf.logger, backend)
+	if err != nil {
+		pf.logger.Error(fmt.Sprintf("[BACKEND: %s] Unable to order the middlewares: %s", backend.URLPattern, err.Error()))
+		return
+	}
+	return mw(p)
+}
+
+// newStaticStack returns the pipe of a backend answering with a static document, so only the
+// instrumentation middlewares are added
+func (pf defaultFactory) newStaticStack(backend *config.Backend) (p Proxy) {
+	p = NewStaticBackendProxy(pf.logger, backend)
+	p = NewBackendMetricsMiddleware(pf.logger, backend)(p)
+	p = NewBackendTracingMiddleware(pf.logger, backend)(p)
+	p = NewBackendMetaHeadersMiddleware(pf.logger, backend)(p)
+	return
+}
+
+// newQueueStack returns the pipe of a backend publishing the requests to a broker. The requests
+// do not reach a service, so the balancing and the http related middlewares are not added
+func (pf defaultFactory) newQueueStack(backend *config.Backend) (p Proxy) {
+	p = NewQueuePublisherProxy(pf.logger, backend)
+	p = NewBackendBulkheadMiddleware(pf.logger, backend)(p)
+	p = NewFilterQueryStringsMiddleware(pf.logger, backend)(p)
+	p = NewRequestBuilderMiddlewareWithLogger(pf.logger, backend)(p)
+	p = NewBackendMetricsMiddleware(pf.logger, backend)(p)
+	p = NewBackendTracingMiddleware(pf.logger, backend)(p)
+	p = NewBackendMetaHeadersMiddleware(pf.logger, backend)(p)
+	return
+}
+
+// newInternalStack returns the pipe of a backend referencing another endpoint. The requests do
+// not leave the process, so the transport related middlewares are not added
+func (pf defaultFactory) newInternalStack(backend *config.Backend, endpoint string) (p Proxy) {
+	p = NewInternalEndpointProxy(pf.logger, backend, endpoint)
+	p = NewFilterHeadersMiddleware(pf.logger, backend)(p)
+	p = NewFilterQueryStringsMiddleware(pf.logger, backend)(p)
 	p = NewRequestBuilderMiddlewareWithLogger(pf.logger, backend)(p)
+	p = NewBackendMetricsMiddleware(pf.logger, backend)(p)
+	p = NewBackendTracingMiddleware(pf.logger, backend)(p)
+	p = NewBackendMetaHeadersMiddleware(pf.logger, backend)(p)
+	p = NewBackendMetadataMiddleware(pf.logger, backend)(p)
 	return
 }
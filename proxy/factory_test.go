@@ -142,3 +142,62 @@ func TestNewDefaultFactory_ok(t *testing.T) {
 		t.Errorf("The proxy middleware propagated an unexpected error: %v\n", response)
 	}
 }
+
+func TestNewDefaultFactoryWithRegistry(t *testing.T) {
+	var trace []string
+	tracer := func(name string) Middleware {
+		return func(next ...Proxy) Proxy {
+			return func(ctx context.Context, r *Request) (*Response, error) {
+				trace = append(trace, name)
+				return next[0](ctx, r)
+			}
+		}
+	}
+	r := NewDefaultMiddlewareRegistry()
+	if err := r.Register(MiddlewareDefinition{
+		Name:     "custom",
+		After:    []string{"response-headers"},
+		Before:   []string{"enrichment"},
+		Endpoint: func(_ logging.Logger, _ *config.EndpointConfig) Middleware { return tracer("custom") },
+		Backend:  func(_ logging.Logger, _ *config.Backend) Middleware { return tracer("custom-backend") },
+	}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	backend := func(_ *config.Backend) Proxy {
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			trace = append(trace, "backend")
+			return &Response{IsComplete: true, Data: map[string]interface{}{}}, nil
+		}
+	}
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/foo",
+		Backend:  []*config.Backend{{URLPattern: "/foo", Host: []string{"http://example.com"}}},
+	}
+	p, err := NewDefaultFactoryWithRegistry(backend, logging.NoOp, sd.FixedSubscriberFactory, r).New(endpoint)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := p(context.Background(), &Request{Path: "/foo", Params: map[string]string{}}); err != nil {
+		t.Error(err)
+	}
+	if s := strings.Join(trace, ","); s != "custom,custom-backend,backend" {
+		t.Errorf("unexpected execution order: %s", s)
+	}
+
+	// the middlewares breaking the order of the built-in ones are rejected
+	if err := r.Register(MiddlewareDefinition{
+		Name:     "loop",
+		After:    []string{"enrichment"},
+		Before:   []string{"meta-headers"},
+		Endpoint: func(_ logging.Logger, _ *config.EndpointConfig) Middleware { return tracer("loop") },
+	}); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := NewDefaultFactoryWithRegistry(backend, logging.NoOp, sd.FixedSubscriberFactory, r).New(endpoint); err == nil {
+		t.Error("expecting an error")
+	}
+}
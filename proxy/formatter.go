@@ -30,6 +30,9 @@ type entityFormatter struct {
 	Prefix         string
 	PropertyFilter propertyFilter
 	Mapping        map[string]string
+	// the paths of the target and the group are split once, instead of on every response
+	targetPath []string
+	groupPath  []string
 }
 
 // NewEntityFormatter creates an entity formatter with the received backend definition
@@ -54,13 +57,23 @@ func NewEntityFormatter(remote *config.Backend) EntityFormatter {
 		Prefix:         remote.Group,
 		PropertyFilter: propertyFilter,
 		Mapping:        sanitizedMappings,
+		targetPath:     splitPath(remote.Target),
+		groupPath:      splitPath(remote.Group),
 	}
 }
 
+// splitPath returns the parts of a dotted path, or nil if the path is empty
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
 // Format implements the EntityFormatter interface
 func (e entityFormatter) Format(entity Response) Response {
-	if e.Target != "" {
-		extractTarget(e.Target, &entity)
+	if len(e.targetPath) > 0 {
+		extractTargetPath(e.targetPath, &entity)
 	}
 	if len(entity.Data) > 0 {
 		e.PropertyFilter(&entity)
@@ -73,14 +86,31 @@ func (e entityFormatter) Format(entity Response) Response {
 			}
 		}
 	}
-	if e.Prefix != "" {
-		entity.Data = map[string]interface{}{e.Prefix: entity.Data}
+	if len(e.groupPath) > 0 {
+		entity.Data = groupDataPath(e.groupPath, entity.Data)
 	}
 	return entity
 }
 
+// groupData nests the data under the group. The groups with dots (a.b.c) declare the path of
+// nested objects to create
+func groupData(group string, data map[string]interface{}) map[string]interface{} {
+	return groupDataPath(strings.Split(group, "."), data)
+}
+
+func groupDataPath(parts []string, data map[string]interface{}) map[string]interface{} {
+	for i := len(parts) - 1; i >= 0; i-- {
+		data = map[string]interface{}{parts[i]: data}
+	}
+	return data
+}
+
 func extractTarget(target string, entity *Response) {
-	for _, part := range strings.Split(target, ".") {
+	extractTargetPath(strings.Split(target, "."), entity)
+}
+
+func extractTargetPath(parts []string, entity *Response) {
+	for _, part := range parts {
 		if tmp, ok := entity.Data[part]; ok {
 			entity.Data, ok = tmp.(map[string]interface{})
 			if !ok {
@@ -227,9 +257,11 @@ func newDenylistingFilter(blacklist []string) propertyFilter {
 const flatmapKey = "flatmap_filter"
 
 type flatmapFormatter struct {
-	Target string
-	Prefix string
-	Ops    []flatmapOp
+	Target     string
+	Prefix     string
+	Ops        []flatmapOp
+	targetPath []string
+	groupPath  []string
 }
 
 type flatmapOp struct {
@@ -239,14 +271,14 @@ type flatmapOp struct {
 
 // Format implements the EntityFormatter interface
 func (e flatmapFormatter) Format(entity Response) Response {
-	if e.Target != "" {
-		extractTarget(e.Target, &entity)
+	if len(e.targetPath) > 0 {
+		extractTargetPath(e.targetPath, &entity)
 	}
 
 	e.processOps(&entity)
 
-	if e.Prefix != "" {
-		entity.Data = map[string]interface{}{e.Prefix: entity.Data}
+	if len(e.groupPath) > 0 {
+		entity.Data = groupDataPath(e.groupPath, entity.Data)
 	}
 	return entity
 }
@@ -306,9 +338,11 @@ func newFlatmapFormatter(cfg config.ExtraConfig, target, group string) *flatmapF
 					return nil
 				}
 				return &flatmapFormatter{
-					Target: target,
-					Prefix: group,
-					Ops:    ops,
+					Target:     target,
+					Prefix:     group,
+					Ops:        ops,
+					targetPath: splitPath(target),
+					groupPath:  splitPath(group),
 				}
 			}
 		}
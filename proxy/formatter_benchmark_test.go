@@ -309,3 +309,25 @@ func BenchmarkEntityFormatter_flatmap(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkEntityFormatter_targetAndGroup(b *testing.B) {
+	f := NewEntityFormatter(&config.Backend{
+		Target: "data.user",
+		Group:  "account.owner",
+		Mapping: map[string]string{
+			"id": "user_id",
+		},
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.Format(Response{
+			Data: map[string]interface{}{
+				"data": map[string]interface{}{
+					"user": map[string]interface{}{"id": 42, "name": "supu"},
+				},
+			},
+			IsComplete: true,
+		})
+	}
+}
@@ -6,15 +6,42 @@ import (
 	"context"
 
 	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
 	"github.com/luraproject/lura/v2/logging"
 )
 
 // NewFilterHeadersMiddleware returns a middleware with or without a header filtering
-// proxy wrapping the next element (depending on the configuration).
+// proxy wrapping the next element (depending on the configuration). The headers added by the
+// sequential proxy to the backend requests are always allowed, as the range headers of the no-op
+// backends. The backends declaring the wildcard get all the headers but the denied ones
 func NewFilterHeadersMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
 	if len(remote.HeadersToPass) == 0 {
 		return emptyMiddlewareFallback(logger)
 	}
+	declared := remote.HeadersToPass
+	if remote.Encoding == encoding.NOOP {
+		declared = config.WithRangeHeaders(declared)
+	}
+	filter := config.NewHeadersFilter(declared)
+	if filter.All {
+		return newWildcardFilterMiddleware(logger, remote, filter, "NewFilterHeadersMiddleware", func(r *Request) *Request {
+			headers := filter.Filter(r.Headers)
+			if len(headers) == len(r.Headers) {
+				return r
+			}
+			res := r.Clone()
+			res.Headers = headers
+			return &res
+		})
+	}
+	headersToPass := filter.Allowed
+	if seq := getSequentialHeaders(remote.ExtraConfig); len(seq) > 0 {
+		headersToPass = make([]string, 0, len(filter.Allowed)+len(seq))
+		headersToPass = append(headersToPass, filter.Allowed...)
+		for k := range seq {
+			headersToPass = append(headersToPass, k)
+		}
+	}
 
 	return func(next ...Proxy) Proxy {
 		if len(next) > 1 {
@@ -27,7 +54,7 @@ func NewFilterHeadersMiddleware(logger logging.Logger, remote *config.Backend) M
 				return nextProxy(ctx, request)
 			}
 			numHeadersToPass := 0
-			for _, v := range remote.HeadersToPass {
+			for _, v := range headersToPass {
 				if _, ok := request.Headers[v]; ok {
 					numHeadersToPass++
 				}
@@ -44,7 +71,7 @@ func NewFilterHeadersMiddleware(logger logging.Logger, remote *config.Backend) M
 			// that should be done at an upper level (so the approach is the same
 			// for non filtered parallel requests).
 			newHeaders := make(map[string][]string, numHeadersToPass)
-			for _, v := range remote.HeadersToPass {
+			for _, v := range headersToPass {
 				if values, ok := request.Headers[v]; ok {
 					newHeaders[v] = values
 				}
@@ -61,3 +88,21 @@ func NewFilterHeadersMiddleware(logger logging.Logger, remote *config.Backend) M
 		}
 	}
 }
+
+// newWildcardFilterMiddleware returns a middleware applying the filter function to the requests
+// of the backends declaring the wildcard. Nothing is filtered if the backend does not deny params
+func newWildcardFilterMiddleware(logger logging.Logger, remote *config.Backend, filter config.ParamsFilter, name string, f func(*Request) *Request) Middleware {
+	if len(filter.Denied) == 0 {
+		return emptyMiddlewareFallback(logger)
+	}
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: %s only accepts 1 proxy, got %d", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, name, len(next))
+			return nil
+		}
+		nextProxy := next[0]
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			return nextProxy(ctx, f(request))
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
 	"github.com/luraproject/lura/v2/logging"
 )
 
@@ -82,6 +83,39 @@ func TestNewFilterHeadersMiddleware(t *testing.T) {
 	}
 }
 
+func TestNewFilterHeadersMiddleware_noopRange(t *testing.T) {
+	mw := NewFilterHeadersMiddleware(
+		logging.NoOp,
+		&config.Backend{
+			HeadersToPass: []string{"X-This-Shall-Pass"},
+			Encoding:      encoding.NOOP,
+		},
+	)
+
+	var receivedReq *Request
+	prxy := mw(func(ctx context.Context, req *Request) (*Response, error) {
+		receivedReq = req
+		return nil, nil
+	})
+
+	prxy(context.Background(), &Request{
+		Headers: map[string][]string{
+			"X-This-Shall-Pass":    {"tupu"},
+			"X-You-Shall-Not-Pass": {"Balrog"},
+			"Range":                {"bytes=0-99"},
+			"If-Range":             {`"abc"`},
+		},
+	})
+
+	if len(receivedReq.Headers) != 3 {
+		t.Errorf("unexpected headers: %v", receivedReq.Headers)
+		return
+	}
+	if v := receivedReq.Headers["Range"]; len(v) != 1 || v[0] != "bytes=0-99" {
+		t.Errorf("unexpected range header: %v", v)
+	}
+}
+
 func TestNewFilterHeadersMiddlewareBlockAll(t *testing.T) {
 	mw := NewFilterHeadersMiddleware(
 		logging.NoOp,
@@ -148,3 +182,39 @@ func TestNewFilterHeadersMiddlewareAllowAll(t *testing.T) {
 		return
 	}
 }
+
+func TestNewFilterHeadersMiddlewareWildcard(t *testing.T) {
+	mw := NewFilterHeadersMiddleware(
+		logging.NoOp,
+		&config.Backend{
+			HeadersToPass: []string{"*", "!X-You-Shall-Not-Pass"},
+		},
+	)
+
+	var receivedReq *Request
+	prxy := mw(func(ctx context.Context, req *Request) (*Response, error) {
+		receivedReq = req
+		return nil, nil
+	})
+
+	sentReq := &Request{
+		Params: map[string]string{},
+		Headers: map[string][]string{
+			"X-This-Shall-Pass":    {"tupu", "supu"},
+			"X-This-Also-Passes":   {"foo"},
+			"X-You-Shall-Not-Pass": {"Balrog"},
+		},
+	}
+
+	prxy(context.Background(), sentReq)
+
+	if len(receivedReq.Headers) != 2 {
+		t.Errorf("unexpected headers: %v", receivedReq.Headers)
+	}
+	if _, ok := receivedReq.Headers["X-You-Shall-Not-Pass"]; ok {
+		t.Error("the denied header should not pass")
+	}
+	if len(sentReq.Headers) != 3 {
+		t.Error("the headers of the received request should not be modified")
+	}
+}
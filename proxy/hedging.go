@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/debugtoken"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	hedgingKey = "hedging"
+	// hedgingHostPicks is the number of times the balancer is asked for a host not used yet by
+	// the request before giving up the hedge
+	hedgingHostPicks = 3
+)
+
+type hedgingConfig struct {
+	delay     time.Duration
+	maxHedges int
+}
+
+// getHedgingCfg parses the hedging config of the backend:
+//
+//	"github.com/devopsfaith/krakend/proxy": {
+//		"hedging": {
+//			"delay": "50ms",
+//			"max_hedges": 1
+//		}
+//	}
+func getHedgingCfg(remote *config.Backend) (hedgingConfig, bool, error) {
+	cfg := hedgingConfig{maxHedges: 1}
+	e, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	tmp, ok := e[hedgingKey].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	delay, _ := tmp["delay"].(string)
+	d, err := time.ParseDuration(delay)
+	if err != nil || d <= 0 {
+		return cfg, true, fmt.Errorf("hedging: invalid delay %q", delay)
+	}
+	cfg.delay = d
+	if v, ok := tmp["max_hedges"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 1 || n != float64(int(n)) {
+			return cfg, true, fmt.Errorf("hedging: invalid max_hedges %v", v)
+		}
+		cfg.maxHedges = int(n)
+	}
+	return cfg, true, nil
+}
+
+var errHedgeSameHost = errors.New("hedging: no other host available")
+
+type hedgingHostsKey struct{}
+
+// hedgingHosts tracks the hosts already receiving a request
+type hedgingHosts struct {
+	mu   sync.Mutex
+	used map[string]struct{}
+}
+
+// claim returns false if the host is already receiving the request
+func (h *hedgingHosts) claim(host string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.used[host]; ok {
+		return false
+	}
+	h.used[host] = struct{}{}
+	return true
+}
+
+type hedgingResult struct {
+	resp *Response
+	err  error
+}
+
+// NewHedgingMiddleware creates proxy middleware sending a copy of the retry-safe requests to a
+// different host of the backend when the response does not arrive before the hedging delay, so a
+// single slow replica does not drive the tail latency. The first successful response is returned
+// and the rest of the requests are cancelled. It returns the load balancer received when the
+// backend does not declare the hedging config
+func NewHedgingMiddleware(logger logging.Logger, remote *config.Backend, lb Middleware) Middleware {
+	cfg, ok, err := getHedgingCfg(remote)
+	if !ok {
+		return lb
+	}
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Hedging]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	if err != nil {
+		logger.Error(logPrefix, "Ignoring the hedging config:", err.Error())
+		return lb
+	}
+	logger.Debug(logPrefix, fmt.Sprintf("Sending up to %d hedged requests after %s", cfg.maxHedges, cfg.delay))
+	classify := NewRequestClassifier(remote.ExtraConfig)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewHedgingMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		plain := lb(next[0])
+		balanced := lb(func(ctx context.Context, r *Request) (*Response, error) {
+			if h, ok := ctx.Value(hedgingHostsKey{}).(*hedgingHosts); ok && r.URL != nil && !h.claim(r.URL.Host) {
+				return nil, errHedgeSameHost
+			}
+			return next[0](ctx, r)
+		})
+		pick := func(ctx context.Context, r *Request) (*Response, error) {
+			var err error
+			for i := 0; i < hedgingHostPicks; i++ {
+				var resp *Response
+				resp, err = balanced(ctx, r)
+				if err != errHedgeSameHost {
+					return resp, err
+				}
+			}
+			return nil, err
+		}
+
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			if !classify(r.Method).IsRetrySafe() {
+				return plain(ctx, r)
+			}
+			if _, ok := debugtoken.TargetHost(ctx); ok {
+				return plain(ctx, r)
+			}
+			localCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			localCtx = context.WithValue(localCtx, hedgingHostsKey{}, &hedgingHosts{used: map[string]struct{}{}})
+
+			results := make(chan hedgingResult, cfg.maxHedges+1)
+			launch := func() {
+				req := CloneRequest(r)
+				go func() {
+					resp, err := pick(localCtx, req)
+					results <- hedgingResult{resp, err}
+				}()
+			}
+
+			launch()
+			sent, pending := 1, 1
+			timer := time.NewTimer(cfg.delay)
+			defer timer.Stop()
+
+			var failed error
+			for {
+				select {
+				case res := <-results:
+					pending--
+					if res.err == nil && res.resp != nil {
+						return res.resp, nil
+					}
+					if res.err != errHedgeSameHost || failed == nil {
+						failed = res.err
+					}
+					if pending == 0 {
+						if failed == nil {
+							failed = errNullResult
+						}
+						return nil, failed
+					}
+				case <-timer.C:
+					if sent > cfg.maxHedges {
+						continue
+					}
+					logger.Debug(logPrefix, "Sending a hedged request")
+					launch()
+					sent++
+					pending++
+					timer.Reset(cfg.delay)
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+func hedgingBackend(cfg map[string]interface{}) *config.Backend {
+	return &config.Backend{
+		URLPattern: "/users",
+		Method:     "GET",
+		Host:       []string{"http://a", "http://b"},
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{hedgingKey: cfg},
+		},
+	}
+}
+
+func TestGetHedgingCfg(t *testing.T) {
+	if _, ok, _ := getHedgingCfg(&config.Backend{}); ok {
+		t.Error("the config should not be found")
+	}
+	cfg, ok, err := getHedgingCfg(hedgingBackend(map[string]interface{}{"delay": "20ms"}))
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if cfg.delay != 20*time.Millisecond || cfg.maxHedges != 1 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	for _, c := range []map[string]interface{}{
+		{},
+		{"delay": "-1s"},
+		{"delay": "10ms", "max_hedges": 0.0},
+		{"delay": "10ms", "max_hedges": 1.5},
+	} {
+		if _, _, err := getHedgingCfg(hedgingBackend(c)); err == nil {
+			t.Errorf("%v: expecting an error", c)
+		}
+	}
+}
+
+func TestNewHedgingMiddleware(t *testing.T) {
+	backend := hedgingBackend(map[string]interface{}{"delay": "10ms"})
+	lb := NewRoundRobinLoadBalancedMiddlewareWithSubscriberAndLogger(logging.NoOp, sd.FixedSubscriber{"http://slow", "http://fast"})
+	var slow, cancelled int32
+	p := NewHedgingMiddleware(logging.NoOp, backend, lb)(func(ctx context.Context, r *Request) (*Response, error) {
+		if r.URL.Host == "slow" {
+			atomic.AddInt32(&slow, 1)
+			<-ctx.Done()
+			atomic.AddInt32(&cancelled, 1)
+			return nil, ctx.Err()
+		}
+		return &Response{IsComplete: true, Data: map[string]interface{}{"host": r.URL.Host}}, nil
+	})
+
+	for i := 0; i < 4; i++ {
+		resp, err := p(context.Background(), &Request{Method: "GET", Path: "/users"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Data["host"] != "fast" {
+			t.Errorf("unexpected response: %v", resp.Data)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	if s, c := atomic.LoadInt32(&slow), atomic.LoadInt32(&cancelled); s == 0 || s != c {
+		t.Errorf("the slow requests should be cancelled. have: %d/%d", c, s)
+	}
+}
+
+func TestNewHedgingMiddleware_sameHost(t *testing.T) {
+	backend := hedgingBackend(map[string]interface{}{"delay": "5ms", "max_hedges": 2.0})
+	lb := NewLoadBalancedMiddlewareWithSubscriberAndLogger(logging.NoOp, sd.FixedSubscriber{"http://only"})
+	var calls int32
+	errSlow := errors.New("slow")
+	p := NewHedgingMiddleware(logging.NoOp, backend, lb)(func(_ context.Context, _ *Request) (*Response, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(30 * time.Millisecond)
+		return nil, errSlow
+	})
+
+	if _, err := p(context.Background(), &Request{Method: "GET", Path: "/users"}); err != errSlow {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if c := atomic.LoadInt32(&calls); c != 1 {
+		t.Errorf("the hedges should not reach the same host. have %d calls", c)
+	}
+}
+
+func TestNewHedgingMiddleware_unsafe(t *testing.T) {
+	backend := hedgingBackend(map[string]interface{}{"delay": "1ms"})
+	lb := NewRoundRobinLoadBalancedMiddlewareWithSubscriberAndLogger(logging.NoOp, sd.FixedSubscriber{"http://a", "http://b"})
+	var calls int32
+	p := NewHedgingMiddleware(logging.NoOp, backend, lb)(func(_ context.Context, _ *Request) (*Response, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &Response{IsComplete: true}, nil
+	})
+
+	if _, err := p(context.Background(), &Request{Method: "POST", Path: "/users"}); err != nil {
+		t.Fatal(err)
+	}
+	if c := atomic.LoadInt32(&calls); c != 1 {
+		t.Errorf("the unsafe requests should not be hedged. have %d calls", c)
+	}
+}
+
+func TestExplain_hedging(t *testing.T) {
+	backend := hedgingBackend(map[string]interface{}{"delay": "50ms"})
+	plan := Explain(&config.EndpointConfig{Endpoint: "/users", Method: "GET", Backend: []*config.Backend{backend}})
+	if m := strings.Join(plan.Backends[0].Middlewares, ","); !strings.Contains(m, "hedging(50ms),load-balancer") {
+		t.Errorf("unexpected middlewares: %s", m)
+	}
+}
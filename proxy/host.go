@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+// NewBackendHostMiddleware creates proxy middleware resolving the Host header of the requests to
+// the backend, as declared in its extra config. The preserve mode sends the host of the request
+// received by the router, the fixed mode sends the declared value and the param mode sends the
+// value of a param of the request. The requests without a host to send keep the host of their URL
+func NewBackendHostMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	cfg, ok, err := client.HostConfigGetter(remote.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Host]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return emptyMiddlewareFallback(logger)
+	}
+	logger.Debug(logPrefix, "Setting the Host header in", cfg.Mode, "mode")
+
+	var resolve func(r *Request) string
+	switch cfg.Mode {
+	case client.HostFixed:
+		resolve = func(_ *Request) string { return cfg.Value }
+	case client.HostParam:
+		param := strings.ToUpper(cfg.Param[:1]) + cfg.Param[1:]
+		resolve = func(r *Request) string { return r.Params[param] }
+	default:
+		resolve = func(r *Request) string {
+			if vs := r.Headers["X-Forwarded-Host"]; len(vs) > 0 {
+				return vs[0]
+			}
+			return ""
+		}
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: NewBackendHostMiddleware only accepts 1 proxy, got %d",
+				remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			if host := resolve(r); host != "" {
+				ctx = client.WithHost(ctx, host)
+			}
+			return next[0](ctx, r)
+		}
+	}
+}
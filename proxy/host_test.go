@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+func TestNewBackendHostMiddleware(t *testing.T) {
+	for _, tc := range []struct {
+		cfg      map[string]interface{}
+		expected string
+	}{
+		{cfg: map[string]interface{}{"mode": "preserve"}, expected: "gateway.example.com"},
+		{cfg: map[string]interface{}{"mode": "fixed", "value": "api.example.com"}, expected: "api.example.com"},
+		{cfg: map[string]interface{}{"mode": "param", "param": "tenant"}, expected: "acme.example.com"},
+		{cfg: map[string]interface{}{"mode": "param", "param": "unknown"}},
+	} {
+		backend := &config.Backend{ExtraConfig: config.ExtraConfig{
+			client.Namespace: map[string]interface{}{"host_header": tc.cfg},
+		}}
+		var host string
+		p := NewBackendHostMiddleware(logging.NoOp, backend)(func(ctx context.Context, _ *Request) (*Response, error) {
+			host, _ = client.HostFromContext(ctx)
+			return &Response{}, nil
+		})
+		p(context.Background(), &Request{
+			Params:  map[string]string{"Tenant": "acme.example.com"},
+			Headers: map[string][]string{"X-Forwarded-Host": {"gateway.example.com"}},
+		})
+		if host != tc.expected {
+			t.Errorf("%v: unexpected host %q", tc.cfg, host)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/encoding"
@@ -29,11 +30,19 @@ func CustomHTTPProxyFactory(cf client.HTTPClientFactory) BackendFactory {
 	}
 }
 
-// NewHTTPProxy creates a http proxy with the injected configuration, HTTPClientFactory and Decoder
+// NewHTTPProxy creates a http proxy with the injected configuration, HTTPClientFactory and Decoder.
+// The TLS, transport, gRPC and SOAP options of the backends, and the features decorating their
+// request executors, are added by the factory of the proxy/backend package
 func NewHTTPProxy(remote *config.Backend, cf client.HTTPClientFactory, decode encoding.Decoder) Proxy {
 	return NewHTTPProxyWithHTTPExecutor(remote, client.DefaultHTTPRequestExecutor(cf), decode)
 }
 
+// failingExecutor returns an executor failing with the error of an invalid backend config, so
+// the misconfiguration is not hidden behind the default client settings
+func failingExecutor(err error) client.HTTPRequestExecutor {
+	return func(_ context.Context, _ *http.Request) (*http.Response, error) { return nil, err }
+}
+
 // NewHTTPProxyWithHTTPExecutor creates a http proxy with the injected configuration, HTTPRequestExecutor and Decoder
 func NewHTTPProxyWithHTTPExecutor(remote *config.Backend, re client.HTTPRequestExecutor, dec encoding.Decoder) Proxy {
 	if remote.Encoding == encoding.NOOP {
@@ -46,8 +55,9 @@ func NewHTTPProxyWithHTTPExecutor(remote *config.Backend, re client.HTTPRequestE
 }
 
 // NewHTTPProxyDetailed creates a http proxy with the injected configuration, HTTPRequestExecutor,
-// Decoder and HTTPResponseParser
-func NewHTTPProxyDetailed(_ *config.Backend, re client.HTTPRequestExecutor, ch client.HTTPStatusHandler, rp HTTPResponseParser) Proxy {
+// Decoder and HTTPResponseParser. The errors of the executor and the invalid status codes are
+// returned as client.BackendError, describing the failing backend
+func NewHTTPProxyDetailed(remote *config.Backend, re client.HTTPRequestExecutor, ch client.HTTPStatusHandler, rp HTTPResponseParser) Proxy {
 	return func(ctx context.Context, request *Request) (*Response, error) {
 		requestToBackend, err := http.NewRequest(strings.ToTitle(request.Method), request.URL.String(), request.Body)
 		if err != nil {
@@ -67,6 +77,7 @@ func NewHTTPProxyDetailed(_ *config.Backend, re client.HTTPRequestExecutor, ch c
 			}
 		}
 
+		start := time.Now()
 		resp, err := re(ctx, requestToBackend)
 		if requestToBackend.Body != nil {
 			requestToBackend.Body.Close()
@@ -78,9 +89,11 @@ func NewHTTPProxyDetailed(_ *config.Backend, re client.HTTPRequestExecutor, ch c
 		default:
 		}
 		if err != nil {
-			return nil, err
+			return nil, client.NewBackendError(remote, requestToBackend, 0, time.Since(start), err)
 		}
+		captureMetadata(ctx, resp)
 
+		status := resp.StatusCode
 		resp, err = ch(ctx, resp)
 		if err != nil {
 			if t, ok := err.(responseError); ok {
@@ -91,7 +104,7 @@ func NewHTTPProxyDetailed(_ *config.Backend, re client.HTTPRequestExecutor, ch c
 					Metadata: Metadata{StatusCode: t.StatusCode()},
 				}, nil
 			}
-			return nil, err
+			return nil, client.NewBackendError(remote, requestToBackend, status, time.Since(start), err)
 		}
 
 		return rp(ctx, resp)
@@ -99,7 +112,8 @@ func NewHTTPProxyDetailed(_ *config.Backend, re client.HTTPRequestExecutor, ch c
 }
 
 // NewRequestBuilderMiddleware creates a proxy middleware that parses the request params received
-// from the outer layer and generates the path to the backend endpoints
+// from the outer layer and generates the path to the backend endpoints. The params are encoded as
+// declared by the url_encoding option of the backend, if any
 var NewRequestBuilderMiddleware = func(remote *config.Backend) Middleware {
 	return newRequestBuilderMiddleware(logging.NoOp, remote)
 }
@@ -109,6 +123,11 @@ func NewRequestBuilderMiddlewareWithLogger(logger logging.Logger, remote *config
 }
 
 func newRequestBuilderMiddleware(l logging.Logger, remote *config.Backend) Middleware {
+	enc, ok, err := getPathEncoding(remote.ExtraConfig)
+	if err != nil {
+		l.Error(fmt.Sprintf("[BACKEND: %s %s -> %s][URLEncoding] Inserting the raw params: %s", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, err.Error()))
+		ok = false
+	}
 	return func(next ...Proxy) Proxy {
 		if len(next) > 1 {
 			l.Fatal("too many proxies for this %s %s -> %s proxy middleware: newRequestBuilderMiddleware only accepts 1 proxy, got %d", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
@@ -116,7 +135,11 @@ func newRequestBuilderMiddleware(l logging.Logger, remote *config.Backend) Middl
 		}
 		return func(ctx context.Context, request *Request) (*Response, error) {
 			r := request.Clone()
-			r.GeneratePath(remote.URLPattern)
+			if ok {
+				r.GeneratePathWithEncoding(remote.URLPattern, enc)
+			} else {
+				r.GeneratePath(remote.URLPattern)
+			}
 			r.Method = remote.Method
 			return next[0](ctx, &r)
 		}
@@ -189,7 +189,7 @@ func TestNewHTTPProxy_badStatusCode(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(10)*time.Millisecond)
 	defer cancel()
 	response, err := httpProxy(&backend)(ctx, &request)
-	if err == nil || err != client.ErrInvalidStatusCode {
+	if !errors.Is(err, client.ErrInvalidStatusCode) {
 		t.Errorf("The proxy didn't propagate the backend error: %s\n", err)
 	}
 	if response != nil {
@@ -358,7 +358,7 @@ func TestNewHTTPProxy_requestKo(t *testing.T) {
 		t.Error("The proxy didn't return the expected error")
 		return
 	}
-	if err != expectedError {
+	if !errors.Is(err, expectedError) || err.Error() != expectedError.Error() {
 		t.Errorf("The proxy returned an unexpected error: %s\n", err.Error())
 		return
 	}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/register"
+)
+
+const (
+	internalEndpointKey = "internal_endpoint"
+	maxInternalDepth    = 8
+)
+
+// ErrInternalLoop is returned when the internal dispatches of a request are nested too deep,
+// usually because the endpoints reference each other
+var ErrInternalLoop = errors.New("too many nested internal endpoint calls")
+
+// ErrUnknownInternalEndpoint is returned when a backend references an endpoint not built by the
+// default factory
+var ErrUnknownInternalEndpoint = errors.New("unknown internal endpoint")
+
+var internalEndpoints = register.NewUntyped()
+
+type internalEndpoint struct {
+	segments []string
+	proxy    Proxy
+}
+
+type internalDepthKey struct{}
+
+// registerInternalEndpoint makes the pipe of the endpoint available to the backends referencing it
+func registerInternalEndpoint(cfg *config.EndpointConfig, p Proxy) {
+	internalEndpoints.Register(
+		strings.ToUpper(cfg.Method)+" "+cfg.Endpoint,
+		internalEndpoint{segments: strings.Split(strings.Trim(cfg.Endpoint, "/"), "/"), proxy: p},
+	)
+}
+
+// getInternalEndpoint returns the endpoint referenced by the backend, if any
+func getInternalEndpoint(remote *config.Backend) (string, bool) {
+	e, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	v, ok := e[internalEndpointKey].(string)
+	return v, ok && v != ""
+}
+
+// NewInternalEndpointProxy returns a proxy dispatching the requests to the pipe of another
+// endpoint of the service, without a network hop, so the aggregations can be layered:
+//
+//	"backend": [{
+//		"url_pattern": "/users/{id}",
+//		"extra_config": {
+//			"github.com/devopsfaith/krakend/proxy": {
+//				"internal_endpoint": "/users/{id}"
+//			}
+//		}
+//	}]
+//
+// The path generated from the url pattern is matched against the referenced endpoint to extract
+// its params, and the method of the backend selects the endpoint among the ones sharing the
+// path. The requests skip the router, so the handlers of the referenced endpoint (auth, rate
+// limits...) are not applied
+func NewInternalEndpointProxy(logger logging.Logger, remote *config.Backend, endpoint string) Proxy {
+	key := strings.ToUpper(remote.Method) + " " + endpoint
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Internal]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	logger.Debug(logPrefix, "Dispatching the requests to the endpoint", key)
+
+	return func(ctx context.Context, request *Request) (*Response, error) {
+		depth, _ := ctx.Value(internalDepthKey{}).(int)
+		if depth >= maxInternalDepth {
+			return nil, ErrInternalLoop
+		}
+		v, ok := internalEndpoints.Get(key)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownInternalEndpoint, key)
+		}
+		target := v.(internalEndpoint)
+
+		path, rawQuery := request.Path, ""
+		if i := strings.IndexByte(path, '?'); i >= 0 {
+			path, rawQuery = path[:i], path[i+1:]
+		}
+		params, ok := matchInternalPath(target.segments, path)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s does not match %s", ErrUnknownInternalEndpoint, path, key)
+		}
+		query := url.Values{}
+		for k, vs := range request.Query {
+			query[k] = vs
+		}
+		if rawQuery != "" {
+			if extra, err := url.ParseQuery(rawQuery); err == nil {
+				for k, vs := range extra {
+					query[k] = vs
+				}
+			}
+		}
+
+		return target.proxy(context.WithValue(ctx, internalDepthKey{}, depth+1), &Request{
+			Method:  strings.ToUpper(remote.Method),
+			Path:    path,
+			Query:   query,
+			Body:    request.Body,
+			Params:  params,
+			Headers: request.Headers,
+		})
+	}
+}
+
+// matchInternalPath extracts the params of the endpoint from the path. The params are named as
+// the routers do
+func matchInternalPath(segments []string, path string) (map[string]string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != len(segments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, s := range segments {
+		var name string
+		switch {
+		case len(s) > 2 && s[0] == '{' && s[len(s)-1] == '}':
+			name = s[1 : len(s)-1]
+		case len(s) > 1 && s[0] == ':':
+			name = s[1:]
+		default:
+			if s != parts[i] {
+				return nil, false
+			}
+			continue
+		}
+		v, err := url.PathUnescape(parts[i])
+		if err != nil {
+			v = parts[i]
+		}
+		params[strings.ToUpper(name[:1])+name[1:]] = v
+	}
+	return params, true
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewInternalEndpointProxy(t *testing.T) {
+	var received *Request
+	factory := NewDefaultFactory(func(remote *config.Backend) Proxy {
+		return func(_ context.Context, r *Request) (*Response, error) {
+			received = r
+			return &Response{Data: map[string]interface{}{"user": r.Path}, IsComplete: true}, nil
+		}
+	}, logging.NoOp)
+
+	users := &config.EndpointConfig{
+		Endpoint: "/internal/users/{id}",
+		Method:   "GET",
+		Timeout:  time.Second,
+		Backend: []*config.Backend{
+			{URLPattern: "/users/{{.Id}}", Method: "GET", Host: []string{"http://users"}},
+		},
+	}
+	if _, err := factory.New(users); err != nil {
+		t.Fatal(err)
+	}
+
+	profile := &config.EndpointConfig{
+		Endpoint: "/profile/{id}",
+		Method:   "GET",
+		Timeout:  time.Second,
+		Backend: []*config.Backend{
+			{
+				URLPattern: "/internal/users/{{.Id}}?fields=name",
+				Method:     "GET",
+				ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+					internalEndpointKey: "/internal/users/{id}",
+				}},
+			},
+		},
+	}
+	p, err := factory.New(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := p(context.Background(), &Request{Method: "GET", Params: map[string]string{"Id": "42"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["user"] != "/users/42" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if received == nil || received.Params["Id"] != "42" {
+		t.Errorf("unexpected request to the referenced endpoint %+v", received)
+	}
+}
+
+func TestNewInternalEndpointProxy_errors(t *testing.T) {
+	loop := &config.EndpointConfig{Endpoint: "/loop/{id}", Method: "GET"}
+	remote := &config.Backend{URLPattern: "/loop/{{.Id}}", Method: "GET"}
+	p := NewRequestBuilderMiddleware(remote)(NewInternalEndpointProxy(logging.NoOp, remote, loop.Endpoint))
+	registerInternalEndpoint(loop, p)
+
+	if _, err := p(context.Background(), &Request{Params: map[string]string{"Id": "1"}}); !errors.Is(err, ErrInternalLoop) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	unknown := NewInternalEndpointProxy(logging.NoOp, &config.Backend{Method: "POST"}, "/loop/{id}")
+	if _, err := unknown(context.Background(), &Request{Path: "/loop/1"}); !errors.Is(err, ErrUnknownInternalEndpoint) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	mismatch := NewInternalEndpointProxy(logging.NoOp, remote, "/loop/{id}")
+	if _, err := mismatch(context.Background(), &Request{Path: "/other/1"}); !errors.Is(err, ErrUnknownInternalEndpoint) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMatchInternalPath(t *testing.T) {
+	params, ok := matchInternalPath([]string{"users", ":id", "{kind}"}, "/users/a%20b/admin")
+	if !ok || params["Id"] != "a b" || params["Kind"] != "admin" {
+		t.Errorf("unexpected params %v", params)
+	}
+	if _, ok := matchInternalPath([]string{"users", "{id}"}, "/users"); ok {
+		t.Error("the path should not match")
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"time"
 
 	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client"
 )
 
-// NewLoggingMiddleware creates proxy middleware for logging requests and responses
+// NewLoggingMiddleware creates proxy middleware for logging requests and responses. The failures
+// of the backends are logged with their metadata (url, status, elapsed time and retryability)
 func NewLoggingMiddleware(logger logging.Logger, name string) Middleware {
 	logPrefix := "[" + strings.ToUpper(name) + "]"
 	return func(next ...Proxy) Proxy {
@@ -27,7 +29,11 @@ func NewLoggingMiddleware(logger logging.Logger, name string) Middleware {
 
 			logger.Info(logPrefix, "Call to backend took", time.Since(begin).String())
 			if err != nil {
-				logger.Warning(logPrefix, "Call to backend failed:", err.Error())
+				if e, ok := client.AsBackendError(err); ok {
+					logger.Warning(logPrefix, "Call to backend failed:", e.Details())
+				} else {
+					logger.Warning(logPrefix, "Call to backend failed:", err.Error())
+				}
 				return result, err
 			}
 			if result == nil {
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/masking"
+)
+
+// NewMaskingMiddleware creates proxy middleware masking the fields of the endpoint responses
+// with the rules and profiles declared in its extra config
+func NewMaskingMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	return newMaskingMiddleware(logger, endpointConfig.ExtraConfig, "[ENDPOINT: "+endpointConfig.Endpoint+"][Masking]")
+}
+
+// NewBackendMaskingMiddleware creates proxy middleware masking the fields of the backend responses
+// with the rules and profiles declared in its extra config
+func NewBackendMaskingMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Masking]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	return newMaskingMiddleware(logger, remote.ExtraConfig, logPrefix)
+}
+
+func newMaskingMiddleware(logger logging.Logger, e config.ExtraConfig, logPrefix string) Middleware {
+	m, ok, err := masking.ConfigGetter(e)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	if err != nil {
+		// fail closed, so the sensitive fields are never exposed because of a config error
+		logger.Error(logPrefix, err.Error())
+		return func(next ...Proxy) Proxy {
+			if len(next) > 1 {
+				logger.Fatal("too many proxies for this proxy middleware: NewMaskingMiddleware only accepts 1 proxy, got %d", len(next))
+				return nil
+			}
+			return func(_ context.Context, _ *Request) (*Response, error) {
+				return nil, err
+			}
+		}
+	}
+
+	logger.Debug(logPrefix, fmt.Sprintf("Masking %d fields", len(m.Rules())))
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewMaskingMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			resp, err := next[0](ctx, r)
+			if resp != nil && resp.Data != nil {
+				m.Mask(resp.Data)
+			}
+			return resp, err
+		}
+	}
+}
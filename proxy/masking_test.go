@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/masking"
+)
+
+func TestNewMaskingMiddleware(t *testing.T) {
+	masking.SetProfiles(map[string][]masking.Rule{"pii": {{Field: "email", Strategy: masking.Redact}}})
+	defer masking.SetProfiles(map[string][]masking.Rule{})
+
+	cfg := &config.EndpointConfig{
+		Endpoint: "/users",
+		ExtraConfig: config.ExtraConfig{
+			masking.Namespace: map[string]interface{}{"profiles": []interface{}{"pii"}},
+		},
+	}
+	p := NewMaskingMiddleware(logging.NoOp, cfg)(func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{Data: map[string]interface{}{"email": "jane@example.com", "name": "jane"}, IsComplete: true}, nil
+	})
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["email"] != masking.DefaultReplacement || resp.Data["name"] != "jane" {
+		t.Errorf("unexpected data: %v", resp.Data)
+	}
+}
+
+func TestNewBackendMaskingMiddleware_unknownProfile(t *testing.T) {
+	remote := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			masking.Namespace: map[string]interface{}{"profiles": []interface{}{"unknown"}},
+		},
+	}
+	called := false
+	p := NewBackendMaskingMiddleware(logging.NoOp, remote)(func(_ context.Context, _ *Request) (*Response, error) {
+		called = true
+		return &Response{}, nil
+	})
+	if _, err := p(context.Background(), &Request{}); err == nil {
+		t.Error("expecting an error for the unknown profile")
+	}
+	if called {
+		t.Error("the backend should not be called")
+	}
+}
+
+func TestNewMaskingMiddleware_unconfigured(t *testing.T) {
+	mw := NewMaskingMiddleware(logging.NoOp, &config.EndpointConfig{})
+	if mw == nil {
+		t.Fatal("unexpected nil middleware")
+	}
+}
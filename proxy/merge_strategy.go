@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/register"
+)
+
+// MergeStrategy merges the data of a backend response into the data accumulated from the
+// previous backends of the endpoint
+type MergeStrategy func(dst, src map[string]interface{}) error
+
+// The merge strategies available by default. The responses are always merged following the
+// order of the backends in the endpoint config, even when they are requested in parallel
+const (
+	// MergeStrategyDeep merges the nested objects recursively. The rest of the colliding values
+	// are replaced by the ones of the last backend
+	MergeStrategyDeep = "deep"
+	// MergeStrategyConcat merges the nested objects like MergeStrategyDeep and concatenates the
+	// colliding arrays
+	MergeStrategyConcat = "concat"
+	// MergeStrategyLastWins keeps the value of the last backend for every colliding key
+	MergeStrategyLastWins = "last_wins"
+	// MergeStrategyFirstWins keeps the value of the first backend for every colliding key
+	MergeStrategyFirstWins = "first_wins"
+	// MergeStrategyErrorOnConflict keeps the value of the first backend for every colliding key
+	// and fails the merge when the values are not equal
+	MergeStrategyErrorOnConflict = "error_on_conflict"
+)
+
+const mergeStrategyKey = "merge_strategy"
+
+// ErrMergeConflict is the error returned by the MergeStrategyErrorOnConflict strategy when two
+// backends return different values for the same key
+var ErrMergeConflict = errors.New("merge conflict")
+
+var mergeStrategies = initMergeStrategies()
+
+func initMergeStrategies() *register.Untyped {
+	r := register.NewUntyped()
+	r.Register(MergeStrategyDeep, MergeStrategy(deepMerge(false)))
+	r.Register(MergeStrategyConcat, MergeStrategy(deepMerge(true)))
+	r.Register(MergeStrategyLastWins, MergeStrategy(lastWinsMerge))
+	r.Register(MergeStrategyFirstWins, MergeStrategy(firstWinsMerge))
+	r.Register(MergeStrategyErrorOnConflict, MergeStrategy(errorOnConflictMerge))
+	return r
+}
+
+// RegisterMergeStrategy adds a new merge strategy into the internal register
+func RegisterMergeStrategy(name string, s MergeStrategy) {
+	mergeStrategies.Register(name, s)
+}
+
+// getMergeStrategy returns the merge strategy declared by the endpoint, if any
+func getMergeStrategy(extra config.ExtraConfig) (string, MergeStrategy, bool) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return "", nil, false
+	}
+	name, ok := e[mergeStrategyKey].(string)
+	if !ok {
+		return "", nil, false
+	}
+	v, ok := mergeStrategies.Get(name)
+	if !ok {
+		return name, nil, false
+	}
+	s, ok := v.(MergeStrategy)
+	return name, s, ok
+}
+
+func deepMerge(concat bool) MergeStrategy {
+	var merge MergeStrategy
+	merge = func(dst, src map[string]interface{}) error {
+		for k, v := range src {
+			prev, ok := dst[k]
+			if !ok {
+				dst[k] = v
+				continue
+			}
+			switch p := prev.(type) {
+			case map[string]interface{}:
+				if s, ok := v.(map[string]interface{}); ok {
+					merge(p, s)
+					continue
+				}
+			case []interface{}:
+				if s, ok := v.([]interface{}); ok && concat {
+					res := make([]interface{}, 0, len(p)+len(s))
+					dst[k] = append(append(res, p...), s...)
+					continue
+				}
+			}
+			dst[k] = v
+		}
+		return nil
+	}
+	return merge
+}
+
+func lastWinsMerge(dst, src map[string]interface{}) error {
+	for k, v := range src {
+		dst[k] = v
+	}
+	return nil
+}
+
+func firstWinsMerge(dst, src map[string]interface{}) error {
+	for k, v := range src {
+		if _, ok := dst[k]; !ok {
+			dst[k] = v
+		}
+	}
+	return nil
+}
+
+func errorOnConflictMerge(dst, src map[string]interface{}) error {
+	var conflicts []string
+	for k, v := range src {
+		prev, ok := dst[k]
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		if !reflect.DeepEqual(prev, v) {
+			conflicts = append(conflicts, k)
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Strings(conflicts)
+	return fmt.Errorf("%w on the keys %v", ErrMergeConflict, conflicts)
+}
+
+// orderedMergeAccumulator merges the responses with a MergeStrategy following the order of the
+// backends, no matter the order they arrive in
+type orderedMergeAccumulator struct {
+	parts    []*Response
+	received int
+	errs     []error
+	strategy MergeStrategy
+	prefixes []string
+}
+
+func newOrderedMergeAccumulator(total int, s MergeStrategy) *orderedMergeAccumulator {
+	return &orderedMergeAccumulator{
+		parts:    make([]*Response, total),
+		errs:     []error{},
+		strategy: s,
+	}
+}
+
+// Merge adds the response of the next backend
+func (o *orderedMergeAccumulator) Merge(res *Response, err error) {
+	o.MergeAt(o.received, res, err)
+}
+
+// MergeAt adds the response of the i-th backend
+func (o *orderedMergeAccumulator) MergeAt(i int, res *Response, err error) {
+	o.received++
+	if err != nil {
+		o.errs = append(o.errs, err)
+		return
+	}
+	if res == nil {
+		o.errs = append(o.errs, errNullResult)
+		return
+	}
+	o.parts[i] = res
+}
+
+func (o *orderedMergeAccumulator) Result() (*Response, error) {
+	var data *Response
+	isComplete := true
+	for i, part := range o.parts {
+		if part == nil || part.Data == nil {
+			isComplete = false
+			continue
+		}
+		isComplete = isComplete && part.IsComplete
+		if data == nil {
+			data = part
+			continue
+		}
+		src := part.Data
+		if o.prefixes != nil && o.prefixes[i] != "" {
+			src = prefixCollisions(data.Data, src, o.prefixes[i])
+		}
+		if err := o.strategy(data.Data, src); err != nil {
+			o.errs = append(o.errs, err)
+		}
+	}
+	if data == nil {
+		return nil, newMergeError(o.errs)
+	}
+	data.IsComplete = isComplete && len(o.errs) == 0
+	return data, newMergeError(o.errs)
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewMergeDataMiddleware_strategies(t *testing.T) {
+	for _, tc := range []struct {
+		strategy string
+		expected string
+		conflict bool
+	}{
+		{
+			strategy: MergeStrategyDeep,
+			expected: `{"id":1,"items":[3],"user":{"name":"b","role":"admin","since":2020}}`,
+		},
+		{
+			strategy: MergeStrategyConcat,
+			expected: `{"id":1,"items":[1,2,3],"user":{"name":"b","role":"admin","since":2020}}`,
+		},
+		{
+			strategy: MergeStrategyLastWins,
+			expected: `{"id":1,"items":[3],"user":{"name":"b","since":2020}}`,
+		},
+		{
+			strategy: MergeStrategyFirstWins,
+			expected: `{"id":1,"items":[1,2],"user":{"name":"a","role":"admin"}}`,
+		},
+		{
+			strategy: MergeStrategyErrorOnConflict,
+			expected: `{"id":1,"items":[1,2],"user":{"name":"a","role":"admin"}}`,
+			conflict: true,
+		},
+	} {
+		t.Run(tc.strategy, func(t *testing.T) {
+			backend := config.Backend{}
+			endpoint := config.EndpointConfig{
+				Backend:     []*config.Backend{&backend, &backend},
+				Timeout:     time.Second,
+				ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{mergeStrategyKey: tc.strategy}},
+			}
+			first := func(_ context.Context, _ *Request) (*Response, error) {
+				// the first backend answers last, but its data is merged first
+				time.Sleep(20 * time.Millisecond)
+				return &Response{IsComplete: true, Data: map[string]interface{}{
+					"id":    1,
+					"items": []interface{}{1, 2},
+					"user":  map[string]interface{}{"name": "a", "role": "admin"},
+				}}, nil
+			}
+			second := func(_ context.Context, _ *Request) (*Response, error) {
+				return &Response{IsComplete: true, Data: map[string]interface{}{
+					"id":    1,
+					"items": []interface{}{3},
+					"user":  map[string]interface{}{"name": "b", "since": 2020},
+				}}, nil
+			}
+
+			out, err := NewMergeDataMiddleware(logging.NoOp, &endpoint)(first, second)(context.Background(), &Request{})
+			if tc.conflict {
+				if !errors.Is(err.(mergeError).Errors()[0], ErrMergeConflict) {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if out.IsComplete {
+					t.Error("the conflicting responses must be incomplete")
+				}
+			} else if err != nil || !out.IsComplete {
+				t.Errorf("unexpected result. complete: %v, error: %v", out.IsComplete, err)
+			}
+			b, _ := json.Marshal(out.Data)
+			if string(b) != tc.expected {
+				t.Errorf("unexpected data: %s", b)
+			}
+		})
+	}
+}
+
+func TestNewMergeDataMiddleware_sequentialStrategy(t *testing.T) {
+	backend := config.Backend{}
+	endpoint := config.EndpointConfig{
+		Backend: []*config.Backend{&backend, &backend},
+		Timeout: time.Second,
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			mergeStrategyKey: MergeStrategyFirstWins,
+			isSequentialKey:  true,
+		}},
+	}
+	p := NewMergeDataMiddleware(logging.NoOp, &endpoint)(
+		dummyProxy(&Response{Data: map[string]interface{}{"a": 1}, IsComplete: true}),
+		dummyProxy(&Response{Data: map[string]interface{}{"a": 2, "b": 2}, IsComplete: true}),
+	)
+	out, err := p(context.Background(), &Request{Params: map[string]string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Data["a"] != 1 || out.Data["b"] != 2 || !out.IsComplete {
+		t.Errorf("unexpected response %+v", out)
+	}
+}
+
+func TestNewMergeDataMiddleware_customStrategy(t *testing.T) {
+	RegisterMergeStrategy("count", func(dst, src map[string]interface{}) error {
+		dst["count"] = dst["count"].(int) + src["count"].(int)
+		return nil
+	})
+	backend := config.Backend{}
+	endpoint := config.EndpointConfig{
+		Backend:     []*config.Backend{&backend, &backend, &backend},
+		Timeout:     time.Second,
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{mergeStrategyKey: "count"}},
+	}
+	part := func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{Data: map[string]interface{}{"count": 1}, IsComplete: true}, nil
+	}
+	p := NewMergeDataMiddleware(logging.NoOp, &endpoint)(part, part, part)
+	out, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Data["count"] != 3 {
+		t.Errorf("unexpected response %+v", out)
+	}
+}
@@ -5,7 +5,6 @@ package proxy
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
@@ -27,17 +26,72 @@ func NewMergeDataMiddleware(logger logging.Logger, endpointConfig *config.Endpoi
 	}
 	serviceTimeout := time.Duration(85*endpointConfig.Timeout.Nanoseconds()/100) * time.Nanosecond
 	combiner := getResponseCombiner(endpointConfig.ExtraConfig)
+	combinerName := getResponseCombinerName(endpointConfig.ExtraConfig)
 	isSequential := shouldRunSequentialMerger(endpointConfig)
 
+	newAcc := func() mergeAccumulator { return newIncrementalMergeAccumulator(totalBackends, combiner) }
+	prefixes := getCollisionPrefixes(endpointConfig.Backend)
+	strategyName, strategy, ok := getMergeStrategy(endpointConfig.ExtraConfig)
+	if !ok && strategyName == "" && prefixes != nil && combinerName == defaultCombinerName {
+		// the default combiner keeps the values of the last backends too
+		strategyName, strategy, ok = MergeStrategyLastWins, lastWinsMerge, true
+	}
+	if ok {
+		newAcc = func() mergeAccumulator {
+			acc := newOrderedMergeAccumulator(totalBackends, strategy)
+			acc.prefixes = prefixes
+			return acc
+		}
+		combinerName = "strategy " + strategyName
+	} else if strategyName != "" {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][Merge] Unknown merge strategy %s, using the combiner", endpointConfig.Endpoint, strategyName))
+	}
+	if !ok && prefixes != nil {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][Merge] Ignoring the collision prefixes, they are not supported by the combiner %s", endpointConfig.Endpoint, combinerName))
+	}
+	conds, errs := getBackendConditions(endpointConfig)
+	for _, err := range errs {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][Merge] The backend will always be called. %s", endpointConfig.Endpoint, err.Error()))
+	}
+	maxParallel := getMaxParallel(endpointConfig.ExtraConfig)
+	deps, err := getDependencies(endpointConfig.Backend)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][Merge] Ignoring the backend dependencies: %s", endpointConfig.Endpoint, err.Error()))
+	}
+	if deps != nil && isSequential {
+		logger.Warning(fmt.Sprintf("[ENDPOINT: %s][Merge] Ignoring the backend dependencies of the sequential endpoint", endpointConfig.Endpoint))
+		deps = nil
+	}
+	policy, err := getPartialResponsePolicy(endpointConfig)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][Merge] Ignoring the partial response policy: %s", endpointConfig.Endpoint, err.Error()))
+	}
+	budget, err := getSequentialBudget(endpointConfig)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ENDPOINT: %s][Merge] Ignoring the sequential budget: %s", endpointConfig.Endpoint, err.Error()))
+	}
+	if budget != nil && !isSequential {
+		logger.Warning(fmt.Sprintf("[ENDPOINT: %s][Merge] Ignoring the sequential budget of the non sequential endpoint", endpointConfig.Endpoint))
+		budget = nil
+	}
+
 	logger.Debug(
 		fmt.Sprintf(
-			"[ENDPOINT: %s][Merge] Backends: %d, sequential: %t, combiner: %s",
+			"[ENDPOINT: %s][Merge] Backends: %d, sequential: %t, dependencies: %t, max parallel: %d, combiner: %s",
 			endpointConfig.Endpoint,
 			totalBackends,
 			isSequential,
-			getResponseCombinerName(endpointConfig.ExtraConfig),
+			deps != nil,
+			maxParallel,
+			combinerName,
 		),
 	)
+	if policy != nil {
+		logger.Debug(fmt.Sprintf("[ENDPOINT: %s][Merge] Partial response policy: %s", endpointConfig.Endpoint, policy))
+	}
+	if budget != nil {
+		logger.Debug(fmt.Sprintf("[ENDPOINT: %s][Merge] Sequential budget: %s", endpointConfig.Endpoint, budget))
+	}
 
 	return func(next ...Proxy) Proxy {
 		if len(next) != totalBackends {
@@ -53,15 +107,26 @@ func NewMergeDataMiddleware(logger logging.Logger, endpointConfig *config.Endpoi
 			reqClone = CloneRequest
 		}
 
-		if !isSequential {
-			return parallelMerge(reqClone, serviceTimeout, combiner, next...)
+		merge := func(next ...Proxy) Proxy {
+			switch {
+			case isSequential:
+				return sequentialMerge(reqClone, newSequentialSteps(endpointConfig.Backend, conds), serviceTimeout, budget, newAcc, next...)
+			case deps != nil:
+				return dependencyMerge(reqClone, newSequentialSteps(endpointConfig.Backend, conds), deps, maxParallel, serviceTimeout, newAcc, next...)
+			default:
+				return parallelMerge(reqClone, serviceTimeout, maxParallel, newAcc, conds, next...)
+			}
+		}
+
+		if policy == nil {
+			return merge(next...)
 		}
 
-		patterns := make([]string, len(endpointConfig.Backend))
-		for i, b := range endpointConfig.Backend {
-			patterns[i] = b.URLPattern
+		recorded := make([]Proxy, len(next))
+		for i, n := range next {
+			recorded[i] = policy.record(i, endpointConfig.Backend[i], n)
 		}
-		return sequentialMerge(reqClone, patterns, serviceTimeout, combiner, next...)
+		return policy.apply(merge(recorded...))
 	}
 }
 
@@ -84,7 +149,7 @@ func hasUnsafeBackends(cfg *config.EndpointConfig) bool {
 
 	hasOneUnsafe := false
 	for _, b := range cfg.Backend {
-		if m := strings.ToUpper(b.Method); m != http.MethodGet && m != http.MethodHead {
+		if !NewRequestClassifier(cfg.ExtraConfig, b.ExtraConfig)(b.Method).IsSafe() {
 			if hasOneUnsafe {
 				return true
 			}
@@ -94,25 +159,25 @@ func hasUnsafeBackends(cfg *config.EndpointConfig) bool {
 	return false
 }
 
-func parallelMerge(reqCloner func(*Request) *Request, timeout time.Duration, rc ResponseCombiner, next ...Proxy) Proxy {
+func parallelMerge(reqCloner func(*Request) *Request, timeout time.Duration, maxParallel int, newAcc func() mergeAccumulator, conds []*backendCondition, next ...Proxy) Proxy {
 	return func(ctx context.Context, request *Request) (*Response, error) {
 		localCtx, cancel := context.WithTimeout(ctx, timeout)
 
-		parts := make(chan *Response, len(next))
-		failed := make(chan error, len(next))
+		parts := make(chan indexedPart, len(next))
+		sem := newSemaphore(maxParallel)
 
-		for _, n := range next {
-			go requestPart(localCtx, n, reqCloner(request), parts, failed)
+		for i, n := range next {
+			if conds[i].skip(request.Params) {
+				parts <- indexedPart{index: i, response: conds[i].skipped()}
+				continue
+			}
+			go requestBoundedPart(localCtx, sem, i, n, reqCloner(request), parts)
 		}
 
-		acc := newIncrementalMergeAccumulator(len(next), rc)
+		acc := newAcc()
 		for i := 0; i < len(next); i++ {
-			select {
-			case err := <-failed:
-				acc.Merge(nil, err)
-			case response := <-parts:
-				acc.Merge(response, nil)
-			}
+			p := <-parts
+			acc.MergeAt(p.index, p.response, p.err)
 		}
 
 		result, err := acc.Result()
@@ -123,77 +188,45 @@ func parallelMerge(reqCloner func(*Request) *Request, timeout time.Duration, rc
 
 var reMergeKey = regexp.MustCompile(`\{\{\.Resp(\d+)_([\w-\.]+)\}\}`)
 
-func sequentialMerge(reqCloner func(*Request) *Request, patterns []string, timeout time.Duration, rc ResponseCombiner, next ...Proxy) Proxy {
+func sequentialMerge(reqCloner func(*Request) *Request, steps []sequentialStep, timeout time.Duration, budget *sequentialBudget, newAcc func() mergeAccumulator, next ...Proxy) Proxy {
+	withMetadata := usesMetadata(steps)
 	return func(ctx context.Context, request *Request) (*Response, error) {
 		localCtx, cancel := context.WithTimeout(ctx, timeout)
 
 		parts := make([]*Response, len(next))
+		metas := make([]Metadata, len(next))
 		out := make(chan *Response, 1)
 		errCh := make(chan error, 1)
 
-		acc := newIncrementalMergeAccumulator(len(next), rc)
+		acc := newAcc()
 	TxLoop:
 		for i, n := range next {
+			templates := steps[i].templates()
 			if i > 0 {
-				for _, match := range reMergeKey.FindAllStringSubmatch(patterns[i], -1) {
-					if len(match) > 1 {
-						rNum, err := strconv.Atoi(match[1])
-						if err != nil || rNum >= i || parts[rNum] == nil {
-							continue
-						}
-						key := "Resp" + match[1] + "_" + match[2]
-
-						var v interface{}
-						var ok bool
-
-						data := parts[rNum].Data
-						keys := strings.Split(match[2], ".")
-						if len(keys) > 1 {
-							for _, k := range keys[:len(keys)-1] {
-								v, ok = data[k]
-								if !ok {
-									break
-								}
-								clean, ok := v.(map[string]interface{})
-								if !ok {
-									break
-								}
-								data = clean
-							}
-						}
-
-						v, ok = data[keys[len(keys)-1]]
-						if !ok {
-							continue
-						}
-						switch clean := v.(type) {
-						case []interface{}:
-							if len(clean) == 0 {
-								request.Params[key] = ""
-								continue
-							}
-							var b strings.Builder
-							for i := 0; i < len(clean)-1; i++ {
-								fmt.Fprintf(&b, "%v,", clean[i])
-							}
-							fmt.Fprintf(&b, "%v", clean[len(clean)-1])
-							request.Params[key] = b.String()
-						case string:
-							request.Params[key] = clean
-						case int:
-							request.Params[key] = strconv.Itoa(clean)
-						case float64:
-							request.Params[key] = strconv.FormatFloat(clean, 'E', -1, 32)
-						case bool:
-							request.Params[key] = strconv.FormatBool(clean)
-						default:
-							request.Params[key] = fmt.Sprintf("%v", v)
-						}
-					}
-				}
+				setMetadataParams(request.Params, templates, metas[:i])
+				setResponseParams(request.Params, templates, parts[:i])
 			}
 
-			sequentialRequestPart(localCtx, n, reqCloner(request), out, errCh)
+			if steps[i].cond.skip(request.Params) {
+				acc.Merge(steps[i].cond.skipped(), nil)
+				continue
+			}
+
+			stepCtx := localCtx
+			capture := &metadataCapture{}
+			if withMetadata {
+				stepCtx = newMetadataCaptureContext(localCtx, capture)
+			}
+			stepRequest := reqCloner(request)
+			if len(steps[i].headers) > 0 {
+				stepRequest = withSequentialHeaders(stepRequest, steps[i].headers)
+			}
+			stepCancel := func() {}
+			if budget != nil {
+				stepCtx, stepCancel, stepRequest = budget.withStepBudget(stepCtx, i, stepRequest)
+			}
+			sequentialRequestPart(stepCtx, n, stepRequest, out, errCh)
+			stepCancel()
 
 			select {
 			case err := <-errCh:
@@ -204,6 +237,10 @@ func sequentialMerge(reqCloner func(*Request) *Request, patterns []string, timeo
 				acc.Merge(nil, err)
 				break TxLoop
 			case response := <-out:
+				metas[i] = response.Metadata
+				if m := capture.metadata(); m.StatusCode != 0 {
+					metas[i] = m
+				}
 				acc.Merge(response, nil)
 				if !response.IsComplete {
 					break TxLoop
@@ -218,18 +255,87 @@ func sequentialMerge(reqCloner func(*Request) *Request, patterns []string, timeo
 	}
 }
 
+// setResponseParams adds the values of the previous responses referenced by the templates to the
+// params. The nil responses are ignored
+func setResponseParams(params map[string]string, templates string, parts []*Response) {
+	for _, match := range reMergeKey.FindAllStringSubmatch(templates, -1) {
+		if len(match) > 1 {
+			rNum, err := strconv.Atoi(match[1])
+			if err != nil || rNum >= len(parts) || parts[rNum] == nil {
+				continue
+			}
+			key := "Resp" + match[1] + "_" + match[2]
+
+			var v interface{}
+			var ok bool
+
+			data := parts[rNum].Data
+			keys := strings.Split(match[2], ".")
+			if len(keys) > 1 {
+				for _, k := range keys[:len(keys)-1] {
+					v, ok = data[k]
+					if !ok {
+						break
+					}
+					clean, ok := v.(map[string]interface{})
+					if !ok {
+						break
+					}
+					data = clean
+				}
+			}
+
+			v, ok = data[keys[len(keys)-1]]
+			if !ok {
+				continue
+			}
+			switch clean := v.(type) {
+			case []interface{}:
+				if len(clean) == 0 {
+					params[key] = ""
+					continue
+				}
+				var b strings.Builder
+				for i := 0; i < len(clean)-1; i++ {
+					fmt.Fprintf(&b, "%v,", clean[i])
+				}
+				fmt.Fprintf(&b, "%v", clean[len(clean)-1])
+				params[key] = b.String()
+			case string:
+				params[key] = clean
+			case int:
+				params[key] = strconv.Itoa(clean)
+			case float64:
+				params[key] = strconv.FormatFloat(clean, 'E', -1, 32)
+			case bool:
+				params[key] = strconv.FormatBool(clean)
+			default:
+				params[key] = fmt.Sprintf("%v", v)
+			}
+		}
+	}
+}
+
+// mergeAccumulator collects the responses of the backends of an endpoint
+type mergeAccumulator interface {
+	Merge(*Response, error)
+	MergeAt(int, *Response, error)
+	Result() (*Response, error)
+}
+
 type incrementalMergeAccumulator struct {
 	pending  int
 	data     *Response
 	combiner ResponseCombiner
 	errs     []error
+	// pair holds the parts passed to the combiner, so the slice is not allocated on every merge
+	pair [2]*Response
 }
 
 func newIncrementalMergeAccumulator(total int, combiner ResponseCombiner) *incrementalMergeAccumulator {
 	return &incrementalMergeAccumulator{
 		pending:  total,
 		combiner: combiner,
-		errs:     []error{},
 	}
 }
 
@@ -250,7 +356,14 @@ func (i *incrementalMergeAccumulator) Merge(res *Response, err error) {
 		i.data = res
 		return
 	}
-	i.data = i.combiner(2, []*Response{i.data, res})
+	i.pair[0], i.pair[1] = i.data, res
+	i.data = i.combiner(2, i.pair[:])
+	i.pair[0], i.pair[1] = nil, nil
+}
+
+// MergeAt merges the response as it arrives, ignoring the position of its backend
+func (i *incrementalMergeAccumulator) MergeAt(_ int, res *Response, err error) {
+	i.Merge(res, err)
 }
 
 func (i *incrementalMergeAccumulator) Result() (*Response, error) {
@@ -264,26 +377,30 @@ func (i *incrementalMergeAccumulator) Result() (*Response, error) {
 	return i.data, newMergeError(i.errs)
 }
 
-func requestPart(ctx context.Context, next Proxy, request *Request, out chan<- *Response, failed chan<- error) {
+type indexedPart struct {
+	index    int
+	response *Response
+	err      error
+}
+
+func requestIndexedPart(ctx context.Context, i int, next Proxy, request *Request, out chan<- indexedPart) {
 	localCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	in, err := next(localCtx, request)
 	if err != nil {
-		failed <- err
-		cancel()
+		out <- indexedPart{index: i, err: err}
 		return
 	}
 	if in == nil {
-		failed <- errNullResult
-		cancel()
+		out <- indexedPart{index: i, err: errNullResult}
 		return
 	}
-	select {
-	case out <- in:
-	case <-ctx.Done():
-		failed <- ctx.Err()
+	if err := ctx.Err(); err != nil {
+		out <- indexedPart{index: i, err: err}
+		return
 	}
-	cancel()
+	out <- indexedPart{index: i, response: in}
 }
 
 func sequentialRequestPart(ctx context.Context, next Proxy, request *Request, out chan<- *Response, failed chan<- error) {
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/metaheaders"
+)
+
+// NewMetaHeadersMiddleware creates proxy middleware adding the meta headers enabled for the
+// endpoint to its responses with content. The inner middlewares and backends record their
+// metadata in the metaheaders.Info of the request context
+func NewMetaHeadersMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	cfg, ok := metaheaders.EndpointConfig(endpointConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	logger.Debug("[ENDPOINT: "+endpointConfig.Endpoint+"][MetaHeaders]", "Adding the meta headers to the responses")
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewMetaHeadersMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			ctx, info := metaheaders.NewContext(ctx)
+			resp, err := next[0](ctx, r)
+			if resp == nil || (len(resp.Data) == 0 && resp.Io == nil) {
+				return resp, err
+			}
+			// the response could be shared, so the headers are added to a copy
+			res := *resp
+			res.Metadata.Headers = CloneRequestHeaders(resp.Metadata.Headers)
+			for k, vs := range cfg.Headers(endpointConfig, info) {
+				res.Metadata.Headers[k] = vs
+			}
+			return &res, err
+		}
+	}
+}
+
+// NewBackendMetaHeadersMiddleware creates proxy middleware recording the backends answering the
+// requests, so their names can be exposed by the backend meta header. The backends are named by
+// their group or, if they do not declare one, by their url pattern
+func NewBackendMetaHeadersMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	if !metaheaders.RecordsBackends() {
+		return emptyMiddlewareFallback(logger)
+	}
+	name := remote.Group
+	if name == "" {
+		name = remote.URLPattern
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: NewBackendMetaHeadersMiddleware only accepts 1 proxy, got %d", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			resp, err := next[0](ctx, r)
+			if resp != nil {
+				if info, ok := metaheaders.FromContext(ctx); ok {
+					info.AddBackend(name)
+				}
+			}
+			return resp, err
+		}
+	}
+}
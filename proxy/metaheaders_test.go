@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/metaheaders"
+)
+
+func TestNewMetaHeadersMiddleware(t *testing.T) {
+	defer metaheaders.SetGlobal(metaheaders.Config{})
+
+	backend := &config.Backend{URLPattern: "/users", Group: "users"}
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/users",
+		Method:   "GET",
+		CacheTTL: time.Minute,
+		Backend:  []*config.Backend{backend},
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				cacheKey: map[string]interface{}{"max_size": 1024.0},
+			},
+		},
+	}
+	if _, err := metaheaders.Register(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{metaheaders.Namespace: map[string]interface{}{
+			"cache":   true,
+			"backend": "X-Served-By",
+		}},
+		Endpoints: []*config.EndpointConfig{endpoint},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	shared := &Response{Data: map[string]interface{}{"supu": 42.0}, IsComplete: true}
+	p := NewBackendMetaHeadersMiddleware(logging.NoOp, backend)(dummyProxy(shared))
+	p = NewCacheMiddleware(logging.NoOp, endpoint)(p)
+	p = NewMetaHeadersMiddleware(logging.NoOp, endpoint)(p)
+
+	for _, expected := range []map[string]string{
+		{"X-Cache": metaheaders.CacheMiss, "X-Served-By": "users"},
+		{"X-Cache": metaheaders.CacheHit},
+	} {
+		resp, err := p(context.Background(), &Request{Method: "GET", Path: "/users"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Metadata.Headers) != len(expected) {
+			t.Errorf("unexpected headers %v", resp.Metadata.Headers)
+		}
+		for k, v := range expected {
+			if vs := resp.Metadata.Headers[k]; len(vs) != 1 || vs[0] != v {
+				t.Errorf("unexpected header %s: %v", k, vs)
+			}
+		}
+	}
+	if shared.Metadata.Headers != nil {
+		t.Error("the response of the backend has been modified")
+	}
+}
+
+func TestNewMetaHeadersMiddleware_disabled(t *testing.T) {
+	metaheaders.SetGlobal(metaheaders.Config{})
+	endpoint := &config.EndpointConfig{ExtraConfig: config.ExtraConfig{
+		metaheaders.Namespace: map[string]interface{}{"version": false},
+	}}
+	resp := &Response{Data: map[string]interface{}{"supu": 42.0}}
+	out, _ := NewMetaHeadersMiddleware(logging.NoOp, endpoint)(dummyProxy(resp))(context.Background(), &Request{})
+	if out != resp {
+		t.Error("the middleware must not be added when the headers are disabled")
+	}
+}
@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/metrics"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+// NewBackendMetricsMiddleware creates proxy middleware counting the requests and the errors of
+// the backend and recording their latencies. The failures described by a client.BackendError are
+// counted by status code and retryability too. It does nothing when the service does not declare
+// the metrics
+func NewBackendMetricsMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	m, ok := metrics.GetGlobal()
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	logger.Debug(fmt.Sprintf("[BACKEND: %s %s -> %s][Metrics]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern), "Instrumenting the requests")
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewBackendMetricsMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			start := time.Now()
+			resp, err := next[0](ctx, r)
+			m.ObserveBackend(remote.ParentEndpoint, remote.URLPattern, time.Since(start), err != nil)
+			if e, ok := client.AsBackendError(err); ok {
+				m.ObserveBackendFailure(remote.ParentEndpoint, remote.URLPattern, e.Status, e.Retryable)
+			}
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/metrics"
+)
+
+func TestNewBackendMetricsMiddleware(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.SetGlobal(metrics.NewPipeline(r, metrics.DefaultBuckets))
+	defer metrics.SetGlobal(nil)
+
+	remote := &config.Backend{ParentEndpoint: "/a", URLPattern: "/backend"}
+	failing := true
+	p := NewBackendMetricsMiddleware(logging.NoOp, remote)(func(_ context.Context, _ *Request) (*Response, error) {
+		if failing {
+			return nil, errors.New("boom")
+		}
+		return &Response{IsComplete: true}, nil
+	})
+	p(context.Background(), &Request{})
+	failing = false
+	p(context.Background(), &Request{})
+
+	buf := new(bytes.Buffer)
+	r.WriteTo(buf)
+	for _, line := range []string{
+		`lura_backend_requests_total{endpoint="/a",backend="/backend"} 2`,
+		`lura_backend_errors_total{endpoint="/a",backend="/backend"} 1`,
+		`lura_backend_request_duration_seconds_count{endpoint="/a",backend="/backend"} 2`,
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("%s not found in:\n%s", line, buf.String())
+		}
+	}
+}
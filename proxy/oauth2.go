@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client/oauth2"
+)
+
+// NewBackendOAuth2Middleware creates proxy middleware adding the service token obtained with the
+// client credentials declared in the extra config of the backend to the Authorization header of
+// its requests. The cached token is discarded when the backend rejects it
+func NewBackendOAuth2Middleware(logger logging.Logger, remote *config.Backend) Middleware {
+	cfg, ok := oauth2.ConfigGetter(remote.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][OAuth2]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	if err := cfg.Validate(); err != nil {
+		logger.Error(logPrefix, err.Error())
+		return emptyMiddlewareFallback(logger)
+	}
+	ts := oauth2.SharedTokenSource(cfg)
+
+	logger.Debug(logPrefix, "Adding the service tokens issued by", cfg.TokenURL)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewBackendOAuth2Middleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			t, err := ts.Token(ctx)
+			if err != nil {
+				logger.Error(logPrefix, err.Error())
+				return nil, err
+			}
+			// the headers may be shared with the requests to other backends
+			r.Headers = CloneRequestHeaders(r.Headers)
+			r.Headers["Authorization"] = []string{t.AuthorizationHeader()}
+
+			resp, err := next[0](ctx, r)
+			if isUnauthorized(resp, err) {
+				ts.Invalidate()
+			}
+			return resp, err
+		}
+	}
+}
+
+func isUnauthorized(resp *Response, err error) bool {
+	if e, ok := err.(interface{ StatusCode() int }); ok && e.StatusCode() == http.StatusUnauthorized {
+		return true
+	}
+	return resp != nil && resp.Metadata.StatusCode == http.StatusUnauthorized
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client"
+	"github.com/luraproject/lura/v2/transport/http/client/oauth2"
+)
+
+func TestNewBackendOAuth2Middleware(t *testing.T) {
+	var issued int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":3600}`, atomic.AddInt32(&issued, 1))
+	}))
+	defer ts.Close()
+
+	backend := &config.Backend{
+		URLPattern: "/protected",
+		ExtraConfig: config.ExtraConfig{
+			oauth2.Namespace: map[string]interface{}{
+				"client_id":     "TestNewBackendOAuth2Middleware",
+				"client_secret": "secret",
+				"token_url":     ts.URL,
+			},
+		},
+	}
+
+	var received []string
+	reject := false
+	mw := NewBackendOAuth2Middleware(logging.NoOp, backend)
+	p := mw(func(_ context.Context, r *Request) (*Response, error) {
+		received = append(received, r.Headers["Authorization"][0])
+		if reject {
+			return nil, client.HTTPResponseError{Code: http.StatusUnauthorized}
+		}
+		return &Response{IsComplete: true}, nil
+	})
+
+	headers := map[string][]string{"X-Foo": {"bar"}}
+	if _, err := p(context.Background(), &Request{Headers: headers}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := headers["Authorization"]; ok {
+		t.Error("the original headers should not be modified")
+	}
+	reject = true
+	p(context.Background(), &Request{})
+	reject = false
+	p(context.Background(), &Request{})
+
+	want := []string{"Bearer token-1", "Bearer token-1", "Bearer token-2"}
+	if fmt.Sprint(received) != fmt.Sprint(want) {
+		t.Errorf("unexpected authorization headers. have: %v, want: %v", received, want)
+	}
+}
+
+func TestNewBackendOAuth2Middleware_notConfigured(t *testing.T) {
+	mw := NewBackendOAuth2Middleware(logging.NoOp, &config.Backend{})
+	p := mw(func(_ context.Context, r *Request) (*Response, error) {
+		if _, ok := r.Headers["Authorization"]; ok {
+			t.Error("unexpected authorization header")
+		}
+		return &Response{}, nil
+	})
+	p(context.Background(), &Request{Headers: map[string][]string{}})
+}
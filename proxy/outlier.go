@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/sd/outlier"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+// NewOutlierDetectionMiddleware creates proxy middleware reporting the result of every request
+// balanced by the received load balancer to the detector, so the hosts failing too much are
+// ejected from the balancing pool. The failed requests are the ones returning a server error
+// status code or an error not related to a client error status code. The requests cancelled by the client, or by the hedging middleware,
+// are not reported. It returns the load balancer received when there is no detector
+func NewOutlierDetectionMiddleware(detector *outlier.Detector, lb Middleware) Middleware {
+	if detector == nil {
+		return lb
+	}
+	return func(next ...Proxy) Proxy {
+		return lb(func(ctx context.Context, r *Request) (*Response, error) {
+			resp, err := next[0](ctx, r)
+			if ctx.Err() != nil || r.URL == nil {
+				return resp, err
+			}
+			target := r.URL.String()
+			if socket, ok := client.UnixSocketFromContext(ctx); ok {
+				target = "unix://" + socket
+			}
+			detector.Report(target, isOutlierFailure(resp, err))
+			return resp, err
+		})
+	}
+}
+
+// isOutlierFailure returns true if the backend failed to process the request. The errors
+// exposing a client error status code are not failures of the backend
+func isOutlierFailure(resp *Response, err error) bool {
+	if err == nil {
+		return resp != nil && resp.Metadata.StatusCode >= http.StatusInternalServerError
+	}
+	var e interface{ StatusCode() int }
+	if errors.As(err, &e) {
+		return e.StatusCode() >= http.StatusInternalServerError
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+	"github.com/luraproject/lura/v2/sd/outlier"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+func TestNewOutlierDetectionMiddleware(t *testing.T) {
+	backend := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			outlier.Namespace: map[string]interface{}{"consecutive_errors": 2},
+		},
+	}
+	detector, ok := outlier.New(logging.NoOp, backend, sd.FixedSubscriber{"http://a", "http://b"})
+	if !ok {
+		t.Fatal("expecting a detector")
+	}
+	lb := NewOutlierDetectionMiddleware(detector, NewRoundRobinLoadBalancedMiddlewareWithSubscriber(detector))
+
+	calls := map[string]int{}
+	p := lb(func(_ context.Context, r *Request) (*Response, error) {
+		calls[r.URL.Host]++
+		if r.URL.Host == "a" {
+			return &Response{Metadata: Metadata{StatusCode: 503}}, nil
+		}
+		return &Response{IsComplete: true, Metadata: Metadata{StatusCode: 200}}, nil
+	})
+
+	for i := 0; i < 10; i++ {
+		if _, err := p(context.Background(), &Request{Path: "/users"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls["a"] != 2 || calls["b"] != 8 {
+		t.Errorf("unexpected calls %v", calls)
+	}
+	if !detector.Ejected("http://a") {
+		t.Error("the failing host should be ejected")
+	}
+}
+
+func TestNewOutlierDetectionMiddleware_cancelled(t *testing.T) {
+	backend := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			outlier.Namespace: map[string]interface{}{"consecutive_errors": 1},
+		},
+	}
+	detector, _ := outlier.New(logging.NoOp, backend, sd.FixedSubscriber{"http://a", "http://b"})
+	p := NewOutlierDetectionMiddleware(detector, NewLoadBalancedMiddlewareWithSubscriber(detector))(func(ctx context.Context, _ *Request) (*Response, error) {
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 4; i++ {
+		if _, err := p(ctx, &Request{Path: "/"}); !errors.Is(err, context.Canceled) {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	if detector.Ejected("http://a") || detector.Ejected("http://b") {
+		t.Error("the cancelled requests should not be reported")
+	}
+}
+
+func TestIsOutlierFailure(t *testing.T) {
+	for i, tc := range []struct {
+		resp     *Response
+		err      error
+		expected bool
+	}{
+		{resp: &Response{Metadata: Metadata{StatusCode: 200}}},
+		{resp: &Response{Metadata: Metadata{StatusCode: 502}}, expected: true},
+		{err: client.HTTPResponseError{Code: 404}},
+		{err: fmt.Errorf("wrapped: %w", client.HTTPResponseError{Code: 500}), expected: true},
+		{err: errors.New("connection refused"), expected: true},
+	} {
+		if res := isOutlierFailure(tc.resp, tc.err); res != tc.expected {
+			t.Errorf("#%d: unexpected result %v", i, res)
+		}
+	}
+}
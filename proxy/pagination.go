@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	paginationKey = "pagination"
+
+	paginationToken = "token"
+	paginationPage  = "page"
+
+	defaultMaxPages = 10
+)
+
+// paginationConfig declares how to follow the pages of a backend:
+//
+//	"github.com/devopsfaith/krakend/proxy": {
+//		"pagination": {
+//			"mode": "token",
+//			"items": "data.items",
+//			"next_token": "meta.next_cursor",
+//			"param": "cursor",
+//			"max_pages": 20,
+//			"max_items": 500
+//		}
+//	}
+//
+// The "token" mode sends the token found at the next_token path of every page as the param of
+// the next request, until a page comes without it. The "page" mode sends the page number as the
+// param, starting at start_page (1 by default), until a page comes without items or with less
+// items than the page_size, if declared. The items of all the pages are returned at the
+// 'collection' key. The max_pages (10 by default) and max_items limits bound the aggregation
+type paginationConfig struct {
+	mode      string
+	items     string
+	nextToken string
+	param     string
+	startPage int
+	pageSize  int
+	maxPages  int
+	maxItems  int
+}
+
+func getPaginationConfig(extra config.ExtraConfig) (paginationConfig, bool, error) {
+	cfg := paginationConfig{}
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	v, ok := e[paginationKey].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+
+	cfg.mode, _ = v["mode"].(string)
+	cfg.items, _ = v["items"].(string)
+	cfg.nextToken, _ = v["next_token"].(string)
+	cfg.param, _ = v["param"].(string)
+	cfg.startPage = 1
+	if n, ok := v["start_page"].(float64); ok {
+		cfg.startPage = int(n)
+	}
+	if n, ok := v["page_size"].(float64); ok {
+		cfg.pageSize = int(n)
+	}
+	cfg.maxPages = defaultMaxPages
+	if n, ok := v["max_pages"].(float64); ok && n > 0 {
+		cfg.maxPages = int(n)
+	}
+	if n, ok := v["max_items"].(float64); ok {
+		cfg.maxItems = int(n)
+	}
+
+	switch cfg.mode {
+	case paginationToken:
+		if cfg.nextToken == "" {
+			return cfg, true, errors.New("pagination: the token mode requires the next_token path")
+		}
+	case paginationPage:
+	default:
+		return cfg, true, fmt.Errorf("pagination: unknown mode %q", cfg.mode)
+	}
+	if cfg.items == "" || cfg.param == "" {
+		return cfg, true, errors.New("pagination: the items path and the param are required")
+	}
+	return cfg, true, nil
+}
+
+// NewPaginationMiddleware creates a proxy middleware following the pages of the backend and
+// merging their items into a single collection (depending on the configuration). The items are
+// taken from the data of every page, after the manipulations of the backend. When a page fails,
+// the items collected so far are returned as an incomplete response, along with the error
+func NewPaginationMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Pagination]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	cfg, ok, err := getPaginationConfig(remote.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return emptyMiddlewareFallback(logger)
+	}
+	itemsPath := strings.Split(cfg.items, ".")
+	tokenPath := strings.Split(cfg.nextToken, ".")
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: NewPaginationMiddleware only accepts 1 proxy, got %d",
+				remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
+			return nil
+		}
+
+		logger.Debug(logPrefix, "Mode:", cfg.mode, "max pages:", cfg.maxPages)
+
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			var (
+				result *Response
+				items  = []interface{}{}
+				token  string
+			)
+			for i := 0; i < cfg.maxPages; i++ {
+				r := CloneRequest(request)
+				r.Query = cloneQuery(request.Query)
+				switch {
+				case cfg.mode == paginationPage:
+					r.Query.Set(cfg.param, strconv.Itoa(cfg.startPage+i))
+				case i > 0:
+					r.Query.Set(cfg.param, token)
+				}
+
+				resp, err := next[0](ctx, r)
+				if err != nil || resp == nil {
+					if result == nil {
+						return resp, err
+					}
+					if err == nil {
+						err = errNullResult
+					}
+					result.IsComplete = false
+					result.Data = map[string]interface{}{"collection": items}
+					return result, err
+				}
+				if result == nil {
+					result = &Response{IsComplete: true, Metadata: resp.Metadata}
+				}
+				result.IsComplete = result.IsComplete && resp.IsComplete
+
+				page, _ := lookupPath(resp.Data, itemsPath).([]interface{})
+				items = append(items, page...)
+				if cfg.maxItems > 0 && len(items) >= cfg.maxItems {
+					items = items[:cfg.maxItems]
+					break
+				}
+
+				if cfg.mode == paginationPage {
+					if len(page) == 0 || (cfg.pageSize > 0 && len(page) < cfg.pageSize) {
+						break
+					}
+					continue
+				}
+				token = tokenString(lookupPath(resp.Data, tokenPath))
+				if token == "" {
+					break
+				}
+			}
+			result.Data = map[string]interface{}{"collection": items}
+			return result, nil
+		}
+	}
+}
+
+func cloneQuery(q url.Values) url.Values {
+	res := make(url.Values, len(q)+1)
+	for k, vs := range q {
+		res[k] = append([]string{}, vs...)
+	}
+	return res
+}
+
+// lookupPath returns the value at the path of nested objects, or nil if it is not found
+func lookupPath(data map[string]interface{}, path []string) interface{} {
+	var v interface{} = data
+	for _, part := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[part]
+	}
+	return v
+}
+
+func tokenString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func paginatedBackend(cfg map[string]interface{}) *config.Backend {
+	return &config.Backend{
+		URLPattern:  "/items",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{paginationKey: cfg}},
+	}
+}
+
+func TestNewPaginationMiddleware_token(t *testing.T) {
+	pages := map[string]map[string]interface{}{
+		"": {
+			"data": map[string]interface{}{"items": []interface{}{1, 2}},
+			"meta": map[string]interface{}{"next": "b"},
+		},
+		"b": {
+			"data": map[string]interface{}{"items": []interface{}{3}},
+			"meta": map[string]interface{}{"next": "c"},
+		},
+		"c": {
+			"data": map[string]interface{}{"items": []interface{}{4, 5}},
+		},
+	}
+	calls := 0
+	p := NewPaginationMiddleware(logging.NoOp, paginatedBackend(map[string]interface{}{
+		"mode":       "token",
+		"items":      "data.items",
+		"next_token": "meta.next",
+		"param":      "cursor",
+	}))(func(_ context.Context, r *Request) (*Response, error) {
+		calls++
+		if r.Query.Get("q") != "x" {
+			t.Errorf("the query string was not forwarded: %v", r.Query)
+		}
+		return &Response{IsComplete: true, Data: pages[r.Query.Get("cursor")]}, nil
+	})
+
+	out, err := p(context.Background(), &Request{Query: map[string][]string{"q": {"x"}}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if calls != 3 {
+		t.Errorf("unexpected number of calls: %d", calls)
+	}
+	b, _ := json.Marshal(out.Data)
+	if string(b) != `{"collection":[1,2,3,4,5]}` || !out.IsComplete {
+		t.Errorf("unexpected response: %s, complete: %v", b, out.IsComplete)
+	}
+}
+
+func TestNewPaginationMiddleware_page(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      map[string]interface{}
+		calls    int
+		expected string
+	}{
+		{
+			name:     "until empty",
+			cfg:      map[string]interface{}{"mode": "page", "items": "items", "param": "page"},
+			calls:    4,
+			expected: `{"collection":[1,2,3,4,5,6,7]}`,
+		},
+		{
+			name:     "page size",
+			cfg:      map[string]interface{}{"mode": "page", "items": "items", "param": "page", "page_size": 3.0},
+			calls:    3,
+			expected: `{"collection":[1,2,3,4,5,6,7]}`,
+		},
+		{
+			name:     "max pages",
+			cfg:      map[string]interface{}{"mode": "page", "items": "items", "param": "page", "max_pages": 2.0},
+			calls:    2,
+			expected: `{"collection":[1,2,3,4,5,6]}`,
+		},
+		{
+			name:     "max items",
+			cfg:      map[string]interface{}{"mode": "page", "items": "items", "param": "page", "max_items": 4.0},
+			calls:    2,
+			expected: `{"collection":[1,2,3,4]}`,
+		},
+		{
+			name:     "start page",
+			cfg:      map[string]interface{}{"mode": "page", "items": "items", "param": "page", "start_page": 2.0},
+			calls:    3,
+			expected: `{"collection":[4,5,6,7]}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pages := [][]interface{}{{1, 2, 3}, {4, 5, 6}, {7}}
+			calls := 0
+			p := NewPaginationMiddleware(logging.NoOp, paginatedBackend(tc.cfg))(func(_ context.Context, r *Request) (*Response, error) {
+				calls++
+				page, _ := strconv.Atoi(r.Query.Get("page"))
+				data := map[string]interface{}{}
+				if page > 0 && page <= len(pages) {
+					data["items"] = pages[page-1]
+				}
+				return &Response{IsComplete: true, Data: data}, nil
+			})
+
+			out, err := p(context.Background(), &Request{})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if calls != tc.calls {
+				t.Errorf("unexpected number of calls: %d", calls)
+			}
+			b, _ := json.Marshal(out.Data)
+			if string(b) != tc.expected {
+				t.Errorf("unexpected data: %s", b)
+			}
+		})
+	}
+}
+
+func TestNewPaginationMiddleware_failedPage(t *testing.T) {
+	expectedErr := errors.New("boom")
+	p := NewPaginationMiddleware(logging.NoOp, paginatedBackend(map[string]interface{}{
+		"mode":  "page",
+		"items": "items",
+		"param": "page",
+	}))(func(_ context.Context, r *Request) (*Response, error) {
+		if r.Query.Get("page") == "2" {
+			return nil, expectedErr
+		}
+		return &Response{IsComplete: true, Data: map[string]interface{}{"items": []interface{}{1}}}, nil
+	})
+
+	out, err := p(context.Background(), &Request{})
+	if err != expectedErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if out == nil || out.IsComplete {
+		t.Errorf("unexpected response: %v", out)
+		return
+	}
+	b, _ := json.Marshal(out.Data)
+	if string(b) != `{"collection":[1]}` {
+		t.Errorf("unexpected data: %s", b)
+	}
+}
+
+func TestNewPaginationMiddleware_failedFirstPage(t *testing.T) {
+	expectedErr := errors.New("boom")
+	p := NewPaginationMiddleware(logging.NoOp, paginatedBackend(map[string]interface{}{
+		"mode":  "page",
+		"items": "items",
+		"param": "page",
+	}))(func(_ context.Context, _ *Request) (*Response, error) {
+		return nil, expectedErr
+	})
+	if out, err := p(context.Background(), &Request{}); err != expectedErr || out != nil {
+		t.Errorf("unexpected result: %v, %v", out, err)
+	}
+}
+
+func TestGetPaginationConfig_ko(t *testing.T) {
+	for i, cfg := range []map[string]interface{}{
+		{"mode": "offset", "items": "items", "param": "page"},
+		{"mode": "token", "items": "items", "param": "cursor"},
+		{"mode": "page", "param": "page"},
+		{"mode": "page", "items": "items"},
+	} {
+		if _, ok, err := getPaginationConfig(paginatedBackend(cfg).ExtraConfig); !ok || err == nil {
+			t.Errorf("#%d: expecting an error", i)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const (
+	partialResponseKey = "partial_response"
+
+	// PartialResponseErrorsKey is the key of the section describing the failed backends, added to
+	// the partial responses when the policy of the endpoint requests it
+	PartialResponseErrorsKey = "_errors"
+)
+
+var errIncompleteResponse = errors.New("incomplete response")
+
+// partialResponsePolicy decides what the multi-backend endpoints return when only some of their
+// backends succeed:
+//
+//	"extra_config": {
+//		"github.com/devopsfaith/krakend/proxy": {
+//			"partial_response": {
+//				"policy": "partial",
+//				"status": 207,
+//				"include_errors": true
+//			}
+//		}
+//	}
+//
+// The partial policy returns the merged data with the status, a 2xx one (200 by default), and the
+// include_errors flag adds an _errors section describing the failed backends. The fail policy
+// discards the merged data and fails the whole request with the status, 502 by default
+type partialResponsePolicy struct {
+	fail          bool
+	status        int
+	includeErrors bool
+}
+
+func getPartialResponsePolicy(cfg *config.EndpointConfig) (*partialResponsePolicy, error) {
+	e, ok := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	v, ok := e[partialResponseKey].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	p := &partialResponsePolicy{}
+	switch policy, _ := v["policy"].(string); policy {
+	case "", "partial":
+	case "fail":
+		p.fail = true
+		p.status = http.StatusBadGateway
+	default:
+		return nil, fmt.Errorf("unknown partial response policy %q", policy)
+	}
+	if s, ok := v["status"].(float64); ok {
+		p.status = int(s)
+	}
+	if p.fail && (p.status < 400 || p.status > 599) {
+		return nil, fmt.Errorf("invalid status %d for the failed requests", p.status)
+	}
+	if !p.fail && p.status != 0 && (p.status < 200 || p.status > 299) {
+		return nil, fmt.Errorf("invalid status %d for the partial responses", p.status)
+	}
+	p.includeErrors, _ = v["include_errors"].(bool)
+	return p, nil
+}
+
+// String returns a human readable representation of the policy
+func (p *partialResponsePolicy) String() string {
+	if p == nil {
+		return ""
+	}
+	if p.fail {
+		return fmt.Sprintf("fail (%d)", p.status)
+	}
+	status := p.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if p.includeErrors {
+		return fmt.Sprintf("partial (%d, with errors)", status)
+	}
+	return fmt.Sprintf("partial (%d)", status)
+}
+
+// BackendFailure describes a backend failing to contribute to a partial response
+type BackendFailure struct {
+	Index   int    `json:"index"`
+	Backend string `json:"backend"`
+	Error   string `json:"error"`
+}
+
+type partialReport struct {
+	mu       sync.Mutex
+	failures []BackendFailure
+}
+
+type partialReportKey struct{}
+
+// record wraps the proxy of a backend, so its failures are added to the report of the request
+func (p *partialResponsePolicy) record(index int, remote *config.Backend, next Proxy) Proxy {
+	name := remote.Group
+	if name == "" {
+		name = remote.URLPattern
+	}
+	return func(ctx context.Context, r *Request) (*Response, error) {
+		resp, err := next(ctx, r)
+		if err == nil && resp != nil && resp.IsComplete {
+			return resp, err
+		}
+		report, ok := ctx.Value(partialReportKey{}).(*partialReport)
+		if !ok {
+			return resp, err
+		}
+		if err == nil {
+			err = errIncompleteResponse
+		}
+		report.mu.Lock()
+		report.failures = append(report.failures, BackendFailure{Index: index, Backend: name, Error: err.Error()})
+		report.mu.Unlock()
+		return resp, err
+	}
+}
+
+// apply wraps the merging proxy, so the incomplete responses follow the policy
+func (p *partialResponsePolicy) apply(next Proxy) Proxy {
+	return func(ctx context.Context, r *Request) (*Response, error) {
+		report := &partialReport{}
+		resp, err := next(context.WithValue(ctx, partialReportKey{}, report), r)
+		if resp == nil || resp.IsComplete {
+			return resp, err
+		}
+		if p.fail {
+			if err == nil {
+				err = errIncompleteResponse
+			}
+			return nil, partialResponseError{err: err, status: p.status}
+		}
+
+		out := *resp
+		out.Metadata.StatusCode = p.status
+		report.mu.Lock()
+		failures := report.failures
+		report.mu.Unlock()
+		if p.includeErrors && len(failures) > 0 {
+			// the merged data may belong to one of the backends, so it is not modified
+			out.Data = make(map[string]interface{}, len(resp.Data)+1)
+			for k, v := range resp.Data {
+				out.Data[k] = v
+			}
+			out.Data[PartialResponseErrorsKey] = failures
+		}
+		return &out, err
+	}
+}
+
+// partialResponseError is returned when the fail policy rejects an incomplete response. It
+// exposes the status configured for the endpoint to the routers
+type partialResponseError struct {
+	err    error
+	status int
+}
+
+func (p partialResponseError) Error() string { return p.err.Error() }
+
+func (p partialResponseError) Unwrap() error { return p.err }
+
+func (p partialResponseError) StatusCode() int { return p.status }
+
+// Errors returns the errors of the failed backends
+func (p partialResponseError) Errors() []error {
+	if m, ok := p.err.(mergeError); ok {
+		return m.Errors()
+	}
+	return []error{p.err}
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestGetPartialResponsePolicy(t *testing.T) {
+	for _, tc := range []struct {
+		cfg      map[string]interface{}
+		expected string
+	}{
+		{cfg: map[string]interface{}{}, expected: "partial (200)"},
+		{cfg: map[string]interface{}{"status": 207.0, "include_errors": true}, expected: "partial (207, with errors)"},
+		{cfg: map[string]interface{}{"policy": "fail"}, expected: "fail (502)"},
+		{cfg: map[string]interface{}{"policy": "fail", "status": 503.0}, expected: "fail (503)"},
+	} {
+		p, err := getPartialResponsePolicy(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{partialResponseKey: tc.cfg},
+		}})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if s := p.String(); s != tc.expected {
+			t.Errorf("unexpected policy %s", s)
+		}
+	}
+
+	for _, cfg := range []map[string]interface{}{
+		{"policy": "unknown"},
+		{"status": 500.0},
+		{"policy": "fail", "status": 200.0},
+	} {
+		if _, err := getPartialResponsePolicy(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{partialResponseKey: cfg},
+		}}); err == nil {
+			t.Errorf("%v: error expected", cfg)
+		}
+	}
+
+	if p, err := getPartialResponsePolicy(&config.EndpointConfig{}); p != nil || err != nil {
+		t.Error("the endpoints without policy must not get one")
+	}
+}
+
+func TestNewMergeDataMiddleware_partialResponse(t *testing.T) {
+	failing := func(_ context.Context, _ *Request) (*Response, error) {
+		return nil, errors.New("backend unavailable")
+	}
+	for _, sequential := range []bool{false, true} {
+		shared := &Response{Data: map[string]interface{}{"supu": 42.0}, IsComplete: true}
+		endpoint := &config.EndpointConfig{
+			Backend: []*config.Backend{{URLPattern: "/supu"}, {URLPattern: "/tupu", Group: "tupu"}},
+			Timeout: time.Second,
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+				isSequentialKey: sequential,
+				partialResponseKey: map[string]interface{}{
+					"status":         207.0,
+					"include_errors": true,
+				},
+			}},
+		}
+		p := NewMergeDataMiddleware(logging.NoOp, endpoint)(dummyProxy(shared), failing)
+		resp, err := p(context.Background(), &Request{Params: map[string]string{}})
+		if err == nil {
+			t.Errorf("sequential %t: error expected", sequential)
+		}
+		if resp == nil || resp.IsComplete || resp.Metadata.StatusCode != http.StatusMultiStatus {
+			t.Errorf("sequential %t: unexpected response %+v", sequential, resp)
+			continue
+		}
+		failures, ok := resp.Data[PartialResponseErrorsKey].([]BackendFailure)
+		if !ok || len(failures) != 1 || failures[0].Index != 1 || failures[0].Backend != "tupu" ||
+			failures[0].Error != "backend unavailable" {
+			t.Errorf("sequential %t: unexpected failures %+v", sequential, resp.Data[PartialResponseErrorsKey])
+		}
+		if resp.Data["supu"] != 42.0 {
+			t.Errorf("sequential %t: unexpected data %+v", sequential, resp.Data)
+		}
+		if _, ok := shared.Data[PartialResponseErrorsKey]; ok {
+			t.Errorf("sequential %t: the response of the backend has been modified", sequential)
+		}
+	}
+}
+
+func TestNewMergeDataMiddleware_partialResponseFail(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Backend: []*config.Backend{{URLPattern: "/supu"}, {URLPattern: "/tupu"}},
+		Timeout: time.Second,
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			partialResponseKey: map[string]interface{}{"policy": "fail", "status": 503.0},
+		}},
+	}
+	p := NewMergeDataMiddleware(logging.NoOp, endpoint)(
+		dummyProxy(&Response{Data: map[string]interface{}{"supu": 42.0}, IsComplete: true}),
+		func(_ context.Context, _ *Request) (*Response, error) {
+			return nil, errors.New("backend unavailable")
+		},
+	)
+	resp, err := p(context.Background(), &Request{Params: map[string]string{}})
+	if resp != nil {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	e, ok := err.(interface{ StatusCode() int })
+	if !ok || e.StatusCode() != http.StatusServiceUnavailable {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	complete, err := NewMergeDataMiddleware(logging.NoOp, endpoint)(
+		dummyProxy(&Response{Data: map[string]interface{}{"supu": 42.0}, IsComplete: true}),
+		dummyProxy(&Response{Data: map[string]interface{}{"tupu": 1.0}, IsComplete: true}),
+	)(context.Background(), &Request{Params: map[string]string{}})
+	if err != nil || !complete.IsComplete || len(complete.Data) != 2 {
+		t.Errorf("unexpected response %+v: %v", complete, err)
+	}
+}
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/luraproject/lura/v2/funcmap"
+)
+
+var (
+	templateActionPattern = regexp.MustCompile(`\{\{-?\s*(.*?)\s*-?\}\}`)
+	plainParamPattern     = regexp.MustCompile(`^\.[^\s|()"']+$`)
+
+	pathTemplates sync.Map
+)
+
+// isPathTemplate returns true if the url pattern calls any function of the funcmap registry, like
+// {{ lower .Id }}. The patterns only referencing params keep their plain replacement
+func isPathTemplate(pattern string) bool {
+	if !strings.Contains(pattern, "{{") {
+		return false
+	}
+	for _, m := range templateActionPattern.FindAllStringSubmatch(pattern, -1) {
+		if !plainParamPattern.MatchString(m[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathTemplate returns the parsed template of the url pattern, or nil if it does not call any
+// function or it is not valid. The patterns are inspected and parsed once, so the functions must
+// be registered before serving the requests
+func pathTemplate(pattern string) *template.Template {
+	if t, ok := pathTemplates.Load(pattern); ok {
+		return t.(*template.Template)
+	}
+	var t *template.Template
+	if isPathTemplate(pattern) {
+		var err error
+		if t, err = funcmap.New("path").Option("missingkey=zero").Parse(pattern); err != nil {
+			t = nil
+		}
+	}
+	pathTemplates.Store(pattern, t)
+	return t
+}
+
+// renderPath executes the template of the url pattern with the params, if it calls any function.
+// The template receives the values as they are, so the params can not inject template actions
+func renderPath(pattern string, params map[string]string) (string, bool) {
+	t := pathTemplate(pattern)
+	if t == nil {
+		return "", false
+	}
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, params); err != nil {
+		return "", false
+	}
+	return buf.String(), true
+}
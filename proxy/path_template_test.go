@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/funcmap"
+)
+
+func TestRequest_GeneratePath_template(t *testing.T) {
+	defer funcmap.Reset()
+	if err := funcmap.Register("tenant", func(host string) string { return strings.Split(host, ".")[0] }); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		pattern, expected string
+	}{
+		{"/users/{{ lower .Id }}/{{.Section}}", "/users/supu/Orders"},
+		{"/{{ tenant .Host }}/users/{{.Id}}", "/acme/users/SUPU"},
+		{"/users/{{ .Missing | default \"all\" }}", "/users/all"},
+		{"/users/{{.Id}}", "/users/SUPU"},
+		{"/users/{{ lower .Id", "/users/{{ lower .Id"},
+	} {
+		r := Request{Params: map[string]string{"Id": "SUPU", "Section": "Orders", "Host": "acme.example.com"}}
+		r.GeneratePath(tc.pattern)
+		if r.Path != tc.expected {
+			t.Errorf("%s: unexpected path %s", tc.pattern, r.Path)
+		}
+	}
+
+	// the params can not inject template actions
+	r := Request{Params: map[string]string{"Id": "{{ printf \"%s\" \"injected\" }}"}}
+	r.GeneratePath("/users/{{ lower .Id }}")
+	if r.Path != "/users/{{ printf \"%s\" \"injected\" }}" {
+		t.Errorf("unexpected path: %s", r.Path)
+	}
+
+	r = Request{Params: map[string]string{"Id": "a b/c"}}
+	r.GeneratePathWithEncoding("/users/{{ trim .Id }}", PathEncoding{Path: true})
+	if r.Path != "/users/a%20b%2Fc" {
+		t.Errorf("unexpected path: %s", r.Path)
+	}
+}
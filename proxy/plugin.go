@@ -58,11 +58,11 @@ func newPluginMiddleware(logger logging.Logger, tag, pattern string, cfg map[str
 			continue
 		}
 
+		// the modifiers registered for both the request and the response are added to both chains
 		if mf, ok := plugin.GetRequestModifier(name); ok {
 			if fn := mf(cfg); fn != nil {
 				reqModifiers = append(reqModifiers, fn)
 			}
-			continue
 		}
 
 		if mf, ok := plugin.GetResponseModifier(name); ok {
@@ -2,6 +2,13 @@
 
 /*
 Package plugin provides tools for loading and registering proxy plugins
+
+The modifier plugins expose a ModifierRegisterer symbol implementing the Registerer interface.
+Every registered factory receives the extra config of the pipe and returns a modifier. The
+request modifiers receive and return values implementing the proxy.RequestWrapper interface and
+the response modifiers do the same with the proxy.ResponseWrapper. Modifiers registered for both
+the request and the response are executed in both directions, so they must check the type of
+the received value. The modifiers are chained in the order declared by the extra config.
 */
 package plugin
 
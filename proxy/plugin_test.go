@@ -241,6 +241,42 @@ func TestNewPluginMiddleware_PoisonedPlugin(t *testing.T) {
 	}
 }
 
+func TestNewPluginMiddleware_bothDirections(t *testing.T) {
+	var requests, responses int
+	plugin.RegisterModifier("both-directions", func(map[string]interface{}) func(interface{}) (interface{}, error) {
+		return func(v interface{}) (interface{}, error) {
+			switch v.(type) {
+			case RequestWrapper:
+				requests++
+			case ResponseWrapper:
+				responses++
+			}
+			return v, nil
+		}
+	}, true, true)
+
+	p := NewPluginMiddleware(
+		logging.NoOp,
+		&config.EndpointConfig{
+			ExtraConfig: map[string]interface{}{
+				plugin.Namespace: map[string]interface{}{
+					"name": []interface{}{"both-directions"},
+				},
+			},
+		},
+	)(func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{Data: map[string]interface{}{"foo": "bar"}, IsComplete: true}, nil
+	})
+
+	if _, err := p(context.Background(), &Request{Path: "/bar"}); err != nil {
+		t.Error(err)
+		return
+	}
+	if requests != 1 || responses != 1 {
+		t.Errorf("unexpected number of executions. requests: %d, responses: %d", requests, responses)
+	}
+}
+
 type statusCodeError interface {
 	error
 	StatusCode() int
@@ -100,5 +100,19 @@ func emptyMiddlewareFallback(logger logging.Logger) Middleware {
 	}
 }
 
+// errorMiddlewareFallback returns a middleware failing all the requests with the received error,
+// so a pipe is not served without the processing it declares
+func errorMiddlewareFallback(logger logging.Logger, err error) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: errorMiddlewareFallback only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			return nil, err
+		}
+	}
+}
+
 // NoopProxy is a do nothing proxy, useful for testing
 func NoopProxy(_ context.Context, _ *Request) (*Response, error) { return nil, nil }
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package proxytest provides test doubles for the code building or decorating backend proxies.
+
+Backend replaces a whole backend proxy, recording the requests received and returning a canned
+response. Executor replaces the transport of a backend, so the custom backend factories can be
+tested with the real request building, status handling and decoding:
+
+	e := proxytest.NewExecutor(http.StatusOK, `{"id": 42}`, nil)
+	p := proxy.NewBackendFactory(e.Factory(), proxy.BackendOptions{})(remote)
+*/
+package proxytest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+// Backend is a backend proxy returning the same response or error to every request
+type Backend struct {
+	Response *proxy.Response
+	Err      error
+
+	mu       sync.Mutex
+	requests []*proxy.Request
+	remotes  []*config.Backend
+}
+
+// NewBackend returns a Backend returning the response and the error
+func NewBackend(resp *proxy.Response, err error) *Backend {
+	return &Backend{Response: resp, Err: err}
+}
+
+// Proxy returns the proxy recording the requests
+func (b *Backend) Proxy() proxy.Proxy {
+	return func(ctx context.Context, r *proxy.Request) (*proxy.Response, error) {
+		c := proxy.CloneRequest(r)
+		b.mu.Lock()
+		b.requests = append(b.requests, c)
+		b.mu.Unlock()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return b.Response, b.Err
+	}
+}
+
+// Factory returns a BackendFactory creating the proxies of the backend and recording the configs
+// received
+func (b *Backend) Factory() proxy.BackendFactory {
+	return func(remote *config.Backend) proxy.Proxy {
+		b.mu.Lock()
+		b.remotes = append(b.remotes, remote)
+		b.mu.Unlock()
+		return b.Proxy()
+	}
+}
+
+// Requests returns a copy of the requests received, in order
+func (b *Backend) Requests() []*proxy.Request {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*proxy.Request{}, b.requests...)
+}
+
+// Remotes returns the backend configs received by the factory, in order
+func (b *Backend) Remotes() []*config.Backend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*config.Backend{}, b.remotes...)
+}
+
+// RecordedRequest is a request sent to an Executor, with its body already read
+type RecordedRequest struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// Executor is a request executor returning the same response to every request
+type Executor struct {
+	Status int
+	Body   string
+	Header http.Header
+	Err    error
+
+	mu       sync.Mutex
+	requests []RecordedRequest
+}
+
+// NewExecutor returns an Executor responding with the status, the body and the headers
+func NewExecutor(status int, body string, header http.Header) *Executor {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &Executor{Status: status, Body: body, Header: header}
+}
+
+// Execute implements the client.HTTPRequestExecutor signature
+func (e *Executor) Execute(ctx context.Context, req *http.Request) (*http.Response, error) {
+	rec := RecordedRequest{Method: req.Method, URL: req.URL.String(), Header: req.Header.Clone()}
+	if req.Body != nil {
+		rec.Body, _ = io.ReadAll(req.Body)
+	}
+	e.mu.Lock()
+	e.requests = append(e.requests, rec)
+	e.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if e.Err != nil {
+		return nil, e.Err
+	}
+	header := e.Header.Clone()
+	header.Set("Content-Length", strconv.Itoa(len(e.Body)))
+	return &http.Response{
+		Status:        strconv.Itoa(e.Status) + " " + http.StatusText(e.Status),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewBufferString(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Factory returns an ExecutorFactory returning the executor for every backend
+func (e *Executor) Factory() proxy.ExecutorFactory {
+	return func(_ *config.Backend) (client.HTTPRequestExecutor, error) {
+		return e.Execute, nil
+	}
+}
+
+// Requests returns a copy of the requests received, in order
+func (e *Executor) Requests() []RecordedRequest {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]RecordedRequest{}, e.requests...)
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestBackend(t *testing.T) {
+	b := NewBackend(&proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil)
+	remote := &config.Backend{URLPattern: "/users"}
+	p := b.Factory()(remote)
+
+	resp, err := p(context.Background(), &proxy.Request{
+		Method:  "POST",
+		Body:    io.NopCloser(strings.NewReader("payload")),
+		Headers: map[string][]string{"X-Foo": {"bar"}},
+	})
+	if err != nil || resp.Data["ok"] != true {
+		t.Errorf("unexpected response %+v %v", resp, err)
+	}
+
+	reqs := b.Requests()
+	if len(reqs) != 1 || reqs[0].Method != "POST" || reqs[0].Headers["X-Foo"][0] != "bar" {
+		t.Fatalf("unexpected requests %+v", reqs)
+	}
+	if body, _ := io.ReadAll(reqs[0].Body); string(body) != "payload" {
+		t.Errorf("unexpected body %s", body)
+	}
+	if rs := b.Remotes(); len(rs) != 1 || rs[0] != remote {
+		t.Errorf("unexpected remotes %v", rs)
+	}
+
+	b.Err = errors.New("boom")
+	if _, err := p(context.Background(), &proxy.Request{}); err != b.Err {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestExecutor(t *testing.T) {
+	e := NewExecutor(http.StatusOK, `{"id": 42}`, http.Header{"X-Backend": {"users"}})
+	remote := &config.Backend{Decoder: encoding.JSONDecoder}
+	p := proxy.NewBackendFactory(e.Factory(), proxy.BackendOptions{})(remote)
+
+	u, _ := url.Parse("http://example.com/users?page=2")
+	resp, err := p(context.Background(), &proxy.Request{
+		Method: "PUT",
+		URL:    u,
+		Body:   io.NopCloser(strings.NewReader(`{"name": "foo"}`)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(resp.Data["id"]) != "42" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	reqs := e.Requests()
+	if len(reqs) != 1 || reqs[0].Method != "PUT" || reqs[0].URL != "http://example.com/users?page=2" || string(reqs[0].Body) != `{"name": "foo"}` {
+		t.Errorf("unexpected requests %+v", reqs)
+	}
+
+	e.Err = errors.New("connection refused")
+	if _, err := p(context.Background(), &proxy.Request{Method: "GET", URL: u}); !errors.Is(err, e.Err) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -11,11 +11,25 @@ import (
 )
 
 // NewFilterQueryStringsMiddleware returns a middleware with or without a header filtering
-// proxy wrapping the next element (depending on the configuration).
+// proxy wrapping the next element (depending on the configuration). The backends declaring the
+// wildcard get all the query string params but the denied ones
 func NewFilterQueryStringsMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
 	if len(remote.QueryStringsToPass) == 0 {
 		return emptyMiddlewareFallback(logger)
 	}
+	filter := config.NewParamsFilter(remote.QueryStringsToPass)
+	if filter.All {
+		return newWildcardFilterMiddleware(logger, remote, filter, "NewFilterQueryStringsMiddleware", func(r *Request) *Request {
+			query := filter.Filter(r.Query)
+			if len(query) == len(r.Query) {
+				return r
+			}
+			res := r.Clone()
+			res.Query = query
+			return &res
+		})
+	}
+	queryStringsToPass := filter.Allowed
 
 	return func(next ...Proxy) Proxy {
 		if len(next) > 1 {
@@ -28,7 +42,7 @@ func NewFilterQueryStringsMiddleware(logger logging.Logger, remote *config.Backe
 				return nextProxy(ctx, request)
 			}
 			numQueryStringsToPass := 0
-			for _, v := range remote.QueryStringsToPass {
+			for _, v := range queryStringsToPass {
 				if _, ok := request.Query[v]; ok {
 					numQueryStringsToPass++
 				}
@@ -45,7 +59,7 @@ func NewFilterQueryStringsMiddleware(logger logging.Logger, remote *config.Backe
 			// that should be done at an upper level (so the approach is the same
 			// for non filtered parallel requests).
 			newQueryStrings := make(url.Values, numQueryStringsToPass)
-			for _, v := range remote.QueryStringsToPass {
+			for _, v := range queryStringsToPass {
 				if values, ok := request.Query[v]; ok {
 					newQueryStrings[v] = values
 				}
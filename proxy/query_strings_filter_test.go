@@ -166,3 +166,46 @@ func TestFilterQueryStringsAllowAll(t *testing.T) {
 		return
 	}
 }
+
+func TestFilterQueryStringsWildcard(t *testing.T) {
+	mw := NewFilterQueryStringsMiddleware(
+		logging.NoOp,
+		&config.Backend{
+			QueryStringsToPass: []string{"*", "!maple"},
+		},
+	)
+
+	var receivedReq *Request
+	prxy := mw(func(ctx context.Context, req *Request) (*Response, error) {
+		receivedReq = req
+		return nil, nil
+	})
+
+	sentReq := &Request{
+		Params: map[string]string{},
+		Query: map[string][]string{
+			"oak":   {"acorn", "evergreen"},
+			"maple": {"tree", "shrub"},
+			"cedar": {"mediterranean", "himalayas"},
+		},
+	}
+
+	prxy(context.Background(), sentReq)
+
+	if len(receivedReq.Query) != 2 {
+		t.Errorf("unexpected query strings: %v", receivedReq.Query)
+	}
+	if _, ok := receivedReq.Query["maple"]; ok {
+		t.Error("the denied query string should not pass")
+	}
+
+	mw = NewFilterQueryStringsMiddleware(logging.NoOp, &config.Backend{QueryStringsToPass: []string{"*"}})
+	prxy = mw(func(ctx context.Context, req *Request) (*Response, error) {
+		receivedReq = req
+		return nil, nil
+	})
+	prxy(context.Background(), sentReq)
+	if receivedReq != sentReq {
+		t.Error("the request should pass untouched")
+	}
+}
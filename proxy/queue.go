@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/queue"
+)
+
+// NewQueuePublisherProxy returns a proxy publishing the requests to the broker declared by the
+// backend, instead of sending them to a service. The message is rendered from the params, the
+// query string and the JSON body of the request, or it is the body of the request when the
+// backend does not declare a template. The responses just acknowledge the publication, with the
+// declared status code. The publisher connects to the broker with the first request
+func NewQueuePublisherProxy(logger logging.Logger, remote *config.Backend) Proxy {
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Queue]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	opt, err := queue.GetOptions(remote.ExtraConfig)
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return failingProxy(err)
+	}
+	renderer, err := queue.New(*opt)
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return failingProxy(err)
+	}
+	pf, _ := queue.GetDriver(opt.Driver)
+	publisher, err := pf(*opt)
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return failingProxy(err)
+	}
+	logger.Debug(logPrefix, "Publishing the requests with the", opt.Driver, "driver")
+
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		data := queue.TemplateData{Params: req.Params, Query: map[string]string{}}
+		for k := range req.Query {
+			data.Query[k] = req.Query.Get(k)
+		}
+		var raw []byte
+		if req.Body != nil {
+			var err error
+			raw, err = io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			if renderer.Templated() && len(bytes.TrimSpace(raw)) > 0 {
+				if err := json.Unmarshal(raw, &data.Body); err != nil {
+					return nil, fmt.Errorf("queue: decoding the request body: %w", err)
+				}
+			}
+		}
+
+		m, err := renderer.Render(data, raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := publisher.Publish(ctx, m); err != nil {
+			logger.Warning(logPrefix, "Publishing the message:", err.Error())
+			return nil, err
+		}
+		return &Response{
+			Data:       map[string]interface{}{"published": true},
+			IsComplete: true,
+			Metadata:   Metadata{StatusCode: opt.AckStatus},
+		}, nil
+	}
+}
+
+func failingProxy(err error) Proxy {
+	return func(_ context.Context, _ *Request) (*Response, error) {
+		return nil, err
+	}
+}
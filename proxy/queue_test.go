@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/queue"
+)
+
+type recordingPublisher struct {
+	messages []queue.Message
+	err      error
+}
+
+func (r *recordingPublisher) Publish(_ context.Context, m queue.Message) error {
+	r.messages = append(r.messages, m)
+	return r.err
+}
+
+func (*recordingPublisher) Close() error { return nil }
+
+func TestNewQueuePublisherProxy(t *testing.T) {
+	publisher := &recordingPublisher{}
+	queue.RegisterDriver("recording", func(queue.Options) (queue.Publisher, error) { return publisher, nil })
+
+	p := NewQueuePublisherProxy(logging.NoOp, &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			queue.Namespace: map[string]interface{}{
+				"driver":      "recording",
+				"url":         "recording://",
+				"routing_key": "orders.{{.Params.Tenant}}",
+				"template":    `{"page": {{json .Query.page}}, "total": {{.Body.total}}}`,
+			},
+		},
+	})
+	resp, err := p(context.Background(), &Request{
+		Params: map[string]string{"Tenant": "acme"},
+		Query:  url.Values{"page": {"2"}},
+		Body:   io.NopCloser(strings.NewReader(`{"total": 10}`)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Metadata.StatusCode != queue.DefaultAckStatus || !resp.IsComplete || resp.Data["published"] != true {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if len(publisher.messages) != 1 {
+		t.Fatalf("unexpected messages %v", publisher.messages)
+	}
+	if m := publisher.messages[0]; m.Topic != "orders.acme" || string(m.Body) != `{"page": "2", "total": 10}` {
+		t.Errorf("unexpected message %s %s", m.Topic, m.Body)
+	}
+
+	publisher.err = errors.New("nack")
+	if _, err := p(context.Background(), &Request{}); err != publisher.err {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestNewQueuePublisherProxy_ko(t *testing.T) {
+	p := NewQueuePublisherProxy(logging.NoOp, &config.Backend{
+		ExtraConfig: config.ExtraConfig{queue.Namespace: map[string]interface{}{"driver": "unknown"}},
+	})
+	if _, err := p(context.Background(), &Request{}); err == nil {
+		t.Error("expecting an error")
+	}
+}
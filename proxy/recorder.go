@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/recorder"
+)
+
+// NewBackendRecorderMiddleware creates a proxy middleware adding the responses of the backend to
+// the record of the request, if the router is recording it
+func NewBackendRecorderMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: NewBackendRecorderMiddleware only accepts 1 proxy, got %d", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			rec, ok := recorder.FromContext(ctx)
+			if !ok {
+				return next[0](ctx, r)
+			}
+			capture := &metadataCapture{}
+			start := time.Now()
+			resp, err := next[0](newMetadataCaptureContext(ctx, capture), r)
+
+			b := recorder.Backend{URLPattern: remote.URLPattern, Elapsed: time.Since(start)}
+			meta := capture.metadata()
+			if resp != nil && meta.StatusCode == 0 {
+				meta = resp.Metadata
+			}
+			b.Status, b.Headers = meta.StatusCode, CloneRequestHeaders(meta.Headers)
+			if resp != nil {
+				b.Data = resp.Data
+			}
+			if err != nil {
+				b.Error = err.Error()
+			}
+			rec.AddBackend(b)
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/recorder"
+)
+
+func TestNewBackendRecorderMiddleware(t *testing.T) {
+	remote := &config.Backend{URLPattern: "/a"}
+	expectedErr := errors.New("boom")
+	p := NewBackendRecorderMiddleware(logging.NoOp, remote)(func(ctx context.Context, _ *Request) (*Response, error) {
+		captureMetadata(ctx, &http.Response{StatusCode: http.StatusBadGateway, Header: http.Header{"X-A": {"1"}}})
+		return nil, expectedErr
+	})
+
+	if _, err := p(context.Background(), &Request{}); err != expectedErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	rec := &recorder.Record{}
+	if _, err := p(recorder.NewContext(context.Background(), rec), &Request{}); err != expectedErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(rec.Backends) != 1 {
+		t.Fatalf("unexpected backends: %+v", rec.Backends)
+	}
+	b := rec.Backends[0]
+	if b.URLPattern != "/a" || b.Status != http.StatusBadGateway || b.Error != "boom" || b.Headers["X-A"][0] != "1" {
+		t.Errorf("unexpected backend: %+v", b)
+	}
+}
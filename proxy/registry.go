@@ -0,0 +1,215 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/register"
+)
+
+// EndpointMiddlewareFactory creates the middleware for an endpoint pipe
+type EndpointMiddlewareFactory func(logging.Logger, *config.EndpointConfig) Middleware
+
+// BackendMiddlewareFactory creates the middleware for a backend pipe
+type BackendMiddlewareFactory func(logging.Logger, *config.Backend) Middleware
+
+// MiddlewareDefinition declares a named middleware and its ordering constraints. The
+// middlewares placed first in the resolved order wrap the ones placed after them, so
+// "auth before rate-limit" means the auth middleware is executed first
+type MiddlewareDefinition struct {
+	Name     string
+	Before   []string
+	After    []string
+	Requires []string
+	Endpoint EndpointMiddlewareFactory
+	Backend  BackendMiddlewareFactory
+}
+
+// MiddlewareRegistry collects middleware definitions and composes them in a deterministic order
+type MiddlewareRegistry struct {
+	mu          sync.RWMutex
+	definitions []MiddlewareDefinition
+}
+
+// NewMiddlewareRegistry returns an empty MiddlewareRegistry
+func NewMiddlewareRegistry() *MiddlewareRegistry {
+	return &MiddlewareRegistry{}
+}
+
+// Register adds the definition to the registry
+func (m *MiddlewareRegistry) Register(def MiddlewareDefinition) error {
+	if def.Endpoint == nil && def.Backend == nil {
+		return fmt.Errorf("the middleware %s has no factories", def.Name)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.definitions {
+		if d.Name == def.Name {
+			return fmt.Errorf("the middleware %s is already registered", def.Name)
+		}
+	}
+	m.definitions = append(m.definitions, def)
+	return nil
+}
+
+// Order returns the names of the registered middlewares in the order they will be composed
+func (m *MiddlewareRegistry) Order() ([]string, error) {
+	defs, err := m.sorted()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(defs))
+	for i, d := range defs {
+		names[i] = d.Name
+	}
+	return names, nil
+}
+
+// EndpointMiddleware returns a middleware composing all the endpoint middlewares in order
+func (m *MiddlewareRegistry) EndpointMiddleware(logger logging.Logger, cfg *config.EndpointConfig) (Middleware, error) {
+	defs, err := m.sorted()
+	if err != nil {
+		return nil, err
+	}
+	mws := []Middleware{}
+	for _, d := range defs {
+		if d.Endpoint != nil {
+			mws = append(mws, d.Endpoint(logger, cfg))
+		}
+	}
+	return chainMiddlewares(mws), nil
+}
+
+// BackendMiddleware returns a middleware composing all the backend middlewares in order
+func (m *MiddlewareRegistry) BackendMiddleware(logger logging.Logger, remote *config.Backend) (Middleware, error) {
+	defs, err := m.sorted()
+	if err != nil {
+		return nil, err
+	}
+	mws := []Middleware{}
+	for _, d := range defs {
+		if d.Backend != nil {
+			mws = append(mws, d.Backend(logger, remote))
+		}
+	}
+	return chainMiddlewares(mws), nil
+}
+
+func (m *MiddlewareRegistry) sorted() ([]MiddlewareDefinition, error) {
+	m.mu.RLock()
+	defs := make([]MiddlewareDefinition, len(m.definitions))
+	copy(defs, m.definitions)
+	m.mu.RUnlock()
+
+	constraints := make([]register.Constraint, len(defs))
+	byName := make(map[string]MiddlewareDefinition, len(defs))
+	for i, d := range defs {
+		constraints[i] = register.Constraint{Name: d.Name, Before: d.Before, After: d.After, Requires: d.Requires}
+		byName[d.Name] = d
+	}
+	names, err := register.Order(constraints)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]MiddlewareDefinition, len(names))
+	for i, n := range names {
+		res[i] = byName[n]
+	}
+	return res, nil
+}
+
+// chainMiddlewares composes the middlewares, so the first one wraps all the others
+func chainMiddlewares(mws []Middleware) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(mws) == 0 {
+			return next[0]
+		}
+		p := mws[len(mws)-1](next...)
+		for i := len(mws) - 2; i >= 0; i-- {
+			p = mws[i](p)
+		}
+		return p
+	}
+}
+
+// NewFactoryWithRegistry returns a Factory wrapping the endpoint proxies created by the received
+// factory with the endpoint middlewares of the registry
+func NewFactoryWithRegistry(f Factory, r *MiddlewareRegistry, logger logging.Logger) Factory {
+	return FactoryFunc(func(cfg *config.EndpointConfig) (Proxy, error) {
+		p, err := f.New(cfg)
+		if err != nil {
+			return p, err
+		}
+		mw, err := r.EndpointMiddleware(logger, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return mw(p), nil
+	})
+}
+
+// NewBackendFactoryWithRegistry returns a BackendFactory wrapping the backend proxies created by the
+// received factory with the backend middlewares of the registry. The ordering errors are logged and
+// the backend proxy is returned without the registry middlewares
+func NewBackendFactoryWithRegistry(bf BackendFactory, r *MiddlewareRegistry, logger logging.Logger) BackendFactory {
+	return func(remote *config.Backend) Proxy {
+		p := bf(remote)
+		mw, err := r.BackendMiddleware(logger, remote)
+		if err != nil {
+			logger.Error(fmt.Sprintf("[BACKEND: %s] Unable to order the middlewares: %s", remote.URLPattern, err.Error()))
+			return p
+		}
+		return mw(p)
+	}
+}
+
+// NewDefaultMiddlewareRegistry returns a MiddlewareRegistry declaring the middlewares composed by
+// the default factory around the endpoint pipes and around the instrumented backend pipes, so the
+// custom middlewares can be placed relative to them. The built-in middlewares are named after
+// their constructors, and the backend ones are prefixed with "backend-"
+func NewDefaultMiddlewareRegistry() *MiddlewareRegistry {
+	r := NewMiddlewareRegistry()
+	for _, def := range defaultMiddlewares() {
+		r.Register(def)
+	}
+	return r
+}
+
+func defaultMiddlewares() []MiddlewareDefinition {
+	return []MiddlewareDefinition{
+		{Name: "meta-headers", Endpoint: NewMetaHeadersMiddleware},
+		{Name: "telemetry", After: []string{"meta-headers"}, Endpoint: NewTelemetryMiddleware},
+		{Name: "budget", After: []string{"telemetry"}, Endpoint: NewBudgetMiddleware},
+		{Name: "schedule", After: []string{"budget"}, Endpoint: NewScheduleMiddleware},
+		{Name: "cache", After: []string{"schedule"}, Endpoint: NewCacheMiddleware},
+		{Name: "static", After: []string{"cache"}, Endpoint: NewStaticMiddleware},
+		{Name: "masking", After: []string{"static"}, Endpoint: NewMaskingMiddleware},
+		{Name: "wasm", After: []string{"masking"}, Endpoint: NewWASMMiddleware},
+		{Name: "script", After: []string{"wasm"}, Endpoint: NewScriptMiddleware},
+		{Name: "plugin", After: []string{"script"}, Endpoint: NewPluginMiddleware},
+		{Name: "cookie-policy", After: []string{"plugin"}, Endpoint: NewCookiePolicyMiddleware},
+		{Name: "response-validation", After: []string{"cookie-policy"}, Endpoint: NewResponseValidationMiddleware},
+		{Name: "success-status", After: []string{"response-validation"}, Endpoint: NewSuccessStatusMiddleware},
+		{Name: "response-headers", After: []string{"success-status"}, Endpoint: NewResponseHeadersMiddleware},
+		// the enrichment is the innermost one, so the rest of the middlewares see the computed
+		// params
+		{Name: "enrichment", After: []string{"response-headers"}, Endpoint: NewEnrichmentMiddleware},
+
+		// the fallback answers the failures of the whole instrumented pipe
+		{Name: "backend-static-fallback", Backend: NewStaticBackendFallbackMiddleware},
+		{Name: "backend-recorder", After: []string{"backend-static-fallback"}, Backend: NewBackendRecorderMiddleware},
+		{Name: "backend-metadata", After: []string{"backend-recorder"}, Backend: NewBackendMetadataMiddleware},
+		{Name: "backend-meta-headers", After: []string{"backend-metadata"}, Backend: NewBackendMetaHeadersMiddleware},
+		{Name: "backend-tracing", After: []string{"backend-meta-headers"}, Backend: NewBackendTracingMiddleware},
+		{Name: "backend-metrics", After: []string{"backend-tracing"}, Backend: NewBackendMetricsMiddleware},
+		{Name: "backend-collapse", After: []string{"backend-metrics"}, Backend: NewBackendCollapseMiddleware},
+		{Name: "backend-schedule", After: []string{"backend-collapse"}, Backend: NewBackendScheduleMiddleware},
+		{Name: "backend-request-builder", After: []string{"backend-schedule"}, Backend: NewRequestBuilderMiddlewareWithLogger},
+		// the pages are requested with the params already mapped by the request builder
+		{Name: "backend-pagination", After: []string{"backend-request-builder"}, Backend: NewPaginationMiddleware},
+	}
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestMiddlewareRegistry(t *testing.T) {
+	var trace []string
+	tracer := func(name string) Middleware {
+		return func(next ...Proxy) Proxy {
+			return func(ctx context.Context, r *Request) (*Response, error) {
+				trace = append(trace, name)
+				return next[0](ctx, r)
+			}
+		}
+	}
+	endpointFactory := func(name string) EndpointMiddlewareFactory {
+		return func(_ logging.Logger, _ *config.EndpointConfig) Middleware { return tracer(name) }
+	}
+
+	r := NewMiddlewareRegistry()
+	for _, d := range []MiddlewareDefinition{
+		{Name: "rate-limit", After: []string{"auth"}, Endpoint: endpointFactory("rate-limit")},
+		{Name: "auth", Endpoint: endpointFactory("auth")},
+		{Name: "metrics", Before: []string{"auth"}, Endpoint: endpointFactory("metrics")},
+		{Name: "backend-only", Backend: func(_ logging.Logger, _ *config.Backend) Middleware { return tracer("backend") }},
+	} {
+		if err := r.Register(d); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if err := r.Register(MiddlewareDefinition{Name: "auth", Endpoint: endpointFactory("auth")}); err == nil {
+		t.Error("expecting an error registering a duplicated middleware")
+	}
+
+	order, err := r.Order()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if s := strings.Join(order, ","); s != "metrics,auth,rate-limit,backend-only" {
+		t.Errorf("unexpected order: %s", s)
+	}
+
+	f := NewFactoryWithRegistry(FactoryFunc(func(_ *config.EndpointConfig) (Proxy, error) {
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			trace = append(trace, "proxy")
+			return &Response{IsComplete: true}, nil
+		}, nil
+	}), r, logging.NoOp)
+
+	p, err := f.New(&config.EndpointConfig{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	p(context.Background(), &Request{})
+	if s := strings.Join(trace, ","); s != "metrics,auth,rate-limit,proxy" {
+		t.Errorf("unexpected execution order: %s", s)
+	}
+
+	trace = nil
+	bf := NewBackendFactoryWithRegistry(func(_ *config.Backend) Proxy {
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			trace = append(trace, "proxy")
+			return &Response{IsComplete: true}, nil
+		}
+	}, r, logging.NoOp)
+	bf(&config.Backend{})(context.Background(), &Request{})
+	if s := strings.Join(trace, ","); s != "backend,proxy" {
+		t.Errorf("unexpected execution order: %s", s)
+	}
+}
+
+func TestMiddlewareRegistry_cycle(t *testing.T) {
+	r := NewMiddlewareRegistry()
+	noop := func(_ logging.Logger, _ *config.EndpointConfig) Middleware {
+		return emptyMiddlewareFallback(logging.NoOp)
+	}
+	r.Register(MiddlewareDefinition{Name: "a", Before: []string{"b"}, Endpoint: noop})
+	r.Register(MiddlewareDefinition{Name: "b", Before: []string{"a"}, Endpoint: noop})
+
+	f := NewFactoryWithRegistry(FactoryFunc(func(_ *config.EndpointConfig) (Proxy, error) {
+		return NoopProxy, nil
+	}), r, logging.NoOp)
+	if _, err := f.New(&config.EndpointConfig{}); err == nil {
+		t.Error("expecting an ordering error")
+	}
+}
+
+func TestNewDefaultMiddlewareRegistry(t *testing.T) {
+	order, err := NewDefaultMiddlewareRegistry().Order()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the order is resolved from the constraints, not from the declaration order
+	defs := defaultMiddlewares()
+	r := NewMiddlewareRegistry()
+	for i := len(defs) - 1; i >= 0; i-- {
+		if err := r.Register(defs[i]); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	reversed, err := r.Order()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := "meta-headers,telemetry,budget,schedule,cache,static,masking,wasm,script,plugin,cookie-policy," +
+		"response-validation,success-status,response-headers,enrichment"
+	expectedBackend := "backend-static-fallback,backend-recorder,backend-metadata,backend-meta-headers," +
+		"backend-tracing,backend-metrics,backend-collapse,backend-schedule,backend-request-builder,backend-pagination"
+	for _, o := range [][]string{order, reversed} {
+		var endpoint, backend []string
+		for _, name := range o {
+			if strings.HasPrefix(name, "backend-") {
+				backend = append(backend, name)
+				continue
+			}
+			endpoint = append(endpoint, name)
+		}
+		if s := strings.Join(endpoint, ","); s != expected {
+			t.Errorf("unexpected endpoint order: %s", s)
+		}
+		if s := strings.Join(backend, ","); s != expectedBackend {
+			t.Errorf("unexpected backend order: %s", s)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"io"
 	"net/url"
+	"strings"
 )
 
 // Request contains the data to send to the backend
@@ -19,8 +20,13 @@ type Request struct {
 	Headers map[string][]string
 }
 
-// GeneratePath takes a pattern and updates the path of the request
+// GeneratePath takes a pattern and updates the path of the request. The patterns calling the
+// functions of the funcmap registry ({{ lower .Id }}) are executed as templates
 func (r *Request) GeneratePath(URLPattern string) {
+	if path, ok := renderPath(URLPattern, r.Params); ok {
+		r.Path = path
+		return
+	}
 	if len(r.Params) == 0 {
 		r.Path = URLPattern
 		return
@@ -36,6 +42,71 @@ func (r *Request) GeneratePath(URLPattern string) {
 	r.Path = string(buff)
 }
 
+// PathEncoding declares how GeneratePathWithEncoding encodes the params of the request
+type PathEncoding struct {
+	// Path percent-encodes the params inserted in the path of the pattern
+	Path bool
+	// Query escapes the params inserted in the query string of the pattern
+	Query bool
+	// MultiSegment are the params spanning several path segments, so their slashes are kept
+	MultiSegment map[string]bool
+}
+
+// GeneratePathWithEncoding takes a pattern and updates the path of the request, encoding the
+// params as declared, so the values containing spaces, '#' or '?' do not break the URL
+func (r *Request) GeneratePathWithEncoding(URLPattern string, enc PathEncoding) {
+	if len(r.Params) == 0 || (!enc.Path && !enc.Query) {
+		r.GeneratePath(URLPattern)
+		return
+	}
+	if pathTemplate(URLPattern) != nil {
+		// the templates can not tell the path from the query, so the params are encoded as
+		// declared for the path, or for the query if the path is not encoded
+		params := make(map[string]string, len(r.Params))
+		for k, v := range r.Params {
+			if enc.Path {
+				params[k] = escapePathParam(v, enc.MultiSegment[k])
+			} else {
+				params[k] = url.QueryEscape(v)
+			}
+		}
+		if path, ok := renderPath(URLPattern, params); ok {
+			r.Path = path
+			return
+		}
+	}
+	path, query := URLPattern, ""
+	if i := strings.IndexByte(URLPattern, '?'); i >= 0 {
+		path, query = URLPattern[:i], URLPattern[i:]
+	}
+	pathValues := make([]string, 0, 2*len(r.Params))
+	queryValues := make([]string, 0, 2*len(r.Params))
+	for k, v := range r.Params {
+		key := "{{." + k + "}}"
+		pathValue, queryValue := v, v
+		if enc.Path {
+			pathValue = escapePathParam(v, enc.MultiSegment[k])
+		}
+		if enc.Query {
+			queryValue = url.QueryEscape(v)
+		}
+		pathValues = append(pathValues, key, pathValue)
+		queryValues = append(queryValues, key, queryValue)
+	}
+	r.Path = strings.NewReplacer(pathValues...).Replace(path) + strings.NewReplacer(queryValues...).Replace(query)
+}
+
+func escapePathParam(v string, multiSegment bool) string {
+	if !multiSegment {
+		return url.PathEscape(v)
+	}
+	segments := strings.Split(v, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
 // Clone clones itself into a new request. The returned cloned request is not
 // thread-safe, so changes on request.Params and request.Headers could generate
 // race-conditions depending on the part of the pipe they are being executed.
@@ -54,7 +125,10 @@ func (r *Request) Clone() Request {
 }
 
 // CloneRequest returns a deep copy of the received request, so the received and the
-// returned proxy.Request do not share a pointer
+// returned proxy.Request do not share a pointer. The body is buffered into a RewindableBody
+// shared by both requests, each one with its own read offset, so it can be read by every clone.
+// If the body can not be buffered, the received request keeps it and the clone gets a body
+// failing with the error
 func CloneRequest(r *Request) *Request {
 	clone := r.Clone()
 	clone.Headers = CloneRequestHeaders(r.Headers)
@@ -62,12 +136,12 @@ func CloneRequest(r *Request) *Request {
 	if r.Body == nil {
 		return &clone
 	}
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(r.Body)
-	r.Body.Close()
-
-	r.Body = io.NopCloser(bytes.NewReader(buf.Bytes()))
-	clone.Body = io.NopCloser(buf)
+	b, err := r.BufferBody()
+	if err != nil {
+		clone.Body = failedBody{err: err}
+		return &clone
+	}
+	clone.Body = b.Clone()
 
 	return &clone
 }
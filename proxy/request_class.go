@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// RequestClass tells how safe is to repeat, duplicate or reuse the result of a request. The
+// middlewares sending the same request more than once (concurrent calls), sharing the
+// responses (caching) or cloning the request between backends rely on it instead of guessing
+// from the HTTP method alone
+type RequestClass int
+
+const (
+	// RequestUnsafe is the class of the requests with side effects that must be sent only once
+	RequestUnsafe RequestClass = iota
+	// RequestIdempotent is the class of the requests with side effects that can be retried or
+	// duplicated, because repeating them has the same effect than sending them once
+	RequestIdempotent
+	// RequestSafe is the class of the read-only requests
+	RequestSafe
+)
+
+const requestClassKey = "request_class"
+
+var requestClassNames = map[string]RequestClass{
+	"unsafe":     RequestUnsafe,
+	"idempotent": RequestIdempotent,
+	"safe":       RequestSafe,
+}
+
+// String returns the name of the class, as declared in the config
+func (c RequestClass) String() string {
+	switch c {
+	case RequestSafe:
+		return "safe"
+	case RequestIdempotent:
+		return "idempotent"
+	default:
+		return "unsafe"
+	}
+}
+
+// IsSafe returns true if the request does not modify the state of the backend, so its response
+// can be shared with other requests
+func (c RequestClass) IsSafe() bool { return c == RequestSafe }
+
+// IsRetrySafe returns true if the request can be sent more than once
+func (c RequestClass) IsRetrySafe() bool { return c >= RequestIdempotent }
+
+// RequestClassifier returns the class of the requests using the received method
+type RequestClassifier func(method string) RequestClass
+
+// DefaultRequestClass returns the class of the method as defined by RFC 9110. As in net/http, an
+// empty method means GET
+func DefaultRequestClass(method string) RequestClass {
+	switch strings.ToUpper(method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return RequestSafe
+	case http.MethodPut, http.MethodDelete:
+		return RequestIdempotent
+	default:
+		return RequestUnsafe
+	}
+}
+
+// NewRequestClassifier returns the RequestClassifier declared in the extra configs, falling back
+// to DefaultRequestClass. The declarations of the last configs override the previous ones, so
+// the backends can refine the classes of their endpoint. The class can be declared for all
+// the methods or per method:
+//
+//	"extra_config": {
+//		"github.com/devopsfaith/krakend/proxy": {
+//			"request_class": {"POST": "idempotent", "PATCH": "idempotent"}
+//		}
+//	}
+//
+// Unknown classes are ignored
+func NewRequestClassifier(extras ...config.ExtraConfig) RequestClassifier {
+	var all *RequestClass
+	perMethod := map[string]RequestClass{}
+	for _, extra := range extras {
+		e, ok := extra[Namespace].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch v := e[requestClassKey].(type) {
+		case string:
+			if c, ok := requestClassNames[strings.ToLower(v)]; ok {
+				all = &c
+				perMethod = map[string]RequestClass{}
+			}
+		case map[string]interface{}:
+			for m, name := range v {
+				s, _ := name.(string)
+				if c, ok := requestClassNames[strings.ToLower(s)]; ok {
+					perMethod[strings.ToUpper(m)] = c
+				}
+			}
+		}
+	}
+
+	return func(method string) RequestClass {
+		if c, ok := perMethod[strings.ToUpper(method)]; ok {
+			return c
+		}
+		if all != nil {
+			return *all
+		}
+		return DefaultRequestClass(method)
+	}
+}
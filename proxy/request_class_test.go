@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewRequestClassifier(t *testing.T) {
+	endpoint := config.ExtraConfig{Namespace: map[string]interface{}{
+		requestClassKey: map[string]interface{}{"post": "idempotent", "PATCH": "whatever"},
+	}}
+	backend := config.ExtraConfig{Namespace: map[string]interface{}{
+		requestClassKey: map[string]interface{}{"GET": "unsafe"},
+	}}
+
+	for _, tc := range []struct {
+		name     string
+		classify RequestClassifier
+		method   string
+		expected RequestClass
+	}{
+		{"default get", NewRequestClassifier(), "get", RequestSafe},
+		{"default empty", NewRequestClassifier(), "", RequestSafe},
+		{"default put", NewRequestClassifier(), "PUT", RequestIdempotent},
+		{"default post", NewRequestClassifier(), "POST", RequestUnsafe},
+		{"endpoint post", NewRequestClassifier(endpoint), "POST", RequestIdempotent},
+		{"unknown class", NewRequestClassifier(endpoint), "PATCH", RequestUnsafe},
+		{"backend override", NewRequestClassifier(endpoint, backend), "GET", RequestUnsafe},
+		{"inherited", NewRequestClassifier(endpoint, backend), "POST", RequestIdempotent},
+		{"all methods", NewRequestClassifier(config.ExtraConfig{Namespace: map[string]interface{}{requestClassKey: "safe"}}), "POST", RequestSafe},
+	} {
+		if c := tc.classify(tc.method); c != tc.expected {
+			t.Errorf("%s: unexpected class %s", tc.name, c)
+		}
+	}
+
+	if !RequestIdempotent.IsRetrySafe() || RequestIdempotent.IsSafe() || RequestUnsafe.IsRetrySafe() {
+		t.Error("unexpected class semantics")
+	}
+}
+
+func TestNewConcurrentMiddleware_unsafe(t *testing.T) {
+	for _, tc := range []struct {
+		extra    config.ExtraConfig
+		expected int32
+	}{
+		{extra: config.ExtraConfig{}, expected: 1},
+		{extra: config.ExtraConfig{Namespace: map[string]interface{}{requestClassKey: "idempotent"}}, expected: 3},
+	} {
+		backend := config.Backend{
+			Method:          "POST",
+			ConcurrentCalls: 3,
+			Timeout:         time.Second,
+			ExtraConfig:     tc.extra,
+		}
+		var calls int32
+		p := NewConcurrentMiddlewareWithLogger(logging.NoOp, &backend)(func(_ context.Context, _ *Request) (*Response, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(10 * time.Millisecond)
+			return &Response{IsComplete: true}, nil
+		})
+		if _, err := p(context.Background(), &Request{Method: "POST"}); err != nil {
+			t.Error(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if c := atomic.LoadInt32(&calls); c != tc.expected {
+			t.Errorf("unexpected number of calls: %d", c)
+		}
+	}
+}
+
+func TestNewCacheMiddleware_requestClass(t *testing.T) {
+	endpoint := config.EndpointConfig{
+		Endpoint: "/search",
+		Method:   "POST",
+		CacheTTL: time.Minute,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				cacheKey:        map[string]interface{}{"max_size": 1024.0},
+				requestClassKey: map[string]interface{}{"POST": "safe"},
+			},
+		},
+	}
+
+	calls := 0
+	p := NewCacheMiddleware(logging.NoOp, &endpoint)(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		return &Response{Data: map[string]interface{}{"supu": 42.0}, IsComplete: true}, nil
+	})
+	for i := 0; i < 3; i++ {
+		if _, err := p(context.Background(), &Request{Method: "POST", Path: "/search"}); err != nil {
+			t.Error(err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("the requests declared as safe should be cached. calls: %d", calls)
+	}
+}
@@ -147,3 +147,45 @@ func TestCloneRequest(t *testing.T) {
 		t.Errorf("unexpected bodies. original: %s, returned: %s", string(rb), string(cb))
 	}
 }
+
+func TestRequestGeneratePathWithEncoding(t *testing.T) {
+	r := Request{
+		Method: "GET",
+		Params: map[string]string{
+			"Id":   "a b#c?d",
+			"Path": "dir one/file?.txt",
+			"Q":    "x&y=z",
+		},
+	}
+
+	for i, testCase := range []struct {
+		enc      PathEncoding
+		pattern  string
+		expected string
+	}{
+		{
+			enc:      PathEncoding{Path: true, Query: true},
+			pattern:  "/a/{{.Id}}?q={{.Q}}&id={{.Id}}",
+			expected: "/a/a%20b%23c%3Fd?q=x%26y%3Dz&id=a+b%23c%3Fd",
+		},
+		{
+			enc:      PathEncoding{Path: true, MultiSegment: map[string]bool{"Path": true}},
+			pattern:  "/files/{{.Path}}?q={{.Q}}",
+			expected: "/files/dir%20one/file%3F.txt?q=x&y=z",
+		},
+		{
+			enc:      PathEncoding{Path: true},
+			pattern:  "/files/{{.Path}}",
+			expected: "/files/dir%20one%2Ffile%3F.txt",
+		},
+		{
+			pattern:  "/a/{{.Q}}",
+			expected: "/a/x&y=z",
+		},
+	} {
+		r.GeneratePathWithEncoding(testCase.pattern, testCase.enc)
+		if r.Path != testCase.expected {
+			t.Errorf("%d: want %s, have %s", i, testCase.expected, r.Path)
+		}
+	}
+}
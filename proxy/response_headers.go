@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	responseHeadersKey = "response_headers"
+
+	headerMergeFirst  = "first"
+	headerMergeLast   = "last"
+	headerMergeAppend = "append"
+)
+
+// responseHeadersPolicy declares the headers of the backend responses copied into the responses
+// of an endpoint not using the no-op encoding:
+//
+//	"extra_config": {
+//		"github.com/devopsfaith/krakend/proxy": {
+//			"response_headers": {
+//				"headers": ["Content-Disposition", "Cache-Control", "X-Rate-Limit"],
+//				"merge": "first",
+//				"priority": [1, 0],
+//				"rules": {"X-Rate-Limit": "append"}
+//			}
+//		}
+//	}
+//
+// When several backends return the same header, the merge strategy decides the value copied:
+// "first" (the default) keeps the one of the backend with the highest priority, "last" the one of
+// the backend with the lowest priority and "append" keeps all of them. The priority lists the
+// indexes of the backends from the highest priority, and the backends not listed follow in their
+// declaration order. The rules override the merge strategy of single headers
+type responseHeadersPolicy struct {
+	headers  []string
+	strategy string
+	rules    map[string]string
+	order    []int
+}
+
+// getResponseHeadersPolicy returns the response headers policy of the endpoint, if declared
+func getResponseHeadersPolicy(cfg *config.EndpointConfig) (*responseHeadersPolicy, error) {
+	e, ok := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	v, ok := e[responseHeadersKey].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	p := &responseHeadersPolicy{strategy: headerMergeFirst, rules: map[string]string{}}
+	raw, _ := v["headers"].([]interface{})
+	for _, h := range raw {
+		name, ok := h.(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid response header %v", h)
+		}
+		p.headers = append(p.headers, http.CanonicalHeaderKey(name))
+	}
+	if len(p.headers) == 0 {
+		return nil, fmt.Errorf("no response headers declared")
+	}
+
+	if m, ok := v["merge"].(string); ok && m != "" {
+		p.strategy = m
+	}
+	if !isHeaderMergeStrategy(p.strategy) {
+		return nil, fmt.Errorf("unknown header merge strategy %q", p.strategy)
+	}
+	rules, _ := v["rules"].(map[string]interface{})
+	for h, r := range rules {
+		m, _ := r.(string)
+		if !isHeaderMergeStrategy(m) {
+			return nil, fmt.Errorf("unknown header merge strategy %v for the header %s", r, h)
+		}
+		p.rules[http.CanonicalHeaderKey(h)] = m
+	}
+
+	listed := map[int]bool{}
+	priority, _ := v["priority"].([]interface{})
+	for _, i := range priority {
+		f, ok := i.(float64)
+		idx := int(f)
+		if !ok || f != float64(idx) || idx < 0 || idx >= len(cfg.Backend) || listed[idx] {
+			return nil, fmt.Errorf("invalid backend %v in the header priority", i)
+		}
+		listed[idx] = true
+		p.order = append(p.order, idx)
+	}
+	for i := range cfg.Backend {
+		if !listed[i] {
+			p.order = append(p.order, i)
+		}
+	}
+	return p, nil
+}
+
+func isHeaderMergeStrategy(m string) bool {
+	return m == headerMergeFirst || m == headerMergeLast || m == headerMergeAppend
+}
+
+// merge returns the headers to copy from the metadata of the responses of every backend
+func (p *responseHeadersPolicy) merge(collected []Metadata) http.Header {
+	res := http.Header{}
+	for _, name := range p.headers {
+		strategy := p.strategy
+		if m, ok := p.rules[name]; ok {
+			strategy = m
+		}
+		for _, i := range p.order {
+			vs := http.Header(collected[i].Headers)[name]
+			if len(vs) == 0 {
+				continue
+			}
+			switch strategy {
+			case headerMergeAppend:
+				res[name] = append(res[name], vs...)
+			case headerMergeLast:
+				res[name] = vs
+			default:
+				if _, ok := res[name]; !ok {
+					res[name] = vs
+				}
+			}
+		}
+	}
+	return res
+}
+
+// NewResponseHeadersMiddleware creates a proxy middleware copying the headers of the backend
+// responses declared by the response_headers option of the endpoint into its responses with
+// content. The NewBackendMetadataMiddleware of the backends record their headers
+func NewResponseHeadersMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	policy, err := getResponseHeadersPolicy(endpointConfig)
+	logPrefix := "[ENDPOINT: " + endpointConfig.Endpoint + "][ResponseHeaders]"
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return emptyMiddlewareFallback(logger)
+	}
+	if policy == nil {
+		return emptyMiddlewareFallback(logger)
+	}
+	if endpointConfig.OutputEncoding == encoding.NOOP {
+		logger.Warning(logPrefix, "The no-op endpoints already return the headers of their backend")
+		return emptyMiddlewareFallback(logger)
+	}
+	logger.Debug(logPrefix, "Copying the backend headers", policy.headers)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewResponseHeadersMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			ctx, metas := withBackendMetadata(ctx, endpointConfig.Backend)
+			resp, err := next[0](ctx, r)
+			if resp == nil || len(resp.Data) == 0 {
+				return resp, err
+			}
+			headers := policy.merge(metas.collected())
+			if len(headers) == 0 {
+				return resp, err
+			}
+			// the response could be shared, so the headers are added to a copy
+			res := *resp
+			res.Metadata.Headers = CloneRequestHeaders(resp.Metadata.Headers)
+			for k, vs := range headers {
+				res.Metadata.Headers[k] = vs
+			}
+			return &res, err
+		}
+	}
+}
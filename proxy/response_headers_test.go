@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestGetResponseHeadersPolicy(t *testing.T) {
+	backends := []*config.Backend{{}, {}, {}}
+	for i, tc := range []struct {
+		policy map[string]interface{}
+		order  []int
+		err    bool
+	}{
+		{map[string]interface{}{"headers": []interface{}{"cache-control"}}, []int{0, 1, 2}, false},
+		{map[string]interface{}{"headers": []interface{}{"X-A"}, "priority": []interface{}{2.0}}, []int{2, 0, 1}, false},
+		{map[string]interface{}{"headers": []interface{}{"X-A"}, "merge": "append", "rules": map[string]interface{}{"x-a": "last"}}, []int{0, 1, 2}, false},
+		{map[string]interface{}{}, nil, true},
+		{map[string]interface{}{"headers": []interface{}{"X-A"}, "merge": "random"}, nil, true},
+		{map[string]interface{}{"headers": []interface{}{"X-A"}, "rules": map[string]interface{}{"X-A": "random"}}, nil, true},
+		{map[string]interface{}{"headers": []interface{}{"X-A"}, "priority": []interface{}{3.0}}, nil, true},
+		{map[string]interface{}{"headers": []interface{}{"X-A"}, "priority": []interface{}{1.0, 1.0}}, nil, true},
+	} {
+		p, err := getResponseHeadersPolicy(&config.EndpointConfig{
+			Backend:     backends,
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{responseHeadersKey: tc.policy}},
+		})
+		if (err != nil) != tc.err {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(p.order) != len(tc.order) {
+			t.Errorf("#%d: unexpected order: %v", i, p.order)
+			continue
+		}
+		for j := range tc.order {
+			if p.order[j] != tc.order[j] {
+				t.Errorf("#%d: unexpected order: %v", i, p.order)
+				break
+			}
+		}
+	}
+
+	if p, err := getResponseHeadersPolicy(&config.EndpointConfig{Backend: backends}); p != nil || err != nil {
+		t.Errorf("unexpected result: %v %v", p, err)
+	}
+}
+
+func TestNewResponseHeadersMiddleware(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/a",
+		Timeout:  time.Second,
+		Backend:  []*config.Backend{{URLPattern: "/a"}, {URLPattern: "/b"}},
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				responseHeadersKey: map[string]interface{}{
+					"headers":  []interface{}{"Cache-Control", "X-Rate-Limit", "Content-Disposition"},
+					"priority": []interface{}{1.0},
+					"rules":    map[string]interface{}{"X-Rate-Limit": "append"},
+				},
+			},
+		},
+	}
+	backend := func(i int, h http.Header) Proxy {
+		return NewBackendMetadataMiddleware(logging.NoOp, endpoint.Backend[i])(
+			func(ctx context.Context, _ *Request) (*Response, error) {
+				captureMetadata(ctx, &http.Response{StatusCode: 200, Header: h})
+				return &Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+			},
+		)
+	}
+	p := NewResponseHeadersMiddleware(logging.NoOp, endpoint)(
+		NewMergeDataMiddleware(logging.NoOp, endpoint)(
+			backend(0, http.Header{
+				"Cache-Control": {"max-age=60"},
+				"X-Rate-Limit":  {"10"},
+				"X-Internal":    {"secret"},
+			}),
+			backend(1, http.Header{
+				"Cache-Control":       {"no-cache"},
+				"X-Rate-Limit":        {"20"},
+				"Content-Disposition": {"attachment"},
+			}),
+		),
+	)
+
+	resp, err := p(context.Background(), &Request{Params: map[string]string{}, Headers: map[string][]string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := http.Header(resp.Metadata.Headers)
+	if v := h.Get("Cache-Control"); v != "no-cache" {
+		t.Errorf("the backend with the highest priority should win: %s", v)
+	}
+	if v := h.Values("X-Rate-Limit"); len(v) != 2 || v[0] != "20" || v[1] != "10" {
+		t.Errorf("unexpected appended values: %v", v)
+	}
+	if v := h.Get("Content-Disposition"); v != "attachment" {
+		t.Errorf("unexpected header: %s", v)
+	}
+	if _, ok := h["X-Internal"]; ok {
+		t.Error("the headers not declared should not be copied")
+	}
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/jsonschema"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+// NewResponseValidationMiddleware creates proxy middleware validating the merged responses of the
+// endpoint against the JSON schema declared in its extra config
+func NewResponseValidationMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	return newResponseValidationMiddleware(logger, endpointConfig.ExtraConfig, "[ENDPOINT: "+endpointConfig.Endpoint+"][ResponseValidation]")
+}
+
+// NewBackendResponseValidationMiddleware creates proxy middleware validating the responses of the
+// backend against the JSON schema declared in its extra config
+func NewBackendResponseValidationMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][ResponseValidation]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	return newResponseValidationMiddleware(logger, remote.ExtraConfig, logPrefix)
+}
+
+func newResponseValidationMiddleware(logger logging.Logger, e config.ExtraConfig, logPrefix string) Middleware {
+	cfg, ok, err := jsonschema.ConfigGetter(e)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	if err != nil {
+		// fail closed, so the clients never receive the responses the schema was meant to check
+		logger.Error(logPrefix, err.Error())
+		return func(next ...Proxy) Proxy {
+			if len(next) > 1 {
+				logger.Fatal("too many proxies for this proxy middleware: NewResponseValidationMiddleware only accepts 1 proxy, got %d", len(next))
+				return nil
+			}
+			return func(_ context.Context, _ *Request) (*Response, error) {
+				return nil, err
+			}
+		}
+	}
+
+	logger.Debug(logPrefix, "Validating the responses in", cfg.Mode, "mode")
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewResponseValidationMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			resp, err := next[0](ctx, r)
+			if resp == nil || resp.Data == nil {
+				return resp, err
+			}
+
+			if cfg.Mode == jsonschema.ModeStrip {
+				cfg.Schema.Strip(resp.Data)
+			}
+			violations := cfg.Schema.Validate(resp.Data)
+			if len(violations) == 0 {
+				return resp, err
+			}
+			if cfg.Mode == jsonschema.ModeReject {
+				return nil, jsonschema.ValidationError{Violations: violations}
+			}
+			for _, v := range violations {
+				logger.Warning(logPrefix, "Invalid response:", v.String())
+			}
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/jsonschema"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func responseValidationConfig(mode string) config.ExtraConfig {
+	return config.ExtraConfig{
+		jsonschema.Namespace: map[string]interface{}{
+			"schema": map[string]interface{}{
+				"type":     "object",
+				"required": []interface{}{"id"},
+				"properties": map[string]interface{}{
+					"id":   map[string]interface{}{"type": "integer"},
+					"name": map[string]interface{}{"type": "string"},
+				},
+			},
+			"mode": mode,
+		},
+	}
+}
+
+func TestNewResponseValidationMiddleware(t *testing.T) {
+	for _, tc := range []struct {
+		mode    string
+		data    map[string]interface{}
+		invalid bool
+		want    map[string]interface{}
+	}{
+		{mode: "reject", data: map[string]interface{}{"id": 1, "name": "jane"}, want: map[string]interface{}{"id": 1, "name": "jane"}},
+		{mode: "reject", data: map[string]interface{}{"id": "1"}, invalid: true},
+		{mode: "strip", data: map[string]interface{}{"id": 1, "password": "secret"}, want: map[string]interface{}{"id": 1}},
+		{mode: "strip", data: map[string]interface{}{"name": 1}, want: map[string]interface{}{"name": 1}},
+		{mode: "log", data: map[string]interface{}{"password": "secret"}, want: map[string]interface{}{"password": "secret"}},
+	} {
+		cfg := &config.EndpointConfig{Endpoint: "/users", ExtraConfig: responseValidationConfig(tc.mode)}
+		p := NewResponseValidationMiddleware(logging.NoOp, cfg)(func(_ context.Context, _ *Request) (*Response, error) {
+			return &Response{Data: tc.data, IsComplete: true}, nil
+		})
+		resp, err := p(context.Background(), &Request{})
+		if tc.invalid {
+			var verr jsonschema.ValidationError
+			if !errors.As(err, &verr) || resp != nil {
+				t.Errorf("%s: expecting a validation error, got %v %v", tc.mode, resp, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.mode, err)
+			continue
+		}
+		if len(resp.Data) != len(tc.want) {
+			t.Errorf("%s: unexpected data: %v", tc.mode, resp.Data)
+		}
+		for k, v := range tc.want {
+			if resp.Data[k] != v {
+				t.Errorf("%s: unexpected data: %v", tc.mode, resp.Data)
+			}
+		}
+	}
+}
+
+func TestNewBackendResponseValidationMiddleware_invalidConfig(t *testing.T) {
+	remote := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			jsonschema.Namespace: map[string]interface{}{"mode": "reject"},
+		},
+	}
+	called := false
+	p := NewBackendResponseValidationMiddleware(logging.NoOp, remote)(func(_ context.Context, _ *Request) (*Response, error) {
+		called = true
+		return &Response{}, nil
+	})
+	if _, err := p(context.Background(), &Request{}); err == nil {
+		t.Error("expecting an error for the missing schema")
+	}
+	if called {
+		t.Error("the backend should not be called")
+	}
+}
+
+func TestNewResponseValidationMiddleware_unconfigured(t *testing.T) {
+	mw := NewResponseValidationMiddleware(logging.NoOp, &config.EndpointConfig{})
+	if mw == nil {
+		t.Fatal("unexpected nil middleware")
+	}
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/schedule"
+)
+
+// NewScheduleMiddleware creates proxy middleware rejecting the requests to the endpoint arriving
+// out of the active windows declared in its extra config
+func NewScheduleMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	return newScheduleMiddleware(logger, endpointConfig.ExtraConfig, "[ENDPOINT: "+endpointConfig.Endpoint+"][Schedule]")
+}
+
+// NewBackendScheduleMiddleware creates proxy middleware rejecting the requests to the backend
+// arriving out of the active windows declared in its extra config
+func NewBackendScheduleMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Schedule]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	return newScheduleMiddleware(logger, remote.ExtraConfig, logPrefix)
+}
+
+func newScheduleMiddleware(logger logging.Logger, e config.ExtraConfig, logPrefix string) Middleware {
+	cfg, ok, err := schedule.ConfigGetter(e)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	var s *schedule.Schedule
+	if err == nil {
+		s, err = schedule.New(cfg)
+	}
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return emptyMiddlewareFallback(logger)
+	}
+
+	logger.Debug(logPrefix, fmt.Sprintf("Accepting requests in %d windows and %d cron schedules", len(cfg.Windows), len(cfg.Cron)))
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewScheduleMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			if err := s.Check(); err != nil {
+				return nil, err
+			}
+			return next[0](ctx, r)
+		}
+	}
+}
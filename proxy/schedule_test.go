@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/schedule"
+)
+
+func TestNewScheduleMiddleware(t *testing.T) {
+	called := 0
+	next := func(_ context.Context, _ *Request) (*Response, error) {
+		called++
+		return &Response{IsComplete: true}, nil
+	}
+
+	always := &config.EndpointConfig{
+		Endpoint: "/always",
+		ExtraConfig: config.ExtraConfig{
+			schedule.Namespace: map[string]interface{}{
+				"windows": []interface{}{map[string]interface{}{"start": "00:00", "end": "24:00"}},
+			},
+		},
+	}
+	if _, err := NewScheduleMiddleware(logging.NoOp, always)(next)(context.Background(), &Request{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	never := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			schedule.Namespace: map[string]interface{}{
+				"cron": []interface{}{map[string]interface{}{"expression": "0 0 30 2 *", "duration": "1m"}},
+			},
+		},
+	}
+	_, err := NewBackendScheduleMiddleware(logging.NoOp, never)(next)(context.Background(), &Request{})
+	if err != schedule.ErrClosed {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if called != 1 {
+		t.Errorf("unexpected number of calls: %d", called)
+	}
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/script"
+)
+
+// NewScriptMiddleware creates proxy middleware running the scripts declared in the extra config
+// of the endpoint before and after the rest of the pipe
+func NewScriptMiddleware(logger logging.Logger, endpoint *config.EndpointConfig) Middleware {
+	return newScriptMiddleware(logger, "[ENDPOINT: "+endpoint.Endpoint+"][Script]", endpoint.ExtraConfig)
+}
+
+// NewBackendScriptMiddleware creates proxy middleware running the scripts declared in the extra
+// config of the backend before and after the request to the backend
+func NewBackendScriptMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Script]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	return newScriptMiddleware(logger, logPrefix, remote.ExtraConfig)
+}
+
+func newScriptMiddleware(logger logging.Logger, logPrefix string, extra config.ExtraConfig) Middleware {
+	cfg, ok := script.ConfigGetter(extra)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	engine, ok := script.GetEngine(cfg.Engine)
+	if !ok {
+		logger.Error(logPrefix, "Unknown script engine:", cfg.Engine)
+		return errorMiddlewareFallback(logger, fmt.Errorf("script: unknown engine %s", cfg.Engine))
+	}
+
+	var pre, post script.Program
+	var err error
+	if cfg.Pre != nil {
+		if pre, err = script.Compile(engine, *cfg.Pre, script.PreStage); err != nil {
+			logger.Error(logPrefix, "Unable to compile the pre script:", err.Error())
+			return errorMiddlewareFallback(logger, err)
+		}
+	}
+	if cfg.Post != nil {
+		if post, err = script.Compile(engine, *cfg.Post, script.PostStage); err != nil {
+			logger.Error(logPrefix, "Unable to compile the post script:", err.Error())
+			return errorMiddlewareFallback(logger, err)
+		}
+	}
+
+	logger.Debug(logPrefix, "Running", cfg.Engine, "scripts. pre:", pre != nil, "post:", post != nil)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewScriptMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			req := scriptRequest(r)
+			if pre != nil {
+				if err := pre.Run(ctx, &script.Env{Stage: script.PreStage, Request: req}); err != nil {
+					return nil, err
+				}
+				updateRequest(r, req)
+			}
+
+			resp, err := next[0](ctx, r)
+			if post == nil || err != nil || resp == nil {
+				return resp, err
+			}
+
+			sr := &script.Response{
+				Data:       resp.Data,
+				IsComplete: resp.IsComplete,
+				StatusCode: resp.Metadata.StatusCode,
+				Headers:    resp.Metadata.Headers,
+			}
+			if err := post.Run(ctx, &script.Env{Stage: script.PostStage, Request: req, Response: sr}); err != nil {
+				return nil, err
+			}
+			resp.Data = sr.Data
+			resp.IsComplete = sr.IsComplete
+			resp.Metadata.StatusCode = sr.StatusCode
+			resp.Metadata.Headers = sr.Headers
+			return resp, nil
+		}
+	}
+}
+
+func scriptRequest(r *Request) *script.Request {
+	return &script.Request{
+		Method:  r.Method,
+		Path:    r.Path,
+		Params:  r.Params,
+		Headers: r.Headers,
+		Query:   r.Query,
+	}
+}
+
+func updateRequest(r *Request, sr *script.Request) {
+	r.Method = sr.Method
+	r.Path = sr.Path
+	r.Params = sr.Params
+	r.Headers = sr.Headers
+	r.Query = sr.Query
+}
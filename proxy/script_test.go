@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/script"
+)
+
+func TestNewScriptMiddleware(t *testing.T) {
+	script.RegisterEngine("proxy-test", script.EngineFunc(func(_, source string) (script.Program, error) {
+		return script.ProgramFunc(func(_ context.Context, env *script.Env) error {
+			switch source {
+			case "set-header":
+				env.Request.Headers["X-Script"] = []string{"pre"}
+			case "decorate":
+				env.Response.Data["stage"] = string(env.Stage)
+				env.Response.StatusCode = 201
+			case "fail":
+				return errors.New("rejected by the script")
+			}
+			return nil
+		}), nil
+	}))
+
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/scripted",
+		ExtraConfig: config.ExtraConfig{
+			script.Namespace: map[string]interface{}{
+				"engine": "proxy-test",
+				"pre":    map[string]interface{}{"source": "set-header"},
+				"post":   map[string]interface{}{"source": "decorate"},
+			},
+		},
+	}
+	p := NewScriptMiddleware(logging.NoOp, endpoint)(func(_ context.Context, r *Request) (*Response, error) {
+		if h := r.Headers["X-Script"]; len(h) != 1 || h[0] != "pre" {
+			t.Errorf("unexpected headers: %v", r.Headers)
+		}
+		return &Response{Data: map[string]interface{}{"foo": "bar"}, IsComplete: true}, nil
+	})
+
+	resp, err := p(context.Background(), &Request{Headers: map[string][]string{}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if resp.Data["stage"] != "post" || resp.Data["foo"] != "bar" || resp.Metadata.StatusCode != 201 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	endpoint.ExtraConfig[script.Namespace] = map[string]interface{}{
+		"engine": "proxy-test",
+		"pre":    map[string]interface{}{"source": "fail"},
+	}
+	p = NewScriptMiddleware(logging.NoOp, endpoint)(func(_ context.Context, _ *Request) (*Response, error) {
+		t.Error("the pipe should not be executed")
+		return nil, nil
+	})
+	if _, err := p(context.Background(), &Request{}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestNewBackendScriptMiddleware_unknownEngine(t *testing.T) {
+	remote := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			script.Namespace: map[string]interface{}{
+				"engine": "unknown",
+				"pre":    map[string]interface{}{"source": "x"},
+			},
+		},
+	}
+	p := NewBackendScriptMiddleware(logging.NoOp, remote)(func(_ context.Context, _ *Request) (*Response, error) {
+		t.Error("the backend should not be called")
+		return &Response{IsComplete: true}, nil
+	})
+	if resp, err := p(context.Background(), &Request{}); err == nil || resp != nil {
+		t.Errorf("unexpected result: %v %v", resp, err)
+	}
+
+	_, err := DefaultFactory(logging.NoOp).New(&config.EndpointConfig{
+		Endpoint: "/supu",
+		Backend:  []*config.Backend{remote},
+	})
+	if err == nil || err.Error() != "script: unknown engine unknown" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const sequentialHeadersKey = "sequential_headers"
+
+var (
+	reMergeHeaderKey  = regexp.MustCompile(`\{\{\.Resp(\d+)Header_([\w-]+)\}\}`)
+	reMergeStatusKey  = regexp.MustCompile(`\{\{\.Resp(\d+)Status\}\}`)
+	reSimpleParamsKey = regexp.MustCompile(`\{([\w\-\.:/]+)\}`)
+)
+
+// getSequentialHeaders returns the headers to add to the request of a backend of a sequential
+// endpoint. The values are templates accepting the same params than the url pattern, so the
+// backends can forward the data ({resp0_id}), the headers ({resp0Header_Location}) and the
+// status codes ({resp0Status}) of the previous responses:
+//
+//	"extra_config": {
+//		"github.com/devopsfaith/krakend/proxy": {
+//			"sequential_headers": {
+//				"X-Order-Location": "{resp0Header_Location}",
+//				"X-Order-Status": "{resp0Status}",
+//				"X-Order-Id": "{resp0_id}"
+//			}
+//		}
+//	}
+func getSequentialHeaders(extra config.ExtraConfig) map[string]string {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	raw, ok := e[sequentialHeadersKey].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	headers := make(map[string]string, len(raw))
+	for k, v := range raw {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if !strings.Contains(s, "{{") {
+			// same conversion than the one applied by the config package to the url patterns
+			s = reSimpleParamsKey.ReplaceAllStringFunc(s, func(m string) string {
+				return "{{." + strings.ToUpper(m[1:2]) + m[2:len(m)-1] + "}}"
+			})
+		}
+		headers[http.CanonicalHeaderKey(k)] = s
+	}
+	return headers
+}
+
+// sequentialStep contains the templates and the condition of a backend of a sequential endpoint
+type sequentialStep struct {
+	pattern string
+	headers map[string]string
+	cond    *backendCondition
+}
+
+func newSequentialSteps(backends []*config.Backend, conds []*backendCondition) []sequentialStep {
+	steps := make([]sequentialStep, len(backends))
+	for i, b := range backends {
+		steps[i] = sequentialStep{pattern: b.URLPattern, headers: getSequentialHeaders(b.ExtraConfig), cond: conds[i]}
+	}
+	return steps
+}
+
+// templates returns all the templates of the step, so they can be scanned at once
+func (s sequentialStep) templates() string {
+	if len(s.headers) == 0 && s.cond == nil {
+		return s.pattern
+	}
+	parts := make([]string, 0, len(s.headers)+2)
+	parts = append(parts, s.pattern, s.cond.templates())
+	for _, v := range s.headers {
+		parts = append(parts, v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// usesMetadata returns true if any step references the headers or the status code of the
+// previous responses
+func usesMetadata(steps []sequentialStep) bool {
+	for _, s := range steps {
+		t := s.templates()
+		if reMergeHeaderKey.MatchString(t) || reMergeStatusKey.MatchString(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// setMetadataParams adds the headers and the status codes of the previous responses referenced
+// by the templates to the params
+func setMetadataParams(params map[string]string, templates string, metas []Metadata) {
+	for _, match := range reMergeHeaderKey.FindAllStringSubmatch(templates, -1) {
+		rNum, err := strconv.Atoi(match[1])
+		if err != nil || rNum >= len(metas) {
+			continue
+		}
+		if v := http.Header(metas[rNum].Headers).Get(match[2]); v != "" {
+			params["Resp"+match[1]+"Header_"+match[2]] = v
+		}
+	}
+	for _, match := range reMergeStatusKey.FindAllStringSubmatch(templates, -1) {
+		rNum, err := strconv.Atoi(match[1])
+		if err != nil || rNum >= len(metas) || metas[rNum].StatusCode == 0 {
+			continue
+		}
+		params["Resp"+match[1]+"Status"] = strconv.Itoa(metas[rNum].StatusCode)
+	}
+}
+
+// withSequentialHeaders returns a copy of the request with the headers of the step, so the
+// received request is not modified
+func withSequentialHeaders(r *Request, headers map[string]string) *Request {
+	clone := *r
+	clone.Headers = CloneRequestHeaders(r.Headers)
+	for k, tmpl := range headers {
+		v := tmpl
+		for p, pv := range r.Params {
+			v = strings.ReplaceAll(v, "{{."+p+"}}", pv)
+		}
+		if v == "" || strings.Contains(v, "{{.") {
+			// the referenced value is not available, so the header can not be trusted
+			delete(clone.Headers, k)
+			continue
+		}
+		clone.Headers[k] = []string{v}
+	}
+	return &clone
+}
+
+type metadataCaptureKey struct{}
+
+// metadataCapture stores the status code and the headers of the last backend response, even
+// when the response parser discards them
+type metadataCapture struct {
+	mu     sync.Mutex
+	m      Metadata
+	parent *metadataCapture
+}
+
+func (c *metadataCapture) metadata() Metadata {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.m
+}
+
+// newMetadataCaptureContext returns a context with the capture. The captures already in the
+// context keep receiving the metadata, so several middlewares can capture the same response
+func newMetadataCaptureContext(ctx context.Context, c *metadataCapture) context.Context {
+	if parent, ok := ctx.Value(metadataCaptureKey{}).(*metadataCapture); ok && parent != c {
+		c.parent = parent
+	}
+	return context.WithValue(ctx, metadataCaptureKey{}, c)
+}
+
+func captureMetadata(ctx context.Context, resp *http.Response) {
+	c, ok := ctx.Value(metadataCaptureKey{}).(*metadataCapture)
+	if !ok || resp == nil {
+		return
+	}
+	for ; c != nil; c = c.parent {
+		c.mu.Lock()
+		c.m = Metadata{StatusCode: resp.StatusCode, Headers: resp.Header.Clone()}
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const (
+	sequentialBudgetKey = "sequential_budget"
+
+	budgetModeProportional = "proportional"
+	budgetModeFixed        = "fixed"
+)
+
+// sequentialBudget splits the timeout of a sequential endpoint into the budgets of the calls to
+// its backends, so a slow backend can not eat the time of the next ones:
+//
+//	"extra_config": {
+//		"github.com/devopsfaith/krakend/proxy": {
+//			"sequential": true,
+//			"sequential_budget": {
+//				"mode": "proportional",
+//				"weights": [1, 3],
+//				"header": "X-Request-Budget"
+//			}
+//		}
+//	}
+//
+// The proportional budgets split the time left among the backends not called yet, according to
+// their weights (1 by default), so the time not used by a backend is available to the next ones.
+// The fixed budgets ("mode": "fixed", "budgets": ["200ms", "1s"]) cap the calls to every backend,
+// and the backends without one get the time left. The calls see their budget as the deadline of
+// their context, and the header, if declared, sends it to the backends in milliseconds
+type sequentialBudget struct {
+	mode    string
+	weights []float64
+	fixed   []time.Duration
+	header  string
+}
+
+// getSequentialBudget returns the budgets of the backends of the endpoint, if declared
+func getSequentialBudget(cfg *config.EndpointConfig) (*sequentialBudget, error) {
+	e, ok := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	v, ok := e[sequentialBudgetKey].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	b := &sequentialBudget{mode: budgetModeProportional}
+	if m, ok := v["mode"].(string); ok && m != "" {
+		b.mode = m
+	}
+	if h, ok := v["header"].(string); ok && h != "" {
+		b.header = http.CanonicalHeaderKey(h)
+	}
+
+	switch b.mode {
+	case budgetModeProportional:
+		raw, _ := v["weights"].([]interface{})
+		if len(raw) > len(cfg.Backend) {
+			return nil, fmt.Errorf("%d weights declared for %d backends", len(raw), len(cfg.Backend))
+		}
+		b.weights = make([]float64, len(cfg.Backend))
+		for i := range b.weights {
+			b.weights[i] = 1
+			if i >= len(raw) {
+				continue
+			}
+			w, ok := raw[i].(float64)
+			if !ok || w <= 0 {
+				return nil, fmt.Errorf("invalid weight %v for the backend %d", raw[i], i)
+			}
+			b.weights[i] = w
+		}
+	case budgetModeFixed:
+		raw, _ := v["budgets"].([]interface{})
+		if len(raw) > len(cfg.Backend) {
+			return nil, fmt.Errorf("%d budgets declared for %d backends", len(raw), len(cfg.Backend))
+		}
+		b.fixed = make([]time.Duration, len(cfg.Backend))
+		for i, r := range raw {
+			s, _ := r.(string)
+			d, err := time.ParseDuration(s)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid budget %v for the backend %d", r, i)
+			}
+			b.fixed[i] = d
+		}
+	default:
+		return nil, fmt.Errorf("unknown sequential budget mode %q", b.mode)
+	}
+	return b, nil
+}
+
+// step returns the budget of the call to the backend i with the time left
+func (b *sequentialBudget) step(i int, left time.Duration) time.Duration {
+	if b.mode == budgetModeFixed {
+		if d := b.fixed[i]; d > 0 && d < left {
+			return d
+		}
+		return left
+	}
+	total := 0.0
+	for _, w := range b.weights[i:] {
+		total += w
+	}
+	return time.Duration(float64(left) * b.weights[i] / total)
+}
+
+// withStepBudget returns the context of the call to the backend i, with the deadline of its
+// budget, and the request carrying the budget header, if declared
+func (b *sequentialBudget) withStepBudget(ctx context.Context, i int, r *Request) (context.Context, context.CancelFunc, *Request) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, r
+	}
+	d := b.step(i, time.Until(deadline))
+	stepCtx, cancel := context.WithTimeout(ctx, d)
+	if b.header == "" {
+		return stepCtx, cancel, r
+	}
+	clone := *r
+	clone.Headers = CloneRequestHeaders(r.Headers)
+	clone.Headers[b.header] = []string{strconv.FormatInt(d.Milliseconds(), 10)}
+	return stepCtx, cancel, &clone
+}
+
+// String returns a human readable representation of the budgets
+func (b *sequentialBudget) String() string {
+	parts := []string{}
+	if b.mode == budgetModeFixed {
+		for _, d := range b.fixed {
+			if d == 0 {
+				parts = append(parts, "rest")
+				continue
+			}
+			parts = append(parts, d.String())
+		}
+	} else {
+		for _, w := range b.weights {
+			parts = append(parts, strconv.FormatFloat(w, 'f', -1, 64))
+		}
+	}
+	return b.mode + "(" + strings.Join(parts, ", ") + ")"
+}
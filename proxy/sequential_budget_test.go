@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestGetSequentialBudget(t *testing.T) {
+	backends := []*config.Backend{{}, {}, {}}
+	for i, tc := range []struct {
+		budget   map[string]interface{}
+		expected string
+		err      bool
+	}{
+		{map[string]interface{}{}, "proportional(1, 1, 1)", false},
+		{map[string]interface{}{"weights": []interface{}{2.0, 0.5}}, "proportional(2, 0.5, 1)", false},
+		{map[string]interface{}{"mode": "fixed", "budgets": []interface{}{"100ms", "1s"}}, "fixed(100ms, 1s, rest)", false},
+		{map[string]interface{}{"weights": []interface{}{0.0}}, "", true},
+		{map[string]interface{}{"weights": []interface{}{1.0, 1.0, 1.0, 1.0}}, "", true},
+		{map[string]interface{}{"mode": "fixed", "budgets": []interface{}{"soon"}}, "", true},
+		{map[string]interface{}{"mode": "random"}, "", true},
+	} {
+		b, err := getSequentialBudget(&config.EndpointConfig{
+			Backend:     backends,
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{sequentialBudgetKey: tc.budget}},
+		})
+		if (err != nil) != tc.err {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if err == nil && b.String() != tc.expected {
+			t.Errorf("#%d: unexpected budget: %s", i, b)
+		}
+	}
+
+	if b, err := getSequentialBudget(&config.EndpointConfig{Backend: backends}); b != nil || err != nil {
+		t.Errorf("unexpected result: %v %v", b, err)
+	}
+}
+
+func TestSequentialBudget_step(t *testing.T) {
+	b := &sequentialBudget{mode: budgetModeProportional, weights: []float64{1, 3}}
+	if d := b.step(0, 400*time.Millisecond); d != 100*time.Millisecond {
+		t.Errorf("unexpected budget: %s", d)
+	}
+	if d := b.step(1, 350*time.Millisecond); d != 350*time.Millisecond {
+		t.Errorf("the last backend should get the time left: %s", d)
+	}
+
+	b = &sequentialBudget{mode: budgetModeFixed, fixed: []time.Duration{100 * time.Millisecond, 0}}
+	if d := b.step(0, 400*time.Millisecond); d != 100*time.Millisecond {
+		t.Errorf("unexpected budget: %s", d)
+	}
+	if d := b.step(0, 50*time.Millisecond); d != 50*time.Millisecond {
+		t.Errorf("the budget should not exceed the time left: %s", d)
+	}
+	if d := b.step(1, 300*time.Millisecond); d != 300*time.Millisecond {
+		t.Errorf("unexpected budget: %s", d)
+	}
+}
+
+func TestNewMergeDataMiddleware_sequentialBudget(t *testing.T) {
+	endpoint := config.EndpointConfig{
+		Backend: []*config.Backend{{URLPattern: "/a"}, {URLPattern: "/b"}},
+		Timeout: time.Second,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				isSequentialKey: true,
+				sequentialBudgetKey: map[string]interface{}{
+					"mode":    "fixed",
+					"budgets": []interface{}{"20ms"},
+					"header":  "x-request-budget",
+				},
+			},
+		},
+	}
+
+	var header string
+	var secondBudget time.Duration
+	p := NewMergeDataMiddleware(logging.NoOp, &endpoint)(
+		func(ctx context.Context, r *Request) (*Response, error) {
+			header = r.Headers["X-Request-Budget"][0]
+			return &Response{Data: map[string]interface{}{"a": 1}, IsComplete: true}, nil
+		},
+		func(ctx context.Context, r *Request) (*Response, error) {
+			deadline, _ := ctx.Deadline()
+			secondBudget = time.Until(deadline)
+			return &Response{Data: map[string]interface{}{"b": 1}, IsComplete: true}, nil
+		},
+	)
+	resp, err := p(context.Background(), &Request{Params: map[string]string{}, Headers: map[string][]string{}})
+	if err != nil || !resp.IsComplete {
+		t.Fatalf("unexpected response: %+v %v", resp, err)
+	}
+	if header != "20" {
+		t.Errorf("unexpected budget header: %s", header)
+	}
+	if secondBudget < 500*time.Millisecond {
+		t.Errorf("the second backend should get the time left: %s", secondBudget)
+	}
+
+	// a slow first backend is cut at its budget
+	p = NewMergeDataMiddleware(logging.NoOp, &endpoint)(
+		func(ctx context.Context, _ *Request) (*Response, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		dummyProxy(&Response{IsComplete: true}),
+	)
+	start := time.Now()
+	_, err = p(context.Background(), &Request{Params: map[string]string{}, Headers: map[string][]string{}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("the first backend ate the timeout of the endpoint: %s", elapsed)
+	}
+}
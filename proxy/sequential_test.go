@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewMergeDataMiddleware_sequentialMetadata(t *testing.T) {
+	backend := &config.Backend{URLPattern: "/orders", Decoder: encoding.JSONDecoder}
+	endpoint := config.EndpointConfig{
+		Backend: []*config.Backend{
+			backend,
+			{
+				URLPattern: "/status/{{.Resp0Status}}?location={{.Resp0Header_Location}}",
+				ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+					sequentialHeadersKey: map[string]interface{}{
+						"x-order-location": "{{.Resp0Header_Location}}",
+						"X-Order-Id":       "{resp0_id}",
+						"X-Missing":        "{resp0Header_X-Missing}",
+					},
+				}},
+			},
+		},
+		Timeout: time.Second,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{isSequentialKey: true},
+		},
+	}
+
+	created := NewHTTPProxyWithHTTPExecutor(backend, func(_ context.Context, _ *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Location": {"/orders/42"}},
+			Body:       io.NopCloser(strings.NewReader(`{"id":42}`)),
+		}, nil
+	}, backend.Decoder)
+	withURL := func(ctx context.Context, r *Request) (*Response, error) {
+		r.URL, _ = url.Parse("http://example.com/orders")
+		return created(ctx, r)
+	}
+
+	var path string
+	var headers map[string][]string
+	second := func(_ context.Context, r *Request) (*Response, error) {
+		r.GeneratePath(endpoint.Backend[1].URLPattern)
+		path = r.Path
+		headers = r.Headers
+		return &Response{Data: map[string]interface{}{"status": "ok"}, IsComplete: true}, nil
+	}
+
+	req := &Request{
+		Params:  map[string]string{},
+		Headers: map[string][]string{"X-Missing": {"spoofed"}, "User-Agent": {"test"}},
+	}
+	resp, err := NewMergeDataMiddleware(logging.NoOp, &endpoint)(withURL, second)(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.IsComplete || resp.Data["status"] != "ok" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if path != "/status/200?location=/orders/42" {
+		t.Errorf("unexpected path: %s", path)
+	}
+	if v := headers["X-Order-Location"]; len(v) != 1 || v[0] != "/orders/42" {
+		t.Errorf("unexpected location header: %v", headers)
+	}
+	if v := headers["X-Order-Id"]; len(v) != 1 || v[0] != "42" {
+		t.Errorf("unexpected id header: %v", headers)
+	}
+	if _, ok := headers["X-Missing"]; ok {
+		t.Errorf("the unresolved headers must be removed: %v", headers)
+	}
+	if _, ok := req.Headers["X-Order-Id"]; ok {
+		t.Error("the headers of the original request have been modified")
+	}
+}
+
+func TestNewFilterHeadersMiddleware_sequentialHeaders(t *testing.T) {
+	backend := config.Backend{
+		HeadersToPass: []string{"X-Foo"},
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			sequentialHeadersKey: map[string]interface{}{"X-Order-Id": "{{.Resp0_id}}"},
+		}},
+	}
+	var headers map[string][]string
+	p := NewFilterHeadersMiddleware(logging.NoOp, &backend)(func(_ context.Context, r *Request) (*Response, error) {
+		headers = r.Headers
+		return nil, nil
+	})
+	p(context.Background(), &Request{Headers: map[string][]string{
+		"X-Foo":      {"foo"},
+		"X-Bar":      {"bar"},
+		"X-Order-Id": {"42"},
+	}})
+	if len(headers) != 2 || headers["X-Order-Id"][0] != "42" {
+		t.Errorf("unexpected headers: %v", headers)
+	}
+}
@@ -4,6 +4,7 @@ package proxy
 
 import (
 	"context"
+	"math/rand"
 	"time"
 
 	"github.com/luraproject/lura/v2/config"
@@ -11,8 +12,9 @@ import (
 )
 
 const (
-	shadowKey        = "shadow"
-	shadowTimeoutKey = "shadow_timeout"
+	shadowKey           = "shadow"
+	shadowTimeoutKey    = "shadow_timeout"
+	shadowSampleRateKey = "shadow_sample_rate"
 )
 
 type shadowFactory struct {
@@ -21,7 +23,9 @@ type shadowFactory struct {
 
 // New check the Backends for an ExtraConfig with the "shadow" param to true
 // implements the Factory interface. Sets the "shadow_timeout" defined in the
-// config; uses the backend timeout as fallback.
+// config; uses the backend timeout as fallback. Every shadow backend gets its own
+// pipe, so only the ratio of requests declared by its "shadow_sample_rate" is mirrored
+// to it.
 func (s shadowFactory) New(cfg *config.EndpointConfig) (p Proxy, err error) {
 	if len(cfg.Backend) == 0 {
 		err = ErrNoBackends
@@ -30,12 +34,8 @@ func (s shadowFactory) New(cfg *config.EndpointConfig) (p Proxy, err error) {
 
 	var shadow []*config.Backend
 	var regular []*config.Backend
-	var maxTimeout time.Duration
 	for _, b := range cfg.Backend {
-		if d, ok := isShadowBackend(b); ok {
-			if maxTimeout < d {
-				maxTimeout = d
-			}
+		if _, ok := isShadowBackend(b); ok {
 			shadow = append(shadow, b)
 			continue
 		}
@@ -45,11 +45,16 @@ func (s shadowFactory) New(cfg *config.EndpointConfig) (p Proxy, err error) {
 	cfg.Backend = regular
 	p, err = s.f.New(cfg)
 
-	if len(shadow) > 0 {
-		cfg.Backend = shadow
-		pShadow, _ := s.f.New(cfg)
-		p = ShadowMiddlewareWithTimeout(maxTimeout, p, pShadow)
+	for _, b := range shadow {
+		timeout, _ := isShadowBackend(b)
+		cfg.Backend = []*config.Backend{b}
+		pShadow, shadowErr := s.f.New(cfg)
+		if shadowErr != nil {
+			continue
+		}
+		p = NewSampledShadowProxyWithTimeout(timeout, shadowSampleRate(b), p, pShadow)
 	}
+	cfg.Backend = regular
 
 	return
 }
@@ -121,6 +126,35 @@ func NewShadowProxyWithTimeout(timeout time.Duration, p1, p2 Proxy) Proxy {
 	}
 }
 
+// NewSampledShadowProxyWithTimeout returns a Proxy that sends requests to p1 and the received
+// ratio of them to p2, ignoring the response of p2. The ratio must be in the (0, 1] range, and
+// the rest of the values mirror all the requests. Sets a timeout in the context.
+func NewSampledShadowProxyWithTimeout(timeout time.Duration, rate float64, p1, p2 Proxy) Proxy {
+	shadowProxy := NewShadowProxyWithTimeout(timeout, p1, p2)
+	if rate <= 0 || rate >= 1 {
+		return shadowProxy
+	}
+	return func(ctx context.Context, request *Request) (*Response, error) {
+		if rand.Float64() >= rate {
+			return p1(ctx, request)
+		}
+		return shadowProxy(ctx, request)
+	}
+}
+
+// shadowSampleRate returns the ratio of requests to mirror to the shadow backend
+func shadowSampleRate(c *config.Backend) float64 {
+	e, ok := c.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return 1
+	}
+	rate, ok := e[shadowSampleRateKey].(float64)
+	if !ok || rate <= 0 || rate > 1 {
+		return 1
+	}
+	return rate
+}
+
 func isShadowBackend(c *config.Backend) (time.Duration, bool) {
 	duration := c.Timeout
 	v, ok := c.ExtraConfig[Namespace]
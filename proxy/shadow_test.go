@@ -170,3 +170,78 @@ func TestShadowMiddleware_partialTimeout(t *testing.T) {
 		return
 	}
 }
+
+func TestNewSampledShadowProxyWithTimeout(t *testing.T) {
+	var regular, shadow uint64
+	p := NewSampledShadowProxyWithTimeout(time.Second, 0.25, newAssertionProxy(&regular), newAssertionProxy(&shadow))
+	for i := 0; i < 1000; i++ {
+		p(context.Background(), &Request{})
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadUint64(&regular); n != 1000 {
+		t.Errorf("all the requests should reach the regular backends: %d", n)
+	}
+	if n := atomic.LoadUint64(&shadow); n < 150 || n > 350 {
+		t.Errorf("unexpected number of mirrored requests: %d", n)
+	}
+}
+
+func TestShadowSampleRate(t *testing.T) {
+	for _, tc := range []struct {
+		rate     interface{}
+		expected float64
+	}{
+		{nil, 1},
+		{0.1, 0.1},
+		{1.0, 1},
+		{0.0, 1},
+		{2.0, 1},
+		{"0.5", 1},
+	} {
+		b := &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"shadow": true, "shadow_sample_rate": tc.rate}}}
+		if r := shadowSampleRate(b); r != tc.expected {
+			t.Errorf("%v: unexpected rate %f", tc.rate, r)
+		}
+	}
+}
+
+func TestNewShadowFactory_sampled(t *testing.T) {
+	var regular, sampled, full uint64
+	proxies := map[string]Proxy{
+		"/regular": newAssertionProxy(&regular),
+		"/sampled": newAssertionProxy(&sampled),
+		"/full":    newAssertionProxy(&full),
+	}
+	factory := NewDefaultFactory(func(b *config.Backend) Proxy { return proxies[b.URLPattern] }, logging.NoOp)
+	endpointConfig := &config.EndpointConfig{
+		Endpoint: "/shadowed",
+		Backend: []*config.Backend{
+			{URLPattern: "/regular"},
+			{URLPattern: "/sampled", ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"shadow": true, "shadow_sample_rate": 0.0001}}},
+			{URLPattern: "/full", ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"shadow": true}}},
+		},
+	}
+	serviceConfig := config.ServiceConfig{
+		Version:   config.ConfigVersion,
+		Endpoints: []*config.EndpointConfig{endpointConfig},
+		Timeout:   time.Second,
+		Host:      []string{"dummy"},
+	}
+	if err := serviceConfig.Init(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewShadowFactory(factory).New(endpointConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpointConfig.Backend) != 1 || endpointConfig.Backend[0].URLPattern != "/regular" {
+		t.Errorf("unexpected backends: %v", endpointConfig.Backend)
+	}
+	for i := 0; i < 10; i++ {
+		p(context.Background(), &Request{})
+	}
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadUint64(&regular) != 10 || atomic.LoadUint64(&full) != 10 || atomic.LoadUint64(&sampled) > 1 {
+		t.Errorf("unexpected calls. regular: %d, full: %d, sampled: %d", regular, full, sampled)
+	}
+}
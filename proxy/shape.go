@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	liftKey            = "lift"
+	collisionPrefixKey = "collision_prefix"
+)
+
+// getLiftPath returns the path of the nested object to lift to the root of the merged response
+func getLiftPath(extra config.ExtraConfig) (string, bool) {
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	path, ok := e[liftKey].(string)
+	return path, ok && path != ""
+}
+
+// NewLiftMiddleware creates a proxy middleware replacing the data of the merged response with the
+// nested object declared by the lift path of the endpoint. The responses without an object at
+// the path get an empty data
+func NewLiftMiddleware(logger logging.Logger, cfg *config.EndpointConfig) Middleware {
+	path, ok := getLiftPath(cfg.ExtraConfig)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewLiftMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+
+		logger.Debug(fmt.Sprintf("[ENDPOINT: %s][Lift] Lifting %s to the root of the response", cfg.Endpoint, path))
+
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			if resp == nil || resp.Data == nil {
+				return resp, err
+			}
+			r := *resp
+			extractTarget(path, &r)
+			return &r, err
+		}
+	}
+}
+
+// getCollisionPrefixes returns the prefixes declared by the backends for their colliding keys,
+// or nil if none of them declares it
+func getCollisionPrefixes(backends []*config.Backend) []string {
+	var prefixes []string
+	for i, b := range backends {
+		e, ok := b.ExtraConfig[Namespace].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		prefix, ok := e[collisionPrefixKey].(string)
+		if !ok || prefix == "" {
+			continue
+		}
+		if prefixes == nil {
+			prefixes = make([]string, len(backends))
+		}
+		prefixes[i] = prefix
+	}
+	return prefixes
+}
+
+// prefixCollisions returns a copy of src with the keys already present in dst renamed with the
+// prefix. Just the keys at the root are renamed
+func prefixCollisions(dst, src map[string]interface{}, prefix string) map[string]interface{} {
+	res := make(map[string]interface{}, len(src))
+	for k, v := range src {
+		if _, ok := dst[k]; ok {
+			k = prefix + k
+		}
+		res[k] = v
+	}
+	return res
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewEntityFormatter_nestedGroup(t *testing.T) {
+	f := NewEntityFormatter(&config.Backend{Group: "a.b.c"})
+	out := f.Format(Response{Data: map[string]interface{}{"id": 1}})
+	b, _ := json.Marshal(out.Data)
+	if string(b) != `{"a":{"b":{"c":{"id":1}}}}` {
+		t.Errorf("unexpected data: %s", b)
+	}
+}
+
+func TestNewMergeDataMiddleware_nestedGroups(t *testing.T) {
+	backends := []*config.Backend{{Group: "data.users"}, {Group: "data.orders"}}
+	endpoint := config.EndpointConfig{
+		Backend: backends,
+		Timeout: time.Second,
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			mergeStrategyKey: MergeStrategyDeep,
+			liftKey:          "data",
+		}},
+	}
+	backendProxy := func(i int) Proxy {
+		f := NewEntityFormatter(backends[i])
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			r := f.Format(Response{IsComplete: true, Data: map[string]interface{}{"total": i}})
+			return &r, nil
+		}
+	}
+
+	p := NewMergeDataMiddleware(logging.NoOp, &endpoint)(backendProxy(0), backendProxy(1))
+	p = NewLiftMiddleware(logging.NoOp, &endpoint)(p)
+	out, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, _ := json.Marshal(out.Data)
+	if string(b) != `{"orders":{"total":1},"users":{"total":0}}` {
+		t.Errorf("unexpected data: %s", b)
+	}
+}
+
+func TestNewMergeDataMiddleware_collisionPrefix(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		extra    map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "default",
+			extra:    map[string]interface{}{},
+			expected: `{"id":1,"legacy_id":2,"legacy_name":"b","name":"a","role":"admin"}`,
+		},
+		{
+			name:     "first_wins",
+			extra:    map[string]interface{}{mergeStrategyKey: MergeStrategyFirstWins},
+			expected: `{"id":1,"legacy_id":2,"legacy_name":"b","name":"a","role":"admin"}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			endpoint := config.EndpointConfig{
+				Backend: []*config.Backend{
+					{},
+					{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{collisionPrefixKey: "legacy_"}}},
+				},
+				Timeout:     time.Second,
+				ExtraConfig: config.ExtraConfig{Namespace: tc.extra},
+			}
+			first := func(_ context.Context, _ *Request) (*Response, error) {
+				time.Sleep(20 * time.Millisecond)
+				return &Response{IsComplete: true, Data: map[string]interface{}{"id": 1, "name": "a"}}, nil
+			}
+			second := func(_ context.Context, _ *Request) (*Response, error) {
+				return &Response{IsComplete: true, Data: map[string]interface{}{"id": 2, "name": "b", "role": "admin"}}, nil
+			}
+
+			out, err := NewMergeDataMiddleware(logging.NoOp, &endpoint)(first, second)(context.Background(), &Request{})
+			if err != nil || !out.IsComplete {
+				t.Errorf("unexpected result. complete: %v, error: %v", out.IsComplete, err)
+				return
+			}
+			b, _ := json.Marshal(out.Data)
+			if string(b) != tc.expected {
+				t.Errorf("unexpected data: %s", b)
+			}
+		})
+	}
+}
+
+func TestNewLiftMiddleware(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint:    "/lift",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{liftKey: "a.b"}},
+	}
+	for _, tc := range []struct {
+		data     map[string]interface{}
+		expected string
+	}{
+		{
+			data:     map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"id": 1}}, "c": 2},
+			expected: `{"id":1}`,
+		},
+		{
+			data:     map[string]interface{}{"a": map[string]interface{}{"b": 1}},
+			expected: `{}`,
+		},
+		{
+			data:     map[string]interface{}{"c": 2},
+			expected: `{}`,
+		},
+	} {
+		p := NewLiftMiddleware(logging.NoOp, endpoint)(func(_ context.Context, _ *Request) (*Response, error) {
+			return &Response{IsComplete: true, Data: tc.data}, nil
+		})
+		out, err := p(context.Background(), &Request{})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		b, _ := json.Marshal(out.Data)
+		if string(b) != tc.expected {
+			t.Errorf("unexpected data: %s", b)
+		}
+	}
+}
+
+func TestNewLiftMiddleware_disabled(t *testing.T) {
+	expected := &Response{Data: map[string]interface{}{"a": 1}}
+	p := NewLiftMiddleware(logging.NoOp, &config.EndpointConfig{})(func(_ context.Context, _ *Request) (*Response, error) {
+		return expected, nil
+	})
+	if out, _ := p(context.Background(), &Request{}); out != expected {
+		t.Errorf("unexpected response: %v", out)
+	}
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client/soap"
+)
+
+// NewSOAPMiddleware returns a middleware with or without the SOAP proxy wrapping the next
+// element (depending on the configuration). The requests are replaced by POST requests with the
+// envelope rendered from the params, the query string and the JSON body of the request, and the
+// content type and action headers of the declared SOAP version
+func NewSOAPMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][SOAP]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	opt, err := soap.GetOptions(remote.ExtraConfig)
+	if err != nil {
+		if err != soap.ErrNoConfigFound {
+			logger.Warning(logPrefix, err.Error())
+		}
+		return emptyMiddlewareFallback(logger)
+	}
+	renderer, err := soap.New(*opt)
+	if err != nil {
+		logger.Warning(logPrefix, err.Error())
+		return emptyMiddlewareFallback(logger)
+	}
+	headers := renderer.Headers()
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this %s %s -> %s proxy middleware: NewSOAPMiddleware only accepts 1 proxy, got %d",
+				remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, len(next))
+			return nil
+		}
+
+		logger.Debug(logPrefix, "Version:", opt.Version, "Action:", opt.Action)
+
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			data := soap.TemplateData{Params: req.Params, Query: map[string]string{}}
+			for k := range req.Query {
+				data.Query[k] = req.Query.Get(k)
+			}
+			if req.Body != nil {
+				b, err := io.ReadAll(req.Body)
+				req.Body.Close()
+				if err != nil {
+					return nil, err
+				}
+				if len(bytes.TrimSpace(b)) > 0 {
+					if err := json.Unmarshal(b, &data.Body); err != nil {
+						return nil, fmt.Errorf("soap: decoding the request body: %w", err)
+					}
+				}
+			}
+
+			b, err := renderer.Render(data)
+			if err != nil {
+				return nil, err
+			}
+
+			req.Body = io.NopCloser(bytes.NewReader(b))
+			req.Method = http.MethodPost
+			if req.Headers == nil {
+				req.Headers = map[string][]string{}
+			}
+			for k, vs := range headers {
+				req.Headers[k] = vs
+			}
+			req.Headers["Content-Length"] = []string{strconv.Itoa(len(b))}
+
+			return next[0](ctx, req)
+		}
+	}
+}
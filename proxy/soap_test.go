@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client/soap"
+)
+
+const soapTemplate = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>` +
+	`<GetUser xmlns="urn:users"><Id>{{.Params.Id}}</Id><Name>{{.Body.name}}</Name></GetUser>` +
+	`</soap:Body></soap:Envelope>`
+
+func TestNewSOAPMiddleware(t *testing.T) {
+	backend := &config.Backend{
+		URLPattern: "/users",
+		ExtraConfig: config.ExtraConfig{
+			soap.Namespace: map[string]interface{}{
+				"template": soapTemplate,
+				"action":   "urn:users#GetUser",
+			},
+		},
+	}
+	expectedResponse := &Response{Data: map[string]interface{}{"foo": "bar"}}
+	prxy := NewSOAPMiddleware(logging.NoOp, backend)(func(_ context.Context, req *Request) (*Response, error) {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		expected := `<GetUser xmlns="urn:users"><Id>42</Id><Name>John &amp; Jane</Name></GetUser>`
+		if !strings.Contains(string(b), expected) {
+			t.Errorf("unexpected body: %s", string(b))
+		}
+		if req.Method != http.MethodPost {
+			t.Errorf("unexpected method: %s", req.Method)
+		}
+		if h := req.Headers["Soapaction"]; len(h) != 1 || h[0] != `"urn:users#GetUser"` {
+			t.Errorf("unexpected action: %v", h)
+		}
+		if h := req.Headers["Content-Length"]; len(h) != 1 || h[0] != fmt.Sprintf("%d", len(b)) {
+			t.Errorf("unexpected content length: %v", h)
+		}
+		return expectedResponse, nil
+	})
+
+	resp, err := prxy(context.Background(), &Request{
+		Method:  http.MethodGet,
+		Body:    io.NopCloser(strings.NewReader(`{"name":"John & Jane"}`)),
+		Params:  map[string]string{"Id": "42"},
+		Headers: map[string][]string{},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(resp, expectedResponse) {
+		t.Errorf("unexpected response: %v", resp)
+	}
+}
+
+func TestNewSOAPMiddleware_badBody(t *testing.T) {
+	backend := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			soap.Namespace: map[string]interface{}{"template": soapTemplate},
+		},
+	}
+	prxy := NewSOAPMiddleware(logging.NoOp, backend)(func(_ context.Context, _ *Request) (*Response, error) {
+		t.Error("the backend should not be called")
+		return nil, nil
+	})
+	if _, err := prxy(context.Background(), &Request{Body: io.NopCloser(strings.NewReader("{"))}); err == nil {
+		t.Error("expecting an error")
+	}
+}
@@ -124,3 +124,95 @@ func staticIfCompleteMatch(r *Response, err error) bool {
 	return err == nil && r != nil && r.IsComplete
 }
 func staticIfIncompleteMatch(r *Response, _ error) bool { return r == nil || !r.IsComplete }
+
+const staticBackendFallbackKey = "fallback"
+
+// staticBackendConfig is the document returned by a static backend
+type staticBackendConfig struct {
+	data     []byte
+	fallback bool
+}
+
+// getStaticBackendCfg returns the static document declared by the backend, if any
+func getStaticBackendCfg(remote *config.Backend) (staticBackendConfig, bool) {
+	e, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return staticBackendConfig{}, false
+	}
+	tmp, ok := e[staticKey].(map[string]interface{})
+	if !ok {
+		return staticBackendConfig{}, false
+	}
+	data, ok := tmp["data"].(map[string]interface{})
+	if !ok {
+		return staticBackendConfig{}, false
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return staticBackendConfig{}, false
+	}
+	fallback, _ := tmp[staticBackendFallbackKey].(bool)
+	return staticBackendConfig{data: b, fallback: fallback}, true
+}
+
+func (s staticBackendConfig) response(ef EntityFormatter, complete bool) *Response {
+	data := map[string]interface{}{}
+	json.Unmarshal(s.data, &data)
+	r := ef.Format(Response{Data: data, IsComplete: complete})
+	return &r
+}
+
+// NewStaticBackendProxy returns a proxy answering with the document declared by the backend,
+// without calling any host, so the backends can be stubbed during the development:
+//
+//	"backend": [{
+//		"url_pattern": "/features",
+//		"extra_config": {
+//			"github.com/devopsfaith/krakend/proxy": {
+//				"static": {"data": {"beta": false}}
+//			}
+//		}
+//	}]
+//
+// The document goes through the manipulations of the backend (group, target, allow, mapping...)
+// and is merged like the responses of any other backend
+func NewStaticBackendProxy(logger logging.Logger, remote *config.Backend) Proxy {
+	cfg, _ := getStaticBackendCfg(remote)
+	ef := NewEntityFormatter(remote)
+	logger.Debug(fmt.Sprintf("[BACKEND: %s %s -> %s][Static] Answering with the static document: %s", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern, cfg.data))
+
+	return func(_ context.Context, _ *Request) (*Response, error) {
+		return cfg.response(ef, true), nil
+	}
+}
+
+// NewStaticBackendFallbackMiddleware creates proxy middleware answering with the document
+// declared by the backend when its request fails. The fallback responses are flagged as
+// incomplete and the error is dropped, so the rest of the backends of the endpoint are merged
+// with the default values
+func NewStaticBackendFallbackMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	cfg, ok := getStaticBackendCfg(remote)
+	if !ok || !cfg.fallback {
+		return emptyMiddlewareFallback(logger)
+	}
+	ef := NewEntityFormatter(remote)
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Static]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	logger.Debug(logPrefix, "Using the static document as the fallback:", string(cfg.data))
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewStaticBackendFallbackMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			if err == nil && resp != nil {
+				return resp, nil
+			}
+			if err != nil {
+				logger.Debug(logPrefix, "Falling back to the static document:", err.Error())
+			}
+			return cfg.response(ef, false), nil
+		}
+	}
+}
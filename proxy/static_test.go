@@ -7,6 +7,7 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/logging"
@@ -386,3 +387,84 @@ func Test_getStaticMiddlewareCfg_strategy(t *testing.T) {
 		t.Error("wrong parsing default strategy")
 	}
 }
+
+func TestNewDefaultFactory_staticBackends(t *testing.T) {
+	calls := 0
+	factory := NewDefaultFactory(func(remote *config.Backend) Proxy {
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			calls++
+			return nil, errors.New("unavailable")
+		}
+	}, logging.NoOp)
+
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/home",
+		Method:   "GET",
+		Timeout:  time.Second,
+		Backend: []*config.Backend{
+			{
+				URLPattern: "/features",
+				Group:      "features",
+				ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+					staticKey: map[string]interface{}{"data": map[string]interface{}{"beta": false}},
+				}},
+			},
+			{
+				URLPattern: "/recommendations",
+				Host:       []string{"http://recommendations"},
+				Method:     "GET",
+				ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+					staticKey: map[string]interface{}{
+						"data":                   map[string]interface{}{"items": []interface{}{}},
+						staticBackendFallbackKey: true,
+					},
+				}},
+			},
+		},
+	}
+	p, err := factory.New(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := p(context.Background(), &Request{Method: "GET", Params: map[string]string{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{
+			"features": map[string]interface{}{"beta": false},
+			"items":    []interface{}{},
+		}
+		if !reflect.DeepEqual(resp.Data, expected) {
+			t.Errorf("unexpected data %v", resp.Data)
+		}
+		if resp.IsComplete {
+			t.Error("the fallback responses must be flagged as incomplete")
+		}
+		// the documents must not be shared between the responses
+		resp.Data["features"].(map[string]interface{})["beta"] = true
+	}
+	if calls != 2 {
+		t.Errorf("the static backend has been called: %d calls", calls)
+	}
+}
+
+func TestNewStaticBackendFallbackMiddleware(t *testing.T) {
+	remote := &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		staticKey: map[string]interface{}{"data": map[string]interface{}{"a": 1.0}, staticBackendFallbackKey: true},
+	}}}
+	expected := &Response{Data: map[string]interface{}{"a": 2.0}, IsComplete: true}
+	p := NewStaticBackendFallbackMiddleware(logging.NoOp, remote)(func(_ context.Context, _ *Request) (*Response, error) {
+		return expected, nil
+	})
+	if resp, err := p(context.Background(), &Request{}); resp != expected || err != nil {
+		t.Errorf("unexpected result %v %v", resp, err)
+	}
+
+	if _, ok := getStaticBackendCfg(&config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		staticKey: map[string]interface{}{"data": "not an object"},
+	}}}); ok {
+		t.Error("unexpected config")
+	}
+}
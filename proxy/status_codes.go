@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"fmt"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+// inheritStatusCodes copies the status code propagation options of the endpoint into the extra
+// config of the backends not declaring their own ones, so the status handlers created by the
+// backend factories apply them. The invalid options are reported and ignored
+func inheritStatusCodes(logger logging.Logger, cfg *config.EndpointConfig) {
+	logPrefix := fmt.Sprintf("[ENDPOINT: %s][StatusCodes]", cfg.Endpoint)
+	endpointCfg, err := client.ParseStatusCodesConfig(cfg.ExtraConfig)
+	if err != nil {
+		logger.Error(logPrefix, "Ignoring the options of the endpoint:", err.Error())
+		endpointCfg = nil
+	}
+
+	for _, b := range cfg.Backend {
+		backendCfg, err := client.ParseStatusCodesConfig(b.ExtraConfig)
+		if err != nil {
+			logger.Error(logPrefix, "Ignoring the options of the backend", b.URLPattern+":", err.Error())
+			continue
+		}
+		if backendCfg != nil || endpointCfg == nil {
+			continue
+		}
+
+		// the maps of the config can be shared, so they are copied instead of updated
+		ns := map[string]interface{}{}
+		if v, ok := b.ExtraConfig[client.Namespace].(map[string]interface{}); ok {
+			for k, v := range v {
+				ns[k] = v
+			}
+		}
+		ns[client.StatusCodesKey] = cfg.ExtraConfig[client.Namespace].(map[string]interface{})[client.StatusCodesKey]
+		extra := make(config.ExtraConfig, len(b.ExtraConfig)+1)
+		for k, v := range b.ExtraConfig {
+			extra[k] = v
+		}
+		extra[client.Namespace] = ns
+		b.ExtraConfig = extra
+		logger.Debug(logPrefix, "Backend", b.URLPattern, "inherits the", endpointCfg.Mode, "mode")
+	}
+}
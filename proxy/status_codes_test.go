@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+func TestInheritStatusCodes(t *testing.T) {
+	shared := map[string]interface{}{"return_error_code": true}
+	own := map[string]interface{}{"mode": client.StatusModeMerge}
+	cfg := &config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{client.Namespace: map[string]interface{}{
+			client.StatusCodesKey: map[string]interface{}{"mode": client.StatusModePassThrough},
+		}},
+		Backend: []*config.Backend{
+			{URLPattern: "/a", ExtraConfig: config.ExtraConfig{client.Namespace: shared}},
+			{URLPattern: "/b", ExtraConfig: config.ExtraConfig{client.Namespace: map[string]interface{}{client.StatusCodesKey: own}}},
+			{URLPattern: "/c"},
+		},
+	}
+	inheritStatusCodes(logging.NoOp, cfg)
+
+	for i, expected := range []string{client.StatusModePassThrough, client.StatusModeMerge, client.StatusModePassThrough} {
+		c, err := client.ParseStatusCodesConfig(cfg.Backend[i].ExtraConfig)
+		if err != nil || c == nil || c.Mode != expected {
+			t.Errorf("backend #%d: unexpected config %+v: %v", i, c, err)
+		}
+	}
+	if ns := cfg.Backend[0].ExtraConfig[client.Namespace].(map[string]interface{}); ns["return_error_code"] != true {
+		t.Errorf("the options of the backend have been lost: %v", ns)
+	}
+	if _, ok := shared[client.StatusCodesKey]; ok {
+		t.Error("the shared options have been modified")
+	}
+}
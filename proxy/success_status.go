@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const successStatusKey = "success_status"
+
+// successStatus declares the status code of the successful responses of an endpoint, instead of
+// the 200 returned by the routers:
+//
+//	"extra_config": {
+//		"github.com/devopsfaith/krakend/proxy": {
+//			"success_status": {
+//				"status": 201,
+//				"empty_status": 204,
+//				"backend": 0
+//			}
+//		}
+//	}
+//
+// The empty status is used when the response has no data, like the merges of empty responses.
+// The backend is the index of the backend whose successful status code, if any, is returned, so
+// the endpoints in front of a service queueing the work can return its 202. The status is used
+// otherwise. Only the complete responses without errors are affected
+type successStatus struct {
+	status  int
+	empty   int
+	backend int
+}
+
+// getSuccessStatus returns the success status of the endpoint, if declared
+func getSuccessStatus(cfg *config.EndpointConfig) (*successStatus, error) {
+	e, ok := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	v, ok := e[successStatusKey].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	s := &successStatus{status: http.StatusOK, backend: -1}
+	var err error
+	if s.status, err = parseSuccessStatus(v, "status", http.StatusOK); err != nil {
+		return nil, err
+	}
+	if s.empty, err = parseSuccessStatus(v, "empty_status", s.status); err != nil {
+		return nil, err
+	}
+	if b, ok := v["backend"]; ok {
+		f, ok := b.(float64)
+		if !ok || f != float64(int(f)) || int(f) < 0 || int(f) >= len(cfg.Backend) {
+			return nil, fmt.Errorf("invalid backend %v for the success status", b)
+		}
+		s.backend = int(f)
+	}
+	return s, nil
+}
+
+func parseSuccessStatus(v map[string]interface{}, key string, fallback int) (int, error) {
+	raw, ok := v[key]
+	if !ok {
+		return fallback, nil
+	}
+	f, ok := raw.(float64)
+	status := int(f)
+	if !ok || f != float64(status) || status < http.StatusOK || status >= http.StatusMultipleChoices {
+		return 0, fmt.Errorf("invalid success %s %v", key, raw)
+	}
+	return status, nil
+}
+
+// HasSuccessStatus returns true if the endpoint declares the status code of its successful
+// responses, so the routers return the status code of the metadata of its complete responses
+func HasSuccessStatus(cfg *config.EndpointConfig) bool {
+	s, err := getSuccessStatus(cfg)
+	return s != nil && err == nil && cfg.OutputEncoding != encoding.NOOP
+}
+
+// resolve returns the status code of a complete response with the metadata of the backends
+func (s *successStatus) resolve(resp *Response, metas []Metadata) int {
+	if len(resp.Data) == 0 && resp.Io == nil {
+		return s.empty
+	}
+	if s.backend >= 0 {
+		if status := metas[s.backend].StatusCode; status >= http.StatusOK && status < http.StatusMultipleChoices {
+			return status
+		}
+	}
+	return s.status
+}
+
+// NewSuccessStatusMiddleware creates a proxy middleware setting the status code of the complete
+// responses of the endpoint declaring a success status. The NewBackendMetadataMiddleware of the
+// backends record their status codes
+func NewSuccessStatusMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	s, err := getSuccessStatus(endpointConfig)
+	logPrefix := "[ENDPOINT: " + endpointConfig.Endpoint + "][SuccessStatus]"
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return emptyMiddlewareFallback(logger)
+	}
+	if s == nil {
+		return emptyMiddlewareFallback(logger)
+	}
+	if endpointConfig.OutputEncoding == encoding.NOOP {
+		logger.Warning(logPrefix, "The no-op endpoints already return the status code of their backend")
+		return emptyMiddlewareFallback(logger)
+	}
+	logger.Debug(logPrefix, "Returning the status", s.status)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewSuccessStatusMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			ctx, metas := withBackendMetadata(ctx, endpointConfig.Backend)
+			resp, err := next[0](ctx, r)
+			if err != nil || resp == nil || !resp.IsComplete {
+				return resp, err
+			}
+			// the response could be shared, so the status is set to a copy
+			res := *resp
+			res.Metadata.StatusCode = s.resolve(resp, metas.collected())
+			return &res, err
+		}
+	}
+}
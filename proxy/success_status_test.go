@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestGetSuccessStatus(t *testing.T) {
+	backends := []*config.Backend{{}, {}}
+	for i, tc := range []struct {
+		cfg    map[string]interface{}
+		status int
+		empty  int
+		err    bool
+	}{
+		{map[string]interface{}{}, 200, 200, false},
+		{map[string]interface{}{"status": 201.0}, 201, 201, false},
+		{map[string]interface{}{"status": 201.0, "empty_status": 204.0, "backend": 1.0}, 201, 204, false},
+		{map[string]interface{}{"status": 404.0}, 0, 0, true},
+		{map[string]interface{}{"empty_status": "none"}, 0, 0, true},
+		{map[string]interface{}{"backend": 2.0}, 0, 0, true},
+	} {
+		s, err := getSuccessStatus(&config.EndpointConfig{
+			Backend:     backends,
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{successStatusKey: tc.cfg}},
+		})
+		if (err != nil) != tc.err {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if err == nil && (s.status != tc.status || s.empty != tc.empty) {
+			t.Errorf("#%d: unexpected success status: %+v", i, s)
+		}
+	}
+}
+
+func TestNewSuccessStatusMiddleware(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/a",
+		Timeout:  time.Second,
+		Backend:  []*config.Backend{{URLPattern: "/a"}, {URLPattern: "/b"}},
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				successStatusKey: map[string]interface{}{"status": 201.0, "empty_status": 204.0, "backend": 1.0},
+			},
+		},
+	}
+	backend := func(i, status int, data map[string]interface{}) Proxy {
+		return NewBackendMetadataMiddleware(logging.NoOp, endpoint.Backend[i])(
+			func(ctx context.Context, _ *Request) (*Response, error) {
+				if status != 0 {
+					captureMetadata(ctx, &http.Response{StatusCode: status, Header: http.Header{}})
+				}
+				return &Response{Data: data, IsComplete: true}, nil
+			},
+		)
+	}
+	request := func() *Request { return &Request{Params: map[string]string{}, Headers: map[string][]string{}} }
+
+	for i, tc := range []struct {
+		first, second int
+		data          map[string]interface{}
+		expected      int
+	}{
+		{200, 0, map[string]interface{}{"a": 1}, http.StatusCreated},
+		{200, 200, map[string]interface{}{"a": 1}, http.StatusOK},
+		{200, 202, map[string]interface{}{"a": 1}, http.StatusAccepted},
+		{200, 202, map[string]interface{}{}, http.StatusNoContent},
+	} {
+		p := NewSuccessStatusMiddleware(logging.NoOp, endpoint)(
+			NewMergeDataMiddleware(logging.NoOp, endpoint)(
+				backend(0, tc.first, tc.data),
+				backend(1, tc.second, map[string]interface{}{}),
+			),
+		)
+		resp, err := p(context.Background(), request())
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if resp.Metadata.StatusCode != tc.expected {
+			t.Errorf("#%d: unexpected status: %d", i, resp.Metadata.StatusCode)
+		}
+	}
+
+	// the failed responses are not modified
+	expectedErr := errors.New("boom")
+	p := NewSuccessStatusMiddleware(logging.NoOp, endpoint)(func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{Data: map[string]interface{}{"a": 1}}, expectedErr
+	})
+	if resp, err := p(context.Background(), request()); err != expectedErr || resp.Metadata.StatusCode != 0 {
+		t.Errorf("unexpected result: %+v %v", resp, err)
+	}
+
+	noop := *endpoint
+	noop.OutputEncoding = encoding.NOOP
+	if HasSuccessStatus(&noop) || !HasSuccessStatus(endpoint) {
+		t.Error("unexpected success status")
+	}
+}
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/telemetry"
+)
+
+// NewTelemetryMiddleware creates proxy middleware recording the request rate, the latency and
+// the errors of the endpoint in the telemetry collector, if the telemetry is enabled
+func NewTelemetryMiddleware(logger logging.Logger, endpoint *config.EndpointConfig) Middleware {
+	if !telemetry.Enabled() {
+		return emptyMiddlewareFallback(logger)
+	}
+	return newTelemetryMiddleware(logger, telemetry.DefaultCollector(), endpoint.Method+" "+endpoint.Endpoint)
+}
+
+func newTelemetryMiddleware(logger logging.Logger, c *telemetry.Collector, name string) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewTelemetryMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			begin := time.Now()
+			resp, err := next[0](ctx, r)
+			c.Observe(name, time.Since(begin), err != nil, resp != nil && resp.IsComplete)
+			return resp, err
+		}
+	}
+}
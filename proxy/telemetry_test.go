@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/telemetry"
+)
+
+func TestNewTelemetryMiddleware(t *testing.T) {
+	c := telemetry.NewCollector()
+	mw := newTelemetryMiddleware(logging.NoOp, c, "GET /observed")
+
+	ok := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{IsComplete: true}, nil
+	})
+	ko := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		return nil, errors.New("ko")
+	})
+
+	ok(context.Background(), &Request{})
+	ok(context.Background(), &Request{})
+	ko(context.Background(), &Request{})
+
+	s := c.Snapshot().Endpoints["GET /observed"]
+	if s.Requests != 3 || s.Errors != 1 || s.Incomplete != 0 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/tracing"
+)
+
+// NewMergeTracingMiddleware creates proxy middleware opening a span for the merge of the backend
+// responses of the endpoint, so the spans of its backends become its children
+func NewMergeTracingMiddleware(logger logging.Logger, endpointConfig *config.EndpointConfig) Middleware {
+	t, ok := tracing.GetGlobal()
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewMergeTracingMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			ctx, span := t.Start(ctx, "merge "+endpointConfig.Endpoint, tracing.SpanKindInternal)
+			span.SetAttribute("lura.backends", len(endpointConfig.Backend))
+			resp, err := next[0](ctx, r)
+			finishProxySpan(span, resp, err)
+			return resp, err
+		}
+	}
+}
+
+// NewBackendTracingMiddleware creates proxy middleware opening a span for every request to the
+// backend, covering all its middlewares and the calls to its hosts
+func NewBackendTracingMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	t, ok := tracing.GetGlobal()
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	logger.Debug(fmt.Sprintf("[BACKEND: %s %s -> %s][Tracing]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern), "Tracing the requests")
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewBackendTracingMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			ctx, span := t.Start(ctx, "backend "+remote.URLPattern, tracing.SpanKindInternal)
+			span.SetAttribute("lura.backend.method", remote.Method)
+			span.SetAttribute("lura.backend.url_pattern", remote.URLPattern)
+			resp, err := next[0](ctx, r)
+			finishProxySpan(span, resp, err)
+			return resp, err
+		}
+	}
+}
+
+func finishProxySpan(span *tracing.Span, resp *Response, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	if resp != nil {
+		span.SetAttribute("lura.response.complete", resp.IsComplete)
+	}
+	span.Finish()
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/tracing"
+)
+
+func TestNewBackendTracingMiddleware(t *testing.T) {
+	var spans []*tracing.Span
+	tracer, err := tracing.New(tracing.Config{}, tracing.ExporterFunc(func(_ context.Context, _ string, s []*tracing.Span) error {
+		spans = append(spans, s...)
+		return nil
+	}), logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracing.SetGlobal(tracer)
+	defer tracing.SetGlobal(nil)
+
+	endpoint := &config.EndpointConfig{Endpoint: "/aggregated"}
+	backends := []*config.Backend{
+		{URLPattern: "/a", Method: "GET"},
+		{URLPattern: "/b", Method: "GET"},
+	}
+	endpoint.Backend = backends
+
+	backendProxy := func(fail bool) Proxy {
+		return func(ctx context.Context, _ *Request) (*Response, error) {
+			if _, ok := tracing.SpanFromContext(ctx); !ok {
+				t.Error("the backend span is not in the context")
+			}
+			if fail {
+				return nil, errors.New("boom")
+			}
+			return &Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+		}
+	}
+	merge := func(next ...Proxy) Proxy {
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			for _, p := range next {
+				p(ctx, r)
+			}
+			return &Response{}, nil
+		}
+	}
+
+	p := NewMergeTracingMiddleware(logging.NoOp, endpoint)(merge(
+		NewBackendTracingMiddleware(logging.NoOp, backends[0])(backendProxy(false)),
+		NewBackendTracingMiddleware(logging.NoOp, backends[1])(backendProxy(true)),
+	))
+	if _, err := p(context.Background(), &Request{}); err != nil {
+		t.Fatal(err)
+	}
+	tracer.Close()
+
+	if len(spans) != 3 {
+		t.Fatalf("unexpected number of spans: %d", len(spans))
+	}
+	a, b, m := spans[0], spans[1], spans[2]
+	if m.Name != "merge /aggregated" || a.Name != "backend /a" || b.Name != "backend /b" {
+		t.Errorf("unexpected spans: %s, %s, %s", a.Name, b.Name, m.Name)
+	}
+	if a.Parent != m.Context.SpanID || b.Parent != m.Context.SpanID {
+		t.Error("the backend spans are not children of the merge one")
+	}
+	if a.Error != "" || b.Error != "boom" {
+		t.Errorf("unexpected errors: %q, %q", a.Error, b.Error)
+	}
+}
+
+func TestNewBackendTracingMiddleware_disabled(t *testing.T) {
+	called := false
+	p := NewBackendTracingMiddleware(logging.NoOp, &config.Backend{})(func(ctx context.Context, _ *Request) (*Response, error) {
+		called = true
+		if _, ok := tracing.SpanFromContext(ctx); ok {
+			t.Error("unexpected span")
+		}
+		return nil, nil
+	})
+	p(context.Background(), &Request{})
+	if !called {
+		t.Error("the backend was not called")
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const urlEncodingKey = "url_encoding"
+
+// getPathEncoding parses the encoding of the params inserted in the url pattern:
+//
+//	"github.com/devopsfaith/krakend/proxy": {
+//		"url_encoding": {
+//			"path": true,
+//			"query": true,
+//			"multi_segment": ["path"]
+//		}
+//	}
+func getPathEncoding(extra config.ExtraConfig) (PathEncoding, bool, error) {
+	enc := PathEncoding{}
+	e, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return enc, false, nil
+	}
+	tmp, ok := e[urlEncodingKey].(map[string]interface{})
+	if !ok {
+		return enc, false, nil
+	}
+	enc.Path, _ = tmp["path"].(bool)
+	enc.Query, _ = tmp["query"].(bool)
+	params, _ := tmp["multi_segment"].([]interface{})
+	for _, p := range params {
+		name, ok := p.(string)
+		if !ok || name == "" {
+			return enc, true, fmt.Errorf("url_encoding: invalid multi_segment param %v", p)
+		}
+		if enc.MultiSegment == nil {
+			enc.MultiSegment = map[string]bool{}
+		}
+		// the params of the requests are capitalized by the router
+		enc.MultiSegment[strings.ToUpper(name[:1])+name[1:]] = true
+	}
+	return enc, true, nil
+}
+
+// inheritPathEncoding copies the url encoding declared by the endpoint to the backends not
+// declaring their own one
+func inheritPathEncoding(logger logging.Logger, cfg *config.EndpointConfig) {
+	e, ok := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return
+	}
+	v, ok := e[urlEncodingKey]
+	if !ok {
+		return
+	}
+	for _, b := range cfg.Backend {
+		if _, ok, _ := getPathEncoding(b.ExtraConfig); ok {
+			continue
+		}
+
+		// the maps of the config can be shared, so they are copied instead of updated
+		ns := map[string]interface{}{}
+		if v, ok := b.ExtraConfig[Namespace].(map[string]interface{}); ok {
+			for k, v := range v {
+				ns[k] = v
+			}
+		}
+		ns[urlEncodingKey] = v
+		extra := make(config.ExtraConfig, len(b.ExtraConfig)+1)
+		for k, v := range b.ExtraConfig {
+			extra[k] = v
+		}
+		extra[Namespace] = ns
+		b.ExtraConfig = extra
+		logger.Debug(fmt.Sprintf("[ENDPOINT: %s][URLEncoding] Backend %s inherits the url encoding", cfg.Endpoint, b.URLPattern))
+	}
+}
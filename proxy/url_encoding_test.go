@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestGetPathEncoding(t *testing.T) {
+	if _, ok, _ := getPathEncoding(config.ExtraConfig{}); ok {
+		t.Error("the config should not be found")
+	}
+	enc, ok, err := getPathEncoding(config.ExtraConfig{Namespace: map[string]interface{}{
+		urlEncodingKey: map[string]interface{}{"path": true, "multi_segment": []interface{}{"path"}},
+	}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if !enc.Path || enc.Query || !enc.MultiSegment["Path"] {
+		t.Errorf("unexpected encoding: %+v", enc)
+	}
+	if _, _, err := getPathEncoding(config.ExtraConfig{Namespace: map[string]interface{}{
+		urlEncodingKey: map[string]interface{}{"multi_segment": []interface{}{42}},
+	}}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestInheritPathEncoding(t *testing.T) {
+	shared := map[string]interface{}{"cache": false}
+	cfg := &config.EndpointConfig{
+		Endpoint: "/users/{id}",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			urlEncodingKey: map[string]interface{}{"path": true},
+		}},
+		Backend: []*config.Backend{
+			{URLPattern: "/a", ExtraConfig: config.ExtraConfig{Namespace: shared}},
+			{URLPattern: "/b", ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+				urlEncodingKey: map[string]interface{}{"query": true},
+			}}},
+		},
+	}
+	inheritPathEncoding(logging.NoOp, cfg)
+
+	if enc, ok, _ := getPathEncoding(cfg.Backend[0].ExtraConfig); !ok || !enc.Path {
+		t.Errorf("the first backend should inherit the encoding: %+v", enc)
+	}
+	if _, ok := shared[urlEncodingKey]; ok {
+		t.Error("the shared config should not be modified")
+	}
+	if enc, _, _ := getPathEncoding(cfg.Backend[1].ExtraConfig); enc.Path || !enc.Query {
+		t.Errorf("the second backend should keep its encoding: %+v", enc)
+	}
+}
+
+func TestNewRequestBuilderMiddleware_urlEncoding(t *testing.T) {
+	backend := &config.Backend{
+		URLPattern: "/users/{{.Id}}",
+		Method:     "GET",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			urlEncodingKey: map[string]interface{}{"path": true},
+		}},
+	}
+	var path string
+	p := NewRequestBuilderMiddleware(backend)(func(_ context.Context, r *Request) (*Response, error) {
+		path = r.Path
+		return &Response{}, nil
+	})
+	p(context.Background(), &Request{Params: map[string]string{"Id": "a b"}})
+	if path != "/users/a%20b" {
+		t.Errorf("unexpected path %s", path)
+	}
+}
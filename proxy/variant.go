@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const (
+	variantsKey = "variants"
+	variantKey  = "variant"
+)
+
+// variant is an alternative set of backends of an endpoint, selected by the requests matching
+// all its predicates. The endpoints declare the variants in order and the backends declare the
+// variant they belong to:
+//
+//	"endpoint": "/users/{id}",
+//	"input_headers": ["X-Beta", "Cookie"],
+//	"extra_config": {
+//		"github.com/devopsfaith/krakend/proxy": {
+//			"variants": [
+//				{"name": "beta", "headers": {"X-Beta": "true"}},
+//				{"name": "internal", "cookies": {"ring": "internal"}}
+//			]
+//		}
+//	},
+//	"backend": [
+//		{"url_pattern": "/v1/users/{id}"},
+//		{"url_pattern": "/v2/users/{id}", "extra_config": {"github.com/devopsfaith/krakend/proxy": {"variant": "beta"}}}
+//	]
+//
+// The first matching variant serves the request and the requests not matching any variant are
+// served by the backends without variant. A "*" value matches any request carrying the header or
+// the cookie. The headers, including the Cookie one, must be declared in the input_headers
+type variant struct {
+	name    string
+	headers map[string]string
+	cookies map[string]string
+}
+
+// getVariants returns the variants declared by the endpoint, if any
+func getVariants(cfg *config.EndpointConfig) ([]variant, error) {
+	e, ok := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	tmp, ok := e[variantsKey].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+	variants := make([]variant, 0, len(tmp))
+	names := map[string]struct{}{}
+	for i, v := range tmp {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid variant #%d", i)
+		}
+		name, _ := m["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("the variant #%d has no name", i)
+		}
+		if _, ok := names[name]; ok {
+			return nil, fmt.Errorf("duplicated variant %s", name)
+		}
+		names[name] = struct{}{}
+		vr := variant{name: name, headers: map[string]string{}, cookies: map[string]string{}}
+		for k, v := range getStringMap(m["headers"]) {
+			vr.headers[textproto.CanonicalMIMEHeaderKey(k)] = v
+		}
+		vr.cookies = getStringMap(m["cookies"])
+		if len(vr.headers)+len(vr.cookies) == 0 {
+			return nil, fmt.Errorf("the variant %s has no predicates", name)
+		}
+		variants = append(variants, vr)
+	}
+	return variants, nil
+}
+
+func getStringMap(v interface{}) map[string]string {
+	res := map[string]string{}
+	m, _ := v.(map[string]interface{})
+	for k, v := range m {
+		if s, ok := v.(string); ok {
+			res[k] = s
+		}
+	}
+	return res
+}
+
+// getBackendVariant returns the name of the variant the backend belongs to, if any
+func getBackendVariant(remote *config.Backend) string {
+	e, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	v, _ := e[variantKey].(string)
+	return v
+}
+
+func (v variant) match(r *Request) bool {
+	for k, expected := range v.headers {
+		vs := r.Headers[k]
+		if len(vs) == 0 || (expected != "*" && vs[0] != expected) {
+			return false
+		}
+	}
+	if len(v.cookies) == 0 {
+		return true
+	}
+	req := http.Request{Header: http.Header{"Cookie": r.Headers["Cookie"]}}
+	for k, expected := range v.cookies {
+		c, err := req.Cookie(k)
+		if err != nil || (expected != "*" && c.Value != expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// newVariants returns the pipe of the backends of the endpoint. The endpoints declaring variants
+// get a pipe for every set of backends and the requests are sent to the one of the first variant
+// they match
+func (pf defaultFactory) newVariants(cfg *config.EndpointConfig) (Proxy, error) {
+	variants, err := getVariants(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("endpoint %s: %w", cfg.Endpoint, err)
+	}
+	if len(variants) == 0 {
+		return pf.newBackends(cfg)
+	}
+
+	sets := map[string][]*config.Backend{}
+	for _, b := range cfg.Backend {
+		name := getBackendVariant(b)
+		sets[name] = append(sets[name], b)
+	}
+	declared := map[string]struct{}{"": {}}
+	for _, v := range variants {
+		declared[v.name] = struct{}{}
+	}
+	for name := range sets {
+		if _, ok := declared[name]; !ok {
+			return nil, fmt.Errorf("endpoint %s: the backends declare the unknown variant %s", cfg.Endpoint, name)
+		}
+	}
+
+	newSet := func(name string) (Proxy, error) {
+		set := *cfg
+		set.Backend = sets[name]
+		p, err := pf.newBackends(&set)
+		if err != nil && name != "" {
+			err = fmt.Errorf("variant %s: %w", name, err)
+		}
+		return p, err
+	}
+	regular, err := newSet("")
+	if err != nil {
+		return nil, err
+	}
+	proxies := make([]Proxy, len(variants))
+	names := make([]string, len(variants))
+	for i, v := range variants {
+		if proxies[i], err = newSet(v.name); err != nil {
+			return nil, err
+		}
+		names[i] = v.name
+	}
+	pf.logger.Debug(fmt.Sprintf("[ENDPOINT: %s][Variants] Routing the matching requests to the variants %s", cfg.Endpoint, strings.Join(names, ", ")))
+	return newVariantProxy(variants, proxies, regular), nil
+}
+
+func newVariantProxy(variants []variant, proxies []Proxy, regular Proxy) Proxy {
+	return func(ctx context.Context, r *Request) (*Response, error) {
+		for i, v := range variants {
+			if v.match(r) {
+				return proxies[i](ctx, r)
+			}
+		}
+		return regular(ctx, r)
+	}
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func variantBackend(pattern, name string) *config.Backend {
+	b := &config.Backend{
+		URLPattern: pattern,
+		Host:       []string{"http://example.com"},
+		Timeout:    time.Second,
+	}
+	if name != "" {
+		b.ExtraConfig = config.ExtraConfig{Namespace: map[string]interface{}{variantKey: name}}
+	}
+	return b
+}
+
+func variantEndpoint(variants []interface{}, backends ...*config.Backend) *config.EndpointConfig {
+	return &config.EndpointConfig{
+		Endpoint: "/users",
+		Method:   "GET",
+		Timeout:  time.Second,
+		Backend:  backends,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{variantsKey: variants},
+		},
+	}
+}
+
+func TestGetVariants(t *testing.T) {
+	if vs, err := getVariants(&config.EndpointConfig{}); err != nil || len(vs) != 0 {
+		t.Errorf("unexpected result. variants: %v, err: %v", vs, err)
+	}
+	vs, err := getVariants(variantEndpoint([]interface{}{
+		map[string]interface{}{"name": "beta", "headers": map[string]interface{}{"x-beta": "true"}},
+		map[string]interface{}{"name": "internal", "cookies": map[string]interface{}{"ring": "*"}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 2 || vs[0].headers["X-Beta"] != "true" || vs[1].cookies["ring"] != "*" {
+		t.Errorf("unexpected variants: %+v", vs)
+	}
+
+	for _, variants := range [][]interface{}{
+		{"beta"},
+		{map[string]interface{}{"headers": map[string]interface{}{"X-Beta": "true"}}},
+		{map[string]interface{}{"name": "beta"}},
+		{
+			map[string]interface{}{"name": "beta", "headers": map[string]interface{}{"X-Beta": "true"}},
+			map[string]interface{}{"name": "beta", "headers": map[string]interface{}{"X-Beta": "1"}},
+		},
+	} {
+		if _, err := getVariants(variantEndpoint(variants)); err == nil {
+			t.Errorf("%v: expecting an error", variants)
+		}
+	}
+}
+
+func TestVariant_match(t *testing.T) {
+	v := variant{
+		name:    "beta",
+		headers: map[string]string{"X-Beta": "true", "X-Client": "*"},
+		cookies: map[string]string{"ring": "beta"},
+	}
+	for i, tc := range []struct {
+		headers map[string][]string
+		match   bool
+	}{
+		{headers: map[string][]string{"X-Beta": {"true"}, "X-Client": {"web"}, "Cookie": {"a=b; ring=beta"}}, match: true},
+		{headers: map[string][]string{"X-Beta": {"false"}, "X-Client": {"web"}, "Cookie": {"ring=beta"}}},
+		{headers: map[string][]string{"X-Beta": {"true"}, "Cookie": {"ring=beta"}}},
+		{headers: map[string][]string{"X-Beta": {"true"}, "X-Client": {"web"}, "Cookie": {"ring=stable"}}},
+		{headers: map[string][]string{"X-Beta": {"true"}, "X-Client": {"web"}}},
+		{headers: map[string][]string{}},
+	} {
+		if m := v.match(&Request{Headers: tc.headers}); m != tc.match {
+			t.Errorf("#%d: unexpected match. have: %v, want: %v", i, m, tc.match)
+		}
+	}
+}
+
+func TestDefaultFactory_variants(t *testing.T) {
+	var called string
+	factory := NewDefaultFactory(func(remote *config.Backend) Proxy {
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			called = remote.URLPattern
+			return &Response{IsComplete: true, Data: map[string]interface{}{}}, nil
+		}
+	}, logging.NoOp)
+
+	variants := []interface{}{
+		map[string]interface{}{"name": "beta", "headers": map[string]interface{}{"X-Beta": "true"}},
+		map[string]interface{}{"name": "internal", "cookies": map[string]interface{}{"ring": "internal"}},
+	}
+	cfg := variantEndpoint(variants,
+		variantBackend("/v1", ""),
+		variantBackend("/v2", "beta"),
+		variantBackend("/internal", "internal"),
+	)
+	p, err := factory.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Backend) != 3 {
+		t.Errorf("the backends of the endpoint should not be altered: %d", len(cfg.Backend))
+	}
+
+	for _, tc := range []struct {
+		headers map[string][]string
+		want    string
+	}{
+		{headers: map[string][]string{}, want: "/v1"},
+		{headers: map[string][]string{"X-Beta": {"true"}}, want: "/v2"},
+		{headers: map[string][]string{"Cookie": {"ring=internal"}}, want: "/internal"},
+		{headers: map[string][]string{"X-Beta": {"true"}, "Cookie": {"ring=internal"}}, want: "/v2"},
+	} {
+		called = ""
+		if _, err := p(context.Background(), &Request{Method: "GET", Path: "/users", Headers: tc.headers}); err != nil {
+			t.Fatal(err)
+		}
+		if called != tc.want {
+			t.Errorf("%v: unexpected backend. have: %s, want: %s", tc.headers, called, tc.want)
+		}
+	}
+
+	for _, backends := range [][]*config.Backend{
+		{variantBackend("/v1", ""), variantBackend("/v2", "gamma")},
+		{variantBackend("/v2", "beta"), variantBackend("/internal", "internal")},
+		{variantBackend("/v1", ""), variantBackend("/internal", "internal")},
+	} {
+		if _, err := factory.New(variantEndpoint(variants, backends...)); err == nil {
+			t.Errorf("expecting an error for the backends %v", backends)
+		}
+	}
+}
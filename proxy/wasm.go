@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/wasm"
+)
+
+// NewWASMMiddleware creates proxy middleware processing the requests and the responses of the
+// endpoint with the WebAssembly module declared in its extra config
+func NewWASMMiddleware(logger logging.Logger, endpoint *config.EndpointConfig) Middleware {
+	return newWASMMiddleware(logger, "[ENDPOINT: "+endpoint.Endpoint+"][WASM]", endpoint.ExtraConfig)
+}
+
+// NewBackendWASMMiddleware creates proxy middleware processing the requests and the responses of
+// the backend with the WebAssembly module declared in its extra config
+func NewBackendWASMMiddleware(logger logging.Logger, remote *config.Backend) Middleware {
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][WASM]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	return newWASMMiddleware(logger, logPrefix, remote.ExtraConfig)
+}
+
+func newWASMMiddleware(logger logging.Logger, logPrefix string, extra config.ExtraConfig) Middleware {
+	cfg, ok := wasm.ConfigGetter(extra)
+	if !ok {
+		return emptyMiddlewareFallback(logger)
+	}
+	m, err := wasm.Load(context.Background(), cfg)
+	if err != nil {
+		logger.Error(logPrefix, "Unable to load the module:", err.Error())
+		return errorMiddlewareFallback(logger, err)
+	}
+	moduleCfg, _ := json.Marshal(cfg.Config)
+	onRequest, onResponse := m.Exports(wasm.OnRequest), m.Exports(wasm.OnResponse)
+	if !onRequest && !onResponse {
+		logger.Error(logPrefix, "The module", cfg.Module, "does not export any processor")
+		return errorMiddlewareFallback(logger, fmt.Errorf("wasm: the module %s does not export any processor", cfg.Module))
+	}
+
+	logger.Debug(logPrefix, "Processing with", cfg.Module, "on_request:", onRequest, "on_response:", onResponse)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			logger.Fatal("too many proxies for this proxy middleware: NewWASMMiddleware only accepts 1 proxy, got %d", len(next))
+			return nil
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			if onRequest {
+				if err := processWASMRequest(ctx, m, moduleCfg, r); err != nil {
+					return nil, err
+				}
+			}
+
+			resp, err := next[0](ctx, r)
+			if !onResponse || err != nil || resp == nil {
+				return resp, err
+			}
+			if err := processWASMResponse(ctx, m, moduleCfg, r, resp); err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+	}
+}
+
+func processWASMRequest(ctx context.Context, m wasm.Module, moduleCfg []byte, r *Request) error {
+	ex := &wasm.Exchange{
+		Method:  r.Method,
+		Path:    r.Path,
+		Headers: http.Header(r.Headers),
+		Config:  moduleCfg,
+	}
+	if ex.Headers == nil {
+		ex.Headers = http.Header{}
+	}
+	if r.Body != nil {
+		b, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return err
+		}
+		ex.Body = b
+	}
+
+	code, err := m.Call(ctx, wasm.OnRequest, ex)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return wasm.AbortError{Function: wasm.OnRequest, Code: code}
+	}
+
+	r.Headers = map[string][]string(ex.Headers)
+	r.Body = io.NopCloser(bytes.NewReader(ex.Body))
+	return nil
+}
+
+func processWASMResponse(ctx context.Context, m wasm.Module, moduleCfg []byte, r *Request, resp *Response) error {
+	body, err := json.Marshal(resp.Data)
+	if err != nil {
+		return err
+	}
+	ex := &wasm.Exchange{
+		Method:  r.Method,
+		Path:    r.Path,
+		Headers: http.Header(resp.Metadata.Headers),
+		Body:    body,
+		Status:  resp.Metadata.StatusCode,
+		Config:  moduleCfg,
+	}
+	if ex.Headers == nil {
+		ex.Headers = http.Header{}
+	}
+
+	code, err := m.Call(ctx, wasm.OnResponse, ex)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return wasm.AbortError{Function: wasm.OnResponse, Code: code}
+	}
+
+	if ex.BodyChanged() {
+		data := map[string]interface{}{}
+		if err := json.Unmarshal(ex.Body, &data); err != nil {
+			return fmt.Errorf("wasm: the module returned an invalid body: %w", err)
+		}
+		resp.Data = data
+	}
+	resp.Metadata.Headers = map[string][]string(ex.Headers)
+	resp.Metadata.StatusCode = ex.Status
+	return nil
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/wasm"
+)
+
+type fakeWASMModule struct{}
+
+func (fakeWASMModule) Exports(_ string) bool { return true }
+
+func (fakeWASMModule) Call(_ context.Context, name string, ex *wasm.Exchange) (int32, error) {
+	switch name {
+	case wasm.OnRequest:
+		if _, ok := ex.GetHeader("Authorization"); !ok {
+			return 401, nil
+		}
+		ex.SetHeader("X-Processed", "request")
+		ex.SetBody([]byte(strings.ToUpper(string(ex.Body))))
+	case wasm.OnResponse:
+		ex.SetHeader("X-Processed", "response")
+		ex.SetBody([]byte(`{"rewritten":true}`))
+		ex.Status = 202
+	}
+	return 0, nil
+}
+
+func (fakeWASMModule) Close(_ context.Context) error { return nil }
+
+func TestNewWASMMiddleware(t *testing.T) {
+	wasm.RegisterRuntime("proxy-test", wasm.RuntimeFunc(func(_ context.Context, _ string, _ []byte) (wasm.Module, error) {
+		return fakeWASMModule{}, nil
+	}))
+	path := filepath.Join(t.TempDir(), "module.wasm")
+	if err := os.WriteFile(path, []byte("\x00asm"), 0o600); err != nil {
+		t.Error(err)
+		return
+	}
+
+	p := NewWASMMiddleware(logging.NoOp, &config.EndpointConfig{
+		Endpoint: "/wasm",
+		ExtraConfig: config.ExtraConfig{
+			wasm.Namespace: map[string]interface{}{
+				"runtime": "proxy-test",
+				"module":  path,
+			},
+		},
+	})(func(_ context.Context, r *Request) (*Response, error) {
+		if h := r.Headers["X-Processed"]; len(h) != 1 || h[0] != "request" {
+			t.Errorf("unexpected headers: %v", r.Headers)
+		}
+		if b, _ := io.ReadAll(r.Body); string(b) != "PAYLOAD" {
+			t.Errorf("unexpected body: %s", string(b))
+		}
+		return &Response{Data: map[string]interface{}{"foo": "bar"}, IsComplete: true}, nil
+	})
+
+	resp, err := p(context.Background(), &Request{
+		Headers: map[string][]string{"Authorization": {"Bearer x"}},
+		Body:    io.NopCloser(strings.NewReader("payload")),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if resp.Data["rewritten"] != true || resp.Metadata.StatusCode != 202 || resp.Metadata.Headers["X-Processed"][0] != "response" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	_, err = p(context.Background(), &Request{Headers: map[string][]string{}})
+	abort, ok := err.(wasm.AbortError)
+	if !ok || abort.StatusCode() != 401 {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewWASMMiddleware_unknownRuntime(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint: "/supu",
+		ExtraConfig: config.ExtraConfig{
+			wasm.Namespace: map[string]interface{}{"runtime": "unknown", "module": "processor.wasm"},
+		},
+		Backend: []*config.Backend{{URLPattern: "/"}},
+	}
+	p := NewWASMMiddleware(logging.NoOp, cfg)(func(_ context.Context, _ *Request) (*Response, error) {
+		t.Error("the backend should not be called")
+		return &Response{IsComplete: true}, nil
+	})
+	if resp, err := p(context.Background(), &Request{}); err == nil || resp != nil {
+		t.Errorf("unexpected result: %v %v", resp, err)
+	}
+
+	if _, err := DefaultFactory(logging.NoOp).New(cfg); err == nil || err.Error() != "wasm: unknown runtime unknown" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
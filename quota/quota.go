@@ -0,0 +1,378 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package quota limits the number of requests each client can send per day and per month.
+
+The service declares the quotas of the clients, with the overrides of the clients with a plan of
+their own:
+
+	"extra_config": {
+		"github.com/luraproject/lura/quota": {
+			"daily": 1000,
+			"monthly": 20000,
+			"clients": {
+				"mobile-app": {"daily": 10000, "monthly": 200000}
+			}
+		}
+	}
+
+A zero quota does not limit its period. The client of a request is the client id of its API key
+identity or the subject of its JWT claims, and the requests without any of them are not limited.
+The endpoints opt out with:
+
+	"extra_config": {
+		"github.com/luraproject/lura/quota": {
+			"disabled": true
+		}
+	}
+
+The periods follow the UTC calendar. Every instance of the gateway tracks the usage in memory by
+default, and the clusters of gateways share it with the "store": "shared" option, which keeps it
+in the shared store of the service (see the sharedstore package) and falls back to the memory
+while the shared store is not available. Other persistence layers can be injected with
+NewWithStore.
+*/
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/router/apikey"
+	"github.com/luraproject/lura/v2/router/jwt"
+	"github.com/luraproject/lura/v2/sharedstore"
+)
+
+// Namespace is the key to use to store and access the quota config
+const Namespace = "github.com/luraproject/lura/quota"
+
+const (
+	// LocalStore is the store keeping the usage in the memory of every instance
+	LocalStore = "local"
+	// SharedStore is the store keeping the usage in the shared store of the service
+	SharedStore = "shared"
+)
+
+// Period is the length of the windows of a quota
+type Period string
+
+const (
+	// Daily windows start at midnight UTC
+	Daily Period = "daily"
+	// Monthly windows start at the first day of the month UTC
+	Monthly Period = "monthly"
+)
+
+// start returns the start of the window containing t
+func (p Period) start(t time.Time) time.Time {
+	t = t.UTC()
+	if p == Monthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// end returns the end of the window starting at start
+func (p Period) end(start time.Time) time.Time {
+	if p == Monthly {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// ExceededError is returned when a client exhausted its quota
+type ExceededError struct {
+	Client string
+	Period Period
+	Reset  time.Time
+}
+
+// Error implements the error interface
+func (e ExceededError) Error() string {
+	return fmt.Sprintf("quota: the client %s exceeded its %s quota until %s", e.Client, e.Period, e.Reset.Format(time.RFC3339))
+}
+
+// StatusCode returns the 429 Too Many Requests status code
+func (ExceededError) StatusCode() int { return http.StatusTooManyRequests }
+
+// Limits are the number of requests allowed per period
+type Limits struct {
+	Daily   int64 `json:"daily"`
+	Monthly int64 `json:"monthly"`
+}
+
+func (l Limits) limit(p Period) int64 {
+	if p == Monthly {
+		return l.Monthly
+	}
+	return l.Daily
+}
+
+// Config is the quota config of the service
+type Config struct {
+	Limits
+	Clients map[string]Limits `json:"clients"`
+	Store   string            `json:"store"`
+}
+
+// ConfigGetter parses the quota config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("quota: parsing the config: %w", err)
+	}
+	return cfg, true, nil
+}
+
+// IsDisabled returns true if the extra config of an endpoint opts out of the quotas
+func IsDisabled(e config.ExtraConfig) bool {
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	v, _ := tmp["disabled"].(bool)
+	return v
+}
+
+// Store keeps the usage of the clients in every window
+type Store interface {
+	// Add adds n requests to the usage of the client in the window of the period starting at
+	// start and returns the updated usage. The ttl is the time left to the end of the window
+	Add(ctx context.Context, client string, p Period, start time.Time, ttl time.Duration, n int64) (int64, error)
+}
+
+// NewLocalStore returns a Store keeping the usage in memory
+func NewLocalStore() Store {
+	return &localStore{windows: map[Period]*localWindow{}}
+}
+
+type localStore struct {
+	mu      sync.Mutex
+	windows map[Period]*localWindow
+}
+
+// localWindow drops the usage of the previous window at once, since all the clients share the
+// window boundaries
+type localWindow struct {
+	start time.Time
+	usage map[string]int64
+}
+
+func (s *localStore) Add(_ context.Context, client string, p Period, start time.Time, _ time.Duration, n int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, ok := s.windows[p]
+	if !ok || !start.Equal(w.start) {
+		w = &localWindow{start: start, usage: map[string]int64{}}
+		s.windows[p] = w
+	}
+	w.usage[client] += n
+	return w.usage[client], nil
+}
+
+// NewSharedStore returns a Store keeping the usage in the shared store, so all the instances of
+// the gateway enforce the same quotas. The usage is tracked by the fallback store while the
+// shared one fails
+func NewSharedStore(c sharedstore.Client, fallback Store) Store {
+	return sharedStore{client: c, fallback: fallback}
+}
+
+type sharedStore struct {
+	client   sharedstore.Client
+	fallback Store
+}
+
+func (s sharedStore) Add(ctx context.Context, client string, p Period, start time.Time, ttl time.Duration, n int64) (int64, error) {
+	key := "lura:quota:" + client + ":" + string(p) + ":" + strconv.FormatInt(start.Unix(), 10)
+	usage, err := s.client.Incr(ctx, key, n, ttl)
+	if err != nil {
+		return s.fallback.Add(ctx, client, p, start, ttl, n)
+	}
+	return usage, nil
+}
+
+// Status is the state of the most restrictive quota of a client after a request
+type Status struct {
+	Limit     int64
+	Remaining int64
+	Reset     time.Time
+}
+
+// Quota tracks the requests of the clients in daily and monthly windows
+type Quota struct {
+	limits  Limits
+	clients map[string]Limits
+	store   Store
+	now     func() time.Time
+}
+
+// New returns the Quota of the config, tracking the usage in memory
+func New(cfg Config) (*Quota, error) {
+	return NewWithStore(cfg, NewLocalStore())
+}
+
+// NewWithStore returns the Quota of the config, tracking the usage in the store
+func NewWithStore(cfg Config, s Store) (*Quota, error) {
+	switch cfg.Store {
+	case "", LocalStore, SharedStore:
+	default:
+		return nil, fmt.Errorf("quota: unknown store %s", cfg.Store)
+	}
+	if err := cfg.Limits.validate("the service"); err != nil {
+		return nil, err
+	}
+	for c, l := range cfg.Clients {
+		if err := l.validate("the client " + c); err != nil {
+			return nil, err
+		}
+	}
+	return &Quota{limits: cfg.Limits, clients: cfg.Clients, store: s, now: time.Now}, nil
+}
+
+func (l Limits) validate(owner string) error {
+	if l.Daily < 0 || l.Monthly < 0 {
+		return fmt.Errorf("quota: the quotas of %s can not be negative", owner)
+	}
+	return nil
+}
+
+// Client returns the client of a request with the received context, if identified
+func Client(ctx context.Context) (string, bool) {
+	if i, ok := apikey.FromContext(ctx); ok && i.ClientID != "" {
+		return i.ClientID, true
+	}
+	if c, ok := jwt.FromContext(ctx); ok {
+		if sub, ok := c.Value("sub"); ok && sub != "" {
+			return sub, true
+		}
+	}
+	return "", false
+}
+
+// Limits returns the quotas of the client
+func (q *Quota) Limits(client string) Limits {
+	if l, ok := q.clients[client]; ok {
+		return l
+	}
+	return q.limits
+}
+
+// Consume counts a request of the client and returns the status of its most restrictive quota.
+// It returns an ExceededError, without counting the request, if any quota is exhausted
+func (q *Quota) Consume(ctx context.Context, client string) (Status, error) {
+	limits := q.Limits(client)
+	now := q.now()
+
+	var status Status
+	var charged []Period
+	var starts []time.Time
+	for _, p := range []Period{Daily, Monthly} {
+		limit := limits.limit(p)
+		if limit <= 0 {
+			continue
+		}
+		start := p.start(now)
+		end := p.end(start)
+		usage, err := q.store.Add(ctx, client, p, start, end.Sub(now), 1)
+		if err != nil {
+			q.refund(ctx, client, charged, starts, now)
+			return status, err
+		}
+		charged = append(charged, p)
+		starts = append(starts, start)
+
+		if usage > limit {
+			// the rejected requests do not consume the quotas
+			q.refund(ctx, client, charged, starts, now)
+			return Status{Limit: limit, Remaining: 0, Reset: end}, ExceededError{Client: client, Period: p, Reset: end}
+		}
+		if remaining := limit - usage; status.Limit == 0 || remaining < status.Remaining {
+			status = Status{Limit: limit, Remaining: remaining, Reset: end}
+		}
+	}
+	return status, nil
+}
+
+func (q *Quota) refund(ctx context.Context, client string, ps []Period, starts []time.Time, now time.Time) {
+	for i, p := range ps {
+		q.store.Add(ctx, client, p, starts[i], p.end(starts[i]).Sub(now), -1)
+	}
+}
+
+// SetHeaders adds the X-RateLimit-* headers of the status to the response headers, and the
+// Retry-After one to the rejected requests
+func SetHeaders(h http.Header, s Status, exceeded bool) {
+	if s.Limit == 0 {
+		return
+	}
+	reset := int64(time.Until(s.Reset).Seconds() + 0.5)
+	if reset < 0 {
+		reset = 0
+	}
+	h.Set("X-RateLimit-Limit", strconv.FormatInt(s.Limit, 10))
+	h.Set("X-RateLimit-Remaining", strconv.FormatInt(s.Remaining, 10))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+	if exceeded {
+		h.Set("Retry-After", strconv.FormatInt(reset, 10))
+	}
+}
+
+var (
+	global   *Quota
+	globalMu sync.RWMutex
+)
+
+// Register creates the quotas declared in the service extra config. It returns false if the
+// service does not declare them
+func Register(cfg config.ServiceConfig) (bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok {
+		// drop the quotas of a previous config
+		SetGlobal(nil)
+		return false, nil
+	}
+	var q *Quota
+	if err == nil {
+		q, err = New(c)
+	}
+	if err == nil && c.Store == SharedStore {
+		client, cErr := sharedstore.GetClient(context.Background(), cfg.ExtraConfig)
+		if cErr != nil {
+			// the quotas are still enforced by every instance on its own
+			err = fmt.Errorf("quota: tracking the usage in memory: %w", cErr)
+		} else {
+			q.store = NewSharedStore(client, q.store)
+		}
+	}
+	SetGlobal(q)
+	return true, err
+}
+
+// SetGlobal sets the quotas enforced by the endpoints
+func SetGlobal(q *Quota) {
+	globalMu.Lock()
+	global = q
+	globalMu.Unlock()
+}
+
+// GetGlobal returns the quotas enforced by the endpoints, if any
+func GetGlobal() (*Quota, bool) {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return global, global != nil
+}
@@ -0,0 +1,232 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package quota
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/router/apikey"
+	"github.com/luraproject/lura/v2/router/jwt"
+)
+
+func TestQuota_Consume(t *testing.T) {
+	q, err := New(Config{
+		Limits:  Limits{Daily: 2, Monthly: 3},
+		Clients: map[string]Limits{"premium": {Daily: 10}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2023, 1, 31, 10, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i, tc := range []struct {
+		client    string
+		limit     int64
+		remaining int64
+		err       bool
+	}{
+		{client: "basic", limit: 2, remaining: 1},
+		{client: "basic", limit: 2, remaining: 0},
+		{client: "basic", limit: 2, remaining: 0, err: true},
+		{client: "premium", limit: 10, remaining: 9},
+	} {
+		status, err := q.Consume(ctx, tc.client)
+		if status.Limit != tc.limit || status.Remaining != tc.remaining {
+			t.Errorf("#%d: unexpected status: %+v", i, status)
+		}
+		if (err != nil) != tc.err {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+	}
+
+	// the daily quota is exhausted until midnight, even with a monthly request left
+	now = time.Date(2023, 1, 31, 23, 0, 0, 0, time.UTC)
+	_, err = q.Consume(ctx, "basic")
+	var exceeded ExceededError
+	if !errors.As(err, &exceeded) || exceeded.Period != Daily {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !exceeded.Reset.Equal(time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected reset: %s", exceeded.Reset)
+	}
+	if exceeded.StatusCode() != http.StatusTooManyRequests {
+		t.Errorf("unexpected status code: %d", exceeded.StatusCode())
+	}
+
+	now = time.Date(2023, 2, 1, 0, 30, 0, 0, time.UTC)
+	status, err := q.Consume(ctx, "basic")
+	if err != nil || status.Remaining != 1 || status.Limit != 2 {
+		t.Errorf("the quotas were not renewed: %+v %v", status, err)
+	}
+}
+
+func TestQuota_Consume_monthly(t *testing.T) {
+	q, err := New(Config{Limits: Limits{Daily: 10, Monthly: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2023, 1, 10, 10, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := q.Consume(ctx, "basic"); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(24 * time.Hour)
+	}
+	status, err := q.Consume(ctx, "basic")
+	var exceeded ExceededError
+	if !errors.As(err, &exceeded) || exceeded.Period != Monthly {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Reset.Equal(time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected reset: %s", status.Reset)
+	}
+
+	// the rejected requests do not consume the daily quota
+	l := q.store.(*localStore)
+	if usage := l.windows[Daily].usage["basic"]; usage != 0 {
+		t.Errorf("unexpected daily usage: %d", usage)
+	}
+}
+
+type fakeSharedClient struct {
+	mu       sync.Mutex
+	counters map[string]int64
+	down     bool
+}
+
+func (c *fakeSharedClient) Incr(_ context.Context, key string, delta int64, _ time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.down {
+		return 0, errors.New("connection refused")
+	}
+	c.counters[key] += delta
+	return c.counters[key], nil
+}
+
+func (*fakeSharedClient) Get(_ context.Context, _ string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (*fakeSharedClient) Set(_ context.Context, _ string, _ []byte, _ time.Duration) error {
+	return nil
+}
+
+func (*fakeSharedClient) Del(_ context.Context, _ string) error { return nil }
+
+func (*fakeSharedClient) Close() error { return nil }
+
+func TestQuota_sharedStore(t *testing.T) {
+	client := &fakeSharedClient{counters: map[string]int64{}}
+	ctx := context.Background()
+
+	// two instances of the gateway sharing the store
+	instances := make([]*Quota, 2)
+	for i := range instances {
+		q, err := NewWithStore(Config{Limits: Limits{Daily: 2}, Store: SharedStore}, NewSharedStore(client, NewLocalStore()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		instances[i] = q
+	}
+
+	if _, err := instances[0].Consume(ctx, "basic"); err != nil {
+		t.Error(err)
+	}
+	if _, err := instances[1].Consume(ctx, "basic"); err != nil {
+		t.Error(err)
+	}
+	if _, err := instances[0].Consume(ctx, "basic"); err == nil {
+		t.Error("the usage of the other instance was ignored")
+	}
+
+	client.down = true
+	if status, err := instances[0].Consume(ctx, "basic"); err != nil || status.Remaining != 1 {
+		t.Errorf("the local store was not used: %+v %v", status, err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := Client(ctx); ok {
+		t.Error("the anonymous requests have no client")
+	}
+	if c, ok := Client(jwt.NewContext(ctx, jwt.Claims{"sub": "user-1"})); !ok || c != "user-1" {
+		t.Errorf("unexpected client: %s", c)
+	}
+	ctx = apikey.NewContext(jwt.NewContext(ctx, jwt.Claims{"sub": "user-1"}), apikey.Identity{ClientID: "app"})
+	if c, ok := Client(ctx); !ok || c != "app" {
+		t.Errorf("unexpected client: %s", c)
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	h := http.Header{}
+	SetHeaders(h, Status{Limit: 10, Remaining: 0, Reset: time.Now().Add(time.Minute)}, true)
+	if h.Get("X-RateLimit-Limit") != "10" || h.Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("unexpected headers: %v", h)
+	}
+	if h.Get("X-RateLimit-Reset") != "60" || h.Get("Retry-After") != "60" {
+		t.Errorf("unexpected headers: %v", h)
+	}
+
+	h = http.Header{}
+	SetHeaders(h, Status{}, false)
+	if len(h) != 0 {
+		t.Errorf("unexpected headers: %v", h)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer SetGlobal(nil)
+
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"daily": 100}},
+	}
+	if ok, err := Register(cfg); !ok || err != nil {
+		t.Errorf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if _, ok := GetGlobal(); !ok {
+		t.Error("the quotas were not registered")
+	}
+
+	cfg.ExtraConfig[Namespace] = map[string]interface{}{"daily": -1}
+	if _, err := Register(cfg); err == nil {
+		t.Error("expecting an error")
+	}
+
+	cfg.ExtraConfig[Namespace] = map[string]interface{}{"daily": 100, "store": SharedStore}
+	if ok, err := Register(cfg); !ok || err == nil {
+		t.Errorf("expecting an error without a shared store. ok: %v, err: %v", ok, err)
+	}
+	if _, ok := GetGlobal(); !ok {
+		t.Error("the quotas should fall back to the local store")
+	}
+
+	if ok, _ := Register(config.ServiceConfig{}); ok {
+		t.Error("the service does not declare quotas")
+	}
+	if _, ok := GetGlobal(); ok {
+		t.Error("the quotas of the previous config were not dropped")
+	}
+}
+
+func TestIsDisabled(t *testing.T) {
+	if IsDisabled(config.ExtraConfig{}) {
+		t.Error("the endpoints are not disabled by default")
+	}
+	if !IsDisabled(config.ExtraConfig{Namespace: map[string]interface{}{"disabled": true}}) {
+		t.Error("the endpoint should be disabled")
+	}
+}
@@ -0,0 +1,384 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package recorder captures sampled requests, the responses of their backends and the responses
+returned to the clients in a replayable format, so the production traffic can be inspected when
+debugging and turned into load-test corpora.
+
+The recorder is declared in the service extra config:
+
+	"extra_config": {
+		"github.com/luraproject/lura/recorder": {
+			"sample_rate": 0.01,
+			"endpoints": ["/users/{id}"],
+			"output": "/var/spool/gateway/records.jsonl",
+			"max_body_size": 65536,
+			"redact_headers": ["Authorization", "Cookie", "X-Api-Key"]
+		}
+	}
+
+Every sampled request is written as a JSON line with the received request, the response returned
+to the client and the responses of the backends. The output is the stdout, the stderr, a file or
+an http(s) url receiving the lines with POST requests. The endpoints list restricts the recording
+to some endpoints, and the bodies are truncated at the max body size. The values of the redacted
+headers (Authorization, Cookie, Proxy-Authorization and Set-Cookie by default) are replaced, so
+the records do not leak credentials. As the access log, the records can be buffered and spilled
+to disk with the buffer option (see the spool package).
+
+The Decoder reads the records back and Replay sends them to another deployment.
+*/
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/spool"
+)
+
+// Namespace is the key to use to store and access the recorder config
+const Namespace = "github.com/luraproject/lura/recorder"
+
+// DefaultMaxBodySize is the max size of the recorded bodies when the config does not declare it
+const DefaultMaxBodySize = 64 * 1024
+
+// Redacted replaces the values of the redacted headers
+const Redacted = "[REDACTED]"
+
+// DefaultRedactedHeaders are the headers redacted when the config does not declare them
+var DefaultRedactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+
+// ErrSink is returned when the http sink rejects the records
+var ErrSink = errors.New("recorder: the sink rejected the records")
+
+// Config is the recorder config of the service
+type Config struct {
+	SampleRate    *float64      `json:"sample_rate"`
+	Endpoints     []string      `json:"endpoints"`
+	Output        string        `json:"output"`
+	MaxBodySize   int64         `json:"max_body_size"`
+	RedactHeaders []string      `json:"redact_headers"`
+	Buffer        *spool.Config `json:"buffer"`
+}
+
+// ConfigGetter parses the recorder config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{MaxBodySize: DefaultMaxBodySize, RedactHeaders: DefaultRedactedHeaders}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("recorder: parsing the config: %w", err)
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultMaxBodySize
+	}
+	return cfg, true, nil
+}
+
+// Message is a recorded request or response
+type Message struct {
+	Method    string              `json:"method,omitempty"`
+	URL       string              `json:"url,omitempty"`
+	Status    int                 `json:"status,omitempty"`
+	Headers   map[string][]string `json:"headers,omitempty"`
+	Body      []byte              `json:"body,omitempty"`
+	Truncated bool                `json:"truncated,omitempty"`
+}
+
+// Backend is the recorded response of a backend
+type Backend struct {
+	URLPattern string                 `json:"url_pattern"`
+	Status     int                    `json:"status,omitempty"`
+	Headers    map[string][]string    `json:"headers,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Elapsed    time.Duration          `json:"elapsed"`
+}
+
+// Record is a recorded request with its responses
+type Record struct {
+	Time     time.Time     `json:"time"`
+	Endpoint string        `json:"endpoint"`
+	Request  Message       `json:"request"`
+	Response Message       `json:"response"`
+	Backends []Backend     `json:"backends,omitempty"`
+	Latency  time.Duration `json:"latency"`
+
+	mu sync.Mutex
+}
+
+// AddBackend adds the response of a backend to the record. It is safe for concurrent use
+func (r *Record) AddBackend(b Backend) {
+	r.mu.Lock()
+	r.Backends = append(r.Backends, b)
+	r.mu.Unlock()
+}
+
+type recordKey struct{}
+
+// NewContext returns a copy of the context carrying the record, so the backends can add their
+// responses
+func NewContext(ctx context.Context, r *Record) context.Context {
+	return context.WithValue(ctx, recordKey{}, r)
+}
+
+// FromContext returns the record stored in the context, if any
+func FromContext(ctx context.Context) (*Record, bool) {
+	r, ok := ctx.Value(recordKey{}).(*Record)
+	return r, ok
+}
+
+// Recorder writes the records of the sampled requests
+type Recorder struct {
+	rate      float64
+	endpoints map[string]bool
+	maxBody   int64
+	redact    map[string]bool
+	mu        sync.Mutex
+	out       io.Writer
+	closeFunc func() error
+}
+
+// New returns a Recorder writing the records to out
+func New(cfg Config, out io.Writer) (*Recorder, error) {
+	r := &Recorder{
+		rate:      1,
+		maxBody:   cfg.MaxBodySize,
+		redact:    map[string]bool{},
+		out:       out,
+		closeFunc: func() error { return nil },
+	}
+	if r.maxBody <= 0 {
+		r.maxBody = DefaultMaxBodySize
+	}
+	if cfg.SampleRate != nil {
+		if *cfg.SampleRate < 0 || *cfg.SampleRate > 1 {
+			return nil, fmt.Errorf("recorder: the sample rate must be between 0 and 1, got %v", *cfg.SampleRate)
+		}
+		r.rate = *cfg.SampleRate
+	}
+	if len(cfg.Endpoints) > 0 {
+		r.endpoints = map[string]bool{}
+		for _, e := range cfg.Endpoints {
+			r.endpoints[e] = true
+		}
+	}
+	for _, h := range cfg.RedactHeaders {
+		r.redact[http.CanonicalHeaderKey(h)] = true
+	}
+	return r, nil
+}
+
+// Records returns true if the requests of the endpoint can be recorded
+func (r *Recorder) Records(endpoint string) bool {
+	return r.endpoints == nil || r.endpoints[endpoint]
+}
+
+// Sampled decides if a request is recorded
+func (r *Recorder) Sampled() bool {
+	return r.rate >= 1 || (r.rate > 0 && rand.Float64() < r.rate)
+}
+
+// MaxBodySize returns the max size of the recorded bodies
+func (r *Recorder) MaxBodySize() int64 {
+	return r.maxBody
+}
+
+// NewRecord returns the record of a request received by the endpoint. The recorded part of the
+// body is read and put back, so the request can still be consumed
+func (r *Recorder) NewRecord(req *http.Request, endpoint string) *Record {
+	rec := &Record{
+		Time:     time.Now(),
+		Endpoint: endpoint,
+		Request: Message{
+			Method:  req.Method,
+			URL:     req.URL.RequestURI(),
+			Headers: req.Header.Clone(),
+		},
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return rec
+	}
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(io.LimitReader(req.Body, r.maxBody+1))
+	rec.Request.Body, rec.Request.Truncated = r.truncate(buf.Bytes())
+	req.Body = &replayedBody{Reader: io.MultiReader(bytes.NewReader(buf.Bytes()), req.Body), Closer: req.Body}
+	return rec
+}
+
+// SetResponse records the response returned to the client
+func (r *Recorder) SetResponse(rec *Record, status int, headers http.Header, body []byte) {
+	rec.Response.Status = status
+	rec.Response.Headers = headers.Clone()
+	rec.Response.Body, rec.Response.Truncated = r.truncate(body)
+	rec.Latency = time.Since(rec.Time)
+}
+
+func (r *Recorder) truncate(body []byte) ([]byte, bool) {
+	if int64(len(body)) > r.maxBody {
+		return body[:r.maxBody], true
+	}
+	return body, false
+}
+
+// Write writes the record as a JSON line, redacting its headers
+func (r *Recorder) Write(rec *Record) error {
+	rec.mu.Lock()
+	r.redactHeaders(rec.Request.Headers)
+	r.redactHeaders(rec.Response.Headers)
+	for _, b := range rec.Backends {
+		r.redactHeaders(b.Headers)
+	}
+	b, err := json.Marshal(rec)
+	rec.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.out.Write(b)
+	return err
+}
+
+func (r *Recorder) redactHeaders(h map[string][]string) {
+	for k, vs := range h {
+		if !r.redact[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		redacted := make([]string, len(vs))
+		for i := range redacted {
+			redacted[i] = Redacted
+		}
+		h[k] = redacted
+	}
+}
+
+// Close releases the output of the recorder
+func (r *Recorder) Close() error {
+	return r.closeFunc()
+}
+
+// replayedBody streams the bytes already recorded followed by the rest of the body
+type replayedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// httpSink posts the records written to it to an http(s) url
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s httpSink) Write(p []byte) (int, error) {
+	resp, err := s.client.Post(s.url, "application/x-ndjson", bytes.NewReader(p))
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("%w with the status %d", ErrSink, resp.StatusCode)
+	}
+	return len(p), nil
+}
+
+var (
+	global   *Recorder
+	globalMu sync.RWMutex
+)
+
+// Register creates the recorder declared in the service extra config and closes the previous
+// one. It returns false if the service does not declare a recorder
+func Register(cfg config.ServiceConfig) (bool, error) {
+	return RegisterWithLogger(cfg, logging.NoOp)
+}
+
+// RegisterWithLogger is like Register, but the failures of the buffered outputs are reported to
+// the logger
+func RegisterWithLogger(cfg config.ServiceConfig, logger logging.Logger) (bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok || err != nil {
+		SetGlobal(nil)
+		return ok, err
+	}
+
+	var out io.Writer
+	closeFunc := func() error { return nil }
+	switch output := strings.ToLower(c.Output); {
+	case output == "" || output == "stdout":
+		out = os.Stdout
+	case output == "stderr":
+		out = os.Stderr
+	case strings.HasPrefix(output, "http://") || strings.HasPrefix(output, "https://"):
+		out = httpSink{url: c.Output, client: &http.Client{Timeout: 10 * time.Second}}
+	default:
+		f, err := os.OpenFile(c.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			SetGlobal(nil)
+			return true, fmt.Errorf("recorder: opening the output: %w", err)
+		}
+		out, closeFunc = f, f.Close
+	}
+	if c.Buffer != nil {
+		sw, err := spool.New(out, *c.Buffer, logger)
+		if err != nil {
+			closeFunc()
+			SetGlobal(nil)
+			return true, fmt.Errorf("recorder: creating the buffer: %w", err)
+		}
+		closeOutput := closeFunc
+		out, closeFunc = sw, func() error {
+			err := sw.Close()
+			if cerr := closeOutput(); err == nil {
+				err = cerr
+			}
+			return err
+		}
+	}
+
+	r, err := New(c, out)
+	if err != nil {
+		closeFunc()
+		SetGlobal(nil)
+		return true, err
+	}
+	r.closeFunc = closeFunc
+	SetGlobal(r)
+	return true, nil
+}
+
+// SetGlobal sets the recorder used by the router and the proxies. The previous one is not closed,
+// since the handlers of another router can still be using it: the routers close the ones they
+// registered when their context is done
+func SetGlobal(r *Recorder) {
+	globalMu.Lock()
+	global = r
+	globalMu.Unlock()
+}
+
+// GetGlobal returns the recorder used by the router and the proxies, if any
+func GetGlobal() (*Recorder, bool) {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return global, global != nil
+}
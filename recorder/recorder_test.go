@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestConfigGetter(t *testing.T) {
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{})
+	if ok || err != nil || cfg.MaxBodySize != DefaultMaxBodySize {
+		t.Errorf("unexpected config: %+v %v %v", cfg, ok, err)
+	}
+	cfg, ok, err = ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"redact_headers": []interface{}{}}})
+	if !ok || err != nil || len(cfg.RedactHeaders) != 0 || cfg.MaxBodySize != DefaultMaxBodySize {
+		t.Errorf("unexpected config: %+v %v %v", cfg, ok, err)
+	}
+	if _, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"sample_rate": "all"}}); !ok || err == nil {
+		t.Errorf("expecting an error: %v %v", ok, err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	rate := 1.5
+	if _, err := New(Config{SampleRate: &rate}, io.Discard); err == nil {
+		t.Error("expecting an error")
+	}
+	rate = 0
+	r, err := New(Config{SampleRate: &rate, Endpoints: []string{"/a"}}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Sampled() {
+		t.Error("the requests should not be sampled")
+	}
+	if !r.Records("/a") || r.Records("/b") {
+		t.Error("unexpected endpoints")
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	buf := new(bytes.Buffer)
+	r, err := New(Config{MaxBodySize: 4, RedactHeaders: DefaultRedactedHeaders}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("POST", "http://gateway.local/users/42?fields=name", strings.NewReader("supu tupu"))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Tenant", "acme")
+	rec := r.NewRecord(req, "/users/{id}")
+
+	if body, _ := io.ReadAll(req.Body); string(body) != "supu tupu" {
+		t.Errorf("the request body was not restored: %s", body)
+	}
+	rec.AddBackend(Backend{URLPattern: "/v1/users/{{.Id}}", Status: 200, Data: map[string]interface{}{"id": 42.0}})
+	r.SetResponse(rec, http.StatusCreated, http.Header{"Set-Cookie": {"session=1"}}, []byte("created"))
+	if err := r.Write(rec); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		t.Error("the received request was modified")
+	}
+
+	var got Record
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unexpected record %s: %v", buf.String(), err)
+	}
+	if got.Endpoint != "/users/{id}" || got.Request.Method != "POST" || got.Request.URL != "/users/42?fields=name" {
+		t.Errorf("unexpected request: %+v", got.Request)
+	}
+	if string(got.Request.Body) != "supu" || !got.Request.Truncated {
+		t.Errorf("unexpected request body: %s", got.Request.Body)
+	}
+	if v := got.Request.Headers["Authorization"]; len(v) != 1 || v[0] != Redacted {
+		t.Errorf("the authorization header was not redacted: %v", got.Request.Headers)
+	}
+	if v := got.Request.Headers["X-Tenant"]; len(v) != 1 || v[0] != "acme" {
+		t.Errorf("unexpected headers: %v", got.Request.Headers)
+	}
+	if got.Response.Status != http.StatusCreated || string(got.Response.Body) != "crea" || got.Response.Headers["Set-Cookie"][0] != Redacted {
+		t.Errorf("unexpected response: %+v", got.Response)
+	}
+	if len(got.Backends) != 1 || got.Backends[0].Data["id"] != 42.0 {
+		t.Errorf("unexpected backends: %+v", got.Backends)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer SetGlobal(nil)
+
+	if ok, err := Register(config.ServiceConfig{}); ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	if _, ok := GetGlobal(); ok {
+		t.Error("unexpected recorder")
+	}
+
+	output := filepath.Join(t.TempDir(), "records.jsonl")
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"output": output}}}
+	if ok, err := Register(cfg); !ok || err != nil {
+		t.Fatalf("unexpected result: %v %v", ok, err)
+	}
+	r, ok := GetGlobal()
+	if !ok {
+		t.Fatal("the recorder was not registered")
+	}
+	req, _ := http.NewRequest("GET", "/a", http.NoBody)
+	if err := r.Write(r.NewRecord(req, "/a")); err != nil {
+		t.Fatal(err)
+	}
+	SetGlobal(nil)
+	if b, _ := os.ReadFile(output); !bytes.Contains(b, []byte(`"endpoint":"/a"`)) {
+		t.Errorf("unexpected output: %s", b)
+	}
+}
+
+func TestRegister_httpSink(t *testing.T) {
+	defer SetGlobal(nil)
+
+	received := make(chan []byte, 1)
+	status := int32(http.StatusNoContent)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received <- b
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer s.Close()
+
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"output": s.URL}}}
+	if ok, err := Register(cfg); !ok || err != nil {
+		t.Fatalf("unexpected result: %v %v", ok, err)
+	}
+	r, _ := GetGlobal()
+	req, _ := http.NewRequest("GET", "/a", http.NoBody)
+	if err := r.Write(r.NewRecord(req, "/a")); err != nil {
+		t.Fatal(err)
+	}
+	if b := <-received; !bytes.Contains(b, []byte(`"endpoint":"/a"`)) {
+		t.Errorf("unexpected records: %s", b)
+	}
+
+	atomic.StoreInt32(&status, http.StatusInternalServerError)
+	if err := r.Write(r.NewRecord(req, "/a")); !errors.Is(err, ErrSink) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Decoder reads the records written by a Recorder
+type Decoder struct {
+	s *bufio.Scanner
+}
+
+// NewDecoder returns a Decoder reading the JSON lines of r
+func NewDecoder(r io.Reader) *Decoder {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	return &Decoder{s: s}
+}
+
+// Next returns the next record, or io.EOF when there are no more records. The empty lines are
+// skipped
+func (d *Decoder) Next() (*Record, error) {
+	for d.s.Scan() {
+		line := bytes.TrimSpace(d.s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		rec := &Record{}
+		if err := json.Unmarshal(line, rec); err != nil {
+			return nil, fmt.Errorf("recorder: decoding the record: %w", err)
+		}
+		return rec, nil
+	}
+	if err := d.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// NewRequest returns the recorded request sent to the target, the base url of the deployment
+// replaying it. The redacted headers are not sent, and the truncated bodies are sent as recorded
+func (r *Record) NewRequest(ctx context.Context, target string) (*http.Request, error) {
+	base, err := url.Parse(strings.TrimSuffix(target, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("recorder: parsing the target: %w", err)
+	}
+	ref, err := url.Parse(r.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("recorder: parsing the recorded url: %w", err)
+	}
+	u := *base
+	u.Path = base.Path + ref.Path
+	u.RawPath = ""
+	u.RawQuery = ref.RawQuery
+
+	req, err := http.NewRequestWithContext(ctx, r.Request.Method, u.String(), bytes.NewReader(r.Request.Body))
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range r.Request.Headers {
+		if len(vs) > 0 && vs[0] == Redacted {
+			continue
+		}
+		req.Header[k] = append([]string{}, vs...)
+	}
+	req.Header.Del("Content-Length")
+	return req, nil
+}
+
+// Replay sends the records read from src to the target with the client, calling fn with every
+// record and its response or error. The responses are closed after calling fn. It stops at the
+// first record that can not be decoded or when the context is canceled
+func Replay(ctx context.Context, src io.Reader, target string, client *http.Client, fn func(*Record, *http.Response, error)) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	d := NewDecoder(src)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rec, err := d.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		req, err := rec.NewRequest(ctx, target)
+		if err != nil {
+			fn(rec, nil, err)
+			continue
+		}
+		resp, err := client.Do(req)
+		fn(rec, resp, err)
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}
+}
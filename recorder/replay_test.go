@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	var received []*http.Request
+	var bodies []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer s.Close()
+
+	buf := new(bytes.Buffer)
+	r, _ := New(Config{RedactHeaders: DefaultRedactedHeaders}, buf)
+	req, _ := http.NewRequest("POST", "http://gateway.local/users/42?fields=name", strings.NewReader("supu"))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Tenant", "acme")
+	r.Write(r.NewRecord(req, "/users/{id}"))
+	buf.WriteString("\n")
+	req, _ = http.NewRequest("GET", "http://gateway.local/health", http.NoBody)
+	r.Write(r.NewRecord(req, "/health"))
+
+	statuses := []int{}
+	err := Replay(context.Background(), buf, s.URL+"/", nil, func(rec *Record, resp *http.Response, err error) {
+		if err != nil {
+			t.Errorf("unexpected error replaying %s: %v", rec.Request.URL, err)
+			return
+		}
+		statuses = append(statuses, resp.StatusCode)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 || len(statuses) != 2 || statuses[0] != http.StatusAccepted {
+		t.Fatalf("unexpected replayed requests: %d %v", len(received), statuses)
+	}
+	first := received[0]
+	if first.Method != "POST" || first.URL.Path != "/users/42" || first.URL.RawQuery != "fields=name" || bodies[0] != "supu" {
+		t.Errorf("unexpected request: %s %s %s", first.Method, first.URL, bodies[0])
+	}
+	if first.Header.Get("Authorization") != "" || first.Header.Get("X-Tenant") != "acme" {
+		t.Errorf("unexpected headers: %v", first.Header)
+	}
+	if received[1].URL.Path != "/health" {
+		t.Errorf("unexpected request: %s", received[1].URL)
+	}
+
+	if err := Replay(context.Background(), strings.NewReader("{not json}\n"), s.URL, nil, func(*Record, *http.Response, error) {}); err == nil {
+		t.Error("expecting an error")
+	}
+}
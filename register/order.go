@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package register
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Constraint declares the position of a named element relative to the others. Before and
+// After are soft constraints (ignored when the other element is not present) while Requires
+// fails the ordering if the required element is missing. Required elements are placed before
+// the element requiring them
+type Constraint struct {
+	Name     string
+	Before   []string
+	After    []string
+	Requires []string
+}
+
+// Order returns the names of the elements sorted so every constraint is satisfied. The
+// result is deterministic: when several elements can be placed, the one declared first wins
+func Order(items []Constraint) ([]string, error) {
+	index := make(map[string]int, len(items))
+	for i, c := range items {
+		if _, ok := index[c.Name]; ok {
+			return nil, fmt.Errorf("register: duplicated element %s", c.Name)
+		}
+		index[c.Name] = i
+	}
+
+	edges := make([]map[int]struct{}, len(items))
+	for i := range edges {
+		edges[i] = map[int]struct{}{}
+	}
+	addEdge := func(from, to int) { edges[from][to] = struct{}{} }
+
+	for i, c := range items {
+		for _, r := range c.Requires {
+			j, ok := index[r]
+			if !ok {
+				return nil, fmt.Errorf("register: %s requires the missing element %s", c.Name, r)
+			}
+			addEdge(j, i)
+		}
+		for _, a := range c.After {
+			if j, ok := index[a]; ok {
+				addEdge(j, i)
+			}
+		}
+		for _, b := range c.Before {
+			if j, ok := index[b]; ok {
+				addEdge(i, j)
+			}
+		}
+	}
+
+	inDegree := make([]int, len(items))
+	for _, es := range edges {
+		for to := range es {
+			inDegree[to]++
+		}
+	}
+
+	res := make([]string, 0, len(items))
+	placed := make([]bool, len(items))
+	for len(res) < len(items) {
+		next := -1
+		for i := range items {
+			if !placed[i] && inDegree[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			pending := []string{}
+			for i, c := range items {
+				if !placed[i] {
+					pending = append(pending, c.Name)
+				}
+			}
+			return nil, fmt.Errorf("register: ordering cycle between %s", strings.Join(pending, ", "))
+		}
+		placed[next] = true
+		res = append(res, items[next].Name)
+		for to := range edges[next] {
+			inDegree[to]--
+		}
+	}
+	return res, nil
+}
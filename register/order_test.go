@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package register
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOrder(t *testing.T) {
+	res, err := Order([]Constraint{
+		{Name: "rate-limit", After: []string{"auth"}},
+		{Name: "logging"},
+		{Name: "auth", Before: []string{"cache"}},
+		{Name: "cache", Requires: []string{"logging"}, After: []string{"unknown"}},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if s := strings.Join(res, ","); s != "logging,auth,rate-limit,cache" {
+		t.Errorf("unexpected order: %s", s)
+	}
+}
+
+func TestOrder_ko(t *testing.T) {
+	for name, items := range map[string][]Constraint{
+		"cycle": {
+			{Name: "a", Before: []string{"b"}},
+			{Name: "b", Before: []string{"a"}},
+		},
+		"missing": {
+			{Name: "a", Requires: []string{"b"}},
+		},
+		"duplicated": {
+			{Name: "a"},
+			{Name: "a"},
+		},
+	} {
+		if _, err := Order(items); err == nil {
+			t.Errorf("%s: expecting an error", name)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package requestid identifies every request received by the router, so a client call can be
+correlated with the backend calls it triggers and with their log lines.
+
+The request ids are declared in the service extra config:
+
+	"extra_config": {
+		"github.com/luraproject/lura/requestid": {
+			"header": "X-Request-Id",
+			"trust_incoming": true
+		}
+	}
+
+The router honors the id received in the header, unless the incoming ids are not trusted or the
+received one is not valid, and generates a random UUID otherwise. The id is stored in the request
+context, added to the response headers and sent to every backend in the same header. The access
+log records it and the server spans carry it as an attribute. The components logging in the
+scope of a request can add it to their messages with Logger.
+*/
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+// Namespace is the key to use to store and access the request id config
+const Namespace = "github.com/luraproject/lura/requestid"
+
+// DefaultHeader is the name of the request id header when the config does not declare one
+const DefaultHeader = "X-Request-Id"
+
+// ContextKey is the string key of the request id in the contexts that only support string keys,
+// like the gin ones
+const ContextKey = "github.com/luraproject/lura/requestid.id"
+
+// MaxLength is the max length of the incoming ids accepted
+const MaxLength = 128
+
+// Config is the request id config of the service
+type Config struct {
+	Header        string `json:"header"`
+	TrustIncoming *bool  `json:"trust_incoming"`
+}
+
+// ConfigGetter parses the request id config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("requestid: parsing the config: %w", err)
+	}
+	return cfg, true, nil
+}
+
+// Generator assigns the ids of the requests
+type Generator struct {
+	header        string
+	trustIncoming bool
+}
+
+// New returns a Generator with the config
+func New(cfg Config) *Generator {
+	g := &Generator{header: cfg.Header, trustIncoming: true}
+	if g.header == "" {
+		g.header = DefaultHeader
+	}
+	if cfg.TrustIncoming != nil {
+		g.trustIncoming = *cfg.TrustIncoming
+	}
+	return g
+}
+
+// Header returns the name of the request id header
+func (g *Generator) Header() string { return g.header }
+
+// ID returns the id of a request: the received one, if trusted and valid, or a new one
+func (g *Generator) ID(received string) string {
+	if g.trustIncoming && Valid(received) {
+		return received
+	}
+	return NewID()
+}
+
+// NewID returns a random (version 4) UUID
+func NewID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf[:])
+}
+
+// Valid returns true if the id is not empty, not longer than MaxLength and only contains
+// visible ASCII characters, so it can be safely written in headers and log lines
+func Valid(id string) bool {
+	if id == "" || len(id) > MaxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+type idKey struct{}
+
+// NewContext returns a copy of the context carrying the request id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// FromContext returns the request id stored in the context, if any
+func FromContext(ctx context.Context) (string, bool) {
+	if id, ok := ctx.Value(idKey{}).(string); ok {
+		return id, true
+	}
+	id, ok := ctx.Value(ContextKey).(string)
+	return id, ok
+}
+
+// Logger returns a logger adding the request id of the context to the messages: as the
+// "request_id" key for the structured loggers and as a prefix for the rest. It returns the logger
+// received when the context does not carry a request id
+func Logger(ctx context.Context, l logging.Logger) logging.Logger {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return l
+	}
+	if s, ok := l.(logging.StructuredLogger); ok {
+		return s.With("request_id", id)
+	}
+	return prefixedLogger{Logger: l, prefix: "[REQUEST: " + id + "]"}
+}
+
+type prefixedLogger struct {
+	logging.Logger
+	prefix string
+}
+
+func (l prefixedLogger) with(v []interface{}) []interface{} {
+	return append([]interface{}{l.prefix}, v...)
+}
+
+func (l prefixedLogger) Debug(v ...interface{})    { l.Logger.Debug(l.with(v)...) }
+func (l prefixedLogger) Info(v ...interface{})     { l.Logger.Info(l.with(v)...) }
+func (l prefixedLogger) Warning(v ...interface{})  { l.Logger.Warning(l.with(v)...) }
+func (l prefixedLogger) Error(v ...interface{})    { l.Logger.Error(l.with(v)...) }
+func (l prefixedLogger) Critical(v ...interface{}) { l.Logger.Critical(l.with(v)...) }
+func (l prefixedLogger) Fatal(v ...interface{})    { l.Logger.Fatal(l.with(v)...) }
+
+var (
+	global   *Generator
+	globalMu sync.RWMutex
+)
+
+// Register creates the generator declared in the service extra config. It returns false if the
+// service does not declare the request ids
+func Register(cfg config.ServiceConfig) (bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok || err != nil {
+		SetGlobal(nil)
+		return ok, err
+	}
+	SetGlobal(New(c))
+	return true, nil
+}
+
+// SetGlobal sets the generator used by the router and the http clients
+func SetGlobal(g *Generator) {
+	globalMu.Lock()
+	global = g
+	globalMu.Unlock()
+}
+
+// GetGlobal returns the generator used by the router and the http clients, if any
+func GetGlobal() (*Generator, bool) {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return global, global != nil
+}
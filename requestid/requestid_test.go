@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package requestid
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewID(t *testing.T) {
+	a, b := NewID(), NewID()
+	if !uuidPattern.MatchString(a) {
+		t.Errorf("unexpected id %s", a)
+	}
+	if a == b {
+		t.Error("the ids must be unique")
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, tc := range []struct {
+		id    string
+		valid bool
+	}{
+		{"abc-123", true},
+		{"", false},
+		{"with space", false},
+		{"new\nline", false},
+		{strings.Repeat("a", MaxLength), true},
+		{strings.Repeat("a", MaxLength+1), false},
+	} {
+		if v := Valid(tc.id); v != tc.valid {
+			t.Errorf("%q: unexpected result %v", tc.id, v)
+		}
+	}
+}
+
+func TestGenerator_ID(t *testing.T) {
+	g := New(Config{})
+	if g.Header() != DefaultHeader {
+		t.Errorf("unexpected header %s", g.Header())
+	}
+	if id := g.ID("abc-123"); id != "abc-123" {
+		t.Errorf("the received id must be honored, got %s", id)
+	}
+	if id := g.ID("bad id"); !uuidPattern.MatchString(id) {
+		t.Errorf("an invalid id must be replaced, got %s", id)
+	}
+
+	untrusted := false
+	g = New(Config{Header: "X-Correlation-Id", TrustIncoming: &untrusted})
+	if g.Header() != "X-Correlation-Id" {
+		t.Errorf("unexpected header %s", g.Header())
+	}
+	if id := g.ID("abc-123"); id == "abc-123" {
+		t.Error("the received id must be ignored")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer SetGlobal(nil)
+
+	if ok, err := Register(config.ServiceConfig{}); ok || err != nil {
+		t.Errorf("unexpected result %v %v", ok, err)
+	}
+	if _, ok := GetGlobal(); ok {
+		t.Error("no generator expected")
+	}
+
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{"header": "X-Trace", "trust_incoming": false},
+	}}
+	if ok, err := Register(cfg); !ok || err != nil {
+		t.Fatalf("unexpected result %v %v", ok, err)
+	}
+	g, ok := GetGlobal()
+	if !ok {
+		t.Fatal("generator expected")
+	}
+	if g.Header() != "X-Trace" || g.trustIncoming {
+		t.Errorf("unexpected generator %+v", g)
+	}
+
+	cfg.ExtraConfig[Namespace] = map[string]interface{}{"header": 42}
+	if ok, err := Register(cfg); !ok || err == nil {
+		t.Errorf("unexpected result %v %v", ok, err)
+	}
+	if _, ok := GetGlobal(); ok {
+		t.Error("no generator expected after a bad config")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("no id expected")
+	}
+	if id, ok := FromContext(NewContext(context.Background(), "abc")); !ok || id != "abc" {
+		t.Errorf("unexpected id %s", id)
+	}
+	ctx := context.WithValue(context.Background(), ContextKey, "def")
+	if id, ok := FromContext(ctx); !ok || id != "def" {
+		t.Errorf("unexpected id %s", id)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l, _ := logging.NewLogger("DEBUG", buf, "")
+	if Logger(context.Background(), l) != l {
+		t.Error("the logger must be returned as is without a request id")
+	}
+	Logger(NewContext(context.Background(), "abc"), l).Info("hello")
+	if !strings.Contains(buf.String(), "[REQUEST: abc] hello") {
+		t.Errorf("unexpected log %s", buf.String())
+	}
+
+	buf.Reset()
+	sl, err := logging.NewJSONLogger("DEBUG", buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Logger(NewContext(context.Background(), "abc"), sl).Info("hello")
+	if !strings.Contains(buf.String(), `"request_id":"abc"`) {
+		t.Errorf("unexpected log %s", buf.String())
+	}
+}
@@ -0,0 +1,411 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package accesslog writes a line for every request served by the endpoints of the router.
+
+The access log is declared in the service extra config:
+
+	"extra_config": {
+		"github.com/luraproject/lura/router/accesslog": {
+			"format": "json",
+			"fields": ["latency", "backends", "client_id", "trace_id", "request_id"],
+			"sample_rate": 0.1,
+			"output": "/var/log/gateway/access.log"
+		}
+	}
+
+The supported formats are "json", "combined", the Apache combined log format, and "template", a
+text/template receiving the Entry of the request. The fields select the optional values added to
+the JSON lines: the latency, the number of backends of the endpoint, the id of the client
+authenticated with an API key, the id of the trace and the id of the request. The combined format uses the client id as
+its user. The sample rate keeps the logging cost bounded under load: the requests not sampled are
+not recorded at all. The lines are written to the stdout by default, to the stderr or to a file.
+
+The buffer decouples the requests from a slow output: the lines are buffered, spilled to disk when
+the memory buffer is full and written by a background worker (see the spool package):
+
+	"buffer": {
+		"max_memory": 4194304,
+		"spill_dir": "/var/spool/gateway/access"
+	}
+*/
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/funcmap"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/requestid"
+	"github.com/luraproject/lura/v2/router/forwarded"
+	"github.com/luraproject/lura/v2/spool"
+)
+
+// Namespace is the key to use to store and access the access log config
+const Namespace = "github.com/luraproject/lura/router/accesslog"
+
+const (
+	// JSONFormat writes every entry as a JSON object
+	JSONFormat = "json"
+	// CombinedFormat writes every entry with the Apache combined log format
+	CombinedFormat = "combined"
+	// TemplateFormat writes every entry with the template of the config
+	TemplateFormat = "template"
+)
+
+// The optional fields of the JSON entries
+const (
+	FieldLatency   = "latency"
+	FieldBackends  = "backends"
+	FieldClientID  = "client_id"
+	FieldTraceID   = "trace_id"
+	FieldRequestID = "request_id"
+)
+
+// DefaultFields are the optional fields added when the config does not declare them
+var DefaultFields = []string{FieldLatency, FieldBackends, FieldClientID, FieldTraceID, FieldRequestID}
+
+// ErrUnknownFormat is returned when the config declares an unsupported format
+var ErrUnknownFormat = errors.New("accesslog: unknown format")
+
+// Config is the access log config of the service
+type Config struct {
+	Format     string        `json:"format"`
+	Template   string        `json:"template"`
+	Fields     []string      `json:"fields"`
+	SampleRate *float64      `json:"sample_rate"`
+	Output     string        `json:"output"`
+	Buffer     *spool.Config `json:"buffer"`
+}
+
+// ConfigGetter parses the access log config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{Format: JSONFormat, Fields: DefaultFields}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("accesslog: parsing the config: %w", err)
+	}
+	if cfg.Format == "" {
+		cfg.Format = JSONFormat
+	}
+	return cfg, true, nil
+}
+
+// Entry describes a request served by an endpoint
+type Entry struct {
+	Time       time.Time
+	RemoteAddr string
+	Method     string
+	URI        string
+	Proto      string
+	Status     int
+	Bytes      int64
+	Referer    string
+	UserAgent  string
+	Endpoint   string
+	Latency    time.Duration
+	Backends   int
+	ClientID   string
+	TraceID    string
+	RequestID  string
+}
+
+// NewEntry returns the entry of a request received by the endpoint
+func NewEntry(r *http.Request, cfg *config.EndpointConfig) *Entry {
+	return &Entry{
+		Time:       time.Now(),
+		RemoteAddr: clientIP(r),
+		Method:     r.Method,
+		URI:        r.URL.RequestURI(),
+		Proto:      r.Proto,
+		Status:     http.StatusOK,
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+		Endpoint:   cfg.Endpoint,
+		Backends:   len(cfg.Backend),
+		RequestID:  requestID(r),
+	}
+}
+
+func requestID(r *http.Request) string {
+	id, _ := requestid.FromContext(r.Context())
+	return id
+}
+
+func clientIP(r *http.Request) string {
+	if ip := forwarded.ClientIP(r); ip != nil {
+		return ip.String()
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+type entryKey struct{}
+
+// NewContext returns a copy of the context carrying the entry, so the handlers running after the
+// access log can complete it
+func NewContext(ctx context.Context, e *Entry) context.Context {
+	return context.WithValue(ctx, entryKey{}, e)
+}
+
+// FromContext returns the entry stored in the context, if any
+func FromContext(ctx context.Context) (*Entry, bool) {
+	e, ok := ctx.Value(entryKey{}).(*Entry)
+	return e, ok
+}
+
+// SetClientID records the id of the authenticated client in the entry of the context, if any
+func SetClientID(ctx context.Context, id string) {
+	if e, ok := FromContext(ctx); ok {
+		e.ClientID = id
+	}
+}
+
+// SetTraceID records the id of the trace in the entry of the context, if any
+func SetTraceID(ctx context.Context, id string) {
+	if e, ok := FromContext(ctx); ok {
+		e.TraceID = id
+	}
+}
+
+// Logger writes the entries with the format of its config
+type Logger struct {
+	format    string
+	tmpl      *template.Template
+	fields    map[string]bool
+	rate      float64
+	mu        sync.Mutex
+	out       io.Writer
+	closeFunc func() error
+}
+
+// New returns a Logger writing the entries to out
+func New(cfg Config, out io.Writer) (*Logger, error) {
+	l := &Logger{
+		format:    cfg.Format,
+		fields:    map[string]bool{},
+		rate:      1,
+		out:       out,
+		closeFunc: func() error { return nil },
+	}
+	switch cfg.Format {
+	case JSONFormat, CombinedFormat:
+	case TemplateFormat:
+		tmpl, err := funcmap.New("accesslog").Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("accesslog: parsing the template: %w", err)
+		}
+		l.tmpl = tmpl
+	default:
+		return nil, fmt.Errorf("%w %s", ErrUnknownFormat, cfg.Format)
+	}
+	for _, f := range cfg.Fields {
+		switch f {
+		case FieldLatency, FieldBackends, FieldClientID, FieldTraceID, FieldRequestID:
+			l.fields[f] = true
+		default:
+			return nil, fmt.Errorf("accesslog: unknown field %s", f)
+		}
+	}
+	if cfg.SampleRate != nil {
+		if *cfg.SampleRate < 0 || *cfg.SampleRate > 1 {
+			return nil, fmt.Errorf("accesslog: the sample rate must be between 0 and 1, got %v", *cfg.SampleRate)
+		}
+		l.rate = *cfg.SampleRate
+	}
+	return l, nil
+}
+
+// Sampled decides if a request is recorded
+func (l *Logger) Sampled() bool {
+	return l.rate >= 1 || (l.rate > 0 && rand.Float64() < l.rate)
+}
+
+// Log writes the entry
+func (l *Logger) Log(e *Entry) error {
+	buf := new(bytes.Buffer)
+	switch l.format {
+	case JSONFormat:
+		l.writeJSON(buf, e)
+	case CombinedFormat:
+		l.writeCombined(buf, e)
+	case TemplateFormat:
+		if err := l.tmpl.Execute(buf, e); err != nil {
+			return err
+		}
+		if b := buf.Bytes(); len(b) == 0 || b[len(b)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := l.out.Write(buf.Bytes())
+	return err
+}
+
+// Close releases the output of the logger, if it is a file
+func (l *Logger) Close() error {
+	return l.closeFunc()
+}
+
+func (l *Logger) writeJSON(buf *bytes.Buffer, e *Entry) {
+	m := map[string]interface{}{
+		"time":        e.Time.Format(time.RFC3339),
+		"remote_addr": e.RemoteAddr,
+		"method":      e.Method,
+		"uri":         e.URI,
+		"proto":       e.Proto,
+		"status":      e.Status,
+		"bytes":       e.Bytes,
+		"referer":     e.Referer,
+		"user_agent":  e.UserAgent,
+		"endpoint":    e.Endpoint,
+	}
+	if l.fields[FieldLatency] {
+		m[FieldLatency] = e.Latency.Seconds()
+	}
+	if l.fields[FieldBackends] {
+		m[FieldBackends] = e.Backends
+	}
+	if l.fields[FieldClientID] && e.ClientID != "" {
+		m[FieldClientID] = e.ClientID
+	}
+	if l.fields[FieldTraceID] && e.TraceID != "" {
+		m[FieldTraceID] = e.TraceID
+	}
+	if l.fields[FieldRequestID] && e.RequestID != "" {
+		m[FieldRequestID] = e.RequestID
+	}
+	json.NewEncoder(buf).Encode(m)
+}
+
+func (l *Logger) writeCombined(buf *bytes.Buffer, e *Entry) {
+	user := "-"
+	if e.ClientID != "" {
+		user = e.ClientID
+	}
+	size := "-"
+	if e.Bytes > 0 {
+		size = strconv.FormatInt(e.Bytes, 10)
+	}
+	fmt.Fprintf(buf, "%s - %s [%s] %q %d %s %q %q\n",
+		e.RemoteAddr,
+		user,
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method+" "+e.URI+" "+e.Proto,
+		e.Status,
+		size,
+		orDash(e.Referer),
+		orDash(e.UserAgent),
+	)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+var (
+	global   *Logger
+	globalMu sync.RWMutex
+)
+
+// Register creates the access log declared in the service extra config and closes the previous
+// one. It returns false if the service does not declare an access log
+func Register(cfg config.ServiceConfig) (bool, error) {
+	return RegisterWithLogger(cfg, logging.NoOp)
+}
+
+// RegisterWithLogger is like Register, but the failures of the buffered outputs are reported to
+// the logger
+func RegisterWithLogger(cfg config.ServiceConfig, logger logging.Logger) (bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok || err != nil {
+		SetGlobal(nil)
+		return ok, err
+	}
+
+	var out io.Writer
+	closeFunc := func() error { return nil }
+	switch strings.ToLower(c.Output) {
+	case "", "stdout":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	default:
+		f, err := os.OpenFile(c.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			SetGlobal(nil)
+			return true, fmt.Errorf("accesslog: opening the output: %w", err)
+		}
+		out, closeFunc = f, f.Close
+	}
+	if c.Buffer != nil {
+		sw, err := spool.New(out, *c.Buffer, logger)
+		if err != nil {
+			closeFunc()
+			SetGlobal(nil)
+			return true, fmt.Errorf("accesslog: creating the buffer: %w", err)
+		}
+		closeOutput := closeFunc
+		out, closeFunc = sw, func() error {
+			err := sw.Close()
+			if cerr := closeOutput(); err == nil {
+				err = cerr
+			}
+			return err
+		}
+	}
+
+	l, err := New(c, out)
+	if err != nil {
+		closeFunc()
+		SetGlobal(nil)
+		return true, err
+	}
+	l.closeFunc = closeFunc
+	SetGlobal(l)
+	return true, nil
+}
+
+// SetGlobal sets the access log used by the router. The previous one is not closed, since the
+// handlers of another router can still be using it: the routers close the ones they registered
+// when their context is done
+func SetGlobal(l *Logger) {
+	globalMu.Lock()
+	global = l
+	globalMu.Unlock()
+}
+
+// GetGlobal returns the access log used by the router, if any
+func GetGlobal() (*Logger, bool) {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return global, global != nil
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func testEntry() *Entry {
+	r := httptest.NewRequest("GET", "/foo?bar=1", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("User-Agent", "test-agent")
+	e := NewEntry(r, &config.EndpointConfig{Endpoint: "/foo", Backend: []*config.Backend{{}, {}}})
+	e.Time = time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	e.Status = 201
+	e.Bytes = 42
+	e.Latency = 1500 * time.Millisecond
+
+	ctx := NewContext(context.Background(), e)
+	SetClientID(ctx, "client-1")
+	SetTraceID(ctx, "4bf92f3577b34da6a3ce929d0e0e4736")
+	return e
+}
+
+func TestLogger_json(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l, err := New(Config{Format: JSONFormat, Fields: []string{FieldLatency, FieldClientID}}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Log(testEntry()); err != nil {
+		t.Fatal(err)
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]interface{}{
+		"remote_addr": "10.0.0.1",
+		"method":      "GET",
+		"uri":         "/foo?bar=1",
+		"status":      201.0,
+		"bytes":       42.0,
+		"endpoint":    "/foo",
+		"user_agent":  "test-agent",
+		"latency":     1.5,
+		"client_id":   "client-1",
+	} {
+		if m[k] != v {
+			t.Errorf("unexpected value for %s: %v", k, m[k])
+		}
+	}
+	for _, k := range []string{"backends", "trace_id"} {
+		if _, ok := m[k]; ok {
+			t.Errorf("the field %s was not selected", k)
+		}
+	}
+}
+
+func TestLogger_combined(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l, err := New(Config{Format: CombinedFormat}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Log(testEntry())
+	expected := `10.0.0.1 - client-1 [04/Mar/2021:05:06:07 +0000] "GET /foo?bar=1 HTTP/1.1" 201 42 "-" "test-agent"` + "\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected line: %s", buf.String())
+	}
+}
+
+func TestLogger_template(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l, err := New(Config{Format: TemplateFormat, Template: "{{.Method}} {{.Endpoint}} {{.Status}} {{.Backends}} {{.TraceID}}"}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Log(testEntry())
+	if buf.String() != "GET /foo 201 2 4bf92f3577b34da6a3ce929d0e0e4736\n" {
+		t.Errorf("unexpected line: %s", buf.String())
+	}
+}
+
+func TestNew_errors(t *testing.T) {
+	rate := 2.0
+	for _, cfg := range []Config{
+		{Format: "xml"},
+		{Format: TemplateFormat, Template: "{{.Method"},
+		{Format: JSONFormat, Fields: []string{"cookies"}},
+		{Format: JSONFormat, SampleRate: &rate},
+	} {
+		if _, err := New(cfg, new(bytes.Buffer)); err == nil {
+			t.Errorf("expecting an error for %+v", cfg)
+		}
+	}
+	if _, err := New(Config{Format: "xml"}, new(bytes.Buffer)); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLogger_Sampled(t *testing.T) {
+	rate := 0.0
+	l, _ := New(Config{Format: JSONFormat, SampleRate: &rate}, new(bytes.Buffer))
+	for i := 0; i < 100; i++ {
+		if l.Sampled() {
+			t.Fatal("no request should be sampled")
+		}
+	}
+	l, _ = New(Config{Format: JSONFormat}, new(bytes.Buffer))
+	if !l.Sampled() {
+		t.Error("all the requests should be sampled")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer SetGlobal(nil)
+	output := filepath.Join(t.TempDir(), "access.log")
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"format": "combined",
+		"output": output,
+	}}}
+	if ok, err := Register(cfg); !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	l, ok := GetGlobal()
+	if !ok {
+		t.Fatal("the access log was not registered")
+	}
+	l.Log(testEntry())
+
+	b, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "10.0.0.1 - client-1 ") {
+		t.Errorf("unexpected content: %s", string(b))
+	}
+
+	if ok, _ := Register(config.ServiceConfig{}); ok {
+		t.Error("the service does not declare an access log")
+	}
+	if _, ok := GetGlobal(); ok {
+		t.Error("the access log of the previous config was not dropped")
+	}
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// AliasEndpoint returns a copy of the endpoint config exposed at the path of the received alias.
+// The copy shares the backends and the extra config with the original endpoint
+func AliasEndpoint(e *config.EndpointConfig, alias config.EndpointAlias) *config.EndpointConfig {
+	aliased := *e
+	aliased.Endpoint = alias.Path
+	aliased.Aliases = nil
+	return &aliased
+}
+
+// SetDeprecationHeaders adds the Deprecation, Sunset and Link headers for the deprecated aliases.
+// The Link header, pointing to the successor endpoint, is only added when the endpoint has no params
+func SetDeprecationHeaders(h http.Header, e *config.EndpointConfig, alias config.EndpointAlias) {
+	if !alias.Deprecated {
+		return
+	}
+	h.Set("Deprecation", "true")
+	if alias.Sunset != "" {
+		h.Set("Sunset", alias.Sunset)
+	}
+	if !strings.ContainsAny(e.Endpoint, ":{*") {
+		h.Set("Link", "<"+e.Endpoint+`>; rel="successor-version"`)
+	}
+}
+
+// NewAliasHandler decorates the handler of the endpoint, so the responses served through a
+// deprecated alias include the deprecation headers
+func NewAliasHandler(handler http.HandlerFunc, e *config.EndpointConfig, alias config.EndpointAlias) http.HandlerFunc {
+	if !alias.Deprecated {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		SetDeprecationHeaders(w.Header(), e, alias)
+		handler(w, r)
+	}
+}
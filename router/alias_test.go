@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestNewAliasHandler(t *testing.T) {
+	e := &config.EndpointConfig{Endpoint: "/v2/users"}
+	h := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	w := httptest.NewRecorder()
+	NewAliasHandler(h, e, config.EndpointAlias{Path: "/users"})(w, httptest.NewRequest("GET", "/users", http.NoBody))
+	if v := w.Header().Get("Deprecation"); v != "" {
+		t.Errorf("unexpected deprecation header: %s", v)
+	}
+
+	alias := config.EndpointAlias{Path: "/users", Deprecated: true, Sunset: "Wed, 11 Nov 2026 23:59:59 GMT"}
+	w = httptest.NewRecorder()
+	NewAliasHandler(h, e, alias)(w, httptest.NewRequest("GET", "/users", http.NoBody))
+	if v := w.Header().Get("Deprecation"); v != "true" {
+		t.Errorf("unexpected deprecation header: %s", v)
+	}
+	if v := w.Header().Get("Sunset"); v != alias.Sunset {
+		t.Errorf("unexpected sunset header: %s", v)
+	}
+	if v := w.Header().Get("Link"); v != `</v2/users>; rel="successor-version"` {
+		t.Errorf("unexpected link header: %s", v)
+	}
+
+	w = httptest.NewRecorder()
+	NewAliasHandler(h, &config.EndpointConfig{Endpoint: "/v2/users/:id"}, alias)(w, httptest.NewRequest("GET", "/users/1", http.NoBody))
+	if v := w.Header().Get("Link"); v != "" {
+		t.Errorf("unexpected link header: %s", v)
+	}
+}
+
+func TestAliasEndpoint(t *testing.T) {
+	e := &config.EndpointConfig{
+		Endpoint: "/v2/users",
+		Aliases:  []config.EndpointAlias{{Path: "/users"}},
+		Backend:  []*config.Backend{{}},
+	}
+	aliased := AliasEndpoint(e, e.Aliases[0])
+	if aliased.Endpoint != "/users" || len(aliased.Aliases) != 0 || len(aliased.Backend) != 1 {
+		t.Errorf("unexpected aliased endpoint: %+v", aliased)
+	}
+	if e.Endpoint != "/v2/users" {
+		t.Errorf("the original endpoint has been modified: %s", e.Endpoint)
+	}
+}
@@ -0,0 +1,241 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package apikey authenticates the requests with API keys and attaches the identity of the client to
+the request context, so the layers keying on the client (rate limiting, logging...) can read it
+with FromContext.
+
+The keys are resolved by a Store. The service declares its store in the extra config and the
+endpoints requiring a key declare where to read it and the roles the clients must have:
+
+	"extra_config": {
+		"github.com/luraproject/lura/router/apikey": {
+			"store": "static",
+			"keys": [
+				{"key": "4d2c61e1", "client_id": "mobile-app", "roles": ["user"]}
+			]
+		}
+	}
+
+The package registers the "static", "file" and "http" stores. Other stores can be added with
+RegisterStore.
+*/
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the apikey config
+const Namespace = "github.com/luraproject/lura/router/apikey"
+
+// DefaultHeader is the header containing the key when the endpoint does not declare one
+const DefaultHeader = "X-Api-Key"
+
+// ContextKey is the string key of the identity in the contexts that only support string keys,
+// like the gin ones
+const ContextKey = "github.com/luraproject/lura/router/apikey.identity"
+
+var (
+	// ErrNoKey is returned when the request does not contain a key
+	ErrNoKey = errors.New("apikey: no key found")
+	// ErrUnknownKey is returned when the store does not contain the key
+	ErrUnknownKey = errors.New("apikey: unknown key")
+	// ErrForbidden is returned when the client does not have the roles required by the endpoint
+	ErrForbidden = errors.New("apikey: the client does not have the required roles")
+	// ErrNoStore is returned when the service does not declare a key store
+	ErrNoStore = errors.New("apikey: no key store registered")
+)
+
+// Identity describes the client owning a key
+type Identity struct {
+	ClientID string            `json:"client_id"`
+	Roles    []string          `json:"roles"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// HasRoles returns true if the identity has all the received roles
+func (i Identity) HasRoles(roles []string) bool {
+	for _, want := range roles {
+		found := false
+		for _, r := range i.Roles {
+			if r == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+type identityKey struct{}
+
+// NewContext returns a copy of the context carrying the identity
+func NewContext(ctx context.Context, i Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, i)
+}
+
+// FromContext returns the identity stored in the context, if any
+func FromContext(ctx context.Context) (Identity, bool) {
+	if i, ok := ctx.Value(identityKey{}).(Identity); ok {
+		return i, true
+	}
+	i, ok := ctx.Value(ContextKey).(Identity)
+	return i, ok
+}
+
+// EndpointConfig defines how an endpoint authenticates its requests
+type EndpointConfig struct {
+	Header      string
+	QueryString string
+	Roles       []string
+	// ClientIDHeader is the name of the request header receiving the client id. It reaches the
+	// backends when it is declared in the input headers of the endpoint
+	ClientIDHeader string
+}
+
+// EndpointConfigGetter parses the apikey config from the extra config of an endpoint
+func EndpointConfigGetter(e config.ExtraConfig) (EndpointConfig, bool) {
+	cfg := EndpointConfig{Header: DefaultHeader}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false
+	}
+	if h, ok := tmp["header"].(string); ok && h != "" {
+		cfg.Header = h
+	}
+	cfg.QueryString, _ = tmp["query_string"].(string)
+	cfg.ClientIDHeader, _ = tmp["client_id_header"].(string)
+	cfg.Roles = stringList(tmp["roles"])
+	return cfg, true
+}
+
+func stringList(v interface{}) []string {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	res := make([]string, 0, len(vs))
+	for _, e := range vs {
+		if s, ok := e.(string); ok {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+var (
+	store   Store
+	storeMu sync.RWMutex
+)
+
+// Register creates the store declared in the service extra config and makes it the one used by
+// the endpoints. It returns false if the service does not declare a store
+func Register(cfg config.ServiceConfig) (bool, error) {
+	tmp, ok := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		// drop the store of a previous config
+		SetStore(nil)
+		return false, nil
+	}
+	name, _ := tmp["store"].(string)
+	if name == "" {
+		name = StaticStore
+	}
+	sf, ok := GetStoreFactory(name)
+	if !ok {
+		SetStore(nil)
+		return true, fmt.Errorf("apikey: unknown store %s", name)
+	}
+	s, err := sf(tmp)
+	if err != nil {
+		SetStore(nil)
+		return true, err
+	}
+	SetStore(s)
+	return true, nil
+}
+
+// SetStore sets the store used by the endpoints
+func SetStore(s Store) {
+	storeMu.Lock()
+	store = s
+	storeMu.Unlock()
+}
+
+// GetStore returns the store used by the endpoints
+func GetStore() (Store, bool) {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	return store, store != nil
+}
+
+// Authenticator validates the keys of the requests to an endpoint
+type Authenticator struct {
+	cfg   EndpointConfig
+	store Store
+}
+
+// NewAuthenticator returns an Authenticator for the endpoint config, looking up the keys in the
+// store registered when it is created
+func NewAuthenticator(cfg EndpointConfig) *Authenticator {
+	s, _ := GetStore()
+	return &Authenticator{cfg: cfg, store: s}
+}
+
+// Config returns the endpoint config of the authenticator
+func (a *Authenticator) Config() EndpointConfig {
+	return a.cfg
+}
+
+// Authenticate returns the identity owning the key of the request, if it has the roles
+// required by the endpoint
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, error) {
+	key := r.Header.Get(a.cfg.Header)
+	if key == "" && a.cfg.QueryString != "" {
+		key = r.URL.Query().Get(a.cfg.QueryString)
+	}
+	if key == "" {
+		return Identity{}, ErrNoKey
+	}
+	if a.store == nil {
+		return Identity{}, ErrNoStore
+	}
+	i, err := a.store.Lookup(r.Context(), key)
+	if err != nil {
+		return Identity{}, err
+	}
+	if !i.HasRoles(a.cfg.Roles) {
+		return i, ErrForbidden
+	}
+	return i, nil
+}
+
+// SetClientIDHeader replaces the client id header, if the endpoint declares it, so the clients can
+// not inject it
+func (a *Authenticator) SetClientIDHeader(h http.Header, i Identity) {
+	if a.cfg.ClientIDHeader != "" {
+		h.Set(a.cfg.ClientIDHeader, i.ClientID)
+	}
+}
+
+// StatusCode returns the status code of the response to a request rejected with the error
+func StatusCode(err error) int {
+	switch err {
+	case ErrNoKey, ErrUnknownKey:
+		return http.StatusUnauthorized
+	case ErrForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
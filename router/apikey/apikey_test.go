@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package apikey
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestRegister(t *testing.T) {
+	defer SetStore(nil)
+
+	if ok, _ := Register(config.ServiceConfig{}); ok {
+		t.Error("the service does not declare a store")
+	}
+	if ok, err := Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{"store": "unknown"},
+	}}); !ok || err == nil {
+		t.Errorf("expecting an error for the unknown store. ok: %v, err: %v", ok, err)
+	}
+	ok, err := Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"keys": []interface{}{
+				map[string]interface{}{"key": "k1", "client_id": "a", "roles": []interface{}{"admin", "user"}},
+				map[string]interface{}{"key": "k2", "client_id": "b", "roles": []interface{}{"user"}},
+			},
+		},
+	}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+
+	cfg, ok := EndpointConfigGetter(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"query_string":     "key",
+			"roles":            []interface{}{"admin"},
+			"client_id_header": "X-Client-Id",
+		},
+	})
+	if !ok || cfg.Header != DefaultHeader {
+		t.Fatalf("unexpected endpoint config: %+v", cfg)
+	}
+	auth := NewAuthenticator(cfg)
+
+	for _, tc := range []struct {
+		header, query string
+		client        string
+		err           error
+	}{
+		{err: ErrNoKey},
+		{header: "unknown", err: ErrUnknownKey},
+		{header: "k1", client: "a"},
+		{query: "k1", client: "a"},
+		{header: "k2", client: "b", err: ErrForbidden},
+	} {
+		r, _ := http.NewRequest("GET", "/?key="+tc.query, http.NoBody)
+		if tc.header != "" {
+			r.Header.Set(DefaultHeader, tc.header)
+		}
+		i, err := auth.Authenticate(r)
+		if err != tc.err {
+			t.Errorf("%+v: unexpected error: %v", tc, err)
+			continue
+		}
+		if i.ClientID != tc.client {
+			t.Errorf("%+v: unexpected client: %s", tc, i.ClientID)
+		}
+	}
+
+	h := http.Header{"X-Client-Id": {"spoofed"}}
+	auth.SetClientIDHeader(h, Identity{ClientID: "a"})
+	if h.Get("X-Client-Id") != "a" {
+		t.Errorf("unexpected client id header: %s", h.Get("X-Client-Id"))
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("the context does not contain an identity")
+	}
+	i, ok := FromContext(NewContext(context.Background(), Identity{ClientID: "a"}))
+	if !ok || i.ClientID != "a" {
+		t.Errorf("unexpected identity: %+v", i)
+	}
+	// emulating the contexts supporting only string keys
+	i, ok = FromContext(context.WithValue(context.Background(), ContextKey, Identity{ClientID: "b"}))
+	if !ok || i.ClientID != "b" {
+		t.Errorf("unexpected identity: %+v", i)
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	for err, want := range map[error]int{
+		ErrNoKey:      http.StatusUnauthorized,
+		ErrUnknownKey: http.StatusUnauthorized,
+		ErrForbidden:  http.StatusForbidden,
+		ErrNoStore:    http.StatusInternalServerError,
+	} {
+		if have := StatusCode(err); have != want {
+			t.Errorf("%v: unexpected status code %d", err, have)
+		}
+	}
+}
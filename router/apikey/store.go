@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package apikey
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/register"
+)
+
+const (
+	// StaticStore is the name of the store reading the keys from the config
+	StaticStore = "static"
+	// FileStore is the name of the store reading the keys from a JSON file
+	FileStore = "file"
+	// HTTPStore is the name of the store resolving the keys with an http service
+	HTTPStore = "http"
+
+	// DefaultCacheTTL is the time the http store caches the lookups when the config does not declare it
+	DefaultCacheTTL = time.Minute
+)
+
+// Store resolves the identity owning a key
+type Store interface {
+	// Lookup returns the identity owning the key or ErrUnknownKey
+	Lookup(ctx context.Context, key string) (Identity, error)
+}
+
+// StoreFactory creates a store from the apikey section of the service extra config
+type StoreFactory func(cfg map[string]interface{}) (Store, error)
+
+var stores = register.NewUntyped()
+
+func init() {
+	RegisterStore(StaticStore, NewStaticStoreFromConfig)
+	RegisterStore(FileStore, NewFileStoreFromConfig)
+	RegisterStore(HTTPStore, NewHTTPStoreFromConfig)
+}
+
+// RegisterStore adds a store factory to the package register
+func RegisterStore(name string, sf StoreFactory) {
+	stores.Register(name, sf)
+}
+
+// GetStoreFactory returns the store factory registered with the name
+func GetStoreFactory(name string) (StoreFactory, bool) {
+	v, ok := stores.Get(name)
+	if !ok {
+		return nil, false
+	}
+	sf, ok := v.(StoreFactory)
+	return sf, ok
+}
+
+// KeyDefinition declares a key and its owner
+type KeyDefinition struct {
+	Key string `json:"key"`
+	Identity
+}
+
+// staticStore keeps the keys in memory. The keys are indexed by their hashes, so the raw keys are
+// not kept after the creation of the store
+type staticStore struct {
+	keys map[string]Identity
+}
+
+// NewStaticStore returns a store containing the received keys
+func NewStaticStore(defs []KeyDefinition) (Store, error) {
+	s := staticStore{keys: make(map[string]Identity, len(defs))}
+	for _, d := range defs {
+		if d.Key == "" || d.ClientID == "" {
+			return nil, errors.New("apikey: the keys require a key and a client_id")
+		}
+		h := hashKey(d.Key)
+		if _, ok := s.keys[h]; ok {
+			return nil, fmt.Errorf("apikey: duplicated key for the client %s", d.ClientID)
+		}
+		s.keys[h] = d.Identity
+	}
+	return s, nil
+}
+
+// NewStaticStoreFromConfig is the StoreFactory of the static store
+func NewStaticStoreFromConfig(cfg map[string]interface{}) (Store, error) {
+	b, err := json.Marshal(cfg["keys"])
+	if err != nil {
+		return nil, err
+	}
+	var defs []KeyDefinition
+	if err := json.Unmarshal(b, &defs); err != nil {
+		return nil, fmt.Errorf("apikey: parsing the keys: %w", err)
+	}
+	return NewStaticStore(defs)
+}
+
+// Lookup implements the Store interface
+func (s staticStore) Lookup(_ context.Context, key string) (Identity, error) {
+	if i, ok := s.keys[hashKey(key)]; ok {
+		return i, nil
+	}
+	return Identity{}, ErrUnknownKey
+}
+
+// NewFileStore returns a static store with the keys of the JSON file at the path. The file
+// contains an array of key definitions
+func NewFileStore(path string) (Store, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var defs []KeyDefinition
+	if err := json.Unmarshal(b, &defs); err != nil {
+		return nil, fmt.Errorf("apikey: parsing %s: %w", path, err)
+	}
+	return NewStaticStore(defs)
+}
+
+// NewFileStoreFromConfig is the StoreFactory of the file store
+func NewFileStoreFromConfig(cfg map[string]interface{}) (Store, error) {
+	path, _ := cfg["path"].(string)
+	if path == "" {
+		return nil, errors.New("apikey: the file store requires a path")
+	}
+	return NewFileStore(path)
+}
+
+type cachedLookup struct {
+	identity Identity
+	err      error
+	expires  time.Time
+}
+
+type httpStore struct {
+	url    string
+	header string
+	ttl    time.Duration
+	client *http.Client
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedLookup
+}
+
+// NewHTTPStore returns a store resolving the keys with a GET request to the url, sending the key
+// in the received header. The service replies with the JSON identity of the owner or with a 404
+// Not Found, 401 Unauthorized or 403 Forbidden for the unknown keys. The results of the lookups
+// are cached for the ttl
+func NewHTTPStore(url, header string, ttl time.Duration, c *http.Client) Store {
+	if header == "" {
+		header = DefaultHeader
+	}
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &httpStore{
+		url:    url,
+		header: header,
+		ttl:    ttl,
+		client: c,
+		now:    time.Now,
+		cache:  map[string]cachedLookup{},
+	}
+}
+
+// NewHTTPStoreFromConfig is the StoreFactory of the http store
+func NewHTTPStoreFromConfig(cfg map[string]interface{}) (Store, error) {
+	url, _ := cfg["url"].(string)
+	if url == "" {
+		return nil, errors.New("apikey: the http store requires an url")
+	}
+	header, _ := cfg["header"].(string)
+	ttl := DefaultCacheTTL
+	if v, ok := cfg["cache_ttl"].(string); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("apikey: invalid cache_ttl: %w", err)
+		}
+		ttl = d
+	}
+	return NewHTTPStore(url, header, ttl, nil), nil
+}
+
+// Lookup implements the Store interface
+func (s *httpStore) Lookup(ctx context.Context, key string) (Identity, error) {
+	h := hashKey(key)
+	now := s.now()
+
+	s.mu.Lock()
+	if c, ok := s.cache[h]; ok && now.Before(c.expires) {
+		s.mu.Unlock()
+		return c.identity, c.err
+	}
+	s.mu.Unlock()
+
+	i, err := s.fetch(ctx, key)
+	if err != nil && err != ErrUnknownKey {
+		// do not cache the failures of the service
+		return i, err
+	}
+
+	s.mu.Lock()
+	for k, c := range s.cache {
+		if !now.Before(c.expires) {
+			delete(s.cache, k)
+		}
+	}
+	s.cache[h] = cachedLookup{identity: i, err: err, expires: now.Add(s.ttl)}
+	s.mu.Unlock()
+	return i, err
+}
+
+func (s *httpStore) fetch(ctx context.Context, key string) (Identity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, http.NoBody)
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set(s.header, key)
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("apikey: looking up the key: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusUnauthorized, http.StatusForbidden:
+		io.Copy(io.Discard, resp.Body)
+		return Identity{}, ErrUnknownKey
+	default:
+		io.Copy(io.Discard, resp.Body)
+		return Identity{}, fmt.Errorf("apikey: looking up the key: unexpected status code %d", resp.StatusCode)
+	}
+
+	var i Identity
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&i); err != nil {
+		return Identity{}, fmt.Errorf("apikey: decoding the identity: %w", err)
+	}
+	if i.ClientID == "" {
+		return Identity{}, ErrUnknownKey
+	}
+	return i, nil
+}
+
+func hashKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewStaticStore(t *testing.T) {
+	if _, err := NewStaticStore([]KeyDefinition{{Key: "k"}}); err == nil {
+		t.Error("expecting an error for the keys without client")
+	}
+	if _, err := NewStaticStore([]KeyDefinition{
+		{Key: "k", Identity: Identity{ClientID: "a"}},
+		{Key: "k", Identity: Identity{ClientID: "b"}},
+	}); err == nil {
+		t.Error("expecting an error for the duplicated keys")
+	}
+}
+
+func TestNewFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(`[{"key":"k1","client_id":"a","metadata":{"plan":"gold"}}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewFileStoreFromConfig(map[string]interface{}{"path": path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := s.Lookup(context.Background(), "k1")
+	if err != nil || i.ClientID != "a" || i.Metadata["plan"] != "gold" {
+		t.Errorf("unexpected lookup: %+v %v", i, err)
+	}
+	if _, err := s.Lookup(context.Background(), "k2"); err != ErrUnknownKey {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewHTTPStore(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.Header.Get("X-Key") {
+		case "k1":
+			fmt.Fprint(w, `{"client_id":"a","roles":["user"]}`)
+		case "broken":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	s := NewHTTPStore(ts.URL, "X-Key", time.Minute, ts.Client())
+	for i := 0; i < 3; i++ {
+		if id, err := s.Lookup(context.Background(), "k1"); err != nil || id.ClientID != "a" {
+			t.Errorf("unexpected lookup: %+v %v", id, err)
+		}
+		if _, err := s.Lookup(context.Background(), "k2"); err != ErrUnknownKey {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if h := atomic.LoadInt32(&hits); h != 2 {
+		t.Errorf("the lookups should be cached. requests: %d", h)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.Lookup(context.Background(), "broken"); err == nil || err == ErrUnknownKey {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if h := atomic.LoadInt32(&hits); h != 4 {
+		t.Errorf("the failures should not be cached. requests: %d", h)
+	}
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package bodylimit bounds the size of the request bodies accepted by the endpoints, so the
+oversized requests are rejected by the router before any proxy work happens:
+
+	{
+		"endpoint": "/uploads",
+		"method": "POST",
+		"extra_config": {
+			"github.com/luraproject/lura/router/bodylimit": {
+				"max_size": 1048576
+			}
+		}
+	}
+
+The requests declaring a bigger content length are rejected without reading their body. The
+bodies of unknown length are read up to the limit before calling the proxy, so the ones
+exceeding it are rejected too. The rejected requests get a 413 Request Entity Too Large.
+*/
+package bodylimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the body limit config
+const Namespace = "github.com/luraproject/lura/router/bodylimit"
+
+// Config is the body limit config of an endpoint
+type Config struct {
+	MaxSize int64 `json:"max_size"`
+}
+
+// ConfigGetter parses the body limit config from the endpoint extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("bodylimit: parsing the config: %w", err)
+	}
+	if cfg.MaxSize <= 0 {
+		return cfg, true, fmt.Errorf("bodylimit: the max size must be positive")
+	}
+	return cfg, true, nil
+}
+
+// Error is returned for the request bodies exceeding the limit
+type Error struct {
+	Limit int64
+}
+
+// Error returns a string representation of the Error
+func (e Error) Error() string {
+	return fmt.Sprintf("the request body exceeds the max size of %d bytes", e.Limit)
+}
+
+// StatusCode returns the status of the responses to the rejected requests
+func (Error) StatusCode() int { return http.StatusRequestEntityTooLarge }
+
+// ErrorCode returns the code of the error, for the structured error responses
+func (Error) ErrorCode() string { return "request_too_large" }
+
+// Enforce returns an Error if the body of the request exceeds the limit. The bodies of unknown
+// length are read up to the limit and replaced by an in-memory copy
+func Enforce(r *http.Request, limit int64) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	if r.ContentLength > limit {
+		return Error{Limit: limit}
+	}
+	if r.ContentLength > 0 {
+		// the server never reads more than the declared length
+		return nil
+	}
+	b, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(b)) > limit {
+		return Error{Limit: limit}
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	return nil
+}
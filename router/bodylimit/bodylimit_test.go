@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bodylimit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok, _ := ConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the config should not be found")
+	}
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"max_size": 1024.0}})
+	if !ok || err != nil || cfg.MaxSize != 1024 {
+		t.Errorf("unexpected result: %+v %v %v", cfg, ok, err)
+	}
+	for _, v := range []interface{}{0.0, -1.0, "1MB"} {
+		if _, _, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"max_size": v}}); err == nil {
+			t.Errorf("%v: expecting an error", v)
+		}
+	}
+}
+
+func TestEnforce(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		body io.Reader
+		err  bool
+	}{
+		{"no body", nil, false},
+		{"known length", strings.NewReader("12345"), false},
+		{"known length too large", strings.NewReader("123456"), true},
+		{"unknown length", io.MultiReader(strings.NewReader("12345")), false},
+		{"unknown length too large", io.MultiReader(strings.NewReader("123456")), true},
+	} {
+		req, _ := http.NewRequest("POST", "http://example.com", tc.body)
+		err := Enforce(req, 5)
+		var e Error
+		if tc.err != errors.As(err, &e) {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		if tc.err {
+			if e.StatusCode() != http.StatusRequestEntityTooLarge || e.Limit != 5 {
+				t.Errorf("%s: unexpected error %+v", tc.name, e)
+			}
+			continue
+		}
+		if req.Body == nil {
+			continue
+		}
+		if b, _ := io.ReadAll(req.Body); string(b) != "12345" {
+			t.Errorf("%s: the body was not preserved: %q", tc.name, b)
+		}
+	}
+}
@@ -18,8 +18,8 @@ type HandlerFactory func(*config.EndpointConfig, proxy.Proxy) http.HandlerFunc
 
 // NewEndpointHandler implements the HandleFactory interface using the default ToHTTPError function
 func NewEndpointHandler(cfg *config.EndpointConfig, prxy proxy.Proxy) http.HandlerFunc {
-	hf := mux.CustomEndpointHandler(
-		mux.NewRequestBuilder(extractParamsFromEndpoint),
+	hf := mux.CustomFilteredEndpointHandler(
+		mux.NewFilteredRequestBuilder(extractParamsFromEndpoint),
 	)
 	return hf(cfg, prxy)
 }
@@ -32,6 +32,10 @@ func extractParamsFromEndpoint(r *http.Request) map[string]string {
 	if len(rctx.URLParams.Keys) > 0 {
 		title := cases.Title(language.Und)
 		for _, param := range rctx.URLParams.Keys {
+			if param == "*" {
+				// the catch-all wildcards are copied to their named params by the engine
+				continue
+			}
 			params[title.String(param[:1])+param[1:]] = chi.URLParam(r, param)
 		}
 	}
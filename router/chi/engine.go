@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package chi
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/router/mux"
+)
+
+// Namespace is the key of the options of the chi engine in the service extra config
+const Namespace = "github_com/luraproject/lura/router/chi"
+
+// EngineOptions collects the dependencies of the engines returned by NewEngine
+type EngineOptions struct {
+	Logger logging.Logger
+	// Writer receives the access log. It defaults to the standard output
+	Writer io.Writer
+}
+
+type engineConfiguration struct {
+	// DisableAccessLog removes the access log middleware
+	DisableAccessLog bool `json:"disable_access_log"`
+	// DisableRecovery removes the middleware answering the panicking requests with a 500
+	DisableRecovery bool `json:"disable_recovery"`
+	// RedirectTrailingSlash redirects the requests with a trailing slash to the path without it
+	RedirectTrailingSlash bool `json:"redirect_trailing_slash"`
+	// StripTrailingSlash routes the requests with a trailing slash as if they had none. It is
+	// ignored when RedirectTrailingSlash is enabled
+	StripTrailingSlash bool `json:"strip_trailing_slash"`
+	// CleanPath removes the double slashes and the dot segments of the paths before routing them
+	CleanPath bool `json:"clean_path"`
+}
+
+// NewEngine returns a chi router with the middlewares declared by the options of the service extra
+// config under the Namespace key
+func NewEngine(cfg config.ServiceConfig, opt EngineOptions) chi.Router {
+	engine := chi.NewRouter()
+
+	options := engineConfiguration{}
+	if v, ok := cfg.ExtraConfig[Namespace]; ok {
+		b, err := json.Marshal(v)
+		if err == nil {
+			err = json.Unmarshal(b, &options)
+		}
+		if err != nil && opt.Logger != nil {
+			opt.Logger.Error(logPrefix, "Unable to parse the engine options:", err.Error())
+		}
+	}
+
+	if !options.DisableAccessLog {
+		w := opt.Writer
+		if w == nil {
+			w = os.Stdout
+		}
+		engine.Use(middleware.RequestLogger(&middleware.DefaultLogFormatter{
+			Logger:  log.New(w, "", log.LstdFlags),
+			NoColor: true,
+		}))
+	}
+	if !options.DisableRecovery {
+		engine.Use(middleware.Recoverer)
+	}
+	if options.CleanPath {
+		engine.Use(middleware.CleanPath)
+	}
+	if options.RedirectTrailingSlash {
+		engine.Use(middleware.RedirectSlashes)
+	} else if options.StripTrailingSlash {
+		engine.Use(middleware.StripSlashes)
+	}
+
+	return engine
+}
+
+// chiEngine adapts a chi router to the mux.Engine interface, translating the colon params and the
+// catch-all wildcards of the endpoint patterns into the chi syntax
+type chiEngine struct {
+	r chi.Router
+}
+
+// Handle implements the mux.Engine interface from the lura router package
+func (e chiEngine) Handle(pattern, method string, handler http.Handler) {
+	pattern, catchAll := chiPattern(pattern)
+	if catchAll != "" {
+		// chi stores the rest of the path under the * key, so it is copied to the named param
+		next := handler
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				rctx.URLParams.Add(catchAll, rctx.URLParam("*"))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	e.r.Method(method, pattern, handler)
+}
+
+// ServeHTTP implements the http:Handler interface from the stdlib
+func (e chiEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.r.ServeHTTP(mux.NewHTTPErrorInterceptor(w), r)
+}
+
+// chiPattern returns the chi version of the received pattern and the name of its catch-all
+// wildcard, if any. Both the colon (:param and *param) and the brackets ({param} and
+// {param...}) placeholders are accepted, so the router works with any config.RoutingPattern
+func chiPattern(pattern string) (string, string) {
+	segments := strings.Split(pattern, "/")
+	catchAll := ""
+	for i, s := range segments {
+		switch {
+		case strings.HasPrefix(s, ":") && len(s) > 1:
+			segments[i] = "{" + s[1:] + "}"
+		case i == len(segments)-1 && strings.HasPrefix(s, "*") && len(s) > 1:
+			catchAll = s[1:]
+			segments[i] = "*"
+		case i == len(segments)-1 && strings.HasPrefix(s, "{") && strings.HasSuffix(s, config.CatchAllMarker+"}"):
+			catchAll = strings.TrimSuffix(s[1:len(s)-1], config.CatchAllMarker)
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, "/"), catchAll
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package chi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestChiPattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, expected, catchAll string
+	}{
+		{"/supu", "/supu", ""},
+		{"/supu/:tupu/foo", "/supu/{tupu}/foo", ""},
+		{"/supu/{tupu}/foo", "/supu/{tupu}/foo", ""},
+		{"/files/*path", "/files/*", "path"},
+		{"/files/{path...}", "/files/*", "path"},
+		{"/__debug/*", "/__debug/*", ""},
+	} {
+		pattern, catchAll := chiPattern(tc.pattern)
+		if pattern != tc.expected || catchAll != tc.catchAll {
+			t.Errorf("%s: unexpected result: %s %s", tc.pattern, pattern, catchAll)
+		}
+	}
+}
+
+func TestNewEngine(t *testing.T) {
+	buff := new(bytes.Buffer)
+	engine := NewEngine(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"strip_trailing_slash": true,
+			},
+		},
+	}, EngineOptions{Writer: buff})
+	engine.Get("/supu", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest("GET", "/supu/", http.NoBody))
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+	if buff.Len() == 0 {
+		t.Error("the access log should be enabled")
+	}
+}
+
+func TestRun_params(t *testing.T) {
+	var handler http.Handler
+	r := NewFactory(Config{
+		Engine:      chi.NewRouter(),
+		Middlewares: chi.Middlewares{},
+		HandlerFactory: func(_ *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range extractParamsFromEndpoint(r) {
+					w.Header().Set("X-Param-"+k, v)
+				}
+			}
+		},
+		ProxyFactory: proxy.FactoryFunc(func(_ *config.EndpointConfig) (proxy.Proxy, error) {
+			return proxy.NoopProxy, nil
+		}),
+		Logger: logging.NoOp,
+		RunServer: func(_ context.Context, _ config.ServiceConfig, h http.Handler) error {
+			handler = h
+			return nil
+		},
+	}).NewWithContext(context.Background())
+
+	r.Run(config.ServiceConfig{
+		Debug: true,
+		Echo:  true,
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/users/:id", Method: "GET", Backend: []*config.Backend{{}}},
+			{Endpoint: "/files/*path", Method: "GET", Backend: []*config.Backend{{}}},
+		},
+	})
+	if handler == nil {
+		t.Error("the router did not start the server")
+		return
+	}
+
+	for _, tc := range []struct {
+		path, header, value string
+	}{
+		{"/users/42", "X-Param-Id", "42"},
+		{"/files/a/b.txt", "X-Param-Path", "a/b.txt"},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", tc.path, http.NoBody))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: unexpected status code: %d", tc.path, w.Code)
+		}
+		if v := w.Header().Get(tc.header); v != tc.value {
+			t.Errorf("%s: unexpected param: %s", tc.path, v)
+		}
+	}
+
+	for _, path := range []string{"/__health", "/__debug/supu", "/__echo/supu"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, http.NoBody))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: unexpected status code: %d", path, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/users/42", http.NoBody))
+	if allow := w.Header().Get("Allow"); allow == "" {
+		t.Errorf("unexpected Allow header: %v", w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/unknown", http.NoBody))
+	b, _ := io.ReadAll(w.Result().Body)
+	if w.Code != http.StatusNotFound || w.Header().Get("X-Krakend-Completed") != "false" {
+		t.Errorf("unexpected response: %d %v %s", w.Code, w.Header(), b)
+	}
+}
@@ -8,7 +8,6 @@ package chi
 import (
 	"context"
 	"net/http"
-	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -20,10 +19,13 @@ import (
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
-// ChiDefaultDebugPattern is the default pattern used to define the debug endpoint
-const ChiDefaultDebugPattern = "/__debug/"
-
-const logPrefix = "[SERVICE: Chi]"
+const (
+	// ChiDefaultDebugPattern is the default pattern used to define the debug endpoint
+	ChiDefaultDebugPattern = "/__debug/*"
+	// ChiDefaultEchoPattern is the default pattern used to define the echo endpoint
+	ChiDefaultEchoPattern = "/__echo/*"
+	logPrefix             = "[SERVICE: Chi]"
+)
 
 // RunServerFunc is a func that will run the http Server with the given params.
 type RunServerFunc func(context.Context, config.ServiceConfig, http.Handler) error
@@ -36,20 +38,23 @@ type Config struct {
 	ProxyFactory   proxy.Factory
 	Logger         logging.Logger
 	DebugPattern   string
+	EchoPattern    string
 	RunServer      RunServerFunc
 }
 
 // DefaultFactory returns a chi router factory with the injected proxy factory and logger.
 // It also uses a default chi router and the default HandlerFactory
 func DefaultFactory(proxyFactory proxy.Factory, logger logging.Logger) router.Factory {
+	logger = logging.Module(logger, "router")
 	return NewFactory(
 		Config{
 			Engine:         chi.NewRouter(),
 			Middlewares:    chi.Middlewares{middleware.Logger},
-			HandlerFactory: NewEndpointHandler,
+			HandlerFactory: HandlerFactory(mux.NewDefaultHandlerFactory(mux.HandlerFactory(NewEndpointHandler), logger)),
 			ProxyFactory:   proxyFactory,
 			Logger:         logger,
 			DebugPattern:   ChiDefaultDebugPattern,
+			EchoPattern:    ChiDefaultEchoPattern,
 			RunServer:      server.RunServer,
 		},
 	)
@@ -57,9 +62,13 @@ func DefaultFactory(proxyFactory proxy.Factory, logger logging.Logger) router.Fa
 
 // NewFactory returns a chi router factory with the injected configuration
 func NewFactory(cfg Config) router.Factory {
+	cfg.Logger = logging.Module(cfg.Logger, "router")
 	if cfg.DebugPattern == "" {
 		cfg.DebugPattern = ChiDefaultDebugPattern
 	}
+	if cfg.EchoPattern == "" {
+		cfg.EchoPattern = ChiDefaultEchoPattern
+	}
 	return factory{cfg}
 }
 
@@ -83,77 +92,19 @@ type chiRouter struct {
 	RunServer RunServerFunc
 }
 
-// Run implements the router interface
+// Run implements the router interface. The service is served by the mux router over the chi
+// engine, so it exposes the same health, debug and echo endpoints and service level features
 func (r chiRouter) Run(cfg config.ServiceConfig) {
 	r.cfg.Engine.Use(r.cfg.Middlewares...)
-	if cfg.Debug {
-		r.registerDebugEndpoints()
-	}
-
-	r.cfg.Engine.Get("/__health", mux.HealthHandler)
-
-	server.InitHTTPDefaultTransport(cfg)
-
-	r.registerKrakendEndpoints(cfg.Endpoints)
-
-	r.cfg.Engine.NotFound(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set(server.CompleteResponseHeaderName, server.HeaderIncompleteResponseValue)
-		http.NotFound(w, r)
-	})
-
-	if err := r.RunServer(r.ctx, cfg, r.cfg.Engine); err != nil {
-		r.cfg.Logger.Error(logPrefix, err.Error())
-	}
-
-	r.cfg.Logger.Info(logPrefix, "Router execution ended")
-}
-
-func (r chiRouter) registerDebugEndpoints() {
-	debugHandler := mux.DebugHandler(r.cfg.Logger)
-	r.cfg.Engine.Get(r.cfg.DebugPattern, debugHandler)
-	r.cfg.Engine.Post(r.cfg.DebugPattern, debugHandler)
-	r.cfg.Engine.Put(r.cfg.DebugPattern, debugHandler)
-	r.cfg.Engine.Patch(r.cfg.DebugPattern, debugHandler)
-	r.cfg.Engine.Delete(r.cfg.DebugPattern, debugHandler)
-}
 
-func (r chiRouter) registerKrakendEndpoints(endpoints []*config.EndpointConfig) {
-	for _, c := range endpoints {
-		proxyStack, err := r.cfg.ProxyFactory.New(c)
-		if err != nil {
-			r.cfg.Logger.Error(logPrefix, "calling the ProxyFactory", err.Error())
-			continue
-		}
-
-		r.registerKrakendEndpoint(c.Method, c, r.cfg.HandlerFactory(c, proxyStack), len(c.Backend))
-	}
-}
-
-func (r chiRouter) registerKrakendEndpoint(method string, endpoint *config.EndpointConfig, handler http.HandlerFunc, totBackends int) {
-	method = strings.ToTitle(method)
-	path := endpoint.Endpoint
-
-	if method != http.MethodGet && totBackends > 1 {
-		if !router.IsValidSequentialEndpoint(endpoint) {
-			r.cfg.Logger.Error(logPrefix, method, "endpoints with sequential proxy enabled only allow a non-GET in the last backend! Ignoring", path)
-			return
-		}
-	}
-
-	switch method {
-	case http.MethodGet:
-		r.cfg.Engine.Get(path, handler)
-	case http.MethodPost:
-		r.cfg.Engine.Post(path, handler)
-	case http.MethodPut:
-		r.cfg.Engine.Put(path, handler)
-	case http.MethodPatch:
-		r.cfg.Engine.Patch(path, handler)
-	case http.MethodDelete:
-		r.cfg.Engine.Delete(path, handler)
-	default:
-		r.cfg.Logger.Error(logPrefix, "Unsupported method", method)
-		return
-	}
-	r.cfg.Logger.Debug(logPrefix, "registering the endpoint", method, path)
+	mux.NewFactory(mux.Config{
+		Engine:         chiEngine{r.cfg.Engine},
+		Middlewares:    []mux.HandlerMiddleware{},
+		HandlerFactory: mux.HandlerFactory(r.cfg.HandlerFactory),
+		ProxyFactory:   r.cfg.ProxyFactory,
+		Logger:         r.cfg.Logger,
+		DebugPattern:   r.cfg.DebugPattern,
+		EchoPattern:    r.cfg.EchoPattern,
+		RunServer:      mux.RunServerFunc(r.RunServer),
+	}).NewWithContext(r.ctx).Run(cfg)
 }
@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package cors handles the Cross-Origin Resource Sharing requests at the router layer, so the
+preflight requests are answered before reaching the 404 and 405 handlers of the engines.
+
+The CORS policy is declared in the service extra config:
+
+	"extra_config": {
+		"github.com/luraproject/lura/router/cors": {
+			"allow_origins": ["https://app.example.com", "https://*.example.org"],
+			"allow_methods": ["GET", "POST"],
+			"allow_headers": ["Authorization", "Content-Type"],
+			"expose_headers": ["X-Request-Id"],
+			"allow_credentials": true,
+			"max_age": "12h"
+		}
+	}
+*/
+package cors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the cors config
+const Namespace = "github.com/luraproject/lura/router/cors"
+
+var (
+	// DefaultAllowMethods are the methods allowed when the config does not declare them
+	DefaultAllowMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	// DefaultAllowHeaders are the headers allowed when the config does not declare them
+	DefaultAllowHeaders = []string{"Origin", "Accept", "Content-Type", "X-Requested-With"}
+)
+
+// Config is the cors config
+type Config struct {
+	AllowOrigins     []string `json:"allow_origins"`
+	AllowMethods     []string `json:"allow_methods"`
+	AllowHeaders     []string `json:"allow_headers"`
+	ExposeHeaders    []string `json:"expose_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           string   `json:"max_age"`
+}
+
+// ConfigGetter parses the cors config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("cors: parsing the config: %w", err)
+	}
+	return cfg, true, nil
+}
+
+// CORS applies a cors policy to the requests
+type CORS struct {
+	anyOrigin     bool
+	origins       map[string]struct{}
+	wildcards     [][2]string
+	methods       map[string]struct{}
+	allowMethods  string
+	anyHeader     bool
+	headers       map[string]struct{}
+	allowHeaders  string
+	exposeHeaders string
+	credentials   bool
+	maxAge        string
+}
+
+// New returns a CORS applying the policy of the config
+func New(cfg Config) (*CORS, error) {
+	c := &CORS{
+		origins:       map[string]struct{}{},
+		methods:       map[string]struct{}{},
+		headers:       map[string]struct{}{},
+		exposeHeaders: strings.Join(cfg.ExposeHeaders, ", "),
+		credentials:   cfg.AllowCredentials,
+	}
+
+	origins := cfg.AllowOrigins
+	if len(origins) == 0 {
+		origins = []string{"*"}
+	}
+	for _, o := range origins {
+		o = strings.ToLower(strings.TrimSpace(o))
+		switch i := strings.Index(o, "*"); {
+		case o == "*":
+			c.anyOrigin = true
+		case i >= 0:
+			if strings.Contains(o[i+1:], "*") {
+				return nil, fmt.Errorf("cors: invalid origin %s: only one wildcard is supported", o)
+			}
+			c.wildcards = append(c.wildcards, [2]string{o[:i], o[i+1:]})
+		default:
+			c.origins[o] = struct{}{}
+		}
+	}
+
+	methods := cfg.AllowMethods
+	if len(methods) == 0 {
+		methods = DefaultAllowMethods
+	}
+	normalized := make([]string, len(methods))
+	for i, m := range methods {
+		normalized[i] = strings.ToUpper(strings.TrimSpace(m))
+		c.methods[normalized[i]] = struct{}{}
+	}
+	c.allowMethods = strings.Join(normalized, ", ")
+
+	headers := cfg.AllowHeaders
+	if len(headers) == 0 {
+		headers = DefaultAllowHeaders
+	}
+	normalized = make([]string, 0, len(headers))
+	for _, h := range headers {
+		if h = strings.TrimSpace(h); h == "*" {
+			c.anyHeader = true
+			continue
+		}
+		normalized = append(normalized, http.CanonicalHeaderKey(h))
+		c.headers[strings.ToLower(h)] = struct{}{}
+	}
+	c.allowHeaders = strings.Join(normalized, ", ")
+
+	if cfg.MaxAge != "" {
+		d, err := time.ParseDuration(cfg.MaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("cors: invalid max_age: %w", err)
+		}
+		c.maxAge = strconv.Itoa(int(d.Seconds()))
+	}
+	return c, nil
+}
+
+// FromConfig returns the CORS declared in the service config. It returns false if the service
+// does not declare a cors policy
+func FromConfig(cfg config.ServiceConfig) (*CORS, bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	res, err := New(c)
+	return res, true, err
+}
+
+// Handler decorates the handler with the cors policy. It implements the mux HandlerMiddleware
+// interface
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.Handle(w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Handle adds the cors headers to the response. It returns true if the request is a preflight
+// one, already answered, so it must not reach the endpoints
+func (c *CORS) Handle(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+		c.preflight(w, r, origin)
+		return true
+	}
+
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	if origin == "" || !c.allowedOrigin(origin) {
+		return false
+	}
+	c.setOrigin(h, origin)
+	if c.exposeHeaders != "" {
+		h.Set("Access-Control-Expose-Headers", c.exposeHeaders)
+	}
+	return false
+}
+
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	requested := parseHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+	if !c.allowedOrigin(origin) || !c.allowedMethod(method) || !c.allowedHeaders(requested) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	c.setOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", c.allowMethods)
+	if c.anyHeader {
+		if len(requested) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+		}
+	} else if c.allowHeaders != "" {
+		h.Set("Access-Control-Allow-Headers", c.allowHeaders)
+	}
+	if c.maxAge != "" {
+		h.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *CORS) setOrigin(h http.Header, origin string) {
+	if c.anyOrigin && !c.credentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		// the wildcard is not accepted by the browsers for the requests with credentials
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *CORS) allowedOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if _, ok := c.origins[origin]; ok {
+		return true
+	}
+	for _, w := range c.wildcards {
+		if len(origin) >= len(w[0])+len(w[1]) && strings.HasPrefix(origin, w[0]) && strings.HasSuffix(origin, w[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) allowedMethod(method string) bool {
+	if method == http.MethodOptions {
+		return true
+	}
+	_, ok := c.methods[method]
+	return ok
+}
+
+func (c *CORS) allowedHeaders(headers []string) bool {
+	if c.anyHeader {
+		return true
+	}
+	for _, h := range headers {
+		if _, ok := c.headers[strings.ToLower(h)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func parseHeaderList(v string) []string {
+	if v == "" {
+		return nil
+	}
+	parts := strings.Split(v, ",")
+	headers := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			headers = append(headers, http.CanonicalHeaderKey(p))
+		}
+	}
+	return headers
+}
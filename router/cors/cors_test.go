@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestCORS_preflight(t *testing.T) {
+	c, err := New(Config{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		AllowMethods:     []string{"get", "POST"},
+		AllowHeaders:     []string{"authorization", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		t.Error("the preflight requests should not reach the handler")
+	})
+
+	for _, tc := range []struct {
+		origin, method, headers string
+		status                  int
+	}{
+		{origin: "https://app.example.com", method: "POST", headers: "Authorization, content-type", status: http.StatusNoContent},
+		{origin: "https://api.example.org", method: "GET", status: http.StatusNoContent},
+		{origin: "https://example.org", method: "GET", status: http.StatusForbidden},
+		{origin: "https://evil.com", method: "GET", status: http.StatusForbidden},
+		{origin: "https://app.example.com", method: "DELETE", status: http.StatusForbidden},
+		{origin: "https://app.example.com", method: "GET", headers: "X-Custom", status: http.StatusForbidden},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("OPTIONS", "/a", http.NoBody)
+		req.Header.Set("Origin", tc.origin)
+		req.Header.Set("Access-Control-Request-Method", tc.method)
+		if tc.headers != "" {
+			req.Header.Set("Access-Control-Request-Headers", tc.headers)
+		}
+		c.Handler(next).ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%s %s: unexpected status code %d", tc.origin, tc.method, w.Code)
+			continue
+		}
+		if tc.status != http.StatusNoContent {
+			if w.Header().Get("Access-Control-Allow-Origin") != "" {
+				t.Errorf("%s %s: unexpected cors headers %v", tc.origin, tc.method, w.Header())
+			}
+			continue
+		}
+		h := w.Header()
+		if h.Get("Access-Control-Allow-Origin") != tc.origin ||
+			h.Get("Access-Control-Allow-Credentials") != "true" ||
+			h.Get("Access-Control-Allow-Methods") != "GET, POST" ||
+			h.Get("Access-Control-Allow-Headers") != "Authorization, Content-Type" ||
+			h.Get("Access-Control-Max-Age") != "3600" {
+			t.Errorf("%s %s: unexpected headers %v", tc.origin, tc.method, h)
+		}
+	}
+}
+
+func TestCORS_actualRequest(t *testing.T) {
+	c, _ := New(Config{ExposeHeaders: []string{"X-Request-Id"}})
+	called := 0
+	handler := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		called++
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/a", http.NoBody)
+	req.Header.Set("Origin", "https://anywhere.com")
+	handler.ServeHTTP(w, req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Access-Control-Expose-Headers") != "X-Request-Id" {
+		t.Errorf("unexpected headers: %v", w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/a", http.NoBody))
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("the requests without origin should not get cors headers: %v", w.Header())
+	}
+	if called != 2 {
+		t.Errorf("unexpected number of calls to the handler: %d", called)
+	}
+}
+
+func TestCORS_anyHeader(t *testing.T) {
+	c, _ := New(Config{AllowHeaders: []string{"*"}})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("OPTIONS", "/a", http.NoBody)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	req.Header.Set("Access-Control-Request-Headers", "x-custom")
+	c.Handle(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Headers") != "X-Custom" {
+		t.Errorf("unexpected response: %d %v", w.Code, w.Header())
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	if _, ok, _ := FromConfig(config.ServiceConfig{}); ok {
+		t.Error("the service does not declare a cors policy")
+	}
+	for _, cfg := range []map[string]interface{}{
+		{"max_age": "forever"},
+		{"allow_origins": []interface{}{"https://*.*.example.com"}},
+	} {
+		if _, _, err := FromConfig(config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: cfg}}); err == nil {
+			t.Errorf("expecting an error for %v", cfg)
+		}
+	}
+}
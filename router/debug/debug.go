@@ -0,0 +1,276 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package debug provides the handler of the debug endpoint, echoing the received requests along with
+the requests the backends of the matching endpoint would receive.
+
+The routers expose it under /__debug/ when the service enables the debug mode. The path after
+the prefix is matched against the endpoints of the service, so a request to
+/__debug/users/42?fields=name with the method of an endpoint declared as /users/{id} returns the
+params extracted from the path and the requests built for every backend of the endpoint, after
+filtering the headers and query strings and replacing the placeholders of their url patterns. The
+backends are never called.
+
+The echoed requests are configured at the service level:
+
+	"extra_config": {
+		"github.com/luraproject/lura/router/debug": {
+			"max_body_size": 1024,
+			"redact_headers": ["Authorization", "X-Api-Key"]
+		}
+	}
+
+The values of the redacted headers are replaced in the responses, and the bodies bigger than the
+limit are truncated. By default, the Authorization, Cookie and Proxy-Authorization headers are
+redacted and the bodies are limited to 64 KB.
+*/
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/forwarded"
+	"github.com/luraproject/lura/v2/transport/http/server"
+)
+
+// Namespace is the key to use to store and access the debug endpoint config
+const Namespace = "github.com/luraproject/lura/router/debug"
+
+// DefaultMaxBodySize is the max size of the echoed bodies, when not configured
+const DefaultMaxBodySize = 64 * 1024
+
+// Redacted replaces the values of the redacted headers
+const Redacted = "[REDACTED]"
+
+// DefaultRedactedHeaders are the headers redacted when not configured
+var DefaultRedactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// Config is the config of the debug endpoint
+type Config struct {
+	MaxBodySize   int64    `json:"max_body_size"`
+	RedactHeaders []string `json:"redact_headers"`
+}
+
+// ConfigGetter parses the debug endpoint config from the service extra config, applying the
+// defaults to the missing options
+func ConfigGetter(e config.ExtraConfig) (Config, error) {
+	cfg := Config{}
+	if tmp, ok := e[Namespace].(map[string]interface{}); ok {
+		b, err := json.Marshal(tmp)
+		if err != nil {
+			return defaults(cfg), err
+		}
+		if err := json.Unmarshal(b, &cfg); err != nil {
+			return defaults(Config{}), fmt.Errorf("debug: parsing the config: %w", err)
+		}
+	}
+	return defaults(cfg), nil
+}
+
+func defaults(cfg Config) Config {
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultMaxBodySize
+	}
+	if cfg.RedactHeaders == nil {
+		cfg.RedactHeaders = DefaultRedactedHeaders
+	}
+	return cfg
+}
+
+// Response is the content returned by the debug endpoint
+type Response struct {
+	Method        string                 `json:"method"`
+	URI           string                 `json:"uri"`
+	Path          string                 `json:"path"`
+	Query         map[string][]string    `json:"query"`
+	Headers       map[string][]string    `json:"headers"`
+	Body          string                 `json:"body"`
+	BodyTruncated bool                   `json:"body_truncated,omitempty"`
+	Endpoint      string                 `json:"endpoint,omitempty"`
+	Params        map[string]string      `json:"params,omitempty"`
+	Backends      []proxy.BackendRequest `json:"backend_requests,omitempty"`
+}
+
+// NewHandler returns the handler of the debug endpoint registered with the received route
+// pattern. The path of the requests after the static prefix of the pattern is matched against
+// the endpoints of the service
+func NewHandler(cfg config.ServiceConfig, pattern string, logger logging.Logger) http.HandlerFunc {
+	logPrefix := "[ENDPOINT: /__debug/*]"
+	dcfg, err := ConfigGetter(cfg.ExtraConfig)
+	if err != nil {
+		logger.Error(logPrefix, "Unable to parse the config:", err.Error())
+	}
+	prefix := pattern
+	if i := strings.LastIndex(pattern, "/"); i >= 0 {
+		prefix = pattern[:i]
+	}
+	redacted := make(map[string]struct{}, len(dcfg.RedactHeaders))
+	for _, h := range dcfg.RedactHeaders {
+		redacted[textproto.CanonicalMIMEHeaderKey(h)] = struct{}{}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		var truncated bool
+		if r.Body != nil {
+			body, _ = io.ReadAll(io.LimitReader(r.Body, dcfg.MaxBodySize+1))
+			r.Body.Close()
+			if int64(len(body)) > dcfg.MaxBodySize {
+				body = body[:dcfg.MaxBodySize]
+				truncated = true
+			}
+		}
+		logger.Debug(logPrefix, "Method:", r.Method)
+		logger.Debug(logPrefix, "URL:", r.RequestURI)
+		logger.Debug(logPrefix, "Query:", r.URL.Query())
+		logger.Debug(logPrefix, "Headers:", redact(r.Header, redacted))
+		logger.Debug(logPrefix, "Body:", string(body))
+
+		path := strings.TrimPrefix(r.URL.Path, prefix)
+		if path == "" {
+			path = "/"
+		}
+		resp := Response{
+			Method:        r.Method,
+			URI:           r.RequestURI,
+			Path:          path,
+			Query:         r.URL.Query(),
+			Headers:       redact(r.Header, redacted),
+			Body:          string(body),
+			BodyTruncated: truncated,
+		}
+
+		if e, params, ok := Match(cfg.Endpoints, r.Method, path); ok {
+			logger.Debug(logPrefix, "Endpoint:", e.Endpoint, "Params:", params)
+			resp.Endpoint = e.Endpoint
+			resp.Params = params
+			resp.Backends = proxy.ExplainRequest(e, newRequest(e, r, path, params))
+			for i, b := range resp.Backends {
+				resp.Backends[i].Headers = redact(b.Headers, redacted)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// newRequest builds the request the router would send to the proxy of the endpoint
+func newRequest(e *config.EndpointConfig, r *http.Request, path string, params map[string]string) *proxy.Request {
+	headers := proxy.CloneRequestHeaders(config.NewHeadersFilter(e.HeadersToPass).Filter(r.Header))
+	if f, ok := forwarded.FromRequest(r); ok {
+		f.SetHeaders(headers, r)
+	} else {
+		var ip string
+		if addr := forwarded.ClientIP(r); addr != nil {
+			ip = addr.String()
+		}
+		headers["X-Forwarded-For"] = []string{ip}
+		headers["X-Forwarded-Host"] = []string{r.Host}
+	}
+	if _, ok := headers["User-Agent"]; !ok {
+		headers["User-Agent"] = server.UserAgentHeaderValue
+	} else {
+		headers["X-Forwarded-Via"] = server.UserAgentHeaderValue
+	}
+
+	return &proxy.Request{
+		Path:    path,
+		Method:  r.Method,
+		Query:   config.NewParamsFilter(e.QueryString).Filter(r.URL.Query()),
+		Params:  params,
+		Headers: headers,
+	}
+}
+
+func redact(h map[string][]string, redacted map[string]struct{}) map[string][]string {
+	res := make(map[string][]string, len(h))
+	for k, v := range h {
+		if _, ok := redacted[textproto.CanonicalMIMEHeaderKey(k)]; ok {
+			res[k] = []string{Redacted}
+			continue
+		}
+		res[k] = v
+	}
+	return res
+}
+
+// Match returns the endpoint declared with the method and a pattern matching the path, along
+// with the params extracted from the path, keyed as the routers do
+func Match(endpoints []*config.EndpointConfig, method, path string) (*config.EndpointConfig, map[string]string, bool) {
+	for _, e := range endpoints {
+		if !strings.EqualFold(e.Method, method) {
+			continue
+		}
+		if params, ok := matchPattern(e.Endpoint, path); ok {
+			return e, params, true
+		}
+	}
+	return nil, nil, false
+}
+
+func matchPattern(pattern, path string) (map[string]string, bool) {
+	// the casers are stateful, so every match gets its own
+	title := cases.Title(language.Und)
+	segments := split(pattern)
+	parts := split(path)
+	params := map[string]string{}
+	for i, s := range segments {
+		name, catchAll, optional := param(s)
+		switch {
+		case catchAll:
+			params[title.String(name)] = strings.Join(parts[i:], "/")
+			return params, true
+		case optional && i == len(parts) && i == len(segments)-1:
+			params[title.String(name)] = ""
+			return params, true
+		case i >= len(parts):
+			return nil, false
+		case name != "":
+			params[title.String(name)] = parts[i]
+		case s != parts[i]:
+			return nil, false
+		}
+	}
+	return params, len(parts) == len(segments)
+}
+
+func split(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+// param returns the name of the param declared by the segment of a pattern, if any
+func param(s string) (name string, catchAll, optional bool) {
+	switch {
+	case strings.HasPrefix(s, "*"):
+		return s[1:], true, false
+	case strings.HasPrefix(s, ":"):
+		name = s[1:]
+	case strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}"):
+		name = s[1 : len(s)-1]
+		if strings.HasSuffix(name, "...") {
+			return strings.TrimSuffix(name, "..."), true, false
+		}
+	default:
+		return "", false, false
+	}
+	if strings.HasSuffix(name, config.OptionalSegmentMarker) {
+		return strings.TrimSuffix(name, config.OptionalSegmentMarker), false, true
+	}
+	return name, false, false
+}
@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestMatch(t *testing.T) {
+	endpoints := []*config.EndpointConfig{
+		{Endpoint: "/", Method: "GET"},
+		{Endpoint: "/users/:id", Method: "GET"},
+		{Endpoint: "/users/{id}/orders/{orderId?}", Method: "GET"},
+		{Endpoint: "/files/*path", Method: "GET"},
+		{Endpoint: "/static/{path...}", Method: "GET"},
+		{Endpoint: "/users", Method: "POST"},
+	}
+	for _, tc := range []struct {
+		method, path, endpoint string
+		params                 map[string]string
+	}{
+		{"GET", "/", "/", map[string]string{}},
+		{"GET", "/users/42", "/users/:id", map[string]string{"Id": "42"}},
+		{"GET", "/users/42/orders", "/users/{id}/orders/{orderId?}", map[string]string{"Id": "42", "Orderid": ""}},
+		{"GET", "/users/42/orders/7", "/users/{id}/orders/{orderId?}", map[string]string{"Id": "42", "Orderid": "7"}},
+		{"GET", "/files/a/b.txt", "/files/*path", map[string]string{"Path": "a/b.txt"}},
+		{"GET", "/static/css/app.css", "/static/{path...}", map[string]string{"Path": "css/app.css"}},
+		{"post", "/users", "/users", map[string]string{}},
+		{"DELETE", "/users", "", nil},
+		{"GET", "/users/42/unknown", "", nil},
+	} {
+		e, params, ok := Match(endpoints, tc.method, tc.path)
+		if tc.endpoint == "" {
+			if ok {
+				t.Errorf("%s %s: unexpected match %s", tc.method, tc.path, e.Endpoint)
+			}
+			continue
+		}
+		if !ok || e.Endpoint != tc.endpoint {
+			t.Errorf("%s %s: unexpected endpoint %v", tc.method, tc.path, e)
+			continue
+		}
+		if len(params) != len(tc.params) {
+			t.Errorf("%s %s: unexpected params %v", tc.method, tc.path, params)
+		}
+		for k, v := range tc.params {
+			if params[k] != v {
+				t.Errorf("%s %s: unexpected param %s: %s", tc.method, tc.path, k, params[k])
+			}
+		}
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{"max_body_size": 4},
+		},
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint:      "/users/{id}",
+				Method:        "POST",
+				QueryString:   []string{"fields"},
+				HeadersToPass: []string{"Authorization", "X-Tenant"},
+				Backend: []*config.Backend{
+					{
+						URLPattern: "/v1/users/{{.Id}}",
+						Method:     "PUT",
+						Host:       []string{"http://users.local"},
+					},
+				},
+			},
+		},
+	}
+	handler := NewHandler(cfg, "/__debug/", logging.NoOp)
+
+	req := httptest.NewRequest("POST", "/__debug/users/42?fields=name&other=1", strings.NewReader("supu tupu"))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Tenant", "acme")
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected response: %d %v", w.Code, w.Header())
+	}
+	var resp Response
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Path != "/users/42" || resp.Method != "POST" || resp.Endpoint != "/users/{id}" || resp.Params["Id"] != "42" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if resp.Body != "supu" || !resp.BodyTruncated {
+		t.Errorf("unexpected body: %s", resp.Body)
+	}
+	if v := resp.Headers["Authorization"]; len(v) != 1 || v[0] != Redacted {
+		t.Errorf("the authorization header was not redacted: %v", resp.Headers)
+	}
+	if len(resp.Backends) != 1 {
+		t.Fatalf("unexpected backend requests: %v", resp.Backends)
+	}
+	b := resp.Backends[0]
+	if b.Method != "PUT" || b.URL != "http://users.local/v1/users/42?fields=name" {
+		t.Errorf("unexpected backend request: %+v", b)
+	}
+	if v := b.Headers["Authorization"]; len(v) != 1 || v[0] != Redacted {
+		t.Errorf("the authorization header was not redacted: %v", b.Headers)
+	}
+	if v := b.Headers["X-Tenant"]; len(v) != 1 || v[0] != "acme" {
+		t.Errorf("unexpected headers: %v", b.Headers)
+	}
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		t.Error("the received request was modified")
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/__debug/unknown", http.NoBody))
+	resp = Response{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Endpoint != "" || resp.Backends != nil || resp.Path != "/unknown" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestConfigGetter(t *testing.T) {
+	cfg, err := ConfigGetter(config.ExtraConfig{})
+	if err != nil || cfg.MaxBodySize != DefaultMaxBodySize || len(cfg.RedactHeaders) != len(DefaultRedactedHeaders) {
+		t.Errorf("unexpected config: %+v %v", cfg, err)
+	}
+	cfg, err = ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"redact_headers": []interface{}{}}})
+	if err != nil || len(cfg.RedactHeaders) != 0 {
+		t.Errorf("unexpected config: %+v %v", cfg, err)
+	}
+	if _, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"max_body_size": "big"}}); err == nil {
+		t.Error("expecting an error")
+	}
+}
@@ -0,0 +1,332 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package errorencoder renders the errors returned by the endpoints in a structured format, so the
+clients can tell them apart without parsing plain text messages.
+
+The encoder is declared in the service extra config:
+
+	"extra_config": {
+		"github.com/luraproject/lura/router/errorencoder": {
+			"format": "problem",
+			"type_base": "https://errors.example.com/",
+			"hide_detail": false
+		}
+	}
+
+The default format renders the errors as RFC 7807 problem details (application/problem+json),
+extended with the id of the request, the name of the failing backend, if known, and a stable
+error code:
+
+	{
+		"type": "https://errors.example.com/timeout",
+		"title": "Internal Server Error",
+		"status": 500,
+		"detail": "context deadline exceeded",
+		"instance": "/users/42",
+		"code": "timeout",
+		"request_id": "0f5c4b1e-...",
+		"backend": "users"
+	}
+
+The failures of the backends returning an unexpected status add it as backend_status, unless
+the details are hidden. The type is the type_base followed by the code, or about:blank without a
+type_base. The text format keeps the plain text bodies. Other formats can be added with
+RegisterEncoder. The errors can declare their code by implementing the Coder interface or with
+RegisterErrorCode, and the code of the rest is derived from the status. The responses of the
+backends passing their failures through are never encoded.
+*/
+package errorencoder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/requestid"
+	"github.com/luraproject/lura/v2/transport/http/client"
+	"github.com/luraproject/lura/v2/transport/http/server"
+)
+
+// Namespace is the key to use to store and access the error encoder config
+const Namespace = "github.com/luraproject/lura/router/errorencoder"
+
+const (
+	// FormatProblem is the name of the RFC 7807 encoder
+	FormatProblem = "problem"
+	// FormatText is the name of the plain text encoder
+	FormatText = "text"
+)
+
+// ProblemContentType is the content type of the RFC 7807 bodies
+const ProblemContentType = "application/problem+json"
+
+// Config is the error encoder config of the service
+type Config struct {
+	Format     string `json:"format"`
+	TypeBase   string `json:"type_base"`
+	HideDetail bool   `json:"hide_detail"`
+}
+
+// ConfigGetter parses the error encoder config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("errorencoder: parsing the config: %w", err)
+	}
+	return cfg, true, nil
+}
+
+// Problem describes an error returned by an endpoint
+type Problem struct {
+	Type          string `json:"type"`
+	Title         string `json:"title"`
+	Status        int    `json:"status"`
+	Detail        string `json:"detail,omitempty"`
+	Instance      string `json:"instance,omitempty"`
+	Code          string `json:"code"`
+	RequestID     string `json:"request_id,omitempty"`
+	Backend       string `json:"backend,omitempty"`
+	BackendStatus int    `json:"backend_status,omitempty"`
+}
+
+// Encoder returns the body and the content type of the responses for a problem
+type Encoder func(Problem) ([]byte, string)
+
+var (
+	encoders = map[string]Encoder{
+		FormatProblem: problemEncoder,
+		FormatText:    textEncoder,
+	}
+	encodersMu sync.RWMutex
+)
+
+// RegisterEncoder adds an encoder to the available formats, replacing the one with the same name
+func RegisterEncoder(name string, e Encoder) {
+	encodersMu.Lock()
+	encoders[name] = e
+	encodersMu.Unlock()
+}
+
+func problemEncoder(p Problem) ([]byte, string) {
+	b, _ := json.Marshal(p)
+	return b, ProblemContentType
+}
+
+func textEncoder(p Problem) ([]byte, string) {
+	msg := p.Detail
+	if msg == "" {
+		msg = p.Title
+	}
+	return []byte(msg + "\n"), "text/plain; charset=utf-8"
+}
+
+// Coder is implemented by the errors declaring their code
+type Coder interface {
+	ErrorCode() string
+}
+
+var (
+	errorCodes = []errorCode{
+		{context.DeadlineExceeded, "timeout"},
+		{context.Canceled, "canceled"},
+		{server.ErrInternalError, "internal_error"},
+		{client.ErrInvalidStatusCode, "invalid_backend_status"},
+	}
+	errorCodesMu sync.RWMutex
+)
+
+type errorCode struct {
+	err  error
+	code string
+}
+
+// RegisterErrorCode sets the code of the errors matching the received one
+func RegisterErrorCode(err error, code string) {
+	errorCodesMu.Lock()
+	errorCodes = append(errorCodes, errorCode{err, code})
+	errorCodesMu.Unlock()
+}
+
+type multiError interface {
+	error
+	Errors() []error
+}
+
+// ErrorCode returns the stable code of an error. The errors aggregating several ones get the code
+// of the first one, and the unknown errors the snake cased text of the status
+func ErrorCode(err error, status int) string {
+	if err != nil {
+		var c Coder
+		if errors.As(err, &c) && c.ErrorCode() != "" {
+			return c.ErrorCode()
+		}
+		errorCodesMu.RLock()
+		for i := len(errorCodes) - 1; i >= 0; i-- {
+			if errors.Is(err, errorCodes[i].err) {
+				errorCodesMu.RUnlock()
+				return errorCodes[i].code
+			}
+		}
+		errorCodesMu.RUnlock()
+		var m multiError
+		if errors.As(err, &m) && len(m.Errors()) > 0 {
+			return ErrorCode(m.Errors()[0], status)
+		}
+	}
+	return statusCode(status)
+}
+
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "status_" + strconv.Itoa(status)
+	}
+	var b strings.Builder
+	for _, w := range strings.Fields(strings.ToLower(text)) {
+		if b.Len() > 0 {
+			b.WriteByte('_')
+		}
+		for _, r := range w {
+			if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+type namedError interface {
+	error
+	Name() string
+}
+
+// Backend returns the name of the backend reporting the error, if known
+func Backend(err error) (string, bool) {
+	if e, ok := client.AsBackendError(err); ok && e.Name != "" {
+		return e.Name, true
+	}
+	var n namedError
+	if errors.As(err, &n) {
+		return n.Name(), n.Name() != ""
+	}
+	var m multiError
+	if errors.As(err, &m) {
+		for _, e := range m.Errors() {
+			if name, ok := Backend(e); ok {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Renderer encodes the errors of the endpoints
+type Renderer struct {
+	encoder    Encoder
+	typeBase   string
+	hideDetail bool
+}
+
+// New returns a Renderer with the config
+func New(cfg Config) (*Renderer, error) {
+	format := cfg.Format
+	if format == "" {
+		format = FormatProblem
+	}
+	encodersMu.RLock()
+	e, ok := encoders[format]
+	encodersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("errorencoder: unknown format %s", format)
+	}
+	return &Renderer{encoder: e, typeBase: cfg.TypeBase, hideDetail: cfg.HideDetail}, nil
+}
+
+// Problem returns the description of an error returned for the request with the path. The request
+// id is taken from the context
+func (r *Renderer) Problem(ctx context.Context, path string, status int, err error) Problem {
+	p := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: path,
+		Code:     ErrorCode(err, status),
+	}
+	if r.typeBase != "" {
+		p.Type = r.typeBase + p.Code
+	}
+	if err != nil {
+		if !r.hideDetail {
+			p.Detail = err.Error()
+			if e, ok := client.AsBackendError(err); ok {
+				p.BackendStatus = e.Status
+			}
+		}
+		p.Backend, _ = Backend(err)
+	}
+	p.RequestID, _ = requestid.FromContext(ctx)
+	return p
+}
+
+// Write sends the encoded error as the response
+func (r *Renderer) Write(ctx context.Context, w http.ResponseWriter, path string, status int, err error) {
+	body, contentType := r.encoder(r.Problem(ctx, path, status, err))
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+var (
+	global   *Renderer
+	globalMu sync.RWMutex
+)
+
+// Register creates the renderer declared in the service extra config. It returns false if the
+// service does not declare the error encoder, so the errors keep their plain text bodies
+func Register(cfg config.ServiceConfig) (bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok || err != nil {
+		SetGlobal(nil)
+		return ok, err
+	}
+	r, err := New(c)
+	if err != nil {
+		SetGlobal(nil)
+		return ok, err
+	}
+	SetGlobal(r)
+	return ok, nil
+}
+
+// SetGlobal sets the renderer used by the routers
+func SetGlobal(r *Renderer) {
+	globalMu.Lock()
+	global = r
+	globalMu.Unlock()
+}
+
+// GetGlobal returns the renderer used by the routers, if any
+func GetGlobal() (*Renderer, bool) {
+	globalMu.RLock()
+	r := global
+	globalMu.RUnlock()
+	return r, r != nil
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package errorencoder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/requestid"
+	"github.com/luraproject/lura/v2/transport/http/client"
+	"github.com/luraproject/lura/v2/transport/http/server"
+)
+
+type backendError struct {
+	error
+	name string
+}
+
+func (b backendError) Name() string  { return b.name }
+func (b backendError) Unwrap() error { return b.error }
+
+type codedError struct{ error }
+
+func (codedError) ErrorCode() string { return "quota_exceeded" }
+
+type multi []error
+
+func (m multi) Error() string   { return "multiple errors" }
+func (m multi) Errors() []error { return m }
+
+func TestRenderer_Write(t *testing.T) {
+	r, err := New(Config{TypeBase: "https://errors.example.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := requestid.NewContext(context.Background(), "abc")
+	w := httptest.NewRecorder()
+	r.Write(ctx, w, "/users/42", http.StatusInternalServerError, backendError{fmt.Errorf("calling: %w", context.DeadlineExceeded), "users"})
+
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != ProblemContentType {
+		t.Errorf("unexpected response %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	expected := Problem{
+		Type:      "https://errors.example.com/timeout",
+		Title:     "Internal Server Error",
+		Status:    http.StatusInternalServerError,
+		Detail:    "calling: context deadline exceeded",
+		Instance:  "/users/42",
+		Code:      "timeout",
+		RequestID: "abc",
+		Backend:   "users",
+	}
+	if p != expected {
+		t.Errorf("unexpected problem %+v", p)
+	}
+}
+
+func TestRenderer_text(t *testing.T) {
+	r, _ := New(Config{Format: FormatText, HideDetail: true})
+	w := httptest.NewRecorder()
+	r.Write(context.Background(), w, "/", http.StatusBadGateway, errors.New("secret"))
+	if w.Body.String() != "Bad Gateway\n" || w.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("unexpected response %q", w.Body.String())
+	}
+
+	if _, err := New(Config{Format: "xml"}); err == nil {
+		t.Error("error expected")
+	}
+	RegisterEncoder("xml", func(p Problem) ([]byte, string) { return []byte("<problem/>"), "application/xml" })
+	if _, err := New(Config{Format: "xml"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	errCustom := errors.New("custom")
+	RegisterErrorCode(errCustom, "custom_failure")
+
+	for _, tc := range []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{nil, http.StatusMethodNotAllowed, "method_not_allowed"},
+		{errors.New("boom"), http.StatusTeapot, "im_a_teapot"},
+		{errors.New("boom"), 599, "status_599"},
+		{server.ErrInternalError, http.StatusInternalServerError, "internal_error"},
+		{codedError{errors.New("boom")}, http.StatusTooManyRequests, "quota_exceeded"},
+		{fmt.Errorf("wrapped: %w", errCustom), http.StatusBadRequest, "custom_failure"},
+		{multi{context.Canceled, errCustom}, http.StatusInternalServerError, "canceled"},
+	} {
+		if code := ErrorCode(tc.err, tc.status); code != tc.code {
+			t.Errorf("%v: unexpected code %s", tc.err, code)
+		}
+	}
+
+	if name, ok := Backend(multi{errors.New("a"), backendError{errors.New("b"), "b"}}); !ok || name != "b" {
+		t.Errorf("unexpected backend %s", name)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer SetGlobal(nil)
+
+	if ok, err := Register(config.ServiceConfig{}); ok || err != nil {
+		t.Errorf("unexpected result %v %v", ok, err)
+	}
+	if _, ok := GetGlobal(); ok {
+		t.Error("unexpected renderer")
+	}
+	ok, err := Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"format": "unknown"}}})
+	if !ok || err == nil {
+		t.Errorf("unexpected result %v %v", ok, err)
+	}
+	if ok, err := Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{}}}); !ok || err != nil {
+		t.Errorf("unexpected result %v %v", ok, err)
+	}
+	if _, ok := GetGlobal(); !ok {
+		t.Error("the renderer has not been registered")
+	}
+}
+
+func TestRenderer_backendError(t *testing.T) {
+	r, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backendErr := &client.BackendError{Name: "users", URLPattern: "/users/{{.Id}}", Status: http.StatusServiceUnavailable, Err: client.ErrInvalidStatusCode}
+	p := r.Problem(context.Background(), "/users/42", http.StatusInternalServerError, multi{errors.New("other"), backendErr})
+	if p.Backend != "users" || p.BackendStatus != http.StatusServiceUnavailable || p.Code != "internal_server_error" {
+		t.Errorf("unexpected problem %+v", p)
+	}
+
+	r, _ = New(Config{HideDetail: true})
+	p = r.Problem(context.Background(), "/users/42", http.StatusInternalServerError, backendErr)
+	if p.Detail != "" || p.BackendStatus != 0 || p.Code != "invalid_backend_status" {
+		t.Errorf("unexpected problem %+v", p)
+	}
+}
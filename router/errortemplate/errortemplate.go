@@ -0,0 +1,204 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package errortemplate replaces the bodies of the error responses generated by the gateway, so
+the error contracts of the endpoints match the style of the rest of the API.
+
+The templates are indexed by status code and can be declared by the service, applying to all
+its endpoints, and by the endpoints, overriding the service ones:
+
+	"extra_config": {
+		"github.com/luraproject/lura/router/errortemplate": {
+			"content_type": "application/json",
+			"templates": {
+				"401": "{\"error\": \"unauthorized\", \"detail\": {{json .Message}}}",
+				"429": {
+					"body": "{\"type\": \"about:blank\", \"status\": {{.Status}}, \"title\": {{json .StatusText}}}",
+					"content_type": "application/problem+json"
+				}
+			}
+		}
+	}
+
+The templates are parsed with text/template and receive a Data value. The json function encodes
+a value as JSON. Only the responses generated by the gateway are replaced: the rejections of the
+router layer (400, 401, 403...) and the errors returned by the proxy stage (429, 503...). The
+responses of the backends keep their bodies, whatever their status code.
+*/
+package errortemplate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/funcmap"
+)
+
+// Namespace is the key to use to store and access the error templates config
+const Namespace = "github.com/luraproject/lura/router/errortemplate"
+
+// DefaultContentType is the content type of the templated bodies when the config does not
+// declare one
+const DefaultContentType = "application/json"
+
+// ContextKey is the string key of the proxy marker in the contexts that only support string
+// keys, like the gin ones
+const ContextKey = "github.com/luraproject/lura/router/errortemplate.marker"
+
+// Data is the value received by the templates
+type Data struct {
+	Status     int
+	StatusText string
+	// Message is the error reported by the gateway or the status text if there is none
+	Message  string
+	Endpoint string
+	Method   string
+	Path     string
+}
+
+// Template is the body of the responses with a status code
+type Template struct {
+	ContentType string
+	tmpl        *template.Template
+}
+
+// Templates are the error templates indexed by status code
+type Templates map[int]Template
+
+// FromExtraConfig parses the templates declared in the extra config
+func FromExtraConfig(e config.ExtraConfig) (Templates, bool, error) {
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+	contentType := DefaultContentType
+	if ct, ok := tmp["content_type"].(string); ok && ct != "" {
+		contentType = ct
+	}
+	raw, _ := tmp["templates"].(map[string]interface{})
+	ts := make(Templates, len(raw))
+	for k, v := range raw {
+		status, err := strconv.Atoi(k)
+		if err != nil || status < http.StatusBadRequest || status > 599 {
+			return nil, true, fmt.Errorf("errortemplate: invalid status code %s", k)
+		}
+		t := Template{ContentType: contentType}
+		var body string
+		switch v := v.(type) {
+		case string:
+			body = v
+		case map[string]interface{}:
+			body, _ = v["body"].(string)
+			if ct, ok := v["content_type"].(string); ok && ct != "" {
+				t.ContentType = ct
+			}
+		default:
+			return nil, true, fmt.Errorf("errortemplate: invalid template for the status %d", status)
+		}
+		t.tmpl, err = funcmap.New(k).Parse(body)
+		if err != nil {
+			return nil, true, fmt.Errorf("errortemplate: parsing the template for the status %d: %w", status, err)
+		}
+		ts[status] = t
+	}
+	return ts, true, nil
+}
+
+// Render returns the body of the responses with the status of the data. It returns false if
+// there is no template for the status
+func (ts Templates) Render(d Data) ([]byte, string, bool) {
+	t, ok := ts[d.Status]
+	if !ok {
+		return nil, "", false
+	}
+	buf := new(bytes.Buffer)
+	if err := t.tmpl.Execute(buf, d); err != nil {
+		return nil, "", false
+	}
+	return buf.Bytes(), t.ContentType, true
+}
+
+var (
+	global   Templates
+	globalMu sync.RWMutex
+)
+
+// Register sets the templates declared in the service extra config as the ones of every
+// endpoint. It returns false if the service does not declare any
+func Register(cfg config.ServiceConfig) (bool, error) {
+	ts, ok, err := FromExtraConfig(cfg.ExtraConfig)
+	globalMu.Lock()
+	global = ts
+	globalMu.Unlock()
+	return ok, err
+}
+
+// EndpointTemplates returns the templates of the endpoint: the service ones overridden by the
+// ones declared by the endpoint. It returns false if there are no templates for the endpoint
+func EndpointTemplates(cfg *config.EndpointConfig) (Templates, bool, error) {
+	ts, _, err := FromExtraConfig(cfg.ExtraConfig)
+	if err != nil {
+		return nil, true, err
+	}
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	if len(ts) == 0 && len(global) == 0 {
+		return nil, false, nil
+	}
+	res := make(Templates, len(global)+len(ts))
+	for k, v := range global {
+		res[k] = v
+	}
+	for k, v := range ts {
+		res[k] = v
+	}
+	return res, true, nil
+}
+
+// Marker records if the proxy of an endpoint returned a response, so its status and body come
+// from the backends and must not be replaced
+type Marker struct {
+	proxied int32
+}
+
+// Proxied returns true if the proxy returned a response
+func (m *Marker) Proxied() bool { return atomic.LoadInt32(&m.proxied) == 1 }
+
+type markerKey struct{}
+
+// NewContext returns a copy of the context carrying the marker
+func NewContext(ctx context.Context, m *Marker) context.Context {
+	return context.WithValue(ctx, markerKey{}, m)
+}
+
+// FromContext returns the marker stored in the context, if any
+func FromContext(ctx context.Context) (*Marker, bool) {
+	if m, ok := ctx.Value(markerKey{}).(*Marker); ok {
+		return m, true
+	}
+	m, ok := ctx.Value(ContextKey).(*Marker)
+	return m, ok
+}
+
+// MarkProxied flags the marker in the context, if any, as proxied
+func MarkProxied(ctx context.Context) {
+	if m, ok := FromContext(ctx); ok {
+		atomic.StoreInt32(&m.proxied, 1)
+	}
+}
+
+// Message returns the message of an error body written by the gateway
+func Message(body []byte, status int) string {
+	if msg := strings.TrimSpace(string(body)); msg != "" {
+		return msg
+	}
+	return http.StatusText(status)
+}
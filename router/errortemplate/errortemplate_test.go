@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package errortemplate
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/funcmap"
+)
+
+func TestFromExtraConfig(t *testing.T) {
+	ts, ok, err := FromExtraConfig(config.ExtraConfig{Namespace: map[string]interface{}{
+		"templates": map[string]interface{}{
+			"401": `{"error":"unauthorized","detail":{{json .Message}}}`,
+			"429": map[string]interface{}{
+				"body":         `{"status":{{.Status}},"title":{{json .StatusText}}}`,
+				"content_type": "application/problem+json",
+			},
+		},
+	}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+
+	body, contentType, ok := ts.Render(Data{Status: 401, Message: `invalid "token"`})
+	if !ok || contentType != DefaultContentType || string(body) != `{"error":"unauthorized","detail":"invalid \"token\""}` {
+		t.Errorf("unexpected rendering: %s (%s)", body, contentType)
+	}
+	body, contentType, ok = ts.Render(Data{Status: 429, StatusText: "Too Many Requests"})
+	if !ok || contentType != "application/problem+json" || string(body) != `{"status":429,"title":"Too Many Requests"}` {
+		t.Errorf("unexpected rendering: %s (%s)", body, contentType)
+	}
+	if _, _, ok := ts.Render(Data{Status: 503}); ok {
+		t.Error("there is no template for the 503 status")
+	}
+}
+
+func TestFromExtraConfig_invalid(t *testing.T) {
+	for _, templates := range []map[string]interface{}{
+		{"200": "ok"},
+		{"four hundred": "bad"},
+		{"400": 42},
+		{"400": "{{.Unclosed"},
+	} {
+		if _, _, err := FromExtraConfig(config.ExtraConfig{Namespace: map[string]interface{}{"templates": templates}}); err == nil {
+			t.Errorf("expecting an error for %v", templates)
+		}
+	}
+}
+
+func TestEndpointTemplates(t *testing.T) {
+	defer Register(config.ServiceConfig{})
+
+	Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"templates": map[string]interface{}{"401": "service 401", "403": "service 403"},
+	}}})
+
+	ts, ok, err := EndpointTemplates(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"content_type": "text/plain",
+		"templates":    map[string]interface{}{"403": "endpoint 403"},
+	}}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	for status, expected := range map[int]string{401: "service 401", 403: "endpoint 403"} {
+		if body, _, _ := ts.Render(Data{Status: status}); string(body) != expected {
+			t.Errorf("unexpected body for %d: %s", status, body)
+		}
+	}
+
+	Register(config.ServiceConfig{})
+	if _, ok, _ := EndpointTemplates(&config.EndpointConfig{}); ok {
+		t.Error("the endpoint should not have templates")
+	}
+}
+
+func TestMarker(t *testing.T) {
+	m := &Marker{}
+	MarkProxied(context.Background())
+	MarkProxied(NewContext(context.Background(), m))
+	if !m.Proxied() {
+		t.Error("the marker was not flagged")
+	}
+	m = &Marker{}
+	MarkProxied(context.WithValue(context.Background(), ContextKey, m))
+	if !m.Proxied() {
+		t.Error("the marker stored with the string key was not flagged")
+	}
+	if Message([]byte(" boom\n"), 500) != "boom" || Message(nil, 404) != "Not Found" {
+		t.Error("unexpected messages")
+	}
+}
+
+func TestFromExtraConfig_funcmap(t *testing.T) {
+	defer funcmap.Reset()
+	funcmap.Register("shout", strings.ToUpper)
+
+	ts, _, err := FromExtraConfig(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"templates": map[string]interface{}{"404": `{"error":"{{ shout .Message }}"}`},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _, ok := ts.Render(Data{Status: 404, Message: "not found"})
+	if !ok || string(body) != `{"error":"NOT FOUND"}` {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
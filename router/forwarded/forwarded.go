@@ -0,0 +1,338 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package forwarded resolves the real IP of the clients behind trusted proxies and sets the
+forwarding headers of the requests to the backends.
+
+The config is declared in the service extra config:
+
+	"extra_config": {
+		"github.com/luraproject/lura/router/forwarded": {
+			"trusted_proxies": ["10.0.0.0/8"],
+			"client_ip_headers": ["Forwarded", "X-Forwarded-For"],
+			"forwarded": true
+		}
+	}
+
+The client IP is the remote address of the connection unless it belongs to a trusted proxy. In
+that case, the client IP headers are checked in order and their chain of hops is walked from
+right to left, skipping the trusted proxies, so the clients can not spoof their address by
+sending the headers themselves. The resolver is shared by the ip filters, the access logs and
+the rest of the packages needing the client IP, so the trusted proxies are declared only here.
+
+The requests to the backends get the gateway appended to the X-Forwarded-For chain and the
+X-Forwarded-Proto and X-Forwarded-Host of the original request. The forwarding headers received
+from untrusted addresses are discarded. When the forwarded flag is set, the RFC 7239 Forwarded
+header is sent too.
+*/
+package forwarded
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the forwarded config
+const Namespace = "github.com/luraproject/lura/router/forwarded"
+
+// DefaultClientIPHeaders are the headers checked when the config does not declare them
+var DefaultClientIPHeaders = []string{"X-Forwarded-For", "Forwarded", "X-Real-IP"}
+
+const (
+	headerForwarded      = "Forwarded"
+	headerForwardedFor   = "X-Forwarded-For"
+	headerForwardedHost  = "X-Forwarded-Host"
+	headerForwardedProto = "X-Forwarded-Proto"
+)
+
+// Config is the forwarded config
+type Config struct {
+	TrustedProxies  []string `json:"trusted_proxies"`
+	ClientIPHeaders []string `json:"client_ip_headers"`
+	Forwarded       bool     `json:"forwarded"`
+}
+
+// ConfigGetter parses the forwarded config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("forwarded: parsing the config: %w", err)
+	}
+	return cfg, true, nil
+}
+
+// Resolver resolves the client IP of the requests and the forwarding headers to send
+type Resolver struct {
+	trusted   []*net.IPNet
+	headers   []string
+	forwarded bool
+}
+
+// New returns the Resolver of the config
+func New(cfg Config) (*Resolver, error) {
+	r := &Resolver{forwarded: cfg.Forwarded}
+	for _, h := range cfg.ClientIPHeaders {
+		r.headers = append(r.headers, http.CanonicalHeaderKey(strings.TrimSpace(h)))
+	}
+	if len(r.headers) == 0 {
+		r.headers = DefaultClientIPHeaders
+	}
+	for _, v := range cfg.TrustedProxies {
+		n, err := parseNetwork(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		r.trusted = append(r.trusted, n)
+	}
+	return r, nil
+}
+
+// Trusted returns true if the ip belongs to a trusted proxy
+func (r *Resolver) Trusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range r.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP of the client sending the request. The client IP headers are only
+// considered when the request comes from a trusted proxy
+func (r *Resolver) ClientIP(req *http.Request) net.IP {
+	remote := RemoteIP(req)
+	if !r.Trusted(remote) {
+		return remote
+	}
+
+	for _, h := range r.headers {
+		hops := r.hops(req, h)
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(hops[i])
+			if ip == nil {
+				break
+			}
+			if i == 0 || !r.Trusted(ip) {
+				return ip
+			}
+		}
+	}
+	return remote
+}
+
+// SetHeaders sets the forwarding headers of the request to a backend, appending the hop of the
+// received request to the chain sent by the trusted proxies, if any
+func (r *Resolver) SetHeaders(headers map[string][]string, req *http.Request) {
+	remote := RemoteIP(req)
+	trusted := r.Trusted(remote)
+	var addr string
+	if remote != nil {
+		addr = remote.String()
+	}
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+
+	forwardedFor := addr
+	forwardedProto := proto
+	forwardedHost := req.Host
+	var forwarded string
+	if trusted {
+		if vs := req.Header.Values(headerForwardedFor); len(vs) > 0 {
+			forwardedFor = strings.Join(vs, ", ") + ", " + addr
+		}
+		if v := req.Header.Get(headerForwardedProto); v != "" {
+			forwardedProto = v
+		}
+		if v := req.Header.Get(headerForwardedHost); v != "" {
+			forwardedHost = v
+		}
+		if vs := req.Header.Values(headerForwarded); len(vs) > 0 {
+			forwarded = strings.Join(vs, ", ") + ", "
+		}
+	}
+
+	headers[headerForwardedFor] = []string{forwardedFor}
+	headers[headerForwardedProto] = []string{forwardedProto}
+	headers[headerForwardedHost] = []string{forwardedHost}
+	if !r.forwarded {
+		return
+	}
+	node := addr
+	if remote == nil {
+		node = "unknown"
+	} else if remote.To4() == nil {
+		node = "[" + addr + "]"
+	}
+	headers[headerForwarded] = []string{forwarded + "for=" + quote(node) + ";host=" + quote(req.Host) + ";proto=" + proto}
+}
+
+// hops returns the addresses listed in the header of the request, from the client to the last
+// proxy
+func (*Resolver) hops(req *http.Request, header string) []string {
+	values := req.Header.Values(header)
+	if len(values) == 0 {
+		return nil
+	}
+	elements := strings.Split(strings.Join(values, ","), ",")
+	hops := make([]string, 0, len(elements))
+	for _, e := range elements {
+		if header != headerForwarded {
+			hops = append(hops, strings.TrimSpace(e))
+			continue
+		}
+		hops = append(hops, forwardedFor(e))
+	}
+	return hops
+}
+
+// forwardedFor returns the address of the for parameter of a Forwarded element
+func forwardedFor(element string) string {
+	for _, pair := range strings.Split(element, ";") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || !strings.EqualFold(kv[0], "for") {
+			continue
+		}
+		node := strings.Trim(kv[1], `"`)
+		if strings.HasPrefix(node, "[") {
+			if end := strings.Index(node, "]"); end > 0 {
+				return node[1:end]
+			}
+			return ""
+		}
+		if host, _, err := net.SplitHostPort(node); err == nil {
+			return host
+		}
+		return node
+	}
+	return ""
+}
+
+// quote returns the value as a quoted string, unless it is a valid token
+func quote(v string) string {
+	if v != "" && strings.IndexFunc(v, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", r))
+	}) < 0 {
+		return v
+	}
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(v, `\`, `\\`), `"`, `\"`) + `"`
+}
+
+// RemoteIP returns the IP of the remote address of the request
+func RemoteIP(req *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(req.RemoteAddr))
+	if err != nil {
+		host = strings.TrimSpace(req.RemoteAddr)
+	}
+	return net.ParseIP(host)
+}
+
+func parseNetwork(v string) (*net.IPNet, error) {
+	if !strings.Contains(v, "/") {
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return nil, fmt.Errorf("forwarded: invalid ip %s", v)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(v)
+	if err != nil {
+		return nil, fmt.Errorf("forwarded: invalid cidr %s", v)
+	}
+	return n, nil
+}
+
+var (
+	global   *Resolver
+	globalMu sync.RWMutex
+)
+
+// Register creates the resolver declared in the service extra config. It returns false if the
+// service does not declare the forwarded config
+func Register(cfg config.ServiceConfig) (bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	var r *Resolver
+	if ok && err == nil {
+		r, err = New(c)
+	}
+	SetGlobal(r)
+	return ok, err
+}
+
+// SetGlobal sets the resolver used by the router
+func SetGlobal(r *Resolver) {
+	globalMu.Lock()
+	global = r
+	globalMu.Unlock()
+}
+
+// GetGlobal returns the resolver used by the router, if any
+func GetGlobal() (*Resolver, bool) {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return global, global != nil
+}
+
+type contextKey struct{}
+
+// resolverHolder keeps the resolver of a router in the request context, so the routers without
+// a resolver do not fall back to the registered one
+type resolverHolder struct {
+	resolver *Resolver
+}
+
+// NewContext returns a copy of the context carrying the resolver of the router serving the
+// request. The nil resolver is stored too, so the router does not use the one registered by
+// another router
+func NewContext(ctx context.Context, r *Resolver) context.Context {
+	return context.WithValue(ctx, contextKey{}, resolverHolder{r})
+}
+
+// Handler decorates the received handler, so the requests carry the resolver of the router
+func Handler(r *Resolver, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		h.ServeHTTP(w, req.WithContext(NewContext(req.Context(), r)))
+	})
+}
+
+// FromRequest returns the resolver of the router serving the request or, if the request does not
+// carry one, the registered resolver
+func FromRequest(req *http.Request) (*Resolver, bool) {
+	if h, ok := req.Context().Value(contextKey{}).(resolverHolder); ok {
+		return h.resolver, h.resolver != nil
+	}
+	return GetGlobal()
+}
+
+// ClientIP returns the IP of the client sending the request, resolved with the resolver of the
+// router serving it, if any, or the remote address of the request. The loggers, the ip filters
+// and the rate limiters should rely on it instead of parsing the forwarding headers themselves
+func ClientIP(req *http.Request) net.IP {
+	if r, ok := FromRequest(req); ok {
+		return r.ClientIP(req)
+	}
+	return RemoteIP(req)
+}
@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package forwarded
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestResolver_ClientIP(t *testing.T) {
+	r, err := New(Config{TrustedProxies: []string{"10.0.0.0/8", "2001:db8::1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		remote   string
+		headers  map[string]string
+		expected string
+	}{
+		{remote: "1.2.3.4:1234", headers: map[string]string{"X-Forwarded-For": "5.6.7.8"}, expected: "1.2.3.4"},
+		{remote: "10.0.0.1:1234", headers: map[string]string{"X-Forwarded-For": "5.6.7.8"}, expected: "5.6.7.8"},
+		{remote: "10.0.0.1:1234", headers: map[string]string{"X-Forwarded-For": "9.9.9.9, 5.6.7.8, 10.0.0.2"}, expected: "5.6.7.8"},
+		{remote: "10.0.0.1:1234", headers: map[string]string{"Forwarded": `for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"`}, expected: "192.0.2.60"},
+		{remote: "10.0.0.1:1234", headers: map[string]string{"Forwarded": `for=unknown`}, expected: "10.0.0.1"},
+		{remote: "[2001:db8::1]:1234", headers: map[string]string{"X-Real-Ip": "5.6.7.8"}, expected: "5.6.7.8"},
+		{remote: "10.0.0.1:1234", expected: "10.0.0.1"},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		req.RemoteAddr = tc.remote
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		if ip := r.ClientIP(req); ip.String() != tc.expected {
+			t.Errorf("%s %v: unexpected client ip %s", tc.remote, tc.headers, ip)
+		}
+	}
+}
+
+func TestResolver_SetHeaders(t *testing.T) {
+	r, err := New(Config{TrustedProxies: []string{"10.0.0.0/8"}, Forwarded: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com:8443/users", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.TLS = &tls.ConnectionState{}
+	req.Header.Set("X-Forwarded-For", "5.6.7.8")
+	req.Header.Set("X-Forwarded-Proto", "http")
+	req.Header.Set("Forwarded", "for=5.6.7.8")
+	headers := map[string][]string{}
+	r.SetHeaders(headers, req)
+	for k, v := range map[string]string{
+		"X-Forwarded-For":   "5.6.7.8, 10.0.0.1",
+		"X-Forwarded-Proto": "http",
+		"X-Forwarded-Host":  "api.example.com:8443",
+		"Forwarded":         `for=5.6.7.8, for=10.0.0.1;host="api.example.com:8443";proto=https`,
+	} {
+		if vs := headers[k]; len(vs) != 1 || vs[0] != v {
+			t.Errorf("%s: unexpected value %v", k, vs)
+		}
+	}
+
+	// the headers sent by untrusted clients are discarded
+	req.RemoteAddr = "[2001:db8::5]:1234"
+	headers = map[string][]string{}
+	r.SetHeaders(headers, req)
+	for k, v := range map[string]string{
+		"X-Forwarded-For":   "2001:db8::5",
+		"X-Forwarded-Proto": "https",
+		"Forwarded":         `for="[2001:db8::5]";host="api.example.com:8443";proto=https`,
+	} {
+		if vs := headers[k]; len(vs) != 1 || vs[0] != v {
+			t.Errorf("%s: unexpected value %v", k, vs)
+		}
+	}
+}
+
+func TestNew_invalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{TrustedProxies: []string{"10.0.0.0/33"}},
+		{TrustedProxies: []string{"not-an-ip"}},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("expecting an error for %+v", cfg)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer SetGlobal(nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "5.6.7.8")
+	if ip := ClientIP(req); ip.String() != "10.0.0.1" {
+		t.Errorf("unexpected client ip %s", ip)
+	}
+
+	ok, err := Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{"trusted_proxies": []interface{}{"10.0.0.1"}},
+	}})
+	if !ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	if ip := ClientIP(req); ip.String() != "5.6.7.8" {
+		t.Errorf("unexpected client ip %s", ip)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	global, err := New(Config{TrustedProxies: []string{"10.0.0.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetGlobal(global)
+	defer SetGlobal(nil)
+
+	var ip string
+	h := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		ip = ClientIP(r).String()
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "5.6.7.8")
+
+	h.ServeHTTP(nil, req)
+	if ip != "5.6.7.8" {
+		t.Errorf("the registered resolver was not used: %s", ip)
+	}
+
+	// the router without a resolver does not use the one registered by another router
+	Handler(nil, h).ServeHTTP(nil, req)
+	if ip != "10.0.0.1" {
+		t.Errorf("unexpected client ip %s", ip)
+	}
+
+	r, err := New(Config{TrustedProxies: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Handler(r, h).ServeHTTP(nil, req)
+	if ip != "5.6.7.8" {
+		t.Errorf("unexpected client ip %s", ip)
+	}
+}
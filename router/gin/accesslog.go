@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/accesslog"
+)
+
+// NewAccessLogHandlerFactory decorates the handlers of the endpoints, so the sampled requests are
+// written to the access log. It does nothing when the service does not declare an access log
+func NewAccessLogHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handler := hf(cfg, p)
+		l, ok := accesslog.GetGlobal()
+		if !ok {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][AccessLog]"
+
+		return func(c *gin.Context) {
+			if !l.Sampled() {
+				handler(c)
+				return
+			}
+			e := accesslog.NewEntry(c.Request, cfg)
+			c.Request = c.Request.WithContext(accesslog.NewContext(c.Request.Context(), e))
+			handler(c)
+
+			e.Status, e.Latency = c.Writer.Status(), time.Since(e.Time)
+			if size := c.Writer.Size(); size > 0 {
+				e.Bytes = int64(size)
+			}
+			if err := l.Log(e); err != nil {
+				logger.Error(logPrefix, "Unable to write the entry:", err.Error())
+			}
+		}
+	}
+}
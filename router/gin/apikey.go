@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/accesslog"
+	"github.com/luraproject/lura/v2/router/apikey"
+)
+
+// NewAPIKeyHandlerFactory decorates the handlers of the endpoints declaring the apikey extra
+// config, so the requests without a valid key are rejected before reaching the proxy stage and
+// the identity of the client is added to the context of the accepted ones
+func NewAPIKeyHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		keyCfg, ok := apikey.EndpointConfigGetter(cfg.ExtraConfig)
+		if !ok {
+			return hf(cfg, p)
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][APIKey]"
+		if _, ok := apikey.GetStore(); !ok {
+			logger.Error(logPrefix, "Rejecting all the requests:", apikey.ErrNoStore.Error())
+		}
+		auth := apikey.NewAuthenticator(keyCfg)
+		handler := hf(cfg, p)
+
+		return func(c *gin.Context) {
+			identity, err := auth.Authenticate(c.Request)
+			if err != nil {
+				logger.Debug(logPrefix, err.Error())
+				c.AbortWithStatus(apikey.StatusCode(err))
+				return
+			}
+			auth.SetClientIDHeader(c.Request.Header, identity)
+			accesslog.SetClientID(c.Request.Context(), identity.ClientID)
+			// the proxy context derives from the gin one, which only resolves string keys
+			c.Set(apikey.ContextKey, identity)
+			c.Request = c.Request.WithContext(apikey.NewContext(c.Request.Context(), identity))
+			handler(c)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/consistency"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+// NewConsistencyHandlerFactory decorates the handlers of the endpoints with backends declaring
+// primary hosts, so the requests carrying a valid routing hint are sent to them
+func NewConsistencyHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handler := hf(cfg, p)
+		if !consistency.UsesPrimaries(cfg) {
+			return handler
+		}
+		logger.Debug("[ENDPOINT: "+cfg.Endpoint+"][Consistency]", "Routing the hinted requests to the primary hosts")
+		h := consistency.GetGlobal()
+
+		return func(c *gin.Context) {
+			if h.Hinted(c.Request) {
+				// the proxy context derives from the gin one, which only resolves string keys
+				c.Set(consistency.ContextKey, true)
+				c.Request = c.Request.WithContext(consistency.WithPrimary(c.Request.Context()))
+			}
+			handler(c)
+		}
+	}
+}
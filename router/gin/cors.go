@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/router/cors"
+)
+
+// NewCORSMiddleware returns a gin middleware applying the cors policy. The preflight requests
+// are answered by the middleware and never reach the endpoints
+func NewCORSMiddleware(c *cors.CORS) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if c.Handle(ctx.Writer, ctx.Request) {
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/debugtoken"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/tracing"
+)
+
+// NewDebugTokenHandlerFactory decorates the handlers of the endpoints, so the requests carrying a
+// valid debug token get the overrides it grants. The invalid tokens are logged and ignored
+func NewDebugTokenHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handler := hf(cfg, p)
+		v, ok := debugtoken.GetGlobal()
+		if !ok {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][DebugToken]"
+
+		return func(c *gin.Context) {
+			claims, ok, err := v.FromRequest(c.Request, cfg.Endpoint)
+			if err != nil {
+				logger.Warning(logPrefix, "Ignoring the debug token:", err.Error())
+			}
+			if !ok {
+				handler(c)
+				return
+			}
+			logger.Info(logPrefix, fmt.Sprintf("Debug overrides: trace=%t no_cache=%t host=%q", claims.Trace, claims.NoCache, claims.Host))
+			// the proxy context derives from the gin one, which only resolves string keys
+			c.Set(debugtoken.ContextKey, claims)
+			ctx := debugtoken.NewContext(c.Request.Context(), claims)
+			if claims.Trace {
+				c.Set(tracing.ForceSamplingContextKey, true)
+				ctx = tracing.WithForcedSampling(ctx)
+			}
+			c.Request = c.Request.WithContext(ctx)
+			handler(c)
+		}
+	}
+}
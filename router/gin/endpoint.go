@@ -5,19 +5,28 @@ package gin
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/textproto"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/core"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/etag"
 	"github.com/luraproject/lura/v2/logging"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router"
+	"github.com/luraproject/lura/v2/router/bodylimit"
+	"github.com/luraproject/lura/v2/router/errorencoder"
+	"github.com/luraproject/lura/v2/router/forwarded"
+	"github.com/luraproject/lura/v2/router/pathparams"
+	"github.com/luraproject/lura/v2/router/redirect"
+	"github.com/luraproject/lura/v2/router/requestschema"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
-const requestParamsAsterisk string = "*"
-
 // HandlerFactory creates a handler function that adapts the gin router with the injected proxy
 type HandlerFactory func(*config.EndpointConfig, proxy.Proxy) gin.HandlerFunc
 
@@ -38,16 +47,110 @@ func CustomErrorEndpointHandler(logger logging.Logger, errF server.ToHTTPError)
 	return func(configuration *config.EndpointConfig, prxy proxy.Proxy) gin.HandlerFunc {
 		cacheControlHeaderValue := fmt.Sprintf("public, max-age=%d", int(configuration.CacheTTL.Seconds()))
 		isCacheEnabled := configuration.CacheTTL.Seconds() != 0
-		requestGenerator := NewRequest(configuration.HeadersToPass)
+		headersToPass := configuration.HeadersToPass
+		if configuration.OutputEncoding == encoding.NOOP {
+			if len(headersToPass) == 0 {
+				headersToPass = server.HeadersToSend
+			}
+			// the range requests are forwarded, so the clients can resume the downloads
+			headersToPass = config.WithRangeHeaders(headersToPass)
+		}
+		requestGenerator := newRequestBuilder(headersToPass, configuration.QueryString)
 		render := getRender(configuration)
+		negotiated := isNegotiated(configuration)
+		hasSuccessStatus := proxy.HasSuccessStatus(configuration)
 		logPrefix := "[ENDPOINT: " + configuration.Endpoint + "]"
+		encoder, hasEncoder := errorencoder.GetGlobal()
+		bodyLimit, hasBodyLimit, bodyLimitErr := bodylimit.ConfigGetter(configuration.ExtraConfig)
+		if bodyLimitErr != nil {
+			logger.Error(logPrefix, "Rejecting all the requests:", bodyLimitErr.Error())
+		}
+		validator, hasValidator, validatorErr := pathparams.New(configuration)
+		if validatorErr != nil {
+			logger.Error(logPrefix, "Rejecting all the requests:", validatorErr.Error())
+		}
+		schema, hasSchema, schemaErr := requestschema.New(configuration)
+		if schemaErr != nil {
+			logger.Error(logPrefix, "Rejecting all the requests:", schemaErr.Error())
+		}
+		tags, hasTags, tagsErr := etag.ConfigGetter(configuration.ExtraConfig)
+		if tagsErr != nil {
+			logger.Error(logPrefix, "Disabling the ETags:", tagsErr.Error())
+			hasTags = false
+		}
+		optionalParams := router.OptionalParams(configuration.Endpoint)
 
 		return func(c *gin.Context) {
-			requestCtx, cancel := context.WithTimeout(c, configuration.Timeout)
-
 			c.Header(core.KrakendHeaderName, core.KrakendHeaderValue)
 
-			response, err := prxy(requestCtx, requestGenerator(c, configuration.QueryString))
+			if hasBodyLimit {
+				err := bodyLimitErr
+				status := http.StatusInternalServerError
+				if err == nil {
+					err = bodylimit.Enforce(c.Request, bodyLimit.MaxSize)
+					status = http.StatusBadRequest
+					if t, ok := err.(bodylimit.Error); ok {
+						status = t.StatusCode()
+					}
+				}
+				if err != nil {
+					if hasEncoder {
+						encoder.Write(c, c.Writer, c.Request.URL.Path, status, err)
+					} else {
+						c.String(status, err.Error())
+					}
+					c.Abort()
+					return
+				}
+			}
+
+			if hasSchema {
+				err := schemaErr
+				status := http.StatusInternalServerError
+				if err == nil {
+					err = schema.Validate(c.Request)
+					status = requestschema.StatusCode(err)
+				}
+				if err != nil {
+					if t, ok := err.(requestschema.Error); ok && !hasEncoder {
+						c.Data(status, t.Encoding(), t.Body())
+					} else if hasEncoder {
+						encoder.Write(c, c.Writer, c.Request.URL.Path, status, err)
+					} else {
+						c.String(status, err.Error())
+					}
+					c.Abort()
+					return
+				}
+			}
+
+			request := requestGenerator(c)
+			if hasValidator {
+				err := validatorErr
+				status := http.StatusInternalServerError
+				if err == nil {
+					err = validator.Validate(request.Params)
+					status = http.StatusBadRequest
+				}
+				if err != nil {
+					if hasEncoder {
+						encoder.Write(c, c.Writer, c.Request.URL.Path, status, err)
+					} else {
+						c.String(status, err.Error())
+					}
+					c.Abort()
+					return
+				}
+			}
+			router.SetOptionalParams(request.Params, optionalParams)
+
+			requestCtx, cancel := context.WithTimeout(c, configuration.Timeout)
+			var recorder *etag.Recorder
+			if hasTags && isConditionalMethod(c.Request.Method) {
+				requestCtx, recorder = etag.NewContext(requestCtx)
+			}
+
+			response, err := prxy(requestCtx, request)
 
 			select {
 			case <-requestCtx.Done():
@@ -65,6 +168,13 @@ func CustomErrorEndpointHandler(logger logging.Logger, errF server.ToHTTPError)
 					if isCacheEnabled {
 						c.Header("Cache-Control", cacheControlHeaderValue)
 					}
+					if s := response.Metadata.StatusCode; hasSuccessStatus && isSuccessStatus(s) {
+						// the endpoint declares the status of its successful responses
+						c.Status(s)
+					}
+				} else if s := response.Metadata.StatusCode; isSuccessStatus(s) {
+					// the partial response policies set the status of the incomplete responses
+					c.Status(s)
 				}
 
 				for k, vs := range response.Metadata.Headers {
@@ -80,6 +190,12 @@ func CustomErrorEndpointHandler(logger logging.Logger, errF server.ToHTTPError)
 				logger.Error(logPrefix, err.Error())
 			}
 
+			if location, status, ok := redirect.Target(response); ok && err == nil {
+				c.Redirect(status, location)
+				cancel()
+				return
+			}
+
 			if err != nil {
 				if t, ok := err.(multiError); ok {
 					for i, errN := range t.Errors() {
@@ -92,11 +208,21 @@ func CustomErrorEndpointHandler(logger logging.Logger, errF server.ToHTTPError)
 				}
 
 				if response == nil {
+					if t, ok := err.(passThroughError); ok {
+						c.Data(t.StatusCode(), t.Encoding(), t.Body())
+						cancel()
+						return
+					}
+					status := errF(err)
 					if t, ok := err.(responseError); ok {
-						c.Status(t.StatusCode())
-					} else {
-						c.Status(errF(err))
+						status = t.StatusCode()
+					}
+					if hasEncoder {
+						encoder.Write(c, c.Writer, c.Request.URL.Path, status, err)
+						cancel()
+						return
 					}
+					c.Status(status)
 					if returnErrorMsg {
 						ErrorResponseWriter(c, err)
 					}
@@ -105,70 +231,124 @@ func CustomErrorEndpointHandler(logger logging.Logger, errF server.ToHTTPError)
 				}
 			}
 
+			if err == nil && hasSuccessStatus && response != nil && len(response.Data) == 0 && isSuccessStatus(response.Metadata.StatusCode) {
+				if response.Metadata.StatusCode == http.StatusNoContent {
+					c.Status(http.StatusNoContent)
+					c.Writer.WriteHeaderNow()
+					cancel()
+					return
+				}
+				c.Status(response.Metadata.StatusCode)
+			}
+
+			if recorder != nil && err == nil && response != nil && response.IsComplete && response.Io == nil {
+				variant := ""
+				if negotiated {
+					variant = c.GetHeader("Accept")
+				}
+				if tag, ok := responseTag(tags, recorder, response, variant); ok {
+					c.Header("ETag", tag)
+					if etag.Match(c.GetHeader("If-None-Match"), tag) {
+						if negotiated {
+							c.Writer.Header().Add("Vary", "Accept")
+						}
+						c.Status(http.StatusNotModified)
+						c.Writer.WriteHeaderNow()
+						cancel()
+						return
+					}
+				}
+			}
+
 			render(c, response)
 			cancel()
 		}
 	}
 }
 
-// NewRequest gets a request from the current gin context and the received query string
-func NewRequest(headersToSend []string) func(*gin.Context, []string) *proxy.Request {
-	if len(headersToSend) == 0 {
-		headersToSend = server.HeadersToSend
-	}
+// isSuccessStatus returns true for the 2xx statuses the partial response policies set on the
+// incomplete responses and the success statuses set on the complete ones
+func isSuccessStatus(status int) bool {
+	return status >= http.StatusOK && status < http.StatusMultipleChoices
+}
 
-	return func(c *gin.Context, queryString []string) *proxy.Request {
-		params := make(map[string]string, len(c.Params))
-		for _, param := range c.Params {
-			params[textproto.CanonicalMIMEHeaderKey(param.Key[:1])+param.Key[1:]] = param.Value
+// isConditionalMethod returns true for the methods answering the conditional requests with a 304
+func isConditionalMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// responseTag returns the tag of a complete response, reusing the digest recorded by the cache
+func responseTag(cfg etag.Config, recorder *etag.Recorder, response *proxy.Response, variant string) (string, bool) {
+	digest := recorder.Digest()
+	if digest == "" {
+		var err error
+		if digest, err = etag.Digest(response.Data); err != nil {
+			return "", false
 		}
+	}
+	return cfg.Tag(digest, variant), true
+}
 
-		headers := make(map[string][]string, 3+len(headersToSend))
+// NewRequest gets a request from the current gin context and the received query string. The
+// filter of the headers is built once, and the one of the query string params on every call
+func NewRequest(headersToSend []string) func(*gin.Context, []string) *proxy.Request {
+	headers := newHeadersFilter(headersToSend)
+	return func(c *gin.Context, queryString []string) *proxy.Request {
+		return newRequest(c, headers, config.NewParamsFilter(queryString))
+	}
+}
 
-		for _, k := range headersToSend {
-			if k == requestParamsAsterisk {
-				headers = c.Request.Header
+// newRequestBuilder returns the request builder of an endpoint, with the filters of its headers
+// and query string params built once
+func newRequestBuilder(headersToSend, queryString []string) func(*gin.Context) *proxy.Request {
+	headers := newHeadersFilter(headersToSend)
+	query := config.NewParamsFilter(queryString)
+	return func(c *gin.Context) *proxy.Request {
+		return newRequest(c, headers, query)
+	}
+}
 
-				break
-			}
+func newHeadersFilter(headersToSend []string) config.ParamsFilter {
+	if len(headersToSend) == 0 {
+		headersToSend = server.HeadersToSend
+	}
+	return config.NewHeadersFilter(headersToSend)
+}
 
-			if h, ok := c.Request.Header[textproto.CanonicalMIMEHeaderKey(k)]; ok {
-				headers[k] = h
-			}
+func newRequest(c *gin.Context, headersFilter, query config.ParamsFilter) *proxy.Request {
+	params := make(map[string]string, len(c.Params))
+	for _, param := range c.Params {
+		value := param.Value
+		if strings.Contains(c.FullPath(), "*"+param.Key) {
+			// the catch-all params include the leading slash
+			value = strings.TrimPrefix(value, "/")
 		}
+		params[textproto.CanonicalMIMEHeaderKey(param.Key[:1])+param.Key[1:]] = value
+	}
+
+	headers := headersFilter.Filter(c.Request.Header)
 
+	if f, ok := forwarded.FromRequest(c.Request); ok {
+		f.SetHeaders(headers, c.Request)
+	} else {
 		headers["X-Forwarded-For"] = []string{c.ClientIP()}
 		headers["X-Forwarded-Host"] = []string{c.Request.Host}
-		// if User-Agent is not forwarded using headersToSend, we set
-		// the KrakenD router User Agent value
-		if _, ok := headers["User-Agent"]; !ok {
-			headers["User-Agent"] = server.UserAgentHeaderValue
-		} else {
-			headers["X-Forwarded-Via"] = server.UserAgentHeaderValue
-		}
-
-		query := make(map[string][]string, len(queryString))
-		queryValues := c.Request.URL.Query()
-		for i := range queryString {
-			if queryString[i] == requestParamsAsterisk {
-				query = c.Request.URL.Query()
-
-				break
-			}
-
-			if v, ok := queryValues[queryString[i]]; ok && len(v) > 0 {
-				query[queryString[i]] = v
-			}
-		}
+	}
+	// if User-Agent is not forwarded using headersToSend, we set
+	// the KrakenD router User Agent value
+	if _, ok := headers["User-Agent"]; !ok {
+		headers["User-Agent"] = server.UserAgentHeaderValue
+	} else {
+		headers["X-Forwarded-Via"] = server.UserAgentHeaderValue
+	}
 
-		return &proxy.Request{
-			Path:    c.Request.URL.Path,
-			Method:  c.Request.Method,
-			Query:   query,
-			Body:    c.Request.Body,
-			Params:  params,
-			Headers: headers,
-		}
+	return &proxy.Request{
+		Path:    c.Request.URL.Path,
+		Method:  c.Request.Method,
+		Query:   query.Filter(c.Request.URL.Query()),
+		Body:    c.Request.Body,
+		Params:  params,
+		Headers: headers,
 	}
 }
 
@@ -182,6 +362,14 @@ type responseError interface {
 	StatusCode() int
 }
 
+// passThroughError is a responseError carrying the response of a backend passing its failures
+// through to the clients
+type passThroughError interface {
+	responseError
+	Encoding() string
+	Body() []byte
+}
+
 type multiError interface {
 	error
 	Errors() []error
@@ -19,6 +19,7 @@ import (
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/logging"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/redirect"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
@@ -404,3 +405,64 @@ func ctxMiddleware(c *gin.Context) {
 		c.Set(k, v)
 	}
 }
+
+func TestEndpointHandler_successStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	endpoint := &config.EndpointConfig{
+		Method:  "POST",
+		Timeout: time.Second,
+		Backend: []*config.Backend{{}},
+		ExtraConfig: config.ExtraConfig{
+			proxy.Namespace: map[string]interface{}{
+				"success_status": map[string]interface{}{"status": 201.0, "empty_status": 204.0},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		data   map[string]interface{}
+		status int
+		body   string
+	}{
+		{map[string]interface{}{"id": 42}, http.StatusCreated, `{"id":42}`},
+		{map[string]interface{}{}, http.StatusNoContent, ""},
+	} {
+		data := tc.data
+		p := proxy.NewSuccessStatusMiddleware(logging.NoOp, endpoint)(func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+			return &proxy.Response{Data: data, IsComplete: true}, nil
+		})
+		e := gin.New()
+		e.POST("/", EndpointHandler(endpoint, p))
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest("POST", "/", http.NoBody))
+		if w.Code != tc.status || w.Body.String() != tc.body {
+			t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestEndpointHandler_redirect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	endpoint := &config.EndpointConfig{
+		Endpoint:    "/users/:id",
+		Method:      "GET",
+		Timeout:     time.Second,
+		QueryString: []string{"page"},
+		ExtraConfig: config.ExtraConfig{redirect.Namespace: map[string]interface{}{
+			"location":       "/v2/users/{id}",
+			"status":         301,
+			"preserve_query": true,
+		}},
+	}
+	p, ok, err := redirect.New(endpoint)
+	if !ok || err != nil {
+		t.Fatalf("unexpected result %v %v", ok, err)
+	}
+	e := gin.New()
+	e.GET("/users/:id", EndpointHandler(endpoint, p))
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/users/42?page=2&other=1", http.NoBody))
+
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/v2/users/42?page=2" {
+		t.Errorf("unexpected response %d %s", w.Code, w.Header().Get("Location"))
+	}
+}
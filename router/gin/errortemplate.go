@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/errortemplate"
+)
+
+// NewErrorTemplateHandlerFactory decorates the handlers of the endpoints with error templates, so
+// the bodies of the error responses generated by the gateway are replaced with the rendered
+// templates. The responses of the backends are never replaced
+func NewErrorTemplateHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		ts, ok, err := errortemplate.EndpointTemplates(cfg)
+		if !ok {
+			return hf(cfg, p)
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][ErrorTemplate]"
+		if err != nil {
+			logger.Error(logPrefix, "Keeping the default error bodies:", err.Error())
+			return hf(cfg, p)
+		}
+		handler := hf(cfg, markingProxy(p))
+
+		return func(c *gin.Context) {
+			m := &errortemplate.Marker{}
+			// the proxies receive the gin context, only resolving the values with string keys
+			c.Set(errortemplate.ContextKey, m)
+
+			w := c.Writer
+			tw := &templateWriter{ResponseWriter: w, templates: ts, marker: m}
+			c.Writer = tw
+			handler(c)
+			c.Writer = w
+
+			status := w.Status()
+			if !tw.intercepted && (w.Written() || !tw.hold()) {
+				return
+			}
+			body, contentType, _ := ts.Render(errortemplate.Data{
+				Status:     status,
+				StatusText: http.StatusText(status),
+				Message:    errortemplate.Message(tw.buf.Bytes(), status),
+				Endpoint:   cfg.Endpoint,
+				Method:     c.Request.Method,
+				Path:       c.Request.URL.Path,
+			})
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(status)
+			w.Write(body)
+		}
+	}
+}
+
+// markingProxy flags the requests getting a response from the proxy, so their bodies are kept
+func markingProxy(p proxy.Proxy) proxy.Proxy {
+	return func(ctx context.Context, r *proxy.Request) (*proxy.Response, error) {
+		resp, err := p(ctx, r)
+		if resp != nil && (err == nil || len(resp.Data) > 0) {
+			errortemplate.MarkProxied(ctx)
+		}
+		return resp, err
+	}
+}
+
+// templateWriter holds back the error responses with a template, so their bodies can be
+// replaced. The gin writers send the status lazily, so the decision is taken on the first write
+type templateWriter struct {
+	gin.ResponseWriter
+	templates   errortemplate.Templates
+	marker      *errortemplate.Marker
+	intercepted bool
+	buf         bytes.Buffer
+}
+
+func (w *templateWriter) hold() bool {
+	if w.ResponseWriter.Written() || w.marker.Proxied() {
+		return false
+	}
+	_, ok := w.templates[w.ResponseWriter.Status()]
+	return ok
+}
+
+func (w *templateWriter) WriteHeaderNow() {
+	if w.intercepted || w.hold() {
+		w.intercepted = true
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *templateWriter) Write(b []byte) (int, error) {
+	if w.intercepted || w.hold() {
+		w.intercepted = true
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *templateWriter) WriteString(s string) (int, error) {
+	if w.intercepted || w.hold() {
+		w.intercepted = true
+		return w.buf.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *templateWriter) Written() bool {
+	return w.intercepted || w.ResponseWriter.Written()
+}
+
+func (w *templateWriter) Flush() {
+	if w.intercepted {
+		return
+	}
+	w.ResponseWriter.Flush()
+}
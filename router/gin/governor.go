@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/governor"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+// NewGovernorHandlerFactory decorates the handlers of the endpoints with the governor of the
+// service, if any, so the requests arriving while the gateway is at its ceilings get a 503
+// Service Unavailable and the bytes of the admitted request bodies are reserved as they are read
+func NewGovernorHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handler := hf(cfg, p)
+		g, ok := governor.GetGlobal()
+		if !ok {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][Governor]"
+
+		return func(c *gin.Context) {
+			t, err := g.Admit()
+			if err != nil {
+				logger.Debug(logPrefix, err.Error())
+				c.Header("Retry-After", "1")
+				c.AbortWithStatus(http.StatusServiceUnavailable)
+				return
+			}
+			defer t.Done()
+			ctx := governor.NewContext(c.Request.Context(), t)
+			c.Request = c.Request.WithContext(ctx)
+			c.Request.Body = governor.NewReader(ctx, c.Request.Body)
+			handler(c)
+		}
+	}
+}
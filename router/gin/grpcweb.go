@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/grpcweb"
+)
+
+// NewGRPCWebHandlerFactory decorates the handlers of the endpoints declaring a gRPC method, so
+// they accept the requests of the gRPC-Web clients too. The responses of the gRPC-Web requests
+// are held back until the endpoint completes, so they can be transcoded
+func NewGRPCWebHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handler := hf(cfg, p)
+		gwCfg, ok, err := grpcweb.ConfigGetter(cfg.ExtraConfig)
+		if !ok {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][gRPC-Web]"
+		var t *grpcweb.Transcoder
+		if err == nil {
+			t, err = grpcweb.New(gwCfg)
+		}
+		if err != nil {
+			logger.Error(logPrefix, "Disabling the gRPC-Web transcoding:", err.Error())
+			return handler
+		}
+		logger.Debug(logPrefix, "Transcoding the gRPC-Web requests of the method", gwCfg.Method)
+
+		return func(c *gin.Context) {
+			if !grpcweb.IsGRPCWeb(c.Request) {
+				handler(c)
+				return
+			}
+			text := grpcweb.IsText(c.Request)
+			if err := t.DecodeRequest(c.Request); err != nil {
+				t.WriteResponse(c.Writer, http.StatusBadRequest, []byte(err.Error()), text)
+				c.Abort()
+				return
+			}
+			w := c.Writer
+			bw := &bufferedWriter{ResponseWriter: w}
+			c.Writer = bw
+			handler(c)
+			c.Writer = w
+			t.WriteResponse(w, bw.Status(), bw.buf.Bytes(), text)
+		}
+	}
+}
+
+// bufferedWriter holds back the whole response, so it can be replaced. The headers are set on
+// the wrapped writer
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status  int
+	written bool
+	buf     bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if !w.written && code > 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() { w.written = true }
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.buf.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.buf.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int { return w.buf.Len() }
+
+func (w *bufferedWriter) Written() bool { return w.written }
+
+func (w *bufferedWriter) Flush() {}
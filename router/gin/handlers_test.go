@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDefaultHandlerFactoryDecorators(t *testing.T) {
+	decorators := DefaultHandlerFactoryDecorators()
+	expected := []HandlerFactoryDecorator{
+		NewRequestIDHandlerFactory,
+		NewDebugTokenHandlerFactory,
+		NewAccessLogHandlerFactory,
+		NewRecorderHandlerFactory,
+		NewTracingHandlerFactory,
+		NewGRPCWebHandlerFactory,
+		NewMetricsHandlerFactory,
+		NewGovernorHandlerFactory,
+		NewLoadSheddingHandlerFactory,
+		NewErrorTemplateHandlerFactory,
+		NewIPFilterHandlerFactory,
+		NewSecureHeadersHandlerFactory,
+		NewAPIKeyHandlerFactory,
+		NewJWTHandlerFactory,
+		NewQuotaHandlerFactory,
+		NewConsistencyHandlerFactory,
+	}
+
+	// the order is resolved from the constraints, not from the declaration order
+	defs := DefaultDecoratorDefinitions()
+	for i, j := 0, len(defs)-1; i < j; i, j = i+1, j-1 {
+		defs[i], defs[j] = defs[j], defs[i]
+	}
+	reversed, err := OrderDecorators(defs...)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, ds := range [][]HandlerFactoryDecorator{decorators, reversed} {
+		if len(ds) != len(expected) {
+			t.Errorf("unexpected number of decorators: %d", len(ds))
+			continue
+		}
+		for i, d := range ds {
+			if reflect.ValueOf(d).Pointer() != reflect.ValueOf(expected[i]).Pointer() {
+				t.Errorf("unexpected decorator at %d", i)
+			}
+		}
+	}
+
+	decorators[0] = nil
+	if DefaultHandlerFactoryDecorators()[0] == nil {
+		t.Error("the default decorators were modified")
+	}
+}
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/ipfilter"
+)
+
+// NewIPFilterHandlerFactory decorates the handlers of the endpoints filtered by the ipfilter
+// rules of the endpoint or the service, so the requests from the rejected addresses get a 403
+// Forbidden before reaching any other stage. The client IP is resolved with the trusted proxies
+// of the ipfilter config, not with the gin ones
+func NewIPFilterHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handler := hf(cfg, p)
+		f, ok, err := ipfilter.EndpointFilter(cfg)
+		if !ok {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][IPFilter]"
+		if err != nil {
+			logger.Error(logPrefix, "Rejecting all the requests:", err.Error())
+			return func(c *gin.Context) {
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}
+
+		return func(c *gin.Context) {
+			if err := f.Check(c.Request); err != nil {
+				logger.Debug(logPrefix, err.Error(), c.Request.RemoteAddr)
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			handler(c)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/jwt"
+)
+
+// NewJWTHandlerFactory decorates the handlers of the endpoints declaring the jwt extra config, so
+// the requests without a valid token are rejected before reaching the proxy stage. The
+// endpoints with an invalid jwt config reject all the requests
+func NewJWTHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		jwtCfg, ok := jwt.ConfigGetter(cfg.ExtraConfig)
+		if !ok {
+			return hf(cfg, p)
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][JWT]"
+		validator, err := jwt.NewValidator(jwtCfg, nil)
+		if err != nil {
+			logger.Error(logPrefix, "Rejecting all the requests:", err.Error())
+			return func(c *gin.Context) {
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}
+		handler := hf(cfg, p)
+
+		return func(c *gin.Context) {
+			claims, err := validator.ValidateRequest(c.Request)
+			if err != nil {
+				logger.Debug(logPrefix, err.Error())
+				jwt.Unauthorized(c.Writer, err)
+				c.Abort()
+				return
+			}
+			jwt.SetHeaders(c.Request.Header, claims, jwtCfg.Headers)
+			for _, p := range jwtCfg.Params {
+				if v, ok := claims.Value(p.Claim); ok && p.Name != "" {
+					c.Params = append(c.Params, gin.Param{Key: p.Name, Value: v})
+				}
+			}
+			c.Request = c.Request.WithContext(jwt.NewContext(c.Request.Context(), claims))
+			handler(c)
+		}
+	}
+}
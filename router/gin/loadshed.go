@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/loadshed"
+)
+
+// NewLoadSheddingHandlerFactory decorates the handlers of the endpoints with the admission
+// controller of the service, if any, so the requests of the endpoints with a priority below
+// the current load get a 503 Service Unavailable
+func NewLoadSheddingHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handler := hf(cfg, p)
+		ctrl, ok := loadshed.GetGlobal()
+		if !ok {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][LoadShedding]"
+		priority, err := loadshed.EndpointPriority(cfg)
+		if err != nil {
+			logger.Error(logPrefix, "Using the normal priority:", err.Error())
+		}
+		logger.Debug(logPrefix, "Priority:", priority)
+
+		return func(c *gin.Context) {
+			done, err := ctrl.Admit(priority)
+			if err != nil {
+				logger.Debug(logPrefix, err.Error())
+				c.Header("Retry-After", "1")
+				c.AbortWithStatus(http.StatusServiceUnavailable)
+				return
+			}
+			defer done()
+			handler(c)
+		}
+	}
+}
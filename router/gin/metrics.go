@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/metrics"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+// NewMetricsHandlerFactory decorates the handlers of the endpoints, so the requests are counted
+// by status code and their latencies recorded. It does nothing when the service does not declare
+// the metrics
+func NewMetricsHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handler := hf(cfg, p)
+		m, ok := metrics.GetGlobal()
+		if !ok {
+			return handler
+		}
+		logger.Debug("[ENDPOINT: "+cfg.Endpoint+"][Metrics]", "Instrumenting the requests")
+
+		return func(c *gin.Context) {
+			start := time.Now()
+			handler(c)
+			m.ObserveRequest(cfg.Endpoint, c.Request.Method, c.Writer.Status(), time.Since(start))
+		}
+	}
+}
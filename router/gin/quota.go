@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/quota"
+)
+
+// NewQuotaHandlerFactory decorates the handlers of the endpoints, so the requests of the clients
+// exceeding their quotas are rejected with a 429 before reaching the proxy stage. It must wrap the
+// handlers identifying the clients, like the API key and the JWT ones
+func NewQuotaHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handler := hf(cfg, p)
+		q, ok := quota.GetGlobal()
+		if !ok || quota.IsDisabled(cfg.ExtraConfig) {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][Quota]"
+
+		return func(c *gin.Context) {
+			client, ok := quota.Client(c.Request.Context())
+			if !ok {
+				handler(c)
+				return
+			}
+			status, err := q.Consume(c.Request.Context(), client)
+			if _, exceeded := err.(quota.ExceededError); exceeded {
+				logger.Debug(logPrefix, err.Error())
+				quota.SetHeaders(c.Writer.Header(), status, true)
+				c.AbortWithStatus(http.StatusTooManyRequests)
+				return
+			}
+			if err != nil {
+				logger.Error(logPrefix, "Unable to track the usage:", err.Error())
+			}
+			quota.SetHeaders(c.Writer.Header(), status, false)
+			handler(c)
+		}
+	}
+}
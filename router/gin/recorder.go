@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"bytes"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/recorder"
+)
+
+// NewRecorderHandlerFactory decorates the handlers of the endpoints, so the sampled requests are
+// recorded with their responses. It does nothing when the service does not declare a recorder
+// or the recorder skips the endpoint
+func NewRecorderHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handler := hf(cfg, p)
+		rc, ok := recorder.GetGlobal()
+		if !ok || !rc.Records(cfg.Endpoint) {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][Recorder]"
+
+		return func(c *gin.Context) {
+			if !rc.Sampled() {
+				handler(c)
+				return
+			}
+			rec := rc.NewRecord(c.Request, cfg.Endpoint)
+			rw := &recorderWriter{ResponseWriter: c.Writer, max: int(rc.MaxBodySize()) + 1}
+			c.Writer = rw
+			c.Request = c.Request.WithContext(recorder.NewContext(c.Request.Context(), rec))
+			handler(c)
+			c.Writer = rw.ResponseWriter
+
+			rc.SetResponse(rec, rw.Status(), rw.Header(), rw.body.Bytes())
+			if err := rc.Write(rec); err != nil {
+				logger.Error(logPrefix, "Unable to write the record:", err.Error())
+			}
+		}
+	}
+}
+
+// recorderWriter keeps the first bytes of the response
+type recorderWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+	max  int
+}
+
+func (w *recorderWriter) Write(b []byte) (int, error) {
+	w.keep(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recorderWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *recorderWriter) keep(b []byte) {
+	if left := w.max - w.body.Len(); left > 0 {
+		if len(b) < left {
+			left = len(b)
+		}
+		w.body.Write(b[:left])
+	}
+}
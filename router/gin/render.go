@@ -11,6 +11,7 @@ import (
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/encoding"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/negotiate"
 )
 
 // Render defines the signature of the functions to be use for the final response
@@ -31,13 +32,14 @@ var (
 		"json-collection": jsonCollectionRender,
 		XML:               xmlRender,
 		YAML:              yamlRender,
+		negotiate.MsgPack: msgpackRender,
 	}
 )
 
 func init() {
 	// the negotiated render must be registered at the init function in order
 	// to avoid a cyclical dependency
-	renderRegister[NEGOTIATE] = negotiatedRender
+	renderRegister[NEGOTIATE] = newNegotiatedRender(negotiate.DefaultEncodings, jsonRender)
 }
 
 // RegisterRender allows clients to register their custom renders
@@ -53,11 +55,18 @@ func getRender(cfg *config.EndpointConfig) Render {
 		fallback = getWithFallback(cfg.Backend[0].Encoding, fallback)
 	}
 
-	if cfg.OutputEncoding == "" {
-		return fallback
+	render := fallback
+	if cfg.OutputEncoding != "" {
+		render = getWithFallback(cfg.OutputEncoding, fallback)
 	}
 
-	return getWithFallback(cfg.OutputEncoding, fallback)
+	if n, ok, err := negotiate.ConfigGetter(cfg.ExtraConfig); ok && err == nil {
+		if cfg.OutputEncoding == NEGOTIATE {
+			render = jsonRender
+		}
+		return newNegotiatedRender(n.Encodings, render)
+	}
+	return render
 }
 
 func getWithFallback(key string, fallback Render) Render {
@@ -70,17 +79,34 @@ func getWithFallback(key string, fallback Render) Render {
 	return r
 }
 
-func negotiatedRender(c *gin.Context, response *proxy.Response) {
-	switch c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain, gin.MIMEXML) {
-	case gin.MIMEXML:
-		getWithFallback(XML, jsonRender)(c, response)
-	case gin.MIMEPlain:
-		getWithFallback(YAML, jsonRender)(c, response)
-	default:
-		getWithFallback(encoding.JSON, jsonRender)(c, response)
+// newNegotiatedRender returns a render choosing among the renders of the encodings with the
+// Accept header of the request, or using the fallback one if the request accepts none of them
+func newNegotiatedRender(encodings []string, fallback Render) Render {
+	return func(c *gin.Context, response *proxy.Response) {
+		c.Writer.Header().Add("Vary", "Accept")
+		accept := c.GetHeader("Accept")
+		if accept == "" {
+			fallback(c, response)
+			return
+		}
+		name, ok := negotiate.Negotiate(accept, encodings)
+		if !ok {
+			fallback(c, response)
+			return
+		}
+		getWithFallback(name, fallback)(c, response)
 	}
 }
 
+// isNegotiated returns true if the render of the endpoint depends on the Accept header
+func isNegotiated(cfg *config.EndpointConfig) bool {
+	if cfg.OutputEncoding == NEGOTIATE {
+		return true
+	}
+	_, ok, err := negotiate.ConfigGetter(cfg.ExtraConfig)
+	return ok && err == nil
+}
+
 func stringRender(c *gin.Context, response *proxy.Response) {
 	status := c.Writer.Status()
 
@@ -107,7 +133,7 @@ func jsonRender(c *gin.Context, response *proxy.Response) {
 		c.JSON(status, emptyResponse)
 		return
 	}
-	c.JSON(status, response.Data)
+	c.Render(status, engineJSON{Data: response.Data})
 }
 
 func jsonCollectionRender(c *gin.Context, response *proxy.Response) {
@@ -121,7 +147,29 @@ func jsonCollectionRender(c *gin.Context, response *proxy.Response) {
 		c.JSON(status, []struct{}{})
 		return
 	}
-	c.JSON(status, col)
+	c.Render(status, engineJSON{Data: col})
+}
+
+var jsonContentType = []string{"application/json; charset=utf-8"}
+
+// engineJSON is the gin json render encoding the data with the json engine selected in the
+// encoding package
+type engineJSON struct {
+	Data interface{}
+}
+
+// Render implements the render.Render interface
+func (r engineJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return encoding.WriteJSON(w, r.Data)
+}
+
+// WriteContentType implements the render.Render interface
+func (engineJSON) WriteContentType(w http.ResponseWriter) {
+	header := w.Header()
+	if val := header["Content-Type"]; len(val) == 0 {
+		header["Content-Type"] = jsonContentType
+	}
 }
 
 func xmlRender(c *gin.Context, response *proxy.Response) {
@@ -147,6 +195,19 @@ func yamlRender(c *gin.Context, response *proxy.Response) {
 	c.YAML(status, response.Data)
 }
 
+func msgpackRender(c *gin.Context, response *proxy.Response) {
+	status := c.Writer.Status()
+	var data interface{} = map[string]interface{}{}
+	if response != nil {
+		data = response.Data
+	}
+	c.Header("Content-Type", "application/msgpack")
+	c.Status(status)
+	if err := negotiate.WriteMsgPack(c.Writer, data); err != nil {
+		c.Error(err)
+	}
+}
+
 func noopRender(c *gin.Context, response *proxy.Response) {
 	if response == nil {
 		c.Status(http.StatusInternalServerError)
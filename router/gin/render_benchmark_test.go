@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func BenchmarkRender_json(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+	for _, size := range []int{5, 50, 500} {
+		data := make(map[string]interface{}, size)
+		for i := 0; i < size; i++ {
+			data[fmt.Sprintf("key%d", i)] = map[string]interface{}{"supu": i, "tupu": "some text"}
+		}
+		response := &proxy.Response{Data: data, IsComplete: true}
+		b.Run(fmt.Sprintf("%d keys", size), func(b *testing.B) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w.Body.Reset()
+				jsonRender(c, response)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/encoding"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/negotiate"
 )
 
 func TestRender_Negotiated_ok(t *testing.T) {
@@ -534,3 +535,46 @@ func TestRender_noop_nilResponse(t *testing.T) {
 		t.Error("Unexpected status code:", w.Result().StatusCode)
 	}
 }
+
+func TestRender_negotiatedEncodings(t *testing.T) {
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{
+			IsComplete: true,
+			Data:       map[string]interface{}{"id": 42},
+		}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Timeout:        time.Second,
+		OutputEncoding: encoding.JSON,
+		ExtraConfig: config.ExtraConfig{
+			negotiate.Namespace: map[string]interface{}{"encodings": []interface{}{"yaml", "msgpack"}},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.GET("/_gin_endpoint", EndpointHandler(endpoint, p))
+
+	for _, testData := range [][]string{
+		{"none", "", "application/json; charset=utf-8", `{"id":42}`},
+		{"json", "application/json", "application/json; charset=utf-8", `{"id":42}`},
+		{"wildcard", "*/*", "application/x-yaml; charset=utf-8", "id: 42\n"},
+		{"msgpack", "application/x-yaml;q=0.5, application/msgpack", "application/msgpack", "\x81\xa2id\x2a"},
+	} {
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/_gin_endpoint", http.NoBody)
+		req.Header.Set("Accept", testData[1])
+
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+
+		if w.Result().Header.Get("Content-Type") != testData[2] {
+			t.Error(testData[0], "Content-Type error:", w.Result().Header.Get("Content-Type"))
+		}
+		if w.Result().Header.Get("Vary") != "Accept" {
+			t.Error(testData[0], "Vary error:", w.Result().Header.Get("Vary"))
+		}
+		if content := w.Body.String(); content != testData[3] {
+			t.Error(testData[0], fmt.Sprintf("Unexpected body: %q\nexpected: %q", content, testData[3]))
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/requestid"
+)
+
+// NewRequestIDHandlerFactory decorates the handlers of the endpoints, so every request gets an
+// id, stored in its context and added to the response headers. It does nothing when the service
+// does not declare the request ids
+func NewRequestIDHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handler := hf(cfg, p)
+		g, ok := requestid.GetGlobal()
+		if !ok {
+			return handler
+		}
+		logger.Debug("[ENDPOINT: "+cfg.Endpoint+"][RequestID]", "Identifying the requests with the header", g.Header())
+
+		return func(c *gin.Context) {
+			id := g.ID(c.Request.Header.Get(g.Header()))
+			c.Header(g.Header(), id)
+			// the proxy context derives from the gin one, which only resolves string keys
+			c.Set(requestid.ContextKey, id)
+			c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+			handler(c)
+		}
+	}
+}
@@ -8,17 +8,45 @@ package gin
 import (
 	"context"
 	"net/http"
-	"sort"
 	"strings"
 	"sync"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/luraproject/lura/v2/admin"
+	"github.com/luraproject/lura/v2/budget"
 	"github.com/luraproject/lura/v2/config"
-	"github.com/luraproject/lura/v2/core"
+	"github.com/luraproject/lura/v2/consistency"
+	"github.com/luraproject/lura/v2/debugtoken"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/governor"
 	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/masking"
+	"github.com/luraproject/lura/v2/metaheaders"
+	"github.com/luraproject/lura/v2/metrics"
+	"github.com/luraproject/lura/v2/openapi"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/quota"
+	"github.com/luraproject/lura/v2/recorder"
+	"github.com/luraproject/lura/v2/requestid"
 	"github.com/luraproject/lura/v2/router"
+	"github.com/luraproject/lura/v2/router/accesslog"
+	"github.com/luraproject/lura/v2/router/apikey"
+	"github.com/luraproject/lura/v2/router/cors"
+	"github.com/luraproject/lura/v2/router/debug"
+	"github.com/luraproject/lura/v2/router/errorencoder"
+	"github.com/luraproject/lura/v2/router/errortemplate"
+	"github.com/luraproject/lura/v2/router/forwarded"
+	"github.com/luraproject/lura/v2/router/health"
+	"github.com/luraproject/lura/v2/router/ipfilter"
+	"github.com/luraproject/lura/v2/router/loadshed"
+	"github.com/luraproject/lura/v2/router/methodoverride"
+	"github.com/luraproject/lura/v2/router/redirect"
+	"github.com/luraproject/lura/v2/router/secure"
+	"github.com/luraproject/lura/v2/router/static"
+	"github.com/luraproject/lura/v2/sd/healthcheck"
+	"github.com/luraproject/lura/v2/telemetry"
+	"github.com/luraproject/lura/v2/tracing"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
@@ -40,11 +68,12 @@ type Config struct {
 // DefaultFactory returns a gin router factory with the injected proxy factory and logger.
 // It also uses a default gin router and the default HandlerFactory
 func DefaultFactory(proxyFactory proxy.Factory, logger logging.Logger) router.Factory {
+	logger = logging.Module(logger, "router")
 	return NewFactory(
 		Config{
 			Engine:         gin.Default(),
 			Middlewares:    []gin.HandlerFunc{},
-			HandlerFactory: EndpointHandler,
+			HandlerFactory: NewDefaultHandlerFactory(EndpointHandler, logger),
 			ProxyFactory:   proxyFactory,
 			Logger:         logger,
 			RunServer:      server.RunServer,
@@ -54,6 +83,7 @@ func DefaultFactory(proxyFactory proxy.Factory, logger logging.Logger) router.Fa
 
 // NewFactory returns a gin router factory with the injected configuration
 func NewFactory(cfg Config) router.Factory {
+	cfg.Logger = logging.Module(cfg.Logger, "router")
 	return factory{cfg}
 }
 
@@ -98,31 +128,221 @@ func (r ginRouter) Run(cfg config.ServiceConfig) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	server.InitHTTPDefaultTransport(cfg)
-
-	r.registerEndpointsAndMiddlewares(cfg)
+	var handler http.Handler
+	router.WithRegistries(func() {
+		handler = r.build(cfg)
+	})
 
 	r.cfg.Logger.Info("[SERVICE: Gin] Listening on port:", cfg.Port)
-	if err := r.runServerF(r.ctx, cfg, r.cfg.Engine.Handler()); err != nil && err != http.ErrServerClosed {
+	if err := r.runServerF(r.ctx, cfg, handler); err != nil && err != http.ErrServerClosed {
 		r.cfg.Logger.Error(logPrefix, err.Error())
 	}
 
 	r.cfg.Logger.Info(logPrefix, "Router execution ended")
 }
 
+// build registers the components declared in the service config and returns the handler tree of
+// the router, capturing them. It must be called holding the lock of the registries
+func (r ginRouter) build(cfg config.ServiceConfig) http.Handler {
+	server.InitHTTPDefaultTransport(cfg)
+
+	r.registerEndpointsAndMiddlewares(cfg)
+
+	handler := r.cfg.Engine.Handler()
+	if o, ok, err := methodoverride.FromConfig(cfg); err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the method override:", err.Error())
+	} else if ok {
+		// the method is overridden before the engine routes the request
+		handler = o.Handler(handler)
+	}
+	// the requests carry the resolver of the router, so it is used after another router
+	// registers its own
+	resolver, _ := forwarded.GetGlobal()
+	return forwarded.Handler(resolver, handler)
+}
+
+// closeOnDone closes the components registered by the router holding outputs or background
+// workers when its context is done, so they are released when the router is replaced by a
+// reload without closing the ones of the other routers
+func (r ginRouter) closeOnDone() {
+	l, hasLog := accesslog.GetGlobal()
+	rc, hasRecorder := recorder.GetGlobal()
+	t, hasTracer := tracing.GetGlobal()
+	if r.ctx.Done() == nil || !hasLog && !hasRecorder && !hasTracer {
+		return
+	}
+	go func() {
+		<-r.ctx.Done()
+		if hasLog {
+			l.Close()
+		}
+		if hasRecorder {
+			rc.Close()
+		}
+		if hasTracer {
+			t.Close()
+		}
+	}()
+}
+
 func (r ginRouter) registerEndpointsAndMiddlewares(cfg config.ServiceConfig) {
+	if hs, ok := secure.Register(cfg); ok {
+		r.cfg.Engine.Use(NewSecureHeadersMiddleware(hs))
+	}
+	if c, ok, err := cors.FromConfig(cfg); err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the CORS policy:", err.Error())
+	} else if ok {
+		// the engine middlewares also run before the 404 and 405 handlers
+		r.cfg.Engine.Use(NewCORSMiddleware(c))
+	}
+
 	if cfg.Debug {
-		r.cfg.Engine.Any("/__debug/*param", DebugHandler(r.cfg.Logger))
+		r.cfg.Engine.Any("/__debug/*param", gin.WrapH(debug.NewHandler(cfg, "/__debug/*param", r.cfg.Logger)))
 	}
 
 	if cfg.Echo {
 		r.cfg.Engine.Any("/__echo/*param", EchoHandler())
 	}
 
+	for _, w := range cfg.NamespaceWarnings() {
+		r.cfg.Logger.Warning(logPrefix, w.Error())
+	}
+
+	if ok, err := encoding.SetJSONEngineFromExtraConfig(cfg.ExtraConfig); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to set the json engine:", err.Error())
+	}
+
+	if ok, err := errortemplate.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to parse the error templates:", err.Error())
+	}
+
+	if ok, err := errorencoder.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the error encoder:", err.Error())
+	}
+
+	if ok, err := ipfilter.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the IP filter:", err.Error())
+	}
+
+	if ok, err := apikey.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the API key store:", err.Error())
+	}
+
+	if ok, err := masking.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to register the masking profiles:", err.Error())
+	}
+
+	if ok, err := budget.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to register the budget:", err.Error())
+	}
+
+	if ok, err := quota.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to register the quotas:", err.Error())
+	}
+
+	if ok, err := metaheaders.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to register the meta headers:", err.Error())
+	}
+
+	if ok, err := consistency.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the consistency hints:", err.Error())
+	}
+
+	if ok, err := forwarded.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the forwarded headers resolver:", err.Error())
+	}
+
+	if ok, err := proxy.RegisterBodyBuffer(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to set the max size of the buffered bodies:", err.Error())
+	}
+
+	if ok, err := loadshed.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the load shedding controller:", err.Error())
+	}
+
+	if ok, err := governor.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the governor:", err.Error())
+	}
+
+	if ok, err := debugtoken.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the debug token verifier:", err.Error())
+	}
+
+	if ok, err := tracing.Register(cfg, r.cfg.Logger); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the tracer:", err.Error())
+	}
+
+	if _, ok, err := metrics.Register(r.ctx, cfg, r.cfg.Logger); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to expose the metrics:", err.Error())
+	}
+
+	if _, ok, err := admin.Register(r.ctx, cfg, r.cfg.Logger); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to expose the admin API:", err.Error())
+	}
+
+	if ok, err := accesslog.RegisterWithLogger(cfg, r.cfg.Logger); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the access log:", err.Error())
+	}
+
+	if ok, err := recorder.RegisterWithLogger(cfg, r.cfg.Logger); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the recorder:", err.Error())
+	}
+
+	if ok, err := requestid.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the request ids:", err.Error())
+	}
+
+	if path, ok := healthcheck.Register(cfg); ok {
+		r.cfg.Logger.Debug(logPrefix, "Exposing the health of the backends at", path)
+		r.cfg.Engine.GET(path, gin.WrapH(healthcheck.Handler()))
+	}
+
+	if t, ok := telemetry.Register(cfg); ok {
+		r.cfg.Logger.Debug(logPrefix, "Exposing the telemetry snapshots at", t.Path)
+		r.cfg.Engine.GET(t.Path, gin.WrapH(telemetry.Handler(telemetry.DefaultCollector())))
+	}
+
+	if o, ok := openapi.Register(cfg); ok {
+		r.cfg.Logger.Debug(logPrefix, "Exposing the OpenAPI document at", o.Path)
+		r.cfg.Engine.GET(o.Path, gin.WrapH(openapi.Handler(cfg, o)))
+	}
+
+	if hc, ok, err := health.Register(cfg); ok {
+		if err != nil {
+			r.cfg.Logger.Error(logPrefix, "Unable to parse the health config:", err.Error())
+		}
+		r.cfg.Engine.GET(hc.LivePath, gin.WrapH(health.LiveHandler()))
+		r.cfg.Engine.GET(hc.ReadyPath, gin.WrapH(health.ReadyHandler()))
+	}
+
+	if sc, ok, err := static.ConfigGetter(cfg.ExtraConfig); ok {
+		if err != nil {
+			r.cfg.Logger.Error(logPrefix, "Unable to register the static content:", err.Error())
+			sc.Routes = nil
+		}
+		for _, route := range sc.Routes {
+			pattern := route.Path
+			if route.IsDir() {
+				pattern += "/*filepath"
+			}
+			h := gin.WrapH(static.Handler(route))
+			r.cfg.Engine.GET(pattern, h)
+			r.cfg.Engine.HEAD(pattern, h)
+		}
+	}
+
+	r.closeOnDone()
+
+	if cfg.Debug {
+		// the plans describe the components registered above
+		r.cfg.Engine.GET(proxy.ExplainPath, gin.WrapH(proxy.ExplainHandler(cfg)))
+	}
+
 	endpointGroup := r.cfg.Engine.Group("/")
 	endpointGroup.Use(r.cfg.Middlewares...)
 
 	r.registerKrakendEndpoints(endpointGroup, cfg)
+	health.MarkReady(health.ConfigGate)
 
 	if opts, ok := cfg.ExtraConfig[Namespace].(map[string]interface{}); ok {
 		if v, ok := opts["auto_options"].(bool); ok && v {
@@ -133,18 +353,63 @@ func (r ginRouter) registerEndpointsAndMiddlewares(cfg config.ServiceConfig) {
 }
 
 func (r ginRouter) registerKrakendEndpoints(rg *gin.RouterGroup, cfg config.ServiceConfig) {
+	hosts := hostRoutes{
+		routes:   router.HostRoutes(cfg.Endpoints),
+		handlers: map[string]*hostHandler{},
+	}
 	// build and register the pipes and endpoints sequentially
 	for _, c := range cfg.Endpoints {
-		proxyStack, err := r.cfg.ProxyFactory.New(c)
+		// the redirects are served without building the pipe of the endpoint
+		proxyStack, ok, err := redirect.New(c)
+		if !ok {
+			proxyStack, err = r.cfg.ProxyFactory.New(c)
+		}
 		if err != nil {
 			r.cfg.Logger.Error(logPrefix, "Calling the ProxyFactory", err.Error())
 			continue
 		}
-		r.registerKrakendEndpoint(rg, c.Method, c, r.cfg.HandlerFactory(c, proxyStack), len(c.Backend))
+		handler := r.cfg.HandlerFactory(c, proxyStack)
+		r.registerKrakendEndpoint(rg, c.Method, c, handler, len(c.Backend), hosts)
+
+		for _, alias := range c.Aliases {
+			r.registerKrakendEndpoint(rg, c.Method, router.AliasEndpoint(c, alias), newAliasHandler(handler, c, alias), len(c.Backend), hosts)
+		}
+	}
+}
+
+func newAliasHandler(h gin.HandlerFunc, e *config.EndpointConfig, alias config.EndpointAlias) gin.HandlerFunc {
+	if !alias.Deprecated {
+		return h
+	}
+	return func(c *gin.Context) {
+		router.SetDeprecationHeaders(c.Writer.Header(), e, alias)
+		h(c)
+	}
+}
+
+// hostRoutes keeps the handlers dispatching the routes of the endpoints restricted to a set of
+// hosts, since several endpoints can share them
+type hostRoutes struct {
+	routes   map[string]bool
+	handlers map[string]*hostHandler
+}
+
+// hostHandler dispatches the requests to the handler of the endpoint serving their host
+type hostHandler struct {
+	matcher  *router.HostMatcher
+	handlers []gin.HandlerFunc
+}
+
+func (h *hostHandler) handle(c *gin.Context) {
+	i := h.matcher.Match(c.Request)
+	if i < 0 {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
 	}
+	h.handlers[i](c)
 }
 
-func (r ginRouter) registerKrakendEndpoint(rg *gin.RouterGroup, method string, e *config.EndpointConfig, h gin.HandlerFunc, total int) {
+func (r ginRouter) registerKrakendEndpoint(rg *gin.RouterGroup, method string, e *config.EndpointConfig, h gin.HandlerFunc, total int, hosts hostRoutes) {
 	method = strings.ToTitle(method)
 	path := e.Endpoint
 	if method != http.MethodGet && total > 1 {
@@ -154,17 +419,18 @@ func (r ginRouter) registerKrakendEndpoint(rg *gin.RouterGroup, method string, e
 		}
 	}
 
+	var register func(string, ...gin.HandlerFunc) gin.IRoutes
 	switch method {
 	case http.MethodGet:
-		rg.GET(path, h)
+		register = rg.GET
 	case http.MethodPost:
-		rg.POST(path, h)
+		register = rg.POST
 	case http.MethodPut:
-		rg.PUT(path, h)
+		register = rg.PUT
 	case http.MethodPatch:
-		rg.PATCH(path, h)
+		register = rg.PATCH
 	case http.MethodDelete:
-		rg.DELETE(path, h)
+		register = rg.DELETE
 	default:
 		r.cfg.Logger.Error(logPrefix, "[ENDPOINT:", path, "] Unsupported method", method)
 		return
@@ -173,12 +439,26 @@ func (r ginRouter) registerKrakendEndpoint(rg *gin.RouterGroup, method string, e
 	r.urlCatalog.mu.Lock()
 	defer r.urlCatalog.mu.Unlock()
 
-	methods, ok := r.urlCatalog.catalog[path]
-	if !ok {
-		r.urlCatalog.catalog[path] = []string{method}
-		return
+	for _, p := range router.EndpointPaths(path) {
+		route := method + " " + p
+		if !hosts.routes[route] {
+			register(p, h)
+			r.urlCatalog.catalog[p] = append(r.urlCatalog.catalog[p], method)
+			continue
+		}
+		hh, ok := hosts.handlers[route]
+		if !ok {
+			hh = &hostHandler{matcher: router.NewHostMatcher()}
+			hosts.handlers[route] = hh
+			register(p, hh.handle)
+			r.urlCatalog.catalog[p] = append(r.urlCatalog.catalog[p], method)
+		}
+		if _, ok := hh.matcher.Add(e.Hosts); !ok {
+			r.cfg.Logger.Error(logPrefix, "[ENDPOINT:", p, "] The hosts of the endpoint are already served. Ignoring", e.Hosts)
+			continue
+		}
+		hh.handlers = append(hh.handlers, h)
 	}
-	r.urlCatalog.catalog[path] = append(methods, method)
 }
 
 func (r ginRouter) registerOptionEndpoints(rg *gin.RouterGroup) {
@@ -186,12 +466,6 @@ func (r ginRouter) registerOptionEndpoints(rg *gin.RouterGroup) {
 	defer r.urlCatalog.mu.Unlock()
 
 	for path, methods := range r.urlCatalog.catalog {
-		sort.Strings(methods)
-		allowed := strings.Join(methods, ", ")
-
-		rg.OPTIONS(path, func(c *gin.Context) {
-			c.Header("Allow", allowed)
-			c.Header(core.KrakendHeaderName, core.KrakendHeaderValue)
-		})
+		rg.OPTIONS(path, gin.WrapF(router.NewOptionsHandler(methods)))
 	}
 }
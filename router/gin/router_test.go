@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"regexp"
 	"strings"
 	"testing"
@@ -329,3 +330,107 @@ type erroredProxyFactory struct {
 func (e erroredProxyFactory) New(_ *config.EndpointConfig) (proxy.Proxy, error) {
 	return proxy.NoopProxy, e.Error
 }
+
+func TestRouter_optionalSegmentsAndCatchAll(t *testing.T) {
+	var handler http.Handler
+	r := NewFactory(Config{
+		Engine:         gin.New(),
+		Middlewares:    []gin.HandlerFunc{},
+		HandlerFactory: EndpointHandler,
+		ProxyFactory:   paramsProxyFactory{},
+		Logger:         logging.NoOp,
+		RunServer: func(_ context.Context, _ config.ServiceConfig, h http.Handler) error {
+			handler = h
+			return nil
+		},
+	}).NewWithContext(context.Background())
+	r.Run(config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/users/:id?", Method: "GET", Timeout: time.Second, Backend: []*config.Backend{{}}},
+			{Endpoint: "/files/*path", Method: "GET", Timeout: time.Second, Backend: []*config.Backend{{}}},
+		},
+	})
+
+	for path, expected := range map[string]string{
+		"/users":         `{"Id":""}`,
+		"/users/42":      `{"Id":"42"}`,
+		"/files/a/b.txt": `{"Path":"a/b.txt"}`,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, http.NoBody))
+		if w.Code != http.StatusOK || w.Body.String() != expected {
+			t.Errorf("unexpected response for %s: %d %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRouter_tenants(t *testing.T) {
+	var handler http.Handler
+	r := NewFactory(Config{
+		Engine:         gin.New(),
+		Middlewares:    []gin.HandlerFunc{},
+		HandlerFactory: EndpointHandler,
+		ProxyFactory:   tenantProxyFactory{},
+		Logger:         logging.NoOp,
+		RunServer: func(_ context.Context, _ config.ServiceConfig, h http.Handler) error {
+			handler = h
+			return nil
+		},
+	}).NewWithContext(context.Background())
+	r.Run(config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/users", Method: "GET", Timeout: time.Second, Backend: []*config.Backend{{}}},
+			{Endpoint: "/users", Method: "GET", Timeout: time.Second, Tenant: "a", Hosts: []string{"a.com"}, Backend: []*config.Backend{{}}},
+			{Endpoint: "/users", Method: "GET", Timeout: time.Second, Tenant: "b", Hosts: []string{"*.b.com"}, Backend: []*config.Backend{{}}},
+			{Endpoint: "/items/:id", Method: "GET", Timeout: time.Second, Tenant: "b", Hosts: []string{"*.b.com"}, Backend: []*config.Backend{{}}},
+		},
+	})
+
+	for _, tc := range []struct {
+		host, path string
+		status     int
+		body       string
+	}{
+		{host: "a.com", path: "/users", status: http.StatusOK, body: `{"tenant":"a"}`},
+		{host: "api.b.com:8080", path: "/users", status: http.StatusOK, body: `{"tenant":"b"}`},
+		{host: "c.com", path: "/users", status: http.StatusOK, body: `{"tenant":""}`},
+		{host: "api.b.com", path: "/items/42", status: http.StatusOK, body: `{"Id":"42","tenant":"b"}`},
+		{host: "a.com", path: "/items/42", status: http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", tc.path, http.NoBody)
+		req.Host = tc.host
+		handler.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("unexpected status code for %s%s: %d", tc.host, tc.path, w.Code)
+			continue
+		}
+		if tc.body != "" && w.Body.String() != tc.body {
+			t.Errorf("unexpected response for %s%s: %s", tc.host, tc.path, w.Body.String())
+		}
+	}
+}
+
+type tenantProxyFactory struct{}
+
+func (tenantProxyFactory) New(cfg *config.EndpointConfig) (proxy.Proxy, error) {
+	return func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+		data := map[string]interface{}{"tenant": cfg.Tenant}
+		for k, v := range r.Params {
+			data[k] = v
+		}
+		return &proxy.Response{IsComplete: true, Data: data}, nil
+	}, nil
+}
+
+type paramsProxyFactory struct{}
+
+func (paramsProxyFactory) New(_ *config.EndpointConfig) (proxy.Proxy, error) {
+	return func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+		data := map[string]interface{}{}
+		for k, v := range r.Params {
+			data[k] = v
+		}
+		return &proxy.Response{IsComplete: true, Data: data}, nil
+	}, nil
+}
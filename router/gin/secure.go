@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/secure"
+)
+
+// NewSecureHeadersMiddleware returns a gin middleware adding the security headers to the responses
+func NewSecureHeadersMiddleware(hs secure.Headers) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hs.Apply(c.Writer.Header())
+		c.Next()
+	}
+}
+
+// NewSecureHeadersHandlerFactory decorates the handlers of the endpoints declaring their own
+// security headers, so they override the ones of the service
+func NewSecureHeadersHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handler := hf(cfg, p)
+		hs, ok := secure.EndpointHeaders(cfg)
+		if !ok {
+			return handler
+		}
+		logger.Debug("[ENDPOINT: "+cfg.Endpoint+"][Secure]", "Overriding the security headers")
+
+		return func(c *gin.Context) {
+			hs.Apply(c.Writer.Header())
+			handler(c)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/requestid"
+	"github.com/luraproject/lura/v2/router/accesslog"
+	"github.com/luraproject/lura/v2/tracing"
+)
+
+// NewTracingHandlerFactory decorates the handlers of the endpoints, so every request opens the
+// server span of the endpoint, joining the trace propagated by the client, if any. It does
+// nothing when the service does not declare a tracer
+func NewTracingHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handler := hf(cfg, p)
+		t, ok := tracing.GetGlobal()
+		if !ok {
+			return handler
+		}
+		logger.Debug("[ENDPOINT: "+cfg.Endpoint+"][Tracing]", "Tracing the requests")
+		name := cfg.Method + " " + cfg.Endpoint
+
+		return func(c *gin.Context) {
+			ctx := c.Request.Context()
+			if sc, ok := tracing.Extract(c.Request.Header); ok {
+				ctx = tracing.ContextWithRemoteParent(ctx, sc)
+			}
+			ctx, span := t.Start(ctx, name, tracing.SpanKindServer)
+			span.SetAttribute("http.method", c.Request.Method)
+			span.SetAttribute("http.route", cfg.Endpoint)
+			span.SetAttribute("http.target", c.Request.URL.Path)
+			if id, ok := requestid.FromContext(ctx); ok {
+				span.SetAttribute("http.request_id", id)
+			}
+
+			c.Request = c.Request.WithContext(ctx)
+			// the proxies receive the gin context, only resolving the values with string keys
+			if sc, ok := tracing.SpanContextFromContext(ctx); ok {
+				c.Set(tracing.ContextKey, sc)
+				accesslog.SetTraceID(ctx, sc.TraceID.String())
+			}
+			handler(c)
+
+			status := c.Writer.Status()
+			span.SetAttribute("http.status_code", status)
+			if status >= http.StatusInternalServerError {
+				span.RecordError(errStatus(status))
+			}
+			span.Finish()
+		}
+	}
+}
+
+type errStatus int
+
+func (e errStatus) Error() string { return http.StatusText(int(e)) }
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/register"
+)
+
+// HandlerWrapper declares a named decorator of the endpoint handlers and its ordering constraints.
+// The wrappers placed first in the resolved order are executed first
+type HandlerWrapper struct {
+	Name     string
+	Before   []string
+	After    []string
+	Requires []string
+	Wrap     func(*config.EndpointConfig, gin.HandlerFunc) gin.HandlerFunc
+}
+
+// NewHandlerFactoryWithWrappers returns a HandlerFactory decorating the handlers created by the
+// received one with the wrappers, composed in the order resolved from their constraints
+func NewHandlerFactoryWithWrappers(hf HandlerFactory, wrappers ...HandlerWrapper) (HandlerFactory, error) {
+	constraints := make([]register.Constraint, len(wrappers))
+	byName := make(map[string]HandlerWrapper, len(wrappers))
+	for i, w := range wrappers {
+		constraints[i] = register.Constraint{Name: w.Name, Before: w.Before, After: w.After, Requires: w.Requires}
+		byName[w.Name] = w
+	}
+	names, err := register.Order(constraints)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		h := hf(cfg, p)
+		for i := len(names) - 1; i >= 0; i-- {
+			h = byName[names[i]].Wrap(cfg, h)
+		}
+		return h
+	}, nil
+}
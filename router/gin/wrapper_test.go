@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestNewHandlerFactoryWithWrappers(t *testing.T) {
+	var trace []string
+	wrapper := func(name string) func(*config.EndpointConfig, gin.HandlerFunc) gin.HandlerFunc {
+		return func(_ *config.EndpointConfig, next gin.HandlerFunc) gin.HandlerFunc {
+			return func(c *gin.Context) {
+				trace = append(trace, name)
+				next(c)
+			}
+		}
+	}
+	hf := func(_ *config.EndpointConfig, _ proxy.Proxy) gin.HandlerFunc {
+		return func(_ *gin.Context) { trace = append(trace, "handler") }
+	}
+
+	f, err := NewHandlerFactoryWithWrappers(
+		hf,
+		HandlerWrapper{Name: "rate-limit", After: []string{"auth"}, Wrap: wrapper("rate-limit")},
+		HandlerWrapper{Name: "auth", Wrap: wrapper("auth")},
+		HandlerWrapper{Name: "cors", Before: []string{"auth"}, Wrap: wrapper("cors")},
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", http.NoBody)
+	f(&config.EndpointConfig{}, nil)(c)
+	if s := strings.Join(trace, ","); s != "cors,auth,rate-limit,handler" {
+		t.Errorf("unexpected execution order: %s", s)
+	}
+
+	if _, err := NewHandlerFactoryWithWrappers(hf, HandlerWrapper{Name: "a", Requires: []string{"b"}}); err == nil {
+		t.Error("expecting an error")
+	}
+}
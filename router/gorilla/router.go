@@ -7,6 +7,7 @@ package gorilla
 
 import (
 	"net/http"
+	"regexp"
 
 	gorilla "github.com/gorilla/mux"
 
@@ -26,10 +27,11 @@ func DefaultFactory(pf proxy.Factory, logger logging.Logger) router.Factory {
 
 // DefaultConfig returns the struct that collects the parts the router should be builded from
 func DefaultConfig(pf proxy.Factory, logger logging.Logger) mux.Config {
+	logger = logging.Module(logger, "router")
 	return mux.Config{
 		Engine:         gorillaEngine{gorilla.NewRouter()},
 		Middlewares:    []mux.HandlerMiddleware{},
-		HandlerFactory: mux.CustomEndpointHandler(mux.NewRequestBuilder(gorillaParamsExtractor)),
+		HandlerFactory: mux.NewDefaultHandlerFactory(mux.CustomFilteredEndpointHandler(mux.NewFilteredRequestBuilder(gorillaParamsExtractor)), logger),
 		ProxyFactory:   pf,
 		Logger:         logger,
 		DebugPattern:   "/__debug/{params}",
@@ -47,12 +49,16 @@ func gorillaParamsExtractor(r *http.Request) map[string]string {
 	return params
 }
 
+var catchAllPattern = regexp.MustCompile(`\{([a-zA-Z\-_0-9]+)\.\.\.\}$`)
+
 type gorillaEngine struct {
 	r *gorilla.Router
 }
 
 // Handle implements the mux.Engine interface from the lura router package
 func (g gorillaEngine) Handle(pattern, method string, handler http.Handler) {
+	// the catch-all wildcards are translated into params matching the rest of the path
+	pattern = catchAllPattern.ReplaceAllString(pattern, "{$1:.*}")
 	g.r.Handle(pattern, handler).Methods(method)
 }
 
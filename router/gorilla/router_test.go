@@ -11,12 +11,14 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/logging"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/mux"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
@@ -283,3 +285,43 @@ type identityMiddleware struct{}
 func (identityMiddleware) Handler(h http.Handler) http.Handler {
 	return h
 }
+
+func TestRouter_optionalSegmentsAndCatchAll(t *testing.T) {
+	builder := mux.NewHandlerBuilder(func() mux.Config {
+		return DefaultConfig(paramsProxyFactory{}, logging.NoOp)
+	})
+	h, err := builder(context.Background(), config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/users/{id?}", Method: "GET", Timeout: time.Second, Backend: []*config.Backend{{}}},
+			{Endpoint: "/files/{path...}", Method: "GET", Timeout: time.Second, Backend: []*config.Backend{{}}},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for path, expected := range map[string]string{
+		"/users":         `{"Id":""}`,
+		"/users/42":      `{"Id":"42"}`,
+		"/files/a/b.txt": `{"Path":"a/b.txt"}`,
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, http.NoBody))
+		if w.Code != http.StatusOK || w.Body.String() != expected {
+			t.Errorf("unexpected response for %s: %d %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+type paramsProxyFactory struct{}
+
+func (paramsProxyFactory) New(_ *config.EndpointConfig) (proxy.Proxy, error) {
+	return func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+		data := map[string]interface{}{}
+		for k, v := range r.Params {
+			data[k] = v
+		}
+		return &proxy.Response{IsComplete: true, Data: data}, nil
+	}, nil
+}
@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package grpcweb lets the endpoints accept the requests of the gRPC-Web clients, like the browser
+ones, transcoding them to the JSON requests of the pipe and the JSON responses back to gRPC-Web.
+The endpoint declares the descriptor sets of the proto files and the gRPC method it implements:
+
+	{
+		"endpoint": "/orders.Orders/GetOrder",
+		"method": "POST",
+		"extra_config": {
+			"github.com/luraproject/lura/router/grpcweb": {
+				"descriptor_sets": ["./orders.pb"],
+				"method": "/orders.Orders/GetOrder"
+			}
+		}
+	}
+
+The requests without a gRPC-Web content type are processed as usual, so the same endpoint serves
+the JSON clients too. The endpoint responses are always sent with the 200 status and the outcome
+is declared by the grpc-status trailer, with the code equivalent to the HTTP status. Combine it
+with the gRPC backends of the transport/http/client/grpc package to bridge the gRPC-Web clients
+to native gRPC services.
+*/
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/transport/http/client/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Namespace is the key to use to store and access the gRPC-Web config of the endpoints
+const Namespace = "github.com/luraproject/lura/router/grpcweb"
+
+const (
+	contentType     = "application/grpc-web"
+	contentTypeText = "application/grpc-web-text"
+)
+
+// Config is the gRPC-Web config of an endpoint
+type Config struct {
+	DescriptorSets []string `json:"descriptor_sets"`
+	Method         string   `json:"method"`
+}
+
+// ConfigGetter parses the gRPC-Web config of the endpoint
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("grpcweb: parsing the config: %w", err)
+	}
+	if len(cfg.DescriptorSets) == 0 || cfg.Method == "" {
+		return cfg, true, fmt.Errorf("grpcweb: the descriptor sets and the method are required")
+	}
+	return cfg, true, nil
+}
+
+// Transcoder translates the gRPC-Web requests and responses of a method
+type Transcoder struct {
+	method protoreflect.MethodDescriptor
+}
+
+// New returns the Transcoder of the method declared by the config
+func New(cfg Config) (*Transcoder, error) {
+	files, err := grpc.LoadDescriptors(cfg.DescriptorSets)
+	if err != nil {
+		return nil, err
+	}
+	md, err := grpc.FindMethod(files, cfg.Method)
+	if err != nil {
+		return nil, err
+	}
+	if md.IsStreamingClient() {
+		return nil, fmt.Errorf("grpcweb: the client streaming method %s is not supported", md.FullName())
+	}
+	return &Transcoder{method: md}, nil
+}
+
+// IsGRPCWeb returns true for the requests of the gRPC-Web clients
+func IsGRPCWeb(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), contentType)
+}
+
+// IsText returns true for the requests of the gRPC-Web clients encoding the messages in base64
+func IsText(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), contentTypeText)
+}
+
+// DecodeRequest replaces the body of the gRPC-Web request with the JSON document of its message.
+// The request is updated to look like the ones of the JSON clients, so check IsText before
+func (t *Transcoder) DecodeRequest(r *http.Request) error {
+	var body io.Reader = http.NoBody
+	if r.Body != nil {
+		body = r.Body
+	}
+	if IsText(r) {
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	f, err := grpc.ReadFrame(body)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if r.Body != nil {
+		r.Body.Close()
+	}
+	msg := dynamicpb.NewMessage(t.method.Input())
+	if err := proto.Unmarshal(f.Payload, msg); err != nil {
+		return fmt.Errorf("grpcweb: decoding the request: %w", err)
+	}
+	b, err := grpc.ToJSON(msg)
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	r.ContentLength = int64(len(b))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Length", strconv.Itoa(len(b)))
+	r.Header.Set("Accept", "application/json")
+	return nil
+}
+
+// WriteResponse writes the gRPC-Web response equivalent to the JSON response of the endpoint.
+// The successful responses get their body transcoded to the output message of the method and the
+// rest get the gRPC status equivalent to their HTTP status, with their body as the message. The
+// text responses are encoded in base64
+func (t *Transcoder) WriteResponse(w http.ResponseWriter, status int, body []byte, text bool) {
+	buf := new(bytes.Buffer)
+	code := grpc.CodeFromHTTPStatus(status)
+	message := ""
+	if code == grpc.OK {
+		msg, err := grpc.FromJSON(t.method.Output(), body)
+		if err == nil {
+			err = grpc.WriteFrame(buf, msg)
+		}
+		if err != nil {
+			buf.Reset()
+			code, message = grpc.Internal, "transcoding the response: "+err.Error()
+		}
+	} else {
+		message = strings.TrimSpace(string(body))
+	}
+	trailer := http.Header{"Grpc-Status": {strconv.Itoa(int(code))}}
+	if message != "" {
+		trailer.Set("Grpc-Message", url.PathEscape(message))
+	}
+	grpc.WriteTrailer(buf, trailer)
+
+	out := buf.Bytes()
+	ct := contentType + "+proto"
+	if text {
+		out = []byte(base64.StdEncoding.EncodeToString(out))
+		ct = contentTypeText + "+proto"
+	}
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	h.Set("Content-Type", ct)
+	h.Set("Content-Length", strconv.Itoa(len(out)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(out)
+}
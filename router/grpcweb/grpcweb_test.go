@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/transport/http/client/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// testdata/orders.pb is the descriptor set of:
+//
+//	message GetOrderRequest { string id = 1; int32 limit = 2; repeated string tags = 3; bool full = 4; }
+//	message Order { string id = 1; double total = 2; }
+//	service Orders {
+//		rpc GetOrder(GetOrderRequest) returns (Order);
+//		rpc ListOrders(GetOrderRequest) returns (stream Order);
+//		rpc SaveOrders(stream Order) returns (Order);
+//	}
+var testCfg = Config{DescriptorSets: []string{"testdata/orders.pb"}, Method: "/orders.Orders/GetOrder"}
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok, _ := ConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the config should not be found")
+	}
+	if _, _, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"method": "/orders.Orders/GetOrder"}}); err == nil {
+		t.Error("expecting an error")
+	}
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"descriptor_sets": []interface{}{"testdata/orders.pb"},
+		"method":          "/orders.Orders/GetOrder",
+	}})
+	if !ok || err != nil || cfg.Method != testCfg.Method {
+		t.Errorf("unexpected result. cfg: %+v, ok: %v, err: %v", cfg, ok, err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(testCfg); err != nil {
+		t.Error(err)
+	}
+	for _, m := range []string{"/orders.Orders/Missing", "/orders.Orders/SaveOrders"} {
+		if _, err := New(Config{DescriptorSets: testCfg.DescriptorSets, Method: m}); err == nil {
+			t.Errorf("%s: expecting an error", m)
+		}
+	}
+}
+
+func grpcWebRequest(t *testing.T, tr *Transcoder, doc string, text bool) *http.Request {
+	msg, err := grpc.FromJSON(tr.method.Input(), []byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	grpc.WriteFrame(buf, msg)
+	ct := "application/grpc-web+proto"
+	body := buf.Bytes()
+	if text {
+		ct = "application/grpc-web-text"
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+	r := httptest.NewRequest("POST", "/orders.Orders/GetOrder", bytes.NewReader(body))
+	r.Header.Set("Content-Type", ct)
+	return r
+}
+
+func TestTranscoder_DecodeRequest(t *testing.T) {
+	tr, err := New(testCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []bool{false, true} {
+		r := grpcWebRequest(t, tr, `{"id":"42","limit":3}`, text)
+		if !IsGRPCWeb(r) || IsText(r) != text {
+			t.Errorf("unexpected content type: %s", r.Header.Get("Content-Type"))
+		}
+		if err := tr.DecodeRequest(r); err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(r.Body)
+		if s := strings.ReplaceAll(string(b), " ", ""); s != `{"id":"42","limit":3}` {
+			t.Errorf("unexpected body: %s", b)
+		}
+		if r.Header.Get("Content-Type") != "application/json" || r.ContentLength != int64(len(b)) {
+			t.Errorf("unexpected request: %v", r.Header)
+		}
+	}
+
+	r := httptest.NewRequest("POST", "/orders.Orders/GetOrder", bytes.NewReader([]byte{0, 0, 0, 0, 9, 1}))
+	r.Header.Set("Content-Type", "application/grpc-web")
+	if err := tr.DecodeRequest(r); err == nil {
+		t.Error("expecting an error for the truncated frame")
+	}
+}
+
+func TestTranscoder_WriteResponse(t *testing.T) {
+	tr, err := New(testCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	tr.WriteResponse(w, http.StatusOK, []byte(`{"id":"42","total":9.5,"extra":1}`), false)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/grpc-web+proto" {
+		t.Errorf("unexpected response: %d %v", w.Code, w.Header())
+	}
+	f, err := grpc.ReadFrame(w.Body)
+	if err != nil || f.IsTrailer() {
+		t.Fatalf("unexpected frame: %+v, err: %v", f, err)
+	}
+	out := dynamicpb.NewMessage(tr.method.Output())
+	if err := proto.Unmarshal(f.Payload, out); err != nil {
+		t.Fatal(err)
+	}
+	if id := out.Get(tr.method.Output().Fields().ByName("id")).String(); id != "42" {
+		t.Errorf("unexpected id: %s", id)
+	}
+	f, err = grpc.ReadFrame(w.Body)
+	if err != nil || !f.IsTrailer() || string(f.Payload) != "grpc-status: 0\r\n" {
+		t.Errorf("unexpected trailer: %+v, err: %v", f, err)
+	}
+
+	w = httptest.NewRecorder()
+	tr.WriteResponse(w, http.StatusNotFound, []byte("order not found\n"), true)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/grpc-web-text+proto" {
+		t.Errorf("unexpected response: %d %v", w.Code, w.Header())
+	}
+	f, err = grpc.ReadFrame(base64.NewDecoder(base64.StdEncoding, w.Body))
+	if err != nil || !f.IsTrailer() {
+		t.Fatalf("unexpected frame: %+v, err: %v", f, err)
+	}
+	if s := string(f.Payload); !strings.Contains(s, "grpc-status: 5\r\n") || !strings.Contains(s, "grpc-message: order%20not%20found\r\n") {
+		t.Errorf("unexpected trailer: %q", s)
+	}
+}
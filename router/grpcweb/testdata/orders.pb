@@ -0,0 +1,17 @@
+
+
+orders.protoorders"_
+GetOrderRequest
+id (	Rid
+limit (Rlimit
+tags (	Rtags
+full (Rfull"-
+Order
+id (	Rid
+total (Rtotal2
+Orders2
+GetOrder.orders.GetOrderRequest.orders.Order6
+
+ListOrders.orders.GetOrderRequest.orders.Order0,
+
+SaveOrders.orders.Order.orders.Order(bproto3
\ No newline at end of file
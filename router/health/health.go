@@ -0,0 +1,238 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package health exposes the liveness and the readiness of the gateway as two distinct endpoints,
+so the orchestrators can restart the stuck instances and stop routing traffic to the ones not
+able to serve it yet.
+
+The endpoints are only exposed when the health namespace is declared in the service extra config,
+and the endpoints of the config can not use their paths. The liveness endpoint always answers with a 200 while the router is serving. The readiness
+endpoint answers with a 200 once all the required gates are open and with a 503 otherwise. The
+router opens the "config" gate once all the endpoints are registered. The other gates are
+opened by the applications embedding lura with MarkReady, i.e. the "plugins" one after loading
+the plugins. The readiness can also require at least one healthy host for the critical backends
+probed by the sd/healthcheck package.
+
+	"extra_config": {
+		"github.com/luraproject/lura/router/health": {
+			"live_path": "/__live",
+			"ready_path": "/__ready",
+			"require": ["config", "plugins"],
+			"critical_backends": ["/users/{id}"]
+		}
+	}
+
+The critical backends are identified by their url pattern.
+*/
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/sd/healthcheck"
+)
+
+// Namespace is the key to use to store and access the health config
+const Namespace = "github.com/luraproject/lura/router/health"
+
+const (
+	// DefaultLivePath is the path of the liveness endpoint when the config does not declare one
+	DefaultLivePath = "/__live"
+	// DefaultReadyPath is the path of the readiness endpoint when the config does not declare one
+	DefaultReadyPath = "/__ready"
+
+	// ConfigGate is opened by the router once the endpoints of the config are registered
+	ConfigGate = "config"
+	// PluginsGate is the gate the applications open once the plugins are loaded
+	PluginsGate = "plugins"
+)
+
+// Config is the health config of the service
+type Config struct {
+	LivePath         string   `json:"live_path"`
+	ReadyPath        string   `json:"ready_path"`
+	Require          []string `json:"require"`
+	CriticalBackends []string `json:"critical_backends"`
+}
+
+func init() {
+	config.ReservedPaths[Namespace] = func(e config.ExtraConfig) []string {
+		cfg, ok, _ := ConfigGetter(e)
+		if !ok {
+			return nil
+		}
+		return []string{cfg.LivePath, cfg.ReadyPath}
+	}
+}
+
+// ConfigGetter parses the health config from the service extra config, returning false if the
+// namespace is not declared. The configs not declaring the required gates only require the config
+// one
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return defaultConfig(cfg), false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return defaultConfig(cfg), true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return defaultConfig(Config{}), true, err
+	}
+	return defaultConfig(cfg), true, nil
+}
+
+func defaultConfig(cfg Config) Config {
+	if cfg.LivePath == "" {
+		cfg.LivePath = DefaultLivePath
+	}
+	if cfg.ReadyPath == "" {
+		cfg.ReadyPath = DefaultReadyPath
+	}
+	if cfg.Require == nil {
+		cfg.Require = []string{ConfigGate}
+	}
+	return cfg
+}
+
+// Report is the body of the readiness responses
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+const (
+	statusOK       = "ok"
+	statusReady    = "ready"
+	statusNotReady = "not ready"
+)
+
+var (
+	mu       sync.RWMutex
+	current  = defaultConfig(Config{})
+	openings = map[string]bool{}
+	draining bool
+)
+
+// Register sets the config of the readiness checks and closes the config gate until the router
+// registers the endpoints of the new config, returning false if the health namespace is not
+// declared, so the router does not expose the endpoints. The rest of the gates keep their state
+func Register(cfg config.ServiceConfig) (Config, bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	mu.Lock()
+	current = c
+	openings[ConfigGate] = false
+	mu.Unlock()
+	return c, ok, err
+}
+
+// MarkReady opens the gate
+func MarkReady(gate string) {
+	mu.Lock()
+	openings[gate] = true
+	mu.Unlock()
+}
+
+// MarkNotReady closes the gate
+func MarkNotReady(gate string) {
+	mu.Lock()
+	openings[gate] = false
+	mu.Unlock()
+}
+
+// SetDraining flags the service as shutting down, so the readiness probes fail while the
+// requests in flight are completed
+func SetDraining(d bool) {
+	mu.Lock()
+	draining = d
+	mu.Unlock()
+}
+
+// Check returns true if all the required gates are open and all the critical backends have at
+// least one healthy host, with the state of every check. The services shutting down are never
+// ready
+func Check() (bool, Report) {
+	mu.RLock()
+	require := current.Require
+	critical := current.CriticalBackends
+	gates := make(map[string]bool, len(require))
+	for _, g := range require {
+		gates[g] = openings[g]
+	}
+	isDraining := draining
+	mu.RUnlock()
+
+	ready := true
+	checks := make(map[string]string, len(gates)+len(critical)+1)
+	if isDraining {
+		checks["shutdown"] = "draining"
+		ready = false
+	}
+	for g, open := range gates {
+		if open {
+			checks[g] = statusOK
+			continue
+		}
+		checks[g] = "pending"
+		ready = false
+	}
+
+	if len(critical) > 0 {
+		healthy := map[string]int{}
+		checked := map[string]bool{}
+		for _, b := range healthcheck.Statuses() {
+			checked[b.Backend] = true
+			for _, h := range b.Hosts {
+				if h.Healthy {
+					healthy[b.Backend]++
+				}
+			}
+		}
+		for _, b := range critical {
+			key := "backend " + b
+			switch {
+			case !checked[b]:
+				checks[key] = "not checked"
+				ready = false
+			case healthy[b] == 0:
+				checks[key] = "no healthy hosts"
+				ready = false
+			default:
+				checks[key] = statusOK
+			}
+		}
+	}
+
+	r := Report{Status: statusReady, Checks: checks}
+	if !ready {
+		r.Status = statusNotReady
+	}
+	return ready, r
+}
+
+// LiveHandler returns a http handler answering the liveness probes
+func LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte(`{"status":"ok"}`))
+	})
+}
+
+// ReadyHandler returns a http handler answering the readiness probes
+func ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		ready, r := Check()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(r)
+	})
+}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+	"github.com/luraproject/lura/v2/sd/healthcheck"
+)
+
+func TestConfigGetter(t *testing.T) {
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("the health namespace is not declared")
+	}
+	if cfg.LivePath != DefaultLivePath || cfg.ReadyPath != DefaultReadyPath || len(cfg.Require) != 1 || cfg.Require[0] != ConfigGate {
+		t.Errorf("unexpected defaults %+v", cfg)
+	}
+
+	cfg, ok, err = ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"ready_path": "/ready",
+		"require":    []interface{}{},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("the health namespace is declared")
+	}
+	if cfg.ReadyPath != "/ready" || cfg.LivePath != DefaultLivePath || len(cfg.Require) != 0 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	if _, _, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"require": "config"}}); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestReservedPaths(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		extra    config.ExtraConfig
+		endpoint string
+		alias    string
+		ok       bool
+	}{
+		{name: "not declared", extra: config.ExtraConfig{}, endpoint: "/__live", alias: "/__ready", ok: true},
+		{name: "live", extra: config.ExtraConfig{Namespace: map[string]interface{}{}}, endpoint: "/__live"},
+		{name: "ready alias", extra: config.ExtraConfig{Namespace: map[string]interface{}{}}, endpoint: "/a", alias: "/__ready"},
+		{name: "custom", extra: config.ExtraConfig{Namespace: map[string]interface{}{"live_path": "/live"}}, endpoint: "/live"},
+		{name: "default moved", extra: config.ExtraConfig{Namespace: map[string]interface{}{"live_path": "/live"}}, endpoint: "/__live", ok: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &config.EndpointConfig{Endpoint: tc.endpoint, Method: "GET", Backend: []*config.Backend{{URLPattern: "/"}}}
+			if tc.alias != "" {
+				e.Aliases = []config.EndpointAlias{{Path: tc.alias}}
+			}
+			cfg := config.ServiceConfig{Version: config.ConfigVersion, ExtraConfig: tc.extra, Endpoints: []*config.EndpointConfig{e}}
+
+			errs := config.Validate(cfg)
+			if err := cfg.Init(); (err == nil) != tc.ok {
+				t.Errorf("unexpected init error: %v", err)
+			}
+			if (len(errs) == 0) != tc.ok {
+				t.Errorf("unexpected validation errors: %v", errs)
+			}
+		})
+	}
+}
+
+func TestReadyHandler_gates(t *testing.T) {
+	defer MarkNotReady(PluginsGate)
+
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"require": []interface{}{ConfigGate, PluginsGate},
+	}}}
+	if _, _, err := Register(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	assertReady(t, http.StatusServiceUnavailable, map[string]string{ConfigGate: "pending", PluginsGate: "pending"})
+
+	MarkReady(ConfigGate)
+	assertReady(t, http.StatusServiceUnavailable, map[string]string{ConfigGate: "ok", PluginsGate: "pending"})
+
+	MarkReady(PluginsGate)
+	assertReady(t, http.StatusOK, map[string]string{ConfigGate: "ok", PluginsGate: "ok"})
+
+	// a new config closes the config gate until its endpoints are registered
+	if _, _, err := Register(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertReady(t, http.StatusServiceUnavailable, map[string]string{ConfigGate: "pending", PluginsGate: "ok"})
+
+	// the services shutting down are never ready
+	MarkReady(ConfigGate)
+	SetDraining(true)
+	defer SetDraining(false)
+	assertReady(t, http.StatusServiceUnavailable, map[string]string{ConfigGate: "ok", PluginsGate: "ok", "shutdown": "draining"})
+}
+
+func TestReadyHandler_criticalBackends(t *testing.T) {
+	defer healthcheck.StopAll()
+
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"critical_backends": []interface{}{"/users"},
+	}}}
+	if _, _, err := Register(cfg); err != nil {
+		t.Fatal(err)
+	}
+	MarkReady(ConfigGate)
+	assertReady(t, http.StatusServiceUnavailable, map[string]string{ConfigGate: "ok", "backend /users": "not checked"})
+
+	remote := &config.Backend{
+		URLPattern: "/users",
+		ExtraConfig: config.ExtraConfig{healthcheck.Namespace: map[string]interface{}{
+			"interval":            "10ms",
+			"unhealthy_threshold": 1,
+		}},
+	}
+	s := healthcheck.NewSubscriber(logging.NoOp, remote, sd.FixedSubscriber{"http://127.0.0.1:1"})
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if hosts, _ := s.Hosts(); len(hosts) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the host was not marked as unhealthy")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assertReady(t, http.StatusServiceUnavailable, map[string]string{ConfigGate: "ok", "backend /users": "no healthy hosts"})
+
+}
+
+func TestLiveHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	LiveHandler().ServeHTTP(w, httptest.NewRequest("GET", DefaultLivePath, nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"status":"ok"}` {
+		t.Errorf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+}
+
+func assertReady(t *testing.T, status int, checks map[string]string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	ReadyHandler().ServeHTTP(w, httptest.NewRequest("GET", DefaultReadyPath, nil))
+	if w.Code != status {
+		t.Errorf("unexpected status %d", w.Code)
+	}
+	var r Report
+	if err := json.Unmarshal(w.Body.Bytes(), &r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Checks) != len(checks) {
+		t.Errorf("unexpected checks %v", r.Checks)
+	}
+	for k, v := range checks {
+		if r.Checks[k] != v {
+			t.Errorf("unexpected check %s: %s", k, r.Checks[k])
+		}
+	}
+}
@@ -25,10 +25,11 @@ func DefaultFactory(pf proxy.Factory, logger logging.Logger) router.Factory {
 
 // DefaultConfig returns the struct that collects the parts the router should be built from
 func DefaultConfig(pf proxy.Factory, logger logging.Logger) mux.Config {
+	logger = logging.Module(logger, "router")
 	return mux.Config{
 		Engine:         NewEngine(httptreemux.NewContextMux()),
 		Middlewares:    []mux.HandlerMiddleware{},
-		HandlerFactory: mux.CustomEndpointHandler(mux.NewRequestBuilder(ParamsExtractor)),
+		HandlerFactory: mux.NewDefaultHandlerFactory(mux.CustomFilteredEndpointHandler(mux.NewFilteredRequestBuilder(ParamsExtractor)), logger),
 		ProxyFactory:   pf,
 		Logger:         logger,
 		DebugPattern:   "/__debug/{params}",
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package ipfilter restricts the access to the endpoints by the IP of the clients.
+
+The rules can be declared in the service extra config, applying to every endpoint, and in the
+extra config of the endpoints, replacing the service ones:
+
+	"extra_config": {
+		"github.com/luraproject/lura/router/ipfilter": {
+			"allow": ["10.0.0.0/8", "192.168.1.12"],
+			"deny": ["10.0.3.0/24"]
+		}
+	}
+
+The denied addresses take precedence over the allowed ones and, when the allow list is empty,
+every address not denied is accepted.
+
+The client IP is resolved by the forwarded package, so the trusted proxies and the client IP
+headers are declared once, in its config, for the filters, the loggers and the forwarding headers.
+Without it, the client IP is the remote address of the connection.
+*/
+package ipfilter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/router/forwarded"
+)
+
+// Namespace is the key to use to store and access the ipfilter config
+const Namespace = "github.com/luraproject/lura/router/ipfilter"
+
+// ErrForbidden is returned when the client IP is not allowed
+var ErrForbidden = errors.New("ipfilter: the client ip is not allowed")
+
+// Config is the ipfilter config
+type Config struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// ConfigGetter parses the ipfilter config from the extra config of the service or an endpoint
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("ipfilter: parsing the config: %w", err)
+	}
+	for _, k := range []string{"trusted_proxies", "client_ip_headers"} {
+		if _, ok := tmp[k]; ok {
+			return cfg, true, fmt.Errorf("ipfilter: %s must be declared in the %s config", k, forwarded.Namespace)
+		}
+	}
+	return cfg, true, nil
+}
+
+// Filter decides if the requests are accepted by their client IP
+type Filter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// New returns a Filter with the rules of the config
+func New(cfg Config) (*Filter, error) {
+	f := &Filter{}
+	var err error
+	if f.allow, err = parseNetworks(cfg.Allow); err != nil {
+		return nil, err
+	}
+	if f.deny, err = parseNetworks(cfg.Deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Allowed checks if the ip is accepted by the filter
+func (f *Filter) Allowed(ip net.IP) bool {
+	if ip == nil || contains(f.deny, ip) {
+		return false
+	}
+	return len(f.allow) == 0 || contains(f.allow, ip)
+}
+
+// Check returns ErrForbidden if the client IP of the request is not accepted by the filter
+func (f *Filter) Check(r *http.Request) error {
+	if !f.Allowed(f.ClientIP(r)) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// ClientIP returns the IP of the client sending the request, resolved by the forwarded package
+func (*Filter) ClientIP(r *http.Request) net.IP {
+	return forwarded.ClientIP(r)
+}
+
+var (
+	global    *Filter
+	globalErr error
+	globalMu  sync.RWMutex
+)
+
+// Register creates the filter declared in the service extra config and makes it the one used by
+// the endpoints without their own rules. It returns false if the service does not declare one.
+// When the declared config is not valid, the endpoints without their own rules reject all the
+// requests instead of being exposed without filtering
+func Register(cfg config.ServiceConfig) (bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	var f *Filter
+	if ok && err == nil {
+		f, err = New(c)
+	}
+	globalMu.Lock()
+	global, globalErr = f, err
+	globalMu.Unlock()
+	return ok, err
+}
+
+// SetGlobal sets the filter used by the endpoints without their own rules
+func SetGlobal(f *Filter) {
+	globalMu.Lock()
+	global, globalErr = f, nil
+	globalMu.Unlock()
+}
+
+// GetGlobal returns the filter used by the endpoints without their own rules
+func GetGlobal() (*Filter, bool) {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return global, global != nil
+}
+
+// EndpointFilter returns the filter of the endpoint: its own one, if declared, or the global one.
+// It returns false if the endpoint is not filtered
+func EndpointFilter(cfg *config.EndpointConfig) (*Filter, bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if err != nil {
+		return nil, true, err
+	}
+	if ok {
+		f, err := New(c)
+		return f, true, err
+	}
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	if globalErr != nil {
+		return nil, true, globalErr
+	}
+	return global, global != nil, nil
+}
+
+func parseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("ipfilter: invalid ip %s", v)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("ipfilter: invalid cidr %s", v)
+		}
+		networks = append(networks, n)
+	}
+	return networks, nil
+}
+
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package ipfilter
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/router/forwarded"
+)
+
+func TestFilter_Allowed(t *testing.T) {
+	f, err := New(Config{
+		Allow: []string{"10.0.0.0/8", "192.168.1.12", "2001:db8::/32"},
+		Deny:  []string{"10.0.3.0/24"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, expected := range map[string]bool{
+		"10.1.2.3":     true,
+		"10.0.3.4":     false,
+		"192.168.1.12": true,
+		"192.168.1.13": false,
+		"2001:db8::1":  true,
+		"2001:db9::1":  false,
+	} {
+		if f.Allowed(net.ParseIP(ip)) != expected {
+			t.Errorf("%s: expecting %v", ip, expected)
+		}
+	}
+	if f.Allowed(nil) {
+		t.Error("the unknown addresses should not be allowed")
+	}
+
+	f, _ = New(Config{Deny: []string{"1.2.3.4"}})
+	if !f.Allowed(net.ParseIP("5.6.7.8")) || f.Allowed(net.ParseIP("1.2.3.4")) {
+		t.Error("without allow list, every address not denied should be accepted")
+	}
+}
+
+func TestNew_invalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{Allow: []string{"10.0.0.0/33"}},
+		{Deny: []string{"not-an-ip"}},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("expecting an error for %+v", cfg)
+		}
+	}
+}
+
+func TestConfigGetter_trustedProxies(t *testing.T) {
+	_, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"allow":           []interface{}{"10.0.0.0/8"},
+		"trusted_proxies": []interface{}{"172.16.0.0/12"},
+	}})
+	if !ok || err == nil {
+		t.Errorf("the trusted proxies must be rejected. ok: %v, err: %v", ok, err)
+	}
+}
+
+func TestFilter_ClientIP(t *testing.T) {
+	f, _ := New(Config{Allow: []string{"5.5.5.5"}})
+	req, _ := http.NewRequest("GET", "/", http.NoBody)
+	req.RemoteAddr = "172.16.0.1:80"
+	req.Header.Set("X-Forwarded-For", "5.5.5.5")
+
+	if err := f.Check(req); err != ErrForbidden {
+		t.Errorf("the forwarding headers of an untrusted address were used: %v", err)
+	}
+
+	r, _ := forwarded.New(forwarded.Config{TrustedProxies: []string{"172.16.0.0/12"}})
+	forwarded.SetGlobal(r)
+	defer forwarded.SetGlobal(nil)
+
+	if ip := f.ClientIP(req); ip.String() != "5.5.5.5" {
+		t.Errorf("unexpected client ip %s", ip)
+	}
+	if err := f.Check(req); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestEndpointFilter(t *testing.T) {
+	defer SetGlobal(nil)
+
+	global := config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{"allow": []interface{}{"10.0.0.0/8"}},
+	}}
+	if ok, err := Register(global); !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+
+	f, ok, err := EndpointFilter(&config.EndpointConfig{})
+	if !ok || err != nil || !f.Allowed(net.ParseIP("10.0.0.1")) || f.Allowed(net.ParseIP("1.1.1.1")) {
+		t.Errorf("the endpoints without rules should use the global filter. ok: %v, err: %v", ok, err)
+	}
+
+	f, ok, err = EndpointFilter(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{"allow": []interface{}{"1.1.1.1"}},
+	}})
+	if !ok || err != nil || f.Allowed(net.ParseIP("10.0.0.1")) || !f.Allowed(net.ParseIP("1.1.1.1")) {
+		t.Errorf("the endpoint rules should replace the global ones. ok: %v, err: %v", ok, err)
+	}
+
+	global.ExtraConfig[Namespace] = map[string]interface{}{"allow": []interface{}{"bad"}}
+	if _, err := Register(global); err == nil {
+		t.Error("expecting an error for the invalid global config")
+	}
+	if _, ok, err := EndpointFilter(&config.EndpointConfig{}); !ok || err == nil {
+		t.Error("the endpoints without rules should fail closed when the global config is invalid")
+	}
+
+	if ok, _ := Register(config.ServiceConfig{}); ok {
+		t.Error("the service does not declare a filter")
+	}
+	if _, ok, _ := EndpointFilter(&config.EndpointConfig{}); ok {
+		t.Error("the endpoint should not be filtered")
+	}
+}
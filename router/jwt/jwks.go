@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MinRefreshInterval is the minimum time between two fetches of a JWKS triggered by unknown key ids
+const MinRefreshInterval = 10 * time.Second
+
+// ErrUnknownKey is returned when the JWKS does not contain the key used to sign the token
+var ErrUnknownKey = errors.New("jwt: unknown key")
+
+// JSONWebKey is a verification key published in a JWKS
+type JSONWebKey struct {
+	ID        string
+	Algorithm string
+	// Key is a *rsa.PublicKey, a *ecdsa.PublicKey or the []byte secret of a symmetric key
+	Key interface{}
+}
+
+// KeySet fetches and caches the keys published at a JWKS url
+type KeySet struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]JSONWebKey
+	fetchedAt time.Time
+}
+
+// NewKeySet returns a KeySet caching the keys of the url for the received ttl
+func NewKeySet(url string, ttl time.Duration, c *http.Client) *KeySet {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &KeySet{url: url, ttl: ttl, client: c, now: time.Now}
+}
+
+var (
+	keySets   = map[string]*KeySet{}
+	keySetsMu sync.Mutex
+)
+
+func sharedKeySet(url string, ttl time.Duration, c *http.Client) *KeySet {
+	k := url + "|" + ttl.String()
+	keySetsMu.Lock()
+	defer keySetsMu.Unlock()
+	if ks, ok := keySets[k]; ok {
+		return ks
+	}
+	ks := NewKeySet(url, ttl, c)
+	keySets[k] = ks
+	return ks
+}
+
+// Key returns the key with the received id. The JWKS is fetched again when the cached keys are
+// expired or, to support key rotations, when the key is unknown and the last fetch is older than
+// MinRefreshInterval. An empty id is only accepted if the JWKS contains a single key
+func (ks *KeySet) Key(ctx context.Context, kid string) (JSONWebKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	now := ks.now()
+	age := now.Sub(ks.fetchedAt)
+	if ks.keys == nil || age > ks.ttl {
+		if err := ks.fetch(ctx); err != nil {
+			return JSONWebKey{}, err
+		}
+	} else if _, ok := ks.lookup(kid); !ok && age > MinRefreshInterval {
+		if err := ks.fetch(ctx); err != nil {
+			return JSONWebKey{}, err
+		}
+	}
+
+	if k, ok := ks.lookup(kid); ok {
+		return k, nil
+	}
+	return JSONWebKey{}, ErrUnknownKey
+}
+
+func (ks *KeySet) lookup(kid string) (JSONWebKey, bool) {
+	if kid != "" {
+		k, ok := ks.keys[kid]
+		return k, ok
+	}
+	if len(ks.keys) != 1 {
+		return JSONWebKey{}, false
+	}
+	for _, k := range ks.keys {
+		return k, true
+	}
+	return JSONWebKey{}, false
+}
+
+func (ks *KeySet) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.url, http.NoBody)
+	if err != nil {
+		return err
+	}
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("jwt: fetching the jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("jwt: fetching the jwks: unexpected status code %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("jwt: decoding the jwks: %w", err)
+	}
+	keys := make(map[string]JSONWebKey, len(set.Keys))
+	for _, raw := range set.Keys {
+		k, err := ParseJWK(raw)
+		if err != nil {
+			// skip the keys we can not use, so a single unsupported key does not break the set
+			continue
+		}
+		keys[k.ID] = k
+	}
+	ks.keys = keys
+	ks.fetchedAt = ks.now()
+	return nil
+}
+
+// ParseJWK parses a JSON Web Key with the RSA, EC or oct key type. The keys declaring a use
+// other than signature are rejected
+func ParseJWK(raw []byte) (JSONWebKey, error) {
+	var jwk struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Alg string `json:"alg"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+		K   string `json:"k"`
+	}
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return JSONWebKey{}, err
+	}
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return JSONWebKey{}, fmt.Errorf("jwt: unsupported key use %s", jwk.Use)
+	}
+	res := JSONWebKey{ID: jwk.Kid, Algorithm: jwk.Alg}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return res, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return res, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return res, errors.New("jwt: invalid rsa exponent")
+		}
+		res.Key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return res, fmt.Errorf("jwt: unsupported curve %s", jwk.Crv)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return res, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return res, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return res, errors.New("jwt: invalid ec point")
+		}
+		res.Key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	case "oct":
+		k, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil {
+			return res, err
+		}
+		res.Key = k
+	default:
+		return res, fmt.Errorf("jwt: unsupported key type %s", jwk.Kty)
+	}
+	return res, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("jwt: empty key component")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeySet_Key(t *testing.T) {
+	var hits int32
+	kid := "first"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []interface{}{
+				map[string]interface{}{"kty": "oct", "kid": kid, "k": "c2VjcmV0"},
+				map[string]interface{}{"kty": "oct", "kid": "enc", "use": "enc", "k": "c2VjcmV0"},
+			},
+		})
+	}))
+	defer ts.Close()
+
+	now := time.Now()
+	ks := NewKeySet(ts.URL, time.Minute, ts.Client())
+	ks.now = func() time.Time { return now }
+
+	if _, err := ks.Key(context.Background(), "first"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Key(context.Background(), "first"); err != nil {
+		t.Fatal(err)
+	}
+	if h := atomic.LoadInt32(&hits); h != 1 {
+		t.Errorf("the keys should be cached. fetches: %d", h)
+	}
+	if _, err := ks.Key(context.Background(), "enc"); err != ErrUnknownKey {
+		t.Errorf("the encryption keys should be ignored: %v", err)
+	}
+
+	kid = "second"
+	if _, err := ks.Key(context.Background(), "second"); err != ErrUnknownKey {
+		t.Errorf("the unknown keys should not trigger a fetch before the refresh interval: %v", err)
+	}
+	now = now.Add(MinRefreshInterval + time.Second)
+	if _, err := ks.Key(context.Background(), "second"); err != nil {
+		t.Errorf("the rotated key should be fetched: %v", err)
+	}
+	if h := atomic.LoadInt32(&hits); h != 2 {
+		t.Errorf("unexpected number of fetches: %d", h)
+	}
+}
+
+func TestKeySet_Key_fetchError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	if _, err := NewKeySet(ts.URL, time.Minute, ts.Client()).Key(context.Background(), "kid"); err == nil {
+		t.Error("expecting an error")
+	}
+}
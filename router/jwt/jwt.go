@@ -0,0 +1,425 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package jwt validates the JSON Web Tokens of the requests before they reach the proxy stage.
+
+The tokens are verified with the keys published at a JWKS url, restricted to an allowlist of
+algorithms and checked against the expected issuer and audiences. The routers expose the
+validation as a handler factory wrapper and, optionally, propagate a selection of the claims
+to the backends as headers or params.
+*/
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the jwt config
+const Namespace = "github.com/luraproject/lura/router/jwt"
+
+// DefaultCacheTTL is the time the fetched keys are cached when the config does not declare it
+const DefaultCacheTTL = 15 * time.Minute
+
+// DefaultAlgorithms is the allowlist used when the config does not declare one
+var DefaultAlgorithms = []string{"RS256"}
+
+var (
+	// ErrNoToken is returned when the request does not contain a bearer token
+	ErrNoToken = errors.New("jwt: no token found")
+	// ErrMalformedToken is returned when the token is not a valid compact JWS
+	ErrMalformedToken = errors.New("jwt: malformed token")
+	// ErrAlgorithmNotAllowed is returned when the token is signed with an algorithm out of the allowlist
+	ErrAlgorithmNotAllowed = errors.New("jwt: algorithm not allowed")
+	// ErrInvalidSignature is returned when the signature of the token does not match
+	ErrInvalidSignature = errors.New("jwt: invalid signature")
+	// ErrExpired is returned when the token is expired
+	ErrExpired = errors.New("jwt: token expired")
+	// ErrNotYetValid is returned when the token is used before its nbf claim
+	ErrNotYetValid = errors.New("jwt: token not valid yet")
+	// ErrInvalidIssuer is returned when the iss claim does not match the expected issuer
+	ErrInvalidIssuer = errors.New("jwt: invalid issuer")
+	// ErrInvalidAudience is returned when the aud claim does not contain any of the expected audiences
+	ErrInvalidAudience = errors.New("jwt: invalid audience")
+	// ErrNoJWKURL is returned when the config does not declare the JWKS url
+	ErrNoJWKURL = errors.New("jwt: no jwk_url declared")
+)
+
+// Claims contains the payload of a validated token
+type Claims map[string]interface{}
+
+// Value returns the string representation of a claim. Nested claims are accessed with dots
+// (i.e. "realm_access.roles") and the arrays are joined with commas
+func (c Claims) Value(name string) (string, bool) {
+	var v interface{} = map[string]interface{}(c)
+	for _, part := range strings.Split(name, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if v, ok = m[part]; !ok {
+			return "", false
+		}
+	}
+	return claimString(v)
+}
+
+func claimString(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case bool:
+		return strconv.FormatBool(t), true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	case json.Number:
+		return t.String(), true
+	case []interface{}:
+		parts := make([]string, 0, len(t))
+		for _, e := range t {
+			if s, ok := claimString(e); ok {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ","), true
+	default:
+		return "", false
+	}
+}
+
+// Propagation maps a claim to the header or param receiving its value
+type Propagation struct {
+	Claim string
+	Name  string
+}
+
+// Config is the jwt configuration of an endpoint
+type Config struct {
+	JWKURL     string
+	Algorithms []string
+	Issuer     string
+	Audience   []string
+	CacheTTL   time.Duration
+	Leeway     time.Duration
+	// Headers lists the claims to add as request headers. They reach the backends when they are
+	// declared in the input headers of the endpoint
+	Headers []Propagation
+	// Params lists the claims to add as request params
+	Params []Propagation
+}
+
+// ConfigGetter parses the jwt config from the extra config of an endpoint
+func ConfigGetter(e config.ExtraConfig) (Config, bool) {
+	cfg := Config{
+		Algorithms: DefaultAlgorithms,
+		CacheTTL:   DefaultCacheTTL,
+	}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false
+	}
+	cfg.JWKURL, _ = tmp["jwk_url"].(string)
+	cfg.Issuer, _ = tmp["issuer"].(string)
+	if algs := stringList(tmp["alg"]); len(algs) > 0 {
+		cfg.Algorithms = algs
+	}
+	cfg.Audience = stringList(tmp["audience"])
+	if v, ok := tmp["cache_ttl"].(string); ok {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.CacheTTL = d
+		}
+	}
+	if v, ok := tmp["leeway"].(string); ok {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.Leeway = d
+		}
+	}
+	cfg.Headers = propagations(tmp["propagate_claims"])
+	cfg.Params = propagations(tmp["claims_to_params"])
+	return cfg, true
+}
+
+func stringList(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		res := make([]string, 0, len(t))
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+func propagations(v interface{}) []Propagation {
+	pairs, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	res := make([]Propagation, 0, len(pairs))
+	for _, p := range pairs {
+		if pair := stringList(p); len(pair) == 2 {
+			res = append(res, Propagation{Claim: pair[0], Name: pair[1]})
+		}
+	}
+	return res
+}
+
+// Validator validates the tokens of an endpoint
+type Validator struct {
+	cfg  Config
+	keys *KeySet
+	algs map[string]struct{}
+	now  func() time.Time
+}
+
+// NewValidator returns a Validator for the config. The validators declaring the same JWKS url
+// share their key sets
+func NewValidator(cfg Config, c *http.Client) (*Validator, error) {
+	if cfg.JWKURL == "" {
+		return nil, ErrNoJWKURL
+	}
+	v := &Validator{
+		cfg:  cfg,
+		keys: sharedKeySet(cfg.JWKURL, cfg.CacheTTL, c),
+		algs: make(map[string]struct{}, len(cfg.Algorithms)),
+		now:  time.Now,
+	}
+	for _, a := range cfg.Algorithms {
+		if _, ok := algorithms[a]; !ok {
+			return nil, fmt.Errorf("jwt: unsupported algorithm %s", a)
+		}
+		v.algs[a] = struct{}{}
+	}
+	return v, nil
+}
+
+// Config returns the config of the validator
+func (v *Validator) Config() Config {
+	return v.cfg
+}
+
+// ValidateRequest extracts the bearer token of the request and validates it
+func (v *Validator) ValidateRequest(r *http.Request) (Claims, error) {
+	token, err := TokenFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return v.Validate(r.Context(), token)
+}
+
+// Validate verifies the signature of the token and its registered claims
+func (v *Validator) Validate(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrMalformedToken
+	}
+	if _, ok := v.algs[header.Alg]; !ok {
+		return nil, ErrAlgorithmNotAllowed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	key, err := v.keys.Key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if key.Algorithm != "" && key.Algorithm != header.Alg {
+		return nil, ErrAlgorithmNotAllowed
+	}
+	if err := verify(header.Alg, key.Key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	claims := Claims{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformedToken
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Validator) checkClaims(c Claims) error {
+	now := v.now()
+	if exp, ok := c["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(v.cfg.Leeway)) {
+		return ErrExpired
+	}
+	if nbf, ok := c["nbf"].(float64); ok && now.Add(v.cfg.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return ErrNotYetValid
+	}
+	if v.cfg.Issuer != "" {
+		if iss, _ := c["iss"].(string); iss != v.cfg.Issuer {
+			return ErrInvalidIssuer
+		}
+	}
+	if len(v.cfg.Audience) == 0 {
+		return nil
+	}
+	for _, got := range stringList(c["aud"]) {
+		for _, want := range v.cfg.Audience {
+			if got == want {
+				return nil
+			}
+		}
+	}
+	return ErrInvalidAudience
+}
+
+// TokenFromRequest returns the bearer token of the Authorization header
+func TokenFromRequest(r *http.Request) (string, error) {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "bearer ") {
+		return "", ErrNoToken
+	}
+	if t := strings.TrimSpace(h[7:]); t != "" {
+		return t, nil
+	}
+	return "", ErrNoToken
+}
+
+type claimsKey struct{}
+
+// NewContext returns a copy of the context carrying the claims
+func NewContext(ctx context.Context, c Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, c)
+}
+
+// FromContext returns the claims stored in the context, if any
+func FromContext(ctx context.Context) (Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(Claims)
+	return c, ok
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+type algorithm struct {
+	hash crypto.Hash
+	kind string
+}
+
+var algorithms = map[string]algorithm{
+	"RS256": {crypto.SHA256, "RS"},
+	"RS384": {crypto.SHA384, "RS"},
+	"RS512": {crypto.SHA512, "RS"},
+	"PS256": {crypto.SHA256, "PS"},
+	"PS384": {crypto.SHA384, "PS"},
+	"PS512": {crypto.SHA512, "PS"},
+	"ES256": {crypto.SHA256, "ES"},
+	"ES384": {crypto.SHA384, "ES"},
+	"ES512": {crypto.SHA512, "ES"},
+	"HS256": {crypto.SHA256, "HS"},
+	"HS384": {crypto.SHA384, "HS"},
+	"HS512": {crypto.SHA512, "HS"},
+}
+
+func verify(alg string, key interface{}, signed, sig []byte) error {
+	a := algorithms[alg]
+	if a.kind == "HS" {
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrInvalidSignature
+		}
+		mac := hmac.New(a.hash.New, secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+
+	h := a.hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch a.kind {
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrInvalidSignature
+		}
+		var err error
+		if a.kind == "RS" {
+			err = rsa.VerifyPKCS1v15(pub, a.hash, digest, sig)
+		} else {
+			err = rsa.VerifyPSS(pub, a.hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		if err != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrInvalidSignature
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	return ErrAlgorithmNotAllowed
+}
+
+// SetHeaders adds the propagated claims to the headers. The headers of the claims missing in the
+// token are removed, so the clients can not inject them
+func SetHeaders(h http.Header, c Claims, ps []Propagation) {
+	for _, p := range ps {
+		if v, ok := c.Value(p.Claim); ok {
+			h.Set(p.Name, v)
+		} else {
+			h.Del(p.Name)
+		}
+	}
+}
+
+// Unauthorized replies to the request with a 401 Unauthorized and the WWW-Authenticate challenge
+// matching the error
+func Unauthorized(w http.ResponseWriter, err error) {
+	if err == ErrNoToken {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	} else {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+}
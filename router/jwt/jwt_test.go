@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestValidator_Validate(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ts := newJWKSServer(t, map[string]interface{}{
+		"keys": []interface{}{
+			rsaJWK("rsa", &rsaKey.PublicKey),
+			ecJWK("ec", &ecKey.PublicKey),
+			map[string]interface{}{"kty": "oct", "kid": "hmac", "alg": "HS256", "k": b64([]byte("secret"))},
+		},
+	})
+	defer ts.Close()
+
+	cfg, ok := ConfigGetter(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"jwk_url":  ts.URL,
+			"alg":      []interface{}{"RS256", "ES256", "HS256"},
+			"issuer":   "https://issuer",
+			"audience": []interface{}{"api"},
+			"leeway":   "1s",
+		},
+	})
+	if !ok {
+		t.Fatal("the config should be parsed")
+	}
+	v, err := NewValidator(cfg, ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	valid := map[string]interface{}{"iss": "https://issuer", "aud": "api", "sub": "1234", "exp": now + 60}
+
+	for name, tc := range map[string]struct {
+		token string
+		err   error
+	}{
+		"rsa":             {token: signRSA(t, rsaKey, "rsa", valid)},
+		"ec":              {token: signEC(t, ecKey, "ec", valid)},
+		"hmac":            {token: signHMAC(t, []byte("secret"), "hmac", "HS256", valid)},
+		"wrong key":       {token: signHMAC(t, []byte("other"), "hmac", "HS256", valid), err: ErrInvalidSignature},
+		"alg not allowed": {token: signHMAC(t, []byte("secret"), "hmac", "HS512", valid), err: ErrAlgorithmNotAllowed},
+		"alg confusion":   {token: signHMAC(t, []byte("secret"), "rsa", "HS256", valid), err: ErrAlgorithmNotAllowed},
+		"unknown kid":     {token: signRSA(t, rsaKey, "unknown", valid), err: ErrUnknownKey},
+		"malformed":       {token: "a.b", err: ErrMalformedToken},
+		"expired": {
+			token: signRSA(t, rsaKey, "rsa", map[string]interface{}{"iss": "https://issuer", "aud": "api", "exp": now - 10}),
+			err:   ErrExpired,
+		},
+		"not yet valid": {
+			token: signRSA(t, rsaKey, "rsa", map[string]interface{}{"iss": "https://issuer", "aud": "api", "nbf": now + 10}),
+			err:   ErrNotYetValid,
+		},
+		"wrong issuer": {
+			token: signRSA(t, rsaKey, "rsa", map[string]interface{}{"iss": "https://other", "aud": "api"}),
+			err:   ErrInvalidIssuer,
+		},
+		"wrong audience": {
+			token: signRSA(t, rsaKey, "rsa", map[string]interface{}{"iss": "https://issuer", "aud": []interface{}{"x", "y"}}),
+			err:   ErrInvalidAudience,
+		},
+	} {
+		claims, err := v.Validate(context.Background(), tc.token)
+		if err != tc.err {
+			t.Errorf("%s: unexpected error. have: %v, want: %v", name, err, tc.err)
+			continue
+		}
+		if err == nil && claims["sub"] != "1234" {
+			t.Errorf("%s: unexpected claims: %v", name, claims)
+		}
+	}
+}
+
+func TestClaims_Value(t *testing.T) {
+	c := Claims{
+		"sub":   "1234",
+		"admin": true,
+		"level": float64(3),
+		"realm": map[string]interface{}{"roles": []interface{}{"a", "b"}},
+	}
+	for name, want := range map[string]string{"sub": "1234", "admin": "true", "level": "3", "realm.roles": "a,b"} {
+		if v, ok := c.Value(name); !ok || v != want {
+			t.Errorf("%s: unexpected value %q", name, v)
+		}
+	}
+	if _, ok := c.Value("realm.missing"); ok {
+		t.Error("missing claims should not be found")
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	h := http.Header{"X-User": {"spoofed"}, "X-Role": {"spoofed"}}
+	SetHeaders(h, Claims{"sub": "1234"}, []Propagation{{Claim: "sub", Name: "X-User"}, {Claim: "role", Name: "X-Role"}})
+	if h.Get("X-User") != "1234" {
+		t.Errorf("unexpected header: %s", h.Get("X-User"))
+	}
+	if _, ok := h["X-Role"]; ok {
+		t.Error("the headers of the missing claims should be removed")
+	}
+}
+
+func TestTokenFromRequest(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", http.NoBody)
+	if _, err := TokenFromRequest(r); err != ErrNoToken {
+		t.Errorf("unexpected error: %v", err)
+	}
+	r.Header.Set("Authorization", "bearer abc")
+	if tok, err := TokenFromRequest(r); err != nil || tok != "abc" {
+		t.Errorf("unexpected token: %s %v", tok, err)
+	}
+}
+
+func newJWKSServer(t *testing.T, jwks map[string]interface{}) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(jwks)
+	}))
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, k *rsa.PublicKey) map[string]interface{} {
+	return map[string]interface{}{
+		"kty": "RSA",
+		"kid": kid,
+		"alg": "RS256",
+		"n":   b64(k.N.Bytes()),
+		"e":   b64(big.NewInt(int64(k.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, k *ecdsa.PublicKey) map[string]interface{} {
+	return map[string]interface{}{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   b64(k.X.Bytes()),
+		"y":   b64(k.Y.Bytes()),
+	}
+}
+
+func signingInput(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	h, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b64(h) + "." + b64(c)
+}
+
+func signRSA(t *testing.T, k *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	in := signingInput(t, "RS256", kid, claims)
+	h := crypto.SHA256.New()
+	h.Write([]byte(in))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, h.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return in + "." + b64(sig)
+}
+
+func signEC(t *testing.T, k *ecdsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	in := signingInput(t, "ES256", kid, claims)
+	h := crypto.SHA256.New()
+	h.Write([]byte(in))
+	r, s, err := ecdsa.Sign(rand.Reader, k, h.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return in + "." + b64(sig)
+}
+
+func signHMAC(t *testing.T, secret []byte, kid, alg string, claims map[string]interface{}) string {
+	t.Helper()
+	in := signingInput(t, alg, kid, claims)
+	hash := crypto.SHA256
+	if alg == "HS512" {
+		hash = crypto.SHA512
+	}
+	mac := hmac.New(hash.New, secret)
+	mac.Write([]byte(in))
+	return in + "." + b64(mac.Sum(nil))
+}
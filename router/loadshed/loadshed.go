@@ -0,0 +1,299 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package loadshed keeps the gateway stable under overload, rejecting the requests of the low
+priority endpoints when the pipeline is saturated.
+
+The thresholds are declared in the service extra config. The load is the highest of the ratios
+between the requests in flight and max_in_flight and between the p99 latency of the last
+window and max_p99_latency. The endpoints of every priority class are rejected while the load
+reaches the threshold of their class:
+
+	"extra_config": {
+		"github.com/luraproject/lura/router/loadshed": {
+			"max_in_flight": 2000,
+			"max_p99_latency": "800ms",
+			"window": "10s",
+			"thresholds": {"low": 0.7, "normal": 1.0}
+		}
+	}
+
+The endpoints declare their priority class (critical, high, normal or low) in their extra
+config and the ones not declaring it are normal:
+
+	"extra_config": {
+		"github.com/luraproject/lura/router/loadshed": {
+			"priority": "low"
+		}
+	}
+
+The low and the normal classes are rejected at the 0.8 and 1.0 loads by default and the high
+and the critical ones are never rejected unless they declare a threshold.
+*/
+package loadshed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the load shedding config
+const Namespace = "github.com/luraproject/lura/router/loadshed"
+
+// The priority classes of the endpoints
+const (
+	PriorityLow      = "low"
+	PriorityNormal   = "normal"
+	PriorityHigh     = "high"
+	PriorityCritical = "critical"
+)
+
+const (
+	defaultWindow = 10 * time.Second
+	// maxSamples bounds the latencies kept to compute the p99
+	maxSamples = 4096
+	// refreshInterval is the time the computed p99 is cached for
+	refreshInterval = 100 * time.Millisecond
+)
+
+var defaultThresholds = map[string]float64{
+	PriorityLow:    0.8,
+	PriorityNormal: 1.0,
+}
+
+// ErrOverloaded is returned when a request is rejected to shed the load
+var ErrOverloaded = OverloadedError{}
+
+// OverloadedError is the error returned when a request is rejected to shed the load
+type OverloadedError struct{}
+
+// Error implements the error interface
+func (OverloadedError) Error() string { return "loadshed: the gateway is overloaded" }
+
+// StatusCode returns the 503 Service Unavailable status code
+func (OverloadedError) StatusCode() int { return http.StatusServiceUnavailable }
+
+// Config is the load shedding config of the service
+type Config struct {
+	MaxInFlight   int                `json:"max_in_flight"`
+	MaxP99Latency string             `json:"max_p99_latency"`
+	Window        string             `json:"window"`
+	Thresholds    map[string]float64 `json:"thresholds"`
+}
+
+// EndpointConfig is the load shedding config of an endpoint
+type EndpointConfig struct {
+	Priority string `json:"priority"`
+}
+
+// ConfigGetter parses the load shedding config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	ok, err := parse(e, &cfg)
+	return cfg, ok, err
+}
+
+// EndpointPriority returns the priority class declared by the endpoint, or the normal one
+func EndpointPriority(cfg *config.EndpointConfig) (string, error) {
+	var c EndpointConfig
+	if _, err := parse(cfg.ExtraConfig, &c); err != nil {
+		return PriorityNormal, err
+	}
+	switch c.Priority {
+	case "":
+		return PriorityNormal, nil
+	case PriorityLow, PriorityNormal, PriorityHigh, PriorityCritical:
+		return c.Priority, nil
+	}
+	return PriorityNormal, fmt.Errorf("loadshed: unknown priority %q", c.Priority)
+}
+
+func parse(e config.ExtraConfig, v interface{}) (bool, error) {
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return true, err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return true, fmt.Errorf("loadshed: parsing the config: %w", err)
+	}
+	return true, nil
+}
+
+// Controller admits or rejects the requests by the load of the gateway and their priority
+type Controller struct {
+	maxInFlight int64
+	maxP99      time.Duration
+	window      time.Duration
+	thresholds  map[string]float64
+	now         func() time.Time
+
+	inFlight int64
+
+	mu        sync.Mutex
+	samples   []sample
+	next      int
+	p99       time.Duration
+	refreshed time.Time
+}
+
+type sample struct {
+	at      time.Time
+	latency time.Duration
+}
+
+// New returns the Controller of the config
+func New(cfg Config) (*Controller, error) {
+	if cfg.MaxInFlight < 0 {
+		return nil, errors.New("loadshed: the max_in_flight can not be negative")
+	}
+	c := &Controller{
+		maxInFlight: int64(cfg.MaxInFlight),
+		window:      defaultWindow,
+		thresholds:  map[string]float64{},
+		now:         time.Now,
+	}
+	if cfg.MaxP99Latency != "" {
+		d, err := time.ParseDuration(cfg.MaxP99Latency)
+		if err != nil {
+			return nil, fmt.Errorf("loadshed: parsing the max_p99_latency: %w", err)
+		}
+		c.maxP99 = d
+	}
+	if c.maxInFlight == 0 && c.maxP99 <= 0 {
+		return nil, errors.New("loadshed: no max_in_flight nor max_p99_latency declared")
+	}
+	if cfg.Window != "" {
+		d, err := time.ParseDuration(cfg.Window)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("loadshed: invalid window %q", cfg.Window)
+		}
+		c.window = d
+	}
+	for k, v := range defaultThresholds {
+		c.thresholds[k] = v
+	}
+	for k, v := range cfg.Thresholds {
+		switch k {
+		case PriorityLow, PriorityNormal, PriorityHigh, PriorityCritical:
+		default:
+			return nil, fmt.Errorf("loadshed: unknown priority %q", k)
+		}
+		if v <= 0 {
+			return nil, fmt.Errorf("loadshed: invalid threshold %v for the %s priority", v, k)
+		}
+		c.thresholds[k] = v
+	}
+	return c, nil
+}
+
+// Admit admits the request of an endpoint with the received priority, unless the load reached
+// the threshold of the priority. The returned function must be called once the request is
+// done, so its latency is recorded
+func (c *Controller) Admit(priority string) (func(), error) {
+	if threshold, ok := c.thresholds[priority]; ok && c.Load() >= threshold {
+		return nil, ErrOverloaded
+	}
+	atomic.AddInt64(&c.inFlight, 1)
+	start := c.now()
+	return func() {
+		atomic.AddInt64(&c.inFlight, -1)
+		end := c.now()
+		c.record(end, end.Sub(start))
+	}, nil
+}
+
+// Load returns the current load of the gateway, where 1 means the thresholds are reached
+func (c *Controller) Load() float64 {
+	var load float64
+	if c.maxInFlight > 0 {
+		load = float64(atomic.LoadInt64(&c.inFlight)) / float64(c.maxInFlight)
+	}
+	if c.maxP99 > 0 {
+		if l := float64(c.P99()) / float64(c.maxP99); l > load {
+			load = l
+		}
+	}
+	return load
+}
+
+// InFlight returns the number of requests in flight
+func (c *Controller) InFlight() int { return int(atomic.LoadInt64(&c.inFlight)) }
+
+// P99 returns the p99 latency of the requests completed during the last window
+func (c *Controller) P99() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if now.Sub(c.refreshed) < refreshInterval {
+		return c.p99
+	}
+	c.refreshed = now
+	since := now.Add(-c.window)
+	latencies := make([]time.Duration, 0, len(c.samples))
+	for _, s := range c.samples {
+		if s.at.After(since) {
+			latencies = append(latencies, s.latency)
+		}
+	}
+	if len(latencies) == 0 {
+		c.p99 = 0
+		return 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	c.p99 = latencies[(len(latencies)*99-1)/100]
+	return c.p99
+}
+
+func (c *Controller) record(at time.Time, latency time.Duration) {
+	c.mu.Lock()
+	if len(c.samples) < maxSamples {
+		c.samples = append(c.samples, sample{at: at, latency: latency})
+	} else {
+		c.samples[c.next] = sample{at: at, latency: latency}
+		c.next = (c.next + 1) % maxSamples
+	}
+	c.mu.Unlock()
+}
+
+var (
+	global   *Controller
+	globalMu sync.RWMutex
+)
+
+// Register creates the controller declared in the service extra config. It returns false if
+// the service does not declare the load shedding config
+func Register(cfg config.ServiceConfig) (bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	var ctrl *Controller
+	if ok && err == nil {
+		ctrl, err = New(c)
+	}
+	SetGlobal(ctrl)
+	return ok, err
+}
+
+// SetGlobal sets the controller used by the router
+func SetGlobal(c *Controller) {
+	globalMu.Lock()
+	global = c
+	globalMu.Unlock()
+}
+
+// GetGlobal returns the controller used by the router, if any
+func GetGlobal() (*Controller, bool) {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return global, global != nil
+}
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package loadshed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestController_inFlight(t *testing.T) {
+	c, err := New(Config{MaxInFlight: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dones []func()
+	for i := 0; i < 8; i++ {
+		done, err := c.Admit(PriorityCritical)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		dones = append(dones, done)
+	}
+
+	if _, err := c.Admit(PriorityLow); err != ErrOverloaded {
+		t.Errorf("the low priority requests should be rejected at the 0.8 load. have: %v", err)
+	}
+	done, err := c.Admit(PriorityNormal)
+	if err != nil {
+		t.Errorf("the normal priority requests should be admitted at the 0.8 load. have: %v", err)
+	}
+	dones = append(dones, done)
+
+	done, err = c.Admit(PriorityHigh)
+	if err != nil {
+		t.Errorf("the high priority requests should be admitted. have: %v", err)
+	}
+	dones = append(dones, done)
+
+	if _, err := c.Admit(PriorityNormal); err != ErrOverloaded {
+		t.Errorf("the normal priority requests should be rejected at the 1.0 load. have: %v", err)
+	}
+	if _, err := c.Admit(PriorityCritical); err != nil {
+		t.Errorf("the critical priority requests should never be rejected. have: %v", err)
+	}
+
+	for _, done := range dones {
+		done()
+	}
+	if _, err := c.Admit(PriorityLow); err != nil {
+		t.Errorf("the low priority requests should be admitted once the load decreases. have: %v", err)
+	}
+}
+
+func TestController_p99(t *testing.T) {
+	now := time.Now()
+	c, err := New(Config{MaxP99Latency: "100ms", Window: "1s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		done, err := c.Admit(PriorityCritical)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		now = now.Add(200 * time.Millisecond)
+		done()
+	}
+
+	if p99 := c.P99(); p99 != 200*time.Millisecond {
+		t.Errorf("unexpected p99: %v", p99)
+	}
+	if _, err := c.Admit(PriorityNormal); err != ErrOverloaded {
+		t.Errorf("the normal priority requests should be rejected. have: %v", err)
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, err := c.Admit(PriorityLow); err != nil {
+		t.Errorf("the old latencies should be out of the window. have: %v", err)
+	}
+}
+
+func TestNew_invalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{},
+		{MaxInFlight: -1},
+		{MaxP99Latency: "fast"},
+		{MaxInFlight: 10, Window: "-1s"},
+		{MaxInFlight: 10, Thresholds: map[string]float64{"urgent": 0.5}},
+		{MaxInFlight: 10, Thresholds: map[string]float64{PriorityLow: 0}},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("expecting an error for %+v", cfg)
+		}
+	}
+}
+
+func TestEndpointPriority(t *testing.T) {
+	for _, tc := range []struct {
+		extra    config.ExtraConfig
+		priority string
+		err      bool
+	}{
+		{extra: config.ExtraConfig{}, priority: PriorityNormal},
+		{extra: config.ExtraConfig{Namespace: map[string]interface{}{"priority": "low"}}, priority: PriorityLow},
+		{extra: config.ExtraConfig{Namespace: map[string]interface{}{"priority": "urgent"}}, priority: PriorityNormal, err: true},
+	} {
+		priority, err := EndpointPriority(&config.EndpointConfig{ExtraConfig: tc.extra})
+		if priority != tc.priority {
+			t.Errorf("unexpected priority: %s", priority)
+		}
+		if (err != nil) != tc.err {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer SetGlobal(nil)
+
+	ok, err := Register(config.ServiceConfig{})
+	if ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	if _, ok := GetGlobal(); ok {
+		t.Error("no controller expected")
+	}
+
+	ok, err = Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{"max_in_flight": 100},
+	}})
+	if !ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	if _, ok := GetGlobal(); !ok {
+		t.Error("controller expected")
+	}
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package methodoverride lets the clients stuck behind POST-only proxies reach the endpoints
+declared with other methods, by sending the actual method in the X-HTTP-Method-Override header
+or in the _method query string param.
+
+The override is declared in the service extra config:
+
+	"extra_config": {
+		"github.com/luraproject/lura/router/methodoverride": {
+			"methods": ["PUT", "PATCH", "DELETE"],
+			"header": "X-HTTP-Method-Override",
+			"query_param": "_method"
+		}
+	}
+
+Only the POST requests are overridden, and only with one of the allowed methods. The requests
+asking for any other method are rejected with a 405 Method Not Allowed. The override is applied
+before routing the request, so it reaches the endpoint declared with the overriding method.
+*/
+package methodoverride
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the method override config
+const Namespace = "github.com/luraproject/lura/router/methodoverride"
+
+const (
+	// DefaultHeader is the header checked when the config does not declare it
+	DefaultHeader = "X-HTTP-Method-Override"
+	// DefaultQueryParam is the query string param checked when the config does not declare it
+	DefaultQueryParam = "_method"
+)
+
+// DefaultMethods are the override targets allowed when the config does not declare them
+var DefaultMethods = []string{http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// Config is the method override config
+type Config struct {
+	Methods    []string `json:"methods"`
+	Header     string   `json:"header"`
+	QueryParam string   `json:"query_param"`
+}
+
+// ConfigGetter parses the method override config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("methodoverride: parsing the config: %w", err)
+	}
+	return cfg, true, nil
+}
+
+// Override rewrites the method of the overridden requests
+type Override struct {
+	methods    map[string]struct{}
+	header     string
+	queryParam string
+}
+
+// New returns an Override applying the config
+func New(cfg Config) (*Override, error) {
+	o := &Override{
+		methods:    map[string]struct{}{},
+		header:     http.CanonicalHeaderKey(strings.TrimSpace(cfg.Header)),
+		queryParam: strings.TrimSpace(cfg.QueryParam),
+	}
+	if o.header == "" {
+		o.header = DefaultHeader
+	}
+	if o.queryParam == "" {
+		o.queryParam = DefaultQueryParam
+	}
+	methods := cfg.Methods
+	if len(methods) == 0 {
+		methods = DefaultMethods
+	}
+	for _, m := range methods {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		switch m {
+		case http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			return nil, fmt.Errorf("methodoverride: unsupported method %s", m)
+		}
+		o.methods[m] = struct{}{}
+	}
+	return o, nil
+}
+
+// FromConfig returns the Override declared in the service config. It returns false if the
+// service does not declare the method override
+func FromConfig(cfg config.ServiceConfig) (*Override, bool, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	res, err := New(c)
+	return res, true, err
+}
+
+// Handler decorates the handler with the method override. It implements the mux
+// HandlerMiddleware interface
+func (o *Override) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !o.Apply(r) {
+			http.Error(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Apply sets the overriding method of the request, removing the header and the query string
+// param declaring it, so they are not forwarded to the backends. It returns false if the
+// request asks for a method not allowed
+func (o *Override) Apply(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return true
+	}
+	method := r.Header.Get(o.header)
+	query := r.URL.Query()
+	if method == "" {
+		method = query.Get(o.queryParam)
+	}
+	if method == "" {
+		return true
+	}
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if _, ok := o.methods[method]; !ok {
+		return false
+	}
+
+	r.Method = method
+	r.Header.Del(o.header)
+	if _, ok := query[o.queryParam]; ok {
+		query.Del(o.queryParam)
+		r.URL.RawQuery = query.Encode()
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package methodoverride
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestOverride_Handler(t *testing.T) {
+	o, err := New(Config{Methods: []string{"put", "DELETE"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var method, query, header string
+	h := o.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		method, query, header = r.Method, r.URL.RawQuery, r.Header.Get(DefaultHeader)
+	}))
+
+	for _, tc := range []struct {
+		method, url, header string
+		status              int
+		expected, query     string
+	}{
+		{method: "POST", url: "/a", status: http.StatusOK, expected: "POST"},
+		{method: "POST", url: "/a", header: "put", status: http.StatusOK, expected: "PUT"},
+		{method: "POST", url: "/a?_method=DELETE&b=1", status: http.StatusOK, expected: "DELETE", query: "b=1"},
+		{method: "POST", url: "/a?_method=DELETE", header: "PUT", status: http.StatusOK, expected: "PUT"},
+		{method: "GET", url: "/a?_method=DELETE", status: http.StatusOK, expected: "GET", query: "_method=DELETE"},
+		{method: "POST", url: "/a", header: "PATCH", status: http.StatusMethodNotAllowed},
+		{method: "POST", url: "/a?_method=CONNECT", status: http.StatusMethodNotAllowed},
+	} {
+		method, query, header = "", "", ""
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.url, http.NoBody)
+		if tc.header != "" {
+			req.Header.Set(DefaultHeader, tc.header)
+		}
+		h.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("%s %s: unexpected status %d", tc.method, tc.url, w.Code)
+			continue
+		}
+		if method != tc.expected || query != tc.query || header != "" {
+			t.Errorf("%s %s: unexpected request %s %s %s", tc.method, tc.url, method, query, header)
+		}
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	if _, ok, err := FromConfig(config.ServiceConfig{}); ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+
+	o, ok, err := FromConfig(config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{"header": "x-method", "query_param": "m"},
+	}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result: %v %v", ok, err)
+	}
+	req := httptest.NewRequest("POST", "/a?m=patch", http.NoBody)
+	if !o.Apply(req) || req.Method != http.MethodPatch {
+		t.Errorf("unexpected method %s", req.Method)
+	}
+	req = httptest.NewRequest("POST", "/a", http.NoBody)
+	req.Header.Set("X-Method", "DELETE")
+	if !o.Apply(req) || req.Method != http.MethodDelete {
+		t.Errorf("unexpected method %s", req.Method)
+	}
+
+	if _, _, err := FromConfig(config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{"methods": []interface{}{"CONNECT"}},
+	}}); err == nil {
+		t.Error("expecting an error")
+	}
+}
@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/accesslog"
+)
+
+// NewAccessLogHandlerFactory decorates the handlers of the endpoints, so the sampled requests are
+// written to the access log. It does nothing when the service does not declare an access log
+func NewAccessLogHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		handler := hf(cfg, p)
+		l, ok := accesslog.GetGlobal()
+		if !ok {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][AccessLog]"
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !l.Sampled() {
+				handler(w, r)
+				return
+			}
+			e := accesslog.NewEntry(r, cfg)
+			aw := &accessLogWriter{statusWriter: statusWriter{ResponseWriter: w, status: http.StatusOK}}
+			handler(aw, r.WithContext(accesslog.NewContext(r.Context(), e)))
+
+			e.Status, e.Bytes, e.Latency = aw.status, aw.bytes, time.Since(e.Time)
+			if err := l.Log(e); err != nil {
+				logger.Error(logPrefix, "Unable to write the entry:", err.Error())
+			}
+		}
+	}
+}
+
+// accessLogWriter records the status code and the size of the response
+type accessLogWriter struct {
+	statusWriter
+	bytes int64
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.statusWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
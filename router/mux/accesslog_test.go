@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/accesslog"
+)
+
+func TestNewAccessLogHandlerFactory(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l, err := accesslog.New(accesslog.Config{Format: accesslog.JSONFormat, Fields: accesslog.DefaultFields}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accesslog.SetGlobal(l)
+	defer accesslog.SetGlobal(nil)
+
+	cfg := &config.EndpointConfig{
+		Endpoint: "/logged",
+		Method:   "GET",
+		Timeout:  time.Second,
+		Backend:  []*config.Backend{{}},
+	}
+	p := func(ctx context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		accesslog.SetClientID(ctx, "client-1")
+		return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+	handler := NewAccessLogHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/logged", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	handler(w, req)
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("unexpected entry %s: %v", buf.String(), err)
+	}
+	for k, v := range map[string]interface{}{
+		"remote_addr": "10.0.0.1",
+		"endpoint":    "/logged",
+		"status":      200.0,
+		"bytes":       float64(w.Body.Len()),
+		"backends":    1.0,
+		"client_id":   "client-1",
+	} {
+		if m[k] != v {
+			t.Errorf("unexpected value for %s: %v", k, m[k])
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/accesslog"
+	"github.com/luraproject/lura/v2/router/apikey"
+)
+
+// NewAPIKeyHandlerFactory decorates the handlers of the endpoints declaring the apikey extra
+// config, so the requests without a valid key are rejected before reaching the proxy stage and
+// the identity of the client is added to the context of the accepted ones
+func NewAPIKeyHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		keyCfg, ok := apikey.EndpointConfigGetter(cfg.ExtraConfig)
+		if !ok {
+			return hf(cfg, p)
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][APIKey]"
+		if _, ok := apikey.GetStore(); !ok {
+			logger.Error(logPrefix, "Rejecting all the requests:", apikey.ErrNoStore.Error())
+		}
+		auth := apikey.NewAuthenticator(keyCfg)
+		handler := hf(cfg, p)
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			identity, err := auth.Authenticate(r)
+			if err != nil {
+				logger.Debug(logPrefix, err.Error())
+				w.WriteHeader(apikey.StatusCode(err))
+				return
+			}
+			auth.SetClientIDHeader(r.Header, identity)
+			accesslog.SetClientID(r.Context(), identity.ClientID)
+			handler(w, r.WithContext(apikey.NewContext(r.Context(), identity)))
+		}
+	}
+}
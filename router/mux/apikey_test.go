@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/apikey"
+)
+
+func TestNewAPIKeyHandlerFactory(t *testing.T) {
+	s, err := apikey.NewStaticStore([]apikey.KeyDefinition{{Key: "secret", Identity: apikey.Identity{ClientID: "mobile"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	apikey.SetStore(s)
+	defer apikey.SetStore(nil)
+
+	cfg := &config.EndpointConfig{
+		Endpoint:      "/orders",
+		Method:        "GET",
+		Timeout:       time.Second,
+		HeadersToPass: []string{"X-Client-Id"},
+		ExtraConfig: config.ExtraConfig{
+			apikey.Namespace: map[string]interface{}{"client_id_header": "X-Client-Id"},
+		},
+	}
+
+	var clientID string
+	var received *proxy.Request
+	p := func(ctx context.Context, r *proxy.Request) (*proxy.Response, error) {
+		received = r
+		if i, ok := apikey.FromContext(ctx); ok {
+			clientID = i.ClientID
+		}
+		return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+	handler := NewAPIKeyHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/orders", http.NoBody)
+	req.Header.Set(apikey.DefaultHeader, "wrong")
+	handler(w, req)
+	if w.Code != http.StatusUnauthorized || received != nil {
+		t.Errorf("the requests with unknown keys should be rejected. status: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/orders", http.NoBody)
+	req.Header.Set(apikey.DefaultHeader, "secret")
+	req.Header.Set("X-Client-Id", "spoofed")
+	handler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", w.Code)
+	}
+	if clientID != "mobile" {
+		t.Errorf("unexpected identity in the proxy context: %s", clientID)
+	}
+	if v := received.Headers["X-Client-Id"]; len(v) != 1 || v[0] != "mobile" {
+		t.Errorf("unexpected client id header: %v", v)
+	}
+}
@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/consistency"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+// NewConsistencyHandlerFactory decorates the handlers of the endpoints with backends declaring
+// primary hosts, so the requests carrying a valid routing hint are sent to them
+func NewConsistencyHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		handler := hf(cfg, p)
+		if !consistency.UsesPrimaries(cfg) {
+			return handler
+		}
+		logger.Debug("[ENDPOINT: "+cfg.Endpoint+"][Consistency]", "Routing the hinted requests to the primary hosts")
+		h := consistency.GetGlobal()
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			if h.Hinted(r) {
+				r = r.WithContext(consistency.WithPrimary(r.Context()))
+			}
+			handler(w, r)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/consistency"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestNewConsistencyHandlerFactory(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint: "/orders",
+		Method:   "GET",
+		Timeout:  time.Second,
+		Backend: []*config.Backend{{ExtraConfig: config.ExtraConfig{consistency.Namespace: map[string]interface{}{
+			"primary_hosts": []interface{}{"http://primary"},
+		}}}},
+	}
+	var primary bool
+	p := func(ctx context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		primary = consistency.RequiresPrimary(ctx)
+		return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+	handler := NewConsistencyHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)
+
+	req, _ := http.NewRequest("GET", "/orders", nil)
+	handler(httptest.NewRecorder(), req)
+	if primary {
+		t.Error("the request without a hint should not require the primary")
+	}
+
+	req, _ = http.NewRequest("GET", "/orders", nil)
+	req.Header.Set(consistency.DefaultHeader, consistency.GetGlobal().Token())
+	handler(httptest.NewRecorder(), req)
+	if !primary {
+		t.Error("the hinted request should require the primary")
+	}
+
+	req, _ = http.NewRequest("GET", "/orders", nil)
+	req.Header.Set(consistency.DefaultHeader, "1")
+	handler(httptest.NewRecorder(), req)
+	if primary {
+		t.Error("the request with an expired hint should not require the primary")
+	}
+}
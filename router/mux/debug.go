@@ -11,6 +11,9 @@ import (
 )
 
 // DebugHandler creates a dummy handler function, useful for quick integration tests
+//
+// Deprecated: the routers serve the debug endpoint with debug.NewHandler, echoing the requests
+// and the requests built for the backends of the matching endpoint
 func DebugHandler(logger logging.Logger) http.HandlerFunc {
 	logPrefixSecondary := "[ENDPOINT /__debug/*]"
 	return func(w http.ResponseWriter, r *http.Request) {
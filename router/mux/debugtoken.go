@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/debugtoken"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/tracing"
+)
+
+// NewDebugTokenHandlerFactory decorates the handlers of the endpoints, so the requests carrying a
+// valid debug token get the overrides it grants. The invalid tokens are logged and ignored
+func NewDebugTokenHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		handler := hf(cfg, p)
+		v, ok := debugtoken.GetGlobal()
+		if !ok {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][DebugToken]"
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			c, ok, err := v.FromRequest(r, cfg.Endpoint)
+			if err != nil {
+				logger.Warning(logPrefix, "Ignoring the debug token:", err.Error())
+			}
+			if !ok {
+				handler(w, r)
+				return
+			}
+			logger.Info(logPrefix, fmt.Sprintf("Debug overrides: trace=%t no_cache=%t host=%q", c.Trace, c.NoCache, c.Host))
+			ctx := debugtoken.NewContext(r.Context(), c)
+			if c.Trace {
+				ctx = tracing.WithForcedSampling(ctx)
+			}
+			handler(w, r.WithContext(ctx))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/debugtoken"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestNewDebugTokenHandlerFactory(t *testing.T) {
+	v, _ := debugtoken.New(debugtoken.Config{Secret: "s3cr3t"})
+	debugtoken.SetGlobal(v)
+	defer debugtoken.SetGlobal(nil)
+
+	cfg := &config.EndpointConfig{Endpoint: "/users", Method: "GET", Timeout: time.Second}
+	var (
+		claims debugtoken.Claims
+		ok     bool
+	)
+	p := func(ctx context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		claims, ok = debugtoken.FromContext(ctx)
+		return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+	handler := NewDebugTokenHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)
+
+	for _, tc := range []struct {
+		token    string
+		expected bool
+	}{
+		{"", false},
+		{"invalid", false},
+		{v.Issue(debugtoken.Claims{NoCache: true, Endpoint: "/orders"}, time.Minute), false},
+		{v.Issue(debugtoken.Claims{NoCache: true}, time.Minute), true},
+	} {
+		req, _ := http.NewRequest("GET", "/users", nil)
+		if tc.token != "" {
+			req.Header.Set(debugtoken.DefaultHeader, tc.token)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("unexpected status %d", w.Code)
+		}
+		if ok != tc.expected || ok && !claims.NoCache {
+			t.Errorf("%q: unexpected claims %+v", tc.token, claims)
+		}
+	}
+}
@@ -7,23 +7,29 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"net/textproto"
 	"strings"
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/core"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/etag"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router"
+	"github.com/luraproject/lura/v2/router/bodylimit"
+	"github.com/luraproject/lura/v2/router/errorencoder"
+	"github.com/luraproject/lura/v2/router/forwarded"
+	"github.com/luraproject/lura/v2/router/pathparams"
+	"github.com/luraproject/lura/v2/router/redirect"
+	"github.com/luraproject/lura/v2/router/requestschema"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
-const requestParamsAsterisk string = "*"
-
 // HandlerFactory creates a handler function that adapts the mux router with the injected proxy
 type HandlerFactory func(*config.EndpointConfig, proxy.Proxy) http.HandlerFunc
 
 // EndpointHandler is a HandlerFactory that adapts the mux router with the injected proxy
-// and the default RequestBuilder
-var EndpointHandler = CustomEndpointHandler(NewRequest)
+// and the default FilteredRequestBuilder
+var EndpointHandler = CustomFilteredEndpointHandler(NewFilteredRequest)
 
 // CustomEndpointHandler returns a HandlerFactory with the received RequestBuilder using the default ToHTTPError function
 func CustomEndpointHandler(rb RequestBuilder) HandlerFactory {
@@ -32,28 +38,131 @@ func CustomEndpointHandler(rb RequestBuilder) HandlerFactory {
 
 // CustomEndpointHandlerWithHTTPError returns a HandlerFactory with the received RequestBuilder
 func CustomEndpointHandlerWithHTTPError(rb RequestBuilder, errF server.ToHTTPError) HandlerFactory {
+	return newEndpointHandler(func(queryString, headersToSend []string) func(*http.Request) *proxy.Request {
+		return func(r *http.Request) *proxy.Request {
+			return rb(r, queryString, headersToSend)
+		}
+	}, errF)
+}
+
+// CustomFilteredEndpointHandler returns a HandlerFactory with the received FilteredRequestBuilder
+// using the default ToHTTPError function
+func CustomFilteredEndpointHandler(rb FilteredRequestBuilder) HandlerFactory {
+	return CustomFilteredEndpointHandlerWithHTTPError(rb, server.DefaultToHTTPError)
+}
+
+// CustomFilteredEndpointHandlerWithHTTPError returns a HandlerFactory with the received
+// FilteredRequestBuilder. The filters of the query string params and the headers are built once
+// per endpoint
+func CustomFilteredEndpointHandlerWithHTTPError(rb FilteredRequestBuilder, errF server.ToHTTPError) HandlerFactory {
+	return newEndpointHandler(func(queryString, headersToSend []string) func(*http.Request) *proxy.Request {
+		query := config.NewParamsFilter(queryString)
+		headers := config.NewHeadersFilter(headersToSend)
+		return func(r *http.Request) *proxy.Request {
+			return rb(r, query, headers)
+		}
+	}, errF)
+}
+
+// newEndpointHandler returns a HandlerFactory building the requests of every endpoint with the
+// function returned by newRequest for its query string params and headers
+func newEndpointHandler(newRequest func(queryString, headersToSend []string) func(*http.Request) *proxy.Request, errF server.ToHTTPError) HandlerFactory {
 	return func(configuration *config.EndpointConfig, prxy proxy.Proxy) http.HandlerFunc {
 		cacheControlHeaderValue := fmt.Sprintf("public, max-age=%d", int(configuration.CacheTTL.Seconds()))
 		isCacheEnabled := configuration.CacheTTL.Seconds() != 0
 		render := getRender(configuration)
+		negotiated := isNegotiated(configuration)
+		hasSuccessStatus := proxy.HasSuccessStatus(configuration)
 
 		headersToSend := configuration.HeadersToPass
 		if len(headersToSend) == 0 {
 			headersToSend = server.HeadersToSend
 		}
+		if configuration.OutputEncoding == encoding.NOOP {
+			// the range requests are forwarded, so the clients can resume the downloads
+			headersToSend = config.WithRangeHeaders(headersToSend)
+		}
+		buildRequest := newRequest(configuration.QueryString, headersToSend)
 		method := strings.ToTitle(configuration.Method)
+		bodyLimit, hasBodyLimit, bodyLimitErr := bodylimit.ConfigGetter(configuration.ExtraConfig)
+		validator, hasValidator, validatorErr := pathparams.New(configuration)
+		schema, hasSchema, schemaErr := requestschema.New(configuration)
+		optionalParams := router.OptionalParams(configuration.Endpoint)
+		tags, hasTags, tagsErr := etag.ConfigGetter(configuration.ExtraConfig)
+		hasTags = hasTags && tagsErr == nil
+		encoder, hasEncoder := errorencoder.GetGlobal()
+		writeError := func(w http.ResponseWriter, r *http.Request, status int, err error) {
+			if hasEncoder {
+				encoder.Write(r.Context(), w, r.URL.Path, status, err)
+				return
+			}
+			msg := ""
+			if err != nil {
+				msg = err.Error()
+			}
+			http.Error(w, msg, status)
+		}
 
 		return func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set(core.KrakendHeaderName, core.KrakendHeaderValue)
 			if r.Method != method {
 				w.Header().Set(server.CompleteResponseHeaderName, server.HeaderIncompleteResponseValue)
-				http.Error(w, "", http.StatusMethodNotAllowed)
+				writeError(w, r, http.StatusMethodNotAllowed, nil)
 				return
 			}
+			if hasBodyLimit {
+				if bodyLimitErr != nil {
+					writeError(w, r, http.StatusInternalServerError, bodyLimitErr)
+					return
+				}
+				if err := bodylimit.Enforce(r, bodyLimit.MaxSize); err != nil {
+					status := http.StatusBadRequest
+					if t, ok := err.(bodylimit.Error); ok {
+						status = t.StatusCode()
+					}
+					writeError(w, r, status, err)
+					return
+				}
+			}
+
+			if hasSchema {
+				if schemaErr != nil {
+					writeError(w, r, http.StatusInternalServerError, schemaErr)
+					return
+				}
+				if err := schema.Validate(r); err != nil {
+					status := requestschema.StatusCode(err)
+					if t, ok := err.(requestschema.Error); ok && !hasEncoder {
+						w.Header().Set("Content-Type", t.Encoding())
+						w.WriteHeader(status)
+						w.Write(t.Body())
+						return
+					}
+					writeError(w, r, status, err)
+					return
+				}
+			}
+
+			if hasValidator && validatorErr != nil {
+				writeError(w, r, http.StatusInternalServerError, validatorErr)
+				return
+			}
+			request := buildRequest(r)
+			if hasValidator {
+				if err := validator.Validate(request.Params); err != nil {
+					writeError(w, r, http.StatusBadRequest, err)
+					return
+				}
+			}
+			router.SetOptionalParams(request.Params, optionalParams)
 
 			requestCtx, cancel := context.WithTimeout(r.Context(), configuration.Timeout)
+			var recorder *etag.Recorder
+			if hasTags && isConditionalMethod(r.Method) {
+				requestCtx, recorder = etag.NewContext(requestCtx)
+			}
 
-			response, err := prxy(requestCtx, rb(r, configuration.QueryString, headersToSend))
+			response, err := prxy(requestCtx, request)
 
 			select {
 			case <-requestCtx.Done():
@@ -69,8 +178,14 @@ func CustomEndpointHandlerWithHTTPError(rb RequestBuilder, errF server.ToHTTPErr
 					if isCacheEnabled {
 						w.Header().Set("Cache-Control", cacheControlHeaderValue)
 					}
+					if hasSuccessStatus && isSuccessStatus(response.Metadata.StatusCode) {
+						w = &deferredStatusWriter{ResponseWriter: w, status: response.Metadata.StatusCode}
+					}
 				} else {
 					w.Header().Set(server.CompleteResponseHeaderName, server.HeaderIncompleteResponseValue)
+					if isSuccessStatus(response.Metadata.StatusCode) {
+						w = &deferredStatusWriter{ResponseWriter: w, status: response.Metadata.StatusCode}
+					}
 				}
 
 				for k, vs := range response.Metadata.Headers {
@@ -80,17 +195,57 @@ func CustomEndpointHandlerWithHTTPError(rb RequestBuilder, errF server.ToHTTPErr
 				}
 			} else {
 				w.Header().Set(server.CompleteResponseHeaderName, server.HeaderIncompleteResponseValue)
+				if location, status, ok := redirect.Target(response); ok && err == nil {
+					http.Redirect(w, r, location, status)
+					cancel()
+					return
+				}
 				if err != nil {
-					if t, ok := err.(responseError); ok {
-						http.Error(w, err.Error(), t.StatusCode())
+					if t, ok := err.(passThroughError); ok {
+						if enc := t.Encoding(); enc != "" {
+							w.Header().Set("Content-Type", enc)
+						}
+						w.WriteHeader(t.StatusCode())
+						w.Write(t.Body())
+					} else if t, ok := err.(responseError); ok {
+						writeError(w, r, t.StatusCode(), err)
 					} else {
-						http.Error(w, err.Error(), errF(err))
+						writeError(w, r, errF(err), err)
 					}
 					cancel()
 					return
 				}
+				if hasSuccessStatus && response != nil && isSuccessStatus(response.Metadata.StatusCode) {
+					if response.Metadata.StatusCode == http.StatusNoContent {
+						w.WriteHeader(http.StatusNoContent)
+						cancel()
+						return
+					}
+					w = &deferredStatusWriter{ResponseWriter: w, status: response.Metadata.StatusCode}
+				}
 			}
 
+			if recorder != nil && err == nil && response != nil && response.IsComplete && response.Io == nil {
+				variant := ""
+				if negotiated {
+					variant = r.Header.Get("Accept")
+				}
+				if tag, ok := responseTag(tags, recorder, response, variant); ok {
+					w.Header().Set("ETag", tag)
+					if etag.Match(r.Header.Get("If-None-Match"), tag) {
+						if negotiated {
+							w.Header().Add("Vary", "Accept")
+						}
+						w.WriteHeader(http.StatusNotModified)
+						cancel()
+						return
+					}
+				}
+			}
+
+			if negotiated {
+				w = &acceptWriter{ResponseWriter: w, accept: r.Header.Get("Accept")}
+			}
 			render(w, response)
 			cancel()
 		}
@@ -100,6 +255,10 @@ func CustomEndpointHandlerWithHTTPError(rb RequestBuilder, errF server.ToHTTPErr
 // RequestBuilder is a function that creates a proxy.Request from the received http request
 type RequestBuilder func(r *http.Request, queryString, headersToSend []string) *proxy.Request
 
+// FilteredRequestBuilder is a function that creates a proxy.Request from the received http request
+// with the filters of the query string params and the headers of the endpoint
+type FilteredRequestBuilder func(r *http.Request, query, headers config.ParamsFilter) *proxy.Request
+
 // ParamExtractor is a function that extracts query params from the requested uri
 type ParamExtractor func(r *http.Request) map[string]string
 
@@ -110,27 +269,33 @@ func NoopParamExtractor(_ *http.Request) map[string]string { return map[string]s
 // processing the uri params
 var NewRequest = NewRequestBuilder(NoopParamExtractor)
 
+// NewFilteredRequest is a FilteredRequestBuilder that creates a proxy request from the received
+// http request without processing the uri params
+var NewFilteredRequest = NewFilteredRequestBuilder(NoopParamExtractor)
+
 // NewRequestBuilder gets a RequestBuilder with the received ParamExtractor as a query param
-// extraction mechanism
+// extraction mechanism. The filters of the query string params and the headers are built on every
+// call, so the routers build their handlers with NewFilteredRequestBuilder instead
 func NewRequestBuilder(paramExtractor ParamExtractor) RequestBuilder {
+	rb := NewFilteredRequestBuilder(paramExtractor)
 	return func(r *http.Request, queryString, headersToSend []string) *proxy.Request {
-		params := paramExtractor(r)
-		headers := make(map[string][]string, 3+len(headersToSend))
-
-		for _, k := range headersToSend {
-			if k == requestParamsAsterisk {
-				headers = r.Header
+		return rb(r, config.NewParamsFilter(queryString), config.NewHeadersFilter(headersToSend))
+	}
+}
 
-				break
-			}
+// NewFilteredRequestBuilder gets a FilteredRequestBuilder with the received ParamExtractor as a
+// query param extraction mechanism
+func NewFilteredRequestBuilder(paramExtractor ParamExtractor) FilteredRequestBuilder {
+	return func(r *http.Request, query, headersFilter config.ParamsFilter) *proxy.Request {
+		params := paramExtractor(r)
+		headers := headersFilter.Filter(r.Header)
 
-			if h, ok := r.Header[textproto.CanonicalMIMEHeaderKey(k)]; ok {
-				headers[k] = h
-			}
+		if f, ok := forwarded.FromRequest(r); ok {
+			f.SetHeaders(headers, r)
+		} else {
+			headers["X-Forwarded-For"] = []string{clientIP(r)}
+			headers["X-Forwarded-Host"] = []string{r.Host}
 		}
-
-		headers["X-Forwarded-For"] = []string{clientIP(r)}
-		headers["X-Forwarded-Host"] = []string{r.Host}
 		// if User-Agent is not forwarded using headersToSend, we set
 		// the KrakenD router User Agent value
 		if _, ok := headers["User-Agent"]; !ok {
@@ -139,24 +304,10 @@ func NewRequestBuilder(paramExtractor ParamExtractor) RequestBuilder {
 			headers["X-Forwarded-Via"] = server.UserAgentHeaderValue
 		}
 
-		query := make(map[string][]string, len(queryString))
-		queryValues := r.URL.Query()
-		for i := range queryString {
-			if queryString[i] == requestParamsAsterisk {
-				query = queryValues
-
-				break
-			}
-
-			if v, ok := queryValues[queryString[i]]; ok && len(v) > 0 {
-				query[queryString[i]] = v
-			}
-		}
-
 		return &proxy.Request{
 			Path:    r.URL.Path,
 			Method:  r.Method,
-			Query:   query,
+			Query:   query.Filter(r.URL.Query()),
 			Body:    r.Body,
 			Params:  params,
 			Headers: headers,
@@ -164,11 +315,63 @@ func NewRequestBuilder(paramExtractor ParamExtractor) RequestBuilder {
 	}
 }
 
+// isConditionalMethod returns true for the methods answering the conditional requests with a 304
+func isConditionalMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// responseTag returns the tag of a complete response, reusing the digest recorded by the cache
+func responseTag(cfg etag.Config, recorder *etag.Recorder, response *proxy.Response, variant string) (string, bool) {
+	digest := recorder.Digest()
+	if digest == "" {
+		var err error
+		if digest, err = etag.Digest(response.Data); err != nil {
+			return "", false
+		}
+	}
+	return cfg.Tag(digest, variant), true
+}
+
+// isSuccessStatus returns true for the 2xx statuses the partial response policies set on the
+// incomplete responses and the success statuses set on the complete ones
+func isSuccessStatus(status int) bool {
+	return status >= http.StatusOK && status < http.StatusMultipleChoices
+}
+
+// deferredStatusWriter writes the status of a partial response or of an endpoint declaring its
+// success status before its body, once the render has set the rest of the headers
+type deferredStatusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *deferredStatusWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *deferredStatusWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(w.status)
+	return w.ResponseWriter.Write(b)
+}
+
 type responseError interface {
 	error
 	StatusCode() int
 }
 
+// passThroughError is a responseError carrying the response of a backend passing its failures
+// through to the clients
+type passThroughError interface {
+	responseError
+	Encoding() string
+	Body() []byte
+}
+
 // clientIP implements a best effort algorithm to return the real client IP, it parses
 // X-Real-IP and X-Forwarded-For in order to work properly with reverse-proxies such us: nginx or haproxy.
 // Use X-Forwarded-For before X-Real-Ip as nginx uses X-Real-Ip with the proxy's IP.
@@ -11,11 +11,23 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/etag"
+	"github.com/luraproject/lura/v2/logging"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/requestid"
+	"github.com/luraproject/lura/v2/router/bodylimit"
+	"github.com/luraproject/lura/v2/router/errorencoder"
+	"github.com/luraproject/lura/v2/router/pathparams"
+	"github.com/luraproject/lura/v2/router/redirect"
+	"github.com/luraproject/lura/v2/router/requestschema"
+	"github.com/luraproject/lura/v2/transport/http/client"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
@@ -70,6 +82,33 @@ func TestEndpointHandler_okAllParams(t *testing.T) {
 	time.Sleep(5 * time.Millisecond)
 }
 
+func TestEndpointHandler_okAllParamsDenied(t *testing.T) {
+	p := func(_ context.Context, req *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{
+			IsComplete: true,
+			Data:       map[string]interface{}{"query": req.Query, "headers": req.Headers},
+			Metadata: proxy.Metadata{
+				Headers:    map[string][]string{"X-YZ": {"something"}},
+				StatusCode: 200,
+			},
+		}, nil
+	}
+	endpointHandlerTestCase{
+		timeout:            10,
+		proxy:              p,
+		method:             "GET",
+		expectedBody:       `{"headers":{"User-Agent":["KrakenD Version undefined"],"X-Forwarded-For":[""],"X-Forwarded-Host":["127.0.0.1:8081"]},"query":{"a":["42"],"d":["1","2"]}}`,
+		expectedCache:      "public, max-age=21600",
+		expectedContent:    "application/json",
+		expectedStatusCode: http.StatusOK,
+		completed:          true,
+		queryString:        []string{"*", "!b", "!c[]"},
+		headers:            []string{"*", "!content-type"},
+		expectedHeaders:    map[string][]string{"X-YZ": {"something"}},
+	}.test(t)
+	time.Sleep(5 * time.Millisecond)
+}
+
 func TestEndpointHandler_incomplete(t *testing.T) {
 	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
 		return &proxy.Response{
@@ -90,6 +129,27 @@ func TestEndpointHandler_incomplete(t *testing.T) {
 	time.Sleep(5 * time.Millisecond)
 }
 
+func TestEndpointHandler_partialStatus(t *testing.T) {
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{
+			IsComplete: false,
+			Data:       map[string]interface{}{"foo": "bar"},
+			Metadata:   proxy.Metadata{StatusCode: http.StatusMultiStatus},
+		}, errors.New("This is a dummy error")
+	}
+	endpointHandlerTestCase{
+		timeout:            10,
+		proxy:              p,
+		method:             "GET",
+		expectedBody:       "{\"foo\":\"bar\"}",
+		expectedCache:      "",
+		expectedContent:    "application/json",
+		expectedStatusCode: http.StatusMultiStatus,
+		completed:          false,
+	}.test(t)
+	time.Sleep(5 * time.Millisecond)
+}
+
 func TestEndpointHandler_ko(t *testing.T) {
 	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
 		return nil, fmt.Errorf("This is %s", "a dummy error")
@@ -180,6 +240,41 @@ func TestEndpointHandler_noop(t *testing.T) {
 	time.Sleep(5 * time.Millisecond)
 }
 
+func TestEndpointHandler_noopRange(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "data.txt", time.Time{}, strings.NewReader(content))
+	}))
+	defer backend.Close()
+
+	u, _ := url.Parse(backend.URL + "/data.txt")
+	bp := proxy.NewHTTPProxy(&config.Backend{Encoding: encoding.NOOP}, client.NewHTTPClient, nil)
+	p := func(ctx context.Context, r *proxy.Request) (*proxy.Response, error) {
+		r.URL = u
+		return bp(ctx, r)
+	}
+	endpoint := &config.EndpointConfig{
+		Endpoint:       "/data",
+		Method:         "GET",
+		Timeout:        time.Second,
+		OutputEncoding: encoding.NOOP,
+	}
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/data", nil)
+	req.Header.Set("Range", "bytes=10-19")
+	w := httptest.NewRecorder()
+	EndpointHandler(endpoint, p)(w, req)
+	if w.Code != http.StatusPartialContent {
+		t.Errorf("unexpected status %d", w.Code)
+	}
+	if v := w.Header().Get("Content-Range"); v != "bytes 10-19/100" {
+		t.Errorf("unexpected content range %s", v)
+	}
+	if w.Body.String() != content[10:20] {
+		t.Errorf("unexpected body %s", w.Body.String())
+	}
+}
+
 func TestEndpointHandler_badMethod(t *testing.T) {
 	endpointHandlerTestCase{
 		timeout:            10,
@@ -211,6 +306,222 @@ func TestEndpointHandler_errored_responseError(t *testing.T) {
 	time.Sleep(5 * time.Millisecond)
 }
 
+func TestEndpointHandler_errored_passThrough(t *testing.T) {
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return nil, client.PassThroughResponseError{HTTPResponseError: client.HTTPResponseError{
+			Code: http.StatusNotFound,
+			Msg:  `{"msg":"not found"}`,
+			Enc:  "application/json",
+		}}
+	}
+	endpointHandlerTestCase{
+		timeout:            10,
+		proxy:              p,
+		method:             "GET",
+		expectedBody:       `{"msg":"not found"}`,
+		expectedCache:      "",
+		expectedContent:    "application/json",
+		expectedStatusCode: http.StatusNotFound,
+		completed:          false,
+	}.test(t)
+	time.Sleep(5 * time.Millisecond)
+}
+
+func TestEndpointHandler_errorEncoder(t *testing.T) {
+	r, err := errorencoder.New(errorencoder.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errorencoder.SetGlobal(r)
+	defer errorencoder.SetGlobal(nil)
+
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return nil, dummyResponseError{err: "this is a dummy error", status: http.StatusTeapot}
+	}
+	handler := EndpointHandler(&config.EndpointConfig{Method: "GET", Timeout: time.Second}, p)
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/_mux_endpoint", nil)
+	req = req.WithContext(requestid.NewContext(req.Context(), "abc"))
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != http.StatusTeapot || w.Header().Get("Content-Type") != errorencoder.ProblemContentType {
+		t.Errorf("unexpected response %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	expected := `{"type":"about:blank","title":"I'm a teapot","status":418,"detail":"this is a dummy error","instance":"/_mux_endpoint","code":"im_a_teapot","request_id":"abc"}`
+	if body := w.Body.String(); body != expected {
+		t.Errorf("unexpected body %s", body)
+	}
+}
+
+func TestEndpointHandler_redirect(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint:    "/users/{id}",
+		Method:      "GET",
+		Timeout:     time.Second,
+		QueryString: []string{"page"},
+		ExtraConfig: config.ExtraConfig{redirect.Namespace: map[string]interface{}{
+			"location":       "/v2/users/{id}",
+			"status":         301,
+			"preserve_query": true,
+		}},
+	}
+	p, ok, err := redirect.New(endpoint)
+	if !ok || err != nil {
+		t.Fatalf("unexpected result %v %v", ok, err)
+	}
+	rb := NewRequestBuilder(func(_ *http.Request) map[string]string { return map[string]string{"Id": "42"} })
+	handler := CustomEndpointHandler(rb)(endpoint, p)
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/users/42?page=2&other=1", http.NoBody)
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/v2/users/42?page=2" {
+		t.Errorf("unexpected response %d %s", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestEndpointHandler_bodyLimit(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint:    "/uploads",
+		Method:      "POST",
+		Timeout:     time.Second,
+		ExtraConfig: config.ExtraConfig{bodylimit.Namespace: map[string]interface{}{"max_size": 10}},
+	}
+	calls := 0
+	p := func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+		calls++
+		b, _ := io.ReadAll(r.Body)
+		return &proxy.Response{Data: map[string]interface{}{"size": len(b)}, IsComplete: true}, nil
+	}
+	handler := CustomEndpointHandler(NewRequest)(endpoint, p)
+
+	for _, tc := range []struct {
+		body   io.Reader
+		status int
+	}{
+		{strings.NewReader("0123456789"), http.StatusOK},
+		{strings.NewReader("0123456789a"), http.StatusRequestEntityTooLarge},
+		// the bodies of unknown length are checked too
+		{io.MultiReader(strings.NewReader("0123456789")), http.StatusOK},
+		{io.MultiReader(strings.NewReader("0123456789a")), http.StatusRequestEntityTooLarge},
+	} {
+		req, _ := http.NewRequest("POST", "http://127.0.0.1:8080/uploads", tc.body)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != tc.status {
+			t.Errorf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		if tc.status == http.StatusOK && !strings.Contains(w.Body.String(), `"size":10`) {
+			t.Errorf("unexpected body %s", w.Body.String())
+		}
+	}
+	if calls != 2 {
+		t.Errorf("the oversized requests should not reach the proxy. calls: %d", calls)
+	}
+}
+
+func TestEndpointHandler_pathParams(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/users/{id}",
+		Method:   "GET",
+		Timeout:  time.Second,
+		ExtraConfig: config.ExtraConfig{pathparams.Namespace: map[string]interface{}{
+			"id": map[string]interface{}{"type": "int"},
+		}},
+	}
+	calls := 0
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		calls++
+		return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+	for id, status := range map[string]int{"42": http.StatusOK, "abc": http.StatusBadRequest} {
+		rb := NewRequestBuilder(func(_ *http.Request) map[string]string { return map[string]string{"Id": id} })
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/users/"+id, http.NoBody)
+		w := httptest.NewRecorder()
+		CustomEndpointHandler(rb)(endpoint, p)(w, req)
+		if w.Code != status {
+			t.Errorf("%s: unexpected status %d: %s", id, w.Code, w.Body.String())
+		}
+	}
+	if calls != 1 {
+		t.Errorf("the invalid requests should not reach the proxy. calls: %d", calls)
+	}
+}
+
+func TestEndpointHandler_requestSchema(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/users",
+		Method:   "POST",
+		Timeout:  time.Second,
+		ExtraConfig: config.ExtraConfig{requestschema.Namespace: map[string]interface{}{
+			"body": map[string]interface{}{
+				"type":     "object",
+				"required": []interface{}{"email"},
+			},
+		}},
+	}
+	var received string
+	p := func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		received = string(b)
+		return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+	for body, status := range map[string]int{`{"email":"jane@example.com"}`: http.StatusOK, `{"name":"jane"}`: http.StatusBadRequest} {
+		req, _ := http.NewRequest("POST", "http://127.0.0.1:8080/users", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		EndpointHandler(endpoint, p)(w, req)
+		if w.Code != status {
+			t.Errorf("%s: unexpected status %d: %s", body, w.Code, w.Body.String())
+		}
+		if status == http.StatusBadRequest && w.Body.String() != `{"error":"invalid_request","violations":[{"in":"body","path":"","message":"missing required property email"}]}` {
+			t.Errorf("unexpected body %s", w.Body.String())
+		}
+	}
+	if received != `{"email":"jane@example.com"}` {
+		t.Errorf("unexpected body sent to the proxy: %s", received)
+	}
+}
+
+func TestEndpointHandler_etag(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint:    "/users",
+		Method:      "GET",
+		Timeout:     time.Second,
+		ExtraConfig: config.ExtraConfig{etag.Namespace: map[string]interface{}{}},
+	}
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+	handler := EndpointHandler(endpoint, p)
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/users", nil)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	tag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || tag == "" || w.Body.String() != `{"ok":true}` {
+		t.Errorf("unexpected response %d %q: %s", w.Code, tag, w.Body.String())
+		return
+	}
+
+	req, _ = http.NewRequest("GET", "http://127.0.0.1:8080/users", nil)
+	req.Header.Set("If-None-Match", `"other", `+tag)
+	w = httptest.NewRecorder()
+	handler(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != tag {
+		t.Errorf("unexpected response %d %q: %s", w.Code, w.Header().Get("ETag"), w.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "http://127.0.0.1:8080/users", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	w = httptest.NewRecorder()
+	handler(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != `{"ok":true}` {
+		t.Errorf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+}
+
 type dummyResponseError struct {
 	err    string
 	status int
@@ -301,3 +612,78 @@ func startMuxServer(handlerFunc http.HandlerFunc) *http.ServeMux {
 	router.Handle("/_mux_endpoint", handlerFunc)
 	return router
 }
+
+func TestEndpointHandler_successStatus(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Method:  "POST",
+		Timeout: time.Second,
+		Backend: []*config.Backend{{}},
+		ExtraConfig: config.ExtraConfig{
+			proxy.Namespace: map[string]interface{}{
+				"success_status": map[string]interface{}{"status": 201.0, "empty_status": 204.0},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		data   map[string]interface{}
+		status int
+		body   string
+	}{
+		{map[string]interface{}{"id": 42}, http.StatusCreated, `{"id":42}`},
+		{map[string]interface{}{}, http.StatusNoContent, ""},
+	} {
+		data := tc.data
+		p := proxy.NewSuccessStatusMiddleware(logging.NoOp, endpoint)(func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+			return &proxy.Response{Data: data, IsComplete: true}, nil
+		})
+		w := httptest.NewRecorder()
+		EndpointHandler(endpoint, p)(w, httptest.NewRequest("POST", "/", http.NoBody))
+		if w.Code != tc.status || w.Body.String() != tc.body {
+			t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	// the status of the metadata is ignored by the endpoints not declaring a success status
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{Data: map[string]interface{}{"id": 42}, IsComplete: true, Metadata: proxy.Metadata{StatusCode: http.StatusAccepted}}, nil
+	}
+	w := httptest.NewRecorder()
+	EndpointHandler(&config.EndpointConfig{Method: "POST", Timeout: time.Second}, p)(w, httptest.NewRequest("POST", "/", http.NoBody))
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status: %d", w.Code)
+	}
+}
+
+func TestCustomFilteredEndpointHandler(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint:      "/users/{id}",
+		Method:        "GET",
+		Timeout:       time.Second,
+		QueryString:   []string{"*", "!secret"},
+		HeadersToPass: []string{"x-tenant"},
+	}
+	var request *proxy.Request
+	p := func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+		request = r
+		return &proxy.Response{Data: map[string]interface{}{}, IsComplete: true}, nil
+	}
+	rb := NewFilteredRequestBuilder(func(_ *http.Request) map[string]string { return map[string]string{"Id": "42"} })
+	handler := CustomFilteredEndpointHandler(rb)(endpoint, p)
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/users/42?page=2&secret=1", http.NoBody)
+		req.Header.Set("X-Tenant", "acme")
+		req.Header.Set("X-Other", "1")
+		handler(httptest.NewRecorder(), req)
+
+		if request.Params["Id"] != "42" {
+			t.Errorf("#%d: unexpected params %v", i, request.Params)
+		}
+		if len(request.Query) != 1 || request.Query.Get("page") != "2" {
+			t.Errorf("#%d: unexpected query %v", i, request.Query)
+		}
+		if request.Headers["X-Tenant"][0] != "acme" || request.Headers["X-Other"] != nil {
+			t.Errorf("#%d: unexpected headers %v", i, request.Headers)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/errortemplate"
+)
+
+// NewErrorTemplateHandlerFactory decorates the handlers of the endpoints with error templates, so
+// the bodies of the error responses generated by the gateway are replaced with the rendered
+// templates. The responses of the backends are never replaced
+func NewErrorTemplateHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		ts, ok, err := errortemplate.EndpointTemplates(cfg)
+		if !ok {
+			return hf(cfg, p)
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][ErrorTemplate]"
+		if err != nil {
+			logger.Error(logPrefix, "Keeping the default error bodies:", err.Error())
+			return hf(cfg, p)
+		}
+		handler := hf(cfg, markingProxy(p))
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			m := &errortemplate.Marker{}
+			tw := &templateWriter{ResponseWriter: w, templates: ts, marker: m}
+			handler(tw, r.WithContext(errortemplate.NewContext(r.Context(), m)))
+			if !tw.intercepted {
+				return
+			}
+			body, contentType, _ := ts.Render(errortemplate.Data{
+				Status:     tw.status,
+				StatusText: http.StatusText(tw.status),
+				Message:    errortemplate.Message(tw.buf.Bytes(), tw.status),
+				Endpoint:   cfg.Endpoint,
+				Method:     r.Method,
+				Path:       r.URL.Path,
+			})
+			h := w.Header()
+			h.Set("Content-Type", contentType)
+			h.Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(tw.status)
+			w.Write(body)
+		}
+	}
+}
+
+// markingProxy flags the requests getting a response from the proxy, so their bodies are kept
+func markingProxy(p proxy.Proxy) proxy.Proxy {
+	return func(ctx context.Context, r *proxy.Request) (*proxy.Response, error) {
+		resp, err := p(ctx, r)
+		if resp != nil && (err == nil || len(resp.Data) > 0) {
+			errortemplate.MarkProxied(ctx)
+		}
+		return resp, err
+	}
+}
+
+// templateWriter holds back the error responses with a template, so their bodies can be replaced
+type templateWriter struct {
+	http.ResponseWriter
+	templates   errortemplate.Templates
+	marker      *errortemplate.Marker
+	wroteHeader bool
+	intercepted bool
+	status      int
+	buf         bytes.Buffer
+}
+
+func (w *templateWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if _, ok := w.templates[code]; ok && !w.marker.Proxied() {
+		w.intercepted, w.status = true, code
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *templateWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.intercepted {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements the http.Flusher interface, so the streamed responses keep working
+func (w *templateWriter) Flush() {
+	if w.intercepted {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/errortemplate"
+	"github.com/luraproject/lura/v2/router/ipfilter"
+)
+
+type statusError struct{ code int }
+
+func (e statusError) Error() string   { return "too many requests" }
+func (e statusError) StatusCode() int { return e.code }
+
+func TestNewErrorTemplateHandlerFactory(t *testing.T) {
+	defer ipfilter.SetGlobal(nil)
+
+	cfg := &config.EndpointConfig{
+		Endpoint: "/templated",
+		Method:   "GET",
+		Timeout:  time.Second,
+		ExtraConfig: config.ExtraConfig{
+			errortemplate.Namespace: map[string]interface{}{
+				"templates": map[string]interface{}{
+					"403": `{"code":"forbidden"}`,
+					"429": `{"code":"rate_limited","detail":{{json .Message}},"path":{{json .Path}}}`,
+					"404": `{"code":"not_found"}`,
+				},
+			},
+			ipfilter.Namespace: map[string]interface{}{
+				"deny": []interface{}{"10.0.0.0/8"},
+			},
+		},
+	}
+
+	var result func() (*proxy.Response, error)
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) { return result() }
+	hf := NewErrorTemplateHandlerFactory(NewIPFilterHandlerFactory(CustomEndpointHandler(NewRequest), logging.NoOp), logging.NoOp)
+	handler := hf(cfg, p)
+
+	for i, tc := range []struct {
+		remoteAddr string
+		result     func() (*proxy.Response, error)
+		status     int
+		body       string
+	}{
+		{
+			remoteAddr: "10.1.1.1:1234",
+			status:     http.StatusForbidden,
+			body:       `{"code":"forbidden"}`,
+		},
+		{
+			remoteAddr: "127.0.0.1:1234",
+			result:     func() (*proxy.Response, error) { return nil, statusError{http.StatusTooManyRequests} },
+			status:     http.StatusTooManyRequests,
+			body:       `{"code":"rate_limited","detail":"too many requests","path":"/templated"}`,
+		},
+		{
+			remoteAddr: "127.0.0.1:1234",
+			result:     func() (*proxy.Response, error) { return nil, errors.New("boom") },
+			status:     http.StatusInternalServerError,
+			body:       "boom\n",
+		},
+		{
+			// the responses of the backends keep their bodies
+			remoteAddr: "127.0.0.1:1234",
+			result: func() (*proxy.Response, error) {
+				return &proxy.Response{
+					Data:     map[string]interface{}{"backend": "not found"},
+					Metadata: proxy.Metadata{StatusCode: http.StatusNotFound},
+				}, nil
+			},
+			status: http.StatusOK,
+			body:   `{"backend":"not found"}`,
+		},
+	} {
+		result = tc.result
+		req, _ := http.NewRequest("GET", "/templated", nil)
+		req.RemoteAddr = tc.remoteAddr
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("#%d: unexpected status code: %d", i, w.Code)
+		}
+		if w.Body.String() != tc.body {
+			t.Errorf("#%d: unexpected body: %s", i, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/governor"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+// NewGovernorHandlerFactory decorates the handlers of the endpoints with the governor of the
+// service, if any, so the requests arriving while the gateway is at its ceilings get a 503
+// Service Unavailable and the bytes of the admitted request bodies are reserved as they are read
+func NewGovernorHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		handler := hf(cfg, p)
+		g, ok := governor.GetGlobal()
+		if !ok {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][Governor]"
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			t, err := g.Admit()
+			if err != nil {
+				logger.Debug(logPrefix, err.Error())
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			defer t.Done()
+			ctx := governor.NewContext(r.Context(), t)
+			r = r.WithContext(ctx)
+			r.Body = governor.NewReader(ctx, r.Body)
+			handler(w, r)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/governor"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestNewGovernorHandlerFactory(t *testing.T) {
+	g, err := governor.New(governor.Config{MaxInFlight: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	governor.SetGlobal(g)
+	defer governor.SetGlobal(nil)
+
+	cfg := &config.EndpointConfig{
+		Endpoint: "/orders",
+		Method:   "GET",
+		Timeout:  time.Second,
+	}
+	var inner int
+	p := func(ctx context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		if _, ok := governor.FromContext(ctx); !ok {
+			t.Error("the ticket was not propagated to the proxy")
+		}
+		inner = g.InFlight()
+		// a second request arriving while this one is in flight is rejected
+		w := httptest.NewRecorder()
+		NewGovernorHandlerFactory(EndpointHandler, logging.NoOp)(cfg, proxy.NoopProxy)(w, httptest.NewRequest("GET", "/orders", http.NoBody))
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+			t.Errorf("unexpected response for the rejected request: %d %v", w.Code, w.Header())
+		}
+		return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+	handler := NewGovernorHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/orders", http.NoBody))
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if inner != 1 || g.InFlight() != 0 {
+		t.Errorf("unexpected requests in flight. during: %d, after: %d", inner, g.InFlight())
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/grpcweb"
+)
+
+// NewGRPCWebHandlerFactory decorates the handlers of the endpoints declaring a gRPC method, so
+// they accept the requests of the gRPC-Web clients too. The responses of the gRPC-Web requests
+// are held back until the endpoint completes, so they can be transcoded
+func NewGRPCWebHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		handler := hf(cfg, p)
+		gwCfg, ok, err := grpcweb.ConfigGetter(cfg.ExtraConfig)
+		if !ok {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][gRPC-Web]"
+		var t *grpcweb.Transcoder
+		if err == nil {
+			t, err = grpcweb.New(gwCfg)
+		}
+		if err != nil {
+			logger.Error(logPrefix, "Disabling the gRPC-Web transcoding:", err.Error())
+			return handler
+		}
+		logger.Debug(logPrefix, "Transcoding the gRPC-Web requests of the method", gwCfg.Method)
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !grpcweb.IsGRPCWeb(r) {
+				handler(w, r)
+				return
+			}
+			text := grpcweb.IsText(r)
+			if err := t.DecodeRequest(r); err != nil {
+				t.WriteResponse(w, http.StatusBadRequest, []byte(err.Error()), text)
+				return
+			}
+			bw := &bufferedWriter{header: http.Header{}}
+			handler(bw, r)
+			h := w.Header()
+			for k, vs := range bw.header {
+				h[k] = vs
+			}
+			t.WriteResponse(w, bw.Status(), bw.buf.Bytes(), text)
+		}
+	}
+}
+
+// bufferedWriter holds back the whole response, so it can be replaced
+type bufferedWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *bufferedWriter) Header() http.Header { return w.header }
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.buf.Write(b)
+}
+
+// Status returns the status of the response
+func (w *bufferedWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
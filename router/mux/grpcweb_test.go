@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/grpcweb"
+	"github.com/luraproject/lura/v2/transport/http/client/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestNewGRPCWebHandlerFactory(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint: "/orders.Orders/GetOrder",
+		Method:   "POST",
+		Timeout:  time.Second,
+		ExtraConfig: config.ExtraConfig{
+			grpcweb.Namespace: map[string]interface{}{
+				"descriptor_sets": []interface{}{"../grpcweb/testdata/orders.pb"},
+				"method":          "/orders.Orders/GetOrder",
+			},
+		},
+	}
+	files, err := grpc.LoadDescriptors([]string{"../grpcweb/testdata/orders.pb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, _ := grpc.FindMethod(files, "/orders.Orders/GetOrder")
+
+	p := func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+		var in map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			return nil, err
+		}
+		if in["id"] != "42" {
+			return nil, notFoundError{}
+		}
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"id": in["id"], "total": 9.5}}, nil
+	}
+	handler := NewGRPCWebHandlerFactory(CustomEndpointHandler(NewRequest), logging.NoOp)(cfg, p)
+
+	send := func(id string) *httptest.ResponseRecorder {
+		msg, _ := grpc.FromJSON(md.Input(), []byte(`{"id":"`+id+`"}`))
+		buf := new(bytes.Buffer)
+		grpc.WriteFrame(buf, msg)
+		req := httptest.NewRequest("POST", "/orders.Orders/GetOrder", buf)
+		req.Header.Set("Content-Type", "application/grpc-web+proto")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	w := send("42")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/grpc-web+proto" {
+		t.Fatalf("unexpected response: %d %v", w.Code, w.Header())
+	}
+	f, err := grpc.ReadFrame(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := dynamicpb.NewMessage(md.Output())
+	if err := proto.Unmarshal(f.Payload, out); err != nil {
+		t.Fatal(err)
+	}
+	if total := out.Get(md.Output().Fields().ByName("total")).Float(); total != 9.5 {
+		t.Errorf("unexpected total: %v", total)
+	}
+	if f, _ := grpc.ReadFrame(w.Body); string(f.Payload) != "grpc-status: 0\r\n" {
+		t.Errorf("unexpected trailer: %q", f.Payload)
+	}
+
+	w = send("7")
+	f, _ = grpc.ReadFrame(w.Body)
+	if !f.IsTrailer() || !strings.Contains(string(f.Payload), "grpc-status: 5\r\n") {
+		t.Errorf("unexpected trailer: %q", f.Payload)
+	}
+
+	// the JSON clients are served as usual
+	req := httptest.NewRequest("POST", "/orders.Orders/GetOrder", strings.NewReader(`{"id":"42"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	b, _ := io.ReadAll(rec.Body)
+	if rec.Code != http.StatusOK || string(b) != `{"id":"42","total":9.5}` {
+		t.Errorf("unexpected response: %d %s", rec.Code, b)
+	}
+}
+
+type notFoundError struct{}
+
+func (notFoundError) Error() string   { return "order not found" }
+func (notFoundError) StatusCode() int { return http.StatusNotFound }
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/register"
+)
+
+// HandlerFactoryDecorator wraps a HandlerFactory with one of the features of the router
+type HandlerFactoryDecorator func(HandlerFactory, logging.Logger) HandlerFactory
+
+// DecoratorDefinition declares a named HandlerFactoryDecorator and its ordering constraints. The
+// decorators placed first in the resolved order are the outermost ones
+type DecoratorDefinition struct {
+	Name      string
+	Before    []string
+	After     []string
+	Requires  []string
+	Decorator HandlerFactoryDecorator
+}
+
+// DefaultDecoratorDefinitions returns the definitions of the decorators of the default handler
+// factory. A new slice is returned on every call, so the callers can add their own decorators,
+// placed relative to the built-in ones, before ordering them with OrderDecorators
+func DefaultDecoratorDefinitions() []DecoratorDefinition {
+	return []DecoratorDefinition{
+		// the request id is the outermost one, so the rest of the features can log it
+		{Name: "request-id", Decorator: NewRequestIDHandlerFactory},
+		{Name: "debug-token", After: []string{"request-id"}, Decorator: NewDebugTokenHandlerFactory},
+		{Name: "access-log", After: []string{"debug-token"}, Decorator: NewAccessLogHandlerFactory},
+		{Name: "recorder", After: []string{"access-log"}, Decorator: NewRecorderHandlerFactory},
+		{Name: "tracing", After: []string{"recorder"}, Decorator: NewTracingHandlerFactory},
+		{Name: "grpc-web", After: []string{"tracing"}, Decorator: NewGRPCWebHandlerFactory},
+		{Name: "metrics", After: []string{"grpc-web"}, Decorator: NewMetricsHandlerFactory},
+		// the requests rejected by the protections are still logged, traced and measured
+		{Name: "governor", After: []string{"metrics"}, Decorator: NewGovernorHandlerFactory},
+		{Name: "load-shedding", After: []string{"governor"}, Decorator: NewLoadSheddingHandlerFactory},
+		{Name: "error-template", After: []string{"load-shedding"}, Decorator: NewErrorTemplateHandlerFactory},
+		{Name: "ip-filter", After: []string{"error-template"}, Decorator: NewIPFilterHandlerFactory},
+		{Name: "secure-headers", After: []string{"ip-filter"}, Decorator: NewSecureHeadersHandlerFactory},
+		{Name: "api-key", After: []string{"secure-headers"}, Decorator: NewAPIKeyHandlerFactory},
+		{Name: "jwt", After: []string{"api-key"}, Decorator: NewJWTHandlerFactory},
+		// the quotas are consumed by the authenticated requests only
+		{Name: "quota", After: []string{"jwt"}, Decorator: NewQuotaHandlerFactory},
+		{Name: "consistency", After: []string{"quota"}, Decorator: NewConsistencyHandlerFactory},
+	}
+}
+
+// OrderDecorators returns the decorators in the order resolved from the constraints of their
+// definitions, from the outermost to the innermost one
+func OrderDecorators(defs ...DecoratorDefinition) ([]HandlerFactoryDecorator, error) {
+	constraints := make([]register.Constraint, len(defs))
+	byName := make(map[string]HandlerFactoryDecorator, len(defs))
+	for i, d := range defs {
+		constraints[i] = register.Constraint{Name: d.Name, Before: d.Before, After: d.After, Requires: d.Requires}
+		byName[d.Name] = d.Decorator
+	}
+	names, err := register.Order(constraints)
+	if err != nil {
+		return nil, err
+	}
+	decorators := make([]HandlerFactoryDecorator, len(names))
+	for i, n := range names {
+		decorators[i] = byName[n]
+	}
+	return decorators, nil
+}
+
+// DefaultHandlerFactoryDecorators returns the decorators of the default handler factory, from the
+// outermost to the innermost one, in the order resolved from DefaultDecoratorDefinitions. A new
+// slice is returned on every call
+func DefaultHandlerFactoryDecorators() []HandlerFactoryDecorator {
+	// the constraints of the built-in decorators can always be satisfied
+	decorators, _ := OrderDecorators(DefaultDecoratorDefinitions()...)
+	return decorators
+}
+
+// NewDefaultHandlerFactory wraps the received HandlerFactory with the default decorators
+func NewDefaultHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return DecorateHandlerFactory(hf, logger, DefaultHandlerFactoryDecorators()...)
+}
+
+// DecorateHandlerFactory wraps the received HandlerFactory with the decorators, so the first one
+// is the outermost
+func DecorateHandlerFactory(hf HandlerFactory, logger logging.Logger, decorators ...HandlerFactoryDecorator) HandlerFactory {
+	for i := len(decorators) - 1; i >= 0; i-- {
+		hf = decorators[i](hf, logger)
+	}
+	return hf
+}
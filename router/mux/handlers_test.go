@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestDecorateHandlerFactory(t *testing.T) {
+	var calls []string
+	decorator := func(name string) HandlerFactoryDecorator {
+		return func(hf HandlerFactory, _ logging.Logger) HandlerFactory {
+			return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+				next := hf(cfg, p)
+				return func(w http.ResponseWriter, r *http.Request) {
+					calls = append(calls, name)
+					next(w, r)
+				}
+			}
+		}
+	}
+	hf := func(_ *config.EndpointConfig, _ proxy.Proxy) http.HandlerFunc {
+		return func(_ http.ResponseWriter, _ *http.Request) {
+			calls = append(calls, "endpoint")
+		}
+	}
+
+	h := DecorateHandlerFactory(hf, logging.NoOp, decorator("a"), decorator("b"))(&config.EndpointConfig{}, proxy.NoopProxy)
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/", http.NoBody))
+
+	if len(calls) != 3 || calls[0] != "a" || calls[1] != "b" || calls[2] != "endpoint" {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+}
+
+func TestDefaultHandlerFactoryDecorators(t *testing.T) {
+	decorators := DefaultHandlerFactoryDecorators()
+	expected := []HandlerFactoryDecorator{
+		NewRequestIDHandlerFactory,
+		NewDebugTokenHandlerFactory,
+		NewAccessLogHandlerFactory,
+		NewRecorderHandlerFactory,
+		NewTracingHandlerFactory,
+		NewGRPCWebHandlerFactory,
+		NewMetricsHandlerFactory,
+		NewGovernorHandlerFactory,
+		NewLoadSheddingHandlerFactory,
+		NewErrorTemplateHandlerFactory,
+		NewIPFilterHandlerFactory,
+		NewSecureHeadersHandlerFactory,
+		NewAPIKeyHandlerFactory,
+		NewJWTHandlerFactory,
+		NewQuotaHandlerFactory,
+		NewConsistencyHandlerFactory,
+	}
+
+	// the order is resolved from the constraints, not from the declaration order
+	defs := DefaultDecoratorDefinitions()
+	for i, j := 0, len(defs)-1; i < j; i, j = i+1, j-1 {
+		defs[i], defs[j] = defs[j], defs[i]
+	}
+	reversed, err := OrderDecorators(defs...)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, ds := range [][]HandlerFactoryDecorator{decorators, reversed} {
+		if len(ds) != len(expected) {
+			t.Errorf("unexpected number of decorators: %d", len(ds))
+			continue
+		}
+		for i, d := range ds {
+			if reflect.ValueOf(d).Pointer() != reflect.ValueOf(expected[i]).Pointer() {
+				t.Errorf("unexpected decorator at %d", i)
+			}
+		}
+	}
+
+	decorators[0] = nil
+	if DefaultHandlerFactoryDecorators()[0] == nil {
+		t.Error("the default decorators were modified")
+	}
+}
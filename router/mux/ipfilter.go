@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/ipfilter"
+)
+
+// NewIPFilterHandlerFactory decorates the handlers of the endpoints filtered by the ipfilter
+// rules of the endpoint or the service, so the requests from the rejected addresses get a 403
+// Forbidden before reaching any other stage
+func NewIPFilterHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		handler := hf(cfg, p)
+		f, ok, err := ipfilter.EndpointFilter(cfg)
+		if !ok {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][IPFilter]"
+		if err != nil {
+			logger.Error(logPrefix, "Rejecting all the requests:", err.Error())
+			return func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			if err := f.Check(r); err != nil {
+				logger.Debug(logPrefix, err.Error(), r.RemoteAddr)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			handler(w, r)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/forwarded"
+	"github.com/luraproject/lura/v2/router/ipfilter"
+)
+
+func TestNewIPFilterHandlerFactory(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint: "/internal",
+		Method:   "GET",
+		Timeout:  time.Second,
+		ExtraConfig: config.ExtraConfig{
+			ipfilter.Namespace: map[string]interface{}{
+				"allow": []interface{}{"10.0.0.0/8"},
+			},
+		},
+	}
+	resolver, _ := forwarded.New(forwarded.Config{TrustedProxies: []string{"127.0.0.1"}})
+	forwarded.SetGlobal(resolver)
+	defer forwarded.SetGlobal(nil)
+
+	called := 0
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		called++
+		return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+	handler := NewIPFilterHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)
+
+	for _, tc := range []struct {
+		remote, forwarded string
+		status            int
+	}{
+		{remote: "10.1.1.1:1234", status: http.StatusOK},
+		{remote: "1.1.1.1:1234", status: http.StatusForbidden},
+		{remote: "1.1.1.1:1234", forwarded: "10.1.1.1", status: http.StatusForbidden},
+		{remote: "127.0.0.1:1234", forwarded: "10.1.1.1", status: http.StatusOK},
+		{remote: "127.0.0.1:1234", forwarded: "1.1.1.1", status: http.StatusForbidden},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/internal", http.NoBody)
+		req.RemoteAddr = tc.remote
+		if tc.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		handler(w, req)
+		if w.Code != tc.status {
+			t.Errorf("%s (%s): unexpected status code %d", tc.remote, tc.forwarded, w.Code)
+		}
+	}
+	if called != 2 {
+		t.Errorf("unexpected number of calls to the proxy: %d", called)
+	}
+}
+
+func TestNewIPFilterHandlerFactory_invalidConfig(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint: "/internal",
+		Method:   "GET",
+		Timeout:  time.Second,
+		ExtraConfig: config.ExtraConfig{
+			ipfilter.Namespace: map[string]interface{}{"deny": []interface{}{"bad"}},
+		},
+	}
+	handler := NewIPFilterHandlerFactory(EndpointHandler, logging.NoOp)(cfg, func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		t.Error("the proxy should not be called")
+		return nil, nil
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/internal", http.NoBody)
+	req.RemoteAddr = "10.1.1.1:1234"
+	handler(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("unexpected status code %d", w.Code)
+	}
+}
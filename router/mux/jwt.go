@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"context"
+	"net/http"
+	"net/textproto"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/jwt"
+)
+
+// NewJWTHandlerFactory decorates the handlers of the endpoints declaring the jwt extra config, so
+// the requests without a valid token are rejected before reaching the proxy stage. The
+// endpoints with an invalid jwt config reject all the requests
+func NewJWTHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		jwtCfg, ok := jwt.ConfigGetter(cfg.ExtraConfig)
+		if !ok {
+			return hf(cfg, p)
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][JWT]"
+		validator, err := jwt.NewValidator(jwtCfg, nil)
+		if err != nil {
+			logger.Error(logPrefix, "Rejecting all the requests:", err.Error())
+			return func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}
+
+		if len(jwtCfg.Params) > 0 {
+			p = jwtParamsProxy(p, jwtCfg.Params)
+		}
+		handler := hf(cfg, p)
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			claims, err := validator.ValidateRequest(r)
+			if err != nil {
+				logger.Debug(logPrefix, err.Error())
+				jwt.Unauthorized(w, err)
+				return
+			}
+			jwt.SetHeaders(r.Header, claims, jwtCfg.Headers)
+			handler(w, r.WithContext(jwt.NewContext(r.Context(), claims)))
+		}
+	}
+}
+
+func jwtParamsProxy(next proxy.Proxy, ps []jwt.Propagation) proxy.Proxy {
+	return func(ctx context.Context, r *proxy.Request) (*proxy.Response, error) {
+		claims, ok := jwt.FromContext(ctx)
+		if !ok {
+			return next(ctx, r)
+		}
+		if r.Params == nil {
+			r.Params = map[string]string{}
+		}
+		for _, p := range ps {
+			if v, ok := claims.Value(p.Claim); ok && p.Name != "" {
+				r.Params[textproto.CanonicalMIMEHeaderKey(p.Name[:1])+p.Name[1:]] = v
+			}
+		}
+		return next(ctx, r)
+	}
+}
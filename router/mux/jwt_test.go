@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/jwt"
+)
+
+func TestNewJWTHandlerFactory(t *testing.T) {
+	secret := []byte("secret")
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []interface{}{
+				map[string]interface{}{"kty": "oct", "kid": "k1", "k": base64.RawURLEncoding.EncodeToString(secret)},
+			},
+		})
+	}))
+	defer jwks.Close()
+
+	cfg := &config.EndpointConfig{
+		Endpoint:      "/me",
+		Method:        "GET",
+		Timeout:       time.Second,
+		HeadersToPass: []string{"X-User"},
+		ExtraConfig: config.ExtraConfig{
+			jwt.Namespace: map[string]interface{}{
+				"jwk_url":          jwks.URL,
+				"alg":              []interface{}{"HS256"},
+				"propagate_claims": []interface{}{[]interface{}{"sub", "X-User"}},
+				"claims_to_params": []interface{}{[]interface{}{"sub", "user"}},
+			},
+		},
+	}
+
+	var received *proxy.Request
+	p := func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+		received = r
+		return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+	handler := NewJWTHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/me", http.NoBody)
+	req.Header.Set("X-User", "spoofed")
+	handler(w, req)
+	if w.Code != http.StatusUnauthorized || received != nil {
+		t.Errorf("the requests without token should be rejected. status: %d", w.Code)
+	}
+	if w.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("unexpected challenge: %s", w.Header().Get("WWW-Authenticate"))
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/me", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+signHS256(secret, map[string]interface{}{"sub": "1234"}))
+	req.Header.Set("X-User", "spoofed")
+	handler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", w.Code)
+	}
+	if received == nil {
+		t.Fatal("the proxy was not called")
+	}
+	if v := received.Headers["X-User"]; len(v) != 1 || v[0] != "1234" {
+		t.Errorf("unexpected propagated header: %v", v)
+	}
+	if v := received.Params["User"]; v != "1234" {
+		t.Errorf("unexpected propagated param: %s", v)
+	}
+}
+
+func TestNewJWTHandlerFactory_invalidConfig(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint:    "/me",
+		ExtraConfig: config.ExtraConfig{jwt.Namespace: map[string]interface{}{}},
+	}
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		t.Error("the proxy should not be called")
+		return nil, nil
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/me", http.NoBody)
+	NewJWTHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+}
+
+func signHS256(secret []byte, claims map[string]interface{}) string {
+	h, _ := json.Marshal(map[string]string{"alg": "HS256", "kid": "k1"})
+	c, _ := json.Marshal(claims)
+	in := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	mac := hmac.New(crypto.SHA256.New, secret)
+	mac.Write([]byte(in))
+	return in + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
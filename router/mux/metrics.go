@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/metrics"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+// NewMetricsHandlerFactory decorates the handlers of the endpoints, so the requests are counted
+// by status code and their latencies recorded. It does nothing when the service does not declare
+// the metrics
+func NewMetricsHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		handler := hf(cfg, p)
+		m, ok := metrics.GetGlobal()
+		if !ok {
+			return handler
+		}
+		logger.Debug("[ENDPOINT: "+cfg.Endpoint+"][Metrics]", "Instrumenting the requests")
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			handler(sw, r)
+			m.ObserveRequest(cfg.Endpoint, r.Method, sw.status, time.Since(start))
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/metrics"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestNewMetricsHandlerFactory(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.SetGlobal(metrics.NewPipeline(r, metrics.DefaultBuckets))
+	defer metrics.SetGlobal(nil)
+
+	cfg := &config.EndpointConfig{Endpoint: "/measured", Method: "GET", Timeout: time.Second}
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+	handler := NewMetricsHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)
+	for _, method := range []string{"GET", "GET", "POST"} {
+		req, _ := http.NewRequest(method, "/measured", nil)
+		handler(httptest.NewRecorder(), req)
+	}
+
+	buf := new(bytes.Buffer)
+	r.WriteTo(buf)
+	for _, line := range []string{
+		`lura_router_requests_total{endpoint="/measured",method="GET",status="200"} 2`,
+		`lura_router_requests_total{endpoint="/measured",method="POST",status="405"} 1`,
+		`lura_router_request_duration_seconds_count{endpoint="/measured",method="GET"} 2`,
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("%s not found in:\n%s", line, buf.String())
+		}
+	}
+}
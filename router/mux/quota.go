@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/quota"
+)
+
+// NewQuotaHandlerFactory decorates the handlers of the endpoints, so the requests of the clients
+// exceeding their quotas are rejected with a 429 before reaching the proxy stage. It must wrap the
+// handlers identifying the clients, like the API key and the JWT ones
+func NewQuotaHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		handler := hf(cfg, p)
+		q, ok := quota.GetGlobal()
+		if !ok || quota.IsDisabled(cfg.ExtraConfig) {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][Quota]"
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			client, ok := quota.Client(r.Context())
+			if !ok {
+				handler(w, r)
+				return
+			}
+			status, err := q.Consume(r.Context(), client)
+			if _, exceeded := err.(quota.ExceededError); exceeded {
+				logger.Debug(logPrefix, err.Error())
+				quota.SetHeaders(w.Header(), status, true)
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			if err != nil {
+				logger.Error(logPrefix, "Unable to track the usage:", err.Error())
+			}
+			quota.SetHeaders(w.Header(), status, false)
+			handler(w, r)
+		}
+	}
+}
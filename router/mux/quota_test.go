@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/quota"
+	"github.com/luraproject/lura/v2/router/apikey"
+)
+
+func TestNewQuotaHandlerFactory(t *testing.T) {
+	q, err := quota.New(quota.Config{Limits: quota.Limits{Daily: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	quota.SetGlobal(q)
+	defer quota.SetGlobal(nil)
+
+	cfg := &config.EndpointConfig{
+		Endpoint: "/orders",
+		Method:   "GET",
+		Timeout:  time.Second,
+	}
+	calls := 0
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		calls++
+		return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+	// the client is identified by the API key handler wrapped by the quota one
+	identify := func(hf HandlerFactory) HandlerFactory {
+		return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+			h := hf(cfg, p)
+			return func(w http.ResponseWriter, r *http.Request) {
+				h(w, r.WithContext(apikey.NewContext(r.Context(), apikey.Identity{ClientID: "mobile"})))
+			}
+		}
+	}
+	handler := identify(NewQuotaHandlerFactory(EndpointHandler, logging.NoOp))(cfg, p)
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/orders", http.NoBody))
+	if w.Code != http.StatusOK || calls != 1 {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if w.Header().Get("X-RateLimit-Limit") != "1" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("unexpected headers: %v", w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/orders", http.NoBody))
+	if w.Code != http.StatusTooManyRequests || calls != 1 {
+		t.Errorf("the request should be rejected. status: %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Errorf("unexpected headers: %v", w.Header())
+	}
+
+	// the anonymous requests are not limited
+	anonymous := NewQuotaHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)
+	w = httptest.NewRecorder()
+	anonymous(w, httptest.NewRequest("GET", "/orders", http.NoBody))
+	if w.Code != http.StatusOK || calls != 2 {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+}
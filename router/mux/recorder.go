@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/recorder"
+)
+
+// NewRecorderHandlerFactory decorates the handlers of the endpoints, so the sampled requests are
+// recorded with their responses. It does nothing when the service does not declare a recorder
+// or the recorder skips the endpoint
+func NewRecorderHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		handler := hf(cfg, p)
+		rc, ok := recorder.GetGlobal()
+		if !ok || !rc.Records(cfg.Endpoint) {
+			return handler
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][Recorder]"
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !rc.Sampled() {
+				handler(w, r)
+				return
+			}
+			rec := rc.NewRecord(r, cfg.Endpoint)
+			rw := &recorderWriter{statusWriter: statusWriter{ResponseWriter: w, status: http.StatusOK}, max: int(rc.MaxBodySize()) + 1}
+			handler(rw, r.WithContext(recorder.NewContext(r.Context(), rec)))
+
+			rc.SetResponse(rec, rw.status, rw.Header(), rw.body.Bytes())
+			if err := rc.Write(rec); err != nil {
+				logger.Error(logPrefix, "Unable to write the record:", err.Error())
+			}
+		}
+	}
+}
+
+// recorderWriter keeps the first bytes of the response
+type recorderWriter struct {
+	statusWriter
+	body bytes.Buffer
+	max  int
+}
+
+func (w *recorderWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if left := w.max - w.body.Len(); left > 0 {
+		if len(b) < left {
+			left = len(b)
+		}
+		w.body.Write(b[:left])
+	}
+	return w.statusWriter.Write(b)
+}
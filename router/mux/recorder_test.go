@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/recorder"
+)
+
+func TestNewRecorderHandlerFactory(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rc, err := recorder.New(recorder.Config{Endpoints: []string{"/recorded"}}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.SetGlobal(rc)
+	defer recorder.SetGlobal(nil)
+
+	cfg := &config.EndpointConfig{
+		Endpoint: "/recorded",
+		Method:   "POST",
+		Timeout:  time.Second,
+		Backend:  []*config.Backend{{URLPattern: "/backend"}},
+	}
+	var received string
+	p := proxy.NewBackendRecorderMiddleware(logging.NoOp, cfg.Backend[0])(
+		func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+			b, _ := io.ReadAll(r.Body)
+			received = string(b)
+			return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+		},
+	)
+	handler := NewRecorderHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/recorded", strings.NewReader(`{"a":1}`))
+	handler(w, req)
+
+	if received != `{"a":1}` {
+		t.Errorf("the body did not reach the backend: %s", received)
+	}
+	var rec recorder.Record
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("unexpected record %s: %v", buf.String(), err)
+	}
+	if string(rec.Request.Body) != `{"a":1}` || rec.Response.Status != http.StatusOK || string(rec.Response.Body) != w.Body.String() {
+		t.Errorf("unexpected record: %+v", &rec)
+	}
+	if len(rec.Backends) != 1 || rec.Backends[0].URLPattern != "/backend" || rec.Backends[0].Data["ok"] != true {
+		t.Errorf("unexpected backends: %+v", rec.Backends)
+	}
+
+	buf.Reset()
+	other := *cfg
+	other.Endpoint = "/other"
+	NewRecorderHandlerFactory(EndpointHandler, logging.NoOp)(&other, p)(httptest.NewRecorder(), httptest.NewRequest("POST", "/other", http.NoBody))
+	if buf.Len() != 0 {
+		t.Errorf("the endpoint should not be recorded: %s", buf.String())
+	}
+}
@@ -3,14 +3,18 @@
 package mux
 
 import (
+	"bytes"
 	"encoding/json"
 	"io"
 	"net/http"
 	"sync"
 
+	"gopkg.in/yaml.v3"
+
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/encoding"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/negotiate"
 )
 
 // Render defines the signature of the functions to be use for the final response
@@ -27,9 +31,18 @@ var (
 		encoding.JSON:     jsonRender,
 		encoding.NOOP:     noopRender,
 		"json-collection": jsonCollectionRender,
+		negotiate.XML:     xmlRender,
+		negotiate.YAML:    yamlRender,
+		negotiate.MsgPack: msgpackRender,
 	}
 )
 
+func init() {
+	// the negotiated render must be registered at the init function in order
+	// to avoid a cyclical dependency
+	renderRegister[NEGOTIATE] = newNegotiatedRender(negotiate.DefaultEncodings, jsonRender)
+}
+
 // RegisterRender allows clients to register their custom renders
 func RegisterRender(name string, r Render) {
 	mutex.Lock()
@@ -43,11 +56,18 @@ func getRender(cfg *config.EndpointConfig) Render {
 		fallback = getWithFallback(cfg.Backend[0].Encoding, fallback)
 	}
 
-	if cfg.OutputEncoding == "" {
-		return fallback
+	render := fallback
+	if cfg.OutputEncoding != "" {
+		render = getWithFallback(cfg.OutputEncoding, fallback)
 	}
 
-	return getWithFallback(cfg.OutputEncoding, fallback)
+	if n, ok, err := negotiate.ConfigGetter(cfg.ExtraConfig); ok && err == nil {
+		if cfg.OutputEncoding == NEGOTIATE {
+			render = jsonRender
+		}
+		return newNegotiatedRender(n.Encodings, render)
+	}
+	return render
 }
 
 func getWithFallback(key string, fallback Render) Render {
@@ -60,6 +80,47 @@ func getWithFallback(key string, fallback Render) Render {
 	return r
 }
 
+// newNegotiatedRender returns a render choosing among the renders of the encodings with the
+// Accept header of the request, or using the fallback one if the request accepts none of them
+func newNegotiatedRender(encodings []string, fallback Render) Render {
+	return func(w http.ResponseWriter, response *proxy.Response) {
+		w.Header().Add("Vary", "Accept")
+		accept := acceptHeader(w)
+		if accept == "" {
+			fallback(w, response)
+			return
+		}
+		name, ok := negotiate.Negotiate(accept, encodings)
+		if !ok {
+			fallback(w, response)
+			return
+		}
+		getWithFallback(name, fallback)(w, response)
+	}
+}
+
+// isNegotiated returns true if the render of the endpoint depends on the Accept header
+func isNegotiated(cfg *config.EndpointConfig) bool {
+	if cfg.OutputEncoding == NEGOTIATE {
+		return true
+	}
+	_, ok, err := negotiate.ConfigGetter(cfg.ExtraConfig)
+	return ok && err == nil
+}
+
+// acceptWriter carries the Accept header of the request to the negotiated renders
+type acceptWriter struct {
+	http.ResponseWriter
+	accept string
+}
+
+func acceptHeader(w http.ResponseWriter) string {
+	if a, ok := w.(*acceptWriter); ok {
+		return a.accept
+	}
+	return ""
+}
+
 var (
 	emptyResponse   = []byte("{}")
 	emptyCollection = []byte("[]")
@@ -72,12 +133,9 @@ func jsonRender(w http.ResponseWriter, response *proxy.Response) {
 		return
 	}
 
-	js, err := json.Marshal(response.Data)
-	if err != nil {
+	if err := encoding.WriteJSON(w, response.Data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
 	}
-	w.Write(js)
 }
 
 func jsonCollectionRender(w http.ResponseWriter, response *proxy.Response) {
@@ -92,12 +150,58 @@ func jsonCollectionRender(w http.ResponseWriter, response *proxy.Response) {
 		return
 	}
 
-	js, err := json.Marshal(col)
+	if err := encoding.WriteJSON(w, col); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func xmlRender(w http.ResponseWriter, response *proxy.Response) {
+	var data interface{}
+	if response != nil {
+		data = response.Data
+	}
+	buf := new(bytes.Buffer)
+	if err := encoding.EncodeXML(buf, "response", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write(buf.Bytes())
+}
+
+func yamlRender(w http.ResponseWriter, response *proxy.Response) {
+	js := emptyResponse
+	if response != nil {
+		var err error
+		if js, err = json.Marshal(response.Data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	// the data is decoded again, so the json numbers are written as yaml numbers
+	var data interface{}
+	json.Unmarshal(js, &data)
+	b, err := yaml.Marshal(data)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	w.Write(js)
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Write(b)
+}
+
+func msgpackRender(w http.ResponseWriter, response *proxy.Response) {
+	var data interface{} = map[string]interface{}{}
+	if response != nil {
+		data = response.Data
+	}
+	buf := new(bytes.Buffer)
+	if err := negotiate.WriteMsgPack(buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/msgpack")
+	w.Write(buf.Bytes())
 }
 
 func stringRender(w http.ResponseWriter, response *proxy.Response) {
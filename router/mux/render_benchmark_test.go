@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (d *discardResponseWriter) Header() http.Header         { return d.header }
+func (d *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardResponseWriter) WriteHeader(int)             {}
+
+func BenchmarkRender_json(b *testing.B) {
+	for _, size := range []int{5, 50, 500} {
+		data := make(map[string]interface{}, size)
+		for i := 0; i < size; i++ {
+			data[fmt.Sprintf("key%d", i)] = map[string]interface{}{"supu": i, "tupu": "some text"}
+		}
+		response := &proxy.Response{Data: data, IsComplete: true}
+		b.Run(fmt.Sprintf("%d keys", size), func(b *testing.B) {
+			w := &discardResponseWriter{header: http.Header{}}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				jsonRender(w, response)
+			}
+		})
+	}
+}
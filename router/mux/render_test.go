@@ -5,6 +5,7 @@ package mux
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -15,6 +16,7 @@ import (
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/encoding"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/negotiate"
 )
 
 func TestRender_unknown(t *testing.T) {
@@ -365,3 +367,49 @@ func TestRender_noop_nilResponse(t *testing.T) {
 		t.Error("Unexpected status code:", w.Result().StatusCode)
 	}
 }
+
+func TestRender_negotiated(t *testing.T) {
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{
+			IsComplete: true,
+			Data:       map[string]interface{}{"id": json.Number("42"), "name": "supu"},
+		}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Timeout:        time.Second,
+		Method:         "GET",
+		OutputEncoding: encoding.STRING,
+		ExtraConfig: config.ExtraConfig{
+			negotiate.Namespace: map[string]interface{}{"encodings": []interface{}{"json", "xml", "yaml", "msgpack"}},
+		},
+	}
+	handler := EndpointHandler(endpoint, p)
+
+	for _, tc := range []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", "text/plain", ""},
+		{"image/png", "text/plain", ""},
+		{"*/*", "application/json", `{"id":42,"name":"supu"}`},
+		{"application/json;q=0.5, application/xml", "application/xml", "<response><id>42</id><name>supu</name></response>"},
+		{"application/x-yaml", "application/x-yaml", "id: 42\nname: supu\n"},
+		{"application/msgpack", "application/msgpack", "\x82\xa2id\x2a\xa4name\xa4supu"},
+	} {
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/_mux_endpoint", http.NoBody)
+		req.Header.Set("Accept", tc.accept)
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		if ct := w.Result().Header.Get("Content-Type"); ct != tc.contentType {
+			t.Errorf("%s: unexpected content type %s", tc.accept, ct)
+		}
+		if w.Result().Header.Get("Vary") != "Accept" {
+			t.Errorf("%s: unexpected vary header %s", tc.accept, w.Result().Header.Get("Vary"))
+		}
+		if body := w.Body.String(); body != tc.body {
+			t.Errorf("%s: unexpected body %q", tc.accept, body)
+		}
+	}
+}
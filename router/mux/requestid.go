@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/requestid"
+)
+
+// NewRequestIDHandlerFactory decorates the handlers of the endpoints, so every request gets an
+// id, stored in its context and added to the response headers. It does nothing when the service
+// does not declare the request ids
+func NewRequestIDHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		handler := hf(cfg, p)
+		g, ok := requestid.GetGlobal()
+		if !ok {
+			return handler
+		}
+		logger.Debug("[ENDPOINT: "+cfg.Endpoint+"][RequestID]", "Identifying the requests with the header", g.Header())
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			id := g.ID(r.Header.Get(g.Header()))
+			w.Header().Set(g.Header(), id)
+			handler(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+		}
+	}
+}
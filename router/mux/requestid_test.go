@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/requestid"
+)
+
+func TestNewRequestIDHandlerFactory(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint: "/orders",
+		Method:   "GET",
+		Timeout:  time.Second,
+	}
+	var proxied string
+	p := func(ctx context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		proxied, _ = requestid.FromContext(ctx)
+		return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+
+	requestid.SetGlobal(nil)
+	handler := NewRequestIDHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)
+	req, _ := http.NewRequest("GET", "/orders", nil)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	if proxied != "" || w.Header().Get(requestid.DefaultHeader) != "" {
+		t.Error("no request id expected without a generator")
+	}
+
+	requestid.SetGlobal(requestid.New(requestid.Config{}))
+	defer requestid.SetGlobal(nil)
+	handler = NewRequestIDHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)
+
+	req, _ = http.NewRequest("GET", "/orders", nil)
+	req.Header.Set(requestid.DefaultHeader, "abc-123")
+	w = httptest.NewRecorder()
+	handler(w, req)
+	if proxied != "abc-123" {
+		t.Errorf("unexpected id in the proxy context: %s", proxied)
+	}
+	if id := w.Header().Get(requestid.DefaultHeader); id != "abc-123" {
+		t.Errorf("unexpected id in the response: %s", id)
+	}
+
+	req, _ = http.NewRequest("GET", "/orders", nil)
+	w = httptest.NewRecorder()
+	handler(w, req)
+	if proxied == "" || w.Header().Get(requestid.DefaultHeader) != proxied {
+		t.Errorf("unexpected ids: %s %s", proxied, w.Header().Get(requestid.DefaultHeader))
+	}
+}
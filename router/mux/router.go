@@ -10,10 +10,41 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/luraproject/lura/v2/admin"
+	"github.com/luraproject/lura/v2/budget"
 	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/consistency"
+	"github.com/luraproject/lura/v2/debugtoken"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/governor"
 	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/masking"
+	"github.com/luraproject/lura/v2/metaheaders"
+	"github.com/luraproject/lura/v2/metrics"
+	"github.com/luraproject/lura/v2/openapi"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/quota"
+	"github.com/luraproject/lura/v2/recorder"
+	"github.com/luraproject/lura/v2/requestid"
 	"github.com/luraproject/lura/v2/router"
+	"github.com/luraproject/lura/v2/router/accesslog"
+	"github.com/luraproject/lura/v2/router/apikey"
+	"github.com/luraproject/lura/v2/router/cors"
+	"github.com/luraproject/lura/v2/router/debug"
+	"github.com/luraproject/lura/v2/router/errorencoder"
+	"github.com/luraproject/lura/v2/router/errortemplate"
+	"github.com/luraproject/lura/v2/router/forwarded"
+	"github.com/luraproject/lura/v2/router/health"
+	"github.com/luraproject/lura/v2/router/ipfilter"
+	"github.com/luraproject/lura/v2/router/loadshed"
+	"github.com/luraproject/lura/v2/router/methodoverride"
+	"github.com/luraproject/lura/v2/router/redirect"
+	"github.com/luraproject/lura/v2/router/reload"
+	"github.com/luraproject/lura/v2/router/secure"
+	"github.com/luraproject/lura/v2/router/static"
+	"github.com/luraproject/lura/v2/sd/healthcheck"
+	"github.com/luraproject/lura/v2/telemetry"
+	"github.com/luraproject/lura/v2/tracing"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
@@ -46,11 +77,12 @@ type HandlerMiddleware interface {
 
 // DefaultFactory returns a net/http mux router factory with the injected proxy factory and logger
 func DefaultFactory(pf proxy.Factory, logger logging.Logger) router.Factory {
+	logger = logging.Module(logger, "router")
 	return factory{
 		Config{
 			Engine:         DefaultEngine(),
 			Middlewares:    []HandlerMiddleware{},
-			HandlerFactory: EndpointHandler,
+			HandlerFactory: NewDefaultHandlerFactory(EndpointHandler, logger),
 			ProxyFactory:   pf,
 			Logger:         logger,
 			DebugPattern:   DefaultDebugPattern,
@@ -62,6 +94,7 @@ func DefaultFactory(pf proxy.Factory, logger logging.Logger) router.Factory {
 
 // NewFactory returns a net/http mux router factory with the injected configuration
 func NewFactory(cfg Config) router.Factory {
+	cfg.Logger = logging.Module(cfg.Logger, "router")
 	if cfg.DebugPattern == "" {
 		cfg.DebugPattern = DefaultDebugPattern
 	}
@@ -96,8 +129,23 @@ func HealthHandler(w http.ResponseWriter, _ *http.Request) {
 
 // Run implements the router interface
 func (r httpRouter) Run(cfg config.ServiceConfig) {
+	var handler http.Handler
+	router.WithRegistries(func() {
+		handler = r.build(cfg)
+	})
+
+	if err := r.RunServer(r.ctx, cfg, handler); err != nil {
+		r.cfg.Logger.Error(logPrefix, err.Error())
+	}
+
+	r.cfg.Logger.Info(logPrefix, "Router execution ended")
+}
+
+// build registers the components declared in the service config and returns the handler tree of
+// the router, capturing them. It must be called holding the lock of the registries
+func (r httpRouter) build(cfg config.ServiceConfig) http.Handler {
 	if cfg.Debug {
-		debugHandler := DebugHandler(r.cfg.Logger)
+		debugHandler := debug.NewHandler(cfg, r.cfg.DebugPattern, r.cfg.Logger)
 		for _, method := range []string{
 			http.MethodGet,
 			http.MethodPost,
@@ -132,36 +180,243 @@ func (r httpRouter) Run(cfg config.ServiceConfig) {
 
 	r.cfg.Engine.Handle("/__health", "GET", http.HandlerFunc(HealthHandler))
 
+	if hc, ok, err := health.Register(cfg); ok {
+		if err != nil {
+			r.cfg.Logger.Error(logPrefix, "Unable to parse the health config:", err.Error())
+		}
+		r.cfg.Engine.Handle(hc.LivePath, "GET", health.LiveHandler())
+		r.cfg.Engine.Handle(hc.ReadyPath, "GET", health.ReadyHandler())
+	}
+
+	if sc, ok, err := static.ConfigGetter(cfg.ExtraConfig); ok {
+		if err != nil {
+			r.cfg.Logger.Error(logPrefix, "Unable to register the static content:", err.Error())
+			sc.Routes = nil
+		}
+		for _, route := range sc.Routes {
+			pattern := route.Path
+			if route.IsDir() {
+				// the basic engine serves the subtrees of the patterns ending with a slash
+				pattern += "/"
+			}
+			h := static.Handler(route)
+			r.cfg.Engine.Handle(pattern, http.MethodGet, h)
+			r.cfg.Engine.Handle(pattern, http.MethodHead, h)
+		}
+	}
+
+	hs, secureHeaders := secure.Register(cfg)
+
+	for _, w := range cfg.NamespaceWarnings() {
+		r.cfg.Logger.Warning(logPrefix, w.Error())
+	}
+
+	if ok, err := encoding.SetJSONEngineFromExtraConfig(cfg.ExtraConfig); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to set the json engine:", err.Error())
+	}
+
+	if ok, err := errortemplate.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to parse the error templates:", err.Error())
+	}
+
+	if ok, err := errorencoder.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the error encoder:", err.Error())
+	}
+
+	if ok, err := ipfilter.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the IP filter:", err.Error())
+	}
+
+	if ok, err := apikey.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the API key store:", err.Error())
+	}
+
+	if ok, err := masking.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to register the masking profiles:", err.Error())
+	}
+
+	if ok, err := budget.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to register the budget:", err.Error())
+	}
+
+	if ok, err := quota.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to register the quotas:", err.Error())
+	}
+
+	if ok, err := metaheaders.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to register the meta headers:", err.Error())
+	}
+
+	if ok, err := consistency.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the consistency hints:", err.Error())
+	}
+
+	if ok, err := forwarded.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the forwarded headers resolver:", err.Error())
+	}
+
+	if ok, err := proxy.RegisterBodyBuffer(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to set the max size of the buffered bodies:", err.Error())
+	}
+
+	if ok, err := loadshed.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the load shedding controller:", err.Error())
+	}
+
+	if ok, err := governor.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the governor:", err.Error())
+	}
+
+	if ok, err := debugtoken.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the debug token verifier:", err.Error())
+	}
+
+	if ok, err := tracing.Register(cfg, r.cfg.Logger); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the tracer:", err.Error())
+	}
+
+	if _, ok, err := metrics.Register(r.ctx, cfg, r.cfg.Logger); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to expose the metrics:", err.Error())
+	}
+
+	if _, ok, err := admin.Register(r.ctx, cfg, r.cfg.Logger); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to expose the admin API:", err.Error())
+	}
+
+	if ok, err := accesslog.RegisterWithLogger(cfg, r.cfg.Logger); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the access log:", err.Error())
+	}
+
+	if ok, err := recorder.RegisterWithLogger(cfg, r.cfg.Logger); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the recorder:", err.Error())
+	}
+
+	if ok, err := requestid.Register(cfg); ok && err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the request ids:", err.Error())
+	}
+
+	if path, ok := healthcheck.Register(cfg); ok {
+		r.cfg.Logger.Debug(logPrefix, "Exposing the health of the backends at", path)
+		r.cfg.Engine.Handle(path, "GET", healthcheck.Handler())
+	}
+
+	if t, ok := telemetry.Register(cfg); ok {
+		r.cfg.Logger.Debug(logPrefix, "Exposing the telemetry snapshots at", t.Path)
+		r.cfg.Engine.Handle(t.Path, "GET", telemetry.Handler(telemetry.DefaultCollector()))
+	}
+
+	if o, ok := openapi.Register(cfg); ok {
+		r.cfg.Logger.Debug(logPrefix, "Exposing the OpenAPI document at", o.Path)
+		r.cfg.Engine.Handle(o.Path, "GET", openapi.Handler(cfg, o))
+	}
+
 	server.InitHTTPDefaultTransport(cfg)
+	r.closeOnDone()
+
+	if cfg.Debug {
+		// the plans describe the components registered above
+		r.cfg.Engine.Handle(proxy.ExplainPath, "GET", proxy.ExplainHandler(cfg))
+	}
 
 	r.registerKrakendEndpoints(cfg.Endpoints)
+	health.MarkReady(health.ConfigGate)
 
-	if err := r.RunServer(r.ctx, cfg, r.handler()); err != nil {
-		r.cfg.Logger.Error(logPrefix, err.Error())
+	handler := r.handler()
+	if o, ok, err := methodoverride.FromConfig(cfg); err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the method override:", err.Error())
+	} else if ok {
+		// the method is overridden before the engine routes the request
+		handler = o.Handler(handler)
+	}
+	if c, ok, err := cors.FromConfig(cfg); err != nil {
+		r.cfg.Logger.Error(logPrefix, "Unable to create the CORS policy:", err.Error())
+	} else if ok {
+		// the preflight requests are answered before reaching the engine and its 405 responses
+		handler = c.Handler(handler)
 	}
+	if secureHeaders {
+		handler = hs.Handler(handler)
+	}
+	// the requests carry the resolver of the router, so it is used after another router
+	// registers its own
+	resolver, _ := forwarded.GetGlobal()
+	return forwarded.Handler(resolver, handler)
+}
 
-	r.cfg.Logger.Info(logPrefix, "Router execution ended")
+// closeOnDone closes the components registered by the router holding outputs or background
+// workers when its context is done, so they are released when the router is replaced by a
+// reload without closing the ones of the other routers
+func (r httpRouter) closeOnDone() {
+	l, hasLog := accesslog.GetGlobal()
+	rc, hasRecorder := recorder.GetGlobal()
+	t, hasTracer := tracing.GetGlobal()
+	if r.ctx.Done() == nil || !hasLog && !hasRecorder && !hasTracer {
+		return
+	}
+	go func() {
+		<-r.ctx.Done()
+		if hasLog {
+			l.Close()
+		}
+		if hasRecorder {
+			rc.Close()
+		}
+		if hasTracer {
+			t.Close()
+		}
+	}()
 }
 
 func (r httpRouter) registerKrakendEndpoints(endpoints []*config.EndpointConfig) {
+	catalog := map[string][]string{}
+	hosts := hostRoutes{
+		routes:   router.HostRoutes(endpoints),
+		handlers: map[string]*router.HostHandler{},
+	}
 	for _, c := range endpoints {
-		proxyStack, err := r.cfg.ProxyFactory.New(c)
+		// the redirects are served without building the pipe of the endpoint
+		proxyStack, ok, err := redirect.New(c)
+		if !ok {
+			proxyStack, err = r.cfg.ProxyFactory.New(c)
+		}
 		if err != nil {
 			r.cfg.Logger.Error(logPrefix, "Calling the ProxyFactory", err.Error())
 			continue
 		}
 
-		r.registerKrakendEndpoint(c.Method, c, r.cfg.HandlerFactory(c, proxyStack), len(c.Backend))
+		handler := r.cfg.HandlerFactory(c, proxyStack)
+		for _, p := range r.registerKrakendEndpoint(c.Method, c, handler, len(c.Backend), hosts) {
+			catalog[p] = append(catalog[p], strings.ToTitle(c.Method))
+		}
+
+		for _, alias := range c.Aliases {
+			for _, p := range r.registerKrakendEndpoint(c.Method, router.AliasEndpoint(c, alias), router.NewAliasHandler(handler, c, alias), len(c.Backend), hosts) {
+				catalog[p] = append(catalog[p], strings.ToTitle(c.Method))
+			}
+		}
+	}
+
+	// the OPTIONS requests get the methods of all the endpoints sharing the path
+	for p, methods := range catalog {
+		r.cfg.Engine.Handle(p, http.MethodOptions, router.NewOptionsHandler(methods))
 	}
 }
 
-func (r httpRouter) registerKrakendEndpoint(method string, endpoint *config.EndpointConfig, handler http.HandlerFunc, totBackends int) {
+// hostRoutes keeps the handlers dispatching the routes of the endpoints restricted to a set of
+// hosts, since several endpoints can share them
+type hostRoutes struct {
+	routes   map[string]bool
+	handlers map[string]*router.HostHandler
+}
+
+// registerKrakendEndpoint registers the handler of the endpoint and returns the registered paths
+func (r httpRouter) registerKrakendEndpoint(method string, endpoint *config.EndpointConfig, handler http.HandlerFunc, totBackends int, hosts hostRoutes) []string {
 	method = strings.ToTitle(method)
 	path := endpoint.Endpoint
 	if method != http.MethodGet && totBackends > 1 {
 		if !router.IsValidSequentialEndpoint(endpoint) {
 			r.cfg.Logger.Error(logPrefix, method, " endpoints with sequential proxy enabled only allow a non-GET in the last backend! Ignoring", path)
-			return
+			return nil
 		}
 	}
 
@@ -173,10 +428,27 @@ func (r httpRouter) registerKrakendEndpoint(method string, endpoint *config.Endp
 	case http.MethodDelete:
 	default:
 		r.cfg.Logger.Error(logPrefix, "Unsupported method", method)
-		return
+		return nil
+	}
+	paths := router.EndpointPaths(path)
+	for _, p := range paths {
+		r.cfg.Logger.Debug(logPrefix, "Registering the endpoint", method, p)
+		route := method + " " + p
+		if !hosts.routes[route] {
+			r.cfg.Engine.Handle(p, method, handler)
+			continue
+		}
+		h, ok := hosts.handlers[route]
+		if !ok {
+			h = router.NewHostHandler()
+			hosts.handlers[route] = h
+			r.cfg.Engine.Handle(p, method, h)
+		}
+		if !h.Add(endpoint.Hosts, handler) {
+			r.cfg.Logger.Error(logPrefix, "The hosts of the endpoint are already served. Ignoring", method, p, endpoint.Hosts)
+		}
 	}
-	r.cfg.Logger.Debug(logPrefix, "Registering the endpoint", method, path)
-	r.cfg.Engine.Handle(path, method, handler)
+	return paths
 }
 
 func (r httpRouter) handler() http.Handler {
@@ -187,3 +459,50 @@ func (r httpRouter) handler() http.Handler {
 	}
 	return handler
 }
+
+// NewHandlerBuilder returns a reload.HandlerBuilder creating a brand new handler tree for every
+// configuration. The newConfig func must return a fresh Config (with a new Engine) on every call
+func NewHandlerBuilder(newConfig func() Config) reload.HandlerBuilder {
+	return func(ctx context.Context, cfg config.ServiceConfig) (http.Handler, error) {
+		var handler http.Handler
+		c := newConfig()
+		c.RunServer = func(_ context.Context, _ config.ServiceConfig, h http.Handler) error {
+			handler = h
+			return nil
+		}
+		NewFactory(c).NewWithContext(ctx).Run(cfg)
+		if handler == nil {
+			return nil, reload.ErrNoHandler
+		}
+		return handler, nil
+	}
+}
+
+// NewPartialHandlerBuilder returns a reload.HandlerBuilder like NewHandlerBuilder, but reusing the
+// pipes of the endpoints not modified by the new configuration, so their caches, breakers and
+// balancers survive the reloads
+func NewPartialHandlerBuilder(newConfig func() Config, cache *reload.PipeCache) reload.HandlerBuilder {
+	return func(ctx context.Context, cfg config.ServiceConfig) (http.Handler, error) {
+		if err := cache.Begin(cfg); err != nil {
+			return nil, err
+		}
+		var handler http.Handler
+		c := newConfig()
+		c.ProxyFactory = cache.Wrap(c.ProxyFactory)
+		c.RunServer = func(_ context.Context, _ config.ServiceConfig, h http.Handler) error {
+			handler = h
+			return nil
+		}
+		NewFactory(c).NewWithContext(ctx).Run(cfg)
+		if handler == nil {
+			cache.Rollback()
+			return nil, reload.ErrNoHandler
+		}
+		cache.Commit()
+		if c.Logger != nil {
+			reused, rebuilt := cache.Stats()
+			c.Logger.Debug("[SERVICE: Reload]", "Pipes reused:", reused, "rebuilt:", rebuilt)
+		}
+		return handler, nil
+	}
+}
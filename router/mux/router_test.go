@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"regexp"
 	"strings"
 	"testing"
@@ -19,7 +20,12 @@ import (
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/openapi"
 	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/cors"
+	"github.com/luraproject/lura/v2/router/forwarded"
+	"github.com/luraproject/lura/v2/router/health"
+	"github.com/luraproject/lura/v2/router/methodoverride"
 	"github.com/luraproject/lura/v2/transport/http/server"
 )
 
@@ -268,6 +274,140 @@ func TestRunServer_ko(t *testing.T) {
 	}
 }
 
+func TestRouter_aliases(t *testing.T) {
+	builder := NewHandlerBuilder(func() Config {
+		return Config{
+			Engine:         DefaultEngine(),
+			Middlewares:    []HandlerMiddleware{},
+			HandlerFactory: EndpointHandler,
+			ProxyFactory:   noopProxyFactory(map[string]interface{}{"supu": "tupu"}),
+			Logger:         logging.NoOp,
+		}
+	})
+
+	h, err := builder(context.Background(), config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint: "/v2/users",
+				Method:   "GET",
+				Timeout:  10,
+				Aliases: []config.EndpointAlias{
+					{Path: "/users", Deprecated: true},
+					{Path: "/people"},
+				},
+				Backend: []*config.Backend{{}},
+			},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for path, deprecation := range map[string]string{"/v2/users": "", "/users": "true", "/people": ""} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, http.NoBody))
+		if w.Code != http.StatusOK || w.Body.String() != `{"supu":"tupu"}` {
+			t.Errorf("unexpected response for %s: %d %s", path, w.Code, w.Body.String())
+		}
+		if v := w.Header().Get("Deprecation"); v != deprecation {
+			t.Errorf("unexpected deprecation header for %s: %s", path, v)
+		}
+	}
+}
+
+func TestRouter_tenants(t *testing.T) {
+	builder := NewHandlerBuilder(func() Config {
+		return Config{
+			Engine:         DefaultEngine(),
+			Middlewares:    []HandlerMiddleware{},
+			HandlerFactory: EndpointHandler,
+			ProxyFactory:   tenantProxyFactory{},
+			Logger:         logging.NoOp,
+		}
+	})
+
+	h, err := builder(context.Background(), config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/users", Method: "GET", Timeout: 10, Backend: []*config.Backend{{}}},
+			{Endpoint: "/users", Method: "GET", Timeout: 10, Tenant: "a", Hosts: []string{"a.com"}, Backend: []*config.Backend{{}}},
+			{Endpoint: "/users", Method: "GET", Timeout: 10, Tenant: "b", Hosts: []string{"*.b.com"}, Backend: []*config.Backend{{}}},
+			{Endpoint: "/items", Method: "GET", Timeout: 10, Tenant: "b", Hosts: []string{"*.b.com"}, Backend: []*config.Backend{{}}},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, tc := range []struct {
+		host, path string
+		status     int
+		body       string
+	}{
+		{host: "a.com", path: "/users", status: http.StatusOK, body: `{"tenant":"a"}`},
+		{host: "api.b.com:8080", path: "/users", status: http.StatusOK, body: `{"tenant":"b"}`},
+		{host: "c.com", path: "/users", status: http.StatusOK, body: `{"tenant":""}`},
+		{host: "api.b.com", path: "/items", status: http.StatusOK, body: `{"tenant":"b"}`},
+		{host: "a.com", path: "/items", status: http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", tc.path, http.NoBody)
+		r.Host = tc.host
+		h.ServeHTTP(w, r)
+		if w.Code != tc.status {
+			t.Errorf("unexpected status code for %s%s: %d", tc.host, tc.path, w.Code)
+			continue
+		}
+		if tc.body != "" && w.Body.String() != tc.body {
+			t.Errorf("unexpected response for %s%s: %s", tc.host, tc.path, w.Body.String())
+		}
+	}
+}
+
+type tenantProxyFactory struct{}
+
+func (tenantProxyFactory) New(cfg *config.EndpointConfig) (proxy.Proxy, error) {
+	return func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"tenant": cfg.Tenant}}, nil
+	}, nil
+}
+
+func TestNewHandlerBuilder(t *testing.T) {
+	builder := NewHandlerBuilder(func() Config {
+		return Config{
+			Engine:         DefaultEngine(),
+			Middlewares:    []HandlerMiddleware{},
+			HandlerFactory: EndpointHandler,
+			ProxyFactory:   noopProxyFactory(map[string]interface{}{"supu": "tupu"}),
+			Logger:         logging.NoOp,
+		}
+	})
+
+	for _, endpoint := range []string{"/a", "/b"} {
+		h, err := builder(context.Background(), config.ServiceConfig{
+			Endpoints: []*config.EndpointConfig{
+				{
+					Endpoint: endpoint,
+					Method:   "GET",
+					Timeout:  10,
+					Backend:  []*config.Backend{{}},
+				},
+			},
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", endpoint, http.NoBody))
+		if w.Code != http.StatusOK || w.Body.String() != `{"supu":"tupu"}` {
+			t.Errorf("unexpected response for %s: %d %s", endpoint, w.Code, w.Body.String())
+		}
+	}
+}
+
 func checkResponseIs404(t *testing.T, req *http.Request) {
 	expectedBody := "404 page not found\n"
 	resp, err := http.DefaultClient.Do(req)
@@ -326,3 +466,271 @@ type identityMiddleware struct{}
 func (identityMiddleware) Handler(h http.Handler) http.Handler {
 	return h
 }
+
+func TestRun_cors(t *testing.T) {
+	builder := NewHandlerBuilder(func() Config {
+		return Config{
+			Engine:         DefaultEngine(),
+			Middlewares:    []HandlerMiddleware{},
+			HandlerFactory: EndpointHandler,
+			ProxyFactory:   noopProxyFactory(map[string]interface{}{"supu": "tupu"}),
+			Logger:         logging.NoOp,
+		}
+	})
+	h, err := builder(context.Background(), config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			cors.Namespace: map[string]interface{}{"allow_origins": []interface{}{"https://app.example.com"}},
+		},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/a", Method: "GET", Timeout: 10, Backend: []*config.Backend{{}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("OPTIONS", "/a", http.NoBody)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("unexpected preflight response: %d %v", w.Code, w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/a", http.NoBody)
+	req.Header.Set("Origin", "https://app.example.com")
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("unexpected response: %d %v", w.Code, w.Header())
+	}
+}
+
+func TestRun_methodOverride(t *testing.T) {
+	builder := NewHandlerBuilder(func() Config {
+		return Config{
+			Engine:         DefaultEngine(),
+			Middlewares:    []HandlerMiddleware{},
+			HandlerFactory: EndpointHandler,
+			ProxyFactory:   noopProxyFactory(map[string]interface{}{"supu": "tupu"}),
+			Logger:         logging.NoOp,
+		}
+	})
+	h, err := builder(context.Background(), config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			methodoverride.Namespace: map[string]interface{}{"methods": []interface{}{"DELETE"}},
+		},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/a", Method: "DELETE", Timeout: 10, Backend: []*config.Backend{{}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for override, status := range map[string]int{
+		"DELETE": http.StatusOK,
+		"PUT":    http.StatusMethodNotAllowed,
+		"":       http.StatusMethodNotAllowed,
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/a", http.NoBody)
+		req.Header.Set("X-HTTP-Method-Override", override)
+		h.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("%s: unexpected status %d", override, w.Code)
+		}
+	}
+}
+
+func TestRun_options(t *testing.T) {
+	builder := NewHandlerBuilder(func() Config {
+		return Config{
+			Engine:         DefaultEngine(),
+			Middlewares:    []HandlerMiddleware{},
+			HandlerFactory: EndpointHandler,
+			ProxyFactory:   noopProxyFactory(map[string]interface{}{"supu": "tupu"}),
+			Logger:         logging.NoOp,
+		}
+	})
+	h, err := builder(context.Background(), config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/a", Method: "GET", Timeout: 10, Backend: []*config.Backend{{}}},
+			{Endpoint: "/a", Method: "DELETE", Timeout: 10, Backend: []*config.Backend{{}}},
+			{Endpoint: "/b", Method: "POST", Timeout: 10, Backend: []*config.Backend{{}}, Aliases: []config.EndpointAlias{{Path: "/c"}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, allow := range map[string]string{
+		"/a": "DELETE, GET",
+		"/b": "POST",
+		"/c": "POST",
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("OPTIONS", path, http.NoBody))
+		if w.Code != http.StatusNoContent || w.Header().Get("Allow") != allow {
+			t.Errorf("%s: unexpected response %d %v", path, w.Code, w.Header())
+		}
+	}
+}
+
+func TestRun_openapi(t *testing.T) {
+	builder := NewHandlerBuilder(func() Config {
+		return Config{
+			Engine:         DefaultEngine(),
+			Middlewares:    []HandlerMiddleware{},
+			HandlerFactory: EndpointHandler,
+			ProxyFactory:   noopProxyFactory(map[string]interface{}{"supu": "tupu"}),
+			Logger:         logging.NoOp,
+		}
+	})
+	h, err := builder(context.Background(), config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			openapi.Namespace: map[string]interface{}{"path": "/__spec"},
+		},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/a", Method: "GET", Timeout: 10, Backend: []*config.Backend{{}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/__spec", http.NoBody))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"/a":{"get"`) {
+		t.Errorf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRun_health(t *testing.T) {
+	builder := NewHandlerBuilder(func() Config {
+		return Config{
+			Engine:         DefaultEngine(),
+			Middlewares:    []HandlerMiddleware{},
+			HandlerFactory: EndpointHandler,
+			ProxyFactory:   noopProxyFactory(map[string]interface{}{"supu": "tupu"}),
+			Logger:         logging.NoOp,
+		}
+	})
+	h, err := builder(context.Background(), config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			health.Namespace: map[string]interface{}{"ready_path": "/__ready/gateway"},
+		},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/a", Method: "GET", Timeout: 10, Backend: []*config.Backend{{}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, status := range map[string]int{
+		"/__health":        http.StatusOK,
+		"/__live":          http.StatusOK,
+		"/__ready/gateway": http.StatusOK,
+		"/__ready":         http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, http.NoBody))
+		if w.Code != status {
+			t.Errorf("%s: unexpected status %d", path, w.Code)
+		}
+	}
+
+	health.MarkNotReady(health.ConfigGate)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/__ready/gateway", http.NoBody))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status %d", w.Code)
+	}
+}
+
+func TestRun_healthNotDeclared(t *testing.T) {
+	builder := NewHandlerBuilder(func() Config {
+		return Config{
+			Engine:         DefaultEngine(),
+			Middlewares:    []HandlerMiddleware{},
+			HandlerFactory: EndpointHandler,
+			ProxyFactory:   noopProxyFactory(map[string]interface{}{"supu": "tupu"}),
+			Logger:         logging.NoOp,
+		}
+	})
+	h, err := builder(context.Background(), config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/__live", Method: "GET", Timeout: 10, Backend: []*config.Backend{{}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, status := range map[string]int{
+		"/__live":  http.StatusOK,
+		"/__ready": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, http.NoBody))
+		if w.Code != status {
+			t.Errorf("%s: unexpected status %d", path, w.Code)
+		}
+	}
+}
+
+type forwardedProxyFactory struct{}
+
+func (forwardedProxyFactory) New(_ *config.EndpointConfig) (proxy.Proxy, error) {
+	return func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"for": r.Headers["X-Forwarded-For"]}}, nil
+	}, nil
+}
+
+func TestNewHandlerBuilder_perRouterState(t *testing.T) {
+	builder := NewHandlerBuilder(func() Config {
+		return Config{
+			Engine:         DefaultEngine(),
+			Middlewares:    []HandlerMiddleware{},
+			HandlerFactory: NewDefaultHandlerFactory(EndpointHandler, logging.NoOp),
+			ProxyFactory:   forwardedProxyFactory{},
+			Logger:         logging.NoOp,
+		}
+	})
+	endpoints := []*config.EndpointConfig{{Endpoint: "/a", Method: "GET", Timeout: 10, Backend: []*config.Backend{{}}}}
+
+	trusting, err := builder(context.Background(), config.ServiceConfig{
+		Endpoints: endpoints,
+		ExtraConfig: config.ExtraConfig{
+			forwarded.Namespace: map[string]interface{}{"trusted_proxies": []interface{}{"10.0.0.0/8"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second router registers no resolver, but it must not drop the one of the first router
+	untrusting, err := builder(context.Background(), config.ServiceConfig{Endpoints: endpoints})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		h        http.Handler
+		expected string
+	}{
+		{trusting, `{"for":["5.6.7.8, 10.0.0.1"]}`},
+		// the routers without a resolver keep the legacy header
+		{untrusting, `{"for":["5.6.7.8"]}`},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/a", http.NoBody)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", "5.6.7.8")
+		tc.h.ServeHTTP(w, r)
+		if expected := tc.expected; w.Body.String() != expected {
+			t.Errorf("unexpected response. have: %s, want: %s", w.Body.String(), expected)
+		}
+	}
+}
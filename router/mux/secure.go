@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/secure"
+)
+
+// NewSecureHeadersHandlerFactory decorates the handlers of the endpoints declaring their own
+// security headers, so they override the ones of the service
+func NewSecureHeadersHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		handler := hf(cfg, p)
+		hs, ok := secure.EndpointHeaders(cfg)
+		if !ok {
+			return handler
+		}
+		logger.Debug("[ENDPOINT: "+cfg.Endpoint+"][Secure]", "Overriding the security headers")
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			hs.Apply(w.Header())
+			handler(w, r)
+		}
+	}
+}
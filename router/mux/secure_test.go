@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/router/secure"
+)
+
+func TestNewSecureHeadersHandlerFactory(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint: "/embeddable",
+		Method:   "GET",
+		Timeout:  time.Second,
+		ExtraConfig: config.ExtraConfig{
+			secure.Namespace: map[string]interface{}{"frame_options": "SAMEORIGIN"},
+		},
+	}
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{Data: map[string]interface{}{"ok": true}, IsComplete: true}, nil
+	}
+	handler := NewSecureHeadersHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)
+
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Frame-Options", "DENY")
+	handler(w, httptest.NewRequest("GET", "/embeddable", http.NoBody))
+	if w.Code != http.StatusOK || w.Header().Get("X-Frame-Options") != "SAMEORIGIN" {
+		t.Errorf("unexpected response: %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/requestid"
+	"github.com/luraproject/lura/v2/router/accesslog"
+	"github.com/luraproject/lura/v2/tracing"
+)
+
+// NewTracingHandlerFactory decorates the handlers of the endpoints, so every request opens the
+// server span of the endpoint, joining the trace propagated by the client, if any. It does
+// nothing when the service does not declare a tracer
+func NewTracingHandlerFactory(hf HandlerFactory, logger logging.Logger) HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) http.HandlerFunc {
+		handler := hf(cfg, p)
+		t, ok := tracing.GetGlobal()
+		if !ok {
+			return handler
+		}
+		logger.Debug("[ENDPOINT: "+cfg.Endpoint+"][Tracing]", "Tracing the requests")
+		name := cfg.Method + " " + cfg.Endpoint
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if sc, ok := tracing.Extract(r.Header); ok {
+				ctx = tracing.ContextWithRemoteParent(ctx, sc)
+			}
+			ctx, span := t.Start(ctx, name, tracing.SpanKindServer)
+			if sc, ok := tracing.SpanContextFromContext(ctx); ok {
+				accesslog.SetTraceID(ctx, sc.TraceID.String())
+			}
+			span.SetAttribute("http.method", r.Method)
+			span.SetAttribute("http.route", cfg.Endpoint)
+			span.SetAttribute("http.target", r.URL.Path)
+			if id, ok := requestid.FromContext(ctx); ok {
+				span.SetAttribute("http.request_id", id)
+			}
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			handler(sw, r.WithContext(ctx))
+
+			span.SetAttribute("http.status_code", sw.status)
+			if sw.status >= http.StatusInternalServerError {
+				span.RecordError(errStatus(sw.status))
+			}
+			span.Finish()
+		}
+	}
+}
+
+type errStatus int
+
+func (e errStatus) Error() string { return http.StatusText(int(e)) }
+
+// statusWriter records the status code of the response
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush implements the http.Flusher interface, so the streamed responses keep working
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/luraproject/lura/v2/tracing"
+)
+
+func TestNewTracingHandlerFactory(t *testing.T) {
+	var spans []*tracing.Span
+	tracer, err := tracing.New(tracing.Config{}, tracing.ExporterFunc(func(_ context.Context, _ string, s []*tracing.Span) error {
+		spans = append(spans, s...)
+		return nil
+	}), logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracing.SetGlobal(tracer)
+	defer tracing.SetGlobal(nil)
+
+	cfg := &config.EndpointConfig{Endpoint: "/traced", Method: "GET", Timeout: time.Second}
+	var proxySpan tracing.SpanContext
+	p := func(ctx context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		proxySpan, _ = tracing.SpanContextFromContext(ctx)
+		return nil, errors.New("boom")
+	}
+	handler := NewTracingHandlerFactory(EndpointHandler, logging.NoOp)(cfg, p)
+
+	req, _ := http.NewRequest("GET", "/traced", nil)
+	req.Header.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	handler(w, req)
+	tracer.Close()
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if len(spans) != 1 {
+		t.Fatalf("unexpected number of spans: %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /traced" || span.Kind != tracing.SpanKindServer {
+		t.Errorf("unexpected span: %+v", span)
+	}
+	if span.Context.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.Parent.String() != "00f067aa0ba902b7" {
+		t.Error("the span does not join the trace of the client")
+	}
+	if proxySpan != span.Context {
+		t.Error("the proxy did not receive the span of the endpoint")
+	}
+	if span.Attributes["http.status_code"] != http.StatusInternalServerError || span.Error == "" {
+		t.Errorf("unexpected span status: %v, %q", span.Attributes["http.status_code"], span.Error)
+	}
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestNewHandlerFactoryWithWrappers(t *testing.T) {
+	var trace []string
+	wrapper := func(name string) func(*config.EndpointConfig, http.HandlerFunc) http.HandlerFunc {
+		return func(_ *config.EndpointConfig, next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				trace = append(trace, name)
+				next(w, r)
+			}
+		}
+	}
+	hf := func(_ *config.EndpointConfig, _ proxy.Proxy) http.HandlerFunc {
+		return func(_ http.ResponseWriter, _ *http.Request) { trace = append(trace, "handler") }
+	}
+
+	f, err := NewHandlerFactoryWithWrappers(
+		hf,
+		HandlerWrapper{Name: "rate-limit", After: []string{"auth"}, Wrap: wrapper("rate-limit")},
+		HandlerWrapper{Name: "auth", Wrap: wrapper("auth")},
+		HandlerWrapper{Name: "cors", Before: []string{"auth"}, Wrap: wrapper("cors")},
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	f(&config.EndpointConfig{}, nil)(httptest.NewRecorder(), httptest.NewRequest("GET", "/", http.NoBody))
+	if s := strings.Join(trace, ","); s != "cors,auth,rate-limit,handler" {
+		t.Errorf("unexpected execution order: %s", s)
+	}
+
+	if _, err := NewHandlerFactoryWithWrappers(hf, HandlerWrapper{Name: "a", Requires: []string{"b"}}); err == nil {
+		t.Error("expecting an error")
+	}
+}
@@ -0,0 +1,327 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package negotiate selects the encoding of the responses of the endpoints with the Accept header of
+the requests.
+
+The endpoints with the negotiate output encoding choose among the json, xml, yaml and msgpack
+renders. The endpoints declaring the list of encodings in their extra config choose among them,
+in order of preference, and fall back to their output encoding when the clients do not accept
+any of them:
+
+	{
+		"endpoint": "/users/{id}",
+		"output_encoding": "json",
+		"extra_config": {
+			"github.com/luraproject/lura/router/negotiate": {
+				"encodings": ["json", "msgpack", "xml"]
+			}
+		}
+	}
+
+The quality values of the Accept header rank the encodings, and the more specific media ranges
+take precedence over the wildcards. The media types of other encodings can be added with
+RegisterMediaTypes.
+*/
+package negotiate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the negotiation config
+const Namespace = "github.com/luraproject/lura/router/negotiate"
+
+// The names of the negotiable encodings
+const (
+	JSON    = "json"
+	XML     = "xml"
+	YAML    = "yaml"
+	MsgPack = "msgpack"
+)
+
+// DefaultEncodings are the encodings of the endpoints without a list of encodings
+var DefaultEncodings = []string{JSON, XML, YAML, MsgPack}
+
+var (
+	mediaTypes = map[string][]string{
+		JSON: {"application/json"},
+		XML:  {"application/xml", "text/xml"},
+		// text/plain is kept for the clients of the former negotiated render of gin
+		YAML:    {"application/x-yaml", "application/yaml", "text/yaml", "text/plain"},
+		MsgPack: {"application/msgpack", "application/x-msgpack"},
+	}
+	mediaTypesMu sync.RWMutex
+)
+
+// RegisterMediaTypes sets the media types of an encoding, so it can be negotiated
+func RegisterMediaTypes(encoding string, types ...string) {
+	mediaTypesMu.Lock()
+	mediaTypes[encoding] = types
+	mediaTypesMu.Unlock()
+}
+
+// Config is the negotiation config of an endpoint
+type Config struct {
+	Encodings []string `json:"encodings"`
+}
+
+// ConfigGetter parses the negotiation config from the extra config of an endpoint
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("negotiate: parsing the config: %w", err)
+	}
+	if len(cfg.Encodings) == 0 {
+		return cfg, true, errors.New("negotiate: no encodings declared")
+	}
+	mediaTypesMu.RLock()
+	defer mediaTypesMu.RUnlock()
+	for _, name := range cfg.Encodings {
+		if _, ok := mediaTypes[name]; !ok {
+			return cfg, true, fmt.Errorf("negotiate: unknown encoding %s", name)
+		}
+	}
+	return cfg, true, nil
+}
+
+type mediaRange struct {
+	kind    string
+	subtype string
+	q       float64
+}
+
+// specificity returns how specific the match of the range and the media type is, or -1 if they
+// do not match
+func (r mediaRange) specificity(kind, subtype string) int {
+	switch {
+	case r.kind == "*":
+		return 0
+	case r.kind != kind:
+		return -1
+	case r.subtype == "*":
+		return 1
+	case r.subtype == subtype:
+		return 2
+	}
+	return -1
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		kind, subtype, ok := splitMediaType(params[0])
+		if !ok {
+			continue
+		}
+		r := mediaRange{kind: kind, subtype: subtype, q: 1}
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) != 2 || strings.ToLower(kv[0]) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(kv[1], 64); err == nil && q >= 0 && q <= 1 {
+				r.q = q
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+func splitMediaType(s string) (string, string, bool) {
+	parts := strings.SplitN(strings.ToLower(strings.TrimSpace(s)), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// Negotiate returns the encoding with the highest quality value for the Accept header, choosing
+// the first one of the list on ties, and false if the header does not accept any of them
+func Negotiate(accept string, encodings []string) (string, bool) {
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+
+	mediaTypesMu.RLock()
+	defer mediaTypesMu.RUnlock()
+	for _, name := range encodings {
+		q := 0.0
+		for _, mt := range mediaTypes[name] {
+			kind, subtype, _ := splitMediaType(mt)
+			// the quality of a media type is the one of the most specific range matching it
+			specificity, mtQ := -1, 0.0
+			for _, r := range ranges {
+				if s := r.specificity(kind, subtype); s > specificity {
+					specificity, mtQ = s, r.q
+				}
+			}
+			if mtQ > q {
+				q = mtQ
+			}
+		}
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// WriteMsgPack writes the MessagePack representation of a value decoded from JSON. The json.Number
+// values are written as integers when possible and as floats otherwise, and the keys of the maps
+// are sorted
+func WriteMsgPack(w io.Writer, v interface{}) error {
+	b, err := appendMsgPack(nil, v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func appendMsgPack(b []byte, v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if t {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case string:
+		return appendMsgPackString(b, t), nil
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return appendMsgPackInt(b, i), nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return appendMsgPackFloat(b, f), nil
+	case int:
+		return appendMsgPackInt(b, int64(t)), nil
+	case int64:
+		return appendMsgPackInt(b, t), nil
+	case float64:
+		if t == math.Trunc(t) && math.Abs(t) < 1<<53 {
+			return appendMsgPackInt(b, int64(t)), nil
+		}
+		return appendMsgPackFloat(b, t), nil
+	case []interface{}:
+		b = appendMsgPackHeader(b, len(t), 0x90, 0xdc)
+		for _, item := range t {
+			var err error
+			if b, err = appendMsgPack(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = appendMsgPackHeader(b, len(t), 0x80, 0xde)
+		for _, k := range keys {
+			b = appendMsgPackString(b, k)
+			var err error
+			if b, err = appendMsgPack(b, t[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+
+	// the rest of the values are written as their JSON representation would be decoded
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	d := json.NewDecoder(strings.NewReader(string(raw)))
+	d.UseNumber()
+	if err := d.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return appendMsgPack(b, decoded)
+}
+
+// appendMsgPackHeader appends the header of an array or a map, with the fix type for the small
+// sizes and the 16 or 32 bits variants for the rest
+func appendMsgPackHeader(b []byte, n int, fix, var16 byte) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, var16), uint16(n))
+	}
+	return appendUint32(append(b, var16+1), uint32(n))
+}
+
+func appendMsgPackString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgPackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i < 128:
+		return append(b, byte(i))
+	case i < 0 && i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return appendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return appendUint32(append(b, 0xd2), uint32(i))
+	}
+	return appendUint64(append(b, 0xd3), uint64(i))
+}
+
+func appendMsgPackFloat(b []byte, f float64) []byte {
+	return appendUint64(append(b, 0xcb), math.Float64bits(f))
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package negotiate
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		accept    string
+		encodings []string
+		expected  string
+		ok        bool
+	}{
+		{"application/json", DefaultEncodings, JSON, true},
+		{"*/*", DefaultEncodings, JSON, true},
+		{"*/*", []string{MsgPack, JSON}, MsgPack, true},
+		{"application/xml;q=0.9, application/msgpack", DefaultEncodings, MsgPack, true},
+		{"application/*;q=0.5, application/xml", DefaultEncodings, XML, true},
+		{"text/*", DefaultEncodings, XML, true},
+		{"application/json;q=0, */*;q=0.1", []string{JSON, YAML}, YAML, true},
+		{"application/json; charset=utf-8, text/plain;q=0.8", DefaultEncodings, JSON, true},
+		{"TEXT/YAML", DefaultEncodings, YAML, true},
+		{"image/png", DefaultEncodings, "", false},
+		{"application/json;q=0", []string{JSON}, "", false},
+		{"invalid", DefaultEncodings, "", false},
+	} {
+		name, ok := Negotiate(tc.accept, tc.encodings)
+		if name != tc.expected || ok != tc.ok {
+			t.Errorf("%s: unexpected result %s %v", tc.accept, name, ok)
+		}
+	}
+}
+
+func TestRegisterMediaTypes(t *testing.T) {
+	RegisterMediaTypes("csv", "text/csv")
+	defer func() {
+		mediaTypesMu.Lock()
+		delete(mediaTypes, "csv")
+		mediaTypesMu.Unlock()
+	}()
+	if name, ok := Negotiate("text/csv, application/json;q=0.5", []string{JSON, "csv"}); !ok || name != "csv" {
+		t.Errorf("unexpected result %s %v", name, ok)
+	}
+}
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok, err := ConfigGetter(config.ExtraConfig{}); ok || err != nil {
+		t.Errorf("unexpected result %v %v", ok, err)
+	}
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"encodings": []interface{}{"msgpack", "json"}}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result %v %v", ok, err)
+	}
+	if len(cfg.Encodings) != 2 || cfg.Encodings[0] != MsgPack {
+		t.Errorf("unexpected config %v", cfg)
+	}
+	for _, e := range []map[string]interface{}{
+		{},
+		{"encodings": []interface{}{"json", "protobuf"}},
+	} {
+		if _, ok, err := ConfigGetter(config.ExtraConfig{Namespace: e}); !ok || err == nil {
+			t.Errorf("expecting an error for %v", e)
+		}
+	}
+}
+
+func TestWriteMsgPack(t *testing.T) {
+	data := map[string]interface{}{
+		"id":    json.Number("42"),
+		"price": json.Number("1.5"),
+		"tags":  []interface{}{"a", nil, true},
+		"neg":   -200,
+	}
+	buf := new(bytes.Buffer)
+	if err := WriteMsgPack(buf, data); err != nil {
+		t.Fatal(err)
+	}
+	// {"id": 42, "neg": -200, "price": 1.5, "tags": ["a", nil, true]}
+	expected := "84" +
+		"a26964" + "2a" +
+		"a36e6567" + "d1ff38" +
+		"a57072696365" + "cb3ff8000000000000" +
+		"a474616773" + "93" + "a161" + "c0" + "c3"
+	if hex.EncodeToString(buf.Bytes()) != expected {
+		t.Errorf("unexpected encoding:\nhave: %x\nwant: %s", buf.Bytes(), expected)
+	}
+}
+
+func TestWriteMsgPack_sizes(t *testing.T) {
+	long := string(bytes.Repeat([]byte("a"), 300))
+	items := make([]interface{}, 20)
+	for i := range items {
+		items[i] = i * 1000
+	}
+	buf := new(bytes.Buffer)
+	if err := WriteMsgPack(buf, []interface{}{long, items, int64(1) << 40}); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if b[0] != 0x93 || b[1] != 0xda || b[2] != 0x01 || b[3] != 0x2c {
+		t.Errorf("unexpected string header: %x", b[:4])
+	}
+	arr := b[4+300:]
+	if arr[0] != 0xdc || arr[1] != 0 || arr[2] != 20 {
+		t.Errorf("unexpected array header: %x", arr[:3])
+	}
+	if tail := b[len(b)-9:]; tail[0] != 0xd3 || tail[3] != 0x01 {
+		t.Errorf("unexpected int64: %x", tail)
+	}
+}
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/luraproject/lura/v2/core"
+)
+
+// AllowHeader returns the value of the Allow header of a path serving the received methods
+func AllowHeader(methods []string) string {
+	set := map[string]struct{}{}
+	for _, m := range methods {
+		set[strings.ToUpper(m)] = struct{}{}
+	}
+	allowed := make([]string, 0, len(set))
+	for m := range set {
+		allowed = append(allowed, m)
+	}
+	sort.Strings(allowed)
+	return strings.Join(allowed, ", ")
+}
+
+// NewOptionsHandler returns the handler answering the OPTIONS requests to a path serving the
+// received methods with a 204 No Content and the Allow header
+func NewOptionsHandler(methods []string) http.HandlerFunc {
+	allowed := AllowHeader(methods)
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Allow", allowed)
+		w.Header().Set(core.KrakendHeaderName, core.KrakendHeaderValue)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,20 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewOptionsHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewOptionsHandler([]string{"PUT", "get", "GET"})(w, httptest.NewRequest("OPTIONS", "/a", http.NoBody))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("unexpected status %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, PUT" {
+		t.Errorf("unexpected allow header: %s", allow)
+	}
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package pathparams constrains the values of the path params of the endpoints, so the requests
+with invalid identifiers are rejected by the router before reaching the backends:
+
+	{
+		"endpoint": "/users/{id}/orders/{status}",
+		"extra_config": {
+			"github.com/luraproject/lura/router/pathparams": {
+				"id": {"type": "int"},
+				"status": {"enum": ["open", "closed"]}
+			}
+		}
+	}
+
+Every param accepts a type (int or uuid), a regular expression pattern and a list of allowed
+values, and the values must satisfy all of the declared constraints. The rejected requests get a
+400 Bad Request describing the invalid param.
+*/
+package pathparams
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the path params config
+const Namespace = "github.com/luraproject/lura/router/pathparams"
+
+// The types of the params
+const (
+	TypeInt  = "int"
+	TypeUUID = "uuid"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Error is returned for the requests with an invalid param
+type Error struct {
+	Param  string
+	Reason string
+}
+
+// Error returns a string representation of the Error
+func (e Error) Error() string {
+	return fmt.Sprintf("invalid param %s: %s", e.Param, e.Reason)
+}
+
+// StatusCode returns the status of the responses to the rejected requests
+func (Error) StatusCode() int { return http.StatusBadRequest }
+
+// ErrorCode returns the code of the error, for the structured error responses
+func (Error) ErrorCode() string { return "invalid_param" }
+
+type constraint struct {
+	name    string
+	kind    string
+	pattern *regexp.Regexp
+	enum    map[string]struct{}
+	values  []string
+}
+
+// Validator checks the params of the requests to an endpoint
+type Validator struct {
+	constraints map[string]constraint
+}
+
+// New returns the Validator of the endpoint, and false if the endpoint does not constrain its
+// params. It fails if the config is not valid or constrains a param not declared by the endpoint
+func New(cfg *config.EndpointConfig) (*Validator, bool, error) {
+	tmp, ok := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+	declared := map[string]struct{}{}
+	for _, m := range endpointParamPattern.FindAllStringSubmatch(cfg.Endpoint, -1) {
+		declared[strings.ToLower(m[1]+m[2])] = struct{}{}
+	}
+
+	v := &Validator{constraints: make(map[string]constraint, len(tmp))}
+	for name, raw := range tmp {
+		if _, ok := declared[strings.ToLower(name)]; !ok {
+			return nil, true, fmt.Errorf("pathparams: the endpoint %s has no param %s", cfg.Endpoint, name)
+		}
+		c, err := parseConstraint(name, raw)
+		if err != nil {
+			return nil, true, err
+		}
+		v.constraints[strings.ToLower(name)] = c
+	}
+	return v, true, nil
+}
+
+// endpointParamPattern matches the params of the endpoints, before and after being translated
+// into the syntax of the router
+var endpointParamPattern = regexp.MustCompile(`/(?:\{([a-zA-Z\-_0-9]+)\}|:([a-zA-Z\-_0-9]+))`)
+
+func parseConstraint(name string, raw interface{}) (constraint, error) {
+	c := constraint{name: name}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return c, fmt.Errorf("pathparams: invalid constraints of the param %s", name)
+	}
+	c.kind, _ = m["type"].(string)
+	switch c.kind {
+	case "", TypeInt, TypeUUID:
+	default:
+		return c, fmt.Errorf("pathparams: unknown type %s of the param %s", c.kind, name)
+	}
+	if p, ok := m["pattern"].(string); ok && p != "" {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return c, fmt.Errorf("pathparams: invalid pattern of the param %s: %w", name, err)
+		}
+		c.pattern = re
+	}
+	if values, ok := m["enum"].([]interface{}); ok {
+		c.enum = make(map[string]struct{}, len(values))
+		for _, e := range values {
+			s, ok := e.(string)
+			if !ok {
+				return c, fmt.Errorf("pathparams: invalid enum value %v of the param %s", e, name)
+			}
+			c.enum[s] = struct{}{}
+			c.values = append(c.values, s)
+		}
+		sort.Strings(c.values)
+	}
+	return c, nil
+}
+
+// Validate returns an Error if any of the params does not satisfy its constraints
+func (v *Validator) Validate(params map[string]string) error {
+	for k, value := range params {
+		c, ok := v.constraints[strings.ToLower(k)]
+		if !ok {
+			continue
+		}
+		if err := c.check(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c constraint) check(value string) error {
+	switch c.kind {
+	case TypeInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return Error{Param: c.name, Reason: "must be an integer"}
+		}
+	case TypeUUID:
+		if !uuidPattern.MatchString(value) {
+			return Error{Param: c.name, Reason: "must be a uuid"}
+		}
+	}
+	if c.pattern != nil && !c.pattern.MatchString(value) {
+		return Error{Param: c.name, Reason: "must match " + c.pattern.String()}
+	}
+	if c.enum != nil {
+		if _, ok := c.enum[value]; !ok {
+			return Error{Param: c.name, Reason: "must be one of " + strings.Join(c.values, ", ")}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pathparams
+
+import (
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestValidator(t *testing.T) {
+	v, ok, err := New(&config.EndpointConfig{
+		Endpoint: "/users/:id/orders/{orderId}/{status}/:code",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			"id":      map[string]interface{}{"type": "int"},
+			"orderId": map[string]interface{}{"type": "uuid"},
+			"status":  map[string]interface{}{"enum": []interface{}{"open", "closed"}},
+			"code":    map[string]interface{}{"pattern": "^[A-Z]{3}$"},
+		}},
+	})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+
+	valid := map[string]string{"Id": "42", "Orderid": "0b7c4d2e-9a3f-4b8e-8f1a-2c3d4e5f6a7b", "Status": "open", "Code": "ABC", "Other": "x"}
+	if err := v.Validate(valid); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for k, value := range map[string]string{
+		"Id":      "4x",
+		"Orderid": "not-a-uuid",
+		"Status":  "pending",
+		"Code":    "abc",
+	} {
+		params := map[string]string{}
+		for k, v := range valid {
+			params[k] = v
+		}
+		params[k] = value
+		err := v.Validate(params)
+		e, ok := err.(Error)
+		if !ok {
+			t.Errorf("%s: unexpected error %v", k, err)
+			continue
+		}
+		if e.StatusCode() != 400 {
+			t.Errorf("%s: unexpected status %d", k, e.StatusCode())
+		}
+	}
+
+	if err := v.Validate(map[string]string{"Status": "pending"}); err == nil || err.Error() != "invalid param status: must be one of closed, open" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, ok, _ := New(&config.EndpointConfig{Endpoint: "/users/:id"}); ok {
+		t.Error("the config should not be found")
+	}
+
+	for _, constraints := range []map[string]interface{}{
+		{"unknown": map[string]interface{}{"type": "int"}},
+		{"id": map[string]interface{}{"type": "float"}},
+		{"id": map[string]interface{}{"pattern": "["}},
+		{"id": map[string]interface{}{"enum": []interface{}{1}}},
+		{"id": "int"},
+	} {
+		_, ok, err := New(&config.EndpointConfig{
+			Endpoint:    "/users/:id",
+			ExtraConfig: config.ExtraConfig{Namespace: constraints},
+		})
+		if !ok || err == nil {
+			t.Errorf("%v: expecting an error", constraints)
+		}
+	}
+}
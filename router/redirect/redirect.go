@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package redirect serves endpoints answering with a redirect, for the moved APIs and the vanity
+URLs. The redirect endpoints do not declare any backend and their requests never reach the proxy
+stage:
+
+	{
+		"endpoint": "/users/{id}",
+		"extra_config": {
+			"github.com/luraproject/lura/router/redirect": {
+				"location": "https://api.example.com/v2/users/{id}",
+				"status": 308,
+				"preserve_query": true
+			}
+		}
+	}
+
+The placeholders of the location are replaced with the escaped values of the params of the
+endpoint with the same name. The status must be one of 301, 302 (the default), 307 and 308. The
+query string params of the request are appended to the location when preserve_query is set. As in
+the rest of the endpoints, only the ones declared in input_query_strings are kept.
+*/
+package redirect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+// Namespace is the key to use to store and access the redirect config
+const Namespace = "github.com/luraproject/lura/router/redirect"
+
+func init() {
+	config.BackendlessNamespaces[Namespace] = struct{}{}
+}
+
+// Config is the redirect config of an endpoint
+type Config struct {
+	Location      string `json:"location"`
+	Status        int    `json:"status"`
+	PreserveQuery bool   `json:"preserve_query"`
+}
+
+var placeholderPattern = regexp.MustCompile(`\{([\w\-\.:/]+)\}`)
+
+// ConfigGetter parses the redirect config from the endpoint extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("redirect: parsing the config: %w", err)
+	}
+	if cfg.Status == 0 {
+		cfg.Status = http.StatusFound
+	}
+	switch cfg.Status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return cfg, true, fmt.Errorf("redirect: unsupported status %d", cfg.Status)
+	}
+	if cfg.Location == "" {
+		return cfg, true, fmt.Errorf("redirect: the location is required")
+	}
+	return cfg, true, nil
+}
+
+// New returns the proxy of the endpoint if it is a redirect. The proxy does not call any backend:
+// it returns an empty response with the status of the redirect and the Location header built from
+// the params of the request, so the routers answer with the redirect. It returns false if the
+// endpoint is not a redirect
+func New(e *config.EndpointConfig) (proxy.Proxy, bool, error) {
+	cfg, ok, err := ConfigGetter(e.ExtraConfig)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	if len(e.Backend) > 0 {
+		return nil, true, fmt.Errorf("redirect: the endpoint %s can not declare backends", e.Endpoint)
+	}
+	for _, m := range placeholderPattern.FindAllStringSubmatch(cfg.Location, -1) {
+		if !strings.Contains(e.Endpoint, "{"+m[1]+"}") && !strings.Contains(e.Endpoint, ":"+m[1]) {
+			return nil, true, fmt.Errorf("redirect: the endpoint %s has no param %s", e.Endpoint, m[1])
+		}
+	}
+
+	return func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+		location := placeholderPattern.ReplaceAllStringFunc(cfg.Location, func(p string) string {
+			return url.PathEscape(param(r.Params, p[1:len(p)-1]))
+		})
+		if cfg.PreserveQuery && len(r.Query) > 0 {
+			sep := "?"
+			if strings.Contains(location, "?") {
+				sep = "&"
+			}
+			location += sep + url.Values(r.Query).Encode()
+		}
+		return &proxy.Response{
+			IsComplete: true,
+			Metadata: proxy.Metadata{
+				StatusCode: cfg.Status,
+				Headers:    map[string][]string{"Location": {location}},
+			},
+		}, nil
+	}, true, nil
+}
+
+// param returns the value of the param. The routers capitalize the names of the params in
+// different ways, so the names are compared ignoring the case
+func param(params map[string]string, name string) string {
+	if v, ok := params[name]; ok {
+		return v
+	}
+	for k, v := range params {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// Target returns the location and the status of the redirect responses, without data, so the
+// routers can answer them with the redirect. It returns false for the rest of the responses
+func Target(resp *proxy.Response) (string, int, bool) {
+	if resp == nil || len(resp.Data) > 0 {
+		return "", 0, false
+	}
+	switch resp.Metadata.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return "", 0, false
+	}
+	locations := resp.Metadata.Headers["Location"]
+	if len(locations) == 0 || locations[0] == "" {
+		return "", 0, false
+	}
+	return locations[0], resp.Metadata.StatusCode, true
+}
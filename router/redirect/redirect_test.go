@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package redirect
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func newEndpoint(cfg map[string]interface{}) *config.EndpointConfig {
+	return &config.EndpointConfig{
+		Endpoint:    "/users/{id}",
+		Method:      "GET",
+		ExtraConfig: config.ExtraConfig{Namespace: cfg},
+	}
+}
+
+func TestNew(t *testing.T) {
+	p, ok, err := New(newEndpoint(map[string]interface{}{
+		"location":       "https://api.example.com/v2/users/{id}?from=v1",
+		"status":         308,
+		"preserve_query": true,
+	}))
+	if !ok || err != nil {
+		t.Fatalf("unexpected result %v %v", ok, err)
+	}
+
+	resp, err := p(context.Background(), &proxy.Request{
+		Params: map[string]string{"Id": "a/b"},
+		Query:  map[string][]string{"page": {"2"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	location, status, ok := Target(resp)
+	if !ok || status != http.StatusPermanentRedirect || location != "https://api.example.com/v2/users/a%2Fb?from=v1&page=2" {
+		t.Errorf("unexpected redirect %v %d %s", ok, status, location)
+	}
+}
+
+func TestNew_defaults(t *testing.T) {
+	p, _, err := New(newEndpoint(map[string]interface{}{"location": "/v2/users/{id}"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p(context.Background(), &proxy.Request{
+		Params: map[string]string{"Id": "42"},
+		Query:  map[string][]string{"page": {"2"}},
+	})
+	if location, status, _ := Target(resp); err != nil || status != http.StatusFound || location != "/v2/users/42" {
+		t.Errorf("unexpected redirect %d %s %v", status, location, err)
+	}
+
+	if _, ok, err := New(&config.EndpointConfig{}); ok || err != nil {
+		t.Errorf("unexpected result %v %v", ok, err)
+	}
+}
+
+func TestNew_invalid(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{},
+		{"location": "/v2", "status": 200},
+		{"location": "/v2", "status": "301"},
+		{"location": "/v2/{name}"},
+	} {
+		if _, ok, err := New(newEndpoint(cfg)); !ok || err == nil {
+			t.Errorf("%v: error expected", cfg)
+		}
+	}
+
+	e := newEndpoint(map[string]interface{}{"location": "/v2"})
+	e.Backend = []*config.Backend{{URLPattern: "/"}}
+	if _, _, err := New(e); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestIsBackendless(t *testing.T) {
+	if !newEndpoint(map[string]interface{}{}).IsBackendless() {
+		t.Error("the redirects do not require backends")
+	}
+	cfg := config.ServiceConfig{
+		Version:   config.ConfigVersion,
+		Endpoints: []*config.EndpointConfig{newEndpoint(map[string]interface{}{"location": "/v2/users/{id}"})},
+	}
+	if errs := config.Validate(cfg); len(errs) != 0 {
+		t.Errorf("unexpected errors %v", errs)
+	}
+	if err := cfg.Init(); err != nil {
+		t.Error(err)
+	}
+}
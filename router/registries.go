@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import "sync"
+
+var registriesMu sync.Mutex
+
+// WithRegistries runs f holding the lock of the registries of the feature packages.
+//
+// The routers register the components declared in their config (tracers, quotas, access logs,
+// forwarded resolvers...) as the globals of the feature packages, and their handlers and pipes
+// capture them while they are built. Building the handlers of a router inside f keeps another
+// router of the same process, or a reload, from replacing the registered components in the
+// middle of the build, so every router keeps its own ones after the registries are replaced.
+// The server must be started after f returns, so the lock is not held while serving
+func WithRegistries(f func()) {
+	registriesMu.Lock()
+	defer registriesMu.Unlock()
+	f()
+}
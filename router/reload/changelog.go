@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package reload
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+// ChangeType is the kind of change of an endpoint
+type ChangeType string
+
+const (
+	// EndpointAdded is the type of the changes adding an endpoint
+	EndpointAdded ChangeType = "endpoint_added"
+	// EndpointRemoved is the type of the changes removing an endpoint
+	EndpointRemoved ChangeType = "endpoint_removed"
+	// EndpointChanged is the type of the changes modifying the contract of an endpoint
+	EndpointChanged ChangeType = "endpoint_changed"
+)
+
+// Contract contains the attributes of an endpoint its consumers depend on
+type Contract struct {
+	Endpoint       string   `json:"endpoint"`
+	Method         string   `json:"method"`
+	Params         []string `json:"params"`
+	QueryStrings   []string `json:"input_query_strings"`
+	Headers        []string `json:"input_headers"`
+	OutputEncoding string   `json:"output_encoding"`
+}
+
+// Change describes a change of an endpoint. The fields list the attributes of the contract
+// modified by an EndpointChanged change
+type Change struct {
+	Type     ChangeType `json:"type"`
+	Endpoint string     `json:"endpoint"`
+	Method   string     `json:"method"`
+	Fields   []string   `json:"fields,omitempty"`
+	Before   *Contract  `json:"before,omitempty"`
+	After    *Contract  `json:"after,omitempty"`
+}
+
+// ChangeEvent is emitted every time a reload changes the API exposed by the gateway
+type ChangeEvent struct {
+	Service string    `json:"service"`
+	Time    time.Time `json:"time"`
+	Hash    string    `json:"hash"`
+	Changes []Change  `json:"changes"`
+}
+
+// ChangeSink receives the change events
+type ChangeSink interface {
+	Publish(context.Context, ChangeEvent) error
+}
+
+// ChangeSinkFunc type is an adapter to allow the use of ordinary functions as change sinks
+type ChangeSinkFunc func(context.Context, ChangeEvent) error
+
+// Publish implements the ChangeSink interface
+func (f ChangeSinkFunc) Publish(ctx context.Context, e ChangeEvent) error { return f(ctx, e) }
+
+// NewLoggerChangeSink returns a ChangeSink writing the events as JSON to the logger
+func NewLoggerChangeSink(logger logging.Logger) ChangeSink {
+	return ChangeSinkFunc(func(_ context.Context, e ChangeEvent) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		logger.Info(logPrefix, "API changes:", string(b))
+		return nil
+	})
+}
+
+// NewHTTPChangeSink returns a ChangeSink posting the events as JSON to the url
+func NewHTTPChangeSink(url string, c *http.Client) ChangeSink {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return ChangeSinkFunc(func(ctx context.Context, e ChangeEvent) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("publishing the api changes: unexpected status code %d", resp.StatusCode)
+		}
+		return nil
+	})
+}
+
+// Changelog returns the changes of the endpoints exposed by the next config, compared with the
+// ones exposed by the previous one. The endpoints are identified by their method and path, with
+// the names of the params ignored, so renaming a param is reported as a change of the endpoint
+func Changelog(prev, next config.ServiceConfig) []Change {
+	before := contracts(prev)
+	after := contracts(next)
+
+	changes := []Change{}
+	for k, b := range before {
+		a, ok := after[k]
+		if !ok {
+			b := b
+			changes = append(changes, Change{Type: EndpointRemoved, Endpoint: b.Endpoint, Method: b.Method, Before: &b})
+			continue
+		}
+		if fields := b.diff(a); len(fields) > 0 {
+			b, a := b, a
+			changes = append(changes, Change{
+				Type:     EndpointChanged,
+				Endpoint: a.Endpoint,
+				Method:   a.Method,
+				Fields:   fields,
+				Before:   &b,
+				After:    &a,
+			})
+		}
+	}
+	for k, a := range after {
+		if _, ok := before[k]; !ok {
+			a := a
+			changes = append(changes, Change{Type: EndpointAdded, Endpoint: a.Endpoint, Method: a.Method, After: &a})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Endpoint != changes[j].Endpoint {
+			return changes[i].Endpoint < changes[j].Endpoint
+		}
+		if changes[i].Method != changes[j].Method {
+			return changes[i].Method < changes[j].Method
+		}
+		return changes[i].Type < changes[j].Type
+	})
+	return changes
+}
+
+func contracts(cfg config.ServiceConfig) map[string]Contract {
+	res := make(map[string]Contract, len(cfg.Endpoints))
+	for _, e := range cfg.Endpoints {
+		pattern, params := pathParams(e.Endpoint)
+		c := Contract{
+			Endpoint:       e.Endpoint,
+			Method:         strings.ToUpper(e.Method),
+			Params:         params,
+			QueryStrings:   sortedCopy(e.QueryString),
+			Headers:        sortedCopy(e.HeadersToPass),
+			OutputEncoding: e.OutputEncoding,
+		}
+		res[c.Method+" "+pattern] = c
+	}
+	return res
+}
+
+func (c Contract) diff(other Contract) []string {
+	var fields []string
+	if !equalStrings(c.Params, other.Params) {
+		fields = append(fields, "params")
+	}
+	if !equalStrings(c.QueryStrings, other.QueryStrings) {
+		fields = append(fields, "input_query_strings")
+	}
+	if !equalStrings(c.Headers, other.Headers) {
+		fields = append(fields, "input_headers")
+	}
+	if c.OutputEncoding != other.OutputEncoding {
+		fields = append(fields, "output_encoding")
+	}
+	return fields
+}
+
+// pathParams returns the path with the param names removed and the names of the params
+func pathParams(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	params := []string{}
+	for i, s := range segments {
+		var name string
+		switch {
+		case strings.HasPrefix(s, ":"), strings.HasPrefix(s, "*"):
+			name = s[1:]
+		case strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}"):
+			name = s[1 : len(s)-1]
+		default:
+			continue
+		}
+		params = append(params, name)
+		segments[i] = s[:1]
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func sortedCopy(s []string) []string {
+	res := append([]string{}, s...)
+	sort.Strings(res)
+	return res
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
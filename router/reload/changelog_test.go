@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package reload
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestChangelog(t *testing.T) {
+	prev := config.ServiceConfig{Endpoints: []*config.EndpointConfig{
+		{Endpoint: "/users/:id", Method: "GET", OutputEncoding: "json"},
+		{Endpoint: "/users", Method: "POST", HeadersToPass: []string{"Authorization"}},
+		{Endpoint: "/legacy", Method: "GET"},
+		{Endpoint: "/orders", Method: "GET", QueryString: []string{"page", "limit"}},
+	}}
+	next := config.ServiceConfig{Endpoints: []*config.EndpointConfig{
+		{Endpoint: "/users/:user_id", Method: "GET", OutputEncoding: "negotiate"},
+		{Endpoint: "/users", Method: "POST", HeadersToPass: []string{"Authorization"}},
+		{Endpoint: "/orders", Method: "GET", QueryString: []string{"limit", "page"}},
+		{Endpoint: "/orders", Method: "POST"},
+	}}
+
+	changes := Changelog(prev, next)
+	b, _ := json.Marshal(changes)
+	expected := `[` +
+		`{"type":"endpoint_removed","endpoint":"/legacy","method":"GET","before":{"endpoint":"/legacy","method":"GET","params":[],"input_query_strings":[],"input_headers":[],"output_encoding":""}},` +
+		`{"type":"endpoint_added","endpoint":"/orders","method":"POST","after":{"endpoint":"/orders","method":"POST","params":[],"input_query_strings":[],"input_headers":[],"output_encoding":""}},` +
+		`{"type":"endpoint_changed","endpoint":"/users/:user_id","method":"GET","fields":["params","output_encoding"],` +
+		`"before":{"endpoint":"/users/:id","method":"GET","params":["id"],"input_query_strings":[],"input_headers":[],"output_encoding":"json"},` +
+		`"after":{"endpoint":"/users/:user_id","method":"GET","params":["user_id"],"input_query_strings":[],"input_headers":[],"output_encoding":"negotiate"}}` +
+		`]`
+	if string(b) != expected {
+		t.Errorf("unexpected changes:\nhave: %s\nwant: %s", b, expected)
+	}
+
+	if changes := Changelog(next, next); len(changes) != 0 {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+}
+
+func TestPathParams(t *testing.T) {
+	for path, expected := range map[string]struct {
+		pattern string
+		params  []string
+	}{
+		"/users/:id/orders/:order": {"/users/:/orders/:", []string{"id", "order"}},
+		"/users/{id}":              {"/users/{", []string{"id"}},
+		"/static/*filepath":        {"/static/*", []string{"filepath"}},
+		"/plain":                   {"/plain", []string{}},
+	} {
+		pattern, params := pathParams(path)
+		if pattern != expected.pattern || !equalStrings(params, expected.params) {
+			t.Errorf("%s: unexpected result %s %v", path, pattern, params)
+		}
+	}
+}
+
+func TestReloader_changeSinks(t *testing.T) {
+	builder := func(_ context.Context, _ config.ServiceConfig) (http.Handler, error) {
+		return http.NotFoundHandler(), nil
+	}
+	r := New(builder, nil, "", nil)
+
+	var events []ChangeEvent
+	r.AddChangeSink(ChangeSinkFunc(func(_ context.Context, e ChangeEvent) error {
+		events = append(events, e)
+		return nil
+	}))
+
+	var received ChangeEvent
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewDecoder(req.Body).Decode(&received)
+	}))
+	defer s.Close()
+	r.AddChangeSink(NewHTTPChangeSink(s.URL, nil))
+
+	ctx := context.Background()
+	cfg := config.ServiceConfig{Name: "gw", Endpoints: []*config.EndpointConfig{{Endpoint: "/a", Method: "GET"}}}
+	if err := r.Load(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("the initial load should not emit events: %+v", events)
+	}
+
+	cfg = config.ServiceConfig{Name: "gw", Endpoints: []*config.EndpointConfig{{Endpoint: "/b", Method: "GET"}}}
+	if err := r.Load(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || len(events[0].Changes) != 2 || events[0].Service != "gw" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if len(received.Changes) != 2 || received.Hash != events[0].Hash {
+		t.Errorf("unexpected event posted to the http sink: %+v", received)
+	}
+
+	cfg.Port = 8081
+	if err := r.Load(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Errorf("the reloads without endpoint changes should not emit events: %+v", events)
+	}
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package reload
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+// PipeCache keeps the pipes built for the endpoints of the current configuration, so a reload
+// only rebuilds the pipes of the endpoints whose definition changed. The reused pipes keep their
+// state (caches, circuit breakers, load balancers, sticky sessions...) across the reloads.
+//
+// The handlers are always rebuilt, since they are cheap and stateless. Any change outside the
+// endpoints (timeouts, service extra config...) rebuilds all the pipes, because they may depend
+// on it.
+//
+// Every build must be wrapped by Begin and Commit (or Rollback if the build fails), using the
+// factory returned by Wrap to create the pipes.
+type PipeCache struct {
+	mu      sync.Mutex
+	service string
+	pipes   map[string]proxy.Proxy
+	next    map[string]proxy.Proxy
+	reused  int
+	rebuilt int
+}
+
+// NewPipeCache returns an empty PipeCache
+func NewPipeCache() *PipeCache {
+	return &PipeCache{pipes: map[string]proxy.Proxy{}}
+}
+
+// Begin starts the build of the pipes for the service config. The pipes of the previous config
+// are only available for reuse if the rest of the service config is the same
+func (c *PipeCache) Begin(cfg config.ServiceConfig) error {
+	cfg.Name = ""
+	cfg.Endpoints = nil
+	service, err := hashOf(cfg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if service != c.service {
+		c.pipes = map[string]proxy.Proxy{}
+	}
+	c.service = service
+	c.next = map[string]proxy.Proxy{}
+	c.reused, c.rebuilt = 0, 0
+	c.mu.Unlock()
+	return nil
+}
+
+// Wrap returns a proxy.Factory returning the cached pipe of every endpoint with the same
+// definition and delegating the creation of the rest to the received factory
+func (c *PipeCache) Wrap(pf proxy.Factory) proxy.Factory {
+	return proxy.FactoryFunc(func(cfg *config.EndpointConfig) (proxy.Proxy, error) {
+		key, err := hashOf(cfg)
+		if err != nil {
+			return pf.New(cfg)
+		}
+
+		c.mu.Lock()
+		if p, ok := c.next[key]; ok {
+			c.mu.Unlock()
+			return p, nil
+		}
+		if p, ok := c.pipes[key]; ok && c.next != nil {
+			c.next[key] = p
+			c.reused++
+			c.mu.Unlock()
+			return p, nil
+		}
+		c.mu.Unlock()
+
+		p, err := pf.New(cfg)
+		if err != nil {
+			return p, err
+		}
+
+		c.mu.Lock()
+		if c.next != nil {
+			c.next[key] = p
+			c.rebuilt++
+		}
+		c.mu.Unlock()
+		return p, nil
+	})
+}
+
+// Commit replaces the cached pipes with the ones used by the last build, so the pipes of the
+// removed or modified endpoints are released
+func (c *PipeCache) Commit() {
+	c.mu.Lock()
+	if c.next != nil {
+		c.pipes, c.next = c.next, nil
+	}
+	c.mu.Unlock()
+}
+
+// Rollback discards the pipes created by the last build, keeping the ones of the current config
+func (c *PipeCache) Rollback() {
+	c.mu.Lock()
+	c.next = nil
+	c.mu.Unlock()
+}
+
+// Stats returns the number of pipes reused and rebuilt by the last build
+func (c *PipeCache) Stats() (reused, rebuilt int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reused, c.rebuilt
+}
+
+func hashOf(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.StdEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package reload
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestPipeCache(t *testing.T) {
+	var created []string
+	pf := proxy.FactoryFunc(func(cfg *config.EndpointConfig) (proxy.Proxy, error) {
+		created = append(created, cfg.Endpoint)
+		n := len(created)
+		return func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+			return &proxy.Response{Data: map[string]interface{}{"pipe": n}}, nil
+		}, nil
+	})
+
+	newConfig := func(timeout time.Duration, endpoints ...*config.EndpointConfig) config.ServiceConfig {
+		return config.ServiceConfig{Timeout: timeout, Endpoints: endpoints}
+	}
+	a := &config.EndpointConfig{Endpoint: "/a", Method: "GET"}
+	b := &config.EndpointConfig{Endpoint: "/b", Method: "GET"}
+	b2 := &config.EndpointConfig{Endpoint: "/b", Method: "GET", Timeout: time.Second}
+
+	c := NewPipeCache()
+	build := func(cfg config.ServiceConfig) map[string]interface{} {
+		if err := c.Begin(cfg); err != nil {
+			t.Fatal(err)
+		}
+		f := c.Wrap(pf)
+		res := map[string]interface{}{}
+		for _, e := range cfg.Endpoints {
+			p, err := f.New(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, _ := p(context.Background(), &proxy.Request{})
+			res[e.Endpoint] = resp.Data["pipe"]
+		}
+		return res
+	}
+
+	first := build(newConfig(time.Second, a, b))
+	c.Commit()
+	if reused, rebuilt := c.Stats(); reused != 0 || rebuilt != 2 {
+		t.Errorf("unexpected stats: %d %d", reused, rebuilt)
+	}
+
+	second := build(newConfig(time.Second, a, b2))
+	c.Commit()
+	if second["/a"] != first["/a"] {
+		t.Errorf("the pipe of the unchanged endpoint was rebuilt: %v %v", first, second)
+	}
+	if second["/b"] == first["/b"] {
+		t.Errorf("the pipe of the modified endpoint was reused: %v %v", first, second)
+	}
+	if reused, rebuilt := c.Stats(); reused != 1 || rebuilt != 1 {
+		t.Errorf("unexpected stats: %d %d", reused, rebuilt)
+	}
+
+	// a failed build does not release the pipes of the current config
+	build(newConfig(time.Second, b))
+	c.Rollback()
+	third := build(newConfig(time.Second, a, b2))
+	c.Commit()
+	if third["/a"] != second["/a"] || third["/b"] != second["/b"] {
+		t.Errorf("the pipes were rebuilt after a rollback: %v %v", second, third)
+	}
+
+	// a change outside the endpoints rebuilds all the pipes
+	fourth := build(newConfig(2*time.Second, a, b2))
+	c.Commit()
+	if fourth["/a"] == third["/a"] || fourth["/b"] == third["/b"] {
+		t.Errorf("the pipes were reused after a service change: %v %v", third, fourth)
+	}
+	if len(created) != 6 {
+		t.Errorf("unexpected number of pipes created: %d", len(created))
+	}
+}
@@ -0,0 +1,232 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package reload provides a runtime component able to rebuild the handler tree of the router
+from a new service configuration without restarting the process.
+
+The Reloader is an http.Handler delegating to the last built handler tree. When a new
+configuration is loaded, the new tree is built aside and atomically swapped, so the requests
+in flight keep being served by the tree that received them. Only the handler tree is rebuilt:
+changes in the listener settings (address, port, TLS...) still require a restart.
+
+The builders using a PipeCache only rebuild the pipes of the endpoints modified by the reload,
+so the unchanged ones keep their caches, breakers and balancers.
+
+The endpoints added, removed or with a modified contract by a reload are published to the
+change sinks registered with AddChangeSink, so the consumers of the API can be notified.
+*/
+package reload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const logPrefix = "[SERVICE: Reload]"
+
+// DefaultInterval is the period between checks of the config file when the watcher is
+// started without an explicit one
+const DefaultInterval = 5 * time.Second
+
+// ErrNoHandler is the error returned when the builder does not return a handler
+var ErrNoHandler = errors.New("the handler builder returned no handler")
+
+// HandlerBuilder creates the handler tree for the received service configuration. The
+// context is cancelled when the tree is replaced by a new one
+type HandlerBuilder func(context.Context, config.ServiceConfig) (http.Handler, error)
+
+// RunServerFunc is a func that will run the http Server with the given params
+type RunServerFunc func(context.Context, config.ServiceConfig, http.Handler) error
+
+// New returns a Reloader building the handler trees with the builder from the configurations
+// parsed from the file at the given path
+func New(builder HandlerBuilder, parser config.Parser, path string, logger logging.Logger) *Reloader {
+	if logger == nil {
+		logger = logging.NoOp
+	}
+	return &Reloader{
+		builder: builder,
+		parser:  parser,
+		path:    path,
+		logger:  logger,
+		mu:      new(sync.Mutex),
+	}
+}
+
+// Reloader is an http.Handler able to swap its handler tree at runtime
+type Reloader struct {
+	builder HandlerBuilder
+	parser  config.Parser
+	path    string
+	logger  logging.Logger
+	current atomic.Value
+
+	mu      *sync.Mutex
+	cfg     config.ServiceConfig
+	hash    string
+	modTime time.Time
+	cancel  context.CancelFunc
+	sinks   []ChangeSink
+}
+
+type generation struct {
+	handler http.Handler
+}
+
+// ServeHTTP implements the http.Handler interface, delegating to the current handler tree
+func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	g, ok := r.current.Load().(generation)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	g.handler.ServeHTTP(w, req)
+}
+
+// Config returns the configuration of the current handler tree
+func (r *Reloader) Config() config.ServiceConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg
+}
+
+// Reload parses the config file and, if it changed, builds and swaps the handler tree. If the
+// new configuration can not be parsed or built, the current tree is kept
+func (r *Reloader) Reload(ctx context.Context) error {
+	r.mu.Lock()
+	r.modTime = fileModTime(r.path)
+	r.mu.Unlock()
+
+	cfg, err := r.parser.Parse(r.path)
+	if err != nil {
+		return err
+	}
+	return r.Load(ctx, cfg)
+}
+
+// Load builds and swaps the handler tree for the received configuration. When it replaces a
+// previous configuration, the changes of the exposed endpoints are published to the change sinks
+func (r *Reloader) Load(ctx context.Context, cfg config.ServiceConfig) error {
+	r.mu.Lock()
+
+	hash, err := cfg.Hash()
+	if err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	if hash == r.hash {
+		r.mu.Unlock()
+		return nil
+	}
+
+	genCtx, cancel := context.WithCancel(ctx)
+	h, err := r.builder(genCtx, cfg)
+	if err == nil && h == nil {
+		err = ErrNoHandler
+	}
+	if err != nil {
+		cancel()
+		r.mu.Unlock()
+		return err
+	}
+
+	r.current.Store(generation{handler: h})
+	if r.cancel != nil {
+		r.cancel()
+	}
+	var changes []Change
+	if r.hash != "" && len(r.sinks) > 0 {
+		changes = Changelog(r.cfg, cfg)
+	}
+	r.cfg, r.hash, r.cancel = cfg, hash, cancel
+	sinks := r.sinks
+	r.mu.Unlock()
+
+	if len(changes) > 0 {
+		r.publish(ctx, sinks, ChangeEvent{Service: cfg.Name, Time: time.Now(), Hash: hash, Changes: changes})
+	}
+	return nil
+}
+
+// AddChangeSink registers a sink receiving the changes of the exposed endpoints applied by the
+// reloads
+func (r *Reloader) AddChangeSink(s ChangeSink) {
+	r.mu.Lock()
+	r.sinks = append(r.sinks, s)
+	r.mu.Unlock()
+}
+
+func (r *Reloader) publish(ctx context.Context, sinks []ChangeSink, e ChangeEvent) {
+	for _, s := range sinks {
+		if err := s.Publish(ctx, e); err != nil {
+			r.logger.Error(fmt.Sprintf("%s Unable to publish the API changes: %s", logPrefix, err.Error()))
+		}
+	}
+}
+
+// Watch reloads the configuration every time the config file is modified or the process
+// receives a SIGHUP, until the context is cancelled
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			r.reload(ctx, "SIGHUP received")
+		case <-ticker.C:
+			r.mu.Lock()
+			changed := !fileModTime(r.path).Equal(r.modTime)
+			r.mu.Unlock()
+			if changed {
+				r.reload(ctx, "config file changed")
+			}
+		}
+	}
+}
+
+func (r *Reloader) reload(ctx context.Context, reason string) {
+	if err := r.Reload(ctx); err != nil {
+		r.logger.Error(fmt.Sprintf("%s Unable to reload the configuration (%s): %s", logPrefix, reason, err.Error()))
+		return
+	}
+	r.logger.Info(fmt.Sprintf("%s Configuration reloaded (%s)", logPrefix, reason))
+}
+
+// Run loads the configuration, starts the watcher and runs the server with the Reloader as
+// its handler. It blocks until the server stops
+func (r *Reloader) Run(ctx context.Context, runServer RunServerFunc, interval time.Duration) error {
+	if err := r.Reload(ctx); err != nil {
+		return err
+	}
+	go r.Watch(ctx, interval)
+	return runServer(ctx, r.Config(), r)
+}
+
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package reload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "krakend.json")
+	if err := os.WriteFile(path, []byte("1"), 0o600); err != nil {
+		t.Error(err)
+		return
+	}
+
+	parser := config.ParserFunc(func(p string) (config.ServiceConfig, error) {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return config.ServiceConfig{}, err
+		}
+		if string(b) == "broken" {
+			return config.ServiceConfig{}, errors.New("broken config")
+		}
+		return config.ServiceConfig{Name: string(b), Port: len(b)}, nil
+	})
+
+	var builds int
+	cancelled := make(chan struct{}, 10)
+	builder := func(ctx context.Context, cfg config.ServiceConfig) (http.Handler, error) {
+		builds++
+		go func() {
+			<-ctx.Done()
+			cancelled <- struct{}{}
+		}()
+		gen := builds
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprintf(w, "%d", gen)
+		}), nil
+	}
+
+	r := New(builder, parser, path, nil)
+
+	if status := serve(r); status != "503" {
+		t.Errorf("unexpected response before loading the config: %s", status)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := r.Reload(ctx); err != nil {
+		t.Error(err)
+		return
+	}
+	if resp := serve(r); resp != "1" {
+		t.Errorf("unexpected response: %s", resp)
+	}
+
+	if err := r.Reload(ctx); err != nil {
+		t.Error(err)
+		return
+	}
+	if builds != 1 {
+		t.Errorf("unchanged configs should not trigger a rebuild. builds: %d", builds)
+	}
+
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	writeConfig(t, path, "broken")
+	time.Sleep(50 * time.Millisecond)
+	if resp := serve(r); resp != "1" {
+		t.Errorf("the current tree should be kept after a failed reload: %s", resp)
+	}
+
+	writeConfig(t, path, "22")
+	for i := 0; i < 100; i++ {
+		if serve(r) == "2" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if resp := serve(r); resp != "2" {
+		t.Errorf("the tree should have been swapped: %s", resp)
+	}
+	if cfg := r.Config(); cfg.Port != 2 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("the context of the replaced tree should have been cancelled")
+	}
+}
+
+func TestReloader_Load_ko(t *testing.T) {
+	r := New(func(_ context.Context, _ config.ServiceConfig) (http.Handler, error) {
+		return nil, nil
+	}, nil, "", nil)
+	if err := r.Load(context.Background(), config.ServiceConfig{}); err != ErrNoHandler {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func serve(h http.Handler) string {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	if w.Code != http.StatusOK {
+		return fmt.Sprintf("%d", w.Code)
+	}
+	b, _ := io.ReadAll(w.Body)
+	return string(b)
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Error(err)
+	}
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(path, future, future)
+}
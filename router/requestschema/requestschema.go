@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package requestschema validates the requests against the JSON schemas of their endpoints, so the
+invalid requests are rejected by the router before reaching the backends:
+
+	{
+		"endpoint": "/users",
+		"method": "POST",
+		"extra_config": {
+			"github.com/luraproject/lura/router/requestschema": {
+				"body": {
+					"type": "object",
+					"required": ["email"],
+					"properties": {"email": {"type": "string", "pattern": "@"}}
+				},
+				"query": {
+					"properties": {"dry_run": {"enum": ["true", "false"]}}
+				},
+				"headers_path": "./schemas/headers.json"
+			}
+		}
+	}
+
+The body, the query and the headers accept an inline schema or the path of a file storing it. The
+bodies are decoded as JSON, so the empty bodies are validated as null. The query strings and the
+headers are validated as objects, with the canonical names of the headers as keys, the value of
+the params received once as strings and the values of the repeated ones as arrays of strings.
+
+The rejected requests get a 400 Bad Request listing the violations.
+*/
+package requestschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/jsonschema"
+)
+
+// Namespace is the key to use to store and access the request schema config
+const Namespace = "github.com/luraproject/lura/router/requestschema"
+
+// The parts of the requests
+const (
+	InBody    = "body"
+	InQuery   = "query"
+	InHeaders = "headers"
+)
+
+// Violation is a constraint of a schema not satisfied by a part of the request
+type Violation struct {
+	In      string `json:"in"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Error is returned for the requests not satisfying the schemas of their endpoint
+type Error struct {
+	Violations []Violation `json:"violations"`
+}
+
+// Error returns a string representation of the Error
+func (e Error) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		path := v.Path
+		if path == "" {
+			path = "/"
+		}
+		msgs[i] = v.In + " " + path + ": " + v.Message
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+// StatusCode returns the status of the responses to the rejected requests
+func (Error) StatusCode() int { return http.StatusBadRequest }
+
+// ErrorCode returns the code of the error, for the structured error responses
+func (Error) ErrorCode() string { return "invalid_request" }
+
+// Encoding returns the content type of the body of the error
+func (Error) Encoding() string { return "application/json" }
+
+// Body returns the JSON representation of the error, listing its violations
+func (e Error) Body() []byte {
+	b, _ := json.Marshal(struct {
+		Error      string      `json:"error"`
+		Violations []Violation `json:"violations"`
+	}{e.ErrorCode(), e.Violations})
+	return b
+}
+
+// StatusCode returns the status of the responses to the requests rejected with the error: the one
+// declared by the error, if any, or a 400 Bad Request
+func StatusCode(err error) int {
+	if t, ok := err.(interface{ StatusCode() int }); ok {
+		return t.StatusCode()
+	}
+	return http.StatusBadRequest
+}
+
+type rawConfig struct {
+	Body        map[string]interface{} `json:"body"`
+	BodyPath    string                 `json:"body_path"`
+	Query       map[string]interface{} `json:"query"`
+	QueryPath   string                 `json:"query_path"`
+	Headers     map[string]interface{} `json:"headers"`
+	HeadersPath string                 `json:"headers_path"`
+}
+
+// Validator checks the requests to an endpoint
+type Validator struct {
+	body    *jsonschema.Schema
+	query   *jsonschema.Schema
+	headers *jsonschema.Schema
+}
+
+// New returns the Validator of the endpoint, and false if the endpoint does not declare any
+// schema. It fails if the config is not valid or any of the schemas can not be compiled
+func New(cfg *config.EndpointConfig) (*Validator, bool, error) {
+	tmp, ok := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return nil, true, err
+	}
+	var raw rawConfig
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, true, fmt.Errorf("requestschema: parsing the config: %w", err)
+	}
+
+	v := &Validator{}
+	for _, s := range []struct {
+		in   string
+		doc  map[string]interface{}
+		path string
+		dst  **jsonschema.Schema
+	}{
+		{InBody, raw.Body, raw.BodyPath, &v.body},
+		{InQuery, raw.Query, raw.QueryPath, &v.query},
+		{InHeaders, raw.Headers, raw.HeadersPath, &v.headers},
+	} {
+		if s.doc == nil && s.path == "" {
+			continue
+		}
+		schema, err := jsonschema.Load(s.doc, s.path)
+		if err != nil {
+			return nil, true, fmt.Errorf("requestschema: the %s schema of the endpoint %s: %w", s.in, cfg.Endpoint, err)
+		}
+		*s.dst = schema
+	}
+	if v.body == nil && v.query == nil && v.headers == nil {
+		return nil, true, fmt.Errorf("requestschema: the endpoint %s declares no schema", cfg.Endpoint)
+	}
+	return v, true, nil
+}
+
+// Validate returns an Error if the request does not satisfy the schemas. The body of the request
+// is read and replaced by a copy, so it can be sent to the backends
+func (v *Validator) Validate(r *http.Request) error {
+	var violations []Violation
+	add := func(in string, vs []jsonschema.Violation) {
+		for _, v := range vs {
+			violations = append(violations, Violation{In: in, Path: v.Path, Message: v.Message})
+		}
+	}
+
+	if v.headers != nil {
+		add(InHeaders, v.headers.Validate(values(r.Header)))
+	}
+	if v.query != nil {
+		add(InQuery, v.query.Validate(values(r.URL.Query())))
+	}
+	if v.body != nil {
+		var b []byte
+		if r.Body != nil {
+			var err error
+			if b, err = io.ReadAll(r.Body); err != nil {
+				return err
+			}
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(b))
+		}
+		var data interface{}
+		if len(bytes.TrimSpace(b)) > 0 {
+			d := json.NewDecoder(bytes.NewReader(b))
+			d.UseNumber()
+			if err := d.Decode(&data); err != nil {
+				violations = append(violations, Violation{In: InBody, Message: "invalid JSON: " + err.Error()})
+				return Error{Violations: violations}
+			}
+		}
+		add(InBody, v.body.Validate(data))
+	}
+
+	if len(violations) > 0 {
+		return Error{Violations: violations}
+	}
+	return nil
+}
+
+// values returns the params received once as strings and the repeated ones as arrays of strings
+func values(params map[string][]string) map[string]interface{} {
+	res := make(map[string]interface{}, len(params))
+	for k, vs := range params {
+		if len(vs) == 1 {
+			res[k] = vs[0]
+			continue
+		}
+		list := make([]interface{}, len(vs))
+		for i, v := range vs {
+			list[i] = v
+		}
+		res[k] = list
+	}
+	return res
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package requestschema
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func newValidator(t *testing.T) *Validator {
+	t.Helper()
+	v, ok, err := New(&config.EndpointConfig{
+		Endpoint: "/users",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			"body": map[string]interface{}{
+				"type":     "object",
+				"required": []interface{}{"email"},
+				"properties": map[string]interface{}{
+					"age": map[string]interface{}{"type": "integer", "minimum": 18},
+				},
+			},
+			"query": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"tag": map[string]interface{}{"type": "array", "maxItems": 2},
+				},
+			},
+			"headers": map[string]interface{}{
+				"required": []interface{}{"X-Tenant"},
+			},
+		}},
+	})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	return v
+}
+
+func TestValidator(t *testing.T) {
+	v := newValidator(t)
+	body := `{"email": "jane@example.com", "age": 30}`
+	req, _ := http.NewRequest("POST", "http://example.com/users?tag=a&tag=b", strings.NewReader(body))
+	req.Header.Set("X-Tenant", "acme")
+	if err := v.Validate(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, _ := io.ReadAll(req.Body)
+	if string(b) != body {
+		t.Errorf("unexpected body after the validation: %s", b)
+	}
+}
+
+func TestValidator_ko(t *testing.T) {
+	v := newValidator(t)
+	req, _ := http.NewRequest("POST", "http://example.com/users?tag=a&tag=b&tag=c", strings.NewReader(`{"age": 17}`))
+	err := v.Validate(req)
+	e, ok := err.(Error)
+	if !ok {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if e.StatusCode() != http.StatusBadRequest || e.ErrorCode() != "invalid_request" {
+		t.Errorf("unexpected status or code: %d %s", e.StatusCode(), e.ErrorCode())
+	}
+	expected := "invalid request: headers /: missing required property X-Tenant; query /tag: more than 2 items; body /: missing required property email; body /age: less than the minimum 18"
+	if e.Error() != expected {
+		t.Errorf("unexpected error:\nhave: %s\nwant: %s", e.Error(), expected)
+	}
+
+	req, _ = http.NewRequest("POST", "http://example.com/users", strings.NewReader(`{"email":`))
+	req.Header.Set("X-Tenant", "acme")
+	if e, ok := v.Validate(req).(Error); !ok || len(e.Violations) != 1 || !strings.HasPrefix(e.Violations[0].Message, "invalid JSON") {
+		t.Errorf("unexpected error %v", e)
+	}
+}
+
+func TestError_Body(t *testing.T) {
+	e := Error{Violations: []Violation{{In: InQuery, Path: "/page", Message: "expected integer, got string"}}}
+	expected := `{"error":"invalid_request","violations":[{"in":"query","path":"/page","message":"expected integer, got string"}]}`
+	if string(e.Body()) != expected {
+		t.Errorf("unexpected body %s", e.Body())
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	if s := StatusCode(Error{}); s != http.StatusBadRequest {
+		t.Errorf("unexpected status %d", s)
+	}
+	if s := StatusCode(io.EOF); s != http.StatusBadRequest {
+		t.Errorf("unexpected status %d", s)
+	}
+	if s := StatusCode(statusError(http.StatusUnprocessableEntity)); s != http.StatusUnprocessableEntity {
+		t.Errorf("unexpected status %d", s)
+	}
+}
+
+type statusError int
+
+func (statusError) Error() string     { return "invalid" }
+func (s statusError) StatusCode() int { return int(s) }
+
+func TestNew(t *testing.T) {
+	if _, ok, err := New(&config.EndpointConfig{}); ok || err != nil {
+		t.Errorf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	for _, e := range []map[string]interface{}{
+		{},
+		{"body_path": "/unknown/schema.json"},
+		{"query": map[string]interface{}{"pattern": "("}},
+	} {
+		if _, ok, err := New(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{Namespace: e}}); !ok || err == nil {
+			t.Errorf("expecting an error for %v", e)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package secure adds the security headers to the responses of the router.
+
+The headers declared in the service extra config are added to every response, including the
+404 and 405 ones:
+
+	"extra_config": {
+		"github.com/luraproject/lura/router/secure": {
+			"sts_seconds": 31536000,
+			"sts_include_subdomains": true,
+			"content_type_nosniff": true,
+			"frame_options": "DENY",
+			"content_security_policy": "default-src 'self'",
+			"referrer_policy": "no-referrer"
+		}
+	}
+
+The endpoints can override any of them by declaring the same keys in their own extra config.
+The empty values and the zero sts_seconds remove the header from the endpoint responses.
+*/
+package secure
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the secure headers config
+const Namespace = "github.com/luraproject/lura/router/secure"
+
+const (
+	stsHeader            = "Strict-Transport-Security"
+	noSniffHeader        = "X-Content-Type-Options"
+	frameOptionsHeader   = "X-Frame-Options"
+	cspHeader            = "Content-Security-Policy"
+	referrerPolicyHeader = "Referrer-Policy"
+)
+
+// Headers is the set of security headers to add to the responses. The empty values delete the
+// header from the response
+type Headers []Header
+
+// Header is a security header
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Apply sets the headers in h
+func (hs Headers) Apply(h http.Header) {
+	for _, header := range hs {
+		if header.Value == "" {
+			h.Del(header.Name)
+			continue
+		}
+		h.Set(header.Name, header.Value)
+	}
+}
+
+// Handler decorates the handler, so the headers are added to all its responses. It implements
+// the mux HandlerMiddleware interface
+func (hs Headers) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hs.Apply(w.Header())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// FromExtraConfig returns the headers declared in the extra config. Only the declared keys are
+// returned, so the headers of an endpoint can be applied over the service ones
+func FromExtraConfig(e config.ExtraConfig) (Headers, bool) {
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	hs := Headers{}
+
+	if v, ok := tmp["sts_seconds"]; ok {
+		value := ""
+		if seconds := getInt(v); seconds > 0 {
+			value = "max-age=" + strconv.Itoa(seconds)
+			if b, _ := tmp["sts_include_subdomains"].(bool); b {
+				value += "; includeSubDomains"
+			}
+			if b, _ := tmp["sts_preload"].(bool); b {
+				value += "; preload"
+			}
+		}
+		hs = append(hs, Header{Name: stsHeader, Value: value})
+	}
+	if v, ok := tmp["content_type_nosniff"]; ok {
+		value := ""
+		if b, _ := v.(bool); b {
+			value = "nosniff"
+		}
+		hs = append(hs, Header{Name: noSniffHeader, Value: value})
+	}
+	for _, kv := range [][2]string{
+		{"frame_options", frameOptionsHeader},
+		{"content_security_policy", cspHeader},
+		{"referrer_policy", referrerPolicyHeader},
+	} {
+		if v, ok := tmp[kv[0]]; ok {
+			s, _ := v.(string)
+			hs = append(hs, Header{Name: kv[1], Value: s})
+		}
+	}
+	return hs, true
+}
+
+var (
+	global   Headers
+	globalMu sync.RWMutex
+)
+
+// Register sets the headers declared in the service extra config as the ones added to every
+// response. It returns false if the service does not declare any
+func Register(cfg config.ServiceConfig) (Headers, bool) {
+	hs, ok := FromExtraConfig(cfg.ExtraConfig)
+	globalMu.Lock()
+	global = hs
+	globalMu.Unlock()
+	return hs, ok
+}
+
+// EndpointHeaders returns the headers to apply to the responses of the endpoint: the service
+// ones overridden by the ones declared by the endpoint. It returns false if the endpoint does
+// not declare any
+func EndpointHeaders(cfg *config.EndpointConfig) (Headers, bool) {
+	hs, ok := FromExtraConfig(cfg.ExtraConfig)
+	if !ok {
+		return nil, false
+	}
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return append(append(Headers{}, global...), hs...), true
+}
+
+func getInt(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	case int64:
+		return int(n)
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package secure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestHeaders_Handler(t *testing.T) {
+	hs, ok := FromExtraConfig(config.ExtraConfig{Namespace: map[string]interface{}{
+		"sts_seconds":             31536000.0,
+		"sts_include_subdomains":  true,
+		"sts_preload":             true,
+		"content_type_nosniff":    true,
+		"frame_options":           "DENY",
+		"content_security_policy": "default-src 'self'",
+		"referrer_policy":         "no-referrer",
+	}})
+	if !ok {
+		t.Fatal("the config declares the headers")
+	}
+
+	w := httptest.NewRecorder()
+	hs.Handler(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest("GET", "/", http.NoBody))
+
+	for k, v := range map[string]string{
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains; preload",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Content-Security-Policy":   "default-src 'self'",
+		"Referrer-Policy":           "no-referrer",
+	} {
+		if have := w.Header().Get(k); have != v {
+			t.Errorf("unexpected %s: %s", k, have)
+		}
+	}
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unexpected status code %d", w.Code)
+	}
+}
+
+func TestEndpointHeaders(t *testing.T) {
+	defer Register(config.ServiceConfig{})
+
+	if _, ok := Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"frame_options":        "DENY",
+		"content_type_nosniff": true,
+	}}}); !ok {
+		t.Fatal("the service declares the headers")
+	}
+
+	if _, ok := EndpointHeaders(&config.EndpointConfig{}); ok {
+		t.Error("the endpoint does not declare headers")
+	}
+
+	hs, ok := EndpointHeaders(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"frame_options":           "",
+		"content_security_policy": "default-src 'none'",
+	}}})
+	if !ok {
+		t.Fatal("the endpoint declares headers")
+	}
+	h := http.Header{}
+	h.Set("X-Frame-Options", "SAMEORIGIN")
+	hs.Apply(h)
+
+	if h.Get("X-Frame-Options") != "" {
+		t.Errorf("the endpoint should remove the frame options: %v", h)
+	}
+	if h.Get("X-Content-Type-Options") != "nosniff" || h.Get("Content-Security-Policy") != "default-src 'none'" {
+		t.Errorf("unexpected headers: %v", h)
+	}
+}
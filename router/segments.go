@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// EndpointPaths returns the route patterns to register for the path of an endpoint. The routers
+// do not support optional segments, so an endpoint ending with one of them ({param?} or
+// :param?) is registered both without the segment and with a regular param
+func EndpointPaths(path string) []string {
+	name, ok := optionalParam(path)
+	if !ok {
+		return []string{path}
+	}
+	i := strings.LastIndex(path, "/")
+	without := path[:i]
+	if without == "" {
+		without = "/"
+	}
+	segment := strings.TrimSuffix(path[i+1:], config.OptionalSegmentMarker)
+	if strings.HasPrefix(segment, "{") {
+		segment = "{" + name + "}"
+	}
+	return []string{without, path[:i+1] + segment}
+}
+
+// OptionalParams returns the params of the endpoint missing from the requests matching the
+// route registered without its optional segment, with the key used by the request params
+func OptionalParams(path string) []string {
+	name, ok := optionalParam(path)
+	if !ok {
+		return nil
+	}
+	return []string{strings.ToUpper(name[:1]) + name[1:]}
+}
+
+// SetOptionalParams adds an empty value for every optional param missing from the params, so
+// the placeholders of the backend url patterns are always replaced
+func SetOptionalParams(params map[string]string, optional []string) {
+	for _, p := range optional {
+		if _, ok := params[p]; !ok {
+			params[p] = ""
+		}
+	}
+}
+
+func optionalParam(path string) (string, bool) {
+	segment := path[strings.LastIndex(path, "/")+1:]
+	switch {
+	case strings.HasPrefix(segment, ":") && strings.HasSuffix(segment, config.OptionalSegmentMarker):
+		name := strings.TrimSuffix(segment[1:], config.OptionalSegmentMarker)
+		return name, name != ""
+	case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, config.OptionalSegmentMarker+"}"):
+		name := strings.TrimSuffix(segment[1:len(segment)-1], config.OptionalSegmentMarker)
+		return name, name != ""
+	}
+	return "", false
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEndpointPaths(t *testing.T) {
+	for path, expected := range map[string][]string{
+		"/users":             {"/users"},
+		"/users/:id":         {"/users/:id"},
+		"/users/:id?":        {"/users", "/users/:id"},
+		"/users/{id?}":       {"/users", "/users/{id}"},
+		"/:id?":              {"/", "/:id"},
+		"/files/*path":       {"/files/*path"},
+		"/files/{path...}":   {"/files/{path...}"},
+		"/users/:id/:order?": {"/users/:id", "/users/:id/:order"},
+	} {
+		if have := EndpointPaths(path); !reflect.DeepEqual(have, expected) {
+			t.Errorf("%s: unexpected paths %v", path, have)
+		}
+	}
+}
+
+func TestSetOptionalParams(t *testing.T) {
+	if p := OptionalParams("/users/:id"); len(p) != 0 {
+		t.Errorf("unexpected optional params %v", p)
+	}
+	optional := OptionalParams("/users/{userId?}")
+	if !reflect.DeepEqual(optional, []string{"UserId"}) {
+		t.Errorf("unexpected optional params %v", optional)
+	}
+
+	params := map[string]string{}
+	SetOptionalParams(params, optional)
+	if v, ok := params["UserId"]; !ok || v != "" {
+		t.Errorf("unexpected params %v", params)
+	}
+	params = map[string]string{"UserId": "42"}
+	SetOptionalParams(params, optional)
+	if params["UserId"] != "42" {
+		t.Errorf("unexpected params %v", params)
+	}
+}
@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package static serves small assets from the local filesystem, like the openapi specs or the
+status pages, so they do not require a separate web server.
+
+The routes are declared in the service extra config. Every route serves a single file or the
+content of a directory:
+
+	"extra_config": {
+		"github.com/luraproject/lura/router/static": {
+			"max_age": "1h",
+			"routes": [
+				{"path": "/openapi.json", "file": "./specs/openapi.json", "max_age": "5m"},
+				{"path": "/status", "dir": "./status", "index": "index.html"}
+			]
+		}
+	}
+
+The routes only accept GET and HEAD requests. The responses carry a Cache-Control header with
+the max_age of the route, or the one of the config, and the Last-Modified and ETag headers, so the
+conditional and the range requests are supported. The requests for a directory get its index
+file, index.html by default, and a 404 if there is none: the directories are never listed. The
+hidden files and directories, whose name starts with a dot, are never served either.
+*/
+package static
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/core"
+)
+
+// Namespace is the key to use to store and access the static content config
+const Namespace = "github.com/luraproject/lura/router/static"
+
+// DefaultIndex is the file served for the directories when the route does not declare one
+const DefaultIndex = "index.html"
+
+// Config is the static content config of the service
+type Config struct {
+	MaxAge string  `json:"max_age"`
+	Routes []Route `json:"routes"`
+}
+
+// Route is a path of the router served from the filesystem
+type Route struct {
+	Path   string `json:"path"`
+	File   string `json:"file"`
+	Dir    string `json:"dir"`
+	Index  string `json:"index"`
+	MaxAge string `json:"max_age"`
+
+	maxAge time.Duration
+}
+
+// IsDir returns true if the route serves the content of a directory
+func (r Route) IsDir() bool { return r.Dir != "" }
+
+// ConfigGetter parses and validates the static content config from the service extra config. It
+// returns false if the service does not declare any static content
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("static: parsing the config: %w", err)
+	}
+
+	maxAge, err := parseMaxAge(cfg.MaxAge)
+	if err != nil {
+		return cfg, true, err
+	}
+	for i, r := range cfg.Routes {
+		if !strings.HasPrefix(r.Path, "/") || path.Clean(r.Path) != r.Path {
+			return cfg, true, fmt.Errorf("static: invalid path %q", r.Path)
+		}
+		if (r.File == "") == (r.Dir == "") {
+			return cfg, true, fmt.Errorf("static: the route %s must declare either a file or a dir", r.Path)
+		}
+		if r.IsDir() && r.Path == "/" {
+			return cfg, true, fmt.Errorf("static: the route %s can not serve the root of the router", r.Path)
+		}
+		info, err := os.Stat(r.File + r.Dir)
+		if err != nil {
+			return cfg, true, fmt.Errorf("static: the route %s: %w", r.Path, err)
+		}
+		if info.IsDir() != r.IsDir() {
+			return cfg, true, fmt.Errorf("static: the route %s: unexpected kind of file %s", r.Path, r.File+r.Dir)
+		}
+		if r.Index == "" {
+			r.Index = DefaultIndex
+		}
+		r.maxAge = maxAge
+		if r.MaxAge != "" {
+			if r.maxAge, err = parseMaxAge(r.MaxAge); err != nil {
+				return cfg, true, err
+			}
+		}
+		cfg.Routes[i] = r
+	}
+	return cfg, true, nil
+}
+
+func parseMaxAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("static: invalid max age %s", s)
+	}
+	return d, nil
+}
+
+// Handler returns the handler of the route. The routes serving a directory resolve the files
+// with the path of the request, minus the path of the route
+func Handler(r Route) http.Handler {
+	cacheControl := "no-cache"
+	if r.maxAge > 0 {
+		cacheControl = "public, max-age=" + strconv.Itoa(int(r.maxAge.Seconds()))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(core.KrakendHeaderName, core.KrakendHeaderValue)
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := r.File
+		if r.IsDir() {
+			rel := strings.TrimPrefix(req.URL.Path, r.Path)
+			if rel == "" {
+				http.Redirect(w, req, r.Path+"/", http.StatusMovedPermanently)
+				return
+			}
+			if hidden(rel) {
+				http.NotFound(w, req)
+				return
+			}
+			name = filepath.Join(r.Dir, filepath.FromSlash(path.Clean("/"+rel)))
+			if strings.HasSuffix(rel, "/") {
+				name = filepath.Join(name, r.Index)
+			}
+		}
+
+		f, err := os.Open(name)
+		if err != nil {
+			http.NotFound(w, req)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			if err == nil && r.IsDir() {
+				http.Redirect(w, req, req.URL.Path+"/", http.StatusMovedPermanently)
+				return
+			}
+			http.NotFound(w, req)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Cache-Control", cacheControl)
+		h.Set("ETag", fmt.Sprintf(`W/"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+		h.Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, req, info.Name(), info.ModTime(), f)
+	})
+}
+
+// hidden returns true if any of the segments of the relative path starts with a dot
+func hidden(rel string) bool {
+	for _, s := range strings.Split(rel, "/") {
+		if strings.HasPrefix(s, ".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package static
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func newTestRoutes(t *testing.T) (Route, Route) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"openapi.json":        `{"openapi":"3.0.0"}`,
+		"site/index.html":     "<h1>ok</h1>",
+		"site/docs/page.html": "page",
+		"site/.env":           "SECRET=1",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"max_age": "1h",
+		"routes": []interface{}{
+			map[string]interface{}{"path": "/openapi.json", "file": filepath.Join(dir, "openapi.json"), "max_age": "5m"},
+			map[string]interface{}{"path": "/status", "dir": filepath.Join(dir, "site")},
+		},
+	}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result %v %v", ok, err)
+	}
+	return cfg.Routes[0], cfg.Routes[1]
+}
+
+func TestHandler_file(t *testing.T) {
+	file, _ := newTestRoutes(t)
+	h := Handler(file)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"openapi":"3.0.0"}` {
+		t.Errorf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type %s", ct)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=300" {
+		t.Errorf("unexpected cache control %s", cc)
+	}
+
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("unexpected status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/openapi.json", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status %d", w.Code)
+	}
+}
+
+func TestHandler_dir(t *testing.T) {
+	_, dir := newTestRoutes(t)
+	h := Handler(dir)
+
+	for _, tc := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/status/", http.StatusOK, "<h1>ok</h1>"},
+		{"/status/docs/page.html", http.StatusOK, "page"},
+		{"/status", http.StatusMovedPermanently, ""},
+		{"/status/docs", http.StatusMovedPermanently, ""},
+		{"/status/docs/", http.StatusNotFound, ""},
+		{"/status/.env", http.StatusNotFound, ""},
+		{"/status/../openapi.json", http.StatusNotFound, ""},
+		{"/status/missing.html", http.StatusNotFound, ""},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.URL.Path = tc.path
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("%s: unexpected status %d", tc.path, w.Code)
+		}
+		if tc.body != "" && w.Body.String() != tc.body {
+			t.Errorf("%s: unexpected body %s", tc.path, w.Body.String())
+		}
+	}
+}
+
+func TestConfigGetter_invalid(t *testing.T) {
+	dir := t.TempDir()
+	for _, routes := range []map[string]interface{}{
+		{"path": "status", "dir": dir},
+		{"path": "/status/", "dir": dir},
+		{"path": "/", "dir": dir},
+		{"path": "/status", "dir": dir, "file": dir},
+		{"path": "/status"},
+		{"path": "/status", "file": dir},
+		{"path": "/status", "dir": filepath.Join(dir, "missing")},
+		{"path": "/status", "dir": dir, "max_age": "forever"},
+	} {
+		if _, _, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"routes": []interface{}{routes}}}); err == nil {
+			t.Errorf("%v: error expected", routes)
+		}
+	}
+	if _, ok, _ := ConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("unexpected config")
+	}
+}
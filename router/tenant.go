@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// HostRoutes returns the routes ("METHOD path") exposed by the endpoints restricted to a set of
+// hosts, like the ones of the tenants. The routers dispatch the requests to these routes with a
+// HostMatcher, since several endpoints can share them
+func HostRoutes(endpoints []*config.EndpointConfig) map[string]bool {
+	routes := map[string]bool{}
+	for _, e := range endpoints {
+		if len(e.Hosts) == 0 {
+			continue
+		}
+		method := strings.ToTitle(e.Method)
+		paths := EndpointPaths(e.Endpoint)
+		for _, a := range e.Aliases {
+			paths = append(paths, EndpointPaths(AliasEndpoint(e, a).Endpoint)...)
+		}
+		for _, p := range paths {
+			routes[method+" "+p] = true
+		}
+	}
+	return routes
+}
+
+// HostMatcher resolves which one of the handlers sharing a route serves the host of a request.
+// The exact hosts have precedence over the wildcards ("*.example.com"), and the handler
+// registered without hosts serves the rest of them
+type HostMatcher struct {
+	exact     map[string]int
+	wildcards []hostWildcard
+	fallback  int
+	size      int
+}
+
+type hostWildcard struct {
+	suffix string
+	index  int
+}
+
+// NewHostMatcher returns an empty HostMatcher
+func NewHostMatcher() *HostMatcher {
+	return &HostMatcher{exact: map[string]int{}, fallback: -1}
+}
+
+// Add registers the hosts of the next handler of the route and returns its index. An empty set
+// of hosts registers the fallback handler. It returns false if any of the hosts was already
+// registered, and then nothing is registered
+func (h *HostMatcher) Add(hosts []string) (int, bool) {
+	if len(hosts) == 0 {
+		if h.fallback != -1 {
+			return -1, false
+		}
+		h.fallback = h.size
+		h.size++
+		return h.fallback, true
+	}
+	for _, host := range hosts {
+		if _, ok := h.exact[host]; ok {
+			return -1, false
+		}
+		for _, w := range h.wildcards {
+			if "*"+w.suffix == host {
+				return -1, false
+			}
+		}
+	}
+	i := h.size
+	h.size++
+	for _, host := range hosts {
+		if strings.HasPrefix(host, "*.") {
+			h.wildcards = append(h.wildcards, hostWildcard{suffix: host[1:], index: i})
+			continue
+		}
+		h.exact[host] = i
+	}
+	return i, true
+}
+
+// Match returns the index of the handler serving the host of the request, or -1 if there is none
+func (h *HostMatcher) Match(r *http.Request) int {
+	host := RequestHost(r)
+	if i, ok := h.exact[host]; ok {
+		return i
+	}
+	for _, w := range h.wildcards {
+		if strings.HasSuffix(host, w.suffix) {
+			return w.index
+		}
+	}
+	return h.fallback
+}
+
+// RequestHost returns the host of the request in lower case, without the port and the trailing dot
+func RequestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// HostHandler dispatches the requests to a route shared by several endpoints to the handler of
+// the one serving their host. The requests to other hosts get a 404 Not Found response
+type HostHandler struct {
+	matcher  *HostMatcher
+	handlers []http.Handler
+}
+
+// NewHostHandler returns a HostHandler without handlers
+func NewHostHandler() *HostHandler {
+	return &HostHandler{matcher: NewHostMatcher()}
+}
+
+// Add registers the handler of the endpoint serving the hosts. It returns false if any of the
+// hosts is already served by another handler
+func (h *HostHandler) Add(hosts []string, handler http.Handler) bool {
+	if _, ok := h.matcher.Add(hosts); !ok {
+		return false
+	}
+	h.handlers = append(h.handlers, handler)
+	return true
+}
+
+// ServeHTTP implements the http.Handler interface
+func (h *HostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	i := h.matcher.Match(r)
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	h.handlers[i].ServeHTTP(w, r)
+}
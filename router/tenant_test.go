@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestHostRoutes(t *testing.T) {
+	routes := HostRoutes([]*config.EndpointConfig{
+		{Endpoint: "/users", Method: "get"},
+		{Endpoint: "/items/:id?", Method: "get", Hosts: []string{"a.com"}},
+		{Endpoint: "/orders", Method: "post", Hosts: []string{"a.com"}, Aliases: []config.EndpointAlias{{Path: "/purchases"}}},
+	})
+	expected := []string{"GET /items", "GET /items/:id", "POST /orders", "POST /purchases"}
+	if len(routes) != len(expected) {
+		t.Errorf("unexpected routes: %v", routes)
+	}
+	for _, r := range expected {
+		if !routes[r] {
+			t.Errorf("missing route %s: %v", r, routes)
+		}
+	}
+}
+
+func TestHostMatcher(t *testing.T) {
+	m := NewHostMatcher()
+	for i, hosts := range [][]string{{"a.com", "b.com"}, {"*.a.com"}, nil} {
+		if j, ok := m.Add(hosts); !ok || i != j {
+			t.Errorf("unexpected result for %v: %d %v", hosts, j, ok)
+		}
+	}
+	for _, hosts := range [][]string{{"c.com", "b.com"}, {"*.a.com"}, {}} {
+		if _, ok := m.Add(hosts); ok {
+			t.Errorf("the hosts %v were already registered", hosts)
+		}
+	}
+
+	for host, expected := range map[string]int{
+		"a.com":           0,
+		"B.com:8080":      0,
+		"a.com.":          0,
+		"api.a.com":       1,
+		"v1.api.a.com":    1,
+		"c.com":           2,
+		"[::1]:8080":      2,
+		"notreally-a.com": 2,
+	} {
+		r := httptest.NewRequest("GET", "/", http.NoBody)
+		r.Host = host
+		if i := m.Match(r); i != expected {
+			t.Errorf("unexpected handler for %s: %d", host, i)
+		}
+	}
+}
+
+func TestHostHandler(t *testing.T) {
+	h := NewHostHandler()
+	for _, name := range []string{"a", "b"} {
+		name := name
+		if !h.Add([]string{name + ".com"}, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			io.WriteString(w, name)
+		})) {
+			t.Errorf("unable to add the handler %s", name)
+		}
+	}
+	if h.Add([]string{"a.com"}, http.NotFoundHandler()) {
+		t.Error("the host was already served")
+	}
+
+	for host, expected := range map[string]string{"a.com": "a", "b.com:80": "b"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", http.NoBody)
+		r.Host = host
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Body.String() != expected {
+			t.Errorf("unexpected response for %s: %d %s", host, w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", http.NoBody)
+	r.Host = "c.com"
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for expr, cases := range map[string]map[string]bool{
+		"*/15 * * * *": {
+			"2023-01-04T10:00:00Z": true,
+			"2023-01-04T10:45:00Z": true,
+			"2023-01-04T10:20:00Z": false,
+		},
+		"0 2 1,15 * *": {
+			"2023-01-15T02:00:00Z": true,
+			"2023-01-16T02:00:00Z": false,
+		},
+		"0 0 * 6-8 0": {
+			"2023-07-02T00:00:00Z": true, // sunday in july
+			"2023-07-03T00:00:00Z": false,
+			"2023-01-01T00:00:00Z": false, // sunday in january
+		},
+		"0 0 13 * 5": { // friday or the 13th
+			"2023-01-13T00:00:00Z": true,
+			"2023-01-06T00:00:00Z": true,
+			"2023-01-07T00:00:00Z": false,
+		},
+		"0 0 * * 7": {
+			"2023-01-08T00:00:00Z": true, // sunday
+		},
+	} {
+		c, err := ParseCron(expr)
+		if err != nil {
+			t.Errorf("%s: %s", expr, err.Error())
+			continue
+		}
+		for when, expected := range cases {
+			ts, _ := time.Parse(time.RFC3339, when)
+			if c.Matches(ts) != expected {
+				t.Errorf("%s at %s: expecting %v", expr, when, expected)
+			}
+		}
+	}
+}
+
+func TestParseCron_invalid(t *testing.T) {
+	for _, expr := range []string{
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("expecting an error for %s", expr)
+		}
+	}
+}
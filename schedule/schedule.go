@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package schedule restricts the endpoints and the backends to a set of active time windows.
+
+The windows are declared in the extra config of the endpoints or the backends, as daily time
+ranges or as cron expressions opening the schedule for a duration:
+
+	"github.com/luraproject/lura/schedule": {
+		"timezone": "Europe/Madrid",
+		"windows": [
+			{"start": "02:00", "end": "04:00"},
+			{"days": ["sat", "sun"], "start": "22:00", "end": "01:00"}
+		],
+		"cron": [
+			{"expression": "30 12 * * 1-5", "duration": "15m"}
+		]
+	}
+
+The schedule is active while any of its windows is open. The windows ending before they start
+cross the midnight and their days refer to the day they start. The cron expressions have the
+standard five fields (minute, hour, day of month, month and day of week) supporting lists,
+ranges and steps.
+*/
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key to use to store and access the schedule config
+const Namespace = "github.com/luraproject/lura/schedule"
+
+// MaxCronDuration is the max time a cron expression can keep the schedule open
+const MaxCronDuration = 24 * time.Hour
+
+// ErrClosed is returned when a request arrives out of the active windows
+var ErrClosed = ClosedError{}
+
+// ClosedError is the error returned when a request arrives out of the active windows
+type ClosedError struct{}
+
+// Error implements the error interface
+func (ClosedError) Error() string { return "schedule: out of the active windows" }
+
+// StatusCode returns the 503 Service Unavailable status code
+func (ClosedError) StatusCode() int { return http.StatusServiceUnavailable }
+
+// Window is a daily time range
+type Window struct {
+	Days  []string `json:"days"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+}
+
+// Cron opens the schedule for a duration every time the expression fires
+type Cron struct {
+	Expression string `json:"expression"`
+	Duration   string `json:"duration"`
+}
+
+// Config is the schedule config
+type Config struct {
+	Timezone string   `json:"timezone"`
+	Windows  []Window `json:"windows"`
+	Cron     []Cron   `json:"cron"`
+}
+
+// ConfigGetter parses the schedule config from the extra config of an endpoint or a backend
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("schedule: parsing the config: %w", err)
+	}
+	return cfg, true, nil
+}
+
+// Schedule decides if the requests are accepted by their arrival time. The decisions are taken
+// with a precision of one minute and cached for it
+type Schedule struct {
+	location *time.Location
+	windows  []window
+	crons    []cronWindow
+	now      func() time.Time
+
+	mu     sync.Mutex
+	minute int64
+	active bool
+}
+
+type window struct {
+	days       [7]bool
+	start, end int
+}
+
+type cronWindow struct {
+	expr     *CronExpression
+	duration time.Duration
+}
+
+// New returns the Schedule of the config
+func New(cfg Config) (*Schedule, error) {
+	if len(cfg.Windows) == 0 && len(cfg.Cron) == 0 {
+		return nil, errors.New("schedule: no windows declared")
+	}
+	s := &Schedule{location: time.UTC, now: time.Now, minute: -1}
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("schedule: invalid timezone: %w", err)
+		}
+		s.location = loc
+	}
+
+	for _, w := range cfg.Windows {
+		parsed, err := parseWindow(w)
+		if err != nil {
+			return nil, err
+		}
+		s.windows = append(s.windows, parsed)
+	}
+
+	for _, c := range cfg.Cron {
+		expr, err := ParseCron(c.Expression)
+		if err != nil {
+			return nil, err
+		}
+		d, err := time.ParseDuration(c.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("schedule: invalid duration for %s: %w", c.Expression, err)
+		}
+		if d < time.Minute || d > MaxCronDuration {
+			return nil, fmt.Errorf("schedule: the duration of %s must be between 1m and %s", c.Expression, MaxCronDuration)
+		}
+		s.crons = append(s.crons, cronWindow{expr: expr, duration: d})
+	}
+	return s, nil
+}
+
+// Check returns ErrClosed if the schedule is not active
+func (s *Schedule) Check() error {
+	if !s.ActiveNow() {
+		return ErrClosed
+	}
+	return nil
+}
+
+// ActiveNow checks if the schedule is active at the current time
+func (s *Schedule) ActiveNow() bool {
+	t := s.now()
+	minute := t.Unix() / 60
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if minute != s.minute {
+		s.minute, s.active = minute, s.Active(t)
+	}
+	return s.active
+}
+
+// Active checks if the schedule is active at the time t
+func (s *Schedule) Active(t time.Time) bool {
+	t = t.In(s.location).Truncate(time.Minute)
+	minuteOfDay := t.Hour()*60 + t.Minute()
+	weekday := int(t.Weekday())
+
+	for _, w := range s.windows {
+		if w.start <= w.end {
+			if w.days[weekday] && minuteOfDay >= w.start && minuteOfDay < w.end {
+				return true
+			}
+			continue
+		}
+		// the window crosses the midnight
+		if w.days[weekday] && minuteOfDay >= w.start {
+			return true
+		}
+		if w.days[(weekday+6)%7] && minuteOfDay < w.end {
+			return true
+		}
+	}
+
+	for _, c := range s.crons {
+		for f := t; t.Sub(f) < c.duration; f = f.Add(-time.Minute) {
+			if c.expr.Matches(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var weekdays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+func parseWindow(w Window) (window, error) {
+	res := window{}
+	var err error
+	if res.start, err = parseClock(w.Start); err != nil {
+		return res, err
+	}
+	if res.end, err = parseClock(w.End); err != nil {
+		return res, err
+	}
+	if res.start == res.end {
+		return res, fmt.Errorf("schedule: the window %s-%s is empty", w.Start, w.End)
+	}
+	if len(w.Days) == 0 {
+		res.days = [7]bool{true, true, true, true, true, true, true}
+		return res, nil
+	}
+	for _, d := range w.Days {
+		i, ok := weekdays[strings.ToLower(d)[:min(3, len(d))]]
+		if !ok {
+			return res, fmt.Errorf("schedule: invalid day %s", d)
+		}
+		res.days[i] = true
+	}
+	return res, nil
+}
+
+func parseClock(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("schedule: invalid time %s, expecting HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
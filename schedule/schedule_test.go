@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestSchedule_Active_windows(t *testing.T) {
+	s, err := New(Config{
+		Timezone: "UTC",
+		Windows: []Window{
+			{Start: "02:00", End: "04:00"},
+			{Days: []string{"saturday", "sun"}, Start: "22:00", End: "01:00"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for when, expected := range map[string]bool{
+		"2023-01-04T02:00:00Z": true,  // wednesday
+		"2023-01-04T03:59:59Z": true,  // wednesday
+		"2023-01-04T04:00:00Z": false, // wednesday
+		"2023-01-04T23:00:00Z": false, // wednesday
+		"2023-01-07T23:00:00Z": true,  // saturday
+		"2023-01-08T00:30:00Z": true,  // sunday, window opened on saturday
+		"2023-01-09T00:30:00Z": true,  // monday, window opened on sunday
+		"2023-01-10T00:30:00Z": false, // tuesday
+	} {
+		ts, _ := time.Parse(time.RFC3339, when)
+		if s.Active(ts) != expected {
+			t.Errorf("%s: expecting %v", when, expected)
+		}
+	}
+}
+
+func TestSchedule_Active_timezone(t *testing.T) {
+	s, err := New(Config{Timezone: "America/New_York", Windows: []Window{{Start: "09:00", End: "17:00"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for when, expected := range map[string]bool{
+		"2023-01-04T13:00:00Z": false, // 08:00 in New York
+		"2023-01-04T15:00:00Z": true,  // 10:00 in New York
+		"2023-01-04T22:30:00Z": false, // 17:30 in New York
+	} {
+		ts, _ := time.Parse(time.RFC3339, when)
+		if s.Active(ts) != expected {
+			t.Errorf("%s: expecting %v", when, expected)
+		}
+	}
+}
+
+func TestSchedule_Active_cron(t *testing.T) {
+	s, err := New(Config{Cron: []Cron{{Expression: "30 12 * * 1-5", Duration: "15m"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for when, expected := range map[string]bool{
+		"2023-01-04T12:29:00Z": false,
+		"2023-01-04T12:30:00Z": true,
+		"2023-01-04T12:44:59Z": true,
+		"2023-01-04T12:45:00Z": false,
+		"2023-01-07T12:35:00Z": false, // saturday
+	} {
+		ts, _ := time.Parse(time.RFC3339, when)
+		if s.Active(ts) != expected {
+			t.Errorf("%s: expecting %v", when, expected)
+		}
+	}
+}
+
+func TestSchedule_Check(t *testing.T) {
+	s, _ := New(Config{Windows: []Window{{Start: "02:00", End: "04:00"}}})
+	now := time.Date(2023, 1, 4, 3, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	if err := s.Check(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if err := s.Check(); err != ErrClosed {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if ErrClosed.StatusCode() != 503 {
+		t.Errorf("unexpected status code: %d", ErrClosed.StatusCode())
+	}
+}
+
+func TestNew_invalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{},
+		{Timezone: "Mars/Olympus", Windows: []Window{{Start: "02:00", End: "04:00"}}},
+		{Windows: []Window{{Start: "2am", End: "04:00"}}},
+		{Windows: []Window{{Start: "02:00", End: "02:00"}}},
+		{Windows: []Window{{Days: []string{"someday"}, Start: "02:00", End: "04:00"}}},
+		{Cron: []Cron{{Expression: "* * *", Duration: "1m"}}},
+		{Cron: []Cron{{Expression: "* * * * *", Duration: "48h"}}},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("expecting an error for %+v", cfg)
+		}
+	}
+}
+
+func TestConfigGetter(t *testing.T) {
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"timezone": "UTC",
+		"windows":  []interface{}{map[string]interface{}{"start": "02:00", "end": "04:00"}},
+	}})
+	if !ok || err != nil || len(cfg.Windows) != 1 || cfg.Windows[0].End != "04:00" {
+		t.Errorf("unexpected result. ok: %v, err: %v, cfg: %+v", ok, err, cfg)
+	}
+	if _, ok, _ := ConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the config does not declare a schedule")
+	}
+}
@@ -0,0 +1,25 @@
+module github.com/luraproject/lura/v2/script/lua
+
+go 1.22
+
+require github.com/luraproject/lura/v2 v2.0.0-00010101000000-000000000000
+
+require (
+	github.com/bytedance/sonic v1.9.1 // indirect
+	github.com/chenzhuoyu/base64x v0.0.0-20221115062448-fe3a3abad311 // indirect
+	github.com/goccy/go-json v0.10.2 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/klauspost/cpuid/v2 v2.2.4 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
+	golang.org/x/arch v0.3.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+)
+
+require (
+	github.com/yuin/gopher-lua v1.1.1
+	golang.org/x/text v0.14.0 // indirect
+)
+
+replace github.com/luraproject/lura/v2 => ../..
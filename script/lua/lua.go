@@ -0,0 +1,271 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package lua runs the scripts of the proxy pipes with gopher-lua, an interpreter of Lua 5.1
+written in go.
+
+The package registers its engine in the script package as the default "lua" one when imported:
+
+	import _ "github.com/luraproject/lura/v2/script/lua"
+
+	"github.com/luraproject/lura/script": {
+		"pre": {"source": "request.headers['X-Stage'] = {stage}"},
+		"post": {"files": ["./scripts/rename.lua"]}
+	}
+
+The scripts read and modify the globals exposing the pipe:
+
+	stage     "pre" or "post"
+	request   {method, path, params, headers, query}
+	response  {data, is_complete, status_code, headers}, only in the post stage
+
+The headers and the query strings are tables of lists of strings, and the data of the response
+is the decoded json tree, with the objects and the arrays as tables. The changes are copied back
+to the pipe once the script ends. The scripts calling error abort the pipe with their message.
+
+The compiled scripts run in pooled interpreters, so the globals declared by a script may be seen
+by its next executions. The package is a module of its own, so the services without scripts do
+not depend on gopher-lua.
+*/
+package lua
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	glua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+
+	"github.com/luraproject/lura/v2/script"
+)
+
+func init() {
+	script.RegisterEngine(script.DefaultEngine, Engine{})
+}
+
+// Engine compiles the sources as Lua chunks. It implements the script.Engine interface
+type Engine struct{}
+
+// Compile implements the script.Engine interface
+func (Engine) Compile(name, source string) (script.Program, error) {
+	chunk, err := parse.Parse(strings.NewReader(source), name)
+	if err != nil {
+		return nil, err
+	}
+	proto, err := glua.Compile(chunk, name)
+	if err != nil {
+		return nil, err
+	}
+	return &program{
+		proto: proto,
+		states: sync.Pool{
+			New: func() interface{} { return glua.NewState() },
+		},
+	}, nil
+}
+
+type program struct {
+	proto  *glua.FunctionProto
+	states sync.Pool
+}
+
+// Run implements the script.Program interface
+func (p *program) Run(ctx context.Context, env *script.Env) error {
+	l := p.states.Get().(*glua.LState)
+	defer p.states.Put(l)
+
+	l.SetContext(ctx)
+	defer l.RemoveContext()
+
+	l.SetGlobal("stage", glua.LString(env.Stage))
+	l.SetGlobal("request", requestTable(l, env.Request))
+	if env.Response != nil {
+		l.SetGlobal("response", responseTable(l, env.Response))
+	} else {
+		l.SetGlobal("response", glua.LNil)
+	}
+
+	l.Push(l.NewFunctionFromProto(p.proto))
+	if err := l.PCall(0, glua.MultRet, nil); err != nil {
+		l.SetTop(0)
+		return err
+	}
+	l.SetTop(0)
+
+	if t, ok := l.GetGlobal("request").(*glua.LTable); ok {
+		updateRequest(env.Request, t)
+	}
+	if t, ok := l.GetGlobal("response").(*glua.LTable); ok && env.Response != nil {
+		updateResponse(env.Response, t)
+	}
+	return nil
+}
+
+func requestTable(l *glua.LState, r *script.Request) *glua.LTable {
+	t := l.NewTable()
+	t.RawSetString("method", glua.LString(r.Method))
+	t.RawSetString("path", glua.LString(r.Path))
+	params := l.NewTable()
+	for k, v := range r.Params {
+		params.RawSetString(k, glua.LString(v))
+	}
+	t.RawSetString("params", params)
+	t.RawSetString("headers", multimapTable(l, r.Headers))
+	t.RawSetString("query", multimapTable(l, r.Query))
+	return t
+}
+
+func responseTable(l *glua.LState, r *script.Response) *glua.LTable {
+	t := l.NewTable()
+	t.RawSetString("data", toLua(l, r.Data))
+	t.RawSetString("is_complete", glua.LBool(r.IsComplete))
+	t.RawSetString("status_code", glua.LNumber(r.StatusCode))
+	t.RawSetString("headers", multimapTable(l, r.Headers))
+	return t
+}
+
+func updateRequest(r *script.Request, t *glua.LTable) {
+	r.Method = stringField(t, "method")
+	r.Path = stringField(t, "path")
+	r.Params = map[string]string{}
+	if params, ok := t.RawGetString("params").(*glua.LTable); ok {
+		params.ForEach(func(k, v glua.LValue) {
+			r.Params[k.String()] = v.String()
+		})
+	}
+	r.Headers = multimap(t.RawGetString("headers"))
+	r.Query = multimap(t.RawGetString("query"))
+}
+
+func updateResponse(r *script.Response, t *glua.LTable) {
+	r.Data, _ = fromLua(t.RawGetString("data")).(map[string]interface{})
+	if r.Data == nil {
+		r.Data = map[string]interface{}{}
+	}
+	r.IsComplete = glua.LVAsBool(t.RawGetString("is_complete"))
+	if n, ok := t.RawGetString("status_code").(glua.LNumber); ok {
+		r.StatusCode = int(n)
+	}
+	r.Headers = multimap(t.RawGetString("headers"))
+}
+
+func stringField(t *glua.LTable, key string) string {
+	if v := t.RawGetString(key); v != glua.LNil {
+		return v.String()
+	}
+	return ""
+}
+
+func multimapTable(l *glua.LState, m map[string][]string) *glua.LTable {
+	t := l.NewTable()
+	for k, vs := range m {
+		values := l.CreateTable(len(vs), 0)
+		for _, v := range vs {
+			values.Append(glua.LString(v))
+		}
+		t.RawSetString(k, values)
+	}
+	return t
+}
+
+// multimap accepts both lists of strings and single strings as the values of the table
+func multimap(v glua.LValue) map[string][]string {
+	res := map[string][]string{}
+	t, ok := v.(*glua.LTable)
+	if !ok {
+		return res
+	}
+	t.ForEach(func(k, v glua.LValue) {
+		values, ok := v.(*glua.LTable)
+		if !ok {
+			res[k.String()] = []string{v.String()}
+			return
+		}
+		list := make([]string, 0, values.Len())
+		values.ForEach(func(_, item glua.LValue) {
+			list = append(list, item.String())
+		})
+		res[k.String()] = list
+	})
+	return res
+}
+
+func toLua(l *glua.LState, v interface{}) glua.LValue {
+	switch t := v.(type) {
+	case nil:
+		return glua.LNil
+	case string:
+		return glua.LString(t)
+	case bool:
+		return glua.LBool(t)
+	case float64:
+		return glua.LNumber(t)
+	case float32:
+		return glua.LNumber(t)
+	case int:
+		return glua.LNumber(t)
+	case int64:
+		return glua.LNumber(t)
+	case json.Number:
+		if f, err := t.Float64(); err == nil {
+			return glua.LNumber(f)
+		}
+		return glua.LString(t)
+	case map[string]interface{}:
+		res := l.CreateTable(0, len(t))
+		for k, item := range t {
+			res.RawSetString(k, toLua(l, item))
+		}
+		return res
+	case []interface{}:
+		res := l.CreateTable(len(t), 0)
+		for _, item := range t {
+			res.Append(toLua(l, item))
+		}
+		return res
+	}
+	return glua.LNil
+}
+
+// fromLua converts the tables with the keys 1..n to slices and the rest of them to maps, so
+// the empty tables become empty objects
+func fromLua(v glua.LValue) interface{} {
+	switch t := v.(type) {
+	case glua.LString:
+		return string(t)
+	case glua.LBool:
+		return bool(t)
+	case glua.LNumber:
+		return float64(t)
+	case *glua.LTable:
+		if n := t.MaxN(); n > 0 && n == tableLen(t) {
+			res := make([]interface{}, 0, n)
+			for i := 1; i <= n; i++ {
+				res = append(res, fromLua(t.RawGetInt(i)))
+			}
+			return res
+		}
+		res := map[string]interface{}{}
+		t.ForEach(func(k, item glua.LValue) {
+			res[tableKey(k)] = fromLua(item)
+		})
+		return res
+	}
+	return nil
+}
+
+func tableLen(t *glua.LTable) int {
+	n := 0
+	t.ForEach(func(glua.LValue, glua.LValue) { n++ })
+	return n
+}
+
+func tableKey(k glua.LValue) string {
+	if n, ok := k.(glua.LNumber); ok {
+		return strconv.FormatFloat(float64(n), 'f', -1, 64)
+	}
+	return k.String()
+}
@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package lua
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/script"
+)
+
+func TestEngine_registered(t *testing.T) {
+	if _, ok := script.GetEngine(script.DefaultEngine); !ok {
+		t.Error("the lua engine should be registered as the default one")
+	}
+}
+
+func TestProgram_pre(t *testing.T) {
+	p, err := Engine{}.Compile("inline-pre", `
+if stage ~= "pre" then error("unexpected stage " .. stage) end
+request.method = "POST"
+request.path = request.path .. "/" .. request.params.Id
+request.params.Id = nil
+request.params.Extra = "supu"
+request.headers["X-Tenant"] = {request.headers["X-User"][1], "tupu"}
+request.query.page = "2"
+`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	req := &script.Request{
+		Method:  "GET",
+		Path:    "/users",
+		Params:  map[string]string{"Id": "42"},
+		Headers: map[string][]string{"X-User": {"gopher"}},
+		Query:   map[string][]string{},
+	}
+	if err := p.Run(context.Background(), &script.Env{Stage: script.PreStage, Request: req}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := &script.Request{
+		Method:  "POST",
+		Path:    "/users/42",
+		Params:  map[string]string{"Extra": "supu"},
+		Headers: map[string][]string{"X-User": {"gopher"}, "X-Tenant": {"gopher", "tupu"}},
+		Query:   map[string][]string{"page": {"2"}},
+	}
+	if !reflect.DeepEqual(req, expected) {
+		t.Errorf("unexpected request: %+v", req)
+	}
+}
+
+func TestProgram_post(t *testing.T) {
+	p, err := Engine{}.Compile("inline-post", `
+local data = response.data
+data.name = data.user.first .. " " .. data.user.last
+data.user = nil
+table.insert(data.tags, "lua")
+data.total = data.total + 1
+data.empty = {}
+response.status_code = 201
+response.is_complete = false
+response.headers["X-Script"] = {"done"}
+`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp := &script.Response{
+		Data: map[string]interface{}{
+			"user":  map[string]interface{}{"first": "lura", "last": "gopher"},
+			"tags":  []interface{}{"go"},
+			"total": json.Number("41"),
+		},
+		IsComplete: true,
+		StatusCode: 200,
+		Headers:    map[string][]string{},
+	}
+	env := &script.Env{Stage: script.PostStage, Request: &script.Request{}, Response: resp}
+	if err := p.Run(context.Background(), env); err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := &script.Response{
+		Data: map[string]interface{}{
+			"name":  "lura gopher",
+			"tags":  []interface{}{"go", "lua"},
+			"total": 42.0,
+			"empty": map[string]interface{}{},
+		},
+		IsComplete: false,
+		StatusCode: 201,
+		Headers:    map[string][]string{"X-Script": {"done"}},
+	}
+	if !reflect.DeepEqual(resp, expected) {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestProgram_error(t *testing.T) {
+	p, err := Engine{}.Compile("inline-pre", `error("forbidden")`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = p.Run(context.Background(), &script.Env{Stage: script.PreStage, Request: &script.Request{}})
+	if err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestProgram_timeout(t *testing.T) {
+	p, err := Engine{}.Compile("inline-pre", `while true do end`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Run(ctx, &script.Env{Stage: script.PreStage, Request: &script.Request{}}); err == nil {
+		t.Error("the script should be stopped by the context")
+	}
+}
+
+func TestEngine_compileError(t *testing.T) {
+	if _, err := (Engine{}).Compile("inline-pre", `request.path = `); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestCompile_files(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "rename.lua")
+	if err := os.WriteFile(f, []byte(`request.path = "/from-file"`), 0o600); err != nil {
+		t.Error(err)
+		return
+	}
+	p, err := script.Compile(Engine{}, script.Source{
+		Files:  []string{f},
+		Inline: `request.path = request.path .. "/inline"`,
+	}, script.PreStage)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	req := &script.Request{Path: "/original"}
+	if err := p.Run(context.Background(), &script.Env{Stage: script.PreStage, Request: req}); err != nil {
+		t.Error(err)
+		return
+	}
+	if req.Path != "/from-file/inline" {
+		t.Errorf("unexpected path: %s", req.Path)
+	}
+}
@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package script defines the contract for running user-provided scripts inside the proxy pipes.
+
+The scripts are executed by engines registered by name. The package does not embed any interpreter:
+the binaries embedding lura register the engines they want to support (the default one is "lua"),
+so the pipes can run small transformations without compiling plugins. The pipes declaring an
+engine not registered are rejected, so the scripts are never skipped.
+
+The github.com/luraproject/lura/v2/script/lua module registers the "lua" engine when imported,
+running the scripts with gopher-lua.
+*/
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/register"
+)
+
+// Namespace is the key to use to store and access the script config
+const Namespace = "github.com/luraproject/lura/script"
+
+// DefaultEngine is the name of the engine used when the config does not declare one
+const DefaultEngine = "lua"
+
+// Stage identifies the moment of the pipe where the script is executed
+type Stage string
+
+const (
+	// PreStage scripts are executed before passing the request to the next step of the pipe
+	PreStage Stage = "pre"
+	// PostStage scripts are executed with the response returned by the next step of the pipe
+	PostStage Stage = "post"
+)
+
+// ErrNoSource is returned when a stage does not declare inline sources nor files
+var ErrNoSource = errors.New("the script has no source")
+
+// Request is the view of the proxy request exposed to the scripts. The changes made by the
+// scripts are copied back to the proxy request
+type Request struct {
+	Method  string
+	Path    string
+	Params  map[string]string
+	Headers map[string][]string
+	Query   url.Values
+}
+
+// Response is the view of the proxy response exposed to the scripts. The changes made by the
+// scripts are copied back to the proxy response
+type Response struct {
+	Data       map[string]interface{}
+	IsComplete bool
+	StatusCode int
+	Headers    map[string][]string
+}
+
+// Env is the environment of a script execution. Response is nil in the pre stage
+type Env struct {
+	Stage    Stage
+	Request  *Request
+	Response *Response
+}
+
+// Program is a compiled script
+type Program interface {
+	Run(ctx context.Context, env *Env) error
+}
+
+// Engine compiles the sources of a script. The name is the file name of the source or
+// a synthetic one for the inline snippets
+type Engine interface {
+	Compile(name, source string) (Program, error)
+}
+
+// EngineFunc type is an adapter to allow the use of ordinary functions as engines
+type EngineFunc func(name, source string) (Program, error)
+
+// Compile implements the Engine interface
+func (f EngineFunc) Compile(name, source string) (Program, error) { return f(name, source) }
+
+// ProgramFunc type is an adapter to allow the use of ordinary functions as programs
+type ProgramFunc func(ctx context.Context, env *Env) error
+
+// Run implements the Program interface
+func (f ProgramFunc) Run(ctx context.Context, env *Env) error { return f(ctx, env) }
+
+var engines = register.NewUntyped()
+
+// RegisterEngine adds an engine to the package register
+func RegisterEngine(name string, e Engine) {
+	engines.Register(name, e)
+}
+
+// GetEngine returns the engine registered with the name
+func GetEngine(name string) (Engine, bool) {
+	v, ok := engines.Get(name)
+	if !ok {
+		return nil, false
+	}
+	e, ok := v.(Engine)
+	return e, ok
+}
+
+// Source contains the inline snippets and the files to execute in a stage. The files are
+// executed before the inline snippet
+type Source struct {
+	Inline string
+	Files  []string
+}
+
+// Config is the script configuration of a pipe
+type Config struct {
+	Engine string
+	Pre    *Source
+	Post   *Source
+}
+
+// ConfigGetter parses the script config from an extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool) {
+	cfg := Config{Engine: DefaultEngine}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false
+	}
+	if engine, ok := tmp["engine"].(string); ok && engine != "" {
+		cfg.Engine = engine
+	}
+	cfg.Pre = parseSource(tmp[string(PreStage)])
+	cfg.Post = parseSource(tmp[string(PostStage)])
+	return cfg, cfg.Pre != nil || cfg.Post != nil
+}
+
+// Validate returns an error if the extra config declares scripts for an engine not registered
+func Validate(e config.ExtraConfig) error {
+	cfg, ok := ConfigGetter(e)
+	if !ok {
+		return nil
+	}
+	if _, ok := GetEngine(cfg.Engine); !ok {
+		return fmt.Errorf("script: unknown engine %s", cfg.Engine)
+	}
+	return nil
+}
+
+func parseSource(v interface{}) *Source {
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	s := &Source{}
+	s.Inline, _ = tmp["source"].(string)
+	if files, ok := tmp["files"].([]interface{}); ok {
+		for _, f := range files {
+			if name, ok := f.(string); ok {
+				s.Files = append(s.Files, name)
+			}
+		}
+	}
+	return s
+}
+
+// Compile returns a Program executing the files and the inline snippet of the source, in order
+func Compile(e Engine, s Source, stage Stage) (Program, error) {
+	var programs []Program
+	for _, f := range s.Files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		p, err := e.Compile(f, string(b))
+		if err != nil {
+			return nil, fmt.Errorf("compiling %s: %w", f, err)
+		}
+		programs = append(programs, p)
+	}
+	if strings.TrimSpace(s.Inline) != "" {
+		p, err := e.Compile("inline-"+string(stage), s.Inline)
+		if err != nil {
+			return nil, fmt.Errorf("compiling the inline %s script: %w", stage, err)
+		}
+		programs = append(programs, p)
+	}
+
+	switch len(programs) {
+	case 0:
+		return nil, ErrNoSource
+	case 1:
+		return programs[0], nil
+	}
+	return ProgramFunc(func(ctx context.Context, env *Env) error {
+		for _, p := range programs {
+			if err := p.Run(ctx, env); err != nil {
+				return err
+			}
+		}
+		return nil
+	}), nil
+}
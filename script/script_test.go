@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package script
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestConfigGetter(t *testing.T) {
+	cfg, ok := ConfigGetter(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"pre":  map[string]interface{}{"source": "x", "files": []interface{}{"a.lua", "b.lua"}},
+			"post": map[string]interface{}{"source": "y"},
+		},
+	})
+	if !ok {
+		t.Error("the config should be parsed")
+		return
+	}
+	if cfg.Engine != DefaultEngine || cfg.Pre.Inline != "x" || len(cfg.Pre.Files) != 2 || cfg.Post.Inline != "y" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	if _, ok := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{}}); ok {
+		t.Error("a config without stages should be ignored")
+	}
+}
+
+func TestCompile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.script")
+	if err := os.WriteFile(file, []byte("from-file"), 0o600); err != nil {
+		t.Error(err)
+		return
+	}
+
+	var compiled []string
+	engine := EngineFunc(func(name, source string) (Program, error) {
+		compiled = append(compiled, name)
+		if source == "invalid" {
+			return nil, errors.New("syntax error")
+		}
+		return ProgramFunc(func(_ context.Context, env *Env) error {
+			env.Request.Params[source] = string(env.Stage)
+			return nil
+		}), nil
+	})
+
+	p, err := Compile(engine, Source{Inline: "inline", Files: []string{file}}, PreStage)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(compiled) != 2 || compiled[0] != file || compiled[1] != "inline-pre" {
+		t.Errorf("unexpected compiled sources: %v", compiled)
+	}
+
+	env := &Env{Stage: PreStage, Request: &Request{Params: map[string]string{}}}
+	if err := p.Run(context.Background(), env); err != nil {
+		t.Error(err)
+		return
+	}
+	if env.Request.Params["from-file"] != "pre" || env.Request.Params["inline"] != "pre" {
+		t.Errorf("unexpected params: %v", env.Request.Params)
+	}
+
+	if _, err := Compile(engine, Source{}, PreStage); err != ErrNoSource {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := Compile(engine, Source{Inline: "invalid"}, PreStage); err == nil {
+		t.Error("expecting a compilation error")
+	}
+	if _, err := Compile(engine, Source{Files: []string{filepath.Join(dir, "unknown")}}, PreStage); err == nil {
+		t.Error("expecting an error for the unknown file")
+	}
+}
+
+func TestRegisterEngine(t *testing.T) {
+	RegisterEngine("test-engine", EngineFunc(func(_, _ string) (Program, error) { return nil, nil }))
+	if _, ok := GetEngine("test-engine"); !ok {
+		t.Error("the engine should be registered")
+	}
+	if _, ok := GetEngine("unknown"); ok {
+		t.Error("unexpected engine")
+	}
+}
@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package filesd exports the gateway and the hosts of its backends in the Prometheus file_sd format,
+so the monitoring system tracks the same targets the gateway is balancing over
+*/
+package filesd
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+// Namespace is the key to use to store and access the file_sd config
+const Namespace = "github.com/luraproject/lura/sd/filesd"
+
+// DefaultInterval is the period between writes used when the config does not declare one
+const DefaultInterval = 30 * time.Second
+
+const logPrefix = "[SERVICE: FileSD]"
+
+// TargetGroup is a group of targets sharing the same labels, as defined by the Prometheus file_sd format
+type TargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// Config defines where and how often the targets should be exported
+type Config struct {
+	// Path of the file to write. If empty, the targets are only available through the Handler
+	Path string
+	// Interval between writes
+	Interval time.Duration
+	// Self is the address of the gateway to scrape. It defaults to the port of the service
+	Self string
+	// Labels to add to all the target groups
+	Labels map[string]string
+}
+
+// ConfigGetter parses the file_sd configuration from the service config
+func ConfigGetter(cfg config.ServiceConfig) (Config, bool) {
+	res := Config{Interval: DefaultInterval}
+	tmp, ok := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return res, false
+	}
+	res.Path, _ = tmp["path"].(string)
+	if v, ok := tmp["interval"].(string); ok {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			res.Interval = d
+		}
+	}
+	res.Self, _ = tmp["self"].(string)
+	if res.Self == "" {
+		res.Self = net.JoinHostPort("localhost", strconv.Itoa(cfg.Port))
+	}
+	if labels, ok := tmp["labels"].(map[string]interface{}); ok {
+		res.Labels = map[string]string{}
+		for k, v := range labels {
+			if s, ok := v.(string); ok {
+				res.Labels[k] = s
+			}
+		}
+	}
+	return res, true
+}
+
+// Targets returns the target groups for the gateway and for the tracked backends. Every backend
+// gets its own group, labeled with its endpoint, method, backend pattern and sd driver
+func Targets(cfg Config, backends []sd.TrackedBackend) []TargetGroup {
+	groups := make([]TargetGroup, 0, len(backends)+1)
+	if cfg.Self != "" {
+		groups = append(groups, TargetGroup{
+			Targets: []string{cfg.Self},
+			Labels:  withLabels(cfg.Labels, map[string]string{"lura_role": "gateway"}),
+		})
+	}
+	for _, b := range backends {
+		targets := make([]string, 0, len(b.Hosts))
+		for _, h := range b.Hosts {
+			if t, ok := targetFromHost(h); ok {
+				targets = append(targets, t)
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		groups = append(groups, TargetGroup{
+			Targets: targets,
+			Labels: withLabels(cfg.Labels, map[string]string{
+				"lura_role":     "backend",
+				"lura_endpoint": b.Endpoint,
+				"lura_method":   b.Method,
+				"lura_backend":  b.Backend,
+				"lura_sd":       b.SD,
+			}),
+		})
+	}
+	return groups
+}
+
+// Write stores the target groups in the file. The content is replaced atomically, so
+// Prometheus never reads a partial file
+func Write(path string, groups []TargetGroup) error {
+	b, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Handler returns a http handler serving the current target groups, so they can be consumed
+// with the Prometheus http_sd or exposed by an admin API
+func Handler(cfg Config, tracker *sd.Tracker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Targets(cfg, tracker.Backends()))
+	})
+}
+
+// Run writes the target groups into the configured file periodically, until the context is cancelled
+func Run(ctx context.Context, cfg Config, tracker *sd.Tracker, logger logging.Logger) {
+	if cfg.Path == "" {
+		return
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := Write(cfg.Path, Targets(cfg, tracker.Backends())); err != nil {
+			logger.Error(logPrefix, "Unable to write the targets file:", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Register starts the periodic export of the package tracker if the service config enables it
+func Register(ctx context.Context, cfg config.ServiceConfig, logger logging.Logger) bool {
+	c, ok := ConfigGetter(cfg)
+	if !ok || c.Path == "" {
+		return false
+	}
+	logger.Debug(logPrefix, "Exporting the targets to", c.Path, "every", c.Interval.String())
+	go Run(ctx, c, sd.GetTracker(), logger)
+	return true
+}
+
+func withLabels(common, specific map[string]string) map[string]string {
+	res := make(map[string]string, len(common)+len(specific))
+	for k, v := range common {
+		res[k] = v
+	}
+	for k, v := range specific {
+		res[k] = v
+	}
+	return res
+}
+
+// targetFromHost translates the backend hosts (scheme://host:port) into scrape targets (host:port)
+func targetFromHost(h string) (string, bool) {
+	u, err := url.Parse(h)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	if u.Port() != "" {
+		return u.Host, true
+	}
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port), true
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filesd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+func TestConfigGetter(t *testing.T) {
+	cfg, ok := ConfigGetter(config.ServiceConfig{
+		Port: 8080,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"path":     "/tmp/targets.json",
+				"interval": "10s",
+				"labels":   map[string]interface{}{"env": "prod"},
+			},
+		},
+	})
+	if !ok {
+		t.Error("the config should be parsed")
+		return
+	}
+	if cfg.Path != "/tmp/targets.json" || cfg.Interval != 10*time.Second || cfg.Self != "localhost:8080" || cfg.Labels["env"] != "prod" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	if _, ok := ConfigGetter(config.ServiceConfig{}); ok {
+		t.Error("the export should be disabled")
+	}
+}
+
+func TestTargets(t *testing.T) {
+	groups := Targets(
+		Config{Self: "gateway:8080", Labels: map[string]string{"env": "prod"}},
+		[]sd.TrackedBackend{
+			{Endpoint: "/a", Method: "GET", Backend: "/x", SD: "dns", Hosts: []string{"http://10.0.0.1:8000", "https://api.example.com"}},
+			{Endpoint: "/b", Method: "GET", Backend: "/y", SD: "static", Hosts: []string{"unix:///tmp/x.sock"}},
+		},
+	)
+	if len(groups) != 2 {
+		t.Errorf("unexpected groups: %+v", groups)
+		return
+	}
+	if groups[0].Targets[0] != "gateway:8080" || groups[0].Labels["lura_role"] != "gateway" || groups[0].Labels["env"] != "prod" {
+		t.Errorf("unexpected gateway group: %+v", groups[0])
+	}
+	b := groups[1]
+	if len(b.Targets) != 2 || b.Targets[0] != "10.0.0.1:8000" || b.Targets[1] != "api.example.com:443" {
+		t.Errorf("unexpected targets: %v", b.Targets)
+	}
+	if b.Labels["lura_endpoint"] != "/a" || b.Labels["lura_sd"] != "dns" || b.Labels["env"] != "prod" {
+		t.Errorf("unexpected labels: %v", b.Labels)
+	}
+}
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.json")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Run(ctx, Config{Path: path, Self: "gateway:8080", Interval: time.Millisecond}, sd.GetTracker(), logging.NoOp)
+		close(done)
+	}()
+
+	var groups []TargetGroup
+	for i := 0; i < 100; i++ {
+		if b, err := os.ReadFile(path); err == nil && json.Unmarshal(b, &groups) == nil {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if len(groups) == 0 || groups[0].Targets[0] != "gateway:8080" {
+		t.Errorf("unexpected groups: %+v", groups)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	Handler(Config{Self: "gateway:8080"}, sd.GetTracker()).ServeHTTP(w, httptest.NewRequest("GET", "/", http.NoBody))
+
+	var groups []TargetGroup
+	if err := json.Unmarshal(w.Body.Bytes(), &groups); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(groups) != 1 || groups[0].Targets[0] != "gateway:8080" {
+		t.Errorf("unexpected groups: %+v", groups)
+	}
+}
@@ -0,0 +1,453 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package healthcheck probes the hosts of the backends in the background and removes the unhealthy
+ones from the set returned by their subscribers, so the balancers stop selecting them before the
+requests start failing.
+
+The probes are declared per backend:
+
+	"extra_config": {
+		"github.com/luraproject/lura/sd/healthcheck": {
+			"path": "/health",
+			"interval": "10s",
+			"timeout": "2s",
+			"healthy_threshold": 2,
+			"unhealthy_threshold": 3
+		}
+	}
+
+A host is marked unhealthy after the configured number of consecutive failed probes and healthy
+again after the configured number of consecutive successful ones. The probes failing are the ones
+ending with an error or with a status code out of the 2xx and 3xx ranges. The hosts are healthy
+until probed, so the new hosts get traffic right away. When all the hosts of a backend are
+unhealthy, the balancer returns sd.ErrNoHosts.
+
+The service can expose the status of all the checked hosts as a JSON document:
+
+	"extra_config": {
+		"github.com/luraproject/lura/sd/healthcheck": {
+			"path": "/__health/backends"
+		}
+	}
+*/
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+// Namespace is the key to use to store and access the health check config
+const Namespace = "github.com/luraproject/lura/sd/healthcheck"
+
+const (
+	// DefaultPath is the path probed when the backend config does not declare one
+	DefaultPath = "/health"
+	// DefaultInterval is the period between probes when the backend config does not declare one
+	DefaultInterval = 10 * time.Second
+	// DefaultTimeout is the max duration of a probe when the backend config does not declare one
+	DefaultTimeout = 2 * time.Second
+	// DefaultHealthyThreshold is the number of consecutive successful probes required to mark a
+	// host as healthy when the backend config does not declare it
+	DefaultHealthyThreshold = 2
+	// DefaultUnhealthyThreshold is the number of consecutive failed probes required to mark a host
+	// as unhealthy when the backend config does not declare it
+	DefaultUnhealthyThreshold = 3
+	// DefaultStatusPath is the path of the status endpoint when the service config does not
+	// declare one
+	DefaultStatusPath = "/__health/backends"
+)
+
+// Config is the health check config of a backend
+type Config struct {
+	Path               string
+	Interval           time.Duration
+	Timeout            time.Duration
+	HealthyThreshold   int
+	UnhealthyThreshold int
+}
+
+type rawConfig struct {
+	Path               string `json:"path"`
+	Interval           string `json:"interval"`
+	Timeout            string `json:"timeout"`
+	HealthyThreshold   int    `json:"healthy_threshold"`
+	UnhealthyThreshold int    `json:"unhealthy_threshold"`
+}
+
+// ConfigGetter parses the health check config from the backend extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{
+		Path:               DefaultPath,
+		Interval:           DefaultInterval,
+		Timeout:            DefaultTimeout,
+		HealthyThreshold:   DefaultHealthyThreshold,
+		UnhealthyThreshold: DefaultUnhealthyThreshold,
+	}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	var raw rawConfig
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return cfg, true, fmt.Errorf("healthcheck: parsing the config: %w", err)
+	}
+	if raw.Path != "" {
+		cfg.Path = raw.Path
+	}
+	if raw.Interval != "" {
+		d, err := time.ParseDuration(raw.Interval)
+		if err != nil || d <= 0 {
+			return cfg, true, fmt.Errorf("healthcheck: invalid interval %s", raw.Interval)
+		}
+		cfg.Interval = d
+	}
+	if raw.Timeout != "" {
+		d, err := time.ParseDuration(raw.Timeout)
+		if err != nil || d <= 0 {
+			return cfg, true, fmt.Errorf("healthcheck: invalid timeout %s", raw.Timeout)
+		}
+		cfg.Timeout = d
+	}
+	if raw.HealthyThreshold < 0 || raw.UnhealthyThreshold < 0 {
+		return cfg, true, fmt.Errorf("healthcheck: the thresholds must be positive")
+	}
+	if raw.HealthyThreshold > 0 {
+		cfg.HealthyThreshold = raw.HealthyThreshold
+	}
+	if raw.UnhealthyThreshold > 0 {
+		cfg.UnhealthyThreshold = raw.UnhealthyThreshold
+	}
+	return cfg, true, nil
+}
+
+// Prober checks the health of a host. It returns an error if the host is not healthy
+type Prober func(ctx context.Context, host string) error
+
+// NewHTTPProber returns a Prober sending a GET request to the path of the hosts and expecting a
+// 2xx or 3xx status code. The redirections are not followed
+func NewHTTPProber(path string, c *http.Client) Prober {
+	if c == nil {
+		c = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	}
+	return func(ctx context.Context, host string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, host+path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// HostStatus is the health of a host, as seen by the last probes
+type HostStatus struct {
+	Host      string    `json:"host"`
+	Healthy   bool      `json:"healthy"`
+	Successes int       `json:"consecutive_successes"`
+	Failures  int       `json:"consecutive_failures"`
+	LastCheck time.Time `json:"last_check"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// BackendStatus is the health of the hosts of a backend
+type BackendStatus struct {
+	Endpoint string       `json:"endpoint"`
+	Method   string       `json:"method"`
+	Backend  string       `json:"backend"`
+	Hosts    []HostStatus `json:"hosts"`
+}
+
+// Checker probes the hosts of a subscriber periodically. It implements the sd.Subscriber
+// interface, returning only the hosts considered healthy
+type Checker struct {
+	cfg        Config
+	subscriber sd.Subscriber
+	probe      Prober
+	logger     logging.Logger
+	logPrefix  string
+
+	mu     sync.RWMutex
+	status map[string]*HostStatus
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewChecker returns a Checker probing the hosts of the subscriber with the prober. The checker
+// does not probe the hosts until started
+func NewChecker(cfg Config, s sd.Subscriber, probe Prober, logger logging.Logger) *Checker {
+	return &Checker{
+		cfg:        cfg,
+		subscriber: s,
+		probe:      probe,
+		logger:     logger,
+		logPrefix:  "[SERVICE: HealthCheck]",
+		status:     map[string]*HostStatus{},
+		done:       make(chan struct{}),
+	}
+}
+
+// Hosts implements the sd.Subscriber interface
+func (c *Checker) Hosts() ([]string, error) {
+	hosts, err := c.subscriber.Hosts()
+	if err != nil {
+		return hosts, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		if s, ok := c.status[h]; !ok || s.Healthy {
+			res = append(res, h)
+		}
+	}
+	return res, nil
+}
+
+// Start probes the hosts every interval until the checker is stopped
+func (c *Checker) Start() {
+	go func() {
+		t := time.NewTicker(c.cfg.Interval)
+		defer t.Stop()
+		for {
+			c.Check()
+			select {
+			case <-c.done:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic probes
+func (c *Checker) Stop() {
+	c.once.Do(func() { close(c.done) })
+}
+
+// Check probes all the hosts of the subscriber once and updates their status
+func (c *Checker) Check() {
+	hosts, err := c.subscriber.Hosts()
+	if err != nil {
+		c.logger.Warning(c.logPrefix, "Unable to get the hosts to check:", err.Error())
+		return
+	}
+
+	errs := make([]error, len(hosts))
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		if client.IsUnixSocketHost(h) {
+			// the probes can not reach the unix sockets, so they are always healthy
+			continue
+		}
+		wg.Add(1)
+		go func(i int, h string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+			errs[i] = c.probe(ctx, h)
+			cancel()
+		}(i, h)
+	}
+	wg.Wait()
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current := make(map[string]*HostStatus, len(hosts))
+	for i, h := range hosts {
+		s, ok := c.status[h]
+		if !ok {
+			s = &HostStatus{Host: h, Healthy: true}
+		}
+		current[h] = s
+		if client.IsUnixSocketHost(h) {
+			continue
+		}
+		s.LastCheck = now
+		if err := errs[i]; err != nil {
+			s.Successes = 0
+			s.Failures++
+			s.LastError = err.Error()
+			if s.Healthy && s.Failures >= c.cfg.UnhealthyThreshold {
+				s.Healthy = false
+				c.logger.Warning(c.logPrefix, "Host", h, "marked as unhealthy:", s.LastError)
+			}
+			continue
+		}
+		s.Failures = 0
+		s.Successes++
+		s.LastError = ""
+		if !s.Healthy && s.Successes >= c.cfg.HealthyThreshold {
+			s.Healthy = true
+			c.logger.Info(c.logPrefix, "Host", h, "marked as healthy")
+		}
+	}
+	// forget the hosts removed by the subscriber
+	c.status = current
+}
+
+// Status returns a snapshot of the status of the checked hosts, sorted by host
+func (c *Checker) Status() []HostStatus {
+	c.mu.RLock()
+	res := make([]HostStatus, 0, len(c.status))
+	for _, s := range c.status {
+		res = append(res, *s)
+	}
+	c.mu.RUnlock()
+	sort.Slice(res, func(i, j int) bool { return res[i].Host < res[j].Host })
+	return res
+}
+
+type trackedChecker struct {
+	cfg     *config.Backend
+	checker *Checker
+}
+
+var (
+	checkers   = map[string]trackedChecker{}
+	checkersMu sync.RWMutex
+)
+
+// NewSubscriber returns a subscriber filtering out the unhealthy hosts of the received one, if
+// the backend declares the health checks, and starts probing them. The checker replaces and stops
+// the previous one of the same backend. It returns the received subscriber otherwise
+func NewSubscriber(logger logging.Logger, remote *config.Backend, s sd.Subscriber) sd.Subscriber {
+	cfg, ok, err := ConfigGetter(remote.ExtraConfig)
+	if !ok {
+		return s
+	}
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][HealthCheck]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return s
+	}
+	c := NewChecker(cfg, s, NewHTTPProber(cfg.Path, nil), logger)
+	c.logPrefix = logPrefix
+	logger.Debug(logPrefix, "Probing the hosts at", cfg.Path, "every", cfg.Interval.String())
+
+	key := backendKey(remote)
+	checkersMu.Lock()
+	if prev, ok := checkers[key]; ok {
+		prev.checker.Stop()
+	}
+	checkers[key] = trackedChecker{cfg: remote, checker: c}
+	checkersMu.Unlock()
+
+	c.Start()
+	return c
+}
+
+// Statuses returns a snapshot of the status of the hosts of all the checked backends, sorted by
+// endpoint, method and backend
+func Statuses() []BackendStatus {
+	checkersMu.RLock()
+	tcs := make([]trackedChecker, 0, len(checkers))
+	for _, tc := range checkers {
+		tcs = append(tcs, tc)
+	}
+	checkersMu.RUnlock()
+
+	res := make([]BackendStatus, 0, len(tcs))
+	for _, tc := range tcs {
+		res = append(res, BackendStatus{
+			Endpoint: tc.cfg.ParentEndpoint,
+			Method:   tc.cfg.ParentEndpointMethod,
+			Backend:  tc.cfg.URLPattern,
+			Hosts:    tc.checker.Status(),
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Endpoint != res[j].Endpoint {
+			return res[i].Endpoint < res[j].Endpoint
+		}
+		if res[i].Method != res[j].Method {
+			return res[i].Method < res[j].Method
+		}
+		return res[i].Backend < res[j].Backend
+	})
+	return res
+}
+
+// Handler returns a http handler serving the status of the hosts of all the checked backends
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(Statuses())
+	})
+}
+
+// StopAll stops the probes of all the checked backends and forgets them
+func StopAll() {
+	checkersMu.Lock()
+	defer checkersMu.Unlock()
+	for k, tc := range checkers {
+		tc.checker.Stop()
+		delete(checkers, k)
+	}
+}
+
+// retain stops and forgets the checkers not matching a backend of the service config
+func retain(cfg config.ServiceConfig) {
+	keep := map[string]string{}
+	for _, e := range cfg.Endpoints {
+		for _, b := range e.Backend {
+			if raw, err := json.Marshal(b); err == nil {
+				keep[backendKey(b)] = string(raw)
+			}
+		}
+	}
+
+	checkersMu.Lock()
+	defer checkersMu.Unlock()
+	for k, tc := range checkers {
+		if raw, err := json.Marshal(tc.cfg); err == nil && keep[k] == string(raw) {
+			continue
+		}
+		tc.checker.Stop()
+		delete(checkers, k)
+	}
+}
+
+func backendKey(remote *config.Backend) string {
+	return remote.ParentEndpointMethod + " " + remote.ParentEndpoint + " -> " + remote.URLPattern
+}
+
+// Register stops the checkers of the backends removed or modified by the service config, so the
+// ones of the endpoints about to be created replace them. The checkers of the unchanged backends
+// keep running, since the pipes reused across the reloads still depend on them. It returns the
+// path of the status endpoint and false if the service does not declare it
+func Register(cfg config.ServiceConfig) (string, bool) {
+	retain(cfg)
+	tmp, ok := cfg.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	path, _ := tmp["path"].(string)
+	if path == "" {
+		path = DefaultStatusPath
+	}
+	return path, true
+}
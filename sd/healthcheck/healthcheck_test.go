@@ -0,0 +1,221 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+func TestConfigGetter(t *testing.T) {
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{})
+	if ok || err != nil {
+		t.Errorf("unexpected result %v %v", ok, err)
+	}
+	if cfg.Path != DefaultPath || cfg.Interval != DefaultInterval {
+		t.Errorf("unexpected defaults %+v", cfg)
+	}
+
+	cfg, ok, err = ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"path":                "/status",
+		"interval":            "1s",
+		"timeout":             "100ms",
+		"healthy_threshold":   1,
+		"unhealthy_threshold": 5,
+	}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result %v %v", ok, err)
+	}
+	expected := Config{Path: "/status", Interval: time.Second, Timeout: 100 * time.Millisecond, HealthyThreshold: 1, UnhealthyThreshold: 5}
+	if cfg != expected {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	for _, e := range []map[string]interface{}{
+		{"interval": "never"},
+		{"timeout": "-1s"},
+		{"healthy_threshold": -1},
+	} {
+		if _, ok, err := ConfigGetter(config.ExtraConfig{Namespace: e}); !ok || err == nil {
+			t.Errorf("%v: unexpected result %v %v", e, ok, err)
+		}
+	}
+}
+
+func TestChecker(t *testing.T) {
+	var failing atomic.Value
+	failing.Store("")
+	probe := func(_ context.Context, host string) error {
+		if host == failing.Load().(string) {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	cfg := Config{Interval: time.Hour, Timeout: time.Second, HealthyThreshold: 2, UnhealthyThreshold: 2}
+	c := NewChecker(cfg, sd.FixedSubscriber{"http://a", "http://b"}, probe, logging.NoOp)
+
+	assertHosts := func(expected ...string) {
+		t.Helper()
+		hosts, err := c.Hosts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hosts) != len(expected) {
+			t.Fatalf("unexpected hosts %v", hosts)
+		}
+		for i := range hosts {
+			if hosts[i] != expected[i] {
+				t.Fatalf("unexpected hosts %v", hosts)
+			}
+		}
+	}
+
+	assertHosts("http://a", "http://b")
+
+	failing.Store("http://b")
+	c.Check()
+	assertHosts("http://a", "http://b")
+	c.Check()
+	assertHosts("http://a")
+
+	status := c.Status()
+	if len(status) != 2 || status[1].Healthy || status[1].Failures != 2 || status[1].LastError != "boom" {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	failing.Store("")
+	c.Check()
+	assertHosts("http://a")
+	c.Check()
+	assertHosts("http://a", "http://b")
+}
+
+func TestNewHTTPProber(t *testing.T) {
+	var status int32 = http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer ts.Close()
+
+	probe := NewHTTPProber("/health", nil)
+	if err := probe(context.Background(), ts.URL); err != nil {
+		t.Error(err)
+	}
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	if err := probe(context.Background(), ts.URL); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestNewSubscriber(t *testing.T) {
+	defer StopAll()
+
+	var healthy int32 = 1
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	remote := &config.Backend{URLPattern: "/users"}
+	s := sd.FixedSubscriber{ts.URL}
+	if _, ok := NewSubscriber(logging.NoOp, remote, s).(sd.FixedSubscriber); !ok {
+		t.Error("the subscriber of the backends without health checks must not be wrapped")
+	}
+
+	atomic.StoreInt32(&healthy, 0)
+	remote = &config.Backend{
+		ParentEndpoint:       "/users",
+		ParentEndpointMethod: "GET",
+		URLPattern:           "/users",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			"interval":            "10ms",
+			"unhealthy_threshold": 1,
+		}},
+	}
+	hc := NewSubscriber(logging.NoOp, remote, s)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		hosts, _ := hc.Hosts()
+		if len(hosts) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the host was not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := sd.NewBalancer(hc).Host(); err != sd.ErrNoHosts {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", DefaultStatusPath, nil))
+	var statuses []BackendStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Endpoint != "/users" || len(statuses[0].Hosts) != 1 || statuses[0].Hosts[0].Healthy {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+
+	StopAll()
+	if len(Statuses()) != 0 {
+		t.Error("no checkers expected after stopping them")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if _, ok := Register(config.ServiceConfig{}); ok {
+		t.Error("no status endpoint expected")
+	}
+	path, ok := Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{}}})
+	if !ok || path != DefaultStatusPath {
+		t.Errorf("unexpected result %s %v", path, ok)
+	}
+}
+
+func TestRegister_retain(t *testing.T) {
+	defer StopAll()
+
+	newBackend := func(path string) *config.Backend {
+		return &config.Backend{
+			ParentEndpoint:       "/users",
+			ParentEndpointMethod: "GET",
+			URLPattern:           "/users",
+			ExtraConfig:          config.ExtraConfig{Namespace: map[string]interface{}{"path": path, "interval": "1h"}},
+		}
+	}
+	kept := newBackend("/health")
+	NewSubscriber(logging.NoOp, kept, sd.FixedSubscriber{})
+	removed := newBackend("/health")
+	removed.URLPattern = "/removed"
+	NewSubscriber(logging.NoOp, removed, sd.FixedSubscriber{})
+
+	Register(config.ServiceConfig{Endpoints: []*config.EndpointConfig{{Backend: []*config.Backend{newBackend("/health")}}}})
+	if statuses := Statuses(); len(statuses) != 1 || statuses[0].Backend != "/users" {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+
+	Register(config.ServiceConfig{Endpoints: []*config.EndpointConfig{{Backend: []*config.Backend{newBackend("/status")}}}})
+	if statuses := Statuses(); len(statuses) != 0 {
+		t.Errorf("the checker of the modified backend was not stopped: %+v", statuses)
+	}
+}
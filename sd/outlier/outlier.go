@@ -0,0 +1,304 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package outlier ejects the misbehaving hosts of the backends from the balancing pool, based on the
+results of the requests sent to them, so no probes are required.
+
+The detection is declared per backend:
+
+	"extra_config": {
+		"github.com/luraproject/lura/sd/outlier": {
+			"consecutive_errors": 5,
+			"error_rate": 0.5,
+			"min_requests": 10,
+			"interval": "10s",
+			"base_ejection_time": "30s",
+			"max_ejection_time": "5m",
+			"max_ejection_percent": 50
+		}
+	}
+
+A host is ejected after the configured number of consecutive failed requests, or when its error
+rate during the interval reaches the configured one, once it got the minimum number of requests.
+The ejection time doubles every time the host is ejected again, up to the max ejection time, and
+decreases while the host behaves. The detector never ejects more than the max ejection percentage
+of the hosts, so the backend keeps part of its capacity while the outliers recover.
+
+The detection is independent of the active health checks, and both of them can be combined.
+*/
+package outlier
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+// Namespace is the key to use to store and access the outlier detection config
+const Namespace = "github.com/luraproject/lura/sd/outlier"
+
+const (
+	// DefaultConsecutiveErrors is the number of consecutive failed requests ejecting a host when
+	// the backend config does not declare it
+	DefaultConsecutiveErrors = 5
+	// DefaultMinRequests is the number of requests required to check the error rate of a host
+	// when the backend config does not declare it
+	DefaultMinRequests = 10
+	// DefaultInterval is the period of the error rates when the backend config does not declare it
+	DefaultInterval = 10 * time.Second
+	// DefaultBaseEjectionTime is the duration of the first ejection of a host when the backend
+	// config does not declare it
+	DefaultBaseEjectionTime = 30 * time.Second
+	// DefaultMaxEjectionTime is the max duration of the ejections when the backend config does
+	// not declare it
+	DefaultMaxEjectionTime = 5 * time.Minute
+	// DefaultMaxEjectionPercent is the max percentage of ejected hosts when the backend config
+	// does not declare it
+	DefaultMaxEjectionPercent = 50
+)
+
+// Config is the outlier detection config of a backend
+type Config struct {
+	ConsecutiveErrors  int
+	ErrorRate          float64
+	MinRequests        int
+	Interval           time.Duration
+	BaseEjectionTime   time.Duration
+	MaxEjectionTime    time.Duration
+	MaxEjectionPercent int
+}
+
+type rawConfig struct {
+	ConsecutiveErrors  int     `json:"consecutive_errors"`
+	ErrorRate          float64 `json:"error_rate"`
+	MinRequests        int     `json:"min_requests"`
+	Interval           string  `json:"interval"`
+	BaseEjectionTime   string  `json:"base_ejection_time"`
+	MaxEjectionTime    string  `json:"max_ejection_time"`
+	MaxEjectionPercent *int    `json:"max_ejection_percent"`
+}
+
+// ConfigGetter parses the outlier detection config from the backend extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{
+		ConsecutiveErrors:  DefaultConsecutiveErrors,
+		MinRequests:        DefaultMinRequests,
+		Interval:           DefaultInterval,
+		BaseEjectionTime:   DefaultBaseEjectionTime,
+		MaxEjectionTime:    DefaultMaxEjectionTime,
+		MaxEjectionPercent: DefaultMaxEjectionPercent,
+	}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	var raw rawConfig
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return cfg, true, fmt.Errorf("outlier: parsing the config: %w", err)
+	}
+	if raw.ConsecutiveErrors < 0 || raw.MinRequests < 0 {
+		return cfg, true, fmt.Errorf("outlier: the thresholds must be positive")
+	}
+	if raw.ConsecutiveErrors > 0 {
+		cfg.ConsecutiveErrors = raw.ConsecutiveErrors
+	}
+	if raw.MinRequests > 0 {
+		cfg.MinRequests = raw.MinRequests
+	}
+	if raw.ErrorRate < 0 || raw.ErrorRate > 1 {
+		return cfg, true, fmt.Errorf("outlier: invalid error_rate %v", raw.ErrorRate)
+	}
+	cfg.ErrorRate = raw.ErrorRate
+	if raw.MaxEjectionPercent != nil {
+		if *raw.MaxEjectionPercent < 0 || *raw.MaxEjectionPercent > 100 {
+			return cfg, true, fmt.Errorf("outlier: invalid max_ejection_percent %d", *raw.MaxEjectionPercent)
+		}
+		cfg.MaxEjectionPercent = *raw.MaxEjectionPercent
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"interval", raw.Interval, &cfg.Interval},
+		{"base_ejection_time", raw.BaseEjectionTime, &cfg.BaseEjectionTime},
+		{"max_ejection_time", raw.MaxEjectionTime, &cfg.MaxEjectionTime},
+	} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v <= 0 {
+			return cfg, true, fmt.Errorf("outlier: invalid %s %s", d.name, d.value)
+		}
+		*d.dst = v
+	}
+	if cfg.MaxEjectionTime < cfg.BaseEjectionTime {
+		cfg.MaxEjectionTime = cfg.BaseEjectionTime
+	}
+	return cfg, true, nil
+}
+
+type hostState struct {
+	consecutive  int
+	requests     int
+	failures     int
+	windowStart  time.Time
+	ejections    int
+	ejectedUntil time.Time
+}
+
+// Detector tracks the results of the requests to the hosts of a subscriber. It implements the
+// sd.Subscriber interface, returning only the hosts not ejected
+type Detector struct {
+	cfg        Config
+	subscriber sd.Subscriber
+	logger     logging.Logger
+	logPrefix  string
+	now        func() time.Time
+
+	mu     sync.Mutex
+	hosts  []string
+	states map[string]*hostState
+}
+
+// NewDetector returns a Detector ejecting the outliers of the hosts of the subscriber
+func NewDetector(cfg Config, s sd.Subscriber, logger logging.Logger) *Detector {
+	return &Detector{
+		cfg:        cfg,
+		subscriber: s,
+		logger:     logger,
+		logPrefix:  "[OutlierDetection]",
+		now:        time.Now,
+		states:     map[string]*hostState{},
+	}
+}
+
+// New returns a Detector over the received subscriber if the backend declares the outlier
+// detection, and false otherwise
+func New(logger logging.Logger, remote *config.Backend, s sd.Subscriber) (*Detector, bool) {
+	cfg, ok, err := ConfigGetter(remote.ExtraConfig)
+	if !ok {
+		return nil, false
+	}
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][OutlierDetection]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return nil, false
+	}
+	d := NewDetector(cfg, s, logger)
+	d.logPrefix = logPrefix
+	return d, true
+}
+
+// Hosts implements the sd.Subscriber interface
+func (d *Detector) Hosts() ([]string, error) {
+	hosts, err := d.subscriber.Hosts()
+	if err != nil {
+		return hosts, err
+	}
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hosts = hosts
+	res := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		if s, ok := d.states[h]; ok && now.Before(s.ejectedUntil) {
+			continue
+		}
+		res = append(res, h)
+	}
+	return res, nil
+}
+
+// Ejected returns true if the host is currently ejected
+func (d *Detector) Ejected(host string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.states[host]
+	return ok && d.now().Before(s.ejectedUntil)
+}
+
+// Report records the result of a request. The target is the url of the request or the host
+// selected by the balancer, so the request is accounted to the host it starts with
+func (d *Detector) Report(target string, failed bool) {
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	host := d.match(target)
+	if host == "" {
+		return
+	}
+	s, ok := d.states[host]
+	if !ok {
+		s = &hostState{windowStart: now}
+		d.states[host] = s
+	}
+	if now.Sub(s.windowStart) >= d.cfg.Interval {
+		// the hosts behaving during a whole interval get their ejection time reduced
+		if s.failures == 0 && s.ejections > 0 && !now.Before(s.ejectedUntil) {
+			s.ejections--
+		}
+		s.requests, s.failures, s.windowStart = 0, 0, now
+	}
+
+	s.requests++
+	if !failed {
+		s.consecutive = 0
+		return
+	}
+	s.failures++
+	s.consecutive++
+	if now.Before(s.ejectedUntil) || !d.outlier(s) || !d.canEject(now) {
+		return
+	}
+
+	ejection := d.cfg.BaseEjectionTime << uint(s.ejections)
+	if ejection > d.cfg.MaxEjectionTime || ejection <= 0 {
+		ejection = d.cfg.MaxEjectionTime
+	}
+	s.ejections++
+	s.ejectedUntil = now.Add(ejection)
+	s.consecutive, s.requests, s.failures, s.windowStart = 0, 0, 0, now
+	d.logger.Warning(d.logPrefix, "Ejecting the host", host, "for", ejection.String())
+}
+
+func (d *Detector) outlier(s *hostState) bool {
+	if s.consecutive >= d.cfg.ConsecutiveErrors {
+		return true
+	}
+	return d.cfg.ErrorRate > 0 && s.requests >= d.cfg.MinRequests && float64(s.failures)/float64(s.requests) >= d.cfg.ErrorRate
+}
+
+func (d *Detector) canEject(now time.Time) bool {
+	ejected := 0
+	for _, h := range d.hosts {
+		if s, ok := d.states[h]; ok && now.Before(s.ejectedUntil) {
+			ejected++
+		}
+	}
+	return (ejected+1)*100 <= d.cfg.MaxEjectionPercent*len(d.hosts)
+}
+
+// match returns the longest known host the target starts with
+func (d *Detector) match(target string) string {
+	res := ""
+	for _, h := range d.hosts {
+		if len(h) > len(res) && strings.HasPrefix(target, h) {
+			res = h
+		}
+	}
+	return res
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package outlier
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+func TestConfigGetter(t *testing.T) {
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{})
+	if ok || err != nil {
+		t.Errorf("unexpected result %v %v", ok, err)
+	}
+	if cfg.ConsecutiveErrors != DefaultConsecutiveErrors || cfg.MaxEjectionPercent != DefaultMaxEjectionPercent {
+		t.Errorf("unexpected defaults %+v", cfg)
+	}
+
+	cfg, ok, err = ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"consecutive_errors":   3,
+		"error_rate":           0.5,
+		"min_requests":         4,
+		"interval":             "1s",
+		"base_ejection_time":   "10s",
+		"max_ejection_time":    "1m",
+		"max_ejection_percent": 100,
+	}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result %v %v", ok, err)
+	}
+	expected := Config{
+		ConsecutiveErrors:  3,
+		ErrorRate:          0.5,
+		MinRequests:        4,
+		Interval:           time.Second,
+		BaseEjectionTime:   10 * time.Second,
+		MaxEjectionTime:    time.Minute,
+		MaxEjectionPercent: 100,
+	}
+	if cfg != expected {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	for _, raw := range []map[string]interface{}{
+		{"error_rate": 2},
+		{"interval": "nope"},
+		{"max_ejection_percent": 120},
+		{"consecutive_errors": -1},
+	} {
+		if _, ok, err := ConfigGetter(config.ExtraConfig{Namespace: raw}); !ok || err == nil {
+			t.Errorf("%v: expecting an error", raw)
+		}
+	}
+}
+
+func newTestDetector(cfg Config, hosts ...string) (*Detector, *time.Time) {
+	now := time.Now()
+	d := NewDetector(cfg, sd.FixedSubscriber(hosts), logging.NoOp)
+	d.now = func() time.Time { return now }
+	d.Hosts()
+	return d, &now
+}
+
+func TestDetector_consecutiveErrors(t *testing.T) {
+	cfg := Config{
+		ConsecutiveErrors:  3,
+		MinRequests:        DefaultMinRequests,
+		Interval:           time.Minute,
+		BaseEjectionTime:   10 * time.Second,
+		MaxEjectionTime:    30 * time.Second,
+		MaxEjectionPercent: 50,
+	}
+	d, now := newTestDetector(cfg, "http://a", "http://b")
+
+	d.Report("http://a/users", true)
+	d.Report("http://a/users", true)
+	d.Report("http://a/users", false)
+	d.Report("http://a/users", true)
+	d.Report("http://a/users", true)
+	if d.Ejected("http://a") {
+		t.Fatal("the host should not be ejected yet")
+	}
+	d.Report("http://a/users?page=1", true)
+	if !d.Ejected("http://a") {
+		t.Fatal("the host should be ejected")
+	}
+	if hosts, _ := d.Hosts(); !reflect.DeepEqual(hosts, []string{"http://b"}) {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+
+	// the second host can not be ejected because of the max ejection percent
+	for i := 0; i < 5; i++ {
+		d.Report("http://b/users", true)
+	}
+	if d.Ejected("http://b") {
+		t.Error("the max ejection percent should be respected")
+	}
+
+	*now = now.Add(10 * time.Second)
+	if d.Ejected("http://a") {
+		t.Fatal("the host should be back")
+	}
+	for i := 0; i < 3; i++ {
+		d.Report("http://a/users", true)
+	}
+	// the second ejection lasts twice the base ejection time
+	*now = now.Add(15 * time.Second)
+	if !d.Ejected("http://a") {
+		t.Error("the ejection time should grow")
+	}
+	*now = now.Add(5 * time.Second)
+	if d.Ejected("http://a") {
+		t.Error("the host should be back")
+	}
+}
+
+func TestDetector_errorRate(t *testing.T) {
+	cfg := Config{
+		ConsecutiveErrors:  100,
+		ErrorRate:          0.5,
+		MinRequests:        4,
+		Interval:           time.Minute,
+		BaseEjectionTime:   10 * time.Second,
+		MaxEjectionTime:    10 * time.Second,
+		MaxEjectionPercent: 100,
+	}
+	d, _ := newTestDetector(cfg, "unix:///tmp/a.sock", "http://b")
+
+	d.Report("unix:///tmp/a.sock", true)
+	d.Report("unix:///tmp/a.sock", false)
+	d.Report("unix:///tmp/a.sock", true)
+	if d.Ejected("unix:///tmp/a.sock") {
+		t.Fatal("the min requests should be respected")
+	}
+	d.Report("unix:///tmp/a.sock", true)
+	if !d.Ejected("unix:///tmp/a.sock") {
+		t.Fatal("the host should be ejected")
+	}
+
+	d.Report("http://unknown/users", true)
+	if d.Ejected("http://unknown") {
+		t.Error("unknown hosts should be ignored")
+	}
+}
+
+func TestNew(t *testing.T) {
+	s := sd.FixedSubscriber{"http://a"}
+	if _, ok := New(logging.NoOp, &config.Backend{}, s); ok {
+		t.Error("the backend does not declare the detection")
+	}
+	if _, ok := New(logging.NoOp, &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"interval": "-"}}}, s); ok {
+		t.Error("the invalid configs should be ignored")
+	}
+	d, ok := New(logging.NoOp, &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{}}}, s)
+	if !ok || d == nil {
+		t.Fatal("expecting a detector")
+	}
+	if hosts, err := d.Hosts(); err != nil || !reflect.DeepEqual(hosts, []string{"http://a"}) {
+		t.Errorf("unexpected hosts %v %v", hosts, err)
+	}
+}
@@ -34,17 +34,64 @@ func (r *Register) Register(name string, sf SubscriberFactory) error {
 }
 
 // Get returns the SubscriberFactory stored under the given name. It falls back to
-// a FixedSubscriberFactory if there is no factory with that name
+// a FixedSubscriberFactory if there is no factory with that name. The subscribers built
+// by the returned factory are added to the package tracker
 func (r *Register) Get(name string) SubscriberFactory {
 	tmp, ok := r.data.Get(name)
 	if !ok {
-		return FixedSubscriberFactory
+		return trackedFactory(FixedSubscriberFactory)
 	}
 	sf, ok := tmp.(SubscriberFactory)
 	if !ok {
-		return FixedSubscriberFactory
+		return trackedFactory(FixedSubscriberFactory)
 	}
-	return sf
+	return trackedFactory(sf)
 }
 
 var subscriberFactories = initRegister()
+
+// BalancerFactory builds a Balancer over the received subscriber
+type BalancerFactory func(Subscriber) Balancer
+
+// The names of the balancers included in the balancer register
+const (
+	BalancerRoundRobin = "round-robin"
+	BalancerRandom     = "random"
+)
+
+// GetBalancerRegister returns the package register of balancer factories
+func GetBalancerRegister() *BalancerRegister {
+	return balancerFactories
+}
+
+// BalancerRegister maps the balancer factories to their names, so the backends can select their
+// balancing strategy by name
+type BalancerRegister struct {
+	data untypedRegister
+}
+
+func initBalancerRegister() *BalancerRegister {
+	r := &BalancerRegister{register.NewUntyped()}
+	r.Register(BalancerRoundRobin, NewRoundRobinLB)
+	r.Register(BalancerRandom, NewRandomLB)
+	return r
+}
+
+// Register adds the BalancerFactory to the internal register under the given name
+func (r *BalancerRegister) Register(name string, bf BalancerFactory) error {
+	r.data.Register(name, bf)
+	return nil
+}
+
+// Get returns the BalancerFactory stored under the given name, and false if there is no
+// factory with that name
+func (r *BalancerRegister) Get(name string) (BalancerFactory, bool) {
+	tmp, ok := r.data.Get(name)
+	if !ok {
+		return nil, false
+	}
+	bf, ok := tmp.(BalancerFactory)
+	return bf, ok
+}
+
+var balancerFactories = initBalancerRegister()
@@ -50,3 +50,48 @@ func TestGetRegister_Get_errored(t *testing.T) {
 	}
 	subscriberFactories = initRegister()
 }
+
+func TestGetBalancerRegister(t *testing.T) {
+	defer func() { balancerFactories = initBalancerRegister() }()
+
+	subscriber := FixedSubscriber{"a", "b"}
+	for _, name := range []string{BalancerRoundRobin, BalancerRandom} {
+		bf, ok := GetBalancerRegister().Get(name)
+		if !ok {
+			t.Errorf("missing balancer %s", name)
+			continue
+		}
+		if _, err := bf(subscriber).Host(); err != nil {
+			t.Errorf("%s: %s", name, err.Error())
+		}
+	}
+
+	if _, ok := GetBalancerRegister().Get("first"); ok {
+		t.Error("unexpected balancer")
+	}
+	GetBalancerRegister().Register("first", func(s Subscriber) Balancer {
+		return &firstLB{s}
+	})
+	bf, ok := GetBalancerRegister().Get("first")
+	if !ok {
+		t.Fatal("missing balancer")
+	}
+	if h, err := bf(subscriber).Host(); err != nil || h != "a" {
+		t.Errorf("unexpected host %s: %v", h, err)
+	}
+}
+
+type firstLB struct {
+	s Subscriber
+}
+
+func (f *firstLB) Host() (string, error) {
+	hosts, err := f.s.Hosts()
+	if err != nil {
+		return "", err
+	}
+	if len(hosts) == 0 {
+		return "", ErrNoHosts
+	}
+	return hosts[0], nil
+}
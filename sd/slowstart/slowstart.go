@@ -0,0 +1,227 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package slowstart ramps up the traffic share of the hosts joining the balancing pool of a backend,
+so the freshly deployed instances are not hit with their full share of the load while their caches
+and connection pools are cold.
+
+The slow start is declared per backend:
+
+	"extra_config": {
+		"github.com/luraproject/lura/sd/slowstart": {
+			"window": "60s",
+			"aggression": 1.0,
+			"min_weight_percent": 10
+		}
+	}
+
+A host is warming up during the window after it is returned for the first time by the wrapped
+subscriber (service discovery, health checks, outlier detection...), so the hosts recovering from
+a failure are ramped up too. Its weight grows from the min weight percent to 100% following
+(elapsed / window) ^ (1 / aggression): the aggression 1 ramps up linearly, and the greater values
+send more traffic earlier. The balancers get the warming hosts with a probability equal to their
+weight, so their share of the requests grows smoothly.
+
+The hosts available when the subscriber is first used are not ramped up, so the restarts of the
+gateway do not throttle the whole pool, and neither are the hosts of a pool with no warm hosts.
+*/
+package slowstart
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/valyala/fastrand"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+// Namespace is the key to use to store and access the slow start config
+const Namespace = "github.com/luraproject/lura/sd/slowstart"
+
+const (
+	// DefaultAggression is the aggression of the ramp up when the backend config does not declare it
+	DefaultAggression = 1.0
+	// DefaultMinWeightPercent is the initial weight of the new hosts when the backend config does
+	// not declare it
+	DefaultMinWeightPercent = 10
+)
+
+// Config is the slow start config of a backend
+type Config struct {
+	Window           time.Duration
+	Aggression       float64
+	MinWeightPercent int
+}
+
+type rawConfig struct {
+	Window           string   `json:"window"`
+	Aggression       *float64 `json:"aggression"`
+	MinWeightPercent *int     `json:"min_weight_percent"`
+}
+
+// ConfigGetter parses the slow start config from the backend extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{Aggression: DefaultAggression, MinWeightPercent: DefaultMinWeightPercent}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	var raw rawConfig
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return cfg, true, fmt.Errorf("slowstart: parsing the config: %w", err)
+	}
+	cfg.Window, err = time.ParseDuration(raw.Window)
+	if err != nil || cfg.Window <= 0 {
+		return cfg, true, fmt.Errorf("slowstart: invalid window %q", raw.Window)
+	}
+	if raw.Aggression != nil {
+		if *raw.Aggression <= 0 {
+			return cfg, true, fmt.Errorf("slowstart: invalid aggression %v", *raw.Aggression)
+		}
+		cfg.Aggression = *raw.Aggression
+	}
+	if raw.MinWeightPercent != nil {
+		if *raw.MinWeightPercent < 0 || *raw.MinWeightPercent > 100 {
+			return cfg, true, fmt.Errorf("slowstart: invalid min_weight_percent %d", *raw.MinWeightPercent)
+		}
+		cfg.MinWeightPercent = *raw.MinWeightPercent
+	}
+	return cfg, true, nil
+}
+
+// Subscriber returns the hosts of the wrapped subscriber, dropping the warming ones from some of
+// the lists according to their weight. It implements the sd.Subscriber interface
+type Subscriber struct {
+	cfg        Config
+	subscriber sd.Subscriber
+	now        func() time.Time
+	rand       func(uint32) uint32
+
+	mu      sync.Mutex
+	started bool
+	joined  map[string]time.Time
+	// warmUntil is the end of the window of the latest host joining the pool
+	warmUntil time.Time
+}
+
+// NewSlowStartSubscriber returns a Subscriber ramping up the traffic share of the hosts joining
+// the pool of the received subscriber
+func NewSlowStartSubscriber(cfg Config, s sd.Subscriber) *Subscriber {
+	return &Subscriber{
+		cfg:        cfg,
+		subscriber: s,
+		now:        time.Now,
+		rand:       fastrand.Uint32n,
+		joined:     map[string]time.Time{},
+	}
+}
+
+// NewSubscriber wraps the received subscriber with a Subscriber if the backend declares the slow
+// start config
+func NewSubscriber(logger logging.Logger, remote *config.Backend, s sd.Subscriber) sd.Subscriber {
+	cfg, ok, err := ConfigGetter(remote.ExtraConfig)
+	if !ok {
+		return s
+	}
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][SlowStart]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return s
+	}
+	logger.Debug(logPrefix, "Ramping up the new hosts during", cfg.Window.String())
+	return NewSlowStartSubscriber(cfg, s)
+}
+
+// Hosts implements the sd.Subscriber interface
+func (s *Subscriber) Hosts() ([]string, error) {
+	hosts, err := s.subscriber.Hosts()
+	if err != nil || len(hosts) == 0 {
+		return hosts, err
+	}
+	now := s.now()
+	weights := s.weights(hosts, now)
+	if weights == nil {
+		return hosts, nil
+	}
+	res := make([]string, 0, len(hosts))
+	for i, h := range hosts {
+		if w := weights[i]; w < 1 && float64(s.rand(1<<16)) >= w*(1<<16) {
+			continue
+		}
+		res = append(res, h)
+	}
+	if len(res) == 0 {
+		return hosts, nil
+	}
+	return res, nil
+}
+
+// weight returns the weight of a host after being in the pool for the elapsed time
+func (c Config) weight(elapsed time.Duration) float64 {
+	if elapsed >= c.Window {
+		return 1
+	}
+	min := float64(c.MinWeightPercent) / 100
+	if elapsed <= 0 {
+		return min
+	}
+	return math.Max(min, math.Pow(float64(elapsed)/float64(c.Window), 1/c.Aggression))
+}
+
+// weights updates the hosts in the pool and returns their weights, or nil if there are no
+// warming hosts or no warm ones
+func (s *Subscriber) weights(hosts []string, now time.Time) []float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := len(s.joined) != len(hosts)
+	for i := 0; !changed && i < len(hosts); i++ {
+		_, ok := s.joined[hosts[i]]
+		changed = !ok
+	}
+	if changed {
+		// the hosts leaving the pool are forgotten, so they are ramped up again if they return
+		joined := make(map[string]time.Time, len(hosts))
+		for _, h := range hosts {
+			t, ok := s.joined[h]
+			if !ok && s.started {
+				t = now
+				if end := now.Add(s.cfg.Window); end.After(s.warmUntil) {
+					s.warmUntil = end
+				}
+			}
+			joined[h] = t
+		}
+		s.joined = joined
+		s.started = true
+	}
+	if !now.Before(s.warmUntil) {
+		return nil
+	}
+
+	weights := make([]float64, len(hosts))
+	warm := false
+	for i, h := range hosts {
+		t := s.joined[h]
+		if t.IsZero() {
+			weights[i] = 1
+		} else {
+			weights[i] = s.cfg.weight(now.Sub(t))
+		}
+		warm = warm || weights[i] == 1
+	}
+	if !warm {
+		return nil
+	}
+	return weights
+}
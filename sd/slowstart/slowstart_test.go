@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package slowstart
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok, _ := ConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("unexpected config")
+	}
+
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"window": "30s"}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result %v %v", ok, err)
+	}
+	if cfg.Window != 30*time.Second || cfg.Aggression != DefaultAggression || cfg.MinWeightPercent != DefaultMinWeightPercent {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	for _, raw := range []map[string]interface{}{
+		{},
+		{"window": "-1s"},
+		{"window": "1s", "aggression": 0},
+		{"window": "1s", "min_weight_percent": 101},
+		{"window": 10},
+	} {
+		if _, ok, err := ConfigGetter(config.ExtraConfig{Namespace: raw}); !ok || err == nil {
+			t.Errorf("%v: expecting an error", raw)
+		}
+	}
+}
+
+func TestConfig_weight(t *testing.T) {
+	cfg := Config{Window: 100 * time.Second, Aggression: 1, MinWeightPercent: 10}
+	for elapsed, expected := range map[time.Duration]float64{
+		0:                  0.1,
+		5 * time.Second:    0.1,
+		50 * time.Second:   0.5,
+		100 * time.Second:  1,
+		1000 * time.Second: 1,
+	} {
+		if w := cfg.weight(elapsed); math.Abs(w-expected) > 1e-9 {
+			t.Errorf("unexpected weight after %s: %v", elapsed, w)
+		}
+	}
+	cfg.Aggression = 2
+	if w := cfg.weight(25 * time.Second); math.Abs(w-0.5) > 1e-9 {
+		t.Errorf("unexpected weight: %v", w)
+	}
+}
+
+type hostsSubscriber struct{ hosts []string }
+
+func (h *hostsSubscriber) Hosts() ([]string, error) { return h.hosts, nil }
+
+func TestSubscriber(t *testing.T) {
+	available := &hostsSubscriber{hosts: []string{"http://a", "http://b"}}
+	s := NewSlowStartSubscriber(Config{Window: 100 * time.Second, Aggression: 1, MinWeightPercent: 10}, available)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	var dice uint32
+	s.rand = func(n uint32) uint32 { return dice*n/100 + n/200 }
+
+	count := func() map[string]int {
+		res := map[string]int{}
+		for dice = 0; dice < 100; dice++ {
+			hosts, err := s.Hosts()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, h := range hosts {
+				res[h]++
+			}
+		}
+		return res
+	}
+
+	if c := count(); c["http://a"] != 100 || c["http://b"] != 100 {
+		t.Errorf("the initial hosts must not be ramped up: %v", c)
+	}
+
+	available.hosts = []string{"http://a", "http://b", "http://c"}
+	if c := count(); c["http://a"] != 100 || c["http://c"] != 10 {
+		t.Errorf("unexpected distribution: %v", c)
+	}
+
+	now = now.Add(50 * time.Second)
+	if c := count(); c["http://b"] != 100 || c["http://c"] != 50 {
+		t.Errorf("unexpected distribution: %v", c)
+	}
+
+	now = now.Add(50 * time.Second)
+	if c := count(); c["http://c"] != 100 {
+		t.Errorf("the host must be warm: %v", c)
+	}
+
+	// the hosts leaving the pool are ramped up again when they return
+	available.hosts = []string{"http://a", "http://c"}
+	count()
+	available.hosts = []string{"http://a", "http://b", "http://c"}
+	if c := count(); c["http://b"] != 10 || c["http://c"] != 100 {
+		t.Errorf("unexpected distribution: %v", c)
+	}
+
+	// the pools without warm hosts are not ramped up
+	available.hosts = []string{"http://d"}
+	if c := count(); c["http://d"] != 100 {
+		t.Errorf("unexpected distribution: %v", c)
+	}
+}
+
+func TestNewSubscriber(t *testing.T) {
+	s := sd.FixedSubscriber{"http://a"}
+	if res := NewSubscriber(logging.NoOp, &config.Backend{}, s); len(res.(sd.FixedSubscriber)) != 1 {
+		t.Error("the subscriber must not be wrapped")
+	}
+	if _, ok := NewSubscriber(logging.NoOp, &config.Backend{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{"window": "nope"},
+	}}, s).(sd.FixedSubscriber); !ok {
+		t.Error("the subscriber must not be wrapped with an invalid config")
+	}
+	if _, ok := NewSubscriber(logging.NoOp, &config.Backend{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{"window": "10s"},
+	}}, s).(*Subscriber); !ok {
+		t.Error("the subscriber was not wrapped")
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sd
+
+import (
+	"sort"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/register"
+)
+
+// GetTracker returns the package tracker, containing all the subscribers built with the factories
+// returned by the package register
+func GetTracker() *Tracker {
+	return tracker
+}
+
+// Tracker keeps track of the subscribers used by the balancers, so the discovered hosts can
+// be exported to other systems
+type Tracker struct {
+	data *register.Untyped
+}
+
+// TrackedBackend describes the hosts of a backend, as seen by its subscriber
+type TrackedBackend struct {
+	Endpoint string
+	Method   string
+	Backend  string
+	SD       string
+	Hosts    []string
+}
+
+type trackedSubscriber struct {
+	cfg        *config.Backend
+	subscriber Subscriber
+}
+
+// Track adds the subscriber to the tracker. Subscribers for the same backend replace the
+// previous ones
+func (t *Tracker) Track(cfg *config.Backend, s Subscriber) {
+	t.data.Register(trackerKey(cfg), trackedSubscriber{cfg: cfg, subscriber: s})
+}
+
+// Backends returns a snapshot of the hosts of all the tracked backends, sorted by endpoint,
+// method and backend. The subscribers returning an error are skipped
+func (t *Tracker) Backends() []TrackedBackend {
+	res := []TrackedBackend{}
+	for _, v := range t.data.Clone() {
+		ts, ok := v.(trackedSubscriber)
+		if !ok {
+			continue
+		}
+		hosts, err := ts.subscriber.Hosts()
+		if err != nil {
+			continue
+		}
+		sd := ts.cfg.SD
+		if sd == "" {
+			sd = "static"
+		}
+		res = append(res, TrackedBackend{
+			Endpoint: ts.cfg.ParentEndpoint,
+			Method:   ts.cfg.ParentEndpointMethod,
+			Backend:  ts.cfg.URLPattern,
+			SD:       sd,
+			Hosts:    append([]string{}, hosts...),
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Endpoint != res[j].Endpoint {
+			return res[i].Endpoint < res[j].Endpoint
+		}
+		if res[i].Method != res[j].Method {
+			return res[i].Method < res[j].Method
+		}
+		return res[i].Backend < res[j].Backend
+	})
+	return res
+}
+
+func trackerKey(cfg *config.Backend) string {
+	return cfg.ParentEndpointMethod + " " + cfg.ParentEndpoint + " -> " + cfg.URLPattern
+}
+
+func trackedFactory(sf SubscriberFactory) SubscriberFactory {
+	return func(cfg *config.Backend) Subscriber {
+		s := sf(cfg)
+		tracker.Track(cfg, s)
+		return s
+	}
+}
+
+var tracker = &Tracker{register.NewUntyped()}
@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sd
+
+import (
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestTracker(t *testing.T) {
+	GetRegister().Get("")(&config.Backend{
+		Host:                 []string{"http://b:8080"},
+		URLPattern:           "/b",
+		ParentEndpoint:       "/tracked",
+		ParentEndpointMethod: "GET",
+	})
+	GetRegister().Get("")(&config.Backend{
+		Host:                 []string{"http://a:8080", "http://a:8081"},
+		URLPattern:           "/a",
+		ParentEndpoint:       "/tracked",
+		ParentEndpointMethod: "GET",
+	})
+
+	var tracked []TrackedBackend
+	for _, b := range GetTracker().Backends() {
+		if b.Endpoint == "/tracked" {
+			tracked = append(tracked, b)
+		}
+	}
+	if len(tracked) != 2 {
+		t.Errorf("unexpected tracked backends: %+v", tracked)
+		return
+	}
+	if tracked[0].Backend != "/a" || len(tracked[0].Hosts) != 2 || tracked[0].SD != "static" {
+		t.Errorf("unexpected tracked backend: %+v", tracked[0])
+	}
+	if tracked[1].Backend != "/b" || len(tracked[1].Hosts) != 1 {
+		t.Errorf("unexpected tracked backend: %+v", tracked[1])
+	}
+}
@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package zone makes the balancers of the backends prefer the hosts running in the same zone, or
+region, as the gateway, so the cross zone traffic (and its costs) is reduced.
+
+The location of the hosts is declared per backend:
+
+	"extra_config": {
+		"github.com/luraproject/lura/sd/zone": {
+			"zone": "eu-west-1a",
+			"region": "eu-west-1",
+			"hosts": {
+				"http://10.0.1.10:8080": {"zone": "eu-west-1a", "region": "eu-west-1"},
+				"http://10.0.2.10:8080": {"zone": "eu-west-1b", "region": "eu-west-1"},
+				"http://10.1.1.10:8080": {"zone": "us-east-1a", "region": "us-east-1"}
+			},
+			"min_healthy_percent": 50
+		}
+	}
+
+The zone and the region of the gateway default to the values of the LURA_ZONE and LURA_REGION
+environment variables, so the same config can be deployed in every zone.
+
+The balancers get the available hosts of the local zone while, at least, the min healthy
+percentage of them is available. Otherwise, the traffic spills over to the available hosts of the
+local region and, then, to all the available hosts. The hosts are available when the subscribers
+wrapped (health checks, outlier detection...) return them.
+*/
+package zone
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+// Namespace is the key to use to store and access the zone config
+const Namespace = "github.com/luraproject/lura/sd/zone"
+
+const (
+	// ZoneEnvVar is the environment variable with the zone of the gateway
+	ZoneEnvVar = "LURA_ZONE"
+	// RegionEnvVar is the environment variable with the region of the gateway
+	RegionEnvVar = "LURA_REGION"
+	// DefaultMinHealthyPercent is the min percentage of available local hosts preventing the
+	// spillover when the backend config does not declare it
+	DefaultMinHealthyPercent = 50
+)
+
+// Location is the zone and the region of a host
+type Location struct {
+	Zone   string `json:"zone"`
+	Region string `json:"region"`
+}
+
+// Config is the zone config of a backend
+type Config struct {
+	Location
+	Hosts             map[string]Location
+	MinHealthyPercent int
+}
+
+type rawConfig struct {
+	Zone              string              `json:"zone"`
+	Region            string              `json:"region"`
+	Hosts             map[string]Location `json:"hosts"`
+	MinHealthyPercent *int                `json:"min_healthy_percent"`
+}
+
+// ConfigGetter parses the zone config from the backend extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{MinHealthyPercent: DefaultMinHealthyPercent}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, true, err
+	}
+	var raw rawConfig
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return cfg, true, fmt.Errorf("zone: parsing the config: %w", err)
+	}
+	cfg.Zone, cfg.Region, cfg.Hosts = raw.Zone, raw.Region, raw.Hosts
+	if cfg.Zone == "" {
+		cfg.Zone = os.Getenv(ZoneEnvVar)
+	}
+	if cfg.Region == "" {
+		cfg.Region = os.Getenv(RegionEnvVar)
+	}
+	if raw.MinHealthyPercent != nil {
+		if *raw.MinHealthyPercent < 0 || *raw.MinHealthyPercent > 100 {
+			return cfg, true, fmt.Errorf("zone: invalid min_healthy_percent %d", *raw.MinHealthyPercent)
+		}
+		cfg.MinHealthyPercent = *raw.MinHealthyPercent
+	}
+	if cfg.Zone == "" && cfg.Region == "" {
+		return cfg, true, fmt.Errorf("zone: the location of the gateway is unknown")
+	}
+	if len(cfg.Hosts) == 0 {
+		return cfg, true, fmt.Errorf("zone: no host locations declared")
+	}
+	return cfg, true, nil
+}
+
+// Subscriber returns the available hosts closest to the gateway. It implements the sd.Subscriber
+// interface
+type Subscriber struct {
+	subscriber sd.Subscriber
+	minHealthy int
+	// tiers contains the sets of hosts of the local zone and of the local region
+	tiers []map[string]struct{}
+}
+
+// NewZoneSubscriber returns a Subscriber preferring the hosts of the subscriber located in the
+// zone or the region of the config
+func NewZoneSubscriber(cfg Config, s sd.Subscriber) *Subscriber {
+	zone, region := map[string]struct{}{}, map[string]struct{}{}
+	for h, l := range cfg.Hosts {
+		if cfg.Zone != "" && l.Zone == cfg.Zone {
+			zone[h] = struct{}{}
+		}
+		if cfg.Region != "" && l.Region == cfg.Region {
+			region[h] = struct{}{}
+		}
+	}
+	tiers := []map[string]struct{}{}
+	for _, t := range []map[string]struct{}{zone, region} {
+		if len(t) > 0 {
+			tiers = append(tiers, t)
+		}
+	}
+	return &Subscriber{subscriber: s, minHealthy: cfg.MinHealthyPercent, tiers: tiers}
+}
+
+// NewSubscriber wraps the received subscriber with a Subscriber if the backend declares the zone
+// config
+func NewSubscriber(logger logging.Logger, remote *config.Backend, s sd.Subscriber) sd.Subscriber {
+	cfg, ok, err := ConfigGetter(remote.ExtraConfig)
+	if !ok {
+		return s
+	}
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][Zone]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	if err != nil {
+		logger.Error(logPrefix, err.Error())
+		return s
+	}
+	logger.Debug(logPrefix, fmt.Sprintf("Preferring the hosts of the zone %q and the region %q", cfg.Zone, cfg.Region))
+	return NewZoneSubscriber(cfg, s)
+}
+
+// Hosts implements the sd.Subscriber interface
+func (s *Subscriber) Hosts() ([]string, error) {
+	hosts, err := s.subscriber.Hosts()
+	if err != nil || len(hosts) == 0 {
+		return hosts, err
+	}
+	for _, tier := range s.tiers {
+		local := make([]string, 0, len(tier))
+		for _, h := range hosts {
+			if _, ok := tier[h]; ok {
+				local = append(local, h)
+			}
+		}
+		if len(local) > 0 && len(local)*100 >= s.minHealthy*len(tier) {
+			return local, nil
+		}
+	}
+	return hosts, nil
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package zone
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok, _ := ConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("unexpected config")
+	}
+
+	t.Setenv(ZoneEnvVar, "a")
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"region": "eu",
+		"hosts": map[string]interface{}{
+			"http://a": map[string]interface{}{"zone": "a", "region": "eu"},
+		},
+	}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result %v %v", ok, err)
+	}
+	if cfg.Zone != "a" || cfg.Region != "eu" || cfg.MinHealthyPercent != DefaultMinHealthyPercent {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	for _, raw := range []map[string]interface{}{
+		{"zone": "a"},
+		{"zone": "a", "hosts": map[string]interface{}{"http://a": map[string]interface{}{}}, "min_healthy_percent": 120},
+		{"zone": "a", "hosts": "nope"},
+	} {
+		if _, ok, err := ConfigGetter(config.ExtraConfig{Namespace: raw}); !ok || err == nil {
+			t.Errorf("%v: expecting an error", raw)
+		}
+	}
+}
+
+type hostsSubscriber struct{ hosts []string }
+
+func (h *hostsSubscriber) Hosts() ([]string, error) { return h.hosts, nil }
+
+func TestSubscriber(t *testing.T) {
+	cfg := Config{
+		Location: Location{Zone: "eu-1a", Region: "eu-1"},
+		Hosts: map[string]Location{
+			"http://a1": {Zone: "eu-1a", Region: "eu-1"},
+			"http://a2": {Zone: "eu-1a", Region: "eu-1"},
+			"http://b1": {Zone: "eu-1b", Region: "eu-1"},
+			"http://c1": {Zone: "us-1a", Region: "us-1"},
+		},
+		MinHealthyPercent: 50,
+	}
+	available := &hostsSubscriber{}
+	s := NewZoneSubscriber(cfg, available)
+
+	for i, tc := range []struct {
+		available []string
+		expected  []string
+	}{
+		{
+			available: []string{"http://a1", "http://a2", "http://b1", "http://c1", "http://d1"},
+			expected:  []string{"http://a1", "http://a2"},
+		},
+		{
+			available: []string{"http://a2", "http://b1", "http://c1"},
+			expected:  []string{"http://a2"},
+		},
+		{
+			available: []string{"http://b1", "http://c1"},
+			expected:  []string{"http://b1", "http://c1"},
+		},
+		{
+			available: []string{"http://c1", "http://d1"},
+			expected:  []string{"http://c1", "http://d1"},
+		},
+		{
+			available: []string{},
+			expected:  []string{},
+		},
+	} {
+		available.hosts = tc.available
+		hosts, err := s.Hosts()
+		if err != nil {
+			t.Errorf("#%d: unexpected error %v", i, err)
+		}
+		if !reflect.DeepEqual(hosts, tc.expected) {
+			t.Errorf("#%d: unexpected hosts %v", i, hosts)
+		}
+	}
+
+	cfg.MinHealthyPercent = 60
+	available.hosts = []string{"http://a2", "http://b1", "http://c1"}
+	if hosts, _ := NewZoneSubscriber(cfg, available).Hosts(); !reflect.DeepEqual(hosts, []string{"http://a2", "http://b1"}) {
+		t.Errorf("unexpected spillover %v", hosts)
+	}
+}
+
+func TestNewSubscriber(t *testing.T) {
+	s := sd.FixedSubscriber{"http://a"}
+	if res := NewSubscriber(logging.NoOp, &config.Backend{}, s); !reflect.DeepEqual(res, s) {
+		t.Error("the subscriber should not be wrapped")
+	}
+	if res := NewSubscriber(logging.NoOp, &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{}}}, s); !reflect.DeepEqual(res, s) {
+		t.Error("the invalid configs should be ignored")
+	}
+	res := NewSubscriber(logging.NoOp, &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"zone":  "a",
+		"hosts": map[string]interface{}{"http://a": map[string]interface{}{"zone": "a"}},
+	}}}, s)
+	if _, ok := res.(*Subscriber); !ok {
+		t.Errorf("unexpected subscriber %T", res)
+	}
+}
@@ -0,0 +1,261 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package sharedstore provides the configuration and the driver register for the key-value
+stores shared by all the instances of a gateway cluster (Redis and alike).
+
+The components requiring a shared store (cache, rate limit, idempotency...) read a single
+configuration block from the service extra config and delegate the connection to the driver
+registered for it, so topologies, TLS, authentication and pool tuning are defined just once.
+*/
+package sharedstore
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/register"
+	"github.com/luraproject/lura/v2/transport/http/server"
+)
+
+// Namespace is the key to use to store and access the shared store config
+const Namespace = "github.com/luraproject/lura/sharedstore"
+
+// Topology is the deployment mode of the shared store
+type Topology string
+
+const (
+	// Standalone is a single node (or a proxy in front of the nodes)
+	Standalone Topology = "standalone"
+	// Cluster is a sharded deployment where the client discovers the nodes from the seeds
+	Cluster Topology = "cluster"
+	// Sentinel is a replicated deployment where the sentinels point to the current master
+	Sentinel Topology = "sentinel"
+)
+
+var (
+	// ErrNoConfig is the error returned when the service does not declare a shared store
+	ErrNoConfig = errors.New("shared store not configured")
+	// ErrNoAddress is the error returned when the shared store config has no addresses
+	ErrNoAddress = errors.New("shared store: at least one address is required")
+	// ErrNoMasterName is the error returned when a sentinel topology has no master name
+	ErrNoMasterName = errors.New("shared store: the sentinel topology requires a master name")
+)
+
+// Config is the connection configuration of the shared store
+type Config struct {
+	// Driver is the name of the registered driver to use
+	Driver string
+	// Topology is the deployment mode of the store
+	Topology Topology
+	// Addresses are the host:port of the node, the cluster seeds or the sentinels
+	Addresses []string
+	// MasterName is the name of the master monitored by the sentinels
+	MasterName string
+	// Username and Password are the ACL credentials of the store
+	Username string
+	Password string
+	// SentinelUsername and SentinelPassword are the credentials of the sentinels, if different
+	SentinelUsername string
+	SentinelPassword string
+	// DB is the logical database to select. Not supported by the cluster topology
+	DB int
+	// TLS, if defined, enables TLS for the connections to the store
+	TLS *config.ClientTLS
+	// PoolSize is the max number of connections per node
+	PoolSize int
+	// MinIdleConns is the number of idle connections to keep open per node
+	MinIdleConns int
+	// MaxRetries is the number of retries before giving up a command
+	MaxRetries   int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	PoolTimeout  time.Duration
+	IdleTimeout  time.Duration
+}
+
+// TLSConfig returns the tls.Config to use for the connections to the store, or nil
+// if TLS is not enabled
+func (c Config) TLSConfig(logger logging.Logger) *tls.Config {
+	return server.ParseClientTLSConfigWithLogger(c.TLS, logger)
+}
+
+// Client is the minimal set of operations a shared store driver must support
+type Client interface {
+	// Get returns the value stored under the key. The flag is false if the key is missing
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores the value under the key for the given ttl. A ttl of 0 means no expiration
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Incr increments the counter stored under the key, setting its ttl if it is new, and
+	// returns the updated value
+	Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+	// Del removes the key
+	Del(ctx context.Context, key string) error
+	// Close releases the resources of the client
+	Close() error
+}
+
+// Driver creates clients for the received configuration
+type Driver func(ctx context.Context, cfg Config) (Client, error)
+
+// RegisterDriver adds a driver to the package register, so it can be selected by name
+// from the configuration
+func RegisterDriver(name string, d Driver) {
+	drivers.Register(name, d)
+}
+
+// New returns a client created by the driver selected in the configuration
+func New(ctx context.Context, cfg Config) (Client, error) {
+	v, ok := drivers.Get(cfg.Driver)
+	if !ok {
+		return nil, fmt.Errorf("shared store: unknown driver '%s'", cfg.Driver)
+	}
+	d, ok := v.(Driver)
+	if !ok {
+		return nil, fmt.Errorf("shared store: invalid driver '%s'", cfg.Driver)
+	}
+	return d(ctx, cfg)
+}
+
+// GetClient returns the client of the shared store declared in the service configuration.
+// The client is created just once and shared by all the components requesting it.
+func GetClient(ctx context.Context, e config.ExtraConfig) (Client, error) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if sharedClient != nil {
+		return sharedClient, nil
+	}
+	cfg, err := ConfigGetter(e)
+	if err != nil {
+		return nil, err
+	}
+	c, err := New(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	sharedClient = c
+	return c, nil
+}
+
+// ConfigGetter parses the shared store configuration from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, error) {
+	cfg := Config{}
+	v, ok := e[Namespace]
+	if !ok {
+		return cfg, ErrNoConfig
+	}
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return cfg, ErrNoConfig
+	}
+
+	cfg.Driver, _ = tmp["driver"].(string)
+	if cfg.Driver == "" {
+		cfg.Driver = "redis"
+	}
+	topology, _ := tmp["topology"].(string)
+	cfg.Topology = Topology(strings.ToLower(topology))
+	if cfg.Topology == "" {
+		cfg.Topology = Standalone
+	}
+
+	if addrs, ok := tmp["addresses"].([]interface{}); ok {
+		for _, a := range addrs {
+			if s, ok := a.(string); ok && s != "" {
+				cfg.Addresses = append(cfg.Addresses, s)
+			}
+		}
+	}
+	if a, ok := tmp["address"].(string); ok && a != "" {
+		cfg.Addresses = append(cfg.Addresses, a)
+	}
+
+	cfg.MasterName, _ = tmp["master_name"].(string)
+	cfg.Username, _ = tmp["username"].(string)
+	cfg.Password, _ = tmp["password"].(string)
+	cfg.SentinelUsername, _ = tmp["sentinel_username"].(string)
+	cfg.SentinelPassword, _ = tmp["sentinel_password"].(string)
+	cfg.DB = getInt(tmp["db"])
+
+	if pool, ok := tmp["pool"].(map[string]interface{}); ok {
+		cfg.PoolSize = getInt(pool["size"])
+		cfg.MinIdleConns = getInt(pool["min_idle_conns"])
+		cfg.MaxRetries = getInt(pool["max_retries"])
+		cfg.DialTimeout = getDuration(pool["dial_timeout"])
+		cfg.ReadTimeout = getDuration(pool["read_timeout"])
+		cfg.WriteTimeout = getDuration(pool["write_timeout"])
+		cfg.PoolTimeout = getDuration(pool["pool_timeout"])
+		cfg.IdleTimeout = getDuration(pool["idle_timeout"])
+	}
+
+	if t, ok := tmp["tls"]; ok {
+		tlsCfg, err := config.ParseClientTLS(t)
+		if err != nil {
+			return cfg, fmt.Errorf("shared store: invalid tls config: %s", err.Error())
+		}
+		cfg.TLS = tlsCfg
+	}
+
+	return cfg, cfg.validate()
+}
+
+func (c Config) validate() error {
+	if len(c.Addresses) == 0 {
+		return ErrNoAddress
+	}
+	switch c.Topology {
+	case Standalone:
+	case Cluster:
+		if c.DB != 0 {
+			return errors.New("shared store: the cluster topology does not support database selection")
+		}
+	case Sentinel:
+		if c.MasterName == "" {
+			return ErrNoMasterName
+		}
+	default:
+		return fmt.Errorf("shared store: unknown topology '%s'", c.Topology)
+	}
+	if c.PoolSize < 0 || c.MinIdleConns < 0 {
+		return errors.New("shared store: the pool sizes can not be negative")
+	}
+	return nil
+}
+
+func getInt(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	case int64:
+		return int(n)
+	}
+	return 0
+}
+
+func getDuration(v interface{}) time.Duration {
+	s, ok := v.(string)
+	if !ok {
+		return 0
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+var (
+	drivers      = register.NewUntyped()
+	sharedMu     sync.Mutex
+	sharedClient Client
+)
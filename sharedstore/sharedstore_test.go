@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sharedstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestConfigGetter(t *testing.T) {
+	cfg, err := ConfigGetter(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"topology":    "Sentinel",
+			"addresses":   []interface{}{"10.0.0.1:26379", "10.0.0.2:26379"},
+			"master_name": "mymaster",
+			"username":    "gateway",
+			"password":    "secret",
+			"db":          2.0,
+			"pool": map[string]interface{}{
+				"size":           20.0,
+				"min_idle_conns": 5.0,
+				"dial_timeout":   "2s",
+				"read_timeout":   "500ms",
+			},
+			"tls": map[string]interface{}{
+				"server_name":  "redis.internal",
+				"ca_certs":     []interface{}{"ca.pem"},
+				"client_certs": []interface{}{map[string]interface{}{"certificate": "cert.pem", "private_key": "key.pem"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if cfg.Driver != "redis" || cfg.Topology != Sentinel || cfg.MasterName != "mymaster" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if len(cfg.Addresses) != 2 || cfg.Username != "gateway" || cfg.Password != "secret" || cfg.DB != 2 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if cfg.PoolSize != 20 || cfg.MinIdleConns != 5 || cfg.DialTimeout != 2*time.Second || cfg.ReadTimeout != 500*time.Millisecond {
+		t.Errorf("unexpected pool config: %+v", cfg)
+	}
+	if cfg.TLS == nil || cfg.TLS.ServerName != "redis.internal" || len(cfg.TLS.ClientCerts) != 1 {
+		t.Errorf("unexpected tls config: %+v", cfg.TLS)
+	}
+}
+
+func TestConfigGetter_ko(t *testing.T) {
+	for i, tc := range []struct {
+		cfg map[string]interface{}
+		err error
+	}{
+		{cfg: map[string]interface{}{}, err: ErrNoAddress},
+		{cfg: map[string]interface{}{"topology": "sentinel", "address": "localhost:26379"}, err: ErrNoMasterName},
+		{cfg: map[string]interface{}{"topology": "cluster", "address": "localhost:6379", "db": 1.0}},
+		{cfg: map[string]interface{}{"topology": "ring", "address": "localhost:6379"}},
+	} {
+		_, err := ConfigGetter(config.ExtraConfig{Namespace: tc.cfg})
+		if err == nil {
+			t.Errorf("#%d: error expected", i)
+			continue
+		}
+		if tc.err != nil && err != tc.err {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+	}
+
+	if _, err := ConfigGetter(config.ExtraConfig{}); err != ErrNoConfig {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestGetClient(t *testing.T) {
+	var created int
+	RegisterDriver("test", func(_ context.Context, cfg Config) (Client, error) {
+		created++
+		if cfg.Topology != Cluster {
+			return nil, errors.New("unexpected topology")
+		}
+		return dummyClient{}, nil
+	})
+
+	if _, err := New(context.Background(), Config{Driver: "unknown"}); err == nil {
+		t.Error("error expected")
+	}
+
+	e := config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"driver":    "test",
+			"topology":  "cluster",
+			"addresses": []interface{}{"localhost:7000", "localhost:7001"},
+		},
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := GetClient(context.Background(), e); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if created != 1 {
+		t.Errorf("the shared client should be created just once. created: %d", created)
+	}
+}
+
+type dummyClient struct{}
+
+func (dummyClient) Get(_ context.Context, _ string) ([]byte, bool, error) { return nil, false, nil }
+func (dummyClient) Set(_ context.Context, _ string, _ []byte, _ time.Duration) error {
+	return nil
+}
+func (dummyClient) Incr(_ context.Context, _ string, _ int64, _ time.Duration) (int64, error) {
+	return 0, nil
+}
+func (dummyClient) Del(_ context.Context, _ string) error { return nil }
+func (dummyClient) Close() error                          { return nil }
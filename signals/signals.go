@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package signals provides runtime control of a running gateway through OS signals.
+
+By default, SIGHUP triggers a config reload, SIGUSR1 dumps the goroutine and heap profiles to
+a directory and SIGUSR2 toggles the debug logging. The user signals are not available on
+windows, so only the reload is supported there.
+*/
+package signals
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+// Namespace is the key to use to store and access the signals config
+const Namespace = "github.com/luraproject/lura/signals"
+
+const logPrefix = "[SERVICE: Signals]"
+
+// Handler is executed every time the process receives the signal it is registered for
+type Handler func(context.Context, os.Signal)
+
+// New returns a Controller without handlers
+func New(logger logging.Logger) *Controller {
+	if logger == nil {
+		logger = logging.NoOp
+	}
+	return &Controller{
+		logger:   logger,
+		handlers: map[os.Signal][]Handler{},
+		mu:       new(sync.Mutex),
+	}
+}
+
+// Controller dispatches the received signals to their handlers
+type Controller struct {
+	logger   logging.Logger
+	handlers map[os.Signal][]Handler
+	mu       *sync.Mutex
+}
+
+// Handle registers a handler for the signal. Nil signals are ignored, so the platform
+// dependent signals can be used without build tags
+func (c *Controller) Handle(sig os.Signal, h Handler) {
+	if sig == nil || h == nil {
+		return
+	}
+	c.mu.Lock()
+	c.handlers[sig] = append(c.handlers[sig], h)
+	c.mu.Unlock()
+}
+
+// Run listens for the registered signals until the context is cancelled. The handlers are
+// executed sequentially, in the order they were registered
+func (c *Controller) Run(ctx context.Context) {
+	c.mu.Lock()
+	sigs := make([]os.Signal, 0, len(c.handlers))
+	for sig := range c.handlers {
+		sigs = append(sigs, sig)
+	}
+	c.mu.Unlock()
+	if len(sigs) == 0 {
+		return
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	defer signal.Stop(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-ch:
+			c.dispatch(ctx, sig)
+		}
+	}
+}
+
+func (c *Controller) dispatch(ctx context.Context, sig os.Signal) {
+	c.mu.Lock()
+	handlers := c.handlers[sig]
+	c.mu.Unlock()
+	c.logger.Debug(logPrefix, "Signal received:", sig.String())
+	for _, h := range handlers {
+		h(ctx, sig)
+	}
+}
+
+// Config contains the handlers to register with the Register function
+type Config struct {
+	// Reload is called on SIGHUP
+	Reload func(context.Context) error
+	// ProfileDir is the folder where the profiles are dumped on SIGUSR1
+	ProfileDir string
+	// DebugSwitch is toggled on SIGUSR2
+	DebugSwitch *logging.DebugSwitch
+}
+
+// ConfigGetter parses the signals configuration from the service extra config. The handlers
+// depending on runtime components (Reload and DebugSwitch) must be set by the caller
+func ConfigGetter(e config.ExtraConfig) (Config, bool) {
+	cfg := Config{}
+	v, ok := e[Namespace]
+	if !ok {
+		return cfg, false
+	}
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return cfg, false
+	}
+	cfg.ProfileDir, _ = tmp["profile_dir"].(string)
+	return cfg, true
+}
+
+// Register adds the default handlers to the controller, for the features enabled in the config
+func Register(c *Controller, cfg Config) {
+	if cfg.Reload != nil {
+		c.Handle(ReloadSignal, ReloadHandler(cfg.Reload, c.logger))
+	}
+	if cfg.ProfileDir != "" {
+		c.Handle(ProfileSignal, ProfileHandler(cfg.ProfileDir, c.logger))
+	}
+	if cfg.DebugSwitch != nil {
+		c.Handle(DebugSignal, DebugHandler(cfg.DebugSwitch, c.logger))
+	}
+}
+
+// ReloadHandler returns a Handler executing the reload function
+func ReloadHandler(reload func(context.Context) error, logger logging.Logger) Handler {
+	return func(ctx context.Context, _ os.Signal) {
+		if err := reload(ctx); err != nil {
+			logger.Error(logPrefix, "Unable to reload the configuration:", err.Error())
+			return
+		}
+		logger.Info(logPrefix, "Configuration reloaded")
+	}
+}
+
+// ProfileHandler returns a Handler dumping the goroutine and heap profiles into the folder
+func ProfileHandler(dir string, logger logging.Logger) Handler {
+	return func(_ context.Context, _ os.Signal) {
+		files, err := DumpProfiles(dir, time.Now())
+		if err != nil {
+			logger.Error(logPrefix, "Unable to dump the profiles:", err.Error())
+			return
+		}
+		logger.Info(logPrefix, "Profiles dumped:", files)
+	}
+}
+
+// DebugHandler returns a Handler toggling the debug mode of the logger
+func DebugHandler(s *logging.DebugSwitch, logger logging.Logger) Handler {
+	return func(_ context.Context, _ os.Signal) {
+		logger.Info(logPrefix, fmt.Sprintf("Debug logging enabled: %t", s.Toggle()))
+	}
+}
+
+// DumpProfiles writes the goroutine and heap profiles into the folder and returns the paths
+// of the created files
+func DumpProfiles(dir string, now time.Time) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	ts := now.Format("20060102T150405")
+	files := make([]string, 0, 2)
+	for _, name := range []string{"goroutine", "heap"} {
+		path := filepath.Join(dir, fmt.Sprintf("%s-%s.pprof", name, ts))
+		if err := writeProfile(name, path); err != nil {
+			return files, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+func writeProfile(name, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := pprof.Lookup(name).WriteTo(f, 0); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,81 @@
+//go:build !windows
+// +build !windows
+
+// SPDX-License-Identifier: Apache-2.0
+
+package signals
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestController(t *testing.T) {
+	buff := new(bytes.Buffer)
+	logger, _ := logging.NewLogger("INFO", buff, "")
+	dir := t.TempDir()
+	debugSwitch := logging.NewBasicDebugSwitch(logger)
+
+	reloaded := make(chan struct{}, 1)
+	cfg, ok := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"profile_dir": dir}})
+	if !ok {
+		t.Error("the config should be parsed")
+		return
+	}
+	cfg.Reload = func(_ context.Context) error {
+		reloaded <- struct{}{}
+		return nil
+	}
+	cfg.DebugSwitch = debugSwitch
+
+	c := New(logger)
+	Register(c, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+	time.Sleep(10 * time.Millisecond)
+
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Error("the config should have been reloaded")
+	}
+
+	syscall.Kill(os.Getpid(), syscall.SIGUSR2)
+	for i := 0; i < 100 && !debugSwitch.IsDebugEnabled(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !debugSwitch.IsDebugEnabled() {
+		t.Error("the debug mode should have been enabled")
+	}
+
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+	var files []string
+	for i := 0; i < 100 && len(files) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+		files, _ = filepath.Glob(filepath.Join(dir, "*.pprof"))
+	}
+	if len(files) != 2 {
+		t.Errorf("unexpected profiles: %v", files)
+	}
+}
+
+func TestReloadHandler(t *testing.T) {
+	buff := new(bytes.Buffer)
+	logger, _ := logging.NewLogger("INFO", buff, "")
+	ReloadHandler(func(_ context.Context) error { return errors.New("boom") }, logger)(context.Background(), syscall.SIGHUP)
+	if !bytes.Contains(buff.Bytes(), []byte("Unable to reload the configuration: boom")) {
+		t.Errorf("unexpected output: %s", buff.String())
+	}
+}
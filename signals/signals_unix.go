@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+// SPDX-License-Identifier: Apache-2.0
+
+package signals
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	// ReloadSignal triggers the reload of the configuration
+	ReloadSignal os.Signal = syscall.SIGHUP
+	// ProfileSignal triggers the dump of the profiles
+	ProfileSignal os.Signal = syscall.SIGUSR1
+	// DebugSignal toggles the debug logging
+	DebugSignal os.Signal = syscall.SIGUSR2
+)
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+// SPDX-License-Identifier: Apache-2.0
+
+package signals
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	// ReloadSignal triggers the reload of the configuration
+	ReloadSignal os.Signal = syscall.SIGHUP
+	// ProfileSignal is not available on windows
+	ProfileSignal os.Signal
+	// DebugSignal is not available on windows
+	DebugSignal os.Signal
+)
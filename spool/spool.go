@@ -0,0 +1,512 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package spool decouples the sinks of the access, audit and analytics records from the request
+handling: the records are buffered in memory and delivered to the sink by a background worker, so a
+slow collector never blocks the handlers.
+
+The memory buffer is bounded. When it is full, the records spill to a file of the spill dir, if
+declared, and they are delivered once the memory backlog is sent. The records are only rejected
+when both buffers are full, and the writes rejected return ErrBufferFull, so the callers can
+report them:
+
+	"buffer": {
+		"max_memory": 4194304,
+		"spill_dir": "/var/spool/gateway/access",
+		"max_disk": 268435456,
+		"max_retries": 5,
+		"retry_backoff": "100ms",
+		"close_timeout": "5s"
+	}
+
+The failed deliveries are retried with an exponential backoff. The records still failing after the
+retries are kept in the spill file when there is one, and dropped and logged otherwise. The records
+pending when the writer is closed are spilled too, and a new writer over the same dir delivers them,
+so the records are delivered at least once.
+*/
+package spool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const (
+	// DefaultMaxMemory is the size of the memory buffer when the config does not declare it
+	DefaultMaxMemory = 4 << 20
+	// DefaultMaxDisk is the size of the spill file when the config does not declare it
+	DefaultMaxDisk = 256 << 20
+	// DefaultMaxRetries is the number of retries of a failed delivery when the config does not
+	// declare it
+	DefaultMaxRetries = 5
+	// DefaultRetryBackoff is the wait before the first retry when the config does not declare it
+	DefaultRetryBackoff = 100 * time.Millisecond
+	// DefaultCloseTimeout is the time the pending records have to be delivered when the writer is
+	// closed, if the config does not declare it
+	DefaultCloseTimeout = 5 * time.Second
+
+	// SpillFile is the name of the spill file in the spill dir
+	SpillFile = "spool.dat"
+
+	maxBackoff   = 30 * time.Second
+	maxBatchSize = 64 << 10
+)
+
+var (
+	// ErrBufferFull is returned by the writes rejected because the buffers are full
+	ErrBufferFull = errors.New("spool: buffer full")
+	// ErrClosed is returned by the writes after the writer is closed
+	ErrClosed = errors.New("spool: closed")
+)
+
+// Config defines the buffers and the delivery of a Writer
+type Config struct {
+	MaxMemory    int    `json:"max_memory"`
+	SpillDir     string `json:"spill_dir"`
+	MaxDisk      int64  `json:"max_disk"`
+	MaxRetries   *int   `json:"max_retries"`
+	RetryBackoff string `json:"retry_backoff"`
+	CloseTimeout string `json:"close_timeout"`
+}
+
+// Stats is a snapshot of the counters of a Writer
+type Stats struct {
+	Buffered  int64  `json:"buffered"`
+	Spilled   int64  `json:"spilled"`
+	Delivered uint64 `json:"delivered"`
+	Retried   uint64 `json:"retried"`
+	Dropped   uint64 `json:"dropped"`
+	Rejected  uint64 `json:"rejected"`
+}
+
+// Writer is an io.Writer buffering the records and delivering them to its sink in the background.
+// Every write is a record, so they are never split or merged with the others
+type Writer struct {
+	out          io.Writer
+	logger       logging.Logger
+	maxMemory    int
+	maxRetries   int
+	backoff      time.Duration
+	closeTimeout time.Duration
+
+	mu       sync.Mutex
+	queue    [][]byte
+	memBytes int
+	inflight [][]byte
+	spill    *spillFile
+	closed   bool
+	finished bool
+
+	wake    chan struct{}
+	done    chan struct{}
+	abort   chan struct{}
+	stopped chan struct{}
+
+	delivered, retried, dropped, rejected uint64
+}
+
+// New returns a Writer delivering the records to out
+func New(out io.Writer, cfg Config, logger logging.Logger) (*Writer, error) {
+	w := &Writer{
+		out:          out,
+		logger:       logger,
+		maxMemory:    cfg.MaxMemory,
+		maxRetries:   DefaultMaxRetries,
+		backoff:      DefaultRetryBackoff,
+		closeTimeout: DefaultCloseTimeout,
+		wake:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+		abort:        make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+	if w.maxMemory <= 0 {
+		w.maxMemory = DefaultMaxMemory
+	}
+	if cfg.MaxRetries != nil {
+		if *cfg.MaxRetries < 0 {
+			return nil, fmt.Errorf("spool: invalid max retries %d", *cfg.MaxRetries)
+		}
+		w.maxRetries = *cfg.MaxRetries
+	}
+	var err error
+	if w.backoff, err = parseDuration(cfg.RetryBackoff, DefaultRetryBackoff); err != nil {
+		return nil, err
+	}
+	if w.closeTimeout, err = parseDuration(cfg.CloseTimeout, DefaultCloseTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.SpillDir != "" {
+		maxDisk := cfg.MaxDisk
+		if maxDisk <= 0 {
+			maxDisk = DefaultMaxDisk
+		}
+		if w.spill, err = openSpillFile(filepath.Join(cfg.SpillDir, SpillFile), maxDisk); err != nil {
+			return nil, err
+		}
+	}
+
+	go w.loop()
+	if w.spill != nil && w.spill.pending() > 0 {
+		w.signal()
+	}
+	return w, nil
+}
+
+func parseDuration(s string, d time.Duration) (time.Duration, error) {
+	if s == "" {
+		return d, nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("spool: invalid duration %s", s)
+	}
+	return v, nil
+}
+
+// Write buffers a copy of the record. It never waits for the sink
+func (w *Writer) Write(p []byte) (int, error) {
+	rec := make([]byte, len(p))
+	copy(rec, p)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	// once the records spill, the next ones follow them, so the order is kept
+	if (w.spill == nil || w.spill.pending() == 0) && w.memBytes+len(rec) <= w.maxMemory {
+		w.queue = append(w.queue, rec)
+		w.memBytes += len(rec)
+		w.signal()
+		return len(p), nil
+	}
+	if w.spill != nil && w.spill.append(rec) == nil {
+		w.signal()
+		return len(p), nil
+	}
+	atomic.AddUint64(&w.rejected, 1)
+	return 0, ErrBufferFull
+}
+
+// Stats returns a snapshot of the counters
+func (w *Writer) Stats() Stats {
+	w.mu.Lock()
+	s := Stats{Buffered: int64(w.memBytes)}
+	if w.spill != nil {
+		s.Spilled = w.spill.pending()
+	}
+	w.mu.Unlock()
+	s.Delivered = atomic.LoadUint64(&w.delivered)
+	s.Retried = atomic.LoadUint64(&w.retried)
+	s.Dropped = atomic.LoadUint64(&w.dropped)
+	s.Rejected = atomic.LoadUint64(&w.rejected)
+	return s
+}
+
+// Close stops accepting records and waits for the pending ones to be delivered, up to the close
+// timeout. The records not delivered by then are spilled, or dropped if there is no spill file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+	close(w.done)
+
+	select {
+	case <-w.stopped:
+	case <-time.After(w.closeTimeout):
+		close(w.abort)
+		// give the worker the chance to leave a blocked retry
+		select {
+		case <-w.stopped:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.finished = true
+	pending := append(w.inflight, w.queue...)
+	w.inflight, w.queue, w.memBytes = nil, nil, 0
+	lost := 0
+	for _, rec := range pending {
+		if w.spill == nil || w.spill.append(rec) != nil {
+			lost++
+		}
+	}
+	if lost > 0 {
+		atomic.AddUint64(&w.dropped, uint64(lost))
+		w.logger.Error("[SERVICE: Spool]", fmt.Sprintf("%d records not delivered before closing", lost))
+	}
+	if w.spill != nil {
+		return w.spill.close()
+	}
+	return nil
+}
+
+func (w *Writer) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Writer) loop() {
+	defer close(w.stopped)
+	for {
+		select {
+		case <-w.done:
+			// the spilled records survive the writer, so only the memory ones must be delivered
+			if w.memoryDrained() {
+				return
+			}
+		default:
+		}
+
+		batch, fromDisk, size := w.next()
+		if batch == nil {
+			select {
+			case <-w.wake:
+			case <-w.done:
+			}
+			continue
+		}
+
+		delivered, aborted := w.deliver(batch)
+		w.mu.Lock()
+		if aborted || w.finished {
+			w.mu.Unlock()
+			return
+		}
+		w.commit(fromDisk, size, delivered)
+		w.mu.Unlock()
+
+		if !delivered && fromDisk {
+			// the sink is down, so the spilled records wait before the next round of retries
+			select {
+			case <-time.After(maxBackoff):
+			case <-w.done:
+			}
+		}
+	}
+}
+
+func (w *Writer) memoryDrained() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.queue) == 0 && len(w.inflight) == 0
+}
+
+// next takes the next batch to deliver: the memory records first and, once they are delivered,
+// the spilled ones
+func (w *Writer) next() ([]byte, bool, int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.inflight) > 0 {
+		return join(w.inflight), false, len(w.inflight)
+	}
+	if len(w.queue) > 0 {
+		n, size := 0, 0
+		for n < len(w.queue) && (n == 0 || size+len(w.queue[n]) <= maxBatchSize) {
+			size += len(w.queue[n])
+			n++
+		}
+		w.inflight = w.queue[:n:n]
+		w.queue = w.queue[n:]
+		return join(w.inflight), false, n
+	}
+	if w.spill == nil || w.spill.pending() == 0 {
+		return nil, false, 0
+	}
+	rec, n, err := w.spill.next()
+	if err != nil {
+		w.logger.Error("[SERVICE: Spool]", "Discarding the unreadable spill file:", err.Error())
+		w.spill.reset()
+		return nil, false, 0
+	}
+	return rec, true, n
+}
+
+func join(records [][]byte) []byte {
+	if len(records) == 1 {
+		return records[0]
+	}
+	size := 0
+	for _, r := range records {
+		size += len(r)
+	}
+	b := make([]byte, 0, size)
+	for _, r := range records {
+		b = append(b, r...)
+	}
+	return b
+}
+
+// commit releases the delivered batch. It must be called with the mu locked
+func (w *Writer) commit(fromDisk bool, n int, delivered bool) {
+	records := n
+	if fromDisk {
+		records = 1
+		if !delivered {
+			// the record stays in the spill file, waiting for the sink to recover
+			return
+		}
+		w.spill.advance(int64(n))
+	} else {
+		for _, rec := range w.inflight {
+			w.memBytes -= len(rec)
+		}
+		if !delivered && w.spill != nil {
+			lost := 0
+			for _, rec := range w.inflight {
+				if w.spill.append(rec) != nil {
+					lost++
+				}
+			}
+			w.inflight = nil
+			if lost > 0 {
+				atomic.AddUint64(&w.dropped, uint64(lost))
+				w.logger.Error("[SERVICE: Spool]", fmt.Sprintf("Dropping %d records: the sink and the spill file are full", lost))
+			}
+			return
+		}
+		w.inflight = nil
+	}
+	if delivered {
+		atomic.AddUint64(&w.delivered, uint64(records))
+		return
+	}
+	atomic.AddUint64(&w.dropped, uint64(records))
+	w.logger.Error("[SERVICE: Spool]", fmt.Sprintf("Dropping %d records after %d retries", records, w.maxRetries))
+}
+
+// deliver writes the batch to the sink, retrying the failures with an exponential backoff
+func (w *Writer) deliver(batch []byte) (delivered, aborted bool) {
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		_, err := w.out.Write(batch)
+		if err == nil {
+			return true, false
+		}
+		if attempt >= w.maxRetries {
+			w.logger.Warning("[SERVICE: Spool]", "Unable to deliver the records:", err.Error())
+			return false, false
+		}
+		atomic.AddUint64(&w.retried, 1)
+		select {
+		case <-time.After(backoff):
+		case <-w.abort:
+			return false, true
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// spillFile stores the records as a sequence of length prefixed blobs. The delivered records are
+// skipped with the read offset, and the file is truncated once all of them are delivered
+type spillFile struct {
+	f       *os.File
+	readOff int64
+	size    int64
+	max     int64
+}
+
+func openSpillFile(path string, max int64) (*spillFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("spool: creating the spill dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("spool: opening the spill file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("spool: opening the spill file: %w", err)
+	}
+	return &spillFile{f: f, size: info.Size(), max: max}, nil
+}
+
+func (s *spillFile) pending() int64 { return s.size - s.readOff }
+
+func (s *spillFile) append(rec []byte) error {
+	if s.size+4+int64(len(rec)) > s.max {
+		return ErrBufferFull
+	}
+	b := make([]byte, 4+len(rec))
+	binary.BigEndian.PutUint32(b, uint32(len(rec)))
+	copy(b[4:], rec)
+	if _, err := s.f.WriteAt(b, s.size); err != nil {
+		return err
+	}
+	s.size += int64(len(b))
+	return nil
+}
+
+// next reads the first pending record and returns it with its size in the file
+func (s *spillFile) next() ([]byte, int, error) {
+	var header [4]byte
+	if _, err := s.f.ReadAt(header[:], s.readOff); err != nil {
+		return nil, 0, err
+	}
+	n := int64(binary.BigEndian.Uint32(header[:]))
+	if s.readOff+4+n > s.size {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	rec := make([]byte, n)
+	if _, err := s.f.ReadAt(rec, s.readOff+4); err != nil {
+		return nil, 0, err
+	}
+	return rec, int(4 + n), nil
+}
+
+func (s *spillFile) advance(n int64) {
+	if s.readOff += n; s.readOff >= s.size {
+		s.reset()
+	}
+}
+
+func (s *spillFile) reset() {
+	s.f.Truncate(0)
+	s.readOff, s.size = 0, 0
+}
+
+// close keeps the pending records, so the next writer delivers them
+func (s *spillFile) close() error {
+	if s.pending() == 0 {
+		s.reset()
+	} else if s.readOff > 0 {
+		if err := s.compact(); err != nil {
+			s.f.Close()
+			return err
+		}
+	}
+	return s.f.Close()
+}
+
+// compact moves the pending records to the head of the file
+func (s *spillFile) compact() error {
+	b := make([]byte, s.pending())
+	if _, err := s.f.ReadAt(b, s.readOff); err != nil {
+		return err
+	}
+	if _, err := s.f.WriteAt(b, 0); err != nil {
+		return err
+	}
+	s.readOff, s.size = 0, int64(len(b))
+	return s.f.Truncate(s.size)
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package spool
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/logging"
+)
+
+type sink struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	fail    int
+	blocked chan struct{}
+}
+
+func (s *sink) Write(p []byte) (int, error) {
+	if s.blocked != nil {
+		<-s.blocked
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail > 0 {
+		s.fail--
+		return 0, errors.New("collector unavailable")
+	}
+	return s.buf.Write(p)
+}
+
+func (s *sink) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+func waitFor(t *testing.T, f func() bool) {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		if f() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("timeout")
+}
+
+func TestWriter_memory(t *testing.T) {
+	out := &sink{fail: 1}
+	w, err := New(out, Config{RetryBackoff: "1ms"}, logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"a\n", "b\n", "c\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, func() bool { return out.String() == "a\nb\nc\n" })
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+	if s := w.Stats(); s.Delivered != 3 || s.Retried != 1 || s.Dropped != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	if _, err := w.Write([]byte("d\n")); err != ErrClosed {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestWriter_spill(t *testing.T) {
+	dir := t.TempDir()
+	out := &sink{blocked: make(chan struct{})}
+	w, err := New(out, Config{MaxMemory: 4, SpillDir: dir, MaxDisk: 20}, logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the slow sink does not block the writes: the first records wait in memory and the rest spill
+	done := make(chan struct{})
+	go func() {
+		for _, line := range []string{"a\n", "b\n", "c\n", "d\n"} {
+			if _, err := w.Write([]byte(line)); err != nil {
+				t.Error(err)
+			}
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the writes are blocked by the sink")
+	}
+	if _, err := w.Write([]byte("too large for the spill file\n")); err != ErrBufferFull {
+		t.Errorf("unexpected error %v", err)
+	}
+	if s := w.Stats(); s.Spilled == 0 || s.Rejected != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	close(out.blocked)
+	waitFor(t, func() bool { return out.String() == "a\nb\nc\nd\n" })
+	w.Close()
+}
+
+func TestWriter_closeSpillsThePendingRecords(t *testing.T) {
+	dir := t.TempDir()
+	retries := 0
+	out := &sink{fail: 1000}
+	w, err := New(out, Config{SpillDir: dir, MaxRetries: &retries, CloseTimeout: "20ms"}, logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("a\n"))
+	w.Write([]byte("b\n"))
+	waitFor(t, func() bool { return w.Stats().Spilled > 0 })
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, SpillFile)); err != nil || info.Size() == 0 {
+		t.Fatalf("the records have not been spilled: %v", err)
+	}
+
+	recovered := &sink{}
+	w, err = New(recovered, Config{SpillDir: dir}, logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		s := recovered.String()
+		return strings.Contains(s, "a\n") && strings.Contains(s, "b\n")
+	})
+	w.Close()
+	if info, _ := os.Stat(filepath.Join(dir, SpillFile)); info.Size() != 0 {
+		t.Error("the delivered records must be removed from the spill file")
+	}
+}
+
+func TestWriter_dropWithoutSpill(t *testing.T) {
+	retries := 1
+	out := &sink{fail: 2}
+	w, _ := New(out, Config{MaxRetries: &retries, RetryBackoff: "1ms"}, logging.NoOp)
+	w.Write([]byte("a\n"))
+	waitFor(t, func() bool { return w.Stats().Dropped == 1 })
+	w.Write([]byte("b\n"))
+	waitFor(t, func() bool { return out.String() == "b\n" })
+	w.Close()
+}
+
+func TestNew_invalid(t *testing.T) {
+	retries := -1
+	for _, cfg := range []Config{
+		{MaxRetries: &retries},
+		{RetryBackoff: "soon"},
+		{CloseTimeout: "-1s"},
+	} {
+		if _, err := New(&sink{}, cfg, logging.NoOp); err == nil {
+			t.Errorf("%+v: error expected", cfg)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package telemetry collects a small set of internal stats of the gateway and exposes them as a
+point-in-time JSON snapshot, useful for the incident triage when the full metrics stack is not
+available.
+*/
+package telemetry
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/luraproject/lura/v2/cache"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/register"
+	"github.com/luraproject/lura/v2/sd"
+)
+
+// Namespace is the key to use to store and access the telemetry config
+const Namespace = "github.com/luraproject/lura/telemetry"
+
+// DefaultPath is the path of the snapshot endpoint used when the config does not declare one
+const DefaultPath = "/__stats"
+
+// Window is the period used to calculate the request rates
+const Window = 10 * time.Second
+
+// Config defines the snapshot endpoint
+type Config struct {
+	Path string
+}
+
+// ConfigGetter parses the telemetry config from the service extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool) {
+	cfg := Config{Path: DefaultPath}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false
+	}
+	if p, ok := tmp["path"].(string); ok && p != "" {
+		cfg.Path = p
+	}
+	return cfg, true
+}
+
+var enabled int32
+
+// Register enables the collection of the endpoint stats if the service config requires it
+func Register(cfg config.ServiceConfig) (Config, bool) {
+	c, ok := ConfigGetter(cfg.ExtraConfig)
+	if ok {
+		atomic.StoreInt32(&enabled, 1)
+	}
+	return c, ok
+}
+
+// Enabled returns true if the endpoint stats should be collected
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
+
+// EndpointSnapshot contains the stats of an endpoint
+type EndpointSnapshot struct {
+	Requests      uint64  `json:"requests"`
+	Errors        uint64  `json:"errors"`
+	Incomplete    uint64  `json:"incomplete"`
+	RPS           float64 `json:"rps"`
+	ErrorRate     float64 `json:"error_rate"`
+	LatencyAvgMs  float64 `json:"latency_avg_ms"`
+	LatencyMaxMs  float64 `json:"latency_max_ms"`
+	LatencyLastMs float64 `json:"latency_last_ms"`
+}
+
+// BackendSnapshot contains the hosts of a backend
+type BackendSnapshot struct {
+	Endpoint string   `json:"endpoint"`
+	Method   string   `json:"method"`
+	Backend  string   `json:"backend"`
+	SD       string   `json:"sd"`
+	Hosts    []string `json:"hosts"`
+}
+
+// RuntimeSnapshot contains a few stats of the go runtime
+type RuntimeSnapshot struct {
+	Goroutines int    `json:"goroutines"`
+	HeapAlloc  uint64 `json:"heap_alloc"`
+	HeapInuse  uint64 `json:"heap_inuse"`
+	NumGC      uint32 `json:"num_gc"`
+}
+
+// Snapshot is a point-in-time view of the internal stats of the gateway
+type Snapshot struct {
+	Time      time.Time                   `json:"time"`
+	Uptime    string                      `json:"uptime"`
+	Endpoints map[string]EndpointSnapshot `json:"endpoints"`
+	Backends  []BackendSnapshot           `json:"backends"`
+	Caches    map[string]cache.Stats      `json:"caches"`
+	States    map[string]interface{}      `json:"states"`
+	Runtime   RuntimeSnapshot             `json:"runtime"`
+}
+
+// StateProvider returns the current state of a component (i.e. a circuit breaker)
+type StateProvider func() interface{}
+
+var states = register.NewUntyped()
+
+// RegisterState adds a component state to the snapshots. Components like circuit breakers
+// should register their state providers here, so their state is part of the snapshots
+func RegisterState(name string, p StateProvider) {
+	states.Register(name, p)
+}
+
+// States returns the current state of all the registered components
+func States() map[string]interface{} {
+	res := map[string]interface{}{}
+	for name, v := range states.Clone() {
+		if p, ok := v.(StateProvider); ok {
+			res[name] = p()
+		}
+	}
+	return res
+}
+
+// Collector accumulates the stats of the endpoints
+type Collector struct {
+	start     time.Time
+	now       func() time.Time
+	mu        sync.RWMutex
+	endpoints map[string]*endpointStats
+}
+
+// NewCollector returns an empty Collector
+func NewCollector() *Collector {
+	return &Collector{
+		start:     time.Now(),
+		now:       time.Now,
+		endpoints: map[string]*endpointStats{},
+	}
+}
+
+var defaultCollector = NewCollector()
+
+// DefaultCollector returns the package collector, used by the proxy middleware
+func DefaultCollector() *Collector {
+	return defaultCollector
+}
+
+// Observe records the execution of a request against the endpoint
+func (c *Collector) Observe(endpoint string, d time.Duration, failed, complete bool) {
+	c.mu.RLock()
+	s, ok := c.endpoints[endpoint]
+	c.mu.RUnlock()
+	if !ok {
+		c.mu.Lock()
+		if s, ok = c.endpoints[endpoint]; !ok {
+			s = &endpointStats{}
+			c.endpoints[endpoint] = s
+		}
+		c.mu.Unlock()
+	}
+	s.observe(c.now(), d, failed, complete)
+}
+
+// Snapshot returns the current stats
+func (c *Collector) Snapshot() Snapshot {
+	now := c.now()
+	res := Snapshot{
+		Time:      now,
+		Uptime:    now.Sub(c.start).Truncate(time.Second).String(),
+		Endpoints: map[string]EndpointSnapshot{},
+		Backends:  []BackendSnapshot{},
+		Caches:    cache.GetRegister().Stats(),
+		States:    map[string]interface{}{},
+	}
+
+	c.mu.RLock()
+	for name, s := range c.endpoints {
+		res.Endpoints[name] = s.snapshot(now)
+	}
+	c.mu.RUnlock()
+
+	for _, b := range sd.GetTracker().Backends() {
+		res.Backends = append(res.Backends, BackendSnapshot(b))
+	}
+
+	res.States = States()
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	res.Runtime = RuntimeSnapshot{
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  m.HeapAlloc,
+		HeapInuse:  m.HeapInuse,
+		NumGC:      m.NumGC,
+	}
+	return res
+}
+
+// Handler returns a http handler serving the snapshots of the collector
+func Handler(c *Collector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(c.Snapshot())
+	})
+}
+
+type endpointStats struct {
+	mu         sync.Mutex
+	requests   uint64
+	errors     uint64
+	incomplete uint64
+	total      time.Duration
+	max        time.Duration
+	last       time.Duration
+	buckets    [10]bucket
+}
+
+type bucket struct {
+	second int64
+	count  uint64
+}
+
+func (s *endpointStats) observe(now time.Time, d time.Duration, failed, complete bool) {
+	s.mu.Lock()
+	s.requests++
+	if failed {
+		s.errors++
+	} else if !complete {
+		s.incomplete++
+	}
+	s.total += d
+	s.last = d
+	if d > s.max {
+		s.max = d
+	}
+	sec := now.Unix()
+	b := &s.buckets[sec%int64(len(s.buckets))]
+	if b.second != sec {
+		b.second, b.count = sec, 0
+	}
+	b.count++
+	s.mu.Unlock()
+}
+
+func (s *endpointStats) snapshot(now time.Time) EndpointSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := EndpointSnapshot{
+		Requests:      s.requests,
+		Errors:        s.errors,
+		Incomplete:    s.incomplete,
+		LatencyMaxMs:  toMillis(s.max),
+		LatencyLastMs: toMillis(s.last),
+	}
+	if s.requests > 0 {
+		res.ErrorRate = float64(s.errors) / float64(s.requests)
+		res.LatencyAvgMs = toMillis(s.total) / float64(s.requests)
+	}
+	var recent uint64
+	oldest := now.Unix() - int64(len(s.buckets))
+	for _, b := range s.buckets {
+		if b.second > oldest {
+			recent += b.count
+		}
+	}
+	res.RPS = float64(recent) / Window.Seconds()
+	return res
+}
+
+func toMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
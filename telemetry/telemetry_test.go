@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+
+	c.Observe("GET /a", 10*time.Millisecond, false, true)
+	c.Observe("GET /a", 30*time.Millisecond, true, false)
+	now = now.Add(time.Second)
+	c.Observe("GET /a", 20*time.Millisecond, false, false)
+
+	RegisterState("breaker GET /a", func() interface{} { return "closed" })
+
+	s := c.Snapshot()
+	a, ok := s.Endpoints["GET /a"]
+	if !ok {
+		t.Errorf("unexpected endpoints: %+v", s.Endpoints)
+		return
+	}
+	if a.Requests != 3 || a.Errors != 1 || a.Incomplete != 1 {
+		t.Errorf("unexpected counters: %+v", a)
+	}
+	if a.LatencyAvgMs != 20 || a.LatencyMaxMs != 30 || a.LatencyLastMs != 20 {
+		t.Errorf("unexpected latencies: %+v", a)
+	}
+	if a.RPS != 0.3 {
+		t.Errorf("unexpected rps: %f", a.RPS)
+	}
+	if s.States["breaker GET /a"] != "closed" {
+		t.Errorf("unexpected states: %v", s.States)
+	}
+
+	now = now.Add(time.Minute)
+	if rps := c.Snapshot().Endpoints["GET /a"].RPS; rps != 0 {
+		t.Errorf("unexpected rps after the window: %f", rps)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	c := NewCollector()
+	c.Observe("GET /a", time.Millisecond, false, true)
+
+	w := httptest.NewRecorder()
+	Handler(c).ServeHTTP(w, httptest.NewRequest("GET", DefaultPath, http.NoBody))
+
+	var s Snapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &s); err != nil {
+		t.Error(err)
+		return
+	}
+	if s.Endpoints["GET /a"].Requests != 1 {
+		t.Errorf("unexpected snapshot: %s", w.Body.String())
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if _, ok := Register(config.ServiceConfig{}); ok || Enabled() {
+		t.Error("the telemetry should be disabled")
+	}
+	cfg, ok := Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{"path": "/__snapshot"},
+	}})
+	if !ok || !Enabled() || cfg.Path != "/__snapshot" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
@@ -0,0 +1,227 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/register"
+)
+
+const (
+	// OTLPExporter is the name of the exporter sending the spans to an OTLP/HTTP collector
+	OTLPExporter = "otlp"
+	// LogExporter is the name of the exporter writing the spans to the logger
+	LogExporter = "log"
+
+	// DefaultOTLPEndpoint is the url of the collector when the config does not declare one
+	DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+	instrumentationScope = "github.com/luraproject/lura/v2"
+)
+
+// Exporter sends the finished spans to a tracing backend
+type Exporter interface {
+	Export(ctx context.Context, service string, spans []*Span) error
+}
+
+// ExporterFunc type is an adapter to allow the use of ordinary functions as exporters
+type ExporterFunc func(context.Context, string, []*Span) error
+
+// Export implements the Exporter interface
+func (f ExporterFunc) Export(ctx context.Context, service string, spans []*Span) error {
+	return f(ctx, service, spans)
+}
+
+// ExporterFactory creates an exporter from the tracing section of the service extra config
+type ExporterFactory func(cfg map[string]interface{}, logger logging.Logger) (Exporter, error)
+
+var exporters = register.NewUntyped()
+
+func init() {
+	RegisterExporter(OTLPExporter, NewOTLPExporterFromConfig)
+	RegisterExporter(LogExporter, func(_ map[string]interface{}, logger logging.Logger) (Exporter, error) {
+		return NewLoggerExporter(logger), nil
+	})
+}
+
+// RegisterExporter adds an exporter factory to the package register
+func RegisterExporter(name string, ef ExporterFactory) {
+	exporters.Register(name, ef)
+}
+
+// GetExporterFactory returns the exporter factory registered with the name
+func GetExporterFactory(name string) (ExporterFactory, bool) {
+	v, ok := exporters.Get(name)
+	if !ok {
+		return nil, false
+	}
+	ef, ok := v.(ExporterFactory)
+	return ef, ok
+}
+
+// NewLoggerExporter returns an Exporter writing the spans as OTLP JSON to the logger
+func NewLoggerExporter(logger logging.Logger) Exporter {
+	return ExporterFunc(func(_ context.Context, service string, spans []*Span) error {
+		b, err := json.Marshal(newOTLPRequest(service, spans))
+		if err != nil {
+			return err
+		}
+		logger.Info(logPrefix, string(b))
+		return nil
+	})
+}
+
+// NewOTLPExporterFromConfig returns an OTLP exporter with the endpoint and the headers declared in
+// the config
+func NewOTLPExporterFromConfig(cfg map[string]interface{}, _ logging.Logger) (Exporter, error) {
+	url, _ := cfg["endpoint"].(string)
+	if url == "" {
+		url = DefaultOTLPEndpoint
+	}
+	headers := map[string]string{}
+	if hs, ok := cfg["headers"].(map[string]interface{}); ok {
+		for k, v := range hs {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("tracing: invalid value for the header %s", k)
+			}
+			headers[k] = s
+		}
+	}
+	return NewOTLPExporter(url, headers, nil), nil
+}
+
+// NewOTLPExporter returns an Exporter posting the spans to an OTLP/HTTP collector with the JSON
+// encoding
+func NewOTLPExporter(url string, headers map[string]string, c *http.Client) Exporter {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return ExporterFunc(func(ctx context.Context, service string, spans []*Span) error {
+		b, err := json.Marshal(newOTLPRequest(service, spans))
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			return errors.New("tracing: unexpected status code from the collector: " + strconv.Itoa(resp.StatusCode))
+		}
+		return nil
+	})
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func newOTLPRequest(service string, spans []*Span) otlpRequest {
+	res := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		res[i] = otlpSpan{
+			TraceID:           s.Context.TraceID.String(),
+			SpanID:            s.Context.SpanID.String(),
+			TraceState:        s.Context.TraceState,
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+		}
+		if s.Parent.IsValid() {
+			res[i].ParentSpanID = s.Parent.String()
+		}
+		if s.Error != "" {
+			res[i].Status = otlpStatus{Code: 2, Message: s.Error}
+		}
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(map[string]interface{}{"service.name": service})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: instrumentationScope}, Spans: res}},
+	}}}
+}
+
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	res := make([]otlpKeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		var value map[string]interface{}
+		switch t := v.(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": t}
+		case bool:
+			value = map[string]interface{}{"boolValue": t}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(t)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(t, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": t}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprintf("%v", t)}
+		}
+		res = append(res, otlpKeyValue{Key: k, Value: value})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res
+}
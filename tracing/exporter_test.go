@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	var payload map[string]interface{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer s.Close()
+
+	e, err := NewOTLPExporterFromConfig(map[string]interface{}{
+		"endpoint": s.URL,
+		"headers":  map[string]interface{}{"Authorization": "Bearer secret"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sc, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	span := &Span{
+		Name:       "GET /foo",
+		Kind:       SpanKindServer,
+		Context:    sc,
+		Start:      time.Unix(1, 0),
+		End:        time.Unix(2, 0),
+		Attributes: map[string]interface{}{"http.status_code": 500, "http.method": "GET"},
+		Error:      "Internal Server Error",
+	}
+	if err := e.Export(context.Background(), "gateway", []*Span{span}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := json.Marshal(payload)
+	expected := `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"gateway"}}]},` +
+		`"scopeSpans":[{"scope":{"name":"github.com/luraproject/lura/v2"},"spans":[{"attributes":[` +
+		`{"key":"http.method","value":{"stringValue":"GET"}},{"key":"http.status_code","value":{"intValue":"500"}}],` +
+		`"endTimeUnixNano":"2000000000","kind":2,"name":"GET /foo","spanId":"00f067aa0ba902b7",` +
+		`"startTimeUnixNano":"1000000000","status":{"code":2,"message":"Internal Server Error"},` +
+		`"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"}]}]}]}`
+	if string(b) != expected {
+		t.Errorf("unexpected payload: %s", string(b))
+	}
+
+	if _, err := NewOTLPExporterFromConfig(map[string]interface{}{"headers": map[string]interface{}{"a": 1}}, nil); err == nil {
+		t.Error("expecting an error")
+	}
+}
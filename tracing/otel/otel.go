@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package otel exports the spans of the lura tracer with the OpenTelemetry SDK exporters.
+
+The package registers two exporters in the tracing package when imported:
+
+	otel         sends the spans to an OTLP/HTTP collector with the protobuf encoding
+	otel_stdout  writes the spans to the standard output, for debugging
+
+	import _ "github.com/luraproject/lura/v2/tracing/otel"
+
+	"github.com/luraproject/lura/tracing": {
+		"service_name": "gateway",
+		"exporter": "otel",
+		"endpoint": "http://collector:4318/v1/traces",
+		"headers": {"Authorization": "Bearer token"},
+		"compression": "gzip"
+	}
+
+Any other sdktrace.SpanExporter can be registered by wrapping it with NewExporter. The package is
+a module of its own, so the services using the built-in exporters do not depend on the SDK.
+*/
+package otel
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/tracing"
+)
+
+const (
+	// OTLPExporter is the name of the exporter sending the spans to an OTLP/HTTP collector
+	OTLPExporter = "otel"
+	// StdoutExporter is the name of the exporter writing the spans to the standard output
+	StdoutExporter = "otel_stdout"
+
+	instrumentationScope = "github.com/luraproject/lura/v2"
+)
+
+func init() {
+	tracing.RegisterExporter(OTLPExporter, NewOTLPExporterFromConfig)
+	tracing.RegisterExporter(StdoutExporter, func(_ map[string]interface{}, _ logging.Logger) (tracing.Exporter, error) {
+		e, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		return NewExporter(e), nil
+	})
+}
+
+// NewOTLPExporterFromConfig returns an exporter backed by the OTLP/HTTP exporter of the SDK, with
+// the endpoint, the headers and the compression declared in the config
+func NewOTLPExporterFromConfig(cfg map[string]interface{}, _ logging.Logger) (tracing.Exporter, error) {
+	url, _ := cfg["endpoint"].(string)
+	if url == "" {
+		url = tracing.DefaultOTLPEndpoint
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(url)}
+	if hs, ok := cfg["headers"].(map[string]interface{}); ok {
+		headers := map[string]string{}
+		for k, v := range hs {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("otel: invalid value for the header %s", k)
+			}
+			headers[k] = s
+		}
+		opts = append(opts, otlptracehttp.WithHeaders(headers))
+	}
+	switch c, _ := cfg["compression"].(string); c {
+	case "":
+	case "gzip":
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	default:
+		return nil, fmt.Errorf("otel: unknown compression %s", c)
+	}
+	e, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewExporter(e), nil
+}
+
+// NewExporter returns a tracing.Exporter sending the spans of the lura tracer to the SDK exporter
+func NewExporter(e sdktrace.SpanExporter) tracing.Exporter {
+	return tracing.ExporterFunc(func(ctx context.Context, service string, spans []*tracing.Span) error {
+		return e.ExportSpans(ctx, ReadOnlySpans(service, spans))
+	})
+}
+
+// ReadOnlySpans converts the spans of the lura tracer to the ones exported by the SDK
+func ReadOnlySpans(service string, spans []*tracing.Span) []sdktrace.ReadOnlySpan {
+	res := resource.NewSchemaless(attribute.String("service.name", service))
+	scope := instrumentation.Scope{Name: instrumentationScope}
+
+	out := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, s := range spans {
+		stub := tracetest.SpanStub{
+			Name:                 s.Name,
+			SpanContext:          spanContext(s.Context.TraceID, s.Context.SpanID, s.Context),
+			SpanKind:             spanKind(s.Kind),
+			StartTime:            s.Start,
+			EndTime:              s.End,
+			Attributes:           attributes(s.Attributes),
+			Resource:             res,
+			InstrumentationScope: scope,
+		}
+		if s.Parent.IsValid() {
+			stub.Parent = spanContext(s.Context.TraceID, s.Parent, s.Context)
+		}
+		if s.Error != "" {
+			stub.Status = sdktrace.Status{Code: codes.Error, Description: s.Error}
+		}
+		out[i] = stub.Snapshot()
+	}
+	return out
+}
+
+func spanContext(traceID tracing.TraceID, spanID tracing.SpanID, sc tracing.SpanContext) trace.SpanContext {
+	cfg := trace.SpanContextConfig{
+		TraceID: trace.TraceID(traceID),
+		SpanID:  trace.SpanID(spanID),
+	}
+	if sc.Sampled {
+		cfg.TraceFlags = trace.FlagsSampled
+	}
+	if ts, err := trace.ParseTraceState(sc.TraceState); err == nil {
+		cfg.TraceState = ts
+	}
+	return trace.NewSpanContext(cfg)
+}
+
+func spanKind(k tracing.SpanKind) trace.SpanKind {
+	switch k {
+	case tracing.SpanKindServer:
+		return trace.SpanKindServer
+	case tracing.SpanKindClient:
+		return trace.SpanKindClient
+	}
+	return trace.SpanKindInternal
+}
+
+func attributes(attrs map[string]interface{}) []attribute.KeyValue {
+	res := make([]attribute.KeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		switch t := v.(type) {
+		case string:
+			res = append(res, attribute.String(k, t))
+		case bool:
+			res = append(res, attribute.Bool(k, t))
+		case int:
+			res = append(res, attribute.Int(k, t))
+		case int64:
+			res = append(res, attribute.Int64(k, t))
+		case float64:
+			res = append(res, attribute.Float64(k, t))
+		default:
+			res = append(res, attribute.String(k, fmt.Sprintf("%v", t)))
+		}
+	}
+	return res
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package otel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	collector "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/tracing"
+)
+
+func TestNewExporter(t *testing.T) {
+	memory := tracetest.NewInMemoryExporter()
+	tracer, err := tracing.New(tracing.Config{ServiceName: "gateway"}, NewExporter(memory), logging.NoOp)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ctx, parent := tracer.Start(context.Background(), "GET /supu", tracing.SpanKindServer)
+	parent.SetAttribute("http.status_code", 200)
+	_, child := tracer.Start(ctx, "backend", tracing.SpanKindClient)
+	child.RecordError(errors.New("timeout"))
+	child.Finish()
+	parent.Finish()
+	tracer.Close()
+
+	spans := memory.GetSpans()
+	if len(spans) != 2 {
+		t.Errorf("unexpected number of spans: %d", len(spans))
+		return
+	}
+	byName := map[string]tracetest.SpanStub{}
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+
+	server, client := byName["GET /supu"], byName["backend"]
+	if server.SpanKind != trace.SpanKindServer || client.SpanKind != trace.SpanKindClient {
+		t.Errorf("unexpected kinds: %v %v", server.SpanKind, client.SpanKind)
+	}
+	if server.Parent.IsValid() {
+		t.Error("the server span should be a root")
+	}
+	if client.Parent.SpanID() != server.SpanContext.SpanID() || client.SpanContext.TraceID() != server.SpanContext.TraceID() {
+		t.Error("the client span should be a child of the server span")
+	}
+	if !server.SpanContext.IsSampled() {
+		t.Error("the exported spans should be sampled")
+	}
+	if len(server.Attributes) != 1 || server.Attributes[0] != attribute.Int("http.status_code", 200) {
+		t.Errorf("unexpected attributes: %v", server.Attributes)
+	}
+	if client.Status.Code != codes.Error || client.Status.Description != "timeout" {
+		t.Errorf("unexpected status: %+v", client.Status)
+	}
+	if v, ok := server.Resource.Set().Value("service.name"); !ok || v.AsString() != "gateway" {
+		t.Errorf("unexpected resource: %v", server.Resource)
+	}
+	if server.InstrumentationScope.Name != instrumentationScope {
+		t.Errorf("unexpected scope: %v", server.InstrumentationScope)
+	}
+}
+
+func TestNewOTLPExporterFromConfig(t *testing.T) {
+	received := make(chan *collector.ExportTraceServiceRequest, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected auth header: %s", req.Header.Get("Authorization"))
+		}
+		if req.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("unexpected content type: %s", req.Header.Get("Content-Type"))
+		}
+		b, _ := io.ReadAll(req.Body)
+		msg := &collector.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(b, msg); err != nil {
+			t.Error(err)
+		}
+		received <- msg
+		rw.Header().Set("Content-Type", "application/x-protobuf")
+		out, _ := proto.Marshal(&collector.ExportTraceServiceResponse{})
+		rw.Write(out)
+	}))
+	defer s.Close()
+
+	ef, ok := tracing.GetExporterFactory(OTLPExporter)
+	if !ok {
+		t.Error("the otel exporter should be registered")
+		return
+	}
+	e, err := ef(map[string]interface{}{
+		"endpoint": s.URL + "/v1/traces",
+		"headers":  map[string]interface{}{"Authorization": "Bearer token"},
+	}, logging.NoOp)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	tracer, err := tracing.New(tracing.Config{ServiceName: "gateway"}, e, logging.NoOp)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, span := tracer.Start(context.Background(), "GET /supu", tracing.SpanKindServer)
+	span.Finish()
+	tracer.Close()
+
+	msg := <-received
+	rs := msg.GetResourceSpans()
+	if len(rs) != 1 || len(rs[0].GetScopeSpans()) != 1 || len(rs[0].GetScopeSpans()[0].GetSpans()) != 1 {
+		t.Errorf("unexpected request: %v", msg)
+		return
+	}
+	if name := rs[0].GetScopeSpans()[0].GetSpans()[0].GetName(); name != "GET /supu" {
+		t.Errorf("unexpected span name: %s", name)
+	}
+}
+
+func TestNewOTLPExporterFromConfig_ko(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"headers": map[string]interface{}{"Authorization": 42}},
+		{"compression": "zstd"},
+	} {
+		if _, err := NewOTLPExporterFromConfig(cfg, logging.NoOp); err == nil {
+			t.Errorf("error expected for %v", cfg)
+		}
+	}
+}
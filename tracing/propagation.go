@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const (
+	// TraceparentHeader is the W3C tracecontext header carrying the trace and the parent span
+	TraceparentHeader = "Traceparent"
+	// TracestateHeader is the W3C tracecontext header carrying the vendor specific trace data
+	TracestateHeader = "Tracestate"
+
+	traceparentVersion = "00"
+	sampledFlag        = 0x01
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// IsValid returns false for the all zeros id
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// String returns the hex encoding of the id
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanID identifies a span
+type SpanID [8]byte
+
+// IsValid returns false for the all zeros id
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// String returns the hex encoding of the id
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanContext is the part of a span propagated across the process boundaries
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+}
+
+// IsValid returns true if both the trace and the span ids are valid
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// Traceparent returns the value of the traceparent header for the span context
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return traceparentVersion + "-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses the value of a traceparent header. The values of the future versions
+// are accepted as long as they start with the fields of the current one
+func ParseTraceparent(v string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, false
+	}
+	if parts[0] == traceparentVersion && len(parts) != 4 {
+		return sc, false
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 {
+		return sc, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&sampledFlag != 0
+	return sc, sc.IsValid()
+}
+
+// Extract returns the span context propagated in the headers, if any
+func Extract(h http.Header) (SpanContext, bool) {
+	sc, ok := ParseTraceparent(h.Get(TraceparentHeader))
+	if !ok {
+		return sc, false
+	}
+	sc.TraceState = h.Get(TracestateHeader)
+	return sc, true
+}
+
+// Inject adds the span context to the headers, replacing the propagated by the previous hops
+func Inject(h http.Header, sc SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
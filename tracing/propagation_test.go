@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	valid := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(valid)
+	if !ok || !sc.Sampled {
+		t.Fatalf("unexpected result. ok: %v, sc: %+v", ok, sc)
+	}
+	if sc.Traceparent() != valid {
+		t.Errorf("unexpected traceparent: %s", sc.Traceparent())
+	}
+	if _, ok := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); !ok {
+		t.Error("the future versions must be accepted")
+	}
+
+	for _, v := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+	} {
+		if _, ok := ParseTraceparent(v); ok {
+			t.Errorf("expecting %q to be rejected", v)
+		}
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	sc, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	sc.TraceState = "vendor=value"
+
+	h := http.Header{}
+	h.Set(TracestateHeader, "stale=true")
+	Inject(h, sc)
+	if h.Get(TraceparentHeader) != sc.Traceparent() || h.Get(TracestateHeader) != "vendor=value" {
+		t.Errorf("unexpected headers: %v", h)
+	}
+
+	extracted, ok := Extract(h)
+	if !ok || extracted != sc {
+		t.Errorf("unexpected span context: %+v", extracted)
+	}
+
+	Inject(h, SpanContext{TraceID: sc.TraceID, SpanID: sc.SpanID})
+	if h.Get(TracestateHeader) != "" {
+		t.Error("the stale tracestate was not removed")
+	}
+}
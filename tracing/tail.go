@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDecisionWait is the max time the spans of a trace are buffered waiting for its local
+	// root span when the tail sampling config does not declare it
+	DefaultDecisionWait = 30 * time.Second
+	// DefaultMaxTraces is the max number of traces buffered when the tail sampling config does not
+	// declare it
+	DefaultMaxTraces = 10000
+)
+
+// TailSamplingConfig defines the tail sampling of the traces. The spans of every trace are
+// buffered until its local root span ends, and the trace is only exported if one of its spans
+// failed or forced the sampling, if the root span lasted more than the latency threshold or, for
+// the rest of the traces, with the sample rate
+type TailSamplingConfig struct {
+	LatencyThreshold string  `json:"latency_threshold"`
+	SampleRate       float64 `json:"sample_rate"`
+	DecisionWait     string  `json:"decision_wait"`
+	MaxTraces        int     `json:"max_traces"`
+}
+
+type tailSampler struct {
+	latency   time.Duration
+	threshold uint64
+	wait      time.Duration
+	maxTraces int
+
+	mu      sync.Mutex
+	pending map[TraceID]*pendingTrace
+}
+
+type pendingTrace struct {
+	spans  []*Span
+	first  time.Time
+	failed bool
+	forced bool
+}
+
+func newTailSampler(cfg TailSamplingConfig) (*tailSampler, error) {
+	ts := &tailSampler{
+		wait:      DefaultDecisionWait,
+		maxTraces: cfg.MaxTraces,
+		pending:   map[TraceID]*pendingTrace{},
+	}
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		return nil, fmt.Errorf("tracing: the tail sample rate must be between 0 and 1, got %v", cfg.SampleRate)
+	}
+	if cfg.SampleRate == 1 {
+		ts.threshold = ^uint64(0)
+	} else {
+		ts.threshold = uint64(cfg.SampleRate * float64(^uint64(0)))
+	}
+	if cfg.LatencyThreshold != "" {
+		d, err := time.ParseDuration(cfg.LatencyThreshold)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("tracing: invalid latency threshold %s", cfg.LatencyThreshold)
+		}
+		ts.latency = d
+	}
+	if cfg.DecisionWait != "" {
+		d, err := time.ParseDuration(cfg.DecisionWait)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("tracing: invalid decision wait %s", cfg.DecisionWait)
+		}
+		ts.wait = d
+	}
+	if ts.maxTraces <= 0 {
+		ts.maxTraces = DefaultMaxTraces
+	}
+	return ts, nil
+}
+
+// add buffers the finished span and returns the spans of its trace to export, if the span is the
+// local root and the trace must be kept
+func (ts *tailSampler) add(s *Span) []*Span {
+	id := s.Context.TraceID
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	pt, ok := ts.pending[id]
+	if !ok {
+		if len(ts.pending) >= ts.maxTraces {
+			ts.evictOldest()
+		}
+		pt = &pendingTrace{first: s.Start}
+		ts.pending[id] = pt
+	}
+	pt.spans = append(pt.spans, s)
+	pt.failed = pt.failed || s.Error != ""
+	pt.forced = pt.forced || s.forced
+
+	if !s.localRoot {
+		return nil
+	}
+	delete(ts.pending, id)
+	if ts.keep(id, pt, s.End.Sub(s.Start)) {
+		return pt.spans
+	}
+	return nil
+}
+
+// expire decides the traces waiting for their local root span for too long, using the longest
+// span as the duration of the trace. All the pending traces are decided when force is true
+func (ts *tailSampler) expire(now time.Time, force bool) []*Span {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	var res []*Span
+	for id, pt := range ts.pending {
+		if !force && now.Sub(pt.first) < ts.wait {
+			continue
+		}
+		delete(ts.pending, id)
+		var longest time.Duration
+		for _, s := range pt.spans {
+			if d := s.End.Sub(s.Start); d > longest {
+				longest = d
+			}
+		}
+		if ts.keep(id, pt, longest) {
+			res = append(res, pt.spans...)
+		}
+	}
+	return res
+}
+
+func (ts *tailSampler) keep(id TraceID, pt *pendingTrace, d time.Duration) bool {
+	if pt.failed || pt.forced || (ts.latency > 0 && d >= ts.latency) {
+		return true
+	}
+	return ts.threshold != 0 && binary.BigEndian.Uint64(id[8:]) <= ts.threshold
+}
+
+// evictOldest drops the trace buffered for the longest time. It must be called with the mu locked
+func (ts *tailSampler) evictOldest() {
+	var (
+		oldest TraceID
+		first  time.Time
+	)
+	for id, pt := range ts.pending {
+		if first.IsZero() || pt.first.Before(first) {
+			oldest, first = id, pt.first
+		}
+	}
+	delete(ts.pending, oldest)
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestTracer_tailSampling(t *testing.T) {
+	zero := 0.0
+	rec := &recorder{}
+	tracer, err := New(Config{
+		SampleRate:   &zero,
+		TailSampling: &TailSamplingConfig{LatencyThreshold: "20ms"},
+	}, rec, logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trace := func(name string, d time.Duration, err error) *Span {
+		ctx, root := tracer.Start(context.Background(), name, SpanKindServer)
+		if root == nil {
+			t.Fatal("the tail sampling must record all the spans")
+		}
+		_, child := tracer.Start(ctx, name+" child", SpanKindClient)
+		time.Sleep(d)
+		child.RecordError(err)
+		child.Finish()
+		root.Finish()
+		return root
+	}
+
+	trace("fast", 0, nil)
+	failed := trace("failed", 0, errors.New("boom"))
+	slow := trace("slow", 30*time.Millisecond, nil)
+
+	// the orphan spans are decided when the tracer is closed
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := tracer.Start(ContextWithRemoteParent(context.Background(), remote), "orphan", SpanKindServer)
+	_, orphan := tracer.Start(ctx, "orphan child", SpanKindClient)
+	orphan.RecordError(errors.New("boom"))
+	orphan.Finish()
+	tracer.Close()
+	root.Finish()
+
+	traces := map[TraceID]int{}
+	for _, s := range rec.spans {
+		traces[s.Context.TraceID]++
+	}
+	if len(rec.spans) != 5 || traces[failed.Context.TraceID] != 2 || traces[slow.Context.TraceID] != 2 || traces[remote.TraceID] != 1 {
+		t.Errorf("unexpected spans exported: %d %v", len(rec.spans), traces)
+	}
+}
+
+func TestTailSampler_maxTraces(t *testing.T) {
+	ts, err := newTailSampler(TailSamplingConfig{MaxTraces: 1, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	first := &Span{Context: SpanContext{TraceID: newTraceID()}, Start: now, End: now}
+	second := &Span{Context: SpanContext{TraceID: newTraceID()}, Start: now.Add(time.Millisecond), End: now}
+	ts.add(first)
+	ts.add(second)
+	if spans := ts.expire(now, true); len(spans) != 1 || spans[0] != second {
+		t.Errorf("the oldest trace was not evicted: %v", spans)
+	}
+}
+
+func TestNewTailSampler_invalid(t *testing.T) {
+	for _, cfg := range []TailSamplingConfig{
+		{SampleRate: 2},
+		{LatencyThreshold: "slow"},
+		{DecisionWait: "-1s"},
+	} {
+		if _, err := newTailSampler(cfg); err == nil {
+			t.Errorf("expecting an error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,498 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package tracing instruments the request lifecycle with OpenTelemetry compatible spans.
+
+The router opens a server span for every endpoint request, the proxy stack opens a child span for
+the merge of the backend responses and for every backend, and the http client opens a client span
+for every request sent, propagating the trace to the backends with the W3C tracecontext headers.
+
+The tracer and its exporter are declared in the service extra config:
+
+	"extra_config": {
+		"github.com/luraproject/lura/tracing": {
+			"service_name": "gateway",
+			"sample_rate": 0.25,
+			"exporter": "otlp",
+			"endpoint": "http://otel-collector:4318/v1/traces",
+			"headers": {"Authorization": "Bearer secret"},
+			"batch_size": 512,
+			"flush_interval": "5s"
+		}
+	}
+
+The package registers the "otlp" exporter, sending the spans to an OTLP/HTTP collector with the
+JSON encoding, and the "log" one, writing them to the logger. Other exporters can be added with
+RegisterExporter, like the ones of the github.com/luraproject/lura/v2/tracing/otel module, backed
+by the exporters of the OpenTelemetry SDK. The requests arriving with a sampled trace are always recorded, the rest are
+sampled with the sample rate.
+
+The tail sampling replaces the sampling decision at the start of the traces with one taken once
+the request is completed, so the failed and the slow requests are always exported while the rest
+are exported with the tail sample rate:
+
+	"tail_sampling": {
+		"latency_threshold": "500ms",
+		"sample_rate": 0.01,
+		"decision_wait": "30s",
+		"max_traces": 10000
+	}
+
+With the tail sampling, all the spans are recorded and buffered until the local root span of
+their trace ends, and the traces are propagated to the backends as sampled.
+*/
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+// Namespace is the key to use to store and access the tracing config
+const Namespace = "github.com/luraproject/lura/tracing"
+
+const (
+	// DefaultServiceName is the name of the traced service when the config does not declare one
+	DefaultServiceName = "lura"
+	// DefaultBatchSize is the number of spans exported at once when the config does not declare it
+	DefaultBatchSize = 512
+	// DefaultFlushInterval is the max time the spans wait to be exported when the config does not
+	// declare it
+	DefaultFlushInterval = 5 * time.Second
+)
+
+// ContextKey is the string key of the current span context in the contexts that only support
+// string keys, like the gin ones
+const ContextKey = "github.com/luraproject/lura/tracing.span_context"
+
+// ForceSamplingContextKey is the string key of the forced sampling flag in the contexts that only
+// support string keys, like the gin ones
+const ForceSamplingContextKey = "github.com/luraproject/lura/tracing.force_sampling"
+
+const logPrefix = "[SERVICE: Tracing]"
+
+// ErrUnknownExporter is returned when the config declares an exporter not registered
+var ErrUnknownExporter = errors.New("tracing: unknown exporter")
+
+// Config is the tracing config of the service
+type Config struct {
+	ServiceName   string   `json:"service_name"`
+	SampleRate    *float64 `json:"sample_rate"`
+	Exporter      string   `json:"exporter"`
+	BatchSize     int      `json:"batch_size"`
+	FlushInterval string   `json:"flush_interval"`
+
+	TailSampling *TailSamplingConfig `json:"tail_sampling"`
+}
+
+// ConfigGetter parses the tracing config from the service extra config. It also returns the raw
+// config, so the exporters can read their own settings
+func ConfigGetter(e config.ExtraConfig) (Config, map[string]interface{}, bool, error) {
+	var cfg Config
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, nil, false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, tmp, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, tmp, true, fmt.Errorf("tracing: parsing the config: %w", err)
+	}
+	return cfg, tmp, true, nil
+}
+
+// SpanKind is the role of a span in the trace
+type SpanKind int
+
+// The values match the OTLP ones
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// Span is a traced operation. All its methods are safe to call on a nil span, so the code
+// instrumenting an operation does not need to check if the request is sampled
+type Span struct {
+	Name       string
+	Kind       SpanKind
+	Context    SpanContext
+	Parent     SpanID
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	Error      string
+
+	tracer    *Tracer
+	localRoot bool
+	forced    bool
+	mu        sync.Mutex
+	ended     bool
+}
+
+// SetAttribute adds an attribute to the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Attributes[key] = value
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Error = err.Error()
+	s.mu.Unlock()
+}
+
+// Finish ends the span and queues it for the export. The calls after the first one are ignored
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.mu.Unlock()
+	s.tracer.enqueue(s)
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+type forceKey struct{}
+
+// ContextWithSpan returns a copy of the context carrying the span
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// SpanFromContext returns the span stored in the context, if any
+func SpanFromContext(ctx context.Context) (*Span, bool) {
+	s, ok := ctx.Value(spanKey{}).(*Span)
+	return s, ok && s != nil
+}
+
+// ContextWithRemoteParent returns a copy of the context carrying the span context received from
+// the client, so the spans started with it join its trace
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// WithForcedSampling returns a copy of the context forcing the sampling of the spans started with
+// it, whatever the sample rates, i.e. to debug a single request
+func WithForcedSampling(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceKey{}, true)
+}
+
+func forcedSampling(ctx context.Context) bool {
+	if v, ok := ctx.Value(forceKey{}).(bool); ok {
+		return v
+	}
+	v, _ := ctx.Value(ForceSamplingContextKey).(bool)
+	return v
+}
+
+// SpanContextFromContext returns the context of the current span, or the remote parent if the
+// context does not carry a local span
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	if s, ok := SpanFromContext(ctx); ok {
+		return s.Context, true
+	}
+	if sc, ok := ctx.Value(remoteKey{}).(SpanContext); ok && sc.IsValid() {
+		return sc, true
+	}
+	sc, ok := ctx.Value(ContextKey).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// Tracer creates the spans and exports them in batches
+type Tracer struct {
+	service       string
+	threshold     uint64
+	exporter      Exporter
+	batchSize     int
+	flushInterval time.Duration
+	logger        logging.Logger
+	tail          *tailSampler
+
+	mu     sync.Mutex
+	buffer []*Span
+	flush  chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New returns a Tracer exporting the spans with the exporter. The tracer must be closed, so the
+// pending spans get exported
+func New(cfg Config, e Exporter, logger logging.Logger) (*Tracer, error) {
+	t := &Tracer{
+		service:       cfg.ServiceName,
+		threshold:     ^uint64(0),
+		exporter:      e,
+		batchSize:     cfg.BatchSize,
+		flushInterval: DefaultFlushInterval,
+		logger:        logger,
+		flush:         make(chan struct{}, 1),
+		done:          make(chan struct{}),
+	}
+	if t.service == "" {
+		t.service = DefaultServiceName
+	}
+	if t.batchSize <= 0 {
+		t.batchSize = DefaultBatchSize
+	}
+	if cfg.SampleRate != nil {
+		rate := *cfg.SampleRate
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("tracing: the sample rate must be between 0 and 1, got %v", rate)
+		}
+		if rate < 1 {
+			t.threshold = uint64(rate * float64(^uint64(0)))
+		}
+	}
+	if cfg.FlushInterval != "" {
+		d, err := time.ParseDuration(cfg.FlushInterval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("tracing: invalid flush interval %s", cfg.FlushInterval)
+		}
+		t.flushInterval = d
+	}
+	if cfg.TailSampling != nil {
+		ts, err := newTailSampler(*cfg.TailSampling)
+		if err != nil {
+			return nil, err
+		}
+		t.tail = ts
+	}
+
+	t.wg.Add(1)
+	go t.loop()
+	return t, nil
+}
+
+// ServiceName returns the name of the traced service
+func (t *Tracer) ServiceName() string {
+	return t.service
+}
+
+// Start starts a span as a child of the span, local or remote, carried by the context. The spans
+// of the traces not sampled are nil, but their context is still propagated
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent, hasParent := SpanContextFromContext(ctx)
+	sc := SpanContext{SpanID: newSpanID()}
+	if hasParent {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+		sc.TraceState = parent.TraceState
+	} else {
+		sc.TraceID = newTraceID()
+		sc.Sampled = t.sample(sc.TraceID)
+	}
+	forced := forcedSampling(ctx)
+	if t.tail != nil || forced {
+		// the tail sampler decides once the trace is completed, and keeps the forced ones
+		sc.Sampled = true
+	}
+
+	if !sc.Sampled {
+		// keep the trace id and the sampling decision for the next hops
+		return ContextWithRemoteParent(ctx, sc), nil
+	}
+
+	s := &Span{
+		Name:       name,
+		Kind:       kind,
+		Context:    sc,
+		Start:      time.Now(),
+		Attributes: map[string]interface{}{},
+		tracer:     t,
+		forced:     forced,
+	}
+	if _, ok := SpanFromContext(ctx); !ok {
+		s.localRoot = true
+	}
+	if hasParent {
+		s.Parent = parent.SpanID
+	}
+	return ContextWithSpan(ctx, s), s
+}
+
+// sample decides with the trace id, so all the gateways sharing the sample rate take the same
+// decision for a trace
+func (t *Tracer) sample(id TraceID) bool {
+	return binary.BigEndian.Uint64(id[8:]) <= t.threshold && t.threshold != 0
+}
+
+func (t *Tracer) enqueue(s *Span) {
+	spans := []*Span{s}
+	if t.tail != nil {
+		if spans = t.tail.add(s); len(spans) == 0 {
+			return
+		}
+	}
+	t.mu.Lock()
+	t.buffer = append(t.buffer, spans...)
+	full := len(t.buffer) >= t.batchSize
+	t.mu.Unlock()
+	if full {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Tracer) loop() {
+	defer t.wg.Done()
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.flush:
+		case <-t.done:
+			t.expire(true)
+			t.export()
+			return
+		}
+		t.expire(false)
+		t.export()
+	}
+}
+
+// expire moves the spans of the expired traces kept by the tail sampler to the export buffer
+func (t *Tracer) expire(force bool) {
+	if t.tail == nil {
+		return
+	}
+	if spans := t.tail.expire(time.Now(), force); len(spans) > 0 {
+		t.mu.Lock()
+		t.buffer = append(t.buffer, spans...)
+		t.mu.Unlock()
+	}
+}
+
+func (t *Tracer) export() {
+	t.mu.Lock()
+	spans := t.buffer
+	t.buffer = nil
+	t.mu.Unlock()
+
+	for len(spans) > 0 {
+		n := t.batchSize
+		if n > len(spans) {
+			n = len(spans)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), t.flushInterval)
+		if err := t.exporter.Export(ctx, t.service, spans[:n]); err != nil {
+			t.logger.Error(logPrefix, "Unable to export the spans:", err.Error())
+		}
+		cancel()
+		spans = spans[n:]
+	}
+}
+
+// Close stops the tracer after exporting the pending spans
+func (t *Tracer) Close() {
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	close(t.done)
+	t.wg.Wait()
+}
+
+var (
+	global   *Tracer
+	globalMu sync.RWMutex
+)
+
+// Register creates the tracer declared in the service extra config and closes the previous one.
+// It returns false if the service does not declare a tracer
+func Register(cfg config.ServiceConfig, logger logging.Logger) (bool, error) {
+	c, raw, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok {
+		SetGlobal(nil)
+		return false, nil
+	}
+	if err != nil {
+		SetGlobal(nil)
+		return true, err
+	}
+	name := c.Exporter
+	if name == "" {
+		name = OTLPExporter
+	}
+	ef, ok := GetExporterFactory(name)
+	if !ok {
+		SetGlobal(nil)
+		return true, fmt.Errorf("%w %s", ErrUnknownExporter, name)
+	}
+	e, err := ef(raw, logger)
+	if err != nil {
+		SetGlobal(nil)
+		return true, err
+	}
+	t, err := New(c, e, logger)
+	if err != nil {
+		SetGlobal(nil)
+		return true, err
+	}
+	SetGlobal(t)
+	return true, nil
+}
+
+// SetGlobal sets the tracer used by the router, the proxies and the http clients. The previous
+// one is not closed, since the pipes of another router can still be using it: the routers close
+// the ones they registered when their context is done
+func SetGlobal(t *Tracer) {
+	globalMu.Lock()
+	global = t
+	globalMu.Unlock()
+}
+
+// GetGlobal returns the tracer used by the router, the proxies and the http clients, if any
+func GetGlobal() (*Tracer, bool) {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return global, global != nil
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+type recorder struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (r *recorder) Export(_ context.Context, _ string, spans []*Span) error {
+	r.mu.Lock()
+	r.spans = append(r.spans, spans...)
+	r.mu.Unlock()
+	return nil
+}
+
+func TestTracer_Start(t *testing.T) {
+	rec := &recorder{}
+	tracer, err := New(Config{}, rec, logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, root := tracer.Start(context.Background(), "root", SpanKindServer)
+	_, child := tracer.Start(ctx, "child", SpanKindInternal)
+	child.SetAttribute("key", "value")
+	child.RecordError(errors.New("boom"))
+	child.Finish()
+	child.Finish()
+	root.Finish()
+	tracer.Close()
+
+	if len(rec.spans) != 2 {
+		t.Fatalf("unexpected number of spans: %d", len(rec.spans))
+	}
+	if rec.spans[0] != child || rec.spans[1] != root {
+		t.Error("unexpected order of the exported spans")
+	}
+	if root.Parent.IsValid() {
+		t.Error("the root span has a parent")
+	}
+	if child.Context.TraceID != root.Context.TraceID || child.Parent != root.Context.SpanID {
+		t.Error("the child span does not belong to the root one")
+	}
+	if child.Attributes["key"] != "value" || child.Error != "boom" {
+		t.Errorf("unexpected child span: %+v", child)
+	}
+}
+
+func TestTracer_Start_remoteParent(t *testing.T) {
+	zero := 0.0
+	tracer, _ := New(Config{SampleRate: &zero}, &recorder{}, logging.NoOp)
+	defer tracer.Close()
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, span := tracer.Start(ContextWithRemoteParent(context.Background(), remote), "sampled", SpanKindServer)
+	if span == nil || span.Context.TraceID != remote.TraceID || span.Parent != remote.SpanID {
+		t.Fatalf("the span does not join the remote trace: %+v", span)
+	}
+	if sc, _ := SpanContextFromContext(ctx); sc.SpanID != span.Context.SpanID {
+		t.Error("the context does not carry the span")
+	}
+
+	ctx, span = tracer.Start(context.Background(), "not sampled", SpanKindServer)
+	if span != nil {
+		t.Error("the span should not be sampled")
+	}
+	sc, ok := SpanContextFromContext(ctx)
+	if !ok || sc.Sampled {
+		t.Errorf("unexpected span context: %+v", sc)
+	}
+	// the methods of the spans not sampled are safe to call
+	span.SetAttribute("key", "value")
+	span.RecordError(errors.New("boom"))
+	span.Finish()
+}
+
+func TestTracer_Start_forcedSampling(t *testing.T) {
+	zero := 0.0
+	for _, cfg := range []Config{
+		{SampleRate: &zero},
+		{SampleRate: &zero, TailSampling: &TailSamplingConfig{}},
+	} {
+		rec := &recorder{}
+		tracer, err := New(cfg, rec, logging.NoOp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, root := tracer.Start(WithForcedSampling(context.Background()), "forced", SpanKindServer)
+		_, child := tracer.Start(ctx, "forced child", SpanKindClient)
+		if root == nil || child == nil {
+			t.Fatal("the forced spans must be sampled")
+		}
+		child.Finish()
+		root.Finish()
+		tracer.Close()
+
+		if len(rec.spans) != 2 {
+			t.Errorf("unexpected number of spans: %d", len(rec.spans))
+		}
+	}
+}
+
+func TestNew_invalid(t *testing.T) {
+	rate := 2.0
+	for _, cfg := range []Config{
+		{SampleRate: &rate},
+		{FlushInterval: "never"},
+	} {
+		if _, err := New(cfg, &recorder{}, logging.NoOp); err == nil {
+			t.Errorf("expecting an error for %+v", cfg)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer SetGlobal(nil)
+
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"exporter": "log"}}}
+	if ok, err := Register(cfg, logging.NoOp); !ok || err != nil {
+		t.Errorf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if _, ok := GetGlobal(); !ok {
+		t.Error("the tracer was not registered")
+	}
+
+	cfg.ExtraConfig[Namespace] = map[string]interface{}{"exporter": "unknown"}
+	if _, err := Register(cfg, logging.NoOp); !errors.Is(err, ErrUnknownExporter) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, ok := GetGlobal(); ok {
+		t.Error("the tracer of the previous config was not dropped")
+	}
+}
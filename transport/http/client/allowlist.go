@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const allowedHostsKey = "allowed_hosts"
+
+// HostNotAllowedError is the error returned when the gateway tries to connect to an address
+// not covered by the host allowlist
+type HostNotAllowedError struct {
+	Host string
+}
+
+// Error returns a string representation of the HostNotAllowedError
+func (h HostNotAllowedError) Error() string {
+	return "the host '" + h.Host + "' is not in the allowlist"
+}
+
+// AllowedHostsConfig defines the destinations the gateway is allowed to connect to
+type AllowedHostsConfig struct {
+	// Domains is the list of allowed domains. The entries starting with a dot or with `*.`
+	// allow all the subdomains
+	Domains []string
+	// CIDRs is the list of allowed networks
+	CIDRs []string
+	// Ports is the list of allowed ports. If empty, all the ports are allowed
+	Ports []int
+}
+
+// AllowedHostsConfigGetter parses the allowlist from the extra config of the service
+func AllowedHostsConfigGetter(e config.ExtraConfig) (AllowedHostsConfig, bool) {
+	cfg := AllowedHostsConfig{}
+	v, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false
+	}
+	tmp, ok := v[allowedHostsKey].(map[string]interface{})
+	if !ok {
+		return cfg, false
+	}
+	cfg.Domains = getStrings(tmp["domains"])
+	cfg.CIDRs = getStrings(tmp["cidrs"])
+	if ports, ok := tmp["ports"].([]interface{}); ok {
+		for _, p := range ports {
+			if n, ok := p.(float64); ok {
+				cfg.Ports = append(cfg.Ports, int(n))
+			}
+		}
+	}
+	return cfg, true
+}
+
+func getStrings(v interface{}) []string {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	res := make([]string, 0, len(vs))
+	for _, s := range vs {
+		if s, ok := s.(string); ok {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// HostPolicy enforces a deny-by-default policy over the destinations of the gateway
+type HostPolicy struct {
+	domains  []string
+	suffixes []string
+	networks []*net.IPNet
+	ports    map[int]struct{}
+	resolver *net.Resolver
+}
+
+// NewHostPolicy returns a HostPolicy allowing only the destinations declared in the config
+func NewHostPolicy(cfg AllowedHostsConfig) (*HostPolicy, error) {
+	p := &HostPolicy{
+		ports:    map[int]struct{}{},
+		resolver: net.DefaultResolver,
+	}
+	for _, d := range cfg.Domains {
+		d = strings.ToLower(strings.TrimSuffix(d, "."))
+		switch {
+		case strings.HasPrefix(d, "*."):
+			p.suffixes = append(p.suffixes, d[1:])
+		case strings.HasPrefix(d, "."):
+			p.suffixes = append(p.suffixes, d)
+		default:
+			p.domains = append(p.domains, d)
+		}
+	}
+	for _, c := range cfg.CIDRs {
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid network in the allowlist: %s", c)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			p.networks = append(p.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network in the allowlist: %w", err)
+		}
+		p.networks = append(p.networks, n)
+	}
+	for _, port := range cfg.Ports {
+		p.ports[port] = struct{}{}
+	}
+	return p, nil
+}
+
+// AllowPort returns true if the port is allowed
+func (p *HostPolicy) AllowPort(port int) bool {
+	if len(p.ports) == 0 {
+		return true
+	}
+	_, ok := p.ports[port]
+	return ok
+}
+
+// AllowDomain returns true if the domain is explicitly allowed
+func (p *HostPolicy) AllowDomain(domain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	for _, d := range p.domains {
+		if d == domain {
+			return true
+		}
+	}
+	for _, s := range p.suffixes {
+		if strings.HasSuffix(domain, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowIP returns true if the ip belongs to one of the allowed networks
+func (p *HostPolicy) AllowIP(ip net.IP) bool {
+	for _, n := range p.networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check verifies the received address (host:port) against the policy. The names not
+// allowed as domains are resolved and all their addresses must belong to the allowed networks
+func (p *HostPolicy) Check(ctx context.Context, addr string) error {
+	host, port, err := splitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if !p.AllowPort(port) {
+		return HostNotAllowedError{Host: addr}
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if p.AllowIP(ip) {
+			return nil
+		}
+		return HostNotAllowedError{Host: addr}
+	}
+	if p.AllowDomain(host) {
+		return nil
+	}
+	if len(p.networks) == 0 {
+		return HostNotAllowedError{Host: addr}
+	}
+	ips, err := p.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if !p.AllowIP(ip.IP) {
+			return HostNotAllowedError{Host: addr}
+		}
+	}
+	return nil
+}
+
+// NewAllowedHostsDialer decorates the received dialer, so the connections to destinations
+// not covered by the policy are rejected before being opened
+func NewAllowedHostsDialer(p *HostPolicy, next DialContextFunc) DialContextFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if err := p.Check(ctx, addr); err != nil {
+			return nil, err
+		}
+		return next(ctx, network, addr)
+	}
+}
+
+// NewAllowedHostsExecutor decorates the received executor, so the requests to urls not covered
+// by the policy are rejected before being sent, even when the connection goes through a proxy
+func NewAllowedHostsExecutor(p *HostPolicy, next HTTPRequestExecutor) HTTPRequestExecutor {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		if _, ok := UnixSocketFromContext(ctx); ok {
+			return next(ctx, req)
+		}
+		addr := req.URL.Host
+		if req.URL.Port() == "" {
+			port := "80"
+			if req.URL.Scheme == "https" {
+				port = "443"
+			}
+			addr = net.JoinHostPort(req.URL.Hostname(), port)
+		}
+		if err := p.Check(ctx, addr); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+func splitHostPort(addr string) (string, int, error) {
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port in the address %s", addr)
+	}
+	return host, port, nil
+}
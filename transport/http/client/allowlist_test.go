@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestAllowedHostsConfigGetter(t *testing.T) {
+	cfg, ok := AllowedHostsConfigGetter(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"allowed_hosts": map[string]interface{}{
+				"domains": []interface{}{"api.example.com"},
+				"cidrs":   []interface{}{"10.0.0.0/8"},
+				"ports":   []interface{}{443.0},
+			},
+		},
+	})
+	if !ok {
+		t.Error("the config should be parsed")
+		return
+	}
+	if len(cfg.Domains) != 1 || len(cfg.CIDRs) != 1 || len(cfg.Ports) != 1 || cfg.Ports[0] != 443 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	if _, ok := AllowedHostsConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the allowlist should not be enabled")
+	}
+}
+
+func TestHostPolicy_Check(t *testing.T) {
+	p, err := NewHostPolicy(AllowedHostsConfig{
+		Domains: []string{"api.example.com", "*.internal.example.com"},
+		CIDRs:   []string{"127.0.0.0/8", "10.1.2.3"},
+		Ports:   []int{443, 8080},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for addr, allowed := range map[string]bool{
+		"api.example.com:443":           true,
+		"API.example.com.:8080":         true,
+		"api.example.com:22":            false,
+		"a.internal.example.com:443":    true,
+		"internal.example.com.evil:443": false,
+		"evil.com:443":                  false,
+		"127.0.0.1:8080":                true,
+		"10.1.2.3:443":                  true,
+		"10.1.2.4:443":                  false,
+		"169.254.169.254:443":           false,
+		"[::1]:443":                     false,
+	} {
+		err := p.Check(context.Background(), addr)
+		if allowed && err != nil {
+			t.Errorf("%s should be allowed: %v", addr, err)
+		}
+		if !allowed && err == nil {
+			t.Errorf("%s should be rejected", addr)
+		}
+	}
+
+	if _, err := NewHostPolicy(AllowedHostsConfig{CIDRs: []string{"not-an-ip"}}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestHostPolicy_denyByDefault(t *testing.T) {
+	p, _ := NewHostPolicy(AllowedHostsConfig{})
+	if err := p.Check(context.Background(), "localhost:80"); err == nil {
+		t.Error("an empty allowlist should reject everything")
+	}
+}
+
+func TestNewAllowedHostsDialer(t *testing.T) {
+	p, _ := NewHostPolicy(AllowedHostsConfig{CIDRs: []string{"10.0.0.0/8"}})
+	var called bool
+	dialer := NewAllowedHostsDialer(p, func(_ context.Context, _, _ string) (net.Conn, error) {
+		called = true
+		return nil, nil
+	})
+
+	_, err := dialer(context.Background(), "tcp", "127.0.0.1:80")
+	if !errors.As(err, &HostNotAllowedError{}) || called {
+		t.Errorf("unexpected result: %v", err)
+	}
+	if _, err := dialer(context.Background(), "tcp", "10.0.0.1:80"); err != nil || !called {
+		t.Errorf("unexpected result: %v", err)
+	}
+}
+
+func TestNewAllowedHostsExecutor(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer s.Close()
+
+	p, _ := NewHostPolicy(AllowedHostsConfig{CIDRs: []string{"127.0.0.1/32"}})
+	executor := NewAllowedHostsExecutor(p, DefaultHTTPRequestExecutor(NewHTTPClient))
+
+	req, _ := http.NewRequest("GET", s.URL, http.NoBody)
+	resp, err := executor(context.Background(), req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+
+	req, _ = http.NewRequest("GET", "http://169.254.169.254/latest/meta-data", http.NoBody)
+	if _, err := executor(context.Background(), req); !errors.As(err, &HostNotAllowedError{}) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
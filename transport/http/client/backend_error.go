@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// BackendError describes a failed request to a backend. The http proxies return it wrapping the
+// errors of their executors and the invalid status codes, so the loggers, the metrics and the
+// error encoder can tell which backend failed and why. It keeps the message of the wrapped error,
+// so the clients never get the details of the backends, and it unwraps to it, so errors.Is and
+// errors.As keep matching the original error
+type BackendError struct {
+	// Name is the name declared by the return_error_details option of the backend, if any
+	Name string
+	// URLPattern is the url pattern of the backend
+	URLPattern string
+	// URL is the requested url, without its query string
+	URL string
+	// Status is the status code returned by the backend, or zero without a response
+	Status int
+	// Elapsed is the time spent waiting for the backend
+	Elapsed time.Duration
+	// Retryable is true if sending the request again could succeed
+	Retryable bool
+	// Err is the wrapped error
+	Err error
+}
+
+// Error returns the message of the wrapped error
+func (e *BackendError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
+// Details returns a description of the failure with the metadata of the backend, for the logs
+func (e *BackendError) Details() string {
+	name := e.URLPattern
+	if e.Name != "" {
+		name = e.Name + " (" + e.URLPattern + ")"
+	}
+	status := "no response"
+	if e.Status != 0 {
+		status = fmt.Sprintf("status %d", e.Status)
+	}
+	return fmt.Sprintf("backend %s at %s failed after %s with %s (retryable: %t): %s", name, e.URL, e.Elapsed, status, e.Retryable, e.Err.Error())
+}
+
+// NewBackendError wraps the error of a request to the backend with its metadata. The nil errors
+// and the errors declaring their own status code, like the ones of the status handlers passing the
+// failures through, are returned as they are, so the routers keep rendering them
+func NewBackendError(remote *config.Backend, req *http.Request, status int, elapsed time.Duration, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(interface{ StatusCode() int }); ok {
+		return err
+	}
+	if _, ok := err.(*BackendError); ok {
+		return err
+	}
+	e := &BackendError{
+		Status:    status,
+		Elapsed:   elapsed,
+		Retryable: IsRetryable(status, err),
+		Err:       err,
+	}
+	if remote != nil {
+		e.URLPattern = remote.URLPattern
+		if m, ok := remote.ExtraConfig[Namespace].(map[string]interface{}); ok {
+			e.Name, _ = m["return_error_details"].(string)
+		}
+	}
+	if req != nil && req.URL != nil {
+		u := *req.URL
+		u.RawQuery = ""
+		u.User = nil
+		e.URL = u.String()
+	}
+	return e
+}
+
+// AsBackendError returns the first BackendError found in the chain of the error or in the errors
+// it aggregates, like the ones of the merged responses
+func AsBackendError(err error) (*BackendError, bool) {
+	var e *BackendError
+	if errors.As(err, &e) {
+		return e, true
+	}
+	var m interface{ Errors() []error }
+	if errors.As(err, &m) {
+		for _, err := range m.Errors() {
+			if e, ok := AsBackendError(err); ok {
+				return e, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// IsRetryable returns true if a request ending with the status code or the error could succeed if
+// sent again: the timeouts, the refused and reset connections, the closed connections and the
+// statuses signaling a temporary condition of the backend
+func IsRetryable(status int, err error) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	if status != 0 || err == nil {
+		return false
+	}
+	var e *BackendError
+	if errors.As(err, &e) {
+		return e.Retryable
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
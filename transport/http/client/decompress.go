@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const compressedPassthroughKey = "compressed_passthrough"
+
+// Decompressor returns a reader decompressing the received body
+type Decompressor func(io.Reader) (io.ReadCloser, error)
+
+var (
+	decompressors = map[string]Decompressor{
+		"gzip":    gzipDecompressor,
+		"x-gzip":  gzipDecompressor,
+		"deflate": deflateDecompressor,
+		"br":      brotliDecompressor,
+	}
+	decompressorsMu sync.RWMutex
+)
+
+// RegisterDecompressor adds a decompressor for the responses with the received content encoding,
+// like zstd, replacing the previous one, if any
+func RegisterDecompressor(contentEncoding string, d Decompressor) {
+	decompressorsMu.Lock()
+	decompressors[strings.ToLower(contentEncoding)] = d
+	decompressorsMu.Unlock()
+}
+
+func gzipDecompressor(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func brotliDecompressor(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(brotli.NewReader(r)), nil
+}
+
+// deflateDecompressor accepts both the zlib streams required by the spec and the raw deflate ones
+// sent by some servers
+func deflateDecompressor(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	h, err := br.Peek(2)
+	if err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// UnsupportedEncodingError is returned for the responses with a content encoding without a
+// registered decompressor
+type UnsupportedEncodingError struct {
+	Encoding string
+}
+
+// Error returns a string representation of the UnsupportedEncodingError
+func (u UnsupportedEncodingError) Error() string {
+	return "unsupported content encoding " + u.Encoding
+}
+
+// ErrorCode returns the code of the error, for the structured error responses
+func (UnsupportedEncodingError) ErrorCode() string { return "unsupported_content_encoding" }
+
+// CompressedPassthroughGetter returns true if the backend forwards the compressed bodies of its
+// responses, instead of decompressing them:
+//
+//	"github.com/devopsfaith/krakend/http": {
+//		"compressed_passthrough": true
+//	}
+func CompressedPassthroughGetter(e config.ExtraConfig) bool {
+	v, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	passthrough, _ := v[compressedPassthroughKey].(bool)
+	return passthrough
+}
+
+// NewDecompressionExecutor decorates the received executor, so the bodies of the responses are
+// decompressed with the decompressors of their content encodings, removing the Content-Encoding
+// and Content-Length headers. The Accept-Encoding headers forwarded to the backend are limited to
+// the supported encodings, and removed if the client accepts none of them. When strict, the
+// responses with unsupported encodings fail and the gzip bodies without a Content-Encoding header
+// are also decompressed, so the backends ignoring the negotiation can still be decoded.
+// Otherwise, the bodies with unsupported encodings are returned untouched. The partial contents
+// are never decompressed
+func NewDecompressionExecutor(strict bool, next HTTPRequestExecutor) HTTPRequestExecutor {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		if accept := req.Header.Get("Accept-Encoding"); accept != "" {
+			if accept = supportedEncodings(accept); accept != "" {
+				req.Header.Set("Accept-Encoding", accept)
+			} else {
+				req.Header.Del("Accept-Encoding")
+			}
+		}
+		resp, err := next(ctx, req)
+		if err != nil || resp == nil || resp.Body == nil {
+			return resp, err
+		}
+		if resp.StatusCode == http.StatusPartialContent {
+			// the content range refers to the encoded bytes, so the partial contents are forwarded
+			// untouched
+			return resp, nil
+		}
+
+		var encodings []string
+		for _, e := range strings.Split(resp.Header.Get("Content-Encoding"), ",") {
+			if e = strings.ToLower(strings.TrimSpace(e)); e != "" && e != "identity" {
+				encodings = append(encodings, e)
+			}
+		}
+		if len(encodings) == 0 {
+			if strict {
+				resp.Body = sniffGzip(resp.Body)
+			}
+			return resp, nil
+		}
+
+		body := resp.Body
+		// the encodings are listed in the order they were applied
+		for i := len(encodings) - 1; i >= 0; i-- {
+			decompressorsMu.RLock()
+			d, ok := decompressors[encodings[i]]
+			decompressorsMu.RUnlock()
+			if !ok {
+				if !strict && body == resp.Body {
+					return resp, nil
+				}
+				resp.Body.Close()
+				return nil, UnsupportedEncodingError{Encoding: encodings[i]}
+			}
+			r, err := d(body)
+			if err != nil {
+				resp.Body.Close()
+				return nil, fmt.Errorf("decompressing the %s body: %w", encodings[i], err)
+			}
+			body = r
+		}
+
+		resp.Body = &decompressedBody{Reader: body, closers: []io.Closer{body, resp.Body}}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+		return resp, nil
+	}
+}
+
+// supportedEncodings returns the codings of the Accept-Encoding header with a decompressor,
+// keeping their quality values
+func supportedEncodings(accept string) string {
+	var res []string
+	decompressorsMu.RLock()
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		name := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		if _, ok := decompressors[name]; ok || name == "identity" {
+			res = append(res, part)
+		}
+	}
+	decompressorsMu.RUnlock()
+	return strings.Join(res, ", ")
+}
+
+// sniffGzip returns a body decompressing the gzip streams and returning the rest untouched
+func sniffGzip(body io.ReadCloser) io.ReadCloser {
+	br := bufio.NewReader(body)
+	h, err := br.Peek(2)
+	if err != nil || h[0] != 0x1f || h[1] != 0x8b {
+		return &decompressedBody{Reader: br, closers: []io.Closer{body}}
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return &decompressedBody{Reader: br, closers: []io.Closer{body}}
+	}
+	return &decompressedBody{Reader: zr, closers: []io.Closer{zr, body}}
+}
+
+type decompressedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decompressedBody) Close() error {
+	var err error
+	for _, c := range d.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func compress(t *testing.T, encoding, s string) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(buf)
+	case "zlib":
+		w = zlib.NewWriter(buf)
+	case "flate":
+		w, _ = flate.NewWriter(buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(buf)
+	}
+	io.WriteString(w, s)
+	w.Close()
+	return buf.Bytes()
+}
+
+func staticExecutor(body []byte, contentEncoding string, accept *string) HTTPRequestExecutor {
+	return func(_ context.Context, req *http.Request) (*http.Response, error) {
+		*accept = req.Header.Get("Accept-Encoding")
+		resp := &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Length": {"42"}},
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+		}
+		if contentEncoding != "" {
+			resp.Header.Set("Content-Encoding", contentEncoding)
+		}
+		return resp, nil
+	}
+}
+
+func TestNewDecompressionExecutor(t *testing.T) {
+	const msg = `{"supu":"tupu"}`
+	for _, tc := range []struct {
+		name            string
+		body            []byte
+		contentEncoding string
+		strict          bool
+		expected        string
+	}{
+		{"gzip", compress(t, "gzip", msg), "gzip", false, msg},
+		{"zlib deflate", compress(t, "zlib", msg), "deflate", true, msg},
+		{"raw deflate", compress(t, "flate", msg), "Deflate", true, msg},
+		{"stacked", compress(t, "gzip", string(compress(t, "zlib", msg))), "deflate, gzip", true, msg},
+		{"identity", []byte(msg), "identity", true, msg},
+		{"sniffed gzip", compress(t, "gzip", msg), "", true, msg},
+		{"plain", []byte(msg), "", true, msg},
+		{"not sniffed", compress(t, "gzip", msg), "", false, string(compress(t, "gzip", msg))},
+		{"brotli", compress(t, "br", msg), "br", true, msg},
+		{"unsupported", []byte(msg), "zstd", false, msg},
+	} {
+		var accept string
+		req, _ := http.NewRequest("GET", "http://example.com", nil)
+		req.Header.Set("Accept-Encoding", "zstd, br, gzip;q=0.8, identity")
+		resp, err := NewDecompressionExecutor(tc.strict, staticExecutor(tc.body, tc.contentEncoding, &accept))(context.Background(), req)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || string(b) != tc.expected {
+			t.Errorf("%s: unexpected body %q %v", tc.name, b, err)
+		}
+		if accept != "br, gzip;q=0.8, identity" {
+			t.Errorf("%s: unexpected accept encoding %s", tc.name, accept)
+		}
+		if tc.contentEncoding != "" && tc.contentEncoding != "zstd" && tc.contentEncoding != "identity" {
+			if resp.Header.Get("Content-Encoding") != "" || resp.Header.Get("Content-Length") != "" || resp.ContentLength != -1 {
+				t.Errorf("%s: unexpected headers %v", tc.name, resp.Header)
+			}
+		}
+	}
+}
+
+func TestNewDecompressionExecutor_unsupported(t *testing.T) {
+	var accept string
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	_, err := NewDecompressionExecutor(true, staticExecutor([]byte("x"), "zstd", &accept))(context.Background(), req)
+	var e UnsupportedEncodingError
+	if !errors.As(err, &e) || e.Encoding != "zstd" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if accept != "" {
+		t.Errorf("the accept encoding should not be added: %s", accept)
+	}
+	req.Header.Set("Accept-Encoding", "zstd")
+	NewDecompressionExecutor(true, staticExecutor([]byte("x"), "zstd", &accept))(context.Background(), req)
+	if accept != "" {
+		t.Errorf("the unsupported accept encoding should be removed: %s", accept)
+	}
+
+	RegisterDecompressor("zstd", func(r io.Reader) (io.ReadCloser, error) {
+		b, _ := io.ReadAll(r)
+		return io.NopCloser(strings.NewReader(strings.ToUpper(string(b)))), nil
+	})
+	defer func() {
+		decompressorsMu.Lock()
+		delete(decompressors, "zstd")
+		decompressorsMu.Unlock()
+	}()
+	resp, err := NewDecompressionExecutor(true, staticExecutor([]byte("x"), "zstd", &accept))(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(resp.Body); string(b) != "X" {
+		t.Errorf("unexpected body %q", b)
+	}
+}
+
+func TestNewDecompressionExecutor_partialContent(t *testing.T) {
+	body := compress(t, "gzip", `{"supu":"tupu"}`)[:10]
+	var accept string
+	next := staticExecutor(body, "gzip", &accept)
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	resp, err := NewDecompressionExecutor(true, func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		resp, err := next(ctx, req)
+		resp.StatusCode = http.StatusPartialContent
+		return resp, err
+	})(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(resp.Body); !bytes.Equal(b, body) {
+		t.Errorf("unexpected body %q", b)
+	}
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("unexpected headers %v", resp.Header)
+	}
+}
+
+func TestCompressedPassthroughGetter(t *testing.T) {
+	if CompressedPassthroughGetter(config.ExtraConfig{}) {
+		t.Error("the passthrough should be disabled by default")
+	}
+	if !CompressedPassthroughGetter(config.ExtraConfig{Namespace: map[string]interface{}{"compressed_passthrough": true}}) {
+		t.Error("the passthrough should be enabled")
+	}
+}
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const dialerKey = "dialer"
+
+// DialerConfig contains the dialer settings of a backend
+type DialerConfig struct {
+	// Timeout is the maximum time to establish a connection
+	Timeout time.Duration
+	// KeepAlive is the interval between the TCP keep-alive probes. Negative values disable them
+	KeepAlive time.Duration
+	// NoDelay disables the Nagle's algorithm on the connections. It is enabled by default
+	NoDelay bool
+	// LocalAddress is the source IP the connections are bound to
+	LocalAddress string
+}
+
+// DialerConfigGetter parses the dialer settings from the extra config of a backend. It returns
+// an error if the declared settings are not valid:
+//
+//	"github.com/devopsfaith/krakend/http": {
+//		"dialer": {
+//			"timeout": "500ms",
+//			"keep_alive": "15s",
+//			"no_delay": false,
+//			"local_address": "10.0.0.5"
+//		}
+//	}
+func DialerConfigGetter(e config.ExtraConfig) (DialerConfig, bool, error) {
+	cfg := DialerConfig{NoDelay: true}
+	v, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	tmp, ok := v[dialerKey].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	for k, d := range map[string]*time.Duration{"timeout": &cfg.Timeout, "keep_alive": &cfg.KeepAlive} {
+		s, ok := tmp[k].(string)
+		if !ok {
+			continue
+		}
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return cfg, true, fmt.Errorf("invalid dialer %s: %w", k, err)
+		}
+		*d = parsed
+	}
+	if b, ok := tmp["no_delay"].(bool); ok {
+		cfg.NoDelay = b
+	}
+	cfg.LocalAddress, _ = tmp["local_address"].(string)
+	if cfg.LocalAddress != "" && net.ParseIP(cfg.LocalAddress) == nil {
+		return cfg, true, fmt.Errorf("invalid dialer local_address %s", cfg.LocalAddress)
+	}
+	return cfg, true, nil
+}
+
+// NewDialer returns a DialContextFunc with the settings of the config. The dialer is decorated
+// with the registered DialContextDecorator, so it keeps the service level policies
+func NewDialer(cfg DialerConfig) DialContextFunc {
+	d := &net.Dialer{
+		Timeout:   cfg.Timeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+	if cfg.LocalAddress != "" {
+		d.LocalAddr = &net.TCPAddr{IP: net.ParseIP(cfg.LocalAddress)}
+	}
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if tc, ok := conn.(*net.TCPConn); ok && !cfg.NoDelay {
+			tc.SetNoDelay(false)
+		}
+		return conn, nil
+	}
+	return getDialContextDecorator()(dial)
+}
+
+// DialContextDecorator wraps the dialers of the transports
+type DialContextDecorator func(DialContextFunc) DialContextFunc
+
+var (
+	dialDecorator   DialContextDecorator = NewUnixSocketDialer
+	dialDecoratorMu sync.RWMutex
+)
+
+// SetDialContextDecorator sets the decorator applied to the dialers created by NewDialer. The
+// service transport registers here the decorators it applies to its own dialer
+func SetDialContextDecorator(d DialContextDecorator) {
+	dialDecoratorMu.Lock()
+	dialDecorator = d
+	dialDecoratorMu.Unlock()
+}
+
+func getDialContextDecorator() DialContextDecorator {
+	dialDecoratorMu.RLock()
+	defer dialDecoratorMu.RUnlock()
+	return dialDecorator
+}
+
+// NewDialerHTTPClientFactory returns a HTTPClientFactory creating a dedicated http client dialing
+// the connections with the received settings and using the TLS config, if any. The transport of
+// the client is a clone of the http default transport, lazily created, so it inherits the
+// timeouts and pool settings of the service
+func NewDialerHTTPClientFactory(cfg DialerConfig, tlsConfig *tls.Config) HTTPClientFactory {
+	var (
+		once sync.Once
+		c    *http.Client
+	)
+	return func(_ context.Context) *http.Client {
+		once.Do(func() {
+			var t *http.Transport
+			if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+				t = dt.Clone()
+			} else {
+				t = &http.Transport{Proxy: http.ProxyFromEnvironment}
+			}
+			t.DialContext = NewDialer(cfg)
+			if tlsConfig != nil {
+				t.TLSClientConfig = tlsConfig
+			}
+			c = &http.Client{Transport: t}
+		})
+		return c
+	}
+}
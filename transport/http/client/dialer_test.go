@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestDialerConfigGetter(t *testing.T) {
+	if _, ok, _ := DialerConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the config should not be found")
+	}
+
+	cfg, ok, err := DialerConfigGetter(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"dialer": map[string]interface{}{
+				"timeout":       "500ms",
+				"keep_alive":    "15s",
+				"no_delay":      false,
+				"local_address": "127.0.0.1",
+			},
+		},
+	})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if cfg.Timeout != 500*time.Millisecond || cfg.KeepAlive != 15*time.Second || cfg.NoDelay || cfg.LocalAddress != "127.0.0.1" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	for _, tmp := range []map[string]interface{}{
+		{"timeout": "fast"},
+		{"local_address": "localhost"},
+	} {
+		if _, _, err := DialerConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"dialer": tmp}}); err == nil {
+			t.Errorf("%v: expecting an error", tmp)
+		}
+	}
+}
+
+func TestNewDialerHTTPClientFactory(t *testing.T) {
+	var remote string
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		remote = r.RemoteAddr
+	}))
+	defer ts.Close()
+
+	decorated := 0
+	SetDialContextDecorator(func(next DialContextFunc) DialContextFunc {
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			decorated++
+			return next(ctx, network, addr)
+		}
+	})
+	defer SetDialContextDecorator(NewUnixSocketDialer)
+
+	cf := NewDialerHTTPClientFactory(DialerConfig{Timeout: time.Second, LocalAddress: "127.0.0.1"}, nil)
+	resp, err := cf(context.Background()).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if host, _, _ := net.SplitHostPort(remote); host != "127.0.0.1" {
+		t.Errorf("unexpected source address: %s", remote)
+	}
+	if decorated != 1 {
+		t.Errorf("the dialer should be decorated. calls: %d", decorated)
+	}
+}
@@ -0,0 +1,24 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/governor"
+)
+
+// NewGovernorExecutor decorates the executor, so the bytes of the response bodies are reserved
+// with the governor ticket of the request as they are read. The bodies exceeding the buffered
+// bytes of the governor fail with a governor.BufferFullError
+func NewGovernorExecutor(next HTTPRequestExecutor) HTTPRequestExecutor {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		resp, err := next(ctx, req)
+		if err != nil || resp == nil || resp.Body == nil {
+			return resp, err
+		}
+		resp.Body = governor.NewReader(ctx, resp.Body)
+		return resp, nil
+	}
+}
@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/governor"
+)
+
+func TestNewGovernorExecutor(t *testing.T) {
+	g, _ := governor.New(governor.Config{MaxBufferedBytes: 4})
+	tk, _ := g.Admit()
+	defer tk.Done()
+
+	re := NewGovernorExecutor(func(_ context.Context, _ *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("too large"))}, nil
+	})
+	req, _ := http.NewRequest("GET", "http://example.com", http.NoBody)
+
+	resp, err := re(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := io.ReadAll(resp.Body); err != nil || string(b) != "too large" {
+		t.Errorf("the requests without a ticket are not governed. body: %s, err: %v", b, err)
+	}
+
+	resp, err = re(governor.NewContext(context.Background(), tk), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(resp.Body); err != governor.ErrBufferFull {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luraproject/lura/v2/transport/http/client"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// NewHTTPClientFactory returns a factory of the http clients talking HTTP/2 to the gRPC services.
+// The services with plain http hosts are reached with HTTP/2 over cleartext (h2c)
+func NewHTTPClientFactory(tlsConfig *tls.Config) client.HTTPClientFactory {
+	c := &http.Client{Transport: &transport{
+		h2: &http2.Transport{TLSClientConfig: tlsConfig},
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+	}}
+	return func(_ context.Context) *http.Client { return c }
+}
+
+type transport struct {
+	h2  http.RoundTripper
+	h2c http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(r)
+	}
+	return t.h2.RoundTrip(r)
+}
+
+// NewExecutor decorates the received executor, so the requests are sent to the unary gRPC method
+// of their path. The request message is built from the JSON body and the query string params of
+// the request and the response message is returned as a JSON document. The responses with a
+// status other than OK get the equivalent HTTP status and a JSON body with the gRPC code and
+// message
+func NewExecutor(files *protoregistry.Files, next client.HTTPRequestExecutor) client.HTTPRequestExecutor {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		md, err := FindMethod(files, req.URL.Path)
+		if err != nil {
+			return nil, err
+		}
+		if md.IsStreamingClient() {
+			return nil, fmt.Errorf("grpc: the client streaming method %s is not supported", md.FullName())
+		}
+
+		var body []byte
+		if req.Body != nil {
+			body, err = io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		msg, err := FromJSON(md.Input(), body)
+		if err != nil {
+			return nil, fmt.Errorf("grpc: decoding the request of %s: %w", md.FullName(), err)
+		}
+		if err := SetFields(msg, req.URL.Query()); err != nil {
+			return nil, err
+		}
+		buf := new(bytes.Buffer)
+		if err := WriteFrame(buf, msg); err != nil {
+			return nil, err
+		}
+
+		out := req.Clone(ctx)
+		out.Method = http.MethodPost
+		out.URL.RawQuery = ""
+		out.Body = io.NopCloser(buf)
+		out.ContentLength = int64(buf.Len())
+		out.Header.Del("Content-Length")
+		out.Header.Set("Content-Type", ContentType)
+		out.Header.Set("Te", "trailers")
+		if deadline, ok := ctx.Deadline(); ok {
+			out.Header.Set("Grpc-Timeout", timeoutHeader(time.Until(deadline)))
+		}
+
+		resp, err := next(ctx, out)
+		if err != nil {
+			return nil, err
+		}
+		return transcodeResponse(md, resp)
+	}
+}
+
+// timeoutHeader encodes the timeout in milliseconds, the resolution of the backend timeouts
+func timeoutHeader(d time.Duration) string {
+	ms := d.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	return strconv.FormatInt(ms, 10) + "m"
+}
+
+func transcodeResponse(md protoreflect.MethodDescriptor, resp *http.Response) (*http.Response, error) {
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), ContentType) {
+		// not a gRPC response, so the status handler of the backend takes care of it
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var msgs [][]byte
+	for {
+		f, err := ReadFrame(resp.Body)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		out := dynamicpb.NewMessage(md.Output())
+		if err := proto.Unmarshal(f.Payload, out); err != nil {
+			return nil, fmt.Errorf("grpc: decoding the response of %s: %w", md.FullName(), err)
+		}
+		b, err := ToJSON(out)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, b)
+	}
+
+	status, ok := statusFromHeader(resp.Trailer)
+	if !ok {
+		// trailers-only responses declare the status in the headers
+		status, ok = statusFromHeader(resp.Header)
+	}
+	if !ok {
+		status = StatusError{Code: Internal, Message: "missing grpc-status"}
+	}
+
+	var body []byte
+	switch {
+	case status.Code != OK:
+		body = []byte(fmt.Sprintf(`{"code":%d,"message":%s}`, status.Code, strconv.Quote(status.Message)))
+	case md.IsStreamingServer():
+		body = append(append([]byte("["), bytes.Join(msgs, []byte(","))...), ']')
+	case len(msgs) == 1:
+		body = msgs[0]
+	default:
+		return nil, fmt.Errorf("grpc: the unary method %s returned %d messages", md.FullName(), len(msgs))
+	}
+
+	res := &http.Response{
+		Status:        http.StatusText(status.StatusCode()),
+		StatusCode:    status.StatusCode(),
+		Proto:         resp.Proto,
+		ProtoMajor:    resp.ProtoMajor,
+		ProtoMinor:    resp.ProtoMinor,
+		Header:        resp.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       resp.Request,
+	}
+	res.Header.Set("Content-Type", "application/json")
+	res.Header.Del("Content-Length")
+	res.Header.Del("Grpc-Status")
+	res.Header.Del("Grpc-Message")
+	return res, nil
+}
+
+// SetFields sets the fields of the message with the received values. The fields are matched by
+// their JSON or proto name and the values of the unknown fields are ignored
+func SetFields(msg proto.Message, values url.Values) error {
+	m := msg.ProtoReflect()
+	fields := m.Descriptor().Fields()
+	for k, vs := range values {
+		fd := fields.ByJSONName(k)
+		if fd == nil {
+			fd = fields.ByName(protoreflect.Name(k))
+		}
+		if fd == nil || fd.IsMap() || fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+			continue
+		}
+		if fd.IsList() {
+			l := m.Mutable(fd).List()
+			for _, s := range vs {
+				v, err := parseScalar(fd, s)
+				if err != nil {
+					return err
+				}
+				l.Append(v)
+			}
+			continue
+		}
+		if len(vs) == 0 {
+			continue
+		}
+		v, err := parseScalar(fd, vs[0])
+		if err != nil {
+			return err
+		}
+		m.Set(fd, v)
+	}
+	return nil
+}
+
+func parseScalar(fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	var (
+		v   protoreflect.Value
+		err error
+	)
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		v = protoreflect.ValueOfString(s)
+	case protoreflect.BytesKind:
+		v = protoreflect.ValueOfBytes([]byte(s))
+	case protoreflect.BoolKind:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		v = protoreflect.ValueOfBool(b)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 32)
+		v = protoreflect.ValueOfInt32(int32(n))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 64)
+		v = protoreflect.ValueOfInt64(n)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var n uint64
+		n, err = strconv.ParseUint(s, 10, 32)
+		v = protoreflect.ValueOfUint32(uint32(n))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var n uint64
+		n, err = strconv.ParseUint(s, 10, 64)
+		v = protoreflect.ValueOfUint64(n)
+	case protoreflect.FloatKind:
+		var f float64
+		f, err = strconv.ParseFloat(s, 32)
+		v = protoreflect.ValueOfFloat32(float32(f))
+	case protoreflect.DoubleKind:
+		var f float64
+		f, err = strconv.ParseFloat(s, 64)
+		v = protoreflect.ValueOfFloat64(f)
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+			v = protoreflect.ValueOfEnum(ev.Number())
+			break
+		}
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 32)
+		v = protoreflect.ValueOfEnum(protoreflect.EnumNumber(n))
+	default:
+		err = fmt.Errorf("unsupported kind %s", fd.Kind())
+	}
+	if err != nil {
+		return v, fmt.Errorf("grpc: invalid value of the field %s: %w", fd.Name(), err)
+	}
+	return v, nil
+}
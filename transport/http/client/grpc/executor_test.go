@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func grpcResponse(t *testing.T, md protoreflect.MethodDescriptor, trailer http.Header, docs ...string) *http.Response {
+	buf := new(bytes.Buffer)
+	for _, d := range docs {
+		msg, err := FromJSON(md.Output(), []byte(d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		WriteFrame(buf, msg)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {ContentType}},
+		Body:       io.NopCloser(buf),
+		Trailer:    trailer,
+	}
+}
+
+func TestNewExecutor(t *testing.T) {
+	files, err := LoadDescriptors([]string{writeDescriptorSet(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, _ := FindMethod(files, "/orders.Orders/GetOrder")
+
+	re := NewExecutor(files, func(_ context.Context, req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodPost || req.URL.Path != "/orders.Orders/GetOrder" || req.URL.RawQuery != "" {
+			t.Errorf("unexpected request: %s %s", req.Method, req.URL)
+		}
+		if req.Header.Get("Content-Type") != ContentType || req.Header.Get("Te") != "trailers" || req.Header.Get("Grpc-Timeout") == "" {
+			t.Errorf("unexpected headers: %v", req.Header)
+		}
+		f, err := ReadFrame(req.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in := dynamicpb.NewMessage(md.Input())
+		if err := proto.Unmarshal(f.Payload, in); err != nil {
+			t.Fatal(err)
+		}
+		fields := md.Input().Fields()
+		if id := in.Get(fields.ByName("id")).String(); id != "42" {
+			t.Errorf("unexpected id: %s", id)
+		}
+		if limit := in.Get(fields.ByName("limit")).Int(); limit != 10 {
+			t.Errorf("unexpected limit: %d", limit)
+		}
+		if !in.Get(fields.ByName("full")).Bool() || in.Get(fields.ByName("tags")).List().Len() != 2 {
+			t.Errorf("unexpected message: %v", in)
+		}
+		return grpcResponse(t, md, http.Header{"Grpc-Status": {"0"}}, `{"id":"42","total":9.5}`), nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, _ := http.NewRequest("GET", "http://orders:50051/orders.Orders/GetOrder?id=42&full=true&tags=a&tags=b&other=1", strings.NewReader(`{"limit":10}`))
+	resp, err := re(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected response: %d %v", resp.StatusCode, resp.Header)
+	}
+	var data map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if data["id"] != "42" || data["total"] != 9.5 {
+		t.Errorf("unexpected data: %v", data)
+	}
+
+	req, _ = http.NewRequest("GET", "http://orders:50051/orders.Orders/GetOrder?limit=ten", nil)
+	if _, err := re(ctx, req); err == nil {
+		t.Error("expecting an error for the invalid field value")
+	}
+	req, _ = http.NewRequest("GET", "http://orders:50051/orders.Orders/Missing", nil)
+	if _, err := re(ctx, req); err == nil {
+		t.Error("expecting an error for the unknown method")
+	}
+}
+
+func TestNewExecutor_status(t *testing.T) {
+	files, err := LoadDescriptors([]string{writeDescriptorSet(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, _ := FindMethod(files, "/orders.Orders/GetOrder")
+
+	for _, tc := range []struct {
+		resp   *http.Response
+		status int
+		body   string
+	}{
+		{
+			resp:   grpcResponse(t, md, http.Header{"Grpc-Status": {"5"}, "Grpc-Message": {"order%2042%20not%20found"}}),
+			status: http.StatusNotFound,
+			body:   `{"code":5,"message":"order 42 not found"}`,
+		},
+		{
+			// trailers-only response
+			resp: &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {ContentType}, "Grpc-Status": {"14"}},
+				Body:       http.NoBody,
+			},
+			status: http.StatusServiceUnavailable,
+			body:   `{"code":14,"message":""}`,
+		},
+		{
+			resp:   grpcResponse(t, md, nil, `{"id":"42"}`),
+			status: http.StatusInternalServerError,
+			body:   `{"code":13,"message":"missing grpc-status"}`,
+		},
+		{
+			resp: &http.Response{
+				StatusCode: http.StatusBadGateway,
+				Header:     http.Header{"Content-Type": {"text/plain"}},
+				Body:       io.NopCloser(strings.NewReader("bad gateway")),
+			},
+			status: http.StatusBadGateway,
+			body:   "bad gateway",
+		},
+	} {
+		resp := tc.resp
+		re := NewExecutor(files, func(_ context.Context, _ *http.Request) (*http.Response, error) { return resp, nil })
+		req, _ := http.NewRequest("POST", "http://orders:50051/orders.Orders/GetOrder", nil)
+		res, err := re(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(res.Body)
+		if res.StatusCode != tc.status || string(b) != tc.body {
+			t.Errorf("unexpected response: %d %s", res.StatusCode, b)
+		}
+	}
+}
+
+func TestNewExecutor_serverStreaming(t *testing.T) {
+	files, err := LoadDescriptors([]string{writeDescriptorSet(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, _ := FindMethod(files, "/orders.Orders/ListOrders")
+	re := NewExecutor(files, func(_ context.Context, _ *http.Request) (*http.Response, error) {
+		return grpcResponse(t, md, http.Header{"Grpc-Status": {"0"}}, `{"id":"1"}`, `{"id":"2"}`), nil
+	})
+	req, _ := http.NewRequest("POST", "http://orders:50051/orders.Orders/ListOrders", nil)
+	resp, err := re(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 || data[1]["id"] != "2" {
+		t.Errorf("unexpected data: %v", data)
+	}
+
+	req, _ = http.NewRequest("POST", "http://orders:50051/orders.Orders/SaveOrders", nil)
+	if _, err := re(context.Background(), req); err == nil {
+		t.Error("expecting an error for the client streaming method")
+	}
+}
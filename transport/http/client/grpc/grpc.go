@@ -0,0 +1,231 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package grpc transcodes the JSON requests and responses of the gateway to the ones of the unary
+gRPC services, using the descriptor sets of their proto files:
+
+	protoc --include_imports --descriptor_set_out=orders.pb orders.proto
+
+The backends declare the descriptor sets and their url_pattern is the path of the gRPC method,
+optionally with the query string params to set on the request message:
+
+	{
+		"url_pattern": "/orders.Orders/GetOrder?id={id}",
+		"method": "POST",
+		"extra_config": {
+			"github.com/luraproject/lura/transport/http/client/grpc": {
+				"descriptor_sets": ["./orders.pb"]
+			}
+		}
+	}
+
+The configs declaring the former github.com/devopsfaith/krakend/transport/http/client/grpc
+namespace are still accepted, as an alias of the current one.
+*/
+package grpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Namespace is the key to use to store and access the gRPC config of the backends
+const Namespace = "github.com/luraproject/lura/transport/http/client/grpc"
+
+// LegacyNamespace is the former key of the gRPC config, moved to Namespace when the service
+// config is initialized
+const LegacyNamespace = "github.com/devopsfaith/krakend/transport/http/client/grpc"
+
+func init() {
+	config.RenameNamespace(LegacyNamespace, Namespace, config.NamespaceAliased)
+}
+
+// ContentType is the content type of the gRPC requests
+const ContentType = "application/grpc"
+
+// maxMessageSize bounds the size of the decoded messages
+const maxMessageSize = 64 << 20
+
+// ErrNoConfigFound is returned when the backend does not declare a gRPC config
+var ErrNoConfigFound = errors.New("grpc: no configuration found")
+
+// Config is the gRPC config of a backend
+type Config struct {
+	DescriptorSets []string `json:"descriptor_sets"`
+}
+
+// ConfigGetter parses the gRPC config of a backend
+func ConfigGetter(e config.ExtraConfig) (Config, error) {
+	var cfg Config
+	tmp, ok := e[Namespace]
+	if !ok {
+		return cfg, ErrNoConfigFound
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("grpc: parsing the config: %w", err)
+	}
+	if len(cfg.DescriptorSets) == 0 {
+		return cfg, errors.New("grpc: no descriptor sets declared")
+	}
+	return cfg, nil
+}
+
+// LoadDescriptors returns the registry of the files in the descriptor sets
+func LoadDescriptors(paths []string) (*protoregistry.Files, error) {
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("grpc: reading the descriptor set: %w", err)
+		}
+		tmp := &descriptorpb.FileDescriptorSet{}
+		if err := proto.Unmarshal(b, tmp); err != nil {
+			return nil, fmt.Errorf("grpc: parsing the descriptor set %s: %w", p, err)
+		}
+		set.File = append(set.File, tmp.File...)
+	}
+	files, err := protodesc.NewFiles(dedupFiles(set))
+	if err != nil {
+		return nil, fmt.Errorf("grpc: loading the descriptors: %w", err)
+	}
+	return files, nil
+}
+
+// dedupFiles removes the files declared by several sets, like the shared imports
+func dedupFiles(set *descriptorpb.FileDescriptorSet) *descriptorpb.FileDescriptorSet {
+	seen := map[string]struct{}{}
+	res := &descriptorpb.FileDescriptorSet{}
+	for _, f := range set.File {
+		if _, ok := seen[f.GetName()]; ok {
+			continue
+		}
+		seen[f.GetName()] = struct{}{}
+		res.File = append(res.File, f)
+	}
+	return res
+}
+
+// FindMethod returns the descriptor of the method with the received path (/package.Service/Method)
+func FindMethod(files *protoregistry.Files, path string) (protoreflect.MethodDescriptor, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("grpc: invalid method path %s", path)
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(parts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("grpc: unknown service %s", parts[0])
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("grpc: %s is not a service", parts[0])
+	}
+	md := sd.Methods().ByName(protoreflect.Name(parts[1]))
+	if md == nil {
+		return nil, fmt.Errorf("grpc: unknown method %s", path)
+	}
+	return md, nil
+}
+
+var unmarshalOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// FromJSON returns the message of the received type with the content of the JSON document. The
+// unknown fields are ignored
+func FromJSON(md protoreflect.MessageDescriptor, b []byte) (proto.Message, error) {
+	msg := dynamicpb.NewMessage(md)
+	if len(bytes.TrimSpace(b)) == 0 {
+		return msg, nil
+	}
+	if err := unmarshalOptions.Unmarshal(b, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// ToJSON returns the JSON document of the message
+func ToJSON(msg proto.Message) ([]byte, error) {
+	return protojson.Marshal(msg)
+}
+
+// WriteFrame writes the message with the length-prefixed framing of gRPC
+func WriteFrame(w io.Writer, msg proto.Message) error {
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return writeRawFrame(w, 0, b)
+}
+
+func writeRawFrame(w io.Writer, flags byte, b []byte) error {
+	var header [5]byte
+	header[0] = flags
+	binary.BigEndian.PutUint32(header[1:], uint32(len(b)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// Frame is a length-prefixed message of a gRPC stream
+type Frame struct {
+	Flags   byte
+	Payload []byte
+}
+
+// IsTrailer returns true for the frames with the trailers of the gRPC-Web responses
+func (f Frame) IsTrailer() bool { return f.Flags&0x80 != 0 }
+
+// ReadFrame reads the next frame of the stream. It returns io.EOF at the end of the stream
+func ReadFrame(r io.Reader) (Frame, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Frame{}, errors.New("grpc: truncated frame")
+		}
+		return Frame{}, err
+	}
+	f := Frame{Flags: header[0]}
+	if f.Flags&0x01 != 0 {
+		return f, errors.New("grpc: compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxMessageSize {
+		return f, fmt.Errorf("grpc: message of %d bytes exceeds the max size", size)
+	}
+	f.Payload = make([]byte, size)
+	if _, err := io.ReadFull(r, f.Payload); err != nil {
+		return f, errors.New("grpc: truncated frame")
+	}
+	return f, nil
+}
+
+// WriteTrailer writes the trailers of a gRPC-Web response as the last frame of the body
+func WriteTrailer(w io.Writer, trailer http.Header) error {
+	buf := new(bytes.Buffer)
+	for k, vs := range trailer {
+		for _, v := range vs {
+			fmt.Fprintf(buf, "%s: %s\r\n", strings.ToLower(k), v)
+		}
+	}
+	return writeRawFrame(w, 0x80, buf.Bytes())
+}
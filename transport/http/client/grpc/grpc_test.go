@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package grpc
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// writeDescriptorSet stores the descriptor set of the orders service:
+//
+//	message GetOrderRequest { string id = 1; int32 limit = 2; repeated string tags = 3; bool full = 4; }
+//	message Order { string id = 1; double total = 2; }
+//	service Orders {
+//		rpc GetOrder(GetOrderRequest) returns (Order);
+//		rpc ListOrders(GetOrderRequest) returns (stream Order);
+//		rpc SaveOrders(stream Order) returns (Order);
+//	}
+func writeDescriptorSet(t *testing.T) string {
+	field := func(name string, n int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(n),
+			Type:     typ.Enum(),
+			Label:    label.Enum(),
+		}
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("orders.proto"),
+		Package: proto.String("orders"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("GetOrderRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional),
+					field("limit", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional),
+					field("tags", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_LABEL_REPEATED),
+					field("full", 4, descriptorpb.FieldDescriptorProto_TYPE_BOOL, optional),
+				},
+			},
+			{
+				Name: proto.String("Order"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional),
+					field("total", 2, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, optional),
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Orders"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{Name: proto.String("GetOrder"), InputType: proto.String(".orders.GetOrderRequest"), OutputType: proto.String(".orders.Order")},
+				{Name: proto.String("ListOrders"), InputType: proto.String(".orders.GetOrderRequest"), OutputType: proto.String(".orders.Order"), ServerStreaming: proto.Bool(true)},
+				{Name: proto.String("SaveOrders"), InputType: proto.String(".orders.Order"), OutputType: proto.String(".orders.Order"), ClientStreaming: proto.Bool(true)},
+			},
+		}},
+	}
+	b, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(t.TempDir(), "orders.pb")
+	if err := os.WriteFile(p, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestConfigGetter(t *testing.T) {
+	if _, err := ConfigGetter(config.ExtraConfig{}); err != ErrNoConfigFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{}}); err == nil {
+		t.Error("expecting an error")
+	}
+	cfg, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"descriptor_sets": []interface{}{"a.pb", "b.pb"},
+	}})
+	if err != nil || len(cfg.DescriptorSets) != 2 {
+		t.Errorf("unexpected result. cfg: %+v, err: %v", cfg, err)
+	}
+}
+
+func TestConfigGetter_legacyNamespace(t *testing.T) {
+	cfg := config.ServiceConfig{
+		Version: config.ConfigVersion,
+		Endpoints: []*config.EndpointConfig{{
+			Endpoint: "/orders/{id}",
+			Method:   "GET",
+			Backend: []*config.Backend{{
+				URLPattern: "/orders.Orders/GetOrder",
+				Host:       []string{"http://127.0.0.1:8080"},
+				ExtraConfig: config.ExtraConfig{LegacyNamespace: map[string]interface{}{
+					"descriptor_sets": []interface{}{"a.pb"},
+				}},
+			}},
+		}},
+	}
+	if err := cfg.Init(); err != nil {
+		t.Fatal(err)
+	}
+	res, err := ConfigGetter(cfg.Endpoints[0].Backend[0].ExtraConfig)
+	if err != nil || len(res.DescriptorSets) != 1 {
+		t.Errorf("unexpected result. cfg: %+v, err: %v", res, err)
+	}
+}
+
+func TestFindMethod(t *testing.T) {
+	p := writeDescriptorSet(t)
+	files, err := LoadDescriptors([]string{p, p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := FindMethod(files, "/orders.Orders/GetOrder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.Input().FullName() != "orders.GetOrderRequest" || md.Output().FullName() != "orders.Order" {
+		t.Errorf("unexpected method: %s", md.FullName())
+	}
+	for _, path := range []string{"/orders.Orders", "/orders.Missing/GetOrder", "/orders.Order/GetOrder", "/orders.Orders/Missing"} {
+		if _, err := FindMethod(files, path); err == nil {
+			t.Errorf("%s: expecting an error", path)
+		}
+	}
+	if _, err := LoadDescriptors([]string{filepath.Join(t.TempDir(), "missing.pb")}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestFrames(t *testing.T) {
+	files, err := LoadDescriptors([]string{writeDescriptorSet(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, _ := FindMethod(files, "/orders.Orders/GetOrder")
+	msg, err := FromJSON(md.Output(), []byte(`{"id":"42","total":9.5,"unknown":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteFrame(buf, msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteTrailer(buf, http.Header{"Grpc-Status": {"0"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := ReadFrame(buf)
+	if err != nil || f.IsTrailer() {
+		t.Fatalf("unexpected frame: %+v, err: %v", f, err)
+	}
+	out := dynamicpb.NewMessage(md.Output())
+	if err := proto.Unmarshal(f.Payload, out); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ToJSON(out); string(bytes.ReplaceAll(b, []byte(" "), nil)) != `{"id":"42","total":9.5}` {
+		t.Errorf("unexpected message: %s", b)
+	}
+	f, err = ReadFrame(buf)
+	if err != nil || !f.IsTrailer() || string(f.Payload) != "grpc-status: 0\r\n" {
+		t.Errorf("unexpected trailer: %+v, err: %v", f, err)
+	}
+	if _, err := ReadFrame(buf); err != io.EOF {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := ReadFrame(bytes.NewReader([]byte{0, 0, 0, 0, 5, 1})); err == nil {
+		t.Error("expecting an error for the truncated frame")
+	}
+	if _, err := ReadFrame(bytes.NewReader([]byte{1, 0, 0, 0, 0})); err == nil {
+		t.Error("expecting an error for the compressed frame")
+	}
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package grpc
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Code is the status code of a gRPC response
+type Code int
+
+// The status codes of the gRPC responses
+const (
+	OK Code = iota
+	Canceled
+	Unknown
+	InvalidArgument
+	DeadlineExceeded
+	NotFound
+	AlreadyExists
+	PermissionDenied
+	ResourceExhausted
+	FailedPrecondition
+	Aborted
+	OutOfRange
+	Unimplemented
+	Internal
+	Unavailable
+	DataLoss
+	Unauthenticated
+)
+
+var httpStatuses = map[Code]int{
+	OK:                 http.StatusOK,
+	Canceled:           499,
+	Unknown:            http.StatusInternalServerError,
+	InvalidArgument:    http.StatusBadRequest,
+	DeadlineExceeded:   http.StatusGatewayTimeout,
+	NotFound:           http.StatusNotFound,
+	AlreadyExists:      http.StatusConflict,
+	PermissionDenied:   http.StatusForbidden,
+	ResourceExhausted:  http.StatusTooManyRequests,
+	FailedPrecondition: http.StatusBadRequest,
+	Aborted:            http.StatusConflict,
+	OutOfRange:         http.StatusBadRequest,
+	Unimplemented:      http.StatusNotImplemented,
+	Internal:           http.StatusInternalServerError,
+	Unavailable:        http.StatusServiceUnavailable,
+	DataLoss:           http.StatusInternalServerError,
+	Unauthenticated:    http.StatusUnauthorized,
+}
+
+// HTTPStatus returns the HTTP status equivalent to the gRPC code
+func (c Code) HTTPStatus() int {
+	if s, ok := httpStatuses[c]; ok {
+		return s
+	}
+	return http.StatusInternalServerError
+}
+
+// CodeFromHTTPStatus returns the gRPC code equivalent to the HTTP status
+func CodeFromHTTPStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return InvalidArgument
+	case http.StatusUnauthorized:
+		return Unauthenticated
+	case http.StatusForbidden:
+		return PermissionDenied
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusConflict:
+		return AlreadyExists
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return ResourceExhausted
+	case 499:
+		return Canceled
+	case http.StatusNotImplemented:
+		return Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return Unavailable
+	case http.StatusGatewayTimeout:
+		return DeadlineExceeded
+	}
+	switch {
+	case status >= 200 && status < 300:
+		return OK
+	case status >= 500:
+		return Internal
+	}
+	return Unknown
+}
+
+// StatusError is the error of a gRPC response with a status other than OK
+type StatusError struct {
+	Code    Code
+	Message string
+}
+
+// Error returns a string representation of the StatusError
+func (s StatusError) Error() string {
+	return fmt.Sprintf("grpc: status %d: %s", s.Code, s.Message)
+}
+
+// StatusCode returns the HTTP status equivalent to the gRPC code
+func (s StatusError) StatusCode() int { return s.Code.HTTPStatus() }
+
+// statusFromHeader returns the status declared by the grpc-status and grpc-message headers
+func statusFromHeader(h http.Header) (StatusError, bool) {
+	v := h.Get("Grpc-Status")
+	if v == "" {
+		return StatusError{}, false
+	}
+	var code int
+	if _, err := fmt.Sscanf(v, "%d", &code); err != nil {
+		return StatusError{Code: Unknown, Message: "invalid grpc-status " + v}, true
+	}
+	msg, err := url.PathUnescape(h.Get("Grpc-Message"))
+	if err != nil {
+		msg = h.Get("Grpc-Message")
+	}
+	return StatusError{Code: Code(code), Message: msg}, true
+}
@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package grpc
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCode_HTTPStatus(t *testing.T) {
+	for code, status := range map[Code]int{
+		OK:               http.StatusOK,
+		InvalidArgument:  http.StatusBadRequest,
+		NotFound:         http.StatusNotFound,
+		Unauthenticated:  http.StatusUnauthorized,
+		DeadlineExceeded: http.StatusGatewayTimeout,
+		Code(42):         http.StatusInternalServerError,
+	} {
+		if s := code.HTTPStatus(); s != status {
+			t.Errorf("%d: have %d, want %d", code, s, status)
+		}
+	}
+}
+
+func TestCodeFromHTTPStatus(t *testing.T) {
+	for status, code := range map[int]Code{
+		http.StatusOK:                  OK,
+		http.StatusCreated:             OK,
+		http.StatusBadRequest:          InvalidArgument,
+		http.StatusForbidden:           PermissionDenied,
+		http.StatusTooManyRequests:     ResourceExhausted,
+		http.StatusBadGateway:          Unavailable,
+		http.StatusInternalServerError: Internal,
+		http.StatusTeapot:              Unknown,
+	} {
+		if c := CodeFromHTTPStatus(status); c != code {
+			t.Errorf("%d: have %d, want %d", status, c, code)
+		}
+	}
+}
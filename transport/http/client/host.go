@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const (
+	hostHeaderKey = "host_header"
+	serverNameKey = "tls_server_name"
+)
+
+// The modes of the host header config
+const (
+	// HostPreserve sends the Host header of the request received by the router
+	HostPreserve = "preserve"
+	// HostFixed sends the Host header declared by the backend
+	HostFixed = "fixed"
+	// HostParam sends the value of a param of the request as the Host header
+	HostParam = "param"
+)
+
+// HostConfig tells how to set the Host header of the requests to a backend
+type HostConfig struct {
+	Mode  string
+	Value string
+	Param string
+}
+
+// HostConfigGetter parses the host header config from the extra config of a backend:
+//
+//	"github.com/devopsfaith/krakend/http": {
+//		"host_header": {
+//			"mode": "param",
+//			"param": "tenant"
+//		}
+//	}
+//
+// The fixed mode requires the value of the header and the param mode the name of the param
+func HostConfigGetter(e config.ExtraConfig) (HostConfig, bool, error) {
+	cfg := HostConfig{}
+	v, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	tmp, ok := v[hostHeaderKey].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	cfg.Mode, _ = tmp["mode"].(string)
+	cfg.Value, _ = tmp["value"].(string)
+	cfg.Param, _ = tmp["param"].(string)
+	switch cfg.Mode {
+	case HostPreserve:
+	case HostFixed:
+		if cfg.Value == "" {
+			return cfg, true, fmt.Errorf("the fixed %s requires a value", hostHeaderKey)
+		}
+	case HostParam:
+		if cfg.Param == "" {
+			return cfg, true, fmt.Errorf("the param %s requires a param", hostHeaderKey)
+		}
+	default:
+		return cfg, true, fmt.Errorf("unknown %s mode %q", hostHeaderKey, cfg.Mode)
+	}
+	return cfg, true, nil
+}
+
+// ServerNameGetter returns the server name to send in the TLS handshakes with the backend, if
+// the backend declares it:
+//
+//	"github.com/devopsfaith/krakend/http": {
+//		"tls_server_name": "origin.example.com"
+//	}
+func ServerNameGetter(e config.ExtraConfig) (string, bool) {
+	v, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	name, _ := v[serverNameKey].(string)
+	return name, name != ""
+}
+
+// WithServerName returns a copy of the TLS config sending the received server name, or a new one
+// if the config is nil
+func WithServerName(tlsConfig *tls.Config, name string) *tls.Config {
+	if tlsConfig == nil {
+		return &tls.Config{ServerName: name}
+	}
+	c := tlsConfig.Clone()
+	c.ServerName = name
+	return c
+}
+
+type hostKeyType struct{}
+
+var hostKey = hostKeyType{}
+
+// WithHost returns a copy of the context flagging the requests sent with it to use the received
+// Host header
+func WithHost(ctx context.Context, host string) context.Context {
+	return context.WithValue(ctx, hostKey, host)
+}
+
+// HostFromContext returns the Host header stored in the context, if any
+func HostFromContext(ctx context.Context) (string, bool) {
+	host, ok := ctx.Value(hostKey).(string)
+	return host, ok && host != ""
+}
+
+// NewHostExecutor decorates the executor, so the requests sent with a context containing a Host
+// header use it instead of the host of their URL
+func NewHostExecutor(next HTTPRequestExecutor) HTTPRequestExecutor {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		if host, ok := HostFromContext(ctx); ok {
+			req.Host = host
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestHostConfigGetter(t *testing.T) {
+	if _, ok, _ := HostConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the config should not be found")
+	}
+	cfg, ok, err := HostConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		hostHeaderKey: map[string]interface{}{"mode": "fixed", "value": "api.example.com"},
+	}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if cfg.Mode != HostFixed || cfg.Value != "api.example.com" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	for _, c := range []map[string]interface{}{
+		{},
+		{"mode": "unknown"},
+		{"mode": "fixed"},
+		{"mode": "param"},
+	} {
+		if _, _, err := HostConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{hostHeaderKey: c}}); err == nil {
+			t.Errorf("%v: expecting an error", c)
+		}
+	}
+}
+
+func TestServerNameGetter(t *testing.T) {
+	if _, ok := ServerNameGetter(config.ExtraConfig{}); ok {
+		t.Error("the server name should not be found")
+	}
+	name, ok := ServerNameGetter(config.ExtraConfig{Namespace: map[string]interface{}{serverNameKey: "origin.example.com"}})
+	if !ok || name != "origin.example.com" {
+		t.Errorf("unexpected server name: %s", name)
+	}
+
+	original := &tls.Config{MinVersion: tls.VersionTLS12}
+	c := WithServerName(original, name)
+	if c.ServerName != name || c.MinVersion != tls.VersionTLS12 || original.ServerName != "" {
+		t.Errorf("unexpected tls config: %+v", c)
+	}
+	if c := WithServerName(nil, name); c.ServerName != name {
+		t.Errorf("unexpected tls config: %+v", c)
+	}
+}
+
+func TestNewHostExecutor(t *testing.T) {
+	var received []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Host)
+	}))
+	defer ts.Close()
+
+	re := NewHostExecutor(DefaultHTTPRequestExecutor(NewHTTPClient))
+	for _, ctx := range []context.Context{
+		WithHost(context.Background(), "api.example.com"),
+		context.Background(),
+	} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		resp, err := re(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if len(received) != 2 || received[0] != "api.example.com" || received[1] != ts.Listener.Addr().String() {
+		t.Errorf("unexpected hosts %v", received)
+	}
+}
@@ -0,0 +1,247 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package oauth2 obtains and caches the service tokens of the OAuth2 client credentials grant, so the
+backends protected by an authorization server can be reached without plumbing the tokens by hand
+*/
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key for the backend's extra config
+const Namespace = "github.com/luraproject/lura/transport/http/client/oauth2"
+
+// ExpiryDelta is the time before the expiration of a token when it is considered expired, so the
+// tokens are renewed before the backends start rejecting them
+const ExpiryDelta = 10 * time.Second
+
+// ErrIncompleteConfig is returned when the config does not declare the client id, the client
+// secret or the token url
+var ErrIncompleteConfig = errors.New("oauth2: client_id, client_secret and token_url are required")
+
+// Config defines the client credentials used to obtain the tokens of a backend
+type Config struct {
+	ClientID       string
+	ClientSecret   string
+	TokenURL       string
+	Scopes         []string
+	EndpointParams url.Values
+}
+
+// ConfigGetter parses the oauth2 config from the extra config of a backend
+func ConfigGetter(e config.ExtraConfig) (Config, bool) {
+	cfg := Config{EndpointParams: url.Values{}}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false
+	}
+	cfg.ClientID, _ = tmp["client_id"].(string)
+	cfg.ClientSecret, _ = tmp["client_secret"].(string)
+	cfg.TokenURL, _ = tmp["token_url"].(string)
+	if scopes, ok := tmp["scopes"].([]interface{}); ok {
+		for _, s := range scopes {
+			if v, ok := s.(string); ok {
+				cfg.Scopes = append(cfg.Scopes, v)
+			}
+		}
+	}
+	if params, ok := tmp["endpoint_params"].(map[string]interface{}); ok {
+		for k, v := range params {
+			switch t := v.(type) {
+			case string:
+				cfg.EndpointParams.Add(k, t)
+			case []interface{}:
+				for _, e := range t {
+					if s, ok := e.(string); ok {
+						cfg.EndpointParams.Add(k, s)
+					}
+				}
+			}
+		}
+	}
+	return cfg, true
+}
+
+// Validate checks the required fields of the config
+func (c Config) Validate() error {
+	if c.ClientID == "" || c.ClientSecret == "" || c.TokenURL == "" {
+		return ErrIncompleteConfig
+	}
+	return nil
+}
+
+func (c Config) key() string {
+	scopes := append([]string{}, c.Scopes...)
+	sort.Strings(scopes)
+	return c.TokenURL + "|" + c.ClientID + "|" + strings.Join(scopes, " ") + "|" + c.EndpointParams.Encode()
+}
+
+// Token is an access token issued by the authorization server
+type Token struct {
+	AccessToken string
+	TokenType   string
+	Expiry      time.Time
+}
+
+// Valid returns true if the token is not empty and it is not about to expire
+func (t Token) Valid(now time.Time) bool {
+	return t.AccessToken != "" && (t.Expiry.IsZero() || now.Add(ExpiryDelta).Before(t.Expiry))
+}
+
+// AuthorizationHeader returns the value of the Authorization header carrying the token
+func (t Token) AuthorizationHeader() string {
+	typ := t.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	return typ + " " + t.AccessToken
+}
+
+// RetrieveError is returned when the authorization server rejects the token request
+type RetrieveError struct {
+	Code        int
+	ErrorCode   string
+	Description string
+}
+
+// Error returns a string representation of the RetrieveError
+func (r RetrieveError) Error() string {
+	if r.ErrorCode == "" {
+		return fmt.Sprintf("oauth2: token request failed with status code %d", r.Code)
+	}
+	if r.Description == "" {
+		return fmt.Sprintf("oauth2: token request failed with status code %d: %s", r.Code, r.ErrorCode)
+	}
+	return fmt.Sprintf("oauth2: token request failed with status code %d: %s (%s)", r.Code, r.ErrorCode, r.Description)
+}
+
+// TokenSource obtains the tokens with the client credentials grant and caches them until they
+// are about to expire. Concurrent callers wait for a single token request
+type TokenSource struct {
+	cfg    Config
+	client *http.Client
+	now    func() time.Time
+
+	mu    sync.Mutex
+	token Token
+}
+
+// NewTokenSource returns a TokenSource for the config. If the client is nil, the http default
+// client is used
+func NewTokenSource(cfg Config, c *http.Client) *TokenSource {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &TokenSource{cfg: cfg, client: c, now: time.Now}
+}
+
+var (
+	sources   = map[string]*TokenSource{}
+	sourcesMu sync.Mutex
+)
+
+// SharedTokenSource returns the TokenSource of the config, shared by all the backends declaring
+// the same token url, client and scopes, so they reuse the same tokens
+func SharedTokenSource(cfg Config) *TokenSource {
+	k := cfg.key()
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if ts, ok := sources[k]; ok {
+		return ts
+	}
+	ts := NewTokenSource(cfg, nil)
+	sources[k] = ts
+	return ts
+}
+
+// Token returns the cached token or requests a new one if it is missing or about to expire
+func (ts *TokenSource) Token(ctx context.Context) (Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token.Valid(ts.now()) {
+		return ts.token, nil
+	}
+	t, err := ts.retrieve(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+	ts.token = t
+	return t, nil
+}
+
+// Invalidate discards the cached token, so the next call to Token requests a new one. It should
+// be called when a backend rejects the token before its expiration
+func (ts *TokenSource) Invalidate() {
+	ts.mu.Lock()
+	ts.token = Token{}
+	ts.mu.Unlock()
+}
+
+func (ts *TokenSource) retrieve(ctx context.Context) (Token, error) {
+	form := url.Values{}
+	for k, vs := range ts.cfg.EndpointParams {
+		form[k] = vs
+	}
+	form.Set("grant_type", "client_credentials")
+	if len(ts.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(ts.cfg.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(ts.cfg.ClientID), url.QueryEscape(ts.cfg.ClientSecret))
+
+	requested := ts.now()
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return Token{}, fmt.Errorf("oauth2: requesting the token: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Token{}, fmt.Errorf("oauth2: reading the token response: %w", err)
+	}
+
+	var payload struct {
+		AccessToken      string      `json:"access_token"`
+		TokenType        string      `json:"token_type"`
+		ExpiresIn        json.Number `json:"expires_in"`
+		Error            string      `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+	}
+	decodeErr := json.Unmarshal(body, &payload)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Token{}, RetrieveError{Code: resp.StatusCode, ErrorCode: payload.Error, Description: payload.ErrorDescription}
+	}
+	if decodeErr != nil {
+		return Token{}, fmt.Errorf("oauth2: decoding the token response: %w", decodeErr)
+	}
+	if payload.AccessToken == "" {
+		return Token{}, errors.New("oauth2: the server returned an empty access token")
+	}
+
+	t := Token{AccessToken: payload.AccessToken, TokenType: payload.TokenType}
+	if secs, err := payload.ExpiresIn.Int64(); err == nil && secs > 0 {
+		t.Expiry = requested.Add(time.Duration(secs) * time.Second)
+	}
+	return t, nil
+}
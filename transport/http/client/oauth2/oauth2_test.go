@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestConfigGetter(t *testing.T) {
+	cfg, ok := ConfigGetter(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"client_id":       "id",
+			"client_secret":   "secret",
+			"token_url":       "https://auth/token",
+			"scopes":          []interface{}{"read", "write"},
+			"endpoint_params": map[string]interface{}{"audience": "api"},
+		},
+	})
+	if !ok {
+		t.Fatal("the config should be found")
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Error(err)
+	}
+	if len(cfg.Scopes) != 2 || cfg.EndpointParams.Get("audience") != "api" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if err := (Config{ClientID: "id"}).Validate(); err != ErrIncompleteConfig {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTokenSource_Token(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		id, secret, _ := r.BasicAuth()
+		r.ParseForm()
+		if id != "id" || secret != "s%26cret" || r.Form.Get("grant_type") != "client_credentials" ||
+			r.Form.Get("scope") != "read write" || r.Form.Get("audience") != "api" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":60}`, n)
+	}))
+	defer ts.Close()
+
+	src := NewTokenSource(Config{
+		ClientID:       "id",
+		ClientSecret:   "s&cret",
+		TokenURL:       ts.URL,
+		Scopes:         []string{"read", "write"},
+		EndpointParams: map[string][]string{"audience": {"api"}},
+	}, ts.Client())
+	now := time.Now()
+	src.now = func() time.Time { return now }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tok, err := src.Token(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if tok.AuthorizationHeader() != "Bearer token-1" {
+				t.Errorf("unexpected header: %s", tok.AuthorizationHeader())
+			}
+		}()
+	}
+	wg.Wait()
+	if h := atomic.LoadInt32(&hits); h != 1 {
+		t.Errorf("the token should be cached. requests: %d", h)
+	}
+
+	now = now.Add(55 * time.Second)
+	if tok, _ := src.Token(context.Background()); tok.AccessToken != "token-2" {
+		t.Errorf("the token about to expire should be renewed: %s", tok.AccessToken)
+	}
+
+	src.Invalidate()
+	if tok, _ := src.Token(context.Background()); tok.AccessToken != "token-3" {
+		t.Errorf("the invalidated token should be renewed: %s", tok.AccessToken)
+	}
+}
+
+func TestTokenSource_Token_rejected(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_scope","error_description":"unknown scope"}`)
+	}))
+	defer ts.Close()
+
+	_, err := NewTokenSource(Config{ClientID: "id", ClientSecret: "secret", TokenURL: ts.URL}, ts.Client()).Token(context.Background())
+	re, ok := err.(RetrieveError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if re.Code != http.StatusBadRequest || re.ErrorCode != "invalid_scope" || re.Description != "unknown scope" {
+		t.Errorf("unexpected error: %+v", re)
+	}
+}
+
+func TestSharedTokenSource(t *testing.T) {
+	a := SharedTokenSource(Config{ClientID: "id", TokenURL: "https://auth", Scopes: []string{"a", "b"}})
+	b := SharedTokenSource(Config{ClientID: "id", TokenURL: "https://auth", Scopes: []string{"b", "a"}})
+	c := SharedTokenSource(Config{ClientID: "other", TokenURL: "https://auth"})
+	if a != b {
+		t.Error("the sources with the same config should be shared")
+	}
+	if a == c {
+		t.Error("the sources with different clients should not be shared")
+	}
+}
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const outboundProxyKey = "proxy"
+
+// OutboundProxyConfigGetter parses the egress proxy of a backend. The http and https proxies
+// tunnel the TLS connections to the backend through HTTP CONNECT, and the socks5 ones tunnel all
+// the connections through SOCKS5. The credentials can be part of the url or declared apart:
+//
+//	"github.com/devopsfaith/krakend/http": {
+//		"proxy": {
+//			"url": "http://proxy.corp.example.com:3128",
+//			"username": "gateway",
+//			"password": "secret"
+//		}
+//	}
+//
+// It returns an error if the declared proxy is not valid
+func OutboundProxyConfigGetter(e config.ExtraConfig) (*url.URL, bool, error) {
+	v, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+	tmp, ok := v[outboundProxyKey].(map[string]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+	raw, _ := tmp["url"].(string)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, true, fmt.Errorf("invalid proxy url %q: unsupported scheme", raw)
+	}
+	if u.Host == "" {
+		return nil, true, fmt.Errorf("invalid proxy url %q: missing host", raw)
+	}
+	if username, ok := tmp["username"].(string); ok && username != "" {
+		password, _ := tmp["password"].(string)
+		u.User = url.UserPassword(username, password)
+	}
+	return u, true, nil
+}
+
+// NewOutboundProxyHTTPClientFactory returns a HTTPClientFactory creating a dedicated http client
+// sending the requests through the received egress proxy, instead of the ones declared by the
+// environment. The rest of the settings are the ones of NewTransportHTTPClientFactory
+func NewOutboundProxyHTTPClientFactory(proxyURL *url.URL, cfg TransportConfig, dialer *DialerConfig, tlsConfig *tls.Config) HTTPClientFactory {
+	var (
+		once sync.Once
+		c    *http.Client
+	)
+	return func(_ context.Context) *http.Client {
+		once.Do(func() {
+			t := newTransport(cfg, dialer, tlsConfig)
+			t.Proxy = http.ProxyURL(proxyURL)
+			c = &http.Client{Transport: t}
+		})
+		return c
+	}
+}
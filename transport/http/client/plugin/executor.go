@@ -58,23 +58,32 @@ func HTTPRequestExecutorWithContext(
 			return next(cfg)
 		}
 
-		hf, ok := rawHf.(func(context.Context, map[string]interface{}) (http.Handler, error))
-		if !ok {
-			logger.Warning(logPrefix, "Wrong plugin handler type:", name)
-			return next(cfg)
-		}
+		switch hf := rawHf.(type) {
+		case func(context.Context, map[string]interface{}) (func(context.Context, *http.Request) (*http.Response, error), error):
+			executor, err := hf(ctx, extra)
+			if err != nil {
+				logger.Warning(logPrefix, "Error getting the plugin executor:", err.Error())
+				return next(cfg)
+			}
+			logger.Debug(logPrefix, "Injecting plugin executor", name)
+			return executor
 
-		handler, err := hf(ctx, extra)
-		if err != nil {
-			logger.Warning(logPrefix, "Error getting the plugin handler:", err.Error())
-			return next(cfg)
-		}
+		case func(context.Context, map[string]interface{}) (http.Handler, error):
+			handler, err := hf(ctx, extra)
+			if err != nil {
+				logger.Warning(logPrefix, "Error getting the plugin handler:", err.Error())
+				return next(cfg)
+			}
 
-		logger.Debug(logPrefix, "Injecting plugin", name)
-		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
-			w := httptest.NewRecorder()
-			handler.ServeHTTP(w, req.WithContext(ctx))
-			return w.Result(), nil
+			logger.Debug(logPrefix, "Injecting plugin", name)
+			return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req.WithContext(ctx))
+				return w.Result(), nil
+			}
 		}
+
+		logger.Warning(logPrefix, "Wrong plugin handler type:", name)
+		return next(cfg)
 	}
 }
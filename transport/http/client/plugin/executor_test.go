@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"plugin"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+type executorRegisterer string
+
+func (r executorRegisterer) RegisterExecutors(f func(
+	name string,
+	factory func(context.Context, map[string]interface{}) (func(context.Context, *http.Request) (*http.Response, error), error),
+)) {
+	f(string(r), func(_ context.Context, extra map[string]interface{}) (func(context.Context, *http.Request) (*http.Response, error), error) {
+		greeting, ok := extra["greeting"].(string)
+		if !ok {
+			return nil, errors.New("missing greeting")
+		}
+		return func(_ context.Context, req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(greeting + " " + req.URL.Path)),
+			}, nil
+		}, nil
+	})
+}
+
+type fakePlugin map[string]interface{}
+
+func (f fakePlugin) Lookup(name string) (plugin.Symbol, error) {
+	v, ok := f[name]
+	if !ok {
+		return nil, errors.New("symbol not found")
+	}
+	return v, nil
+}
+
+func TestLoadFromConfig_executors(t *testing.T) {
+	defer func(o func(string) (Plugin, error)) { pluginOpener = o }(pluginOpener)
+	pluginOpener = func(_ string) (Plugin, error) {
+		return fakePlugin{"ClientRegisterer": executorRegisterer("custom-executor")}, nil
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "executor.so"), []byte{}, 0o600); err != nil {
+		t.Error(err)
+		return
+	}
+
+	total, err := LoadFromConfig(config.ServiceConfig{Plugin: &config.Plugin{Folder: dir, Pattern: ".so"}}, logging.NoOp)
+	if err != nil || total != 1 {
+		t.Errorf("unexpected result: %d %v", total, err)
+		return
+	}
+
+	fallback := func(_ *config.Backend) client.HTTPRequestExecutor {
+		return func(_ context.Context, _ *http.Request) (*http.Response, error) {
+			return nil, errors.New("the fallback executor should not be called")
+		}
+	}
+	hre := HTTPRequestExecutor(logging.NoOp, fallback)(&config.Backend{
+		ExtraConfig: map[string]interface{}{
+			Namespace: map[string]interface{}{
+				"name":     "custom-executor",
+				"greeting": "hello",
+			},
+		},
+	})
+
+	req, _ := http.NewRequest("GET", "http://some.example.tld/path", http.NoBody)
+	resp, err := hre(context.Background(), req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "hello /path" {
+		t.Errorf("unexpected response body: %s", string(b))
+	}
+
+	hre = HTTPRequestExecutor(logging.NoOp, fallback)(&config.Backend{
+		ExtraConfig: map[string]interface{}{
+			Namespace: map[string]interface{}{"name": "custom-executor"},
+		},
+	})
+	if _, err := hre(context.Background(), req); err == nil {
+		t.Error("the misconfigured plugin should use the fallback executor")
+	}
+}
+
+func TestLoadFromConfig_noPlugins(t *testing.T) {
+	if total, err := LoadFromConfig(config.ServiceConfig{}, logging.NoOp); err != nil || total != 0 {
+		t.Errorf("unexpected result: %d %v", total, err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"plugin"
 	"strings"
 
+	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/logging"
 	luraplugin "github.com/luraproject/lura/v2/plugin"
 	"github.com/luraproject/lura/v2/register"
@@ -23,6 +24,15 @@ func RegisterClient(
 	clientRegister.Register(Namespace, name, handler)
 }
 
+// RegisterExecutor registers a factory of custom request executors. The factories are selected
+// per backend with the name declared in the extra config and receive the namespaced config
+func RegisterExecutor(
+	name string,
+	factory func(context.Context, map[string]interface{}) (func(context.Context, *http.Request) (*http.Response, error), error),
+) {
+	clientRegister.Register(Namespace, name, factory)
+}
+
 type Registerer interface {
 	RegisterClients(func(
 		name string,
@@ -30,6 +40,15 @@ type Registerer interface {
 	))
 }
 
+// ExecutorRegisterer is the interface of the plugins exposing custom request executors instead
+// of http handlers
+type ExecutorRegisterer interface {
+	RegisterExecutors(func(
+		name string,
+		factory func(context.Context, map[string]interface{}) (func(context.Context, *http.Request) (*http.Response, error), error),
+	))
+}
+
 type LoggerRegisterer interface {
 	RegisterLogger(interface{})
 }
@@ -43,6 +62,15 @@ func Load(path, pattern string, rcf RegisterClientFunc) (int, error) {
 	return LoadWithLogger(path, pattern, rcf, nil)
 }
 
+// LoadFromConfig loads the plugins found in the folder declared in the plugin section of the
+// service config, if any
+func LoadFromConfig(cfg config.ServiceConfig, logger logging.Logger) (int, error) {
+	if cfg.Plugin == nil || cfg.Plugin.Folder == "" {
+		return 0, nil
+	}
+	return LoadWithLogger(cfg.Plugin.Folder, cfg.Plugin.Pattern, RegisterClient, logger)
+}
+
 func LoadWithLogger(path, pattern string, rcf RegisterClientFunc, logger logging.Logger) (int, error) {
 	plugins, err := luraplugin.Scan(path, pattern)
 	if err != nil {
@@ -89,8 +117,9 @@ func open(pluginName string, rcf RegisterClientFunc, logger logging.Logger) (err
 	if err != nil {
 		return
 	}
-	registerer, ok := r.(Registerer)
-	if !ok {
+	registerer, isClient := r.(Registerer)
+	executorRegisterer, isExecutor := r.(ExecutorRegisterer)
+	if !isClient && !isExecutor {
 		return fmt.Errorf("http-request-executor plugin loader: unknown type")
 	}
 
@@ -100,7 +129,12 @@ func open(pluginName string, rcf RegisterClientFunc, logger logging.Logger) (err
 		}
 	}
 
-	registerer.RegisterClients(rcf)
+	if isClient {
+		registerer.RegisterClients(rcf)
+	}
+	if isExecutor {
+		executorRegisterer.RegisterExecutors(RegisterExecutor)
+	}
 	return
 }
 
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const (
+	// ProtocolHTTP2 is the ALPN identifier of HTTP/2 over TLS
+	ProtocolHTTP2 = "h2"
+	// ProtocolHTTP11 is the ALPN identifier of HTTP/1.1
+	ProtocolHTTP11 = "http/1.1"
+)
+
+// ProtocolsConfigGetter returns the ALPN protocols declared per host in the extra config of a
+// backend, in order of preference:
+//
+//	"github.com/devopsfaith/krakend/http": {
+//		"protocols": {
+//			"https://api-a:443": ["h2", "http/1.1"],
+//			"https://api-b:8443": ["http/1.1"]
+//		}
+//	}
+func ProtocolsConfigGetter(e config.ExtraConfig) (map[string][]string, bool) {
+	v, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	hosts, ok := v["protocols"].(map[string]interface{})
+	if !ok || len(hosts) == 0 {
+		return nil, false
+	}
+	res := make(map[string][]string, len(hosts))
+	for h, ps := range hosts {
+		res[h] = getStrings(ps)
+	}
+	return res, true
+}
+
+// NewProtocolSelectionExecutor returns a HTTPRequestExecutor sending the requests to the hosts
+// with declared protocols through dedicated clients negotiating them with ALPN. The requests to
+// the rest of hosts are delegated to the next executor. The dedicated transports are clones of
+// the http default transport using the received TLS config, if any
+func NewProtocolSelectionExecutor(protocols map[string][]string, tlsConfig *tls.Config, next HTTPRequestExecutor) (HTTPRequestExecutor, error) {
+	clients := make(map[string]*http.Client, len(protocols))
+	for host, ps := range protocols {
+		key, err := protocolHostKey(host)
+		if err != nil {
+			return nil, err
+		}
+		t, err := newProtocolTransport(key, ps, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		clients[key] = &http.Client{Transport: t}
+	}
+
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		if c, ok := clients[strings.ToLower(req.URL.Scheme+"://"+req.URL.Host)]; ok {
+			return c.Do(req.WithContext(ctx))
+		}
+		return next(ctx, req)
+	}, nil
+}
+
+func protocolHostKey(host string) (string, error) {
+	u, err := url.Parse(host)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid host %q in the protocols config", host)
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+func newProtocolTransport(host string, protocols []string, tlsConfig *tls.Config) (*http.Transport, error) {
+	if len(protocols) == 0 {
+		return nil, fmt.Errorf("no protocols declared for the host %s", host)
+	}
+	h2 := false
+	for _, p := range protocols {
+		switch p {
+		case ProtocolHTTP2:
+			if strings.HasPrefix(host, "http://") {
+				return nil, fmt.Errorf("the host %s can not negotiate %s without TLS", host, p)
+			}
+			h2 = true
+		case ProtocolHTTP11:
+		default:
+			return nil, fmt.Errorf("unsupported protocol %s for the host %s", p, host)
+		}
+	}
+
+	var t *http.Transport
+	if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+		t = dt.Clone()
+	} else {
+		t = &http.Transport{Proxy: http.ProxyFromEnvironment}
+	}
+	if tlsConfig != nil {
+		t.TLSClientConfig = tlsConfig.Clone()
+	} else if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.NextProtos = append([]string{}, protocols...)
+	t.ForceAttemptHTTP2 = h2
+	if h2 {
+		t.TLSNextProto = nil
+	} else {
+		// a non-nil empty map disables the HTTP/2 upgrade of the transport
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return t, nil
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestProtocolsConfigGetter(t *testing.T) {
+	if _, ok := ProtocolsConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the config should not be found")
+	}
+	protocols, ok := ProtocolsConfigGetter(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"protocols": map[string]interface{}{
+				"https://a:443": []interface{}{"h2", "http/1.1"},
+			},
+		},
+	})
+	if !ok {
+		t.Fatal("the config should be found")
+	}
+	if ps := protocols["https://a:443"]; len(ps) != 2 || ps[0] != "h2" || ps[1] != "http/1.1" {
+		t.Errorf("unexpected protocols: %v", ps)
+	}
+}
+
+func TestNewProtocolSelectionExecutor(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	tlsConfig := &tls.Config{RootCAs: pool}
+
+	errNext := errors.New("next executor")
+	next := func(_ context.Context, _ *http.Request) (*http.Response, error) { return nil, errNext }
+
+	for _, tc := range []struct {
+		protocols []string
+		want      string
+	}{
+		{protocols: []string{"h2", "http/1.1"}, want: "HTTP/2.0"},
+		{protocols: []string{"http/1.1"}, want: "HTTP/1.1"},
+	} {
+		re, err := NewProtocolSelectionExecutor(map[string][]string{ts.URL: tc.protocols}, tlsConfig, next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest("GET", ts.URL+"/path", http.NoBody)
+		resp, err := re(context.Background(), req)
+		if err != nil {
+			t.Errorf("%v: %s", tc.protocols, err.Error())
+			continue
+		}
+		resp.Body.Close()
+		if p := resp.Header.Get("X-Proto"); p != tc.want {
+			t.Errorf("%v: unexpected protocol. have: %s, want: %s", tc.protocols, p, tc.want)
+		}
+
+		req, _ = http.NewRequest("GET", "http://other.example.com/path", http.NoBody)
+		if _, err := re(context.Background(), req); err != errNext {
+			t.Errorf("the requests to other hosts should use the next executor: %v", err)
+		}
+	}
+}
+
+func TestNewProtocolSelectionExecutor_invalidConfig(t *testing.T) {
+	for _, protocols := range []map[string][]string{
+		{"http://a": {"h2"}},
+		{"https://a": {"spdy/3"}},
+		{"https://a": {}},
+		{"a": {"http/1.1"}},
+	} {
+		if _, err := NewProtocolSelectionExecutor(protocols, nil, nil); err == nil {
+			t.Errorf("%v: expecting an error", protocols)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/requestid"
+)
+
+// NewRequestIDExecutor decorates the executor, so every request sent to the backend carries the
+// id of the request received by the router, if any
+func NewRequestIDExecutor(g *requestid.Generator, next HTTPRequestExecutor) HTTPRequestExecutor {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		if id, ok := requestid.FromContext(ctx); ok {
+			req.Header.Set(g.Header(), id)
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/requestid"
+)
+
+func TestNewRequestIDExecutor(t *testing.T) {
+	var received []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("X-Correlation-Id"))
+	}))
+	defer ts.Close()
+
+	re := NewRequestIDExecutor(requestid.New(requestid.Config{Header: "X-Correlation-Id"}), DefaultHTTPRequestExecutor(NewHTTPClient))
+	for _, ctx := range []context.Context{
+		requestid.NewContext(context.Background(), "abc-123"),
+		context.Background(),
+	} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		resp, err := re(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if len(received) != 2 || received[0] != "abc-123" || received[1] != "" {
+		t.Errorf("unexpected headers %v", received)
+	}
+}
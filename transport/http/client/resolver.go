@@ -0,0 +1,261 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/metrics"
+)
+
+const dnsCacheKey = "dns_cache"
+
+// DefaultDNSCacheTTL is the time the resolved addresses are cached when the config does not
+// declare one
+const DefaultDNSCacheTTL = 30 * time.Second
+
+// DefaultDNSCacheMaxEntries is the maximum number of cached names when the config does not
+// declare one
+const DefaultDNSCacheMaxEntries = 4096
+
+// The results of the lookups reported to the metrics
+const (
+	DNSCacheHit         = "hit"
+	DNSCacheNegativeHit = "negative_hit"
+	DNSCacheMiss        = "miss"
+)
+
+// DNSCacheConfig contains the settings of the caching resolver of the service
+type DNSCacheConfig struct {
+	// TTL is the time the resolved addresses are cached. The system resolver does not expose the
+	// TTL of the records, so it should not exceed the one declared in the zones
+	TTL time.Duration
+	// NegativeTTL is the time the names not found are cached. Zero disables the negative cache
+	NegativeTTL time.Duration
+	// MaxEntries is the maximum number of cached names
+	MaxEntries int
+}
+
+// DNSCacheConfigGetter parses the caching resolver settings from the extra config of the service.
+// It returns an error if the declared settings are not valid:
+//
+//	"github.com/devopsfaith/krakend/http": {
+//		"dns_cache": {
+//			"ttl": "30s",
+//			"negative_ttl": "5s",
+//			"max_entries": 4096
+//		}
+//	}
+func DNSCacheConfigGetter(e config.ExtraConfig) (DNSCacheConfig, bool, error) {
+	cfg := DNSCacheConfig{TTL: DefaultDNSCacheTTL, MaxEntries: DefaultDNSCacheMaxEntries}
+	v, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	tmp, ok := v[dnsCacheKey].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	for k, d := range map[string]*time.Duration{"ttl": &cfg.TTL, "negative_ttl": &cfg.NegativeTTL} {
+		s, ok := tmp[k].(string)
+		if !ok {
+			continue
+		}
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return cfg, true, fmt.Errorf("invalid dns_cache %s: %w", k, err)
+		}
+		if parsed < 0 {
+			return cfg, true, fmt.Errorf("invalid dns_cache %s: %s", k, s)
+		}
+		*d = parsed
+	}
+	if cfg.TTL == 0 {
+		return cfg, true, fmt.Errorf("invalid dns_cache ttl: it must be positive")
+	}
+	if n, ok := tmp["max_entries"].(float64); ok {
+		if n < 1 {
+			return cfg, true, fmt.Errorf("invalid dns_cache max_entries: %v", n)
+		}
+		cfg.MaxEntries = int(n)
+	}
+	return cfg, true, nil
+}
+
+// Lookuper resolves the addresses of a host
+type Lookuper interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// CachingResolver caches the addresses resolved by another resolver, so the gateways with a high
+// throughput do not query the system resolver for every new connection
+type CachingResolver struct {
+	next    Lookuper
+	cfg     DNSCacheConfig
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+	now     func() time.Time
+	hits    uint64
+	misses  uint64
+}
+
+type dnsEntry struct {
+	addrs   []net.IPAddr
+	err     error
+	expires time.Time
+}
+
+// NewCachingResolver returns a CachingResolver on top of the received one. The system resolver
+// is used if it is nil
+func NewCachingResolver(cfg DNSCacheConfig, next Lookuper) *CachingResolver {
+	if next == nil {
+		next = net.DefaultResolver
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = DefaultDNSCacheMaxEntries
+	}
+	return &CachingResolver{
+		next:    next,
+		cfg:     cfg,
+		entries: map[string]dnsEntry{},
+		now:     time.Now,
+	}
+}
+
+// LookupIPAddr returns the addresses of the host, from the cache if they are not expired. The
+// names not found are cached for the negative TTL, while the rest of the failures are never
+// cached
+func (r *CachingResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	now := r.now()
+	r.mu.Lock()
+	e, ok := r.entries[host]
+	if ok && now.Before(e.expires) {
+		r.hits++
+		r.mu.Unlock()
+		if e.err != nil {
+			metrics.ObserveDNSLookup(DNSCacheNegativeHit)
+			return nil, e.err
+		}
+		metrics.ObserveDNSLookup(DNSCacheHit)
+		return e.addrs, nil
+	}
+	r.misses++
+	r.mu.Unlock()
+	metrics.ObserveDNSLookup(DNSCacheMiss)
+
+	addrs, err := r.next.LookupIPAddr(ctx, host)
+	switch {
+	case err == nil && len(addrs) > 0:
+		r.store(host, dnsEntry{addrs: addrs, expires: now.Add(r.cfg.TTL)})
+	case err != nil && r.cfg.NegativeTTL > 0 && isNotFound(err):
+		r.store(host, dnsEntry{err: err, expires: now.Add(r.cfg.NegativeTTL)})
+	}
+	return addrs, err
+}
+
+func (r *CachingResolver) store(host string, e dnsEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[host]; !ok && len(r.entries) >= r.cfg.MaxEntries {
+		r.evict()
+	}
+	r.entries[host] = e
+}
+
+// evict removes the expired entries or, if there are none, an arbitrary one
+func (r *CachingResolver) evict() {
+	now := r.now()
+	for k, e := range r.entries {
+		if !now.Before(e.expires) {
+			delete(r.entries, k)
+		}
+	}
+	if len(r.entries) < r.cfg.MaxEntries {
+		return
+	}
+	for k := range r.entries {
+		delete(r.entries, k)
+		return
+	}
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// DNSCacheStats contains the usage of a CachingResolver
+type DNSCacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+// HitRatio returns the ratio of lookups resolved by the cache
+func (s DNSCacheStats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// Stats returns the usage of the resolver
+func (r *CachingResolver) Stats() DNSCacheStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return DNSCacheStats{Hits: r.hits, Misses: r.misses, Entries: len(r.entries)}
+}
+
+// NewCachingResolverDialer decorates the received dialer, so the hosts are resolved with the
+// CachingResolver and the addresses are dialed in order until one of them accepts the connection
+func NewCachingResolverDialer(r *CachingResolver, next DialContextFunc) DialContextFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return next(ctx, network, addr)
+		}
+		addrs, err := r.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, a := range addrs {
+			if !matchesNetwork(network, a.IP) {
+				continue
+			}
+			ip := a.IP.String()
+			if a.Zone != "" {
+				ip += "%" + a.Zone
+			}
+			conn, err := next(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if lastErr == nil {
+			lastErr = &net.DNSError{Err: "no suitable address found", Name: host, IsNotFound: true}
+		}
+		return nil, lastErr
+	}
+}
+
+func matchesNetwork(network string, ip net.IP) bool {
+	switch network {
+	case "tcp4", "udp4":
+		return ip.To4() != nil
+	case "tcp6", "udp6":
+		return ip.To4() == nil
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+type lookuperFunc func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+func (f lookuperFunc) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return f(ctx, host)
+}
+
+func TestDNSCacheConfigGetter(t *testing.T) {
+	if _, ok, _ := DNSCacheConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the config should not be found")
+	}
+
+	cfg, ok, err := DNSCacheConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"dns_cache": map[string]interface{}{}}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if cfg.TTL != DefaultDNSCacheTTL || cfg.NegativeTTL != 0 || cfg.MaxEntries != DefaultDNSCacheMaxEntries {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	cfg, _, err = DNSCacheConfigGetter(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"dns_cache": map[string]interface{}{
+				"ttl":          "1m",
+				"negative_ttl": "5s",
+				"max_entries":  10.0,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TTL != time.Minute || cfg.NegativeTTL != 5*time.Second || cfg.MaxEntries != 10 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	for _, tmp := range []map[string]interface{}{
+		{"ttl": "0s"},
+		{"ttl": "forever"},
+		{"negative_ttl": "-1s"},
+		{"max_entries": 0.0},
+	} {
+		if _, _, err := DNSCacheConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"dns_cache": tmp}}); err == nil {
+			t.Errorf("%v: expecting an error", tmp)
+		}
+	}
+}
+
+func TestCachingResolver(t *testing.T) {
+	lookups := map[string]int{}
+	notFound := &net.DNSError{Err: "no such host", Name: "unknown.example.com", IsNotFound: true}
+	temporary := &net.DNSError{Err: "server misbehaving", Name: "flaky.example.com", IsTemporary: true}
+	r := NewCachingResolver(DNSCacheConfig{TTL: time.Minute, NegativeTTL: time.Second, MaxEntries: 2}, lookuperFunc(func(_ context.Context, host string) ([]net.IPAddr, error) {
+		lookups[host]++
+		switch host {
+		case "unknown.example.com":
+			return nil, notFound
+		case "flaky.example.com":
+			return nil, temporary
+		}
+		return []net.IPAddr{{IP: net.ParseIP("10.0.0.1")}}, nil
+	}))
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		addrs, err := r.LookupIPAddr(context.Background(), "api.example.com")
+		if err != nil || len(addrs) != 1 || !addrs[0].IP.Equal(net.ParseIP("10.0.0.1")) {
+			t.Errorf("unexpected result: %v %v", addrs, err)
+		}
+		if _, err := r.LookupIPAddr(context.Background(), "unknown.example.com"); !errors.Is(err, notFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, err := r.LookupIPAddr(context.Background(), "flaky.example.com"); !errors.Is(err, temporary) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if lookups["api.example.com"] != 1 || lookups["unknown.example.com"] != 1 || lookups["flaky.example.com"] != 3 {
+		t.Errorf("unexpected lookups: %v", lookups)
+	}
+	if s := r.Stats(); s.Hits != 4 || s.Misses != 5 || s.Entries != 2 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	// the negative entries expire before the rest
+	now = now.Add(2 * time.Second)
+	r.LookupIPAddr(context.Background(), "api.example.com")
+	r.LookupIPAddr(context.Background(), "unknown.example.com")
+	if lookups["api.example.com"] != 1 || lookups["unknown.example.com"] != 2 {
+		t.Errorf("unexpected lookups: %v", lookups)
+	}
+
+	now = now.Add(time.Minute)
+	r.LookupIPAddr(context.Background(), "api.example.com")
+	if lookups["api.example.com"] != 2 {
+		t.Errorf("unexpected lookups: %v", lookups)
+	}
+
+	// the cache is bounded
+	r.LookupIPAddr(context.Background(), "other.example.com")
+	if s := r.Stats(); s.Entries > 2 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func TestNewCachingResolverDialer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	lookups := 0
+	r := NewCachingResolver(DNSCacheConfig{TTL: time.Minute}, lookuperFunc(func(_ context.Context, _ string) ([]net.IPAddr, error) {
+		lookups++
+		// the first address refuses the connections
+		return []net.IPAddr{{IP: net.ParseIP("::1")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+	}))
+	var dialed []string
+	dial := NewCachingResolverDialer(r, func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	})
+
+	c := &http.Client{Transport: &http.Transport{DialContext: dial, DisableKeepAlives: true}}
+	for i := 0; i < 2; i++ {
+		resp, err := c.Get("http://backend.example.com:" + port)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if lookups != 1 {
+		t.Errorf("unexpected lookups: %d", lookups)
+	}
+	if dialed[len(dialed)-1] != "127.0.0.1:"+port {
+		t.Errorf("unexpected addresses: %v", dialed)
+	}
+
+	if _, err := dial(context.Background(), "tcp6", "backend.example.com:"+port); err == nil {
+		t.Error("expecting an error")
+	}
+	if _, err := dial(context.Background(), "tcp4", "127.0.0.1:"+port); err != nil {
+		t.Errorf("the addresses should be dialed directly: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const maxResponseSizeKey = "max_response_size"
+
+// ResponseTooLargeError is returned when the body of a backend response is bigger than the max
+// size declared by the backend
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+// Error returns a string representation of the ResponseTooLargeError
+func (r ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("the backend response exceeds the max size of %d bytes", r.Limit)
+}
+
+// ErrorCode returns the code of the error, for the structured error responses
+func (ResponseTooLargeError) ErrorCode() string { return "response_too_large" }
+
+// MaxResponseSizeGetter parses the max size in bytes of the response bodies from the extra config
+// of a backend:
+//
+//	"github.com/devopsfaith/krakend/http": {
+//		"max_response_size": 1048576
+//	}
+func MaxResponseSizeGetter(e config.ExtraConfig) (int64, bool, error) {
+	v, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return 0, false, nil
+	}
+	tmp, ok := v[maxResponseSizeKey]
+	if !ok {
+		return 0, false, nil
+	}
+	n, ok := tmp.(float64)
+	if !ok || n < 1 || n != float64(int64(n)) {
+		return 0, true, fmt.Errorf("invalid %s: %v", maxResponseSizeKey, tmp)
+	}
+	return int64(n), true, nil
+}
+
+// NewMaxResponseSizeExecutor decorates the received executor, so the responses declaring a bigger
+// content length are rejected before reading them and the rest of the bodies fail with a
+// ResponseTooLargeError as soon as they exceed the limit. The bodies are never buffered, so the
+// limit is also enforced on the streamed responses
+func NewMaxResponseSizeExecutor(limit int64, next HTTPRequestExecutor) HTTPRequestExecutor {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		resp, err := next(ctx, req)
+		if err != nil || resp == nil || resp.Body == nil {
+			return resp, err
+		}
+		if resp.ContentLength > limit {
+			resp.Body.Close()
+			return nil, ResponseTooLargeError{Limit: limit}
+		}
+		resp.Body = &limitedBody{ReadCloser: resp.Body, n: limit, limit: limit}
+		return resp, nil
+	}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	n     int64
+	limit int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		var b [1]byte
+		n, err := l.ReadCloser.Read(b[:])
+		if n > 0 {
+			return 0, ResponseTooLargeError{Limit: l.limit}
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
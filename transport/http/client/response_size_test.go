@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestMaxResponseSizeGetter(t *testing.T) {
+	if _, ok, _ := MaxResponseSizeGetter(config.ExtraConfig{}); ok {
+		t.Error("the config should not be found")
+	}
+	limit, ok, err := MaxResponseSizeGetter(config.ExtraConfig{Namespace: map[string]interface{}{"max_response_size": 1024.0}})
+	if !ok || err != nil || limit != 1024 {
+		t.Errorf("unexpected result: %d %v %v", limit, ok, err)
+	}
+	for _, v := range []interface{}{0.0, -1.0, 1.5, "1MB"} {
+		if _, _, err := MaxResponseSizeGetter(config.ExtraConfig{Namespace: map[string]interface{}{"max_response_size": v}}); err == nil {
+			t.Errorf("%v: expecting an error", v)
+		}
+	}
+}
+
+func TestNewMaxResponseSizeExecutor(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := strings.Repeat("a", 10)
+		if r.URL.Path == "/big" {
+			body = strings.Repeat("a", 20)
+		}
+		if r.URL.Query().Get("chunked") != "" {
+			// flushing before writing the body removes the content length
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, body)
+	}))
+	defer ts.Close()
+
+	re := NewMaxResponseSizeExecutor(15, DefaultHTTPRequestExecutor(NewHTTPClient))
+	get := func(path string) (string, error) {
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		resp, err := re(context.Background(), req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	if body, err := get("/small"); err != nil || len(body) != 10 {
+		t.Errorf("unexpected result: %q %v", body, err)
+	}
+	for _, path := range []string{"/big", "/big?chunked=1"} {
+		_, err := get(path)
+		var tooLarge ResponseTooLargeError
+		if !errors.As(err, &tooLarge) || tooLarge.Limit != 15 {
+			t.Errorf("%s: unexpected error: %v", path, err)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	resp, err := NewMaxResponseSizeExecutor(5, func(_ context.Context, _ *http.Request) (*http.Response, error) {
+		return &http.Response{ContentLength: 10, Body: io.NopCloser(strings.NewReader(strings.Repeat("a", 10)))}, nil
+	})(context.Background(), req)
+	if resp != nil || !errors.As(err, new(ResponseTooLargeError)) {
+		t.Errorf("the response should be rejected before reading it: %v %v", resp, err)
+	}
+}
@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GatewayMethod is the name of the signing method adding the gateway identity headers
+const GatewayMethod = "gateway"
+
+// The headers added by the GatewaySigner
+const (
+	HeaderGatewayID        = "X-Gateway-Id"
+	HeaderGatewayTimestamp = "X-Gateway-Timestamp"
+	HeaderGatewayNonce     = "X-Gateway-Nonce"
+	HeaderGatewaySignature = "X-Gateway-Signature"
+)
+
+const gatewaySignatureVersion = "v1="
+
+// DefaultMaxSkew is the max difference between the timestamp of a request and the clock of the
+// GatewayVerifier when it does not declare one
+const DefaultMaxSkew = 5 * time.Minute
+
+var (
+	// ErrMissingGatewayHeaders is returned when a request does not contain the gateway headers
+	ErrMissingGatewayHeaders = errors.New("signing: missing gateway headers")
+	// ErrUnknownGateway is returned when the verifier does not have the secret of the gateway id
+	ErrUnknownGateway = errors.New("signing: unknown gateway")
+	// ErrExpiredRequest is returned when the timestamp of a request exceeds the max skew
+	ErrExpiredRequest = errors.New("signing: the request timestamp is out of the allowed window")
+	// ErrInvalidSignature is returned when the signature of a request does not match
+	ErrInvalidSignature = errors.New("signing: invalid gateway signature")
+	// ErrReplayedRequest is returned when the nonce of a request was already seen
+	ErrReplayedRequest = errors.New("signing: replayed request")
+)
+
+// GatewaySigner adds the identity of the gateway to the requests, so the backends can verify they
+// were sent by the gateway and reject the replays:
+//
+//	X-Gateway-Id: gateway-eu-1
+//	X-Gateway-Timestamp: 1672628645
+//	X-Gateway-Nonce: 5f0c3a9e2b7d41c8a6e9f01d2c3b4a59
+//	X-Gateway-Signature: v1=<hex hmac-sha256>
+//
+// The signature covers the method, the path with the query string, the timestamp, the nonce, the
+// gateway id and the hash of the body, one per line. GatewayVerifier checks them
+type GatewaySigner struct {
+	GatewayID string
+	Secret    []byte
+
+	now   func() time.Time
+	nonce func() (string, error)
+}
+
+// NewGatewaySigner returns a GatewaySigner for the gateway id
+func NewGatewaySigner(gatewayID string, secret []byte) (*GatewaySigner, error) {
+	if gatewayID == "" || len(secret) == 0 {
+		return nil, errors.New("signing: the gateway signer requires a gateway_id and a secret")
+	}
+	return &GatewaySigner{GatewayID: gatewayID, Secret: secret, now: time.Now, nonce: newNonce}, nil
+}
+
+// NewGatewaySignerFromConfig is the SignerFactory of the gateway method
+func NewGatewaySignerFromConfig(cfg map[string]interface{}) (Signer, error) {
+	return NewGatewaySigner(getString(cfg, "gateway_id"), []byte(getString(cfg, "secret")))
+}
+
+// Sign implements the Signer interface
+func (s *GatewaySigner) Sign(req *http.Request) error {
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+	nonce, err := s.nonce()
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(s.now().Unix(), 10)
+
+	req.Header.Set(HeaderGatewayID, s.GatewayID)
+	req.Header.Set(HeaderGatewayTimestamp, ts)
+	req.Header.Set(HeaderGatewayNonce, nonce)
+	req.Header.Set(HeaderGatewaySignature, gatewaySignatureVersion+hex.EncodeToString(
+		gatewaySignature(s.Secret, req.Method, req.URL.RequestURI(), ts, nonce, s.GatewayID, body)))
+	return nil
+}
+
+func gatewaySignature(secret []byte, method, uri, ts, nonce, id string, body []byte) []byte {
+	sum := sha256.Sum256(body)
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(strings.Join([]string{
+		strings.ToUpper(method), uri, ts, nonce, id, hex.EncodeToString(sum[:]),
+	}, "\n")))
+	return m.Sum(nil)
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("signing: generating the nonce: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NonceStore remembers the nonces of the verified requests until they expire
+type NonceStore interface {
+	// Seen stores the nonce until the expiration time and returns true if it was already stored
+	Seen(nonce string, expiration time.Time) bool
+}
+
+// NewMemoryNonceStore returns a NonceStore keeping the nonces in memory. The expired ones are
+// removed while storing new nonces
+func NewMemoryNonceStore() NonceStore {
+	return &memoryNonceStore{nonces: map[string]time.Time{}, now: time.Now}
+}
+
+type memoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func (m *memoryNonceStore) Seen(nonce string, expiration time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.lastPrune) > time.Second {
+		for k, exp := range m.nonces {
+			if now.After(exp) {
+				delete(m.nonces, k)
+			}
+		}
+		m.lastPrune = now
+	}
+	if exp, ok := m.nonces[nonce]; ok && !now.After(exp) {
+		return true
+	}
+	m.nonces[nonce] = expiration
+	return false
+}
+
+// GatewayVerifier checks the identity headers added by the GatewaySigner, so the services behind
+// the gateway written in Go can reject the requests not sent by a known gateway, the ones out of
+// the allowed time window and the replays
+type GatewayVerifier struct {
+	// Secrets are the secrets of the accepted gateway ids
+	Secrets map[string][]byte
+	// MaxSkew is the max difference between the timestamp of the requests and the local clock.
+	// DefaultMaxSkew is used if zero
+	MaxSkew time.Duration
+	// Nonces stores the nonces of the verified requests. The replays are not detected if nil
+	Nonces NonceStore
+
+	now func() time.Time
+}
+
+// NewGatewayVerifier returns a GatewayVerifier accepting the gateway ids of the secrets and
+// detecting the replays with a memory nonce store
+func NewGatewayVerifier(secrets map[string][]byte, maxSkew time.Duration) *GatewayVerifier {
+	return &GatewayVerifier{Secrets: secrets, MaxSkew: maxSkew, Nonces: NewMemoryNonceStore(), now: time.Now}
+}
+
+// Verify checks the gateway headers of the request. The body is read and replaced, so it can be
+// consumed after the verification
+func (v *GatewayVerifier) Verify(req *http.Request) error {
+	id := req.Header.Get(HeaderGatewayID)
+	ts := req.Header.Get(HeaderGatewayTimestamp)
+	nonce := req.Header.Get(HeaderGatewayNonce)
+	sig := req.Header.Get(HeaderGatewaySignature)
+	if id == "" || ts == "" || nonce == "" || !strings.HasPrefix(sig, gatewaySignatureVersion) {
+		return ErrMissingGatewayHeaders
+	}
+	secret, ok := v.Secrets[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownGateway, id)
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrExpiredRequest
+	}
+	skew := v.MaxSkew
+	if skew <= 0 {
+		skew = DefaultMaxSkew
+	}
+	now := time.Now
+	if v.now != nil {
+		now = v.now
+	}
+	sent := time.Unix(unix, 0)
+	if d := now().Sub(sent); d > skew || d < -skew {
+		return ErrExpiredRequest
+	}
+
+	have, err := hex.DecodeString(strings.TrimPrefix(sig, gatewaySignatureVersion))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if err := bufferBody(req); err != nil {
+		return err
+	}
+	if req.Body == nil {
+		// the handlers expect a non-nil body in the server requests
+		req.Body = http.NoBody
+	}
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(have, gatewaySignature(secret, req.Method, req.URL.RequestURI(), ts, nonce, id, body)) {
+		return ErrInvalidSignature
+	}
+
+	// the nonces are only stored for the valid signatures, and they are kept until the timestamp
+	// leaves the allowed window
+	if v.Nonces != nil && v.Nonces.Seen(id+":"+nonce, sent.Add(skew)) {
+		return ErrReplayedRequest
+	}
+	return nil
+}
+
+// NewGatewayVerifierHandler returns a handler rejecting with a 401 the requests not passing the
+// verification before calling the next one
+func NewGatewayVerifierHandler(v *GatewayVerifier, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := v.Verify(req); err != nil {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
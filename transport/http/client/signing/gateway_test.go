@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGatewaySigner_Sign(t *testing.T) {
+	s, err := NewGatewaySigner("gateway-eu-1", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return time.Unix(1672628645, 0) }
+	s.nonce = func() (string, error) { return "abc", nil }
+
+	req, _ := http.NewRequest("post", "http://internal:8080/orders?id=1", strings.NewReader(`{"a":1}`))
+	bufferBody(req)
+	if err := s.Sign(req); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte(`{"a":1}`))
+	m := hmac.New(sha256.New, []byte("secret"))
+	m.Write([]byte("POST\n/orders?id=1\n1672628645\nabc\ngateway-eu-1\n" + hex.EncodeToString(sum[:])))
+	for h, want := range map[string]string{
+		HeaderGatewayID:        "gateway-eu-1",
+		HeaderGatewayTimestamp: "1672628645",
+		HeaderGatewayNonce:     "abc",
+		HeaderGatewaySignature: "v1=" + hex.EncodeToString(m.Sum(nil)),
+	} {
+		if have := req.Header.Get(h); have != want {
+			t.Errorf("unexpected %s. have: %s, want: %s", h, have, want)
+		}
+	}
+}
+
+func TestGatewayVerifier_Verify(t *testing.T) {
+	now := time.Unix(1672628645, 0)
+	s, _ := NewGatewaySigner("gateway-eu-1", []byte("secret"))
+	s.now = func() time.Time { return now }
+
+	clock := now.Add(30 * time.Second)
+	v := NewGatewayVerifier(map[string][]byte{"gateway-eu-1": []byte("secret")}, time.Minute)
+	v.now = func() time.Time { return clock }
+	v.Nonces.(*memoryNonceStore).now = v.now
+
+	signed := func(body string) *http.Request {
+		req, _ := http.NewRequest("POST", "http://internal/orders", strings.NewReader(body))
+		bufferBody(req)
+		if err := s.Sign(req); err != nil {
+			t.Fatal(err)
+		}
+		// the verifier receives the requests as a server
+		req.Body, _ = req.GetBody()
+		req.GetBody = nil
+		return req
+	}
+
+	req := signed("payload")
+	if err := v.Verify(req); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(req.Body); string(b) != "payload" {
+		t.Errorf("the body was not restored: %s", b)
+	}
+
+	replayed := signed("payload")
+	replayed.Header = req.Header.Clone()
+	if err := v.Verify(replayed); err != ErrReplayedRequest {
+		t.Errorf("unexpected error for the replay: %v", err)
+	}
+
+	tampered := signed("payload")
+	tampered.Body = io.NopCloser(strings.NewReader("other payload"))
+	if err := v.Verify(tampered); err != ErrInvalidSignature {
+		t.Errorf("unexpected error for the tampered body: %v", err)
+	}
+
+	missing := signed("payload")
+	missing.Header.Del(HeaderGatewayNonce)
+	if err := v.Verify(missing); err != ErrMissingGatewayHeaders {
+		t.Errorf("unexpected error for the missing nonce: %v", err)
+	}
+
+	unknown := signed("payload")
+	unknown.Header.Set(HeaderGatewayID, "gateway-us-1")
+	if err := v.Verify(unknown); !errors.Is(err, ErrUnknownGateway) {
+		t.Errorf("unexpected error for the unknown gateway: %v", err)
+	}
+
+	clock = now.Add(2 * time.Minute)
+	if err := v.Verify(signed("payload")); err != ErrExpiredRequest {
+		t.Errorf("unexpected error for the expired request: %v", err)
+	}
+}
+
+func TestNewGatewayVerifierHandler(t *testing.T) {
+	s, _ := NewGatewaySigner("gateway-eu-1", []byte("secret"))
+	v := NewGatewayVerifier(map[string][]byte{"gateway-eu-1": []byte("secret")}, 0)
+	h := NewGatewayVerifierHandler(v, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Write(b)
+	}))
+
+	req := httptest.NewRequest("GET", "/orders", http.NoBody)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unexpected status for the unsigned request: %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/orders", http.NoBody)
+	bufferBody(req)
+	s.Sign(req)
+	req.Body = http.NoBody
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status for the signed request: %d", w.Code)
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	now := time.Unix(1672628645, 0)
+	m := NewMemoryNonceStore().(*memoryNonceStore)
+	m.now = func() time.Time { return now }
+
+	if m.Seen("a", now.Add(time.Minute)) {
+		t.Error("the nonce was not seen before")
+	}
+	if !m.Seen("a", now.Add(time.Minute)) {
+		t.Error("the nonce was already seen")
+	}
+	now = now.Add(2 * time.Minute)
+	if m.Seen("a", now.Add(time.Minute)) {
+		t.Error("the nonce expired")
+	}
+	if len(m.nonces) != 1 {
+		t.Errorf("the expired nonces were not pruned: %v", m.nonces)
+	}
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HMACMethod is the name of the generic HMAC signing method
+const HMACMethod = "hmac"
+
+// RequestTarget is the pseudo header covering the method and the path of the request
+const RequestTarget = "(request-target)"
+
+// DefaultHMACHeaders are the headers signed when the config does not declare them
+var DefaultHMACHeaders = []string{RequestTarget, "host", "date", "digest"}
+
+// HMACSigner signs the requests with a shared secret, following the HTTP Signatures draft. The
+// signature covers the declared headers and it is sent in the Signature header:
+//
+//	Signature: keyId="service-a",algorithm="hmac-sha256",headers="(request-target) host date digest",signature="..."
+//
+// The Date header and, when it is signed, the Digest header with the hash of the body are added
+// to the requests
+type HMACSigner struct {
+	KeyID     string
+	Secret    []byte
+	Algorithm string
+	Headers   []string
+
+	hash func() hash.Hash
+	now  func() time.Time
+}
+
+// NewHMACSigner returns a HMACSigner using the algorithm (hmac-sha256 or hmac-sha512)
+func NewHMACSigner(keyID string, secret []byte, algorithm string, headers []string) (*HMACSigner, error) {
+	if keyID == "" || len(secret) == 0 {
+		return nil, errors.New("signing: the hmac signer requires a key_id and a secret")
+	}
+	s := &HMACSigner{KeyID: keyID, Secret: secret, Algorithm: algorithm, Headers: headers, now: time.Now}
+	switch algorithm {
+	case "", "hmac-sha256":
+		s.Algorithm, s.hash = "hmac-sha256", sha256.New
+	case "hmac-sha512":
+		s.hash = sha512.New
+	default:
+		return nil, fmt.Errorf("signing: unsupported hmac algorithm %s", algorithm)
+	}
+	if len(headers) == 0 {
+		headers = DefaultHMACHeaders
+	}
+	s.Headers = make([]string, len(headers))
+	for i, h := range headers {
+		s.Headers[i] = strings.ToLower(h)
+	}
+	return s, nil
+}
+
+// NewHMACSignerFromConfig is the SignerFactory of the hmac method
+func NewHMACSignerFromConfig(cfg map[string]interface{}) (Signer, error) {
+	var headers []string
+	if hs, ok := cfg["headers"].([]interface{}); ok {
+		for _, h := range hs {
+			if s, ok := h.(string); ok {
+				headers = append(headers, s)
+			}
+		}
+	}
+	return NewHMACSigner(getString(cfg, "key_id"), []byte(getString(cfg, "secret")), getString(cfg, "algorithm"), headers)
+}
+
+// Sign implements the Signer interface
+func (s *HMACSigner) Sign(req *http.Request) error {
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", s.now().UTC().Format(http.TimeFormat))
+	}
+
+	lines := make([]string, len(s.Headers))
+	for i, h := range s.Headers {
+		switch h {
+		case RequestTarget:
+			lines[i] = h + ": " + strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			host := req.Host
+			if host == "" {
+				host = req.URL.Host
+			}
+			lines[i] = h + ": " + host
+		case "digest":
+			body, err := readBody(req)
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256(body)
+			req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]))
+			lines[i] = h + ": " + req.Header.Get("Digest")
+		default:
+			vs, ok := req.Header[http.CanonicalHeaderKey(h)]
+			if !ok {
+				return fmt.Errorf("signing: the request does not contain the signed header %s", h)
+			}
+			lines[i] = h + ": " + strings.Join(vs, ", ")
+		}
+	}
+
+	m := hmac.New(s.hash, s.Secret)
+	m.Write([]byte(strings.Join(lines, "\n")))
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="%s",headers="%s",signature="%s"`,
+		s.KeyID, s.Algorithm, strings.Join(s.Headers, " "), base64.StdEncoding.EncodeToString(m.Sum(nil))))
+	return nil
+}
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACSigner_Sign(t *testing.T) {
+	s, err := NewHMACSigner("service-a", []byte("secret"), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	req, _ := http.NewRequest("POST", "http://internal:8080/orders?id=1", strings.NewReader(`{"a":1}`))
+	bufferBody(req)
+	if err := s.Sign(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Header.Get("Date") != "Mon, 02 Jan 2023 03:04:05 GMT" {
+		t.Errorf("unexpected date: %s", req.Header.Get("Date"))
+	}
+	sum := sha256.Sum256([]byte(`{"a":1}`))
+	digest := "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+	if req.Header.Get("Digest") != digest {
+		t.Errorf("unexpected digest: %s", req.Header.Get("Digest"))
+	}
+
+	m := hmac.New(sha256.New, []byte("secret"))
+	m.Write([]byte("(request-target): post /orders?id=1\nhost: internal:8080\ndate: Mon, 02 Jan 2023 03:04:05 GMT\ndigest: " + digest))
+	want := `keyId="service-a",algorithm="hmac-sha256",headers="(request-target) host date digest",signature="` +
+		base64.StdEncoding.EncodeToString(m.Sum(nil)) + `"`
+	if have := req.Header.Get("Signature"); have != want {
+		t.Errorf("unexpected signature.\nhave: %s\nwant: %s", have, want)
+	}
+}
+
+func TestHMACSigner_Sign_missingHeader(t *testing.T) {
+	s, _ := NewHMACSigner("service-a", []byte("secret"), "hmac-sha512", []string{"X-Tenant"})
+	req, _ := http.NewRequest("GET", "http://internal/", http.NoBody)
+	bufferBody(req)
+	if err := s.Sign(req); err == nil {
+		t.Error("expecting an error for the missing signed header")
+	}
+	req.Header.Set("X-Tenant", "acme")
+	if err := s.Sign(req); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewHMACSigner_invalid(t *testing.T) {
+	if _, err := NewHMACSigner("", []byte("secret"), "", nil); err == nil {
+		t.Error("expecting an error for the missing key id")
+	}
+	if _, err := NewHMACSigner("k", []byte("secret"), "hmac-md5", nil); err == nil {
+		t.Error("expecting an error for the unsupported algorithm")
+	}
+}
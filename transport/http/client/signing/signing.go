@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package signing signs the requests sent to the backends, so the gateway can call the AWS APIs (with
+the Signature Version 4) and the internal services protected with HMAC signatures directly.
+
+The signers are declared in the backend extra config:
+
+	"github.com/luraproject/lura/transport/http/client/signing": {
+		"method": "sigv4",
+		"region": "eu-west-1",
+		"service": "execute-api"
+	}
+
+The gateway method adds the identity of the gateway to the requests, with a timestamp and a nonce,
+so the backends can reject the requests not sent by the gateway and the replays. The services
+written in Go can verify them with a GatewayVerifier.
+
+Other signing methods can be added with RegisterSigner.
+*/
+package signing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/register"
+	"github.com/luraproject/lura/v2/transport/http/client"
+)
+
+// Namespace is the key for the backend's extra config
+const Namespace = "github.com/luraproject/lura/transport/http/client/signing"
+
+// Signer adds the signature to a request. The body of the request, if any, is already buffered,
+// so signers can read it through GetBody
+type Signer interface {
+	Sign(req *http.Request) error
+}
+
+// SignerFunc type is an adapter to allow the use of ordinary functions as signers
+type SignerFunc func(req *http.Request) error
+
+// Sign implements the Signer interface
+func (f SignerFunc) Sign(req *http.Request) error { return f(req) }
+
+// SignerFactory creates a signer from the signing section of the backend extra config
+type SignerFactory func(cfg map[string]interface{}) (Signer, error)
+
+var signers = register.NewUntyped()
+
+func init() {
+	RegisterSigner(SigV4Method, NewSigV4SignerFromConfig)
+	RegisterSigner(HMACMethod, NewHMACSignerFromConfig)
+	RegisterSigner(GatewayMethod, NewGatewaySignerFromConfig)
+}
+
+// RegisterSigner adds a signer factory to the package register
+func RegisterSigner(method string, sf SignerFactory) {
+	signers.Register(method, sf)
+}
+
+// GetSignerFactory returns the signer factory registered for the method
+func GetSignerFactory(method string) (SignerFactory, bool) {
+	v, ok := signers.Get(method)
+	if !ok {
+		return nil, false
+	}
+	sf, ok := v.(SignerFactory)
+	return sf, ok
+}
+
+// SignerFromConfig returns the signer declared in the extra config of a backend. It returns
+// false if the backend does not declare any
+func SignerFromConfig(e config.ExtraConfig) (Signer, bool, error) {
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+	method, _ := tmp["method"].(string)
+	sf, ok := GetSignerFactory(method)
+	if !ok {
+		return nil, true, fmt.Errorf("signing: unknown method %q", method)
+	}
+	s, err := sf(tmp)
+	return s, true, err
+}
+
+// NewSigningExecutor decorates the executor, so the requests are signed right before being sent
+func NewSigningExecutor(s Signer, next client.HTTPRequestExecutor) client.HTTPRequestExecutor {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		if err := bufferBody(req); err != nil {
+			return nil, err
+		}
+		if err := s.Sign(req); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+func bufferBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		req.Body = nil
+		req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		return nil
+	}
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(b))
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(b)), nil }
+	return nil
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.GetBody == nil {
+		return nil, nil
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func getString(cfg map[string]interface{}, k string) string {
+	s, _ := cfg[k].(string)
+	return s
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package signing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestSignerFromConfig(t *testing.T) {
+	if _, ok, _ := SignerFromConfig(config.ExtraConfig{}); ok {
+		t.Error("the backend does not declare a signer")
+	}
+	if _, ok, err := SignerFromConfig(config.ExtraConfig{Namespace: map[string]interface{}{"method": "unknown"}}); !ok || err == nil {
+		t.Errorf("expecting an error for the unknown method. ok: %v, err: %v", ok, err)
+	}
+	if _, ok, err := SignerFromConfig(config.ExtraConfig{Namespace: map[string]interface{}{"method": "hmac"}}); !ok || err == nil {
+		t.Errorf("expecting an error for the incomplete config. ok: %v, err: %v", ok, err)
+	}
+	s, ok, err := SignerFromConfig(config.ExtraConfig{Namespace: map[string]interface{}{
+		"method":            "sigv4",
+		"region":            "us-east-1",
+		"service":           "execute-api",
+		"access_key_id":     "AKID",
+		"secret_access_key": "secret",
+	}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if _, ok := s.(*SigV4Signer); !ok {
+		t.Errorf("unexpected signer: %T", s)
+	}
+}
+
+func TestNewSigningExecutor(t *testing.T) {
+	var signedBody string
+	s := SignerFunc(func(req *http.Request) error {
+		b, err := readBody(req)
+		signedBody = string(b)
+		req.Header.Set("X-Signed", "true")
+		return err
+	})
+	re := NewSigningExecutor(s, func(_ context.Context, req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		if string(b) != "payload" || req.Header.Get("X-Signed") != "true" {
+			t.Errorf("unexpected request. body: %s, headers: %v", b, req.Header)
+		}
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+
+	req, _ := http.NewRequest("POST", "http://example.com/", strings.NewReader("payload"))
+	if _, err := re(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if signedBody != "payload" {
+		t.Errorf("unexpected signed body: %s", signedBody)
+	}
+}
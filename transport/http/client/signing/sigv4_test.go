@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package signing
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// the requests and the signatures of the AWS Signature Version 4 test suite
+func TestSigV4Signer_Sign(t *testing.T) {
+	s, err := NewSigV4Signer(Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}, "us-east-1", "service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+
+	for name, tc := range map[string]struct {
+		url       string
+		signature string
+	}{
+		"get-vanilla": {
+			url:       "https://example.amazonaws.com/",
+			signature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		"get-vanilla-query-order-key-case": {
+			url:       "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			signature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	} {
+		req, _ := http.NewRequest("GET", tc.url, http.NoBody)
+		if err := bufferBody(req); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Sign(req); err != nil {
+			t.Errorf("%s: %s", name, err.Error())
+			continue
+		}
+		want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=host;x-amz-date, Signature=" + tc.signature
+		if have := req.Header.Get("Authorization"); have != want {
+			t.Errorf("%s: unexpected authorization header.\nhave: %s\nwant: %s", name, have, want)
+		}
+	}
+}
+
+func TestSigV4Signer_Sign_sessionToken(t *testing.T) {
+	s, _ := NewSigV4Signer(Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"}, "eu-west-1", "s3")
+	req, _ := http.NewRequest("GET", "https://bucket.s3.amazonaws.com/key", http.NoBody)
+	bufferBody(req)
+	if err := s.Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("X-Amz-Security-Token") != "token" || req.Header.Get("X-Amz-Content-Sha256") == "" {
+		t.Errorf("unexpected headers: %v", req.Header)
+	}
+}
+
+func TestUriEncode(t *testing.T) {
+	if have := uriEncode("a b/c~d*"); have != "a%20b%2Fc~d%2A" {
+		t.Errorf("unexpected encoding: %s", have)
+	}
+}
@@ -0,0 +1,298 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package soap offers the envelope renderer and the response decoder of the SOAP backends, so the
+legacy SOAP services can be aggregated with the REST ones.
+
+The backends declare the template of the envelope, inline or as a file, and the SOAPAction of
+the operation:
+
+	"extra_config": {
+		"github.com/luraproject/lura/transport/http/client/soap": {
+			"template_path": "./get_user.xml",
+			"action": "urn:users#GetUser",
+			"version": "1.1"
+		}
+	}
+
+The templates are rendered with the params of the request ({{.Params.Id}}), the first value of
+its query string params ({{.Query.page}}) and its JSON body ({{.Body.name}}). All the strings are
+XML escaped before rendering.
+
+The configs declaring the former github.com/devopsfaith/krakend/transport/http/client/soap
+namespace are still accepted, as an alias of the current one.
+*/
+package soap
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/funcmap"
+)
+
+// Namespace is the key for the backend's extra config
+const Namespace = "github.com/luraproject/lura/transport/http/client/soap"
+
+// LegacyNamespace is the former key of the SOAP config, moved to Namespace when the service
+// config is initialized
+const LegacyNamespace = "github.com/devopsfaith/krakend/transport/http/client/soap"
+
+func init() {
+	config.RenameNamespace(LegacyNamespace, Namespace, config.NamespaceAliased)
+}
+
+const (
+	// Version11 is the SOAP 1.1 version, the default one
+	Version11 = "1.1"
+	// Version12 is the SOAP 1.2 version
+	Version12 = "1.2"
+)
+
+// ErrNoConfigFound is returned when the backend does not declare a SOAP config
+var ErrNoConfigFound = errors.New("soap: no configuration found")
+
+// Options is the SOAP config of a backend
+type Options struct {
+	Template     string `json:"template,omitempty"`
+	TemplatePath string `json:"template_path,omitempty"`
+	Action       string `json:"action"`
+	Version      string `json:"version,omitempty"`
+}
+
+// GetOptions extracts the Options config from the backend's extra config
+func GetOptions(cfg config.ExtraConfig) (*Options, error) {
+	tmp, ok := cfg[Namespace]
+	if !ok {
+		return nil, ErrNoConfigFound
+	}
+
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	var opt Options
+	if err := json.Unmarshal(b, &opt); err != nil {
+		return nil, fmt.Errorf("soap: parsing the config: %w", err)
+	}
+
+	switch opt.Version {
+	case "":
+		opt.Version = Version11
+	case Version11, Version12:
+	default:
+		return nil, fmt.Errorf("soap: unknown version %s", opt.Version)
+	}
+
+	if opt.TemplatePath != "" {
+		t, err := os.ReadFile(opt.TemplatePath)
+		if err != nil {
+			return nil, fmt.Errorf("soap: reading the template: %w", err)
+		}
+		opt.Template = string(t)
+	}
+	if strings.TrimSpace(opt.Template) == "" {
+		return nil, errors.New("soap: no template declared")
+	}
+
+	return &opt, nil
+}
+
+// TemplateData is the data available to the envelope templates
+type TemplateData struct {
+	Params map[string]string
+	Query  map[string]string
+	Body   interface{}
+}
+
+// Renderer renders the envelopes of a SOAP operation
+type Renderer struct {
+	opt  Options
+	tmpl *template.Template
+}
+
+// New returns the Renderer of the received options
+func New(opt Options) (*Renderer, error) {
+	tmpl, err := funcmap.New("soap").Option("missingkey=zero").Parse(opt.Template)
+	if err != nil {
+		return nil, fmt.Errorf("soap: parsing the template: %w", err)
+	}
+	return &Renderer{opt: opt, tmpl: tmpl}, nil
+}
+
+// Render returns the envelope with the received data. All the strings are XML escaped and the
+// missing body is rendered as an empty object
+func (r *Renderer) Render(data TemplateData) ([]byte, error) {
+	if data.Body == nil {
+		data.Body = map[string]interface{}{}
+	}
+	data.Params = escapeStrings(data.Params)
+	data.Query = escapeStrings(data.Query)
+	data.Body = escapeValue(data.Body)
+
+	buf := new(bytes.Buffer)
+	if err := r.tmpl.Execute(buf, data); err != nil {
+		return nil, fmt.Errorf("soap: rendering the envelope: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Headers returns the content type and the action headers of the requests, as declared by the
+// SOAP version
+func (r *Renderer) Headers() map[string][]string {
+	if r.opt.Version == Version12 {
+		ct := "application/soap+xml; charset=utf-8"
+		if r.opt.Action != "" {
+			ct += "; action=\"" + r.opt.Action + "\""
+		}
+		return map[string][]string{"Content-Type": {ct}}
+	}
+	return map[string][]string{
+		"Content-Type": {"text/xml; charset=utf-8"},
+		"Soapaction":   {"\"" + r.opt.Action + "\""},
+	}
+}
+
+func escapeStrings(in map[string]string) map[string]string {
+	res := make(map[string]string, len(in))
+	for k, v := range in {
+		res[k] = escape(v)
+	}
+	return res
+}
+
+func escapeValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		return escape(t)
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			res[k] = escapeValue(v)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(t))
+		for i, v := range t {
+			res[i] = escapeValue(v)
+		}
+		return res
+	}
+	return v
+}
+
+func escape(s string) string {
+	buf := new(bytes.Buffer)
+	xml.EscapeText(buf, []byte(s))
+	return buf.String()
+}
+
+// Fault is the error returned by the SOAP services
+type Fault struct {
+	Code   string
+	String string
+	Actor  string
+	Detail interface{}
+}
+
+// Error returns a string representation of the Fault
+func (f Fault) Error() string {
+	return fmt.Sprintf("soap: fault %s: %s", f.Code, f.String)
+}
+
+// NewDecoder returns the decoder of the SOAP responses. The envelope and the body are removed, as
+// well as the response element of the operation, and the collections are returned at the
+// 'collection' key
+func NewDecoder(isCollection bool) encoding.Decoder {
+	return func(r io.Reader, v *map[string]interface{}) error {
+		data, err := decode(r)
+		if err != nil {
+			return err
+		}
+		if isCollection {
+			*v = map[string]interface{}{"collection": encoding.XMLChildren(data)}
+			return nil
+		}
+		switch t := data.(type) {
+		case map[string]interface{}:
+			*v = t
+		default:
+			*v = map[string]interface{}{"content": t}
+		}
+		return nil
+	}
+}
+
+func decode(r io.Reader) (interface{}, error) {
+	name, data, err := encoding.DecodeXMLDocument(r)
+	if err != nil {
+		return nil, err
+	}
+	envelope, ok := data.(map[string]interface{})
+	if name != "Envelope" || !ok {
+		return nil, errors.New("soap: the response is not a SOAP envelope")
+	}
+	body, ok := envelope["Body"].(map[string]interface{})
+	if !ok {
+		// an empty body
+		return map[string]interface{}{}, nil
+	}
+	if f, ok := body["Fault"]; ok {
+		return nil, newFault(f)
+	}
+	for k, v := range body {
+		if strings.HasPrefix(k, "@") || k == "#text" {
+			continue
+		}
+		return v, nil
+	}
+	return map[string]interface{}{}, nil
+}
+
+// newFault parses the faults of both SOAP 1.1 and SOAP 1.2
+func newFault(v interface{}) Fault {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return Fault{String: fmt.Sprintf("%v", v)}
+	}
+	f := Fault{Detail: m["detail"]}
+	if s, ok := m["faultcode"].(string); ok {
+		f.Code = s
+		f.String, _ = m["faultstring"].(string)
+		f.Actor, _ = m["faultactor"].(string)
+		return f
+	}
+	// SOAP 1.2
+	if c, ok := m["Code"].(map[string]interface{}); ok {
+		f.Code, _ = c["Value"].(string)
+	}
+	if r, ok := m["Reason"].(map[string]interface{}); ok {
+		switch t := r["Text"].(type) {
+		case string:
+			f.String = t
+		case map[string]interface{}:
+			f.String, _ = t["#text"].(string)
+		case []interface{}:
+			if len(t) > 0 {
+				if s, ok := t[0].(string); ok {
+					f.String = s
+				} else if tm, ok := t[0].(map[string]interface{}); ok {
+					f.String, _ = tm["#text"].(string)
+				}
+			}
+		}
+	}
+	f.Actor, _ = m["Role"].(string)
+	f.Detail = m["Detail"]
+	return f
+}
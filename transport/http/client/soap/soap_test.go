@@ -0,0 +1,220 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package soap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const getUserTemplate = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+<soap:Body><GetUser xmlns="urn:users"><Id>{{.Params.Id}}</Id><Name>{{.Body.name}}</Name><Page>{{.Query.page}}</Page></GetUser></soap:Body>
+</soap:Envelope>`
+
+func TestGetOptions(t *testing.T) {
+	if _, err := GetOptions(config.ExtraConfig{}); err != ErrNoConfigFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	opt, err := GetOptions(config.ExtraConfig{Namespace: map[string]interface{}{
+		"template": getUserTemplate,
+		"action":   "urn:users#GetUser",
+	}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if opt.Version != Version11 {
+		t.Errorf("unexpected version: %s", opt.Version)
+	}
+
+	path := filepath.Join(t.TempDir(), "get_user.xml")
+	if err := os.WriteFile(path, []byte(getUserTemplate), 0600); err != nil {
+		t.Error(err)
+		return
+	}
+	opt, err = GetOptions(config.ExtraConfig{Namespace: map[string]interface{}{
+		"template_path": path,
+		"version":       "1.2",
+	}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if opt.Template != getUserTemplate {
+		t.Errorf("unexpected template: %s", opt.Template)
+	}
+}
+
+func TestGetOptions_ko(t *testing.T) {
+	for i, cfg := range []map[string]interface{}{
+		{},
+		{"template": getUserTemplate, "version": "2.0"},
+		{"template_path": "./unknown.xml"},
+		{"template": 42},
+	} {
+		if _, err := GetOptions(config.ExtraConfig{Namespace: cfg}); err == nil {
+			t.Errorf("#%d: expecting an error", i)
+		}
+	}
+}
+
+func TestGetOptions_legacyNamespace(t *testing.T) {
+	cfg := config.ServiceConfig{
+		Version: config.ConfigVersion,
+		Endpoints: []*config.EndpointConfig{{
+			Endpoint: "/users/{id}",
+			Method:   "GET",
+			Backend: []*config.Backend{{
+				URLPattern: "/users",
+				Host:       []string{"http://127.0.0.1:8080"},
+				ExtraConfig: config.ExtraConfig{LegacyNamespace: map[string]interface{}{
+					"template": getUserTemplate,
+					"action":   "urn:users#GetUser",
+				}},
+			}},
+		}},
+	}
+	if err := cfg.Init(); err != nil {
+		t.Fatal(err)
+	}
+	opt, err := GetOptions(cfg.Endpoints[0].Backend[0].ExtraConfig)
+	if err != nil || opt.Action != "urn:users#GetUser" {
+		t.Errorf("unexpected result. opt: %+v, err: %v", opt, err)
+	}
+}
+
+func TestRenderer(t *testing.T) {
+	r, err := New(Options{Template: getUserTemplate, Action: "urn:users#GetUser", Version: Version11})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, err := r.Render(TemplateData{
+		Params: map[string]string{"Id": "42"},
+		Query:  map[string]string{"page": "2"},
+		Body:   map[string]interface{}{"name": "<John & Jane>"},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !strings.Contains(string(b), "<Id>42</Id><Name>&lt;John &amp; Jane&gt;</Name><Page>2</Page>") {
+		t.Errorf("unexpected envelope: %s", string(b))
+	}
+	expected := map[string][]string{
+		"Content-Type": {"text/xml; charset=utf-8"},
+		"Soapaction":   {`"urn:users#GetUser"`},
+	}
+	if h := r.Headers(); !reflect.DeepEqual(h, expected) {
+		t.Errorf("unexpected headers: %v", h)
+	}
+
+	r, err = New(Options{Template: getUserTemplate, Action: "urn:users#GetUser", Version: Version12})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected = map[string][]string{
+		"Content-Type": {`application/soap+xml; charset=utf-8; action="urn:users#GetUser"`},
+	}
+	if h := r.Headers(); !reflect.DeepEqual(h, expected) {
+		t.Errorf("unexpected headers: %v", h)
+	}
+
+	if _, err := New(Options{Template: "{{.Params"}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestNewDecoder(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+	<soap:Header><Trace>abc</Trace></soap:Header>
+	<soap:Body>
+		<m:GetUserResponse xmlns:m="urn:users">
+			<m:Id>42</m:Id>
+			<m:Name>John</m:Name>
+		</m:GetUserResponse>
+	</soap:Body>
+</soap:Envelope>`
+	var result map[string]interface{}
+	if err := NewDecoder(false)(strings.NewReader(doc), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{"Id": "42", "Name": "John"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestNewDecoder_collection(t *testing.T) {
+	doc := `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>
+<ListUsersResponse><User><Id>1</Id></User><User><Id>2</Id></User></ListUsersResponse>
+</soap:Body></soap:Envelope>`
+	var result map[string]interface{}
+	if err := NewDecoder(true)(strings.NewReader(doc), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{"collection": []interface{}{
+		map[string]interface{}{"Id": "1"},
+		map[string]interface{}{"Id": "2"},
+	}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestNewDecoder_fault(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		doc      string
+		expected Fault
+	}{
+		{
+			name: "soap 1.1",
+			doc: `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:Fault>
+<faultcode>soap:Client</faultcode><faultstring>unknown user</faultstring>
+</soap:Fault></soap:Body></soap:Envelope>`,
+			expected: Fault{Code: "soap:Client", String: "unknown user"},
+		},
+		{
+			name: "soap 1.2",
+			doc: `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Body><env:Fault>
+<env:Code><env:Value>env:Sender</env:Value></env:Code>
+<env:Reason><env:Text xml:lang="en">unknown user</env:Text></env:Reason>
+</env:Fault></env:Body></env:Envelope>`,
+			expected: Fault{Code: "env:Sender", String: "unknown user"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var result map[string]interface{}
+			err := NewDecoder(false)(strings.NewReader(tc.doc), &result)
+			var f Fault
+			if !errors.As(err, &f) {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if !reflect.DeepEqual(f, tc.expected) {
+				t.Errorf("unexpected fault: %+v", f)
+			}
+		})
+	}
+}
+
+func TestNewDecoder_ko(t *testing.T) {
+	for _, doc := range []string{"", "<user><id>1</id></user>", "<soap:Envelope xmlns:soap=\"x\"><soap:Body>"} {
+		var result map[string]interface{}
+		if err := NewDecoder(false)(strings.NewReader(doc), &result); err == nil {
+			t.Errorf("expecting an error decoding %q", doc)
+		}
+	}
+}
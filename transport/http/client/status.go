@@ -22,10 +22,14 @@ var ErrInvalidStatusCode = errors.New("invalid status code")
 // HTTPStatusHandler defines how we tread the http response code
 type HTTPStatusHandler func(context.Context, *http.Response) (*http.Response, error)
 
-// GetHTTPStatusHandler returns a status handler. If the 'return_error_details' key is defined
-// at the extra config, it returns a DetailedHTTPStatusHandler. Otherwise, it returns a
+// GetHTTPStatusHandler returns a status handler. If the 'status_codes' key is defined at the extra
+// config, it returns the handler of the configured propagation mode. If the 'return_error_details'
+// key is defined, it returns a DetailedHTTPStatusHandler. Otherwise, it returns a
 // DefaultHTTPStatusHandler
 func GetHTTPStatusHandler(remote *config.Backend) HTTPStatusHandler {
+	if cfg, err := ParseStatusCodesConfig(remote.ExtraConfig); err == nil && cfg != nil {
+		return NewStatusCodesHandler(cfg)
+	}
 	if e, ok := remote.ExtraConfig[Namespace]; ok {
 		if m, ok := e.(map[string]interface{}); ok {
 			if v, ok := m["return_error_details"]; ok {
@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// StatusCodesKey is the key of the status code propagation options, declared by the backends or
+// by their endpoints
+const StatusCodesKey = "status_codes"
+
+// Status code propagation modes
+const (
+	// StatusModeMerge fails the backend with ErrInvalidStatusCode, so the endpoint applies the
+	// merged-response semantics. It is the default behaviour
+	StatusModeMerge = "merge"
+	// StatusModePassThrough returns the status code and the body of the backend to the client
+	StatusModePassThrough = "pass_through"
+	// StatusModeMap returns the status code mapped from the one of the backend
+	StatusModeMap = "map"
+)
+
+// StatusCodesConfig controls how the non-2xx statuses of a backend reach the client:
+//
+//	"extra_config": {
+//		"github.com/devopsfaith/krakend/http": {
+//			"status_codes": {
+//				"mode": "map",
+//				"map": {"404": 404, "4xx": 400, "5xx": 502}
+//			}
+//		}
+//	}
+//
+// The map accepts exact codes and ranges like 4xx, and the exact codes take precedence. The
+// statuses not matching any entry get the merged-response semantics
+type StatusCodesConfig struct {
+	Mode   string
+	exact  map[int]int
+	ranges map[int]int
+}
+
+// ParseStatusCodesConfig returns the status code propagation options of the extra config, if any
+func ParseStatusCodesConfig(e config.ExtraConfig) (*StatusCodesConfig, error) {
+	ns, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	v, ok := ns[StatusCodesKey].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	cfg := &StatusCodesConfig{Mode: StatusModeMerge, exact: map[int]int{}, ranges: map[int]int{}}
+	if mode, ok := v["mode"].(string); ok && mode != "" {
+		cfg.Mode = mode
+	}
+	switch cfg.Mode {
+	case StatusModeMerge, StatusModePassThrough:
+		return cfg, nil
+	case StatusModeMap:
+	default:
+		return nil, fmt.Errorf("unknown status code mode %q", cfg.Mode)
+	}
+
+	m, _ := v["map"].(map[string]interface{})
+	for k, target := range m {
+		code, ok := target.(float64)
+		if !ok || code < 400 || code > 599 {
+			return nil, fmt.Errorf("invalid status code %v for %s", target, k)
+		}
+		key := strings.ToLower(k)
+		if len(key) == 3 && key[1:] == "xx" && key[0] >= '1' && key[0] <= '5' {
+			cfg.ranges[int(key[0]-'0')] = int(code)
+			continue
+		}
+		status, err := strconv.Atoi(key)
+		if err != nil || status < 100 || status > 599 {
+			return nil, fmt.Errorf("invalid status code matcher %q", k)
+		}
+		cfg.exact[status] = int(code)
+	}
+	return cfg, nil
+}
+
+// StatusCode returns the status code mapped from the one received from the backend
+func (s *StatusCodesConfig) StatusCode(status int) (int, bool) {
+	if code, ok := s.exact[status]; ok {
+		return code, true
+	}
+	code, ok := s.ranges[status/100]
+	return code, ok
+}
+
+// NewStatusCodesHandler returns a HTTPStatusHandler accepting the 2xx responses and propagating
+// the rest as configured
+func NewStatusCodesHandler(cfg *StatusCodesConfig) HTTPStatusHandler {
+	switch cfg.Mode {
+	case StatusModePassThrough:
+		return func(_ context.Context, resp *http.Response) (*http.Response, error) {
+			if isSuccess(resp.StatusCode) {
+				return resp, nil
+			}
+			return resp, PassThroughResponseError{newHTTPResponseError(resp)}
+		}
+	case StatusModeMap:
+		return func(_ context.Context, resp *http.Response) (*http.Response, error) {
+			if isSuccess(resp.StatusCode) {
+				return resp, nil
+			}
+			code, ok := cfg.StatusCode(resp.StatusCode)
+			if !ok {
+				return nil, ErrInvalidStatusCode
+			}
+			return resp, HTTPResponseError{Code: code, Msg: http.StatusText(code)}
+		}
+	}
+	return func(_ context.Context, resp *http.Response) (*http.Response, error) {
+		if isSuccess(resp.StatusCode) {
+			return resp, nil
+		}
+		return nil, ErrInvalidStatusCode
+	}
+}
+
+func isSuccess(status int) bool {
+	return status >= http.StatusOK && status < http.StatusMultipleChoices
+}
+
+// PassThroughResponseError is the error returned by the backends passing their failed responses
+// through to the clients. The routers render it with the status, the body and the content type
+// of the backend
+type PassThroughResponseError struct {
+	HTTPResponseError
+}
+
+// Body returns the body returned by the backend
+func (r PassThroughResponseError) Body() []byte {
+	return []byte(r.Msg)
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func newStatusCodesBackend(cfg map[string]interface{}) *config.Backend {
+	return &config.Backend{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{StatusCodesKey: cfg},
+	}}
+}
+
+func TestGetHTTPStatusHandler_passThrough(t *testing.T) {
+	sh := GetHTTPStatusHandler(newStatusCodesBackend(map[string]interface{}{"mode": StatusModePassThrough}))
+
+	resp := &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(bytes.NewBufferString(""))}
+	if r, err := sh(context.Background(), resp); r != resp || err != nil {
+		t.Errorf("unexpected result %v %v", r, err)
+	}
+
+	resp = &http.Response{
+		StatusCode: http.StatusNotFound,
+		Body:       io.NopCloser(bytes.NewBufferString(`{"msg":"not found"}`)),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+	}
+	_, err := sh(context.Background(), resp)
+	e, ok := err.(PassThroughResponseError)
+	if !ok {
+		t.Fatalf("unexpected error %T: %v", err, err)
+	}
+	if e.StatusCode() != http.StatusNotFound || string(e.Body()) != `{"msg":"not found"}` || e.Encoding() != "application/json" {
+		t.Errorf("unexpected error %+v", e)
+	}
+}
+
+func TestGetHTTPStatusHandler_map(t *testing.T) {
+	sh := GetHTTPStatusHandler(newStatusCodesBackend(map[string]interface{}{
+		"mode": StatusModeMap,
+		"map":  map[string]interface{}{"404": 404.0, "4xx": 400.0, "5XX": 502.0},
+	}))
+
+	for status, expected := range map[int]int{
+		http.StatusNotFound:            http.StatusNotFound,
+		http.StatusConflict:            http.StatusBadRequest,
+		http.StatusServiceUnavailable:  http.StatusBadGateway,
+		http.StatusInternalServerError: http.StatusBadGateway,
+	} {
+		_, err := sh(context.Background(), &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewBufferString("boom"))})
+		e, ok := err.(HTTPResponseError)
+		if !ok || e.StatusCode() != expected || e.Error() != http.StatusText(expected) {
+			t.Errorf("%d: unexpected error %v", status, err)
+		}
+	}
+
+	if _, err := sh(context.Background(), &http.Response{StatusCode: http.StatusMovedPermanently}); err != ErrInvalidStatusCode {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestParseStatusCodesConfig(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"mode": "unknown"},
+		{"mode": StatusModeMap, "map": map[string]interface{}{"4xx": 200.0}},
+		{"mode": StatusModeMap, "map": map[string]interface{}{"4yy": 400.0}},
+		{"mode": StatusModeMap, "map": map[string]interface{}{"404": "400"}},
+	} {
+		if _, err := ParseStatusCodesConfig(newStatusCodesBackend(cfg).ExtraConfig); err == nil {
+			t.Errorf("%v: error expected", cfg)
+		}
+	}
+
+	cfg, err := ParseStatusCodesConfig(newStatusCodesBackend(map[string]interface{}{}).ExtraConfig)
+	if err != nil || cfg.Mode != StatusModeMerge {
+		t.Errorf("unexpected config %+v: %v", cfg, err)
+	}
+	sh := NewStatusCodesHandler(cfg)
+	if _, err := sh(context.Background(), &http.Response{StatusCode: http.StatusNotFound}); err != ErrInvalidStatusCode {
+		t.Errorf("unexpected error %v", err)
+	}
+}
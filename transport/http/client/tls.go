@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"sync"
+)
+
+// NewTLSHTTPClientFactory returns a HTTPClientFactory creating a dedicated http client with
+// the received TLS configuration. The transport of the client is a clone of the http default
+// transport, lazily created, so it inherits the timeouts and pool settings of the service.
+func NewTLSHTTPClientFactory(tlsConfig *tls.Config) HTTPClientFactory {
+	var (
+		once sync.Once
+		c    *http.Client
+	)
+	return func(_ context.Context) *http.Client {
+		once.Do(func() {
+			var t *http.Transport
+			if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+				t = dt.Clone()
+			} else {
+				t = &http.Transport{Proxy: http.ProxyFromEnvironment}
+			}
+			t.TLSClientConfig = tlsConfig
+			c = &http.Client{Transport: t}
+		})
+		return c
+	}
+}
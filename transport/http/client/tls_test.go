@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewTLSHTTPClientFactory(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer s.Close()
+
+	cf := NewTLSHTTPClientFactory(&tls.Config{InsecureSkipVerify: true}) // skipcq: GSC-G402
+
+	c := cf(context.Background())
+	if c != cf(context.Background()) {
+		t.Error("the factory should return always the same client")
+	}
+	if c == http.DefaultClient {
+		t.Error("the factory should not return the default client")
+	}
+
+	resp, err := c.Get(s.URL)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTeapot {
+		t.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if _, err := NewHTTPClient(context.Background()).Get(s.URL); err == nil {
+		t.Error("the default client should not trust the test server")
+	}
+}
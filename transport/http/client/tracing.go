@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/tracing"
+)
+
+// NewTracingExecutor decorates the executor, so every request sent opens a client span and
+// propagates the trace to the backend with the W3C tracecontext headers. The headers are
+// injected even for the traces not sampled, so the backends keep the sampling decision
+func NewTracingExecutor(t *tracing.Tracer, next HTTPRequestExecutor) HTTPRequestExecutor {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		ctx, span := t.Start(ctx, "HTTP "+req.Method, tracing.SpanKindClient)
+		if sc, ok := tracing.SpanContextFromContext(ctx); ok {
+			tracing.Inject(req.Header, sc)
+		}
+		span.SetAttribute("http.method", req.Method)
+		// the query string is not recorded, as it can contain credentials
+		span.SetAttribute("http.url", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+		span.SetAttribute("net.peer.name", req.URL.Hostname())
+
+		resp, err := next(ctx, req)
+		if err != nil {
+			span.RecordError(err)
+		} else {
+			span.SetAttribute("http.status_code", resp.StatusCode)
+		}
+		span.Finish()
+		return resp, err
+	}
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/tracing"
+)
+
+func TestNewTracingExecutor(t *testing.T) {
+	var received string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(tracing.TraceparentHeader)
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer ts.Close()
+
+	var spans []*tracing.Span
+	tracer, err := tracing.New(tracing.Config{}, tracing.ExporterFunc(func(_ context.Context, _ string, s []*tracing.Span) error {
+		spans = append(spans, s...)
+		return nil
+	}), logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := tracer.Start(context.Background(), "backend", tracing.SpanKindInternal)
+	re := NewTracingExecutor(tracer, DefaultHTTPRequestExecutor(NewHTTPClient))
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/foo?secret=1", nil)
+	resp, err := re(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	tracer.Close()
+
+	if len(spans) != 1 {
+		t.Fatalf("unexpected number of spans: %d", len(spans))
+	}
+	span := spans[0]
+	if span.Kind != tracing.SpanKindClient || span.Parent != parent.Context.SpanID {
+		t.Errorf("unexpected span: %+v", span)
+	}
+	if received != span.Context.Traceparent() {
+		t.Errorf("unexpected traceparent: %s", received)
+	}
+	if span.Attributes["http.status_code"] != http.StatusTeapot || span.Attributes["http.url"] != ts.URL+"/foo" {
+		t.Errorf("unexpected attributes: %v", span.Attributes)
+	}
+}
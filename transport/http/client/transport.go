@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const transportKey = "transport"
+
+// TransportConfig contains the connection pool settings of a backend. The zero values keep the
+// settings of the service transport
+type TransportConfig struct {
+	// MaxIdleConns is the maximum number of idle connections of the backend
+	MaxIdleConns int
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept for every host
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the number of connections to every host, including the active ones
+	MaxConnsPerHost int
+	// IdleConnTimeout is the time an idle connection is kept in the pool
+	IdleConnTimeout time.Duration
+	// TLSHandshakeTimeout is the maximum time to complete the TLS handshake
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout is the maximum time to wait for the headers of the response
+	ResponseHeaderTimeout time.Duration
+	// ExpectContinueTimeout is the maximum time to wait for the first response headers of the
+	// requests declaring an "Expect: 100-continue" header
+	ExpectContinueTimeout time.Duration
+	// DisableKeepAlives forces a new connection for every request
+	DisableKeepAlives bool
+	// DisableCompression prevents the transport from requesting gzip encoded responses
+	DisableCompression bool
+}
+
+// TransportConfigGetter parses the connection pool settings from the extra config of a backend.
+// It returns an error if the declared settings are not valid. The dial timeouts are declared
+// with the dialer settings:
+//
+//	"github.com/devopsfaith/krakend/http": {
+//		"transport": {
+//			"max_idle_conns": 100,
+//			"max_idle_conns_per_host": 20,
+//			"max_conns_per_host": 50,
+//			"idle_conn_timeout": "90s",
+//			"tls_handshake_timeout": "5s",
+//			"response_header_timeout": "2s",
+//			"expect_continue_timeout": "1s",
+//			"disable_keep_alives": false,
+//			"disable_compression": false
+//		}
+//	}
+func TransportConfigGetter(e config.ExtraConfig) (TransportConfig, bool, error) {
+	cfg := TransportConfig{}
+	v, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	tmp, ok := v[transportKey].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	for k, n := range map[string]*int{
+		"max_idle_conns":          &cfg.MaxIdleConns,
+		"max_idle_conns_per_host": &cfg.MaxIdleConnsPerHost,
+		"max_conns_per_host":      &cfg.MaxConnsPerHost,
+	} {
+		f, ok := tmp[k].(float64)
+		if !ok {
+			continue
+		}
+		if f < 0 || f != float64(int(f)) {
+			return cfg, true, fmt.Errorf("invalid transport %s: %v", k, f)
+		}
+		*n = int(f)
+	}
+	for k, d := range map[string]*time.Duration{
+		"idle_conn_timeout":       &cfg.IdleConnTimeout,
+		"tls_handshake_timeout":   &cfg.TLSHandshakeTimeout,
+		"response_header_timeout": &cfg.ResponseHeaderTimeout,
+		"expect_continue_timeout": &cfg.ExpectContinueTimeout,
+	} {
+		s, ok := tmp[k].(string)
+		if !ok {
+			continue
+		}
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return cfg, true, fmt.Errorf("invalid transport %s: %w", k, err)
+		}
+		if parsed < 0 {
+			return cfg, true, fmt.Errorf("invalid transport %s: %s", k, s)
+		}
+		*d = parsed
+	}
+	cfg.DisableKeepAlives, _ = tmp["disable_keep_alives"].(bool)
+	cfg.DisableCompression, _ = tmp["disable_compression"].(bool)
+	return cfg, true, nil
+}
+
+// Apply sets the declared settings to the transport
+func (cfg TransportConfig) Apply(t *http.Transport) {
+	if cfg.MaxIdleConns > 0 {
+		t.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = cfg.MaxConnsPerHost
+	}
+	if cfg.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	}
+	if cfg.ResponseHeaderTimeout > 0 {
+		t.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	}
+	if cfg.ExpectContinueTimeout > 0 {
+		t.ExpectContinueTimeout = cfg.ExpectContinueTimeout
+	}
+	t.DisableKeepAlives = t.DisableKeepAlives || cfg.DisableKeepAlives
+	t.DisableCompression = t.DisableCompression || cfg.DisableCompression
+}
+
+// NewTransportHTTPClientFactory returns a HTTPClientFactory creating a dedicated http client with
+// the received connection pool settings, so the backend does not share the pool of the rest. The
+// connections are dialed with the dialer settings and use the TLS config, if any. The transport
+// of the client is a clone of the http default transport, lazily created, so the settings not
+// declared are inherited from the service
+func NewTransportHTTPClientFactory(cfg TransportConfig, dialer *DialerConfig, tlsConfig *tls.Config) HTTPClientFactory {
+	var (
+		once sync.Once
+		c    *http.Client
+	)
+	return func(_ context.Context) *http.Client {
+		once.Do(func() {
+			c = &http.Client{Transport: newTransport(cfg, dialer, tlsConfig)}
+		})
+		return c
+	}
+}
+
+// newTransport returns a clone of the http default transport with the received settings
+func newTransport(cfg TransportConfig, dialer *DialerConfig, tlsConfig *tls.Config) *http.Transport {
+	var t *http.Transport
+	if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+		t = dt.Clone()
+	} else {
+		t = &http.Transport{Proxy: http.ProxyFromEnvironment}
+	}
+	cfg.Apply(t)
+	if dialer != nil {
+		t.DialContext = NewDialer(*dialer)
+	}
+	if tlsConfig != nil {
+		t.TLSClientConfig = tlsConfig
+	}
+	return t
+}
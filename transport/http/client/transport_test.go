@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestTransportConfigGetter(t *testing.T) {
+	if _, ok, _ := TransportConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the config should not be found")
+	}
+
+	cfg, ok, err := TransportConfigGetter(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"transport": map[string]interface{}{
+				"max_idle_conns":          100.0,
+				"max_idle_conns_per_host": 20.0,
+				"max_conns_per_host":      50.0,
+				"idle_conn_timeout":       "90s",
+				"tls_handshake_timeout":   "5s",
+				"response_header_timeout": "2s",
+				"expect_continue_timeout": "1s",
+				"disable_keep_alives":     true,
+				"disable_compression":     true,
+			},
+		},
+	})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	expected := TransportConfig{
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   20,
+		MaxConnsPerHost:       50,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 2 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		DisableKeepAlives:     true,
+		DisableCompression:    true,
+	}
+	if cfg != expected {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	for _, tmp := range []map[string]interface{}{
+		{"idle_conn_timeout": "soon"},
+		{"tls_handshake_timeout": "-1s"},
+		{"max_idle_conns_per_host": -1.0},
+		{"max_conns_per_host": 1.5},
+	} {
+		if _, _, err := TransportConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"transport": tmp}}); err == nil {
+			t.Errorf("%v: expecting an error", tmp)
+		}
+	}
+}
+
+func TestTransportConfig_Apply(t *testing.T) {
+	tr := &http.Transport{MaxIdleConns: 10, IdleConnTimeout: time.Minute}
+	TransportConfig{MaxIdleConnsPerHost: 5, DisableKeepAlives: true}.Apply(tr)
+	if tr.MaxIdleConns != 10 || tr.IdleConnTimeout != time.Minute {
+		t.Errorf("the undeclared settings should be kept: %+v", tr)
+	}
+	if tr.MaxIdleConnsPerHost != 5 || !tr.DisableKeepAlives {
+		t.Errorf("the declared settings should be applied: %+v", tr)
+	}
+}
+
+func TestNewTransportHTTPClientFactory(t *testing.T) {
+	conns := map[string]struct{}{}
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		conns[r.RemoteAddr] = struct{}{}
+	}))
+	defer ts.Close()
+
+	decorated := 0
+	SetDialContextDecorator(func(next DialContextFunc) DialContextFunc {
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			decorated++
+			return next(ctx, network, addr)
+		}
+	})
+	defer SetDialContextDecorator(NewUnixSocketDialer)
+
+	cf := NewTransportHTTPClientFactory(TransportConfig{DisableKeepAlives: true}, &DialerConfig{Timeout: time.Second}, nil)
+	c := cf(context.Background())
+	if c != cf(context.Background()) {
+		t.Error("the client should be reused")
+	}
+	if c.Transport.(*http.Transport) == http.DefaultTransport {
+		t.Error("the transport should be dedicated")
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if len(conns) != 2 {
+		t.Errorf("the keep alives should be disabled. connections: %d", len(conns))
+	}
+	if decorated != 2 {
+		t.Errorf("the dialer should be used. calls: %d", decorated)
+	}
+}
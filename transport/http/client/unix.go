@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"hash/fnv"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// DialContextFunc is the signature of the functions used by the transports to open connections
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+type unixSocketKeyType struct{}
+
+var unixSocketKey = unixSocketKeyType{}
+
+// WithUnixSocket returns a copy of the context flagging the connections to be dialed with it
+// to use the unix domain socket at the received path
+func WithUnixSocket(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, unixSocketKey, path)
+}
+
+// UnixSocketFromContext returns the path of the unix domain socket stored in the context, if any
+func UnixSocketFromContext(ctx context.Context) (string, bool) {
+	path, ok := ctx.Value(unixSocketKey).(string)
+	return path, ok && path != ""
+}
+
+// IsUnixSocketHost returns true if the host points to a unix domain socket
+func IsUnixSocketHost(host string) bool {
+	return strings.HasPrefix(host, config.UnixSocketScheme)
+}
+
+// UnixSocketHost translates a unix socket host (unix:///path/to/service.sock) into the path
+// of the socket and an http host unique for that socket, so the connection pools of the
+// transport do not mix connections to different sockets
+func UnixSocketHost(host string) (path, httpHost string) {
+	path = strings.TrimPrefix(host, config.UnixSocketScheme)
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	return path, "http://unix-" + strconv.FormatUint(h.Sum64(), 16)
+}
+
+// NewUnixSocketDialer decorates the received dialer, so the connections requested with a
+// context containing a unix socket path are dialed against that socket
+func NewUnixSocketDialer(next DialContextFunc) DialContextFunc {
+	var d net.Dialer
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if path, ok := UnixSocketFromContext(ctx); ok {
+			return d.DialContext(ctx, "unix", path)
+		}
+		return next(ctx, network, addr)
+	}
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewUnixSocketDialer(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "service.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello from %s", r.URL.Path)
+	})}
+	go s.Serve(l)
+	defer s.Close()
+
+	tcpCalls := 0
+	transport := &http.Transport{
+		DialContext: NewUnixSocketDialer(func(_ context.Context, _, _ string) (net.Conn, error) {
+			tcpCalls++
+			return nil, fmt.Errorf("unexpected tcp dial")
+		}),
+	}
+
+	path, host := UnixSocketHost("unix://" + socket)
+	if path != socket {
+		t.Errorf("unexpected socket path: %s", path)
+	}
+
+	req, _ := http.NewRequest("GET", host+"/supu", nil)
+	resp, err := (&http.Client{Transport: transport}).Do(req.WithContext(WithUnixSocket(context.Background(), path)))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "hello from /supu" {
+		t.Errorf("unexpected response: %s", string(b))
+	}
+
+	req, _ = http.NewRequest("GET", "http://127.0.0.1:1/supu", nil)
+	if _, err := (&http.Client{Transport: transport}).Do(req); err == nil {
+		t.Error("expecting an error")
+	}
+	if tcpCalls != 1 {
+		t.Errorf("unexpected number of tcp dials: %d", tcpCalls)
+	}
+}
+
+func TestUnixSocketHost(t *testing.T) {
+	if !IsUnixSocketHost("unix:///tmp/a.sock") || IsUnixSocketHost("http://supu") {
+		t.Error("unexpected host detection")
+	}
+	_, a := UnixSocketHost("unix:///tmp/a.sock")
+	_, b := UnixSocketHost("unix:///tmp/b.sock")
+	if a == b {
+		t.Errorf("different sockets should get different hosts: %s", a)
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// EngineNamespace is the key to use to store and access the name of the server engine:
+//
+//	"extra_config": {
+//		"github.com/luraproject/lura/transport/http/server/engine": {
+//			"name": "fasthttp"
+//		}
+//	}
+//
+// The services without it are served by the net/http server
+const EngineNamespace = "github.com/luraproject/lura/transport/http/server/engine"
+
+// DefaultEngine is the name of the net/http server engine
+const DefaultEngine = "net/http"
+
+// Server defines the interface of the servers exposing the gateway with an alternative engine
+type Server interface {
+	ListenAndServe() error
+	Shutdown(context.Context) error
+	Close() error
+}
+
+// ServerFactory creates a server listening at the address of the service with the injected
+// handler. The servers serve TLS by themselves when the service enables it
+type ServerFactory func(cfg config.ServiceConfig, handler http.Handler) (Server, error)
+
+// ErrEngineNotAvailable is the error returned when the service selects a server engine without
+// a registered factory
+var ErrEngineNotAvailable = errors.New("server engine not registered")
+
+var (
+	serverFactories = map[string]ServerFactory{}
+	serverMu        = new(sync.RWMutex)
+)
+
+// RegisterServerFactory makes a server engine available with the received name. Since the
+// alternative engines are not part of the standard library, they are not linked by default: the
+// github.com/luraproject/lura/v2/transport/http/server/fasthttp module registers the fasthttp
+// engine when imported, and other implementations can be injected the same way
+func RegisterServerFactory(name string, f ServerFactory) {
+	serverMu.Lock()
+	serverFactories[name] = f
+	serverMu.Unlock()
+}
+
+// EngineConfigGetter returns the name of the server engine selected in the service extra config
+func EngineConfigGetter(e config.ExtraConfig) (string, error) {
+	tmp, ok := e[EngineNamespace].(map[string]interface{})
+	if !ok {
+		return DefaultEngine, nil
+	}
+	name, ok := tmp["name"].(string)
+	if !ok && tmp["name"] != nil {
+		return DefaultEngine, fmt.Errorf("server engine: invalid name %v", tmp["name"])
+	}
+	if name == "" {
+		return DefaultEngine, nil
+	}
+	return name, nil
+}
+
+func newEngineServer(name string, cfg config.ServiceConfig, handler http.Handler) (Server, error) {
+	serverMu.RLock()
+	f, ok := serverFactories[name]
+	serverMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrEngineNotAvailable, name)
+	}
+	return f(cfg, handler)
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+type fakeServer struct {
+	stop     chan struct{}
+	shutdown bool
+}
+
+func (s *fakeServer) ListenAndServe() error {
+	<-s.stop
+	return http.ErrServerClosed
+}
+
+func (s *fakeServer) Shutdown(_ context.Context) error {
+	s.shutdown = true
+	close(s.stop)
+	return nil
+}
+
+func (*fakeServer) Close() error { return nil }
+
+func TestEngineConfigGetter(t *testing.T) {
+	for _, tc := range []struct {
+		extra    config.ExtraConfig
+		expected string
+		err      bool
+	}{
+		{config.ExtraConfig{}, DefaultEngine, false},
+		{config.ExtraConfig{EngineNamespace: map[string]interface{}{}}, DefaultEngine, false},
+		{config.ExtraConfig{EngineNamespace: map[string]interface{}{"name": "fasthttp"}}, "fasthttp", false},
+		{config.ExtraConfig{EngineNamespace: map[string]interface{}{"name": 42}}, DefaultEngine, true},
+	} {
+		name, err := EngineConfigGetter(tc.extra)
+		if name != tc.expected || (err != nil) != tc.err {
+			t.Errorf("%v: unexpected result: %s %v", tc.extra, name, err)
+		}
+	}
+}
+
+func TestRunServer_engine(t *testing.T) {
+	s := &fakeServer{stop: make(chan struct{})}
+	handlers := make(chan http.Handler, 1)
+	RegisterServerFactory("fake", func(_ config.ServiceConfig, h http.Handler) (Server, error) {
+		handlers <- h
+		return s, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- RunServer(ctx, config.ServiceConfig{
+			ExtraConfig: config.ExtraConfig{EngineNamespace: map[string]interface{}{"name": "fake"}},
+		}, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}))
+	}()
+
+	var handler http.Handler
+	select {
+	case handler = <-handlers:
+	case <-time.After(time.Second):
+		t.Fatal("the server was not created")
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", http.NoBody))
+	if w.Code != http.StatusTeapot {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the server was not shut down")
+	}
+	if !s.shutdown {
+		t.Error("the server was not drained")
+	}
+}
+
+func TestRunServer_unknownEngine(t *testing.T) {
+	err := RunServer(context.Background(), config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{EngineNamespace: map[string]interface{}{"name": "unknown"}},
+	}, http.NotFoundHandler())
+	if !errors.Is(err, ErrEngineNotAvailable) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package fasthttp serves the gateway with the fasthttp engine.
+
+The package registers its server factory in the server package when imported, so the services
+selecting the "fasthttp" engine in their extra config are served by a fasthttp.Server:
+
+	import _ "github.com/luraproject/lura/v2/transport/http/server/fasthttp"
+
+	"github.com/luraproject/lura/transport/http/server/engine": {
+		"name": "fasthttp",
+		"concurrency": 262144,
+		"max_request_body_size": 4194304
+	}
+
+The handler of the router is adapted with fasthttpadaptor, so the features of the net/http
+server traded for the throughput are listed in the docs of the server engine. The read, write
+and idle timeouts of the service apply to the engine, and the services enabling TLS are served
+with their certificates, reloaded when the hot reload is enabled. The package is a module of its
+own, so the services served by net/http do not depend on fasthttp.
+*/
+package fasthttp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/server"
+)
+
+// Engine is the name of the server engine registered by the package
+const Engine = "fasthttp"
+
+func init() {
+	server.RegisterServerFactory(Engine, NewServer)
+}
+
+// NewServer returns a server.Server serving the handler with a fasthttp.Server listening at the
+// address of the service. It implements the server.ServerFactory signature
+func NewServer(cfg config.ServiceConfig, handler http.Handler) (server.Server, error) {
+	s := &Server{
+		addr: net.JoinHostPort(cfg.Address, strconv.Itoa(cfg.Port)),
+		server: &fasthttp.Server{
+			Handler:               fasthttpadaptor.NewFastHTTPHandler(handler),
+			ReadTimeout:           cfg.ReadTimeout,
+			WriteTimeout:          cfg.WriteTimeout,
+			IdleTimeout:           cfg.IdleTimeout,
+			NoDefaultServerHeader: true,
+		},
+	}
+	if tmp, ok := cfg.ExtraConfig[server.EngineNamespace].(map[string]interface{}); ok {
+		var err error
+		if s.server.Concurrency, err = intOption(tmp, "concurrency"); err != nil {
+			return nil, err
+		}
+		if s.server.MaxRequestBodySize, err = intOption(tmp, "max_request_body_size"); err != nil {
+			return nil, err
+		}
+	}
+
+	s.tlsConfig = server.ParseTLSConfig(cfg.TLS)
+	if s.tlsConfig == nil {
+		return s, nil
+	}
+	if cfg.TLS.PublicKey == "" {
+		return nil, server.ErrPublicKey
+	}
+	if cfg.TLS.PrivateKey == "" {
+		return nil, server.ErrPrivateKey
+	}
+	if cfg.TLS.EnableHotReload {
+		r, err := server.NewCertReloader(cfg.TLS.PublicKey, cfg.TLS.PrivateKey, logging.NoOp)
+		if err != nil {
+			return nil, err
+		}
+		s.tlsConfig.GetCertificate = r.GetCertificate
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopReload = cancel
+		go r.Watch(ctx, cfg.TLS.HotReloadInterval)
+		return s, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLS.PublicKey, cfg.TLS.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	s.tlsConfig.Certificates = []tls.Certificate{cert}
+	return s, nil
+}
+
+// Server is a server.Server backed by a fasthttp.Server
+type Server struct {
+	addr       string
+	server     *fasthttp.Server
+	tlsConfig  *tls.Config
+	stopReload context.CancelFunc
+}
+
+// ListenAndServe implements the server.Server interface
+func (s *Server) ListenAndServe() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	if s.tlsConfig != nil {
+		ln = tls.NewListener(ln, s.tlsConfig)
+	}
+	return s.server.Serve(ln)
+}
+
+// Shutdown implements the server.Server interface. It stops accepting connections and waits for
+// the active ones to become idle until the context is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.stopReload != nil {
+		s.stopReload()
+	}
+	return s.server.ShutdownWithContext(ctx)
+}
+
+// Close implements the server.Server interface. It stops accepting connections and closes the
+// idle ones, since fasthttp can not interrupt the requests in flight
+func (s *Server) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Shutdown(ctx); err != nil && err != context.Canceled {
+		return err
+	}
+	return nil
+}
+
+func intOption(cfg map[string]interface{}, key string) (int, error) {
+	v, ok := cfg[key]
+	if !ok {
+		return 0, nil
+	}
+	f, ok := v.(float64)
+	if !ok || f < 0 || f != float64(int(f)) {
+		return 0, fmt.Errorf("fasthttp: invalid %s %v", key, v)
+	}
+	return int(f), nil
+}
@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package fasthttp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/transport/http/server"
+)
+
+var engineConfig = config.ExtraConfig{server.EngineNamespace: map[string]interface{}{"name": Engine}}
+
+func TestRunServer_fasthttp(t *testing.T) {
+	port := freePort(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.RunServer(
+			ctx,
+			config.ServiceConfig{Port: port, ExtraConfig: engineConfig},
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				w.Header().Set("X-Method", r.Method)
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, "%s %s", r.URL.Path, b)
+			}),
+		)
+	}()
+
+	resp := waitFor(t, http.DefaultClient, func() (*http.Response, error) {
+		return http.Post(fmt.Sprintf("http://localhost:%d/supu", port), "text/plain", strings.NewReader("tupu"))
+	})
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("X-Method") != http.MethodPost {
+		t.Errorf("unexpected response: %d %v", resp.StatusCode, resp.Header)
+	}
+	if string(body) != "/supu tupu" {
+		t.Errorf("unexpected body: %s", body)
+	}
+	if h := resp.Header.Get("Server"); h != "" {
+		t.Errorf("unexpected server header: %s", h)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("the server has not been stopped")
+	}
+}
+
+func TestRunServer_fasthttpTLS(t *testing.T) {
+	certFile, keyFile, pool := newCertificate(t)
+	port := freePort(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go server.RunServer(
+		ctx,
+		config.ServiceConfig{
+			Port:        port,
+			ExtraConfig: engineConfig,
+			TLS:         &config.TLS{PublicKey: certFile, PrivateKey: keyFile},
+		},
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, r.URL.Path)
+		}),
+	)
+
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}, Timeout: time.Second}
+	resp := waitFor(t, c, func() (*http.Response, error) {
+		return c.Get(fmt.Sprintf("https://localhost:%d/secure", port))
+	})
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "/secure" {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
+
+func TestNewServer_options(t *testing.T) {
+	s, err := NewServer(config.ServiceConfig{ExtraConfig: config.ExtraConfig{server.EngineNamespace: map[string]interface{}{
+		"name":                  Engine,
+		"concurrency":           10.0,
+		"max_request_body_size": 1024.0,
+	}}}, http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := s.(*Server).server
+	if fs.Concurrency != 10 || fs.MaxRequestBodySize != 1024 {
+		t.Errorf("unexpected options: %d %d", fs.Concurrency, fs.MaxRequestBodySize)
+	}
+
+	for _, e := range []map[string]interface{}{
+		{"concurrency": "10"},
+		{"max_request_body_size": -1.0},
+	} {
+		if _, err := NewServer(config.ServiceConfig{ExtraConfig: config.ExtraConfig{server.EngineNamespace: e}}, http.NotFoundHandler()); err == nil {
+			t.Errorf("error expected for %v", e)
+		}
+	}
+}
+
+func waitFor(t *testing.T, c *http.Client, req func() (*http.Response, error)) *http.Response {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		resp, err := req()
+		if err == nil {
+			return resp
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func newCertificate(t *testing.T) (string, string, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// HTTP3Server defines the interface of the servers able to expose the gateway over HTTP/3 (QUIC)
+type HTTP3Server interface {
+	ListenAndServe() error
+	Shutdown(context.Context) error
+}
+
+// HTTP3ServerFactory creates an HTTP/3 server listening at the received UDP address with the
+// injected handler and TLS configuration
+type HTTP3ServerFactory func(addr string, handler http.Handler, tlsConfig *tls.Config) HTTP3Server
+
+// ErrHTTP3NotAvailable is the error returned when the HTTP/3 listener is enabled but there is
+// no HTTP/3 server factory registered
+var ErrHTTP3NotAvailable = errors.New("http3 enabled but no http3 server factory registered")
+
+var (
+	http3ServerFactory HTTP3ServerFactory
+	http3Mu            = new(sync.RWMutex)
+)
+
+// RegisterHTTP3ServerFactory sets the factory to use for creating the HTTP/3 listeners. Since
+// the QUIC stack is not part of the standard library, it is not linked by default: the
+// github.com/luraproject/lura/v2/transport/http/server/http3 module registers a factory backed
+// by quic-go when imported, and other implementations can be injected the same way
+func RegisterHTTP3ServerFactory(f HTTP3ServerFactory) {
+	http3Mu.Lock()
+	http3ServerFactory = f
+	http3Mu.Unlock()
+}
+
+func getHTTP3ServerFactory() (HTTP3ServerFactory, bool) {
+	http3Mu.RLock()
+	defer http3Mu.RUnlock()
+	return http3ServerFactory, http3ServerFactory != nil
+}
+
+func isHTTP3Enabled(cfg config.ServiceConfig) bool {
+	return cfg.TLS != nil && !cfg.TLS.IsDisabled && cfg.TLS.EnableHTTP3
+}
+
+func http3Port(cfg config.ServiceConfig) int {
+	if cfg.TLS.HTTP3Port != 0 {
+		return cfg.TLS.HTTP3Port
+	}
+	return cfg.Port
+}
+
+// NewAltSvcHandler decorates the received handler, so all the responses advertise the
+// HTTP/3 endpoint available at the given port
+func NewAltSvcHandler(port int, next http.Handler) http.Handler {
+	altSvc := fmt.Sprintf(`h3=":%d"; ma=86400`, port)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", altSvc)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newHTTP3Server(cfg config.ServiceConfig, handler http.Handler, tlsConfig *tls.Config) (HTTP3Server, error) {
+	f, ok := getHTTP3ServerFactory()
+	if !ok {
+		return nil, ErrHTTP3NotAvailable
+	}
+
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+	if len(tlsConfig.Certificates) == 0 && tlsConfig.GetCertificate == nil {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.PublicKey, cfg.TLS.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if tlsConfig.MinVersion < tls.VersionTLS13 {
+		// QUIC requires TLS 1.3
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+	tlsConfig.NextProtos = []string{"h3"}
+
+	addr := net.JoinHostPort(cfg.Address, fmt.Sprintf("%d", http3Port(cfg)))
+	return f(addr, handler, tlsConfig), nil
+}
+
+// closeHTTP3Server shuts the HTTP/3 server down once the requests in flight are drained with the
+// TCP ones. The QUIC clients keep their idle connections open after being asked to go away, so
+// the remaining connections are closed right away
+func closeHTTP3Server(s HTTP3Server) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return s.Shutdown(ctx)
+}
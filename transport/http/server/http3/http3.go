@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package http3 serves the gateway over HTTP/3 with the QUIC stack of quic-go.
+
+The package registers its server factory in the server package when imported, so the services
+enabling the HTTP/3 listener in their TLS config start it alongside the TCP one:
+
+	import _ "github.com/luraproject/lura/v2/transport/http/server/http3"
+
+	"tls": {
+		"public_key": "cert.pem",
+		"private_key": "key.pem",
+		"enable_http3": true,
+		"http3_port": 8443
+	}
+
+The package is a module of its own, so the services not exposing HTTP/3 do not depend on quic-go.
+*/
+package http3
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	quichttp3 "github.com/quic-go/quic-go/http3"
+
+	"github.com/luraproject/lura/v2/transport/http/server"
+)
+
+func init() {
+	server.RegisterHTTP3ServerFactory(NewServer)
+}
+
+// NewServer returns a quic-go HTTP/3 server listening at the received UDP address with the
+// injected handler and TLS configuration. It implements the server.HTTP3ServerFactory signature
+func NewServer(addr string, handler http.Handler, tlsConfig *tls.Config) server.HTTP3Server {
+	return &quichttp3.Server{
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: quichttp3.ConfigureTLSConfig(tlsConfig),
+	}
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+type dummyHTTP3Server struct {
+	addr     string
+	started  chan struct{}
+	shutdown chan struct{}
+}
+
+func (d *dummyHTTP3Server) ListenAndServe() error {
+	close(d.started)
+	<-d.shutdown
+	return http.ErrServerClosed
+}
+
+func (d *dummyHTTP3Server) Shutdown(_ context.Context) error {
+	close(d.shutdown)
+	return nil
+}
+
+func TestRunServer_http3(t *testing.T) {
+	testKeysAreAvailable(t)
+
+	h3 := &dummyHTTP3Server{
+		started:  make(chan struct{}),
+		shutdown: make(chan struct{}),
+	}
+	RegisterHTTP3ServerFactory(func(addr string, _ http.Handler, tlsConfig *tls.Config) HTTP3Server {
+		if tlsConfig.MinVersion != tls.VersionTLS13 {
+			t.Errorf("unexpected min tls version: %d", tlsConfig.MinVersion)
+		}
+		h3.addr = addr
+		return h3
+	})
+	defer RegisterHTTP3ServerFactory(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	port := newPort()
+
+	done := make(chan error)
+	go func() {
+		done <- RunServer(
+			ctx,
+			config.ServiceConfig{
+				Port: port,
+				TLS: &config.TLS{
+					PublicKey:   "cert.pem",
+					PrivateKey:  "key.pem",
+					EnableHTTP3: true,
+					HTTP3Port:   port + 1,
+				},
+			},
+			http.HandlerFunc(dummyHandler),
+		)
+	}()
+
+	select {
+	case <-h3.started:
+	case <-time.After(time.Second):
+		t.Error("the http3 server has not been started")
+		return
+	}
+
+	if h3.addr != fmt.Sprintf(":%d", port+1) {
+		t.Errorf("unexpected http3 address: %s", h3.addr)
+	}
+
+	client, err := httpsClient("cert.pem")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	<-time.After(100 * time.Millisecond)
+
+	resp, err := client.Get(fmt.Sprintf("https://localhost:%d", port))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if h := resp.Header.Get("Alt-Svc"); h != fmt.Sprintf(`h3=":%d"; ma=86400`, port+1) {
+		t.Errorf("unexpected Alt-Svc header: %s", h)
+	}
+	cancel()
+
+	if err = <-done; err != nil {
+		t.Error(err)
+	}
+
+	select {
+	case <-h3.shutdown:
+	case <-time.After(time.Second):
+		t.Error("the http3 server has not been stopped")
+	}
+}
+
+func TestNewHTTP3Server_notAvailable(t *testing.T) {
+	cfg := config.ServiceConfig{TLS: &config.TLS{EnableHTTP3: true}}
+	if _, err := newHTTP3Server(cfg, http.HandlerFunc(dummyHandler), nil); err != ErrHTTP3NotAvailable {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
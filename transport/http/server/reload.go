@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/luraproject/lura/v2/logging"
+)
+
+// DefaultCertReloadInterval is the period between checks of the certificate files when the
+// configuration does not define one
+const DefaultCertReloadInterval = time.Minute
+
+// CertReloader keeps the server certificate in sync with the files at the configured paths.
+// Its GetCertificate method can be used as the tls.Config.GetCertificate callback, so the
+// rotated certificates are used by the new handshakes without restarting the server
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	logger   logging.Logger
+
+	mu       *sync.RWMutex
+	cert     *tls.Certificate
+	certTime time.Time
+	keyTime  time.Time
+}
+
+// NewCertReloader returns a CertReloader with the certificate loaded from the received files
+func NewCertReloader(certFile, keyFile string, logger logging.Logger) (*CertReloader, error) {
+	if logger == nil {
+		logger = logging.NoOp
+	}
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+		mu:       new(sync.RWMutex),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the last loaded certificate
+func (r *CertReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload loads the certificate from the files. If they can not be loaded, the previous
+// certificate is kept
+func (r *CertReloader) Reload() error {
+	certTime, keyTime := modTime(r.certFile), modTime(r.keyFile)
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cert, r.certTime, r.keyTime = &cert, certTime, keyTime
+	r.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the certificate every time the files are modified or the process receives
+// a SIGHUP, until the context is cancelled
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultCertReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			r.reload("SIGHUP received")
+		case <-ticker.C:
+			if r.changed() {
+				r.reload("certificate files changed")
+			}
+		}
+	}
+}
+
+func (r *CertReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !modTime(r.certFile).Equal(r.certTime) || !modTime(r.keyFile).Equal(r.keyTime)
+}
+
+func (r *CertReloader) reload(reason string) {
+	if err := r.Reload(); err != nil {
+		r.logger.Error(fmt.Sprintf("%s Unable to reload the TLS certificate (%s): %s", loggerPrefix, reason, err.Error()))
+		return
+	}
+	r.logger.Info(fmt.Sprintf("%s TLS certificate reloaded (%s)", loggerPrefix, reason))
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
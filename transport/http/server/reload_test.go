@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	writeTestCert(t, certFile, keyFile, 1)
+	r, err := NewCertReloader(certFile, keyFile, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assertSerial(t, r, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	writeTestCert(t, certFile, keyFile, 2)
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(certFile, future, future)
+	os.Chtimes(keyFile, future, future)
+
+	for i := 0; i < 100; i++ {
+		cert, _ := r.GetCertificate(nil)
+		if c, err := x509.ParseCertificate(cert.Certificate[0]); err == nil && c.SerialNumber.Int64() == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assertSerial(t, r, 2)
+
+	if err := os.WriteFile(certFile, []byte("garbage"), 0o600); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("error expected")
+	}
+	assertSerial(t, r, 2)
+
+	if _, err := NewCertReloader(certFile, keyFile, nil); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func assertSerial(t *testing.T, r *CertReloader, serial int64) {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	c, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if c.SerialNumber.Int64() != serial {
+		t.Errorf("unexpected serial number: %d", c.SerialNumber.Int64())
+	}
+}
+
+func writeTestCert(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/core"
 	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 )
@@ -80,14 +81,44 @@ func InitHTTPDefaultTransportWithLogger(cfg config.ServiceConfig, logger logging
 }
 
 func newTransport(cfg config.ServiceConfig, logger logging.Logger) *http.Transport {
+	var policy *client.HostPolicy
+	if allowed, ok := client.AllowedHostsConfigGetter(cfg.ExtraConfig); ok {
+		var err error
+		policy, err = client.NewHostPolicy(allowed)
+		if err != nil {
+			logger.Error(loggerPrefix, "Unable to parse the host allowlist, denying all the backend connections:", err.Error())
+			policy, _ = client.NewHostPolicy(client.AllowedHostsConfig{})
+		}
+		logger.Debug(loggerPrefix, "Enforcing the host allowlist on the backend connections")
+	}
+	var resolver *client.CachingResolver
+	if dnsCfg, ok, err := client.DNSCacheConfigGetter(cfg.ExtraConfig); err != nil {
+		logger.Error(loggerPrefix, "Unable to parse the dns cache config, using the system resolver:", err.Error())
+	} else if ok {
+		resolver = client.NewCachingResolver(dnsCfg, nil)
+		logger.Debug(loggerPrefix, "Caching the resolved backend addresses for", dnsCfg.TTL.String())
+	}
+	// the dialers of the backends with their own settings get the same decorators
+	decorate := func(next client.DialContextFunc) client.DialContextFunc {
+		if resolver != nil {
+			next = client.NewCachingResolverDialer(resolver, next)
+		}
+		if policy != nil {
+			next = client.NewAllowedHostsDialer(policy, next)
+		}
+		return client.NewUnixSocketDialer(next)
+	}
+	client.SetDialContextDecorator(decorate)
+
+	dialer := (&net.Dialer{
+		Timeout:       cfg.DialerTimeout,
+		KeepAlive:     cfg.DialerKeepAlive,
+		FallbackDelay: cfg.DialerFallbackDelay,
+		DualStack:     true,
+	}).DialContext
 	return &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:       cfg.DialerTimeout,
-			KeepAlive:     cfg.DialerKeepAlive,
-			FallbackDelay: cfg.DialerFallbackDelay,
-			DualStack:     true,
-		}).DialContext,
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           decorate(dialer),
 		DisableCompression:    cfg.DisableCompression,
 		DisableKeepAlives:     cfg.DisableKeepAlives,
 		MaxIdleConns:          cfg.MaxIdleConns,
@@ -101,19 +132,80 @@ func newTransport(cfg config.ServiceConfig, logger logging.Logger) *http.Transpo
 }
 
 // RunServer runs a http.Server with the given handler and configuration.
-// It configures the TLS layer if required by the received configuration.
+// It configures the TLS layer if required by the received configuration. When the context is
+// cancelled, the server is drained as declared by the ShutdownConfig of the service.
 func RunServer(ctx context.Context, cfg config.ServiceConfig, handler http.Handler) error {
 	return RunServerWithLoggerFactory(nil)(ctx, cfg, handler)
 }
 
 func RunServerWithLoggerFactory(l logging.Logger) func(context.Context, config.ServiceConfig, http.Handler) error {
+	return RunServerWithOptions(RunServerOptions{Logger: l})
+}
+
+// RunServerOptions customizes the http.Server run by RunServerWithOptions
+type RunServerOptions struct {
+	Logger logging.Logger
+	// Decorate mutates the http.Server before it starts serving, i.e: to set its ConnState hook,
+	// its ErrorLog or its BaseContext
+	Decorate func(*http.Server)
+	// Listener returns the listener to serve from, instead of listening at the address of the
+	// service, i.e: the socket passed by the service manager with socket activation
+	Listener func(config.ServiceConfig) (net.Listener, error)
+}
+
+// RunServerWithOptions returns a RunServer func customizing its http.Server with the options
+func RunServerWithOptions(opts RunServerOptions) func(context.Context, config.ServiceConfig, http.Handler) error {
+	l := opts.Logger
 	return func(ctx context.Context, cfg config.ServiceConfig, handler http.Handler) error {
-		done := make(chan error)
-		s := NewServerWithLogger(cfg, handler, l)
+		logger := l
+		if logger == nil {
+			logger = logging.NoOp
+		}
+		sc, err := ShutdownConfigGetter(cfg.ExtraConfig)
+		if err != nil {
+			return err
+		}
+		engine, err := EngineConfigGetter(cfg.ExtraConfig)
+		if err != nil {
+			return err
+		}
+		f := &inFlight{}
+		handler = f.handler(handler)
+
+		done := make(chan error, 2)
+		if engine != DefaultEngine {
+			es, err := newEngineServer(engine, cfg, handler)
+			if err != nil {
+				return err
+			}
+			logger.Debug(loggerPrefix, "Serving with the engine", engine)
+			go func() {
+				done <- es.ListenAndServe()
+			}()
+			select {
+			case err := <-done:
+				return err
+			case <-ctx.Done():
+				return drain(es, f, sc, logger)
+			}
+		}
 
+		s := NewServerWithLogger(cfg, handler, l)
+		if opts.Decorate != nil {
+			opts.Decorate(s)
+		}
 		if s.TLSConfig == nil {
 			go func() {
-				done <- s.ListenAndServe()
+				if opts.Listener == nil {
+					done <- s.ListenAndServe()
+					return
+				}
+				ln, err := opts.Listener(cfg)
+				if err != nil {
+					done <- err
+					return
+				}
+				done <- s.Serve(ln)
 			}()
 		} else {
 			if cfg.TLS.PublicKey == "" {
@@ -122,8 +214,39 @@ func RunServerWithLoggerFactory(l logging.Logger) func(context.Context, config.S
 			if cfg.TLS.PrivateKey == "" {
 				return ErrPrivateKey
 			}
+			publicKey, privateKey := cfg.TLS.PublicKey, cfg.TLS.PrivateKey
+			if cfg.TLS.EnableHotReload {
+				r, err := NewCertReloader(publicKey, privateKey, logger)
+				if err != nil {
+					return err
+				}
+				s.TLSConfig.GetCertificate = r.GetCertificate
+				publicKey, privateKey = "", ""
+				go r.Watch(ctx, cfg.TLS.HotReloadInterval)
+			}
+			if isHTTP3Enabled(cfg) {
+				h3, err := newHTTP3Server(cfg, handler, s.TLSConfig)
+				if err != nil {
+					logger.Error(loggerPrefix, "Unable to start the HTTP/3 listener:", err.Error())
+				} else {
+					s.Handler = NewAltSvcHandler(http3Port(cfg), s.Handler)
+					go func() {
+						done <- h3.ListenAndServe()
+					}()
+					defer closeHTTP3Server(h3)
+				}
+			}
 			go func() {
-				done <- s.ListenAndServeTLS(cfg.TLS.PublicKey, cfg.TLS.PrivateKey)
+				if opts.Listener == nil {
+					done <- s.ListenAndServeTLS(publicKey, privateKey)
+					return
+				}
+				ln, err := opts.Listener(cfg)
+				if err != nil {
+					done <- err
+					return
+				}
+				done <- s.ServeTLS(ln, publicKey, privateKey)
 			}()
 		}
 
@@ -131,7 +254,7 @@ func RunServerWithLoggerFactory(l logging.Logger) func(context.Context, config.S
 		case err := <-done:
 			return err
 		case <-ctx.Done():
-			return s.Shutdown(context.Background())
+			return drain(s, f, sc, logger)
 		}
 	}
 }
@@ -199,10 +322,15 @@ func ParseTLSConfigWithLogger(cfg *config.TLS, logger logging.Logger) *tls.Confi
 	return tlsConfig
 }
 
+// ParseClientTLSConfigWithLogger creates a tls.Config for the http clients from the received
+// client TLS configuration
 func ParseClientTLSConfigWithLogger(cfg *config.ClientTLS, logger logging.Logger) *tls.Config {
 	if cfg == nil {
 		return nil
 	}
+	if logger == nil {
+		logger = logging.NoOp
+	}
 	return &tls.Config{
 		InsecureSkipVerify: cfg.AllowInsecureConnections,
 		RootCAs:            loadCertPool(cfg.DisableSystemCaPool, cfg.CaCerts, logger),
@@ -211,6 +339,7 @@ func ParseClientTLSConfigWithLogger(cfg *config.ClientTLS, logger logging.Logger
 		CurvePreferences:   parseCurveIDs(cfg.CurvePreferences),
 		CipherSuites:       parseCipherSuites(cfg.CipherSuites),
 		Certificates:       loadClientCerts(cfg.ClientCerts, logger),
+		ServerName:         cfg.ServerName,
 	}
 }
 
@@ -14,6 +14,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -206,6 +207,50 @@ func TestRunServer_plain(t *testing.T) {
 	}
 }
 
+func TestRunServerWithOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var states int32
+	runServer := RunServerWithOptions(RunServerOptions{
+		Decorate: func(s *http.Server) {
+			s.ConnState = func(_ net.Conn, _ http.ConnState) { atomic.AddInt32(&states, 1) }
+		},
+		Listener: func(_ config.ServiceConfig) (net.Listener, error) { return ln, nil },
+	})
+
+	done := make(chan error)
+	go func() {
+		// the port of the service is ignored, since the server accepts from the injected listener
+		done <- runServer(ctx, config.ServiceConfig{Port: newPort()}, http.HandlerFunc(dummyHandler))
+	}()
+
+	<-time.After(100 * time.Millisecond)
+
+	resp, err := http.Get("http://" + ln.Addr().String())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if atomic.LoadInt32(&states) == 0 {
+		t.Error("the server was not decorated")
+	}
+	cancel()
+
+	if err = <-done; err != nil {
+		t.Error(err)
+	}
+}
+
 func TestRunServer_h2c(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/router/health"
+)
+
+// ShutdownNamespace is the key to use to store and access the graceful shutdown config
+const ShutdownNamespace = "github.com/luraproject/lura/transport/http/server/shutdown"
+
+// ShutdownConfig defines how the server drains the connections when its context is cancelled:
+//
+//	"extra_config": {
+//		"github.com/luraproject/lura/transport/http/server/shutdown": {
+//			"mark_not_ready": true,
+//			"drain_delay": "5s",
+//			"drain_timeout": "30s"
+//		}
+//	}
+//
+// When MarkNotReady is set, the readiness endpoint starts failing and the server keeps accepting
+// requests during the DrainDelay, so the load balancers have time to stop routing traffic to it.
+// Then the listener is closed and the requests in flight have the DrainTimeout to complete before
+// the remaining connections are closed. A zero DrainTimeout waits for them without limit
+type ShutdownConfig struct {
+	MarkNotReady bool
+	DrainDelay   time.Duration
+	DrainTimeout time.Duration
+}
+
+type rawShutdownConfig struct {
+	MarkNotReady bool   `json:"mark_not_ready"`
+	DrainDelay   string `json:"drain_delay"`
+	DrainTimeout string `json:"drain_timeout"`
+}
+
+// ShutdownConfigGetter parses the graceful shutdown config from the service extra config
+func ShutdownConfigGetter(e config.ExtraConfig) (ShutdownConfig, error) {
+	cfg := ShutdownConfig{}
+	tmp, ok := e[ShutdownNamespace].(map[string]interface{})
+	if !ok {
+		return cfg, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return cfg, err
+	}
+	var raw rawShutdownConfig
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return cfg, fmt.Errorf("shutdown: parsing the config: %w", err)
+	}
+	cfg.MarkNotReady = raw.MarkNotReady
+	if raw.DrainDelay != "" {
+		d, err := time.ParseDuration(raw.DrainDelay)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("shutdown: invalid drain delay %s", raw.DrainDelay)
+		}
+		cfg.DrainDelay = d
+	}
+	if raw.DrainTimeout != "" {
+		d, err := time.ParseDuration(raw.DrainTimeout)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("shutdown: invalid drain timeout %s", raw.DrainTimeout)
+		}
+		cfg.DrainTimeout = d
+	}
+	return cfg, nil
+}
+
+// inFlight counts the requests being served, including the ones the http.Server does not track
+// after a shutdown, like the hijacked connections
+type inFlight struct {
+	wg sync.WaitGroup
+}
+
+func (f *inFlight) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.wg.Add(1)
+		defer f.wg.Done()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// wait blocks until all the requests in flight are completed or the context is done
+func (f *inFlight) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drain shuts the server down following the config
+func drain(s Server, f *inFlight, cfg ShutdownConfig, logger logging.Logger) error {
+	if cfg.MarkNotReady {
+		health.SetDraining(true)
+		logger.Info(loggerPrefix, "Marked as not ready, draining the connections")
+	}
+	if cfg.DrainDelay > 0 {
+		time.Sleep(cfg.DrainDelay)
+	}
+
+	ctx := context.Background()
+	if cfg.DrainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.DrainTimeout)
+		defer cancel()
+	}
+	err := s.Shutdown(ctx)
+	if err == nil {
+		err = f.wait(ctx)
+	}
+	if err != nil {
+		s.Close()
+		return fmt.Errorf("shutdown: the requests in flight were not completed: %w", err)
+	}
+	logger.Debug(loggerPrefix, "All the requests in flight completed")
+	return nil
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/router/health"
+)
+
+func TestShutdownConfigGetter(t *testing.T) {
+	cfg, err := ShutdownConfigGetter(config.ExtraConfig{ShutdownNamespace: map[string]interface{}{
+		"mark_not_ready": true,
+		"drain_delay":    "1s",
+		"drain_timeout":  "30s",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.MarkNotReady || cfg.DrainDelay != time.Second || cfg.DrainTimeout != 30*time.Second {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	for _, raw := range []map[string]interface{}{
+		{"drain_delay": "soon"},
+		{"drain_timeout": "-1s"},
+		{"mark_not_ready": "yes"},
+	} {
+		if _, err := ShutdownConfigGetter(config.ExtraConfig{ShutdownNamespace: raw}); err == nil {
+			t.Errorf("error expected for %v", raw)
+		}
+	}
+}
+
+func TestRunServer_drain(t *testing.T) {
+	defer health.SetDraining(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	port := newPort()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- RunServer(ctx, config.ServiceConfig{Port: port, ExtraConfig: config.ExtraConfig{
+			ShutdownNamespace: map[string]interface{}{
+				"mark_not_ready": true,
+				"drain_delay":    "50ms",
+				"drain_timeout":  "1s",
+			},
+		}}, handler)
+	}()
+	<-time.After(100 * time.Millisecond)
+
+	body := make(chan string)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d", port))
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		body <- string(b)
+	}()
+	<-started
+	cancel()
+
+	<-time.After(20 * time.Millisecond)
+	if ready, r := health.Check(); ready || r.Checks["shutdown"] != "draining" {
+		t.Errorf("the server must be marked as not ready while draining: %+v", r)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("the server stopped with a request in flight: %v", err)
+	default:
+	}
+
+	close(release)
+	if b := <-body; b != "done" {
+		t.Errorf("unexpected response: %s", b)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunServer_drainTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	port := newPort()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	handler := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- RunServer(ctx, config.ServiceConfig{Port: port, ExtraConfig: config.ExtraConfig{
+			ShutdownNamespace: map[string]interface{}{"drain_timeout": "50ms"},
+		}}, handler)
+	}()
+	<-time.After(100 * time.Millisecond)
+
+	go http.Get(fmt.Sprintf("http://localhost:%d", port))
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("the drain timeout was not applied")
+	}
+}
@@ -0,0 +1,274 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/luraproject/lura/v2/transport/http/client/signing"
+)
+
+const (
+	snsAPIVersion = "2010-03-31"
+	sqsAPIVersion = "2012-11-05"
+	// sqsWaitTime is the time, in seconds, the SQS long polls wait for a message
+	sqsWaitTime = 20
+	// awsBase64Key is the attribute of the messages sent base64 encoded, because SNS and SQS only
+	// accept UTF-8 bodies
+	awsBase64Key = "base64encoded"
+)
+
+func awsEnvCredentials() (signing.Credentials, error) {
+	c := signing.Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return c, errors.New("pubsub: no AWS credentials declared in the environment")
+	}
+	return c, nil
+}
+
+// newAWSClient returns the client of the service of the region, signing its requests with the
+// credentials of the environment
+func newAWSClient(service, region string) (awsClient, error) {
+	creds, err := awsEnvCredentials()
+	if err != nil {
+		return awsClient{}, err
+	}
+	signer, err := signing.NewSigV4Signer(creds, region, service)
+	if err != nil {
+		return awsClient{}, err
+	}
+	return awsClient{signer: signer}, nil
+}
+
+// awsClient sends the requests of the query APIs of SNS and SQS
+type awsClient struct {
+	signer *signing.SigV4Signer
+}
+
+// call posts the form of an action to the endpoint, decoding the XML response
+func (c awsClient) call(ctx context.Context, endpoint string, form url.Values, out interface{}) error {
+	body := []byte(form.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if err := c.signer.Sign(req); err != nil {
+		return err
+	}
+	res, _, err := doRequest(req)
+	if err != nil || out == nil {
+		return err
+	}
+	return xml.Unmarshal(res, out)
+}
+
+// awsBody returns the body to send and adds the attribute flagging it as base64 encoded, if
+// required
+func awsBody(m *Message) (string, map[string]string) {
+	if utf8.Valid(m.Body) {
+		return string(m.Body), m.Metadata
+	}
+	attrs := map[string]string{awsBase64Key: "true"}
+	for k, v := range m.Metadata {
+		attrs[k] = v
+	}
+	return base64.StdEncoding.EncodeToString(m.Body), attrs
+}
+
+// awsAttributes adds the attributes of a message to the form of an action
+func awsAttributes(form url.Values, prefix string, attrs map[string]string) {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		p := prefix + "." + strconv.Itoa(i+1) + "."
+		form.Set(p+"Name", k)
+		form.Set(p+"Value.DataType", "String")
+		form.Set(p+"Value.StringValue", attrs[k])
+	}
+}
+
+// awsEndpoint returns the endpoint declared by the endpoint param of the url, if any, or the
+// default one
+func awsEndpoint(u *url.URL, defaultEndpoint string) string {
+	if e := u.Query().Get("endpoint"); e != "" {
+		if !strings.Contains(e, "://") {
+			e = "https://" + e
+		}
+		return strings.TrimSuffix(e, "/") + "/"
+	}
+	return defaultEndpoint
+}
+
+type snsTopic struct {
+	client   awsClient
+	arn      string
+	endpoint string
+}
+
+// openSNSTopic opens the topic of an url like
+// awssns:///arn:aws:sns:us-east-2:123456789012:mytopic?region=us-east-2
+func openSNSTopic(_ context.Context, u *url.URL) (Topic, error) {
+	arn := strings.TrimPrefix(u.Path, "/")
+	parts := strings.Split(arn, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sns" {
+		return nil, fmt.Errorf("pubsub: invalid awssns url %s", u)
+	}
+	region := u.Query().Get("region")
+	if region == "" {
+		region = parts[3]
+	}
+	client, err := newAWSClient("sns", region)
+	if err != nil {
+		return nil, err
+	}
+	return &snsTopic{
+		client:   client,
+		arn:      arn,
+		endpoint: awsEndpoint(u, "https://sns."+region+".amazonaws.com/"),
+	}, nil
+}
+
+// Send implements the Topic interface
+func (t *snsTopic) Send(ctx context.Context, m *Message) error {
+	body, attrs := awsBody(m)
+	form := url.Values{
+		"Action":   {"Publish"},
+		"Version":  {snsAPIVersion},
+		"TopicArn": {t.arn},
+		"Message":  {body},
+	}
+	awsAttributes(form, "MessageAttributes.entry", attrs)
+	return t.client.call(ctx, t.endpoint, form, nil)
+}
+
+// Shutdown implements the Topic interface
+func (*snsTopic) Shutdown(context.Context) error { return nil }
+
+type sqsQueue struct {
+	client awsClient
+	url    string
+}
+
+// openSQSQueue opens the queue of an url like
+// awssqs://sqs.us-east-2.amazonaws.com/123456789012/myqueue?region=us-east-2
+func openSQSQueue(u *url.URL) (*sqsQueue, error) {
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("pubsub: invalid awssqs url %s", u)
+	}
+	region := u.Query().Get("region")
+	if region == "" {
+		if parts := strings.Split(u.Host, "."); len(parts) > 2 && parts[0] == "sqs" {
+			region = parts[1]
+		}
+	}
+	if region == "" {
+		return nil, fmt.Errorf("pubsub: no region declared by the awssqs url %s", u)
+	}
+	client, err := newAWSClient("sqs", region)
+	if err != nil {
+		return nil, err
+	}
+	return &sqsQueue{
+		client: client,
+		url:    awsEndpoint(u, "https://"+u.Host+"/") + strings.TrimPrefix(u.Path, "/"),
+	}, nil
+}
+
+func openSQSTopic(_ context.Context, u *url.URL) (Topic, error) {
+	return openSQSQueue(u)
+}
+
+func openSQSSubscription(_ context.Context, u *url.URL) (Subscription, error) {
+	return openSQSQueue(u)
+}
+
+// Send implements the Topic interface
+func (q *sqsQueue) Send(ctx context.Context, m *Message) error {
+	body, attrs := awsBody(m)
+	form := url.Values{
+		"Action":      {"SendMessage"},
+		"Version":     {sqsAPIVersion},
+		"MessageBody": {body},
+	}
+	awsAttributes(form, "MessageAttribute", attrs)
+	return q.client.call(ctx, q.url, form, nil)
+}
+
+type sqsReceiveResponse struct {
+	Messages []struct {
+		ReceiptHandle string `xml:"ReceiptHandle"`
+		Body          string `xml:"Body"`
+		Attributes    []struct {
+			Name  string `xml:"Name"`
+			Value string `xml:"Value>StringValue"`
+		} `xml:"MessageAttribute"`
+	} `xml:"ReceiveMessageResult>Message"`
+}
+
+// Receive implements the Subscription interface, long polling the queue until a message arrives
+func (q *sqsQueue) Receive(ctx context.Context) (*Message, error) {
+	form := url.Values{
+		"Action":                 {"ReceiveMessage"},
+		"Version":                {sqsAPIVersion},
+		"MaxNumberOfMessages":    {"1"},
+		"WaitTimeSeconds":        {strconv.Itoa(sqsWaitTime)},
+		"MessageAttributeName.1": {"All"},
+	}
+	for {
+		var out sqsReceiveResponse
+		if err := q.client.call(ctx, q.url, form, &out); err != nil {
+			return nil, err
+		}
+		if len(out.Messages) == 0 {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		sm := out.Messages[0]
+		m := &Message{Body: []byte(sm.Body), Metadata: map[string]string{}}
+		for _, a := range sm.Attributes {
+			m.Metadata[a.Name] = a.Value
+		}
+		if m.Metadata[awsBase64Key] == "true" {
+			b, err := base64.StdEncoding.DecodeString(sm.Body)
+			if err != nil {
+				return nil, fmt.Errorf("pubsub: decoding the message: %w", err)
+			}
+			m.Body = b
+			delete(m.Metadata, awsBase64Key)
+		}
+		m.Ack = func(ctx context.Context) error {
+			form := url.Values{
+				"Action":        {"DeleteMessage"},
+				"Version":       {sqsAPIVersion},
+				"ReceiptHandle": {sm.ReceiptHandle},
+			}
+			return q.client.call(ctx, q.url, form, nil)
+		}
+		return m, nil
+	}
+}
+
+// Shutdown implements the Topic and the Subscription interfaces
+func (*sqsQueue) Shutdown(context.Context) error { return nil }
@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSQS(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	actions := make(chan string, 10)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/sqs/aws4_request") {
+			t.Errorf("unexpected authorization: %s", r.Header.Get("Authorization"))
+		}
+		if r.URL.Path != "/123456789012/orders" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		r.ParseForm()
+		switch action := r.PostForm.Get("Action"); action {
+		case "SendMessage":
+			actions <- fmt.Sprintf("%s %s %s=%s", action, r.PostForm.Get("MessageBody"), r.PostForm.Get("MessageAttribute.1.Name"), r.PostForm.Get("MessageAttribute.1.Value.StringValue"))
+			fmt.Fprint(w, "<SendMessageResponse></SendMessageResponse>")
+		case "ReceiveMessage":
+			fmt.Fprint(w, `<ReceiveMessageResponse><ReceiveMessageResult><Message>
+				<ReceiptHandle>handle-1</ReceiptHandle>
+				<Body>{"id": 42}</Body>
+				<MessageAttribute><Name>Tenant</Name><Value><StringValue>acme</StringValue><DataType>String</DataType></Value></MessageAttribute>
+			</Message></ReceiveMessageResult></ReceiveMessageResponse>`)
+		case "DeleteMessage":
+			actions <- action + " " + r.PostForm.Get("ReceiptHandle")
+			fmt.Fprint(w, "<DeleteMessageResponse></DeleteMessageResponse>")
+		default:
+			t.Errorf("unexpected action %s", action)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer s.Close()
+
+	u := "awssqs://sqs.eu-west-1.amazonaws.com/123456789012/orders?endpoint=" + s.URL
+	topic, err := OpenTopic(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := topic.Send(context.Background(), &Message{Body: []byte(`{"id": 42}`), Metadata: map[string]string{"Tenant": "acme"}}); err != nil {
+		t.Fatal(err)
+	}
+	if a := <-actions; a != `SendMessage {"id": 42} Tenant=acme` {
+		t.Errorf("unexpected action: %s", a)
+	}
+
+	sub, err := OpenSubscription(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := sub.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(m.Body) != `{"id": 42}` || m.Metadata["Tenant"] != "acme" {
+		t.Errorf("unexpected message: %+v", m)
+	}
+	if err := m.Ack(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if a := <-actions; a != "DeleteMessage handle-1" {
+		t.Errorf("unexpected action: %s", a)
+	}
+}
+
+func TestSNS(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	published := make(chan string, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		published <- fmt.Sprintf("%s %s %s %s", r.PostForm.Get("Action"), r.PostForm.Get("TopicArn"), r.PostForm.Get("Message"), r.PostForm.Get("MessageAttributes.entry.1.Name"))
+		fmt.Fprint(w, "<PublishResponse></PublishResponse>")
+	}))
+	defer s.Close()
+
+	topic, err := OpenTopic(context.Background(), "awssns:///arn:aws:sns:us-east-2:123456789012:orders?endpoint="+s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := topic.Send(context.Background(), &Message{Body: []byte{0xff, 0xfe}}); err != nil {
+		t.Fatal(err)
+	}
+	if p := <-published; p != "Publish arn:aws:sns:us-east-2:123456789012:orders //4= base64encoded" {
+		t.Errorf("unexpected publication: %s", p)
+	}
+
+	if _, err := OpenSubscription(context.Background(), "awssns:///arn:aws:sns:us-east-2:123456789012:orders"); err == nil {
+		t.Error("expecting an error")
+	}
+	if _, err := OpenTopic(context.Background(), "awssns:///orders"); err == nil {
+		t.Error("expecting an error")
+	}
+}
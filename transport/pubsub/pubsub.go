@@ -0,0 +1,331 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package pubsub offers the backends publishing the requests to the topics of the cloud messaging
+services, or pulling a message from their subscriptions, behind a single URL based config.
+
+The publishers declare the url of their topic:
+
+	"extra_config": {
+		"github.com/luraproject/lura/transport/pubsub/publisher": {
+			"topic_url": "awssns:///arn:aws:sns:us-east-2:123456789012:mytopic"
+		}
+	}
+
+and the subscribers, the url of their subscription:
+
+	"extra_config": {
+		"github.com/luraproject/lura/transport/pubsub/subscriber": {
+			"subscription_url": "awssqs://sqs.us-east-2.amazonaws.com/123456789012/myqueue?region=us-east-2"
+		}
+	}
+
+The scheme of the url selects the driver. The awssns driver works with the topics of Amazon SNS,
+the awssqs driver with the queues of Amazon SQS and the mem driver with the in-memory topics of
+the process. The AWS drivers sign their requests with the sigv4 signer of the signing package and
+the credentials of the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN variables.
+
+Other drivers, like the ones of Google Cloud Pub/Sub or Azure Service Bus, are added with
+RegisterTopicOpener and RegisterSubscriptionOpener, wrapping the clients of the SDK of the
+service, so they get its credential sources and its retries:
+
+	pubsub.RegisterTopicOpener("gcppubsub", func(ctx context.Context, u *url.URL) (pubsub.Topic, error) {
+		client, err := gcppubsub.NewClient(ctx, u.Host)
+		if err != nil {
+			return nil, err
+		}
+		return gcpTopic{client.Topic(path.Base(u.Path))}, nil
+	})
+
+where gcpTopic implements the Topic interface with the Publish method of the SDK topic.
+*/
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+const (
+	// PublisherNamespace is the key to use to store and access the publisher config
+	PublisherNamespace = "github.com/luraproject/lura/transport/pubsub/publisher"
+	// SubscriberNamespace is the key to use to store and access the subscriber config
+	SubscriberNamespace = "github.com/luraproject/lura/transport/pubsub/subscriber"
+)
+
+// ErrNoURL is returned when the publisher or the subscriber config does not declare the url
+var ErrNoURL = errors.New("pubsub: no url declared")
+
+// PublisherConfig is the config of a backend publishing the requests to a topic
+type PublisherConfig struct {
+	TopicURL string `json:"topic_url"`
+}
+
+// SubscriberConfig is the config of a backend pulling a message from a subscription per request
+type SubscriberConfig struct {
+	SubscriptionURL string `json:"subscription_url"`
+}
+
+// PublisherConfigGetter parses the publisher config from the backend extra config
+func PublisherConfigGetter(e config.ExtraConfig) (PublisherConfig, bool, error) {
+	cfg := PublisherConfig{}
+	ok, err := parseConfig(e, PublisherNamespace, &cfg)
+	if ok && err == nil && cfg.TopicURL == "" {
+		err = ErrNoURL
+	}
+	return cfg, ok, err
+}
+
+// SubscriberConfigGetter parses the subscriber config from the backend extra config
+func SubscriberConfigGetter(e config.ExtraConfig) (SubscriberConfig, bool, error) {
+	cfg := SubscriberConfig{}
+	ok, err := parseConfig(e, SubscriberNamespace, &cfg)
+	if ok && err == nil && cfg.SubscriptionURL == "" {
+		err = ErrNoURL
+	}
+	return cfg, ok, err
+}
+
+func parseConfig(e config.ExtraConfig, namespace string, cfg interface{}) (bool, error) {
+	tmp, ok := e[namespace].(map[string]interface{})
+	if !ok {
+		return false, nil
+	}
+	b, err := json.Marshal(tmp)
+	if err != nil {
+		return true, err
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return true, fmt.Errorf("pubsub: parsing the config: %w", err)
+	}
+	return true, nil
+}
+
+// Message is a message sent to a topic or received from a subscription
+type Message struct {
+	Body     []byte
+	Metadata map[string]string
+	// Ack confirms the received message was processed, so it is not delivered again. It is nil
+	// for the messages to send
+	Ack func(context.Context) error
+}
+
+// Topic publishes the messages
+type Topic interface {
+	Send(context.Context, *Message) error
+	Shutdown(context.Context) error
+}
+
+// Subscription receives the messages
+type Subscription interface {
+	// Receive blocks until a message is available or the context is cancelled
+	Receive(context.Context) (*Message, error)
+	Shutdown(context.Context) error
+}
+
+// TopicOpener opens the topic of an url
+type TopicOpener func(context.Context, *url.URL) (Topic, error)
+
+// SubscriptionOpener opens the subscription of an url
+type SubscriptionOpener func(context.Context, *url.URL) (Subscription, error)
+
+var (
+	topicOpeners = map[string]TopicOpener{
+		"mem":    openMemTopic,
+		"awssns": openSNSTopic,
+		"awssqs": openSQSTopic,
+	}
+	subscriptionOpeners = map[string]SubscriptionOpener{
+		"mem":    openMemSubscription,
+		"awssqs": openSQSSubscription,
+	}
+	openersMu sync.RWMutex
+)
+
+// RegisterTopicOpener adds the opener of the topics of an url scheme, replacing the previous one,
+// if any
+func RegisterTopicOpener(scheme string, o TopicOpener) {
+	openersMu.Lock()
+	topicOpeners[scheme] = o
+	openersMu.Unlock()
+}
+
+// RegisterSubscriptionOpener adds the opener of the subscriptions of an url scheme, replacing
+// the previous one, if any
+func RegisterSubscriptionOpener(scheme string, o SubscriptionOpener) {
+	openersMu.Lock()
+	subscriptionOpeners[scheme] = o
+	openersMu.Unlock()
+}
+
+// OpenTopic opens the topic of the url with the driver of its scheme
+func OpenTopic(ctx context.Context, rawURL string) (Topic, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("pubsub: invalid url: %w", err)
+	}
+	openersMu.RLock()
+	o, ok := topicOpeners[u.Scheme]
+	openersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("pubsub: no topic driver for the scheme %q", u.Scheme)
+	}
+	return o(ctx, u)
+}
+
+// OpenSubscription opens the subscription of the url with the driver of its scheme
+func OpenSubscription(ctx context.Context, rawURL string) (Subscription, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("pubsub: invalid url: %w", err)
+	}
+	openersMu.RLock()
+	o, ok := subscriptionOpeners[u.Scheme]
+	openersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("pubsub: no subscription driver for the scheme %q", u.Scheme)
+	}
+	return o(ctx, u)
+}
+
+// NewBackendFactory returns a BackendFactory creating the proxies of the backends declaring a
+// publisher or a subscriber config, and delegating the rest of the backends to the received one.
+// The topics and the subscriptions are shut down when the context is cancelled
+func NewBackendFactory(ctx context.Context, logger logging.Logger, bf proxy.BackendFactory) proxy.BackendFactory {
+	return func(remote *config.Backend) proxy.Proxy {
+		logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][PubSub]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+		if cfg, ok, err := SubscriberConfigGetter(remote.ExtraConfig); ok {
+			var s Subscription
+			if err == nil {
+				s, err = OpenSubscription(ctx, cfg.SubscriptionURL)
+			}
+			if err != nil {
+				logger.Error(logPrefix, err.Error())
+				return failingProxy(err)
+			}
+			go func() {
+				<-ctx.Done()
+				s.Shutdown(context.Background())
+			}()
+			logger.Debug(logPrefix, "Pulling the messages of", cfg.SubscriptionURL)
+			return NewSubscriberProxy(logger, remote, s)
+		}
+		if cfg, ok, err := PublisherConfigGetter(remote.ExtraConfig); ok {
+			var t Topic
+			if err == nil {
+				t, err = OpenTopic(ctx, cfg.TopicURL)
+			}
+			if err != nil {
+				logger.Error(logPrefix, err.Error())
+				return failingProxy(err)
+			}
+			go func() {
+				<-ctx.Done()
+				t.Shutdown(context.Background())
+			}()
+			logger.Debug(logPrefix, "Publishing the requests to", cfg.TopicURL)
+			return NewPublisherProxy(logger, remote, t)
+		}
+		return bf(remote)
+	}
+}
+
+// NewPublisherProxy returns a proxy sending the body of the requests to the topic, with their
+// headers as metadata. The responses just acknowledge the publication
+func NewPublisherProxy(logger logging.Logger, remote *config.Backend, t Topic) proxy.Proxy {
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][PubSub]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	return func(ctx context.Context, r *proxy.Request) (*proxy.Response, error) {
+		m := &Message{Metadata: map[string]string{}}
+		if r.Body != nil {
+			var err error
+			m.Body, err = io.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		for k, vs := range r.Headers {
+			if len(vs) > 0 {
+				m.Metadata[k] = vs[0]
+			}
+		}
+		if err := t.Send(ctx, m); err != nil {
+			logger.Warning(logPrefix, "Sending the message:", err.Error())
+			return nil, err
+		}
+		return &proxy.Response{
+			Data:       map[string]interface{}{"published": true},
+			IsComplete: true,
+			Metadata:   proxy.Metadata{StatusCode: http.StatusAccepted},
+		}, nil
+	}
+}
+
+// NewSubscriberProxy returns a proxy pulling a message from the subscription per request. The
+// message is decoded with the encoding of the backend and acknowledged, and its metadata are
+// returned as headers
+func NewSubscriberProxy(logger logging.Logger, remote *config.Backend, s Subscription) proxy.Proxy {
+	logPrefix := fmt.Sprintf("[BACKEND: %s %s -> %s][PubSub]", remote.ParentEndpointMethod, remote.ParentEndpoint, remote.URLPattern)
+	ef := proxy.NewEntityFormatter(remote)
+	return func(ctx context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		m, err := s.Receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if m.Ack != nil {
+			// the messages failing to decode are acknowledged too, so they are not pulled forever
+			if err := m.Ack(ctx); err != nil {
+				logger.Warning(logPrefix, "Acknowledging the message:", err.Error())
+			}
+		}
+
+		var data map[string]interface{}
+		if err := remote.Decoder(bytes.NewReader(m.Body), &data); err != nil {
+			return nil, fmt.Errorf("pubsub: decoding the message: %w", err)
+		}
+		headers := make(map[string][]string, len(m.Metadata))
+		for k, v := range m.Metadata {
+			headers[k] = []string{v}
+		}
+		res := ef.Format(proxy.Response{Data: data, IsComplete: true})
+		res.Metadata = proxy.Metadata{StatusCode: http.StatusOK, Headers: headers}
+		return &res, nil
+	}
+}
+
+func failingProxy(err error) proxy.Proxy {
+	return func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return nil, err
+	}
+}
+
+// doRequest sends a request of a cloud driver, returning the body of the successful responses
+func doRequest(req *http.Request) ([]byte, int, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(body) > 256 {
+			body = body[:256]
+		}
+		return nil, resp.StatusCode, fmt.Errorf("pubsub: %s %s responded %d: %s", req.Method, req.URL.Host, resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return body, resp.StatusCode, nil
+}
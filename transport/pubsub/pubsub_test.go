@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestNewBackendFactory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var delegated bool
+	bf := NewBackendFactory(ctx, logging.NoOp, func(*config.Backend) proxy.Proxy {
+		delegated = true
+		return proxy.NoopProxy
+	})
+
+	publisher := bf(&config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			PublisherNamespace: map[string]interface{}{"topic_url": "mem://backend-factory"},
+		},
+	})
+	subscriber := bf(&config.Backend{
+		Decoder:   encoding.JSONDecoder,
+		AllowList: []string{"id"},
+		ExtraConfig: config.ExtraConfig{
+			SubscriberNamespace: map[string]interface{}{"subscription_url": "mem://backend-factory"},
+		},
+	})
+	bf(&config.Backend{})
+	if !delegated {
+		t.Error("the backend without pubsub config was not delegated")
+	}
+
+	resp, err := publisher(ctx, &proxy.Request{
+		Body:    io.NopCloser(bytes.NewBufferString(`{"id": 42, "secret": "s"}`)),
+		Headers: map[string][]string{"X-Tenant": {"acme"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Metadata.StatusCode != 202 || resp.Data["published"] != true {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	timeout, cancelTimeout := context.WithTimeout(ctx, time.Second)
+	defer cancelTimeout()
+	resp, err = subscriber(timeout, &proxy.Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 1 || fmt.Sprint(resp.Data["id"]) != "42" {
+		t.Errorf("unexpected data: %v", resp.Data)
+	}
+	if h := resp.Metadata.Headers["X-Tenant"]; len(h) != 1 || h[0] != "acme" {
+		t.Errorf("unexpected headers: %v", resp.Metadata.Headers)
+	}
+
+	// the subscription is empty
+	timeout, cancelTimeout = context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancelTimeout()
+	if _, err := subscriber(timeout, &proxy.Request{}); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewBackendFactory_ko(t *testing.T) {
+	bf := NewBackendFactory(context.Background(), logging.NoOp, func(*config.Backend) proxy.Proxy {
+		t.Error("the backend was delegated")
+		return proxy.NoopProxy
+	})
+	for _, e := range []config.ExtraConfig{
+		{PublisherNamespace: map[string]interface{}{}},
+		{PublisherNamespace: map[string]interface{}{"topic_url": "unknown://topic"}},
+		{SubscriberNamespace: map[string]interface{}{"subscription_url": "awssns:///arn:aws:sns:us-east-2:123456789012:orders"}},
+	} {
+		if _, err := bf(&config.Backend{ExtraConfig: e})(context.Background(), &proxy.Request{}); err == nil {
+			t.Errorf("expecting an error with %v", e)
+		}
+	}
+}
+
+func TestRegisterTopicOpener(t *testing.T) {
+	var opened string
+	RegisterTopicOpener("custom", func(_ context.Context, u *url.URL) (Topic, error) {
+		opened = u.Host
+		return memTopic(make(chan *Message, 1)), nil
+	})
+	RegisterSubscriptionOpener("custom", func(_ context.Context, u *url.URL) (Subscription, error) {
+		return memSubscription(make(chan *Message, 1)), nil
+	})
+	if _, err := OpenTopic(context.Background(), "custom://orders"); err != nil || opened != "orders" {
+		t.Errorf("unexpected result: %s %v", opened, err)
+	}
+	if _, err := OpenSubscription(context.Background(), "custom://orders"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrNack is returned when the broker does not accept a message
+var ErrNack = errors.New("queue: message rejected by the broker")
+
+type amqpPublisher struct {
+	url      string
+	exchange string
+
+	mu      sync.Mutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	closes  chan *amqp.Error
+}
+
+// NewAMQPPublisher returns a Publisher sending the messages to an exchange of an AMQP 0-9-1
+// broker. The channel is in confirm mode, so every message waits for the ack of the broker
+func NewAMQPPublisher(opt Options) (Publisher, error) {
+	if err := validateAMQPURL(opt.URL); err != nil {
+		return nil, err
+	}
+	return &amqpPublisher{url: opt.URL, exchange: opt.Exchange}, nil
+}
+
+// Publish implements the Publisher interface
+func (p *amqpPublisher) Publish(ctx context.Context, m Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.channel == nil || p.channel.IsClosed() {
+		p.close()
+		if err := p.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	confirmation, err := p.channel.PublishWithDeferredConfirmWithContext(ctx, p.exchange, m.Topic, false, false, amqp.Publishing{
+		ContentType:  m.ContentType,
+		DeliveryMode: amqp.Persistent,
+		Body:         m.Body,
+	})
+	if err != nil {
+		p.close()
+		return err
+	}
+	ack, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		p.close()
+		return err
+	}
+	if ack {
+		return nil
+	}
+	// the pending confirmations are nacked when the broker closes the channel, after notifying
+	// the reason of the closing
+	select {
+	case e, ok := <-p.closes:
+		p.close()
+		if ok && e != nil {
+			return e
+		}
+		return amqp.ErrClosed
+	default:
+		return ErrNack
+	}
+}
+
+// Close implements the Publisher interface
+func (p *amqpPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.close()
+}
+
+func (p *amqpPublisher) connect(ctx context.Context) error {
+	conn, err := dialAMQP(ctx, p.url)
+	if err != nil {
+		return err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return err
+	}
+	p.conn = conn
+	p.channel = ch
+	p.closes = ch.NotifyClose(make(chan *amqp.Error, 1))
+	return nil
+}
+
+func (p *amqpPublisher) close() error {
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	p.channel = nil
+	p.closes = nil
+	if err == amqp.ErrClosed {
+		return nil
+	}
+	return err
+}
+
+func validateAMQPURL(raw string) error {
+	if _, err := amqp.ParseURI(raw); err != nil {
+		return fmt.Errorf("queue: invalid amqp url %s: %w", raw, err)
+	}
+	return nil
+}
+
+// dialAMQP opens a connection to the broker. The handshake is bounded by the deadline of the
+// context, cleared by the client once the connection is open
+func dialAMQP(ctx context.Context, url string) (*amqp.Connection, error) {
+	return amqp.DialConfig(url, amqp.Config{
+		Dial: func(network, addr string) (net.Conn, error) {
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			setDeadline(ctx, conn)
+			return conn, nil
+		},
+	})
+}
+
+type amqpConsumer struct {
+	url string
+	opt ConsumerOptions
+
+	mu    sync.Mutex
+	conns map[*amqp.Connection]struct{}
+}
+
+// NewAMQPConsumer returns a Consumer of a queue of an AMQP 0-9-1 broker. The deliveries must be
+// settled, and the broker does not send more than the prefetch count of unsettled deliveries
+func NewAMQPConsumer(opt ConsumerOptions) (Consumer, error) {
+	if err := validateAMQPURL(opt.URL); err != nil {
+		return nil, err
+	}
+	if opt.Topic == "" {
+		return nil, errors.New("queue: no queue declared")
+	}
+	return &amqpConsumer{url: opt.URL, opt: opt, conns: map[*amqp.Connection]struct{}{}}, nil
+}
+
+// Consume implements the Consumer interface
+func (c *amqpConsumer) Consume(ctx context.Context, handle func(Delivery)) error {
+	conn, err := dialAMQP(ctx, c.url)
+	if err != nil {
+		return err
+	}
+	c.track(conn, true)
+	defer c.track(conn, false)
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	if c.opt.Prefetch > 0 {
+		if err := ch.Qos(c.opt.Prefetch, 0, false); err != nil {
+			return err
+		}
+	}
+	closes := ch.NotifyClose(make(chan *amqp.Error, 1))
+	deliveries, err := ch.ConsumeWithContext(ctx, c.opt.Topic, "", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case d, ok := <-deliveries:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				// the deliveries are closed after notifying the closing of the channel, if any
+				select {
+				case e, ok := <-closes:
+					if ok && e != nil {
+						return e
+					}
+					return amqp.ErrClosed
+				default:
+					return errors.New("queue: the consumer was cancelled by the broker")
+				}
+			}
+			handle(NewDelivery(d.RoutingKey, d.Body, d.ContentType, func(ack, requeue bool) error {
+				if ack {
+					return d.Ack(false)
+				}
+				return d.Nack(false, requeue)
+			}))
+		}
+	}
+}
+
+// Close implements the Consumer interface
+func (c *amqpConsumer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for conn := range c.conns {
+		conn.Close()
+	}
+	return nil
+}
+
+func (c *amqpConsumer) track(conn *amqp.Connection, active bool) {
+	c.mu.Lock()
+	if active {
+		c.conns[conn] = struct{}{}
+	} else {
+		delete(c.conns, conn)
+	}
+	c.mu.Unlock()
+}
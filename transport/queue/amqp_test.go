@@ -0,0 +1,371 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	amqpFrameMethod    = 1
+	amqpFrameHeader    = 2
+	amqpFrameBody      = 3
+	amqpFrameHeartbeat = 8
+	amqpFrameEnd       = 0xCE
+
+	amqpChannel = 1
+)
+
+// the class and method ids of the AMQP 0-9-1 methods exchanged with the fake brokers
+var (
+	amqpConnectionStart   = [2]uint16{10, 10}
+	amqpConnectionTune    = [2]uint16{10, 30}
+	amqpConnectionOpenOk  = [2]uint16{10, 41}
+	amqpChannelOpenOk     = [2]uint16{20, 11}
+	amqpChannelClose      = [2]uint16{20, 40}
+	amqpBasicQos          = [2]uint16{60, 10}
+	amqpBasicQosOk        = [2]uint16{60, 11}
+	amqpBasicConsumeOk    = [2]uint16{60, 21}
+	amqpBasicPublish      = [2]uint16{60, 40}
+	amqpBasicDeliver      = [2]uint16{60, 60}
+	amqpBasicAck          = [2]uint16{60, 80}
+	amqpBasicNack         = [2]uint16{60, 120}
+	amqpConfirmSelectOk   = [2]uint16{85, 11}
+	amqpTestProtocolFrame = []byte("AMQP\x00\x00\x09\x01")
+)
+
+type amqpPublication struct {
+	exchange    string
+	routingKey  string
+	contentType string
+	body        []byte
+}
+
+// amqpServer accepts a connection and records the messages published. The messages with the
+// routing key "reject" are nacked and the ones with the routing key "unknown" close the channel
+func amqpServer(t *testing.T) (string, <-chan amqpPublication, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	publications := make(chan amqpPublication, 10)
+	handshake := make(chan string, 10)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		if !amqpTestHandshake(conn, r, handshake) {
+			return
+		}
+		amqpTestRead(r)
+		amqpTestMethod(conn, amqpChannel, amqpConfirmSelectOk, nil)
+
+		for tag := uint64(1); ; tag++ {
+			id, args := amqpTestRead(r)
+			if id != amqpBasicPublish {
+				return
+			}
+			var p amqpPublication
+			n := int(args[2])
+			p.exchange = string(args[3 : 3+n])
+			args = args[3+n:]
+			p.routingKey = string(args[1 : 1+args[0]])
+
+			_, _, header, _ := readAMQPTestFrame(r)
+			size := binary.BigEndian.Uint64(header[4:])
+			if binary.BigEndian.Uint16(header[12:])&0x8000 != 0 {
+				p.contentType = string(header[15 : 15+header[14]])
+			}
+			for uint64(len(p.body)) < size {
+				_, _, body, err := readAMQPTestFrame(r)
+				if err != nil {
+					return
+				}
+				p.body = append(p.body, body...)
+			}
+			publications <- p
+
+			ack := new(amqpBuffer)
+			ack.longlong(tag)
+			ack.octet(0)
+			switch p.routingKey {
+			case "reject":
+				amqpTestMethod(conn, amqpChannel, amqpBasicNack, ack.Bytes())
+			case "unknown":
+				closing := new(amqpBuffer)
+				closing.short(404)
+				closing.shortstr("NOT_FOUND - no exchange")
+				closing.short(amqpBasicPublish[0])
+				closing.short(amqpBasicPublish[1])
+				amqpTestMethod(conn, amqpChannel, amqpChannelClose, closing.Bytes())
+			default:
+				amqpTestMethod(conn, amqpChannel, amqpBasicAck, ack.Bytes())
+			}
+		}
+	}()
+	return l.Addr().String(), publications, handshake
+}
+
+func amqpTestMethod(conn net.Conn, channel uint16, id [2]uint16, args []byte) {
+	payload := new(amqpBuffer)
+	payload.short(id[0])
+	payload.short(id[1])
+	payload.Write(args)
+	writeAMQPTestFrame(conn, amqpFrameMethod, channel, payload.Bytes())
+}
+
+// amqpTestRead returns the next method sent by the client, skipping the heartbeats
+func amqpTestRead(r *bufio.Reader) ([2]uint16, []byte) {
+	for {
+		kind, _, payload, err := readAMQPTestFrame(r)
+		if kind == amqpFrameHeartbeat && err == nil {
+			continue
+		}
+		if err != nil || len(payload) < 4 {
+			return [2]uint16{}, nil
+		}
+		return [2]uint16{binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:])}, payload[4:]
+	}
+}
+
+// amqpTestHandshake opens the connection and the channel, sending the start ok args and the
+// vhost to the handshake channel
+func amqpTestHandshake(conn net.Conn, r *bufio.Reader, handshake chan<- string) bool {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header, amqpTestProtocolFrame) {
+		return false
+	}
+	start := new(amqpBuffer)
+	start.octet(0)
+	start.octet(9)
+	start.long(0)
+	start.longstr("PLAIN")
+	start.longstr("en_US")
+	amqpTestMethod(conn, 0, amqpConnectionStart, start.Bytes())
+	_, args := amqpTestRead(r)
+	handshake <- string(args)
+	tune := new(amqpBuffer)
+	tune.short(0)
+	tune.long(4096)
+	tune.short(60)
+	amqpTestMethod(conn, 0, amqpConnectionTune, tune.Bytes())
+	amqpTestRead(r)
+	_, args = amqpTestRead(r)
+	if len(args) == 0 {
+		return false
+	}
+	handshake <- string(args[1 : 1+args[0]])
+	amqpTestMethod(conn, 0, amqpConnectionOpenOk, []byte{0})
+	amqpTestRead(r)
+	amqpTestMethod(conn, amqpChannel, amqpChannelOpenOk, []byte{0, 0, 0, 0})
+	return true
+}
+
+func writeAMQPTestFrame(w io.Writer, kind byte, channel uint16, payload []byte) error {
+	buf := make([]byte, 7, 8+len(payload))
+	buf[0] = kind
+	binary.BigEndian.PutUint16(buf[1:], channel)
+	binary.BigEndian.PutUint32(buf[3:], uint32(len(payload)))
+	buf = append(buf, payload...)
+	buf = append(buf, amqpFrameEnd)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readAMQPTestFrame(r io.Reader) (kind byte, channel uint16, payload []byte, err error) {
+	header := make([]byte, 7)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	kind = header[0]
+	channel = binary.BigEndian.Uint16(header[1:])
+	payload = make([]byte, binary.BigEndian.Uint32(header[3:])+1)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	payload = payload[:len(payload)-1]
+	return
+}
+
+// amqpBuffer encodes the AMQP 0-9-1 data types
+type amqpBuffer struct {
+	bytes.Buffer
+}
+
+func (b *amqpBuffer) octet(v byte) {
+	b.WriteByte(v)
+}
+
+func (b *amqpBuffer) short(v uint16) {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], v)
+	b.Write(buf[:])
+}
+
+func (b *amqpBuffer) long(v uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	b.Write(buf[:])
+}
+
+func (b *amqpBuffer) longlong(v uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	b.Write(buf[:])
+}
+
+func (b *amqpBuffer) shortstr(s string) {
+	b.octet(byte(len(s)))
+	b.WriteString(s)
+}
+
+func (b *amqpBuffer) longstr(s string) {
+	b.long(uint32(len(s)))
+	b.WriteString(s)
+}
+
+func TestAMQPPublisher(t *testing.T) {
+	addr, publications, handshake := amqpServer(t)
+	p, err := NewAMQPPublisher(Options{URL: "amqp://user:pass@" + addr + "/orders", Exchange: "events"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	body := bytes.Repeat([]byte("a"), 10000)
+	if err := p.Publish(ctx, Message{Topic: "orders.created", Body: body, ContentType: "application/json"}); err != nil {
+		t.Fatal(err)
+	}
+	if s := <-handshake; !strings.Contains(s, "PLAIN") || !strings.Contains(s, "\x00user\x00pass") {
+		t.Errorf("unexpected start ok %q", s)
+	}
+	if vhost := <-handshake; vhost != "orders" {
+		t.Errorf("unexpected vhost %s", vhost)
+	}
+	m := <-publications
+	if m.exchange != "events" || m.routingKey != "orders.created" || m.contentType != "application/json" || !bytes.Equal(m.body, body) {
+		t.Errorf("unexpected publication %s %s %s %d", m.exchange, m.routingKey, m.contentType, len(m.body))
+	}
+
+	if err := p.Publish(ctx, Message{Topic: "reject", Body: []byte("x")}); err != ErrNack {
+		t.Errorf("unexpected error %v", err)
+	}
+	<-publications
+	if err := p.Publish(ctx, Message{Topic: "unknown"}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestNewAMQPPublisher_ko(t *testing.T) {
+	if _, err := NewAMQPPublisher(Options{URL: "nats://localhost"}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestAMQPConsumer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	handshake := make(chan string, 10)
+	settlements := make(chan string, 10)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		if !amqpTestHandshake(conn, r, handshake) {
+			return
+		}
+		if id, args := amqpTestRead(r); id != amqpBasicQos || binary.BigEndian.Uint16(args[4:]) != 5 {
+			return
+		}
+		amqpTestMethod(conn, amqpChannel, amqpBasicQosOk, nil)
+		_, args := amqpTestRead(r)
+		n := int(args[2])
+		handshake <- string(args[3 : 3+n])
+		tag := string(args[4+n : 4+n+int(args[3+n])])
+		ok := new(amqpBuffer)
+		ok.shortstr(tag)
+		amqpTestMethod(conn, amqpChannel, amqpBasicConsumeOk, ok.Bytes())
+
+		for i, body := range []string{"first", "second"} {
+			deliver := new(amqpBuffer)
+			deliver.shortstr(tag)
+			deliver.longlong(uint64(i + 1))
+			deliver.octet(0)
+			deliver.shortstr("orders")
+			deliver.shortstr("orders.created")
+			amqpTestMethod(conn, amqpChannel, amqpBasicDeliver, deliver.Bytes())
+			header := new(amqpBuffer)
+			header.short(60)
+			header.short(0)
+			header.longlong(uint64(len(body)))
+			header.short(0x8000)
+			header.shortstr("text/plain")
+			writeAMQPTestFrame(conn, amqpFrameHeader, amqpChannel, header.Bytes())
+			writeAMQPTestFrame(conn, amqpFrameBody, amqpChannel, []byte(body))
+
+			id, args := amqpTestRead(r)
+			settlements <- fmt.Sprintf("%v %d %d", id, binary.BigEndian.Uint64(args), args[8])
+		}
+	}()
+
+	c, err := NewAMQPConsumer(ConsumerOptions{URL: "amqp://" + l.Addr().String(), Topic: "orders", Prefetch: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var received []Delivery
+	err = c.Consume(ctx, func(d Delivery) {
+		received = append(received, d)
+		if len(received) == 1 {
+			d.Ack()
+			return
+		}
+		d.Nack(true)
+		cancel()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := <-handshake; !strings.Contains(s, "\x00guest\x00guest") {
+		t.Errorf("unexpected start ok %q", s)
+	}
+	<-handshake
+	if queue := <-handshake; queue != "orders" {
+		t.Errorf("unexpected queue %s", queue)
+	}
+	if len(received) != 2 || string(received[0].Body) != "first" || received[0].Topic != "orders.created" || received[0].ContentType != "text/plain" {
+		t.Errorf("unexpected deliveries %+v", received)
+	}
+	if s := <-settlements; s != "[60 80] 1 0" {
+		t.Errorf("unexpected ack %s", s)
+	}
+	if s := <-settlements; s != "[60 120] 2 2" {
+		t.Errorf("unexpected nack %s", s)
+	}
+}
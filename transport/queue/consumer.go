@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// OffsetOldest starts the Kafka consumers without committed offsets from the oldest message
+	// of the partitions
+	OffsetOldest = "oldest"
+	// OffsetNewest starts the Kafka consumers without committed offsets from the next message
+	// published to the partitions
+	OffsetNewest = "newest"
+	// DefaultCommitInterval is the period of the commits of the Kafka consumers when the options
+	// do not declare one
+	DefaultCommitInterval = time.Second
+)
+
+// Delivery is a message received from a broker
+type Delivery struct {
+	// Topic is the routing key or the subject of the message
+	Topic       string
+	Body        []byte
+	ContentType string
+
+	settle func(ack, requeue bool) error
+}
+
+// NewDelivery returns a Delivery settled with the received function, so the consumers of the
+// custom drivers can ack and nack their messages
+func NewDelivery(topic string, body []byte, contentType string, settle func(ack, requeue bool) error) Delivery {
+	return Delivery{Topic: topic, Body: body, ContentType: contentType, settle: settle}
+}
+
+// Ack confirms the message was processed
+func (d Delivery) Ack() error {
+	if d.settle == nil {
+		return nil
+	}
+	return d.settle(true, false)
+}
+
+// Nack rejects the message, asking the broker to deliver it again or not
+func (d Delivery) Nack(requeue bool) error {
+	if d.settle == nil {
+		return nil
+	}
+	return d.settle(false, requeue)
+}
+
+// ConsumerOptions defines the source of the messages of a consumer
+type ConsumerOptions struct {
+	Driver string
+	URL    string
+	// Topic is the queue or the subject to consume
+	Topic string
+	// Group is the queue group of the NATS subscriptions, so the messages are distributed among
+	// the members of the group
+	Group string
+	// Prefetch is the max number of deliveries not settled yet
+	Prefetch int
+	// InitialOffset is the offset the Kafka consumers start from when their group has no
+	// committed offsets: OffsetOldest or OffsetNewest, the default one
+	InitialOffset string
+	// CommitInterval is the period of the commits of the offsets of the Kafka consumers
+	CommitInterval time.Duration
+}
+
+// Consumer receives the messages of a queue or a subject
+type Consumer interface {
+	// Consume passes the received messages to the handler until the context is cancelled or the
+	// connection fails. The handler is not called concurrently
+	Consume(context.Context, func(Delivery)) error
+	Close() error
+}
+
+// ConsumerFactory creates the consumer of the received options
+type ConsumerFactory func(ConsumerOptions) (Consumer, error)
+
+var (
+	consumerDrivers = map[string]ConsumerFactory{
+		DriverAMQP: NewAMQPConsumer,
+		DriverNATS: NewNATSConsumer,
+	}
+	consumerDriversMu sync.RWMutex
+)
+
+// RegisterConsumerDriver adds a consumer driver to the set of available ones, replacing the
+// previous one with the same name, if any
+func RegisterConsumerDriver(name string, cf ConsumerFactory) {
+	consumerDriversMu.Lock()
+	consumerDrivers[name] = cf
+	consumerDriversMu.Unlock()
+}
+
+// GetConsumerDriver returns the consumer factory of a driver
+func GetConsumerDriver(name string) (ConsumerFactory, bool) {
+	consumerDriversMu.RLock()
+	defer consumerDriversMu.RUnlock()
+	cf, ok := consumerDrivers[name]
+	return cf, ok
+}
@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package queue
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRegisterConsumerDriver(t *testing.T) {
+	for _, d := range []string{DriverAMQP, DriverNATS} {
+		if _, ok := GetConsumerDriver(d); !ok {
+			t.Errorf("the %s driver is not registered", d)
+		}
+	}
+	RegisterConsumerDriver("dummy", func(ConsumerOptions) (Consumer, error) { return nil, nil })
+	if _, ok := GetConsumerDriver("dummy"); !ok {
+		t.Error("the dummy driver is not registered")
+	}
+	if _, ok := GetConsumerDriver("unknown"); ok {
+		t.Error("unexpected driver")
+	}
+}
+
+func TestDelivery(t *testing.T) {
+	var settlements []string
+	d := NewDelivery("topic", []byte("body"), "text/plain", func(ack, requeue bool) error {
+		settlements = append(settlements, fmt.Sprintf("%v %v", ack, requeue))
+		return nil
+	})
+	d.Ack()
+	d.Nack(true)
+	d.Nack(false)
+	if fmt.Sprintf("%v", settlements) != "[true false false true false false]" {
+		t.Errorf("unexpected settlements: %v", settlements)
+	}
+	if err := (Delivery{}).Ack(); err != nil {
+		t.Error(err)
+	}
+}
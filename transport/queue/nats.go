@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsDefaultPending is the number of messages buffered by the NATS consumers declaring no
+// prefetch count
+const natsDefaultPending = 1024
+
+type natsPublisher struct {
+	url string
+
+	mu   sync.Mutex
+	conn *nats.Conn
+}
+
+// NewNATSPublisher returns a Publisher sending the messages to the subjects of a NATS server.
+// Every message is followed by a flush, so the publisher knows the server processed it
+func NewNATSPublisher(opt Options) (Publisher, error) {
+	if err := validateNATSURL(opt.URL); err != nil {
+		return nil, err
+	}
+	return &natsPublisher{url: opt.URL}, nil
+}
+
+// Publish implements the Publisher interface
+func (p *natsPublisher) Publish(ctx context.Context, m Message) error {
+	if !validSubject(m.Topic) {
+		return fmt.Errorf("queue: invalid subject %q", m.Topic)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil || p.conn.IsClosed() {
+		conn, err := dialNATS(ctx, p.url)
+		if err != nil {
+			return err
+		}
+		p.conn = conn
+	}
+
+	// the permission violations do not close the connection, so they are detected by comparing
+	// the last error of the connection before and after the flush
+	last := p.conn.LastError()
+	if err := p.conn.Publish(m.Topic, m.Body); err != nil {
+		p.close()
+		return err
+	}
+	if err := flushNATS(ctx, p.conn); err != nil {
+		p.close()
+		return err
+	}
+	if err := p.conn.LastError(); err != nil && err != last {
+		return err
+	}
+	return nil
+}
+
+// Close implements the Publisher interface
+func (p *natsPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.close()
+}
+
+func (p *natsPublisher) close() error {
+	if p.conn == nil {
+		return nil
+	}
+	p.conn.Close()
+	p.conn = nil
+	return nil
+}
+
+func validateNATSURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("queue: invalid url: %w", err)
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return fmt.Errorf("queue: invalid nats url %s", raw)
+	}
+	return nil
+}
+
+func validSubject(s string) bool {
+	return s != "" && !strings.ContainsAny(s, " \t\r\n")
+}
+
+// dialNATS connects to the server, bounding the dial with the deadline of the context. The
+// connections are not reconnected by the client, so the failures reach the publishers and the
+// consumers
+func dialNATS(ctx context.Context, raw string, opts ...nats.Option) (*nats.Conn, error) {
+	opts = append(opts, nats.Name("lura"), nats.NoReconnect())
+	if d, ok := ctx.Deadline(); ok {
+		opts = append(opts, nats.Timeout(time.Until(d)))
+	}
+	return nats.Connect(raw, opts...)
+}
+
+func flushNATS(ctx context.Context, conn *nats.Conn) error {
+	if _, ok := ctx.Deadline(); ok {
+		return conn.FlushWithContext(ctx)
+	}
+	return conn.Flush()
+}
+
+type natsConsumer struct {
+	url   string
+	opt   ConsumerOptions
+	mu    sync.Mutex
+	conns map[*nats.Conn]struct{}
+}
+
+// NewNATSConsumer returns a Consumer subscribed to a subject of a NATS server, in the declared
+// queue group, if any. The NATS core subjects do not keep the messages, so the deliveries do not
+// require a settlement
+func NewNATSConsumer(opt ConsumerOptions) (Consumer, error) {
+	if err := validateNATSURL(opt.URL); err != nil {
+		return nil, err
+	}
+	if !validSubject(opt.Topic) {
+		return nil, fmt.Errorf("queue: invalid subject %q", opt.Topic)
+	}
+	return &natsConsumer{url: opt.URL, opt: opt, conns: map[*nats.Conn]struct{}{}}, nil
+}
+
+// Consume implements the Consumer interface
+func (c *natsConsumer) Consume(ctx context.Context, handle func(Delivery)) error {
+	closed := make(chan struct{})
+	conn, err := dialNATS(ctx, c.url, nats.ClosedHandler(func(*nats.Conn) { close(closed) }))
+	if err != nil {
+		return err
+	}
+	c.track(conn, true)
+	defer c.track(conn, false)
+	defer conn.Close()
+
+	pending := c.opt.Prefetch
+	if pending <= 0 {
+		pending = natsDefaultPending
+	}
+	msgs := make(chan *nats.Msg, pending)
+	if _, err := conn.ChanQueueSubscribe(c.opt.Topic, c.opt.Group, msgs); err != nil {
+		return err
+	}
+	if err := flushNATS(ctx, conn); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-closed:
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err := conn.LastError(); err != nil {
+				return err
+			}
+			return errors.New("queue: nats connection closed")
+		case msg := <-msgs:
+			handle(NewDelivery(msg.Subject, msg.Data, msg.Header.Get("Content-Type"), nil))
+		}
+	}
+}
+
+// Close implements the Consumer interface
+func (c *natsConsumer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for conn := range c.conns {
+		conn.Close()
+	}
+	return nil
+}
+
+func (c *natsConsumer) track(conn *nats.Conn, active bool) {
+	c.mu.Lock()
+	if active {
+		c.conns[conn] = struct{}{}
+	} else {
+		delete(c.conns, conn)
+	}
+	c.mu.Unlock()
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package queue
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// natsServer accepts a connection and records the commands received. The publications with the
+// subject "fail" are answered with an error
+func natsServer(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	commands := make(chan string, 100)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n"))
+		r := bufio.NewReader(conn)
+		failed := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch {
+			case strings.HasPrefix(line, "PUB "):
+				payload, _ := r.ReadString('\n')
+				commands <- line + " " + strings.TrimRight(payload, "\r\n")
+				failed = strings.HasPrefix(line, "PUB fail ")
+			case line == "PING":
+				if failed {
+					conn.Write([]byte("-ERR 'Permissions Violation for Publish to \"fail\"'\r\n"))
+				}
+				conn.Write([]byte("PONG\r\n"))
+			case line == "PONG":
+			default:
+				commands <- line
+			}
+		}
+	}()
+	return l.Addr().String(), commands
+}
+
+func TestNATSPublisher(t *testing.T) {
+	addr, commands := natsServer(t)
+	p, err := NewNATSPublisher(Options{URL: "nats://user:pass@" + addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := p.Publish(ctx, Message{Topic: "orders.created", Body: []byte(`{"id":1}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if c := <-commands; !strings.HasPrefix(c, "CONNECT ") || !strings.Contains(c, `"user":"user"`) || !strings.Contains(c, `"pass":"pass"`) {
+		t.Errorf("unexpected connect %s", c)
+	}
+	if c := <-commands; c != `PUB orders.created 8 {"id":1}` {
+		t.Errorf("unexpected publication %s", c)
+	}
+
+	if err := p.Publish(ctx, Message{Topic: "fail", Body: []byte("x")}); err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := p.Publish(ctx, Message{Topic: "with spaces"}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestNewNATSPublisher_ko(t *testing.T) {
+	if _, err := NewNATSPublisher(Options{URL: "http://localhost"}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestNATSConsumer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	subscriptions := make(chan string, 1)
+	pongs := make(chan struct{}, 1)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {\"max_payload\":1048576}\r\n"))
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch {
+			case strings.HasPrefix(line, "SUB "):
+				subscriptions <- line
+			case line == "PING":
+				conn.Write([]byte("PONG\r\n"))
+				if len(subscriptions) > 0 {
+					conn.Write([]byte("PING\r\nMSG orders.created 1 5\r\nhello\r\nMSG orders.created 1 _INBOX.1 2\r\nok\r\n"))
+				}
+			case line == "PONG":
+				pongs <- struct{}{}
+			}
+		}
+	}()
+
+	c, err := NewNATSConsumer(ConsumerOptions{URL: "nats://" + l.Addr().String(), Topic: "orders.*", Group: "workers"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var received []string
+	err = c.Consume(ctx, func(d Delivery) {
+		received = append(received, d.Topic+" "+string(d.Body))
+		if err := d.Ack(); err != nil {
+			t.Error(err)
+		}
+		if len(received) == 2 {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := <-subscriptions; s != "SUB orders.* workers 1" {
+		t.Errorf("unexpected subscription %s", s)
+	}
+	<-pongs
+	if len(received) != 2 || received[0] != "orders.created hello" || received[1] != "orders.created ok" {
+		t.Errorf("unexpected deliveries %v", received)
+	}
+
+	if _, err := NewNATSConsumer(ConsumerOptions{URL: "nats://localhost", Topic: "with spaces"}); err == nil {
+		t.Error("expecting an error")
+	}
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestGetOptions(t *testing.T) {
+	if _, err := GetOptions(config.ExtraConfig{}); err != ErrNoConfigFound {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	opt, err := GetOptions(config.ExtraConfig{Namespace: map[string]interface{}{
+		"driver":  "nats",
+		"url":     "nats://localhost:4222",
+		"subject": "orders",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.AckStatus != DefaultAckStatus || opt.ContentType != "application/json" {
+		t.Errorf("unexpected defaults %+v", opt)
+	}
+
+	for _, raw := range []map[string]interface{}{
+		{"driver": "kafka", "url": "kafka://localhost", "subject": "orders"},
+		{"driver": "nats", "subject": "orders"},
+		{"driver": "nats", "url": "nats://localhost"},
+		{"driver": "amqp", "url": "amqp://localhost"},
+		{"driver": "amqp", "url": "amqp://localhost", "exchange": "orders", "ack_status": 500},
+		{"driver": "amqp", "url": "amqp://localhost", "exchange": "orders", "template_path": "./unknown.tmpl"},
+	} {
+		if _, err := GetOptions(config.ExtraConfig{Namespace: raw}); err == nil || err == ErrNoConfigFound {
+			t.Errorf("%v: unexpected error %v", raw, err)
+		}
+	}
+}
+
+func TestRenderer(t *testing.T) {
+	r, err := New(Options{
+		Driver:      DriverAMQP,
+		RoutingKey:  "orders.{{.Params.Tenant}}",
+		Template:    `{"id": {{json .Params.Id}}, "page": {{json .Query.page}}, "order": {{json .Body}}}`,
+		ContentType: "application/json",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := r.Render(TemplateData{
+		Params: map[string]string{"Tenant": "acme", "Id": "42"},
+		Query:  map[string]string{"page": "1"},
+		Body:   map[string]interface{}{"total": 10},
+	}, []byte("ignored"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Topic != "orders.acme" || m.ContentType != "application/json" {
+		t.Errorf("unexpected message %+v", m)
+	}
+	if string(m.Body) != `{"id": "42", "page": "1", "order": {"total":10}}` {
+		t.Errorf("unexpected body %s", m.Body)
+	}
+
+	r, err = New(Options{Driver: DriverNATS, Subject: "orders"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Templated() {
+		t.Error("the renderer should not be templated")
+	}
+	m, err = r.Render(TemplateData{}, []byte("raw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Topic != "orders" || string(m.Body) != "raw" {
+		t.Errorf("unexpected message %+v", m)
+	}
+
+	if _, err := New(Options{Driver: DriverNATS, Subject: "{{"}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+type dummyPublisher struct{ err error }
+
+func (d dummyPublisher) Publish(context.Context, Message) error { return d.err }
+func (dummyPublisher) Close() error                             { return nil }
+
+func TestRegisterDriver(t *testing.T) {
+	errPublish := errors.New("publish")
+	RegisterDriver("dummy", func(Options) (Publisher, error) { return dummyPublisher{errPublish}, nil })
+
+	pf, ok := GetDriver("dummy")
+	if !ok {
+		t.Fatal("the driver should be registered")
+	}
+	p, _ := pf(Options{})
+	if err := p.Publish(context.Background(), Message{}); err != errPublish {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := GetOptions(config.ExtraConfig{Namespace: map[string]interface{}{"driver": "dummy", "url": "dummy://"}}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
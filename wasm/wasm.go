@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package wasm defines an extension point for running WebAssembly modules as request and response
+processors.
+
+The package only provides the extension point: lura does not ship any WebAssembly runtime, not
+even the default "wazero" one, so the binaries embedding lura must register the runtimes they
+want to support with RegisterRuntime, usually as a thin adapter over wazero implementing the ABI
+below. The pipes declaring a module for a runtime not registered are rejected, so the modules
+are never skipped.
+
+Runtimes must expose the following host functions to the guests, under the "lura" module, backed
+by the Exchange of the current call:
+
+	get_method(buf, buf_len) -> len
+	get_path(buf, buf_len) -> len
+	get_header(name, name_len, buf, buf_len) -> len
+	set_header(name, name_len, value, value_len)
+	del_header(name, name_len)
+	get_body(buf, buf_len) -> len
+	set_body(buf, buf_len)
+	get_status() -> status
+	set_status(status)
+	get_config(buf, buf_len) -> len
+
+All the buffers are (pointer, length) pairs in the guest memory. The getters return the length
+of the value, so the guests can retry with a bigger buffer if the returned length exceeds the
+size of the received one (the content is not written in that case). Missing headers return -1.
+
+The guests export the processors as functions without params returning an i32:
+
+	on_request() -> i32
+	on_response() -> i32
+
+Returning 0 continues the pipe. Any other value aborts it, using the value as the status code of
+the response if it is a valid one.
+*/
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/register"
+)
+
+// Namespace is the key to use to store and access the wasm config
+const Namespace = "github.com/luraproject/lura/wasm"
+
+// DefaultRuntime is the name of the runtime used when the config does not declare one
+const DefaultRuntime = "wazero"
+
+const (
+	// OnRequest is the name of the exported function processing the requests
+	OnRequest = "on_request"
+	// OnResponse is the name of the exported function processing the responses
+	OnResponse = "on_response"
+)
+
+// ErrNoModule is returned when the config does not declare the module to load
+var ErrNoModule = errors.New("wasm: no module declared")
+
+// Exchange contains the request or response data the guest can read and write through the ABI
+type Exchange struct {
+	Method  string
+	Path    string
+	Headers http.Header
+	Body    []byte
+	Status  int
+	Config  []byte
+
+	bodyChanged bool
+}
+
+// GetHeader returns the first value of the header and whether it is present
+func (e *Exchange) GetHeader(name string) (string, bool) {
+	vs, ok := e.Headers[http.CanonicalHeaderKey(name)]
+	if !ok || len(vs) == 0 {
+		return "", false
+	}
+	return vs[0], true
+}
+
+// SetHeader replaces the values of the header
+func (e *Exchange) SetHeader(name, value string) {
+	if e.Headers == nil {
+		e.Headers = http.Header{}
+	}
+	e.Headers.Set(name, value)
+}
+
+// DelHeader removes the header
+func (e *Exchange) DelHeader(name string) {
+	e.Headers.Del(name)
+}
+
+// SetBody replaces the body and flags it as changed
+func (e *Exchange) SetBody(b []byte) {
+	e.Body = b
+	e.bodyChanged = true
+}
+
+// BodyChanged returns true if the guest replaced the body
+func (e *Exchange) BodyChanged() bool {
+	return e.bodyChanged
+}
+
+// Module is an instantiable WebAssembly module
+type Module interface {
+	// Exports returns true if the module exports the function
+	Exports(name string) bool
+	// Call executes the exported function with the ABI bound to the exchange
+	Call(ctx context.Context, name string, ex *Exchange) (int32, error)
+	// Close releases the resources of the module
+	Close(ctx context.Context) error
+}
+
+// Runtime compiles WebAssembly modules
+type Runtime interface {
+	Compile(ctx context.Context, name string, code []byte) (Module, error)
+}
+
+// RuntimeFunc type is an adapter to allow the use of ordinary functions as runtimes
+type RuntimeFunc func(ctx context.Context, name string, code []byte) (Module, error)
+
+// Compile implements the Runtime interface
+func (f RuntimeFunc) Compile(ctx context.Context, name string, code []byte) (Module, error) {
+	return f(ctx, name, code)
+}
+
+var runtimes = register.NewUntyped()
+
+// RegisterRuntime adds a runtime to the package register
+func RegisterRuntime(name string, r Runtime) {
+	runtimes.Register(name, r)
+}
+
+// GetRuntime returns the runtime registered with the name
+func GetRuntime(name string) (Runtime, bool) {
+	v, ok := runtimes.Get(name)
+	if !ok {
+		return nil, false
+	}
+	r, ok := v.(Runtime)
+	return r, ok
+}
+
+// Config is the wasm configuration of a pipe
+type Config struct {
+	Runtime string
+	Module  string
+	Config  map[string]interface{}
+}
+
+// ConfigGetter parses the wasm config from an extra config
+func ConfigGetter(e config.ExtraConfig) (Config, bool) {
+	cfg := Config{Runtime: DefaultRuntime}
+	tmp, ok := e[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false
+	}
+	if r, ok := tmp["runtime"].(string); ok && r != "" {
+		cfg.Runtime = r
+	}
+	cfg.Module, _ = tmp["module"].(string)
+	cfg.Config, _ = tmp["config"].(map[string]interface{})
+	return cfg, true
+}
+
+// Validate returns an error if the extra config declares a module without its path or for a
+// runtime not registered
+func Validate(e config.ExtraConfig) error {
+	cfg, ok := ConfigGetter(e)
+	if !ok {
+		return nil
+	}
+	if cfg.Module == "" {
+		return ErrNoModule
+	}
+	if _, ok := GetRuntime(cfg.Runtime); !ok {
+		return fmt.Errorf("wasm: unknown runtime %s", cfg.Runtime)
+	}
+	return nil
+}
+
+var (
+	modules   = map[string]Module{}
+	modulesMu sync.Mutex
+)
+
+// Load compiles the module declared in the config with its runtime. The modules are compiled
+// once per runtime and path, so several pipes can share them
+func Load(ctx context.Context, cfg Config) (Module, error) {
+	if cfg.Module == "" {
+		return nil, ErrNoModule
+	}
+	r, ok := GetRuntime(cfg.Runtime)
+	if !ok {
+		return nil, fmt.Errorf("wasm: unknown runtime %s", cfg.Runtime)
+	}
+
+	key := cfg.Runtime + ":" + cfg.Module
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	if m, ok := modules[key]; ok {
+		return m, nil
+	}
+	code, err := os.ReadFile(cfg.Module)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.Compile(ctx, cfg.Module, code)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: compiling %s: %w", cfg.Module, err)
+	}
+	modules[key] = m
+	return m, nil
+}
+
+// AbortError is returned when a guest aborts the pipe
+type AbortError struct {
+	Function string
+	Code     int32
+}
+
+// Error returns a string representation of the AbortError
+func (a AbortError) Error() string {
+	return fmt.Sprintf("wasm: %s aborted the pipe with code %d", a.Function, a.Code)
+}
+
+// StatusCode returns the code returned by the guest if it is a valid http status code or
+// a 500 Internal Server Error
+func (a AbortError) StatusCode() int {
+	if a.Code >= 100 && a.Code <= 599 {
+		return int(a.Code)
+	}
+	return http.StatusInternalServerError
+}
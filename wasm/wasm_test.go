@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wasm
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+type fakeModule struct{}
+
+func (fakeModule) Exports(name string) bool { return name == OnRequest }
+func (fakeModule) Call(_ context.Context, _ string, _ *Exchange) (int32, error) {
+	return 0, nil
+}
+func (fakeModule) Close(_ context.Context) error { return nil }
+
+func TestLoad(t *testing.T) {
+	compilations := 0
+	RegisterRuntime("load-test", RuntimeFunc(func(_ context.Context, _ string, code []byte) (Module, error) {
+		compilations++
+		if string(code) != "\x00asm" {
+			t.Errorf("unexpected code: %q", code)
+		}
+		return fakeModule{}, nil
+	}))
+
+	path := filepath.Join(t.TempDir(), "module.wasm")
+	if err := os.WriteFile(path, []byte("\x00asm"), 0o600); err != nil {
+		t.Error(err)
+		return
+	}
+
+	cfg, ok := ConfigGetter(config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"runtime": "load-test",
+			"module":  path,
+			"config":  map[string]interface{}{"a": 1},
+		},
+	})
+	if !ok || cfg.Runtime != "load-test" || cfg.Module != path || cfg.Config["a"] != 1 {
+		t.Errorf("unexpected config: %+v", cfg)
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := Load(context.Background(), cfg); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if compilations != 1 {
+		t.Errorf("the module should be compiled once. got %d compilations", compilations)
+	}
+
+	if _, err := Load(context.Background(), Config{Runtime: "load-test"}); err != ErrNoModule {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := Load(context.Background(), Config{Runtime: "unknown", Module: path}); err == nil {
+		t.Error("expecting an error for the unknown runtime")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	ex := &Exchange{}
+	ex.SetHeader("x-custom", "a")
+	if v, ok := ex.GetHeader("X-Custom"); !ok || v != "a" {
+		t.Errorf("unexpected header: %s", v)
+	}
+	ex.DelHeader("X-Custom")
+	if _, ok := ex.GetHeader("X-Custom"); ok {
+		t.Error("the header should be removed")
+	}
+	if ex.BodyChanged() {
+		t.Error("the body has not been changed")
+	}
+	ex.SetBody([]byte("{}"))
+	if !ex.BodyChanged() {
+		t.Error("the body has been changed")
+	}
+}
+
+func TestAbortError(t *testing.T) {
+	if s := (AbortError{Code: 403}).StatusCode(); s != http.StatusForbidden {
+		t.Errorf("unexpected status code: %d", s)
+	}
+	if s := (AbortError{Code: 1}).StatusCode(); s != http.StatusInternalServerError {
+		t.Errorf("unexpected status code: %d", s)
+	}
+}